@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+)
+
+// apiResponse 服务端统一的 JSON 响应结构
+type apiResponse struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	DocURL  string          `json:"doc_url"`
+}
+
+// apiError 服务端返回的业务错误
+type apiError struct {
+	Status  int
+	Code    int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("server returned %d (code %d): %s", e.Status, e.Code, e.Message)
+}
+
+// client 调用 /api/v1 接口的 HTTP 客户端,token 可以是个人访问令牌或登录获得的 JWT
+type client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func newClient(baseURL, token string) *client {
+	return &client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		// 上传和下载的耗时取决于文件大小,不设置整体超时
+		http: &http.Client{Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			ResponseHeaderTimeout: 5 * time.Minute,
+		}},
+	}
+}
+
+// newRequest 创建带认证头的请求,path 为 /api/v1 之后的部分
+func (c *client) newRequest(method, path string, query url.Values, body io.Reader) (*http.Request, error) {
+	u := c.baseURL + "/api/v1" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	return req, nil
+}
+
+// doJSON 发送 JSON 请求并把响应中的 data 解析到 out,out 为 nil 时忽略 data
+func (c *client) doJSON(method, path string, query url.Values, in any, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := c.newRequest(method, path, query, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.do(req, out)
+}
+
+// do 发送请求并解析统一响应结构,业务码不是成功码时返回 *apiError
+func (c *client) do(req *http.Request, out any) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeResponse(resp, out)
+}
+
+func decodeResponse(resp *http.Response, out any) error {
+	var result apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("unexpected response from server (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if result.Code != xerr.SuccessCode {
+		return &apiError{Status: resp.StatusCode, Code: result.Code, Message: result.Message}
+	}
+	if out == nil || len(result.Data) == 0 || string(result.Data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(result.Data, out)
+}
+
+// stat 按绝对路径查询文件或文件夹,根目录返回 nil
+func (c *client) stat(remotePath string) (*models.File, error) {
+	remotePath = cleanRemotePath(remotePath)
+	if remotePath == "/" {
+		return nil, nil
+	}
+	var file models.File
+	if err := c.doJSON(http.MethodGet, "/files/by-path", url.Values{"path": {remotePath}}, nil, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", remotePath, err)
+	}
+	return &file, nil
+}
+
+// folderID 返回远程文件夹的ID,根目录返回 nil
+func (c *client) folderID(remotePath string) (*uint64, error) {
+	folder, err := c.stat(remotePath)
+	if err != nil || folder == nil {
+		return nil, err
+	}
+	if folder.IsFolder != 1 {
+		return nil, fmt.Errorf("%s: not a folder", remotePath)
+	}
+	return &folder.ID, nil
+}
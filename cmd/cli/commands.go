@@ -0,0 +1,257 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+)
+
+// listPageSize ls 每次请求的条数,服务端允许的最大值
+const listPageSize = 500
+
+// newFlagSet 创建子命令的参数解析器,参数错误时返回 errUsage 而不是直接退出
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+func runList(c *client, args []string) error {
+	fs := newFlagSet("ls")
+	long := fs.Bool("l", false, "show ID, size and modification time")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 1 {
+		return errUsage
+	}
+	remotePath := "/"
+	if fs.NArg() == 1 {
+		remotePath = fs.Arg(0)
+	}
+
+	parentID, err := c.folderID(remotePath)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+	for page := 1; ; page++ {
+		query := url.Values{
+			"page":      {strconv.Itoa(page)},
+			"page_size": {strconv.Itoa(listPageSize)},
+		}
+		if parentID != nil {
+			query.Set("parent_id", strconv.FormatUint(*parentID, 10))
+		}
+		var result struct {
+			Files []models.File `json:"files"`
+			Total int64         `json:"total"`
+		}
+		if err := c.doJSON(http.MethodGet, "/files", query, nil, &result); err != nil {
+			return err
+		}
+
+		for _, file := range result.Files {
+			name := file.FileName
+			if file.IsFolder == 1 {
+				name += "/"
+			}
+			if *long {
+				fmt.Fprintf(w, "%d\t%d\t%s\t%s\n", file.ID, file.Size, file.UpdatedAt.Format("2006-01-02 15:04"), name)
+			} else {
+				fmt.Fprintln(w, name)
+			}
+		}
+		if len(result.Files) == 0 || int64(page*listPageSize) >= result.Total {
+			return nil
+		}
+	}
+}
+
+func runUpload(c *client, args []string) error {
+	fs := newFlagSet("upload")
+	mode := fs.String("mode", "", "what to do when a file with the same name exists: version, rename, overwrite or skip")
+	if err := fs.Parse(args); err != nil || fs.NArg() < 2 {
+		return errUsage
+	}
+	localPaths, remoteFolder := fs.Args()[:fs.NArg()-1], fs.Arg(fs.NArg()-1)
+
+	parentID, err := c.folderID(remoteFolder)
+	if err != nil {
+		return err
+	}
+	for _, localPath := range localPaths {
+		result, err := c.upload(localPath, parentID, *mode)
+		if err != nil {
+			return fmt.Errorf("%s: %w", localPath, err)
+		}
+		fmt.Printf("%s\t%s\t%d\n", result.Action, path.Join(cleanRemotePath(remoteFolder), result.FileName), result.ID)
+	}
+	return nil
+}
+
+func runDownload(c *client, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errUsage
+	}
+	file, err := c.stat(args[0])
+	if err != nil {
+		return err
+	}
+	if file == nil {
+		return fmt.Errorf("cannot download the root folder")
+	}
+
+	// 文件夹打包为 ZIP 下载
+	endpoint := fmt.Sprintf("/files/download/%d", file.ID)
+	localPath := file.FileName
+	if file.IsFolder == 1 {
+		endpoint = fmt.Sprintf("/files/download/folder/%d", file.ID)
+		localPath += ".zip"
+	}
+	if len(args) == 2 {
+		localPath = args[1]
+		if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+			localPath = filepath.Join(localPath, filepath.Base(file.FileName))
+		}
+	}
+
+	req, err := c.newRequest(http.MethodGet, endpoint, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return decodeResponse(resp, nil)
+	}
+
+	// 先写入临时文件,下载完整后再替换目标文件,避免中断后留下不完整的文件
+	tmp, err := os.CreateTemp(filepath.Dir(localPath), "."+filepath.Base(localPath)+".*.part")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("download interrupted after %d bytes: %w", n, err)
+	}
+	if err := os.Rename(tmp.Name(), localPath); err != nil {
+		return err
+	}
+	fmt.Printf("%s\t%d bytes\n", localPath, n)
+	return nil
+}
+
+func runMove(c *client, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	file, err := c.stat(args[0])
+	if err != nil {
+		return err
+	}
+	if file == nil {
+		return fmt.Errorf("cannot move the root folder")
+	}
+	targetID, err := c.folderID(args[1])
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		FileInfo models.File `json:"file_info"`
+	}
+	if err := c.doJSON(http.MethodPut, "/files/move", nil, map[string]any{
+		"file_id":                 file.ID,
+		"target_parent_folder_id": targetID,
+	}, &result); err != nil {
+		return err
+	}
+	fmt.Println(path.Join(result.FileInfo.Path, result.FileInfo.FileName))
+	return nil
+}
+
+func runRemove(c *client, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	for _, remotePath := range args {
+		file, err := c.stat(remotePath)
+		if err != nil {
+			return err
+		}
+		if file == nil {
+			return fmt.Errorf("cannot remove the root folder")
+		}
+		// 移入回收站,可以在网页端恢复
+		if err := c.doJSON(http.MethodDelete, fmt.Sprintf("/files/softdelete/%d", file.ID), nil, nil, nil); err != nil {
+			return fmt.Errorf("%s: %w", remotePath, err)
+		}
+		fmt.Println(cleanRemotePath(remotePath))
+	}
+	return nil
+}
+
+func runShare(c *client, args []string) error {
+	if len(args) == 0 || args[0] != "create" {
+		return errUsage
+	}
+	fs := newFlagSet("share create")
+	password := fs.String("password", "", "password required to open the share")
+	expires := fs.Int("expires", 0, "expire the share after this many minutes, 0 for never")
+	direct := fs.Bool("direct", false, "create a direct link that can be embedded without authentication")
+	if err := fs.Parse(args[1:]); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+
+	file, err := c.stat(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if file == nil {
+		return fmt.Errorf("cannot share the root folder")
+	}
+
+	body := map[string]any{
+		"file_id": file.ID,
+		"direct":  *direct,
+	}
+	if *password != "" {
+		body["password"] = *password
+	}
+	if *expires > 0 {
+		body["expires_in_minutes"] = *expires
+	}
+	var result struct {
+		ShareURL  string `json:"share_url"`
+		DirectURL string `json:"direct_url"`
+	}
+	if err := c.doJSON(http.MethodPost, "/shares/", nil, body, &result); err != nil {
+		return err
+	}
+	fmt.Println(result.ShareURL)
+	if result.DirectURL != "" {
+		fmt.Println(result.DirectURL)
+	}
+	return nil
+}
+
+// cleanRemotePath 把远程路径规范为以 "/" 开头的绝对路径
+func cleanRemotePath(remotePath string) string {
+	return path.Clean("/" + remotePath)
+}
@@ -0,0 +1,88 @@
+// clouddisk 命令行客户端,使用个人访问令牌或 JWT 访问云盘接口,便于脚本和 CI 流水线使用。
+//
+// 用法:
+//
+//	clouddisk [-server URL] [-token TOKEN] <command> [arguments]
+//
+// 服务地址和令牌也可以通过环境变量 CLOUDDISK_SERVER 和 CLOUDDISK_TOKEN 提供。
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+const defaultServer = "http://localhost:8080"
+
+// command 子命令,args 为子命令名之后的参数
+type command struct {
+	usage string
+	run   func(c *client, args []string) error
+}
+
+var commands = map[string]command{
+	"ls":       {"ls [-l] [remote-folder]", runList},
+	"upload":   {"upload [-mode version|rename|overwrite|skip] <local-file>... <remote-folder>", runUpload},
+	"download": {"download <remote-path> [local-path]", runDownload},
+	"mv":       {"mv <remote-path> <remote-folder>", runMove},
+	"rm":       {"rm <remote-path>...", runRemove},
+	"share":    {"share create [-password P] [-expires MINUTES] [-direct] <remote-path>", runShare},
+}
+
+// commandOrder 帮助信息中子命令的顺序
+var commandOrder = []string{"ls", "upload", "download", "mv", "rm", "share"}
+
+// errUsage 参数错误,打印子命令的用法
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	server := flag.String("server", envOr("CLOUDDISK_SERVER", defaultServer), "server base URL (env CLOUDDISK_SERVER)")
+	token := flag.String("token", os.Getenv("CLOUDDISK_TOKEN"), "personal access token or JWT (env CLOUDDISK_TOKEN)")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	name := flag.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "clouddisk: unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+	if *token == "" {
+		fmt.Fprintln(os.Stderr, "clouddisk: a token is required, pass -token or set CLOUDDISK_TOKEN")
+		os.Exit(2)
+	}
+
+	if err := cmd.run(newClient(*server, *token), flag.Args()[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(os.Stderr, "usage: clouddisk %s\n", cmd.usage)
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "clouddisk %s: %v\n", name, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "usage: clouddisk [-server URL] [-token TOKEN] <command> [arguments]")
+	fmt.Fprintln(out, "\ncommands:")
+	for _, name := range commandOrder {
+		fmt.Fprintf(out, "  %s\n", commands[name].usage)
+	}
+	fmt.Fprintln(out, "\nflags:")
+	flag.PrintDefaults()
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
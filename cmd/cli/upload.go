@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+)
+
+// upload 上传本地文件到远程文件夹。
+// 服务端按文件 MD5 记录未完成的上传任务,中断后重新执行同一命令会跳过已上传的分片继续上传
+func (c *client) upload(localPath string, parentFolderID *uint64, mode string) (*models.UploadCompleteResponse, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s: is a directory", localPath)
+	}
+
+	md5Hash, sha256Hash, err := hashFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash %s: %w", localPath, err)
+	}
+	fileName := filepath.Base(localPath)
+
+	var initResp models.UploadInitResponse
+	if err := c.doJSON(http.MethodPost, "/uploads/init", nil, models.UploadInitRequest{
+		FileName:   fileName,
+		FileHash:   md5Hash,
+		FileSize:   info.Size(),
+		FileSHA256: sha256Hash,
+	}, &initResp); err != nil {
+		return nil, fmt.Errorf("failed to init upload: %w", err)
+	}
+
+	// 服务端要求内容证明时先尝试秒传,未通过则继续正常上传
+	if !initResp.FileExists && initResp.ProofChallenge != nil {
+		proof, err := proveContent(f, initResp.ProofChallenge)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute upload proof: %w", err)
+		}
+		var proofResp models.UploadInitResponse
+		if err := c.doJSON(http.MethodPost, "/uploads/proof", nil, models.UploadProofRequest{
+			UploadID: initResp.UploadID,
+			Proof:    proof,
+		}, &proofResp); err == nil {
+			initResp = proofResp
+		}
+	}
+
+	// 秒传时存储中已有相同内容,直接完成上传
+	if !initResp.FileExists && initResp.Strategy != models.UploadStrategyInstant {
+		if err := c.uploadChunks(f, info.Size(), fileName, md5Hash, &initResp); err != nil {
+			return nil, err
+		}
+	}
+
+	var result models.UploadCompleteResponse
+	if err := c.doJSON(http.MethodPost, "/uploads/complete", nil, models.UploadCompleteRequest{
+		UploadID:       initResp.UploadID,
+		FileHash:       md5Hash,
+		FileName:       fileName,
+		MimeType:       mime.TypeByExtension(filepath.Ext(fileName)),
+		ParentFolderID: parentFolderID,
+		UploadMode:     mode,
+		FileSHA256:     sha256Hash,
+	}, &result); err != nil {
+		return nil, fmt.Errorf("failed to complete upload: %w", err)
+	}
+	return &result, nil
+}
+
+// uploadChunks 按服务端协商的分片大小上传尚未上传的分片
+func (c *client) uploadChunks(f *os.File, size int64, fileName, md5Hash string, initResp *models.UploadInitResponse) error {
+	chunkSize := initResp.ChunkSize
+	totalChunks := initResp.TotalChunks
+	if initResp.Strategy == models.UploadStrategySingle || chunkSize <= 0 {
+		chunkSize, totalChunks = size, 1
+	}
+	if totalChunks <= 0 {
+		totalChunks = max(1, (size+chunkSize-1)/chunkSize)
+	}
+
+	uploaded := make(map[int]bool, len(initResp.UploadedParts))
+	for _, part := range initResp.UploadedParts {
+		uploaded[part.PartNumber] = true
+	}
+	if len(uploaded) > 0 {
+		fmt.Fprintf(os.Stderr, "resuming upload: %d of %d chunks already uploaded\n", len(uploaded), totalChunks)
+	}
+
+	for chunkNumber := 1; int64(chunkNumber) <= totalChunks; chunkNumber++ {
+		if uploaded[chunkNumber] {
+			continue
+		}
+		offset := int64(chunkNumber-1) * chunkSize
+		length := min(chunkSize, size-offset)
+		chunk := io.NewSectionReader(f, offset, length)
+		if err := c.uploadChunk(chunk, length, chunkNumber, initResp.UploadID, fileName, md5Hash); err != nil {
+			return fmt.Errorf("failed to upload chunk %d/%d: %w", chunkNumber, totalChunks, err)
+		}
+		fmt.Fprintf(os.Stderr, "uploaded chunk %d/%d\n", chunkNumber, totalChunks)
+	}
+	return nil
+}
+
+func (c *client) uploadChunk(chunk io.Reader, length int64, chunkNumber int, uploadID, fileName, md5Hash string) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	fields := map[string]string{
+		"uploadID":    uploadID,
+		"chunkNumber": strconv.Itoa(chunkNumber),
+		"chunkSize":   strconv.FormatInt(length, 10),
+		"fileHash":    md5Hash,
+		"fileName":    fileName,
+	}
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			return err
+		}
+	}
+	part, err := form.CreateFormFile("chunk", fileName)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, chunk); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(http.MethodPost, "/uploads/chunk", nil, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return c.do(req, nil)
+}
+
+// hashFile 计算文件的 MD5 和 SHA-256,完成后把读取位置重置到开头
+func hashFile(f *os.File) (string, string, error) {
+	md5Hasher, sha256Hasher := md5.New(), sha256.New()
+	if _, err := io.Copy(io.MultiWriter(md5Hasher, sha256Hasher), f); err != nil {
+		return "", "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(md5Hasher.Sum(nil)), hex.EncodeToString(sha256Hasher.Sum(nil)), nil
+}
+
+// proveContent 按服务端的挑战计算 SHA-256(salt + 指定范围的文件内容)
+func proveContent(f *os.File, challenge *models.UploadProofChallenge) (string, error) {
+	salt, err := hex.DecodeString(challenge.Salt)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write(salt)
+	if _, err := io.Copy(h, io.NewSectionReader(f, challenge.Offset, challenge.Length)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
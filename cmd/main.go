@@ -29,6 +29,9 @@ func main() {
 	// 统一的日志输出
 	logger.Info("启动云盘程序...")
 
+	// 启用热更新时监听配置文件,运行时调整限流、用量提醒和预签名URL有效期
+	config.Watch(cfg)
+
 	// 创建并构建应用服务器实例
 	srv, err := server.NewServer(cfg)
 	if err != nil {
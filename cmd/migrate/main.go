@@ -0,0 +1,106 @@
+// migrate 执行和查看数据库表结构迁移。
+//
+// 用法:
+//
+//	migrate up       执行未执行的迁移
+//	migrate status   列出每个迁移的执行状态
+//	migrate verify   校验表结构版本,与当前版本不一致时以非零状态退出
+//
+// 服务配置 mysql.auto_migrate 为 false 时,需要在发布新版本前执行 migrate up
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/migrations"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/setup"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: migrate up|status|verify")
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0)); err != nil {
+		fmt.Fprintln(os.Stderr, "migrate:", err)
+		os.Exit(1)
+	}
+}
+
+func run(command string) error {
+	switch command {
+	case "up", "status", "verify":
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := os.MkdirAll("logs", 0755); err != nil {
+		return err
+	}
+	logger.InitLogger(cfg.Log.OutputPath, cfg.Log.ErrorPath, cfg.Log.Level)
+	defer logger.Sync()
+
+	db, err := setup.OpenMySQL(&cfg.MySQL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to MySQL: %w", err)
+	}
+	defer setup.CloseMySQLDB(db)
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		applied, err := migrations.Up(ctx, db)
+		for _, migration := range applied {
+			fmt.Printf("applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("database schema is up to date")
+		}
+		return nil
+	case "status":
+		statuses, err := migrations.Status(ctx, db)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, status := range statuses {
+			state, appliedAt := "pending", ""
+			if status.Applied {
+				state = "applied"
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			if status.Drift {
+				state = "drift"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+		}
+		return w.Flush()
+	default:
+		if err := migrations.Verify(ctx, db); err != nil {
+			return err
+		}
+		fmt.Println("database schema is up to date")
+		return nil
+	}
+}
@@ -5,19 +5,27 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/3Eeeecho/go-clouddisk/internal/config"
 	"github.com/3Eeeecho/go-clouddisk/internal/handlers"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
 	cacheConsumer "github.com/3Eeeecho/go-clouddisk/internal/pkg/cache/consumer"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache/filecache"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mail"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mq"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mq/worker"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/realtime"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/search"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/storage"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/tracing"
 	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
 	"github.com/3Eeeecho/go-clouddisk/internal/router"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/activity"
 	"github.com/3Eeeecho/go-clouddisk/internal/services/admin"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/authz"
 	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
 	"github.com/3Eeeecho/go-clouddisk/internal/services/share"
 	"github.com/3Eeeecho/go-clouddisk/internal/setup"
@@ -27,16 +35,30 @@ import (
 	"gorm.io/gorm"
 )
 
+// defaultShutdownTimeout 未配置 server.shutdown_timeout 时的关机等待时间
+const defaultShutdownTimeout = 30 * time.Second
+
 type Server struct {
-	router         *gin.Engine
-	httpServer     *http.Server
-	db             *gorm.DB
-	redisClient    *redis.Client
-	rabbitMQClient *mq.RabbitMQClient
+	router          *gin.Engine
+	httpServer      *http.Server
+	db              *gorm.DB
+	redisClient     *redis.Client
+	rabbitMQClient  *mq.RabbitMQClient
+	shutdownTimeout time.Duration
+	shutdownTracing func(context.Context) error // 上报缓冲中的 span
+
+	stopConsumers context.CancelFunc // 通知 Redis Stream 消费者和定时任务退出
+	consumers     sync.WaitGroup     // 正在运行的 Redis Stream 消费者和定时任务
 }
 
 // NewServer 负责构建所有依赖
 func NewServer(cfg *config.Config) (*Server, error) {
+	// 初始化链路追踪,需要在创建数据库、Redis 和 MQ 客户端之前完成
+	shutdownTracing, err := tracing.Init(context.Background(), &cfg.Tracing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+
 	// 初始化数据库连接
 	mysqlDB, err := setup.InitMySQL(&cfg.MySQL)
 	if err != nil {
@@ -49,9 +71,21 @@ func NewServer(cfg *config.Config) (*Server, error) {
 		return nil, fmt.Errorf("failed to initialize Redis: %w", err)
 	}
 
-	// 初始化Elasticsearch
-	// database.InitElasticsearchClient(&cfg.Elasticsearch)
-	// logger.Info("Elasticsearch client initialized.")
+	// 文件元数据的本地缓存需要在创建文件仓储之前启用
+	var localFileCache *filecache.LocalCache
+	if cfg.FileCache.LocalEnabled {
+		localFileCache = filecache.EnableLocalCache(cfg.FileCache.LocalMaxEntries, time.Duration(cfg.FileCache.LocalTTL)*time.Second)
+	}
+
+	// 开启内容搜索时初始化 Elasticsearch,并在索引不存在时创建
+	var contentIndex search.ContentIndex
+	if cfg.Search.Enabled {
+		setup.InitElasticsearchClient(&cfg.Elasticsearch)
+		contentIndex = search.NewContentIndex(setup.EsClient, cfg.Search.Index)
+		if err := contentIndex.EnsureIndex(context.Background()); err != nil {
+			return nil, fmt.Errorf("failed to initialize search index: %w", err)
+		}
+	}
 
 	//初始化rabbitmq
 	rabbitMQClient, err := mq.NewRabbitMQClient(cfg.RabbitMQ.URL)
@@ -62,12 +96,34 @@ func NewServer(cfg *config.Config) (*Server, error) {
 	//  初始化 Repositories
 	redisCache := cache.NewRedisCache(redisClient)
 	dbFileRepo := repositories.NewDBFileRepository(mysqlDB)
-	cacheFileRepo := repositories.NewCachedFileRepository(dbFileRepo, redisCache)
-	fileRepo := repositories.NewCachedFileRepository(cacheFileRepo, redisCache)
+	outboxRepo := repositories.NewOutboxRepository(mysqlDB)
+	fileRepo := repositories.NewCachedFileRepository(dbFileRepo, redisCache, outboxRepo)
 	userRepo := repositories.NewUserRepository(mysqlDB)
 	share_repo := repositories.NewShareRepository(mysqlDB)
 	fileVersionRepo := repositories.NewFileVersionRepository(mysqlDB)
 	uploadRepo := repositories.NewDBMultipartUploadRepository(mysqlDB)
+	activityRepo := repositories.NewActivityRepository(mysqlDB)
+	notificationRepo := repositories.NewNotificationRepository(mysqlDB)
+	permissionRepo := repositories.NewFilePermissionRepository(mysqlDB)
+	fileStatsRepo := repositories.NewFileStatsRepository(mysqlDB)
+	accessTokenRepo := repositories.NewAccessTokenRepository(mysqlDB)
+	oauthRepo := repositories.NewOAuthRepository(mysqlDB)
+	favoriteRepo := repositories.NewFileFavoriteRepository(mysqlDB)
+	tagRepo := repositories.NewFileTagRepository(mysqlDB)
+	commentRepo := repositories.NewFileCommentRepository(mysqlDB)
+	exportRepo := repositories.NewDataExportRepository(mysqlDB)
+	archiveRepo := repositories.NewArchiveJobRepository(mysqlDB)
+	extractRepo := repositories.NewExtractJobRepository(mysqlDB)
+	shareAccessRepo := repositories.NewShareAccessLogRepository(mysqlDB)
+	purgeRepo := repositories.NewPurgeJobRepository(mysqlDB)
+	recoveryCodeRepo := repositories.NewRecoveryCodeRepository(mysqlDB)
+	migrationRepo := repositories.NewStorageMigrationRepository(mysqlDB)
+	mediaRepo := repositories.NewMediaMetadataRepository(mysqlDB)
+	lifecycleRepo := repositories.NewLifecycleRuleRepository(mysqlDB)
+	orgRepo := repositories.NewOrganizationRepository(mysqlDB)
+	objectRepo := repositories.NewStorageObjectRepository(mysqlDB)
+	accountDeletionRepo := repositories.NewAccountDeletionRepository(mysqlDB)
+	downloadCountRepo := repositories.NewFileDownloadCountRepository(mysqlDB)
 
 	//初始化其他服务
 	cacheService := cache.NewRedisCache(redisClient)
@@ -76,36 +132,113 @@ func NewServer(cfg *config.Config) (*Server, error) {
 	if err != nil {
 		logger.Fatal("failed to initialize storageService", zap.Any("err", err))
 	}
+	bucketSelector, err := storage.NewBucketSelector(cfg)
+	if err != nil {
+		logger.Fatal("failed to initialize bucket sharding", zap.Error(err))
+	}
+	if err := storage.EnsureBuckets(context.Background(), ss, bucketSelector.Buckets()); err != nil {
+		logger.Fatal("failed to prepare storage buckets", zap.Error(err))
+	}
 
 	//  初始化 Services
-	domainService := explorer.NewFileDomainService(fileRepo)
+	activityService := activity.NewActivityService(activityRepo, outboxRepo)
+	notificationService := activity.NewNotificationService(notificationRepo, cacheService, cfg.Notification)
+	authorizer := authz.NewAuthorizer(permissionRepo, userRepo, orgRepo, fileRepo)
+	domainService := explorer.NewFileDomainService(fileRepo, authorizer, cfg.FileName)
+	lockService := explorer.NewFileLockService(cacheService, domainService)
+	mailer := mail.NewSender(cfg.Mail)
+	statsService := explorer.NewFileStatsService(fileRepo, fileStatsRepo, domainService, outboxRepo, userRepo, activityRepo, notificationService, mailer, cfg.Quota)
 	uploadService := explorer.NewUploadService(fileRepo, fileVersionRepo, uploadRepo, domainService, tm, ss, explorer.UploadServiceDeps{
 		Cache:    cacheService,
-		MQClient: rabbitMQClient,
+		Activity: activityService,
+		Lock:     lockService,
+		Stats:    statsService,
+		Users:    userRepo,
+		Config:   cfg,
+		Buckets:  bucketSelector,
+		Objects:  objectRepo,
+	})
+	sessionService := admin.NewSessionService(userRepo, cacheService, &cfg.JWT)
+	authService := admin.NewAuthService(userRepo, recoveryCodeRepo, sessionService, cacheService, mailer, &cfg.JWT, &cfg.Mail)
+	purgeService := explorer.NewPurgeService(purgeRepo, fileRepo, fileVersionRepo, objectRepo, tm, lockService, activityService, statsService, ss, authorizer, cfg)
+	downloadCountService := explorer.NewDownloadCountService(downloadCountRepo, cacheService)
+	fileService := explorer.NewFileService(fileRepo, fileVersionRepo, userRepo, domainService, tm, ss, outboxRepo, activityService, notificationService, lockService, statsService, purgeService, downloadCountService, authorizer, cfg)
+	shareService := share.NewShareService(share_repo, fileRepo, fileService, domainService, activityService, notificationService, userRepo, redisCache, mailer, authorizer, cfg)
+	shareAnalyticsService := share.NewShareAnalyticsService(shareAccessRepo, share_repo, outboxRepo, authorizer, downloadCountService)
+	userService := admin.NewUserService(userRepo, cfg)
+	accessTokenService := admin.NewAccessTokenService(accessTokenRepo)
+	oauthService := admin.NewOAuthService(oauthRepo, redisCache, &cfg.OAuth)
+	previewService := explorer.NewPreviewService(domainService, ss, cfg)
+	permissionService := explorer.NewPermissionService(permissionRepo, userRepo, domainService, authorizer)
+	transferService := explorer.NewTransferService(fileRepo, userRepo, fileStatsRepo, domainService, tm, redisCache, activityService, notificationService, statsService, cfg)
+	favoriteService := explorer.NewFavoriteService(favoriteRepo, domainService, redisCache)
+	cacheWarmService := explorer.NewCacheWarmService(fileRepo, favoriteService, redisCache, &cfg.CacheWarm)
+	tagService := explorer.NewTagService(tagRepo, domainService)
+	commentService := explorer.NewCommentService(commentRepo, userRepo, domainService, activityService, authorizer)
+	officeService := explorer.NewOfficeService(fileRepo, fileVersionRepo, userRepo, domainService, tm, ss, explorer.UploadServiceDeps{
+		Cache:    cacheService,
+		Activity: activityService,
+		Lock:     lockService,
+		Stats:    statsService,
+		Users:    userRepo,
 		Config:   cfg,
+		Buckets:  bucketSelector,
+		Objects:  objectRepo,
+	})
+	exportService := explorer.NewExportService(exportRepo, fileRepo, activityRepo, fileService, ss, tm, notificationService, cfg)
+	archiveService := explorer.NewArchiveService(archiveRepo, fileRepo, domainService, fileService, ss, tm, notificationService, cfg)
+	extractService := explorer.NewExtractService(extractRepo, fileRepo, fileStatsRepo, domainService, tm, ss, notificationService, explorer.UploadServiceDeps{
+		Cache:   cacheService,
+		Stats:   statsService,
+		Users:   userRepo,
+		Config:  cfg,
+		Buckets: bucketSelector,
+		Objects: objectRepo,
 	})
-	authService := admin.NewAuthService(userRepo, &cfg.JWT)
-	fileService := explorer.NewFileService(fileRepo, fileVersionRepo, domainService, tm, ss, rabbitMQClient, cfg)
-	shareService := share.NewShareService(share_repo, fileRepo, fileService, domainService, cfg)
-	userService := admin.NewUserService(userRepo)
+	bandwidthService := admin.NewBandwidthService(userRepo, share_repo, &cfg.Download)
+	migrationService := explorer.NewStorageMigrationService(migrationRepo, fileRepo, tm, ss, bucketSelector, outboxRepo, cfg)
+	galleryService := explorer.NewGalleryService(mediaRepo, fileRepo, ss)
+	searchService := explorer.NewSearchService(fileRepo, ss, contentIndex, cfg)
+	lifecycleService := explorer.NewLifecycleService(lifecycleRepo, fileService, domainService, authorizer, redisCache, &cfg.Lifecycle)
+	organizationService := explorer.NewOrganizationService(orgRepo, userRepo, fileService, statsService, domainService, authorizer, &cfg.Organization)
+	accountDeletionService := explorer.NewAccountDeletionService(accountDeletionRepo, userRepo, orgRepo, tm, purgeService, sessionService, mailer)
+	integrityService := explorer.NewIntegrityService(fileRepo, ss, activityService, cfg)
 
 	//  初始化 Handlers
-	authHandler := handlers.NewAuthHandler(authService, cfg)
-	fileHandler := handlers.NewFileHandler(fileService, cfg)
-	shareHandler := handlers.NewShareHandler(shareService, cfg)
+	authHandler := handlers.NewAuthHandler(authService, sessionService, cacheWarmService, cfg)
+	fileHandler := handlers.NewFileHandler(fileService, lockService, previewService, statsService, bandwidthService, tagService, purgeService, cacheWarmService, cfg)
+	shareHandler := handlers.NewShareHandler(shareService, bandwidthService, shareAnalyticsService, cfg)
 	uploadHandler := handlers.NewUploadHandler(uploadService)
-	userHandler := handlers.NewUserHandler(userService)
+	userHandler := handlers.NewUserHandler(userService, accountDeletionService)
+	activityHandler := handlers.NewActivityHandler(activityService)
+	permissionHandler := handlers.NewPermissionHandler(permissionService)
+	accessTokenHandler := handlers.NewAccessTokenHandler(accessTokenService)
+	oauthHandler := handlers.NewOAuthHandler(oauthService)
+	signedDownloadHandler := handlers.NewSignedDownloadHandler(ss, bandwidthService, cfg)
+	transferHandler := handlers.NewTransferHandler(transferService)
+	deadLetterService := admin.NewDeadLetterService(redisClient)
+	adminHandler := handlers.NewAdminHandler(bandwidthService, deadLetterService, migrationService, accountDeletionService, integrityService)
+	favoriteHandler := handlers.NewFavoriteHandler(favoriteService)
+	tagHandler := handlers.NewTagHandler(tagService)
+	commentHandler := handlers.NewCommentHandler(commentService)
+	officeHandler := handlers.NewOfficeHandler(officeService)
+	archiveHandler := handlers.NewArchiveHandler(archiveService)
+	extractHandler := handlers.NewExtractHandler(extractService)
+	exportHandler := handlers.NewExportHandler(exportService)
+	galleryHandler := handlers.NewGalleryHandler(galleryService)
+	searchHandler := handlers.NewSearchHandler(searchService)
+	lifecycleHandler := handlers.NewLifecycleHandler(lifecycleService)
+	organizationHandler := handlers.NewOrganizationHandler(organizationService)
+	notificationHandler := handlers.NewNotificationHandler(notificationService)
+	realtimeHub := realtime.NewHub(cfg.WebSocket.MaxConnections)
+	webSocketHandler := handlers.NewWebSocketHandler(realtimeHub, cfg.WebSocket)
 
 	// 启动所有后台 Worker
-	worker.StartAllWorkers(config.AppConfig, rabbitMQClient, fileRepo, fileVersionRepo, tm, ss)
-
-	// 启动 Redis Stream 消费者
-	go cacheConsumer.StartCacheUpdateConsumer(context.Background(), redisClient)
-	go cacheConsumer.StartPathInvalidationConsumer(context.Background(), mysqlDB, redisClient)
+	worker.StartAllWorkers(config.AppConfig, rabbitMQClient, fileRepo, fileVersionRepo, objectRepo, activityRepo, tm, ss, favoriteService, exportService, archiveService, extractService, shareAccessRepo, purgeService, migrationService, galleryService, searchService, accountDeletionService)
 
 	// 初始化 Gin 引擎和注册路由
 	// 将所有依赖传入 RouterConfig
-	engine := router.InitRouter(authHandler, fileHandler, shareHandler, uploadHandler, userHandler, cfg)
+	engine := router.InitRouter(authHandler, fileHandler, shareHandler, uploadHandler, userHandler, activityHandler, permissionHandler, accessTokenHandler, transferHandler, adminHandler, favoriteHandler, tagHandler, commentHandler, officeHandler, archiveHandler, extractHandler, exportHandler, oauthHandler, signedDownloadHandler, galleryHandler, searchHandler, lifecycleHandler, organizationHandler, notificationHandler, webSocketHandler, accessTokenService, oauthService, sessionService, userService, authorizer, redisCache, cfg)
 
 	// 启动 HTTP 服务器
 	addr := ":" + config.AppConfig.Server.Port
@@ -115,22 +248,132 @@ func NewServer(cfg *config.Config) (*Server, error) {
 		Handler: engine,
 	}
 
-	return &Server{
-		router:         engine,
-		httpServer:     httpServer,
-		db:             mysqlDB,
-		redisClient:    redisClient,
-		rabbitMQClient: rabbitMQClient,
-	}, nil
+	shutdownTimeout := time.Duration(cfg.Server.ShutdownTimeout) * time.Second
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = defaultShutdownTimeout
+	}
+
+	s := &Server{
+		router:          engine,
+		httpServer:      httpServer,
+		db:              mysqlDB,
+		redisClient:     redisClient,
+		rabbitMQClient:  rabbitMQClient,
+		shutdownTimeout: shutdownTimeout,
+		shutdownTracing: shutdownTracing,
+	}
+
+	// 启动 Redis Stream 消费者
+	consumerCtx, stopConsumers := context.WithCancel(context.Background())
+	s.stopConsumers = stopConsumers
+	s.consumers.Add(12)
+	go func() {
+		defer s.consumers.Done()
+		cacheConsumer.StartCacheUpdateConsumer(consumerCtx, redisClient)
+	}()
+	go func() {
+		defer s.consumers.Done()
+		cacheConsumer.StartFileStatsConsumer(consumerCtx, redisClient, statsService)
+	}()
+	s.consumers.Add(1)
+	go func() {
+		defer s.consumers.Done()
+		cacheConsumer.StartFolderPathConsumer(consumerCtx, redisClient)
+	}()
+
+	// WebSocket 事件转发,每个实例都会收到全部消息,关机时断开所有连接
+	if cfg.WebSocket.Enabled {
+		s.consumers.Add(1)
+		go func() {
+			defer s.consumers.Done()
+			realtimeHub.Run(consumerCtx, redisClient, explorer.FileStatsStreamName)
+		}()
+	}
+
+	// 本地缓存订阅失效消息,每个实例都会收到全部消息
+	if localFileCache != nil {
+		s.consumers.Add(1)
+		go func() {
+			defer s.consumers.Done()
+			localFileCache.Watch(consumerCtx, redisClient)
+		}()
+	}
+
+	// 历史版本保留策略,删除任务写入发件箱
+	retentionWorker := worker.NewVersionRetentionWorker(outboxRepo, fileVersionRepo, cfg.Version.Retention)
+	go func() {
+		defer s.consumers.Done()
+		retentionWorker.Run(consumerCtx)
+	}()
+
+	// 失效分享清理,活动日志通过 MQ 写入,同样需要在关闭 MQ 连接之前停止
+	shareCleanupWorker := worker.NewShareCleanupWorker(share_repo, activityService, cfg.Share.Cleanup)
+	go func() {
+		defer s.consumers.Done()
+		shareCleanupWorker.Run(consumerCtx)
+	}()
+
+	// 过期上传会话清理
+	uploadCleanupWorker := worker.NewUploadCleanupWorker(uploadService, cfg.Upload.Cleanup)
+	go func() {
+		defer s.consumers.Done()
+		uploadCleanupWorker.Run(consumerCtx)
+	}()
+
+	// 过期的账户数据导出清理
+	exportCleanupWorker := worker.NewExportCleanupWorker(exportService, cfg.Export)
+	go func() {
+		defer s.consumers.Done()
+		exportCleanupWorker.Run(consumerCtx)
+	}()
+
+	// 过期的文件夹打包清理
+	archiveCleanupWorker := worker.NewArchiveCleanupWorker(archiveService, cfg.Archive)
+	go func() {
+		defer s.consumers.Done()
+		archiveCleanupWorker.Run(consumerCtx)
+	}()
+
+	// 存储完整性校验,发现损坏时记录活动日志并通知管理员
+	integrityWorker := worker.NewIntegrityWorker(fileRepo, integrityService, activityService, mailer, cfg)
+	go func() {
+		defer s.consumers.Done()
+		integrityWorker.Run(consumerCtx)
+	}()
+
+	// 文件夹生命周期规则,定时归档或删除长时间未修改的文件
+	lifecycleWorker := worker.NewLifecycleWorker(lifecycleService, cfg.Lifecycle)
+	go func() {
+		defer s.consumers.Done()
+		lifecycleWorker.Run(consumerCtx)
+	}()
+
+	// 回收站保留期,过期文件的彻底删除任务写入发件箱
+	trashExpirationWorker := worker.NewTrashExpirationWorker(purgeService, cfg.Trash)
+	go func() {
+		defer s.consumers.Done()
+		trashExpirationWorker.Run(consumerCtx)
+	}()
+
+	// 下载次数写入数据库,关机时最后写入一次,需要在关闭 Redis 连接之前停止
+	downloadCountWorker := worker.NewDownloadCountWorker(downloadCountService, cfg.Download)
+	go func() {
+		defer s.consumers.Done()
+		downloadCountWorker.Run(consumerCtx)
+	}()
+
+	// 发件箱投递,需要在关闭 MQ 和 Redis 连接之前停止
+	outboxWorker := worker.NewOutboxWorker(outboxRepo, cacheService, rabbitMQClient, cfg.Outbox)
+	go func() {
+		defer s.consumers.Done()
+		outboxWorker.Run(consumerCtx)
+	}()
+
+	return s, nil
 }
 
 // Run 启动服务器和 Worker，并处理优雅关机
 func (s *Server) Run(ctx context.Context, stopChan chan os.Signal) {
-	// 确保在应用关闭时，所有连接都被释放
-	// GORM v2 依赖连接池，通常不需要手动关闭。Redis和MQ需要
-	defer s.rabbitMQClient.Close()
-	defer s.redisClient.Close()
-
 	// 启动 HTTP 服务器
 	go func() {
 		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -139,15 +382,70 @@ func (s *Server) Run(ctx context.Context, stopChan chan os.Signal) {
 	}()
 
 	// 等待停止信号
-	<-stopChan
-	logger.Info("Shutting down server...")
+	select {
+	case <-stopChan:
+	case <-ctx.Done():
+	}
+	logger.Info("Shutting down server...", zap.Duration("timeout", s.shutdownTimeout))
+
+	// 关机期间再次收到信号则立即退出
+	go func() {
+		<-stopChan
+		logger.Warn("Received second signal, exiting immediately")
+		os.Exit(1)
+	}()
 
-	// 优雅关机
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	s.Shutdown()
+	logger.Info("Server exited gracefully")
+}
+
+// Shutdown 按顺序优雅关机，所有等待共用 shutdownTimeout：
+// 1. 停止接收新请求，等待进行中的上传下载完成
+// 2. 停止 MQ 消费并等待已投递的消息处理完成
+// 3. 停止 Redis Stream 消费者，已读取的消息处理完并 XACK 后退出
+// 4. 依次关闭 MQ、Redis、MySQL 连接，后台任务都已退出，不会再有写入
+// 5. 上报剩余的链路追踪数据
+func (s *Server) Shutdown() {
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
 	defer cancel()
 
 	if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
-		logger.Fatal("Server forced to shutdown", zap.Error(err))
+		logger.Warn("Timed out waiting for in-flight requests, closing remaining connections", zap.Error(err))
+		_ = s.httpServer.Close()
+	} else {
+		logger.Info("All in-flight requests finished")
+	}
+
+	if err := s.rabbitMQClient.StopConsuming(shutdownCtx); err != nil {
+		logger.Warn("MQ consumers did not drain in time, unacked messages will be redelivered", zap.Error(err))
+	} else {
+		logger.Info("MQ consumers drained")
+	}
+
+	s.stopConsumers()
+	consumersDone := make(chan struct{})
+	go func() {
+		s.consumers.Wait()
+		close(consumersDone)
+	}()
+	select {
+	case <-consumersDone:
+		logger.Info("Redis stream consumers and scheduled tasks stopped")
+	case <-shutdownCtx.Done():
+		logger.Warn("Redis stream consumers did not stop in time, pending messages will be retried")
+	}
+
+	s.rabbitMQClient.Close()
+	if err := s.redisClient.Close(); err != nil {
+		logger.Error("Failed to close Redis client", zap.Error(err))
+	}
+	if sqlDB, err := s.db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			logger.Error("Failed to close MySQL connection", zap.Error(err))
+		}
+	}
+
+	if err := s.shutdownTracing(shutdownCtx); err != nil {
+		logger.Warn("Failed to flush pending spans", zap.Error(err))
 	}
-	logger.Info("Server exited gracefully")
 }
@@ -0,0 +1,120 @@
+// storage-migrate 在存储后端之间批量迁移文件对象,与管理接口使用同一个迁移任务记录,可以查看进度和继续。
+//
+// 用法:
+//
+//	storage-migrate -target minio [-delete-source]
+//	storage-migrate -rebalance [-delete-source]
+//	storage-migrate -resume 3
+//
+// 源后端和目标后端都需要在 storageconfig.type 或 storageconfig.backends 中启用。
+// -rebalance 按 storageconfig.sharding 把文件搬到分片策略选择的存储桶。
+// 按 Ctrl+C 中断后任务标记为失败,使用 -resume 从中断处继续
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/storage"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/3Eeeecho/go-clouddisk/internal/setup"
+)
+
+func main() {
+	target := flag.String("target", "", "storage backend to move objects to: minio, aliyun_oss, s3 or local")
+	deleteSource := flag.Bool("delete-source", false, "remove objects from the source backend once no file references them")
+	rebalance := flag.Bool("rebalance", false, "move objects into the buckets chosen by storageconfig.sharding")
+	resume := flag.Uint64("resume", 0, "continue an interrupted or failed migration by ID")
+	flag.Parse()
+	modes := 0
+	for _, set := range []bool{*target != "", *rebalance, *resume != 0} {
+		if set {
+			modes++
+		}
+	}
+	if modes != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*target, *rebalance, *deleteSource, *resume); err != nil {
+		fmt.Fprintln(os.Stderr, "storage-migrate:", err)
+		os.Exit(1)
+	}
+}
+
+func run(target string, rebalance, deleteSource bool, resumeID uint64) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := os.MkdirAll("logs", 0755); err != nil {
+		return err
+	}
+	logger.InitLogger(cfg.Log.OutputPath, cfg.Log.ErrorPath, cfg.Log.Level)
+	defer logger.Sync()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	db, err := setup.InitMySQL(&cfg.MySQL)
+	if err != nil {
+		return fmt.Errorf("failed to initialize MySQL: %w", err)
+	}
+	defer setup.CloseMySQLDB(db)
+	// 服务运行期间迁移时需要同步失效文件元数据缓存
+	redisClient, err := setup.InitRedis(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize Redis: %w", err)
+	}
+	defer redisClient.Close()
+	ss, err := storage.NewStorageService(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	bucketSelector, err := storage.NewBucketSelector(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize bucket sharding: %w", err)
+	}
+
+	fileRepo := repositories.NewCachedFileRepository(repositories.NewDBFileRepository(db), cache.NewRedisCache(redisClient), repositories.NewOutboxRepository(db))
+	migrationService := explorer.NewStorageMigrationService(repositories.NewStorageMigrationRepository(db), fileRepo, explorer.NewTransactionManager(db), ss, bucketSelector, nil, cfg)
+
+	var migration *models.StorageMigration
+	switch {
+	case resumeID != 0:
+		migration, err = migrationService.ResumeMigration(ctx, resumeID)
+	case rebalance:
+		migration, err = migrationService.StartRebalance(ctx, 0, deleteSource)
+	default:
+		migration, err = migrationService.StartMigration(ctx, 0, target, deleteSource)
+	}
+	if err != nil {
+		return err
+	}
+	if migration.Rebalance {
+		fmt.Printf("migration %d: rebalancing objects across %v\n", migration.ID, bucketSelector.Buckets())
+	} else {
+		fmt.Printf("migration %d: moving objects to %s (%s)\n", migration.ID, migration.TargetBackend, migration.TargetBucket)
+	}
+
+	if err := migrationService.ProcessMigration(ctx, migration.ID); err != nil {
+		return fmt.Errorf("migration %d stopped, run with -resume %d to continue: %w", migration.ID, migration.ID, err)
+	}
+	migration, err = migrationService.GetMigration(ctx, migration.ID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("migration %d %s: %d files, %d bytes migrated, %d files failed\n",
+		migration.ID, migration.Status, migration.MigratedFiles, migration.MigratedBytes, migration.FailedFiles)
+	return nil
+}
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"reflect"
+	"slices"
 	"strings"
 	"time"
 
@@ -15,21 +18,70 @@ type Config struct {
 	Redis         RedisConfig         `mapstructure:"redis"`
 	MinIO         MinIOConfig         `mapstructure:"minio"`
 	AliyunOSS     AliyunOSSConfig     `mapstructure:"aliyun_oss"`
+	S3            S3Config            `mapstructure:"s3"`
+	Local         LocalStorageConfig  `mapstructure:"local_storage"`
 	RabbitMQ      RabbitMQConfig      `mapstructure:"rabbitmq"`
 	JWT           JWTConfig           `mapstructure:"jwt"`
 	Storage       StorageConfig       `mapstructure:"storageconfig"`
 	Log           LogConfig           `mapstructure:"log"`
 	Elasticsearch ElasticsearchConfig `mapstructure:"elasticsearch"`
+	Share         ShareConfig         `mapstructure:"share"`
+	Preview       PreviewConfig       `mapstructure:"preview"`
+	Upload        UploadConfig        `mapstructure:"upload"`
+	RateLimit     RateLimitConfig     `mapstructure:"rate_limit"`
+	Version       VersionConfig       `mapstructure:"version"`
+	Scan          ScanConfig          `mapstructure:"scan"`
+	Transfer      TransferConfig      `mapstructure:"transfer"`
+	Tracing       TracingConfig       `mapstructure:"tracing"`
+	Metrics       MetricsConfig       `mapstructure:"metrics"`
+	Download      DownloadConfig      `mapstructure:"download"`
+	FileName      FileNameConfig      `mapstructure:"file_name"`
+	Export        ExportConfig        `mapstructure:"export"`
+	Mail          MailConfig          `mapstructure:"mail"`
+	Integrity     IntegrityConfig     `mapstructure:"integrity"`
+	Office        OfficeConfig        `mapstructure:"office"`
+	Archive       ArchiveConfig       `mapstructure:"archive"`
+	Extract       ExtractConfig       `mapstructure:"extract"`
+	OAuth         OAuthConfig         `mapstructure:"oauth"`
+	CacheWarm     CacheWarmConfig     `mapstructure:"cache_warm"`
+	FileCache     FileCacheConfig     `mapstructure:"file_cache"`
+	Quota         QuotaConfig         `mapstructure:"quota"`
+	Notification  NotificationConfig  `mapstructure:"notification"`
+	WebSocket     WebSocketConfig     `mapstructure:"websocket"`
+	Lifecycle     LifecycleConfig     `mapstructure:"lifecycle"`
+	Trash         TrashConfig         `mapstructure:"trash"`
+	Outbox        OutboxConfig        `mapstructure:"outbox"`
+	Reload        ReloadConfig        `mapstructure:"reload"`
+	Organization  OrganizationConfig  `mapstructure:"organization"`
+	API           APIConfig           `mapstructure:"api"`
+	Search        SearchConfig        `mapstructure:"search"`
 }
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Port string `mapstructure:"port"`
+	Port            string `mapstructure:"port"`
+	ShutdownTimeout int    `mapstructure:"shutdown_timeout"` // 优雅关机时等待进行中的请求和消费者的最长时间（秒）
 }
 
 // MySQLConfig 数据库配置
 type MySQLConfig struct {
 	DSN string `mapstructure:"dsn"`
+	// Replicas 只读从库的 DSN,为空时读写都走主库
+	Replicas []string `mapstructure:"replicas"`
+	// AutoMigrate 启动时自动执行未执行的迁移,关闭时只校验表结构版本,需要先运行 cmd/migrate
+	AutoMigrate     bool `mapstructure:"auto_migrate"`
+	MaxIdleConns    int  `mapstructure:"max_idle_conns"`     // 每个连接池的最大空闲连接数
+	MaxOpenConns    int  `mapstructure:"max_open_conns"`     // 每个连接池的最大打开连接数
+	ConnMaxLifetime int  `mapstructure:"conn_max_lifetime"`  // 连接最大复用时间(秒),0 表示不限制
+	ConnMaxIdleTime int  `mapstructure:"conn_max_idle_time"` // 连接最大空闲时间(秒),0 表示不限制
+	// SlowQuery 慢查询日志
+	SlowQuery SlowQueryConfig `mapstructure:"slow_query"`
+}
+
+// SlowQueryConfig 慢查询日志配置,日志中带有发起查询的路由和 handler
+type SlowQueryConfig struct {
+	Threshold  int     `mapstructure:"threshold"`   // 超过该耗时的查询记为慢查询（毫秒）,0 表示不记录
+	SampleRate float64 `mapstructure:"sample_rate"` // 慢查询日志的采样比例(0~1],0 表示全部记录
 }
 
 // RedisConfig Redis配置
@@ -46,6 +98,8 @@ type MinIOConfig struct {
 	SecretAccessKey string `mapstructure:"secret_access_key"`
 	UseSSL          bool   `mapstructure:"use_ssl"`
 	BucketName      string `mapstructure:"bucket_name"`
+	// Regions 其他地域的访问入口,预签名下载链接使用离客户端最近的入口,见 StorageRegionConfig
+	Regions []StorageRegionConfig `mapstructure:"regions"`
 }
 
 type AliyunOSSConfig struct {
@@ -54,6 +108,38 @@ type AliyunOSSConfig struct {
 	SecretAccessKey string `mapstructure:"secret_access_key"`
 	BucketName      string `mapstructure:"bucket_name"`
 	UseSSL          bool   `mapstructure:"use_ssl"` // OSS SDK 默认是HTTPS，但为了明确
+	// Regions 其他地域的访问入口,如传输加速域名,预签名下载链接使用离客户端最近的入口
+	Regions []StorageRegionConfig `mapstructure:"regions"`
+}
+
+// S3Config 通用 S3 兼容存储配置,适用于 AWS S3、Wasabi、Backblaze B2、Ceph RGW 等
+type S3Config struct {
+	Endpoint        string `mapstructure:"endpoint"` // 例如: s3.us-west-001.backblazeb2.com,为空时使用 AWS 官方地址
+	Region          string `mapstructure:"region"`
+	AccessKeyID     string `mapstructure:"access_key_id"`
+	SecretAccessKey string `mapstructure:"secret_access_key"`
+	BucketName      string `mapstructure:"bucket_name"`
+	UseSSL          bool   `mapstructure:"use_ssl"`
+	UsePathStyle    bool   `mapstructure:"use_path_style"` // Ceph RGW 等自建服务通常需要开启路径风格访问
+	// Regions 其他地域的访问入口,如多站点复制的存储桶,预签名下载链接使用离客户端最近的入口
+	Regions []StorageRegionConfig `mapstructure:"regions"`
+}
+
+// StorageRegionConfig 对象存储在某个地域的访问入口。生成预签名下载链接时先按客户端在 X-Client-Region
+// 请求头中声明的地域名称选择,未声明或没有对应地域时按客户端IP所在的网段选择,都不匹配时使用默认的 Endpoint
+type StorageRegionConfig struct {
+	Name     string `mapstructure:"name"`     // 地域名称,与 X-Client-Region 请求头的值比较,不区分大小写
+	Endpoint string `mapstructure:"endpoint"` // 该地域的访问地址,格式与默认的 endpoint 相同
+	// SigningRegion 签名使用的地域,为空时 S3 使用 s3.region,MinIO 向服务端查询存储桶所在地域
+	SigningRegion string   `mapstructure:"signing_region"`
+	CIDRs         []string `mapstructure:"cidrs"` // 该地域客户端的IP网段,多个地域都匹配时使用前缀最长的网段
+}
+
+// LocalStorageConfig 本地文件系统存储配置,文件保存在 storageconfig.local_base_path 下
+type LocalStorageConfig struct {
+	BucketName string `mapstructure:"bucket_name"` // 根目录下的存储桶目录名
+	SigningKey string `mapstructure:"signing_key"` // 签名下载链接使用的 HMAC 密钥
+	PublicURL  string `mapstructure:"public_url"`  // 服务对外访问地址,用于拼接签名下载链接,如 "https://disk.example.com"
 }
 
 // RabbitMQConfig RabbitMQ配置
@@ -73,13 +159,36 @@ type StorageConfig struct {
 	LocalBasePath      string `mapstructure:"local_base_path"`
 	Type               string `mapstructure:"type"`
 	PresignedURLExpiry int    `mapstructure:"presigned_url_expiry"` // 预签名URL有效期（分钟）
+	// Backends 除 Type 外同时连接的存储后端,已有对象按所在存储桶读取和删除,新对象仍写入 Type,
+	// 用于在后端之间迁移对象。各后端的存储桶名称不能相同
+	Backends []string `mapstructure:"backends"`
+	// Sharding 新对象在主后端的多个存储桶之间分布,避免单个存储桶对象数过多
+	Sharding BucketShardingConfig `mapstructure:"sharding"`
+}
+
+// BucketShardingConfig 存储桶分片配置。文件记录的 OssBucket 决定从哪个存储桶读取,修改分片配置不影响已有文件,
+// 需要时通过管理接口发起重新均衡
+type BucketShardingConfig struct {
+	// Strategy 选择存储桶的方式: user_hash 按用户ID哈希, date 按上传月份轮换,为空时只使用默认存储桶
+	Strategy string `mapstructure:"strategy"`
+	// Buckets 主后端中参与分片的存储桶,不存在时启动时自动创建
+	Buckets []string `mapstructure:"buckets"`
 }
 
 // zap日志配置
 type LogConfig struct {
-	OutputPath string `mapstructure:"output_path"`
-	ErrorPath  string `mapstructure:"error_path"`
-	Level      string `mapstructure:"level"`
+	OutputPath string           `mapstructure:"output_path"`
+	ErrorPath  string           `mapstructure:"error_path"`
+	Level      string           `mapstructure:"level"`
+	Request    RequestLogConfig `mapstructure:"request"`
+}
+
+// RequestLogConfig 请求日志配置,关闭时使用 gin 默认的访问日志
+type RequestLogConfig struct {
+	Enabled       bool     `mapstructure:"enabled"`
+	SampleRate    float64  `mapstructure:"sample_rate"`    // 正常请求的采样比例(0~1],0 表示全部记录;出错和慢请求总是记录
+	SlowThreshold int      `mapstructure:"slow_threshold"` // 超过该耗时的请求总是记录（毫秒）,0 表示不区分
+	SkipPaths     []string `mapstructure:"skip_paths"`     // 不记录的路由,如健康检查和指标接口
 }
 
 // ElasticsearchConfig 定义 Elasticsearch 连接配置
@@ -91,8 +200,381 @@ type ElasticsearchConfig struct {
 	// APIKey    string   `mapstructure:"api_key"`
 }
 
+// SearchConfig 文档内容搜索配置,开启后上传的文本、docx 和 PDF 文件由 Worker 提取文本写入 Elasticsearch
+type SearchConfig struct {
+	Enabled        bool   `mapstructure:"enabled"`
+	Index          string `mapstructure:"index"`            // 索引名称
+	TikaURL        string `mapstructure:"tika_url"`         // Apache Tika 服务地址,用于 PDF 以及内置提取失败的文件,为空时不提取 PDF
+	MaxSourceSize  int64  `mapstructure:"max_source_size"`  // 超过该大小（字节）的文件不提取内容,0 表示不限制
+	MaxContentSize int    `mapstructure:"max_content_size"` // 每个文件写入索引的文本上限（字节）,超出部分截断
+	Timeout        int    `mapstructure:"timeout"`          // 单个文件提取的超时时间（秒）
+}
+
+// ShareConfig 分享相关配置
+type ShareConfig struct {
+	DirectLink DirectLinkConfig   `mapstructure:"direct_link"`
+	Cleanup    ShareCleanupConfig `mapstructure:"cleanup"`
+	// PasswordLockout 防止暴力猜测分享密码
+	PasswordLockout SharePasswordLockoutConfig `mapstructure:"password_lockout"`
+}
+
+// SharePasswordLockoutConfig 分享密码错误锁定配置,窗口期内同一分享的错误次数达到上限后暂时拒绝所有密码尝试
+type SharePasswordLockoutConfig struct {
+	Enabled     bool `mapstructure:"enabled"`
+	MaxAttempts int  `mapstructure:"max_attempts"` // 窗口期内允许的错误次数
+	Window      int  `mapstructure:"window"`       // 统计错误次数的窗口（分钟）
+	Duration    int  `mapstructure:"duration"`     // 锁定时长（分钟）
+	NotifyOwner bool `mapstructure:"notify_owner"` // 锁定时是否给分享者发送邮件
+}
+
+// ShareCleanupConfig 失效分享清理配置,已撤销或已过期超过保留期的分享记录会被永久删除
+type ShareCleanupConfig struct {
+	Enabled       bool `mapstructure:"enabled"`
+	RetentionDays int  `mapstructure:"retention_days"` // 撤销或过期后保留的天数
+	Interval      int  `mapstructure:"interval"`       // 清理任务的执行间隔（分钟）
+	BatchSize     int  `mapstructure:"batch_size"`     // 每批删除的记录数量
+}
+
+// DirectLinkConfig 直链分享配置,限制对每个直链生效
+type DirectLinkConfig struct {
+	Enabled           bool     `mapstructure:"enabled"`
+	BandwidthLimit    int64    `mapstructure:"bandwidth_limit"`     // 每个直链请求的下载速率上限(字节/秒),0 表示不限速
+	AllowedReferers   []string `mapstructure:"allowed_referers"`    // 允许引用直链的来源域名,为空表示不限制,子域名同样允许
+	AllowEmptyReferer bool     `mapstructure:"allow_empty_referer"` // 是否允许没有 Referer 的请求,如浏览器直接打开
+}
+
+// PreviewConfig 媒体文件预览转码配置
+type PreviewConfig struct {
+	Enabled       bool   `mapstructure:"enabled"`
+	FFmpegPath    string `mapstructure:"ffmpeg_path"`     // ffmpeg 可执行文件路径,为空时从 PATH 中查找
+	Workers       int    `mapstructure:"workers"`         // 同时进行的转码任务数
+	Timeout       int    `mapstructure:"timeout"`         // 单个转码任务的超时时间（秒）
+	MaxSourceSize int64  `mapstructure:"max_source_size"` // 允许转码的源文件大小上限（字节）,0 表示不限制
+	TempDir       string `mapstructure:"temp_dir"`        // 转码临时文件目录,为空时使用系统临时目录
+}
+
+// UploadConfig 上传策略配置,大小单位均为字节,未配置的项使用默认值
+type UploadConfig struct {
+	SinglePutThreshold int64 `mapstructure:"single_put_threshold"` // 不超过该大小的文件使用单次 PUT 上传
+	LargeFileThreshold int64 `mapstructure:"large_file_threshold"` // 超过该大小的文件使用大分片
+	ChunkSize          int64 `mapstructure:"chunk_size"`           // 中等文件的分片大小
+	LargeChunkSize     int64 `mapstructure:"large_chunk_size"`     // 大文件的分片大小
+	MaxParts           int64 `mapstructure:"max_parts"`            // 单个文件的最大分片数,超出时自动增大分片
+
+	// 文件类型策略,MIME 类型以服务端检测结果为准,支持 "image/*" 形式的通配
+	BlockedMimeTypes  []string `mapstructure:"blocked_mime_types"` // 禁止上传的 MIME 类型
+	AllowedMimeTypes  []string `mapstructure:"allowed_mime_types"` // 非空时只允许上传这些 MIME 类型
+	BlockedExtensions []string `mapstructure:"blocked_extensions"` // 禁止上传的扩展名,如 ".exe"
+
+	Cleanup UploadCleanupConfig `mapstructure:"cleanup"`
+	Dedup   UploadDedupConfig   `mapstructure:"dedup"`
+}
+
+// UploadDedupConfig 秒传去重配置
+type UploadDedupConfig struct {
+	// Scope 秒传匹配范围: global 匹配所有用户的文件, user 只匹配自己的文件, off 关闭秒传,为空时按 global 处理
+	Scope string `mapstructure:"scope"`
+	// RequireProof 匹配到其他用户的文件时,要求客户端证明持有文件内容后才允许秒传
+	RequireProof bool  `mapstructure:"require_proof"`
+	ProofSize    int64 `mapstructure:"proof_size"` // 内容证明抽取的字节数,为 0 时使用默认值
+}
+
+// UploadCleanupConfig 过期上传会话清理配置,超过有效期仍未完成的会话会被中止并标记为 expired
+type UploadCleanupConfig struct {
+	Enabled   bool `mapstructure:"enabled"`
+	TTL       int  `mapstructure:"ttl"`        // 上传会话的有效期（小时）
+	Interval  int  `mapstructure:"interval"`   // 清理任务的执行间隔（分钟）
+	BatchSize int  `mapstructure:"batch_size"` // 每批处理的会话数量
+}
+
+// RateLimitConfig 限流配置,Rules 的键为规则名,在注册路由时按名称挂载到对应接口
+type RateLimitConfig struct {
+	Enabled bool                     `mapstructure:"enabled"`
+	Rules   map[string]RateLimitRule `mapstructure:"rules"`
+}
+
+// RateLimitRule 单条限流规则,分别按用户ID和客户端IP计数,未配置的维度不限制
+type RateLimitRule struct {
+	PerUser TokenBucketConfig `mapstructure:"per_user"`
+	PerIP   TokenBucketConfig `mapstructure:"per_ip"`
+}
+
+// TokenBucketConfig 令牌桶参数
+type TokenBucketConfig struct {
+	Rate  float64 `mapstructure:"rate"`  // 每秒补充的令牌数,<=0 表示不限制
+	Burst int     `mapstructure:"burst"` // 桶容量,即允许的突发请求数
+}
+
+// VersionConfig 文件历史版本配置
+type VersionConfig struct {
+	Retention VersionRetentionConfig `mapstructure:"retention"`
+}
+
+// VersionRetentionConfig 历史版本保留策略,满足任一条件的版本会被保留,文件当前指向的版本始终保留
+type VersionRetentionConfig struct {
+	Enabled   bool `mapstructure:"enabled"`
+	KeepLast  int  `mapstructure:"keep_last"`  // 每个文件保留最近的 N 个版本,0 表示不按数量保留
+	KeepDays  int  `mapstructure:"keep_days"`  // 保留 X 天内创建的版本,0 表示不按时间保留
+	Interval  int  `mapstructure:"interval"`   // 清理任务的执行间隔（分钟）
+	BatchSize int  `mapstructure:"batch_size"` // 每批处理的版本数量
+}
+
+// ScanConfig 病毒扫描配置,启用后上传完成的文件会异步发送到 clamd 扫描
+type ScanConfig struct {
+	Enabled      bool   `mapstructure:"enabled"`
+	ClamdAddress string `mapstructure:"clamd_address"` // clamd 地址,如 "localhost:3310",以 "/" 开头时按 unix socket 连接
+	Timeout      int    `mapstructure:"timeout"`       // 单个文件的扫描超时时间（秒）
+	MaxSize      int64  `mapstructure:"max_size"`      // 超过该大小的文件跳过扫描（字节）,应与 clamd 的 StreamMaxLength 一致,0 表示不限制
+}
+
+// TransferConfig 跨用户转存配置
+type TransferConfig struct {
+	ReceivedFolder string `mapstructure:"received_folder"` // 接收方根目录下存放转存文件的文件夹,不存在时自动创建,为空时直接放在根目录
+}
+
+// DownloadConfig 下载相关配置
+type DownloadConfig struct {
+	ZipPrefetch       int   `mapstructure:"zip_prefetch"`        // 打包文件夹时,写入当前文件的同时并发预取的后续文件数,0 表示顺序读取
+	ZipPrefetchMemory int64 `mapstructure:"zip_prefetch_memory"` // 预取时读入内存的文件内容总大小上限（字节）,超出时只提前建立读取流
+
+	// 限速对同一用户或同一分享的所有并发下载共享,管理员可以单独覆盖
+	UserBandwidthLimit  int64 `mapstructure:"user_bandwidth_limit"`  // 每个用户的默认下载速率上限（字节/秒）,0 表示不限速
+	ShareBandwidthLimit int64 `mapstructure:"share_bandwidth_limit"` // 每个分享链接的默认下载速率上限（字节/秒）,0 表示不限速
+
+	CountFlushInterval int `mapstructure:"count_flush_interval"` // 下载次数从 Redis 写入数据库的间隔（秒）,查询到的次数最多延迟这么久
+}
+
+// ExportConfig 账户数据导出配置,导出的 ZIP 暂存在默认存储桶中,过期后由清理 Worker 删除
+type ExportConfig struct {
+	PartSize        int64  `mapstructure:"part_size"`        // 单个 ZIP 分卷包含的文件内容总大小上限（字节）,0 表示不分卷
+	TTL             int    `mapstructure:"ttl"`              // 导出完成后保留的时间（小时）
+	URLExpiry       int    `mapstructure:"url_expiry"`       // 下载链接的有效期（分钟）,不超过导出的剩余保留时间
+	TempDir         string `mapstructure:"temp_dir"`         // 生成 ZIP 分卷的临时目录,为空时使用系统临时目录
+	CleanupInterval int    `mapstructure:"cleanup_interval"` // 清理过期导出的间隔（分钟）
+}
+
+// ArchiveConfig 文件夹异步打包配置,打包完成的 ZIP 暂存在存储桶中,过期后自动删除
+type ArchiveConfig struct {
+	TTL             int    `mapstructure:"ttl"`              // 打包完成后保留的时间（小时）
+	URLExpiry       int    `mapstructure:"url_expiry"`       // 下载链接的有效期（分钟）,不超过剩余保留时间
+	TempDir         string `mapstructure:"temp_dir"`         // 生成 ZIP 的临时目录,为空时使用系统临时目录
+	CleanupInterval int    `mapstructure:"cleanup_interval"` // 清理过期 ZIP 的间隔（分钟）
+	MaxTotalSize    int64  `mapstructure:"max_total_size"`   // 打包的文件总大小上限（字节）,为 0 时使用默认值 10GB
+}
+
+// ExtractConfig 压缩包在线解压配置,各项上限用于防范压缩炸弹,为 0 时使用默认值
+type ExtractConfig struct {
+	MaxEntries   int    `mapstructure:"max_entries"`    // 单个压缩包最多解压的条目数
+	MaxTotalSize int64  `mapstructure:"max_total_size"` // 解压后的文件总大小上限（字节）
+	MaxRatio     int    `mapstructure:"max_ratio"`      // ZIP 中单个文件解压后大小与压缩后大小的最大比值
+	TempDir      string `mapstructure:"temp_dir"`       // 暂存压缩包和解压文件的临时目录,为空时使用系统临时目录
+}
+
+// CacheWarmConfig 登录后在后台预先加载根目录和最近使用的文件夹到 Redis,减少网页端首屏的回源查询
+type CacheWarmConfig struct {
+	Enabled     bool `mapstructure:"enabled"`
+	Concurrency int  `mapstructure:"concurrency"` // 全部用户同时加载的文件夹数量上限
+	MaxFolders  int  `mapstructure:"max_folders"` // 每次预热最多加载的文件夹数量,包含根目录
+	Interval    int  `mapstructure:"interval"`    // 同一用户两次预热的最小间隔（秒）
+}
+
+// FileCacheConfig 文件元数据的进程内缓存配置,位于 Redis 缓存之前
+type FileCacheConfig struct {
+	LocalEnabled    bool `mapstructure:"local_enabled"`
+	LocalMaxEntries int  `mapstructure:"local_max_entries"` // 本地缓存的最大条目数
+	LocalTTL        int  `mapstructure:"local_ttl"`         // 本地副本的有效期（秒）,漏掉淘汰消息时最多读到这么久的旧数据
+}
+
+// LifecycleConfig 文件夹生命周期规则配置,定时按规则归档或删除长时间未修改的文件
+type LifecycleConfig struct {
+	Enabled   bool `mapstructure:"enabled"`
+	Interval  int  `mapstructure:"interval"`   // 执行所有规则的间隔（分钟）
+	BatchSize int  `mapstructure:"batch_size"` // 每条规则每次最多处理的文件数量
+	MaxRules  int  `mapstructure:"max_rules"`  // 每个用户最多创建的规则数量
+}
+
+// TrashConfig 回收站保留期配置,超过保留期的文件由 TrashExpirationWorker 彻底删除
+type TrashConfig struct {
+	Enabled          bool `mapstructure:"enabled"`
+	RetentionDays    int  `mapstructure:"retention_days"`     // 用户未设置时的保留天数
+	MinRetentionDays int  `mapstructure:"min_retention_days"` // 用户可以设置的最短保留天数
+	MaxRetentionDays int  `mapstructure:"max_retention_days"` // 用户可以设置的最长保留天数
+	Interval         int  `mapstructure:"interval"`           // 检查过期文件的间隔（分钟）
+	BatchSize        int  `mapstructure:"batch_size"`         // 每次最多彻底删除的文件数量
+}
+
+// 回收站保留期的默认值
+const (
+	defaultTrashRetentionDays    = 30
+	defaultTrashMinRetentionDays = 7
+	defaultTrashMaxRetentionDays = 90
+)
+
+// RetentionRange 返回用户可以设置的保留天数范围
+func (c TrashConfig) RetentionRange() (int, int) {
+	minDays, maxDays := c.MinRetentionDays, c.MaxRetentionDays
+	if minDays <= 0 {
+		minDays = defaultTrashMinRetentionDays
+	}
+	if maxDays <= 0 {
+		maxDays = defaultTrashMaxRetentionDays
+	}
+	return minDays, maxDays
+}
+
+// DefaultRetentionDays 返回用户未设置时的保留天数
+func (c TrashConfig) DefaultRetentionDays() int {
+	if c.RetentionDays <= 0 {
+		return defaultTrashRetentionDays
+	}
+	return c.RetentionDays
+}
+
+// EffectiveRetentionDays 返回用户实际生效的保留天数,userDays 为 0 表示使用默认值
+func (c TrashConfig) EffectiveRetentionDays(userDays int) int {
+	if userDays <= 0 {
+		return c.DefaultRetentionDays()
+	}
+	return userDays
+}
+
+// OutboxConfig 发件箱投递配置,事务中写入的 Redis Stream 和 RabbitMQ 消息由 OutboxWorker 轮询投递
+type OutboxConfig struct {
+	PollInterval int `mapstructure:"poll_interval"` // 没有待投递事件时的轮询间隔（毫秒）
+	BatchSize    int `mapstructure:"batch_size"`    // 每次最多投递的事件数量
+	MaxBackoff   int `mapstructure:"max_backoff"`   // 投递失败后重试间隔的上限（秒）
+	MaxAttempts  int `mapstructure:"max_attempts"`  // 最多投递的次数,超过后标记为失败不再投递
+}
+
+// OrganizationConfig 组织(团队空间)配置
+type OrganizationConfig struct {
+	DefaultSpace uint64 `mapstructure:"default_space"` // 新建组织的团队空间配额（字节）,0 表示 10GB
+	MaxPerUser   int    `mapstructure:"max_per_user"`  // 每个用户最多可以创建的组织数量,0 表示不限制
+}
+
+// APIConfig 对外 REST API 的版本配置
+type APIConfig struct {
+	// Deprecations 按版本(如 v1)配置弃用信息,弃用版本的响应附带 Deprecation、Sunset 和 Link 头
+	Deprecations map[string]APIDeprecationConfig `mapstructure:"deprecations"`
+}
+
+// APIDateLayout api.deprecations 中日期的格式
+const APIDateLayout = "2006-01-02"
+
+// APIDeprecationConfig 单个 API 版本的弃用计划
+type APIDeprecationConfig struct {
+	DeprecatedAt string `mapstructure:"deprecated_at"` // 宣布弃用的日期
+	SunsetAt     string `mapstructure:"sunset_at"`     // 计划停止服务的日期,为空表示尚未确定
+	Link         string `mapstructure:"link"`          // 迁移说明的地址
+}
+
+// QuotaConfig 存储空间用量提醒配置
+type QuotaConfig struct {
+	WarnPercent int `mapstructure:"warn_percent"` // 用量达到总空间的该百分比时通过活动日志和邮件提醒用户,0 表示不提醒
+}
+
+// NotificationConfig 站内通知配置
+type NotificationConfig struct {
+	Push                bool `mapstructure:"push"`                  // 同时发布到 Redis pub/sub,供 WebSocket 会话实时推送
+	ShareAccessInterval int  `mapstructure:"share_access_interval"` // 同一分享两次访问通知的最小间隔（秒）,0 表示每次访问都通知
+}
+
+// WebSocketConfig 实时事件推送配置
+type WebSocketConfig struct {
+	Enabled        bool     `mapstructure:"enabled"`
+	AllowedOrigins []string `mapstructure:"allowed_origins"` // 允许建立连接的页面来源,为空时不限制。连接需要令牌,不依赖 Cookie
+	MaxConnections int      `mapstructure:"max_connections"` // 每个用户在单个实例上的最大连接数,0 表示不限制
+}
+
+// OAuthConfig 第三方应用授权(OAuth2 授权码模式)配置
+type OAuthConfig struct {
+	AccessTokenTTL  int `mapstructure:"access_token_ttl"`  // 访问令牌的有效期（分钟）
+	RefreshTokenTTL int `mapstructure:"refresh_token_ttl"` // 刷新令牌的有效期（小时）
+	CodeTTL         int `mapstructure:"code_ttl"`          // 授权码的有效期（秒）
+}
+
+// MailConfig 邮件发送配置,用于邮箱验证和找回密码。未配置 SMTP 地址时邮件内容只写入日志,便于本地开发
+type MailConfig struct {
+	SMTPHost  string `mapstructure:"smtp_host"`
+	SMTPPort  int    `mapstructure:"smtp_port"` // 465 使用隐式 TLS,其他端口在服务器支持时使用 STARTTLS
+	Username  string `mapstructure:"username"`
+	Password  string `mapstructure:"password"`
+	From      string `mapstructure:"from"`       // 发件人地址
+	BaseURL   string `mapstructure:"base_url"`   // 邮件中链接指向的前端地址,令牌以 token 参数附加在链接后
+	VerifyTTL int    `mapstructure:"verify_ttl"` // 邮箱验证链接的有效期（小时）
+	ResetTTL  int    `mapstructure:"reset_ttl"`  // 密码重置链接的有效期（分钟）
+}
+
+// OfficeConfig 对接 OnlyOffice Docs 文档服务器,在线预览和编辑 Office 文档
+type OfficeConfig struct {
+	Enabled           bool   `mapstructure:"enabled"`
+	DocumentServerURL string `mapstructure:"document_server_url"` // 文档服务器地址,浏览器从这里加载编辑器
+	Secret            string `mapstructure:"secret"`              // 与文档服务器共享的 JWT 密钥,用于签名编辑器配置和校验回调
+	CallbackBaseURL   string `mapstructure:"callback_base_url"`   // 文档服务器访问本服务的地址,用于回调保存编辑结果
+	SessionTTL        int    `mapstructure:"session_ttl"`         // 编辑会话中文档下载链接和保存回调地址的有效期（分钟）
+	DownloadTimeout   int    `mapstructure:"download_timeout"`    // 回调时从文档服务器下载编辑结果的超时时间（秒）
+	MaxFileSize       int64  `mapstructure:"max_file_size"`       // 允许保存的编辑结果大小上限（字节）,0 表示不限制
+}
+
+// IntegrityConfig 存储完整性校验配置,定期按上次校验时间从早到晚抽取文件,重新计算哈希以发现存储的静默损坏
+type IntegrityConfig struct {
+	Enabled     bool     `mapstructure:"enabled"`
+	Interval    int      `mapstructure:"interval"`     // 校验任务的执行间隔（分钟）
+	BatchSize   int      `mapstructure:"batch_size"`   // 每轮校验的文件数量
+	AlertEmails []string `mapstructure:"alert_emails"` // 发现损坏时通知的管理员邮箱,为空时只记录日志和指标
+}
+
+// FileNameConfig 文件名校验配置。"/"、"\"、控制字符、末尾的点或空格以及 CON、NUL 等保留名称始终不允许
+type FileNameConfig struct {
+	MaxLength         int  `mapstructure:"max_length"`         // 文件名的最大字符数,0 表示 255
+	NormalizeNFC      bool `mapstructure:"normalize_nfc"`      // 是否将文件名规范化为 Unicode NFC,避免 macOS 上传的文件名与其他系统不一致
+	WindowsCompatible bool `mapstructure:"windows_compatible"` // 是否同时禁止 Windows 不允许的字符 <>:"|?*
+}
+
+// TracingConfig OpenTelemetry 链路追踪配置
+type TracingConfig struct {
+	Enabled     bool    `mapstructure:"enabled"`
+	ServiceName string  `mapstructure:"service_name"`
+	Endpoint    string  `mapstructure:"endpoint"`     // OTLP HTTP 接收地址,如 "localhost:4318"
+	Insecure    bool    `mapstructure:"insecure"`     // 使用 HTTP 而不是 HTTPS 上报
+	SampleRatio float64 `mapstructure:"sample_ratio"` // 根 span 的采样比例,0~1,上游已采样的请求始终采样
+}
+
+// MetricsConfig Prometheus 抓取接口配置
+type MetricsConfig struct {
+	// Token 抓取方通过 Authorization: Bearer 传递的令牌,为空时只允许管理员访问
+	Token string `mapstructure:"token"`
+}
+
+// DefaultBucketName 返回当前存储类型对应的默认存储桶
+func (c *Config) DefaultBucketName() string {
+	return c.BucketNameFor(c.Storage.Type)
+}
+
+// BucketNameFor 返回指定存储类型配置的存储桶
+func (c *Config) BucketNameFor(storageType string) string {
+	switch storageType {
+	case "aliyun_oss":
+		return c.AliyunOSS.BucketName
+	case "s3":
+		return c.S3.BucketName
+	case "local":
+		return c.Local.BucketName
+	default:
+		return c.MinIO.BucketName
+	}
+}
+
+// UsesStorage 检查指定存储类型是否为主后端或额外连接的后端之一
+func (c *Config) UsesStorage(storageType string) bool {
+	return c.Storage.Type == storageType || slices.Contains(c.Storage.Backends, storageType)
+}
+
 var AppConfig *Config // 全局应用配置实例
 
+// envPrefix 环境变量前缀,例如 GO_CLOUD_DISK_SERVER_PORT 覆盖 server.port
+const envPrefix = "GO_CLOUD_DISK"
+
 // LoadConfig 加载配置
 func LoadConfig() (*Config, error) {
 	viper.SetConfigName("config")             // 配置文件名 (不带扩展名)
@@ -103,13 +585,15 @@ func LoadConfig() (*Config, error) {
 
 	// 读取环境变量，环境变量名将自动转换为大写，并用下划线替换点
 	// 例如：SERVER.PORT 对应环境变量 SERVER_PORT
-	viper.SetEnvPrefix("GO_CLOUD_DISK") // 设置环境变量前缀，例如 GO_CLOUD_DISK_SERVER_PORT
-	viper.AutomaticEnv()                // 自动绑定环境变量
+	viper.SetEnvPrefix(envPrefix) // 设置环境变量前缀，例如 GO_CLOUD_DISK_SERVER_PORT
+	viper.AutomaticEnv()          // 自动绑定环境变量
 
 	// 替换环境变量中的点为下划线，例如 "SERVER.PORT" 对应 "SERVER_PORT"
 	// 确保Viper能正确映射如 MYSQL_DSN 到 mysql.dsn
 	replacer := strings.NewReplacer(".", "_")
 	viper.SetEnvKeyReplacer(replacer)
+	// 配置文件中没有的键也可以通过环境变量设置
+	bindEnvs(reflect.TypeOf(Config{}), "")
 
 	// 1. 设置默认值 (如果配置文件和环境变量中都没有，则使用这些默认值)
 	// viper.SetDefault("server.port", "8080")
@@ -150,6 +634,11 @@ func LoadConfig() (*Config, error) {
 		return nil, err
 	}
 
+	// 4. 校验必需的配置项,一次列出所有问题
+	if err := AppConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
 	log.Println("Configuration loaded successfully with Viper.")
 	return AppConfig, nil
 }
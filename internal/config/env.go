@@ -0,0 +1,40 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// bindEnvs 为结构体中的每个配置项绑定环境变量。AutomaticEnv 只对配置文件中已有的键生效,
+// Unmarshal 时配置文件里没有的键需要提前绑定才能从环境变量读取。
+// map 类型的配置项(如 rate_limit.rules)键名不固定,只能整体在配置文件中设置
+func bindEnvs(t reflect.Type, prefix string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("mapstructure")
+		if name == "" || name == "-" {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		ft := field.Type
+		if ft.Kind() == reflect.Struct && ft.PkgPath() == t.PkgPath() {
+			bindEnvs(ft, key)
+			continue
+		}
+		if ft.Kind() == reflect.Map {
+			continue
+		}
+		_ = viper.BindEnv(key)
+	}
+}
+
+// envName 返回配置项对应的环境变量名,如 minio.access_key_id 对应 GO_CLOUD_DISK_MINIO_ACCESS_KEY_ID
+func envName(key string) string {
+	return envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
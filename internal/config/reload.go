@@ -0,0 +1,98 @@
+package config
+
+import (
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// ReloadConfig 配置热更新,启用后配置文件修改时重新加载可在运行时调整的配置项:
+// 限流规则、存储用量提醒比例和预签名URL有效期。其他配置项的修改需要重启服务才生效
+type ReloadConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// ChangeFunc 配置热更新后的回调,old 和 new 只有可在运行时调整的配置项不同
+type ChangeFunc func(old, new *Config)
+
+var (
+	watchMu   sync.Mutex
+	listeners []ChangeFunc
+	current   *Config
+)
+
+// OnChange 注册配置热更新的回调,回调在监听配置文件的 goroutine 中按注册顺序执行,不能阻塞
+func OnChange(fn ChangeFunc) {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	listeners = append(listeners, fn)
+}
+
+// Watch 开始监听配置文件,cfg 为启动时加载的配置。未启用热更新时不做任何事
+func Watch(cfg *Config) {
+	if !cfg.Reload.Enabled {
+		return
+	}
+	watchMu.Lock()
+	current = cfg
+	watchMu.Unlock()
+
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		reload(e.Name)
+	})
+	viper.WatchConfig()
+	logger.Info("Config reload enabled", zap.String("file", viper.ConfigFileUsed()))
+}
+
+// reload 重新解析配置文件,校验通过后只应用可在运行时调整的配置项并通知订阅者。
+// 编辑器保存文件时可能触发多次事件,可调整的配置项没有变化时不通知
+func reload(file string) {
+	next := &Config{}
+	if err := viper.Unmarshal(next); err != nil {
+		logger.Error("Config reload: Failed to unmarshal config, keeping previous settings", zap.String("file", file), zap.Error(err))
+		return
+	}
+	if err := next.Validate(); err != nil {
+		logger.Error("Config reload: Invalid config, keeping previous settings", zap.String("file", file), zap.Error(err))
+		return
+	}
+
+	watchMu.Lock()
+	old := current
+	updated := *old
+	updated.RateLimit = next.RateLimit
+	updated.Quota = next.Quota
+	updated.Storage.PresignedURLExpiry = next.Storage.PresignedURLExpiry
+
+	// 把可调整的配置项换回旧值后仍有差异,说明修改了需要重启才生效的配置
+	restartOnly := *next
+	restartOnly.RateLimit = old.RateLimit
+	restartOnly.Quota = old.Quota
+	restartOnly.Storage.PresignedURLExpiry = old.Storage.PresignedURLExpiry
+	if !reflect.DeepEqual(&restartOnly, old) {
+		logger.Warn("Config reload: Some changed settings only take effect after restart", zap.String("file", file))
+	}
+
+	if reflect.DeepEqual(&updated, old) {
+		watchMu.Unlock()
+		return
+	}
+	current = &updated
+	fns := slices.Clone(listeners)
+	watchMu.Unlock()
+
+	logger.Info("Config reload: Runtime settings updated",
+		zap.String("file", file),
+		zap.Bool("rateLimitEnabled", updated.RateLimit.Enabled),
+		zap.Int("rateLimitRules", len(updated.RateLimit.Rules)),
+		zap.Int("quotaWarnPercent", updated.Quota.WarnPercent),
+		zap.Int("presignedURLExpiry", updated.Storage.PresignedURLExpiry))
+	for _, fn := range fns {
+		fn(old, &updated)
+	}
+}
@@ -0,0 +1,240 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxPresignedURLExpiry S3 协议允许的预签名URL最长有效期（分钟）
+	maxPresignedURLExpiry = 7 * 24 * 60
+	// maxJWTExpiresIn jwt.expires_in 的上限（分钟）,写成 "1h" 这类时长字符串时会被解析为纳秒数而超出上限
+	maxJWTExpiresIn = 365 * 24 * 60
+	// maxJWTRefreshHours jwt.refresh_expire_hours 的上限（小时）
+	maxJWTRefreshHours = 10 * 365 * 24
+	// placeholderSigningKey 示例配置中的签名密钥,使用它签发的下载链接可以被任何人伪造
+	placeholderSigningKey = "CHANGE_ME_LOCAL_STORAGE_SIGNING_KEY"
+)
+
+// Validate 校验启动必需的配置项和数值范围,返回包含所有问题的错误
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	required := func(key, value string) {
+		if value == "" {
+			fail("%s is required (or set %s)", key, envName(key))
+		}
+	}
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port <= 0 || port > 65535 {
+		fail("server.port must be a port number between 1 and 65535, got %q", c.Server.Port)
+	}
+	if c.Server.ShutdownTimeout < 0 {
+		fail("server.shutdown_timeout must not be negative, got %d", c.Server.ShutdownTimeout)
+	}
+	required("mysql.dsn", c.MySQL.DSN)
+	required("redis.addr", c.Redis.Addr)
+	required("rabbitmq.url", c.RabbitMQ.URL)
+
+	required("jwt.secret_key", c.JWT.SecretKey)
+	if c.JWT.ExpiresIn <= 0 || c.JWT.ExpiresIn > maxJWTExpiresIn {
+		fail("jwt.expires_in must be a number of minutes between 1 and %d, got %d", maxJWTExpiresIn, int64(c.JWT.ExpiresIn))
+	}
+	if c.JWT.RefreshExpireHours < 0 || c.JWT.RefreshExpireHours > maxJWTRefreshHours {
+		fail("jwt.refresh_expire_hours must be a number of hours between 0 and %d, got %d", maxJWTRefreshHours, int64(c.JWT.RefreshExpireHours))
+	}
+
+	errs = append(errs, c.validateStorage()...)
+
+	for _, version := range slices.Sorted(maps.Keys(c.API.Deprecations)) {
+		deprecation := c.API.Deprecations[version]
+		if _, err := time.Parse(APIDateLayout, deprecation.DeprecatedAt); err != nil {
+			fail("api.deprecations.%s.deprecated_at must be a date like 2006-01-02, got %q", version, deprecation.DeprecatedAt)
+		}
+		if deprecation.SunsetAt != "" {
+			if _, err := time.Parse(APIDateLayout, deprecation.SunsetAt); err != nil {
+				fail("api.deprecations.%s.sunset_at must be a date like 2006-01-02, got %q", version, deprecation.SunsetAt)
+			}
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(c.RateLimit.Rules)) {
+		rule := c.RateLimit.Rules[name]
+		if rule.PerUser.Burst < 0 {
+			fail("rate_limit.rules.%s.per_user.burst must not be negative, got %d", name, rule.PerUser.Burst)
+		}
+		if rule.PerIP.Burst < 0 {
+			fail("rate_limit.rules.%s.per_ip.burst must not be negative, got %d", name, rule.PerIP.Burst)
+		}
+	}
+	if c.Extract.MaxTotalSize < 0 {
+		fail("extract.max_total_size must not be negative, got %d", c.Extract.MaxTotalSize)
+	}
+	if c.Search.Enabled && len(c.Elasticsearch.Addresses) == 0 {
+		fail("elasticsearch.addresses is required when search.enabled is true")
+	}
+	if c.Search.MaxSourceSize < 0 {
+		fail("search.max_source_size must not be negative, got %d", c.Search.MaxSourceSize)
+	}
+	if minDays, maxDays := c.Trash.RetentionRange(); minDays > maxDays {
+		fail("trash.min_retention_days (%d) must not exceed trash.max_retention_days (%d)", minDays, maxDays)
+	}
+	if c.Quota.WarnPercent < 0 || c.Quota.WarnPercent > 100 {
+		fail("quota.warn_percent must be between 0 and 100, got %d", c.Quota.WarnPercent)
+	}
+
+	// 各后台任务的间隔和有效期为 0 时使用默认值,负数说明配置写错了
+	for _, item := range []struct {
+		key   string
+		value int
+	}{
+		{"mysql.conn_max_lifetime", c.MySQL.ConnMaxLifetime},
+		{"mysql.conn_max_idle_time", c.MySQL.ConnMaxIdleTime},
+		{"mysql.slow_query.threshold", c.MySQL.SlowQuery.Threshold},
+		{"share.cleanup.interval", c.Share.Cleanup.Interval},
+		{"share.cleanup.retention_days", c.Share.Cleanup.RetentionDays},
+		{"share.password_lockout.window", c.Share.PasswordLockout.Window},
+		{"share.password_lockout.duration", c.Share.PasswordLockout.Duration},
+		{"upload.cleanup.ttl", c.Upload.Cleanup.TTL},
+		{"upload.cleanup.interval", c.Upload.Cleanup.Interval},
+		{"version.retention.interval", c.Version.Retention.Interval},
+		{"preview.timeout", c.Preview.Timeout},
+		{"scan.timeout", c.Scan.Timeout},
+		{"export.ttl", c.Export.TTL},
+		{"export.url_expiry", c.Export.URLExpiry},
+		{"export.cleanup_interval", c.Export.CleanupInterval},
+		{"archive.ttl", c.Archive.TTL},
+		{"archive.url_expiry", c.Archive.URLExpiry},
+		{"archive.cleanup_interval", c.Archive.CleanupInterval},
+		{"extract.max_entries", c.Extract.MaxEntries},
+		{"extract.max_ratio", c.Extract.MaxRatio},
+		{"search.max_content_size", c.Search.MaxContentSize},
+		{"search.timeout", c.Search.Timeout},
+		{"integrity.interval", c.Integrity.Interval},
+		{"lifecycle.interval", c.Lifecycle.Interval},
+		{"trash.retention_days", c.Trash.RetentionDays},
+		{"trash.min_retention_days", c.Trash.MinRetentionDays},
+		{"trash.max_retention_days", c.Trash.MaxRetentionDays},
+		{"trash.interval", c.Trash.Interval},
+		{"oauth.access_token_ttl", c.OAuth.AccessTokenTTL},
+		{"oauth.refresh_token_ttl", c.OAuth.RefreshTokenTTL},
+		{"oauth.code_ttl", c.OAuth.CodeTTL},
+		{"mail.verify_ttl", c.Mail.VerifyTTL},
+		{"mail.reset_ttl", c.Mail.ResetTTL},
+		{"office.session_ttl", c.Office.SessionTTL},
+		{"office.download_timeout", c.Office.DownloadTimeout},
+		{"cache_warm.interval", c.CacheWarm.Interval},
+		{"file_cache.local_ttl", c.FileCache.LocalTTL},
+		{"outbox.poll_interval", c.Outbox.PollInterval},
+		{"outbox.max_backoff", c.Outbox.MaxBackoff},
+		{"organization.max_per_user", c.Organization.MaxPerUser},
+	} {
+		if item.value < 0 {
+			fail("%s must not be negative, got %d", item.key, item.value)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateStorage 校验主后端和额外连接的后端都配置了连接信息
+func (c *Config) validateStorage() []error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	switch c.Storage.Type {
+	case "minio", "aliyun_oss", "s3", "local":
+	default:
+		fail("storageconfig.type must be one of minio, aliyun_oss, s3, local, got %q", c.Storage.Type)
+	}
+	if c.Storage.PresignedURLExpiry <= 0 || c.Storage.PresignedURLExpiry > maxPresignedURLExpiry {
+		fail("storageconfig.presigned_url_expiry must be between 1 and %d minutes, got %d", maxPresignedURLExpiry, c.Storage.PresignedURLExpiry)
+	}
+
+	type field struct{ key, value string }
+	backends := map[string][]field{
+		"minio": {
+			{"minio.endpoint", c.MinIO.Endpoint},
+			{"minio.access_key_id", c.MinIO.AccessKeyID},
+			{"minio.secret_access_key", c.MinIO.SecretAccessKey},
+			{"minio.bucket_name", c.MinIO.BucketName},
+		},
+		"aliyun_oss": {
+			{"aliyun_oss.endpoint", c.AliyunOSS.Endpoint},
+			{"aliyun_oss.access_key_id", c.AliyunOSS.AccessKeyID},
+			{"aliyun_oss.secret_access_key", c.AliyunOSS.SecretAccessKey},
+			{"aliyun_oss.bucket_name", c.AliyunOSS.BucketName},
+		},
+		"s3": {
+			{"s3.region", c.S3.Region},
+			{"s3.access_key_id", c.S3.AccessKeyID},
+			{"s3.secret_access_key", c.S3.SecretAccessKey},
+			{"s3.bucket_name", c.S3.BucketName},
+		},
+		"local": {
+			{"storageconfig.local_base_path", c.Storage.LocalBasePath},
+			{"local_storage.bucket_name", c.Local.BucketName},
+			{"local_storage.signing_key", c.Local.SigningKey},
+		},
+	}
+	for _, storageType := range []string{"minio", "aliyun_oss", "s3", "local"} {
+		if !c.UsesStorage(storageType) {
+			continue
+		}
+		for _, f := range backends[storageType] {
+			if f.value == "" {
+				fail("%s is required when %s storage is used (or set %s)", f.key, storageType, envName(f.key))
+			}
+		}
+	}
+	if c.UsesStorage("local") && c.Local.SigningKey == placeholderSigningKey {
+		fail("local_storage.signing_key must be changed from the example value (or set %s)", envName("local_storage.signing_key"))
+	}
+	for _, backend := range c.Storage.Backends {
+		if _, ok := backends[backend]; !ok {
+			fail("storageconfig.backends contains unknown storage type %q", backend)
+		}
+	}
+
+	regions := map[string][]StorageRegionConfig{
+		"minio":      c.MinIO.Regions,
+		"aliyun_oss": c.AliyunOSS.Regions,
+		"s3":         c.S3.Regions,
+	}
+	for _, storageType := range []string{"minio", "aliyun_oss", "s3"} {
+		if !c.UsesStorage(storageType) {
+			continue
+		}
+		seen := make(map[string]bool)
+		for i, region := range regions[storageType] {
+			key := fmt.Sprintf("%s.regions[%d]", storageType, i)
+			name := strings.ToLower(region.Name)
+			switch {
+			case name == "":
+				fail("%s.name is required", key)
+			case seen[name]:
+				fail("%s.name %q is used by another region", key, region.Name)
+			}
+			seen[name] = true
+			if region.Endpoint == "" {
+				fail("%s.endpoint is required", key)
+			}
+			for _, cidr := range region.CIDRs {
+				if _, err := netip.ParsePrefix(cidr); err != nil {
+					fail("%s.cidrs contains invalid network %q", key, cidr)
+				}
+			}
+		}
+	}
+	return errs
+}
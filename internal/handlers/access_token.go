@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/admin"
+	"github.com/gin-gonic/gin"
+)
+
+type AccessTokenHandler struct {
+	tokenService admin.AccessTokenService
+}
+
+func NewAccessTokenHandler(tokenService admin.AccessTokenService) *AccessTokenHandler {
+	return &AccessTokenHandler{
+		tokenService: tokenService,
+	}
+}
+
+// CreateAccessTokenRequest 创建个人访问令牌请求体
+type CreateAccessTokenRequest struct {
+	Name          string `json:"name" binding:"required,max=64"`
+	Scope         string `json:"scope" binding:"required,oneof=read upload full"`
+	ExpiresInDays int    `json:"expires_in_days" binding:"min=0,max=3650"` // 0 表示永不过期
+}
+
+// @Summary 创建个人访问令牌
+// @Description 为第三方客户端创建 pat_ 开头的访问令牌,权限范围可选 read/upload/full。明文令牌只在创建时返回一次
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateAccessTokenRequest true "令牌信息"
+// @Success 200 {object} xerr.Response "令牌信息和明文令牌"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 403 {object} xerr.Response "不能使用访问令牌创建令牌"
+// @Router /api/v1/users/me/tokens [post]
+func (h *AccessTokenHandler) CreateToken(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req CreateAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+
+	token, rawToken, err := h.tokenService.CreateToken(c.Request.Context(), currentUserID, req.Name, req.Scope, time.Duration(req.ExpiresInDays)*24*time.Hour)
+	if err != nil {
+		if errors.Is(err, xerr.ErrInvalidParams) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to create access token")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Access token created successfully", gin.H{
+		"token":      rawToken,
+		"token_info": token,
+	})
+}
+
+// @Summary 获取个人访问令牌列表
+// @Description 列出当前用户的全部访问令牌,不包含明文令牌
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} xerr.Response "令牌列表"
+// @Router /api/v1/users/me/tokens [get]
+func (h *AccessTokenHandler) ListTokens(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.tokenService.ListTokens(c.Request.Context(), currentUserID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to list access tokens")
+		return
+	}
+	response.Success(c, http.StatusOK, "Access tokens listed successfully", tokens)
+}
+
+// @Summary 撤销个人访问令牌
+// @Description 删除访问令牌,使用该令牌的客户端将立即失去访问权限
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Param token_id path int true "令牌ID"
+// @Success 200 {object} xerr.Response "撤销成功"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 404 {object} xerr.Response "令牌不存在"
+// @Router /api/v1/users/me/tokens/{token_id} [delete]
+func (h *AccessTokenHandler) RevokeToken(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	tokenID, err := strconv.ParseUint(c.Param("token_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid token ID format")
+		return
+	}
+
+	if err := h.tokenService.RevokeToken(c.Request.Context(), currentUserID, tokenID); err != nil {
+		if errors.Is(err, xerr.ErrAccessTokenNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.AccessTokenNotFoundCode)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to revoke access token")
+		return
+	}
+	response.Success(c, http.StatusOK, "Access token revoked successfully", nil)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/activity"
+	"github.com/gin-gonic/gin"
+)
+
+type ActivityHandler struct {
+	activityService activity.ActivityService
+}
+
+func NewActivityHandler(activityService activity.ActivityService) *ActivityHandler {
+	return &ActivityHandler{
+		activityService: activityService,
+	}
+}
+
+// 解析分页参数
+func parsePagination(c *gin.Context) (int, int) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+	return page, pageSize
+}
+
+// @Summary 获取文件活动日志
+// @Description 分页获取指定文件的操作记录（上传、下载、重命名、移动、删除、恢复、分享等）
+// @Tags 活动日志
+// @Produce json
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Param page query int false "页码，默认为1" default(1)
+// @Param pageSize query int false "每页数量，默认为10" default(10)
+// @Success 200 {object} xerr.Response "活动日志列表"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Router /api/v1/files/{file_id}/activity [get]
+func (h *ActivityHandler) ListFileActivities(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	fileIDStr := c.Param("file_id")
+	fileID, err := strconv.ParseUint(fileIDStr, 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+
+	page, pageSize := parsePagination(c)
+	activities, total, err := h.activityService.ListFileActivities(c.Request.Context(), currentUserID, fileID, page, pageSize)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to list file activities")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "File activities listed successfully", gin.H{
+		"activities": activities,
+		"total":      total,
+	})
+}
+
+// @Summary 获取我的活动日志
+// @Description 分页获取当前用户空间内的所有操作记录
+// @Tags 活动日志
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "页码，默认为1" default(1)
+// @Param pageSize query int false "每页数量，默认为10" default(10)
+// @Success 200 {object} xerr.Response "活动日志列表"
+// @Router /api/v1/users/me/activity [get]
+func (h *ActivityHandler) ListUserActivities(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	page, pageSize := parsePagination(c)
+	activities, total, err := h.activityService.ListUserActivities(c.Request.Context(), currentUserID, page, pageSize)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to list user activities")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "User activities listed successfully", gin.H{
+		"activities": activities,
+		"total":      total,
+	})
+}
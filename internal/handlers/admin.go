@@ -0,0 +1,496 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/admin"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AdminHandler 管理员接口
+type AdminHandler struct {
+	bandwidthService  admin.BandwidthService
+	deadLetterService admin.DeadLetterService
+	migrationService  explorer.StorageMigrationService
+	deletionService   explorer.AccountDeletionService
+	integrityService  explorer.IntegrityService
+}
+
+func NewAdminHandler(bandwidthService admin.BandwidthService, deadLetterService admin.DeadLetterService, migrationService explorer.StorageMigrationService, deletionService explorer.AccountDeletionService, integrityService explorer.IntegrityService) *AdminHandler {
+	return &AdminHandler{
+		bandwidthService:  bandwidthService,
+		deadLetterService: deadLetterService,
+		migrationService:  migrationService,
+		deletionService:   deletionService,
+		integrityService:  integrityService,
+	}
+}
+
+// SetBandwidthLimitRequest 限速设置请求体,0 表示恢复默认值,-1 表示不限速
+type SetBandwidthLimitRequest struct {
+	BytesPerSecond *int64 `json:"bytes_per_second" binding:"required"`
+}
+
+// @Summary 设置用户下载限速
+// @Description 覆盖配置中的默认用户限速,对该用户的所有并发下载共享生效
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path int true "用户ID"
+// @Param request body SetBandwidthLimitRequest true "限速（字节/秒）"
+// @Success 200 {object} xerr.Response "设置成功"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 403 {object} xerr.Response "需要管理员权限"
+// @Failure 404 {object} xerr.Response "用户不存在"
+// @Router /api/v1/admin/users/{user_id}/bandwidth [put]
+func (h *AdminHandler) SetUserBandwidth(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid user ID format")
+		return
+	}
+
+	var req SetBandwidthLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+
+	user, err := h.bandwidthService.SetUserLimit(c.Request.Context(), userID, *req.BytesPerSecond)
+	if err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrInvalidParams):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+		case errors.Is(err, xerr.ErrUserNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.UserNotFoundCode)
+		default:
+			logger.Error("SetUserBandwidth: Failed to set bandwidth limit", zap.Uint64("userID", userID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to set bandwidth limit")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Bandwidth limit updated", gin.H{
+		"user_id":         user.ID,
+		"bandwidth_limit": user.BandwidthLimit,
+	})
+}
+
+// @Summary 设置分享链接下载限速
+// @Description 覆盖配置中的默认分享限速,对该分享链接的所有访问共享生效
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param share_id path int true "分享ID"
+// @Param request body SetBandwidthLimitRequest true "限速（字节/秒）"
+// @Success 200 {object} xerr.Response "设置成功"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 403 {object} xerr.Response "需要管理员权限"
+// @Failure 404 {object} xerr.Response "分享不存在"
+// @Router /api/v1/admin/shares/{share_id}/bandwidth [put]
+func (h *AdminHandler) SetShareBandwidth(c *gin.Context) {
+	shareID, err := strconv.ParseUint(c.Param("share_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid share ID format")
+		return
+	}
+
+	var req SetBandwidthLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+
+	share, err := h.bandwidthService.SetShareLimit(c.Request.Context(), shareID, *req.BytesPerSecond)
+	if err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrInvalidParams):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+		case errors.Is(err, xerr.ErrShareNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.ShareNotFoundCode)
+		default:
+			logger.Error("SetShareBandwidth: Failed to set bandwidth limit", zap.Uint64("shareID", shareID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to set bandwidth limit")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Bandwidth limit updated", gin.H{
+		"share_id":        share.ID,
+		"bandwidth_limit": share.BandwidthLimit,
+	})
+}
+
+// @Summary 查看死信消息
+// @Description 按失败时间倒序返回缓存消费者多次处理失败后转入死信流的消息
+// @Tags 管理
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "返回数量,默认 50,最大 200"
+// @Success 200 {object} xerr.Response "获取成功"
+// @Failure 403 {object} xerr.Response "需要管理员权限"
+// @Failure 500 {object} xerr.Response "服务器内部错误"
+// @Router /api/v1/admin/dead-letters [get]
+func (h *AdminHandler) ListDeadLetters(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid limit")
+		return
+	}
+
+	letters, err := h.deadLetterService.List(c.Request.Context(), limit)
+	if err != nil {
+		logger.Error("ListDeadLetters: Failed to list dead letters", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to list dead letters")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Dead letters retrieved successfully", letters)
+}
+
+// @Summary 重放死信消息
+// @Description 将死信的原始消息重新写入来源 Stream 并从死信流删除,适用于修复导致失败的问题之后
+// @Tags 管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "死信ID"
+// @Success 200 {object} xerr.Response "重放成功"
+// @Failure 403 {object} xerr.Response "需要管理员权限"
+// @Failure 404 {object} xerr.Response "死信不存在"
+// @Failure 500 {object} xerr.Response "服务器内部错误"
+// @Router /api/v1/admin/dead-letters/{id}/replay [post]
+func (h *AdminHandler) ReplayDeadLetter(c *gin.Context) {
+	id := c.Param("id")
+
+	letter, err := h.deadLetterService.Replay(c.Request.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrDeadLetterNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.DeadLetterNotFoundCode)
+		case errors.Is(err, xerr.ErrInvalidParams):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+		default:
+			logger.Error("ReplayDeadLetter: Failed to replay dead letter", zap.String("id", id), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to replay dead letter")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Dead letter replayed", letter)
+}
+
+// StorageMigrationRequest 存储迁移请求体,target_backend 必须是 storageconfig.type 或 storageconfig.backends 中的存储类型
+type StorageMigrationRequest struct {
+	TargetBackend string `json:"target_backend" binding:"required,oneof=minio aliyun_oss s3 local"`
+	DeleteSource  bool   `json:"delete_source"`
+}
+
+// @Summary 发起存储迁移
+// @Description 把所有不在目标后端中的文件(包括历史版本和回收站中的文件)逐个搬到目标后端,由后台 Worker 执行。
+// @Description 源后端和目标后端都需要在配置中启用,迁移期间文件仍可正常访问。同一时间只允许一个进行中的任务
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body StorageMigrationRequest true "迁移参数"
+// @Success 202 {object} xerr.Response "迁移任务已创建"
+// @Failure 400 {object} xerr.Response "目标后端未启用"
+// @Failure 403 {object} xerr.Response "需要管理员权限"
+// @Failure 409 {object} xerr.Response "已有正在进行的迁移任务"
+// @Router /api/v1/admin/storage/migrations [post]
+func (h *AdminHandler) StartStorageMigration(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req StorageMigrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+
+	migration, err := h.migrationService.StartMigration(c.Request.Context(), currentUserID, req.TargetBackend, req.DeleteSource)
+	if err != nil {
+		h.handleMigrationError(c, err, "StartStorageMigration", "Failed to start storage migration")
+		return
+	}
+
+	response.Success(c, http.StatusAccepted, "Storage migration started", migration)
+}
+
+// StorageRebalanceRequest 存储桶重新均衡请求体
+type StorageRebalanceRequest struct {
+	DeleteSource bool `json:"delete_source"`
+}
+
+// @Summary 重新均衡存储桶
+// @Description 按 storageconfig.sharding 的分片策略,把不在所选存储桶中的文件(包括历史版本和回收站中的文件)搬到对应的存储桶,由后台 Worker 执行。
+// @Description 修改分片存储桶或策略后使用,任务进度与存储迁移任务一起查看
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body StorageRebalanceRequest true "均衡参数"
+// @Success 202 {object} xerr.Response "均衡任务已创建"
+// @Failure 400 {object} xerr.Response "未配置存储桶分片"
+// @Failure 403 {object} xerr.Response "需要管理员权限"
+// @Failure 409 {object} xerr.Response "已有正在进行的迁移任务"
+// @Router /api/v1/admin/storage/rebalance [post]
+func (h *AdminHandler) StartStorageRebalance(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req StorageRebalanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+
+	migration, err := h.migrationService.StartRebalance(c.Request.Context(), currentUserID, req.DeleteSource)
+	if err != nil {
+		h.handleMigrationError(c, err, "StartStorageRebalance", "Failed to start storage rebalance")
+		return
+	}
+
+	response.Success(c, http.StatusAccepted, "Storage rebalance started", migration)
+}
+
+// @Summary 查看存储迁移任务
+// @Description 按创建时间倒序返回最近的存储迁移任务及其进度
+// @Tags 管理
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "返回数量,默认 20,最大 100"
+// @Success 200 {object} xerr.Response "获取成功"
+// @Failure 403 {object} xerr.Response "需要管理员权限"
+// @Router /api/v1/admin/storage/migrations [get]
+func (h *AdminHandler) ListStorageMigrations(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid limit")
+		return
+	}
+
+	migrations, err := h.migrationService.ListMigrations(c.Request.Context(), min(limit, 100))
+	if err != nil {
+		logger.Error("ListStorageMigrations: Failed to list storage migrations", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to list storage migrations")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Storage migrations retrieved successfully", migrations)
+}
+
+// @Summary 查询存储迁移进度
+// @Tags 管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "迁移任务ID"
+// @Success 200 {object} xerr.Response "获取成功"
+// @Failure 403 {object} xerr.Response "需要管理员权限"
+// @Failure 404 {object} xerr.Response "迁移任务不存在"
+// @Router /api/v1/admin/storage/migrations/{id} [get]
+func (h *AdminHandler) GetStorageMigration(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid migration ID format")
+		return
+	}
+
+	migration, err := h.migrationService.GetMigration(c.Request.Context(), id)
+	if err != nil {
+		h.handleMigrationError(c, err, "GetStorageMigration", "Failed to get storage migration")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Storage migration retrieved successfully", migration)
+}
+
+// @Summary 继续存储迁移
+// @Description 继续失败或中断的迁移任务,从上次处理到的文件之后开始。迁移失败跳过的文件不会重试,需要重新发起迁移
+// @Tags 管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "迁移任务ID"
+// @Success 202 {object} xerr.Response "迁移任务已继续"
+// @Failure 403 {object} xerr.Response "需要管理员权限"
+// @Failure 404 {object} xerr.Response "迁移任务不存在"
+// @Failure 409 {object} xerr.Response "任务仍在进行中"
+// @Router /api/v1/admin/storage/migrations/{id}/resume [post]
+func (h *AdminHandler) ResumeStorageMigration(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid migration ID format")
+		return
+	}
+
+	migration, err := h.migrationService.ResumeMigration(c.Request.Context(), id)
+	if err != nil {
+		h.handleMigrationError(c, err, "ResumeStorageMigration", "Failed to resume storage migration")
+		return
+	}
+
+	response.Success(c, http.StatusAccepted, "Storage migration resumed", migration)
+}
+
+// @Summary 迁移单个文件的存储
+// @Description 立即把文件当前内容和所有历史版本搬到目标后端,完成后返回更新后的文件
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Param request body StorageMigrationRequest true "迁移参数"
+// @Success 200 {object} xerr.Response "迁移成功"
+// @Failure 400 {object} xerr.Response "目标后端未启用或文件没有存储对象"
+// @Failure 403 {object} xerr.Response "需要管理员权限"
+// @Failure 404 {object} xerr.Response "文件未找到"
+// @Failure 409 {object} xerr.Response "迁移期间文件被修改"
+// @Router /api/v1/admin/storage/files/{file_id}/migrate [post]
+func (h *AdminHandler) MigrateFileStorage(c *gin.Context) {
+	fileID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+
+	var req StorageMigrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+
+	file, err := h.migrationService.MigrateFile(c.Request.Context(), fileID, req.TargetBackend, req.DeleteSource)
+	if err != nil {
+		h.handleMigrationError(c, err, "MigrateFileStorage", "Failed to migrate file storage")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "File storage migrated", file)
+}
+
+// @Summary 重新校验损坏的文件
+// @Description 从备份修复存储对象后调用,重新计算被标记为损坏的文件的哈希,与记录一致时恢复为正常状态
+// @Tags 管理
+// @Produce json
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Success 200 {object} xerr.Response{data=models.File} "校验通过,已恢复正常"
+// @Failure 400 {object} xerr.Response "文件没有被标记为损坏"
+// @Failure 403 {object} xerr.Response "需要管理员权限"
+// @Failure 404 {object} xerr.Response "文件不存在"
+// @Failure 409 {object} xerr.Response "内容仍与记录的哈希不一致"
+// @Router /api/v1/admin/files/{file_id}/integrity/recheck [post]
+func (h *AdminHandler) RecheckFileIntegrity(c *gin.Context) {
+	fileID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+
+	file, err := h.integrityService.Recheck(c.Request.Context(), fileID)
+	if err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrFileNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+		case errors.Is(err, xerr.ErrFileStatusInvalid):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.FileStatusInvalidCode)
+		case errors.Is(err, xerr.ErrFileCorrupted):
+			response.ErrorCode(c, http.StatusConflict, xerr.FileCorruptedCode)
+		default:
+			logger.Error("RecheckFileIntegrity: Failed to recheck file", zap.Uint64("fileID", fileID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to recheck file")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "File integrity verified", file)
+}
+
+func (h *AdminHandler) handleMigrationError(c *gin.Context, err error, op, message string) {
+	switch {
+	case errors.Is(err, xerr.ErrInvalidParams):
+		response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+	case errors.Is(err, xerr.ErrFileStatusInvalid):
+		response.ErrorCode(c, http.StatusBadRequest, xerr.FileStatusInvalidCode)
+	case errors.Is(err, xerr.ErrMigrationNotFound):
+		response.ErrorCode(c, http.StatusNotFound, xerr.MigrationNotFoundCode)
+	case errors.Is(err, xerr.ErrFileNotFound):
+		response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+	case errors.Is(err, xerr.ErrMigrationInProgress):
+		response.ErrorCode(c, http.StatusConflict, xerr.MigrationInProgressCode)
+	case errors.Is(err, xerr.ErrVersionConflict):
+		response.ErrorCode(c, http.StatusConflict, xerr.VersionConflictCode)
+	default:
+		logger.Error(op+": "+message, zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, message)
+	}
+}
+
+// @Summary 查看账号注销任务
+// @Description 按申请时间倒序返回最近的注销任务,失败的任务包含失败的步骤和错误信息
+// @Tags 管理
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "按状态过滤: pending、revoking、purging、anonymizing、completed、failed"
+// @Success 200 {object} xerr.Response "获取成功"
+// @Failure 403 {object} xerr.Response "需要管理员权限"
+// @Router /api/v1/admin/account-deletions [get]
+func (h *AdminHandler) ListAccountDeletions(c *gin.Context) {
+	deletions, err := h.deletionService.ListDeletions(c.Request.Context(), c.Query("status"))
+	if err != nil {
+		logger.Error("ListAccountDeletions: Failed to list account deletions", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to list account deletions")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Account deletions retrieved successfully", deletions)
+}
+
+// @Summary 重试账号注销
+// @Description 重新投递失败的注销任务,从失败的步骤继续
+// @Tags 管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "注销任务ID"
+// @Success 202 {object} xerr.Response "已重新投递"
+// @Failure 403 {object} xerr.Response "需要管理员权限"
+// @Failure 404 {object} xerr.Response "注销任务不存在"
+// @Failure 409 {object} xerr.Response "任务正在进行"
+// @Router /api/v1/admin/account-deletions/{id}/retry [post]
+func (h *AdminHandler) RetryAccountDeletion(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid account deletion ID format")
+		return
+	}
+
+	deletion, err := h.deletionService.RetryDeletion(c.Request.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrAccountDeletionNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.AccountDeletionNotFoundCode)
+		case errors.Is(err, xerr.ErrAccountDeletionInProgress):
+			response.ErrorCode(c, http.StatusConflict, xerr.AccountDeletionInProgressCode)
+		default:
+			logger.Error("RetryAccountDeletion: Failed to retry account deletion", zap.Uint64("deletionID", id), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to retry account deletion")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusAccepted, "Account deletion requeued", deletion)
+}
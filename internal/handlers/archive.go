@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/metrics"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type ArchiveHandler struct {
+	archiveService explorer.ArchiveService
+}
+
+func NewArchiveHandler(archiveService explorer.ArchiveService) *ArchiveHandler {
+	return &ArchiveHandler{
+		archiveService: archiveService,
+	}
+}
+
+// @Summary 异步打包文件夹
+// @Description 在后台把文件夹打包为 ZIP 暂存,完成后通过打包详情获取下载链接。与流式下载不同,打包好的 ZIP 支持 Range 请求和断点续传,过期后自动删除。同一文件夹已有进行中的打包任务时返回该任务
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "文件夹ID"
+// @Success 202 {object} xerr.Response "打包任务"
+// @Failure 400 {object} xerr.Response "目标不是文件夹"
+// @Failure 403 {object} xerr.Response "无权限"
+// @Failure 404 {object} xerr.Response "文件夹未找到"
+// @Router /api/v1/files/{id}/archive [post]
+func (h *ArchiveHandler) RequestArchive(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	folderID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid folder ID format")
+		return
+	}
+
+	job, err := h.archiveService.RequestArchive(c.Request.Context(), currentUserID, folderID)
+	if err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrFileNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+		case errors.Is(err, xerr.ErrPermissionDenied):
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		case errors.Is(err, xerr.ErrFileStatusInvalid):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.FileStatusInvalidCode)
+		case errors.Is(err, xerr.ErrTargetNotFolder):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.TargetNotFolderCode)
+		case handleFileError(c, err):
+		default:
+			logger.Error("RequestArchive: Failed to request archive", zap.Uint64("folderID", folderID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to request archive")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusAccepted, "Archive requested successfully", job)
+}
+
+// @Summary 获取打包详情
+// @Description 获取文件夹打包任务的状态,已完成时返回限时下载链接
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param job_id path int true "打包任务ID"
+// @Success 200 {object} xerr.Response "打包详情"
+// @Failure 404 {object} xerr.Response "打包任务不存在或已过期"
+// @Router /api/v1/files/archives/{job_id} [get]
+func (h *ArchiveHandler) GetArchive(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	jobID, err := strconv.ParseUint(c.Param("job_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid archive ID format")
+		return
+	}
+
+	job, err := h.archiveService.GetArchive(c.Request.Context(), currentUserID, jobID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrArchiveNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.ArchiveNotFoundCode)
+			return
+		}
+		if handleFileError(c, err) {
+			return
+		}
+		logger.Error("GetArchive: Failed to get archive", zap.Uint64("jobID", jobID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to get archive")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Archive retrieved successfully", job)
+}
+
+// @Summary 下载打包好的文件夹
+// @Description 下载已完成的 ZIP,支持 Range 请求,中断后可以从断点继续下载
+// @Tags 文件
+// @Produce application/zip
+// @Security BearerAuth
+// @Param job_id path int true "打包任务ID"
+// @Success 200 {file} file "ZIP 文件"
+// @Success 206 {file} file "ZIP 文件的一部分"
+// @Failure 404 {object} xerr.Response "打包任务不存在、未完成或已过期"
+// @Router /api/v1/files/archives/{job_id}/download [get]
+func (h *ArchiveHandler) DownloadArchive(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	jobID, err := strconv.ParseUint(c.Param("job_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid archive ID format")
+		return
+	}
+
+	job, reader, err := h.archiveService.OpenArchive(c.Request.Context(), currentUserID, jobID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrArchiveNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.ArchiveNotFoundCode)
+			return
+		}
+		if handleFileError(c, err) {
+			return
+		}
+		logger.Error("DownloadArchive: Failed to open archive", zap.Uint64("jobID", jobID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to download archive")
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", job.FileName))
+	c.Header("ETag", fmt.Sprintf("\"archive-%d\"", job.ID))
+
+	// 本地存储和 MinIO 的对象可以定位,由 ServeContent 处理 Range 和 If-Range
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		c.Header("Content-Type", "application/zip")
+		counter := &countingWriter{ResponseWriter: c.Writer}
+		http.ServeContent(counter, c.Request, job.FileName, job.UpdatedAt, seeker)
+		metrics.AddTransferBytes(metrics.DirectionDownload, counter.written)
+		return
+	}
+
+	// 其他存储后端重定向到预签名链接,由对象存储处理 Range 请求
+	job, err = h.archiveService.GetArchive(c.Request.Context(), currentUserID, jobID)
+	if err != nil {
+		if handleFileError(c, err) {
+			return
+		}
+		logger.Error("DownloadArchive: Failed to generate download URL", zap.Uint64("jobID", jobID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to download archive")
+		return
+	}
+	c.Redirect(http.StatusFound, job.URL)
+}
+
+// countingWriter 统计写入响应的字节数
+type countingWriter struct {
+	gin.ResponseWriter
+	written int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.written += int64(n)
+	return n, err
+}
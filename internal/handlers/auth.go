@@ -6,22 +6,28 @@ import (
 
 	"github.com/3Eeeecho/go-clouddisk/internal/config"
 	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
 	"github.com/3Eeeecho/go-clouddisk/internal/services/admin"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
 	"github.com/gin-gonic/gin"
 )
 
 // AuthHandler 结构体持有 AuthService 依赖
 type AuthHandler struct {
-	authService admin.AuthService
-	cfg         *config.Config
+	authService      admin.AuthService
+	sessionService   admin.SessionService
+	cacheWarmService explorer.CacheWarmService
+	cfg              *config.Config
 }
 
 // NewAuthHandler 创建 AuthHandler 实例的构造函数
-func NewAuthHandler(authService admin.AuthService, cfg *config.Config) *AuthHandler {
+func NewAuthHandler(authService admin.AuthService, sessionService admin.SessionService, cacheWarmService explorer.CacheWarmService, cfg *config.Config) *AuthHandler {
 	return &AuthHandler{
-		authService: authService,
-		cfg:         cfg,
+		authService:      authService,
+		sessionService:   sessionService,
+		cacheWarmService: cacheWarmService,
+		cfg:              cfg,
 	}
 }
 
@@ -51,19 +57,19 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBind(&req); err != nil {
 		// 参数绑定错误，使用通用错误响应
-		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, err.Error())
+		response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
 		return
 	}
 
-	_, err := h.authService.RegisterUser(req.Username, req.Password, req.Email)
+	_, err := h.authService.RegisterUser(c.Request.Context(), req.Username, req.Password, req.Email)
 	if err != nil {
 		// 根据错误类型返回不同的状态码和业务码
 		if errors.Is(err, xerr.ErrUserAlreadyExists) {
-			response.Error(c, http.StatusConflict, xerr.UserAlreadyExistsCode, err.Error())
+			response.ErrorCode(c, http.StatusConflict, xerr.UserAlreadyExistsCode)
 			return
 		}
 		if errors.Is(err, xerr.ErrEmailAlreadyExists) {
-			response.Error(c, http.StatusConflict, xerr.EmailAlreadyExistsCode, err.Error())
+			response.ErrorCode(c, http.StatusConflict, xerr.EmailAlreadyExistsCode)
 			return
 		}
 		// 其他内部服务器错误
@@ -75,23 +81,23 @@ func (h *AuthHandler) Register(c *gin.Context) {
 }
 
 // @Summary 用户登录
-// @Description 用户登录接口
+// @Description 用户登录接口。开启了两步验证的用户返回 2fa_required 和 2fa_token，需要调用 /auth/2fa 换取登录 token
 // @Tags 用户认证
 // @Accept json
 // @Produce json
 // @Param data body LoginRequest true "登录信息"
-// @Success 200 {object} xerr.Response "登录成功，返回token"
+// @Success 200 {object} xerr.Response{data=admin.TokenPair} "登录成功，返回访问token和刷新token"
 // @Failure 400 {object} xerr.Response "参数错误"
 // @Failure 401 {object} xerr.Response "用户名或密码错误"
 // @Router /api/v1/auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, err.Error())
+		response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
 		return
 	}
 
-	token, err := h.authService.LoginUser(req.Identifier, req.Password)
+	result, err := h.authService.LoginUser(c.Request.Context(), req.Identifier, req.Password, c.Request.UserAgent())
 	if err != nil {
 		if errors.Is(err, xerr.ErrUserNotFound) {
 			response.Error(c, http.StatusUnauthorized, xerr.UserNotFoundCode, "用户不存在")
@@ -102,20 +108,378 @@ func (h *AuthHandler) Login(c *gin.Context) {
 			response.Error(c, http.StatusUnauthorized, xerr.InvalidCredentialsCode, "账户名或密码错误")
 			return
 		}
+		if errors.Is(err, xerr.ErrAccountDeletionPending) {
+			response.ErrorCode(c, http.StatusForbidden, xerr.AccountDeletionPendingCode)
+			return
+		}
 		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "登陆失败")
 		return
 	}
 
-	response.Success(c, http.StatusOK, "登录成功", gin.H{"token": token})
+	if result.TwoFactorRequired {
+		response.Success(c, http.StatusOK, "需要两步验证", gin.H{"2fa_required": true, "2fa_token": result.TwoFactorToken})
+		return
+	}
+	// 后台预热根目录和最近使用的文件夹,网页端首屏无需等待回源查询
+	h.cacheWarmService.Warm(c.Request.Context(), result.Tokens.UserID)
+	response.Success(c, http.StatusOK, "登录成功", result.Tokens)
+}
+
+// EmailTokenRequest 携带邮件中令牌的请求体
+type EmailTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ForgotPasswordRequest 找回密码请求体
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest 重置密码请求体
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6,max=255"`
+}
+
+// @Summary 验证邮箱
+// @Description 使用验证邮件中的令牌完成邮箱验证，令牌只能使用一次
+// @Tags 用户认证
+// @Accept json
+// @Produce json
+// @Param data body EmailTokenRequest true "验证令牌"
+// @Success 200 {object} xerr.Response "验证成功"
+// @Failure 400 {object} xerr.Response "链接无效或已过期"
+// @Router /api/v1/auth/verify [post]
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req EmailTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+		return
+	}
+
+	if err := h.authService.VerifyEmail(c.Request.Context(), req.Token); err != nil {
+		if errors.Is(err, xerr.ErrEmailTokenInvalid) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.EmailTokenInvalidCode)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "邮箱验证失败")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "邮箱验证成功", nil)
+}
+
+// @Summary 找回密码
+// @Description 向注册邮箱发送密码重置链接。为避免泄露账户是否存在，邮箱未注册时同样返回成功
+// @Tags 用户认证
+// @Accept json
+// @Produce json
+// @Param data body ForgotPasswordRequest true "注册邮箱"
+// @Success 200 {object} xerr.Response "如果邮箱已注册，重置链接已发送"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Router /api/v1/auth/forgot [post]
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+		return
+	}
+
+	if err := h.authService.ForgotPassword(c.Request.Context(), req.Email); err != nil {
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "发送重置邮件失败")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "如果该邮箱已注册，密码重置链接已发送", nil)
+}
+
+// @Summary 重置密码
+// @Description 使用密码重置邮件中的令牌设置新密码，令牌只能使用一次
+// @Tags 用户认证
+// @Accept json
+// @Produce json
+// @Param data body ResetPasswordRequest true "令牌和新密码"
+// @Success 200 {object} xerr.Response "密码已重置"
+// @Failure 400 {object} xerr.Response "参数错误或链接无效"
+// @Router /api/v1/auth/reset [post]
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+		return
+	}
+
+	if err := h.authService.ResetPassword(c.Request.Context(), req.Token, req.Password); err != nil {
+		if errors.Is(err, xerr.ErrEmailTokenInvalid) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.EmailTokenInvalidCode)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "重置密码失败")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "密码已重置，请使用新密码登录", nil)
+}
+
+// @Summary 重新发送验证邮件
+// @Description 向当前用户的邮箱重新发送验证链接
+// @Tags 用户认证
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} xerr.Response "验证邮件已发送"
+// @Failure 409 {object} xerr.Response "邮箱已验证"
+// @Router /api/v1/users/me/verify-email [post]
+func (h *AuthHandler) ResendVerificationEmail(c *gin.Context) {
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	if err := h.authService.SendVerificationEmail(c.Request.Context(), userID); err != nil {
+		if errors.Is(err, xerr.ErrEmailAlreadyVerified) {
+			response.ErrorCode(c, http.StatusConflict, xerr.EmailAlreadyVerifiedCode)
+			return
+		}
+		if errors.Is(err, xerr.ErrUserNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.UserNotFoundCode)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "发送验证邮件失败")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "验证邮件已发送", nil)
+}
+
+// RefreshTokenRequest 刷新 Token 请求体
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
 // @Summary 刷新Token
-// @Description 刷新JWT Token
+// @Description 使用刷新 token 换取新的访问 token 和刷新 token，旧的刷新 token 随即失效。已使用过的刷新 token 再次出现时整个会话被撤销
 // @Tags 用户认证
+// @Accept json
 // @Produce json
-// @Success 200 {object} xerr.Response "刷新成功"
+// @Param data body RefreshTokenRequest true "刷新 token"
+// @Success 200 {object} xerr.Response{data=admin.TokenPair} "刷新成功"
+// @Failure 401 {object} xerr.Response "刷新 token 无效、已过期或已被撤销"
 // @Router /api/v1/auth/refresh [post]
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
-	//TODO
-	response.Success(c, http.StatusOK, "Refresh token endpoint - To be implemented", nil)
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+		return
+	}
+
+	tokens, err := h.sessionService.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, xerr.ErrTokenInvalid) {
+			response.Error(c, http.StatusUnauthorized, xerr.TokenInvalidCode, "登录已过期，请重新登录")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "刷新Token失败")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "刷新成功", tokens)
+}
+
+// @Summary 登录会话列表
+// @Description 列出当前用户已登录的设备，current 标记发起请求的会话
+// @Tags 用户认证
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} xerr.Response{data=[]models.Session} "获取成功"
+// @Router /api/v1/users/me/sessions [get]
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.sessionService.List(c.Request.Context(), userID, c.GetString("sessionID"))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "获取登录会话失败")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "获取成功", sessions)
+}
+
+// @Summary 撤销登录会话
+// @Description 撤销指定会话，该会话的刷新 token 和已签发的访问 token 立即失效。撤销当前会话相当于退出登录
+// @Tags 用户认证
+// @Produce json
+// @Security BearerAuth
+// @Param session_id path string true "会话ID"
+// @Success 200 {object} xerr.Response "会话已撤销"
+// @Failure 404 {object} xerr.Response "会话不存在"
+// @Router /api/v1/users/me/sessions/{session_id} [delete]
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	if err := h.sessionService.Revoke(c.Request.Context(), userID, c.Param("session_id")); err != nil {
+		if errors.Is(err, xerr.ErrSessionNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.SessionNotFoundCode)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "撤销会话失败")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "会话已撤销", nil)
+}
+
+// TwoFactorLoginRequest 两步验证登录请求体
+type TwoFactorLoginRequest struct {
+	Token string `json:"2fa_token" binding:"required"`
+	Code  string `json:"code" binding:"required"` // 身份验证器中的 6 位验证码或恢复码
+}
+
+// TwoFactorCodeRequest 携带两步验证码的请求体
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// @Summary 两步验证登录
+// @Description 使用登录接口返回的 2fa_token 和验证码(或恢复码)换取登录 token，恢复码只能使用一次
+// @Tags 用户认证
+// @Accept json
+// @Produce json
+// @Param data body TwoFactorLoginRequest true "中间 token 和验证码"
+// @Success 200 {object} xerr.Response{data=admin.TokenPair} "登录成功，返回访问token和刷新token"
+// @Failure 401 {object} xerr.Response "token 无效或验证码不正确,同一 token 连续错误多次后失效,需要重新登录"
+// @Failure 429 {object} xerr.Response "账号两步验证错误次数过多,暂时锁定"
+// @Router /api/v1/auth/2fa [post]
+func (h *AuthHandler) TwoFactorLogin(c *gin.Context) {
+	var req TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+		return
+	}
+
+	tokens, err := h.authService.CompleteTwoFactorLogin(c.Request.Context(), req.Token, req.Code, c.Request.UserAgent())
+	if err != nil {
+		if errors.Is(err, xerr.ErrTokenInvalid) {
+			response.Error(c, http.StatusUnauthorized, xerr.TokenInvalidCode, "登录已过期，请重新登录")
+			return
+		}
+		if errors.Is(err, xerr.ErrTwoFactorCodeInvalid) {
+			response.ErrorCode(c, http.StatusUnauthorized, xerr.TwoFactorCodeInvalidCode)
+			return
+		}
+		if errors.Is(err, xerr.ErrTwoFactorLocked) {
+			response.ErrorCode(c, http.StatusTooManyRequests, xerr.TwoFactorLockedCode)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "登陆失败")
+		return
+	}
+
+	h.cacheWarmService.Warm(c.Request.Context(), tokens.UserID)
+	response.Success(c, http.StatusOK, "登录成功", tokens)
+}
+
+// @Summary 设置两步验证
+// @Description 生成新的两步验证密钥和 otpauth:// 链接(可渲染为二维码)，需要调用确认接口后才生效
+// @Tags 用户认证
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} xerr.Response{data=admin.TwoFactorEnrollment} "密钥已生成"
+// @Failure 409 {object} xerr.Response "两步验证已开启"
+// @Router /api/v1/users/me/2fa/enroll [post]
+func (h *AuthHandler) EnrollTwoFactor(c *gin.Context) {
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.authService.EnrollTwoFactor(c.Request.Context(), userID)
+	if err != nil {
+		h.handleTwoFactorError(c, err, "设置两步验证失败")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "请使用身份验证器扫描二维码", enrollment)
+}
+
+// @Summary 开启两步验证
+// @Description 使用身份验证器生成的验证码确认设置，返回的恢复码只展示这一次
+// @Tags 用户认证
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param data body TwoFactorCodeRequest true "验证码"
+// @Success 200 {object} xerr.Response "两步验证已开启，返回恢复码"
+// @Failure 400 {object} xerr.Response "尚未设置两步验证"
+// @Failure 401 {object} xerr.Response "验证码不正确"
+// @Router /api/v1/users/me/2fa/verify [post]
+func (h *AuthHandler) EnableTwoFactor(c *gin.Context) {
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+		return
+	}
+
+	codes, err := h.authService.EnableTwoFactor(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		h.handleTwoFactorError(c, err, "开启两步验证失败")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "两步验证已开启，请妥善保存恢复码", gin.H{"recovery_codes": codes})
+}
+
+// @Summary 关闭两步验证
+// @Description 使用验证码或恢复码关闭两步验证，未使用的恢复码同时失效
+// @Tags 用户认证
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param data body TwoFactorCodeRequest true "验证码或恢复码"
+// @Success 200 {object} xerr.Response "两步验证已关闭"
+// @Failure 400 {object} xerr.Response "未开启两步验证"
+// @Failure 401 {object} xerr.Response "验证码不正确"
+// @Router /api/v1/users/me/2fa/disable [post]
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+		return
+	}
+
+	if err := h.authService.DisableTwoFactor(c.Request.Context(), userID, req.Code); err != nil {
+		h.handleTwoFactorError(c, err, "关闭两步验证失败")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "两步验证已关闭", nil)
+}
+
+// handleTwoFactorError 两步验证管理接口共用的错误处理
+func (h *AuthHandler) handleTwoFactorError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, xerr.ErrTwoFactorAlreadyEnabled):
+		response.ErrorCode(c, http.StatusConflict, xerr.TwoFactorAlreadyEnabledCode)
+	case errors.Is(err, xerr.ErrTwoFactorNotEnabled):
+		response.ErrorCode(c, http.StatusBadRequest, xerr.TwoFactorNotEnabledCode)
+	case errors.Is(err, xerr.ErrTwoFactorCodeInvalid):
+		response.ErrorCode(c, http.StatusUnauthorized, xerr.TwoFactorCodeInvalidCode)
+	case errors.Is(err, xerr.ErrUserNotFound):
+		response.ErrorCode(c, http.StatusNotFound, xerr.UserNotFoundCode)
+	default:
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, message)
+	}
 }
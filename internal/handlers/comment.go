@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type CommentHandler struct {
+	commentService explorer.CommentService
+}
+
+func NewCommentHandler(commentService explorer.CommentService) *CommentHandler {
+	return &CommentHandler{
+		commentService: commentService,
+	}
+}
+
+// PostCommentRequest 发表评论的请求体
+type PostCommentRequest struct {
+	Content string `json:"content" binding:"required"`
+}
+
+// @Summary 发表评论
+// @Description 在文件或文件夹上发表评论,评论中的 @用户名 会在被提及用户的活动日志中留下记录(仅限能访问该文件的用户)
+// @Tags 文件
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Param request body PostCommentRequest true "评论内容,最多2000个字符"
+// @Success 200 {object} xerr.Response "发表的评论"
+// @Failure 400 {object} xerr.Response "评论为空或过长"
+// @Failure 403 {object} xerr.Response "无权限"
+// @Failure 404 {object} xerr.Response "文件未找到"
+// @Router /api/v1/files/{file_id}/comments [post]
+func (h *CommentHandler) PostComment(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	fileID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+
+	var req PostCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+
+	comment, err := h.commentService.PostComment(c.Request.Context(), currentUserID, fileID, req.Content)
+	if err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrInvalidParams):
+			response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Comment must be 1 to 2000 characters")
+		case errors.Is(err, xerr.ErrFileNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+		case errors.Is(err, xerr.ErrPermissionDenied):
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		case errors.Is(err, xerr.ErrFileStatusInvalid):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.FileStatusInvalidCode)
+		case handleFileError(c, err):
+		default:
+			logger.Error("PostComment: Failed to post comment", zap.Uint64("fileID", fileID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to post comment")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Comment posted successfully", comment)
+}
+
+// @Summary 获取文件评论
+// @Description 分页获取文件或文件夹上的评论,按发表时间从早到晚排列
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Param page query int false "页码，默认为1" default(1)
+// @Param pageSize query int false "每页数量，默认为10" default(10)
+// @Success 200 {object} xerr.Response "评论列表"
+// @Failure 403 {object} xerr.Response "无权限"
+// @Failure 404 {object} xerr.Response "文件未找到"
+// @Router /api/v1/files/{file_id}/comments [get]
+func (h *CommentHandler) ListComments(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	fileID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+
+	page, pageSize := parsePagination(c)
+	comments, total, err := h.commentService.ListComments(c.Request.Context(), currentUserID, fileID, page, pageSize)
+	if err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrFileNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+		case errors.Is(err, xerr.ErrPermissionDenied):
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		case errors.Is(err, xerr.ErrFileStatusInvalid):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.FileStatusInvalidCode)
+		case handleFileError(c, err):
+		default:
+			logger.Error("ListComments: Failed to list comments", zap.Uint64("fileID", fileID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to list comments")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Comments listed successfully", gin.H{
+		"comments": comments,
+		"total":    total,
+	})
+}
+
+// @Summary 删除评论
+// @Description 删除文件上的一条评论,评论者本人和文件所有者可以删除
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Param comment_id path int true "评论ID"
+// @Success 200 {object} xerr.Response "删除成功"
+// @Failure 403 {object} xerr.Response "无权限"
+// @Failure 404 {object} xerr.Response "评论不存在"
+// @Router /api/v1/files/{file_id}/comments/{comment_id} [delete]
+func (h *CommentHandler) DeleteComment(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	fileID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+	commentID, err := strconv.ParseUint(c.Param("comment_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid comment ID format")
+		return
+	}
+
+	if err := h.commentService.DeleteComment(c.Request.Context(), currentUserID, fileID, commentID); err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrCommentNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.CommentNotFoundCode)
+		case errors.Is(err, xerr.ErrFileNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+		case errors.Is(err, xerr.ErrPermissionDenied):
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		case errors.Is(err, xerr.ErrFileStatusInvalid):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.FileStatusInvalidCode)
+		case handleFileError(c, err):
+		default:
+			logger.Error("DeleteComment: Failed to delete comment", zap.Uint64("commentID", commentID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to delete comment")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Comment deleted successfully", nil)
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/gin-gonic/gin"
+)
+
+// fileETag 根据文件元数据版本号生成强 ETag,如 "3"。内容和元数据的每次修改都会增加版本号,
+// 下载返回的 ETag 可以直接作为修改请求的 If-Match 使用
+func fileETag(file *models.File) string {
+	return `"` + strconv.FormatUint(file.Version, 10) + `"`
+}
+
+// writeValidators 设置 ETag 和 Last-Modified 响应头
+func writeValidators(c *gin.Context, file *models.File) {
+	if etag := fileETag(file); etag != "" {
+		c.Header("ETag", etag)
+	}
+	if !file.UpdatedAt.IsZero() {
+		c.Header("Last-Modified", file.UpdatedAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// checkNotModified 设置缓存校验头并处理条件请求,客户端缓存仍然有效时返回 304 并返回 true。
+// 按 RFC 9110,存在 If-None-Match 时忽略 If-Modified-Since。被隔离的文件不走缓存,交给后续流程返回错误
+func checkNotModified(c *gin.Context, file *models.File) bool {
+	if file.ScanStatus == models.ScanStatusInfected {
+		return false
+	}
+	writeValidators(c, file)
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		etag := fileETag(file)
+		if etag == "" || !etagMatches(inm, etag) {
+			return false
+		}
+		c.Status(http.StatusNotModified)
+		return true
+	}
+
+	ims := c.GetHeader("If-Modified-Since")
+	if ims == "" || file.UpdatedAt.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	// HTTP 日期只精确到秒
+	if file.UpdatedAt.Truncate(time.Second).After(since) {
+		return false
+	}
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// etagMatches 对 If-None-Match 中的每个 ETag 做弱比较,"*" 匹配任意存在的资源
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseExpectedVersion 读取客户端期望的文件元数据版本号,优先使用 If-Match 请求头(如 "3"),其次使用请求参数 fallback。
+// If-Match 为 * 时不做检查。格式错误时已写入 400 响应并返回 false
+func parseExpectedVersion(c *gin.Context, fallback *uint64) (*uint64, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		return fallback, true
+	}
+	if header == "*" {
+		return nil, true
+	}
+
+	version, err := strconv.ParseUint(strings.Trim(strings.TrimPrefix(header, "W/"), `"`), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "If-Match must be the file version")
+		return nil, false
+	}
+	return &version, true
+}
+
+// respondVersionConflict 返回 409 和文件的当前状态,客户端可以据此合并修改后重试
+func (h *FileHandler) respondVersionConflict(c *gin.Context, userID uint64, fileID uint64) {
+	current, err := h.fileService.GetFileByID(c.Request.Context(), userID, fileID)
+	if err != nil {
+		response.ErrorCode(c, http.StatusConflict, xerr.VersionConflictCode)
+		return
+	}
+	response.ErrorCodeWithData(c, http.StatusConflict, xerr.VersionConflictCode, gin.H{"file": current})
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary 获取错误码目录
+// @Description 列出全部业务错误码及其 HTTP 状态码、英文标识和默认说明,客户端可以据此映射错误
+// @Tags 错误码
+// @Produce json
+// @Success 200 {object} xerr.Response "错误码列表"
+// @Router /api/v1/errors [get]
+func ListErrorCodes(c *gin.Context) {
+	response.Success(c, http.StatusOK, "Error codes listed successfully", xerr.Catalog())
+}
+
+// @Summary 获取错误码说明
+// @Description 错误响应中的 doc_url 指向该接口
+// @Tags 错误码
+// @Produce json
+// @Param code path int true "错误码"
+// @Success 200 {object} xerr.Response "错误码说明"
+// @Failure 404 {object} xerr.Response "错误码不存在"
+// @Router /api/v1/errors/{code} [get]
+func GetErrorCode(c *gin.Context) {
+	code, err := strconv.Atoi(c.Param("code"))
+	if err != nil {
+		response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+		return
+	}
+
+	entry, ok := xerr.Lookup(code)
+	if !ok {
+		response.ErrorCode(c, http.StatusNotFound, xerr.NotFoundCode)
+		return
+	}
+	response.Success(c, http.StatusOK, "Error code retrieved successfully", entry)
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type ExportHandler struct {
+	exportService explorer.ExportService
+}
+
+func NewExportHandler(exportService explorer.ExportService) *ExportHandler {
+	return &ExportHandler{
+		exportService: exportService,
+	}
+}
+
+// RequestExportRequest 发起导出的请求体
+type RequestExportRequest struct {
+	Scope string `json:"scope" binding:"omitempty,oneof=all recycle_bin"` // 导出范围,默认 all
+}
+
+// @Summary 导出账户数据
+// @Description 异步把整个网盘或回收站打包为一个或多个 ZIP 分卷,完成后会记录到活动日志,通过导出详情获取限时下载链接
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body RequestExportRequest false "导出范围"
+// @Success 202 {object} xerr.Response "导出任务已创建"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 409 {object} xerr.Response "已有正在进行的导出任务"
+// @Router /api/v1/users/me/export [post]
+func (h *ExportHandler) RequestExport(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req RequestExportRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+			return
+		}
+	}
+
+	export, err := h.exportService.RequestExport(c.Request.Context(), currentUserID, req.Scope)
+	if err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrInvalidParams):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+		case errors.Is(err, xerr.ErrExportInProgress):
+			response.ErrorCode(c, http.StatusConflict, xerr.ExportInProgressCode)
+		default:
+			logger.Error("RequestExport: Failed to request export", zap.Uint64("userID", currentUserID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to request export")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusAccepted, "Export requested successfully", export)
+}
+
+// @Summary 获取导出列表
+// @Description 获取最近的账户数据导出任务
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} xerr.Response "导出列表"
+// @Router /api/v1/users/me/exports [get]
+func (h *ExportHandler) ListExports(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	exports, err := h.exportService.ListExports(c.Request.Context(), currentUserID)
+	if err != nil {
+		logger.Error("ListExports: Failed to list exports", zap.Uint64("userID", currentUserID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to list exports")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Exports listed successfully", exports)
+}
+
+// @Summary 获取导出详情
+// @Description 获取导出任务的状态,已完成时返回每个 ZIP 分卷的限时下载链接
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Param export_id path int true "导出任务ID"
+// @Success 200 {object} xerr.Response "导出详情"
+// @Failure 404 {object} xerr.Response "导出任务不存在或已过期"
+// @Router /api/v1/users/me/exports/{export_id} [get]
+func (h *ExportHandler) GetExport(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	exportID, err := strconv.ParseUint(c.Param("export_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid export ID format")
+		return
+	}
+
+	export, err := h.exportService.GetExport(c.Request.Context(), currentUserID, exportID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrExportNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.ExportNotFoundCode)
+			return
+		}
+		logger.Error("GetExport: Failed to get export", zap.Uint64("exportID", exportID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to get export")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Export retrieved successfully", export)
+}
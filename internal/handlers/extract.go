@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type ExtractHandler struct {
+	extractService explorer.ExtractService
+}
+
+func NewExtractHandler(extractService explorer.ExtractService) *ExtractHandler {
+	return &ExtractHandler{
+		extractService: extractService,
+	}
+}
+
+// @Summary 在线解压压缩包
+// @Description 在后台把网盘中的 ZIP、TAR 或 TAR.GZ 压缩包解压到目标文件夹下以压缩包命名的新文件夹中,重名的文件自动重命名。解压受条目数、总大小、压缩比和存储空间限制,包含 ".." 或绝对路径的压缩包会被拒绝。同一压缩包已有进行中的解压任务时返回该任务
+// @Tags 文件
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "压缩包文件ID"
+// @Param request body models.ExtractRequest false "解压目标,不传时解压到压缩包所在的文件夹"
+// @Success 202 {object} xerr.Response "解压任务"
+// @Failure 400 {object} xerr.Response "不支持的压缩包格式或目标不是文件夹"
+// @Failure 403 {object} xerr.Response "无权限"
+// @Failure 404 {object} xerr.Response "文件未找到"
+// @Router /api/v1/files/{id}/extract [post]
+func (h *ExtractHandler) RequestExtract(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	fileID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+	var req models.ExtractRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body")
+		return
+	}
+
+	job, err := h.extractService.RequestExtract(c.Request.Context(), currentUserID, fileID, req.TargetFolderID)
+	if err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrUnsupportedArchive):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.UnsupportedArchiveCode)
+		case handleFileError(c, err):
+		default:
+			logger.Error("RequestExtract: Failed to request extract", zap.Uint64("fileID", fileID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to request extract")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusAccepted, "Extract requested successfully", job)
+}
+
+// @Summary 获取解压进度
+// @Description 获取解压任务的状态和进度,失败时已解压的内容保留在新建的文件夹中
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param job_id path int true "解压任务ID"
+// @Success 200 {object} xerr.Response "解压任务"
+// @Failure 404 {object} xerr.Response "解压任务不存在"
+// @Router /api/v1/files/extracts/{job_id} [get]
+func (h *ExtractHandler) GetExtract(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	jobID, err := strconv.ParseUint(c.Param("job_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid extract job ID format")
+		return
+	}
+
+	job, err := h.extractService.GetExtract(c.Request.Context(), currentUserID, jobID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrExtractJobNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.ExtractJobNotFoundCode)
+			return
+		}
+		logger.Error("GetExtract: Failed to get extract job", zap.Uint64("jobID", jobID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to get extract job")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Extract job retrieved successfully", job)
+}
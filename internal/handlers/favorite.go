@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type FavoriteHandler struct {
+	favoriteService explorer.FavoriteService
+}
+
+func NewFavoriteHandler(favoriteService explorer.FavoriteService) *FavoriteHandler {
+	return &FavoriteHandler{
+		favoriteService: favoriteService,
+	}
+}
+
+// @Summary 收藏文件
+// @Description 为文件或文件夹加星标,重复收藏不会报错
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Success 200 {object} xerr.Response "收藏成功"
+// @Failure 403 {object} xerr.Response "无权限"
+// @Failure 404 {object} xerr.Response "文件未找到"
+// @Router /api/v1/files/{file_id}/star [post]
+func (h *FavoriteHandler) StarFile(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	fileID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+
+	if err := h.favoriteService.Star(c.Request.Context(), currentUserID, fileID); err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrFileNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+		case errors.Is(err, xerr.ErrPermissionDenied):
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		case errors.Is(err, xerr.ErrFileStatusInvalid):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.FileStatusInvalidCode)
+		case handleFileError(c, err):
+		default:
+			logger.Error("StarFile: Failed to star file", zap.Uint64("fileID", fileID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to star file")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "File starred successfully", nil)
+}
+
+// @Summary 取消收藏
+// @Description 取消文件或文件夹的星标
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Success 200 {object} xerr.Response "取消成功"
+// @Failure 404 {object} xerr.Response "未收藏该文件"
+// @Router /api/v1/files/{file_id}/star [delete]
+func (h *FavoriteHandler) UnstarFile(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	fileID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+
+	if err := h.favoriteService.Unstar(c.Request.Context(), currentUserID, fileID); err != nil {
+		if errors.Is(err, xerr.ErrFileNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+			return
+		}
+		if handleFileError(c, err) {
+			return
+		}
+		logger.Error("UnstarFile: Failed to unstar file", zap.Uint64("fileID", fileID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to unstar file")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "File unstarred successfully", nil)
+}
+
+// @Summary 获取收藏列表
+// @Description 分页获取收藏的文件和文件夹,按收藏时间倒序,回收站中的文件不会返回
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "页码，默认为1" default(1)
+// @Param pageSize query int false "每页数量，默认为10" default(10)
+// @Success 200 {object} xerr.Response "收藏列表"
+// @Router /api/v1/files/starred [get]
+func (h *FavoriteHandler) ListStarredFiles(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	page, pageSize := parsePagination(c)
+	favorites, total, err := h.favoriteService.ListStarred(c.Request.Context(), currentUserID, page, pageSize)
+	if err != nil {
+		if handleFileError(c, err) {
+			return
+		}
+		logger.Error("ListStarredFiles: Failed to list starred files", zap.Uint64("userID", currentUserID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to list starred files")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Starred files listed successfully", gin.H{
+		"favorites": favorites,
+		"total":     total,
+	})
+}
+
+// @Summary 获取最近访问的文件
+// @Description 按访问时间倒序返回最近上传、下载、重命名、移动或分享过的文件
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "返回数量，默认为20，最大为50" default(20)
+// @Success 200 {object} xerr.Response "最近访问的文件"
+// @Router /api/v1/files/recent [get]
+func (h *FavoriteHandler) ListRecentFiles(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+
+	files, err := h.favoriteService.ListRecent(c.Request.Context(), currentUserID, limit)
+	if err != nil {
+		if handleFileError(c, err) {
+			return
+		}
+		logger.Error("ListRecentFiles: Failed to list recent files", zap.Uint64("userID", currentUserID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to list recent files")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Recent files listed successfully", files)
+}
@@ -1,32 +1,50 @@
 package handlers
 
 import (
-	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/3Eeeecho/go-clouddisk/internal/config"
 	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/metrics"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/admin"
 	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
 type FileHandler struct {
-	fileService explorer.FileService
-	cfg         *config.Config
+	fileService      explorer.FileService
+	lockService      explorer.FileLockService
+	previewService   explorer.PreviewService
+	statsService     explorer.FileStatsService
+	bandwidthService admin.BandwidthService
+	tagService       explorer.TagService
+	purgeService     explorer.PurgeService
+	cacheWarmService explorer.CacheWarmService
+	cfg              *config.Config
 }
 
-func NewFileHandler(fileService explorer.FileService, cfg *config.Config) *FileHandler {
+func NewFileHandler(fileService explorer.FileService, lockService explorer.FileLockService, previewService explorer.PreviewService, statsService explorer.FileStatsService, bandwidthService admin.BandwidthService, tagService explorer.TagService, purgeService explorer.PurgeService, cacheWarmService explorer.CacheWarmService, cfg *config.Config) *FileHandler {
 	return &FileHandler{
-		fileService: fileService,
-		cfg:         cfg,
+		fileService:      fileService,
+		lockService:      lockService,
+		previewService:   previewService,
+		statsService:     statsService,
+		bandwidthService: bandwidthService,
+		tagService:       tagService,
+		purgeService:     purgeService,
+		cacheWarmService: cacheWarmService,
+		cfg:              cfg,
 	}
 }
 
@@ -52,10 +70,13 @@ func (h *FileHandler) GetSpecificFile(c *gin.Context) {
 		return
 	}
 
-	files, err := h.fileService.GetFileByID(currentUserID, fileID)
+	files, err := h.fileService.GetFileByID(c.Request.Context(), currentUserID, fileID)
 	if err != nil {
 		if errors.Is(err, xerr.ErrFileNotFound) {
-			response.Error(c, http.StatusNotFound, xerr.FileNotFoundCode, err.Error())
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+			return
+		}
+		if handleFileError(c, err) {
 			return
 		}
 		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to get file info")
@@ -65,11 +86,23 @@ func (h *FileHandler) GetSpecificFile(c *gin.Context) {
 }
 
 // @Summary 获取用户文件列表
-// @Description 获取当前用户指定文件夹下的文件和文件夹列表
+// @Description 获取当前用户指定文件夹下的文件和文件夹列表,指定 tag 时忽略 parent_id 和排序参数,返回所有带该标签的文件。trash_counts 为当前文件夹和子文件夹在回收站中的直接子项数量
 // @Tags 文件
 // @Produce json
 // @Security BearerAuth
 // @Param parent_id query int false "父文件夹ID"
+// @Param tag query string false "按标签筛选"
+// @Param page query int false "页码，默认为1" default(1)
+// @Param page_size query int false "每页数量，默认为50，最大500" default(50)
+// @Param sort_by query string false "排序字段 name/size/updated_at" default(name)
+// @Param order query string false "排序方向 asc/desc" default(asc)
+// @Param cursor query string false "游标分页,传入上一页返回的 next_cursor,第一页传空字符串。只支持按名称排序"
+// @Param limit query int false "游标分页每页数量,传入 cursor 或 limit 时忽略 page 和 page_size" default(50)
+// @Param status query string false "删除状态 active/trashed/all" default(active)
+// @Param min_size query int false "文件大小下限(字节,含),设置大小范围时不返回文件夹"
+// @Param max_size query int false "文件大小上限(字节,含)"
+// @Param mime_prefix query string false "内容类型前缀,如 image/"
+// @Param modified_after query string false "只返回在该时间之后修改过的文件,RFC3339 格式"
 // @Success 200 {object} xerr.Response "文件列表"
 // @Failure 400 {object} xerr.Response "参数错误"
 // @Router /api/v1/files/ [get]
@@ -91,17 +124,288 @@ func (h *FileHandler) ListUserFiles(c *gin.Context) {
 		parentFolderID = &parsedID
 	}
 
-	files, err := h.fileService.GetFilesByUserID(currentUserID, parentFolderID)
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	if err != nil || pageSize < 1 || pageSize > 500 {
+		pageSize = 50
+	}
+
+	if tag := c.Query("tag"); tag != "" {
+		h.listFilesByTag(c, currentUserID, tag, page, pageSize)
+		return
+	}
+
+	opts, ok := parseFileListOptions(c, page, pageSize)
+	if !ok {
+		return
+	}
+
+	files, total, nextCursor, err := h.fileService.GetFilesByUserID(c.Request.Context(), currentUserID, parentFolderID, opts)
 	if err != nil {
+		if errors.Is(err, xerr.ErrInvalidParams) {
+			response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "sort_by must be one of name/size/updated_at, order must be asc/desc, status must be active/trashed/all and min_size must not exceed max_size, cursor pagination only supports sort_by=name")
+			return
+		}
 		if errors.Is(err, xerr.ErrDirectoryNotFound) {
-			response.Error(c, http.StatusBadRequest, xerr.DirectoryNotFoundCode, err.Error())
+			response.ErrorCode(c, http.StatusBadRequest, xerr.DirectoryNotFoundCode)
+			return
+		}
+		if handleFileError(c, err) {
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to list files")
+		return
+	}
+
+	// 文件夹的递归大小由统计表提供,获取失败时不影响列表本身
+	folderStats, err := h.statsService.GetFolderStats(c.Request.Context(), files)
+	if err != nil {
+		logger.Error("ListUserFiles: Failed to get folder stats", zap.Uint64("userID", currentUserID), zap.Error(err))
+	}
+
+	// 回收站中的子项数量同样只用于展示
+	trashCounts, err := h.fileService.CountTrashedChildren(c.Request.Context(), currentUserID, parentFolderID, files)
+	if err != nil {
+		logger.Error("ListUserFiles: Failed to count trashed children", zap.Uint64("userID", currentUserID), zap.Error(err))
+	}
+
+	result := gin.H{
+		"files":        files,
+		"total":        total,
+		"folder_stats": folderStats,
+		"trash_counts": trashCounts,
+	}
+	if opts.Cursor != nil {
+		result["next_cursor"] = nextCursor
+	}
+	response.Success(c, http.StatusOK, "Files listed successfully", result)
+}
+
+// parseFileListOptions 解析文件列表的排序、分页和过滤参数,参数无效时写入错误响应并返回 false
+func parseFileListOptions(c *gin.Context, page, pageSize int) (models.FileListOptions, bool) {
+	opts := models.FileListOptions{
+		Page:     page,
+		PageSize: pageSize,
+		SortBy:   c.DefaultQuery("sort_by", models.SortByName),
+		Order:    c.DefaultQuery("order", models.OrderAsc),
+	}
+	filter, ok := parseFileFilter(c)
+	if !ok {
+		return opts, false
+	}
+	opts.Filter = filter
+
+	// 传入 cursor 或 limit 时按游标分页,非常大的文件夹翻到后面的页也不需要跳过前面的行
+	cursorStr, hasCursor := c.GetQuery("cursor")
+	limitStr, hasLimit := c.GetQuery("limit")
+	if hasCursor || hasLimit {
+		cursor, err := models.DecodeListCursor(cursorStr)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid cursor")
+			return opts, false
+		}
+		opts.Cursor = cursor
+		opts.PageSize = parseListLimit(limitStr)
+	}
+	return opts, true
+}
+
+// parseFileFilter 解析文件列表和搜索共用的过滤参数,status 的取值由服务层校验,
+// 数值或时间格式无效时写入错误响应并返回 false
+func parseFileFilter(c *gin.Context) (models.FileFilter, bool) {
+	filter := models.FileFilter{
+		Status:     c.Query("status"),
+		MimePrefix: c.Query("mime_prefix"),
+	}
+	for _, param := range []struct {
+		name   string
+		target **uint64
+	}{
+		{"min_size", &filter.MinSize},
+		{"max_size", &filter.MaxSize},
+	} {
+		raw := c.Query(param.name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid "+param.name)
+			return filter, false
+		}
+		*param.target = &value
+	}
+	if raw := c.Query("modified_after"); raw != "" {
+		modifiedAfter, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "modified_after must be an RFC3339 timestamp")
+			return filter, false
+		}
+		filter.ModifiedAfter = &modifiedAfter
+	}
+	return filter, true
+}
+
+// parseListLimit 解析游标分页的每页数量,无效时使用默认值 50,最大 500
+func parseListLimit(s string) int {
+	limit, err := strconv.Atoi(s)
+	if err != nil || limit < 1 || limit > 500 {
+		return 50
+	}
+	return limit
+}
+
+// listFilesByTag 跨文件夹列出带有指定标签的文件
+func (h *FileHandler) listFilesByTag(c *gin.Context, userID uint64, tag string, page, pageSize int) {
+	files, total, err := h.tagService.ListFilesByTag(c.Request.Context(), userID, tag, page, pageSize)
+	if err != nil {
+		if errors.Is(err, xerr.ErrTagInvalid) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.TagInvalidCode)
+			return
+		}
+		if handleFileError(c, err) {
 			return
 		}
 		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to list files")
 		return
 	}
 
-	response.Success(c, http.StatusOK, "Files listed successfully", files)
+	response.Success(c, http.StatusOK, "Files listed successfully", gin.H{
+		"files": files,
+		"total": total,
+	})
+}
+
+// @Summary 获取文件统计
+// @Description 获取文件夹递归包含的文件数、文件夹数和总字节数，文件返回自身大小
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Success 200 {object} xerr.Response "统计信息"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 403 {object} xerr.Response "无权限"
+// @Failure 404 {object} xerr.Response "文件不存在"
+// @Router /api/v1/files/{file_id}/stats [get]
+func (h *FileHandler) GetFileStats(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	fileID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+
+	stats, err := h.statsService.GetStats(c.Request.Context(), currentUserID, fileID)
+	if err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrFileNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+		case errors.Is(err, xerr.ErrPermissionDenied):
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		case handleFileError(c, err):
+		default:
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to get file stats")
+		}
+		return
+	}
+	response.Success(c, http.StatusOK, "File stats retrieved successfully", stats)
+}
+
+// @Summary 获取回收站统计
+// @Description 获取当前用户回收站中的条目数和占用字节数
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} xerr.Response "统计信息"
+// @Router /api/v1/files/recyclebin/stats [get]
+func (h *FileHandler) GetRecycleBinStats(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.statsService.GetTrashStats(c.Request.Context(), currentUserID)
+	if err != nil {
+		if handleFileError(c, err) {
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to get recycle bin stats")
+		return
+	}
+	response.Success(c, http.StatusOK, "Recycle bin stats retrieved successfully", stats)
+}
+
+// @Summary 获取存储用量明细
+// @Description 返回当前用户按文件类型(图片/视频/文档/其他)、根目录下最大的 10 个文件夹和回收站的用量,
+// @Description 由文件变更后的统计刷新事件异步更新
+// @Tags 用户
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} xerr.Response{data=models.StorageUsage} "用量明细"
+// @Failure 404 {object} xerr.Response "用户未找到"
+// @Router /api/v1/users/me/usage [get]
+func (h *FileHandler) GetStorageUsage(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	usage, err := h.statsService.GetUsage(c.Request.Context(), currentUserID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrUserNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.UserNotFoundCode)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to get storage usage")
+		return
+	}
+	response.Success(c, http.StatusOK, "Storage usage retrieved successfully", usage)
+}
+
+// @Summary 通过路径获取文件
+// @Description 将逻辑路径解析为文件或文件夹记录，如 /Docs/Report.pdf
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param path query string true "文件的完整逻辑路径"
+// @Success 200 {object} xerr.Response "文件信息"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 404 {object} xerr.Response "文件未找到"
+// @Router /api/v1/files/by-path [get]
+func (h *FileHandler) GetFileByPath(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	filePath := c.Query("path")
+	if filePath == "" {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Query parameter 'path' is required")
+		return
+	}
+
+	file, err := h.fileService.GetFileByPath(c.Request.Context(), currentUserID, filePath)
+	if err != nil {
+		if errors.Is(err, xerr.ErrInvalidParams) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+		} else if errors.Is(err, xerr.ErrFileNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+		} else if errors.Is(err, xerr.ErrPermissionDenied) {
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		} else if !handleFileError(c, err) {
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to get file by path")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "File info retrieved successfully", file)
 }
 
 type CreateFolderRequest struct {
@@ -131,14 +435,20 @@ func (h *FileHandler) CreateFolder(c *gin.Context) {
 		return
 	}
 
-	folder, err := h.fileService.CreateFolder(currentUserID, req.FolderName, req.ParentFolderID)
+	folder, err := h.fileService.CreateFolder(c.Request.Context(), currentUserID, req.FolderName, req.ParentFolderID)
 	if err != nil {
+		if handleFileNameError(c, err) {
+			return
+		}
 		if errors.Is(err, xerr.ErrDirectoryNotFound) {
-			response.Error(c, http.StatusBadRequest, xerr.DirectoryNotFoundCode, err.Error())
+			response.ErrorCode(c, http.StatusBadRequest, xerr.DirectoryNotFoundCode)
 			return
 		}
 		if errors.Is(err, xerr.ErrFileAlreadyExists) {
-			response.Error(c, http.StatusConflict, xerr.FileAlreadyExistsCode, err.Error())
+			response.ErrorCode(c, http.StatusConflict, xerr.FileAlreadyExistsCode)
+			return
+		}
+		if handleFileError(c, err) {
 			return
 		}
 		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to create folder")
@@ -156,14 +466,78 @@ func (h *FileHandler) CreateFolder(c *gin.Context) {
 	})
 }
 
+type CreateLinkRequest struct {
+	TargetID       uint64  `json:"target_id" binding:"required"`
+	ParentFolderID *uint64 `json:"parent_folder_id"` // 可选，根目录为 null
+	FileName       string  `json:"file_name"`        // 可选，默认使用目标文件名
+}
+
+// @Summary 创建快捷方式
+// @Description 在文件夹中创建指向其他文件的快捷方式,目标可以位于共享给自己的文件夹中。快捷方式在列表中 is_link 为 true,下载时返回目标文件的内容,目标被删除后 link_broken 为 true
+// @Tags 文件
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateLinkRequest true "目标文件和所在文件夹"
+// @Success 201 {object} xerr.Response "创建的快捷方式"
+// @Failure 400 {object} xerr.Response "目标不是文件"
+// @Failure 403 {object} xerr.Response "无权访问目标文件或文件夹"
+// @Failure 404 {object} xerr.Response "目标文件不存在"
+// @Router /api/v1/files/link [post]
+func (h *FileHandler) CreateLink(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req CreateLinkRequest
+	if err := c.ShouldBindBodyWithJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request payload")
+		return
+	}
+
+	link, err := h.fileService.CreateLink(c.Request.Context(), currentUserID, req.TargetID, req.ParentFolderID, req.FileName)
+	if err != nil {
+		if handleFileNameError(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, xerr.ErrFileNotFound), errors.Is(err, xerr.ErrFileStatusInvalid):
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+		case errors.Is(err, xerr.ErrLinkTargetMissing):
+			response.ErrorCode(c, http.StatusNotFound, xerr.LinkTargetMissingCode)
+		case errors.Is(err, xerr.ErrDirectoryNotFound):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.DirectoryNotFoundCode)
+		case errors.Is(err, xerr.ErrPermissionDenied):
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		case errors.Is(err, xerr.ErrInvalidParams):
+			response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Shortcuts can only point to files")
+		case errors.Is(err, xerr.ErrFileAlreadyExists):
+			response.ErrorCode(c, http.StatusConflict, xerr.FileAlreadyExistsCode)
+		case handleFileError(c, err):
+		default:
+			logger.Error("CreateLink: Failed to create shortcut", zap.Uint64("targetID", req.TargetID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to create shortcut")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusCreated, "Shortcut created successfully", link)
+}
+
 // @Summary 下载文件
-// @Description 下载指定ID的文件
+// @Description 下载指定ID的文件,返回预签名下载链接。用户设置了下载限速时由服务端限速输出文件内容
 // @Tags 文件
 // @Produce application/octet-stream
 // @Security BearerAuth
 // @Param file_id path int true "文件ID"
+// @Param inline query bool false "在浏览器中打开,只对 PDF、图片、音频、视频和纯文本生效,其他类型仍作为附件下载" default(false)
+// @Param If-None-Match header string false "上次下载返回的 ETag"
+// @Param If-Modified-Since header string false "上次下载返回的 Last-Modified"
 // @Success 200 {file} file "文件内容"
+// @Success 304 "文件未修改"
 // @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 403 {object} xerr.Response "文件已被隔离"
 // @Router /api/v1/files/download/{file_id} [get]
 func (h *FileHandler) DownloadFile(c *gin.Context) {
 	fileIDStr := c.Param("file_id")
@@ -178,20 +552,36 @@ func (h *FileHandler) DownloadFile(c *gin.Context) {
 		return
 	}
 
+	// 客户端已缓存的文件未变化时直接返回 304,不再生成预签名URL
+	file, err := h.fileService.GetFileByID(c.Request.Context(), currentUserID, fileID)
+	if err == nil && file.IsFolder == 0 && !file.IsLink && checkNotModified(c, file) {
+		return
+	}
+
+	inline, _ := strconv.ParseBool(c.DefaultQuery("inline", "false"))
+
+	// 预签名链接直接访问对象存储,无法限速,设置了限速时由服务端输出文件内容
+	if err == nil && file.IsFolder == 0 && h.bandwidthService.Limited(c.Request.Context(), currentUserID, nil) {
+		file, reader, err := h.fileService.Download(c.Request.Context(), currentUserID, fileID, false)
+		if err != nil {
+			handleDownloadFileError(c, fileID, err)
+			return
+		}
+		defer reader.Close()
+
+		writer := h.bandwidthService.Throttle(c.Request.Context(), c.Writer, currentUserID, nil)
+		written, err := writeFileContent(c, file, reader, writer, inline)
+		metrics.AddTransferBytes(metrics.DirectionDownload, written)
+		if err != nil {
+			logger.Error("DownloadFile: Failed to write file content to HTTP response", zap.Uint64("fileID", fileID), zap.Error(err))
+		}
+		return
+	}
+
 	// 对于单个文件，生成预签名URL并重定向
-	presignedURL, err := h.fileService.GetPresignedURLForDownload(c.Request.Context(), currentUserID, fileID)
+	presignedURL, err := h.fileService.GetPresignedURLForDownload(c.Request.Context(), currentUserID, fileID, inline)
 	if err != nil {
-		if errors.Is(err, xerr.ErrFileNotFound) {
-			response.Error(c, http.StatusNotFound, xerr.FileNotFoundCode, err.Error())
-		} else if errors.Is(err, xerr.ErrPermissionDenied) {
-			response.Error(c, http.StatusForbidden, xerr.PermissionDeniedCode, err.Error())
-		} else if errors.Is(err, xerr.ErrTargetNotFolder) {
-			// 如果用户尝试用文件下载接口下载文件夹，这里会报错
-			response.Error(c, http.StatusBadRequest, xerr.TargetNotFolderCode, "Folders cannot be downloaded via this endpoint, please use the folder download endpoint.")
-		} else {
-			logger.Error("DownloadFile: Failed to generate presigned URL", zap.Uint64("fileID", fileID), zap.Error(err))
-			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to get download link")
-		}
+		handleDownloadFileError(c, fileID, err)
 		return
 	}
 
@@ -201,12 +591,169 @@ func (h *FileHandler) DownloadFile(c *gin.Context) {
 	})
 }
 
+// handleDownloadFileError 处理下载单个文件时的错误
+func handleDownloadFileError(c *gin.Context, fileID uint64, err error) {
+	if errors.Is(err, xerr.ErrFileNotFound) {
+		response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+	} else if errors.Is(err, xerr.ErrPermissionDenied) {
+		response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+	} else if errors.Is(err, xerr.ErrFileQuarantined) {
+		response.ErrorCode(c, http.StatusForbidden, xerr.FileQuarantinedCode)
+	} else if errors.Is(err, xerr.ErrLinkTargetMissing) {
+		response.ErrorCode(c, http.StatusNotFound, xerr.LinkTargetMissingCode)
+	} else if errors.Is(err, xerr.ErrTargetNotFolder) {
+		// 如果用户尝试用文件下载接口下载文件夹，这里会报错
+		response.Error(c, http.StatusBadRequest, xerr.TargetNotFolderCode, "Folders cannot be downloaded via this endpoint, please use the folder download endpoint.")
+	} else if !handleFileError(c, err) {
+		logger.Error("DownloadFile: Failed to generate presigned URL", zap.Uint64("fileID", fileID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to get download link")
+	}
+}
+
+// writeFileContent 设置下载响应头并把文件内容写入 w,inline 为 true 且类型安全时在浏览器中直接打开
+func writeFileContent(c *gin.Context, file *models.File, reader io.Reader, w io.Writer, inline bool) (int64, error) {
+	contentType := "application/octet-stream"
+	if file.MimeType != nil && *file.MimeType != "" {
+		contentType = *file.MimeType
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Length", strconv.FormatUint(file.Size, 10))
+	c.Header("Content-Disposition", utils.ContentDisposition(file.FileName, contentType, inline))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+	return io.Copy(w, reader)
+}
+
+// @Summary 获取下载文件的元数据
+// @Description 只返回下载文件的响应头,不生成下载链接也不访问对象存储,供同步客户端检查文件是否有变化。快捷方式返回目标文件的信息
+// @Tags 文件
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Param If-None-Match header string false "上次下载返回的 ETag"
+// @Param If-Modified-Since header string false "上次下载返回的 Last-Modified"
+// @Success 200 "Content-Length、Content-Type、ETag、Last-Modified、X-Version-Id 和 Accept-Ranges 响应头"
+// @Success 304 "文件未修改"
+// @Failure 400 "文件夹不能通过该接口下载"
+// @Failure 403 "权限不足或文件已被隔离"
+// @Failure 404 "文件未找到"
+// @Router /api/v1/files/download/{file_id} [head]
+func (h *FileHandler) HeadDownloadFile(c *gin.Context) {
+	fileID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	file, err := h.fileService.StatFile(c.Request.Context(), currentUserID, fileID)
+	if err != nil {
+		if !handleStatError(c, err) {
+			logger.Error("HeadDownloadFile: Failed to get file", zap.Uint64("fileID", fileID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to get file info")
+		}
+		return
+	}
+	if file.IsFolder == 1 {
+		response.ErrorCode(c, http.StatusBadRequest, xerr.CannotDownloadFolderCode)
+		return
+	}
+	if file.ScanStatus == models.ScanStatusInfected {
+		response.ErrorCode(c, http.StatusForbidden, xerr.FileQuarantinedCode)
+		return
+	}
+
+	if checkNotModified(c, file) {
+		return
+	}
+	mimeType := "application/octet-stream"
+	if file.MimeType != nil && *file.MimeType != "" {
+		mimeType = *file.MimeType
+	}
+	c.Header("Content-Type", mimeType)
+	c.Header("Content-Length", strconv.FormatUint(file.Size, 10))
+	c.Header("Accept-Ranges", "bytes")
+	if file.VersionID != nil && *file.VersionID != "" {
+		c.Header("X-Version-Id", *file.VersionID)
+	}
+	c.Status(http.StatusOK)
+}
+
+// @Summary 获取文件元数据
+// @Description 返回文件的大小、类型、ETag、版本和内容哈希,只查询数据库,不访问对象存储。快捷方式返回目标文件的信息
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Success 200 {object} xerr.Response "文件元数据"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 403 {object} xerr.Response "权限不足"
+// @Failure 404 {object} xerr.Response "文件未找到"
+// @Router /api/v1/files/{file_id}/stat [get]
+func (h *FileHandler) StatFile(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	fileID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+
+	file, err := h.fileService.StatFile(c.Request.Context(), currentUserID, fileID)
+	if err != nil {
+		if !handleStatError(c, err) {
+			logger.Error("StatFile: Failed to get file", zap.Uint64("fileID", fileID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to get file info")
+		}
+		return
+	}
+
+	writeValidators(c, file)
+	response.Success(c, http.StatusOK, "File stat retrieved successfully", models.FileStat{
+		ID:         file.ID,
+		FileName:   file.FileName,
+		Path:       file.Path,
+		IsFolder:   file.IsFolder,
+		Size:       file.Size,
+		MimeType:   file.MimeType,
+		ETag:       fileETag(file),
+		VersionID:  file.VersionID,
+		Version:    file.Version,
+		SHA256Hash: file.SHA256Hash,
+		MD5Hash:    file.MD5Hash,
+		Downloads:  file.Downloads,
+		UpdatedAt:  file.UpdatedAt,
+	})
+}
+
+// handleStatError 处理查询文件元数据时的常见错误,已写入响应时返回 true
+func handleStatError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, xerr.ErrFileNotFound):
+		response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+	case errors.Is(err, xerr.ErrPermissionDenied):
+		response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+	case errors.Is(err, xerr.ErrLinkTargetMissing):
+		response.ErrorCode(c, http.StatusNotFound, xerr.LinkTargetMissingCode)
+	default:
+		return handleFileError(c, err)
+	}
+	return true
+}
+
 // @Summary 下载文件夹
-// @Description 下载指定ID的文件夹，打包为ZIP格式
+// @Description 下载指定ID的文件夹，打包为ZIP格式。checksums=true 时在 ZIP 根目录附加 SHA256SUMS.txt,按记录的哈希列出每个文件的 SHA-256 和大小
 // @Tags 文件
 // @Produce application/zip
 // @Security BearerAuth
 // @Param id path int true "文件夹ID"
+// @Param checksums query bool false "是否附加 SHA256SUMS.txt 校验清单"
 // @Success 200 {file} file "文件夹ZIP包"
 // @Failure 400 {object} xerr.Response "参数错误"
 // @Failure 404 {object} xerr.Response "文件夹未找到"
@@ -224,15 +771,16 @@ func (h *FileHandler) DownloadFolder(c *gin.Context) {
 		return
 	}
 
-	folder, zipReader, err := h.fileService.Download(context.Background(), currentUserID, folderID)
+	checksums, _ := strconv.ParseBool(c.DefaultQuery("checksums", "false"))
+	folder, zipReader, err := h.fileService.Download(c.Request.Context(), currentUserID, folderID, checksums)
 	if err != nil {
 		if errors.Is(err, xerr.ErrFileNotFound) {
-			response.Error(c, http.StatusNotFound, xerr.FileNotFoundCode, err.Error())
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
 		} else if errors.Is(err, xerr.ErrPermissionDenied) {
-			response.Error(c, http.StatusForbidden, xerr.PermissionDeniedCode, err.Error())
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
 		} else if errors.Is(err, xerr.ErrTargetNotFolder) {
 			response.Error(c, http.StatusBadRequest, xerr.TargetNotFolderCode, "Cannot download a file using folder download endpoint")
-		} else {
+		} else if !handleFileError(c, err) {
 			logger.Error("DownloadFolder: Failed to get folder for download", zap.Uint64("folderID", folderID), zap.Uint64("userID", currentUserID), zap.Error(err))
 			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "failed to prepare folder for download")
 		}
@@ -244,272 +792,969 @@ func (h *FileHandler) DownloadFolder(c *gin.Context) {
 	c.Header("Content-Type", "application/zip")
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", downloadFileName))
 	c.Header("Content-Transfer-Encoding", "binary")
+	// ZIP 为流式生成,无法给出 Content-Length,这里提供未压缩总大小供客户端估算进度
+	c.Header("X-Estimated-Size", strconv.FormatUint(folder.Size, 10))
 
-	_, err = io.Copy(c.Writer, zipReader)
+	writer := h.bandwidthService.Throttle(c.Request.Context(), c.Writer, currentUserID, nil)
+	written, err := io.Copy(writer, zipReader)
+	metrics.AddTransferBytes(metrics.DirectionDownload, written)
 	if err != nil {
 		logger.Error("DownloadFolder: Failed to write ZIP stream to HTTP response", zap.Uint64("folderID", folderID), zap.Uint64("userID", currentUserID), zap.Error(err))
 	}
 }
 
-// @Summary 删除文件或文件夹（软删除）
-// @Description 将文件或文件夹移动到回收站
+// BatchDownloadRequest 打包下载多个文件的请求体
+type BatchDownloadRequest struct {
+	FileIDs []uint64 `json:"file_ids" binding:"required,min=1"`
+}
+
+// @Summary 打包下载选中的文件
+// @Description 把选中的多个文件或文件夹流式打包成一个 ZIP 下载,条目可以来自不同目录。ZIP 顶层重名时自动添加 " (1)" 等后缀,已隔离的文件会被跳过。checksums=true 时附加 SHA256SUMS.txt 校验清单
 // @Tags 文件
+// @Accept json
+// @Produce application/zip
 // @Security BearerAuth
-// @Param file_id path int true "文件ID"
-// @Success 200 {object} xerr.Response "删除成功"
+// @Param request body BatchDownloadRequest true "要下载的文件ID列表"
+// @Param checksums query bool false "是否附加 SHA256SUMS.txt 校验清单"
+// @Success 200 {file} file "ZIP 文件"
 // @Failure 400 {object} xerr.Response "参数错误"
-// @Router /api/v1/files/softdelete/{file_id} [delete]
-func (h *FileHandler) SoftDeleteFile(c *gin.Context) {
-	fileIDStr := c.Param("file_id")
-	fileID, err := strconv.ParseUint(fileIDStr, 10, 64)
-	if err != nil {
-		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+// @Failure 403 {object} xerr.Response "无权限"
+// @Failure 404 {object} xerr.Response "文件未找到"
+// @Router /api/v1/files/download/batch [post]
+func (h *FileHandler) DownloadBatch(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
 		return
 	}
 
+	var req BatchDownloadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+
+	checksums, _ := strconv.ParseBool(c.DefaultQuery("checksums", "false"))
+	totalSize, zipReader, err := h.fileService.DownloadBatch(c.Request.Context(), currentUserID, req.FileIDs, checksums)
+	if err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrInvalidParams):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+		case errors.Is(err, xerr.ErrFileNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+		case errors.Is(err, xerr.ErrPermissionDenied):
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		case errors.Is(err, xerr.ErrFileStatusInvalid):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.FileStatusInvalidCode)
+		case handleFileError(c, err):
+		default:
+			logger.Error("DownloadBatch: Failed to prepare files for download", zap.Uint64("userID", currentUserID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "failed to prepare files for download")
+		}
+		return
+	}
+	defer zipReader.Close()
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"download-%s.zip\"", time.Now().Format("20060102-150405")))
+	c.Header("Content-Transfer-Encoding", "binary")
+	c.Header("X-Estimated-Size", strconv.FormatUint(totalSize, 10))
+
+	writer := h.bandwidthService.Throttle(c.Request.Context(), c.Writer, currentUserID, nil)
+	written, err := io.Copy(writer, zipReader)
+	metrics.AddTransferBytes(metrics.DirectionDownload, written)
+	if err != nil {
+		logger.Error("DownloadBatch: Failed to write ZIP stream to HTTP response", zap.Uint64("userID", currentUserID), zap.Error(err))
+	}
+}
+
+// FileMetadataBatchRequest 批量获取文件元数据的请求体
+type FileMetadataBatchRequest struct {
+	FileIDs    []uint64 `json:"file_ids" binding:"required,min=1,max=200"`
+	Thumbnails bool     `json:"thumbnails"` // 是否附带已生成的小尺寸图片预览
+}
+
+// @Summary 批量获取文件元数据
+// @Description 一次获取最多 200 个文件的元数据,优先从缓存读取。thumbnails 为 true 时为已生成小尺寸预览的图片附带 base64 编码的缩略图。不存在或无权访问的文件ID放在 missing 中返回
+// @Tags 文件
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body FileMetadataBatchRequest true "文件ID列表"
+// @Success 200 {object} xerr.Response{data=object{files=[]models.FileMetadata,missing=[]uint64}} "文件元数据"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Router /api/v1/files/metadata/batch [post]
+func (h *FileHandler) GetFilesMetadata(c *gin.Context) {
 	currentUserID, ok := utils.GetUserIDFromContext(c)
 	if !ok {
 		return
 	}
 
-	err = h.fileService.SoftDelete(currentUserID, fileID)
+	var req FileMetadataBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+
+	files, missing, err := h.fileService.GetFilesMetadata(c.Request.Context(), currentUserID, req.FileIDs)
+	if err != nil {
+		if !handleFileError(c, err) {
+			logger.Error("GetFilesMetadata: Failed to get files", zap.Uint64("userID", currentUserID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "failed to get file metadata")
+		}
+		return
+	}
+
+	var thumbnails map[uint64][]byte
+	if req.Thumbnails {
+		thumbnails = h.previewService.GetThumbnails(c.Request.Context(), files)
+	}
+	result := make([]models.FileMetadata, len(files))
+	for i := range files {
+		result[i].File = files[i]
+		if data, ok := thumbnails[files[i].ID]; ok {
+			result[i].Thumbnail = base64.StdEncoding.EncodeToString(data)
+		}
+	}
+	if missing == nil {
+		missing = []uint64{}
+	}
+
+	response.Success(c, http.StatusOK, "File metadata retrieved successfully", gin.H{
+		"files":   result,
+		"missing": missing,
+	})
+}
+
+// @Summary 预热文件列表缓存
+// @Description 在后台把根目录和最近使用的文件夹加载到缓存,登录成功时会自动触发。同一用户在配置的间隔内只预热一次,started 为 false 表示本次未触发
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} xerr.Response "是否开始预热"
+// @Router /api/v1/files/warm [post]
+func (h *FileHandler) WarmCache(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	started := h.cacheWarmService.Warm(c.Request.Context(), currentUserID)
+	response.Success(c, http.StatusAccepted, "Cache warm-up requested", gin.H{"started": started})
+}
+
+// @Summary 获取媒体文件预览
+// @Description 返回图片或视频的缩放预览,首次请求时按需转码,之后直接读取缓存的预览
+// @Tags 文件
+// @Produce image/jpeg,video/mp4
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Param size query string false "预览尺寸: small, medium, large" default(medium)
+// @Success 200 {file} file "预览内容"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 404 {object} xerr.Response "文件未找到"
+// @Failure 415 {object} xerr.Response "文件类型不支持预览"
+// @Router /api/v1/files/{file_id}/preview [get]
+func (h *FileHandler) GetFilePreview(c *gin.Context) {
+	fileIDStr := c.Param("file_id")
+	fileID, err := strconv.ParseUint(fileIDStr, 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	size := c.DefaultQuery("size", models.PreviewSizeMedium)
+	info, reader, err := h.previewService.GetPreview(c.Request.Context(), currentUserID, fileID, size)
+	if err != nil {
+		if errors.Is(err, xerr.ErrInvalidParams) {
+			response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid preview size")
+		} else if errors.Is(err, xerr.ErrFileNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+		} else if errors.Is(err, xerr.ErrPermissionDenied) {
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		} else if errors.Is(err, xerr.ErrFileQuarantined) {
+			response.ErrorCode(c, http.StatusForbidden, xerr.FileQuarantinedCode)
+		} else if errors.Is(err, xerr.ErrCannotDownloadFolder) || errors.Is(err, xerr.ErrPreviewNotSupported) {
+			response.ErrorCode(c, http.StatusUnsupportedMediaType, xerr.PreviewNotSupportedCode)
+		} else if errors.Is(err, xerr.ErrFileTooLarge) {
+			response.Error(c, http.StatusRequestEntityTooLarge, xerr.FileTooLargeCode, "File is too large to preview")
+		} else if !handleFileError(c, err) {
+			logger.Error("GetFilePreview: Failed to get preview", zap.Uint64("fileID", fileID), zap.String("size", size), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to generate preview")
+		}
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.DataFromReader(http.StatusOK, info.Size, info.ContentType, reader, nil)
+}
+
+// @Summary 获取文件夹大小
+// @Description 递归统计文件夹下的文件数量和未压缩总大小
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "文件夹ID"
+// @Success 200 {object} xerr.Response "文件夹大小"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 404 {object} xerr.Response "文件夹未找到"
+// @Router /api/v1/files/folder/{id}/size [get]
+func (h *FileHandler) GetFolderSize(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	folderIDStr := c.Param("id")
+	folderID, err := strconv.ParseUint(folderIDStr, 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "invalid folder ID")
+		return
+	}
+
+	size, err := h.fileService.GetFolderSize(c.Request.Context(), currentUserID, folderID)
 	if err != nil {
 		if errors.Is(err, xerr.ErrFileNotFound) {
-			response.Error(c, http.StatusNotFound, xerr.FileNotFoundCode, err.Error())
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+		} else if errors.Is(err, xerr.ErrPermissionDenied) {
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		} else if errors.Is(err, xerr.ErrTargetNotFolder) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.TargetNotFolderCode)
+		} else if !handleFileError(c, err) {
+			logger.Error("GetFolderSize: Failed to calculate folder size", zap.Uint64("folderID", folderID), zap.Uint64("userID", currentUserID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to calculate folder size")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Folder size retrieved successfully", size)
+}
+
+// @Summary 删除文件或文件夹（软删除）
+// @Description 将文件或文件夹移动到回收站,位于设置了跳过回收站的文件夹中时改为彻底删除
+// @Tags 文件
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Success 200 {object} xerr.Response "删除成功"
+// @Success 202 {object} xerr.Response "已创建彻底删除任务"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Router /api/v1/files/softdelete/{file_id} [delete]
+func (h *FileHandler) SoftDeleteFile(c *gin.Context) {
+	fileIDStr := c.Param("file_id")
+	fileID, err := strconv.ParseUint(fileIDStr, 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.fileService.SoftDelete(c.Request.Context(), currentUserID, fileID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrFileLocked) {
+			response.ErrorCode(c, http.StatusConflict, xerr.FileLockedCode)
+			return
+		}
+		if errors.Is(err, xerr.ErrFileNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
 			return
 		}
 		if errors.Is(err, xerr.ErrPermissionDenied) {
-			response.Error(c, http.StatusForbidden, xerr.PermissionDeniedCode, err.Error())
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+			return
+		}
+		if handleFileError(c, err) {
 			return
 		}
 		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to delete file")
 		return
 	}
+	if job != nil {
+		response.Success(c, http.StatusAccepted, fmt.Sprintf("File/Folder %d scheduled for permanent deletion", fileID), job)
+		return
+	}
 	response.Success(c, http.StatusOK, fmt.Sprintf("File/Folder %d soft-deleted successfully", fileID), nil)
 }
 
-// @Summary 彻底删除文件或文件夹（永久删除）
-// @Description 将文件或文件夹彻底删除
+// @Summary 彻底删除文件或文件夹（永久删除）
+// @Description 将文件或文件夹及其所有子项标记为待删除后立即返回，由后台任务分批删除，可通过返回的任务ID查询进度
+// @Tags 文件
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Success 202 {object} xerr.Response "彻底删除任务已创建"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Router /api/v1/files/permanentdelete/{file_id} [delete]
+func (h *FileHandler) PermanentDeleteFile(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	fileIDStr := c.Param("file_id")
+	fileID, err := strconv.ParseUint(fileIDStr, 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+
+	job, err := h.purgeService.RequestPurge(c.Request.Context(), currentUserID, fileID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrFileLocked) {
+			response.ErrorCode(c, http.StatusConflict, xerr.FileLockedCode)
+			return
+		}
+		if errors.Is(err, xerr.ErrFileNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+			return
+		}
+		if errors.Is(err, xerr.ErrPermissionDenied) {
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+			return
+		}
+		if handleFileError(c, err) {
+			return
+		}
+		logger.Error("PermanentDeleteFile: Failed to request purge", zap.Uint64("fileID", fileID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to permanently delete file")
+		return
+	}
+
+	response.Success(c, http.StatusAccepted, fmt.Sprintf("File/Folder %d scheduled for permanent deletion", fileID), job)
+}
+
+// @Summary 查询彻底删除进度
+// @Description 查询彻底删除任务的状态和已删除的数量
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param job_id path int true "彻底删除任务ID"
+// @Success 200 {object} xerr.Response "任务详情"
+// @Failure 404 {object} xerr.Response "任务不存在"
+// @Router /api/v1/files/purge-jobs/{job_id} [get]
+func (h *FileHandler) GetPurgeJob(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	jobID, err := strconv.ParseUint(c.Param("job_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid job ID format")
+		return
+	}
+
+	job, err := h.purgeService.GetPurgeJob(c.Request.Context(), currentUserID, jobID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrPurgeJobNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.PurgeJobNotFoundCode)
+			return
+		}
+		if handleFileError(c, err) {
+			return
+		}
+		logger.Error("GetPurgeJob: Failed to get purge job", zap.Uint64("jobID", jobID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to get purge job")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Purge job retrieved successfully", job)
+}
+
+// @Summary 列出回收站中的文件
+// @Description 按删除时间倒序列出用户回收站中的文件。不传 cursor 和 limit 时返回全部文件的数组,否则返回 {files, next_cursor}。
+// @Description 每个文件的 purge_at 为按用户回收站保留期将被彻底删除的时间
+// @Tags 文件
+// @Security BearerAuth
+// @Param parent_id query int false "只列出删除前位于该文件夹中的文件,0 表示根目录"
+// @Param cursor query string false "传入上一页返回的 next_cursor,第一页传空字符串"
+// @Param limit query int false "每页数量,最大500" default(50)
+// @Success 200 {object} xerr.Response "获取成功"
+// @Failure 400 {object} xerr.Response "游标或父文件夹ID无效"
+// @Failure 500 {object} xerr.Response "内部错误"
+// @Router /api/v1/files/recyclebin [get]
+func (h *FileHandler) ListRecycleBinFiles(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var opts models.TrashListOptions
+	if parentIDStr := c.Query("parent_id"); parentIDStr != "" {
+		parentID, err := strconv.ParseUint(parentIDStr, 10, 64)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid parent_id")
+			return
+		}
+		opts.ParentFolderID = &parentID
+	}
+	cursorStr, hasCursor := c.GetQuery("cursor")
+	limitStr, hasLimit := c.GetQuery("limit")
+	paged := hasCursor || hasLimit
+	if paged {
+		cursor, err := models.DecodeListCursor(cursorStr)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid cursor")
+			return
+		}
+		opts.Cursor = cursor
+		opts.Limit = parseListLimit(limitStr)
+	}
+
+	files, nextCursor, err := h.fileService.ListRecycleBinFiles(c.Request.Context(), currentUserID, opts)
+	if err != nil {
+		if errors.Is(err, xerr.ErrInvalidParams) {
+			response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid cursor")
+			return
+		}
+		if handleFileError(c, err) {
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to list recycle bin files")
+		return
+	}
+
+	if !paged {
+		response.Success(c, http.StatusOK, "Recycle bin files listed successfully", files)
+		return
+	}
+	response.Success(c, http.StatusOK, "Recycle bin files listed successfully", gin.H{
+		"files":       files,
+		"next_cursor": nextCursor,
+	})
+}
+
+// @Summary 恢复文件/文件夹
+// @Description 从回收站恢复文件或文件夹。默认恢复到原位置,原父文件夹已被删除时恢复到根目录;指定 target_parent_id 时恢复到该文件夹。同名时自动改名
+// @Tags 文件
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Param target_parent_id query int false "恢复到的文件夹ID,必须是自己的正常文件夹"
+// @Success 200 {object} xerr.Response "恢复后的文件"
+// @Failure 400 {object} xerr.Response "参数错误或目标不是可用的文件夹"
+// @Failure 403 {object} xerr.Response "权限不足"
+// @Router /api/v1/files/restore/{file_id} [post]
+func (h *FileHandler) RestoreFile(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	fileIDStr := c.Param("file_id")
+	fileID, err := strconv.ParseUint(fileIDStr, 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+
+	var targetParentID *uint64
+	if raw := c.Query("target_parent_id"); raw != "" {
+		parsed, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid target_parent_id")
+			return
+		}
+		targetParentID = &parsed
+	}
+
+	restored, err := h.fileService.RestoreFile(c.Request.Context(), currentUserID, fileID, targetParentID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrFileNotInRecycleBin) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.FileNotInRecycleBinCode)
+			return
+		}
+		if errors.Is(err, xerr.ErrPermissionDenied) {
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+			return
+		}
+		if errors.Is(err, xerr.ErrDirectoryNotFound) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.DirectoryNotFoundCode)
+			return
+		}
+		if errors.Is(err, xerr.ErrTargetNotFolder) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.TargetNotFolderCode)
+			return
+		}
+		if errors.Is(err, xerr.ErrFileStatusInvalid) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.FileStatusInvalidCode)
+			return
+		}
+		if errors.Is(err, xerr.ErrFileAlreadyExists) {
+			response.ErrorCode(c, http.StatusConflict, xerr.FileAlreadyExistsCode)
+			return
+		}
+		if handleFileError(c, err) {
+			return
+		}
+		logger.Error("RestoreFile: Failed to restore file", zap.Uint64("fileID", fileID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to restore file")
+		return
+	}
+
+	response.Success(c, http.StatusOK, fmt.Sprintf("File/Folder %d restored successfully", fileID), restored)
+}
+
+// 定义 RenameFileRequest 结构体
+type RenameFileRequest struct {
+	NewFileName     string  `json:"new_file_name" binding:"required"`
+	ExpectedVersion *uint64 `json:"expected_version"` // 可选,也可以通过 If-Match 请求头传递
+}
+
+// @Summary 重命名文件/文件夹
+// @Description 重命名指定的文件或文件夹
+// @Tags 文件
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "文件ID"
+// @Param If-Match header string false "期望的文件版本号"
+// @Param data body RenameFileRequest true "重命名信息"
+// @Success 200 {object} xerr.Response "重命名成功"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 403 {object} xerr.Response "权限不足"
+// @Failure 404 {object} xerr.Response "文件未找到"
+// @Failure 409 {object} xerr.Response "文件已被其他请求修改,返回当前状态"
+// @Router /api/v1/files/rename/{id} [put]
+func (h *FileHandler) RenameFile(c *gin.Context) {
+	fileIDStr := c.Param("id")
+	fileID, err := strconv.ParseUint(fileIDStr, 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID")
+		return
+	}
+
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req RenameFileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+
+	expectedVersion, ok := parseExpectedVersion(c, req.ExpectedVersion)
+	if !ok {
+		return
+	}
+
+	renamedFile, err := h.fileService.RenameFile(c.Request.Context(), currentUserID, fileID, req.NewFileName, expectedVersion)
+	if err != nil {
+		if handleFileNameError(c, err) {
+			return
+		}
+		if errors.Is(err, xerr.ErrVersionConflict) {
+			h.respondVersionConflict(c, currentUserID, fileID)
+		} else if errors.Is(err, xerr.ErrFileLocked) {
+			response.ErrorCode(c, http.StatusConflict, xerr.FileLockedCode)
+		} else if errors.Is(err, xerr.ErrFileNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+		} else if errors.Is(err, xerr.ErrPermissionDenied) {
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		} else if errors.Is(err, xerr.ErrFileStatusInvalid) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.FileStatusInvalidCode)
+		} else if errors.Is(err, xerr.ErrFileAlreadyExists) {
+			response.ErrorCode(c, http.StatusConflict, xerr.FileAlreadyExistsCode)
+		} else if !handleFileError(c, err) {
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to rename file")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "File/folder renamed successfully", gin.H{
+		"file_info": renamedFile,
+	})
+}
+
+// MoveFileRequest 移动文件的请求体
+type MoveFileRequest struct {
+	FileID               uint64  `json:"file_id" binding:"required"`
+	TargetParentFolderID *uint64 `json:"target_parent_folder_id"`
+	TagPropagation       string  `json:"tag_propagation" binding:"omitempty,oneof=keep inherit replace"` // 标签处理方式,默认 keep
+	ExpectedVersion      *uint64 `json:"expected_version"`                                               // 可选,也可以通过 If-Match 请求头传递
+}
+
+// @Summary 移动文件/文件夹
+// @Description 移动指定文件或文件夹到新的父文件夹下
+// @Tags 文件
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param If-Match header string false "期望的文件版本号"
+// @Param request body MoveFileRequest true "移动文件请求体"
+// @Success 200 {object} xerr.Response "成功移动后的文件/文件夹信息"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 403 {object} xerr.Response "权限不足"
+// @Failure 404 {object} xerr.Response "文件或目标文件夹未找到"
+// @Failure 409 {object} xerr.Response "目标位置已存在同名文件/文件夹,或文件已被其他请求修改"
+// @Router /api/v1/files/move [post]
+func (h *FileHandler) MoveFile(c *gin.Context) {
+	var req MoveFileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	expectedVersion, ok := parseExpectedVersion(c, req.ExpectedVersion)
+	if !ok {
+		return
+	}
+
+	movedFile, err := h.fileService.MoveFile(c.Request.Context(), currentUserID, req.FileID, req.TargetParentFolderID, expectedVersion, req.TagPropagation)
+	if err != nil {
+		if errors.Is(err, xerr.ErrVersionConflict) {
+			h.respondVersionConflict(c, currentUserID, req.FileID)
+		} else if errors.Is(err, xerr.ErrFileLocked) {
+			response.ErrorCode(c, http.StatusConflict, xerr.FileLockedCode)
+		} else if errors.Is(err, xerr.ErrFileNotFound) {
+			response.Error(c, http.StatusNotFound, xerr.FileNotFoundCode, "File or folder to move not found")
+		} else if errors.Is(err, xerr.ErrDirectoryNotFound) {
+			response.Error(c, http.StatusNotFound, xerr.DirectoryNotFoundCode, "Target parent folder not found")
+		} else if errors.Is(err, xerr.ErrPermissionDenied) {
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		} else if errors.Is(err, xerr.ErrCannotMoveRoot) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.CannotMoveRootCode)
+		} else if errors.Is(err, xerr.ErrCannotMoveIntoSubtree) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.CannotMoveIntoSubtreeCode)
+		} else if errors.Is(err, xerr.ErrTargetNotFolder) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.TargetNotFolderCode)
+		} else if errors.Is(err, xerr.ErrFileAlreadyExists) {
+			response.Error(c, http.StatusConflict, xerr.FileAlreadyExistsCode, "Name conflict in target location")
+		} else if !handleFileError(c, err) {
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to move file/folder")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "File/folder moved successfully", gin.H{
+		"file_info": movedFile,
+	})
+}
+
+// BatchMoveRequest 批量移动文件的请求体
+type BatchMoveRequest struct {
+	FileIDs              []uint64 `json:"file_ids" binding:"required,min=1"`
+	TargetParentFolderID *uint64  `json:"target_parent_folder_id"`
+	TagPropagation       string   `json:"tag_propagation" binding:"omitempty,oneof=keep inherit replace"` // 标签处理方式,默认 keep
+}
+
+// @Summary 批量移动文件/文件夹
+// @Description 将多个文件或文件夹移动到同一目标文件夹,任意一项校验失败则整体不执行
 // @Tags 文件
+// @Accept json
+// @Produce json
 // @Security BearerAuth
-// @Param file_id path int true "文件ID"
-// @Success 200 {object} xerr.Response "删除成功"
+// @Param request body BatchMoveRequest true "批量移动请求体"
+// @Success 200 {object} xerr.Response "移动后的文件/文件夹列表"
 // @Failure 400 {object} xerr.Response "参数错误"
-// @Router /api/v1/files/permanentdelete/{file_id} [delete]
-func (h *FileHandler) PermanentDeleteFile(c *gin.Context) {
-	currentUserID, ok := utils.GetUserIDFromContext(c)
-	if !ok {
+// @Failure 403 {object} xerr.Response "权限不足"
+// @Failure 404 {object} xerr.Response "文件或目标文件夹未找到"
+// @Failure 409 {object} xerr.Response "目标位置已存在同名文件/文件夹或文件被锁定"
+// @Router /api/v1/files/batch/move [post]
+func (h *FileHandler) BatchMoveFiles(c *gin.Context) {
+	var req BatchMoveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
 		return
 	}
 
-	fileIDStr := c.Param("file_id")
-	fileID, err := strconv.ParseUint(fileIDStr, 10, 64)
-	if err != nil {
-		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
 		return
 	}
 
-	err = h.fileService.PermanentDelete(currentUserID, fileID)
+	movedFiles, err := h.fileService.BatchMove(c.Request.Context(), currentUserID, req.FileIDs, req.TargetParentFolderID, req.TagPropagation)
 	if err != nil {
-		if errors.Is(err, xerr.ErrFileNotFound) {
-			response.Error(c, http.StatusNotFound, xerr.FileNotFoundCode, err.Error())
-			return
-		}
-		if errors.Is(err, xerr.ErrPermissionDenied) {
-			response.Error(c, http.StatusForbidden, xerr.PermissionDeniedCode, err.Error())
-			return
-		}
-		if errors.Is(err, xerr.ErrDirNotEmpty) {
-			response.Error(c, http.StatusBadRequest, xerr.DirNotEmptyCode, err.Error())
-			return
+		if errors.Is(err, xerr.ErrInvalidParams) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+		} else if errors.Is(err, xerr.ErrFileLocked) {
+			response.ErrorCode(c, http.StatusConflict, xerr.FileLockedCode)
+		} else if errors.Is(err, xerr.ErrFileNotFound) {
+			response.Error(c, http.StatusNotFound, xerr.FileNotFoundCode, "File or folder to move not found")
+		} else if errors.Is(err, xerr.ErrDirectoryNotFound) {
+			response.Error(c, http.StatusNotFound, xerr.DirectoryNotFoundCode, "Target parent folder not found")
+		} else if errors.Is(err, xerr.ErrPermissionDenied) {
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		} else if errors.Is(err, xerr.ErrCannotMoveIntoSubtree) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.CannotMoveIntoSubtreeCode)
+		} else if errors.Is(err, xerr.ErrTargetNotFolder) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.TargetNotFolderCode)
+		} else if errors.Is(err, xerr.ErrFileAlreadyExists) {
+			response.Error(c, http.StatusConflict, xerr.FileAlreadyExistsCode, "Name conflict in target location")
+		} else if !handleFileError(c, err) {
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to move files")
 		}
-		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to permanently delete file")
 		return
 	}
 
-	response.Success(c, http.StatusOK, fmt.Sprintf("File/Folder %d permanently deleted successfully", fileID), nil)
+	response.Success(c, http.StatusOK, "Files moved successfully", gin.H{
+		"files": movedFiles,
+	})
+}
+
+// OrganizeFilesRequest 新建文件夹并移入文件的请求体
+type OrganizeFilesRequest struct {
+	FolderName     string   `json:"folder_name" binding:"required"`
+	ParentFolderID *uint64  `json:"parent_folder_id"` // 新文件夹的父目录,为空表示根目录
+	FileIDs        []uint64 `json:"file_ids" binding:"required,min=1"`
+	TagPropagation string   `json:"tag_propagation" binding:"omitempty,oneof=keep inherit replace"` // 标签处理方式,默认 keep
 }
 
-// @Summary 列出回收站中的文件
-// @Description 列出用户回收站中的所有文件
+// @Summary 移动到新文件夹
+// @Description 在指定目录下新建文件夹(同名时自动重命名),并把选中的文件或文件夹移动进去。新建和移动在同一事务中完成,任意一项校验失败则整体不执行
 // @Tags 文件
+// @Accept json
+// @Produce json
 // @Security BearerAuth
-// @Success 200 {object} xerr.Response "获取成功"
-// @Failure 500 {object} xerr.Response "内部错误"
-// @Router /api/v1/files/recyclebin [get]
-func (h *FileHandler) ListRecycleBinFiles(c *gin.Context) {
+// @Param request body OrganizeFilesRequest true "新文件夹名称和要移动的条目"
+// @Success 200 {object} xerr.Response "新文件夹和移动后的文件/文件夹列表"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 403 {object} xerr.Response "权限不足"
+// @Failure 404 {object} xerr.Response "文件或父文件夹未找到"
+// @Failure 409 {object} xerr.Response "文件被锁定"
+// @Router /api/v1/files/organize [post]
+func (h *FileHandler) OrganizeFiles(c *gin.Context) {
+	var req OrganizeFilesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+
 	currentUserID, ok := utils.GetUserIDFromContext(c)
 	if !ok {
 		return
 	}
 
-	files, err := h.fileService.ListRecycleBinFiles(currentUserID)
+	folder, movedFiles, err := h.fileService.OrganizeFiles(c.Request.Context(), currentUserID, req.FolderName, req.ParentFolderID, req.FileIDs, req.TagPropagation)
 	if err != nil {
-		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to list recycle bin files")
+		if handleFileNameError(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, xerr.ErrInvalidParams):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+		case errors.Is(err, xerr.ErrFileLocked):
+			response.ErrorCode(c, http.StatusConflict, xerr.FileLockedCode)
+		case errors.Is(err, xerr.ErrFileNotFound):
+			response.Error(c, http.StatusNotFound, xerr.FileNotFoundCode, "File or folder to move not found")
+		case errors.Is(err, xerr.ErrDirectoryNotFound):
+			response.Error(c, http.StatusNotFound, xerr.DirectoryNotFoundCode, "Parent folder not found")
+		case errors.Is(err, xerr.ErrPermissionDenied):
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		case errors.Is(err, xerr.ErrCannotMoveIntoSubtree):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.CannotMoveIntoSubtreeCode)
+		case errors.Is(err, xerr.ErrTargetNotFolder):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.TargetNotFolderCode)
+		case handleFileError(c, err):
+		default:
+			logger.Error("OrganizeFiles: Failed to move files into new folder", zap.Uint64("userID", currentUserID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to move files into new folder")
+		}
 		return
 	}
 
-	response.Success(c, http.StatusOK, "Recycle bin files listed successfully", files)
+	response.Success(c, http.StatusOK, "Files moved into new folder successfully", gin.H{
+		"folder": folder,
+		"files":  movedFiles,
+	})
 }
 
-// @Summary 恢复文件/文件夹
-// @Description 从回收站恢复文件或文件夹到原位置
+// BatchSoftDeleteRequest 批量删除文件的请求体
+type BatchSoftDeleteRequest struct {
+	FileIDs []uint64 `json:"file_ids" binding:"required,min=1"`
+}
+
+// @Summary 批量删除文件/文件夹（移入回收站）
+// @Description 将多个文件或文件夹移入回收站,任意一项校验失败则整体不执行
 // @Tags 文件
+// @Accept json
+// @Produce json
 // @Security BearerAuth
-// @Param file_id path int true "文件ID"
-// @Success 200 {object} xerr.Response "恢复成功"
+// @Param request body BatchSoftDeleteRequest true "批量删除请求体"
+// @Success 200 {object} xerr.Response "删除成功"
 // @Failure 400 {object} xerr.Response "参数错误"
 // @Failure 403 {object} xerr.Response "权限不足"
-// @Failure 409 {object} xerr.Response "原位置已存在同名文件"
-// @Router /api/v1/files/restore/{file_id} [post]
-func (h *FileHandler) RestoreFile(c *gin.Context) {
-	currentUserID, ok := utils.GetUserIDFromContext(c)
-	if !ok {
+// @Failure 404 {object} xerr.Response "文件未找到"
+// @Failure 409 {object} xerr.Response "文件被锁定"
+// @Router /api/v1/files/batch/softdelete [post]
+func (h *FileHandler) BatchSoftDeleteFiles(c *gin.Context) {
+	var req BatchSoftDeleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
 		return
 	}
 
-	fileIDStr := c.Param("file_id")
-	fileID, err := strconv.ParseUint(fileIDStr, 10, 64)
-	if err != nil {
-		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
 		return
 	}
 
-	err = h.fileService.RestoreFile(currentUserID, fileID)
+	jobs, err := h.fileService.BatchSoftDelete(c.Request.Context(), currentUserID, req.FileIDs)
 	if err != nil {
-		if errors.Is(err, xerr.ErrFileNotInRecycleBin) {
-			response.Error(c, http.StatusBadRequest, xerr.FileNotInRecycleBinCode, err.Error())
+		if errors.Is(err, xerr.ErrInvalidParams) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+			return
+		}
+		if errors.Is(err, xerr.ErrFileLocked) {
+			response.ErrorCode(c, http.StatusConflict, xerr.FileLockedCode)
+			return
+		}
+		if errors.Is(err, xerr.ErrFileNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
 			return
 		}
 		if errors.Is(err, xerr.ErrPermissionDenied) {
-			response.Error(c, http.StatusForbidden, xerr.PermissionDeniedCode, err.Error())
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
 			return
 		}
-		if errors.Is(err, xerr.ErrFileAlreadyExists) {
-			response.Error(c, http.StatusConflict, xerr.FileAlreadyExistsCode, err.Error())
+		if handleFileError(c, err) {
 			return
 		}
-		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to restore file")
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to delete files")
 		return
 	}
-
-	response.Success(c, http.StatusOK, fmt.Sprintf("File/Folder %d restored successfully", fileID), nil)
+	if len(jobs) > 0 {
+		response.Success(c, http.StatusOK, fmt.Sprintf("%d files/folders deleted successfully", len(req.FileIDs)), gin.H{"purge_jobs": jobs})
+		return
+	}
+	response.Success(c, http.StatusOK, fmt.Sprintf("%d files/folders soft-deleted successfully", len(req.FileIDs)), nil)
 }
 
-// 定义 RenameFileRequest 结构体
-type RenameFileRequest struct {
-	NewFileName string `json:"new_file_name" binding:"required"`
+// FolderSettingsRequest 修改文件夹设置的请求体
+type FolderSettingsRequest struct {
+	SkipRecycleBin *bool `json:"skip_recycle_bin" binding:"required"` // 文件夹中的内容删除时是否跳过回收站
 }
 
-// @Summary 重命名文件/文件夹
-// @Description 重命名指定的文件或文件夹
+// @Summary 修改文件夹设置
+// @Description 设置文件夹中的文件和子文件夹删除时跳过回收站,直接进入彻底删除流程,适合缓存、临时文件等目录。仅文件夹所有者可以修改
 // @Tags 文件
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param id path int true "文件ID"
-// @Param data body RenameFileRequest true "重命名信息"
-// @Success 200 {object} xerr.Response "重命名成功"
-// @Failure 400 {object} xerr.Response "参数错误"
-// @Failure 403 {object} xerr.Response "权限不足"
-// @Failure 404 {object} xerr.Response "文件未找到"
-// @Router /api/v1/files/rename/{id} [put]
-func (h *FileHandler) RenameFile(c *gin.Context) {
-	fileIDStr := c.Param("id")
-	fileID, err := strconv.ParseUint(fileIDStr, 10, 64)
-	if err != nil {
-		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID")
+// @Param id path int true "文件夹ID"
+// @Param request body FolderSettingsRequest true "文件夹设置"
+// @Success 200 {object} xerr.Response "修改后的文件夹"
+// @Failure 400 {object} xerr.Response "参数错误或目标不是文件夹"
+// @Failure 403 {object} xerr.Response "不是文件夹所有者"
+// @Failure 404 {object} xerr.Response "文件夹未找到"
+// @Failure 409 {object} xerr.Response "文件夹已被其他请求修改"
+// @Router /api/v1/files/folder/{id}/settings [put]
+func (h *FileHandler) UpdateFolderSettings(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
 		return
 	}
 
-	currentUserID, ok := utils.GetUserIDFromContext(c)
-	if !ok {
+	folderID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid folder ID format")
 		return
 	}
 
-	var req RenameFileRequest
+	var req FolderSettingsRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body: "+err.Error())
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
 		return
 	}
 
-	renamedFile, err := h.fileService.RenameFile(currentUserID, fileID, req.NewFileName)
+	folder, err := h.fileService.SetSkipRecycleBin(c.Request.Context(), currentUserID, folderID, *req.SkipRecycleBin)
 	if err != nil {
-		if errors.Is(err, xerr.ErrFileNotFound) {
-			response.Error(c, http.StatusNotFound, xerr.FileNotFoundCode, err.Error())
-		} else if errors.Is(err, xerr.ErrPermissionDenied) {
-			response.Error(c, http.StatusForbidden, xerr.PermissionDeniedCode, err.Error())
-		} else if errors.Is(err, xerr.ErrFileStatusInvalid) {
-			response.Error(c, http.StatusBadRequest, xerr.FileStatusInvalidCode, err.Error())
-		} else if errors.Is(err, xerr.ErrFileAlreadyExists) {
-			response.Error(c, http.StatusConflict, xerr.FileAlreadyExistsCode, err.Error())
-		} else {
-			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to rename file")
+		switch {
+		case errors.Is(err, xerr.ErrFileNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+		case errors.Is(err, xerr.ErrPermissionDenied):
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		case errors.Is(err, xerr.ErrFileStatusInvalid):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.FileStatusInvalidCode)
+		case errors.Is(err, xerr.ErrTargetNotFolder):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.TargetNotFolderCode)
+		case errors.Is(err, xerr.ErrVersionConflict):
+			response.ErrorCode(c, http.StatusConflict, xerr.VersionConflictCode)
+		case handleFileError(c, err):
+		default:
+			logger.Error("UpdateFolderSettings: Failed to update folder settings", zap.Uint64("folderID", folderID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to update folder settings")
 		}
 		return
 	}
 
-	response.Success(c, http.StatusOK, "File/folder renamed successfully", gin.H{
-		"file_info": renamedFile,
-	})
+	response.Success(c, http.StatusOK, "Folder settings updated successfully", folder)
 }
 
-// MoveFileRequest 移动文件的请求体
-type MoveFileRequest struct {
-	FileID               uint64  `json:"file_id" binding:"required"`
-	TargetParentFolderID *uint64 `json:"target_parent_folder_id"`
+// UpdateAttributesRequest 修改文件自定义属性的请求体,值为 null 表示删除该属性
+type UpdateAttributesRequest struct {
+	Attributes map[string]*string `json:"attributes" binding:"required"`
 }
 
-// @Summary 移动文件/文件夹
-// @Description 移动指定文件或文件夹到新的父文件夹下
+// @Summary 修改文件自定义属性
+// @Description 合并修改文件或文件夹的自定义属性,如 color(#rrggbb)、icon、description。未提交的属性保持不变,值为 null 的属性被删除。属性名为小写字母开头的字母、数字、下划线、点或连字符,每个文件最多 32 个属性
 // @Tags 文件
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param request body MoveFileRequest true "移动文件请求体"
-// @Success 200 {object} xerr.Response "成功移动后的文件/文件夹信息"
-// @Failure 400 {object} xerr.Response "参数错误"
-// @Failure 403 {object} xerr.Response "权限不足"
-// @Failure 404 {object} xerr.Response "文件或目标文件夹未找到"
-// @Failure 409 {object} xerr.Response "目标位置已存在同名文件/文件夹"
-// @Router /api/v1/files/move [post]
-func (h *FileHandler) MoveFile(c *gin.Context) {
-	var req MoveFileRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+// @Param file_id path int true "文件ID"
+// @Param request body UpdateAttributesRequest true "要修改的属性"
+// @Success 200 {object} xerr.Response{data=models.File} "修改后的文件"
+// @Failure 400 {object} xerr.Response "属性无效"
+// @Failure 403 {object} xerr.Response "无权限"
+// @Failure 404 {object} xerr.Response "文件未找到"
+// @Failure 409 {object} xerr.Response "文件已被锁定或已被其他请求修改"
+// @Router /api/v1/files/{file_id}/attributes [patch]
+func (h *FileHandler) UpdateAttributes(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
 		return
 	}
 
-	currentUserID, ok := utils.GetUserIDFromContext(c)
-	if !ok {
+	fileID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+
+	var req UpdateAttributesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
 		return
 	}
 
-	movedFile, err := h.fileService.MoveFile(currentUserID, req.FileID, req.TargetParentFolderID)
+	file, err := h.fileService.UpdateAttributes(c.Request.Context(), currentUserID, fileID, req.Attributes)
 	if err != nil {
-		if errors.Is(err, xerr.ErrFileNotFound) {
-			response.Error(c, http.StatusNotFound, xerr.FileNotFoundCode, "File or folder to move not found")
-		} else if errors.Is(err, xerr.ErrDirectoryNotFound) {
-			response.Error(c, http.StatusNotFound, xerr.DirectoryNotFoundCode, "Target parent folder not found")
-		} else if errors.Is(err, xerr.ErrPermissionDenied) {
-			response.Error(c, http.StatusForbidden, xerr.PermissionDeniedCode, err.Error())
-		} else if errors.Is(err, xerr.ErrCannotMoveRoot) {
-			response.Error(c, http.StatusBadRequest, xerr.CannotMoveRootCode, err.Error())
-		} else if errors.Is(err, xerr.ErrCannotMoveIntoSubtree) {
-			response.Error(c, http.StatusBadRequest, xerr.CannotMoveIntoSubtreeCode, err.Error())
-		} else if errors.Is(err, xerr.ErrTargetNotFolder) {
-			response.Error(c, http.StatusBadRequest, xerr.TargetNotFolderCode, err.Error())
-		} else if errors.Is(err, xerr.ErrFileAlreadyExists) {
-			response.Error(c, http.StatusConflict, xerr.FileAlreadyExistsCode, "Name conflict in target location")
-		} else {
-			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to move file/folder")
+		switch {
+		case errors.Is(err, xerr.ErrAttributeInvalid):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.AttributeInvalidCode)
+		case errors.Is(err, xerr.ErrFileNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+		case errors.Is(err, xerr.ErrPermissionDenied):
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		case errors.Is(err, xerr.ErrFileStatusInvalid):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.FileStatusInvalidCode)
+		case errors.Is(err, xerr.ErrFileLocked):
+			response.ErrorCode(c, http.StatusConflict, xerr.FileLockedCode)
+		case errors.Is(err, xerr.ErrVersionConflict):
+			response.ErrorCode(c, http.StatusConflict, xerr.VersionConflictCode)
+		case handleFileError(c, err):
+		default:
+			logger.Error("UpdateAttributes: Failed to update file attributes", zap.Uint64("fileID", fileID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to update file attributes")
 		}
 		return
 	}
 
-	response.Success(c, http.StatusOK, "File/folder moved successfully", gin.H{
-		"file_info": movedFile,
-	})
+	response.Success(c, http.StatusOK, "File attributes updated successfully", file)
 }
 
 // @Summary 删除文件版本
@@ -536,13 +1781,13 @@ func (h *FileHandler) DeleteFileVersion(c *gin.Context) {
 
 	versionID := c.Param("version_id")
 
-	err = h.fileService.DeleteFileVersion(currentUserID, fileID, versionID)
+	err = h.fileService.DeleteFileVersion(c.Request.Context(), currentUserID, fileID, versionID)
 	if err != nil {
 		if errors.Is(err, xerr.ErrFileNotFound) {
-			response.Error(c, http.StatusNotFound, xerr.FileNotFoundCode, err.Error())
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
 		} else if errors.Is(err, xerr.ErrPermissionDenied) {
-			response.Error(c, http.StatusForbidden, xerr.PermissionDeniedCode, err.Error())
-		} else {
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		} else if !handleFileError(c, err) {
 			logger.Error("DeleteFileVersion: Failed to delete file version", zap.Uint64("fileID", fileID), zap.String("versionID", versionID), zap.Error(err))
 			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to delete file version")
 		}
@@ -573,13 +1818,13 @@ func (h *FileHandler) ListFileVersions(c *gin.Context) {
 		return
 	}
 
-	versions, err := h.fileService.ListFileVersions(currentUserID, fileID)
+	versions, err := h.fileService.ListFileVersions(c.Request.Context(), currentUserID, fileID)
 	if err != nil {
 		if errors.Is(err, xerr.ErrFileNotFound) {
-			response.Error(c, http.StatusNotFound, xerr.FileNotFoundCode, err.Error())
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
 		} else if errors.Is(err, xerr.ErrPermissionDenied) {
-			response.Error(c, http.StatusForbidden, xerr.PermissionDeniedCode, err.Error())
-		} else {
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		} else if !handleFileError(c, err) {
 			logger.Error("ListFileVersions: Failed to list file versions", zap.Uint64("fileID", fileID), zap.Error(err))
 			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to list file versions")
 		}
@@ -597,10 +1842,13 @@ func (h *FileHandler) ListFileVersions(c *gin.Context) {
 // @Security BearerAuth
 // @Param file_id path int true "文件ID"
 // @Param version_id path string true "版本ID"
+// @Param If-Match header string false "期望的文件版本号"
+// @Param expected_version query int false "期望的文件版本号,与 If-Match 二选一"
 // @Success 200 {object} xerr.Response "恢复成功"
 // @Failure 400 {object} xerr.Response "参数错误"
 // @Failure 403 {object} xerr.Response "权限不足"
 // @Failure 404 {object} xerr.Response "文件或版本未找到"
+// @Failure 409 {object} xerr.Response "文件已被其他请求修改,返回当前状态"
 // @Router /api/v1/files/{file_id}/versions/{version_id}/restore [post]
 func (h *FileHandler) RestoreFileVersion(c *gin.Context) {
 	currentUserID, ok := utils.GetUserIDFromContext(c)
@@ -617,13 +1865,29 @@ func (h *FileHandler) RestoreFileVersion(c *gin.Context) {
 
 	versionID := c.Param("version_id")
 
-	err = h.fileService.RestoreFileVersion(currentUserID, fileID, versionID)
+	var queryVersion *uint64
+	if raw := c.Query("expected_version"); raw != "" {
+		parsed, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid expected_version")
+			return
+		}
+		queryVersion = &parsed
+	}
+	expectedVersion, ok := parseExpectedVersion(c, queryVersion)
+	if !ok {
+		return
+	}
+
+	err = h.fileService.RestoreFileVersion(c.Request.Context(), currentUserID, fileID, versionID, expectedVersion)
 	if err != nil {
-		if errors.Is(err, xerr.ErrFileNotFound) {
-			response.Error(c, http.StatusNotFound, xerr.FileNotFoundCode, err.Error())
+		if errors.Is(err, xerr.ErrVersionConflict) {
+			h.respondVersionConflict(c, currentUserID, fileID)
+		} else if errors.Is(err, xerr.ErrFileNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
 		} else if errors.Is(err, xerr.ErrPermissionDenied) {
-			response.Error(c, http.StatusForbidden, xerr.PermissionDeniedCode, err.Error())
-		} else {
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		} else if !handleFileError(c, err) {
 			logger.Error("RestoreFileVersion: Failed to restore file version", zap.Uint64("fileID", fileID), zap.String("versionID", versionID), zap.Error(err))
 			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to restore file version")
 		}
@@ -632,3 +1896,184 @@ func (h *FileHandler) RestoreFileVersion(c *gin.Context) {
 
 	response.Success(c, http.StatusOK, "File version restored successfully", nil)
 }
+
+// @Summary 下载文件历史版本
+// @Description 生成文件指定历史版本的预签名下载链接
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Param version_id path string true "版本ID"
+// @Success 200 {object} xerr.Response "下载链接"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 403 {object} xerr.Response "权限不足"
+// @Failure 404 {object} xerr.Response "文件或版本未找到"
+// @Router /api/v1/files/{file_id}/versions/{version_id}/download [get]
+func (h *FileHandler) DownloadFileVersion(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	fileID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+	versionID := c.Param("version_id")
+
+	presignedURL, err := h.fileService.GetPresignedURLForVersion(c.Request.Context(), currentUserID, fileID, versionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrFileNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+		case errors.Is(err, xerr.ErrFileVersionNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileVersionNotFoundCode)
+		case errors.Is(err, xerr.ErrPermissionDenied):
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		case errors.Is(err, xerr.ErrFileQuarantined):
+			response.ErrorCode(c, http.StatusForbidden, xerr.FileQuarantinedCode)
+		case errors.Is(err, xerr.ErrCannotDownloadFolder):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.CannotDownloadFolderCode)
+		case handleFileError(c, err):
+		default:
+			logger.Error("DownloadFileVersion: Failed to generate presigned URL", zap.Uint64("fileID", fileID), zap.String("versionID", versionID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to get download link")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Presigned URL generated successfully", gin.H{
+		"url": presignedURL,
+	})
+}
+
+// @Summary 锁定文件
+// @Description 为文件加编辑锁，锁定期间其他用户不能重命名、移动、删除或上传新版本，重复加锁会续期
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Success 200 {object} xerr.Response "锁定成功"
+// @Failure 404 {object} xerr.Response "文件未找到"
+// @Failure 409 {object} xerr.Response "文件已被其他用户锁定"
+// @Router /api/v1/files/{file_id}/lock [post]
+func (h *FileHandler) LockFile(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	fileID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+
+	lock, err := h.lockService.Lock(c.Request.Context(), currentUserID, fileID)
+	if err != nil {
+		h.handleLockError(c, "LockFile", fileID, err)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "File locked successfully", lock)
+}
+
+// @Summary 解锁文件
+// @Description 释放当前用户持有的文件编辑锁
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Success 200 {object} xerr.Response "解锁成功"
+// @Failure 404 {object} xerr.Response "文件未找到"
+// @Failure 409 {object} xerr.Response "文件已被其他用户锁定"
+// @Router /api/v1/files/{file_id}/lock [delete]
+func (h *FileHandler) UnlockFile(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	fileID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+
+	if err := h.lockService.Unlock(c.Request.Context(), currentUserID, fileID); err != nil {
+		h.handleLockError(c, "UnlockFile", fileID, err)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "File unlocked successfully", nil)
+}
+
+// handleLockError 将文件锁相关错误映射为 HTTP 响应
+func (h *FileHandler) handleLockError(c *gin.Context, action string, fileID uint64, err error) {
+	if errors.Is(err, xerr.ErrFileLocked) {
+		response.ErrorCode(c, http.StatusConflict, xerr.FileLockedCode)
+	} else if errors.Is(err, xerr.ErrFileNotFound) {
+		response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+	} else if errors.Is(err, xerr.ErrPermissionDenied) {
+		response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+	} else if errors.Is(err, xerr.ErrFileStatusInvalid) {
+		response.ErrorCode(c, http.StatusBadRequest, xerr.FileStatusInvalidCode)
+	} else if !handleFileError(c, err) {
+		logger.Error(action+": Failed to update file lock", zap.Uint64("fileID", fileID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to update file lock")
+	}
+}
+
+// handleFileError 将文件领域服务返回的通用错误映射为 HTTP 响应,作为各接口特定错误之后的兜底,未识别的错误返回 false
+func handleFileError(c *gin.Context, err error) bool {
+	if handleFileNameError(c, err) {
+		return true
+	}
+	switch {
+	case errors.Is(err, xerr.ErrInvalidParams):
+		response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+	case errors.Is(err, xerr.ErrFileNotFound):
+		response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+	case errors.Is(err, xerr.ErrFileVersionNotFound):
+		response.ErrorCode(c, http.StatusNotFound, xerr.FileVersionNotFoundCode)
+	case errors.Is(err, xerr.ErrLinkTargetMissing):
+		response.ErrorCode(c, http.StatusNotFound, xerr.LinkTargetMissingCode)
+	case errors.Is(err, xerr.ErrFileNotInRecycleBin):
+		response.ErrorCode(c, http.StatusNotFound, xerr.FileNotInRecycleBinCode)
+	case errors.Is(err, xerr.ErrDirectoryNotFound):
+		response.ErrorCode(c, http.StatusBadRequest, xerr.DirectoryNotFoundCode)
+	case errors.Is(err, xerr.ErrTargetNotFolder):
+		response.ErrorCode(c, http.StatusBadRequest, xerr.TargetNotFolderCode)
+	case errors.Is(err, xerr.ErrCannotDownloadFolder):
+		response.ErrorCode(c, http.StatusBadRequest, xerr.CannotDownloadFolderCode)
+	case errors.Is(err, xerr.ErrFileStatusInvalid):
+		response.ErrorCode(c, http.StatusBadRequest, xerr.FileStatusInvalidCode)
+	case errors.Is(err, xerr.ErrPermissionDenied):
+		response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+	case errors.Is(err, xerr.ErrFileQuarantined):
+		response.ErrorCode(c, http.StatusForbidden, xerr.FileQuarantinedCode)
+	case errors.Is(err, xerr.ErrFileAlreadyExists):
+		response.ErrorCode(c, http.StatusConflict, xerr.FileAlreadyExistsCode)
+	case errors.Is(err, xerr.ErrFileLocked):
+		response.ErrorCode(c, http.StatusConflict, xerr.FileLockedCode)
+	default:
+		return false
+	}
+	return true
+}
+
+// handleFileNameError 将文件名校验错误映射为 HTTP 响应,不是文件名错误时返回 false
+func handleFileNameError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, xerr.ErrFileNameInvalid):
+		response.ErrorCode(c, http.StatusBadRequest, xerr.FileNameInvalidCode)
+	case errors.Is(err, xerr.ErrFileNameTooLong):
+		response.ErrorCode(c, http.StatusBadRequest, xerr.FileNameTooLongCode)
+	case errors.Is(err, xerr.ErrFileNameReserved):
+		response.ErrorCode(c, http.StatusBadRequest, xerr.FileNameReservedCode)
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+const testUserID uint64 = 7
+
+// errorCase 服务层返回 err 时期望的 HTTP 状态码和业务码
+type errorCase struct {
+	name   string
+	err    error
+	status int
+	code   int
+}
+
+// fileErrorCases handleFileError 识别的全部错误,服务层的错误都带有包装前缀
+var fileErrorCases = []errorCase{
+	{"invalid params", xerr.ErrInvalidParams, http.StatusBadRequest, xerr.InvalidParamsCode},
+	{"file not found", xerr.ErrFileNotFound, http.StatusNotFound, xerr.FileNotFoundCode},
+	{"version not found", xerr.ErrFileVersionNotFound, http.StatusNotFound, xerr.FileVersionNotFoundCode},
+	{"link target missing", xerr.ErrLinkTargetMissing, http.StatusNotFound, xerr.LinkTargetMissingCode},
+	{"not in recycle bin", xerr.ErrFileNotInRecycleBin, http.StatusNotFound, xerr.FileNotInRecycleBinCode},
+	{"directory not found", xerr.ErrDirectoryNotFound, http.StatusBadRequest, xerr.DirectoryNotFoundCode},
+	{"target not folder", xerr.ErrTargetNotFolder, http.StatusBadRequest, xerr.TargetNotFolderCode},
+	{"cannot download folder", xerr.ErrCannotDownloadFolder, http.StatusBadRequest, xerr.CannotDownloadFolderCode},
+	{"file status invalid", xerr.ErrFileStatusInvalid, http.StatusBadRequest, xerr.FileStatusInvalidCode},
+	{"permission denied", xerr.ErrPermissionDenied, http.StatusForbidden, xerr.PermissionDeniedCode},
+	{"file quarantined", xerr.ErrFileQuarantined, http.StatusForbidden, xerr.FileQuarantinedCode},
+	{"file already exists", xerr.ErrFileAlreadyExists, http.StatusConflict, xerr.FileAlreadyExistsCode},
+	{"file locked", xerr.ErrFileLocked, http.StatusConflict, xerr.FileLockedCode},
+	{"file name invalid", xerr.ErrFileNameInvalid, http.StatusBadRequest, xerr.FileNameInvalidCode},
+	{"file name too long", xerr.ErrFileNameTooLong, http.StatusBadRequest, xerr.FileNameTooLongCode},
+	{"file name reserved", xerr.ErrFileNameReserved, http.StatusBadRequest, xerr.FileNameReservedCode},
+}
+
+// unknownErrorCase 未识别的错误按 500 处理
+var unknownErrorCase = errorCase{"unknown error", errors.New("connection refused"), http.StatusInternalServerError, xerr.InternalServerErrorCode}
+
+func TestHandleFileError(t *testing.T) {
+	for _, tt := range fileErrorCases {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			if !handleFileError(c, fmt.Errorf("file service: %w", tt.err)) {
+				t.Fatal("handleFileError = false, want true")
+			}
+			assertResponse(t, w, tt.status, tt.code)
+		})
+	}
+
+	t.Run(unknownErrorCase.name, func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		if handleFileError(c, unknownErrorCase.err) {
+			t.Fatal("handleFileError = true, want false")
+		}
+		if c.Writer.Written() {
+			t.Errorf("response written for unknown error: %s", w.Body.String())
+		}
+	})
+}
+
+type fakeFileService struct {
+	explorer.FileService
+	file *models.File
+	job  *models.PurgeJob
+	err  error
+}
+
+func (s *fakeFileService) GetFileByID(ctx context.Context, userID, fileID uint64) (*models.File, error) {
+	return s.file, s.err
+}
+
+func (s *fakeFileService) SoftDelete(ctx context.Context, userID, fileID uint64) (*models.PurgeJob, error) {
+	return s.job, s.err
+}
+
+func (s *fakeFileService) RenameFile(ctx context.Context, userID, fileID uint64, newFileName string, expectedVersion *uint64) (*models.File, error) {
+	return s.file, s.err
+}
+
+func TestFileHandlerGetSpecificFile(t *testing.T) {
+	file := &models.File{ID: 42, UserID: testUserID, FileName: "report.pdf"}
+	request := func(err error) *httptest.ResponseRecorder {
+		h := &FileHandler{fileService: &fakeFileService{file: file, err: err}}
+		return serve(h.GetSpecificFile, http.MethodGet, "/files/:file_id", "/files/42", "")
+	}
+
+	w := request(nil)
+	assertResponse(t, w, http.StatusOK, xerr.SuccessCode)
+	var body struct {
+		Data models.File `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Data.ID != file.ID {
+		t.Errorf("data = %+v, %v, want file %d", body.Data, err, file.ID)
+	}
+	runErrorCases(t, withUnknown(fileErrorCases), request)
+}
+
+func TestFileHandlerSoftDeleteFile(t *testing.T) {
+	request := func(job *models.PurgeJob, err error) *httptest.ResponseRecorder {
+		h := &FileHandler{fileService: &fakeFileService{job: job, err: err}}
+		return serve(h.SoftDeleteFile, http.MethodDelete, "/files/:file_id", "/files/42", "")
+	}
+
+	assertResponse(t, request(nil, nil), http.StatusOK, xerr.SuccessCode)
+	// 位于跳过回收站的文件夹中时直接彻底删除
+	assertResponse(t, request(&models.PurgeJob{ID: 1}, nil), http.StatusAccepted, xerr.SuccessCode)
+	runErrorCases(t, withUnknown(fileErrorCases), func(err error) *httptest.ResponseRecorder {
+		return request(nil, err)
+	})
+}
+
+func TestFileHandlerRenameFile(t *testing.T) {
+	request := func(err error) *httptest.ResponseRecorder {
+		h := &FileHandler{fileService: &fakeFileService{file: &models.File{ID: 42, FileName: "new.txt"}, err: err}}
+		return serve(h.RenameFile, http.MethodPut, "/files/:id/rename", "/files/42/rename", `{"new_file_name":"new.txt"}`)
+	}
+
+	assertResponse(t, request(nil), http.StatusOK, xerr.SuccessCode)
+	runErrorCases(t, withUnknown(fileErrorCases), request)
+}
+
+type fakeFavoriteService struct {
+	explorer.FavoriteService
+	err error
+}
+
+func (s *fakeFavoriteService) Star(ctx context.Context, userID, fileID uint64) error {
+	return s.err
+}
+
+func (s *fakeFavoriteService) Unstar(ctx context.Context, userID, fileID uint64) error {
+	return s.err
+}
+
+func TestFavoriteHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(h *FavoriteHandler) gin.HandlerFunc
+		method  string
+	}{
+		{"star", func(h *FavoriteHandler) gin.HandlerFunc { return h.StarFile }, http.MethodPost},
+		{"unstar", func(h *FavoriteHandler) gin.HandlerFunc { return h.UnstarFile }, http.MethodDelete},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := func(err error) *httptest.ResponseRecorder {
+				h := NewFavoriteHandler(&fakeFavoriteService{err: err})
+				return serve(tt.handler(h), tt.method, "/favorites/:file_id", "/favorites/42", "")
+			}
+			assertResponse(t, request(nil), http.StatusOK, xerr.SuccessCode)
+			runErrorCases(t, withUnknown(fileErrorCases), request)
+		})
+	}
+}
+
+type fakeTagService struct {
+	explorer.TagService
+	err error
+}
+
+func (s *fakeTagService) AddTags(ctx context.Context, userID, fileID uint64, tags []string) ([]string, error) {
+	return tags, s.err
+}
+
+func (s *fakeTagService) ListTags(ctx context.Context, userID uint64) ([]models.TagCount, error) {
+	return nil, s.err
+}
+
+func TestTagHandlerAddTags(t *testing.T) {
+	request := func(err error) *httptest.ResponseRecorder {
+		h := NewTagHandler(&fakeTagService{err: err})
+		return serve(h.AddTags, http.MethodPost, "/files/:file_id/tags", "/files/42/tags", `{"tags":["work"]}`)
+	}
+
+	assertResponse(t, request(nil), http.StatusOK, xerr.SuccessCode)
+	cases := append(withUnknown(fileErrorCases),
+		errorCase{"tag invalid", xerr.ErrTagInvalid, http.StatusBadRequest, xerr.TagInvalidCode},
+		errorCase{"too many tags", xerr.ErrTooManyTags, http.StatusBadRequest, xerr.TooManyTagsCode},
+	)
+	runErrorCases(t, cases, request)
+}
+
+func TestTagHandlerListTags(t *testing.T) {
+	request := func(err error) *httptest.ResponseRecorder {
+		h := NewTagHandler(&fakeTagService{err: err})
+		return serve(h.ListTags, http.MethodGet, "/tags", "/tags", "")
+	}
+
+	assertResponse(t, request(nil), http.StatusOK, xerr.SuccessCode)
+	runErrorCases(t, withUnknown(fileErrorCases), request)
+}
+
+type fakeCommentService struct {
+	explorer.CommentService
+	err error
+}
+
+func (s *fakeCommentService) ListComments(ctx context.Context, userID, fileID uint64, page, pageSize int) ([]models.FileComment, int64, error) {
+	return nil, 0, s.err
+}
+
+func TestCommentHandlerListComments(t *testing.T) {
+	request := func(err error) *httptest.ResponseRecorder {
+		h := NewCommentHandler(&fakeCommentService{err: err})
+		return serve(h.ListComments, http.MethodGet, "/files/:file_id/comments", "/files/42/comments", "")
+	}
+
+	assertResponse(t, request(nil), http.StatusOK, xerr.SuccessCode)
+	runErrorCases(t, withUnknown(fileErrorCases), request)
+}
+
+type fakeArchiveService struct {
+	explorer.ArchiveService
+	err error
+}
+
+func (s *fakeArchiveService) GetArchive(ctx context.Context, userID, jobID uint64) (*models.ArchiveJob, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &models.ArchiveJob{ID: jobID, UserID: userID}, nil
+}
+
+func TestArchiveHandlerGetArchive(t *testing.T) {
+	request := func(err error) *httptest.ResponseRecorder {
+		h := NewArchiveHandler(&fakeArchiveService{err: err})
+		return serve(h.GetArchive, http.MethodGet, "/archives/:job_id", "/archives/3", "")
+	}
+
+	assertResponse(t, request(nil), http.StatusOK, xerr.SuccessCode)
+	cases := append(withUnknown(fileErrorCases),
+		errorCase{"archive not found", xerr.ErrArchiveNotFound, http.StatusNotFound, xerr.ArchiveNotFoundCode},
+	)
+	runErrorCases(t, cases, request)
+}
+
+type fakePermissionService struct {
+	explorer.PermissionService
+	err error
+}
+
+func (s *fakePermissionService) ListSharedWithMe(ctx context.Context, userID uint64) ([]models.FilePermission, error) {
+	return nil, s.err
+}
+
+func TestPermissionHandlerListSharedWithMe(t *testing.T) {
+	request := func(err error) *httptest.ResponseRecorder {
+		h := NewPermissionHandler(&fakePermissionService{err: err})
+		return serve(h.ListSharedWithMe, http.MethodGet, "/shared-with-me", "/shared-with-me", "")
+	}
+
+	assertResponse(t, request(nil), http.StatusOK, xerr.SuccessCode)
+	runErrorCases(t, withUnknown(fileErrorCases), request)
+}
+
+type fakeTransferService struct {
+	explorer.TransferService
+	err error
+}
+
+func (s *fakeTransferService) Transfer(ctx context.Context, senderID, fileID uint64, recipientUsername string, copyTags bool) (*models.File, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &models.File{ID: fileID + 1}, nil
+}
+
+func TestTransferHandlerTransferFile(t *testing.T) {
+	request := func(err error) *httptest.ResponseRecorder {
+		h := NewTransferHandler(&fakeTransferService{err: err})
+		return serve(h.TransferFile, http.MethodPost, "/files/:file_id/transfer", "/files/42/transfer", `{"username":"bob"}`)
+	}
+
+	assertResponse(t, request(nil), http.StatusOK, xerr.SuccessCode)
+	cases := append(withUnknown(fileErrorCases),
+		errorCase{"user not found", xerr.ErrUserNotFound, http.StatusNotFound, xerr.UserNotFoundCode},
+		errorCase{"quota exceeded", xerr.ErrQuotaExceeded, http.StatusRequestEntityTooLarge, xerr.QuotaExceededCode},
+	)
+	runErrorCases(t, cases, request)
+}
+
+// serve 以 testUserID 作为当前用户,把请求交给注册在 route 上的 handler
+func serve(handler gin.HandlerFunc, method, route, target, body string) *httptest.ResponseRecorder {
+	r := gin.New()
+	r.Handle(method, route, func(c *gin.Context) {
+		c.Set("userID", testUserID)
+	}, handler)
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// runErrorCases 服务层依次返回每个错误,检查响应的状态码和业务码
+func runErrorCases(t *testing.T, cases []errorCase, request func(err error) *httptest.ResponseRecorder) {
+	t.Helper()
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assertResponse(t, request(fmt.Errorf("service: %w", tt.err)), tt.status, tt.code)
+		})
+	}
+}
+
+func withUnknown(cases []errorCase) []errorCase {
+	return append(append([]errorCase{}, cases...), unknownErrorCase)
+}
+
+func assertResponse(t *testing.T, w *httptest.ResponseRecorder, status, code int) {
+	t.Helper()
+	if w.Code != status {
+		t.Errorf("status = %d, want %d, body %s", w.Code, status, w.Body.String())
+	}
+	var resp response.Response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response body %q: %v", w.Body.String(), err)
+	}
+	if resp.Code != code {
+		t.Errorf("code = %d, want %d, message %q", resp.Code, code, resp.Message)
+	}
+}
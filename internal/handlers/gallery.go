@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type GalleryHandler struct {
+	galleryService explorer.GalleryService
+}
+
+func NewGalleryHandler(galleryService explorer.GalleryService) *GalleryHandler {
+	return &GalleryHandler{
+		galleryService: galleryService,
+	}
+}
+
+// @Summary 相册
+// @Description 跨文件夹列出当前用户的图片和视频,按拍摄时间倒序。拍摄时间在上传后异步从 EXIF 或视频头中提取,提取前或没有拍摄时间时使用上传时间
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param year query int false "年份,不传时返回全部"
+// @Param month query int false "月份 1-12,需要同时传 year"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(100)
+// @Success 200 {object} xerr.Response "图片和视频列表"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Router /api/v1/gallery [get]
+func (h *GalleryHandler) ListGallery(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	year, err := strconv.Atoi(c.DefaultQuery("year", "0"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid year")
+		return
+	}
+	month, err := strconv.Atoi(c.DefaultQuery("month", "0"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid month")
+		return
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "100"))
+	if err != nil || pageSize < 1 || pageSize > 500 {
+		pageSize = 100
+	}
+
+	items, total, err := h.galleryService.ListGallery(c.Request.Context(), currentUserID, year, month, page, pageSize)
+	if err != nil {
+		if errors.Is(err, xerr.ErrInvalidParams) {
+			response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "month must be 1-12 and requires year")
+			return
+		}
+		logger.Error("ListGallery: Failed to list gallery", zap.Uint64("userID", currentUserID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to list gallery")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Gallery listed successfully", gin.H{
+		"items": items,
+		"total": total,
+	})
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type LifecycleHandler struct {
+	lifecycleService explorer.LifecycleService
+}
+
+func NewLifecycleHandler(lifecycleService explorer.LifecycleService) *LifecycleHandler {
+	return &LifecycleHandler{
+		lifecycleService: lifecycleService,
+	}
+}
+
+// @Summary 创建生命周期规则
+// @Description 为自己的文件夹创建规则,最后修改时间超过 age_days 天的文件定时归档到目标文件夹(archive)或移入回收站(delete)。dry_run 为 true 时只生成报告
+// @Tags 文件
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.LifecycleRuleRequest true "规则内容"
+// @Success 201 {object} xerr.Response "创建的规则"
+// @Failure 400 {object} xerr.Response "规则无效"
+// @Failure 403 {object} xerr.Response "不是文件夹所有者"
+// @Failure 404 {object} xerr.Response "文件夹不存在"
+// @Router /api/v1/lifecycle/rules [post]
+func (h *LifecycleHandler) CreateRule(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req models.LifecycleRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+
+	rule, err := h.lifecycleService.CreateRule(c.Request.Context(), currentUserID, &req)
+	if err != nil {
+		h.handleError(c, "CreateRule", err)
+		return
+	}
+
+	response.Success(c, http.StatusCreated, "Lifecycle rule created successfully", rule)
+}
+
+// @Summary 列出生命周期规则
+// @Description 列出当前用户的全部规则,包括最近一次执行的报告
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} xerr.Response "规则列表"
+// @Router /api/v1/lifecycle/rules [get]
+func (h *LifecycleHandler) ListRules(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	rules, err := h.lifecycleService.ListRules(c.Request.Context(), currentUserID)
+	if err != nil {
+		h.handleError(c, "ListRules", err)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Lifecycle rules listed successfully", rules)
+}
+
+// @Summary 修改生命周期规则
+// @Description 修改天数、试运行和启用状态,未提供的字段保持不变
+// @Tags 文件
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param rule_id path int true "规则ID"
+// @Param request body models.LifecycleRuleUpdateRequest true "修改内容"
+// @Success 200 {object} xerr.Response "修改后的规则"
+// @Failure 404 {object} xerr.Response "规则不存在"
+// @Router /api/v1/lifecycle/rules/{rule_id} [patch]
+func (h *LifecycleHandler) UpdateRule(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	ruleID, err := strconv.ParseUint(c.Param("rule_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid rule ID format")
+		return
+	}
+
+	var req models.LifecycleRuleUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+
+	rule, err := h.lifecycleService.UpdateRule(c.Request.Context(), currentUserID, ruleID, &req)
+	if err != nil {
+		h.handleError(c, "UpdateRule", err)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Lifecycle rule updated successfully", rule)
+}
+
+// @Summary 删除生命周期规则
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param rule_id path int true "规则ID"
+// @Success 200 {object} xerr.Response "删除成功"
+// @Failure 404 {object} xerr.Response "规则不存在"
+// @Router /api/v1/lifecycle/rules/{rule_id} [delete]
+func (h *LifecycleHandler) DeleteRule(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	ruleID, err := strconv.ParseUint(c.Param("rule_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid rule ID format")
+		return
+	}
+
+	if err := h.lifecycleService.DeleteRule(c.Request.Context(), currentUserID, ruleID); err != nil {
+		h.handleError(c, "DeleteRule", err)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Lifecycle rule deleted successfully", nil)
+}
+
+// @Summary 立即执行生命周期规则
+// @Description 立即执行一次规则并返回报告。dry_run=true 或规则设置了试运行时只列出会被处理的文件,不做任何修改
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param rule_id path int true "规则ID"
+// @Param dry_run query bool false "只生成报告"
+// @Success 200 {object} xerr.Response "执行报告"
+// @Failure 404 {object} xerr.Response "规则或文件夹不存在"
+// @Failure 409 {object} xerr.Response "规则正在执行"
+// @Router /api/v1/lifecycle/rules/{rule_id}/run [post]
+func (h *LifecycleHandler) RunRule(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	ruleID, err := strconv.ParseUint(c.Param("rule_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid rule ID format")
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+
+	report, err := h.lifecycleService.RunRule(c.Request.Context(), currentUserID, ruleID, dryRun)
+	if err != nil {
+		h.handleError(c, "RunRule", err)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Lifecycle rule executed successfully", report)
+}
+
+func (h *LifecycleHandler) handleError(c *gin.Context, op string, err error) {
+	switch {
+	case errors.Is(err, xerr.ErrLifecycleRuleNotFound):
+		response.ErrorCode(c, http.StatusNotFound, xerr.LifecycleRuleNotFoundCode)
+	case errors.Is(err, xerr.ErrLifecycleRuleInvalid):
+		response.ErrorCode(c, http.StatusBadRequest, xerr.LifecycleRuleInvalidCode)
+	case errors.Is(err, xerr.ErrLifecycleRuleRunning):
+		response.ErrorCode(c, http.StatusConflict, xerr.LifecycleRuleRunningCode)
+	case errors.Is(err, xerr.ErrFileNotFound):
+		response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+	case errors.Is(err, xerr.ErrPermissionDenied):
+		response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+	case errors.Is(err, xerr.ErrFileStatusInvalid):
+		response.ErrorCode(c, http.StatusBadRequest, xerr.FileStatusInvalidCode)
+	case errors.Is(err, xerr.ErrTargetNotFolder):
+		response.ErrorCode(c, http.StatusBadRequest, xerr.TargetNotFolderCode)
+	default:
+		logger.Error(op+": Failed to handle lifecycle rule", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to handle lifecycle rule")
+	}
+}
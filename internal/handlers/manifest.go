@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 同步清单的输出格式
+const (
+	manifestFormatNDJSON = "ndjson"
+	manifestFormatCSV    = "csv"
+)
+
+// manifestFlushInterval 每写入多少行刷新一次响应,客户端可以边接收边处理
+const manifestFlushInterval = 500
+
+// manifestCSVHeader CSV 格式的表头,列顺序与 writeManifestCSVRow 一致
+var manifestCSVHeader = []string{"id", "parent_id", "name", "is_folder", "size", "sha256", "mtime"}
+
+// @Summary 获取同步清单
+// @Description 以流的形式返回文件夹整个子树的精简清单(id、父文件夹、名称、大小、哈希、修改时间),父文件夹总在其子项之前。
+// @Description 同步客户端一次请求即可比对本地状态,不必逐个文件夹列出。format 为 ndjson(默认,每行一个 JSON 对象)或 csv
+// @Tags 文件
+// @Produce application/x-ndjson,text/csv
+// @Security BearerAuth
+// @Param folder_id query int false "文件夹ID,为空时返回整个网盘"
+// @Param format query string false "输出格式: ndjson 或 csv"
+// @Success 200 {string} string "同步清单"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 403 {object} xerr.Response "权限不足"
+// @Failure 404 {object} xerr.Response "文件夹未找到"
+// @Router /api/v1/files/manifest [get]
+func (h *FileHandler) GetManifest(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	folderID, ok := parseOptionalID(c, "folder_id")
+	if !ok {
+		return
+	}
+	format := c.DefaultQuery("format", manifestFormatNDJSON)
+	if format != manifestFormatNDJSON && format != manifestFormatCSV {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "format must be ndjson or csv")
+		return
+	}
+
+	entries, err := h.fileService.GetManifest(c.Request.Context(), currentUserID, folderID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrFileNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+		} else if errors.Is(err, xerr.ErrPermissionDenied) {
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		} else if errors.Is(err, xerr.ErrTargetNotFolder) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.TargetNotFolderCode)
+		} else if !handleFileError(c, err) {
+			logger.Error("GetManifest: Failed to build manifest", zap.Any("folderID", folderID), zap.Uint64("userID", currentUserID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to build manifest")
+		}
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Manifest-Count", strconv.Itoa(len(entries)))
+	if format == manifestFormatCSV {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		c.Header("Content-Type", "application/x-ndjson")
+	}
+	c.Status(http.StatusOK)
+
+	// 响应头已经发出,写入失败通常是客户端断开,只记录日志
+	if err := writeManifest(c, format, entries); err != nil {
+		logger.Warn("GetManifest: Failed to write manifest", zap.Uint64("userID", currentUserID), zap.Error(err))
+	}
+}
+
+// writeManifest 逐行写入清单,每 manifestFlushInterval 行刷新一次
+func writeManifest(c *gin.Context, format string, entries []models.ManifestEntry) error {
+	var csvWriter *csv.Writer
+	encoder := json.NewEncoder(c.Writer)
+	if format == manifestFormatCSV {
+		csvWriter = csv.NewWriter(c.Writer)
+		if err := csvWriter.Write(manifestCSVHeader); err != nil {
+			return err
+		}
+	}
+
+	for i := range entries {
+		var err error
+		if csvWriter != nil {
+			err = writeManifestCSVRow(csvWriter, &entries[i])
+		} else {
+			err = encoder.Encode(&entries[i])
+		}
+		if err != nil {
+			return err
+		}
+		if (i+1)%manifestFlushInterval == 0 {
+			if csvWriter != nil {
+				csvWriter.Flush()
+			}
+			c.Writer.Flush()
+		}
+	}
+	if csvWriter != nil {
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			return err
+		}
+	}
+	c.Writer.Flush()
+	return nil
+}
+
+// writeManifestCSVRow 写入一行 CSV,根目录下条目的 parent_id 为空
+func writeManifestCSVRow(w *csv.Writer, entry *models.ManifestEntry) error {
+	parentID := ""
+	if entry.ParentID != nil {
+		parentID = strconv.FormatUint(*entry.ParentID, 10)
+	}
+	return w.Write([]string{
+		strconv.FormatUint(entry.ID, 10),
+		parentID,
+		entry.Name,
+		strconv.Itoa(int(entry.IsFolder)),
+		strconv.FormatUint(entry.Size, 10),
+		entry.SHA256,
+		strconv.FormatInt(entry.MTime, 10),
+	})
+}
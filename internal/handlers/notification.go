@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/activity"
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationHandler struct {
+	notificationService activity.NotificationService
+}
+
+func NewNotificationHandler(notificationService activity.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: notificationService,
+	}
+}
+
+// @Summary 获取站内通知
+// @Description 分页获取当前用户的通知(分享被访问、收到文件、用量预警、版本还原、打包和导出完成),按时间倒序,同时返回未读数量
+// @Tags 通知
+// @Produce json
+// @Security BearerAuth
+// @Param unread query bool false "只返回未读通知"
+// @Param page query int false "页码，默认为1" default(1)
+// @Param pageSize query int false "每页数量，默认为10" default(10)
+// @Success 200 {object} xerr.Response "通知列表"
+// @Router /api/v1/notifications [get]
+func (h *NotificationHandler) ListNotifications(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	unreadOnly := c.Query("unread") == "true"
+	page, pageSize := parsePagination(c)
+	notifications, total, unread, err := h.notificationService.List(c.Request.Context(), currentUserID, unreadOnly, page, pageSize)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to list notifications")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Notifications listed successfully", gin.H{
+		"notifications": notifications,
+		"total":         total,
+		"unread":        unread,
+	})
+}
+
+type MarkNotificationsReadRequest struct {
+	IDs []uint64 `json:"ids" binding:"required,min=1,max=100"`
+}
+
+// @Summary 标记通知为已读
+// @Description 将指定的通知标记为已读,不属于当前用户或已读的通知会被忽略
+// @Tags 通知
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MarkNotificationsReadRequest true "通知ID列表"
+// @Success 200 {object} xerr.Response "实际更新的数量"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Router /api/v1/notifications/read [post]
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req MarkNotificationsReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+
+	updated, err := h.notificationService.MarkRead(c.Request.Context(), currentUserID, req.IDs)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to mark notifications read")
+		return
+	}
+	response.Success(c, http.StatusOK, "Notifications marked read", gin.H{"updated": updated})
+}
+
+// @Summary 全部标记为已读
+// @Description 将当前用户的全部未读通知标记为已读
+// @Tags 通知
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} xerr.Response "实际更新的数量"
+// @Router /api/v1/notifications/read-all [post]
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	updated, err := h.notificationService.MarkAllRead(c.Request.Context(), currentUserID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to mark notifications read")
+		return
+	}
+	response.Success(c, http.StatusOK, "All notifications marked read", gin.H{"updated": updated})
+}
@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/admin"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type OAuthHandler struct {
+	oauthService admin.OAuthService
+}
+
+func NewOAuthHandler(oauthService admin.OAuthService) *OAuthHandler {
+	return &OAuthHandler{
+		oauthService: oauthService,
+	}
+}
+
+// RegisterOAuthClientRequest 注册第三方应用请求体
+type RegisterOAuthClientRequest struct {
+	Name         string   `json:"name" binding:"required,max=64"`
+	RedirectURIs []string `json:"redirect_uris" binding:"required,min=1,max=10,dive,max=512"`
+	Scopes       []string `json:"scopes" binding:"required,min=1,dive,oneof=files.read files.write shares.manage"`
+}
+
+// OAuthConsentRequest 用户确认或拒绝授权的请求体
+type OAuthConsentRequest struct {
+	models.OAuthAuthorizeRequest
+	Approve bool `json:"approve"`
+}
+
+// OAuthTokenRequest 令牌接口的表单参数,应用密钥也可以通过 HTTP Basic 认证传递
+type OAuthTokenRequest struct {
+	GrantType    string `form:"grant_type" binding:"required"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// @Summary 注册第三方应用
+// @Description 注册 OAuth2 应用,登记回调地址和可申请的权限范围(files.read/files.write/shares.manage)。client_secret 只在注册时返回一次
+// @Tags OAuth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body RegisterOAuthClientRequest true "应用信息"
+// @Success 200 {object} xerr.Response "应用信息和明文密钥"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Router /api/v1/oauth/clients [post]
+func (h *OAuthHandler) RegisterClient(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req RegisterOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+
+	client, secret, err := h.oauthService.RegisterClient(c.Request.Context(), currentUserID, req.Name, req.RedirectURIs, req.Scopes)
+	if err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrInvalidParams):
+			response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Redirect URIs must be absolute URLs without fragments")
+		case errors.Is(err, xerr.ErrOAuthScopeInvalid):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.OAuthScopeInvalidCode)
+		default:
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to register OAuth client")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "OAuth client registered successfully", gin.H{
+		"client_secret": secret,
+		"client":        client,
+	})
+}
+
+// @Summary 获取已注册的第三方应用
+// @Description 列出当前用户注册的 OAuth2 应用,不包含密钥
+// @Tags OAuth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} xerr.Response "应用列表"
+// @Router /api/v1/oauth/clients [get]
+func (h *OAuthHandler) ListClients(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	clients, err := h.oauthService.ListClients(c.Request.Context(), currentUserID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to list OAuth clients")
+		return
+	}
+	response.Success(c, http.StatusOK, "OAuth clients listed successfully", clients)
+}
+
+// @Summary 删除第三方应用
+// @Description 删除 OAuth2 应用,应用获得的全部授权立即失效
+// @Tags OAuth
+// @Produce json
+// @Security BearerAuth
+// @Param client_id path string true "应用 client_id"
+// @Success 200 {object} xerr.Response "删除成功"
+// @Failure 404 {object} xerr.Response "应用不存在"
+// @Router /api/v1/oauth/clients/{client_id} [delete]
+func (h *OAuthHandler) DeleteClient(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	if err := h.oauthService.DeleteClient(c.Request.Context(), currentUserID, c.Param("client_id")); err != nil {
+		if errors.Is(err, xerr.ErrOAuthClientNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.OAuthClientNotFoundCode)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to delete OAuth client")
+		return
+	}
+	response.Success(c, http.StatusOK, "OAuth client deleted successfully", nil)
+}
+
+// @Summary 获取授权确认信息
+// @Description 授权确认页调用,校验应用、回调地址和申请的权限范围,返回需要向用户展示的信息。应用或回调地址无效时不能重定向回应用
+// @Tags OAuth
+// @Produce json
+// @Security BearerAuth
+// @Param response_type query string true "固定为 code"
+// @Param client_id query string true "应用 client_id"
+// @Param redirect_uri query string true "回调地址,必须与注册时一致"
+// @Param scope query string false "以空格分隔的权限范围,为空时申请应用登记的全部权限"
+// @Param state query string false "原样返回给应用"
+// @Param code_challenge query string false "PKCE code_challenge"
+// @Param code_challenge_method query string false "只支持 S256"
+// @Success 200 {object} xerr.Response{data=models.OAuthConsent} "授权确认信息"
+// @Failure 400 {object} xerr.Response "应用、回调地址或权限范围无效"
+// @Router /api/v1/oauth/authorize [get]
+func (h *OAuthHandler) GetConsent(c *gin.Context) {
+	var req models.OAuthAuthorizeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid authorization request")
+		return
+	}
+
+	consent, err := h.oauthService.GetConsent(c.Request.Context(), &req)
+	if err != nil {
+		h.handleAuthorizeError(c, err)
+		return
+	}
+	response.Success(c, http.StatusOK, "Consent retrieved successfully", consent)
+}
+
+// @Summary 确认或拒绝授权
+// @Description 用户在授权确认页做出选择后调用,返回应用的回调地址。同意时附带一次性的授权码,拒绝时附带 error=access_denied,前端跳转到该地址即可
+// @Tags OAuth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body OAuthConsentRequest true "授权请求参数和用户选择"
+// @Success 200 {object} xerr.Response "回调地址"
+// @Failure 400 {object} xerr.Response "应用、回调地址或权限范围无效"
+// @Router /api/v1/oauth/authorize [post]
+func (h *OAuthHandler) Authorize(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req OAuthConsentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid authorization request")
+		return
+	}
+
+	redirectURI, err := h.oauthService.Authorize(c.Request.Context(), currentUserID, &req.OAuthAuthorizeRequest, req.Approve)
+	if err != nil {
+		h.handleAuthorizeError(c, err)
+		return
+	}
+	response.Success(c, http.StatusOK, "Authorization completed", gin.H{"redirect_uri": redirectURI})
+}
+
+func (h *OAuthHandler) handleAuthorizeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, xerr.ErrOAuthClientInvalid):
+		response.ErrorCode(c, http.StatusBadRequest, xerr.OAuthClientInvalidCode)
+	case errors.Is(err, xerr.ErrOAuthScopeInvalid):
+		response.ErrorCode(c, http.StatusBadRequest, xerr.OAuthScopeInvalidCode)
+	case errors.Is(err, xerr.ErrInvalidParams):
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Only response_type=code with optional S256 PKCE is supported")
+	default:
+		logger.Error("Authorize: Failed to handle authorization request", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to handle authorization request")
+	}
+}
+
+// @Summary 签发第三方应用令牌
+// @Description OAuth2 令牌接口,无需用户认证。grant_type 为 authorization_code 时用授权码换取令牌,为 refresh_token 时轮换令牌。应用密钥通过表单或 HTTP Basic 认证传递,响应和错误格式遵循 RFC 6749
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "authorization_code 或 refresh_token"
+// @Param code formData string false "授权码"
+// @Param redirect_uri formData string false "与授权请求一致的回调地址"
+// @Param code_verifier formData string false "PKCE code_verifier"
+// @Param refresh_token formData string false "刷新令牌"
+// @Param scope formData string false "刷新时缩小的权限范围"
+// @Param client_id formData string false "应用 client_id"
+// @Param client_secret formData string false "应用密钥"
+// @Success 200 {object} models.OAuthTokenResponse "令牌"
+// @Failure 400 {object} map[string]string "授权码或刷新令牌无效"
+// @Failure 401 {object} map[string]string "应用认证失败"
+// @Router /api/v1/oauth/token [post]
+func (h *OAuthHandler) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	var req OAuthTokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		oauthError(c, http.StatusBadRequest, "invalid_request", "grant_type is required")
+		return
+	}
+	if clientID, clientSecret, ok := c.Request.BasicAuth(); ok {
+		req.ClientID, req.ClientSecret = clientID, clientSecret
+	}
+
+	var (
+		token *models.OAuthTokenResponse
+		err   error
+	)
+	switch req.GrantType {
+	case "authorization_code":
+		if req.Code == "" || req.RedirectURI == "" {
+			oauthError(c, http.StatusBadRequest, "invalid_request", "code and redirect_uri are required")
+			return
+		}
+		token, err = h.oauthService.ExchangeCode(c.Request.Context(), req.ClientID, req.ClientSecret, req.Code, req.RedirectURI, req.CodeVerifier)
+	case "refresh_token":
+		if req.RefreshToken == "" {
+			oauthError(c, http.StatusBadRequest, "invalid_request", "refresh_token is required")
+			return
+		}
+		token, err = h.oauthService.RefreshToken(c.Request.Context(), req.ClientID, req.ClientSecret, req.RefreshToken, req.Scope)
+	default:
+		oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "")
+		return
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrOAuthClientInvalid):
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+			oauthError(c, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		case errors.Is(err, xerr.ErrOAuthGrantInvalid):
+			oauthError(c, http.StatusBadRequest, "invalid_grant", "The authorization code or refresh token is invalid or expired")
+		case errors.Is(err, xerr.ErrOAuthScopeInvalid):
+			oauthError(c, http.StatusBadRequest, "invalid_scope", "The requested scope exceeds the granted scope")
+		default:
+			logger.Error("Token: Failed to issue oauth token", zap.String("grantType", req.GrantType), zap.Error(err))
+			oauthError(c, http.StatusInternalServerError, "server_error", "")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+}
+
+// oauthError 令牌接口的错误响应遵循 RFC 6749 第 5.2 节
+func oauthError(c *gin.Context, status int, code, description string) {
+	body := gin.H{"error": code}
+	if description != "" {
+		body["error_description"] = description
+	}
+	c.JSON(status, body)
+}
+
+// @Summary 获取已授权的第三方应用
+// @Description 列出当前用户授权过的应用和权限范围
+// @Tags OAuth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} xerr.Response{data=[]models.OAuthAuthorization} "授权列表"
+// @Router /api/v1/users/me/authorizations [get]
+func (h *OAuthHandler) ListAuthorizations(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	authorizations, err := h.oauthService.ListAuthorizations(c.Request.Context(), currentUserID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to list authorizations")
+		return
+	}
+	response.Success(c, http.StatusOK, "Authorizations listed successfully", authorizations)
+}
+
+// @Summary 撤销第三方应用授权
+// @Description 撤销一次授权,应用持有的访问令牌和刷新令牌立即失效
+// @Tags OAuth
+// @Produce json
+// @Security BearerAuth
+// @Param authorization_id path int true "授权ID"
+// @Success 200 {object} xerr.Response "撤销成功"
+// @Failure 404 {object} xerr.Response "授权不存在"
+// @Router /api/v1/users/me/authorizations/{authorization_id} [delete]
+func (h *OAuthHandler) RevokeAuthorization(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	authorizationID, err := strconv.ParseUint(c.Param("authorization_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid authorization ID format")
+		return
+	}
+
+	if err := h.oauthService.RevokeAuthorization(c.Request.Context(), currentUserID, authorizationID); err != nil {
+		if errors.Is(err, xerr.ErrOAuthClientNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.OAuthClientNotFoundCode)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to revoke authorization")
+		return
+	}
+	response.Success(c, http.StatusOK, "Authorization revoked successfully", nil)
+}
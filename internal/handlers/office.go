@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type OfficeHandler struct {
+	officeService explorer.OfficeService
+}
+
+func NewOfficeHandler(officeService explorer.OfficeService) *OfficeHandler {
+	return &OfficeHandler{
+		officeService: officeService,
+	}
+}
+
+// @Summary 创建 Office 在线编辑会话
+// @Description 为 docx/xlsx/pptx 文件生成 OnlyOffice 编辑器配置,有写权限且文件未被他人锁定时可编辑,否则只读打开。前端加载 api_url 后使用 config 创建 DocsAPI.DocEditor
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Success 200 {object} xerr.Response "编辑器脚本地址和签名后的编辑器配置"
+// @Failure 403 {object} xerr.Response "无权限"
+// @Failure 404 {object} xerr.Response "文件未找到"
+// @Failure 415 {object} xerr.Response "文件类型不支持在线编辑或未开启在线编辑"
+// @Router /api/v1/files/{file_id}/office/session [get]
+func (h *OfficeHandler) CreateSession(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	fileID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+
+	session, err := h.officeService.CreateSession(c.Request.Context(), currentUserID, fileID)
+	if err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrFileNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+		case errors.Is(err, xerr.ErrPermissionDenied):
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		case errors.Is(err, xerr.ErrFileQuarantined):
+			response.ErrorCode(c, http.StatusForbidden, xerr.FileQuarantinedCode)
+		case errors.Is(err, xerr.ErrFileStatusInvalid):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.FileStatusInvalidCode)
+		case errors.Is(err, xerr.ErrCannotDownloadFolder), errors.Is(err, xerr.ErrOfficeNotSupported):
+			response.ErrorCode(c, http.StatusUnsupportedMediaType, xerr.OfficeNotSupportedCode)
+		default:
+			logger.Error("CreateSession: Failed to create office session", zap.Uint64("fileID", fileID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to create office session")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Office session created successfully", session)
+}
+
+// @Summary OnlyOffice 文档服务器回调
+// @Description 供文档服务器调用,不使用用户认证。通过回调地址中的 token 和文档服务器的 JWT 签名校验请求,文档关闭且有修改时把编辑结果保存为新版本。响应格式遵循 OnlyOffice 协议,error 为 0 表示处理成功
+// @Tags 文件
+// @Accept json
+// @Produce json
+// @Param token query string true "编辑会话签发的回调 token"
+// @Param request body models.OfficeCallback true "回调内容"
+// @Success 200 {object} map[string]int "处理结果"
+// @Router /api/v1/office/callback [post]
+func (h *OfficeHandler) Callback(c *gin.Context) {
+	var callback models.OfficeCallback
+	if err := c.ShouldBindJSON(&callback); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": 1})
+		return
+	}
+
+	if err := h.officeService.HandleCallback(c.Request.Context(), c.Query("token"), c.GetHeader("Authorization"), &callback); err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrTokenInvalid):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": 1})
+		case errors.Is(err, xerr.ErrPermissionDenied), errors.Is(err, xerr.ErrFileLocked):
+			c.JSON(http.StatusForbidden, gin.H{"error": 1})
+		default:
+			logger.Error("Callback: Failed to handle office callback", zap.String("key", callback.Key), zap.Int("status", callback.Status), zap.Error(err))
+			c.JSON(http.StatusOK, gin.H{"error": 1})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"error": 0})
+}
@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type OrganizationHandler struct {
+	orgService explorer.OrganizationService
+}
+
+func NewOrganizationHandler(orgService explorer.OrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{
+		orgService: orgService,
+	}
+}
+
+// CreateOrganizationRequest 创建组织请求体
+type CreateOrganizationRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// AddOrgMemberRequest 添加组织成员请求体
+type AddOrgMemberRequest struct {
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role" binding:"required,oneof=owner admin member"`
+}
+
+// @Summary 创建组织
+// @Description 创建组织和团队空间,创建者成为所有者。团队文件使用组织的共享配额
+// @Tags 组织
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateOrganizationRequest true "创建组织请求体"
+// @Success 201 {object} xerr.Response "组织信息"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 403 {object} xerr.Response "创建的组织数量达到上限"
+// @Router /api/v1/orgs [post]
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+
+	org, err := h.orgService.CreateOrganization(c.Request.Context(), currentUserID, req.Name)
+	if err != nil {
+		h.handleOrganizationError(c, err, "Failed to create organization")
+		return
+	}
+
+	response.Success(c, http.StatusCreated, "Organization created successfully", org)
+}
+
+// @Summary 我的组织
+// @Description 列出当前用户加入的组织和在其中的角色
+// @Tags 组织
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} xerr.Response "组织列表"
+// @Router /api/v1/orgs [get]
+func (h *OrganizationHandler) ListOrganizations(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	orgs, err := h.orgService.ListOrganizations(c.Request.Context(), currentUserID)
+	if err != nil {
+		h.handleOrganizationError(c, err, "Failed to list organizations")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Organizations retrieved successfully", orgs)
+}
+
+// @Summary 获取组织详情
+// @Description 返回组织信息、当前用户的角色和团队空间用量,仅成员可见
+// @Tags 组织
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path int true "组织ID"
+// @Success 200 {object} xerr.Response "组织详情"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 404 {object} xerr.Response "组织不存在"
+// @Router /api/v1/orgs/{org_id} [get]
+func (h *OrganizationHandler) GetOrganization(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	orgID, ok := parseOrgID(c)
+	if !ok {
+		return
+	}
+
+	org, err := h.orgService.GetOrganization(c.Request.Context(), currentUserID, orgID)
+	if err != nil {
+		h.handleOrganizationError(c, err, "Failed to get organization")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Organization retrieved successfully", org)
+}
+
+// @Summary 列出团队文件
+// @Description 分页列出团队空间中的文件夹内容,未指定 parent_id 时列出团队根文件夹。上传、新建和修改团队文件使用常规文件接口
+// @Tags 组织
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path int true "组织ID"
+// @Param parent_id query int false "团队空间内的父文件夹ID"
+// @Param page query int false "页码，默认为1" default(1)
+// @Param page_size query int false "每页数量，默认为50，最大500" default(50)
+// @Param sort_by query string false "排序字段 name/size/updated_at" default(name)
+// @Param order query string false "排序方向 asc/desc" default(asc)
+// @Param cursor query string false "游标分页,传入上一页返回的 next_cursor,第一页传空字符串。只支持按名称排序"
+// @Param limit query int false "游标分页每页数量,传入 cursor 或 limit 时忽略 page 和 page_size" default(50)
+// @Param status query string false "删除状态 active/trashed/all" default(active)
+// @Param min_size query int false "文件大小下限(字节,含),设置大小范围时不返回文件夹"
+// @Param max_size query int false "文件大小上限(字节,含)"
+// @Param mime_prefix query string false "内容类型前缀,如 image/"
+// @Param modified_after query string false "只返回在该时间之后修改过的文件,RFC3339 格式"
+// @Success 200 {object} xerr.Response "文件列表"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 404 {object} xerr.Response "组织或文件夹不存在"
+// @Router /api/v1/orgs/{org_id}/files [get]
+func (h *OrganizationHandler) ListFiles(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	orgID, ok := parseOrgID(c)
+	if !ok {
+		return
+	}
+	parentFolderID, ok := parseOptionalID(c, "parent_id")
+	if !ok {
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	if err != nil || pageSize < 1 || pageSize > 500 {
+		pageSize = 50
+	}
+	opts, ok := parseFileListOptions(c, page, pageSize)
+	if !ok {
+		return
+	}
+
+	files, total, nextCursor, err := h.orgService.ListFiles(c.Request.Context(), currentUserID, orgID, parentFolderID, opts)
+	if err != nil {
+		h.handleOrganizationError(c, err, "Failed to list organization files")
+		return
+	}
+
+	result := gin.H{
+		"files": files,
+		"total": total,
+	}
+	if opts.Cursor != nil {
+		result["next_cursor"] = nextCursor
+	}
+	response.Success(c, http.StatusOK, "Files listed successfully", result)
+}
+
+// @Summary 列出组织成员
+// @Tags 组织
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path int true "组织ID"
+// @Success 200 {object} xerr.Response "成员列表"
+// @Failure 404 {object} xerr.Response "组织不存在"
+// @Router /api/v1/orgs/{org_id}/members [get]
+func (h *OrganizationHandler) ListMembers(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	orgID, ok := parseOrgID(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.orgService.ListMembers(c.Request.Context(), currentUserID, orgID)
+	if err != nil {
+		h.handleOrganizationError(c, err, "Failed to list organization members")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Members retrieved successfully", members)
+}
+
+// @Summary 添加组织成员
+// @Description 添加成员或修改已有成员的角色。管理员可以添加普通成员,分配或变更管理员和所有者需要所有者
+// @Tags 组织
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path int true "组织ID"
+// @Param request body AddOrgMemberRequest true "添加成员请求体"
+// @Success 200 {object} xerr.Response "成员信息"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 403 {object} xerr.Response "权限不足"
+// @Failure 404 {object} xerr.Response "组织或用户不存在"
+// @Failure 409 {object} xerr.Response "组织至少需要保留一名所有者"
+// @Router /api/v1/orgs/{org_id}/members [post]
+func (h *OrganizationHandler) AddMember(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	orgID, ok := parseOrgID(c)
+	if !ok {
+		return
+	}
+
+	var req AddOrgMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+
+	member, err := h.orgService.AddMember(c.Request.Context(), currentUserID, orgID, req.Username, req.Role)
+	if err != nil {
+		h.handleOrganizationError(c, err, "Failed to add organization member")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Member saved successfully", member)
+}
+
+// @Summary 移除组织成员
+// @Description 管理员可以移除普通成员,移除管理员和所有者需要所有者,成员可以移除自己以退出组织
+// @Tags 组织
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path int true "组织ID"
+// @Param user_id path int true "成员用户ID"
+// @Success 200 {object} xerr.Response "移除成功"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 403 {object} xerr.Response "权限不足"
+// @Failure 404 {object} xerr.Response "组织或成员不存在"
+// @Failure 409 {object} xerr.Response "组织至少需要保留一名所有者"
+// @Router /api/v1/orgs/{org_id}/members/{user_id} [delete]
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	orgID, ok := parseOrgID(c)
+	if !ok {
+		return
+	}
+	memberID, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid user ID format")
+		return
+	}
+
+	if err := h.orgService.RemoveMember(c.Request.Context(), currentUserID, orgID, memberID); err != nil {
+		h.handleOrganizationError(c, err, "Failed to remove organization member")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Member removed successfully", nil)
+}
+
+// parseOrgID 解析路径中的组织ID,无效时写入错误响应
+func parseOrgID(c *gin.Context) (uint64, bool) {
+	orgID, err := strconv.ParseUint(c.Param("org_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid organization ID format")
+		return 0, false
+	}
+	return orgID, true
+}
+
+// handleOrganizationError 将组织相关的业务错误映射为 HTTP 响应
+func (h *OrganizationHandler) handleOrganizationError(c *gin.Context, err error, fallbackMsg string) {
+	switch {
+	case errors.Is(err, xerr.ErrInvalidParams):
+		response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+	case errors.Is(err, xerr.ErrPermissionDenied):
+		response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+	case errors.Is(err, xerr.ErrOrgNotFound):
+		response.ErrorCode(c, http.StatusNotFound, xerr.OrgNotFoundCode)
+	case errors.Is(err, xerr.ErrOrgMemberNotFound):
+		response.ErrorCode(c, http.StatusNotFound, xerr.OrgMemberNotFoundCode)
+	case errors.Is(err, xerr.ErrUserNotFound):
+		response.ErrorCode(c, http.StatusNotFound, xerr.UserNotFoundCode)
+	case errors.Is(err, xerr.ErrLastOrgOwner):
+		response.ErrorCode(c, http.StatusConflict, xerr.LastOrgOwnerCode)
+	case errors.Is(err, xerr.ErrDirectoryNotFound):
+		response.ErrorCode(c, http.StatusBadRequest, xerr.DirectoryNotFoundCode)
+	case handleFileError(c, err):
+	default:
+		logger.Error(fallbackMsg, zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, fallbackMsg)
+	}
+}
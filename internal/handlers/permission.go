@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type PermissionHandler struct {
+	permissionService explorer.PermissionService
+}
+
+func NewPermissionHandler(permissionService explorer.PermissionService) *PermissionHandler {
+	return &PermissionHandler{
+		permissionService: permissionService,
+	}
+}
+
+// GrantPermissionRequest 授权请求体
+type GrantPermissionRequest struct {
+	Username   string `json:"username" binding:"required"`
+	Permission string `json:"permission" binding:"required,oneof=read write"`
+}
+
+// @Summary 授予文件夹协作权限
+// @Description 文件夹所有者授予其他用户对整个文件夹子树的只读或读写权限,重复授权会更新权限级别
+// @Tags 协作
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param file_id path int true "文件夹ID"
+// @Param request body GrantPermissionRequest true "授权请求体"
+// @Success 200 {object} xerr.Response "授权信息"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 403 {object} xerr.Response "只有所有者可以授权"
+// @Failure 404 {object} xerr.Response "文件夹或用户不存在"
+// @Router /api/v1/files/{file_id}/permissions [post]
+func (h *PermissionHandler) GrantPermission(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	folderID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid folder ID format")
+		return
+	}
+
+	var req GrantPermissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+
+	grant, err := h.permissionService.Grant(c.Request.Context(), currentUserID, folderID, req.Username, models.PermissionNames[req.Permission])
+	if err != nil {
+		h.handlePermissionError(c, err, "Failed to grant permission")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Permission granted successfully", grant)
+}
+
+// @Summary 撤销文件夹协作权限
+// @Description 所有者撤销某用户的授权,被授权者也可以撤销自己的授权以退出共享
+// @Tags 协作
+// @Produce json
+// @Security BearerAuth
+// @Param file_id path int true "文件夹ID"
+// @Param user_id path int true "被授权用户ID"
+// @Success 200 {object} xerr.Response "撤销成功"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 403 {object} xerr.Response "权限不足"
+// @Failure 404 {object} xerr.Response "授权不存在"
+// @Router /api/v1/files/{file_id}/permissions/{user_id} [delete]
+func (h *PermissionHandler) RevokePermission(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	folderID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid folder ID format")
+		return
+	}
+	granteeID, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid user ID format")
+		return
+	}
+
+	if err := h.permissionService.Revoke(c.Request.Context(), currentUserID, folderID, granteeID); err != nil {
+		h.handlePermissionError(c, err, "Failed to revoke permission")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Permission revoked successfully", nil)
+}
+
+// @Summary 获取文件夹协作授权列表
+// @Description 列出文件夹上的全部授权,仅所有者可查看
+// @Tags 协作
+// @Produce json
+// @Security BearerAuth
+// @Param file_id path int true "文件夹ID"
+// @Success 200 {object} xerr.Response "授权列表"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 403 {object} xerr.Response "权限不足"
+// @Router /api/v1/files/{file_id}/permissions [get]
+func (h *PermissionHandler) ListPermissions(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	folderID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid folder ID format")
+		return
+	}
+
+	grants, err := h.permissionService.ListGrants(c.Request.Context(), currentUserID, folderID)
+	if err != nil {
+		h.handlePermissionError(c, err, "Failed to list permissions")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Permissions retrieved successfully", grants)
+}
+
+// @Summary 与我共享
+// @Description 列出其他用户共享给当前用户的文件夹及权限级别
+// @Tags 协作
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} xerr.Response "共享文件夹列表"
+// @Router /api/v1/files/shared-with-me [get]
+func (h *PermissionHandler) ListSharedWithMe(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	shared, err := h.permissionService.ListSharedWithMe(c.Request.Context(), currentUserID)
+	if err != nil {
+		if handleFileError(c, err) {
+			return
+		}
+		logger.Error("ListSharedWithMe: Failed to list shared folders", zap.Uint64("userID", currentUserID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to list shared folders")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Shared folders retrieved successfully", shared)
+}
+
+// handlePermissionError 将授权相关的业务错误映射为 HTTP 响应
+func (h *PermissionHandler) handlePermissionError(c *gin.Context, err error, fallbackMsg string) {
+	switch {
+	case errors.Is(err, xerr.ErrInvalidParams):
+		response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+	case errors.Is(err, xerr.ErrTargetNotFolder):
+		response.ErrorCode(c, http.StatusBadRequest, xerr.TargetNotFolderCode)
+	case errors.Is(err, xerr.ErrPermissionDenied):
+		response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+	case errors.Is(err, xerr.ErrFileNotFound):
+		response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+	case errors.Is(err, xerr.ErrUserNotFound):
+		response.ErrorCode(c, http.StatusNotFound, xerr.UserNotFoundCode)
+	case errors.Is(err, xerr.ErrPermissionNotFound):
+		response.ErrorCode(c, http.StatusNotFound, xerr.PermissionNotFoundCode)
+	case handleFileError(c, err):
+	default:
+		logger.Error(fallbackMsg, zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, fallbackMsg)
+	}
+}
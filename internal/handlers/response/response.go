@@ -1,26 +1,89 @@
 package response
 
-import "github.com/gin-gonic/gin"
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorDocPath 错误码说明接口,错误响应的 doc_url 指向其中对应的条目
+const ErrorDocPath = "/api/v1/errors/"
+
+// API 版本,各版本共用 handler,响应格式由 JSONResponse 按请求的版本适配
+const (
+	APIVersion1 = 1
+	APIVersion2 = 2 // 错误放在 error 对象中,列表数据统一包装为 {"items": [...]}
+)
+
+// apiVersionKey gin 上下文中保存请求 API 版本的键
+const apiVersionKey = "api_version"
 
 // Response 是通用 JSON 响应结构
 type Response struct {
-	Code    int    `json:"code"`    // 业务状态码
-	Message string `json:"message"` // 消息
-	Data    any    `json:"data"`    // 响应数据
+	Code    int    `json:"code"`              // 业务状态码
+	Message string `json:"message"`           // 消息
+	Data    any    `json:"data"`              // 响应数据
+	DocURL  string `json:"doc_url,omitempty"` // 错误码说明,仅错误响应包含
+}
+
+// ResponseV2 v2 的响应结构,成功时只包含 data,失败时只包含 error
+type ResponseV2 struct {
+	Data  any      `json:"data,omitempty"`
+	Error *ErrorV2 `json:"error,omitempty"`
+}
+
+// ErrorV2 v2 的错误对象
+type ErrorV2 struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	DocURL  string `json:"doc_url"`
+	Details any    `json:"details,omitempty"` // 错误附带的数据,如冲突时资源的当前状态
+}
+
+// ListV2 v2 中列表数据的包装,之后为列表增加分页字段不需要改变响应结构
+type ListV2 struct {
+	Items any `json:"items"`
+}
+
+// SetAPIVersion 记录请求使用的 API 版本,由路由分组的中间件设置
+func SetAPIVersion(c *gin.Context, version int) {
+	c.Set(apiVersionKey, version)
+}
+
+// APIVersion 返回请求使用的 API 版本,未设置时为 v1
+func APIVersion(c *gin.Context) int {
+	if version, ok := c.Get(apiVersionKey); ok {
+		if v, ok := version.(int); ok {
+			return v
+		}
+	}
+	return APIVersion1
 }
 
 // JSONResponse 发送标准 JSON 响应
 func JSONResponse(c *gin.Context, httpStatus int, code int, message string, data any) {
-	c.JSON(httpStatus, Response{
+	if version := APIVersion(c); version >= APIVersion2 {
+		c.JSON(httpStatus, adaptV2(version, code, message, data))
+		return
+	}
+
+	resp := Response{
 		Code:    code,
 		Message: message,
 		Data:    data,
-	})
+	}
+	if code != xerr.SuccessCode {
+		resp.DocURL = ErrorDocPath + strconv.Itoa(code)
+	}
+	c.JSON(httpStatus, resp)
 }
 
 // Success 成功响应
 func Success(c *gin.Context, httpStatus int, message string, data any) {
-	JSONResponse(c, httpStatus, 20000, message, data) // 20000 表示业务成功码
+	JSONResponse(c, httpStatus, xerr.SuccessCode, message, data)
 }
 
 // Error 错误响应
@@ -28,8 +91,65 @@ func Error(c *gin.Context, httpStatus int, code int, message string) {
 	JSONResponse(c, httpStatus, code, message, nil)
 }
 
+// ErrorCode 使用错误码目录中的默认说明作为错误消息,避免把服务层的错误链(可能包含内部细节)返回给客户端
+func ErrorCode(c *gin.Context, httpStatus int, code int) {
+	Error(c, httpStatus, code, codeMessage(code))
+}
+
+// ErrorCodeWithData 使用错误码目录中的默认说明,并附带数据,如冲突时资源的当前状态
+func ErrorCodeWithData(c *gin.Context, httpStatus int, code int, data any) {
+	JSONResponse(c, httpStatus, code, codeMessage(code), data)
+}
+
+// FromError 按 xerr 错误码目录把服务层错误映射为响应,无法识别的错误按 500 处理并返回 fallbackMessage
+func FromError(c *gin.Context, err error, fallbackMessage string) {
+	entry, ok := xerr.FromError(err)
+	if !ok {
+		Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, fallbackMessage)
+		return
+	}
+	if entry.HTTPStatus >= http.StatusInternalServerError {
+		Error(c, entry.HTTPStatus, entry.Code, fallbackMessage)
+		return
+	}
+	Error(c, entry.HTTPStatus, entry.Code, entry.Message)
+}
+
 // AbortWithError 终止请求并发送错误响应
 func AbortWithError(c *gin.Context, httpStatus int, code int, message string) {
 	Error(c, httpStatus, code, message)
 	c.Abort() // 终止后续的 HandlerFunc
 }
+
+// AbortWithErrorCode 终止请求并使用错误码目录中的默认说明
+func AbortWithErrorCode(c *gin.Context, httpStatus int, code int) {
+	AbortWithError(c, httpStatus, code, codeMessage(code))
+}
+
+// adaptV2 把 v1 的响应参数转换为 v2 的响应结构
+func adaptV2(version int, code int, message string, data any) ResponseV2 {
+	if code != xerr.SuccessCode {
+		return ResponseV2{Error: &ErrorV2{
+			Code:    code,
+			Message: message,
+			DocURL:  "/api/v" + strconv.Itoa(version) + "/errors/" + strconv.Itoa(code),
+			Details: data,
+		}}
+	}
+	if data != nil {
+		if v := reflect.ValueOf(data); v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+			if v.IsNil() {
+				data = []any{}
+			}
+			return ResponseV2{Data: ListV2{Items: data}}
+		}
+	}
+	return ResponseV2{Data: data}
+}
+
+func codeMessage(code int) string {
+	if entry, ok := xerr.Lookup(code); ok {
+		return entry.Message
+	}
+	return http.StatusText(http.StatusInternalServerError)
+}
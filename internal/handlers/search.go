@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type SearchHandler struct {
+	searchService explorer.SearchService
+}
+
+func NewSearchHandler(searchService explorer.SearchService) *SearchHandler {
+	return &SearchHandler{
+		searchService: searchService,
+	}
+}
+
+// @Summary 搜索文件
+// @Description 在当前用户自己的文件中搜索。in=name(默认)按文件名包含关键字搜索;in=content 按文本、docx 和 PDF 文件的内容以及文件和文件夹的自定义属性搜索,需要开启 search.enabled,
+// @Description 内容在上传后异步提取,提取完成前或提取失败的文件只能按文件名搜索到,结果中的 snippet 为命中内容的片段
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param q query string true "关键字"
+// @Param in query string false "搜索范围: name 或 content" default(name)
+// @Param status query string false "删除状态 active/trashed/all" default(active)
+// @Param min_size query int false "文件大小下限(字节,含),设置大小范围时不返回文件夹"
+// @Param max_size query int false "文件大小上限(字节,含)"
+// @Param mime_prefix query string false "内容类型前缀,如 image/"
+// @Param modified_after query string false "只返回在该时间之后修改过的文件,RFC3339 格式"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Success 200 {object} xerr.Response{data=object{items=[]models.FileSearchResult,total=int}} "搜索结果"
+// @Failure 400 {object} xerr.Response "参数错误或未开启内容搜索"
+// @Router /api/v1/files/search [get]
+func (h *SearchHandler) SearchFiles(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	filter, ok := parseFileFilter(c)
+	if !ok {
+		return
+	}
+	opts := models.FileSearchOptions{
+		Query:    c.Query("q"),
+		In:       c.DefaultQuery("in", models.SearchInName),
+		Filter:   filter,
+		Page:     page,
+		PageSize: pageSize,
+	}
+
+	results, total, err := h.searchService.SearchFiles(c.Request.Context(), currentUserID, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrInvalidParams):
+			response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "q is required, in must be name or content, status must be active/trashed/all and min_size must not exceed max_size")
+		case errors.Is(err, xerr.ErrContentSearchDisabled):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.ContentSearchDisabledCode)
+		default:
+			logger.Error("SearchFiles: Failed to search files", zap.Uint64("userID", currentUserID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to search files")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Files searched successfully", gin.H{
+		"items": results,
+		"total": total,
+	})
+}
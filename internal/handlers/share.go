@@ -4,29 +4,39 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
+	"path"
 	"strconv"
+	"strings"
 
 	"github.com/3Eeeecho/go-clouddisk/internal/config"
 	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/metrics"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/admin"
 	"github.com/3Eeeecho/go-clouddisk/internal/services/share"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
 type ShareHandler struct {
-	shareService share.ShareService
-	cfg          *config.Config
+	shareService     share.ShareService
+	bandwidthService admin.BandwidthService
+	analyticsService share.ShareAnalyticsService
+	cfg              *config.Config
 }
 
-func NewShareHandler(shareService share.ShareService, cfg *config.Config) *ShareHandler {
+func NewShareHandler(shareService share.ShareService, bandwidthService admin.BandwidthService, analyticsService share.ShareAnalyticsService, cfg *config.Config) *ShareHandler {
 	return &ShareHandler{
-		shareService: shareService,
-		cfg:          cfg,
+		shareService:     shareService,
+		bandwidthService: bandwidthService,
+		analyticsService: analyticsService,
+		cfg:              cfg,
 	}
 }
 
@@ -34,6 +44,10 @@ type CreateShareRequest struct {
 	FileID           uint64  `json:"file_id" binding:"required"`
 	Password         *string `json:"password"`
 	ExpiresInMinutes *int    `json:"expires_in_minutes"` // 以分钟为单位
+	Direct           bool    `json:"direct"`             // 是否创建直链,直链可无需认证直接嵌入网页
+	// Recipient 接收者的用户名或邮箱,填写时创建只有该用户可以访问的站内分享,不能设置密码和直链
+	Recipient  string `json:"recipient"`
+	Permission string `json:"permission" binding:"omitempty,oneof=read download"` // 站内分享的权限,默认为 read
 }
 
 type ShareCheckPasswordRequest struct {
@@ -42,14 +56,14 @@ type ShareCheckPasswordRequest struct {
 
 // CreateShare handles creation of a new share link.
 // @Summary 创建分享链接
-// @Description 为指定文件或文件夹创建可分享链接，可设置密码和有效期
+// @Description 为指定文件或文件夹创建可分享链接，可设置密码和有效期。填写 recipient 时创建站内分享，只有接收者登录后可以在“收到的分享”中访问
 // @Tags 分享
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param request body CreateShareRequest true "分享链接信息"
 // @Success 200 {object} xerr.Response "分享链接创建成功"
-// @Failure 400 {object} xerr.Response "请求参数无效"
+// @Failure 400 {object} xerr.Response "请求参数无效或接收者无效"
 // @Failure 403 {object} xerr.Response "无权操作或文件状态异常"
 // @Failure 404 {object} xerr.Response "文件未找到"
 // @Failure 409 {object} xerr.Response "文件已存在有效分享链接"
@@ -57,7 +71,7 @@ type ShareCheckPasswordRequest struct {
 func (h *ShareHandler) CreateShare(c *gin.Context) {
 	var req CreateShareRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "请求参数解析失败: "+err.Error())
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
 		return
 	}
 
@@ -66,16 +80,23 @@ func (h *ShareHandler) CreateShare(c *gin.Context) {
 		return
 	}
 
-	share, err := h.shareService.CreateShare(c.Request.Context(), userID, req.FileID, req.Password, req.ExpiresInMinutes)
+	if req.Recipient != "" {
+		h.createInternalShare(c, userID, &req)
+		return
+	}
+
+	share, err := h.shareService.CreateShare(c.Request.Context(), userID, req.FileID, req.Password, req.ExpiresInMinutes, req.Direct)
 	if err != nil {
-		if errors.Is(err, xerr.ErrFileNotFound) {
-			response.Error(c, http.StatusNotFound, xerr.FileNotFoundCode, err.Error())
+		if errors.Is(err, xerr.ErrInvalidParams) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+		} else if errors.Is(err, xerr.ErrFileNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
 		} else if errors.Is(err, xerr.ErrPermissionDenied) {
-			response.Error(c, http.StatusForbidden, xerr.PermissionDeniedCode, err.Error())
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
 		} else if errors.Is(err, xerr.ErrFileStatusInvalid) {
-			response.Error(c, http.StatusBadRequest, xerr.FileStatusInvalidCode, err.Error())
+			response.ErrorCode(c, http.StatusBadRequest, xerr.FileStatusInvalidCode)
 		} else if errors.Is(err, xerr.ErrShareAlreadyExists) {
-			response.Error(c, http.StatusConflict, xerr.ShareAlreadyExistsCode, err.Error())
+			response.ErrorCode(c, http.StatusConflict, xerr.ShareAlreadyExistsCode)
 		} else {
 			logger.Error("CreateShare: 创建分享链接失败", zap.Error(err))
 			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "创建分享链接失败")
@@ -84,9 +105,44 @@ func (h *ShareHandler) CreateShare(c *gin.Context) {
 	}
 
 	shareURL := fmt.Sprintf("%s/share/%s", h.cfg.Storage.LocalBasePath, share.UUID)
-	response.Success(c, http.StatusOK, "分享链接创建成功", gin.H{
+	data := gin.H{
 		"share":     share,
 		"share_url": shareURL,
+	}
+	if share.Direct {
+		data["direct_url"] = fmt.Sprintf("%s/share/%s/raw", h.cfg.Storage.LocalBasePath, share.UUID)
+	}
+	response.Success(c, http.StatusOK, "分享链接创建成功", data)
+}
+
+// createInternalShare 创建站内分享,站内分享不生成公开链接
+func (h *ShareHandler) createInternalShare(c *gin.Context, userID uint64, req *CreateShareRequest) {
+	if req.Direct || (req.Password != nil && *req.Password != "") {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "站内分享不能设置密码或直链")
+		return
+	}
+
+	share, err := h.shareService.CreateInternalShare(c.Request.Context(), userID, req.FileID, req.Recipient, req.Permission, req.ExpiresInMinutes)
+	if err != nil {
+		if errors.Is(err, xerr.ErrInvalidParams) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+		} else if errors.Is(err, xerr.ErrFileNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+		} else if errors.Is(err, xerr.ErrPermissionDenied) {
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		} else if errors.Is(err, xerr.ErrFileStatusInvalid) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.FileStatusInvalidCode)
+		} else if errors.Is(err, xerr.ErrShareAlreadyExists) {
+			response.ErrorCode(c, http.StatusConflict, xerr.ShareAlreadyExistsCode)
+		} else {
+			logger.Error("CreateShare: 创建站内分享失败", zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "创建站内分享失败")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "站内分享创建成功", gin.H{
+		"share": share,
 	})
 }
 
@@ -115,9 +171,13 @@ func (h *ShareHandler) GetShareDetails(c *gin.Context) {
 	share, err := h.shareService.GetShareByUUID(c.Request.Context(), shareUUID, providedPassword)
 	if err != nil {
 		if errors.Is(err, xerr.ErrShareNotFound) {
-			response.Error(c, http.StatusNotFound, xerr.ShareNotFoundCode, err.Error())
+			response.ErrorCode(c, http.StatusNotFound, xerr.ShareNotFoundCode)
 		} else if errors.Is(err, xerr.ErrSharePasswordRequired) {
-			response.Error(c, http.StatusForbidden, xerr.SharePasswordRequiredCode, err.Error())
+			response.ErrorCode(c, http.StatusForbidden, xerr.SharePasswordRequiredCode)
+		} else if errors.Is(err, xerr.ErrSharePasswordIncorrect) {
+			response.ErrorCode(c, http.StatusForbidden, xerr.SharePasswordIncorrectCode)
+		} else if errors.Is(err, xerr.ErrSharePasswordLocked) {
+			response.ErrorCode(c, http.StatusTooManyRequests, xerr.SharePasswordLockedCode)
 		} else {
 			logger.Error("GetShareDetails: 获取分享详情失败", zap.String("uuid", shareUUID), zap.Error(err))
 			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "获取分享详情失败")
@@ -125,6 +185,7 @@ func (h *ShareHandler) GetShareDetails(c *gin.Context) {
 		return
 	}
 
+	h.recordAccess(c, share.ID, models.ShareAccessView)
 	share.Password = nil
 	response.Success(c, http.StatusOK, "获取链接详情成功", gin.H{
 		"share": share,
@@ -142,6 +203,7 @@ func (h *ShareHandler) GetShareDetails(c *gin.Context) {
 // @Success 200 {object} map[string]string "密码验证成功"
 // @Failure 403 {object} xerr.Response "密码不正确或链接已过期"
 // @Failure 404 {object} xerr.Response "分享链接不存在或已失效"
+// @Failure 429 {object} xerr.Response "密码错误次数过多,分享暂时锁定"
 // @Router /share/{share_uuid}/verify [post]
 func (h *ShareHandler) VerifySharePassword(c *gin.Context) {
 	shareUUID := c.Param("share_uuid")
@@ -152,16 +214,20 @@ func (h *ShareHandler) VerifySharePassword(c *gin.Context) {
 
 	var req ShareCheckPasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "请求参数解析失败: "+err.Error())
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
 		return
 	}
 
 	_, err := h.shareService.GetShareByUUID(c.Request.Context(), shareUUID, req.Password)
 	if err != nil {
 		if errors.Is(err, xerr.ErrShareNotFound) {
-			response.Error(c, http.StatusNotFound, xerr.ShareNotFoundCode, err.Error())
+			response.ErrorCode(c, http.StatusNotFound, xerr.ShareNotFoundCode)
+		} else if errors.Is(err, xerr.ErrSharePasswordRequired) {
+			response.ErrorCode(c, http.StatusForbidden, xerr.SharePasswordRequiredCode)
 		} else if errors.Is(err, xerr.ErrSharePasswordIncorrect) {
-			response.Error(c, http.StatusForbidden, xerr.SharePasswordIncorrectCode, err.Error())
+			response.ErrorCode(c, http.StatusForbidden, xerr.SharePasswordIncorrectCode)
+		} else if errors.Is(err, xerr.ErrSharePasswordLocked) {
+			response.ErrorCode(c, http.StatusTooManyRequests, xerr.SharePasswordLockedCode)
 		} else {
 			logger.Error("VerifySharePassword: 验证分享密码失败", zap.String("uuid", shareUUID), zap.Error(err))
 			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "验证分享密码失败")
@@ -178,6 +244,7 @@ func (h *ShareHandler) VerifySharePassword(c *gin.Context) {
 // @Produce octet-stream
 // @Param share_uuid path string true "分享链接 UUID"
 // @Param password query string false "分享密码（如果需要）"
+// @Param inline query bool false "在浏览器中打开单个文件,只对 PDF、图片、音频、视频和纯文本生效" default(false)
 // @Success 200 {file} file "文件/文件夹下载成功"
 // @Failure 403 {object} xerr.Response "分享链接需要密码或密码不正确"
 // @Failure 404 {object} xerr.Response "分享链接不存在或已失效"
@@ -198,11 +265,13 @@ func (h *ShareHandler) DownloadSharedContent(c *gin.Context) {
 	share, err := h.shareService.GetShareByUUID(c.Request.Context(), shareUUID, providedPassword)
 	if err != nil {
 		if errors.Is(err, xerr.ErrShareNotFound) {
-			response.Error(c, http.StatusNotFound, xerr.ShareNotFoundCode, err.Error())
+			response.ErrorCode(c, http.StatusNotFound, xerr.ShareNotFoundCode)
 		} else if errors.Is(err, xerr.ErrSharePasswordRequired) {
-			response.Error(c, http.StatusForbidden, xerr.SharePasswordRequiredCode, err.Error())
+			response.ErrorCode(c, http.StatusForbidden, xerr.SharePasswordRequiredCode)
 		} else if errors.Is(err, xerr.ErrSharePasswordIncorrect) {
-			response.Error(c, http.StatusForbidden, xerr.SharePasswordIncorrectCode, err.Error())
+			response.ErrorCode(c, http.StatusForbidden, xerr.SharePasswordIncorrectCode)
+		} else if errors.Is(err, xerr.ErrSharePasswordLocked) {
+			response.ErrorCode(c, http.StatusTooManyRequests, xerr.SharePasswordLockedCode)
 		} else {
 			logger.Error("DownloadSharedContent: 验证分享链接失败", zap.String("uuid", shareUUID), zap.Error(err))
 			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "下载分享内容失败")
@@ -214,6 +283,9 @@ func (h *ShareHandler) DownloadSharedContent(c *gin.Context) {
 	if share.File.IsFolder == 1 {
 		reader, err := h.shareService.GetSharedFolderContent(c.Request.Context(), share)
 		if err != nil {
+			if handleFileError(c, err) {
+				return
+			}
 			logger.Error("DownloadSharedContent: 打包分享文件夹内容失败", zap.String("uuid", shareUUID), zap.Error(err))
 			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "打包分享文件夹内容失败")
 			return
@@ -226,25 +298,156 @@ func (h *ShareHandler) DownloadSharedContent(c *gin.Context) {
 
 		c.Header("Content-Disposition", contentDisposition)
 		c.Header("Content-Type", "application/zip")
+		h.recordAccess(c, share.ID, models.ShareAccessDownload)
 
-		_, err = io.Copy(c.Writer, reader)
+		// 分享的流量计入分享者的限速
+		writer := h.bandwidthService.Throttle(c.Request.Context(), c.Writer, share.UserID, share)
+		written, err := io.Copy(writer, reader)
+		metrics.AddTransferBytes(metrics.DirectionDownload, written)
 		if err != nil {
 			logger.Error("DownloadSharedContent: 流式传输文件夹ZIP内容失败", zap.String("uuid", shareUUID), zap.Error(err))
 		}
 		return
 	}
 
-	// 如果是单个文件，则生成预签名URL并重定向
-	presignedURL, err := h.shareService.GetSharedFilePresignedURL(c.Request.Context(), share)
+	// 如果是单个文件，客户端缓存仍有效时返回 304，否则生成预签名URL并重定向
+	if checkNotModified(c, share.File) {
+		return
+	}
+	inline, _ := strconv.ParseBool(c.DefaultQuery("inline", "false"))
+
+	// 预签名链接直接访问对象存储，无法限速，设置了限速时由服务端输出文件内容
+	if h.bandwidthService.Limited(c.Request.Context(), share.UserID, share) {
+		reader, err := h.shareService.GetSharedFileContent(c.Request.Context(), share)
+		if err != nil {
+			if handleFileError(c, err) {
+				return
+			}
+			logger.Error("DownloadSharedContent: 获取文件内容失败", zap.String("uuid", shareUUID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.StorageErrorCode, "获取文件内容失败")
+			return
+		}
+		defer reader.Close()
+
+		h.recordAccess(c, share.ID, models.ShareAccessDownload)
+		writer := h.bandwidthService.Throttle(c.Request.Context(), c.Writer, share.UserID, share)
+		written, err := writeFileContent(c, share.File, reader, writer, inline)
+		metrics.AddTransferBytes(metrics.DirectionDownload, written)
+		if err != nil {
+			logger.Error("DownloadSharedContent: 传输文件内容失败", zap.String("uuid", shareUUID), zap.Error(err))
+		}
+		return
+	}
+	presignedURL, err := h.shareService.GetSharedFilePresignedURL(c.Request.Context(), share, inline)
 	if err != nil {
+		if handleFileError(c, err) {
+			return
+		}
 		logger.Error("DownloadSharedContent: 生成预签名URL失败", zap.String("uuid", shareUUID), zap.Error(err))
 		response.Error(c, http.StatusInternalServerError, xerr.StorageErrorCode, "获取文件下载链接失败")
 		return
 	}
 
+	h.recordAccess(c, share.ID, models.ShareAccessDownload)
 	c.Redirect(http.StatusFound, presignedURL)
 }
 
+// ServeDirectShare handles serving the content of a direct-link share.
+// @Summary 直链访问分享文件
+// @Description 无需认证直接返回直链分享的文件内容，带正确的 Content-Type，可用于在网页中嵌入图片或视频
+// @Tags 分享
+// @Produce octet-stream
+// @Param share_uuid path string true "分享链接 UUID"
+// @Success 200 {file} file "文件内容"
+// @Failure 403 {object} xerr.Response "来源页面不允许引用"
+// @Failure 404 {object} xerr.Response "分享链接不存在或已失效"
+// @Router /share/{share_uuid}/raw [get]
+func (h *ShareHandler) ServeDirectShare(c *gin.Context) {
+	shareUUID := c.Param("share_uuid")
+	if shareUUID == "" {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "分享UUID不能为空")
+		return
+	}
+
+	directCfg := h.cfg.Share.DirectLink
+	if !refererAllowed(c.Request.Referer(), directCfg) {
+		response.ErrorCode(c, http.StatusForbidden, xerr.ShareRefererDeniedCode)
+		return
+	}
+
+	share, err := h.shareService.GetDirectShare(c.Request.Context(), shareUUID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrShareNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.ShareNotFoundCode)
+		} else {
+			logger.Error("ServeDirectShare: 获取直链分享失败", zap.String("uuid", shareUUID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "获取直链分享失败")
+		}
+		return
+	}
+
+	if checkNotModified(c, share.File) {
+		return
+	}
+
+	reader, err := h.shareService.GetSharedFileContent(c.Request.Context(), share)
+	if err != nil {
+		if handleFileError(c, err) {
+			return
+		}
+		logger.Error("ServeDirectShare: 获取文件内容失败", zap.String("uuid", shareUUID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.StorageErrorCode, "获取文件内容失败")
+		return
+	}
+	defer reader.Close()
+
+	contentType := "application/octet-stream"
+	if share.File.MimeType != nil && *share.File.MimeType != "" {
+		contentType = *share.File.MimeType
+	} else if byExt := mime.TypeByExtension(path.Ext(share.File.FileName)); byExt != "" {
+		contentType = byExt
+	}
+
+	// 直链用于嵌入网页,只有白名单内的类型以 inline 返回,HTML 等类型作为附件下载
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Length", strconv.FormatUint(share.File.Size, 10))
+	c.Header("Content-Disposition", utils.ContentDisposition(share.File.FileName, contentType, true))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+	h.recordAccess(c, share.ID, models.ShareAccessDownload)
+
+	writer := h.bandwidthService.Throttle(c.Request.Context(), c.Writer, share.UserID, share)
+	writer = utils.NewThrottledWriter(c.Request.Context(), writer, directCfg.BandwidthLimit)
+	written, err := io.Copy(writer, reader)
+	metrics.AddTransferBytes(metrics.DirectionDownload, written)
+	if err != nil {
+		logger.Error("ServeDirectShare: 传输直链文件内容失败", zap.String("uuid", shareUUID), zap.Error(err))
+	}
+}
+
+// refererAllowed 检查请求来源是否在直链允许的域名列表中
+func refererAllowed(referer string, cfg config.DirectLinkConfig) bool {
+	if len(cfg.AllowedReferers) == 0 {
+		return true
+	}
+	if referer == "" {
+		return cfg.AllowEmptyReferer
+	}
+
+	refURL, err := url.Parse(referer)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(refURL.Hostname())
+	for _, allowed := range cfg.AllowedReferers {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
 // ListUserShares handles listing all share links created by the authenticated user.
 // @Summary 列出用户创建的分享链接
 // @Description 列出当前用户创建的所有有效分享链接
@@ -273,7 +476,7 @@ func (h *ShareHandler) ListUserShares(c *gin.Context) {
 		return
 	}
 
-	shares, total, err := h.shareService.ListUserShares(userID, page, pageSize)
+	shares, total, err := h.shareService.ListUserShares(c.Request.Context(), userID, page, pageSize)
 	if err != nil {
 		logger.Error("ListUserShares: 获取用户分享列表失败", zap.Uint64("userID", userID), zap.Error(err))
 		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "获取分享列表失败")
@@ -308,12 +511,12 @@ func (h *ShareHandler) RevokeShare(c *gin.Context) {
 		return
 	}
 
-	err = h.shareService.RevokeShare(userID, shareID)
+	err = h.shareService.RevokeShare(c.Request.Context(), userID, shareID)
 	if err != nil {
 		if errors.Is(err, xerr.ErrShareNotFound) {
-			response.Error(c, http.StatusNotFound, xerr.ShareNotFoundCode, err.Error())
+			response.ErrorCode(c, http.StatusNotFound, xerr.ShareNotFoundCode)
 		} else if errors.Is(err, xerr.ErrPermissionDenied) {
-			response.Error(c, http.StatusForbidden, xerr.PermissionDeniedCode, err.Error())
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
 		} else {
 			logger.Error("RevokeShare: 撤销分享链接失败", zap.Uint64("shareID", shareID), zap.Error(err))
 			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "撤销分享链接失败")
@@ -323,3 +526,271 @@ func (h *ShareHandler) RevokeShare(c *gin.Context) {
 
 	c.Status(http.StatusNoContent)
 }
+
+// RotateShare handles regenerating the UUID of a share link.
+// @Summary 重新生成分享链接
+// @Description 为分享生成新的链接地址，旧链接立即失效，密码、有效期等设置保持不变
+// @Tags 分享
+// @Produce json
+// @Security BearerAuth
+// @Param share_id path int true "分享链接 ID"
+// @Success 200 {object} xerr.Response "分享链接已重新生成"
+// @Failure 403 {object} xerr.Response "无权操作"
+// @Failure 404 {object} xerr.Response "分享链接不存在或已失效"
+// @Router /api/v1/shares/{share_id}/rotate [post]
+func (h *ShareHandler) RotateShare(c *gin.Context) {
+	shareID, err := strconv.ParseUint(c.Param("share_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "分享ID格式无效")
+		return
+	}
+
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	rotated, err := h.shareService.RotateShare(c.Request.Context(), userID, shareID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrShareNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.ShareNotFoundCode)
+		} else if errors.Is(err, xerr.ErrPermissionDenied) {
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		} else {
+			logger.Error("RotateShare: 重新生成分享链接失败", zap.Uint64("shareID", shareID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "重新生成分享链接失败")
+		}
+		return
+	}
+
+	data := gin.H{
+		"share":     rotated,
+		"share_url": fmt.Sprintf("%s/share/%s", h.cfg.Storage.LocalBasePath, rotated.UUID),
+	}
+	if rotated.Direct {
+		data["direct_url"] = fmt.Sprintf("%s/share/%s/raw", h.cfg.Storage.LocalBasePath, rotated.UUID)
+	}
+	response.Success(c, http.StatusOK, "分享链接已重新生成", data)
+}
+
+// GetShareAnalytics handles retrieving access statistics of a share link.
+// @Summary 获取分享访问统计
+// @Description 获取分享链接最近若干天每天的查看和下载次数，以及访问最多的来源域名，只有分享者可以查看
+// @Tags 分享
+// @Produce json
+// @Security BearerAuth
+// @Param share_id path int true "分享链接 ID"
+// @Param days query int false "统计天数，默认为30，最多90" default(30)
+// @Success 200 {object} xerr.Response "分享访问统计"
+// @Failure 403 {object} xerr.Response "无权查看"
+// @Failure 404 {object} xerr.Response "分享链接不存在或已撤销"
+// @Router /api/v1/shares/{share_id}/analytics [get]
+func (h *ShareHandler) GetShareAnalytics(c *gin.Context) {
+	shareID, err := strconv.ParseUint(c.Param("share_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "分享ID格式无效")
+		return
+	}
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "统计天数无效")
+		return
+	}
+
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	analytics, err := h.analyticsService.GetAnalytics(c.Request.Context(), userID, shareID, days)
+	if err != nil {
+		if errors.Is(err, xerr.ErrShareNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.ShareNotFoundCode)
+		} else if errors.Is(err, xerr.ErrPermissionDenied) {
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		} else {
+			logger.Error("GetShareAnalytics: 获取分享访问统计失败", zap.Uint64("shareID", shareID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "获取分享访问统计失败")
+		}
+		return
+	}
+	response.Success(c, http.StatusOK, "获取分享访问统计成功", gin.H{
+		"analytics": analytics,
+	})
+}
+
+// ListReceivedShares handles listing internal shares received by the authenticated user.
+// @Summary 列出收到的站内分享
+// @Description 列出其他用户分享给当前用户且仍然有效的站内分享
+// @Tags 分享
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "页码，默认为1" default(1)
+// @Param pageSize query int false "每页数量，默认为10" default(10)
+// @Success 200 {object} xerr.Response "收到的分享列表"
+// @Router /api/v1/shares/received [get]
+func (h *ShareHandler) ListReceivedShares(c *gin.Context) {
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	page, pageSize := parsePagination(c)
+	shares, total, err := h.shareService.ListReceivedShares(c.Request.Context(), userID, page, pageSize)
+	if err != nil {
+		logger.Error("ListReceivedShares: 获取收到的分享失败", zap.Uint64("userID", userID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "获取收到的分享失败")
+		return
+	}
+	response.Success(c, http.StatusOK, "成功获取收到的分享", gin.H{
+		"shares": shares,
+		"total":  total,
+	})
+}
+
+// ListReceivedShareFiles handles browsing a folder in a received internal share.
+// @Summary 浏览收到的分享文件夹
+// @Description 列出站内分享的文件夹内容，parent_id 为分享文件夹中的子文件夹，不传时列出分享的文件夹本身
+// @Tags 分享
+// @Produce json
+// @Security BearerAuth
+// @Param share_id path int true "分享 ID"
+// @Param parent_id query int false "分享文件夹中的子文件夹ID"
+// @Param page query int false "页码，默认为1" default(1)
+// @Param pageSize query int false "每页数量，默认为10" default(10)
+// @Success 200 {object} xerr.Response "文件列表"
+// @Failure 400 {object} xerr.Response "分享的不是文件夹"
+// @Failure 404 {object} xerr.Response "分享或文件不存在"
+// @Router /api/v1/shares/received/{share_id}/files [get]
+func (h *ShareHandler) ListReceivedShareFiles(c *gin.Context) {
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	shareID, err := strconv.ParseUint(c.Param("share_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "分享ID格式无效")
+		return
+	}
+	parentID, ok := parseOptionalID(c, "parent_id")
+	if !ok {
+		return
+	}
+
+	page, pageSize := parsePagination(c)
+	files, total, err := h.shareService.ListReceivedShareFiles(c.Request.Context(), userID, shareID, parentID, page, pageSize)
+	if err != nil {
+		if !handleReceivedShareError(c, err) {
+			logger.Error("ListReceivedShareFiles: 获取分享文件夹内容失败", zap.Uint64("shareID", shareID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "获取分享文件夹内容失败")
+		}
+		return
+	}
+	response.Success(c, http.StatusOK, "成功获取分享文件夹内容", gin.H{
+		"files": files,
+		"total": total,
+	})
+}
+
+// DownloadReceivedShare handles downloading content of a received internal share.
+// @Summary 下载收到的分享
+// @Description 需要分享的下载权限。文件返回预签名下载链接，文件夹打包为 ZIP 返回。file_id 为分享文件夹中的文件，不传时下载分享本身
+// @Tags 分享
+// @Produce json
+// @Produce application/zip
+// @Security BearerAuth
+// @Param share_id path int true "分享 ID"
+// @Param file_id query int false "分享文件夹中的文件ID"
+// @Param inline query bool false "在浏览器中打开,只对 PDF、图片、音频、视频和纯文本生效" default(false)
+// @Success 200 {object} xerr.Response "下载链接或 ZIP 内容"
+// @Failure 403 {object} xerr.Response "分享只有查看权限"
+// @Failure 404 {object} xerr.Response "分享或文件不存在"
+// @Router /api/v1/shares/received/{share_id}/download [get]
+func (h *ShareHandler) DownloadReceivedShare(c *gin.Context) {
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	shareID, err := strconv.ParseUint(c.Param("share_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "分享ID格式无效")
+		return
+	}
+	fileID, ok := parseOptionalID(c, "file_id")
+	if !ok {
+		return
+	}
+
+	inline, _ := strconv.ParseBool(c.DefaultQuery("inline", "false"))
+	share, file, presignedURL, reader, err := h.shareService.GetReceivedDownload(c.Request.Context(), userID, shareID, fileID, inline)
+	if err != nil {
+		if !handleReceivedShareError(c, err) {
+			logger.Error("DownloadReceivedShare: 下载分享内容失败", zap.Uint64("shareID", shareID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "下载分享内容失败")
+		}
+		return
+	}
+
+	if reader == nil {
+		h.recordAccess(c, share.ID, models.ShareAccessDownload)
+		response.Success(c, http.StatusOK, "成功获取下载链接", gin.H{
+			"url": presignedURL,
+		})
+		return
+	}
+	defer reader.Close()
+
+	encodedFileName := url.PathEscape(file.FileName + ".zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, encodedFileName, encodedFileName))
+	c.Header("Content-Type", "application/zip")
+	h.recordAccess(c, share.ID, models.ShareAccessDownload)
+
+	writer := h.bandwidthService.Throttle(c.Request.Context(), c.Writer, share.UserID, share)
+	written, err := io.Copy(writer, reader)
+	metrics.AddTransferBytes(metrics.DirectionDownload, written)
+	if err != nil {
+		logger.Error("DownloadReceivedShare: 流式传输文件夹ZIP内容失败", zap.Uint64("shareID", shareID), zap.Error(err))
+	}
+}
+
+// parseOptionalID 解析可选的ID查询参数,格式无效时写入错误响应并返回 false
+func parseOptionalID(c *gin.Context, name string) (*uint64, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid "+name)
+		return nil, false
+	}
+	return &id, true
+}
+
+// handleReceivedShareError 处理访问站内分享时的常见错误,已写入响应时返回 true
+func handleReceivedShareError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, xerr.ErrShareNotFound):
+		response.ErrorCode(c, http.StatusNotFound, xerr.ShareNotFoundCode)
+	case errors.Is(err, xerr.ErrFileNotFound):
+		response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+	case errors.Is(err, xerr.ErrPermissionDenied):
+		response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+	case errors.Is(err, xerr.ErrTargetNotFolder):
+		response.ErrorCode(c, http.StatusBadRequest, xerr.TargetNotFolderCode)
+	case errors.Is(err, xerr.ErrFileQuarantined):
+		response.ErrorCode(c, http.StatusForbidden, xerr.FileQuarantinedCode)
+	case errors.Is(err, xerr.ErrLinkTargetMissing):
+		response.ErrorCode(c, http.StatusNotFound, xerr.LinkTargetMissingCode)
+	default:
+		return handleFileError(c, err)
+	}
+	return true
+}
+
+// recordAccess 异步记录一次分享访问,不阻塞内容传输
+func (h *ShareHandler) recordAccess(c *gin.Context, shareID uint64, action string) {
+	h.analyticsService.RecordAccess(c.Request.Context(), shareID, action, c.Request.UserAgent(), c.Request.Referer())
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/metrics"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/storage"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/admin"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SignedDownloadHandler 处理本地存储生成的签名下载链接,链接本身即是凭证,不需要登录
+type SignedDownloadHandler struct {
+	storageService   storage.StorageService
+	bandwidthService admin.BandwidthService
+	cfg              *config.LocalStorageConfig
+}
+
+func NewSignedDownloadHandler(storageService storage.StorageService, bandwidthService admin.BandwidthService, cfg *config.Config) *SignedDownloadHandler {
+	return &SignedDownloadHandler{
+		storageService:   storageService,
+		bandwidthService: bandwidthService,
+		cfg:              &cfg.Local,
+	}
+}
+
+// @Summary 通过签名链接下载文件
+// @Description 本地存储没有预签名机制,下载接口返回的链接指向这里。校验 HMAC 签名和过期时间后直接输出文件内容,支持 Range 请求
+// @Tags 文件
+// @Produce octet-stream
+// @Param token path string true "签名下载令牌"
+// @Success 200 {file} file "文件内容"
+// @Success 206 {file} file "文件的一部分"
+// @Failure 403 {object} xerr.Response "链接无效或已过期"
+// @Failure 404 {object} xerr.Response "文件不存在"
+// @Router /d/{token} [get]
+func (h *SignedDownloadHandler) Download(c *gin.Context) {
+	bucketName, objectName, versionID, opts, err := storage.ParseLocalSignedToken(h.cfg, c.Param("token"))
+	if err != nil {
+		response.ErrorCode(c, http.StatusForbidden, xerr.SignedURLInvalidCode)
+		return
+	}
+
+	object, err := h.storageService.GetObject(c.Request.Context(), bucketName, objectName, versionID)
+	if err != nil {
+		// 链接有效期内文件被永久删除
+		if errors.Is(err, os.ErrNotExist) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+			return
+		}
+		logger.Error("SignedDownload: Failed to get object", zap.String("bucket", bucketName), zap.String("object", objectName), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to download file")
+		return
+	}
+	defer object.Reader.Close()
+
+	// 令牌中记录了下载用户时,与经过服务端的下载共用同一份限速
+	if opts.UserID != 0 {
+		c.Writer = &throttledResponseWriter{
+			ResponseWriter: c.Writer,
+			w:              h.bandwidthService.ThrottleByID(c.Request.Context(), c.Writer, opts.UserID, opts.ShareID),
+		}
+	}
+
+	// 令牌中带有下载接口决定的响应头时优先使用,旧令牌按对象名作为附件下载
+	fileName := path.Base(objectName)
+	disposition := opts.ContentDisposition
+	if disposition == "" {
+		disposition = utils.ContentDisposition(fileName, "", false)
+	}
+	c.Header("Content-Disposition", disposition)
+	if versionID != "" {
+		c.Header("ETag", fmt.Sprintf("\"%s\"", versionID))
+	}
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = object.MimeType
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("X-Content-Type-Options", "nosniff")
+
+	// 本地存储返回的是文件句柄,由 ServeContent 处理 Range 和 HEAD 请求
+	if seeker, ok := object.Reader.(io.ReadSeeker); ok {
+		counter := &countingWriter{ResponseWriter: c.Writer}
+		http.ServeContent(counter, c.Request, fileName, time.Time{}, seeker)
+		metrics.AddTransferBytes(metrics.DirectionDownload, counter.written)
+		return
+	}
+	c.DataFromReader(http.StatusOK, object.Size, contentType, object.Reader, nil)
+}
+
+// throttledResponseWriter 把响应体写入限速后的 writer,其余方法沿用原来的 ResponseWriter
+type throttledResponseWriter struct {
+	gin.ResponseWriter
+	w io.Writer
+}
+
+func (w *throttledResponseWriter) Write(p []byte) (int, error) {
+	return w.w.Write(p)
+}
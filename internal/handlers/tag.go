@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type TagHandler struct {
+	tagService explorer.TagService
+}
+
+func NewTagHandler(tagService explorer.TagService) *TagHandler {
+	return &TagHandler{
+		tagService: tagService,
+	}
+}
+
+// AddTagsRequest 添加标签的请求体
+type AddTagsRequest struct {
+	Tags []string `json:"tags" binding:"required,min=1"`
+}
+
+// @Summary 添加标签
+// @Description 给文件或文件夹添加标签,标签不区分大小写,重复添加不会报错
+// @Tags 文件
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Param request body AddTagsRequest true "标签列表"
+// @Success 200 {object} xerr.Response "文件上的全部标签"
+// @Failure 400 {object} xerr.Response "标签无效或数量超过上限"
+// @Failure 403 {object} xerr.Response "无权限"
+// @Failure 404 {object} xerr.Response "文件未找到"
+// @Router /api/v1/files/{file_id}/tags [post]
+func (h *TagHandler) AddTags(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	fileID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+
+	var req AddTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+
+	tags, err := h.tagService.AddTags(c.Request.Context(), currentUserID, fileID, req.Tags)
+	if err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrTagInvalid):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.TagInvalidCode)
+		case errors.Is(err, xerr.ErrTooManyTags):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.TooManyTagsCode)
+		case errors.Is(err, xerr.ErrFileNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+		case errors.Is(err, xerr.ErrPermissionDenied):
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		case errors.Is(err, xerr.ErrFileStatusInvalid):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.FileStatusInvalidCode)
+		case handleFileError(c, err):
+		default:
+			logger.Error("AddTags: Failed to add tags", zap.Uint64("fileID", fileID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to add tags")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Tags added successfully", tags)
+}
+
+// @Summary 移除标签
+// @Description 移除文件或文件夹上的一个标签
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Param tag path string true "标签"
+// @Success 200 {object} xerr.Response "移除成功"
+// @Failure 404 {object} xerr.Response "文件上没有该标签"
+// @Router /api/v1/files/{file_id}/tags/{tag} [delete]
+func (h *TagHandler) RemoveTag(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	fileID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+
+	if err := h.tagService.RemoveTag(c.Request.Context(), currentUserID, fileID, c.Param("tag")); err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrTagInvalid):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.TagInvalidCode)
+		case errors.Is(err, xerr.ErrFileNotFound):
+			response.Error(c, http.StatusNotFound, xerr.FileNotFoundCode, "Tag not found on this file")
+		case handleFileError(c, err):
+		default:
+			logger.Error("RemoveTag: Failed to remove tag", zap.Uint64("fileID", fileID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to remove tag")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Tag removed successfully", nil)
+}
+
+// @Summary 获取文件的标签
+// @Description 获取当前用户在文件或文件夹上添加的标签
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Success 200 {object} xerr.Response "标签列表"
+// @Failure 403 {object} xerr.Response "无权限"
+// @Failure 404 {object} xerr.Response "文件未找到"
+// @Router /api/v1/files/{file_id}/tags [get]
+func (h *TagHandler) GetFileTags(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	fileID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+
+	tags, err := h.tagService.GetFileTags(c.Request.Context(), currentUserID, fileID)
+	if err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrFileNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+		case errors.Is(err, xerr.ErrPermissionDenied):
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		case errors.Is(err, xerr.ErrFileStatusInvalid):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.FileStatusInvalidCode)
+		case handleFileError(c, err):
+		default:
+			logger.Error("GetFileTags: Failed to get tags", zap.Uint64("fileID", fileID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to get tags")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Tags retrieved successfully", tags)
+}
+
+// @Summary 获取标签列表
+// @Description 获取当前用户使用过的标签和每个标签下的文件数量,按标签名排序
+// @Tags 文件
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} xerr.Response "标签列表"
+// @Router /api/v1/files/tags [get]
+func (h *TagHandler) ListTags(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	tags, err := h.tagService.ListTags(c.Request.Context(), currentUserID)
+	if err != nil {
+		if handleFileError(c, err) {
+			return
+		}
+		logger.Error("ListTags: Failed to list tags", zap.Uint64("userID", currentUserID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to list tags")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Tags listed successfully", tags)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/gin-gonic/gin"
+)
+
+type TransferHandler struct {
+	transferService explorer.TransferService
+}
+
+func NewTransferHandler(transferService explorer.TransferService) *TransferHandler {
+	return &TransferHandler{
+		transferService: transferService,
+	}
+}
+
+// TransferFileRequest 转存请求体
+type TransferFileRequest struct {
+	Username string `json:"username" binding:"required"`
+	CopyTags bool   `json:"copy_tags"` // 是否把自己的标签一并复制给接收方
+}
+
+// @Summary 发送副本给其他用户
+// @Description 将文件或文件夹的副本发送到接收方的接收文件夹,副本与原文件共享存储对象,占用接收方的空间
+// @Tags 文件
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param file_id path int true "文件ID"
+// @Param request body TransferFileRequest true "接收方用户名"
+// @Success 200 {object} xerr.Response "接收方的新文件"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 403 {object} xerr.Response "无权限或文件已隔离"
+// @Failure 404 {object} xerr.Response "文件或用户不存在"
+// @Failure 413 {object} xerr.Response "接收方空间不足"
+// @Router /api/v1/files/{file_id}/transfer [post]
+func (h *TransferHandler) TransferFile(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	fileID, err := strconv.ParseUint(c.Param("file_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid file ID format")
+		return
+	}
+
+	var req TransferFileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+
+	file, err := h.transferService.Transfer(c.Request.Context(), currentUserID, fileID, req.Username, req.CopyTags)
+	if err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrInvalidParams):
+			response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
+		case errors.Is(err, xerr.ErrFileNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.FileNotFoundCode)
+		case errors.Is(err, xerr.ErrUserNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.UserNotFoundCode)
+		case errors.Is(err, xerr.ErrPermissionDenied):
+			response.ErrorCode(c, http.StatusForbidden, xerr.PermissionDeniedCode)
+		case errors.Is(err, xerr.ErrFileQuarantined):
+			response.ErrorCode(c, http.StatusForbidden, xerr.FileQuarantinedCode)
+		case errors.Is(err, xerr.ErrQuotaExceeded):
+			response.ErrorCode(c, http.StatusRequestEntityTooLarge, xerr.QuotaExceededCode)
+		case handleFileError(c, err):
+		default:
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to transfer file")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, "File transferred successfully", file)
+}
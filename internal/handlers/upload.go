@@ -2,15 +2,16 @@ package handlers
 
 import (
 	"errors"
-	"fmt"
 	"net/http"
 
 	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
 	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
 	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 // UploadHandler 结构体持有其服务依赖
@@ -27,7 +28,7 @@ func NewUploadHandler(uploadService explorer.UploadService) *UploadHandler {
 
 // InitUploadHandler 处理上传初始化请求
 // @Summary 初始化文件上传
-// @Description 创建上传会话并返回上传参数
+// @Description 创建上传会话并返回上传参数,根据文件大小协商单次上传或分片上传及分片大小
 // @Tags 文件上传
 // @Accept json
 // @Produce json
@@ -51,12 +52,18 @@ func (h *UploadHandler) InitUploadHandler(c *gin.Context) {
 
 	resp, err := h.uploadService.UploadInit(c, currentUserID, &req)
 	if err != nil {
+		if handleFileNameError(c, err) {
+			return
+		}
 		if errors.Is(err, xerr.ErrDirectoryNotFound) {
-			response.Error(c, http.StatusBadRequest, xerr.DirectoryNotFoundCode, err.Error())
+			response.ErrorCode(c, http.StatusBadRequest, xerr.DirectoryNotFoundCode)
 			return
 		}
 		if errors.Is(err, xerr.ErrFileAlreadyExists) {
-			response.Error(c, http.StatusConflict, xerr.FileAlreadyExistsCode, err.Error())
+			response.ErrorCode(c, http.StatusConflict, xerr.FileAlreadyExistsCode)
+			return
+		}
+		if handleFileError(c, err) {
 			return
 		}
 		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to initialize upload")
@@ -65,9 +72,49 @@ func (h *UploadHandler) InitUploadHandler(c *gin.Context) {
 	response.Success(c, http.StatusOK, "Upload initialized successfully", resp)
 }
 
+// @Summary 提交秒传内容证明
+// @Description 初始化响应中带有 proofChallenge 时,计算 SHA-256(salt + 文件中 [offset, offset+length) 的内容)并提交。
+// @Description 通过后该 uploadID 转为秒传,直接调用完成接口;未通过时按初始化返回的参数继续上传。每个挑战只能提交一次
+// @Tags 文件上传
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.UploadProofRequest true "内容证明"
+// @Success 200 {object} xerr.Response{data=models.UploadInitResponse} "校验通过,可以秒传"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 404 {object} xerr.Response "挑战不存在或已使用"
+// @Failure 422 {object} xerr.Response "校验未通过"
+// @Router /api/v1/uploads/proof [post]
+func (h *UploadHandler) SubmitProofHandler(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	var req models.UploadProofRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body")
+		return
+	}
+
+	resp, err := h.uploadService.SubmitUploadProof(c.Request.Context(), currentUserID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrUploadSessionNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.UploadSessionNotFoundCode)
+		case errors.Is(err, xerr.ErrUploadProofRejected):
+			response.ErrorCode(c, http.StatusUnprocessableEntity, xerr.UploadProofRejectedCode)
+		case handleFileError(c, err):
+		default:
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to verify upload proof")
+		}
+		return
+	}
+	response.Success(c, http.StatusOK, "Upload proof accepted", resp)
+}
+
 // UploadChunkHandler 处理分片上传请求
 // @Summary 上传文件分片
-// @Description 上传文件的一个分片
+// @Description 上传文件的一个分片,除最后一片外分片大小必须等于协商的分片大小
 // @Tags 文件上传
 // @Accept multipart/form-data
 // @Produce json
@@ -78,6 +125,7 @@ func (h *UploadHandler) InitUploadHandler(c *gin.Context) {
 // @Success 200 {object} xerr.Response "分片上传成功"
 // @Failure 400 {object} xerr.Response "参数错误"
 // @Failure 404 {object} xerr.Response "上传会话未找到"
+// @Failure 415 {object} xerr.Response "文件类型不允许上传"
 // @Failure 500 {object} xerr.Response "内部服务器错误"
 // @Router /api/v1/uploads/chunk [post]
 func (h *UploadHandler) UploadChunkHandler(c *gin.Context) {
@@ -103,34 +151,88 @@ func (h *UploadHandler) UploadChunkHandler(c *gin.Context) {
 	// 从 form 中解析其他参数
 	var req models.UploadChunkRequest
 	if err := c.ShouldBind(&req); err != nil {
-		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, fmt.Sprintf("Invalid form data: %v", err))
+		logger.Warn("UploadChunk: Invalid form data", zap.Uint64("userID", currentUserID), zap.Error(err))
+		response.ErrorCode(c, http.StatusBadRequest, xerr.InvalidParamsCode)
 		return
 	}
 
 	// 调用 service 层处理块上传
 	if err := h.uploadService.UploadChunk(c, currentUserID, &req, fileContent); err != nil {
+		if handleFileNameError(c, err) {
+			return
+		}
 		if errors.Is(err, xerr.ErrUploadSessionNotFound) {
-			response.Error(c, http.StatusNotFound, xerr.UploadSessionNotFoundCode, err.Error())
+			response.ErrorCode(c, http.StatusNotFound, xerr.UploadSessionNotFoundCode)
+			return
+		}
+		if errors.Is(err, xerr.ErrChunkSizeInvalid) {
+			response.ErrorCode(c, http.StatusBadRequest, xerr.ChunkSizeInvalidCode)
+			return
+		}
+		if errors.Is(err, xerr.ErrFileTypeNotAllowed) {
+			response.ErrorCode(c, http.StatusUnsupportedMediaType, xerr.FileTypeNotAllowedCode)
+			return
+		}
+		if handleFileError(c, err) {
 			return
 		}
-		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, fmt.Sprintf("Failed to upload chunk: %v", err))
+		logger.Error("UploadChunk: Failed to upload chunk", zap.Uint64("userID", currentUserID), zap.String("uploadID", req.UploadID), zap.Error(err))
+		response.FromError(c, err, "Failed to upload chunk")
 		return
 	}
 
 	response.Success(c, http.StatusOK, "Chunk uploaded successfully", nil)
 }
 
+// AbortUploadHandler 处理取消上传请求
+// @Summary 取消文件上传
+// @Description 取消进行中的上传会话,释放已上传的分片
+// @Tags 文件上传
+// @Produce json
+// @Security BearerAuth
+// @Param upload_id path string true "上传会话ID"
+// @Success 200 {object} xerr.Response "上传已取消"
+// @Failure 404 {object} xerr.Response "上传会话未找到"
+// @Failure 500 {object} xerr.Response "内部服务器错误"
+// @Router /api/v1/uploads/{upload_id} [delete]
+func (h *UploadHandler) AbortUploadHandler(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	uploadID := c.Param("upload_id")
+	if err := h.uploadService.AbortUpload(c.Request.Context(), currentUserID, uploadID); err != nil {
+		if errors.Is(err, xerr.ErrUploadSessionNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.UploadSessionNotFoundCode)
+			return
+		}
+		if handleFileError(c, err) {
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to abort upload")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Upload aborted successfully", nil)
+}
+
 // CompleteUploadHandler 处理分片合并请求
 // @Summary 完成文件上传
-// @Description 合并所有分片完成文件上传
+// @Description 合并所有分片完成文件上传。同名文件已存在时按 uploadMode 处理: version(默认)创建新版本，rename 自动重命名，
+// @Description overwrite 替换最新版本的内容，skip 保留已有文件。响应中的 action 说明实际执行的操作。
+// @Description 合并前核对存储中的分片，有分片缺失或不一致时返回 400，data 中列出需要重新上传的分片，重新上传后再次调用即可。
+// @Description 服务端计算的 MD5 与 fileHash 不一致(提供 fileSha256 时同样比对)时拒绝完成并丢弃已上传的内容
 // @Tags 文件上传
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param request body models.UploadCompleteRequest true "上传完成参数"
-// @Success 200 {object} xerr.Response "文件上传完成"
-// @Failure 400 {object} xerr.Response "参数错误"
+// @Success 200 {object} xerr.Response{data=models.UploadCompleteResponse} "文件上传完成"
+// @Failure 400 {object} xerr.Response{data=models.UploadPartsReport} "参数错误或分片缺失"
 // @Failure 404 {object} xerr.Response "上传会话未找到"
+// @Failure 413 {object} xerr.Response "团队空间不足"
+// @Failure 415 {object} xerr.Response "文件类型不允许上传"
 // @Failure 500 {object} xerr.Response "内部服务器错误"
 // @Router /api/v1/uploads/complete [post]
 func (h *UploadHandler) CompleteUploadHandler(c *gin.Context) {
@@ -144,23 +246,47 @@ func (h *UploadHandler) CompleteUploadHandler(c *gin.Context) {
 		return
 	}
 
-	newFile, err := h.uploadService.UploadComplete(c, currentUserID, &req)
+	result, err := h.uploadService.UploadComplete(c, currentUserID, &req)
 	if err != nil {
+		if handleFileNameError(c, err) {
+			return
+		}
 		if errors.Is(err, xerr.ErrUploadSessionNotFound) {
-			response.Error(c, http.StatusNotFound, xerr.UploadSessionNotFoundCode, err.Error())
+			response.ErrorCode(c, http.StatusNotFound, xerr.UploadSessionNotFoundCode)
+			return
+		}
+		var partsErr *explorer.PartsMismatchError
+		if errors.As(err, &partsErr) {
+			response.ErrorCodeWithData(c, http.StatusBadRequest, xerr.ChunkMissingCode, partsErr.Report)
 			return
 		}
 		if errors.Is(err, xerr.ErrChunkMissing) {
-			response.Error(c, http.StatusBadRequest, xerr.ChunkMissingCode, err.Error())
+			response.ErrorCode(c, http.StatusBadRequest, xerr.ChunkMissingCode)
 			return
 		}
 		if errors.Is(err, xerr.ErrHashMismatch) {
-			response.Error(c, http.StatusBadRequest, xerr.HashMismatchCode, err.Error())
+			response.ErrorCode(c, http.StatusBadRequest, xerr.HashMismatchCode)
 			return
 		}
-		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, fmt.Sprintf("Failed to complete upload: %v", err))
+		if errors.Is(err, xerr.ErrFileTypeNotAllowed) {
+			response.ErrorCode(c, http.StatusUnsupportedMediaType, xerr.FileTypeNotAllowedCode)
+			return
+		}
+		if errors.Is(err, xerr.ErrFileLocked) {
+			response.ErrorCode(c, http.StatusConflict, xerr.FileLockedCode)
+			return
+		}
+		if handleFileError(c, err) {
+			return
+		}
+		logger.Error("CompleteUpload: Failed to complete upload", zap.Uint64("userID", currentUserID), zap.String("uploadID", req.UploadID), zap.Error(err))
+		response.FromError(c, err, "Failed to complete upload")
 		return
 	}
 
-	response.Success(c, http.StatusOK, "File uploaded and merged successfully", newFile)
+	if result.Skipped {
+		response.Success(c, http.StatusOK, "File already exists, upload skipped", result)
+		return
+	}
+	response.Success(c, http.StatusOK, "File uploaded and merged successfully", result)
 }
@@ -8,19 +8,23 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
 	"github.com/3Eeeecho/go-clouddisk/internal/services/admin"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
 )
 
 type UserHandler struct {
-	userService admin.UserService
+	userService     admin.UserService
+	deletionService explorer.AccountDeletionService
 }
 
-func NewUserHandler(userService admin.UserService) *UserHandler {
+func NewUserHandler(userService admin.UserService, deletionService explorer.AccountDeletionService) *UserHandler {
 	return &UserHandler{
-		userService: userService,
+		userService:     userService,
+		deletionService: deletionService,
 	}
 }
 
@@ -42,7 +46,7 @@ func (h *UserHandler) GetUserProfile(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.GetUserProfile(currentUserID)
+	user, err := h.userService.GetUserProfile(c.Request.Context(), currentUserID)
 	if err != nil {
 		if errors.Is(err, xerr.ErrUserNotFound) {
 			response.AbortWithError(c, http.StatusNotFound, xerr.UserNotFoundCode, "未找到用户资料")
@@ -57,3 +61,110 @@ func (h *UserHandler) GetUserProfile(c *gin.Context) {
 
 	response.Success(c, http.StatusOK, "成功获取用户资料", user)
 }
+
+// UpdateUserSettingsRequest 修改用户设置的请求体,省略的字段保持不变
+type UpdateUserSettingsRequest struct {
+	DedupOptOut        *bool `json:"dedup_opt_out"`
+	TrashRetentionDays *int  `json:"trash_retention_days"` // 0 表示恢复默认值
+}
+
+// @Summary 更新当前用户设置
+// @Description 设置是否退出跨用户秒传,退出后自己的文件不会作为其他用户的秒传来源,上传时也只与自己的文件匹配。
+// @Description 设置回收站保留天数(范围由 trash.min_retention_days 和 trash.max_retention_days 配置),超过保留期的文件被彻底删除,0 表示使用默认值
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body UpdateUserSettingsRequest true "用户设置"
+// @Success 200 {object} xerr.Response "设置成功"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 404 {object} xerr.Response "用户未找到"
+// @Router /api/v1/users/me/settings [patch]
+func (h *UserHandler) UpdateUserSettings(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateUserSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+	if req.DedupOptOut == nil && req.TrashRetentionDays == nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "At least one setting is required")
+		return
+	}
+
+	user, err := h.userService.UpdateSettings(c.Request.Context(), currentUserID, models.UserSettingsUpdate{
+		DedupOptOut:        req.DedupOptOut,
+		TrashRetentionDays: req.TrashRetentionDays,
+	})
+	if err != nil {
+		if errors.Is(err, xerr.ErrInvalidParams) {
+			response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "trash_retention_days is outside the allowed range")
+			return
+		}
+		if errors.Is(err, xerr.ErrUserNotFound) {
+			response.ErrorCode(c, http.StatusNotFound, xerr.UserNotFoundCode)
+			return
+		}
+		logger.Error("UpdateUserSettings: Failed to update user settings", zap.Uint64("userID", currentUserID), zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to update user settings")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "User settings updated", gin.H{
+		"dedup_opt_out":        user.DedupOptOut,
+		"trash_retention_days": user.TrashRetentionDays,
+	})
+}
+
+// DeleteAccountRequest 注销账号请求体,需要再次输入密码确认
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// @Summary 注销当前账号
+// @Description 校验密码后账号立即不能登录,后台依次撤销令牌和分享、彻底删除全部文件、匿名化活动日志并删除账号,完成后邮件通知。
+// @Description 组织的最后一名所有者需要先转让所有权
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body DeleteAccountRequest true "当前密码"
+// @Success 202 {object} xerr.Response "已申请注销"
+// @Failure 400 {object} xerr.Response "参数错误"
+// @Failure 401 {object} xerr.Response "密码错误"
+// @Failure 409 {object} xerr.Response "是组织的最后一名所有者"
+// @Router /api/v1/users/me [delete]
+func (h *UserHandler) DeleteAccount(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, xerr.InvalidParamsCode, "Invalid request body format")
+		return
+	}
+
+	deletion, err := h.deletionService.RequestDeletion(c.Request.Context(), currentUserID, req.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, xerr.ErrInvalidCredentials):
+			response.ErrorCode(c, http.StatusUnauthorized, xerr.InvalidCredentialsCode)
+		case errors.Is(err, xerr.ErrUserNotFound):
+			response.ErrorCode(c, http.StatusNotFound, xerr.UserNotFoundCode)
+		case errors.Is(err, xerr.ErrLastOrgOwner):
+			response.ErrorCode(c, http.StatusConflict, xerr.LastOrgOwnerCode)
+		default:
+			logger.Error("DeleteAccount: Failed to request account deletion", zap.Uint64("userID", currentUserID), zap.Error(err))
+			response.Error(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to request account deletion")
+		}
+		return
+	}
+
+	response.Success(c, http.StatusAccepted, "Account deletion scheduled", deletion)
+}
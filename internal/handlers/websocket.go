@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/realtime"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+type WebSocketHandler struct {
+	hub      *realtime.Hub
+	upgrader websocket.Upgrader
+}
+
+func NewWebSocketHandler(hub *realtime.Hub, cfg config.WebSocketConfig) *WebSocketHandler {
+	allowed := cfg.AllowedOrigins
+	return &WebSocketHandler{
+		hub: hub,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 4096,
+			CheckOrigin: func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+				return len(allowed) == 0 || origin == "" || slices.Contains(allowed, origin)
+			},
+		},
+	}
+}
+
+// @Summary 实时事件
+// @Description 升级为 WebSocket 连接,服务端推送当前用户的文件变化(file_changed、folder_changed、path_changed)和站内通知(notification)。
+// @Description 浏览器无法设置请求头时通过 access_token 查询参数传递令牌。断线期间的事件不会补发,重连后需要刷新
+// @Tags 通知
+// @Security BearerAuth
+// @Param access_token query string false "访问令牌,没有 Authorization 头时使用"
+// @Success 101 "切换为 WebSocket 协议"
+// @Failure 401 {object} xerr.Response "未授权"
+// @Router /ws [get]
+func (h *WebSocketHandler) Connect(c *gin.Context) {
+	currentUserID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	// 升级失败时 Upgrader 已经写入了错误响应
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		logger.Warn("WebSocket: Failed to upgrade connection", zap.Uint64("userID", currentUserID), zap.Error(err))
+		return
+	}
+	if !h.hub.Serve(conn, currentUserID, c.GetString("sessionID")) {
+		logger.Warn("WebSocket: Connection rejected, too many connections", zap.Uint64("userID", currentUserID))
+	}
+}
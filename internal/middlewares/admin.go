@@ -0,0 +1,32 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/authz"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdmin 只允许管理员访问,需要挂载在 AuthMiddleware 之后
+func RequireAdmin(authorizer authz.Authorizer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := utils.GetUserIDFromContext(c)
+		if !ok {
+			return
+		}
+
+		isAdmin, err := authorizer.Can(c.Request.Context(), userID, authz.ActionAdmin, authz.System())
+		if err != nil {
+			response.AbortWithError(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to verify user role")
+			return
+		}
+		if !isAdmin {
+			response.AbortWithErrorCode(c, http.StatusForbidden, xerr.ForbiddenCode)
+			return
+		}
+		c.Next()
+	}
+}
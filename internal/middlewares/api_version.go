@@ -0,0 +1,55 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// APIVersion 记录请求使用的 API 版本,response 据此适配响应格式。
+// 版本在 cfg.Deprecations 中配置了弃用计划时,响应附带 Deprecation (RFC 9745)、Sunset (RFC 8594) 和 Link 头
+func APIVersion(version int, cfg config.APIConfig) gin.HandlerFunc {
+	name := "v" + strconv.Itoa(version)
+	headers := deprecationHeaders(name, cfg.Deprecations[name])
+
+	return func(c *gin.Context) {
+		response.SetAPIVersion(c, version)
+		c.Header("API-Version", name)
+		for key, value := range headers {
+			c.Header(key, value)
+		}
+		c.Next()
+	}
+}
+
+// deprecationHeaders 按弃用计划生成响应头,未配置或日期无效时返回 nil
+func deprecationHeaders(version string, deprecation config.APIDeprecationConfig) map[string]string {
+	if deprecation.DeprecatedAt == "" {
+		return nil
+	}
+	deprecatedAt, err := time.Parse(config.APIDateLayout, deprecation.DeprecatedAt)
+	if err != nil {
+		logger.Warn("APIVersion: Invalid deprecation date", zap.String("version", version), zap.String("deprecatedAt", deprecation.DeprecatedAt))
+		return nil
+	}
+
+	headers := map[string]string{
+		"Deprecation": fmt.Sprintf("@%d", deprecatedAt.Unix()),
+	}
+	if deprecation.SunsetAt != "" {
+		if sunsetAt, err := time.Parse(config.APIDateLayout, deprecation.SunsetAt); err == nil {
+			headers["Sunset"] = sunsetAt.UTC().Format(http.TimeFormat)
+		}
+	}
+	if deprecation.Link != "" {
+		headers["Link"] = fmt.Sprintf("<%s>; rel=\"deprecation\"", deprecation.Link)
+	}
+	return headers
+}
@@ -7,13 +7,17 @@ import (
 
 	"github.com/3Eeeecho/go-clouddisk/internal/config"
 	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/admin"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+// AuthMiddleware 支持网页登录的 JWT、pat_ 开头的个人访问令牌和 oat_ 开头的第三方应用令牌
+// 网页登录的 JWT 所属会话被撤销后立即失效,账号申请注销后所有令牌立即失效
+func AuthMiddleware(cfg *config.Config, tokenService admin.AccessTokenService, oauthService admin.OAuthService, sessionService admin.SessionService, userService admin.UserService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 1. 从请求头获取 Token
 		authHeader := c.GetHeader("Authorization")
@@ -30,6 +34,48 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 		}
 		tokenString := parts[1]
 
+		// 个人访问令牌: 额外记录权限范围,由 RequireScope 等中间件检查
+		if strings.HasPrefix(tokenString, models.AccessTokenPrefix) {
+			accessToken, err := tokenService.Authenticate(c.Request.Context(), tokenString)
+			if err != nil {
+				if errors.Is(err, xerr.ErrTokenInvalid) {
+					response.AbortWithError(c, http.StatusUnauthorized, xerr.TokenInvalidCode, "Invalid or expired access token")
+					return
+				}
+				response.AbortWithError(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to verify access token")
+				return
+			}
+			if !requireActiveUser(c, userService, accessToken.UserID) {
+				return
+			}
+			c.Set("userID", accessToken.UserID)
+			c.Set(tokenScopeKey, []string{accessToken.Scope})
+			c.Request = c.Request.WithContext(utils.WithActorID(c.Request.Context(), accessToken.UserID))
+			c.Next()
+			return
+		}
+
+		// 第三方应用令牌: 权限范围为用户授权时同意的范围
+		if strings.HasPrefix(tokenString, models.OAuthAccessTokenPrefix) {
+			oauthToken, err := oauthService.Authenticate(c.Request.Context(), tokenString)
+			if err != nil {
+				if errors.Is(err, xerr.ErrTokenInvalid) {
+					response.AbortWithError(c, http.StatusUnauthorized, xerr.TokenInvalidCode, "Invalid or expired access token")
+					return
+				}
+				response.AbortWithError(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to verify access token")
+				return
+			}
+			if !requireActiveUser(c, userService, oauthToken.UserID) {
+				return
+			}
+			c.Set("userID", oauthToken.UserID)
+			c.Set(tokenScopeKey, strings.Fields(oauthToken.Scope))
+			c.Request = c.Request.WithContext(utils.WithActorID(c.Request.Context(), oauthToken.UserID))
+			c.Next()
+			return
+		}
+
 		// 2. 解析和验证 Token
 		claims := &utils.Claims{}
 		token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (any, error) {
@@ -40,7 +86,7 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 		})
 
 		if err != nil {
-			response.AbortWithError(c, http.StatusUnauthorized, xerr.UnauthorizedCode, "Invalid or malformed token: "+err.Error()) // 统一返回错误
+			response.AbortWithError(c, http.StatusUnauthorized, xerr.UnauthorizedCode, "Invalid or malformed token")
 			return
 		}
 
@@ -49,11 +95,53 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		// 等待两步验证的中间 Token 只能用于 /auth/2fa,其他专用 Token 也不能访问普通接口
+		if claims.Purpose == utils.TokenPurposeTwoFactor {
+			response.AbortWithError(c, http.StatusUnauthorized, xerr.UnauthorizedCode, "Two-factor authentication is required")
+			return
+		}
+		if claims.Purpose != "" {
+			response.AbortWithError(c, http.StatusUnauthorized, xerr.UnauthorizedCode, "Token cannot be used for this request")
+			return
+		}
+
+		if claims.SessionID != "" {
+			revoked, err := sessionService.IsRevoked(c.Request.Context(), claims.SessionID)
+			if err != nil {
+				response.AbortWithError(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to verify session")
+				return
+			}
+			if revoked {
+				response.AbortWithError(c, http.StatusUnauthorized, xerr.TokenInvalidCode, "Session has been revoked")
+				return
+			}
+		}
+
+		if !requireActiveUser(c, userService, claims.UserID) {
+			return
+		}
+
 		// 3. 将用户信息存储到 Gin Context 中，以便后续 Handler 使用
 		c.Set("userID", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("email", claims.Email)
+		c.Set("sessionID", claims.SessionID)
+		c.Request = c.Request.WithContext(utils.WithActorID(c.Request.Context(), claims.UserID))
 
 		c.Next() // Token 有效，继续处理请求
 	}
 }
+
+// requireActiveUser 令牌所属账号已申请注销或已注销时中止请求并返回 false
+func requireActiveUser(c *gin.Context, userService admin.UserService, userID uint64) bool {
+	active, err := userService.IsActive(c.Request.Context(), userID)
+	if err != nil {
+		response.AbortWithError(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to verify account status")
+		return false
+	}
+	if !active {
+		response.AbortWithError(c, http.StatusUnauthorized, xerr.UnauthorizedCode, "Account is not active")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,24 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/gin-gonic/gin"
+)
+
+// BearerToken 校验 Authorization: Bearer 头中的固定令牌,用于 Prometheus 等无法登录的抓取方
+func BearerToken(token string) gin.HandlerFunc {
+	expected := []byte(token)
+	return func(c *gin.Context) {
+		got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), expected) != 1 {
+			response.AbortWithErrorCode(c, http.StatusUnauthorized, xerr.UnauthorizedCode)
+			return
+		}
+		c.Next()
+	}
+}
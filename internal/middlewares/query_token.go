@@ -0,0 +1,18 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// QueryToken 浏览器无法为 WebSocket 握手设置请求头,没有 Authorization 头时使用 access_token 查询参数中的令牌,
+// 只用于 WebSocket 等无法设置请求头的接口,需要放在 AuthMiddleware 之前
+func QueryToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			if token := c.Query("access_token"); token != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		c.Next()
+	}
+}
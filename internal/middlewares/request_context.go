@@ -0,0 +1,32 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ClientRegionHeader 客户端声明自己所在地域的请求头,生成预签名下载链接时用于选择最近的存储入口
+const ClientRegionHeader = "X-Client-Region"
+
+// RequestContext 将客户端IP等请求信息注入 c.Request 的 context 中，
+// 使不依赖 gin 的 service 层也能获取到这些信息。
+// 会修改数据的请求从主库读取，其余请求在写入之后才切换到主库
+func RequestContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := utils.WithClientIP(c.Request.Context(), c.ClientIP())
+		if region := c.GetHeader(ClientRegionHeader); region != "" {
+			ctx = utils.WithClientRegion(ctx, region)
+		}
+		ctx = utils.WithPrimaryRead(ctx, !isSafeMethod(c.Request.Method))
+		ctx = utils.WithRoute(ctx, c.FullPath(), c.HandlerName())
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// isSafeMethod 只读的 HTTP 方法
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
@@ -0,0 +1,129 @@
+package middlewares
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// sensitiveQueryParams 访问日志中需要隐藏的查询参数,WebSocket 令牌和分享密码等会出现在查询参数中
+var sensitiveQueryParams = []string{"access_token", "token", "password"}
+
+// RequestLogger 为每个请求输出一条结构化日志,包含耗时、用户ID、路由、状态码和响应字节数。
+// 只记录路径不记录查询参数,5xx 和慢请求总是记录,其余请求按 sample_rate 采样
+func RequestLogger(cfg config.RequestLogConfig) gin.HandlerFunc {
+	skip := make(map[string]struct{}, len(cfg.SkipPaths))
+	for _, path := range cfg.SkipPaths {
+		skip[path] = struct{}{}
+	}
+	slowThreshold := time.Duration(cfg.SlowThreshold) * time.Millisecond
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if _, ok := skip[route]; ok {
+			return
+		}
+		if _, ok := skip[c.Request.URL.Path]; ok {
+			return
+		}
+
+		latency := time.Since(start)
+		status := c.Writer.Status()
+		slow := slowThreshold > 0 && latency >= slowThreshold
+		if status < http.StatusInternalServerError && !slow && !sampled(cfg.SampleRate) {
+			return
+		}
+
+		if route == "" {
+			route = "unmatched"
+		}
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("route", route),
+			zap.String("path", c.Request.URL.Path),
+			zap.Int("status", status),
+			zap.Duration("latency", latency),
+			zap.Int("bytes", c.Writer.Size()),
+			zap.String("client_ip", c.ClientIP()),
+		}
+		if userID, ok := c.Get("userID"); ok {
+			fields = append(fields, zap.Any("user_id", userID))
+		}
+		if spanCtx := trace.SpanContextFromContext(c.Request.Context()); spanCtx.HasTraceID() {
+			fields = append(fields, zap.String("trace_id", spanCtx.TraceID().String()))
+		}
+		if len(c.Errors) > 0 {
+			fields = append(fields, zap.String("errors", c.Errors.String()))
+		}
+
+		switch {
+		case status >= http.StatusInternalServerError:
+			logger.Error("HTTP request", fields...)
+		case slow:
+			logger.Warn("Slow HTTP request", fields...)
+		default:
+			logger.Info("HTTP request", fields...)
+		}
+	}
+}
+
+// AccessLogger 关闭结构化请求日志时使用的访问日志,格式与 gin 默认的访问日志相同,敏感的查询参数被隐藏
+func AccessLogger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		if param.Latency > time.Minute {
+			param.Latency = param.Latency.Truncate(time.Second)
+		}
+		return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			param.StatusCode,
+			param.Latency,
+			param.ClientIP,
+			param.Method,
+			redactQuery(param.Path),
+			param.ErrorMessage,
+		)
+	})
+}
+
+// redactQuery 把路径中敏感查询参数的值替换为 REDACTED
+func redactQuery(path string) string {
+	base, rawQuery, ok := strings.Cut(path, "?")
+	if !ok {
+		return path
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return base + "?REDACTED"
+	}
+	redacted := false
+	for _, name := range sensitiveQueryParams {
+		if query.Has(name) {
+			query.Set(name, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return path
+	}
+	return base + "?" + query.Encode()
+}
+
+// sampled 按比例决定是否记录,rate 不在 (0,1) 内时全部记录
+func sampled(rate float64) bool {
+	if rate <= 0 || rate >= 1 {
+		return true
+	}
+	return rand.Float64() < rate
+}
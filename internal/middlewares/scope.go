@@ -0,0 +1,71 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/gin-gonic/gin"
+)
+
+// tokenScopeKey 个人访问令牌或 OAuth 令牌的权限范围([]string)在 Gin Context 中的键,网页登录的请求没有该键
+const tokenScopeKey = "tokenScope"
+
+// RequireScope 个人访问令牌或 OAuth 令牌需要具有 scopes 之一或 full 权限,网页登录的请求不受限制
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !scopeAllowed(c, scopes...) {
+			response.AbortWithErrorCode(c, http.StatusForbidden, xerr.InsufficientScopeCode)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireReadScope 只读请求(GET/HEAD)允许 read 权限的个人访问令牌和具有 readScope 或 writeScope 的 OAuth 令牌,
+// 其余请求需要 full 权限或 writeScope。readScope、writeScope 为空表示 OAuth 令牌不能访问
+func RequireReadScope(readScope, writeScope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var scopes []string
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			scopes = append(scopes, models.TokenScopeRead, readScope)
+		}
+		scopes = append(scopes, writeScope)
+		if !scopeAllowed(c, scopes...) {
+			response.AbortWithErrorCode(c, http.StatusForbidden, xerr.InsufficientScopeCode)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RejectAccessToken 令牌管理等敏感接口只允许网页登录访问,防止泄露的令牌自我续期
+func RejectAccessToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get(tokenScopeKey); ok {
+			response.AbortWithError(c, http.StatusForbidden, xerr.InsufficientScopeCode, "Access tokens cannot be used for this operation")
+			return
+		}
+		c.Next()
+	}
+}
+
+func scopeAllowed(c *gin.Context, scopes ...string) bool {
+	value, ok := c.Get(tokenScopeKey)
+	if !ok {
+		return true
+	}
+	granted, _ := value.([]string)
+	for _, scope := range granted {
+		if scope == models.TokenScopeFull {
+			return true
+		}
+		for _, s := range scopes {
+			if s != "" && scope == s {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/admin"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireVerifiedEmail 只允许已验证邮箱的用户访问,需要挂载在 AuthMiddleware 之后
+func RequireVerifiedEmail(userService admin.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := utils.GetUserIDFromContext(c)
+		if !ok {
+			return
+		}
+
+		verified, err := userService.IsEmailVerified(c.Request.Context(), userID)
+		if err != nil {
+			response.AbortWithError(c, http.StatusInternalServerError, xerr.InternalServerErrorCode, "Failed to verify email status")
+			return
+		}
+		if !verified {
+			response.AbortWithErrorCode(c, http.StatusForbidden, xerr.EmailNotVerifiedCode)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,397 @@
+// Package migrations 管理数据库表结构版本。
+//
+// 每个迁移有递增的版本号,执行后记录到 schema_migrations 表。Go 迁移通过 GORM AutoMigrate 创建或补齐模型对应的表,
+// SQL 迁移来自 sql 目录下 "<版本号>_<名称>.sql" 文件,用于 AutoMigrate 无法表达的索引和数据修正,执行时记录文件校验和。
+// 已发布的迁移不能修改,表结构变更需要追加新的迁移。
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"gorm.io/gorm"
+)
+
+//go:embed sql/*.sql
+var sqlFiles embed.FS
+
+// migrationLockName 多个实例同时启动时只有一个执行迁移
+const (
+	migrationLockName    = "go-clouddisk:schema_migrations"
+	migrationLockTimeout = 60 // 秒
+)
+
+// SchemaMigration 已执行的迁移记录
+type SchemaMigration struct {
+	Version   uint      `gorm:"primaryKey;autoIncrement:false" json:"version"`
+	Name      string    `gorm:"type:varchar(255);not null" json:"name"`
+	Checksum  string    `gorm:"type:char(64);not null;default:''" json:"checksum"`
+	AppliedAt time.Time `gorm:"not null" json:"applied_at"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migration 一个版本的表结构变更
+type Migration struct {
+	Version uint
+	Name    string
+	// Checksum SQL 迁移的文件校验和,Go 迁移为空
+	Checksum string
+	// Models Go 迁移创建的表,启动校验时检查这些表是否存在
+	Models []any
+	up     func(tx *gorm.DB) error
+}
+
+// MigrationStatus 迁移的执行状态
+type MigrationStatus struct {
+	Version   uint       `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	// Drift 已执行的 SQL 迁移文件被修改过,或数据库中有当前版本不认识的迁移
+	Drift bool `json:"drift"`
+}
+
+// goMigrations Go 迁移,已有部署中的表由早期的 AutoMigrate 创建,基线迁移会补齐缺少的列和索引
+var goMigrations = []Migration{
+	autoMigrate(1, "baseline",
+		&models.User{},
+		&models.File{},
+		&models.Share{},
+		&models.FileVersion{},
+		&models.MultipartUpload{},
+		&models.Activity{},
+		&models.FilePermission{},
+		&models.FileStats{},
+		&models.PersonalAccessToken{},
+		&models.FileFavorite{},
+		&models.FileTag{},
+		&models.FileComment{},
+		&models.DataExport{},
+		&models.DataExportPart{},
+		&models.ArchiveJob{},
+		&models.ShareAccessLog{},
+		&models.PurgeJob{},
+		&models.TwoFactorRecoveryCode{},
+		&models.StorageMigration{},
+		&models.OAuthClient{},
+		&models.OAuthToken{},
+		&models.MediaMetadata{},
+		&models.LifecycleRule{},
+		&models.OutboxEvent{},
+	),
+	autoMigrate(4, "bucket_sharding", &models.MultipartUpload{}, &models.StorageMigration{}),
+	autoMigrate(5, "user_usage", &models.UserUsage{}),
+	autoMigrate(6, "notifications", &models.Notification{}),
+	autoMigrate(8, "internal_shares", &models.Share{}),
+	autoMigrate(9, "organizations", &models.Organization{}, &models.OrganizationMember{}),
+	autoMigrate(10, "storage_objects", &models.StorageObject{}),
+	// 原为 sql/0012_files_path_rebuild.sql,按层级重新计算 path 列。path 列已在 18 中删除,
+	// 新建的数据库没有该列,改为空迁移并保留版本号,已执行过的部署记录不变
+	{Version: 12, Name: "files_path_rebuild", up: func(tx *gorm.DB) error { return nil }},
+	autoMigrate(13, "extract_jobs", &models.ExtractJob{}),
+	autoMigrate(14, "account_deletions", &models.AccountDeletion{}),
+	autoMigrate(15, "file_download_counts", &models.FileDownloadCount{}),
+	autoMigrate(16, "users_trash_retention", &models.User{}),
+	autoMigrate(17, "files_filter_indexes", &models.File{}),
+	{Version: 18, Name: "files_drop_path", up: dropFilesPath},
+	{Version: 19, Name: "storage_objects_bucket", up: addObjectBuckets},
+	autoMigrate(20, "purge_jobs_attempts", &models.PurgeJob{}),
+	{Version: 21, Name: "users_email_verified_backfill", up: backfillEmailVerified},
+	autoMigrate(22, "lifecycle_failures", &models.LifecycleFailure{}),
+	autoMigrate(23, "outbox_events_status", &models.OutboxEvent{}),
+}
+
+// backfillEmailVerified 把邮箱验证上线之前注册的用户视为已验证,否则这些用户将无法创建分享。
+// 验证时间取注册时间
+func backfillEmailVerified(tx *gorm.DB) error {
+	if err := tx.AutoMigrate(&models.User{}); err != nil {
+		return err
+	}
+	return tx.Exec("UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL").Error
+}
+
+// addObjectBuckets 为版本记录和存储对象记录所在的存储桶,同一个 key 在不同存储桶中是不同的对象。
+// 已有的版本按所属文件当前的存储桶回填,对象按引用它的版本回填
+func addObjectBuckets(tx *gorm.DB) error {
+	if err := tx.AutoMigrate(&models.FileVersion{}, &models.StorageObject{}); err != nil {
+		return err
+	}
+	if err := tx.Exec(`UPDATE file_versions v JOIN files f ON f.id = v.file_id
+		SET v.oss_bucket = f.oss_bucket
+		WHERE v.oss_bucket = '' AND f.oss_bucket IS NOT NULL`).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec(`UPDATE storage_objects o JOIN file_versions v ON v.oss_key = o.oss_key AND v.version_id = o.version_id
+		SET o.bucket = v.oss_bucket
+		WHERE o.bucket = ''`).Error; err != nil {
+		return err
+	}
+	migrator := tx.Migrator()
+	if migrator.HasIndex(&models.StorageObject{}, "idx_object_version") {
+		return migrator.DropIndex(&models.StorageObject{}, "idx_object_version")
+	}
+	return nil
+}
+
+// dropFilesPath 删除已不再维护的 path 列及其索引,路径只按 parent_folder_id 计算。
+// 按名称逐级定位文件使用新的 (user_id, parent_folder_id, file_name) 索引
+func dropFilesPath(tx *gorm.DB) error {
+	migrator := tx.Migrator()
+	if migrator.HasIndex(&models.File{}, "idx_user_path_name") {
+		if err := migrator.DropIndex(&models.File{}, "idx_user_path_name"); err != nil {
+			return err
+		}
+	}
+	if migrator.HasColumn(&models.File{}, "path") {
+		if err := migrator.DropColumn(&models.File{}, "path"); err != nil {
+			return err
+		}
+	}
+	return tx.AutoMigrate(&models.File{})
+}
+
+// autoMigrate 创建通过 AutoMigrate 同步模型表结构的迁移
+func autoMigrate(version uint, name string, models ...any) Migration {
+	return Migration{
+		Version: version,
+		Name:    name,
+		Models:  models,
+		up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(models...)
+		},
+	}
+}
+
+// All 按版本号返回全部迁移
+func All() ([]Migration, error) {
+	all := append([]Migration(nil), goMigrations...)
+	entries, err := sqlFiles.ReadDir("sql")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		migration, err := loadSQLMigration(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, migration)
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	for i := 1; i < len(all); i++ {
+		if all[i].Version == all[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d", all[i].Version)
+		}
+	}
+	return all, nil
+}
+
+// loadSQLMigration 读取 "<版本号>_<名称>.sql" 文件,文件中的语句以分号结尾,逐条执行
+func loadSQLMigration(fileName string) (Migration, error) {
+	base := strings.TrimSuffix(fileName, ".sql")
+	versionPart, name, ok := strings.Cut(base, "_")
+	if !ok {
+		return Migration{}, fmt.Errorf("invalid migration file name %q", fileName)
+	}
+	version, err := strconv.ParseUint(versionPart, 10, 32)
+	if err != nil || version == 0 {
+		return Migration{}, fmt.Errorf("invalid migration version in %q", fileName)
+	}
+	content, err := sqlFiles.ReadFile(path.Join("sql", fileName))
+	if err != nil {
+		return Migration{}, err
+	}
+	sum := sha256.Sum256(content)
+
+	statements := splitStatements(string(content))
+	return Migration{
+		Version:  uint(version),
+		Name:     name,
+		Checksum: hex.EncodeToString(sum[:]),
+		up: func(tx *gorm.DB) error {
+			for _, stmt := range statements {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}, nil
+}
+
+// splitStatements 去掉 "--" 注释行后按行尾分号切分语句
+func splitStatements(content string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// Up 按顺序执行未执行的迁移,返回本次执行的迁移。
+// MySQL 的 DDL 会隐式提交,迁移失败时已执行的语句不会回滚,修正后重新执行即可继续
+func Up(ctx context.Context, db *gorm.DB) ([]Migration, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	// 锁属于单个连接,加锁、迁移和释放锁需要在同一个连接上执行
+	err = db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		var locked int
+		if err := conn.Raw("SELECT GET_LOCK(?, ?)", migrationLockName, migrationLockTimeout).Scan(&locked).Error; err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if locked != 1 {
+			return fmt.Errorf("timed out waiting for migration lock")
+		}
+		defer conn.Exec("SELECT RELEASE_LOCK(?)", migrationLockName)
+
+		if err := conn.AutoMigrate(&SchemaMigration{}); err != nil {
+			return fmt.Errorf("failed to create schema_migrations table: %w", err)
+		}
+		done, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range all {
+			if record, ok := done[migration.Version]; ok {
+				if migration.Checksum != "" && record.Checksum != migration.Checksum {
+					return fmt.Errorf("migration %d_%s has been modified after it was applied", migration.Version, migration.Name)
+				}
+				continue
+			}
+			if err := migration.up(conn); err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			record := SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				Checksum:  migration.Checksum,
+				AppliedAt: time.Now(),
+			}
+			if err := conn.Create(&record).Error; err != nil {
+				return fmt.Errorf("failed to record migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Status 返回每个迁移的执行状态,包括数据库中存在但当前版本不认识的迁移
+func Status(ctx context.Context, db *gorm.DB) ([]MigrationStatus, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+	db = db.WithContext(ctx)
+
+	done := map[uint]SchemaMigration{}
+	if db.Migrator().HasTable(&SchemaMigration{}) {
+		if done, err = appliedVersions(db); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]MigrationStatus, 0, len(all))
+	for _, migration := range all {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if record, ok := done[migration.Version]; ok {
+			appliedAt := record.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			status.Drift = migration.Checksum != "" && record.Checksum != migration.Checksum
+			delete(done, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, record := range done {
+		appliedAt := record.AppliedAt
+		statuses = append(statuses, MigrationStatus{
+			Version:   record.Version,
+			Name:      record.Name,
+			Applied:   true,
+			AppliedAt: &appliedAt,
+			Drift:     true,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Verify 检查数据库与当前版本的迁移是否一致,有未执行、被修改或不认识的迁移以及缺少的表时返回错误,用于启动时快速失败
+func Verify(ctx context.Context, db *gorm.DB) error {
+	statuses, err := Status(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	var pending, drifted []string
+	for _, status := range statuses {
+		label := fmt.Sprintf("%d_%s", status.Version, status.Name)
+		switch {
+		case status.Drift:
+			drifted = append(drifted, label)
+		case !status.Applied:
+			pending = append(pending, label)
+		}
+	}
+	if len(drifted) > 0 {
+		return fmt.Errorf("database schema drift detected: %s", strings.Join(drifted, ", "))
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is out of date, pending migrations: %s (run cmd/migrate)", strings.Join(pending, ", "))
+	}
+
+	// 记录完整但表被手动删除时同样视为漂移
+	migrator := db.WithContext(ctx).Migrator()
+	for _, migration := range goMigrations {
+		for _, model := range migration.Models {
+			if !migrator.HasTable(model) {
+				return fmt.Errorf("database schema drift detected: table for %T is missing", model)
+			}
+		}
+	}
+	return nil
+}
+
+func appliedVersions(db *gorm.DB) (map[uint]SchemaMigration, error) {
+	var records []SchemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	done := make(map[uint]SchemaMigration, len(records))
+	for _, record := range records {
+		done[record.Version] = record
+	}
+	return done, nil
+}
@@ -0,0 +1,2 @@
+-- 回收站列表按 user_id 过滤并按 deleted_at、id 倒序分页
+CREATE INDEX idx_files_user_deleted ON files (user_id, deleted_at, id);
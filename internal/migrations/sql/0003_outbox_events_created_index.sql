@@ -0,0 +1,2 @@
+-- 发件箱积压排查按创建时间查看最早未投递的事件
+CREATE INDEX idx_outbox_events_created_at ON outbox_events (created_at);
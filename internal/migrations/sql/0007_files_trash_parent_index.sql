@@ -0,0 +1,2 @@
+-- 文件夹列表统计回收站中的子项,回收站按原父文件夹过滤
+CREATE INDEX idx_files_user_parent_deleted ON files (user_id, parent_folder_id, deleted_at, id);
@@ -0,0 +1,7 @@
+-- 按已有版本记录(包括回收站中文件的版本)初始化存储对象的引用计数
+INSERT INTO storage_objects (oss_key, version_id, sha256_hash, size, ref_count, created_at, updated_at)
+SELECT oss_key, version_id, MAX(sha256_hash), MAX(size), COUNT(*), NOW(3), NOW(3)
+FROM file_versions
+WHERE oss_key <> ''
+GROUP BY oss_key, version_id
+ON DUPLICATE KEY UPDATE ref_count = VALUES(ref_count);
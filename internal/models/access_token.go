@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// AccessTokenPrefix 个人访问令牌的前缀,认证中间件据此区分令牌和 JWT
+const AccessTokenPrefix = "pat_"
+
+// 个人访问令牌的权限范围
+const (
+	TokenScopeRead   = "read"   // 只读: 浏览、下载、预览
+	TokenScopeUpload = "upload" // 仅上传: 只能调用上传接口
+	TokenScopeFull   = "full"   // 完全权限: 与网页登录相同
+)
+
+// PersonalAccessToken 对应 personal_access_tokens 表,供第三方客户端(同步工具、脚本)使用。
+// 数据库只保存令牌的 SHA-256,明文只在创建时返回一次
+type PersonalAccessToken struct {
+	ID         uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint64     `gorm:"not null;index" json:"user_id"`
+	Name       string     `gorm:"type:varchar(64);not null" json:"name"`
+	TokenHash  string     `gorm:"type:char(64);not null;uniqueIndex" json:"-"`
+	TokenHint  string     `gorm:"type:varchar(16);not null" json:"token_hint"` // 令牌开头几位,方便用户辨认
+	Scope      string     `gorm:"type:varchar(16);not null" json:"scope"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"` // 为空表示永不过期
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (PersonalAccessToken) TableName() string {
+	return "personal_access_tokens"
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// 账号注销任务状态。执行中的状态表示当前所在的步骤,步骤依次为撤销访问、删除文件和匿名化
+const (
+	AccountDeletionPending     = "pending"     // 等待 Worker 处理
+	AccountDeletionRevoking    = "revoking"    // 撤销会话、令牌、分享和协作授权
+	AccountDeletionPurging     = "purging"     // 彻底删除全部文件和版本
+	AccountDeletionAnonymizing = "anonymizing" // 匿名化活动日志并删除账号
+	AccountDeletionCompleted   = "completed"   // 已全部完成,已发送通知
+	AccountDeletionFailed      = "failed"      // 某个步骤失败,管理员重试后从 Step 继续
+)
+
+// AccountDeletion 对应 account_deletions 表,记录用户申请注销后账号数据的清除进度
+type AccountDeletion struct {
+	ID          uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      uint64     `gorm:"not null;index" json:"user_id"`
+	Username    string     `gorm:"type:varchar(64);not null" json:"username"`      // 申请时的用户名,账号匿名化后便于管理员核对
+	Email       string     `gorm:"type:varchar(255);not null;default:''" json:"-"` // 完成后通知的邮箱,发送后清空
+	Status      string     `gorm:"type:varchar(16);not null;index" json:"status"`
+	Step        string     `gorm:"type:varchar(16);not null;default:''" json:"step,omitempty"` // 最近开始执行的步骤,失败后从该步骤重试
+	PurgedFiles int64      `gorm:"not null;default:0" json:"purged_files"`                     // 已彻底删除的文件和文件夹数
+	Error       string     `gorm:"type:varchar(512);not null;default:''" json:"error,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (AccountDeletion) TableName() string {
+	return "account_deletions"
+}
+
+// AccountDeletionTask 发布到 RabbitMQ 的账号注销任务消息体
+type AccountDeletionTask struct {
+	DeletionID uint64 `json:"deletion_id"`
+}
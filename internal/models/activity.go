@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// 活动类型
+const (
+	ActivityUpload      = "upload"
+	ActivityDownload    = "download"
+	ActivityRename      = "rename"
+	ActivityMove        = "move"
+	ActivityDelete      = "delete"
+	ActivityRestore     = "restore"
+	ActivityShareCreate = "share_create"
+	ActivityShareAccess = "share_access"
+	ActivityShareRotate = "share_rotate" // 重新生成分享链接,旧链接失效
+	ActivityShareLocked = "share_locked" // 分享密码错误次数过多,暂时锁定
+	ActivitySharePurge  = "share_purge"  // 清理任务永久删除失效的分享记录
+	ActivityQuarantine  = "quarantine"   // 扫描发现病毒,文件被隔离
+	ActivityTransferOut = "transfer_out" // 将副本发送给其他用户
+	ActivityTransferIn  = "transfer_in"  // 收到其他用户发送的副本
+	ActivityExportReady = "export_ready" // 账户数据导出完成,可以下载
+	ActivityCorrupted   = "corrupted"    // 完整性校验发现文件内容损坏
+	ActivityIntegrityOK = "integrity_ok" // 管理员重新校验损坏的文件后内容一致,恢复正常
+	ActivityComment     = "comment"      // 在文件上发表评论
+	ActivityMention     = "mention"      // 在评论中被提及,记录在被提及用户的活动日志中
+	ActivityEdit        = "edit"         // 在线编辑后保存为新版本
+	ActivityQuotaWarn   = "quota_warn"   // 存储用量达到预警比例
+)
+
+// Activity 对应 activities 表，记录文件相关操作的审计日志
+type Activity struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint64    `gorm:"not null;index" json:"user_id"`                  // 文件所属用户
+	ActorID   *uint64   `gorm:"default:null" json:"actor_id"`                   // 操作者ID，匿名访问(如分享链接)为 null
+	FileID    *uint64   `gorm:"default:null;index" json:"file_id"`              // 目标文件ID
+	Action    string    `gorm:"type:varchar(32);not null" json:"action"`        // 操作类型
+	IP        string    `gorm:"type:varchar(64);not null;default:''" json:"ip"` // 客户端IP
+	Detail    string    `gorm:"type:varchar(1024);not null;default:''" json:"detail"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (Activity) TableName() string {
+	return "activities"
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// 文件夹打包任务状态
+const (
+	ArchiveStatusPending    = "pending"    // 等待 Worker 处理
+	ArchiveStatusProcessing = "processing" // 正在打包
+	ArchiveStatusReady      = "ready"      // 已完成,可以下载
+	ArchiveStatusFailed     = "failed"     // 打包失败
+	ArchiveStatusExpired    = "expired"    // 已过期,暂存的 ZIP 已删除
+)
+
+// ArchiveJob 对应 archive_jobs 表,把文件夹异步打包为 ZIP 暂存到存储桶,
+// 与流式打包下载不同,完成的 ZIP 大小确定,支持断点续传
+type ArchiveJob struct {
+	ID        uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint64     `gorm:"not null;index:idx_archive_user_folder,priority:1" json:"user_id"` // 发起打包的用户
+	FolderID  uint64     `gorm:"not null;index:idx_archive_user_folder,priority:2" json:"folder_id"`
+	FileName  string     `gorm:"type:varchar(255);not null" json:"file_name"` // 下载时使用的文件名
+	Status    string     `gorm:"type:varchar(16);not null;index" json:"status"`
+	OssBucket string     `gorm:"type:varchar(255);not null;default:''" json:"-"`
+	OssKey    string     `gorm:"type:varchar(512);not null;default:''" json:"-"`
+	VersionID string     `gorm:"type:varchar(255);not null;default:''" json:"-"`
+	Size      int64      `gorm:"not null;default:0" json:"size"`       // ZIP 的大小
+	FileCount int64      `gorm:"not null;default:0" json:"file_count"` // 打包的文件数
+	TotalSize uint64     `gorm:"not null;default:0" json:"total_size"` // 打包文件的原始总大小
+	Error     string     `gorm:"type:varchar(512);not null;default:''" json:"error,omitempty"`
+	ExpiresAt *time.Time `gorm:"index" json:"expires_at,omitempty"` // 完成时设置,过期后 ZIP 被删除
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	URL string `gorm:"-" json:"url,omitempty"` // 查询时生成的限时下载链接
+}
+
+// TableName 指定 GORM 使用的表名
+func (ArchiveJob) TableName() string {
+	return "archive_jobs"
+}
+
+// ArchiveTask 发布到 RabbitMQ 的打包任务消息体
+type ArchiveTask struct {
+	JobID  uint64 `json:"job_id"`
+	UserID uint64 `json:"user_id"`
+}
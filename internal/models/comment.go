@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// FileComment 对应 file_comments 表,文件所有者和被授权的协作者都可以在文件上发表评论
+type FileComment struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	FileID    uint64    `gorm:"not null;index:idx_file_created,priority:1" json:"file_id"`
+	UserID    uint64    `gorm:"not null;index" json:"user_id"` // 评论者
+	Content   string    `gorm:"type:text;not null" json:"content"`
+	CreatedAt time.Time `gorm:"autoCreateTime;index:idx_file_created,priority:2" json:"created_at"`
+
+	// AuthorName 评论者的用户名,查询时关联 users 表得到,不在 file_comments 中建列
+	AuthorName string `gorm:"->;-:migration" json:"author_name"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (FileComment) TableName() string {
+	return "file_comments"
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// FileDownloadCount 对应 file_download_counts 表,按文件和存储版本累计下载次数。
+// 文件夹打包下载的 VersionID 为空,文件的累计下载次数为各版本之和
+type FileDownloadCount struct {
+	FileID    uint64    `gorm:"primaryKey;autoIncrement:false" json:"file_id"`
+	VersionID string    `gorm:"primaryKey;type:varchar(128)" json:"version_id"`
+	Downloads int64     `gorm:"not null;default:0" json:"downloads"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"` // 最近一次写入计数的时间
+}
+
+// TableName 指定 GORM 使用的表名
+func (FileDownloadCount) TableName() string {
+	return "file_download_counts"
+}
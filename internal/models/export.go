@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// 导出任务状态
+const (
+	ExportStatusPending    = "pending"    // 等待 Worker 处理
+	ExportStatusProcessing = "processing" // 正在打包
+	ExportStatusReady      = "ready"      // 已完成,可以下载
+	ExportStatusFailed     = "failed"     // 打包失败
+	ExportStatusExpired    = "expired"    // 已过期,暂存的 ZIP 已删除
+)
+
+// 导出范围
+const (
+	ExportScopeAll        = "all"         // 所有正常状态的文件
+	ExportScopeRecycleBin = "recycle_bin" // 回收站中的文件
+)
+
+// DataExport 对应 data_exports 表,用户的账户数据导出任务
+type DataExport struct {
+	ID        uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint64     `gorm:"not null;index" json:"user_id"`
+	Scope     string     `gorm:"type:varchar(16);not null" json:"scope"`
+	Status    string     `gorm:"type:varchar(16);not null;index" json:"status"`
+	OssBucket string     `gorm:"type:varchar(255);not null;default:''" json:"-"`
+	FileCount int64      `gorm:"not null;default:0" json:"file_count"`
+	TotalSize uint64     `gorm:"not null;default:0" json:"total_size"` // 导出文件的原始总大小
+	Error     string     `gorm:"type:varchar(512);not null;default:''" json:"error,omitempty"`
+	ExpiresAt *time.Time `gorm:"index" json:"expires_at,omitempty"` // 完成时设置,过期后 ZIP 被删除
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Parts []DataExportPart `gorm:"foreignKey:ExportID" json:"parts,omitempty"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (DataExport) TableName() string {
+	return "data_exports"
+}
+
+// DataExportPart 对应 data_export_parts 表,导出的一个 ZIP 分卷
+type DataExportPart struct {
+	ID         uint64 `gorm:"primaryKey;autoIncrement" json:"-"`
+	ExportID   uint64 `gorm:"not null;index" json:"-"`
+	PartNumber int    `gorm:"not null" json:"part_number"`
+	OssKey     string `gorm:"type:varchar(512);not null" json:"-"`
+	VersionID  string `gorm:"type:varchar(255);not null;default:''" json:"-"`
+	Size       int64  `gorm:"not null" json:"size"`
+	URL        string `gorm:"-" json:"url,omitempty"` // 查询时生成的限时下载链接
+}
+
+// TableName 指定 GORM 使用的表名
+func (DataExportPart) TableName() string {
+	return "data_export_parts"
+}
+
+// ExportTask 发布到 RabbitMQ 的导出任务消息体
+type ExportTask struct {
+	ExportID uint64 `json:"export_id"`
+	UserID   uint64 `json:"user_id"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// 解压任务状态
+const (
+	ExtractStatusPending    = "pending"    // 等待 Worker 处理
+	ExtractStatusProcessing = "processing" // 正在解压
+	ExtractStatusDone       = "done"       // 已完成
+	ExtractStatusFailed     = "failed"     // 解压失败,已解压的内容保留在目标文件夹中
+)
+
+// 支持解压的压缩包格式
+const (
+	ExtractFormatZip   = "zip"
+	ExtractFormatTar   = "tar"
+	ExtractFormatTarGz = "tar.gz"
+)
+
+// ExtractJob 对应 extract_jobs 表,把网盘中的 ZIP 或 TAR 压缩包解压到目标文件夹下
+// 以压缩包命名的新文件夹中
+type ExtractJob struct {
+	ID               uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID           uint64    `gorm:"not null;index:idx_extract_user_file,priority:1" json:"user_id"` // 发起解压的用户
+	FileID           uint64    `gorm:"not null;index:idx_extract_user_file,priority:2" json:"file_id"` // 压缩包文件
+	TargetFolderID   *uint64   `json:"target_folder_id,omitempty"`                                     // 解压到的文件夹,为空表示根目录
+	RootFolderID     *uint64   `json:"root_folder_id,omitempty"`                                       // 解压时新建的文件夹
+	Format           string    `gorm:"type:varchar(16);not null" json:"format"`
+	Status           string    `gorm:"type:varchar(16);not null;index" json:"status"`
+	TotalEntries     int64     `gorm:"not null;default:0" json:"total_entries"` // 压缩包中的条目数,TAR 需要读完才能确定,处理中为 0
+	ProcessedEntries int64     `gorm:"not null;default:0" json:"processed_entries"`
+	FileCount        int64     `gorm:"not null;default:0" json:"file_count"`      // 已创建的文件数
+	FolderCount      int64     `gorm:"not null;default:0" json:"folder_count"`    // 已创建的文件夹数,包含新建的根文件夹
+	SkippedEntries   int64     `gorm:"not null;default:0" json:"skipped_entries"` // 跳过的符号链接等不支持的条目数
+	ExtractedSize    uint64    `gorm:"not null;default:0" json:"extracted_size"`  // 已解压的文件总大小
+	Error            string    `gorm:"type:varchar(512);not null;default:''" json:"error,omitempty"`
+	CreatedAt        time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (ExtractJob) TableName() string {
+	return "extract_jobs"
+}
+
+// ExtractTask 发布到 RabbitMQ 的解压任务消息体
+type ExtractTask struct {
+	JobID  uint64 `json:"job_id"`
+	UserID uint64 `json:"user_id"`
+}
+
+// ExtractRequest 发起解压的请求体
+type ExtractRequest struct {
+	TargetFolderID *uint64 `json:"target_folder_id"` // 解压到的文件夹,为空时解压到压缩包所在的文件夹
+}
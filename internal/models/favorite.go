@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// FileFavorite 对应 file_favorites 表,用户收藏(加星标)的文件或文件夹
+type FileFavorite struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint64    `gorm:"not null;uniqueIndex:idx_user_file,priority:1" json:"user_id"`
+	FileID    uint64    `gorm:"not null;uniqueIndex:idx_user_file,priority:2;index" json:"file_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+
+	File *File `gorm:"foreignKey:FileID" json:"file,omitempty"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (FileFavorite) TableName() string {
+	return "file_favorites"
+}
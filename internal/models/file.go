@@ -1,37 +1,69 @@
 package models
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
 const (
-	StatusDeleted  = 0 // 已删除 (软删除)
-	StatusNormal   = 1 // 正常
-	StatusBanned   = 2 // 被禁用
-	StatusDeleting = 3 // 待删除 (进入异步删除队列)
+	StatusDeleted   = 0 // 已删除 (软删除)
+	StatusNormal    = 1 // 正常
+	StatusBanned    = 2 // 被禁用
+	StatusDeleting  = 3 // 待删除 (进入异步删除队列)
+	StatusCorrupted = 4 // 完整性校验发现存储内容与记录的哈希不一致
+)
+
+// 病毒扫描状态,未启用扫描时为空
+const (
+	ScanStatusPending  = "pending"  // 等待扫描
+	ScanStatusClean    = "clean"    // 未发现威胁
+	ScanStatusInfected = "infected" // 检测到病毒,文件已隔离,禁止下载
+	ScanStatusSkipped  = "skipped"  // 超出扫描大小限制,未扫描
 )
 
 // File 对应 files 表
 type File struct {
-	ID             uint64         `gorm:"primaryKey;autoIncrement" json:"id"`
-	UUID           string         `gorm:"type:varchar(36);unique;not null" json:"uuid"` // 文件在OSS中的唯一标识
-	UserID         uint64         `gorm:"not null" json:"user_id"`
-	ParentFolderID *uint64        `gorm:"default:null" json:"parent_folder_id"` // 父文件夹ID，根目录为 null
-	FileName       string         `gorm:"type:varchar(255);not null" json:"filename"`
-	Path           string         `gorm:"type:varchar(1024);not null;default:''" json:"path"`        // 逻辑路径
-	IsFolder       uint8          `gorm:"type:tinyint unsigned;not null;default:0" json:"is_folder"` // 1:文件夹, 0:文件
-	Size           uint64         `gorm:"type:bigint unsigned;not null;default:0" json:"size"`
-	MimeType       *string        `gorm:"type:varchar(128);default:null" json:"mime_type"`
-	OssBucket      *string        `gorm:"type:varchar(64);default:null" json:"oss_bucket"`
-	OssKey         *string        `gorm:"type:varchar(255);default:null" json:"oss_key"`
-	VersionID      *string        `gorm:"type:varchar(128);default:null" json:"version_id"`
-	MD5Hash        *string        `gorm:"type:varchar(32);default:null" json:"md5_hash"`
-	Status         uint8          `gorm:"type:tinyint unsigned;not null;default:1" json:"status"` // 1:正常, 0:回收站
-	CreatedAt      time.Time      `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt      time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt      gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	ID             uint64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	UUID           string  `gorm:"type:varchar(36);unique;not null" json:"uuid"` // 文件在OSS中的唯一标识
+	UserID         uint64  `gorm:"not null;index:idx_user_parent_name,priority:1;index:idx_user_mime,priority:1;index:idx_user_updated,priority:1" json:"user_id"`
+	ParentFolderID *uint64 `gorm:"default:null;index:idx_user_parent_name,priority:2" json:"parent_folder_id"` // 父文件夹ID，根目录为 null
+	FileName       string  `gorm:"type:varchar(255);not null;index:idx_user_parent_name,priority:3" json:"filename"`
+	Path           string  `gorm:"-" json:"path"`                                             // 父目录逻辑路径,如 "/a/b/",不保存到数据库,读取时按父文件夹链计算
+	IsFolder       uint8   `gorm:"type:tinyint unsigned;not null;default:0" json:"is_folder"` // 1:文件夹, 0:文件
+	Size           uint64  `gorm:"type:bigint unsigned;not null;default:0" json:"size"`
+	MimeType       *string `gorm:"type:varchar(128);default:null;index:idx_user_mime,priority:2" json:"mime_type"`
+	OssBucket      *string `gorm:"type:varchar(64);default:null" json:"oss_bucket"`
+	OssKey         *string `gorm:"type:varchar(255);default:null" json:"oss_key"`
+	VersionID      *string `gorm:"type:varchar(128);default:null" json:"version_id"`
+	MD5Hash        *string `gorm:"type:varchar(32);default:null" json:"md5_hash"`
+	SHA256Hash     *string `gorm:"type:char(64);default:null;index" json:"sha256_hash"`     // 服务端计算的内容哈希,用于秒传匹配和下载校验
+	Status         uint8   `gorm:"type:tinyint unsigned;not null;default:1" json:"status"`  // 1:正常, 0:回收站
+	ScanStatus     string  `gorm:"type:varchar(16);not null;default:''" json:"scan_status"` // 病毒扫描状态
+	Version        uint64  `gorm:"not null;default:0" json:"version"`                       // 元数据版本号,每次修改加一,用于乐观锁和 ETag
+	// SkipRecycleBin 文件夹设置,其中的文件和子文件夹删除时不进入回收站,直接彻底删除
+	SkipRecycleBin bool `gorm:"not null;default:false" json:"skip_recycle_bin"`
+	// IsLink 为 true 时是快捷方式,本身没有存储对象,下载时解析为 LinkTargetID 指向的文件
+	IsLink       bool    `gorm:"not null;default:false" json:"is_link"`
+	LinkTargetID *uint64 `gorm:"default:null;index" json:"link_target_id,omitempty"`
+	// LinkBroken 快捷方式指向的文件已被删除或不再可以访问,只在列表和详情中填充
+	LinkBroken bool `gorm:"-" json:"link_broken,omitempty"`
+	// Downloads 累计下载次数,只在元数据查询中填充,定期从 Redis 写入数据库,会有短暂延迟
+	Downloads int64 `gorm:"-" json:"downloads,omitempty"`
+	// PurgeAt 回收站中的文件按保留期将被彻底删除的时间,只在回收站列表中填充
+	PurgeAt *time.Time `gorm:"-" json:"purge_at,omitempty"`
+	// Attributes 自定义属性,如颜色、图标和备注,见 AttributeColor 等
+	Attributes FileAttributes `gorm:"type:json;serializer:json" json:"attributes,omitempty"`
+	// IntegrityCheckedAt 最近一次完整性校验的时间,为空表示从未校验
+	IntegrityCheckedAt *time.Time     `gorm:"index" json:"-"`
+	CreatedAt          time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt          time.Time      `gorm:"autoUpdateTime;index:idx_user_updated,priority:2" json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 
 	// 定义 GORM 关联，方便预加载
 	User         *User `gorm:"foreignKey:UserID" json:"-"`
@@ -42,3 +74,210 @@ type File struct {
 func (File) TableName() string {
 	return "files"
 }
+
+// 文件列表排序字段与排序方向
+const (
+	SortByName      = "name"
+	SortBySize      = "size"
+	SortByUpdatedAt = "updated_at"
+
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// FileSortFields 文件列表支持的全部排序字段
+var FileSortFields = []string{SortByName, SortBySize, SortByUpdatedAt}
+
+// FileListOptions 文件列表的分页与排序参数
+type FileListOptions struct {
+	Page     int    // 页码,从 1 开始
+	PageSize int    // 每页数量,<=0 表示不分页,返回全部
+	SortBy   string // name/size/updated_at,默认 name
+	Order    string // asc/desc,默认 asc
+	// Cursor 不为 nil 时按游标分页,忽略 Page,PageSize 为每页数量。游标只支持按名称排序
+	Cursor *ListCursor
+	Filter FileFilter
+}
+
+// Normalize 填充默认值,并校验排序字段、排序方向和过滤条件是否合法
+func (o *FileListOptions) Normalize() bool {
+	if !o.Filter.Normalize() {
+		return false
+	}
+	if o.Page < 1 {
+		o.Page = 1
+	}
+	if o.SortBy == "" {
+		o.SortBy = SortByName
+	}
+	if o.Order == "" {
+		o.Order = OrderAsc
+	}
+	if !slices.Contains(FileSortFields, o.SortBy) {
+		return false
+	}
+	if o.Cursor != nil && o.SortBy != SortByName {
+		return false
+	}
+	return o.Order == OrderAsc || o.Order == OrderDesc
+}
+
+// 文件列表和搜索按删除状态过滤
+const (
+	FilterStatusActive  = "active"  // 未删除的文件,默认
+	FilterStatusTrashed = "trashed" // 回收站中的文件
+	FilterStatusAll     = "all"     // 两者都包含
+)
+
+// FileFilter 文件列表和搜索的过滤条件,零值表示只返回未删除的文件
+type FileFilter struct {
+	Status        string     // active/trashed/all,默认 active
+	MinSize       *uint64    // 文件大小下限(含),设置大小范围时不返回文件夹
+	MaxSize       *uint64    // 文件大小上限(含)
+	MimePrefix    string     // 内容类型前缀,如 image/
+	ModifiedAfter *time.Time // 只返回在该时间之后修改过的文件
+}
+
+// Normalize 填充默认状态,并校验状态和大小范围是否合法
+func (f *FileFilter) Normalize() bool {
+	if f.Status == "" {
+		f.Status = FilterStatusActive
+	}
+	if f.Status != FilterStatusActive && f.Status != FilterStatusTrashed && f.Status != FilterStatusAll {
+		return false
+	}
+	return f.MinSize == nil || f.MaxSize == nil || *f.MinSize <= *f.MaxSize
+}
+
+// IsZero 没有设置任何过滤条件,即只返回未删除的文件
+func (f *FileFilter) IsZero() bool {
+	return (f.Status == "" || f.Status == FilterStatusActive) && f.MinSize == nil && f.MaxSize == nil &&
+		f.MimePrefix == "" && f.ModifiedAfter == nil
+}
+
+// Match 判断文件是否满足过滤条件,与仓库层的查询条件一致,用于过滤从其他来源取得的文件
+func (f *FileFilter) Match(file *File) bool {
+	switch f.Status {
+	case FilterStatusTrashed:
+		if !file.DeletedAt.Valid || file.Status == StatusDeleting {
+			return false
+		}
+	case FilterStatusAll:
+		if file.Status == StatusDeleting {
+			return false
+		}
+	default:
+		if file.DeletedAt.Valid {
+			return false
+		}
+	}
+	if f.MinSize != nil || f.MaxSize != nil {
+		if file.IsFolder == 1 || (f.MinSize != nil && file.Size < *f.MinSize) || (f.MaxSize != nil && file.Size > *f.MaxSize) {
+			return false
+		}
+	}
+	if f.MimePrefix != "" && (file.MimeType == nil || !strings.HasPrefix(*file.MimeType, f.MimePrefix)) {
+		return false
+	}
+	return f.ModifiedAfter == nil || file.UpdatedAt.After(*f.ModifiedAfter)
+}
+
+// TrashListOptions 回收站列表的游标分页参数
+type TrashListOptions struct {
+	Cursor *ListCursor // 为 nil 时从最近删除的文件开始
+	Limit  int         // 每页数量,<=0 表示返回全部
+	// ParentFolderID 不为 nil 时只列出删除前位于该文件夹中的文件,0 表示根目录
+	ParentFolderID *uint64
+}
+
+// TrashCounts 文件夹列表中回收站里的子项数量,只统计删除前直接位于文件夹中的文件和文件夹
+type TrashCounts struct {
+	Folder   int64            `json:"folder"`   // 当前文件夹
+	Children map[uint64]int64 `json:"children"` // 当前页中的子文件夹,没有已删除子项的文件夹不出现
+}
+
+// ListCursor 游标分页的位置,记录上一页最后一条记录的排序键。
+// 文件夹列表按 (is_folder, file_name, id) 定位,回收站按 (deleted_at, id) 定位,ID 为 0 表示第一页
+type ListCursor struct {
+	IsFolder  uint8      `json:"f,omitempty"`
+	FileName  string     `json:"n,omitempty"`
+	DeletedAt *time.Time `json:"d,omitempty"`
+	ID        uint64     `json:"i"`
+}
+
+// FileCursor 返回文件夹列表中位于 file 之后的游标
+func FileCursor(file *File) *ListCursor {
+	return &ListCursor{IsFolder: file.IsFolder, FileName: file.FileName, ID: file.ID}
+}
+
+// TrashCursor 返回回收站列表中位于 file 之后的游标
+func TrashCursor(file *File) *ListCursor {
+	deletedAt := file.DeletedAt.Time
+	return &ListCursor{DeletedAt: &deletedAt, ID: file.ID}
+}
+
+// Encode 编码为 URL 安全的字符串,客户端原样传回即可
+func (c *ListCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeListCursor 解析客户端传回的游标,空字符串表示第一页
+func DecodeListCursor(s string) (*ListCursor, error) {
+	cursor := &ListCursor{}
+	if s == "" {
+		return cursor, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cursor); err != nil {
+		return nil, err
+	}
+	if cursor.ID == 0 {
+		return nil, errors.New("cursor has no id")
+	}
+	return cursor, nil
+}
+
+// FileStat 文件的元数据摘要,供同步客户端判断本地副本是否最新,不访问对象存储
+type FileStat struct {
+	ID         uint64    `json:"id"`
+	FileName   string    `json:"filename"`
+	Path       string    `json:"path"`
+	IsFolder   uint8     `json:"is_folder"`
+	Size       uint64    `json:"size"`
+	MimeType   *string   `json:"mime_type"`
+	ETag       string    `json:"etag"` // 与下载接口返回的 ETag 相同,由 Version 生成,可用作 If-Match
+	VersionID  *string   `json:"version_id"`
+	Version    uint64    `json:"version"` // 元数据版本号,重命名、移动等操作也会增加
+	SHA256Hash *string   `json:"sha256_hash"`
+	MD5Hash    *string   `json:"md5_hash"`
+	Downloads  int64     `json:"downloads"` // 累计下载次数,文件夹为打包下载的次数
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ManifestEntry 同步清单中的一项,只包含同步客户端比对本地状态需要的字段
+type ManifestEntry struct {
+	ID       uint64  `json:"id"`
+	ParentID *uint64 `json:"parent_id"` // 根目录下的条目为 null
+	Name     string  `json:"name"`
+	IsFolder uint8   `json:"is_folder"`
+	Size     uint64  `json:"size"`
+	SHA256   string  `json:"sha256,omitempty"` // 服务端计算的内容哈希,文件夹和尚未计算哈希的文件为空
+	MTime    int64   `json:"mtime"`            // 最后修改时间,Unix 秒
+}
+
+// FileMetadata 批量元数据中的一项
+type FileMetadata struct {
+	File
+	Thumbnail string `json:"thumbnail,omitempty"` // 已生成的小尺寸预览,base64 编码的 JPEG,未生成时为空
+}
+
+// FolderSize 文件夹递归统计结果
+type FolderSize struct {
+	FolderID  uint64 `json:"folder_id"`
+	FileCount int64  `json:"file_count"` // 递归包含的文件数量,不含文件夹
+	TotalSize uint64 `json:"total_size"` // 所有文件未压缩的总字节数
+}
@@ -0,0 +1,11 @@
+package models
+
+// 常用的文件自定义属性,前端据此显示文件夹颜色、图标和备注,其他键由客户端自行约定
+const (
+	AttributeColor       = "color"       // 颜色,格式为 #rrggbb
+	AttributeIcon        = "icon"        // 图标名称
+	AttributeDescription = "description" // 备注
+)
+
+// FileAttributes 文件的自定义属性,以 JSON 保存在 files.attributes 列中
+type FileAttributes map[string]string
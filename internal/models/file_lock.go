@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// FileLock 文件编辑锁,保存在 Redis 中,过期后自动释放
+type FileLock struct {
+	FileID    uint64    `json:"file_id"`
+	UserID    uint64    `json:"user_id"` // 持有锁的用户
+	LockedAt  time.Time `json:"locked_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
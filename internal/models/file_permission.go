@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// 协作权限级别,数值越大权限越高
+const (
+	PermissionRead  uint8 = 1 // 只读: 浏览、下载、预览
+	PermissionWrite uint8 = 2 // 读写: 额外允许新建文件夹、重命名、移动和删除
+)
+
+// PermissionNames API 中使用的权限名称
+var PermissionNames = map[string]uint8{
+	"read":  PermissionRead,
+	"write": PermissionWrite,
+}
+
+// FilePermission 对应 file_permissions 表,文件夹所有者授予其他用户对整个子树的访问权限
+type FilePermission struct {
+	ID         uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	FileID     uint64    `gorm:"not null;uniqueIndex:idx_file_grantee,priority:1" json:"file_id"`                                       // 授权的文件夹ID
+	OwnerID    uint64    `gorm:"not null;index:idx_grantee_owner,priority:2" json:"owner_id"`                                           // 文件夹所有者
+	GranteeID  uint64    `gorm:"not null;uniqueIndex:idx_file_grantee,priority:2;index:idx_grantee_owner,priority:1" json:"grantee_id"` // 被授权用户
+	Permission uint8     `gorm:"type:tinyint unsigned;not null;default:1" json:"permission"`                                            // 1:只读, 2:读写
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// 关联被授权的文件夹,方便判断子树范围
+	Folder *File `gorm:"foreignKey:FileID" json:"folder,omitempty"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (FilePermission) TableName() string {
+	return "file_permissions"
+}
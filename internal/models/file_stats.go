@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// FileStats 对应 file_stats 表,保存文件夹的递归统计结果,
+// 由 FileStatsService 在文件变更后异步刷新,列表接口直接读取而不必逐层遍历
+type FileStats struct {
+	FileID      uint64    `gorm:"primaryKey;autoIncrement:false" json:"file_id"`
+	UserID      uint64    `gorm:"not null;index" json:"user_id"`
+	FileCount   int64     `gorm:"not null;default:0" json:"file_count"`   // 递归包含的文件数量
+	FolderCount int64     `gorm:"not null;default:0" json:"folder_count"` // 递归包含的子文件夹数量
+	TotalSize   uint64    `gorm:"type:bigint unsigned;not null;default:0" json:"total_size"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (FileStats) TableName() string {
+	return "file_stats"
+}
+
+// TrashStats 回收站统计结果
+type TrashStats struct {
+	ItemCount int64  `json:"item_count"` // 回收站中的文件和文件夹数量
+	FileCount int64  `json:"file_count"`
+	TotalSize uint64 `json:"total_size"` // 回收站中文件占用的总字节数
+}
@@ -8,15 +8,18 @@ import (
 
 // FileVersion 对应 file_versions 表，用于存储文件的历史版本
 type FileVersion struct {
-	ID        uint64         `gorm:"primaryKey;autoIncrement" json:"id"`
-	FileID    uint64         `gorm:"not null;index" json:"file_id"` // 关联到 files 表的主键
-	Version   uint           `gorm:"not null" json:"version"`
-	Size      uint64         `gorm:"not null" json:"size"`
-	OssKey    string         `gorm:"type:varchar(255);not null" json:"oss_key"`
-	VersionID string         `gorm:"type:varchar(128);not null" json:"version_id"` // MinIO 返回的版本 ID
-	MD5Hash   string         `gorm:"type:varchar(32);not null" json:"md5_hash"`
-	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	ID         uint64         `gorm:"primaryKey;autoIncrement" json:"id"`
+	FileID     uint64         `gorm:"not null;index" json:"file_id"` // 关联到 files 表的主键
+	Version    uint           `gorm:"not null" json:"version"`
+	Size       uint64         `gorm:"not null" json:"size"`
+	OssBucket  string         `gorm:"type:varchar(64);not null;default:''" json:"-"` // 版本对象所在的存储桶,为空表示默认存储桶
+	OssKey     string         `gorm:"type:varchar(255);not null" json:"oss_key"`
+	VersionID  string         `gorm:"type:varchar(128);not null" json:"version_id"` // MinIO 返回的版本 ID
+	MD5Hash    string         `gorm:"type:varchar(32);not null" json:"md5_hash"`
+	SHA256Hash string         `gorm:"type:char(64);not null;default:''" json:"sha256_hash"`
+	CreatedAt  time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	Downloads  int64          `gorm:"-" json:"downloads"` // 该版本的下载次数,只在版本列表中填充
 
 	File *File `gorm:"foreignKey:FileID" json:"-"`
 }
@@ -0,0 +1,85 @@
+package models
+
+import "time"
+
+// 生命周期规则的处理方式
+const (
+	LifecycleActionArchive = "archive" // 移动到目标文件夹
+	LifecycleActionDelete  = "delete"  // 移入回收站
+)
+
+// LifecycleRule 对应 lifecycle_rules 表,文件夹中超过指定天数未修改的文件自动归档或删除
+type LifecycleRule struct {
+	ID       uint64 `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID   uint64 `gorm:"not null;index" json:"user_id"`
+	FolderID uint64 `gorm:"not null;index" json:"folder_id"` // 规则作用的文件夹
+	Action   string `gorm:"type:varchar(16);not null" json:"action"`
+	AgeDays  int    `gorm:"not null" json:"age_days"` // 文件最后修改时间超过该天数时处理
+	// TargetFolderID 归档的目标文件夹,Action 为 archive 时必填
+	TargetFolderID *uint64 `gorm:"default:null" json:"target_folder_id"`
+	// IncludeSubfolders 为 true 时处理子文件夹中的文件,否则只处理文件夹下直接包含的文件
+	IncludeSubfolders bool `gorm:"not null;default:false" json:"include_subfolders"`
+	// DryRun 为 true 时定时任务只生成报告,不移动或删除文件
+	DryRun     bool             `gorm:"not null;default:false" json:"dry_run"`
+	Enabled    bool             `gorm:"not null;default:true" json:"enabled"`
+	LastRunAt  *time.Time       `json:"last_run_at"`
+	LastReport *LifecycleReport `gorm:"type:json;serializer:json" json:"last_report,omitempty"`
+	CreatedAt  time.Time        `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time        `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (LifecycleRule) TableName() string {
+	return "lifecycle_rules"
+}
+
+// LifecycleFailure 对应 lifecycle_failures 表,记录规则处理失败的文件,
+// 在 NextAttemptAt 之前不再处理该文件,避免反复失败的文件每次都排在最前面挡住其他文件
+type LifecycleFailure struct {
+	RuleID        uint64    `gorm:"primaryKey;autoIncrement:false"`
+	FileID        uint64    `gorm:"primaryKey;autoIncrement:false"`
+	Attempts      int       `gorm:"not null;default:0"`
+	NextAttemptAt time.Time `gorm:"not null"`
+	LastError     string    `gorm:"type:varchar(512);not null;default:''"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (LifecycleFailure) TableName() string {
+	return "lifecycle_failures"
+}
+
+// LifecycleReport 规则的一次执行结果,Files 最多记录前 100 个命中的文件
+type LifecycleReport struct {
+	DryRun  bool                  `json:"dry_run"`
+	RunAt   time.Time             `json:"run_at"`
+	Matched int                   `json:"matched"` // 本次命中的文件数
+	Applied int                   `json:"applied"` // 成功移动或删除的文件数,试运行时为 0
+	Failed  int                   `json:"failed"`
+	Files   []LifecycleReportItem `json:"files"`
+}
+
+// LifecycleReportItem 报告中的一个文件,Error 为处理失败的原因
+type LifecycleReportItem struct {
+	FileID    uint64    `json:"file_id"`
+	Path      string    `json:"path"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// LifecycleRuleRequest 创建生命周期规则的请求体
+type LifecycleRuleRequest struct {
+	FolderID          uint64  `json:"folder_id" binding:"required"`
+	Action            string  `json:"action" binding:"required,oneof=archive delete"`
+	AgeDays           int     `json:"age_days" binding:"required,min=1"`
+	TargetFolderID    *uint64 `json:"target_folder_id"`
+	IncludeSubfolders bool    `json:"include_subfolders"`
+	DryRun            bool    `json:"dry_run"`
+}
+
+// LifecycleRuleUpdateRequest 修改生命周期规则的请求体,字段为 nil 表示不修改
+type LifecycleRuleUpdateRequest struct {
+	AgeDays *int  `json:"age_days" binding:"omitempty,min=1"`
+	DryRun  *bool `json:"dry_run"`
+	Enabled *bool `json:"enabled"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// MediaMetadata 对应 media_metadata 表,上传图片和视频后异步提取,用于相册按拍摄时间排列
+type MediaMetadata struct {
+	FileID      uint64     `gorm:"primaryKey;autoIncrement:false" json:"file_id"`
+	UserID      uint64     `gorm:"not null;index" json:"user_id"`
+	VersionID   string     `gorm:"type:varchar(128);not null;default:''" json:"-"` // 提取时文件指向的对象版本
+	TakenAt     *time.Time `gorm:"index" json:"taken_at"`                          // 拍摄时间,没有 EXIF 等信息时为空
+	Width       int        `gorm:"not null;default:0" json:"width"`
+	Height      int        `gorm:"not null;default:0" json:"height"`
+	CameraModel string     `gorm:"type:varchar(128);not null;default:''" json:"camera_model"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (MediaMetadata) TableName() string {
+	return "media_metadata"
+}
+
+// GalleryItem 相册中的一张图片或一个视频。TakenAt 没有拍摄时间时使用上传时间
+type GalleryItem struct {
+	File        File      `json:"file"`
+	TakenAt     time.Time `json:"taken_at"`
+	Width       int       `json:"width,omitempty"`
+	Height      int       `json:"height,omitempty"`
+	CameraModel string    `json:"camera_model,omitempty"`
+}
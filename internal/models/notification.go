@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// 通知类型
+const (
+	NotificationShareAccess     = "share_access"     // 分享链接被访问
+	NotificationTransferIn      = "transfer_in"      // 收到其他用户发送的文件
+	NotificationQuotaWarning    = "quota_warning"    // 存储用量达到预警比例
+	NotificationVersionRestored = "version_restored" // 其他用户将文件还原到历史版本
+	NotificationArchiveReady    = "archive_ready"    // 文件夹打包完成,可以下载
+	NotificationExportReady     = "export_ready"     // 账户数据导出完成,可以下载
+	NotificationExtractDone     = "extract_done"     // 压缩包解压完成
+	NotificationShareReceived   = "share_received"   // 其他用户向自己分享了文件
+)
+
+// Notification 对应 notifications 表,站内通知。ResourceID 为通知关联的任务ID,如打包或导出任务
+type Notification struct {
+	ID         uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint64     `gorm:"not null;index:idx_notifications_user_read,priority:1" json:"user_id"`
+	Type       string     `gorm:"type:varchar(32);not null" json:"type"`
+	Title      string     `gorm:"type:varchar(255);not null;default:''" json:"title"`
+	Body       string     `gorm:"type:varchar(1024);not null;default:''" json:"body"`
+	FileID     *uint64    `gorm:"default:null" json:"file_id,omitempty"`
+	ResourceID *uint64    `gorm:"default:null" json:"resource_id,omitempty"`
+	ReadAt     *time.Time `gorm:"default:null;index:idx_notifications_user_read,priority:2" json:"read_at"`
+	CreatedAt  time.Time  `gorm:"index" json:"created_at"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (Notification) TableName() string {
+	return "notifications"
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// OAuth 令牌的前缀,认证中间件据此区分 OAuth 令牌、个人访问令牌和 JWT
+const (
+	OAuthAccessTokenPrefix  = "oat_"
+	OAuthRefreshTokenPrefix = "ort_"
+)
+
+// 第三方应用可以申请的权限范围
+const (
+	OAuthScopeFilesRead    = "files.read"    // 浏览、下载、预览文件
+	OAuthScopeFilesWrite   = "files.write"   // 上传和修改文件,包含 files.read
+	OAuthScopeSharesManage = "shares.manage" // 创建、查看和撤销分享链接
+)
+
+// OAuthScopes 全部可申请的权限范围
+var OAuthScopes = []string{OAuthScopeFilesRead, OAuthScopeFilesWrite, OAuthScopeSharesManage}
+
+// OAuthClient 对应 oauth_clients 表,即注册的第三方应用。密钥只保存 SHA-256,明文只在注册时返回一次
+type OAuthClient struct {
+	ID               uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	ClientID         string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"client_id"`
+	ClientSecretHash string    `gorm:"type:char(64);not null" json:"-"`
+	Name             string    `gorm:"type:varchar(64);not null" json:"name"`
+	RedirectURIs     string    `gorm:"type:text;not null" json:"redirect_uris"`  // 允许的回调地址,以空格分隔,授权时必须完全一致
+	Scopes           string    `gorm:"type:varchar(255);not null" json:"scopes"` // 应用可以申请的权限范围,以空格分隔
+	OwnerID          uint64    `gorm:"not null;index" json:"owner_id"`
+	CreatedAt        time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+// AllowsRedirectURI 回调地址是否已登记
+func (c *OAuthClient) AllowsRedirectURI(redirectURI string) bool {
+	for _, uri := range strings.Fields(c.RedirectURIs) {
+		if uri == redirectURI {
+			return true
+		}
+	}
+	return false
+}
+
+// OAuthToken 对应 oauth_tokens 表,一次授权对应一条记录,刷新时原地轮换访问令牌和刷新令牌。
+// 数据库只保存令牌的 SHA-256
+type OAuthToken struct {
+	ID               uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	ClientID         string     `gorm:"type:varchar(64);not null;index" json:"client_id"`
+	UserID           uint64     `gorm:"not null;index" json:"user_id"`
+	Scope            string     `gorm:"type:varchar(255);not null" json:"scope"` // 以空格分隔
+	AccessTokenHash  string     `gorm:"type:char(64);not null;uniqueIndex" json:"-"`
+	RefreshTokenHash string     `gorm:"type:char(64);not null;uniqueIndex" json:"-"`
+	AccessExpiresAt  time.Time  `gorm:"not null" json:"access_expires_at"`
+	RefreshExpiresAt time.Time  `gorm:"not null" json:"refresh_expires_at"`
+	LastUsedAt       *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt        time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (OAuthToken) TableName() string {
+	return "oauth_tokens"
+}
+
+// OAuthAuthorizationCode 保存在 Redis 中的授权码信息,只能使用一次
+type OAuthAuthorizationCode struct {
+	ClientID            string `json:"client_id"`
+	UserID              uint64 `json:"user_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	CodeChallenge       string `json:"code_challenge,omitempty"` // PKCE,只支持 S256
+	CodeChallengeMethod string `json:"code_challenge_method,omitempty"`
+}
+
+// OAuthConsent 授权确认页需要展示的信息
+type OAuthConsent struct {
+	ClientID    string   `json:"client_id"`
+	ClientName  string   `json:"client_name"`
+	RedirectURI string   `json:"redirect_uri"`
+	Scopes      []string `json:"scopes"`
+	State       string   `json:"state,omitempty"`
+}
+
+// OAuthAuthorization 用户已授权的第三方应用
+type OAuthAuthorization struct {
+	ID         uint64     `json:"id"`
+	ClientID   string     `json:"client_id"`
+	ClientName string     `json:"client_name"`
+	Scope      string     `json:"scope"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// OAuthTokenResponse 令牌接口的响应,字段遵循 RFC 6749
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// OAuthAuthorizeRequest 授权请求参数,授权确认页从查询参数读取,确认授权时以 JSON 提交
+type OAuthAuthorizeRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type" binding:"required"`
+	ClientID            string `form:"client_id" json:"client_id" binding:"required"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri" binding:"required"`
+	Scope               string `form:"scope" json:"scope"` // 以空格分隔,为空时申请应用登记的全部权限
+	State               string `form:"state" json:"state"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+}
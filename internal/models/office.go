@@ -0,0 +1,83 @@
+package models
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// OnlyOffice 文档类型
+const (
+	OfficeDocumentWord  = "word"
+	OfficeDocumentCell  = "cell"
+	OfficeDocumentSlide = "slide"
+)
+
+// OfficeDocumentTypes 支持在线编辑的扩展名及其对应的文档类型
+var OfficeDocumentTypes = map[string]string{
+	"docx": OfficeDocumentWord,
+	"xlsx": OfficeDocumentCell,
+	"pptx": OfficeDocumentSlide,
+}
+
+// OfficeFileType 返回文件名的小写扩展名,不带点
+func OfficeFileType(fileName string) string {
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(fileName), "."))
+}
+
+// 文档服务器回调的状态,见 OnlyOffice Docs 的 callbackUrl 说明
+const (
+	OfficeStatusEditing        = 1 // 正在编辑
+	OfficeStatusReadyForSave   = 2 // 所有用户关闭了文档,可以保存
+	OfficeStatusSaveError      = 3 // 文档服务器保存出错
+	OfficeStatusClosedNoChange = 4 // 关闭时没有修改
+	OfficeStatusForceSave      = 6 // 编辑过程中强制保存
+	OfficeStatusForceSaveError = 7 // 强制保存出错
+)
+
+// OfficeEditorConfig 传给 OnlyOffice 前端 DocsAPI.DocEditor 的配置,整体由 Token 签名
+type OfficeEditorConfig struct {
+	Document     OfficeDocument `json:"document"`
+	DocumentType string         `json:"documentType"`
+	EditorConfig OfficeEditor   `json:"editorConfig"`
+	Token        string         `json:"token,omitempty"`
+}
+
+type OfficeDocument struct {
+	FileType    string            `json:"fileType"`
+	Key         string            `json:"key"` // 文档内容的唯一标识,内容变化后必须改变
+	Title       string            `json:"title"`
+	URL         string            `json:"url"` // 文档服务器下载文档的地址
+	Permissions OfficePermissions `json:"permissions"`
+}
+
+type OfficePermissions struct {
+	Edit     bool `json:"edit"`
+	Download bool `json:"download"`
+}
+
+type OfficeEditor struct {
+	CallbackURL string     `json:"callbackUrl"`
+	Mode        string     `json:"mode"` // edit 或 view
+	User        OfficeUser `json:"user"`
+}
+
+type OfficeUser struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// OfficeSession 打开在线编辑器所需的信息
+type OfficeSession struct {
+	// APIURL 编辑器脚本地址,前端加载后使用 Config 创建 DocsAPI.DocEditor
+	APIURL string              `json:"api_url"`
+	Config *OfficeEditorConfig `json:"config"`
+}
+
+// OfficeCallback 文档服务器回调的请求体
+type OfficeCallback struct {
+	Key    string   `json:"key"`
+	Status int      `json:"status"`
+	URL    string   `json:"url"`   // 编辑结果的下载地址,状态为 2 和 6 时提供
+	Users  []string `json:"users"` // 正在编辑或参与了编辑的用户ID
+	Token  string   `json:"token"` // 开启 JWT 时请求体的签名
+}
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// 组织成员角色
+const (
+	OrgRoleOwner  = "owner"  // 所有者: 全部操作,包括分配管理员和所有者
+	OrgRoleAdmin  = "admin"  // 管理员: 管理成员和分享,彻底删除团队文件
+	OrgRoleMember = "member" // 成员: 浏览、上传、编辑和删除团队文件
+)
+
+// OrgRoleRanks 组织角色的级别,数值越大权限越高
+var OrgRoleRanks = map[string]int{
+	OrgRoleMember: 1,
+	OrgRoleAdmin:  2,
+	OrgRoleOwner:  3,
+}
+
+// Organization 对应 organizations 表。每个组织有一个不能登录的网盘账号(角色为 org)持有团队文件,
+// 团队文件的存储配额就是该账号的配额,所有成员共用
+type Organization struct {
+	ID           uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name         string    `gorm:"type:varchar(64);not null" json:"name"`
+	DriveUserID  uint64    `gorm:"not null;uniqueIndex" json:"drive_user_id"` // 持有团队文件的网盘账号
+	RootFolderID uint64    `gorm:"not null" json:"root_folder_id"`            // 团队根文件夹
+	CreatedBy    uint64    `gorm:"not null" json:"created_by"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (Organization) TableName() string {
+	return "organizations"
+}
+
+// OrganizationMember 对应 organization_members 表
+type OrganizationMember struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	OrgID     uint64    `gorm:"not null;uniqueIndex:idx_org_user,priority:1" json:"org_id"`
+	UserID    uint64    `gorm:"not null;uniqueIndex:idx_org_user,priority:2;index" json:"user_id"`
+	Role      string    `gorm:"type:varchar(16);not null;default:'member'" json:"role"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Organization *Organization `gorm:"foreignKey:OrgID" json:"organization,omitempty"`
+	User         *User         `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (OrganizationMember) TableName() string {
+	return "organization_members"
+}
+
+// OrganizationDetail 组织详情,包含当前用户的角色和团队空间用量
+type OrganizationDetail struct {
+	Organization
+	Role  string        `json:"role"`
+	Usage *StorageUsage `json:"usage,omitempty"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 发件箱事件的投递目标
+const (
+	OutboxTargetStream = "stream" // Redis Stream
+	OutboxTargetQueue  = "queue"  // RabbitMQ 队列
+)
+
+// 发件箱事件的状态
+const (
+	OutboxStatusPending = "pending" // 等待投递或重试
+	OutboxStatusFailed  = "failed"  // 超过最大重试次数,不再投递,保留用于排查和人工重放
+)
+
+// OutboxEvent 对应 outbox_events 表,和业务数据在同一事务中写入,由 OutboxWorker 投递后删除,
+// 保证事务提交后消息至少投递一次
+type OutboxEvent struct {
+	ID      uint64 `gorm:"primaryKey;autoIncrement" json:"id"`
+	Target  string `gorm:"type:varchar(16);not null" json:"target"`
+	Topic   string `gorm:"type:varchar(128);not null" json:"topic"` // Stream 名称或队列名称
+	Payload []byte `gorm:"type:mediumblob;not null" json:"-"`
+	Status  string `gorm:"type:varchar(16);not null;default:'pending';index" json:"status"`
+	// Attempts 投递失败的次数,NextAttemptAt 之前不会再次投递
+	Attempts      int       `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time `gorm:"not null;index" json:"next_attempt_at"`
+	LastError     string    `gorm:"type:varchar(512)" json:"last_error"`
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (OutboxEvent) TableName() string {
+	return "outbox_events"
+}
+
+// NewStreamEvent 创建写入 Redis Stream 的事件,消息序列化为 JSON 放在 payload 字段中
+func NewStreamEvent(stream string, message any) (OutboxEvent, error) {
+	return newOutboxEvent(OutboxTargetStream, stream, message)
+}
+
+// NewQueueEvent 创建投递到 RabbitMQ 队列的事件,消息序列化为 JSON 作为消息体
+func NewQueueEvent(queue string, message any) (OutboxEvent, error) {
+	return newOutboxEvent(OutboxTargetQueue, queue, message)
+}
+
+func newOutboxEvent(target, topic string, message any) (OutboxEvent, error) {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return OutboxEvent{}, err
+	}
+	return OutboxEvent{
+		Target:        target,
+		Topic:         topic,
+		Payload:       payload,
+		Status:        OutboxStatusPending,
+		NextAttemptAt: time.Now(),
+	}, nil
+}
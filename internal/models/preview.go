@@ -0,0 +1,44 @@
+package models
+
+import "strings"
+
+// 预览尺寸
+const (
+	PreviewSizeSmall  = "small"
+	PreviewSizeMedium = "medium"
+	PreviewSizeLarge  = "large"
+)
+
+// PreviewSizes 各预览尺寸对应的最长边像素上限
+var PreviewSizes = map[string]int{
+	PreviewSizeSmall:  320,
+	PreviewSizeMedium: 720,
+	PreviewSizeLarge:  1280,
+}
+
+// 支持预览的媒体类型
+const (
+	PreviewKindImage = "image"
+	PreviewKindVideo = "video"
+)
+
+// PreviewKind 根据 MIME 类型判断预览类型,不支持时返回空字符串
+func PreviewKind(mimeType *string) string {
+	if mimeType == nil {
+		return ""
+	}
+	switch {
+	case strings.HasPrefix(*mimeType, "image/"):
+		return PreviewKindImage
+	case strings.HasPrefix(*mimeType, "video/"):
+		return PreviewKindVideo
+	default:
+		return ""
+	}
+}
+
+// PreviewInfo 转码后预览内容的元数据
+type PreviewInfo struct {
+	ContentType string
+	Size        int64
+}
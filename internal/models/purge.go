@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// 彻底删除任务状态
+const (
+	PurgeStatusPending   = "pending"   // 等待 Worker 处理,失败后等待重试时也是这个状态
+	PurgeStatusRunning   = "running"   // 正在分批删除
+	PurgeStatusCompleted = "completed" // 已全部删除
+	// PurgeStatusFailed 重试次数用完后放弃,已删除的部分不会恢复,剩余的文件回到回收站,重新发起彻底删除会继续处理
+	PurgeStatusFailed = "failed"
+)
+
+// PurgeJob 对应 purge_jobs 表,记录文件或文件夹彻底删除的进度。
+// 发起时整棵子树同步标记为待删除,之后由 Worker 分批删除版本、存储对象和数据库记录
+type PurgeJob struct {
+	ID          uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      uint64     `gorm:"not null;index" json:"user_id"`
+	FileID      uint64     `gorm:"not null;index" json:"file_id"` // 被彻底删除的文件或文件夹
+	FileName    string     `gorm:"type:varchar(255);not null" json:"file_name"`
+	Status      string     `gorm:"type:varchar(16);not null;index" json:"status"`
+	TotalItems  int64      `gorm:"not null;default:0" json:"total_items"`  // 子树中的文件和文件夹总数,包括自身
+	PurgedItems int64      `gorm:"not null;default:0" json:"purged_items"` // 已删除的数量
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`     // 已失败的次数
+	Error       string     `gorm:"type:varchar(512);not null;default:''" json:"error,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (PurgeJob) TableName() string {
+	return "purge_jobs"
+}
+
+// PurgeTask 发布到 RabbitMQ 的彻底删除任务消息体
+type PurgeTask struct {
+	JobID uint64 `json:"job_id"`
+}
@@ -0,0 +1,22 @@
+package models
+
+// 文件搜索的范围
+const (
+	SearchInName    = "name"    // 按文件名搜索,直接查询数据库
+	SearchInContent = "content" // 按文档内容和自定义属性搜索,需要开启 search.enabled
+)
+
+// FileSearchOptions 文件搜索条件
+type FileSearchOptions struct {
+	Query    string
+	In       string // SearchInName 或 SearchInContent
+	Filter   FileFilter
+	Page     int
+	PageSize int
+}
+
+// FileSearchResult 一条搜索结果
+type FileSearchResult struct {
+	File
+	Snippet string `json:"snippet,omitempty"` // 按内容搜索时命中内容的片段,匹配词用 <em> 包裹
+}
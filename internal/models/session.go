@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Session 登录会话,保存在 Redis 中,每个会话持有一个刷新 Token
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     uint64    `json:"user_id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	TwoFactor  bool      `json:"2fa"` // 登录时是否通过了两步验证
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"` // 最近一次刷新 Token 的时间
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Current 是否为发起请求的会话,只在会话列表中填充
+	Current bool `json:"current"`
+}
@@ -6,23 +6,46 @@ import (
 	"gorm.io/gorm"
 )
 
+// 站内分享的权限级别
+const (
+	SharePermissionRead     = "read"     // 只能查看文件信息和浏览文件夹
+	SharePermissionDownload = "download" // 可以查看和下载
+)
+
 type Share struct {
-	ID          uint64         `gorm:"primaryKey;autoIncrement" json:"id"`
-	UUID        string         `gorm:"type:varchar(36);not null;uniqueIndex" json:"uuid"` // 唯一分享ID，用于生成链接
-	UserID      uint64         `gorm:"not null;index" json:"user_id"`                     // 分享者ID
-	FileID      uint64         `gorm:"not null;index" json:"file_id"`                     // 被分享的文件或文件夹ID
-	Password    *string        `gorm:"type:varchar(255)" json:"password,omitempty"`       // 可选：分享密码的哈希值
-	ExpiresAt   *time.Time     `json:"expires_at,omitempty"`                              // 可选：分享链接过期时间
-	AccessCount int64          `gorm:"default:0" json:"access_count"`                     // 访问次数（可选）
-	Status      int            `gorm:"type:tinyint;default:1" json:"status"`              // 1: 可用, 0: 被取消/过期
-	CreatedAt   time.Time      `gorm:"not null" json:"created_at"`
-	UpdatedAt   time.Time      `gorm:"not null" json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	ID          uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UUID        string     `gorm:"type:varchar(36);not null;uniqueIndex" json:"uuid"` // 唯一分享ID，用于生成链接
+	UserID      uint64     `gorm:"not null;index" json:"user_id"`                     // 分享者ID
+	FileID      uint64     `gorm:"not null;index" json:"file_id"`                     // 被分享的文件或文件夹ID
+	Password    *string    `gorm:"type:varchar(255)" json:"password,omitempty"`       // 可选：分享密码的哈希值
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`                              // 可选：分享链接过期时间
+	AccessCount int64      `gorm:"default:0" json:"access_count"`                     // 访问次数（可选）
+	Status      int        `gorm:"type:tinyint;default:1" json:"status"`              // 1: 可用, 0: 被取消/过期
+	Direct      bool       `gorm:"not null;default:false" json:"direct"`              // 是否为直链分享,直链无需认证即可直接访问文件内容
+	// BandwidthLimit 管理员为该分享设置的下载限速(字节/秒),0 表示使用配置的默认值,-1 表示不限速
+	BandwidthLimit int64 `gorm:"not null;default:0" json:"bandwidth_limit"`
+	// RecipientID 站内分享的接收者,为空时是公开链接。站内分享只有接收者登录后可以访问,不能通过 UUID 链接访问
+	RecipientID *uint64 `gorm:"default:null;index" json:"recipient_id,omitempty"`
+	// Permission 站内分享的权限级别,见 SharePermissionRead 等,公开链接为空
+	Permission string         `gorm:"type:varchar(16);not null;default:''" json:"permission,omitempty"`
+	CreatedAt  time.Time      `gorm:"not null" json:"created_at"`
+	UpdatedAt  time.Time      `gorm:"not null" json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 
 	// 关系File模型预加载
 	File *File `gorm:"foreignKey:FileID"` // 关联到文件模型，方便查询文件详情
 }
 
+// IsInternal 是否为指定接收者的站内分享
+func (s *Share) IsInternal() bool {
+	return s.RecipientID != nil
+}
+
+// CanDownload 站内分享是否允许下载,公开链接总是允许
+func (s *Share) CanDownload() bool {
+	return !s.IsInternal() || s.Permission == SharePermissionDownload
+}
+
 // 指定gorm的表名
 func (Share) TableName() string {
 	return "shares"
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// 分享访问日志的操作类型
+const (
+	ShareAccessView     = "view"     // 查看分享详情
+	ShareAccessDownload = "download" // 下载分享内容(包括直链访问)
+)
+
+// ShareAccessLog 对应 share_access_logs 表,记录分享链接的每次访问,用于分享者查看访问统计
+type ShareAccessLog struct {
+	ID          uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	ShareID     uint64    `gorm:"not null;index:idx_share_created,priority:1" json:"share_id"`
+	Action      string    `gorm:"type:varchar(16);not null" json:"action"`
+	IP          string    `gorm:"type:varchar(64)" json:"ip"`
+	UserAgent   string    `gorm:"type:varchar(512)" json:"user_agent"`
+	Referer     string    `gorm:"type:varchar(1024)" json:"referer"`
+	RefererHost string    `gorm:"type:varchar(255)" json:"referer_host"` // 来源页面的域名,为空表示直接访问
+	CreatedAt   time.Time `gorm:"not null;index:idx_share_created,priority:2" json:"created_at"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (ShareAccessLog) TableName() string {
+	return "share_access_logs"
+}
+
+// ShareDailyCount 分享在某一天的访问次数
+type ShareDailyCount struct {
+	Date      string `json:"date"` // 格式为 2006-01-02
+	Views     int64  `json:"views"`
+	Downloads int64  `json:"downloads"`
+}
+
+// ShareRefererCount 来源域名及对应的访问次数
+type ShareRefererCount struct {
+	Host  string `json:"host"`
+	Count int64  `json:"count"`
+}
+
+// ShareAnalytics 分享链接在统计区间内的访问概况
+type ShareAnalytics struct {
+	ShareID        uint64              `json:"share_id"`
+	Days           int                 `json:"days"`
+	TotalViews     int64               `json:"total_views"`
+	TotalDownloads int64               `json:"total_downloads"`
+	FileDownloads  int64               `json:"file_downloads"` // 分享文件的累计下载次数,包含所有者和其他途径的下载
+	Daily          []ShareDailyCount   `json:"daily"`
+	TopReferrers   []ShareRefererCount `json:"top_referrers"`
+}
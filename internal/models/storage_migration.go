@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// 存储迁移任务状态
+const (
+	MigrationStatusPending   = "pending"   // 等待 Worker 处理
+	MigrationStatusRunning   = "running"   // 正在迁移
+	MigrationStatusCompleted = "completed" // 已完成
+	MigrationStatusFailed    = "failed"    // 出错或被中断,可以从 LastFileID 之后继续
+)
+
+// StorageMigration 对应 storage_migrations 表,把文件的存储对象从其他后端搬到目标后端。
+// 按文件ID顺序逐个迁移,LastFileID 记录进度,中断后从下一个文件继续
+type StorageMigration struct {
+	ID            uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	TargetBackend string     `gorm:"type:varchar(16);not null" json:"target_backend"` // 目标存储类型,如 minio
+	TargetBucket  string     `gorm:"type:varchar(64);not null" json:"target_bucket"`
+	DeleteSource  bool       `gorm:"not null;default:false" json:"delete_source"` // 迁移后删除源后端中不再被引用的对象
+	Rebalance     bool       `gorm:"not null;default:false" json:"rebalance"`     // 按分片策略重新分布文件,每个文件的目标存储桶由分片策略决定
+	Status        string     `gorm:"type:varchar(16);not null;index" json:"status"`
+	LastFileID    uint64     `gorm:"not null;default:0" json:"last_file_id"`
+	MigratedFiles int64      `gorm:"not null;default:0" json:"migrated_files"`
+	MigratedBytes uint64     `gorm:"not null;default:0" json:"migrated_bytes"`
+	FailedFiles   int64      `gorm:"not null;default:0" json:"failed_files"` // 迁移失败跳过的文件,重新发起迁移时会再次尝试
+	Error         string     `gorm:"type:varchar(512);not null;default:''" json:"error,omitempty"`
+	CreatedBy     uint64     `gorm:"not null" json:"created_by"` // 发起的管理员,迁移命令创建时为 0
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (StorageMigration) TableName() string {
+	return "storage_migrations"
+}
+
+// StorageMigrationTask 发布到 RabbitMQ 的存储迁移任务消息体
+type StorageMigrationTask struct {
+	MigrationID uint64 `json:"migration_id"`
+}
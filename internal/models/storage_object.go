@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// StorageObject 对应 storage_objects 表,记录存储对象(存储桶、key 和版本)被多少条版本记录引用。
+// 秒传和转存会让多个文件共享同一个对象,版本记录的创建和彻底删除与引用计数在同一事务中更新,
+// 引用数降为 0 的对象由删除流程认领后删除物理文件,同一对象只会被认领一次
+type StorageObject struct {
+	ID         uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Bucket     string    `gorm:"type:varchar(64);not null;default:'';uniqueIndex:idx_bucket_object_version,priority:1" json:"bucket"` // 为空表示默认存储桶
+	OssKey     string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_bucket_object_version,priority:2" json:"oss_key"`
+	VersionID  string    `gorm:"type:varchar(128);not null;uniqueIndex:idx_bucket_object_version,priority:3" json:"version_id"`
+	SHA256Hash string    `gorm:"type:char(64);not null;default:''" json:"sha256_hash"`
+	Size       uint64    `gorm:"not null" json:"size"`
+	RefCount   uint64    `gorm:"not null;default:0;index" json:"ref_count"` // 引用该对象的版本记录数,包括回收站中文件的版本
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (StorageObject) TableName() string {
+	return "storage_objects"
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// 移动文件时标签的处理方式
+const (
+	TagPropagationKeep    = "keep"    // 保留原有标签(默认)
+	TagPropagationInherit = "inherit" // 保留原有标签,并添加目标文件夹的标签
+	TagPropagationReplace = "replace" // 用目标文件夹的标签替换原有标签
+)
+
+// FileTag 对应 file_tags 表,文件和标签的多对多关系。标签属于打标签的用户,协作者的标签互不可见
+type FileTag struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint64    `gorm:"not null;uniqueIndex:idx_user_file_tag,priority:1;index:idx_user_tag,priority:1" json:"user_id"`
+	FileID    uint64    `gorm:"not null;uniqueIndex:idx_user_file_tag,priority:2;index" json:"file_id"`
+	Tag       string    `gorm:"type:varchar(64);not null;uniqueIndex:idx_user_file_tag,priority:3;index:idx_user_tag,priority:2" json:"tag"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (FileTag) TableName() string {
+	return "file_tags"
+}
+
+// TagCount 用户使用过的标签及对应的文件数量
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int64  `json:"count"`
+}
@@ -4,6 +4,38 @@ package models
 type DeleteFileTask struct {
 	FileID    uint64 `json:"file_id"`
 	UserID    uint64 `json:"user_id"`
+	Bucket    string `json:"bucket,omitempty"` // 对象所在的存储桶,为空表示默认存储桶
 	OssKey    string `json:"oss_key"`
 	VersionID string `json:"version_id,omitempty"`
 }
+
+// ScanFileTask 上传完成后发布的病毒扫描任务
+type ScanFileTask struct {
+	FileID    uint64 `json:"file_id"`
+	UserID    uint64 `json:"user_id"`
+	OssBucket string `json:"oss_bucket"`
+	OssKey    string `json:"oss_key"`
+	VersionID string `json:"version_id,omitempty"`
+	Size      uint64 `json:"size"`
+}
+
+// ExtractMediaTask 上传图片或视频后发布的元数据提取任务
+type ExtractMediaTask struct {
+	FileID    uint64 `json:"file_id"`
+	OssBucket string `json:"oss_bucket"`
+	OssKey    string `json:"oss_key"`
+	VersionID string `json:"version_id,omitempty"`
+	MimeType  string `json:"mime_type"`
+}
+
+// ContentIndexTask 上传文本、docx 或 PDF 文件后发布的内容提取和索引任务
+type ContentIndexTask struct {
+	FileID    uint64 `json:"file_id"`
+	UserID    uint64 `json:"user_id"`
+	OssBucket string `json:"oss_bucket"`
+	OssKey    string `json:"oss_key"`
+	VersionID string `json:"version_id,omitempty"`
+	Kind      string `json:"kind"` // textextract.Kind
+	// AttributesOnly 自定义属性修改后只更新索引中的属性,不重新提取内容
+	AttributesOnly bool `json:"attributes_only,omitempty"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// RecoveryCodeCount 开启两步验证时生成的恢复码数量
+const RecoveryCodeCount = 10
+
+// TwoFactorRecoveryCode 两步验证恢复码,只保存哈希,每个恢复码只能使用一次
+type TwoFactorRecoveryCode struct {
+	ID        uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint64     `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"type:char(64);not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (TwoFactorRecoveryCode) TableName() string {
+	return "two_factor_recovery_codes"
+}
@@ -2,10 +2,27 @@ package models
 
 import "time"
 
+// 上传策略
+const (
+	UploadStrategySingle    = "single"    // 单次 PUT 上传,整个文件作为唯一的分片提交
+	UploadStrategyMultipart = "multipart" // 分片上传
+	UploadStrategyInstant   = "instant"   // 秒传,存储中已有相同内容,直接调用完成接口即可
+)
+
+// 秒传匹配范围
+const (
+	DedupScopeGlobal = "global" // 匹配所有用户的文件
+	DedupScopeUser   = "user"   // 只匹配自己的文件
+	DedupScopeOff    = "off"    // 关闭秒传
+)
+
 // UploadInitRequest 定义了初始化分片上传的请求体
 type UploadInitRequest struct {
 	FileName string `json:"fileName" binding:"required"`
 	FileHash string `json:"fileHash" binding:"required"`
+	FileSize int64  `json:"fileSize" binding:"gte=0"` // 文件总大小,用于协商上传策略,为 0 时按分片上传处理
+	// FileSHA256 文件内容的 SHA-256,提供时优先用于秒传匹配
+	FileSHA256 string `json:"fileSha256" binding:"omitempty,len=64,hexadecimal"`
 }
 
 // UploadInitResponse 定义了初始化分片上传的响应体
@@ -13,6 +30,26 @@ type UploadInitResponse struct {
 	FileExists    bool             `json:"fileExists"`
 	UploadID      string           `json:"uploadID"`
 	UploadedParts []UploadPartInfo `json:"uploadedParts"`
+	Strategy      string           `json:"strategy"`    // single 或 multipart
+	ChunkSize     int64            `json:"chunkSize"`   // 除最后一片外每个分片的大小
+	TotalChunks   int64            `json:"totalChunks"` // 分片总数,文件大小未知时为 0
+	// ProofChallenge 不为空时可以通过内容证明尝试秒传,证明未通过时按 UploadID 正常上传即可
+	ProofChallenge *UploadProofChallenge `json:"proofChallenge,omitempty"`
+}
+
+// UploadProofChallenge 秒传内容证明的挑战。
+// 客户端计算 SHA-256(Salt 解码后的字节 + 文件中 [Offset, Offset+Length) 的内容),以十六进制提交。
+// 是否下发挑战与存储中是否已有相同内容无关,不会泄露其他用户的文件是否存在
+type UploadProofChallenge struct {
+	Salt   string `json:"salt"` // 十六进制编码的随机盐
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+}
+
+// UploadProofRequest 提交秒传内容证明的请求体
+type UploadProofRequest struct {
+	UploadID string `json:"uploadID" binding:"required"`
+	Proof    string `json:"proof" binding:"required,len=64,hexadecimal"`
 }
 
 // UploadPartInfo 包含了已上传分块的信息
@@ -25,7 +62,7 @@ type UploadPartInfo struct {
 type UploadChunkRequest struct {
 	UploadID    string `form:"uploadID" binding:"required"`
 	ChunkNumber int    `form:"chunkNumber" binding:"required"`
-	ChunkSize   int64  `form:"chunkSize" binding:"required"`
+	ChunkSize   int64  `form:"chunkSize" binding:"min=0"` // 空文件只有一个大小为 0 的分片
 	FileHash    string `form:"fileHash" binding:"required"`
 	FileName    string `form:"fileName" binding:"required"`
 }
@@ -37,7 +74,41 @@ type UploadCompleteRequest struct {
 	FileName       string  `json:"fileName" binding:"required"`
 	MimeType       string  `json:"mimeType"`
 	ParentFolderID *uint64 `json:"parentFolderID"`
-	UploadMode     string  `json:"uploadMode"` // "version" or "rename"
+	// UploadMode 同名文件已存在时的处理方式,为空时默认为 version
+	UploadMode string `json:"uploadMode" binding:"omitempty,oneof=version rename overwrite skip"`
+	// FileSHA256 客户端计算的 SHA-256,提供时与服务端计算结果比对
+	FileSHA256 string `json:"fileSha256" binding:"omitempty,len=64,hexadecimal"`
+}
+
+// 同名文件已存在时的处理方式
+const (
+	UploadModeVersion   = "version"   // 作为已有文件的新版本
+	UploadModeRename    = "rename"    // 自动重命名后创建新文件
+	UploadModeOverwrite = "overwrite" // 替换最新版本的内容,不产生新版本
+	UploadModeSkip      = "skip"      // 保留已有文件,丢弃本次上传的内容
+)
+
+// 上传完成后实际执行的操作
+const (
+	UploadActionCreated     = "created"
+	UploadActionVersioned   = "versioned"
+	UploadActionRenamed     = "renamed"
+	UploadActionOverwritten = "overwritten"
+	UploadActionSkipped     = "skipped"
+)
+
+// UploadCompleteResponse 完成上传的响应,在文件记录的基础上说明实际执行的操作,便于同步客户端实现冲突策略
+type UploadCompleteResponse struct {
+	*File
+	Action  string `json:"action"`
+	Skipped bool   `json:"skipped"` // 同名文件已存在且按 skip 模式保留,返回的是已有文件
+}
+
+// UploadPartsReport 完成上传前核对分片的结果,客户端只需要重新上传其中列出的分片
+type UploadPartsReport struct {
+	TotalChunks     int   `json:"totalChunks"`     // 预期的分片总数
+	MissingParts    []int `json:"missingParts"`    // 存储中不存在的分片
+	MismatchedParts []int `json:"mismatchedParts"` // 存储中的 ETag 与上传时记录的不一致,可能被重新上传过或记录已丢失
 }
 
 // MultipartUpload 对应数据库中的 multipart_uploads 表，用于持久化分片上传任务
@@ -46,8 +117,12 @@ type MultipartUpload struct {
 	FileHash   string `gorm:"type:varchar(255);not null;index:idx_file_hash,unique"`
 	UploadID   string `gorm:"type:varchar(255);not null"`
 	ObjectName string `gorm:"type:varchar(1024);not null"`
+	Bucket     string `gorm:"type:varchar(64);not null;default:''"` // 会话开始时选定的存储桶,为空表示默认存储桶
 	UserID     uint64 `gorm:"not null;index"`
-	Status     string `gorm:"type:varchar(20);not null;default:'in_progress'"` // in_progress, completed, aborted
+	Status     string `gorm:"type:varchar(20);not null;default:'in_progress'"` // in_progress, completed, aborted, expired
+	Strategy   string `gorm:"type:varchar(20);not null;default:'multipart'"`   // single, multipart
+	FileSize   int64  `gorm:"not null;default:0"`                              // 文件总大小,0 表示未知
+	ChunkSize  int64  `gorm:"not null;default:0"`                              // 协商的分片大小
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
 }
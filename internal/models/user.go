@@ -6,6 +6,21 @@ import (
 	"gorm.io/gorm"
 )
 
+// 用户角色
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+	// RoleOrg 组织的网盘账号,只用于持有团队文件,不能登录
+	RoleOrg = "org"
+)
+
+// 用户状态
+const (
+	UserStatusDeleted  = 0 // 已注销,账号信息已匿名化
+	UserStatusActive   = 1
+	UserStatusDeleting = 2 // 已申请注销,不能再登录,等待后台清除数据
+)
+
 // User 对应 users 表
 type User struct {
 	ID           uint64 `gorm:"primaryKey;autoIncrement" json:"id"`
@@ -15,6 +30,18 @@ type User struct {
 	TotalSpace   uint64 `gorm:"type:bigint unsigned;not null;default:0" json:"total_space"`
 	UsedSpace    uint64 `gorm:"type:bigint unsigned;not null;default:0" json:"used_space"`
 	Status       uint8  `gorm:"type:tinyint unsigned;not null;default:1" json:"status"`
+	Role         string `gorm:"type:varchar(16);not null;default:'user'" json:"role"`
+	// EmailVerifiedAt 邮箱验证时间,为空表示未验证,未验证的用户不能创建分享
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	// TOTPSecret 两步验证密钥(Base32),开始设置后即写入,TwoFactorEnabledAt 不为空时才生效
+	TOTPSecret         string     `gorm:"column:totp_secret;type:varchar(64);not null;default:''" json:"-"`
+	TwoFactorEnabledAt *time.Time `json:"two_factor_enabled_at,omitempty"`
+	// BandwidthLimit 管理员为该用户设置的下载限速(字节/秒),0 表示使用配置的默认值,-1 表示不限速
+	BandwidthLimit int64 `gorm:"not null;default:0" json:"bandwidth_limit"`
+	// DedupOptOut 不参与跨用户秒传:自己的文件不会作为其他用户的秒传来源,上传时也只匹配自己的文件
+	DedupOptOut bool `gorm:"not null;default:false" json:"dedup_opt_out"`
+	// TrashRetentionDays 回收站中的文件保留天数,0 表示使用配置的默认值
+	TrashRetentionDays int `gorm:"not null;default:0" json:"trash_retention_days"`
 
 	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
@@ -25,3 +52,9 @@ type User struct {
 func (User) TableName() string {
 	return "users"
 }
+
+// UserSettingsUpdate 用户可以自行修改的设置,为 nil 的字段保持不变
+type UserSettingsUpdate struct {
+	DedupOptOut        *bool
+	TrashRetentionDays *int // 0 表示恢复默认值
+}
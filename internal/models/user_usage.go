@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// 存储用量的文件类型分类
+const (
+	UsageTypeImage    = "image"
+	UsageTypeVideo    = "video"
+	UsageTypeDocument = "document"
+	UsageTypeOther    = "other"
+)
+
+// UserUsage 对应 user_usages 表,保存用户存储用量按类型的汇总,
+// 由 FileStatsService 在处理文件夹统计刷新事件时一并重新计算
+type UserUsage struct {
+	UserID         uint64 `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	ImageCount     int64  `gorm:"not null;default:0" json:"image_count"`
+	ImageSize      uint64 `gorm:"type:bigint unsigned;not null;default:0" json:"image_size"`
+	VideoCount     int64  `gorm:"not null;default:0" json:"video_count"`
+	VideoSize      uint64 `gorm:"type:bigint unsigned;not null;default:0" json:"video_size"`
+	DocumentCount  int64  `gorm:"not null;default:0" json:"document_count"`
+	DocumentSize   uint64 `gorm:"type:bigint unsigned;not null;default:0" json:"document_size"`
+	OtherCount     int64  `gorm:"not null;default:0" json:"other_count"`
+	OtherSize      uint64 `gorm:"type:bigint unsigned;not null;default:0" json:"other_size"`
+	TrashCount     int64  `gorm:"not null;default:0" json:"trash_count"` // 回收站中的文件和文件夹数量
+	TrashFileCount int64  `gorm:"not null;default:0" json:"trash_file_count"`
+	TrashSize      uint64 `gorm:"type:bigint unsigned;not null;default:0" json:"trash_size"`
+	// QuotaWarnedAt 最近一次发送用量预警的时间,用量回落到阈值以下后清空,避免重复提醒
+	QuotaWarnedAt *time.Time `gorm:"default:null" json:"-"`
+	UpdatedAt     time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定 GORM 使用的表名
+func (UserUsage) TableName() string {
+	return "user_usages"
+}
+
+// UsedSize 正常状态文件占用的字节数,不含回收站
+func (u *UserUsage) UsedSize() uint64 {
+	return u.ImageSize + u.VideoSize + u.DocumentSize + u.OtherSize
+}
+
+// TypeUsage 某一类文件的数量和大小
+type TypeUsage struct {
+	Type      string `json:"type"`
+	FileCount int64  `json:"file_count"`
+	TotalSize uint64 `json:"total_size"`
+}
+
+// FolderUsage 根目录下文件夹的递归用量
+type FolderUsage struct {
+	FileID    uint64 `json:"file_id"`
+	FileName  string `json:"filename"`
+	FileCount int64  `json:"file_count"`
+	TotalSize uint64 `json:"total_size"`
+}
+
+// StorageUsage 存储用量明细,TotalSpace 为 0 表示不限制
+type StorageUsage struct {
+	TotalSpace  uint64        `json:"total_space"`
+	UsedSize    uint64        `json:"used_size"`
+	UsedPercent float64       `json:"used_percent"`
+	ByType      []TypeUsage   `json:"by_type"`
+	TopFolders  []FolderUsage `json:"top_folders"`
+	Trash       TrashStats    `json:"trash"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
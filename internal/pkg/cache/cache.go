@@ -20,6 +20,12 @@ type Cache interface {
 	// target应该是一个指针，指向希望解编组成的类型。
 	Get(ctx context.Context, key string, target any) error
 
+	// GetDel原子地读取并删除一个值，key不存在时返回ErrCacheMiss，target的要求与Get相同。
+	GetDel(ctx context.Context, key string, target any) error
+
+	// SetNX仅在key不存在时设置值，返回是否设置成功，value的要求与Set相同。
+	SetNX(ctx context.Context, key string, value any, expiration time.Duration) (bool, error)
+
 	// 删除一个或多个key
 	Del(ctx context.Context, keys ...string) error
 
@@ -35,10 +41,14 @@ type Cache interface {
 
 	//有序集合操作函数
 	ZAdd(ctx context.Context, key string, members ...*redis.Z) *redis.IntCmd
+	ZRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd
 	ZRevRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd
+	ZCard(ctx context.Context, key string) *redis.IntCmd
 	ZRem(ctx context.Context, key string, members ...any) *redis.IntCmd
 
 	XAdd(ctx context.Context, a *redis.XAddArgs) *redis.StringCmd
+	// Publish 向 pub/sub 频道发布消息,没有订阅者时消息直接丢弃
+	Publish(ctx context.Context, channel string, message any) error
 
 	Expire(ctx context.Context, key string, expiration time.Duration) error
 	TTL(ctx context.Context, key string) (time.Duration, error)
@@ -52,10 +62,26 @@ type CacheUpdateMessage struct {
 	OldDeletedAt      gorm.DeletedAt `json:"old_deleted_at"`
 }
 
-type CachePathInvalidationMessage struct {
-	UserID        uint64 `json:"user_id"`
-	OldPathPrefix string `json:"old_path_prefix"`
-	NewPathPrefix string `json:"new_path_prefix"`
+// FolderPathChangeMessage 文件夹移动或重命名后路径的变化,OldPath 和 NewPath 为文件夹自身的完整路径如 "/a/b/"。
+// 子项的路径在读取时按父文件夹链计算,不再逐个改写,缓存了子树路径的客户端据此替换路径前缀
+type FolderPathChangeMessage struct {
+	UserID   uint64 `json:"user_id"`
+	FolderID uint64 `json:"folder_id"`
+	OldPath  string `json:"old_path"`
+	NewPath  string `json:"new_path"`
+}
+
+// SessionRevokedMessage 会话被撤销,各实例据此断开该会话的 WebSocket 连接
+type SessionRevokedMessage struct {
+	UserID    uint64 `json:"user_id"`
+	SessionID string `json:"session_id"`
+}
+
+// FileStatsUpdateMessage 文件变更后需要刷新统计的文件夹,FolderPaths 为文件夹的完整路径如 "/a/b/",
+// 消费者会连同路径上的所有祖先文件夹一起刷新
+type FileStatsUpdateMessage struct {
+	UserID      uint64   `json:"user_id"`
+	FolderPaths []string `json:"folder_paths"`
 }
 
 func GenerateFileListKey(userID uint64, parentFolderID *uint64) string {
@@ -65,6 +91,22 @@ func GenerateFileListKey(userID uint64, parentFolderID *uint64) string {
 	return fmt.Sprintf("files:user:%d:folder:%d", userID, *parentFolderID)
 }
 
+// GenerateSortedFileListKey 生成按指定方式排序的文件列表缓存键,score 为文件在该排序下的名次
+func GenerateSortedFileListKey(userID uint64, parentFolderID *uint64, sortBy, order string) string {
+	return fmt.Sprintf("%s:sort:%s:%s", GenerateFileListKey(userID, parentFolderID), sortBy, order)
+}
+
+// GenerateSortedFileListKeys 返回某个文件夹所有排序方式的列表缓存键,用于缓存失效
+func GenerateSortedFileListKeys(userID uint64, parentFolderID *uint64) []string {
+	keys := make([]string, 0, len(models.FileSortFields)*2)
+	for _, sortBy := range models.FileSortFields {
+		keys = append(keys,
+			GenerateSortedFileListKey(userID, parentFolderID, sortBy, models.OrderAsc),
+			GenerateSortedFileListKey(userID, parentFolderID, sortBy, models.OrderDesc))
+	}
+	return keys
+}
+
 func GenerateDeletedFilesKey(userID uint64) string {
 	return fmt.Sprintf("files:deleted:user:%d", userID)
 }
@@ -73,6 +115,111 @@ func GenerateFileMetadataKey(fileID uint64) string {
 	return fmt.Sprintf("file:metadata:%d", fileID)
 }
 
-func GenerateFileMD5Key(md5Hash string) string {
-	return fmt.Sprintf("file:md5:%s", md5Hash)
+// GenerateFolderChainKey 文件夹的祖先链,值中记录写入时的链版本号
+func GenerateFolderChainKey(userID, folderID uint64) string {
+	return fmt.Sprintf("files:chain:user:%d:folder:%d", userID, folderID)
+}
+
+// GenerateFolderChainEpochKey 用户文件夹链的版本号,文件夹移动、重命名或删除时加一,使该用户缓存的所有链失效
+func GenerateFolderChainEpochKey(userID uint64) string {
+	return fmt.Sprintf("files:chain:epoch:user:%d", userID)
+}
+
+func GenerateFileLockKey(fileID uint64) string {
+	return fmt.Sprintf("file:lock:%d", fileID)
+}
+
+// GenerateLifecycleRuleLockKey 生命周期规则执行锁,多个实例或手动执行与定时任务不会同时执行同一条规则
+func GenerateLifecycleRuleLockKey(ruleID uint64) string {
+	return fmt.Sprintf("lifecycle:rule_lock:%d", ruleID)
+}
+
+// GenerateCacheWarmKey 用户最近一次缓存预热的标记,存在期间不重复预热
+func GenerateCacheWarmKey(userID uint64) string {
+	return fmt.Sprintf("files:warm:user:%d", userID)
+}
+
+// GenerateRecentFilesKey 用户最近访问的文件,有序集合,分数为访问时间
+func GenerateRecentFilesKey(userID uint64) string {
+	return fmt.Sprintf("files:recent:user:%d", userID)
+}
+
+// GenerateEmailVerifyTokenKey 邮箱验证令牌,tokenHash 为令牌的 SHA-256,值为用户ID
+func GenerateEmailVerifyTokenKey(tokenHash string) string {
+	return fmt.Sprintf("auth:verify:%s", tokenHash)
+}
+
+// GeneratePasswordResetTokenKey 密码重置令牌,tokenHash 为令牌的 SHA-256,值为用户ID
+func GeneratePasswordResetTokenKey(tokenHash string) string {
+	return fmt.Sprintf("auth:reset:%s", tokenHash)
+}
+
+// GenerateTOTPUsedKey 已使用过的两步验证码步数,防止同一验证码在有效期内被重复使用
+func GenerateTOTPUsedKey(userID uint64, counter int64) string {
+	return fmt.Sprintf("auth:totp:used:%d:%d", userID, counter)
+}
+
+// GenerateTwoFactorChallengeKey 两步验证中间 Token 已失败的次数,tokenHash 为中间 Token 的 SHA-256
+func GenerateTwoFactorChallengeKey(tokenHash string) string {
+	return fmt.Sprintf("auth:2fa:challenge:%s", tokenHash)
+}
+
+// GenerateTwoFactorFailKey 账号在当前窗口期内两步验证失败的次数
+func GenerateTwoFactorFailKey(userID uint64) string {
+	return fmt.Sprintf("auth:2fa:fail:%d", userID)
+}
+
+// GenerateSessionKey 登录会话,值为会话信息和当前刷新 Token 的哈希
+func GenerateSessionKey(sessionID string) string {
+	return fmt.Sprintf("auth:session:%s", sessionID)
+}
+
+// GenerateUserSessionsKey 用户的会话索引(ZSET),分数为会话过期时间
+func GenerateUserSessionsKey(userID uint64) string {
+	return fmt.Sprintf("auth:sessions:user:%d", userID)
+}
+
+// GenerateRevokedSessionKey 已撤销会话的黑名单,保留到该会话签发的访问 Token 全部过期
+func GenerateRevokedSessionKey(sessionID string) string {
+	return fmt.Sprintf("auth:session:revoked:%s", sessionID)
+}
+
+// GenerateUsedRefreshTokenKey 已轮换的刷新 Token,tokenHash 为 Token 的 SHA-256,用于发现 Token 被重复使用
+func GenerateUsedRefreshTokenKey(tokenHash string) string {
+	return fmt.Sprintf("auth:refresh:used:%s", tokenHash)
+}
+
+// GenerateSharePasswordFailKey 分享密码在当前窗口期内的错误次数
+func GenerateSharePasswordFailKey(shareID uint64) string {
+	return fmt.Sprintf("share:password:fail:%d", shareID)
+}
+
+// GenerateSharePasswordLockKey 分享因密码错误次数过多被锁定的标记
+func GenerateSharePasswordLockKey(shareID uint64) string {
+	return fmt.Sprintf("share:password:lock:%d", shareID)
+}
+
+// GenerateOAuthCodeKey OAuth 授权码,codeHash 为授权码的 SHA-256,值为授权信息
+func GenerateOAuthCodeKey(codeHash string) string {
+	return fmt.Sprintf("oauth:code:%s", codeHash)
+}
+
+// GenerateNotificationOnceKey 同一事件在时间窗口内只通知一次的标记
+func GenerateNotificationOnceKey(key string) string {
+	return fmt.Sprintf("notification:once:%s", key)
+}
+
+// NotificationChannelPrefix 用户站内通知频道的前缀,后接用户ID
+const NotificationChannelPrefix = "notification:user:"
+
+// GenerateNotificationChannel 用户站内通知的 Redis pub/sub 频道,WebSocket 会话订阅后推送给客户端
+func GenerateNotificationChannel(userID uint64) string {
+	return fmt.Sprintf("%s%d", NotificationChannelPrefix, userID)
+}
+
+// SessionRevokedChannel 会话撤销的 Redis pub/sub 频道
+const SessionRevokedChannel = "auth:session:revoked"
+
+func GenerateFileMD5Key(userID uint64, md5Hash string) string {
+	return fmt.Sprintf("file:md5:%d:%s", userID, md5Hash)
 }
@@ -4,65 +4,29 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"math/rand"
-	"strconv"
-	"time"
 
-	"github.com/3Eeeecho/go-clouddisk/internal/models"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache/filecache"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
-	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mapper"
 	"github.com/go-redis/redis/v8"
 	"go.uber.org/zap"
-	"gorm.io/gorm"
 )
 
+// StartCacheUpdateConsumer 消费文件缓存更新消息，ctx 取消后处理完已读取的消息再退出
 func StartCacheUpdateConsumer(ctx context.Context, redisClient *redis.Client) {
-	// 创建消费者组
-	// "0" 表示从 Stream 的开头读取所有消息。
-	streamName := "file_cache_updates"
-	groupName := "file_cache_group"
-	consumerName := "file_cache_consumer_1"
-	redisClient.XGroupCreateMkStream(ctx, streamName, groupName, "0").Result()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			streams, err := redisClient.XReadGroup(ctx, &redis.XReadGroupArgs{
-				Group:    groupName,
-				Consumer: consumerName,
-				Streams:  []string{streamName, ">"}, // 从未消费的消息开始读
-				Count:    10,                        // 每次批量读取10条
-				Block:    0,                         // 不阻塞
-			}).Result()
-			if err != nil {
-				logger.Error("Consumer: Failed to read from Redis Streams", zap.Error(err))
-				time.Sleep(5 * time.Second)
-				continue
-			}
-
-			if len(streams) > 0 {
-				for _, stream := range streams {
-					for _, message := range stream.Messages {
-						//处理每条消息
-						if err := processCacheMessage(ctx, redisClient, message); err != nil {
-							logger.Error("Consumer: Failed to process message", zap.Error(err))
-							// 消息处理失败，不发送 XACK，让消息保留在 pending list，等待重试
-							continue
-						}
-						// 成功处理后发送确认，告知 Redis 可以删除这条消息
-						redisClient.XAck(ctx, streamName, groupName, message.ID).Result()
-					}
-				}
-			}
-		}
-	}
+	fileCache := filecache.New(cache.NewRedisCache(redisClient))
+	consume(ctx, redisClient, streamGroup{
+		name:     "CacheUpdateConsumer",
+		stream:   filecache.UpdateStream,
+		group:    "file_cache_group",
+		consumer: "file_cache_consumer_1",
+	}, func(ctx context.Context, message redis.XMessage) error {
+		return processCacheMessage(ctx, fileCache, message)
+	})
 }
 
 // 负责实际的缓存更新逻辑
-func processCacheMessage(ctx context.Context, redisClient *redis.Client, message redis.XMessage) error {
+func processCacheMessage(ctx context.Context, fileCache filecache.FileCache, message redis.XMessage) error {
 	// 从 message 中解析出 CacheUpdateMessage 结构体
 	var updateMsg cache.CacheUpdateMessage
 	jsonBytes, ok := message.Values["payload"].(string)
@@ -73,159 +37,71 @@ func processCacheMessage(ctx context.Context, redisClient *redis.Client, message
 		return fmt.Errorf("failed to unmarshal message: %w", err)
 	}
 
-	pipe := redisClient.TxPipeline()
-	fileMetadataKey := cache.GenerateFileMetadataKey(updateMsg.File.ID)
-
-	fileMap, err := mapper.FileToMap(&updateMsg.File)
-	if err != nil {
-		logger.Error("FindByID: Failed to map models.File to hash for caching", zap.Uint64("id", updateMsg.File.ID), zap.Error(err))
-	} else {
-		pipe.HMSet(ctx, fileMetadataKey, fileMap)
-		pipe.Expire(ctx, fileMetadataKey, cache.CacheTTL+time.Duration(rand.Intn(300))*time.Second)
-	}
-
-	// 获取旧的父文件夹键和新的父文件夹键
-	oldListCacheKey := cache.GenerateFileListKey(updateMsg.File.UserID, updateMsg.OldParentFolderID)
-	newListCacheKey := cache.GenerateFileListKey(updateMsg.File.UserID, updateMsg.File.ParentFolderID)
-	logger.Info("processCacheMessage", zap.String("oldListCacheKey", oldListCacheKey), zap.String("newListCacheKey", newListCacheKey))
-
-	// 文件ID的字符串形式
-	fileIDStr := strconv.FormatUint(updateMsg.File.ID, 10)
-	newZMember := &redis.Z{
-		Score:  float64(updateMsg.File.CreatedAt.Unix()), // 假设 Score 仍然基于 CreatedAt
-		Member: fileIDStr,
-	}
-
-	// 判断 ParentFolderID 是否变化
-	if oldListCacheKey != newListCacheKey {
-		// 从旧父目录的 Sorted Set 中 ZRem 掉该文件 ID
-		pipe.ZRem(ctx, oldListCacheKey, fileIDStr)
-
-		// ZAdd 到新父目录的 Sorted Set 中
-		pipe.ZAdd(ctx, newListCacheKey, newZMember)
-		pipe.ZRem(ctx, newListCacheKey, "__EMPTY_LIST__") // 如果新列表之前有空标记，删除
-	} else {
-		// ParentFolderID 没有变化，但可能需要更新文件在当前列表中的排序分数
-		// 稳妥的做法是先移除旧的，再添加新的，以确保分数更新
-		pipe.ZRem(ctx, newListCacheKey, fileIDStr)
-		pipe.ZAdd(ctx, newListCacheKey, newZMember)
-		pipe.ZRem(ctx, newListCacheKey, "__EMPTY_LIST__") // 确保移除空标记
+	file := &updateMsg.File
+	if err := fileCache.PutFile(ctx, filecache.ByID(file.ID), file); err != nil {
+		logger.Error("CacheUpdateConsumer: Failed to cache file metadata", zap.Uint64("id", file.ID), zap.Error(err))
 	}
 
-	// --- 精确更新回收站缓存 ---
-	deletedListCacheKey := cache.GenerateDeletedFilesKey(updateMsg.File.UserID)
-	wasDeleted := updateMsg.OldDeletedAt.Valid
-	isNowDeleted := updateMsg.File.DeletedAt.Valid
-
-	if !wasDeleted && isNowDeleted {
-		// 文件被软删除：添加到回收站列表
-		deletedZMember := &redis.Z{
-			Score:  float64(updateMsg.File.DeletedAt.Time.Unix()),
-			Member: fileIDStr,
-		}
-		pipe.ZAdd(ctx, deletedListCacheKey, deletedZMember)
-		pipe.ZRem(ctx, deletedListCacheKey, "__EMPTY_LIST__") // 确保移除空标记
-	} else if wasDeleted && !isNowDeleted {
-		// 文件被恢复：从回收站列表移除
-		pipe.ZRem(ctx, deletedListCacheKey, fileIDStr)
-	}
-	// 如果删除状态没有改变，则不执行任何操作
-
+	// 用消息中记录的旧父目录和旧删除状态还原变化前的文件，同步更新新旧父目录和回收站的列表缓存
 	// TODO: 如果业务允许MD5更新（例如文件内容更新），则需要删除旧缓存,并设置新缓存
-
-	// 执行管道命令
-	if _, execErr := pipe.Exec(ctx); execErr != nil {
-		// 修复错误包装：应该包装 execErr 而不是 err
-		return fmt.Errorf("failed to execute Redis pipeline: %w", execErr)
+	before := *file
+	before.ParentFolderID = updateMsg.OldParentFolderID
+	before.DeletedAt = updateMsg.OldDeletedAt
+	if err := fileCache.MutateList(ctx, filecache.ListChange{Before: &before, After: file}); err != nil {
+		return err
 	}
-	logger.Info("successfully process message", zap.Uint64("file_id", updateMsg.File.ID))
+	logger.Info("successfully process message", zap.Uint64("file_id", file.ID))
 	return nil
 }
 
-func StartPathInvalidationConsumer(ctx context.Context, db *gorm.DB, redisClient *redis.Client) {
-	streamName := "cache_path_invalidation_stream"
-	groupName := "path_invalidation_group"
-	consumerName := "path_invalidation_consumer_1"
-
-	redisClient.XGroupCreateMkStream(ctx, streamName, groupName, "0")
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			streams, err := redisClient.XReadGroup(ctx, &redis.XReadGroupArgs{
-				Group:    groupName,
-				Consumer: consumerName,
-				Streams:  []string{streamName, ">"},
-				Block:    0,
-				Count:    10,
-			}).Result()
+// StartFolderPathConsumer 消费文件夹路径变化消息,使用户缓存的祖先链失效,ctx 取消后处理完已读取的消息再退出。
+// 消息在移动或重命名的事务提交后才投递,能覆盖提交前被其他请求按旧数据重新写入的链
+func StartFolderPathConsumer(ctx context.Context, redisClient *redis.Client) {
+	fileCache := filecache.New(cache.NewRedisCache(redisClient))
+	consume(ctx, redisClient, streamGroup{
+		name:     "FolderPathConsumer",
+		stream:   filecache.PathChangeStream,
+		group:    "file_path_group",
+		consumer: "file_path_consumer_1",
+	}, func(ctx context.Context, message redis.XMessage) error {
+		var pathMsg cache.FolderPathChangeMessage
+		jsonBytes, ok := message.Values["payload"].(string)
+		if !ok {
+			return fmt.Errorf("invalid message payload format")
+		}
+		if err := json.Unmarshal([]byte(jsonBytes), &pathMsg); err != nil {
+			return fmt.Errorf("failed to unmarshal message: %w", err)
+		}
+		return fileCache.InvalidateFolderChains(ctx, pathMsg.UserID)
+	})
+}
 
-			if err != nil {
-				logger.Error("BatchInvalidationConsumer: Failed to read from stream", zap.Error(err))
-				time.Sleep(time.Second * 5)
-				continue
-			}
+// FileStatsRefresher 重新计算文件夹统计,由 explorer.FileStatsService 实现
+type FileStatsRefresher interface {
+	Refresh(ctx context.Context, userID uint64, folderPaths []string) error
+}
 
-			if len(streams) > 0 {
-				for _, message := range streams[0].Messages {
-					if err := processInvalidationMessage(ctx, db, redisClient, message); err != nil {
-						logger.Error("Failed to process invalidation message", zap.Error(err))
-					} else {
-						redisClient.XAck(ctx, streamName, groupName, message.ID).Result()
-					}
-				}
-			}
-		}
-	}
+// StartFileStatsConsumer 消费文件夹统计刷新消息，ctx 取消后处理完已读取的消息再退出
+func StartFileStatsConsumer(ctx context.Context, redisClient *redis.Client, refresher FileStatsRefresher) {
+	consume(ctx, redisClient, streamGroup{
+		name:     "FileStatsConsumer",
+		stream:   "file_stats_updates",
+		group:    "file_stats_group",
+		consumer: "file_stats_consumer_1",
+	}, func(ctx context.Context, message redis.XMessage) error {
+		return processFileStatsMessage(ctx, refresher, message)
+	})
 }
 
-// 处理具体的缓存失效逻辑
-func processInvalidationMessage(ctx context.Context, db *gorm.DB, redisClient *redis.Client, message redis.XMessage) error {
-	var pathInvalidationMsg cache.CachePathInvalidationMessage
+// processFileStatsMessage 统计直接从数据库重新计算,重复或乱序的消息不影响结果
+func processFileStatsMessage(ctx context.Context, refresher FileStatsRefresher, message redis.XMessage) error {
+	var statsMsg cache.FileStatsUpdateMessage
 	jsonBytes, ok := message.Values["payload"].(string)
 	if !ok {
 		return fmt.Errorf("invalid message payload format")
 	}
-	if err := json.Unmarshal([]byte(jsonBytes), &pathInvalidationMsg); err != nil {
-		return fmt.Errorf("failed to unmarshal message :%w", err)
-	}
-
-	//从数据库中找出所有受影响的文件ID
-	// ⚠️ 注意：这个查询可能需要一段时间，因为它需要回源数据库
-	var affectedFiles []models.File
-	if err := db.WithContext(ctx).
-		Where("user_id = ? AND path LIKE ?", pathInvalidationMsg.UserID, pathInvalidationMsg.OldPathPrefix+"%").
-		Find(&affectedFiles).Error; err != nil {
-		return fmt.Errorf("failed to find affected files for invalidation: %w", err)
-	}
-
-	if len(affectedFiles) == 0 {
-		return nil //没有受影响文件,直接返回
-	}
-
-	//批量删除Redis缓存
-	//首先删除元数据缓存
-	var metaKeys []string
-	for _, file := range affectedFiles {
-		metaKeys = append(metaKeys, cache.GenerateFileMetadataKey(file.ID))
-	}
-	if len(metaKeys) > 0 {
-		redisClient.Del(ctx, metaKeys...)
+	if err := json.Unmarshal([]byte(jsonBytes), &statsMsg); err != nil {
+		return fmt.Errorf("failed to unmarshal message: %w", err)
 	}
-
-	// 删除文件列表缓存的逻辑被移除。
-	// 移动文件夹时，文件夹本身的 ParentFolderID 会变化，这会触发 processCacheMessage，
-	// 正确地更新源目录和目标目录的列表缓存。
-	// 子文件的 Path 字段虽然变了，但它们的 ParentFolderID 没有变，所以它们所属的列表缓存 (files:user:id:folder:id) 是不变的。
-	// 因此，我们只需要让子文件的元数据缓存失效即可，这在前面已经通过 Del(metaKeys) 完成了。
-	// 删除所有文件夹列表缓存是错误且没有必要的。
-
-	logger.Info("Successfully invalidated metadata caches for path update",
-		zap.Int("affected_files_count", len(affectedFiles)),
-		zap.Uint64("user_id", pathInvalidationMsg.UserID),
-		zap.String("old_path_prefix", pathInvalidationMsg.OldPathPrefix),
-	)
-
-	return nil
+	return refresher.Refresh(ctx, statsMsg.UserID, statsMsg.FolderPaths)
 }
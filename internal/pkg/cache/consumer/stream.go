@@ -0,0 +1,215 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/metrics"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	// readBlockTimeout 每次阻塞读取的最长时间，超时后检查是否需要退出
+	readBlockTimeout = 2 * time.Second
+	// claimInterval 检查 pending list 的间隔
+	claimInterval = 30 * time.Second
+	// claimMinIdle 消息空闲超过该时间才会被重新认领，避免和正在处理的消费者抢消息
+	claimMinIdle = time.Minute
+	// claimBatchSize 每轮最多重新认领的消息数
+	claimBatchSize = 50
+	// maxDeliveries 消息投递达到该次数仍处理失败时转入死信流
+	maxDeliveries = 5
+)
+
+// DeadLetterStream 多次处理失败的消息统一写入的死信流
+const DeadLetterStream = "cache_dead_letters"
+
+// deadLetterMaxLen 死信流保留的最大消息数
+const deadLetterMaxLen = 10000
+
+// DeadLetter 死信消息，记录原始消息和最后一次失败的原因
+type DeadLetter struct {
+	ID         string    `json:"id"`
+	Stream     string    `json:"stream"`
+	Group      string    `json:"group"`
+	MessageID  string    `json:"message_id"`
+	Payload    string    `json:"payload"`
+	Error      string    `json:"error"`
+	Deliveries int64     `json:"deliveries"`
+	FailedAt   time.Time `json:"failed_at"`
+}
+
+// ParseDeadLetter 从死信流的消息中解析死信记录
+func ParseDeadLetter(message redis.XMessage) DeadLetter {
+	field := func(key string) string {
+		value, _ := message.Values[key].(string)
+		return value
+	}
+	deliveries, _ := strconv.ParseInt(field("deliveries"), 10, 64)
+	failedAt, _ := time.Parse(time.RFC3339, field("failed_at"))
+	return DeadLetter{
+		ID:         message.ID,
+		Stream:     field("stream"),
+		Group:      field("group"),
+		MessageID:  field("message_id"),
+		Payload:    field("payload"),
+		Error:      field("error"),
+		Deliveries: deliveries,
+		FailedAt:   failedAt,
+	}
+}
+
+// streamGroup 消费者组的配置，name 用作日志前缀
+type streamGroup struct {
+	name     string
+	stream   string
+	group    string
+	consumer string
+}
+
+// consume 从消费者组读取并处理消息，处理成功后发送 XACK。
+// 处理失败的消息保留在 pending list，每隔 claimInterval 重新认领空闲的消息重试，
+// 包括消费者崩溃前已读取但未确认的消息。ctx 取消后处理完已读取的消息再退出
+func consume(ctx context.Context, redisClient *redis.Client, g streamGroup, handle func(context.Context, redis.XMessage) error) {
+	// 创建消费者组
+	// "0" 表示从 Stream 的开头读取所有消息。
+	redisClient.XGroupCreateMkStream(ctx, g.stream, g.group, "0")
+
+	// 已读取的消息使用独立的 ctx 处理和确认，关机时不会因为 ctx 取消而丢失 XACK
+	processCtx := context.WithoutCancel(ctx)
+
+	// 零值保证启动后立即检查一次，接管上次退出前遗留的消息
+	var lastClaim time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		if time.Since(lastClaim) >= claimInterval {
+			reclaimPending(ctx, processCtx, redisClient, g, handle)
+			lastClaim = time.Now()
+		}
+
+		streams, err := redisClient.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    g.group,
+			Consumer: g.consumer,
+			Streams:  []string{g.stream, ">"}, // 从未消费的消息开始读
+			Count:    10,                      // 每次批量读取10条
+			Block:    readBlockTimeout,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+				continue // 读取超时或正在关机
+			}
+			logger.Error(g.name+": Failed to read from stream", zap.Error(err))
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		for _, stream := range streams {
+			for _, message := range stream.Messages {
+				if err := handle(processCtx, message); err != nil {
+					// 消息处理失败，不发送 XACK，让消息保留在 pending list，等待重新认领
+					logger.Error(g.name+": Failed to process message", zap.String("messageID", message.ID), zap.Error(err))
+					continue
+				}
+				// 成功处理后发送确认，告知 Redis 可以删除这条消息
+				redisClient.XAck(processCtx, g.stream, g.group, message.ID)
+			}
+		}
+	}
+}
+
+// reclaimPending 重新认领空闲超过 claimMinIdle 的 pending 消息并重试，投递次数达到上限仍失败的消息转入死信流。
+// go-redis v8 无法解析 Redis 7 的 XAUTOCLAIM 返回值，这里用 XPENDING + XCLAIM 实现同样的效果
+func reclaimPending(ctx, processCtx context.Context, redisClient *redis.Client, g streamGroup, handle func(context.Context, redis.XMessage) error) {
+	pending, err := redisClient.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: g.stream,
+		Group:  g.group,
+		Idle:   claimMinIdle,
+		Start:  "-",
+		End:    "+",
+		Count:  claimBatchSize,
+	}).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) && ctx.Err() == nil {
+			logger.Error(g.name+": Failed to list pending messages", zap.Error(err))
+		}
+		return
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	ids := make([]string, 0, len(pending))
+	deliveries := make(map[string]int64, len(pending))
+	for _, p := range pending {
+		ids = append(ids, p.ID)
+		// XCLAIM 会把投递次数加一
+		deliveries[p.ID] = p.RetryCount + 1
+	}
+	messages, err := redisClient.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   g.stream,
+		Group:    g.group,
+		Consumer: g.consumer,
+		MinIdle:  claimMinIdle,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Error(g.name+": Failed to claim pending messages", zap.Error(err))
+		}
+		return
+	}
+
+	for _, message := range messages {
+		err := handle(processCtx, message)
+		if err == nil {
+			redisClient.XAck(processCtx, g.stream, g.group, message.ID)
+			continue
+		}
+		if deliveries[message.ID] < maxDeliveries {
+			logger.Warn(g.name+": Failed to process reclaimed message, will retry",
+				zap.String("messageID", message.ID),
+				zap.Int64("deliveries", deliveries[message.ID]),
+				zap.Error(err))
+			continue
+		}
+		deadLetter(processCtx, redisClient, g, message, deliveries[message.ID], err)
+	}
+}
+
+// deadLetter 将消息写入死信流后从原消费者组确认，写入失败时保留在 pending list 下一轮再试
+func deadLetter(ctx context.Context, redisClient *redis.Client, g streamGroup, message redis.XMessage, deliveries int64, cause error) {
+	payload, _ := message.Values["payload"].(string)
+	if _, err := redisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: DeadLetterStream,
+		MaxLen: deadLetterMaxLen,
+		Approx: true,
+		Values: map[string]any{
+			"stream":     g.stream,
+			"group":      g.group,
+			"message_id": message.ID,
+			"payload":    payload,
+			"error":      cause.Error(),
+			"deliveries": deliveries,
+			"failed_at":  time.Now().Format(time.RFC3339),
+		},
+	}).Result(); err != nil {
+		logger.Error(g.name+": Failed to write dead letter", zap.String("messageID", message.ID), zap.Error(err))
+		return
+	}
+	redisClient.XAck(ctx, g.stream, g.group, message.ID)
+
+	metrics.StreamDeadLettersTotal.WithLabelValues(g.stream).Inc()
+	logger.Error(g.name+": Message moved to dead-letter stream",
+		zap.String("messageID", message.ID),
+		zap.Int64("deliveries", deliveries),
+		zap.Error(cause))
+}
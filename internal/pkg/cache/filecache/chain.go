@@ -0,0 +1,108 @@
+package filecache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/go-redis/redis/v8"
+)
+
+// chainEpochTTL 链版本号的有效期,需要远大于链本身的缓存时间。
+// 版本号过期后从 0 重新开始,此前写入的链早已过期,不会被误认为有效
+const chainEpochTTL = 24 * time.Hour
+
+// cachedChain 缓存中的祖先链,只保存计算路径和判断子树需要的字段
+type cachedChain struct {
+	Epoch   int64         `json:"epoch"`
+	Folders []chainFolder `json:"folders"`
+}
+
+type chainFolder struct {
+	ID             uint64  `json:"id"`
+	ParentFolderID *uint64 `json:"parent_folder_id"`
+	FileName       string  `json:"file_name"`
+}
+
+// GetFolderChains 版本号和所有链在同一个管道中读取,链中记录的版本号与当前版本号不一致时视为未缓存。
+// 任何一个文件夹移动、重命名或删除都会使该用户的所有链失效,不需要找出受影响的子树
+func (c *redisFileCache) GetFolderChains(ctx context.Context, userID uint64, folderIDs []uint64) (map[uint64][]models.File, []uint64, int64, error) {
+	chains := make(map[uint64][]models.File, len(folderIDs))
+	pipe := c.cache.TxPipeline()
+	epochCmd := pipe.Get(ctx, cache.GenerateFolderChainEpochKey(userID))
+	cmds := make([]*redis.StringCmd, len(folderIDs))
+	for i, folderID := range folderIDs {
+		cmds[i] = pipe.Get(ctx, cache.GenerateFolderChainKey(userID, folderID))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, nil, 0, fmt.Errorf("failed to execute folder chain pipeline: %w", err)
+	}
+
+	epoch, err := epochCmd.Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, nil, 0, fmt.Errorf("failed to read folder chain epoch: %w", err)
+	}
+	var missed []uint64
+	for i, folderID := range folderIDs {
+		data, err := cmds[i].Bytes()
+		var cached cachedChain
+		if err != nil || json.Unmarshal(data, &cached) != nil || cached.Epoch != epoch {
+			missed = append(missed, folderID)
+			continue
+		}
+		chain := make([]models.File, len(cached.Folders))
+		for j, folder := range cached.Folders {
+			chain[j] = models.File{
+				ID:             folder.ID,
+				UserID:         userID,
+				ParentFolderID: folder.ParentFolderID,
+				FileName:       folder.FileName,
+				IsFolder:       1,
+			}
+		}
+		chains[folderID] = chain
+	}
+	return chains, missed, epoch, nil
+}
+
+func (c *redisFileCache) PutFolderChains(ctx context.Context, userID uint64, epoch int64, chains map[uint64][]models.File) error {
+	if len(chains) == 0 {
+		return nil
+	}
+	pipe := c.cache.TxPipeline()
+	for folderID, chain := range chains {
+		cached := cachedChain{Epoch: epoch, Folders: make([]chainFolder, len(chain))}
+		for i, folder := range chain {
+			cached.Folders[i] = chainFolder{ID: folder.ID, ParentFolderID: folder.ParentFolderID, FileName: folder.FileName}
+		}
+		data, err := json.Marshal(cached)
+		if err != nil {
+			return fmt.Errorf("failed to marshal folder chain: %w", err)
+		}
+		pipe.Set(ctx, cache.GenerateFolderChainKey(userID, folderID), data, ttl())
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to cache folder chains: %w", err)
+	}
+	return nil
+}
+
+func (c *redisFileCache) InvalidateFolderChains(ctx context.Context, userIDs ...uint64) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	pipe := c.cache.TxPipeline()
+	for _, userID := range userIDs {
+		key := cache.GenerateFolderChainEpochKey(userID)
+		pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, chainEpochTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to invalidate folder chains: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,459 @@
+// Package filecache 文件元数据和文件列表的缓存。
+// 封装键的布局、序列化和失效规则，供文件仓储的缓存装饰器和缓存更新消费者共用
+package filecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mapper"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	// UpdateStream 文件缓存更新消息的 Stream
+	UpdateStream = "file_cache_updates"
+	// PathChangeStream 文件夹路径变化消息的 Stream
+	PathChangeStream = "file_path_changes"
+
+	// notFoundTTL "不存在"标记的有效期，防止缓存穿透
+	notFoundTTL = time.Minute
+
+	notFoundField = "__NOT_FOUND__"
+	emptyListMark = "__EMPTY_LIST__"
+)
+
+// Key 单个文件元数据的缓存键
+type Key string
+
+// ByID 按文件ID缓存的元数据
+func ByID(fileID uint64) Key {
+	return Key(cache.GenerateFileMetadataKey(fileID))
+}
+
+// ByMD5 按用户和 MD5 缓存的元数据
+func ByMD5(userID uint64, md5Hash string) Key {
+	return Key(cache.GenerateFileMD5Key(userID, md5Hash))
+}
+
+// ListChange 一次文件变化，Before 为变化前的状态(新建时为 nil)，After 为变化后的状态(彻底删除时为 nil)
+type ListChange struct {
+	Before *models.File
+	After  *models.File
+}
+
+// FileCache 文件缓存。读取未命中时返回 cache.ErrCacheMiss，命中"不存在"标记时返回 xerr.ErrFileNotFound
+type FileCache interface {
+	// GetFile 读取单个文件的元数据
+	GetFile(ctx context.Context, key Key) (*models.File, error)
+	// GetFiles 一次读取多个文件按ID缓存的元数据,missed 为未缓存的ID,带"不存在"标记的ID两者都不包含
+	GetFiles(ctx context.Context, fileIDs []uint64) (files map[uint64]*models.File, missed []uint64, err error)
+	// PutFile 写入文件元数据
+	PutFile(ctx context.Context, key Key, file *models.File) error
+	// PutNotFound 写入短期的"不存在"标记
+	PutNotFound(ctx context.Context, key Key) error
+	// InvalidateFile 删除文件按ID和按 MD5 缓存的元数据
+	InvalidateFile(ctx context.Context, files ...*models.File) error
+
+	// GetFolderPage 读取文件夹按指定方式排序后名次在 [start, stop] 内的文件，以及文件总数
+	GetFolderPage(ctx context.Context, userID uint64, parentFolderID *uint64, sortBy, order string, start, stop int64) ([]models.File, int64, error)
+	// GetFolderPageAfter 读取文件夹列表中排在 afterID 之后的最多 limit 个文件,afterID 为 0 时从头读取。
+	// afterID 不在列表中时返回 cache.ErrCacheMiss
+	GetFolderPageAfter(ctx context.Context, userID uint64, parentFolderID *uint64, sortBy, order string, afterID uint64, limit int64) ([]models.File, int64, error)
+	// PutFolder 写入文件夹按指定方式排序后的完整列表
+	PutFolder(ctx context.Context, userID uint64, parentFolderID *uint64, sortBy, order string, files []models.File) error
+	// GetTrash 读取回收站列表，按删除时间倒序
+	GetTrash(ctx context.Context, userID uint64) ([]models.File, error)
+	// GetTrashPageAfter 读取回收站中排在 afterID 之后的最多 limit 个文件,afterID 为 0 时从最近删除的文件开始。
+	// afterID 不在列表中时返回 cache.ErrCacheMiss
+	GetTrashPageAfter(ctx context.Context, userID uint64, afterID uint64, limit int64) ([]models.File, error)
+	// PutTrash 写入回收站的完整列表
+	PutTrash(ctx context.Context, userID uint64, files []models.File) error
+	// MutateList 根据文件变化前后的状态更新所属文件夹和回收站的列表缓存
+	MutateList(ctx context.Context, changes ...ListChange) error
+
+	// GetFolderChains 读取用户文件夹的祖先链,missed 为未缓存或已失效的文件夹ID。
+	// epoch 为读取时的链版本号,回源查询后用它写入,查询期间链发生变化时写入的结果不会被读到
+	GetFolderChains(ctx context.Context, userID uint64, folderIDs []uint64) (chains map[uint64][]models.File, missed []uint64, epoch int64, err error)
+	// PutFolderChains 写入回源查询到的祖先链,epoch 为查询前由 GetFolderChains 返回的版本号
+	PutFolderChains(ctx context.Context, userID uint64, epoch int64, chains map[uint64][]models.File) error
+	// InvalidateFolderChains 使用户缓存的所有祖先链失效
+	InvalidateFolderChains(ctx context.Context, userIDs ...uint64) error
+}
+
+type redisFileCache struct {
+	cache *cache.RedisCache
+}
+
+var _ FileCache = (*redisFileCache)(nil)
+
+// New 创建文件缓存，启用了本地缓存时在 Redis 前增加一层进程内缓存
+func New(redisCache *cache.RedisCache) FileCache {
+	fc := &redisFileCache{cache: redisCache}
+	if local := localTier.Load(); local != nil {
+		return &tieredFileCache{FileCache: fc, local: local, redis: redisCache}
+	}
+	return fc
+}
+
+// ttl 在基础过期时间上增加随机值，避免大量缓存同时过期
+func ttl() time.Duration {
+	return cache.CacheTTL + time.Duration(rand.Intn(300))*time.Second
+}
+
+func (c *redisFileCache) GetFile(ctx context.Context, key Key) (*models.File, error) {
+	fields, err := c.cache.HGetAll(ctx, string(key))
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := fields[notFoundField]; ok {
+		return nil, xerr.ErrFileNotFound
+	}
+	file, err := mapper.MapToFile(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map cached hash to file: %w", err)
+	}
+	return file, nil
+}
+
+func (c *redisFileCache) GetFiles(ctx context.Context, fileIDs []uint64) (map[uint64]*models.File, []uint64, error) {
+	files := make(map[uint64]*models.File, len(fileIDs))
+	if len(fileIDs) == 0 {
+		return files, nil, nil
+	}
+	pipe := c.cache.TxPipeline()
+	cmds := make([]*redis.StringStringMapCmd, len(fileIDs))
+	for i, fileID := range fileIDs {
+		cmds[i] = pipe.HGetAll(ctx, string(ByID(fileID)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, nil, fmt.Errorf("failed to execute HGetAll pipeline: %w", err)
+	}
+
+	var missed []uint64
+	for i, fileID := range fileIDs {
+		fields, err := cmds[i].Result()
+		if err != nil || len(fields) == 0 {
+			missed = append(missed, fileID)
+			continue
+		}
+		if _, ok := fields[notFoundField]; ok {
+			continue
+		}
+		file, err := mapper.MapToFile(fields)
+		if err != nil {
+			missed = append(missed, fileID)
+			continue
+		}
+		files[fileID] = file
+	}
+	return files, missed, nil
+}
+
+func (c *redisFileCache) PutFile(ctx context.Context, key Key, file *models.File) error {
+	fields, err := mapper.FileToMap(file)
+	if err != nil {
+		return fmt.Errorf("failed to map file to hash: %w", err)
+	}
+	pipe := c.cache.TxPipeline()
+	// 先删除旧值，避免残留"不存在"标记
+	pipe.Del(ctx, string(key))
+	pipe.HMSet(ctx, string(key), fields)
+	pipe.Expire(ctx, string(key), ttl())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to cache file: %w", err)
+	}
+	return nil
+}
+
+func (c *redisFileCache) PutNotFound(ctx context.Context, key Key) error {
+	pipe := c.cache.TxPipeline()
+	pipe.HSet(ctx, string(key), notFoundField, "1")
+	pipe.Expire(ctx, string(key), notFoundTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to cache not-found marker: %w", err)
+	}
+	return nil
+}
+
+func (c *redisFileCache) InvalidateFile(ctx context.Context, files ...*models.File) error {
+	keys := make([]string, 0, len(files)*2)
+	for _, file := range files {
+		keys = append(keys, string(ByID(file.ID)))
+		if file.MD5Hash != nil && *file.MD5Hash != "" {
+			keys = append(keys, string(ByMD5(file.UserID, *file.MD5Hash)))
+		}
+	}
+	return c.cache.Del(ctx, keys...)
+}
+
+func (c *redisFileCache) GetFolderPage(ctx context.Context, userID uint64, parentFolderID *uint64, sortBy, order string, start, stop int64) ([]models.File, int64, error) {
+	listKey := cache.GenerateSortedFileListKey(userID, parentFolderID, sortBy, order)
+	total, err := c.cache.ZCard(ctx, listKey).Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get file list size from cache: %w", err)
+	}
+	if total == 0 {
+		return nil, 0, cache.ErrCacheMiss
+	}
+
+	if total == 1 {
+		members, err := c.cache.ZRange(ctx, listKey, 0, 0).Result()
+		if err == nil && len(members) == 1 && members[0] == emptyListMark {
+			return []models.File{}, 0, nil
+		}
+	}
+	if start >= total {
+		return []models.File{}, total, nil
+	}
+
+	ids, err := c.cache.ZRange(ctx, listKey, start, stop).Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get file ID list from cache: %w", err)
+	}
+	files, err := c.loadMembers(ctx, listKey, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// 部分元数据已过期，这一页不完整，回源数据库
+	last := total - 1
+	if stop >= 0 && stop < last {
+		last = stop
+	}
+	if int64(len(files)) < last-start+1 {
+		return nil, 0, cache.ErrCacheMiss
+	}
+	return files, total, nil
+}
+
+func (c *redisFileCache) GetFolderPageAfter(ctx context.Context, userID uint64, parentFolderID *uint64, sortBy, order string, afterID uint64, limit int64) ([]models.File, int64, error) {
+	start := int64(0)
+	if afterID != 0 {
+		listKey := cache.GenerateSortedFileListKey(userID, parentFolderID, sortBy, order)
+		rank, err := c.cache.ZRank(ctx, listKey, strconv.FormatUint(afterID, 10)).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				return nil, 0, cache.ErrCacheMiss
+			}
+			return nil, 0, fmt.Errorf("failed to get file rank from cache: %w", err)
+		}
+		start = rank + 1
+	}
+	return c.GetFolderPage(ctx, userID, parentFolderID, sortBy, order, start, start+limit-1)
+}
+
+func (c *redisFileCache) PutFolder(ctx context.Context, userID uint64, parentFolderID *uint64, sortBy, order string, files []models.File) error {
+	// score 为文件在该排序下的名次，分页只需一次 ZRANGE
+	ranks := make(map[uint64]float64, len(files))
+	for i, file := range files {
+		ranks[file.ID] = float64(i)
+	}
+	listKey := cache.GenerateSortedFileListKey(userID, parentFolderID, sortBy, order)
+	return c.putList(ctx, listKey, files, func(file models.File) float64 {
+		return ranks[file.ID]
+	})
+}
+
+func (c *redisFileCache) GetTrash(ctx context.Context, userID uint64) ([]models.File, error) {
+	listKey := cache.GenerateDeletedFilesKey(userID)
+	exists, err := c.cache.Exists(ctx, listKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check cache key existence: %w", err)
+	}
+	if !exists {
+		return nil, cache.ErrCacheMiss
+	}
+
+	ids, err := c.cache.ZRevRange(ctx, listKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file ID list from cache: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, cache.ErrCacheMiss
+	}
+	files, err := c.loadMembers(ctx, listKey, ids)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].DeletedAt.Time.After(files[j].DeletedAt.Time)
+	})
+	return files, nil
+}
+
+func (c *redisFileCache) GetTrashPageAfter(ctx context.Context, userID uint64, afterID uint64, limit int64) ([]models.File, error) {
+	listKey := cache.GenerateDeletedFilesKey(userID)
+	start := int64(0)
+	if afterID != 0 {
+		rank, err := c.cache.ZRevRank(ctx, listKey, strconv.FormatUint(afterID, 10)).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				return nil, cache.ErrCacheMiss
+			}
+			return nil, fmt.Errorf("failed to get file rank from cache: %w", err)
+		}
+		start = rank + 1
+	} else {
+		exists, err := c.cache.Exists(ctx, listKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check cache key existence: %w", err)
+		}
+		if !exists {
+			return nil, cache.ErrCacheMiss
+		}
+	}
+
+	ids, err := c.cache.ZRevRange(ctx, listKey, start, start+limit-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file ID list from cache: %w", err)
+	}
+	files, err := c.loadMembers(ctx, listKey, ids)
+	if err != nil {
+		return nil, err
+	}
+	// 部分元数据已过期,这一页不完整,回源数据库
+	if len(files) < len(ids) && !(len(ids) == 1 && ids[0] == emptyListMark) {
+		return nil, cache.ErrCacheMiss
+	}
+	return files, nil
+}
+
+func (c *redisFileCache) PutTrash(ctx context.Context, userID uint64, files []models.File) error {
+	// 按毫秒计分,与数据库中 deleted_at 的精度一致,分页顺序和数据库保持相同
+	return c.putList(ctx, cache.GenerateDeletedFilesKey(userID), files, func(file models.File) float64 {
+		if file.DeletedAt.Valid {
+			return float64(file.DeletedAt.Time.UnixMilli())
+		}
+		return 0
+	})
+}
+
+func (c *redisFileCache) MutateList(ctx context.Context, changes ...ListChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	pipe := c.cache.TxPipeline()
+	for _, change := range changes {
+		// 文件名、大小、更新时间或父目录的变化都会让排序列表中的名次失效，
+		// 直接删除新旧父目录下所有排序方式的列表，下次读取时重建
+		var file *models.File
+		if change.Before != nil {
+			file = change.Before
+			pipe.Del(ctx, cache.GenerateSortedFileListKeys(file.UserID, file.ParentFolderID)...)
+		}
+		if change.After != nil {
+			file = change.After
+			pipe.Del(ctx, cache.GenerateSortedFileListKeys(file.UserID, file.ParentFolderID)...)
+		}
+		if file == nil {
+			continue
+		}
+
+		wasDeleted := change.Before != nil && change.Before.DeletedAt.Valid
+		isDeleted := change.After != nil && change.After.DeletedAt.Valid
+		trashKey := cache.GenerateDeletedFilesKey(file.UserID)
+		switch {
+		case isDeleted && !wasDeleted:
+			// 回收站列表不存在时 ZADD 会生成只有一个文件的不完整列表，直接删除，下次读取时重建
+			pipe.Del(ctx, trashKey)
+		case wasDeleted && !isDeleted:
+			// 文件被恢复或彻底删除
+			pipe.ZRem(ctx, trashKey, strconv.FormatUint(file.ID, 10))
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to update file list cache: %w", err)
+	}
+	return nil
+}
+
+// loadMembers 按列表中的顺序批量读取文件元数据，已过期的文件被跳过，由调用方决定是否回源
+func (c *redisFileCache) loadMembers(ctx context.Context, listKey string, ids []string) ([]models.File, error) {
+	if len(ids) == 1 && ids[0] == emptyListMark {
+		return []models.File{}, nil
+	}
+
+	fileIDs := make([]uint64, 0, len(ids))
+	for _, idStr := range ids {
+		id, err := strconv.ParseUint(idStr, 10, 64)
+		if err != nil || id == 0 {
+			continue
+		}
+		fileIDs = append(fileIDs, id)
+	}
+	if len(fileIDs) == 0 {
+		return []models.File{}, nil
+	}
+
+	pipe := c.cache.TxPipeline()
+	cmds := make([]*redis.StringStringMapCmd, len(fileIDs))
+	for i, fileID := range fileIDs {
+		cmds[i] = pipe.HGetAll(ctx, string(ByID(fileID)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("failed to execute HGetAll pipeline: %w", err)
+	}
+
+	files := make([]models.File, 0, len(fileIDs))
+	var missedIDs []uint64
+	for i, fileID := range fileIDs {
+		fields, err := cmds[i].Result()
+		if err != nil || len(fields) == 0 || fields[notFoundField] != "" {
+			missedIDs = append(missedIDs, fileID)
+			continue
+		}
+		file, err := mapper.MapToFile(fields)
+		if err != nil {
+			missedIDs = append(missedIDs, fileID)
+			continue
+		}
+		files = append(files, *file)
+	}
+	if len(missedIDs) > 0 {
+		logger.Warn("FileCache: Metadata missing for listed files", zap.String("listKey", listKey), zap.Uint64s("missedFileIDs", missedIDs))
+	}
+	return files, nil
+}
+
+// putList 写入列表及列表中每个文件的元数据，空列表写入占位标记，区分"没有文件"和"未缓存"
+func (c *redisFileCache) putList(ctx context.Context, listKey string, files []models.File, score func(file models.File) float64) error {
+	pipe := c.cache.TxPipeline()
+	pipe.Del(ctx, listKey)
+	if len(files) == 0 {
+		pipe.ZAdd(ctx, listKey, &redis.Z{Score: 0, Member: emptyListMark})
+	} else {
+		members := make([]*redis.Z, 0, len(files))
+		for _, file := range files {
+			fields, err := mapper.FileToMap(&file)
+			if err != nil {
+				logger.Error("FileCache: Failed to map file to hash", zap.Uint64("fileID", file.ID), zap.Error(err))
+				continue
+			}
+			metaKey := string(ByID(file.ID))
+			pipe.HMSet(ctx, metaKey, fields)
+			pipe.Expire(ctx, metaKey, ttl())
+			members = append(members, &redis.Z{Score: score(file), Member: strconv.FormatUint(file.ID, 10)})
+		}
+		if len(members) > 0 {
+			pipe.ZAdd(ctx, listKey, members...)
+		}
+	}
+	pipe.Expire(ctx, listKey, ttl())
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to cache file list %s: %w", listKey, err)
+	}
+	return nil
+}
@@ -0,0 +1,356 @@
+package filecache
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// newTestCache 返回连接到 miniredis 的文件缓存,测试结束时关闭
+func newTestCache(t *testing.T) (*miniredis.Miniredis, *cache.RedisCache, *redisFileCache) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	redisCache := cache.NewRedisCache(client)
+	return mr, redisCache, &redisFileCache{cache: redisCache}
+}
+
+func testFile(id uint64, name string) *models.File {
+	md5Hash := name + "-md5"
+	parentID := uint64(1)
+	return &models.File{
+		ID:             id,
+		UUID:           name + "-uuid",
+		UserID:         7,
+		ParentFolderID: &parentID,
+		FileName:       name,
+		Size:           id * 100,
+		MD5Hash:        &md5Hash,
+		Status:         models.StatusNormal,
+	}
+}
+
+func TestFileSetAndGet(t *testing.T) {
+	ctx := context.Background()
+	_, _, fc := newTestCache(t)
+	file := testFile(10, "report.pdf")
+
+	for _, key := range []Key{ByID(file.ID), ByMD5(file.UserID, *file.MD5Hash)} {
+		if err := fc.PutFile(ctx, key, file); err != nil {
+			t.Fatalf("PutFile(%s) error = %v", key, err)
+		}
+		got, err := fc.GetFile(ctx, key)
+		if err != nil {
+			t.Fatalf("GetFile(%s) error = %v", key, err)
+		}
+		if got.ID != file.ID || got.FileName != file.FileName || got.Size != file.Size || got.UserID != file.UserID {
+			t.Errorf("GetFile(%s) = %+v, want %+v", key, got, file)
+		}
+	}
+
+	if _, err := fc.GetFile(ctx, ByID(99)); !errors.Is(err, cache.ErrCacheMiss) {
+		t.Errorf("GetFile(uncached) error = %v, want cache.ErrCacheMiss", err)
+	}
+}
+
+func TestGetFiles(t *testing.T) {
+	ctx := context.Background()
+	_, _, fc := newTestCache(t)
+	if err := fc.PutFile(ctx, ByID(1), testFile(1, "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fc.PutNotFound(ctx, ByID(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	files, missed, err := fc.GetFiles(ctx, []uint64{1, 2, 3})
+	if err != nil {
+		t.Fatalf("GetFiles error = %v", err)
+	}
+	if len(files) != 1 || files[1] == nil || files[1].FileName != "a.txt" {
+		t.Errorf("GetFiles files = %v, want only file 1", files)
+	}
+	if len(missed) != 1 || missed[0] != 3 {
+		t.Errorf("GetFiles missed = %v, want [3]", missed)
+	}
+}
+
+func TestFileTTL(t *testing.T) {
+	ctx := context.Background()
+	mr, _, fc := newTestCache(t)
+	if err := fc.PutFile(ctx, ByID(1), testFile(1, "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fc.PutNotFound(ctx, ByID(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl := mr.TTL(string(ByID(1))); ttl < cache.CacheTTL || ttl >= cache.CacheTTL+5*time.Minute {
+		t.Errorf("metadata TTL = %v, want within [%v, %v)", ttl, cache.CacheTTL, cache.CacheTTL+5*time.Minute)
+	}
+	if _, err := fc.GetFile(ctx, ByID(2)); !errors.Is(err, xerr.ErrFileNotFound) {
+		t.Errorf("GetFile(not found marker) error = %v, want xerr.ErrFileNotFound", err)
+	}
+
+	// "不存在"标记先于元数据过期
+	mr.FastForward(notFoundTTL + time.Second)
+	if _, err := fc.GetFile(ctx, ByID(2)); !errors.Is(err, cache.ErrCacheMiss) {
+		t.Errorf("GetFile(expired marker) error = %v, want cache.ErrCacheMiss", err)
+	}
+	if _, err := fc.GetFile(ctx, ByID(1)); err != nil {
+		t.Errorf("GetFile(metadata) error = %v, want hit", err)
+	}
+
+	mr.FastForward(cache.CacheTTL + 5*time.Minute)
+	if _, err := fc.GetFile(ctx, ByID(1)); !errors.Is(err, cache.ErrCacheMiss) {
+		t.Errorf("GetFile(expired metadata) error = %v, want cache.ErrCacheMiss", err)
+	}
+}
+
+func TestPutFileClearsNotFoundMarker(t *testing.T) {
+	ctx := context.Background()
+	_, _, fc := newTestCache(t)
+	if err := fc.PutNotFound(ctx, ByID(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fc.PutFile(ctx, ByID(1), testFile(1, "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fc.GetFile(ctx, ByID(1)); err != nil {
+		t.Errorf("GetFile error = %v, want hit", err)
+	}
+}
+
+func TestInvalidateFile(t *testing.T) {
+	ctx := context.Background()
+	mr, _, fc := newTestCache(t)
+	file := testFile(1, "a.txt")
+	idKey, md5Key := ByID(file.ID), ByMD5(file.UserID, *file.MD5Hash)
+	for _, key := range []Key{idKey, md5Key} {
+		if err := fc.PutFile(ctx, key, file); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := fc.InvalidateFile(ctx, file); err != nil {
+		t.Fatalf("InvalidateFile error = %v", err)
+	}
+	for _, key := range []Key{idKey, md5Key} {
+		if mr.Exists(string(key)) {
+			t.Errorf("key %s still exists after InvalidateFile", key)
+		}
+	}
+}
+
+func TestFolderPage(t *testing.T) {
+	ctx := context.Background()
+	parentID := uint64(1)
+	files := []models.File{*testFile(11, "a.txt"), *testFile(12, "b.txt"), *testFile(13, "c.txt")}
+
+	tests := []struct {
+		name        string
+		start, stop int64
+		wantIDs     []uint64
+	}{
+		{name: "first page", start: 0, stop: 1, wantIDs: []uint64{11, 12}},
+		{name: "last page", start: 2, stop: 3, wantIDs: []uint64{13}},
+		{name: "past the end", start: 5, stop: 6, wantIDs: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, fc := newTestCache(t)
+			if err := fc.PutFolder(ctx, 7, &parentID, "name", "asc", files); err != nil {
+				t.Fatal(err)
+			}
+			got, total, err := fc.GetFolderPage(ctx, 7, &parentID, "name", "asc", tt.start, tt.stop)
+			if err != nil {
+				t.Fatalf("GetFolderPage error = %v", err)
+			}
+			if total != int64(len(files)) {
+				t.Errorf("total = %d, want %d", total, len(files))
+			}
+			if ids := fileIDs(got); !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("page = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestFolderPageAfter(t *testing.T) {
+	ctx := context.Background()
+	_, _, fc := newTestCache(t)
+	parentID := uint64(1)
+	files := []models.File{*testFile(11, "a.txt"), *testFile(12, "b.txt"), *testFile(13, "c.txt")}
+	if err := fc.PutFolder(ctx, 7, &parentID, "name", "asc", files); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _, err := fc.GetFolderPageAfter(ctx, 7, &parentID, "name", "asc", 11, 10)
+	if err != nil {
+		t.Fatalf("GetFolderPageAfter error = %v", err)
+	}
+	if ids := fileIDs(got); !slices.Equal(ids, []uint64{12, 13}) {
+		t.Errorf("page = %v, want [12 13]", ids)
+	}
+	if _, _, err := fc.GetFolderPageAfter(ctx, 7, &parentID, "name", "asc", 99, 10); !errors.Is(err, cache.ErrCacheMiss) {
+		t.Errorf("GetFolderPageAfter(unknown cursor) error = %v, want cache.ErrCacheMiss", err)
+	}
+}
+
+func TestEmptyFolder(t *testing.T) {
+	ctx := context.Background()
+	_, _, fc := newTestCache(t)
+	if _, _, err := fc.GetFolderPage(ctx, 7, nil, "name", "asc", 0, 9); !errors.Is(err, cache.ErrCacheMiss) {
+		t.Fatalf("GetFolderPage(uncached) error = %v, want cache.ErrCacheMiss", err)
+	}
+	if err := fc.PutFolder(ctx, 7, nil, "name", "asc", nil); err != nil {
+		t.Fatal(err)
+	}
+	got, total, err := fc.GetFolderPage(ctx, 7, nil, "name", "asc", 0, 9)
+	if err != nil || total != 0 || len(got) != 0 {
+		t.Errorf("GetFolderPage(empty) = %v, %d, %v, want empty hit", got, total, err)
+	}
+}
+
+func TestFolderPageMissingMetadata(t *testing.T) {
+	ctx := context.Background()
+	mr, _, fc := newTestCache(t)
+	parentID := uint64(1)
+	files := []models.File{*testFile(11, "a.txt"), *testFile(12, "b.txt")}
+	if err := fc.PutFolder(ctx, 7, &parentID, "name", "asc", files); err != nil {
+		t.Fatal(err)
+	}
+
+	// 列表中的元数据被单独删除后这一页不完整,需要回源
+	mr.Del(string(ByID(12)))
+	if _, _, err := fc.GetFolderPage(ctx, 7, &parentID, "name", "asc", 0, 9); !errors.Is(err, cache.ErrCacheMiss) {
+		t.Errorf("GetFolderPage error = %v, want cache.ErrCacheMiss", err)
+	}
+}
+
+func TestMutateListInvalidatesLists(t *testing.T) {
+	ctx := context.Background()
+	_, _, fc := newTestCache(t)
+	oldParent, newParent := uint64(1), uint64(2)
+	before := testFile(11, "a.txt")
+	before.ParentFolderID = &oldParent
+	after := *before
+	after.ParentFolderID = &newParent
+
+	for _, parentID := range []*uint64{&oldParent, &newParent} {
+		if err := fc.PutFolder(ctx, 7, parentID, "name", "asc", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fc.MutateList(ctx, ListChange{Before: before, After: &after}); err != nil {
+		t.Fatalf("MutateList error = %v", err)
+	}
+	for _, parentID := range []*uint64{&oldParent, &newParent} {
+		if _, _, err := fc.GetFolderPage(ctx, 7, parentID, "name", "asc", 0, 9); !errors.Is(err, cache.ErrCacheMiss) {
+			t.Errorf("GetFolderPage(%d) error = %v, want cache.ErrCacheMiss", *parentID, err)
+		}
+	}
+}
+
+func TestTrash(t *testing.T) {
+	ctx := context.Background()
+	_, _, fc := newTestCache(t)
+	now := time.Now()
+	older, newer := testFile(11, "a.txt"), testFile(12, "b.txt")
+	older.DeletedAt.Time, older.DeletedAt.Valid = now.Add(-time.Hour), true
+	newer.DeletedAt.Time, newer.DeletedAt.Valid = now, true
+	if err := fc.PutTrash(ctx, 7, []models.File{*older, *newer}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := fc.GetTrash(ctx, 7)
+	if err != nil {
+		t.Fatalf("GetTrash error = %v", err)
+	}
+	if ids := fileIDs(got); !slices.Equal(ids, []uint64{12, 11}) {
+		t.Errorf("trash = %v, want [12 11]", ids)
+	}
+
+	// 恢复文件后从回收站列表中移除
+	restored := *older
+	restored.DeletedAt.Valid = false
+	if err := fc.MutateList(ctx, ListChange{Before: older, After: &restored}); err != nil {
+		t.Fatal(err)
+	}
+	got, err = fc.GetTrashPageAfter(ctx, 7, 0, 10)
+	if err != nil {
+		t.Fatalf("GetTrashPageAfter error = %v", err)
+	}
+	if ids := fileIDs(got); !slices.Equal(ids, []uint64{12}) {
+		t.Errorf("trash after restore = %v, want [12]", ids)
+	}
+}
+
+func TestFolderChains(t *testing.T) {
+	ctx := context.Background()
+	_, _, fc := newTestCache(t)
+	rootID := uint64(1)
+	chain := []models.File{
+		{ID: 1, FileName: "root", IsFolder: 1},
+		{ID: 2, FileName: "docs", ParentFolderID: &rootID, IsFolder: 1},
+	}
+
+	_, missed, epoch, err := fc.GetFolderChains(ctx, 7, []uint64{2})
+	if err != nil || len(missed) != 1 {
+		t.Fatalf("GetFolderChains(uncached) = missed %v, err %v, want [2]", missed, err)
+	}
+	if err := fc.PutFolderChains(ctx, 7, epoch, map[uint64][]models.File{2: chain}); err != nil {
+		t.Fatal(err)
+	}
+	chains, missed, _, err := fc.GetFolderChains(ctx, 7, []uint64{2})
+	if err != nil || len(missed) != 0 {
+		t.Fatalf("GetFolderChains = missed %v, err %v, want hit", missed, err)
+	}
+	if got := chains[2]; len(got) != 2 || got[1].FileName != "docs" || got[1].UserID != 7 {
+		t.Errorf("chain = %+v, want root/docs owned by user 7", got)
+	}
+
+	if err := fc.InvalidateFolderChains(ctx, 7); err != nil {
+		t.Fatalf("InvalidateFolderChains error = %v", err)
+	}
+	if _, missed, _, _ := fc.GetFolderChains(ctx, 7, []uint64{2}); len(missed) != 1 {
+		t.Errorf("GetFolderChains after invalidation missed = %v, want [2]", missed)
+	}
+}
+
+func TestFolderChainsWrittenWithStaleEpoch(t *testing.T) {
+	ctx := context.Background()
+	_, _, fc := newTestCache(t)
+	_, _, epoch, err := fc.GetFolderChains(ctx, 7, []uint64{2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 回源查询期间文件夹发生变化,查询结果写入后也不会被读到
+	if err := fc.InvalidateFolderChains(ctx, 7); err != nil {
+		t.Fatal(err)
+	}
+	if err := fc.PutFolderChains(ctx, 7, epoch, map[uint64][]models.File{2: {{ID: 2, FileName: "old"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, missed, _, _ := fc.GetFolderChains(ctx, 7, []uint64{2}); len(missed) != 1 {
+		t.Errorf("GetFolderChains missed = %v, want [2]", missed)
+	}
+}
+
+func fileIDs(files []models.File) []uint64 {
+	var ids []uint64
+	for _, file := range files {
+		ids = append(ids, file.ID)
+	}
+	return ids
+}
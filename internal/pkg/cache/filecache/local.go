@@ -0,0 +1,327 @@
+package filecache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/metrics"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	// EvictionStream 本地缓存的淘汰消息，删除缓存的实例通过它通知其他实例丢弃本地副本
+	EvictionStream = "file_cache_evictions"
+	// evictionStreamMaxLen 淘汰消息流保留的近似最大长度
+	evictionStreamMaxLen = 10000
+
+	// watchBlockTimeout 每次阻塞读取的最长时间，超时后检查是否需要退出
+	watchBlockTimeout = 2 * time.Second
+)
+
+// EvictionMessage 需要从各实例本地缓存中丢弃的键
+type EvictionMessage struct {
+	Keys []Key `json:"keys"`
+}
+
+// localTier 进程内共享的本地缓存，未启用时为 nil
+var localTier atomic.Pointer[LocalCache]
+
+// EnableLocalCache 启用进程内的本地缓存，之后 New 创建的 FileCache 都会先查本地缓存再查 Redis。
+// 同一进程内的所有文件仓储(包括事务内创建的)共享同一份本地缓存
+func EnableLocalCache(maxEntries int, ttl time.Duration) *LocalCache {
+	local := NewLocalCache(maxEntries, ttl)
+	localTier.Store(local)
+	return local
+}
+
+type localEntry struct {
+	key       Key
+	file      models.File
+	expiresAt time.Time
+}
+
+// LocalCache 进程内的 LRU 缓存，保存文件元数据的副本。
+// 只缓存存在的文件，"不存在"标记仍由 Redis 负责
+type LocalCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	ll         *list.List
+	items      map[Key]*list.Element
+}
+
+func NewLocalCache(maxEntries int, ttl time.Duration) *LocalCache {
+	if maxEntries <= 0 {
+		maxEntries = 10000
+	}
+	if ttl <= 0 {
+		ttl = 10 * time.Second
+	}
+	return &LocalCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		ll:         list.New(),
+		items:      make(map[Key]*list.Element),
+	}
+}
+
+// Get 读取未过期的副本，返回值可以被调用方修改
+func (l *LocalCache) Get(key Key) (*models.File, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	elem, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*localEntry)
+	if time.Now().After(entry.expiresAt) {
+		l.removeElement(elem)
+		return nil, false
+	}
+	l.ll.MoveToFront(elem)
+	file := entry.file
+	return &file, true
+}
+
+// Put 写入副本，超出容量时淘汰最久未使用的条目
+func (l *LocalCache) Put(key Key, file *models.File) {
+	if file == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	expiresAt := time.Now().Add(l.ttl)
+	if elem, ok := l.items[key]; ok {
+		entry := elem.Value.(*localEntry)
+		entry.file = *file
+		entry.expiresAt = expiresAt
+		l.ll.MoveToFront(elem)
+		return
+	}
+	l.items[key] = l.ll.PushFront(&localEntry{key: key, file: *file, expiresAt: expiresAt})
+	for l.ll.Len() > l.maxEntries {
+		l.removeElement(l.ll.Back())
+	}
+}
+
+// Evict 丢弃指定的键
+func (l *LocalCache) Evict(keys ...Key) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		if elem, ok := l.items[key]; ok {
+			l.removeElement(elem)
+		}
+	}
+}
+
+// Purge 清空本地缓存
+func (l *LocalCache) Purge() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ll.Init()
+	l.items = make(map[Key]*list.Element)
+}
+
+// Len 当前条目数
+func (l *LocalCache) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ll.Len()
+}
+
+func (l *LocalCache) removeElement(elem *list.Element) {
+	l.ll.Remove(elem)
+	delete(l.items, elem.Value.(*localEntry).key)
+}
+
+// Watch 订阅文件缓存更新和淘汰消息，丢弃本地缓存中过期的副本，ctx 取消后退出。
+// 不使用消费者组，每个实例都需要收到全部消息；读取失败期间可能漏掉消息，恢复后清空本地缓存
+func (l *LocalCache) Watch(ctx context.Context, redisClient *redis.Client) {
+	streams := []string{UpdateStream, EvictionStream}
+	lastIDs := map[string]string{}
+	for _, stream := range streams {
+		lastIDs[stream] = "$" // 只关心启动之后的消息
+	}
+
+	failed := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		args := make([]string, 0, len(streams)*2)
+		args = append(args, streams...)
+		for _, stream := range streams {
+			args = append(args, lastIDs[stream])
+		}
+		result, err := redisClient.XRead(ctx, &redis.XReadArgs{
+			Streams: args,
+			Count:   100,
+			Block:   watchBlockTimeout,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+				continue
+			}
+			logger.Error("LocalFileCache: Failed to read from streams", zap.Error(err))
+			failed = true
+			time.Sleep(time.Second)
+			continue
+		}
+		if failed {
+			// 读取中断期间可能错过了淘汰消息
+			l.Purge()
+			failed = false
+		}
+
+		for _, stream := range result {
+			for _, message := range stream.Messages {
+				lastIDs[stream.Stream] = message.ID
+				l.apply(stream.Stream, message)
+			}
+		}
+	}
+}
+
+// apply 根据消息丢弃受影响的条目，无法解析的消息直接忽略
+func (l *LocalCache) apply(stream string, message redis.XMessage) {
+	payload, _ := message.Values["payload"].(string)
+	switch stream {
+	case UpdateStream:
+		var msg cache.CacheUpdateMessage
+		if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+			return
+		}
+		keys := []Key{ByID(msg.File.ID)}
+		if msg.File.MD5Hash != nil && *msg.File.MD5Hash != "" {
+			keys = append(keys, ByMD5(msg.File.UserID, *msg.File.MD5Hash))
+		}
+		if msg.OldMD5Hash != nil && *msg.OldMD5Hash != "" {
+			keys = append(keys, ByMD5(msg.File.UserID, *msg.OldMD5Hash))
+		}
+		l.Evict(keys...)
+	case EvictionStream:
+		var msg EvictionMessage
+		if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+			return
+		}
+		l.Evict(msg.Keys...)
+	}
+}
+
+// tieredFileCache 在 Redis 缓存前增加进程内的本地缓存，只作用于单个文件的元数据
+type tieredFileCache struct {
+	FileCache
+	local *LocalCache
+	redis *cache.RedisCache
+}
+
+var _ FileCache = (*tieredFileCache)(nil)
+
+func (c *tieredFileCache) GetFile(ctx context.Context, key Key) (*models.File, error) {
+	if file, ok := c.local.Get(key); ok {
+		metrics.ObserveCache("file_metadata_local", true)
+		return file, nil
+	}
+	metrics.ObserveCache("file_metadata_local", false)
+	file, err := c.FileCache.GetFile(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	c.local.Put(key, file)
+	return file, nil
+}
+
+// GetFiles 本地缓存中没有的文件再批量从 Redis 读取,读到的写入本地缓存
+func (c *tieredFileCache) GetFiles(ctx context.Context, fileIDs []uint64) (map[uint64]*models.File, []uint64, error) {
+	files := make(map[uint64]*models.File, len(fileIDs))
+	var remote []uint64
+	for _, fileID := range fileIDs {
+		if file, ok := c.local.Get(ByID(fileID)); ok {
+			files[fileID] = file
+			continue
+		}
+		remote = append(remote, fileID)
+	}
+	if len(remote) == 0 {
+		return files, nil, nil
+	}
+	fetched, missed, err := c.FileCache.GetFiles(ctx, remote)
+	if err != nil {
+		return nil, nil, err
+	}
+	for fileID, file := range fetched {
+		c.local.Put(ByID(fileID), file)
+		files[fileID] = file
+	}
+	return files, missed, nil
+}
+
+func (c *tieredFileCache) PutFile(ctx context.Context, key Key, file *models.File) error {
+	if err := c.FileCache.PutFile(ctx, key, file); err != nil {
+		c.local.Evict(key)
+		return err
+	}
+	c.local.Put(key, file)
+	return nil
+}
+
+func (c *tieredFileCache) PutNotFound(ctx context.Context, key Key) error {
+	c.local.Evict(key)
+	return c.FileCache.PutNotFound(ctx, key)
+}
+
+// InvalidateFile 删除两级缓存，并通知其他实例丢弃本地副本
+func (c *tieredFileCache) InvalidateFile(ctx context.Context, files ...*models.File) error {
+	keys := make([]Key, 0, len(files)*2)
+	for _, file := range files {
+		keys = append(keys, ByID(file.ID))
+		if file.MD5Hash != nil && *file.MD5Hash != "" {
+			keys = append(keys, ByMD5(file.UserID, *file.MD5Hash))
+		}
+	}
+	c.local.Evict(keys...)
+	if err := c.FileCache.InvalidateFile(ctx, files...); err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(EvictionMessage{Keys: keys})
+	if err != nil {
+		return err
+	}
+	if err := c.redis.XAdd(ctx, &redis.XAddArgs{
+		Stream: EvictionStream,
+		MaxLen: evictionStreamMaxLen,
+		Approx: true,
+		Values: map[string]any{"payload": string(payload)},
+	}).Err(); err != nil {
+		// Redis 中的缓存已删除，其他实例的本地副本会在过期后自然淘汰
+		logger.Warn("LocalFileCache: Failed to publish eviction",
+			zap.String("keys", joinKeys(keys)), zap.Error(err))
+	}
+	return nil
+}
+
+func joinKeys(keys []Key) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = string(key)
+	}
+	return strings.Join(parts, ",")
+}
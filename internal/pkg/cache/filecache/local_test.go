@@ -0,0 +1,244 @@
+package filecache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// newTestTieredCache 返回本地缓存在 miniredis 之前的两级缓存
+func newTestTieredCache(t *testing.T, ttl time.Duration) (*miniredis.Miniredis, *tieredFileCache) {
+	t.Helper()
+	mr, redisCache, fc := newTestCache(t)
+	return mr, &tieredFileCache{FileCache: fc, local: NewLocalCache(10, ttl), redis: redisCache}
+}
+
+func TestLocalCacheSetAndGet(t *testing.T) {
+	local := NewLocalCache(10, time.Minute)
+	file := testFile(1, "a.txt")
+	local.Put(ByID(1), file)
+
+	got, ok := local.Get(ByID(1))
+	if !ok || got.FileName != "a.txt" {
+		t.Fatalf("Get = %v, %v, want a.txt", got, ok)
+	}
+	// 返回的是副本,调用方修改不影响缓存
+	got.FileName = "changed"
+	if again, _ := local.Get(ByID(1)); again.FileName != "a.txt" {
+		t.Errorf("cached file changed to %q through returned copy", again.FileName)
+	}
+	if _, ok := local.Get(ByID(2)); ok {
+		t.Error("Get(uncached) hit, want miss")
+	}
+}
+
+func TestLocalCacheTTL(t *testing.T) {
+	local := NewLocalCache(10, 20*time.Millisecond)
+	local.Put(ByID(1), testFile(1, "a.txt"))
+	time.Sleep(40 * time.Millisecond)
+
+	if _, ok := local.Get(ByID(1)); ok {
+		t.Error("Get(expired) hit, want miss")
+	}
+	if n := local.Len(); n != 0 {
+		t.Errorf("Len after expiry = %d, want 0", n)
+	}
+}
+
+func TestLocalCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	local := NewLocalCache(2, time.Minute)
+	local.Put(ByID(1), testFile(1, "a.txt"))
+	local.Put(ByID(2), testFile(2, "b.txt"))
+	local.Get(ByID(1))
+	local.Put(ByID(3), testFile(3, "c.txt"))
+
+	if _, ok := local.Get(ByID(2)); ok {
+		t.Error("least recently used entry was kept")
+	}
+	for _, id := range []uint64{1, 3} {
+		if _, ok := local.Get(ByID(id)); !ok {
+			t.Errorf("entry %d was evicted", id)
+		}
+	}
+}
+
+func TestLocalCacheApply(t *testing.T) {
+	file := testFile(1, "a.txt")
+	oldMD5 := "old-md5"
+	update, _ := json.Marshal(cache.CacheUpdateMessage{File: *file, OldMD5Hash: &oldMD5})
+	eviction, _ := json.Marshal(EvictionMessage{Keys: []Key{ByID(1)}})
+
+	tests := []struct {
+		name     string
+		stream   string
+		payload  string
+		evicted  []Key
+		retained []Key
+	}{
+		{
+			name:     "update evicts id and both md5 keys",
+			stream:   UpdateStream,
+			payload:  string(update),
+			evicted:  []Key{ByID(1), ByMD5(7, *file.MD5Hash), ByMD5(7, oldMD5)},
+			retained: []Key{ByID(2)},
+		},
+		{
+			name:     "eviction evicts listed keys",
+			stream:   EvictionStream,
+			payload:  string(eviction),
+			evicted:  []Key{ByID(1)},
+			retained: []Key{ByID(2), ByMD5(7, *file.MD5Hash)},
+		},
+		{
+			name:     "malformed message is ignored",
+			stream:   EvictionStream,
+			payload:  "{",
+			retained: []Key{ByID(1), ByID(2)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local := NewLocalCache(10, time.Minute)
+			for _, key := range []Key{ByID(1), ByID(2), ByMD5(7, *file.MD5Hash), ByMD5(7, oldMD5)} {
+				local.Put(key, file)
+			}
+			local.apply(tt.stream, redis.XMessage{Values: map[string]any{"payload": tt.payload}})
+			for _, key := range tt.evicted {
+				if _, ok := local.Get(key); ok {
+					t.Errorf("%s was not evicted", key)
+				}
+			}
+			for _, key := range tt.retained {
+				if _, ok := local.Get(key); !ok {
+					t.Errorf("%s was evicted", key)
+				}
+			}
+		})
+	}
+}
+
+func TestTieredCacheFallsBackToRedis(t *testing.T) {
+	ctx := context.Background()
+	mr, tiered := newTestTieredCache(t, time.Minute)
+	file := testFile(1, "a.txt")
+
+	// 只在 Redis 中的文件读取后写入本地缓存
+	if err := tiered.FileCache.PutFile(ctx, ByID(1), file); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tiered.local.Get(ByID(1)); ok {
+		t.Fatal("file cached locally before first read")
+	}
+	if _, err := tiered.GetFile(ctx, ByID(1)); err != nil {
+		t.Fatalf("GetFile error = %v", err)
+	}
+	if _, ok := tiered.local.Get(ByID(1)); !ok {
+		t.Fatal("file not cached locally after reading from Redis")
+	}
+
+	// 本地副本在 Redis 不可用时仍然可以读取,本地未命中时返回 Redis 的错误
+	mr.Close()
+	if got, err := tiered.GetFile(ctx, ByID(1)); err != nil || got.FileName != "a.txt" {
+		t.Errorf("GetFile(local hit, redis down) = %v, %v, want a.txt", got, err)
+	}
+	if _, err := tiered.GetFile(ctx, ByID(2)); err == nil || errors.Is(err, cache.ErrCacheMiss) {
+		t.Errorf("GetFile(local miss, redis down) error = %v, want redis error", err)
+	}
+}
+
+func TestTieredCacheGetFiles(t *testing.T) {
+	ctx := context.Background()
+	mr, tiered := newTestTieredCache(t, time.Minute)
+	tiered.local.Put(ByID(1), testFile(1, "a.txt"))
+	if err := tiered.FileCache.PutFile(ctx, ByID(2), testFile(2, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	files, missed, err := tiered.GetFiles(ctx, []uint64{1, 2, 3})
+	if err != nil {
+		t.Fatalf("GetFiles error = %v", err)
+	}
+	if len(files) != 2 || len(missed) != 1 || missed[0] != 3 {
+		t.Errorf("GetFiles = %d files, missed %v, want 2 files and [3]", len(files), missed)
+	}
+	if _, ok := tiered.local.Get(ByID(2)); !ok {
+		t.Error("file read from Redis was not cached locally")
+	}
+
+	// 全部命中本地缓存时不访问 Redis
+	mr.Close()
+	if _, _, err := tiered.GetFiles(ctx, []uint64{1, 2}); err != nil {
+		t.Errorf("GetFiles(all local) error = %v", err)
+	}
+}
+
+func TestTieredCacheLocalTTL(t *testing.T) {
+	ctx := context.Background()
+	_, tiered := newTestTieredCache(t, 20*time.Millisecond)
+	if err := tiered.PutFile(ctx, ByID(1), testFile(1, "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	// 本地副本过期后从 Redis 读取最新的值
+	updated := testFile(1, "renamed.txt")
+	if err := tiered.FileCache.PutFile(ctx, ByID(1), updated); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(40 * time.Millisecond)
+	got, err := tiered.GetFile(ctx, ByID(1))
+	if err != nil || got.FileName != "renamed.txt" {
+		t.Errorf("GetFile = %v, %v, want renamed.txt", got, err)
+	}
+}
+
+func TestTieredCacheInvalidateFile(t *testing.T) {
+	ctx := context.Background()
+	mr, tiered := newTestTieredCache(t, time.Minute)
+	file := testFile(1, "a.txt")
+	if err := tiered.PutFile(ctx, ByID(1), file); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tiered.InvalidateFile(ctx, file); err != nil {
+		t.Fatalf("InvalidateFile error = %v", err)
+	}
+	if _, ok := tiered.local.Get(ByID(1)); ok {
+		t.Error("local copy kept after InvalidateFile")
+	}
+	if mr.Exists(string(ByID(1))) {
+		t.Error("Redis key kept after InvalidateFile")
+	}
+
+	// 其他实例通过淘汰消息丢弃本地副本
+	entries, err := mr.Stream(EvictionStream)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("eviction stream = %v, %v, want one message", entries, err)
+	}
+	var msg EvictionMessage
+	if err := json.Unmarshal([]byte(entries[0].Values[1]), &msg); err != nil {
+		t.Fatal(err)
+	}
+	want := []Key{ByID(1), ByMD5(file.UserID, *file.MD5Hash)}
+	if len(msg.Keys) != len(want) || msg.Keys[0] != want[0] || msg.Keys[1] != want[1] {
+		t.Errorf("eviction keys = %v, want %v", msg.Keys, want)
+	}
+}
+
+func TestTieredCacheNotFoundEvictsLocal(t *testing.T) {
+	ctx := context.Background()
+	_, tiered := newTestTieredCache(t, time.Minute)
+	tiered.local.Put(ByID(1), testFile(1, "a.txt"))
+
+	if err := tiered.PutNotFound(ctx, ByID(1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tiered.local.Get(ByID(1)); ok {
+		t.Error("local copy kept after PutNotFound")
+	}
+}
@@ -58,6 +58,24 @@ func (r *RedisCache) Get(ctx context.Context, key string, target any) error {
 	return nil
 }
 
+func (r *RedisCache) GetDel(ctx context.Context, key string, target any) error {
+	data, err := r.client.GetDel(ctx, key).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return ErrCacheMiss
+		}
+		logger.Error("Failed to get and delete value from Redis", zap.String("key", key), zap.Error(err))
+		return fmt.Errorf("从 Redis 读取并删除失败: %w", err)
+	}
+
+	err = json.Unmarshal(data, target)
+	if err != nil {
+		logger.Error("Failed to unmarshal cached value", zap.String("key", key), zap.Error(err))
+		return fmt.Errorf("反序列化缓存值失败: %w", err)
+	}
+	return nil
+}
+
 func (r *RedisCache) Del(ctx context.Context, keys ...string) error {
 	if len(keys) == 0 {
 		return nil
@@ -70,6 +88,21 @@ func (r *RedisCache) Del(ctx context.Context, keys ...string) error {
 	return nil
 }
 
+func (r *RedisCache) SetNX(ctx context.Context, key string, value any, expiration time.Duration) (bool, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		logger.Error("Failed to marshal cache value", zap.String("key", key), zap.Error(err))
+		return false, fmt.Errorf("序列化缓存值失败: %w", err)
+	}
+
+	ok, err := r.client.SetNX(ctx, key, data, expiration).Result()
+	if err != nil {
+		logger.Error("Failed to setnx value in Redis", zap.String("key", key), zap.Error(err))
+		return false, fmt.Errorf("写入 Redis 失败: %w", err)
+	}
+	return ok, nil
+}
+
 func (r *RedisCache) Exists(ctx context.Context, key string) (bool, error) {
 	count, err := r.client.Exists(ctx, key).Result()
 	if err != nil {
@@ -131,6 +164,15 @@ func (r *RedisCache) HGetAll(ctx context.Context, key string) (map[string]string
 	return resultMap, nil
 }
 
+// HIncrBy 把 Hash 字段的值加上 increment,字段或 key 不存在时从 0 开始
+func (r *RedisCache) HIncrBy(ctx context.Context, key string, field string, increment int64) error {
+	if err := r.client.HIncrBy(ctx, key, field, increment).Err(); err != nil {
+		logger.Error("Failed to HIncrBy in Redis", zap.String("key", key), zap.String("field", field), zap.Error(err))
+		return fmt.Errorf("HIncrBy 操作失败: %w", err)
+	}
+	return nil
+}
+
 func (r *RedisCache) HDel(ctx context.Context, key string, fields ...string) error {
 	if len(fields) == 0 {
 		return nil
@@ -147,12 +189,33 @@ func (r *RedisCache) ZAdd(ctx context.Context, key string, members ...*redis.Z)
 	return r.client.ZAdd(ctx, key, members...)
 }
 
+// ZRange返回已排序集合中存储在key处的指定元素范围。
+// 元素按照从低到高的顺序排列。
+func (r *RedisCache) ZRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd {
+	return r.client.ZRange(ctx, key, start, stop)
+}
+
+// ZCard返回已排序集合的元素数量,key 不存在时返回 0
+func (r *RedisCache) ZCard(ctx context.Context, key string) *redis.IntCmd {
+	return r.client.ZCard(ctx, key)
+}
+
 // ZRevRange返回已排序集合中存储在key处的指定元素范围。
 // 元素按照从高到低的顺序排列。
 func (r *RedisCache) ZRevRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd {
 	return r.client.ZRevRange(ctx, key, start, stop)
 }
 
+// ZRank返回成员按从低到高排列的名次,成员或 key 不存在时返回 redis.Nil
+func (r *RedisCache) ZRank(ctx context.Context, key, member string) *redis.IntCmd {
+	return r.client.ZRank(ctx, key, member)
+}
+
+// ZRevRank返回成员按从高到低排列的名次,成员或 key 不存在时返回 redis.Nil
+func (r *RedisCache) ZRevRank(ctx context.Context, key, member string) *redis.IntCmd {
+	return r.client.ZRevRank(ctx, key, member)
+}
+
 func (r *RedisCache) ZRem(ctx context.Context, key string, members ...any) *redis.IntCmd {
 	return r.client.ZRem(ctx, key, members...)
 }
@@ -161,6 +224,15 @@ func (r *RedisCache) XAdd(ctx context.Context, a *redis.XAddArgs) *redis.StringC
 	return r.client.XAdd(ctx, a)
 }
 
+// Publish 向 pub/sub 频道发布消息,没有订阅者时消息直接丢弃
+func (r *RedisCache) Publish(ctx context.Context, channel string, message any) error {
+	if err := r.client.Publish(ctx, channel, message).Err(); err != nil {
+		logger.Error("Failed to publish message to Redis", zap.String("channel", channel), zap.Error(err))
+		return fmt.Errorf("发布消息失败: %w", err)
+	}
+	return nil
+}
+
 func (r *RedisCache) Expire(ctx context.Context, key string, expiration time.Duration) error {
 	err := r.client.Expire(ctx, key, expiration).Err()
 	if err != nil {
@@ -179,6 +251,11 @@ func (r *RedisCache) TTL(ctx context.Context, key string) (time.Duration, error)
 	return ttl, nil
 }
 
+// RunScript 执行 Lua 脚本,优先使用 EVALSHA,脚本未缓存时自动回退到 EVAL
+func (r *RedisCache) RunScript(ctx context.Context, script *redis.Script, keys []string, args ...any) *redis.Cmd {
+	return script.Run(ctx, r.client, keys, args...)
+}
+
 // TxPipeline返回一个新的管道，可以用来发送多个命令
 // 到Redis的单次往返。
 func (r *RedisCache) TxPipeline() redis.Pipeliner {
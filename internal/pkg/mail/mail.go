@@ -0,0 +1,118 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const dialTimeout = 10 * time.Second
+
+// Sender 发送纯文本邮件
+type Sender interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// NewSender 根据配置创建邮件发送器,未配置 SMTP 地址时返回只写日志的发送器
+func NewSender(cfg config.MailConfig) Sender {
+	if cfg.SMTPHost == "" {
+		return &logSender{}
+	}
+	return &smtpSender{cfg: cfg}
+}
+
+type smtpSender struct {
+	cfg config.MailConfig
+}
+
+func (s *smtpSender) Send(ctx context.Context, to, subject, body string) error {
+	host := s.cfg.SMTPHost
+	addr := net.JoinHostPort(host, strconv.Itoa(s.cfg.SMTPPort))
+	tlsConfig := &tls.Config{ServerName: host}
+	dialer := &net.Dialer{Timeout: dialTimeout}
+
+	var conn net.Conn
+	var err error
+	implicitTLS := s.cfg.SMTPPort == 465
+	if implicitTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("mail: failed to connect to %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("mail: failed to create smtp client: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && !implicitTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("mail: failed to start tls: %w", err)
+		}
+	}
+	if s.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, host)); err != nil {
+			return fmt.Errorf("mail: failed to authenticate: %w", err)
+		}
+	}
+	if err := client.Mail(s.cfg.From); err != nil {
+		return fmt.Errorf("mail: failed to set sender: %w", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("mail: failed to set recipient: %w", err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("mail: failed to start data: %w", err)
+	}
+	if _, err := w.Write(buildMessage(s.cfg.From, to, subject, body)); err != nil {
+		return fmt.Errorf("mail: failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("mail: failed to send message: %w", err)
+	}
+	return client.Quit()
+}
+
+// buildMessage 构造 UTF-8 纯文本邮件,主题按 RFC 2047 编码
+func buildMessage(from, to, subject, body string) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + to + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// logSender 只把邮件写入日志,用于未配置 SMTP 的开发环境
+type logSender struct{}
+
+func (s *logSender) Send(ctx context.Context, to, subject, body string) error {
+	logger.Info("Mail not sent, SMTP is not configured",
+		zap.String("to", to), zap.String("subject", subject), zap.String("body", body))
+	return nil
+}
@@ -80,7 +80,8 @@ func stringToTimeHookFunc() mapstructure.DecodeHookFunc {
 	}
 }
 
-// stringToNumericHookFunc 创建一个解码钩子，用于将字符串转换为所有数值类型（包括指针）。
+// stringToNumericHookFunc 创建一个解码钩子，用于将字符串转换为所有数值类型和布尔类型（包括指针）。
+// Redis 中的布尔值保存为 "1" 和 "0"
 func stringToNumericHookFunc() mapstructure.DecodeHookFunc {
 	return func(f reflect.Type, t reflect.Type, data any) (any, error) {
 		if f.Kind() != reflect.String {
@@ -125,6 +126,12 @@ func stringToNumericHookFunc() mapstructure.DecodeHookFunc {
 				result = newVal.Interface()
 			}
 			err = parseErr
+		case reflect.Bool:
+			val, parseErr := strconv.ParseBool(sourceString)
+			if parseErr == nil {
+				result = val
+			}
+			err = parseErr
 		default:
 			// 如果不是目标数值类型，则不处理
 			return data, nil
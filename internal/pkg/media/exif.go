@@ -0,0 +1,186 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+// 用到的 EXIF 标签
+const (
+	tagModel              = 0x0110
+	tagDateTime           = 0x0132
+	tagExifIFD            = 0x8769
+	tagDateTimeOriginal   = 0x9003
+	tagDateTimeDigitized  = 0x9004
+	tagOffsetTimeOriginal = 0x9011
+
+	exifTypeASCII = 2
+	exifTypeLong  = 4
+)
+
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// findExif 返回 JPEG APP1 段中的 TIFF 数据,TIFF 格式的文件(多数相机 RAW)本身即是 TIFF 数据
+func findExif(head []byte) []byte {
+	if bytes.HasPrefix(head, []byte("II*\x00")) || bytes.HasPrefix(head, []byte("MM\x00*")) {
+		return head
+	}
+	if len(head) < 4 || head[0] != 0xFF || head[1] != 0xD8 {
+		return nil
+	}
+
+	for i := 2; i+4 <= len(head); {
+		if head[i] != 0xFF {
+			return nil
+		}
+		marker := head[i+1]
+		// 填充字节和没有长度字段的标记
+		if marker == 0xFF {
+			i++
+			continue
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			i += 2
+			continue
+		}
+		// 图像数据开始后不会再有 EXIF
+		if marker == 0xDA || marker == 0xD9 {
+			return nil
+		}
+		length := int(binary.BigEndian.Uint16(head[i+2:]))
+		if length < 2 || i+2+length > len(head) {
+			return nil
+		}
+		segment := head[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:]
+		}
+		i += 2 + length
+	}
+	return nil
+}
+
+// exifEntry IFD 中的一个条目,value 为值所在的字节,长度不足时已被截断
+type exifEntry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// exifReader 读取 TIFF 数据中的 IFD,所有偏移都相对 TIFF 头
+type exifReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// parseExif 读取拍摄时间和相机型号,数据损坏时返回已读取到的部分
+func parseExif(tiff []byte) (*time.Time, string) {
+	if len(tiff) < 8 {
+		return nil, ""
+	}
+	r := &exifReader{data: tiff}
+	switch string(tiff[:2]) {
+	case "II":
+		r.order = binary.LittleEndian
+	case "MM":
+		r.order = binary.BigEndian
+	default:
+		return nil, ""
+	}
+
+	ifd0 := r.readIFD(r.order.Uint32(tiff[4:]))
+	model := r.ascii(ifd0[tagModel])
+
+	var exif map[uint16]exifEntry
+	if entry, ok := ifd0[tagExifIFD]; ok && entry.typ == exifTypeLong && len(entry.value) >= 4 {
+		exif = r.readIFD(r.order.Uint32(entry.value))
+	}
+
+	// 优先使用原始拍摄时间,旧设备可能只写入数字化时间或修改时间
+	for _, raw := range []string{
+		r.ascii(exif[tagDateTimeOriginal]),
+		r.ascii(exif[tagDateTimeDigitized]),
+		r.ascii(ifd0[tagDateTime]),
+	} {
+		if takenAt, ok := parseExifTime(raw, r.ascii(exif[tagOffsetTimeOriginal])); ok {
+			return &takenAt, model
+		}
+	}
+	return nil, model
+}
+
+func (r *exifReader) readIFD(offset uint32) map[uint16]exifEntry {
+	entries := make(map[uint16]exifEntry)
+	start := int(offset)
+	if offset == 0 || start+2 > len(r.data) {
+		return entries
+	}
+	count := int(r.order.Uint16(r.data[start:]))
+	for i := 0; i < count; i++ {
+		pos := start + 2 + i*12
+		if pos+12 > len(r.data) {
+			break
+		}
+		entry := exifEntry{
+			typ:   r.order.Uint16(r.data[pos+2:]),
+			count: r.order.Uint32(r.data[pos+4:]),
+		}
+		size := int(entry.count) * exifTypeSize(entry.typ)
+		if size < 0 || size > len(r.data) {
+			continue
+		}
+		// 不超过 4 字节的值直接存放在条目中,否则条目中保存的是偏移
+		if size <= 4 {
+			entry.value = r.data[pos+8 : pos+8+size]
+		} else {
+			valueOffset := int(r.order.Uint32(r.data[pos+8:]))
+			if valueOffset < 0 || valueOffset+size > len(r.data) {
+				continue
+			}
+			entry.value = r.data[valueOffset : valueOffset+size]
+		}
+		entries[r.order.Uint16(r.data[pos:])] = entry
+	}
+	return entries
+}
+
+func (r *exifReader) ascii(entry exifEntry) string {
+	if entry.typ != exifTypeASCII {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(entry.value), "\x00"))
+}
+
+func exifTypeSize(typ uint16) int {
+	switch typ {
+	case 1, 2, 6, 7: // BYTE ASCII SBYTE UNDEFINED
+		return 1
+	case 3, 8: // SHORT SSHORT
+		return 2
+	case 4, 9, 11: // LONG SLONG FLOAT
+		return 4
+	case 5, 10, 12: // RATIONAL SRATIONAL DOUBLE
+		return 8
+	default:
+		return 0
+	}
+}
+
+// parseExifTime EXIF 时间不带时区,有 OffsetTimeOriginal 时按其解析,否则按服务器时区解析
+func parseExifTime(raw, offset string) (time.Time, bool) {
+	if raw == "" || strings.HasPrefix(raw, "0000") {
+		return time.Time{}, false
+	}
+	if offset != "" {
+		if t, err := time.Parse(exifTimeLayout+"-07:00", raw+offset); err == nil {
+			return t, true
+		}
+	}
+	t, err := time.ParseInLocation(exifTimeLayout, raw, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,59 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif"  // 注册 GIF 解码器,用于读取尺寸
+	_ "image/jpeg" // 注册 JPEG 解码器,用于读取尺寸
+	_ "image/png"  // 注册 PNG 解码器,用于读取尺寸
+	"io"
+	"strings"
+	"time"
+)
+
+// maxImageHeader 读取图片开头的字节数,EXIF 和尺寸信息都位于文件开头
+const maxImageHeader = 1 << 20
+
+// Metadata 从图片或视频中提取的元数据,无法识别的字段保持零值
+type Metadata struct {
+	TakenAt     *time.Time // 拍摄时间,图片取 EXIF DateTimeOriginal,视频取 mvhd 创建时间
+	Width       int
+	Height      int
+	CameraModel string
+}
+
+// IsMedia 检查 MIME 类型是否为图片或视频
+func IsMedia(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "image/") || strings.HasPrefix(mimeType, "video/")
+}
+
+// Extract 按 MIME 类型提取元数据。图片只读取开头部分;视频需要定位 moov,reader 不支持 Seek 时返回空结果
+func Extract(r io.Reader, mimeType string) (Metadata, error) {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		head, err := io.ReadAll(io.LimitReader(r, maxImageHeader))
+		if err != nil {
+			return Metadata{}, err
+		}
+		return extractImage(head), nil
+	case mimeType == "video/mp4" || mimeType == "video/quicktime":
+		seeker, ok := r.(io.ReadSeeker)
+		if !ok {
+			return Metadata{}, nil
+		}
+		return extractMP4(seeker)
+	default:
+		return Metadata{}, nil
+	}
+}
+
+func extractImage(head []byte) Metadata {
+	var meta Metadata
+	if tiff := findExif(head); tiff != nil {
+		meta.TakenAt, meta.CameraModel = parseExif(tiff)
+	}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(head)); err == nil {
+		meta.Width, meta.Height = cfg.Width, cfg.Height
+	}
+	return meta
+}
@@ -0,0 +1,89 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// mp4Epoch MP4 时间字段的起点
+var mp4Epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// maxMoovSize moov 通常只有几百 KB,超过该大小不再读取
+const maxMoovSize = 16 << 20
+
+// extractMP4 在顶层 box 中查找 moov,读取其中 mvhd 记录的创建时间。moov 可能位于文件末尾,需要跳过 mdat
+func extractMP4(r io.ReadSeeker) (Metadata, error) {
+	var meta Metadata
+	header := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(r, header[:8]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return meta, nil
+			}
+			return meta, err
+		}
+		size := int64(binary.BigEndian.Uint32(header))
+		boxType := string(header[4:8])
+		headerSize := int64(8)
+		switch size {
+		case 0: // 一直延续到文件末尾
+			return meta, nil
+		case 1: // 64 位长度
+			if _, err := io.ReadFull(r, header[8:16]); err != nil {
+				return meta, nil
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if size < headerSize {
+			return meta, nil
+		}
+
+		if boxType != "moov" {
+			if _, err := r.Seek(size-headerSize, io.SeekCurrent); err != nil {
+				return meta, err
+			}
+			continue
+		}
+		if size-headerSize > maxMoovSize {
+			return meta, nil
+		}
+		moov := make([]byte, size-headerSize)
+		if _, err := io.ReadFull(r, moov); err != nil {
+			return meta, nil
+		}
+		meta.TakenAt = parseMvhd(moov)
+		return meta, nil
+	}
+}
+
+// parseMvhd 在 moov 的子 box 中查找 mvhd,创建时间为 0 表示设备没有写入
+func parseMvhd(moov []byte) *time.Time {
+	for pos := 0; pos+8 <= len(moov); {
+		size := int(binary.BigEndian.Uint32(moov[pos:]))
+		if size < 8 || pos+size > len(moov) {
+			return nil
+		}
+		if string(moov[pos+4:pos+8]) != "mvhd" {
+			pos += size
+			continue
+		}
+
+		body := moov[pos+8 : pos+size]
+		var seconds uint64
+		switch {
+		case len(body) >= 12 && body[0] == 1:
+			seconds = binary.BigEndian.Uint64(body[4:])
+		case len(body) >= 8:
+			seconds = uint64(binary.BigEndian.Uint32(body[4:]))
+		}
+		if seconds == 0 {
+			return nil
+		}
+		takenAt := mp4Epoch.Add(time.Duration(seconds) * time.Second)
+		return &takenAt
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+// Package metrics 定义 Prometheus 指标,通过 /metrics 暴露给监控系统
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "clouddisk"
+
+var (
+	// HTTPRequestsTotal 按路由模板统计请求数,route 为 gin 注册的路径,如 /api/v1/files/:file_id
+	HTTPRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Total number of HTTP requests.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// StorageOperationDuration 存储后端调用耗时,result 为 success 或 error
+	StorageOperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_duration_seconds",
+		Help:      "Latency of storage backend operations.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, []string{"operation", "result"})
+
+	// CacheRequestsTotal 缓存命中情况,result 为 hit 或 miss
+	CacheRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Cache lookups by result.",
+	}, []string{"cache", "result"})
+
+	// MQPublishedTotal 消息投递数,result 为 success 或 error
+	MQPublishedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mq_published_total",
+		Help:      "Messages published to RabbitMQ.",
+	}, []string{"queue", "result"})
+
+	MQConsumedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mq_consumed_total",
+		Help:      "Messages delivered to consumers.",
+	}, []string{"queue"})
+
+	// TransferBytesTotal 经过服务器的传输字节数,direction 为 upload 或 download。
+	// 预签名 URL 下载直接访问存储,不计入
+	TransferBytesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfer_bytes_total",
+		Help:      "Bytes uploaded to or downloaded from the server.",
+	}, []string{"direction"})
+
+	FolderZipDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "folder_zip_duration_seconds",
+		Help:      "Time to stream a folder as a ZIP archive.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 14),
+	})
+
+	// IntegrityChecksTotal 完整性校验的文件数,result 为 ok、corrupted 或 error
+	IntegrityChecksTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "integrity_checks_total",
+		Help:      "Files re-hashed by the integrity scanner, by result.",
+	}, []string{"result"})
+
+	// StreamDeadLettersTotal 多次处理失败后转入死信流的 Redis Stream 消息数
+	StreamDeadLettersTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stream_dead_letters_total",
+		Help:      "Redis stream messages moved to the dead-letter stream after repeated failures, by source stream.",
+	}, []string{"stream"})
+
+	// MultipartCompleteDuration 合并分片并计算哈希的耗时,存储后端较慢时会明显升高
+	MultipartCompleteDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "multipart_complete_duration_seconds",
+		Help:      "Time to complete a multipart upload, including server-side hashing.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 14),
+	})
+)
+
+// 传输方向
+const (
+	DirectionUpload   = "upload"
+	DirectionDownload = "download"
+)
+
+// ObserveCache 记录一次缓存查询结果
+func ObserveCache(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	CacheRequestsTotal.WithLabelValues(cache, result).Inc()
+}
+
+// ObserveStorage 记录一次存储调用,用法: defer metrics.ObserveStorage("get_object", time.Now(), &err)
+func ObserveStorage(operation string, start time.Time, err *error) {
+	result := "success"
+	if err != nil && *err != nil {
+		result = "error"
+	}
+	StorageOperationDuration.WithLabelValues(operation, result).Observe(time.Since(start).Seconds())
+}
+
+// AddTransferBytes 累加传输字节数
+func AddTransferBytes(direction string, n int64) {
+	if n > 0 {
+		TransferBytesTotal.WithLabelValues(direction).Add(float64(n))
+	}
+}
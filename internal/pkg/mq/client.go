@@ -1,16 +1,27 @@
 package mq
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"sync"
 
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/metrics"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/tracing"
+	"github.com/google/uuid"
 	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // RabbitMQClient 封装了 RabbitMQ 的连接和通道
 type RabbitMQClient struct {
 	conn    *amqp.Connection
 	channel *amqp.Channel
+
+	mu        sync.Mutex
+	consumers []string       // 已注册的消费者标签，关机时用于取消订阅
+	inflight  sync.WaitGroup // 正在运行的消费协程
 }
 
 // NewRabbitMQClient 创建一个新的 RabbitMQ 客户端实例
@@ -45,38 +56,67 @@ func (c *RabbitMQClient) DeclareQueue(queueName string) (amqp.Queue, error) {
 }
 
 // Publish a message to a specific queue
-func (c *RabbitMQClient) Publish(queueName string, body []byte) error {
-	return c.channel.Publish(
+// ctx 中的链路上下文写入消息头,消费者据此把处理过程关联到发起请求
+func (c *RabbitMQClient) Publish(ctx context.Context, queueName string, body []byte) error {
+	ctx, span := tracing.Start(ctx, queueName+" publish", trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(messagingAttributes(queueName)...))
+	headers := amqp.Table{}
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier(headers))
+
+	err := c.channel.Publish(
 		"",        // exchange (default)
 		queueName, // routing key (queue name)
 		false,     // mandatory
 		false,     // immediate
 		amqp.Publishing{
 			ContentType:  "application/json",
+			Headers:      headers,
 			Body:         body,
 			DeliveryMode: amqp.Persistent, // make message persistent
 		},
 	)
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	metrics.MQPublishedTotal.WithLabelValues(queueName, result).Inc()
+	tracing.End(span, err)
+	return err
 }
 
 // Consume messages from a specific queue
-func (c *RabbitMQClient) Consume(queueName string, handler func(msg amqp.Delivery)) error {
+// handler 收到的 ctx 携带从消息头恢复的链路上下文
+func (c *RabbitMQClient) Consume(queueName string, handler func(ctx context.Context, msg amqp.Delivery)) error {
+	consumerTag := fmt.Sprintf("%s-%s", queueName, uuid.NewString())
 	msgs, err := c.channel.Consume(
 		queueName,
-		"",    // consumer
-		false, // auto-ack (we will manually ack)
-		false, // exclusive
-		false, // no-local
-		false, // no-wait
-		nil,   // args
+		consumerTag, // consumer
+		false,       // auto-ack (we will manually ack)
+		false,       // exclusive
+		false,       // no-local
+		false,       // no-wait
+		nil,         // args
 	)
 	if err != nil {
 		return fmt.Errorf("failed to register a consumer: %w", err)
 	}
 
+	c.mu.Lock()
+	c.consumers = append(c.consumers, consumerTag)
+	c.mu.Unlock()
+
+	// 取消订阅后 msgs 会在已投递的消息处理完后关闭
+	c.inflight.Add(1)
 	go func() {
+		defer c.inflight.Done()
+		consumed := metrics.MQConsumedTotal.WithLabelValues(queueName)
 		for msg := range msgs {
-			handler(msg)
+			consumed.Inc()
+			ctx := otel.GetTextMapPropagator().Extract(context.Background(), headerCarrier(msg.Headers))
+			ctx, span := tracing.Start(ctx, queueName+" process", trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(messagingAttributes(queueName)...))
+			handler(ctx, msg)
+			span.End()
 		}
 	}()
 
@@ -84,6 +124,34 @@ func (c *RabbitMQClient) Consume(queueName string, handler func(msg amqp.Deliver
 	return nil
 }
 
+// StopConsuming 取消全部消费者的订阅，并等待已投递的消息处理完成。
+// ctx 超时后直接返回，未确认的消息会在连接关闭后由 RabbitMQ 重新投递。
+func (c *RabbitMQClient) StopConsuming(ctx context.Context) error {
+	c.mu.Lock()
+	consumers := c.consumers
+	c.consumers = nil
+	c.mu.Unlock()
+
+	for _, consumerTag := range consumers {
+		if err := c.channel.Cancel(consumerTag, false); err != nil {
+			log.Printf("Failed to cancel consumer '%s': %v", consumerTag, err)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for consumers to drain: %w", ctx.Err())
+	}
+}
+
 // Close the channel and connection
 func (c *RabbitMQClient) Close() {
 	if c.channel != nil {
@@ -0,0 +1,42 @@
+package mq
+
+import (
+	"fmt"
+
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// headerCarrier 让 otel 传播器读写 AMQP 消息头,把发布消息时的链路上下文带给消费者
+type headerCarrier amqp.Table
+
+func (c headerCarrier) Get(key string) string {
+	value, ok := c[key]
+	if !ok {
+		return ""
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+func (c headerCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// messagingAttributes 发布和消费 span 共用的属性
+func messagingAttributes(queueName string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("messaging.system", "rabbitmq"),
+		attribute.String("messaging.destination.name", queueName),
+	}
+}
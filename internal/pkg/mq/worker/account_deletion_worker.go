@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mq"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+)
+
+// AccountDeletionWorker 消费账号注销任务,依次撤销访问、删除文件并匿名化账号
+type AccountDeletionWorker struct {
+	mqClient        *mq.RabbitMQClient
+	deletionService explorer.AccountDeletionService
+}
+
+func NewAccountDeletionWorker(mqClient *mq.RabbitMQClient, deletionService explorer.AccountDeletionService) *AccountDeletionWorker {
+	return &AccountDeletionWorker{
+		mqClient:        mqClient,
+		deletionService: deletionService,
+	}
+}
+
+func (w *AccountDeletionWorker) Start() {
+	_, err := w.mqClient.DeclareQueue(explorer.AccountDeletionQueueName)
+	if err != nil {
+		log.Fatalf("Failed to declare queue: %s", err)
+	}
+	err = w.mqClient.Consume(explorer.AccountDeletionQueueName, w.ProcessDeletion)
+	if err != nil {
+		log.Fatalf("Failed to start consuming from queue: %s", err)
+	}
+
+	log.Println("Account deletion worker started...")
+}
+
+func (w *AccountDeletionWorker) ProcessDeletion(ctx context.Context, msg amqp.Delivery) {
+	var task models.AccountDeletionTask
+	if err := json.Unmarshal(msg.Body, &task); err != nil {
+		logger.Error("Failed to unmarshal account deletion task", zap.Error(err))
+		_ = msg.Nack(false, false) // 解析失败,直接抛弃
+		return
+	}
+
+	// 失败时任务已标记为失败并保留失败的步骤,由管理员重试,消息不再重试
+	if err := w.deletionService.ProcessDeletion(ctx, task.DeletionID); err != nil {
+		logger.Error("ProcessDeletion: Failed to process account deletion", zap.Uint64("deletionID", task.DeletionID), zap.Error(err))
+	}
+	_ = msg.Ack(false)
+}
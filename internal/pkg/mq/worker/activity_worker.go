@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mq"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/activity"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+)
+
+// ActivityWorker 消费活动日志消息并写入数据库,同时维护用户的最近访问列表
+type ActivityWorker struct {
+	mqClient        *mq.RabbitMQClient
+	activityRepo    repositories.ActivityRepository
+	favoriteService explorer.FavoriteService
+}
+
+func NewActivityWorker(mqClient *mq.RabbitMQClient, activityRepo repositories.ActivityRepository, favoriteService explorer.FavoriteService) *ActivityWorker {
+	return &ActivityWorker{
+		mqClient:        mqClient,
+		activityRepo:    activityRepo,
+		favoriteService: favoriteService,
+	}
+}
+
+func (w *ActivityWorker) Start() {
+	_, err := w.mqClient.DeclareQueue(activity.QueueName)
+	if err != nil {
+		log.Fatalf("Failed to declare queue: %s", err)
+	}
+	err = w.mqClient.Consume(activity.QueueName, w.SaveActivity)
+	if err != nil {
+		log.Fatalf("Failed to start consuming from queue: %s", err)
+	}
+
+	log.Println("Activity worker started...")
+}
+
+func (w *ActivityWorker) SaveActivity(ctx context.Context, msg amqp.Delivery) {
+	var record models.Activity
+	if err := json.Unmarshal(msg.Body, &record); err != nil {
+		logger.Error("Failed to unmarshal activity", zap.Error(err))
+		_ = msg.Nack(false, false) // 解析失败,直接抛弃
+		return
+	}
+
+	if err := w.activityRepo.Create(ctx, &record); err != nil {
+		logger.Error("Failed to save activity", zap.String("action", record.Action), zap.Error(err))
+		_ = msg.Nack(false, true) // 数据库错误，重新入队
+		return
+	}
+	w.favoriteService.TrackActivity(ctx, &record)
+
+	_ = msg.Ack(false)
+}
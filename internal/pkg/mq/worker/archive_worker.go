@@ -0,0 +1,116 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mq"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultArchiveCleanupInterval  = 30 // 分钟
+	defaultArchiveCleanupBatchSize = 100
+)
+
+// ArchiveWorker 消费文件夹打包任务,逐个打包上传
+type ArchiveWorker struct {
+	mqClient       *mq.RabbitMQClient
+	archiveService explorer.ArchiveService
+}
+
+func NewArchiveWorker(mqClient *mq.RabbitMQClient, archiveService explorer.ArchiveService) *ArchiveWorker {
+	return &ArchiveWorker{
+		mqClient:       mqClient,
+		archiveService: archiveService,
+	}
+}
+
+func (w *ArchiveWorker) Start() {
+	_, err := w.mqClient.DeclareQueue(explorer.ArchiveQueueName)
+	if err != nil {
+		log.Fatalf("Failed to declare queue: %s", err)
+	}
+	err = w.mqClient.Consume(explorer.ArchiveQueueName, w.ProcessArchive)
+	if err != nil {
+		log.Fatalf("Failed to start consuming from queue: %s", err)
+	}
+
+	log.Println("Archive worker started...")
+}
+
+func (w *ArchiveWorker) ProcessArchive(ctx context.Context, msg amqp.Delivery) {
+	var task models.ArchiveTask
+	if err := json.Unmarshal(msg.Body, &task); err != nil {
+		logger.Error("Failed to unmarshal archive task", zap.Error(err))
+		_ = msg.Nack(false, false) // 解析失败,直接抛弃
+		return
+	}
+
+	// 打包失败时任务已标记为失败,用户可以重新发起,消息不再重试
+	if err := w.archiveService.ProcessArchive(ctx, task.JobID); err != nil {
+		logger.Error("ProcessArchive: Failed to process archive", zap.Uint64("jobID", task.JobID), zap.Error(err))
+	}
+	_ = msg.Ack(false)
+}
+
+// ArchiveCleanupWorker 定期删除过期的文件夹打包 ZIP
+type ArchiveCleanupWorker struct {
+	archiveService explorer.ArchiveService
+	cfg            config.ArchiveConfig
+}
+
+func NewArchiveCleanupWorker(archiveService explorer.ArchiveService, cfg config.ArchiveConfig) *ArchiveCleanupWorker {
+	return &ArchiveCleanupWorker{
+		archiveService: archiveService,
+		cfg:            cfg,
+	}
+}
+
+// Run 启动时立即执行一次清理,之后按配置的间隔执行,ctx 取消后退出
+func (w *ArchiveCleanupWorker) Run(ctx context.Context) {
+	interval := w.cfg.CleanupInterval
+	if interval <= 0 {
+		interval = defaultArchiveCleanupInterval
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Minute)
+	defer ticker.Stop()
+
+	logger.Info("Archive cleanup worker started", zap.Int("intervalMinutes", interval))
+	for {
+		w.expire(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expire 分批清理过期的 ZIP,处理失败的留到下一轮重试
+func (w *ArchiveCleanupWorker) expire(ctx context.Context) {
+	now := time.Now()
+	total := 0
+	for ctx.Err() == nil {
+		expired, err := w.archiveService.ExpireArchives(ctx, now, defaultArchiveCleanupBatchSize)
+		total += expired
+		if err != nil {
+			logger.Error("ArchiveCleanup: Failed to expire archives", zap.Error(err))
+			break
+		}
+		if expired < defaultArchiveCleanupBatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		logger.Info("ArchiveCleanup: Expired archives removed", zap.Int("count", total))
+	}
+}
@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mq"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+)
+
+// ContentIndexWorker 消费文档内容提取任务,把提取的文本写入搜索索引
+type ContentIndexWorker struct {
+	mqClient      *mq.RabbitMQClient
+	searchService explorer.SearchService
+}
+
+func NewContentIndexWorker(mqClient *mq.RabbitMQClient, searchService explorer.SearchService) *ContentIndexWorker {
+	return &ContentIndexWorker{
+		mqClient:      mqClient,
+		searchService: searchService,
+	}
+}
+
+func (w *ContentIndexWorker) Start() {
+	_, err := w.mqClient.DeclareQueue(explorer.ContentIndexQueueName)
+	if err != nil {
+		log.Fatalf("Failed to declare queue: %s", err)
+	}
+	err = w.mqClient.Consume(explorer.ContentIndexQueueName, w.IndexContent)
+	if err != nil {
+		log.Fatalf("Failed to start consuming from queue: %s", err)
+	}
+
+	log.Println("Content index worker started...")
+}
+
+func (w *ContentIndexWorker) IndexContent(ctx context.Context, msg amqp.Delivery) {
+	var task models.ContentIndexTask
+	if err := json.Unmarshal(msg.Body, &task); err != nil {
+		logger.Error("Failed to unmarshal content index task", zap.Error(err))
+		_ = msg.Nack(false, false) // 解析失败,直接抛弃
+		return
+	}
+
+	// 提取失败的文件仍可以按文件名搜索,消息不再重试
+	if err := w.searchService.IndexContent(ctx, task); err != nil {
+		logger.Error("IndexContent: Failed to index file content", zap.Uint64("fileID", task.FileID), zap.String("kind", task.Kind), zap.Error(err))
+	}
+	_ = msg.Ack(false)
+}
@@ -20,12 +20,16 @@ import (
 	"gorm.io/gorm"
 )
 
-const DeleteQueueName = "file_delete_queue"
+const (
+	DeleteQueueName                = "file_delete_queue"
+	DeleteSpecificVersionQueueName = "delete_specific_version_queue"
+)
 
 type DeleteWorker struct {
 	mqClient        *mq.RabbitMQClient
 	fileRepo        repositories.FileRepository
 	fileVersionRepo repositories.FileVersionRepository
+	objectRepo      repositories.StorageObjectRepository
 	tm              explorer.TransactionManager
 	storageService  storage.StorageService
 	cfg             *config.Config
@@ -35,6 +39,7 @@ func NewDeleteWorker(
 	mqClient *mq.RabbitMQClient,
 	fileRepo repositories.FileRepository,
 	fileVersionRepo repositories.FileVersionRepository,
+	objectRepo repositories.StorageObjectRepository,
 	tm explorer.TransactionManager,
 	storageService storage.StorageService,
 	cfg *config.Config,
@@ -43,6 +48,7 @@ func NewDeleteWorker(
 		mqClient:        mqClient,
 		fileRepo:        fileRepo,
 		fileVersionRepo: fileVersionRepo,
+		objectRepo:      objectRepo,
 		tm:              tm,
 		storageService:  storageService,
 		cfg:             cfg,
@@ -51,11 +57,11 @@ func NewDeleteWorker(
 
 func (w *DeleteWorker) Start() {
 	// 删除指定版本消费者
-	_, err := w.mqClient.DeclareQueue("delete_specific_version_queue")
+	_, err := w.mqClient.DeclareQueue(DeleteSpecificVersionQueueName)
 	if err != nil {
 		log.Fatalf("Failed to declare queue: %s", err)
 	}
-	err = w.mqClient.Consume("delete_specific_version_queue", w.DeleteSpecificVersion)
+	err = w.mqClient.Consume(DeleteSpecificVersionQueueName, w.DeleteSpecificVersion)
 	if err != nil {
 		log.Fatalf("Failed to start consuming from queue: %s", err)
 	}
@@ -73,7 +79,7 @@ func (w *DeleteWorker) Start() {
 	log.Println("Delete worker started...")
 }
 
-func (w *DeleteWorker) DeleteSpecificVersion(msg amqp.Delivery) {
+func (w *DeleteWorker) DeleteSpecificVersion(ctx context.Context, msg amqp.Delivery) {
 	var task models.DeleteFileTask
 	if err := json.Unmarshal(msg.Body, &task); err != nil {
 		logger.Error("Failed to unmarshal delete task", zap.Error(err))
@@ -83,11 +89,11 @@ func (w *DeleteWorker) DeleteSpecificVersion(msg amqp.Delivery) {
 
 	logger.Info("Received file deletion task", zap.Uint64("FileID", task.FileID))
 
-	ctx := context.Background()
+	var released []models.StorageObject
 	err := w.tm.WithTransaction(ctx, func(tx *gorm.DB) error {
-		// 1. 先删除数据库记录（先子后父）
-		if err := tx.WithContext(ctx).Unscoped().Where("file_id = ? AND version_id = ?", task.FileID, task.VersionID).
-			Delete(&models.FileVersion{}).Error; err != nil {
+		// 1. 先删除数据库记录（先子后父）,同时释放对象引用
+		var err error
+		if released, err = repositories.NewFileVersionRepository(tx).PurgeVersion(ctx, task.FileID, task.VersionID); err != nil {
 			return fmt.Errorf("failed to delete version: %w", err)
 		}
 
@@ -101,7 +107,7 @@ func (w *DeleteWorker) DeleteSpecificVersion(msg amqp.Delivery) {
 
 		// 3. 如果是最后一个版本，删除主记录
 		if remainingVersions == 0 {
-			if err := w.fileRepo.PermanentDelete(tx, task.FileID); err != nil {
+			if err := w.fileRepo.PermanentDelete(ctx, tx, task.FileID); err != nil {
 				return fmt.Errorf("failed to delete file: %w", err)
 			}
 			logger.Info("Last version deleted, removing main file record", zap.Uint64("FileID", task.FileID))
@@ -114,11 +120,13 @@ func (w *DeleteWorker) DeleteSpecificVersion(msg amqp.Delivery) {
 		return
 	}
 
-	// 删除物理文件
-	bucketName := w.cfg.MinIO.BucketName
-	err = w.storageService.RemoveObject(ctx, bucketName, task.OssKey, task.VersionID)
-	if err != nil {
-		logger.Error("Failed to delete file from storage", zap.String("OssKey", task.OssKey), zap.Error(err))
+	// 对象仍被其他文件引用(秒传或转存)时保留物理文件。重新投递的消息不会再释放引用,
+	// 同时尝试认领任务中的对象,上次删除失败后恢复的计数记录可以再次认领
+	if len(released) == 0 {
+		released = []models.StorageObject{{Bucket: task.Bucket, OssKey: task.OssKey, VersionID: task.VersionID}}
+	}
+	if err := explorer.RemoveReleasedObjects(ctx, w.objectRepo, w.storageService, w.cfg.DefaultBucketName(), released); err != nil {
+		logger.Error("Failed to delete file from storage", zap.String("Bucket", task.Bucket), zap.String("OssKey", task.OssKey), zap.Error(err))
 		_ = msg.Nack(false, true) // 重新入队
 		return
 	}
@@ -127,7 +135,7 @@ func (w *DeleteWorker) DeleteSpecificVersion(msg amqp.Delivery) {
 	_ = msg.Ack(false) // 确认消息
 }
 
-func (w *DeleteWorker) DeleteAllVersions(msg amqp.Delivery) {
+func (w *DeleteWorker) DeleteAllVersions(ctx context.Context, msg amqp.Delivery) {
 	var task models.DeleteFileTask
 	if err := json.Unmarshal(msg.Body, &task); err != nil {
 		logger.Error("Failed to unmarshal delete task", zap.Error(err))
@@ -138,16 +146,16 @@ func (w *DeleteWorker) DeleteAllVersions(msg amqp.Delivery) {
 	logger.Info("Received file deletion task", zap.Uint64("FileID", task.FileID))
 
 	// 在事务中处理数据库删除
-	ctx := context.Background()
+	var released []models.StorageObject
 	err := w.tm.WithTransaction(ctx, func(tx *gorm.DB) error {
-		// 1. 先删除所有版本记录（子表）
-		if err := tx.WithContext(ctx).Unscoped().Where("file_id = ?", task.FileID).
-			Delete(&models.FileVersion{}).Error; err != nil {
+		// 1. 先删除所有版本记录（子表）,同时释放对象引用
+		var err error
+		if released, err = repositories.NewFileVersionRepository(tx).PurgeByFileIDs(ctx, []uint64{task.FileID}); err != nil {
 			return fmt.Errorf("failed to delete versions: %w", err)
 		}
 
 		// 2. 再删除主文件记录（父表）
-		if err := w.fileRepo.PermanentDelete(tx, task.FileID); err != nil {
+		if err := w.fileRepo.PermanentDelete(ctx, tx, task.FileID); err != nil {
 			return fmt.Errorf("failed to delete file: %w", err)
 		}
 
@@ -168,11 +176,10 @@ func (w *DeleteWorker) DeleteAllVersions(msg amqp.Delivery) {
 		return
 	}
 
-	// 数据库操作成功后，删除物理文件
-	bucketName := w.cfg.MinIO.BucketName
-	if err := w.storageService.RemoveObjects(ctx, bucketName, task.OssKey); err != nil {
+	// 数据库操作成功后，按对象所在的存储桶删除引用数降为 0 的物理文件，仍被其他文件引用(秒传或转存)的对象保留
+	if err := explorer.RemoveReleasedObjects(ctx, w.objectRepo, w.storageService, w.cfg.DefaultBucketName(), released); err != nil {
 		// 物理文件删除失败只记录不阻塞流程（因为数据库已更新）
-		logger.Error("Failed to delete physical files (need manual cleanup)",
+		logger.Error("Failed to delete physical files",
 			zap.String("OssKey", task.OssKey),
 			zap.Uint64("FileID", task.FileID),
 			zap.Error(err))
@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"go.uber.org/zap"
+)
+
+const defaultDownloadCountFlushInterval = 60 // 秒
+
+// DownloadCountWorker 定期把 Redis 中累加的下载次数写入数据库
+type DownloadCountWorker struct {
+	downloads explorer.DownloadCountService
+	cfg       config.DownloadConfig
+}
+
+func NewDownloadCountWorker(downloads explorer.DownloadCountService, cfg config.DownloadConfig) *DownloadCountWorker {
+	return &DownloadCountWorker{
+		downloads: downloads,
+		cfg:       cfg,
+	}
+}
+
+// Run 按配置的间隔写入下载次数,ctx 取消后再写入一次,避免关机时丢失最后一个周期的计数
+func (w *DownloadCountWorker) Run(ctx context.Context) {
+	interval := w.cfg.CountFlushInterval
+	if interval <= 0 {
+		interval = defaultDownloadCountFlushInterval
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	logger.Info("Download count worker started", zap.Int("intervalSeconds", interval))
+	for {
+		select {
+		case <-ctx.Done():
+			w.flush(context.WithoutCancel(ctx))
+			return
+		case <-ticker.C:
+			w.flush(ctx)
+		}
+	}
+}
+
+func (w *DownloadCountWorker) flush(ctx context.Context) {
+	flushed, err := w.downloads.Flush(ctx)
+	if err != nil {
+		logger.Error("DownloadCount: Failed to flush download counts", zap.Error(err))
+		return
+	}
+	if flushed > 0 {
+		logger.Debug("DownloadCount: Download counts flushed", zap.Int("count", flushed))
+	}
+}
@@ -0,0 +1,116 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mq"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultExportCleanupInterval  = 60 // 分钟
+	defaultExportCleanupBatchSize = 100
+)
+
+// ExportWorker 消费账户数据导出任务,逐个打包上传,同一时间只处理一个导出
+type ExportWorker struct {
+	mqClient      *mq.RabbitMQClient
+	exportService explorer.ExportService
+}
+
+func NewExportWorker(mqClient *mq.RabbitMQClient, exportService explorer.ExportService) *ExportWorker {
+	return &ExportWorker{
+		mqClient:      mqClient,
+		exportService: exportService,
+	}
+}
+
+func (w *ExportWorker) Start() {
+	_, err := w.mqClient.DeclareQueue(explorer.ExportQueueName)
+	if err != nil {
+		log.Fatalf("Failed to declare queue: %s", err)
+	}
+	err = w.mqClient.Consume(explorer.ExportQueueName, w.ProcessExport)
+	if err != nil {
+		log.Fatalf("Failed to start consuming from queue: %s", err)
+	}
+
+	log.Println("Export worker started...")
+}
+
+func (w *ExportWorker) ProcessExport(ctx context.Context, msg amqp.Delivery) {
+	var task models.ExportTask
+	if err := json.Unmarshal(msg.Body, &task); err != nil {
+		logger.Error("Failed to unmarshal export task", zap.Error(err))
+		_ = msg.Nack(false, false) // 解析失败,直接抛弃
+		return
+	}
+
+	// 打包失败时导出任务已标记为失败,用户可以重新发起,消息不再重试
+	if err := w.exportService.ProcessExport(ctx, task.ExportID); err != nil {
+		logger.Error("ProcessExport: Failed to process export", zap.Uint64("exportID", task.ExportID), zap.Error(err))
+	}
+	_ = msg.Ack(false)
+}
+
+// ExportCleanupWorker 定期删除过期导出暂存在存储桶中的 ZIP 分卷
+type ExportCleanupWorker struct {
+	exportService explorer.ExportService
+	cfg           config.ExportConfig
+}
+
+func NewExportCleanupWorker(exportService explorer.ExportService, cfg config.ExportConfig) *ExportCleanupWorker {
+	return &ExportCleanupWorker{
+		exportService: exportService,
+		cfg:           cfg,
+	}
+}
+
+// Run 启动时立即执行一次清理,之后按配置的间隔执行,ctx 取消后退出
+func (w *ExportCleanupWorker) Run(ctx context.Context) {
+	interval := w.cfg.CleanupInterval
+	if interval <= 0 {
+		interval = defaultExportCleanupInterval
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Minute)
+	defer ticker.Stop()
+
+	logger.Info("Export cleanup worker started", zap.Int("intervalMinutes", interval))
+	for {
+		w.expire(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expire 分批清理过期的导出,处理失败的留到下一轮重试
+func (w *ExportCleanupWorker) expire(ctx context.Context) {
+	now := time.Now()
+	total := 0
+	for ctx.Err() == nil {
+		expired, err := w.exportService.ExpireExports(ctx, now, defaultExportCleanupBatchSize)
+		total += expired
+		if err != nil {
+			logger.Error("ExportCleanup: Failed to expire exports", zap.Error(err))
+			break
+		}
+		if expired < defaultExportCleanupBatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		logger.Info("ExportCleanup: Expired exports removed", zap.Int("count", total))
+	}
+}
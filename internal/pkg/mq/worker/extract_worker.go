@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mq"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+)
+
+// ExtractWorker 消费压缩包解压任务
+type ExtractWorker struct {
+	mqClient       *mq.RabbitMQClient
+	extractService explorer.ExtractService
+}
+
+func NewExtractWorker(mqClient *mq.RabbitMQClient, extractService explorer.ExtractService) *ExtractWorker {
+	return &ExtractWorker{
+		mqClient:       mqClient,
+		extractService: extractService,
+	}
+}
+
+func (w *ExtractWorker) Start() {
+	_, err := w.mqClient.DeclareQueue(explorer.ExtractQueueName)
+	if err != nil {
+		log.Fatalf("Failed to declare queue: %s", err)
+	}
+	err = w.mqClient.Consume(explorer.ExtractQueueName, w.ProcessExtract)
+	if err != nil {
+		log.Fatalf("Failed to start consuming from queue: %s", err)
+	}
+
+	log.Println("Extract worker started...")
+}
+
+func (w *ExtractWorker) ProcessExtract(ctx context.Context, msg amqp.Delivery) {
+	var task models.ExtractTask
+	if err := json.Unmarshal(msg.Body, &task); err != nil {
+		logger.Error("Failed to unmarshal extract task", zap.Error(err))
+		_ = msg.Nack(false, false) // 解析失败,直接抛弃
+		return
+	}
+
+	// 解压失败时任务已标记为失败,已解压的内容保留,消息不再重试
+	if err := w.extractService.ProcessExtract(ctx, task.JobID); err != nil {
+		logger.Error("ProcessExtract: Failed to process extract", zap.Uint64("jobID", task.JobID), zap.Error(err))
+	}
+	_ = msg.Ack(false)
+}
@@ -0,0 +1,161 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mail"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/metrics"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/activity"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultIntegrityInterval  = 60 // 分钟
+	defaultIntegrityBatchSize = 50
+	// integrityAlertTimeout 发送告警邮件的超时时间
+	integrityAlertTimeout = 30 * time.Second
+)
+
+// IntegrityWorker 定期抽取文件,从存储流式读取内容重新计算哈希,与数据库中的记录比对以发现静默损坏(bit rot)。
+// 校验不一致或对象已丢失的文件标记为 corrupted,并记录活动日志、通知管理员。修复后由管理员通过重新校验接口恢复
+type IntegrityWorker struct {
+	fileRepo         repositories.FileRepository
+	integrityService explorer.IntegrityService
+	activityService  activity.ActivityService
+	mailer           mail.Sender
+	cfg              *config.Config
+}
+
+func NewIntegrityWorker(
+	fileRepo repositories.FileRepository,
+	integrityService explorer.IntegrityService,
+	activityService activity.ActivityService,
+	mailer mail.Sender,
+	cfg *config.Config,
+) *IntegrityWorker {
+	return &IntegrityWorker{
+		fileRepo:         fileRepo,
+		integrityService: integrityService,
+		activityService:  activityService,
+		mailer:           mailer,
+		cfg:              cfg,
+	}
+}
+
+// Run 启动时立即执行一轮校验,之后按配置的间隔执行,ctx 取消后退出
+func (w *IntegrityWorker) Run(ctx context.Context) {
+	if !w.cfg.Integrity.Enabled {
+		logger.Info("Integrity worker disabled")
+		return
+	}
+
+	interval := w.cfg.Integrity.Interval
+	if interval <= 0 {
+		interval = defaultIntegrityInterval
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Minute)
+	defer ticker.Stop()
+
+	logger.Info("Integrity worker started", zap.Int("intervalMinutes", interval))
+	for {
+		w.checkBatch(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkBatch 校验一批最久未校验的文件
+func (w *IntegrityWorker) checkBatch(ctx context.Context) {
+	batchSize := w.cfg.Integrity.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultIntegrityBatchSize
+	}
+	files, err := w.fileRepo.FindForIntegrityCheck(ctx, batchSize)
+	if err != nil {
+		logger.Error("IntegrityCheck: Failed to find files", zap.Error(err))
+		return
+	}
+
+	checked := make([]uint64, 0, len(files))
+	var corrupted []models.File
+	for _, file := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		mismatch, err := w.integrityService.Verify(ctx, &file)
+		if err != nil {
+			// 读取失败可能是存储暂时不可用,不记录校验时间,下一轮重试
+			metrics.IntegrityChecksTotal.WithLabelValues("error").Inc()
+			logger.Error("IntegrityCheck: Failed to verify file", zap.Uint64("fileID", file.ID), zap.Error(err))
+			continue
+		}
+		checked = append(checked, file.ID)
+		if mismatch == "" {
+			metrics.IntegrityChecksTotal.WithLabelValues("ok").Inc()
+			continue
+		}
+
+		metrics.IntegrityChecksTotal.WithLabelValues("corrupted").Inc()
+		if w.markCorrupted(ctx, &file, mismatch) {
+			corrupted = append(corrupted, file)
+		}
+	}
+
+	if err := w.fileRepo.MarkIntegrityChecked(ctx, checked, time.Now()); err != nil {
+		logger.Error("IntegrityCheck: Failed to record check time", zap.Int("count", len(checked)), zap.Error(err))
+	}
+	if len(corrupted) > 0 {
+		w.alert(corrupted)
+	}
+	logger.Info("IntegrityCheck: Batch finished", zap.Int("checked", len(checked)), zap.Int("corrupted", len(corrupted)))
+}
+
+// markCorrupted 将文件标记为损坏并记录活动日志,返回是否标记成功
+func (w *IntegrityWorker) markCorrupted(ctx context.Context, file *models.File, mismatch string) bool {
+	logger.Error("IntegrityCheck: Stored content does not match recorded hash",
+		zap.Uint64("fileID", file.ID),
+		zap.Uint64("userID", file.UserID),
+		zap.String("ossKey", *file.OssKey),
+		zap.String("mismatch", mismatch))
+
+	if err := w.fileRepo.UpdateFileStatus(ctx, file.ID, models.StatusCorrupted); err != nil {
+		logger.Error("IntegrityCheck: Failed to mark file as corrupted", zap.Uint64("fileID", file.ID), zap.Error(err))
+		return false
+	}
+	w.activityService.Record(ctx, file.UserID, file.ID, models.ActivityCorrupted, file.FileName+": "+mismatch)
+	return true
+}
+
+// alert 向配置的管理员邮箱发送损坏文件列表
+func (w *IntegrityWorker) alert(files []models.File) {
+	if len(w.cfg.Integrity.AlertEmails) == 0 {
+		return
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "完整性校验发现 %d 个文件的存储内容与记录的哈希不一致，已标记为损坏：\n\n", len(files))
+	for _, file := range files {
+		fmt.Fprintf(&body, "- 文件ID %d，用户ID %d，%s%s，对象 %s\n", file.ID, file.UserID, file.Path, file.FileName, *file.OssKey)
+	}
+	body.WriteString("\n请检查存储后端并从备份恢复这些对象。\n")
+
+	ctx, cancel := context.WithTimeout(context.Background(), integrityAlertTimeout)
+	defer cancel()
+	subject := fmt.Sprintf("[go-clouddisk] 发现 %d 个损坏的文件", len(files))
+	for _, to := range w.cfg.Integrity.AlertEmails {
+		if err := w.mailer.Send(ctx, to, subject, body.String()); err != nil {
+			logger.Error("IntegrityCheck: Failed to send alert", zap.String("to", to), zap.Error(err))
+		}
+	}
+}
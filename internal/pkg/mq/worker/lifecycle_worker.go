@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"go.uber.org/zap"
+)
+
+const defaultLifecycleInterval = 60 // 分钟
+
+// LifecycleWorker 定期执行所有启用的文件夹生命周期规则
+type LifecycleWorker struct {
+	lifecycleService explorer.LifecycleService
+	cfg              config.LifecycleConfig
+}
+
+func NewLifecycleWorker(lifecycleService explorer.LifecycleService, cfg config.LifecycleConfig) *LifecycleWorker {
+	return &LifecycleWorker{
+		lifecycleService: lifecycleService,
+		cfg:              cfg,
+	}
+}
+
+// Run 启动时立即执行一次,之后按配置的间隔执行,ctx 取消后退出
+func (w *LifecycleWorker) Run(ctx context.Context) {
+	if !w.cfg.Enabled {
+		logger.Info("Lifecycle worker disabled")
+		return
+	}
+
+	interval := w.cfg.Interval
+	if interval <= 0 {
+		interval = defaultLifecycleInterval
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Minute)
+	defer ticker.Stop()
+
+	logger.Info("Lifecycle worker started", zap.Int("intervalMinutes", interval))
+	for {
+		w.lifecycleService.RunAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -15,13 +15,73 @@ func StartAllWorkers(
 	mqClient *mq.RabbitMQClient,
 	fileRepo repositories.FileRepository,
 	fileVersionRepo repositories.FileVersionRepository,
+	objectRepo repositories.StorageObjectRepository,
+	activityRepo repositories.ActivityRepository,
 	tm explorer.TransactionManager,
 	storageService storage.StorageService,
+	favoriteService explorer.FavoriteService,
+	exportService explorer.ExportService,
+	archiveService explorer.ArchiveService,
+	extractService explorer.ExtractService,
+	shareAccessRepo repositories.ShareAccessLogRepository,
+	purgeService explorer.PurgeService,
+	migrationService explorer.StorageMigrationService,
+	galleryService explorer.GalleryService,
+	searchService explorer.SearchService,
+	accountDeletionService explorer.AccountDeletionService,
 ) {
 	// --- 启动文件删除 Worker ---
-	deleteWorker := NewDeleteWorker(mqClient, fileRepo, fileVersionRepo, tm, storageService, cfg)
+	deleteWorker := NewDeleteWorker(mqClient, fileRepo, fileVersionRepo, objectRepo, tm, storageService, cfg)
 	go deleteWorker.Start()
 
+	// --- 启动活动日志 Worker ---
+	activityWorker := NewActivityWorker(mqClient, activityRepo, favoriteService)
+	go activityWorker.Start()
+
+	// --- 启动病毒扫描 Worker ---
+	if cfg.Scan.Enabled {
+		scanWorker := NewScanWorker(mqClient, fileRepo, activityRepo, storageService, cfg.Scan)
+		go scanWorker.Start()
+	}
+
+	// --- 启动账户数据导出 Worker ---
+	exportWorker := NewExportWorker(mqClient, exportService)
+	go exportWorker.Start()
+
+	// --- 启动文件夹打包 Worker ---
+	archiveWorker := NewArchiveWorker(mqClient, archiveService)
+	go archiveWorker.Start()
+
+	// --- 启动压缩包解压 Worker ---
+	extractWorker := NewExtractWorker(mqClient, extractService)
+	go extractWorker.Start()
+
+	// --- 启动分享访问日志 Worker ---
+	shareAccessWorker := NewShareAccessWorker(mqClient, shareAccessRepo)
+	go shareAccessWorker.Start()
+
+	// --- 启动彻底删除 Worker ---
+	purgeWorker := NewPurgeWorker(mqClient, purgeService)
+	go purgeWorker.Start()
+
+	// --- 启动存储迁移 Worker ---
+	migrationWorker := NewStorageMigrationWorker(mqClient, migrationService)
+	go migrationWorker.Start()
+
+	// --- 启动图片和视频元数据提取 Worker ---
+	mediaWorker := NewMediaWorker(mqClient, galleryService)
+	go mediaWorker.Start()
+
+	// --- 启动文档内容索引 Worker ---
+	if cfg.Search.Enabled {
+		contentIndexWorker := NewContentIndexWorker(mqClient, searchService)
+		go contentIndexWorker.Start()
+	}
+
+	// --- 启动账号注销 Worker ---
+	accountDeletionWorker := NewAccountDeletionWorker(mqClient, accountDeletionService)
+	go accountDeletionWorker.Start()
+
 	// --- 在这里启动其他 Worker ---
 
 	logger.Info("所有后台工作进程已启动。")
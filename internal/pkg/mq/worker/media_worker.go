@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mq"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+)
+
+// MediaWorker 消费图片和视频的元数据提取任务,结果用于相册按拍摄时间排列
+type MediaWorker struct {
+	mqClient       *mq.RabbitMQClient
+	galleryService explorer.GalleryService
+}
+
+func NewMediaWorker(mqClient *mq.RabbitMQClient, galleryService explorer.GalleryService) *MediaWorker {
+	return &MediaWorker{
+		mqClient:       mqClient,
+		galleryService: galleryService,
+	}
+}
+
+func (w *MediaWorker) Start() {
+	_, err := w.mqClient.DeclareQueue(explorer.MediaQueueName)
+	if err != nil {
+		log.Fatalf("Failed to declare queue: %s", err)
+	}
+	err = w.mqClient.Consume(explorer.MediaQueueName, w.ExtractMetadata)
+	if err != nil {
+		log.Fatalf("Failed to start consuming from queue: %s", err)
+	}
+
+	log.Println("Media worker started...")
+}
+
+func (w *MediaWorker) ExtractMetadata(ctx context.Context, msg amqp.Delivery) {
+	var task models.ExtractMediaTask
+	if err := json.Unmarshal(msg.Body, &task); err != nil {
+		logger.Error("Failed to unmarshal media task", zap.Error(err))
+		_ = msg.Nack(false, false) // 解析失败,直接抛弃
+		return
+	}
+
+	// 提取失败的文件在相册中按上传时间排列,消息不再重试
+	if err := w.galleryService.ExtractMetadata(ctx, task); err != nil {
+		logger.Error("ExtractMetadata: Failed to extract media metadata", zap.Uint64("fileID", task.FileID), zap.Error(err))
+	}
+	_ = msg.Ack(false)
+}
@@ -0,0 +1,150 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mq"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultOutboxPollInterval = 500 // 毫秒
+	defaultOutboxBatchSize    = 100
+	defaultOutboxMaxBackoff   = 300 // 秒
+	defaultOutboxMaxAttempts  = 50
+	// outboxClaimLease 领取后到投递完成前其他实例不会重复领取的时间
+	outboxClaimLease = time.Minute
+	// outboxStreamMaxLen 消息 Stream 保留的最大消息数
+	outboxStreamMaxLen = 10000
+)
+
+// OutboxWorker 轮询发件箱,把事务中写入的消息投递到 Redis Stream 和 RabbitMQ,投递成功后删除,
+// 超过最大重试次数的消息标记为失败。投递和删除之间崩溃时消息会被重复投递,消费者需要能处理重复消息
+type OutboxWorker struct {
+	outboxRepo repositories.OutboxRepository
+	cache      cache.Cache
+	mqClient   *mq.RabbitMQClient
+	cfg        config.OutboxConfig
+}
+
+func NewOutboxWorker(outboxRepo repositories.OutboxRepository, cache cache.Cache, mqClient *mq.RabbitMQClient, cfg config.OutboxConfig) *OutboxWorker {
+	return &OutboxWorker{
+		outboxRepo: outboxRepo,
+		cache:      cache,
+		mqClient:   mqClient,
+		cfg:        cfg,
+	}
+}
+
+// Run 持续投递到期的消息,一批取满时立即处理下一批,否则等待轮询间隔,ctx 取消后退出
+func (w *OutboxWorker) Run(ctx context.Context) {
+	interval := w.cfg.PollInterval
+	if interval <= 0 {
+		interval = defaultOutboxPollInterval
+	}
+	batchSize := w.cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultOutboxBatchSize
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Millisecond)
+	defer ticker.Stop()
+
+	logger.Info("Outbox worker started", zap.Int("pollIntervalMs", interval), zap.Int("batchSize", batchSize))
+	for {
+		claimed, err := w.relay(ctx, batchSize)
+		if err != nil && ctx.Err() == nil {
+			logger.Error("OutboxWorker: Failed to relay outbox events", zap.Error(err))
+		}
+		if err == nil && claimed == batchSize {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relay 领取一批到期的消息后在事务外投递,多个实例同时运行时各自处理不同的消息
+func (w *OutboxWorker) relay(ctx context.Context, batchSize int) (int, error) {
+	events, err := w.outboxRepo.ClaimDue(ctx, batchSize, outboxClaimLease)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+
+	maxAttempts := w.cfg.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultOutboxMaxAttempts
+	}
+	delivered := make([]uint64, 0, len(events))
+	for i := range events {
+		event := &events[i]
+		err := w.deliver(ctx, event)
+		if err == nil {
+			delivered = append(delivered, event.ID)
+			continue
+		}
+		attempts := event.Attempts + 1
+		if attempts >= maxAttempts {
+			if err := w.outboxRepo.MarkDead(ctx, event.ID, attempts, err.Error()); err != nil {
+				return len(events), fmt.Errorf("failed to mark outbox event dead: %w", err)
+			}
+			logger.Error("OutboxWorker: Giving up on event after too many attempts",
+				zap.Uint64("eventID", event.ID), zap.String("topic", event.Topic), zap.Int("attempts", attempts), zap.Error(err))
+			continue
+		}
+		if err := w.outboxRepo.MarkFailed(ctx, event.ID, attempts, time.Now().Add(w.backoff(attempts)), err.Error()); err != nil {
+			return len(events), fmt.Errorf("failed to mark outbox event failed: %w", err)
+		}
+		logger.Warn("OutboxWorker: Failed to deliver event, will retry",
+			zap.Uint64("eventID", event.ID), zap.String("topic", event.Topic), zap.Int("attempts", attempts), zap.Error(err))
+	}
+	if err := w.outboxRepo.Delete(ctx, delivered); err != nil {
+		return len(events), fmt.Errorf("failed to delete delivered outbox events: %w", err)
+	}
+	return len(events), nil
+}
+
+func (w *OutboxWorker) deliver(ctx context.Context, event *models.OutboxEvent) error {
+	switch event.Target {
+	case models.OutboxTargetStream:
+		return w.cache.XAdd(ctx, &redis.XAddArgs{
+			Stream: event.Topic,
+			MaxLen: outboxStreamMaxLen,
+			Values: map[string]any{"payload": event.Payload},
+		}).Err()
+	case models.OutboxTargetQueue:
+		return w.mqClient.Publish(ctx, event.Topic, event.Payload)
+	default:
+		return fmt.Errorf("unknown outbox target %q", event.Target)
+	}
+}
+
+// backoff 按失败次数指数增加重试间隔,不超过配置的上限
+func (w *OutboxWorker) backoff(attempts int) time.Duration {
+	maxBackoff := w.cfg.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultOutboxMaxBackoff
+	}
+	limit := time.Duration(maxBackoff) * time.Second
+	if attempts > 20 {
+		return limit
+	}
+	delay := time.Second << (attempts - 1)
+	if delay > limit {
+		return limit
+	}
+	return delay
+}
@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mq"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+)
+
+// PurgeWorker 消费彻底删除任务,分批删除文件的版本、存储对象和数据库记录
+type PurgeWorker struct {
+	mqClient     *mq.RabbitMQClient
+	purgeService explorer.PurgeService
+}
+
+func NewPurgeWorker(mqClient *mq.RabbitMQClient, purgeService explorer.PurgeService) *PurgeWorker {
+	return &PurgeWorker{
+		mqClient:     mqClient,
+		purgeService: purgeService,
+	}
+}
+
+func (w *PurgeWorker) Start() {
+	_, err := w.mqClient.DeclareQueue(explorer.PurgeQueueName)
+	if err != nil {
+		log.Fatalf("Failed to declare queue: %s", err)
+	}
+	err = w.mqClient.Consume(explorer.PurgeQueueName, w.ProcessPurge)
+	if err != nil {
+		log.Fatalf("Failed to start consuming from queue: %s", err)
+	}
+
+	log.Println("Purge worker started...")
+}
+
+func (w *PurgeWorker) ProcessPurge(ctx context.Context, msg amqp.Delivery) {
+	var task models.PurgeTask
+	if err := json.Unmarshal(msg.Body, &task); err != nil {
+		logger.Error("Failed to unmarshal purge task", zap.Error(err))
+		_ = msg.Nack(false, false) // 解析失败,直接抛弃
+		return
+	}
+
+	// 删除失败时服务已通过发件箱安排延迟重试,或在重试次数用完后把任务标记为失败,当前消息不再重试
+	if err := w.purgeService.ProcessPurge(ctx, task.JobID); err != nil {
+		logger.Error("ProcessPurge: Failed to process purge job", zap.Uint64("jobID", task.JobID), zap.Error(err))
+	}
+	_ = msg.Ack(false)
+}
@@ -0,0 +1,144 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mq"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/scanner"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/storage"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+)
+
+// ScanWorker 消费病毒扫描任务,把对象流式发送给 clamd,发现病毒时隔离文件并记录到用户的活动日志
+type ScanWorker struct {
+	mqClient       *mq.RabbitMQClient
+	fileRepo       repositories.FileRepository
+	activityRepo   repositories.ActivityRepository
+	storageService storage.StorageService
+	scanner        *scanner.ClamdClient
+	cfg            config.ScanConfig
+}
+
+func NewScanWorker(
+	mqClient *mq.RabbitMQClient,
+	fileRepo repositories.FileRepository,
+	activityRepo repositories.ActivityRepository,
+	storageService storage.StorageService,
+	cfg config.ScanConfig,
+) *ScanWorker {
+	return &ScanWorker{
+		mqClient:       mqClient,
+		fileRepo:       fileRepo,
+		activityRepo:   activityRepo,
+		storageService: storageService,
+		scanner:        scanner.NewClamdClient(cfg.ClamdAddress, time.Duration(cfg.Timeout)*time.Second),
+		cfg:            cfg,
+	}
+}
+
+func (w *ScanWorker) Start() {
+	_, err := w.mqClient.DeclareQueue(explorer.ScanQueueName)
+	if err != nil {
+		log.Fatalf("Failed to declare queue: %s", err)
+	}
+	err = w.mqClient.Consume(explorer.ScanQueueName, w.ScanFile)
+	if err != nil {
+		log.Fatalf("Failed to start consuming from queue: %s", err)
+	}
+
+	log.Println("Scan worker started...")
+}
+
+func (w *ScanWorker) ScanFile(ctx context.Context, msg amqp.Delivery) {
+	var task models.ScanFileTask
+	if err := json.Unmarshal(msg.Body, &task); err != nil {
+		logger.Error("Failed to unmarshal scan task", zap.Error(err))
+		_ = msg.Nack(false, false) // 解析失败,直接抛弃
+		return
+	}
+
+	file, err := w.fileRepo.FindByID(ctx, task.FileID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrFileNotFound) {
+			_ = msg.Ack(false) // 文件已被删除,无需扫描
+			return
+		}
+		logger.Error("ScanFile: Failed to find file", zap.Uint64("fileID", task.FileID), zap.Error(err))
+		_ = msg.Nack(false, true)
+		return
+	}
+	// 扫描任务投递后文件又上传了新版本,新版本有自己的扫描任务
+	if file.VersionID != nil && *file.VersionID != task.VersionID {
+		logger.Info("ScanFile: File content changed, skipping outdated scan task",
+			zap.Uint64("fileID", task.FileID), zap.String("versionID", task.VersionID))
+		_ = msg.Ack(false)
+		return
+	}
+
+	if w.cfg.MaxSize > 0 && task.Size > uint64(w.cfg.MaxSize) {
+		w.updateScanStatus(ctx, msg, task, models.ScanStatusSkipped)
+		return
+	}
+
+	object, err := w.storageService.GetObject(ctx, task.OssBucket, task.OssKey, task.VersionID)
+	if err != nil {
+		logger.Error("ScanFile: Failed to get object", zap.String("ossKey", task.OssKey), zap.Error(err))
+		_ = msg.Nack(false, true)
+		return
+	}
+	defer object.Reader.Close()
+
+	result, err := w.scanner.Scan(ctx, object.Reader)
+	if err != nil {
+		logger.Error("ScanFile: Failed to scan file", zap.Uint64("fileID", task.FileID), zap.Error(err))
+		_ = msg.Nack(false, true) // clamd 不可用,重新入队
+		return
+	}
+
+	if !result.Infected {
+		w.updateScanStatus(ctx, msg, task, models.ScanStatusClean)
+		return
+	}
+
+	logger.Warn("ScanFile: Virus detected, file quarantined",
+		zap.Uint64("fileID", task.FileID),
+		zap.Uint64("userID", task.UserID),
+		zap.String("signature", result.Signature))
+	if !w.updateScanStatus(ctx, msg, task, models.ScanStatusInfected) {
+		return
+	}
+
+	activity := &models.Activity{
+		UserID:    file.UserID,
+		FileID:    &file.ID,
+		Action:    models.ActivityQuarantine,
+		Detail:    file.FileName + ": " + result.Signature,
+		CreatedAt: time.Now(),
+	}
+	if err := w.activityRepo.Create(ctx, activity); err != nil {
+		logger.Error("ScanFile: Failed to record quarantine activity", zap.Uint64("fileID", task.FileID), zap.Error(err))
+	}
+}
+
+// updateScanStatus 保存扫描结果并确认消息,失败时重新入队
+func (w *ScanWorker) updateScanStatus(ctx context.Context, msg amqp.Delivery, task models.ScanFileTask, scanStatus string) bool {
+	if err := w.fileRepo.UpdateScanStatus(ctx, task.FileID, scanStatus); err != nil {
+		logger.Error("ScanFile: Failed to update scan status", zap.Uint64("fileID", task.FileID), zap.String("scanStatus", scanStatus), zap.Error(err))
+		_ = msg.Nack(false, true)
+		return false
+	}
+	logger.Info("ScanFile: File scanned", zap.Uint64("fileID", task.FileID), zap.String("scanStatus", scanStatus))
+	_ = msg.Ack(false)
+	return true
+}
@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mq"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/share"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+)
+
+// ShareAccessWorker 消费分享访问日志消息并写入数据库,避免下载请求等待数据库写入
+type ShareAccessWorker struct {
+	mqClient   *mq.RabbitMQClient
+	accessRepo repositories.ShareAccessLogRepository
+}
+
+func NewShareAccessWorker(mqClient *mq.RabbitMQClient, accessRepo repositories.ShareAccessLogRepository) *ShareAccessWorker {
+	return &ShareAccessWorker{
+		mqClient:   mqClient,
+		accessRepo: accessRepo,
+	}
+}
+
+func (w *ShareAccessWorker) Start() {
+	_, err := w.mqClient.DeclareQueue(share.AccessQueueName)
+	if err != nil {
+		log.Fatalf("Failed to declare queue: %s", err)
+	}
+	err = w.mqClient.Consume(share.AccessQueueName, w.SaveAccessLog)
+	if err != nil {
+		log.Fatalf("Failed to start consuming from queue: %s", err)
+	}
+
+	log.Println("Share access worker started...")
+}
+
+func (w *ShareAccessWorker) SaveAccessLog(ctx context.Context, msg amqp.Delivery) {
+	var record models.ShareAccessLog
+	if err := json.Unmarshal(msg.Body, &record); err != nil {
+		logger.Error("Failed to unmarshal share access log", zap.Error(err))
+		_ = msg.Nack(false, false) // 解析失败,直接抛弃
+		return
+	}
+
+	if err := w.accessRepo.Create(&record); err != nil {
+		logger.Error("Failed to save share access log", zap.Uint64("shareID", record.ShareID), zap.Error(err))
+		_ = msg.Nack(false, true) // 数据库错误，重新入队
+		return
+	}
+
+	_ = msg.Ack(false)
+}
@@ -0,0 +1,106 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/activity"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultShareCleanupInterval  = 360 // 分钟
+	defaultShareCleanupBatchSize = 200
+)
+
+// ShareCleanupWorker 定期永久删除撤销或过期超过保留期的分享记录,并为每条记录写入活动日志
+type ShareCleanupWorker struct {
+	shareRepo       repositories.ShareRepository
+	activityService activity.ActivityService
+	cfg             config.ShareCleanupConfig
+}
+
+func NewShareCleanupWorker(
+	shareRepo repositories.ShareRepository,
+	activityService activity.ActivityService,
+	cfg config.ShareCleanupConfig,
+) *ShareCleanupWorker {
+	return &ShareCleanupWorker{
+		shareRepo:       shareRepo,
+		activityService: activityService,
+		cfg:             cfg,
+	}
+}
+
+// Run 启动时立即执行一次清理,之后按配置的间隔执行,ctx 取消后退出
+func (w *ShareCleanupWorker) Run(ctx context.Context) {
+	if !w.cfg.Enabled {
+		logger.Info("Share cleanup worker disabled")
+		return
+	}
+
+	interval := w.cfg.Interval
+	if interval <= 0 {
+		interval = defaultShareCleanupInterval
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Minute)
+	defer ticker.Stop()
+
+	logger.Info("Share cleanup worker started",
+		zap.Int("retentionDays", w.cfg.RetentionDays),
+		zap.Int("intervalMinutes", interval))
+	for {
+		w.purge(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purge 分批删除超过保留期的失效分享
+func (w *ShareCleanupWorker) purge(ctx context.Context) {
+	batchSize := w.cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultShareCleanupBatchSize
+	}
+	before := time.Now().AddDate(0, 0, -w.cfg.RetentionDays)
+
+	total := 0
+	for ctx.Err() == nil {
+		shares, err := w.shareRepo.FindPurgeable(ctx, before, batchSize)
+		if err != nil {
+			logger.Error("ShareCleanup: Failed to find purgeable shares", zap.Error(err))
+			return
+		}
+		if len(shares) == 0 {
+			break
+		}
+
+		ids := make([]uint64, 0, len(shares))
+		for _, share := range shares {
+			ids = append(ids, share.ID)
+		}
+		if err := w.shareRepo.PermanentDelete(ctx, ids); err != nil {
+			logger.Error("ShareCleanup: Failed to delete shares", zap.Int("count", len(ids)), zap.Error(err))
+			return
+		}
+		for _, share := range shares {
+			w.activityService.Record(ctx, share.UserID, share.FileID, models.ActivitySharePurge, share.UUID)
+		}
+		total += len(shares)
+
+		if len(shares) < batchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		logger.Info("ShareCleanup: Expired and revoked shares purged", zap.Int("count", total))
+	}
+}
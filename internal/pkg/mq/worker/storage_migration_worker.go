@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mq"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+)
+
+// StorageMigrationWorker 消费存储迁移任务
+type StorageMigrationWorker struct {
+	mqClient         *mq.RabbitMQClient
+	migrationService explorer.StorageMigrationService
+}
+
+func NewStorageMigrationWorker(mqClient *mq.RabbitMQClient, migrationService explorer.StorageMigrationService) *StorageMigrationWorker {
+	return &StorageMigrationWorker{
+		mqClient:         mqClient,
+		migrationService: migrationService,
+	}
+}
+
+func (w *StorageMigrationWorker) Start() {
+	_, err := w.mqClient.DeclareQueue(explorer.StorageMigrationQueueName)
+	if err != nil {
+		log.Fatalf("Failed to declare queue: %s", err)
+	}
+	err = w.mqClient.Consume(explorer.StorageMigrationQueueName, w.ProcessMigration)
+	if err != nil {
+		log.Fatalf("Failed to start consuming from queue: %s", err)
+	}
+
+	log.Println("Storage migration worker started...")
+}
+
+func (w *StorageMigrationWorker) ProcessMigration(ctx context.Context, msg amqp.Delivery) {
+	var task models.StorageMigrationTask
+	if err := json.Unmarshal(msg.Body, &task); err != nil {
+		logger.Error("Failed to unmarshal storage migration task", zap.Error(err))
+		_ = msg.Nack(false, false) // 解析失败,直接抛弃
+		return
+	}
+
+	// 失败或中断时任务已记录进度并标记为失败,由管理员决定是否继续,消息不再重试
+	if err := w.migrationService.ProcessMigration(ctx, task.MigrationID); err != nil {
+		logger.Error("ProcessMigration: Failed to process storage migration", zap.Uint64("migrationID", task.MigrationID), zap.Error(err))
+	}
+	_ = msg.Ack(false)
+}
@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultTrashExpirationInterval  = 60 // 分钟
+	defaultTrashExpirationBatchSize = 500
+)
+
+// TrashExpirationWorker 定期彻底删除超过所有者回收站保留期的文件
+type TrashExpirationWorker struct {
+	purgeService explorer.PurgeService
+	cfg          config.TrashConfig
+}
+
+func NewTrashExpirationWorker(purgeService explorer.PurgeService, cfg config.TrashConfig) *TrashExpirationWorker {
+	return &TrashExpirationWorker{
+		purgeService: purgeService,
+		cfg:          cfg,
+	}
+}
+
+// Run 启动时立即执行一次,之后按配置的间隔执行,ctx 取消后退出
+func (w *TrashExpirationWorker) Run(ctx context.Context) {
+	if !w.cfg.Enabled {
+		logger.Info("Trash expiration worker disabled")
+		return
+	}
+
+	interval := w.cfg.Interval
+	if interval <= 0 {
+		interval = defaultTrashExpirationInterval
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Minute)
+	defer ticker.Stop()
+
+	logger.Info("Trash expiration worker started",
+		zap.Int("defaultRetentionDays", w.cfg.DefaultRetentionDays()),
+		zap.Int("intervalMinutes", interval))
+	for {
+		w.expire(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expire 每次最多处理一批,剩余的文件留到下一轮
+func (w *TrashExpirationWorker) expire(ctx context.Context) {
+	batchSize := w.cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultTrashExpirationBatchSize
+	}
+	created, err := w.purgeService.PurgeExpiredTrash(ctx, time.Now(), batchSize)
+	if err != nil {
+		logger.Error("TrashExpiration: Failed to purge expired trash", zap.Error(err))
+	}
+	if created > 0 {
+		logger.Info("TrashExpiration: Expired trash scheduled for purge", zap.Int("count", created))
+	}
+}
@@ -0,0 +1,90 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultUploadSessionTTL       = 24 // 小时
+	defaultUploadCleanupInterval  = 60 // 分钟
+	defaultUploadCleanupBatchSize = 100
+)
+
+// UploadCleanupWorker 定期中止客户端放弃的上传会话,释放存储中未合并的分片
+type UploadCleanupWorker struct {
+	uploadService explorer.UploadService
+	cfg           config.UploadCleanupConfig
+}
+
+func NewUploadCleanupWorker(uploadService explorer.UploadService, cfg config.UploadCleanupConfig) *UploadCleanupWorker {
+	return &UploadCleanupWorker{
+		uploadService: uploadService,
+		cfg:           cfg,
+	}
+}
+
+// Run 启动时立即执行一次清理,之后按配置的间隔执行,ctx 取消后退出
+func (w *UploadCleanupWorker) Run(ctx context.Context) {
+	if !w.cfg.Enabled {
+		logger.Info("Upload cleanup worker disabled")
+		return
+	}
+
+	interval := w.cfg.Interval
+	if interval <= 0 {
+		interval = defaultUploadCleanupInterval
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Minute)
+	defer ticker.Stop()
+
+	logger.Info("Upload cleanup worker started",
+		zap.Int("ttlHours", w.ttl()),
+		zap.Int("intervalMinutes", interval))
+	for {
+		w.expire(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *UploadCleanupWorker) ttl() int {
+	if w.cfg.TTL <= 0 {
+		return defaultUploadSessionTTL
+	}
+	return w.cfg.TTL
+}
+
+// expire 分批处理过期的上传会话,处理失败的会话留到下一轮重试
+func (w *UploadCleanupWorker) expire(ctx context.Context) {
+	batchSize := w.cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultUploadCleanupBatchSize
+	}
+	before := time.Now().Add(-time.Duration(w.ttl()) * time.Hour)
+
+	total := 0
+	for ctx.Err() == nil {
+		expired, err := w.uploadService.ExpireStaleUploads(ctx, before, batchSize)
+		if err != nil {
+			logger.Error("UploadCleanup: Failed to expire upload sessions", zap.Error(err))
+			return
+		}
+		total += expired
+		if expired < batchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		logger.Info("UploadCleanup: Abandoned upload sessions expired", zap.Int("count", total))
+	}
+}
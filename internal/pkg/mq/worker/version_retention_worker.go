@@ -0,0 +1,123 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultRetentionInterval  = 60 // 分钟
+	defaultRetentionBatchSize = 200
+)
+
+// VersionRetentionWorker 定期按保留策略清理历史版本,只负责投递删除任务,
+// 数据库记录和存储对象的删除由 DeleteWorker 完成
+type VersionRetentionWorker struct {
+	outbox          repositories.OutboxRepository
+	fileVersionRepo repositories.FileVersionRepository
+	cfg             config.VersionRetentionConfig
+}
+
+func NewVersionRetentionWorker(
+	outbox repositories.OutboxRepository,
+	fileVersionRepo repositories.FileVersionRepository,
+	cfg config.VersionRetentionConfig,
+) *VersionRetentionWorker {
+	return &VersionRetentionWorker{
+		outbox:          outbox,
+		fileVersionRepo: fileVersionRepo,
+		cfg:             cfg,
+	}
+}
+
+// Run 启动时立即执行一次清理,之后按配置的间隔执行,ctx 取消后退出
+func (w *VersionRetentionWorker) Run(ctx context.Context) {
+	if !w.cfg.Enabled || (w.cfg.KeepLast <= 0 && w.cfg.KeepDays <= 0) {
+		logger.Info("Version retention worker disabled")
+		return
+	}
+
+	interval := w.cfg.Interval
+	if interval <= 0 {
+		interval = defaultRetentionInterval
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Minute)
+	defer ticker.Stop()
+
+	logger.Info("Version retention worker started",
+		zap.Int("keepLast", w.cfg.KeepLast),
+		zap.Int("keepDays", w.cfg.KeepDays),
+		zap.Int("intervalMinutes", interval))
+	for {
+		w.enforce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// enforce 分批查找超出保留策略的版本并投递删除任务
+func (w *VersionRetentionWorker) enforce(ctx context.Context) {
+	batchSize := w.cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultRetentionBatchSize
+	}
+	before := time.Now()
+	if w.cfg.KeepDays > 0 {
+		before = before.AddDate(0, 0, -w.cfg.KeepDays)
+	}
+
+	total := 0
+	for ctx.Err() == nil {
+		versions, err := w.fileVersionRepo.FindExpiredVersions(ctx, w.cfg.KeepLast, before, batchSize)
+		if err != nil {
+			logger.Error("VersionRetention: Failed to find expired versions", zap.Error(err))
+			return
+		}
+
+		for _, version := range versions {
+			if err := w.enqueue(ctx, version); err != nil {
+				logger.Error("VersionRetention: Failed to enqueue version deletion",
+					zap.Uint64("fileID", version.FileID), zap.String("versionID", version.VersionID), zap.Error(err))
+				return
+			}
+			total++
+		}
+
+		if len(versions) < batchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		logger.Info("VersionRetention: Expired versions enqueued for deletion", zap.Int("count", total))
+	}
+}
+
+// enqueue 在同一事务中写入删除任务并软删除版本记录,避免下一轮重复投递,DeleteWorker 会连同软删除的记录一起清理
+func (w *VersionRetentionWorker) enqueue(ctx context.Context, version models.FileVersion) error {
+	event, err := models.NewQueueEvent(DeleteSpecificVersionQueueName, models.DeleteFileTask{
+		FileID:    version.FileID,
+		Bucket:    version.OssBucket,
+		OssKey:    version.OssKey,
+		VersionID: version.VersionID,
+	})
+	if err != nil {
+		return err
+	}
+	return w.outbox.Transaction(ctx, func(tx *gorm.DB) error {
+		if err := w.outbox.WithTx(tx).Add(ctx, event); err != nil {
+			return err
+		}
+		return repositories.NewFileVersionRepository(tx).Delete(ctx, version.ID)
+	})
+}
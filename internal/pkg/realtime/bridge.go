@@ -0,0 +1,185 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache/filecache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// readBlockTimeout 每次阻塞读取的最长时间,超时后检查是否需要退出
+const readBlockTimeout = 2 * time.Second
+
+// FileChange 单个文件变化事件的内容
+type FileChange struct {
+	FileID            uint64  `json:"file_id"`
+	ParentFolderID    *uint64 `json:"parent_folder_id"`
+	OldParentFolderID *uint64 `json:"old_parent_folder_id"`
+	FileName          string  `json:"filename"`
+	Path              string  `json:"path"`
+	IsFolder          uint8   `json:"is_folder"`
+	Deleted           bool    `json:"deleted"`
+	Version           uint64  `json:"version"`
+}
+
+// FolderChange 文件夹内容变化事件的内容,FolderPaths 为发生变化的文件夹完整路径,根目录为 "/"
+type FolderChange struct {
+	FolderPaths []string `json:"folder_paths"`
+}
+
+// PathChange 文件夹路径变化事件的内容,路径以 OldPath 开头的子项需要替换为以 NewPath 开头
+type PathChange struct {
+	FolderID uint64 `json:"folder_id"`
+	OldPath  string `json:"old_path"`
+	NewPath  string `json:"new_path"`
+}
+
+// Run 将文件变化和站内通知转发给当前实例上的连接,ctx 取消后断开所有连接并退出。
+// statsStream 为文件夹统计刷新事件的 Stream,文件创建和删除只通过它发布
+func (h *Hub) Run(ctx context.Context, redisClient *redis.Client, statsStream string) {
+	defer h.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.forwardNotifications(ctx, redisClient)
+	}()
+	h.forwardStreams(ctx, redisClient, statsStream)
+	<-done
+}
+
+// forwardNotifications 订阅所有用户的通知频道,以及会话撤销频道
+func (h *Hub) forwardNotifications(ctx context.Context, redisClient *redis.Client) {
+	pubsub := redisClient.PSubscribe(ctx, cache.NotificationChannelPrefix+"*")
+	defer pubsub.Close()
+	if err := pubsub.Subscribe(ctx, cache.SessionRevokedChannel); err != nil {
+		logger.Error("Realtime: Failed to subscribe to session revocations", zap.Error(err))
+	}
+
+	channel := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-channel:
+			if !ok {
+				return
+			}
+			if msg.Channel == cache.SessionRevokedChannel {
+				h.closeRevokedSession(msg.Payload)
+				continue
+			}
+			userID, err := strconv.ParseUint(strings.TrimPrefix(msg.Channel, cache.NotificationChannelPrefix), 10, 64)
+			if err != nil {
+				continue
+			}
+			h.Publish(userID, Event{Type: EventNotification, Data: json.RawMessage(msg.Payload)})
+		}
+	}
+}
+
+// forwardStreams 读取文件缓存更新、文件夹路径变化和文件夹统计的 Stream。
+// 不使用消费者组,每个实例都需要收到全部消息;读取失败期间的事件会丢失,客户端重连后需要全量刷新
+func (h *Hub) forwardStreams(ctx context.Context, redisClient *redis.Client, statsStream string) {
+	streams := []string{filecache.UpdateStream, filecache.PathChangeStream, statsStream}
+	lastIDs := map[string]string{}
+	for _, stream := range streams {
+		lastIDs[stream] = "$"
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		args := make([]string, 0, len(streams)*2)
+		args = append(args, streams...)
+		for _, stream := range streams {
+			args = append(args, lastIDs[stream])
+		}
+		result, err := redisClient.XRead(ctx, &redis.XReadArgs{
+			Streams: args,
+			Count:   100,
+			Block:   readBlockTimeout,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+				continue
+			}
+			logger.Error("Realtime: Failed to read from streams", zap.Error(err))
+			time.Sleep(time.Second)
+			continue
+		}
+
+		for _, stream := range result {
+			for _, message := range stream.Messages {
+				lastIDs[stream.Stream] = message.ID
+				payload, _ := message.Values["payload"].(string)
+				switch stream.Stream {
+				case filecache.UpdateStream:
+					h.forwardFileChange(payload)
+				case filecache.PathChangeStream:
+					h.forwardPathChange(payload)
+				case statsStream:
+					h.forwardFolderChange(payload)
+				}
+			}
+		}
+	}
+}
+
+func (h *Hub) closeRevokedSession(payload string) {
+	var msg cache.SessionRevokedMessage
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		return
+	}
+	h.CloseSession(msg.UserID, msg.SessionID)
+}
+
+func (h *Hub) forwardFileChange(payload string) {
+	var msg cache.CacheUpdateMessage
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil || !h.HasClients(msg.File.UserID) {
+		return
+	}
+	file := msg.File
+	h.Publish(file.UserID, Event{Type: EventFileChanged, Data: FileChange{
+		FileID:            file.ID,
+		ParentFolderID:    file.ParentFolderID,
+		OldParentFolderID: msg.OldParentFolderID,
+		FileName:          file.FileName,
+		Path:              file.Path,
+		IsFolder:          file.IsFolder,
+		Deleted:           file.DeletedAt.Valid,
+		Version:           file.Version,
+	}})
+}
+
+func (h *Hub) forwardPathChange(payload string) {
+	var msg cache.FolderPathChangeMessage
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil || !h.HasClients(msg.UserID) {
+		return
+	}
+	h.Publish(msg.UserID, Event{Type: EventPathChanged, Data: PathChange{
+		FolderID: msg.FolderID,
+		OldPath:  msg.OldPath,
+		NewPath:  msg.NewPath,
+	}})
+}
+
+func (h *Hub) forwardFolderChange(payload string) {
+	var msg cache.FileStatsUpdateMessage
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil || !h.HasClients(msg.UserID) {
+		return
+	}
+	h.Publish(msg.UserID, Event{Type: EventFolderChanged, Data: FolderChange{FolderPaths: msg.FolderPaths}})
+}
@@ -0,0 +1,236 @@
+// Package realtime 通过 WebSocket 向在线客户端推送文件变化和站内通知。
+// 每个实例只维护连接到自己的客户端,事件来自 Redis Stream 和 pub/sub,所有实例都会收到全部事件
+package realtime
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// 事件类型
+const (
+	EventFileChanged   = "file_changed"   // 单个文件的元数据变化,如重命名、移动、还原
+	EventFolderChanged = "folder_changed" // 文件夹内容变化,如上传、删除,客户端据此刷新打开的文件夹
+	EventPathChanged   = "path_changed"   // 文件夹移动或重命名导致子树的路径前缀变化
+	EventNotification  = "notification"   // 新的站内通知
+)
+
+const (
+	// sendBuffer 每个连接待发送事件的缓冲数量,写满说明客户端读取太慢,直接断开
+	sendBuffer = 64
+	// writeWait 单次写入的超时时间
+	writeWait = 10 * time.Second
+	// pongWait 超过该时间没有收到 pong 视为连接已断开
+	pongWait = 60 * time.Second
+	// pingPeriod 发送 ping 的间隔,需要小于 pongWait
+	pingPeriod = pongWait * 9 / 10
+	// maxMessageSize 客户端消息的最大字节数,客户端只需要回复 pong
+	maxMessageSize = 512
+)
+
+// Event 推送给客户端的事件
+type Event struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+// Hub 当前实例上的 WebSocket 连接,按用户分组
+type Hub struct {
+	mu         sync.RWMutex
+	clients    map[uint64]map[*client]struct{}
+	maxPerUser int
+	closed     bool
+}
+
+// NewHub 创建 Hub,maxPerUser 为每个用户在单个实例上的最大连接数,0 表示不限制
+func NewHub(maxPerUser int) *Hub {
+	return &Hub{
+		clients:    make(map[uint64]map[*client]struct{}),
+		maxPerUser: maxPerUser,
+	}
+}
+
+type client struct {
+	hub       *Hub
+	userID    uint64
+	sessionID string // 登录会话ID,个人访问令牌和 OAuth 令牌的连接为空
+	conn      *websocket.Conn
+	send      chan []byte
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// Serve 注册连接并开始收发,阻塞到连接断开。sessionID 为连接所属的登录会话,会话被撤销时连接随之断开。
+// 超出连接数限制时关闭连接并返回 false
+func (h *Hub) Serve(conn *websocket.Conn, userID uint64, sessionID string) bool {
+	c := &client{hub: h, userID: userID, sessionID: sessionID, conn: conn, send: make(chan []byte, sendBuffer)}
+	if !h.register(c) {
+		_ = conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "too many connections"), time.Now().Add(writeWait))
+		_ = conn.Close()
+		return false
+	}
+
+	go c.writePump()
+	c.readPump()
+	return true
+}
+
+// Publish 将事件推送给用户在当前实例上的所有连接
+func (h *Hub) Publish(userID uint64, event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	clients := h.clients[userID]
+	if len(clients) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		logger.Error("Realtime: Failed to marshal event", zap.String("type", event.Type), zap.Error(err))
+		return
+	}
+	for c := range clients {
+		c.trySend(payload)
+	}
+}
+
+// HasClients 用户是否有连接到当前实例的客户端,没有时可以跳过事件解析
+func (h *Hub) HasClients(userID uint64) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients[userID]) > 0
+}
+
+// CloseSession 断开用户在当前实例上属于该会话的连接
+func (h *Hub) CloseSession(userID uint64, sessionID string) {
+	if sessionID == "" {
+		return
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for c := range h.clients[userID] {
+		if c.sessionID == sessionID {
+			c.close()
+		}
+	}
+}
+
+// Close 断开所有连接,之后的连接会被直接拒绝
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for _, clients := range h.clients {
+		for c := range clients {
+			c.close()
+		}
+	}
+}
+
+func (h *Hub) register(c *client) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return false
+	}
+	clients := h.clients[c.userID]
+	if h.maxPerUser > 0 && len(clients) >= h.maxPerUser {
+		return false
+	}
+	if clients == nil {
+		clients = make(map[*client]struct{})
+		h.clients[c.userID] = clients
+	}
+	clients[c] = struct{}{}
+	return true
+}
+
+func (h *Hub) unregister(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	clients := h.clients[c.userID]
+	delete(clients, c)
+	if len(clients) == 0 {
+		delete(h.clients, c.userID)
+	}
+}
+
+// trySend 将事件放入发送缓冲,缓冲已满说明客户端跟不上推送速度,断开后由客户端重连并全量刷新
+func (c *client) trySend(payload []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	select {
+	case c.send <- payload:
+	default:
+		c.closed = true
+		close(c.send)
+	}
+}
+
+// close 关闭发送通道,writePump 随后发送关闭帧并断开连接
+func (c *client) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.send)
+	}
+}
+
+// readPump 读取客户端消息以处理 pong 和关闭帧,客户端发送的内容被忽略
+func (c *client) readPump() {
+	defer func() {
+		c.hub.unregister(c)
+		c.close()
+		_ = c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// writePump 发送事件和定时 ping,发送通道关闭后发送关闭帧
+func (c *client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		_ = c.conn.Close()
+	}()
+
+	for {
+		select {
+		case payload, ok := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				_ = c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
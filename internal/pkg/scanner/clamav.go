@@ -0,0 +1,115 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// streamChunkSize INSTREAM 每次发送的数据块大小
+const streamChunkSize = 64 << 10
+
+// ErrScanFailed clamd 返回了错误,例如超出 StreamMaxLength
+var ErrScanFailed = errors.New("clamd scan failed")
+
+// Result 扫描结果
+type Result struct {
+	Infected  bool
+	Signature string // 命中的病毒特征名
+}
+
+// ClamdClient 通过 INSTREAM 命令把数据流发送给 clamd 扫描,不需要和 clamd 共享文件系统
+type ClamdClient struct {
+	address string
+	timeout time.Duration
+}
+
+func NewClamdClient(address string, timeout time.Duration) *ClamdClient {
+	return &ClamdClient{
+		address: address,
+		timeout: timeout,
+	}
+}
+
+// Scan 扫描 r 中的全部内容,ctx 取消或超时时中断连接
+func (c *ClamdClient) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	network := "tcp"
+	if strings.HasPrefix(c.address, "/") {
+		network = "unix"
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, c.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, fmt.Errorf("failed to send command to clamd: %w", err)
+	}
+
+	// 每个数据块前带 4 字节大端长度,长度为 0 的块表示结束
+	writer := bufio.NewWriterSize(conn, streamChunkSize+4)
+	buf := make([]byte, streamChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := writer.Write(size); err != nil {
+				return nil, fmt.Errorf("failed to stream data to clamd: %w", err)
+			}
+			if _, err := writer.Write(buf[:n]); err != nil {
+				return nil, fmt.Errorf("failed to stream data to clamd: %w", err)
+			}
+		}
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read data to scan: %w", readErr)
+		}
+	}
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := writer.Write(size); err != nil {
+		return nil, fmt.Errorf("failed to stream data to clamd: %w", err)
+	}
+	if err := writer.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to stream data to clamd: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	return parseReply(strings.TrimSuffix(reply, "\x00"))
+}
+
+// parseReply 解析 clamd 的响应,格式为 "stream: OK"、"stream: <特征名> FOUND" 或 "<原因> ERROR"
+func parseReply(reply string) (*Result, error) {
+	reply = strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case reply == "OK":
+		return &Result{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return &Result{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrScanFailed, reply)
+	}
+}
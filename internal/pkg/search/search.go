@@ -0,0 +1,277 @@
+// Package search 在 Elasticsearch 中维护文档内容和自定义属性的全文索引,每个文件一篇文档,文档ID为文件ID
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// Document 索引中的一篇文档
+type Document struct {
+	FileID    uint64 `json:"file_id"`
+	UserID    uint64 `json:"user_id"`
+	VersionID string `json:"version_id"` // 提取内容时文件指向的对象版本,搜索结果据此跳过已被新版本替换的内容
+	Size      uint64 `json:"size"`
+	MimeType  string `json:"mime_type"`
+	Content   string `json:"content"`
+	// Attributes 自定义属性的值,每行一个。只有属性的文件(如文件夹)文档中没有内容和版本
+	Attributes string `json:"attributes,omitempty"`
+}
+
+// Filter 搜索时按文档属性过滤。大小和内容类型随版本不变,写入文档时记录;
+// 早于这些字段写入的文档没有对应属性,不会被过滤掉,由调用方按数据库中的记录再次过滤
+type Filter struct {
+	MinSize    *uint64
+	MaxSize    *uint64
+	MimePrefix string
+}
+
+// Hit 一条搜索结果
+type Hit struct {
+	FileID    uint64
+	VersionID string
+	Snippet   string // 命中内容的高亮片段,匹配词用 <em> 包裹
+	// AttributesMatched 自定义属性命中查询,与内容所属的版本无关
+	AttributesMatched bool
+	AttributesSnippet string
+}
+
+// ContentIndex 文档内容索引
+type ContentIndex interface {
+	// EnsureIndex 索引不存在时按映射创建
+	EnsureIndex(ctx context.Context) error
+	// Put 写入或覆盖文件的文档
+	Put(ctx context.Context, doc Document) error
+	// PutAttributes 只更新文档中的自定义属性,文档不存在时创建只有属性的文档
+	PutAttributes(ctx context.Context, fileID, userID uint64, attributes string) error
+	// Delete 删除文件的文档,文档不存在时不报错
+	Delete(ctx context.Context, fileID uint64) error
+	// Search 在用户的文档中按内容和自定义属性搜索,返回当前页的结果和命中总数
+	Search(ctx context.Context, userID uint64, query string, filter Filter, from, size int) ([]Hit, int64, error)
+}
+
+type esContentIndex struct {
+	client *elasticsearch.Client
+	index  string
+}
+
+var _ ContentIndex = (*esContentIndex)(nil)
+
+// defaultIndex 未配置索引名称时使用的索引
+const defaultIndex = "clouddisk_file_contents"
+
+// NewContentIndex 创建基于 Elasticsearch 的内容索引
+func NewContentIndex(client *elasticsearch.Client, index string) ContentIndex {
+	if index == "" {
+		index = defaultIndex
+	}
+	return &esContentIndex{client: client, index: index}
+}
+
+// indexMapping content 和 attributes 使用标准分词器,其余字段只用于过滤
+const indexMapping = `{
+  "mappings": {
+    "properties": {
+      "file_id":    {"type": "long"},
+      "user_id":    {"type": "long"},
+      "version_id": {"type": "keyword", "index": false},
+      "size":       {"type": "long"},
+      "mime_type":  {"type": "keyword"},
+      "content":    {"type": "text"},
+      "attributes": {"type": "text"}
+    }
+  }
+}`
+
+// filterMapping 后来增加的字段,已存在的索引启动时补充映射
+const filterMapping = `{
+  "properties": {
+    "size":       {"type": "long"},
+    "mime_type":  {"type": "keyword"},
+    "attributes": {"type": "text"}
+  }
+}`
+
+func (i *esContentIndex) EnsureIndex(ctx context.Context) error {
+	res, err := esapi.IndicesExistsRequest{Index: []string{i.index}}.Do(ctx, i.client)
+	if err != nil {
+		return fmt.Errorf("failed to check index: %w", err)
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		res, err = esapi.IndicesPutMappingRequest{Index: []string{i.index}, Body: bytes.NewReader([]byte(filterMapping))}.Do(ctx, i.client)
+		if err != nil {
+			return fmt.Errorf("failed to update mapping: %w", err)
+		}
+		return checkResponse(res, "update mapping")
+	}
+
+	res, err = esapi.IndicesCreateRequest{Index: i.index, Body: bytes.NewReader([]byte(indexMapping))}.Do(ctx, i.client)
+	if err != nil {
+		return fmt.Errorf("failed to create index: %w", err)
+	}
+	return checkResponse(res, "create index")
+}
+
+func (i *esContentIndex) Put(ctx context.Context, doc Document) error {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal document: %w", err)
+	}
+	res, err := esapi.IndexRequest{
+		Index:      i.index,
+		DocumentID: strconv.FormatUint(doc.FileID, 10),
+		Body:       bytes.NewReader(body),
+	}.Do(ctx, i.client)
+	if err != nil {
+		return fmt.Errorf("failed to index document: %w", err)
+	}
+	return checkResponse(res, "index document")
+}
+
+func (i *esContentIndex) PutAttributes(ctx context.Context, fileID, userID uint64, attributes string) error {
+	body, err := json.Marshal(map[string]any{
+		"doc":           map[string]any{"file_id": fileID, "user_id": userID, "attributes": attributes},
+		"doc_as_upsert": true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal attributes: %w", err)
+	}
+	res, err := esapi.UpdateRequest{
+		Index:      i.index,
+		DocumentID: strconv.FormatUint(fileID, 10),
+		Body:       bytes.NewReader(body),
+	}.Do(ctx, i.client)
+	if err != nil {
+		return fmt.Errorf("failed to update attributes: %w", err)
+	}
+	return checkResponse(res, "update attributes")
+}
+
+func (i *esContentIndex) Delete(ctx context.Context, fileID uint64) error {
+	res, err := esapi.DeleteRequest{Index: i.index, DocumentID: strconv.FormatUint(fileID, 10)}.Do(ctx, i.client)
+	if err != nil {
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		return nil
+	}
+	return checkResponse(res, "delete document")
+}
+
+type searchResponse struct {
+	Hits struct {
+		Total struct {
+			Value int64 `json:"value"`
+		} `json:"total"`
+		Hits []struct {
+			Source         Document            `json:"_source"`
+			Highlight      map[string][]string `json:"highlight"`
+			MatchedQueries []string            `json:"matched_queries"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+func (i *esContentIndex) Search(ctx context.Context, userID uint64, query string, filter Filter, from, size int) ([]Hit, int64, error) {
+	filters := []any{map[string]any{"term": map[string]any{"user_id": userID}}}
+	if filter.MinSize != nil || filter.MaxSize != nil {
+		sizeRange := map[string]any{}
+		if filter.MinSize != nil {
+			sizeRange["gte"] = *filter.MinSize
+		}
+		if filter.MaxSize != nil {
+			sizeRange["lte"] = *filter.MaxSize
+		}
+		filters = append(filters, orMissing("size", map[string]any{"range": map[string]any{"size": sizeRange}}))
+	}
+	if filter.MimePrefix != "" {
+		filters = append(filters, orMissing("mime_type", map[string]any{"prefix": map[string]any{"mime_type": filter.MimePrefix}}))
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"from":    from,
+		"size":    size,
+		"_source": []string{"file_id", "version_id"},
+		"query": map[string]any{
+			"bool": map[string]any{
+				"filter": filters,
+				"should": []any{
+					map[string]any{"match": map[string]any{
+						"content": map[string]any{"query": query, "operator": "and", "_name": "content"},
+					}},
+					map[string]any{"match": map[string]any{
+						"attributes": map[string]any{"query": query, "operator": "and", "_name": "attributes"},
+					}},
+				},
+				"minimum_should_match": 1,
+			},
+		},
+		"highlight": map[string]any{
+			"fields": map[string]any{
+				"content":    map[string]any{"fragment_size": 150, "number_of_fragments": 1},
+				"attributes": map[string]any{"fragment_size": 150, "number_of_fragments": 1},
+			},
+		},
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to marshal search query: %w", err)
+	}
+
+	res, err := esapi.SearchRequest{Index: []string{i.index}, Body: bytes.NewReader(body)}.Do(ctx, i.client)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, 0, fmt.Errorf("failed to search: %s", res.Status())
+	}
+
+	var result searchResponse
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode search response: %w", err)
+	}
+	hits := make([]Hit, 0, len(result.Hits.Hits))
+	for _, h := range result.Hits.Hits {
+		hit := Hit{FileID: h.Source.FileID, VersionID: h.Source.VersionID}
+		if fragments := h.Highlight["content"]; len(fragments) > 0 {
+			hit.Snippet = fragments[0]
+		}
+		if fragments := h.Highlight["attributes"]; len(fragments) > 0 {
+			hit.AttributesSnippet = fragments[0]
+		}
+		hit.AttributesMatched = slices.Contains(h.MatchedQueries, "attributes")
+		hits = append(hits, hit)
+	}
+	return hits, result.Hits.Total.Value, nil
+}
+
+// orMissing 文档满足 clause 或者没有 field 字段
+func orMissing(field string, clause map[string]any) map[string]any {
+	return map[string]any{"bool": map[string]any{
+		"should": []any{
+			clause,
+			map[string]any{"bool": map[string]any{"must_not": map[string]any{"exists": map[string]any{"field": field}}}},
+		},
+		"minimum_should_match": 1,
+	}}
+}
+
+// checkResponse 关闭响应,返回 Elasticsearch 报告的错误
+func checkResponse(res *esapi.Response, action string) error {
+	defer res.Body.Close()
+	if res.IsError() {
+		detail, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("failed to %s: %s %s", action, res.Status(), detail)
+	}
+	return nil
+}
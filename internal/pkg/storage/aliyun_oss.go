@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -14,11 +15,12 @@ import (
 	"go.uber.org/zap"
 )
 
-//TODO 待完善文件,后续考虑完善
-
 type AliyunOSSStorageService struct {
 	client *oss.Client
-	cfg    *config.AliyunOSSConfig // 阿里云OSS的配置信息
+	// regional 其他地域入口的客户端,按地域名称索引,只用于生成预签名下载链接
+	regional map[string]*oss.Client
+	regions  *regionSelector
+	cfg      *config.AliyunOSSConfig // 阿里云OSS的配置信息
 }
 
 // NewAliyunOSSStorageService 创建并返回一个 AliyunOSSStorageService 实例
@@ -30,12 +32,62 @@ func NewAliyunOSSStorageService(cfg *config.AliyunOSSConfig) (*AliyunOSSStorageS
 		return nil, fmt.Errorf("无法初始化阿里云OSS客户端: %w", err)
 	}
 	logger.Info("阿里云OSS客户端初始化成功", zap.String("endpoint", cfg.Endpoint))
+
+	// 检查并创建存储桶，然后开启版本控制
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	exists, err := ossClient.IsBucketExist(cfg.BucketName)
+	if err != nil {
+		return nil, fmt.Errorf("检查阿里云OSS存储桶存在性失败: %w", err)
+	}
+	if !exists {
+		if err := ossClient.CreateBucket(cfg.BucketName, oss.WithContext(ctx)); err != nil {
+			return nil, fmt.Errorf("创建阿里云OSS存储桶失败: %w", err)
+		}
+		logger.Info("阿里云OSS存储桶创建成功", zap.String("bucketName", cfg.BucketName))
+	}
+
+	if err := enableOSSVersioning(ctx, ossClient, cfg.BucketName); err != nil {
+		return nil, err
+	}
+
+	regions, err := newRegionSelector(cfg.Regions)
+	if err != nil {
+		return nil, fmt.Errorf("无法初始化阿里云OSS地域入口: %w", err)
+	}
+	regional := make(map[string]*oss.Client, len(cfg.Regions))
+	for _, region := range cfg.Regions {
+		client, err := oss.New(region.Endpoint, cfg.AccessKeyID, cfg.SecretAccessKey)
+		if err != nil {
+			return nil, fmt.Errorf("无法初始化阿里云OSS地域 %s 的客户端: %w", region.Name, err)
+		}
+		regional[region.Name] = client
+	}
 	return &AliyunOSSStorageService{
-		client: ossClient,
-		cfg:    cfg,
+		client:   ossClient,
+		regional: regional,
+		regions:  regions,
+		cfg:      cfg,
 	}, nil
 }
 
+// enableOSSVersioning 开启存储桶的版本控制,覆盖上传和删除时保留历史版本,文件版本记录依赖对象的 VersionId
+func enableOSSVersioning(ctx context.Context, client *oss.Client, bucketName string) error {
+	versioning, err := client.GetBucketVersioning(bucketName, oss.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("获取阿里云OSS存储桶版本控制状态失败: %w", err)
+	}
+	if versioning.Status != string(oss.VersionEnabled) {
+		err := client.SetBucketVersioning(bucketName, oss.VersioningConfig{Status: string(oss.VersionEnabled)}, oss.WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("开启阿里云OSS存储桶版本控制失败: %w", err)
+		}
+	}
+	logger.Info("阿里云OSS存储桶版本控制已开启", zap.String("bucketName", bucketName))
+	return nil
+}
+
 // PutObject 实现 StorageService 接口的 PutObject 方法
 func (s *AliyunOSSStorageService) PutObject(ctx context.Context, bucketName, objectName string, reader io.Reader, objectSize int64, contentType string) (PutObjectResult, error) {
 	bucket, err := s.client.Bucket(bucketName)
@@ -43,8 +95,12 @@ func (s *AliyunOSSStorageService) PutObject(ctx context.Context, bucketName, obj
 		return PutObjectResult{}, fmt.Errorf("获取OSS存储桶失败: %w", err)
 	}
 
+	// 版本号和 ETag 从响应头中读取,与 MinIO 一样返回去掉引号的 ETag
+	var respHeader http.Header
 	options := []oss.Option{
 		oss.ContentType(contentType),
+		oss.WithContext(ctx),
+		oss.GetResponseHeader(&respHeader),
 	}
 	// objectSize 参数在 PutObjectFromStream 中会被忽略，OSS SDK 会自动计算
 	err = bucket.PutObject(objectName, reader, options...)
@@ -52,15 +108,12 @@ func (s *AliyunOSSStorageService) PutObject(ctx context.Context, bucketName, obj
 		return PutObjectResult{}, fmt.Errorf("阿里云OSS上传文件失败: %w", err)
 	}
 
-	// 上传后，如果需要 ETag 和 Size，可能需要再次 StatObject
-	// 简单起见，这里可以假设上传成功，如果需要更精确的 info，可以考虑GetObjectMeta
-	// 实际项目中，更常见的是在PutObjectOptions中设置Header，或者依赖OSS的API响应
-	// 假设我们只关心基本的成功信息
 	return PutObjectResult{
-		Bucket: bucketName,
-		Key:    objectName,
-		Size:   objectSize, // 暂时使用传入的尺寸，因为PutObject本身不直接返回
-		// ETag:  如果需要精确ETag，需要调用GetObjectMeta
+		Bucket:    bucketName,
+		Key:       objectName,
+		Size:      objectSize,
+		ETag:      strings.Trim(respHeader.Get(oss.HTTPHeaderEtag), `"`),
+		VersionID: oss.GetVersionId(respHeader),
 	}, nil
 }
 
@@ -71,58 +124,121 @@ func (s *AliyunOSSStorageService) GetObject(ctx context.Context, bucketName, obj
 		return GetObjectResult{}, fmt.Errorf("获取OSS存储桶失败: %w", err)
 	}
 
-	var opts []oss.Option
+	opts := []oss.Option{oss.WithContext(ctx)}
 	if versionID != "" {
 		opts = append(opts, oss.VersionId(versionID))
 	}
 
-	reader, err := bucket.GetObject(objectName, opts...)
+	// 大小和内容类型直接取自下载响应的头部,与读取的版本一致
+	result, err := bucket.DoGetObject(&oss.GetObjectRequest{ObjectKey: objectName}, opts)
 	if err != nil {
+		if ossErr, ok := err.(oss.ServiceError); ok && (ossErr.Code == "NoSuchKey" || ossErr.Code == "NoSuchVersion") {
+			return GetObjectResult{}, fmt.Errorf("阿里云OSS获取文件失败: %w: %w", ErrObjectNotFound, err)
+		}
 		return GetObjectResult{}, fmt.Errorf("阿里云OSS获取文件失败: %w", err)
 	}
 
-	// 获取对象元数据以获取Size和MimeType
-	props, err := bucket.GetObjectDetailedMeta(objectName)
-	if err != nil {
-		logger.Warn("获取OSS对象元数据失败", zap.String("object", objectName), zap.Error(err))
-	}
-
-	size := int64(0)
-
-	if val := props.Get(oss.HTTPHeaderContentLength); val != "" {
+	size := int64(-1)
+	if val := result.Response.Headers.Get(oss.HTTPHeaderContentLength); val != "" {
 		size, _ = strconv.ParseInt(val, 10, 64)
 	}
-	mimeType := ""
-	if mt := props.Get(oss.HTTPHeaderContentType); mt != "" {
-		mimeType = mt
-	}
-
 	return GetObjectResult{
-		Reader:   reader,
+		Reader:   result.Response,
 		Size:     size,
-		MimeType: mimeType,
+		MimeType: result.Response.Headers.Get(oss.HTTPHeaderContentType),
 	}, nil
 }
 
-// RemoveObject 实现 StorageService 接口的 RemoveObject 方法
+// RemoveObject 删除对象的指定版本,VersionID 为空时在开启版本控制的存储桶中只会添加删除标记
 func (s *AliyunOSSStorageService) RemoveObject(ctx context.Context, bucketName, objectName, VersionID string) error {
 	bucket, err := s.client.Bucket(bucketName)
 	if err != nil {
 		return fmt.Errorf("获取OSS存储桶失败: %w", err)
 	}
-	err = bucket.DeleteObject(objectName)
+	opts := []oss.Option{oss.WithContext(ctx)}
+	if VersionID != "" {
+		opts = append(opts, oss.VersionId(VersionID))
+	}
+	err = bucket.DeleteObject(objectName, opts...)
 	if err != nil {
 		return fmt.Errorf("阿里云OSS删除文件失败: %w", err)
 	}
 	return nil
 }
 
-// 从指定存储桶删除所有版本文件
+// ossMaxDeleteObjects OSS 单次 DeleteMultipleObjects 最多删除 1000 个对象
+const ossMaxDeleteObjects = 1000
+
+// RemoveObjects 从指定存储桶删除对象的所有版本和删除标记
 func (s *AliyunOSSStorageService) RemoveObjects(ctx context.Context, bucketName, objectName string) error {
+	bucket, err := s.client.Bucket(bucketName)
+	if err != nil {
+		return fmt.Errorf("获取OSS存储桶失败: %w", err)
+	}
+
+	// 列出对象的所有版本和删除标记
+	var objects []oss.DeleteObject
+	keyMarker, versionIDMarker := "", ""
+	for {
+		result, err := bucket.ListObjectVersions(oss.Prefix(objectName), oss.KeyMarker(keyMarker),
+			oss.VersionIdMarker(versionIDMarker), oss.MaxKeys(ossMaxDeleteObjects), oss.WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("阿里云OSS列出对象版本失败: %w", err)
+		}
+		// Prefix 会匹配到同前缀的其他对象,只保留完全相同的 Key
+		for _, version := range result.ObjectVersions {
+			if version.Key == objectName {
+				objects = append(objects, oss.DeleteObject{Key: version.Key, VersionId: version.VersionId})
+			}
+		}
+		for _, marker := range result.ObjectDeleteMarkers {
+			if marker.Key == objectName {
+				objects = append(objects, oss.DeleteObject{Key: marker.Key, VersionId: marker.VersionId})
+			}
+		}
+		if !result.IsTruncated {
+			break
+		}
+		keyMarker, versionIDMarker = result.NextKeyMarker, result.NextVersionIdMarker
+	}
+
+	// 分批删除所有版本的物理文件
+	for start := 0; start < len(objects); start += ossMaxDeleteObjects {
+		end := min(start+ossMaxDeleteObjects, len(objects))
+		result, err := bucket.DeleteObjectVersions(objects[start:end], oss.WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("阿里云OSS批量删除对象失败: %w", err)
+		}
+		// 非静默模式只返回删除成功的版本,数量不足说明有版本删除失败
+		if len(result.DeletedObjectsDetail) < end-start {
+			undeleted := undeletedOSSVersions(objects[start:end], result.DeletedObjectsDetail)
+			logger.Error("Failed to remove some object versions",
+				zap.String("object", objectName),
+				zap.Int("requested", end-start),
+				zap.Int("deleted", len(result.DeletedObjectsDetail)),
+				zap.Strings("undeleted", undeleted))
+			return fmt.Errorf("阿里云OSS部分对象版本删除失败: %s", strings.Join(undeleted, ", "))
+		}
+	}
 
 	return nil
 }
 
+// undeletedOSSVersions 返回请求删除但不在删除结果中的对象版本,格式为 key@versionId
+func undeletedOSSVersions(requested []oss.DeleteObject, deleted []oss.DeletedKeyInfo) []string {
+	done := make(map[oss.DeleteObject]bool, len(deleted))
+	for _, d := range deleted {
+		done[oss.DeleteObject{Key: d.Key, VersionId: d.VersionId}] = true
+	}
+	var undeleted []string
+	for _, obj := range requested {
+		if !done[oss.DeleteObject{Key: obj.Key, VersionId: obj.VersionId}] {
+			undeleted = append(undeleted, obj.Key+"@"+obj.VersionId)
+		}
+	}
+	return undeleted
+}
+
 // IsBucketExist 实现 StorageService 接口的 IsBucketExist 方法
 func (s *AliyunOSSStorageService) IsBucketExist(ctx context.Context, bucketName string) (bool, error) {
 	found, err := s.client.IsBucketExist(bucketName)
@@ -142,12 +258,12 @@ func (s *AliyunOSSStorageService) MakeBucket(ctx context.Context, bucketName str
 		// 正确的类型断言和错误检查
 		if ossErr, ok := err.(oss.ServiceError); ok && (ossErr.Code == "BucketAlreadyExists" || ossErr.Code == "BucketAlreadyOwnedByYou") {
 			logger.Info("阿里云OSS存储桶已存在，无需创建", zap.String("bucket", bucketName))
-			return nil
+			return enableOSSVersioning(ctx, s.client, bucketName)
 		}
 		return fmt.Errorf("创建阿里云OSS存储桶失败: %w", err)
 	}
 	logger.Info("阿里云OSS存储桶创建成功", zap.String("bucket", bucketName))
-	return nil
+	return enableOSSVersioning(ctx, s.client, bucketName)
 }
 
 // GetObjectURL 获取对象的公开访问URL (如果桶是公开的)
@@ -168,9 +284,13 @@ func (s *AliyunOSSStorageService) GetObjectURL(bucketName, objectName string) st
 	return fmt.Sprintf("%s%s.%s/%s", scheme, bucketName, endpoint, objectName)
 }
 
-// GeneratePresignedURL 为下载生成预签名URL
-func (s *AliyunOSSStorageService) GeneratePresignedURL(ctx context.Context, bucketName, objectName, versionID string, expiry time.Duration) (string, error) {
-	bucket, err := s.client.Bucket(bucketName)
+// GeneratePresignedURL 为下载生成预签名URL,配置了其他地域时使用离客户端最近的入口签名
+func (s *AliyunOSSStorageService) GeneratePresignedURL(ctx context.Context, bucketName, objectName, versionID string, expiry time.Duration, opts PresignOptions) (string, error) {
+	client := s.client
+	if region := s.regions.Select(ctx); region != nil {
+		client = s.regional[region.Name]
+	}
+	bucket, err := client.Bucket(bucketName)
 	if err != nil {
 		return "", fmt.Errorf("获取OSS存储桶失败: %w", err)
 	}
@@ -179,6 +299,12 @@ func (s *AliyunOSSStorageService) GeneratePresignedURL(ctx context.Context, buck
 	if versionID != "" {
 		options = append(options, oss.VersionId(versionID))
 	}
+	if opts.ContentDisposition != "" {
+		options = append(options, oss.ResponseContentDisposition(opts.ContentDisposition))
+	}
+	if opts.ContentType != "" {
+		options = append(options, oss.ResponseContentType(opts.ContentType))
+	}
 
 	// SignURL 默认是 GET 方法
 	signedURL, err := bucket.SignURL(objectName, oss.HTTPGet, int64(expiry.Seconds()), options...)
@@ -188,22 +314,109 @@ func (s *AliyunOSSStorageService) GeneratePresignedURL(ctx context.Context, buck
 	return signedURL, nil
 }
 
-// --- 分块上传实现 (待定) ---
+// --- 分块上传实现 ---
+
+// ossMaxListParts OSS 单次 ListUploadedParts 最多返回 1000 个分块
+const ossMaxListParts = 1000
+
+// multipartUpload 根据 uploadID 构造 OSS SDK 需要的分块上传上下文
+func multipartUpload(bucketName, objectName, uploadID string) oss.InitiateMultipartUploadResult {
+	return oss.InitiateMultipartUploadResult{
+		Bucket:   bucketName,
+		Key:      objectName,
+		UploadID: uploadID,
+	}
+}
 
 func (s *AliyunOSSStorageService) InitMultiPartUpload(ctx context.Context, bucketName, objectName string, opts PutObjectOptions) (string, error) {
-	return "", fmt.Errorf("not implemented")
+	bucket, err := s.client.Bucket(bucketName)
+	if err != nil {
+		return "", fmt.Errorf("获取OSS存储桶失败: %w", err)
+	}
+
+	var options []oss.Option
+	if opts.ContentType != "" {
+		options = append(options, oss.ContentType(opts.ContentType))
+	}
+
+	imur, err := bucket.InitiateMultipartUpload(objectName, options...)
+	if err != nil {
+		return "", fmt.Errorf("阿里云OSS初始化分块上传失败: %w", err)
+	}
+	return imur.UploadID, nil
 }
 
 func (s *AliyunOSSStorageService) UploadPart(ctx context.Context, bucketName, objectName, uploadID string, reader io.Reader, partNumber int, partSize int64) (UploadPartResult, error) {
-	return UploadPartResult{}, fmt.Errorf("not implemented")
+	bucket, err := s.client.Bucket(bucketName)
+	if err != nil {
+		return UploadPartResult{}, fmt.Errorf("获取OSS存储桶失败: %w", err)
+	}
+
+	part, err := bucket.UploadPart(multipartUpload(bucketName, objectName, uploadID), reader, partSize, partNumber, oss.WithContext(ctx))
+	if err != nil {
+		return UploadPartResult{}, fmt.Errorf("阿里云OSS上传分块失败: %w", err)
+	}
+	// 与 MinIO 保持一致,返回去掉引号的 ETag
+	return UploadPartResult{
+		PartNumber: part.PartNumber,
+		ETag:       strings.Trim(part.ETag, `"`),
+	}, nil
 }
 
 func (s *AliyunOSSStorageService) CompleteMultiPartUpload(ctx context.Context, bucketName, objectName, uploadID string, parts []UploadPartResult) (PutObjectResult, error) {
-	return PutObjectResult{}, fmt.Errorf("not implemented")
+	bucket, err := s.client.Bucket(bucketName)
+	if err != nil {
+		return PutObjectResult{}, fmt.Errorf("获取OSS存储桶失败: %w", err)
+	}
+
+	ossParts := make([]oss.UploadPart, 0, len(parts))
+	for _, part := range parts {
+		ossParts = append(ossParts, oss.UploadPart{
+			PartNumber: part.PartNumber,
+			ETag:       fmt.Sprintf(`"%s"`, strings.Trim(part.ETag, `"`)),
+		})
+	}
+
+	var respHeader http.Header
+	result, err := bucket.CompleteMultipartUpload(multipartUpload(bucketName, objectName, uploadID), ossParts,
+		oss.WithContext(ctx), oss.GetResponseHeader(&respHeader))
+	if err != nil {
+		return PutObjectResult{}, fmt.Errorf("阿里云OSS完成分块上传失败: %w", err)
+	}
+
+	putResult := PutObjectResult{
+		Bucket:    bucketName,
+		Key:       objectName,
+		ETag:      strings.Trim(result.ETag, `"`),
+		VersionID: oss.GetVersionId(respHeader),
+	}
+
+	// 合并结果不包含对象大小,合并后立即获取对象元数据
+	var metaOpts []oss.Option
+	if putResult.VersionID != "" {
+		metaOpts = append(metaOpts, oss.VersionId(putResult.VersionID))
+	}
+	props, err := bucket.GetObjectDetailedMeta(objectName, metaOpts...)
+	if err != nil {
+		logger.Error("Aliyun OSS GetObjectDetailedMeta after complete failed", zap.Error(err), zap.String("objectName", objectName))
+		// 即使获取元数据失败，也返回已获得的信息，避免整个操作失败
+		return putResult, nil
+	}
+	if val := props.Get(oss.HTTPHeaderContentLength); val != "" {
+		putResult.Size, _ = strconv.ParseInt(val, 10, 64)
+	}
+	return putResult, nil
 }
 
 func (s *AliyunOSSStorageService) AbortMultiPartUpload(ctx context.Context, bucketName, objectName, uploadID string) error {
-	return fmt.Errorf("not implemented")
+	bucket, err := s.client.Bucket(bucketName)
+	if err != nil {
+		return fmt.Errorf("获取OSS存储桶失败: %w", err)
+	}
+	if err := bucket.AbortMultipartUpload(multipartUpload(bucketName, objectName, uploadID), oss.WithContext(ctx)); err != nil {
+		return fmt.Errorf("阿里云OSS中止分块上传失败: %w", err)
+	}
+	return nil
 }
 
 func (s *AliyunOSSStorageService) GetUploadObjName(fileHash, fileName string) string {
@@ -211,7 +424,34 @@ func (s *AliyunOSSStorageService) GetUploadObjName(fileHash, fileName string) st
 }
 
 func (s *AliyunOSSStorageService) ListObjectParts(ctx context.Context, bucketName, objectName, uploadID string) ([]UploadPartResult, error) {
-	return nil, nil
+	bucket, err := s.client.Bucket(bucketName)
+	if err != nil {
+		return nil, fmt.Errorf("获取OSS存储桶失败: %w", err)
+	}
+
+	imur := multipartUpload(bucketName, objectName, uploadID)
+	var parts []UploadPartResult
+	marker := 0
+	for {
+		result, err := bucket.ListUploadedParts(imur, oss.MaxParts(ossMaxListParts), oss.PartNumberMarker(marker), oss.WithContext(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("阿里云OSS列出已上传分块失败: %w", err)
+		}
+		for _, part := range result.UploadedParts {
+			parts = append(parts, UploadPartResult{
+				PartNumber: part.PartNumber,
+				ETag:       strings.Trim(part.ETag, `"`),
+			})
+		}
+		if !result.IsTruncated {
+			break
+		}
+		marker, err = strconv.Atoi(result.NextPartNumberMarker)
+		if err != nil {
+			return nil, fmt.Errorf("阿里云OSS分块列表标记无效: %w", err)
+		}
+	}
+	return parts, nil
 }
 
 func (s *AliyunOSSStorageService) IsUploadIDNotFound(err error) bool {
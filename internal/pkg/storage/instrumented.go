@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/metrics"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentedStorage 包装存储后端,记录每次调用的耗时、结果和 span,用于发现较慢的存储后端
+type instrumentedStorage struct {
+	next StorageService
+}
+
+var _ StorageService = (*instrumentedStorage)(nil)
+
+func newInstrumentedStorage(next StorageService) StorageService {
+	return &instrumentedStorage{next: next}
+}
+
+// observe 开始一次存储调用,返回的函数在调用结束时记录耗时指标并结束 span
+func (s *instrumentedStorage) observe(ctx context.Context, op string) (context.Context, func(*error)) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "storage."+op, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx, func(err *error) {
+		metrics.ObserveStorage(op, start, err)
+		tracing.End(span, *err)
+	}
+}
+
+func (s *instrumentedStorage) PutObject(ctx context.Context, bucketName, objectName string, reader io.Reader, objectSize int64, contentType string) (result PutObjectResult, err error) {
+	ctx, done := s.observe(ctx, "put_object")
+	defer done(&err)
+	return s.next.PutObject(ctx, bucketName, objectName, reader, objectSize, contentType)
+}
+
+// GetObject 只统计获取读取器的耗时,不包括调用方读取内容的时间
+func (s *instrumentedStorage) GetObject(ctx context.Context, bucketName, objectName, versionID string) (result GetObjectResult, err error) {
+	ctx, done := s.observe(ctx, "get_object")
+	defer done(&err)
+	return s.next.GetObject(ctx, bucketName, objectName, versionID)
+}
+
+func (s *instrumentedStorage) RemoveObject(ctx context.Context, bucketName, objectName, versionID string) (err error) {
+	ctx, done := s.observe(ctx, "remove_object")
+	defer done(&err)
+	return s.next.RemoveObject(ctx, bucketName, objectName, versionID)
+}
+
+func (s *instrumentedStorage) RemoveObjects(ctx context.Context, bucketName, objectName string) (err error) {
+	ctx, done := s.observe(ctx, "remove_objects")
+	defer done(&err)
+	return s.next.RemoveObjects(ctx, bucketName, objectName)
+}
+
+func (s *instrumentedStorage) IsBucketExist(ctx context.Context, bucketName string) (exists bool, err error) {
+	ctx, done := s.observe(ctx, "is_bucket_exist")
+	defer done(&err)
+	return s.next.IsBucketExist(ctx, bucketName)
+}
+
+func (s *instrumentedStorage) MakeBucket(ctx context.Context, bucketName string) (err error) {
+	ctx, done := s.observe(ctx, "make_bucket")
+	defer done(&err)
+	return s.next.MakeBucket(ctx, bucketName)
+}
+
+func (s *instrumentedStorage) GetObjectURL(bucketName, objectName string) string {
+	return s.next.GetObjectURL(bucketName, objectName)
+}
+
+func (s *instrumentedStorage) GeneratePresignedURL(ctx context.Context, bucketName, objectName, versionID string, expiry time.Duration, opts PresignOptions) (url string, err error) {
+	ctx, done := s.observe(ctx, "presign")
+	defer done(&err)
+	return s.next.GeneratePresignedURL(ctx, bucketName, objectName, versionID, expiry, opts)
+}
+
+func (s *instrumentedStorage) InitMultiPartUpload(ctx context.Context, bucketName, objectName string, opts PutObjectOptions) (uploadID string, err error) {
+	ctx, done := s.observe(ctx, "init_multipart")
+	defer done(&err)
+	return s.next.InitMultiPartUpload(ctx, bucketName, objectName, opts)
+}
+
+func (s *instrumentedStorage) UploadPart(ctx context.Context, bucketName, objectName, uploadID string, reader io.Reader, partNumber int, partSize int64) (result UploadPartResult, err error) {
+	ctx, done := s.observe(ctx, "upload_part")
+	defer done(&err)
+	return s.next.UploadPart(ctx, bucketName, objectName, uploadID, reader, partNumber, partSize)
+}
+
+func (s *instrumentedStorage) CompleteMultiPartUpload(ctx context.Context, bucketName, objectName, uploadID string, parts []UploadPartResult) (result PutObjectResult, err error) {
+	ctx, done := s.observe(ctx, "complete_multipart")
+	defer done(&err)
+	return s.next.CompleteMultiPartUpload(ctx, bucketName, objectName, uploadID, parts)
+}
+
+func (s *instrumentedStorage) AbortMultiPartUpload(ctx context.Context, bucketName, objectName, uploadID string) (err error) {
+	ctx, done := s.observe(ctx, "abort_multipart")
+	defer done(&err)
+	return s.next.AbortMultiPartUpload(ctx, bucketName, objectName, uploadID)
+}
+
+func (s *instrumentedStorage) ListObjectParts(ctx context.Context, bucketName, objectName, uploadID string) (parts []UploadPartResult, err error) {
+	ctx, done := s.observe(ctx, "list_parts")
+	defer done(&err)
+	return s.next.ListObjectParts(ctx, bucketName, objectName, uploadID)
+}
+
+func (s *instrumentedStorage) GetUploadObjName(fileHash, fileName string) string {
+	return s.next.GetUploadObjName(fileHash, fileName)
+}
+
+func (s *instrumentedStorage) IsUploadIDNotFound(err error) bool {
+	return s.next.IsUploadIDNotFound(err)
+}
@@ -0,0 +1,536 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	localVersionsSuffix = ".versions"  // 对象的各个版本保存在 <对象名>.versions 目录下
+	localMultipartDir   = ".multipart" // 进行中的分块上传,每个 uploadID 一个目录
+	localUploadMetaFile = "upload.json"
+	localSignedURLPath  = "/d/"
+)
+
+var (
+	// ErrLocalUploadNotFound 分块上传不存在或已完成、已中止
+	ErrLocalUploadNotFound = errors.New("local storage: upload not found")
+	// ErrSignedURLInvalid 签名下载链接格式错误或签名不匹配
+	ErrSignedURLInvalid = errors.New("local storage: invalid signed url")
+	// ErrSignedURLExpired 签名下载链接已过期
+	ErrSignedURLExpired = errors.New("local storage: signed url expired")
+)
+
+// LocalStorageService 基于本地文件系统的存储实现,不依赖 MinIO 等对象存储即可运行完整的文件服务。
+// 目录结构: <根目录>/<存储桶>/<对象名>.versions/<版本ID>,版本ID按写入时间递增,最大的为最新版本
+type LocalStorageService struct {
+	root string
+	cfg  *config.LocalStorageConfig
+}
+
+// localUploadMeta 分块上传的目标对象
+type localUploadMeta struct {
+	Bucket      string `json:"bucket"`
+	Object      string `json:"object"`
+	ContentType string `json:"content_type"`
+}
+
+// NewLocalStorageService 创建并返回一个 LocalStorageService 实例,根目录和默认存储桶不存在时自动创建
+func NewLocalStorageService(root string, cfg *config.LocalStorageConfig) (*LocalStorageService, error) {
+	if root == "" {
+		return nil, errors.New("无法初始化本地存储: local_base_path 不能为空")
+	}
+	if cfg.SigningKey == "" {
+		return nil, errors.New("无法初始化本地存储: signing_key 不能为空")
+	}
+
+	s := &LocalStorageService{root: root, cfg: cfg}
+	if err := os.MkdirAll(filepath.Join(root, localMultipartDir), 0o755); err != nil {
+		return nil, fmt.Errorf("创建本地存储目录失败: %w", err)
+	}
+	if err := s.MakeBucket(context.Background(), cfg.BucketName); err != nil {
+		return nil, err
+	}
+
+	logger.Info("本地存储初始化成功", zap.String("root", root), zap.String("bucket", cfg.BucketName))
+	return s, nil
+}
+
+func (s *LocalStorageService) PutObject(ctx context.Context, bucketName, objectName string, reader io.Reader, objectSize int64, contentType string) (PutObjectResult, error) {
+	dir, err := s.objectDir(bucketName, objectName)
+	if err != nil {
+		return PutObjectResult{}, err
+	}
+
+	versionID := newLocalVersionID()
+	size, etag, err := writeFileAtomic(filepath.Join(dir, versionID), func(w io.Writer) error {
+		_, err := io.Copy(w, reader)
+		return err
+	})
+	if err != nil {
+		return PutObjectResult{}, fmt.Errorf("本地存储写入文件失败: %w", err)
+	}
+	return PutObjectResult{
+		Bucket:    bucketName,
+		Key:       objectName,
+		Size:      size,
+		ETag:      etag,
+		VersionID: versionID,
+	}, nil
+}
+
+func (s *LocalStorageService) GetObject(ctx context.Context, bucketName, objectName, versionID string) (GetObjectResult, error) {
+	filePath, err := s.versionPath(bucketName, objectName, versionID)
+	if err != nil {
+		return GetObjectResult{}, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return GetObjectResult{}, fmt.Errorf("本地存储获取文件失败: %w: %w", ErrObjectNotFound, err)
+		}
+		return GetObjectResult{}, fmt.Errorf("本地存储获取文件失败: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return GetObjectResult{}, fmt.Errorf("本地存储获取文件信息失败: %w", err)
+	}
+	return GetObjectResult{
+		Reader:   file,
+		Size:     info.Size(),
+		MimeType: mime.TypeByExtension(path.Ext(objectName)),
+	}, nil
+}
+
+// 从指定存储桶删除指定版本文件,versionID 为空时删除最新版本
+func (s *LocalStorageService) RemoveObject(ctx context.Context, bucketName, objectName, VersionID string) error {
+	filePath, err := s.versionPath(bucketName, objectName, VersionID)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove object version: %w", err)
+	}
+	// 最后一个版本删除后清理版本目录,目录非空时 Remove 会失败,忽略即可
+	_ = os.Remove(filepath.Dir(filePath))
+	return nil
+}
+
+// 从指定存储桶删除所有版本文件
+func (s *LocalStorageService) RemoveObjects(ctx context.Context, bucketName, objectName string) error {
+	dir, err := s.objectDir(bucketName, objectName)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("本地存储删除对象失败: %w", err)
+	}
+	return nil
+}
+
+func (s *LocalStorageService) IsBucketExist(ctx context.Context, bucketName string) (bool, error) {
+	dir, err := s.bucketDir(bucketName)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("检查本地存储桶存在性失败: %w", err)
+	}
+	return info.IsDir(), nil
+}
+
+func (s *LocalStorageService) MakeBucket(ctx context.Context, bucketName string) error {
+	dir, err := s.bucketDir(bucketName)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("创建本地存储桶失败: %w", err)
+	}
+	return nil
+}
+
+// GetObjectURL 本地存储的对象不能公开访问,下载需要使用 GeneratePresignedURL 生成的签名链接
+func (s *LocalStorageService) GetObjectURL(bucketName, objectName string) string {
+	return ""
+}
+
+// GeneratePresignedURL 本地存储没有预签名机制,生成携带 HMAC 签名和过期时间的下载链接 <public_url>/d/<token>,
+// 响应头覆盖一并写入令牌
+func (s *LocalStorageService) GeneratePresignedURL(ctx context.Context, bucketName, objectName, versionID string, expiry time.Duration, opts PresignOptions) (string, error) {
+	if _, err := s.objectDir(bucketName, objectName); err != nil {
+		return "", err
+	}
+	payload := strings.Join([]string{
+		bucketName,
+		objectName,
+		versionID,
+		strconv.FormatInt(time.Now().Add(expiry).Unix(), 10),
+		opts.ContentDisposition,
+		opts.ContentType,
+		strconv.FormatUint(opts.UserID, 10),
+		strconv.FormatUint(opts.ShareID, 10),
+	}, "\n")
+	token := base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(signLocalPayload(s.cfg.SigningKey, payload))
+	return strings.TrimSuffix(s.cfg.PublicURL, "/") + localSignedURLPath + token, nil
+}
+
+// ParseSignedToken 校验 GeneratePresignedURL 生成的下载令牌,返回令牌指向的对象和响应头覆盖
+func (s *LocalStorageService) ParseSignedToken(token string) (bucketName, objectName, versionID string, opts PresignOptions, err error) {
+	return ParseLocalSignedToken(s.cfg, token)
+}
+
+// ParseLocalSignedToken 只使用签名密钥校验下载令牌,供不持有本地存储实例的下载接口使用
+func ParseLocalSignedToken(cfg *config.LocalStorageConfig, token string) (bucketName, objectName, versionID string, opts PresignOptions, err error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", "", "", opts, ErrSignedURLInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return "", "", "", opts, ErrSignedURLInvalid
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, signLocalPayload(cfg.SigningKey, string(payload))) {
+		return "", "", "", opts, ErrSignedURLInvalid
+	}
+
+	// 旧令牌只有前 4 个字段,没有响应头覆盖;6 个字段的令牌没有限速信息
+	fields := strings.Split(string(payload), "\n")
+	switch len(fields) {
+	case 4:
+	case 6, 8:
+		opts = PresignOptions{ContentDisposition: fields[4], ContentType: fields[5]}
+		if len(fields) == 8 {
+			if opts.UserID, err = strconv.ParseUint(fields[6], 10, 64); err != nil {
+				return "", "", "", opts, ErrSignedURLInvalid
+			}
+			if opts.ShareID, err = strconv.ParseUint(fields[7], 10, 64); err != nil {
+				return "", "", "", opts, ErrSignedURLInvalid
+			}
+		}
+	default:
+		return "", "", "", opts, ErrSignedURLInvalid
+	}
+	expiresAt, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return "", "", "", opts, ErrSignedURLInvalid
+	}
+	if time.Now().Unix() > expiresAt {
+		return "", "", "", opts, ErrSignedURLExpired
+	}
+	return fields[0], fields[1], fields[2], opts, nil
+}
+
+func signLocalPayload(signingKey, payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// --- 分块上传实现,分块先写入临时目录,完成时按顺序拼接为新版本 ---
+
+func (s *LocalStorageService) InitMultiPartUpload(ctx context.Context, bucketName, objectName string, opts PutObjectOptions) (string, error) {
+	if _, err := s.objectDir(bucketName, objectName); err != nil {
+		return "", err
+	}
+
+	uploadID := newLocalVersionID()
+	dir := filepath.Join(s.root, localMultipartDir, uploadID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("本地存储初始化分块上传失败: %w", err)
+	}
+	meta, _ := json.Marshal(localUploadMeta{Bucket: bucketName, Object: objectName, ContentType: opts.ContentType})
+	if err := os.WriteFile(filepath.Join(dir, localUploadMetaFile), meta, 0o644); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("本地存储初始化分块上传失败: %w", err)
+	}
+	return uploadID, nil
+}
+
+func (s *LocalStorageService) UploadPart(ctx context.Context, bucketName, objectName, uploadID string, reader io.Reader, partNumber int, partSize int64) (UploadPartResult, error) {
+	dir, err := s.uploadDir(bucketName, objectName, uploadID)
+	if err != nil {
+		return UploadPartResult{}, err
+	}
+
+	_, etag, err := writeFileAtomic(filepath.Join(dir, localPartName(partNumber)), func(w io.Writer) error {
+		_, err := io.Copy(w, reader)
+		return err
+	})
+	if err != nil {
+		return UploadPartResult{}, fmt.Errorf("本地存储上传分块失败: %w", err)
+	}
+	return UploadPartResult{PartNumber: partNumber, ETag: etag}, nil
+}
+
+func (s *LocalStorageService) CompleteMultiPartUpload(ctx context.Context, bucketName, objectName, uploadID string, parts []UploadPartResult) (PutObjectResult, error) {
+	dir, err := s.uploadDir(bucketName, objectName, uploadID)
+	if err != nil {
+		return PutObjectResult{}, err
+	}
+	objectDir, err := s.objectDir(bucketName, objectName)
+	if err != nil {
+		return PutObjectResult{}, err
+	}
+
+	versionID := newLocalVersionID()
+	size, etag, err := writeFileAtomic(filepath.Join(objectDir, versionID), func(w io.Writer) error {
+		for _, part := range parts {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := appendFile(w, filepath.Join(dir, localPartName(part.PartNumber))); err != nil {
+				return fmt.Errorf("part %d: %w", part.PartNumber, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return PutObjectResult{}, fmt.Errorf("本地存储完成分块上传失败: %w", err)
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		logger.Warn("Failed to remove local multipart directory", zap.String("uploadID", uploadID), zap.Error(err))
+	}
+	return PutObjectResult{
+		Bucket:    bucketName,
+		Key:       objectName,
+		Size:      size,
+		ETag:      etag,
+		VersionID: versionID,
+	}, nil
+}
+
+func (s *LocalStorageService) AbortMultiPartUpload(ctx context.Context, bucketName, objectName, uploadID string) error {
+	dir, err := s.uploadDir(bucketName, objectName, uploadID)
+	if err != nil {
+		if errors.Is(err, ErrLocalUploadNotFound) {
+			return nil
+		}
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("本地存储中止分块上传失败: %w", err)
+	}
+	return nil
+}
+
+func (s *LocalStorageService) ListObjectParts(ctx context.Context, bucketName, objectName, uploadID string) ([]UploadPartResult, error) {
+	dir, err := s.uploadDir(bucketName, objectName, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("本地存储列出已上传分块失败: %w", err)
+	}
+
+	var parts []UploadPartResult
+	for _, entry := range entries {
+		numberStr, ok := strings.CutPrefix(entry.Name(), "part-")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		partNumber, err := strconv.Atoi(numberStr)
+		if err != nil {
+			continue
+		}
+		etag, err := md5File(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("本地存储列出已上传分块失败: %w", err)
+		}
+		parts = append(parts, UploadPartResult{PartNumber: partNumber, ETag: etag})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	return parts, nil
+}
+
+func (s *LocalStorageService) GetUploadObjName(fileHash, fileName string) string {
+	return fmt.Sprintf("uploads/%s", fileName)
+}
+
+func (s *LocalStorageService) IsUploadIDNotFound(err error) bool {
+	return errors.Is(err, ErrLocalUploadNotFound)
+}
+
+// bucketDir 存储桶目录,存储桶名不能包含路径分隔符
+func (s *LocalStorageService) bucketDir(bucketName string) (string, error) {
+	if bucketName == "" || bucketName == "." || bucketName == ".." || bucketName == localMultipartDir ||
+		strings.ContainsAny(bucketName, `/\`) {
+		return "", fmt.Errorf("本地存储: 无效的存储桶名 %q", bucketName)
+	}
+	return filepath.Join(s.root, bucketName), nil
+}
+
+// objectDir 对象的版本目录,对象名中的 ".." 会被拒绝,防止访问存储桶以外的文件
+func (s *LocalStorageService) objectDir(bucketName, objectName string) (string, error) {
+	bucketDir, err := s.bucketDir(bucketName)
+	if err != nil {
+		return "", err
+	}
+	for _, segment := range strings.Split(objectName, "/") {
+		if segment == ".." {
+			return "", fmt.Errorf("本地存储: 无效的对象名 %q", objectName)
+		}
+	}
+	cleaned := strings.TrimPrefix(path.Clean("/"+objectName), "/")
+	if cleaned == "" {
+		return "", fmt.Errorf("本地存储: 无效的对象名 %q", objectName)
+	}
+	return filepath.Join(bucketDir, filepath.FromSlash(cleaned)+localVersionsSuffix), nil
+}
+
+// versionPath 指定版本的文件路径,versionID 为空时返回最新版本
+func (s *LocalStorageService) versionPath(bucketName, objectName, versionID string) (string, error) {
+	dir, err := s.objectDir(bucketName, objectName)
+	if err != nil {
+		return "", err
+	}
+	if versionID != "" {
+		if strings.ContainsAny(versionID, `/\.`) {
+			return "", fmt.Errorf("本地存储: 无效的版本ID %q", versionID)
+		}
+		return filepath.Join(dir, versionID), nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("本地存储获取文件失败: %w", err)
+	}
+	latest := ""
+	for _, entry := range entries {
+		// 写入中的临时文件以 "." 开头
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if entry.Name() > latest {
+			latest = entry.Name()
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("本地存储获取文件失败: %w", os.ErrNotExist)
+	}
+	return filepath.Join(dir, latest), nil
+}
+
+// uploadDir 分块上传的临时目录,同时校验 uploadID 属于该对象
+func (s *LocalStorageService) uploadDir(bucketName, objectName, uploadID string) (string, error) {
+	if uploadID == "" || strings.ContainsAny(uploadID, `/\.`) {
+		return "", ErrLocalUploadNotFound
+	}
+	dir := filepath.Join(s.root, localMultipartDir, uploadID)
+	data, err := os.ReadFile(filepath.Join(dir, localUploadMetaFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", ErrLocalUploadNotFound
+		}
+		return "", fmt.Errorf("本地存储读取分块上传信息失败: %w", err)
+	}
+	var meta localUploadMeta
+	if err := json.Unmarshal(data, &meta); err != nil || meta.Bucket != bucketName || meta.Object != objectName {
+		return "", ErrLocalUploadNotFound
+	}
+	return dir, nil
+}
+
+// newLocalVersionID 生成按时间递增的版本ID
+func newLocalVersionID() string {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("%016x%s", time.Now().UnixNano(), hex.EncodeToString(suffix))
+}
+
+func localPartName(partNumber int) string {
+	return fmt.Sprintf("part-%05d", partNumber)
+}
+
+// writeFileAtomic 先写入同目录下的临时文件再重命名,读取方不会看到写了一半的文件。返回写入的大小和 MD5
+func writeFileAtomic(filePath string, write func(w io.Writer) error) (int64, string, error) {
+	dir := filepath.Dir(filePath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, "", err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(filePath)+"-*")
+	if err != nil {
+		return 0, "", err
+	}
+	defer os.Remove(tmp.Name()) // 重命名成功后删除不会生效
+
+	hasher := md5.New()
+	counter := &countingWriter{w: io.MultiWriter(tmp, hasher)}
+	if err := write(counter); err != nil {
+		tmp.Close()
+		return 0, "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return 0, "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, "", err
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return 0, "", err
+	}
+	return counter.n, hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func appendFile(w io.Writer, filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+func md5File(filePath string) (string, error) {
+	hasher := md5.New()
+	if err := appendFile(hasher, filePath); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
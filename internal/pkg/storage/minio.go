@@ -18,7 +18,10 @@ import (
 type MinIOStorageService struct {
 	client *minio.Client
 	core   *minio.Core
-	cfg    *config.MinIOConfig // MinIO的配置信息
+	// regional 其他地域入口的客户端,按地域名称索引,只用于生成预签名下载链接
+	regional map[string]*minio.Client
+	regions  *regionSelector
+	cfg      *config.MinIOConfig // MinIO的配置信息
 }
 
 // NewMinIOStorageService 创建并返回一个 MinIOStorageService 实例
@@ -42,6 +45,23 @@ func NewMinIOStorageService(cfg *config.MinIOConfig) (*MinIOStorageService, erro
 
 	logger.Info("MinIO 客户端和 Core 初始化成功", zap.String("endpoint", cfg.Endpoint))
 
+	regions, err := newRegionSelector(cfg.Regions)
+	if err != nil {
+		return nil, fmt.Errorf("无法初始化 MinIO 地域入口: %w", err)
+	}
+	regional := make(map[string]*minio.Client, len(cfg.Regions))
+	for _, region := range cfg.Regions {
+		client, err := minio.New(region.Endpoint, &minio.Options{
+			Creds:  opts.Creds,
+			Secure: cfg.UseSSL,
+			Region: region.SigningRegion,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("无法初始化 MinIO 地域 %s 的客户端: %w", region.Name, err)
+		}
+		regional[region.Name] = client
+	}
+
 	// 检查并创建存储桶，然后开启版本控制
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -65,9 +85,11 @@ func NewMinIOStorageService(cfg *config.MinIOConfig) (*MinIOStorageService, erro
 	logger.Info("MinIO 存储桶版本控制已开启", zap.String("bucketName", cfg.BucketName))
 
 	return &MinIOStorageService{
-		client: minioClient,
-		core:   minioCore,
-		cfg:    cfg,
+		client:   minioClient,
+		core:     minioCore,
+		regional: regional,
+		regions:  regions,
+		cfg:      cfg,
 	}, nil
 }
 
@@ -101,6 +123,10 @@ func (s *MinIOStorageService) GetObject(ctx context.Context, bucketName, objectN
 	// 获取对象信息，这里需要读取一部分才能获取到
 	objectStat, err := obj.Stat()
 	if err != nil {
+		if code := minio.ToErrorResponse(err).Code; code == "NoSuchKey" || code == "NoSuchVersion" {
+			obj.Close()
+			return GetObjectResult{}, fmt.Errorf("MinIO 获取文件失败: %w: %w", ErrObjectNotFound, err)
+		}
 		// 如果 Stat 失败，尝试返回基本信息，但可能不完整
 		logger.Warn("获取 MinIO 对象 stat 失败", zap.String("object", objectName), zap.Error(err))
 		return GetObjectResult{
@@ -199,14 +225,24 @@ func (s *MinIOStorageService) GetObjectURL(bucketName, objectName string) string
 	return fmt.Sprintf("%s/%s/%s", endpoint, bucketName, objectName)
 }
 
-// GeneratePresignedURL 为下载生成预签名URL
-func (s *MinIOStorageService) GeneratePresignedURL(ctx context.Context, bucketName, objectName, versionID string, expiry time.Duration) (string, error) {
+// GeneratePresignedURL 为下载生成预签名URL,配置了其他地域时使用离客户端最近的入口签名
+func (s *MinIOStorageService) GeneratePresignedURL(ctx context.Context, bucketName, objectName, versionID string, expiry time.Duration, opts PresignOptions) (string, error) {
 	reqParams := make(url.Values)
 	if versionID != "" {
 		reqParams.Set("versionId", versionID)
 	}
+	if opts.ContentDisposition != "" {
+		reqParams.Set("response-content-disposition", opts.ContentDisposition)
+	}
+	if opts.ContentType != "" {
+		reqParams.Set("response-content-type", opts.ContentType)
+	}
 
-	presignedURL, err := s.client.Presign(ctx, "GET", bucketName, objectName, expiry, reqParams)
+	client := s.client
+	if region := s.regions.Select(ctx); region != nil {
+		client = s.regional[region.Name]
+	}
+	presignedURL, err := client.Presign(ctx, "GET", bucketName, objectName, expiry, reqParams)
 	if err != nil {
 		return "", fmt.Errorf("生成 MinIO 预签名URL失败: %w", err)
 	}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+)
+
+type regionContextKey struct{}
+
+// WithDefaultRegion 预签名链接不是交给发起请求的客户端,而是由其他服务(如文档服务器)访问时,
+// 不按客户端的地域选择入口,使用默认入口
+func WithDefaultRegion(ctx context.Context) context.Context {
+	return context.WithValue(ctx, regionContextKey{}, true)
+}
+
+// regionSelector 为预签名下载链接选择离客户端最近的地域入口,没有配置地域时为 nil
+type regionSelector struct {
+	regions  []config.StorageRegionConfig
+	prefixes []regionPrefix
+}
+
+type regionPrefix struct {
+	prefix netip.Prefix
+	region int // regions 中的下标
+}
+
+// newRegionSelector 解析地域配置,regions 为空时返回 nil
+func newRegionSelector(regions []config.StorageRegionConfig) (*regionSelector, error) {
+	if len(regions) == 0 {
+		return nil, nil
+	}
+	s := &regionSelector{regions: regions}
+	for i, region := range regions {
+		for _, cidr := range region.CIDRs {
+			prefix, err := netip.ParsePrefix(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network %q in region %q: %w", cidr, region.Name, err)
+			}
+			s.prefixes = append(s.prefixes, regionPrefix{prefix: prefix.Masked(), region: i})
+		}
+	}
+	return s, nil
+}
+
+// Select 先按客户端声明的地域名称选择,再按客户端IP匹配前缀最长的网段,都不匹配时返回 nil,使用默认入口
+func (s *regionSelector) Select(ctx context.Context) *config.StorageRegionConfig {
+	if s == nil {
+		return nil
+	}
+	if skip, _ := ctx.Value(regionContextKey{}).(bool); skip {
+		return nil
+	}
+	if name := utils.ClientRegionFromContext(ctx); name != "" {
+		for i := range s.regions {
+			if strings.EqualFold(s.regions[i].Name, name) {
+				return &s.regions[i]
+			}
+		}
+	}
+
+	addr, err := netip.ParseAddr(utils.ClientIPFromContext(ctx))
+	if err != nil {
+		return nil
+	}
+	addr = addr.Unmap()
+	best := -1
+	for i, p := range s.prefixes {
+		if p.prefix.Contains(addr) && (best < 0 || p.prefix.Bits() > s.prefixes[best].prefix.Bits()) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+	return &s.regions[s.prefixes[best].region]
+}
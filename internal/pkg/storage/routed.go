@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+)
+
+// routedStorage 同时连接多个存储后端,按存储桶名称把操作交给对应的后端,
+// 未登记的存储桶和分片存储桶使用主后端。文件记录的 OssBucket 决定从哪个后端读取,迁移时只需更新存储桶即可切换
+type routedStorage struct {
+	primary  StorageService
+	byBucket map[string]StorageService
+}
+
+var _ StorageService = (*routedStorage)(nil)
+
+func newRoutedStorage(cfg *config.Config, primary StorageService) (StorageService, error) {
+	s := &routedStorage{
+		primary:  primary,
+		byBucket: map[string]StorageService{cfg.DefaultBucketName(): primary},
+	}
+	for _, bucket := range cfg.Storage.Sharding.Buckets {
+		s.byBucket[bucket] = primary
+	}
+	for _, storageType := range cfg.Storage.Backends {
+		if storageType == cfg.Storage.Type {
+			continue
+		}
+		bucket := cfg.BucketNameFor(storageType)
+		if _, ok := s.byBucket[bucket]; ok {
+			return nil, fmt.Errorf("storage backend %q uses bucket %q which is already used by another backend", storageType, bucket)
+		}
+		backend, err := newBackend(cfg, storageType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize storage backend %q: %w", storageType, err)
+		}
+		s.byBucket[bucket] = backend
+	}
+	return s, nil
+}
+
+func (s *routedStorage) backend(bucketName string) StorageService {
+	if backend, ok := s.byBucket[bucketName]; ok {
+		return backend
+	}
+	return s.primary
+}
+
+func (s *routedStorage) PutObject(ctx context.Context, bucketName, objectName string, reader io.Reader, objectSize int64, contentType string) (PutObjectResult, error) {
+	return s.backend(bucketName).PutObject(ctx, bucketName, objectName, reader, objectSize, contentType)
+}
+
+func (s *routedStorage) GetObject(ctx context.Context, bucketName, objectName, versionID string) (GetObjectResult, error) {
+	return s.backend(bucketName).GetObject(ctx, bucketName, objectName, versionID)
+}
+
+func (s *routedStorage) RemoveObject(ctx context.Context, bucketName, objectName, versionID string) error {
+	return s.backend(bucketName).RemoveObject(ctx, bucketName, objectName, versionID)
+}
+
+func (s *routedStorage) RemoveObjects(ctx context.Context, bucketName, objectName string) error {
+	return s.backend(bucketName).RemoveObjects(ctx, bucketName, objectName)
+}
+
+func (s *routedStorage) IsBucketExist(ctx context.Context, bucketName string) (bool, error) {
+	return s.backend(bucketName).IsBucketExist(ctx, bucketName)
+}
+
+func (s *routedStorage) MakeBucket(ctx context.Context, bucketName string) error {
+	return s.backend(bucketName).MakeBucket(ctx, bucketName)
+}
+
+func (s *routedStorage) GetObjectURL(bucketName, objectName string) string {
+	return s.backend(bucketName).GetObjectURL(bucketName, objectName)
+}
+
+func (s *routedStorage) GeneratePresignedURL(ctx context.Context, bucketName, objectName, versionID string, expiry time.Duration, opts PresignOptions) (string, error) {
+	return s.backend(bucketName).GeneratePresignedURL(ctx, bucketName, objectName, versionID, expiry, opts)
+}
+
+func (s *routedStorage) InitMultiPartUpload(ctx context.Context, bucketName, objectName string, opts PutObjectOptions) (string, error) {
+	return s.backend(bucketName).InitMultiPartUpload(ctx, bucketName, objectName, opts)
+}
+
+func (s *routedStorage) UploadPart(ctx context.Context, bucketName, objectName, uploadID string, reader io.Reader, partNumber int, partSize int64) (UploadPartResult, error) {
+	return s.backend(bucketName).UploadPart(ctx, bucketName, objectName, uploadID, reader, partNumber, partSize)
+}
+
+func (s *routedStorage) CompleteMultiPartUpload(ctx context.Context, bucketName, objectName, uploadID string, parts []UploadPartResult) (PutObjectResult, error) {
+	return s.backend(bucketName).CompleteMultiPartUpload(ctx, bucketName, objectName, uploadID, parts)
+}
+
+func (s *routedStorage) AbortMultiPartUpload(ctx context.Context, bucketName, objectName, uploadID string) error {
+	return s.backend(bucketName).AbortMultiPartUpload(ctx, bucketName, objectName, uploadID)
+}
+
+func (s *routedStorage) ListObjectParts(ctx context.Context, bucketName, objectName, uploadID string) ([]UploadPartResult, error) {
+	return s.backend(bucketName).ListObjectParts(ctx, bucketName, objectName, uploadID)
+}
+
+// GetUploadObjName 新对象总是写入主后端
+func (s *routedStorage) GetUploadObjName(fileHash, fileName string) string {
+	return s.primary.GetUploadObjName(fileHash, fileName)
+}
+
+func (s *routedStorage) IsUploadIDNotFound(err error) bool {
+	for _, backend := range s.byBucket {
+		if backend.IsUploadIDNotFound(err) {
+			return true
+		}
+	}
+	return false
+}
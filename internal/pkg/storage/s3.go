@@ -0,0 +1,452 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"go.uber.org/zap"
+)
+
+// S3 单次 DeleteObjects 请求最多删除 1000 个对象
+const s3MaxDeleteObjects = 1000
+
+// S3StorageService 基于 AWS SDK 的通用 S3 兼容存储实现
+type S3StorageService struct {
+	client  *s3.Client
+	presign *s3.PresignClient
+	regions *regionSelector  // 预签名下载链接可以使用的其他地域入口,未配置时为 nil
+	cfg     *config.S3Config // S3的配置信息
+}
+
+// NewS3StorageService 创建并返回一个 S3StorageService 实例
+func NewS3StorageService(cfg *config.S3Config) (*S3StorageService, error) {
+	if cfg.Region == "" {
+		return nil, errors.New("无法初始化 S3 客户端: region 不能为空")
+	}
+
+	opts := s3.Options{
+		Region:       cfg.Region,
+		Credentials:  aws.NewCredentialsCache(credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, "")),
+		UsePathStyle: cfg.UsePathStyle,
+		// 许多 S3 兼容服务不支持新版 SDK 默认附带的 CRC 校验头,只在必要时计算
+		RequestChecksumCalculation: aws.RequestChecksumCalculationWhenRequired,
+		ResponseChecksumValidation: aws.ResponseChecksumValidationWhenRequired,
+	}
+	if endpoint := s3EndpointURL(cfg.Endpoint, cfg.UseSSL); endpoint != "" {
+		opts.BaseEndpoint = aws.String(endpoint)
+	}
+	client := s3.New(opts)
+
+	regions, err := newRegionSelector(cfg.Regions)
+	if err != nil {
+		return nil, fmt.Errorf("无法初始化 S3 地域入口: %w", err)
+	}
+
+	logger.Info("S3 客户端初始化成功", zap.String("endpoint", cfg.Endpoint), zap.String("region", cfg.Region))
+
+	s := &S3StorageService{
+		client:  client,
+		presign: s3.NewPresignClient(client),
+		regions: regions,
+		cfg:     cfg,
+	}
+
+	// 检查并创建存储桶，然后尝试开启版本控制
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	exists, err := s.IsBucketExist(ctx, cfg.BucketName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := s.MakeBucket(ctx, cfg.BucketName); err != nil {
+			return nil, err
+		}
+	}
+
+	// 部分兼容服务不支持或默认开启版本控制,失败时只记录警告
+	_, err = client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket: aws.String(cfg.BucketName),
+		VersioningConfiguration: &types.VersioningConfiguration{
+			Status: types.BucketVersioningStatusEnabled,
+		},
+	})
+	if err != nil {
+		logger.Warn("开启 S3 存储桶版本控制失败", zap.String("bucketName", cfg.BucketName), zap.Error(err))
+	} else {
+		logger.Info("S3 存储桶版本控制已开启", zap.String("bucketName", cfg.BucketName))
+	}
+
+	return s, nil
+}
+
+// s3EndpointURL 补全 endpoint 的协议头,endpoint 为空时使用 AWS 默认地址
+func s3EndpointURL(endpoint string, useSSL bool) string {
+	if endpoint == "" {
+		return ""
+	}
+	if strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://") {
+		return endpoint
+	}
+	if useSSL {
+		return "https://" + endpoint
+	}
+	return "http://" + endpoint
+}
+
+func (s *S3StorageService) PutObject(ctx context.Context, bucketName, objectName string, reader io.Reader, objectSize int64, contentType string) (PutObjectResult, error) {
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(bucketName),
+		Key:           aws.String(objectName),
+		Body:          reader,
+		ContentLength: aws.Int64(objectSize),
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+
+	// reader 不一定可 Seek,无法预先计算载荷哈希,因此使用 UNSIGNED-PAYLOAD 签名
+	out, err := s.client.PutObject(ctx, input, s3.WithAPIOptions(v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware))
+	if err != nil {
+		return PutObjectResult{}, fmt.Errorf("S3 上传文件失败: %w", err)
+	}
+	return PutObjectResult{
+		Bucket:    bucketName,
+		Key:       objectName,
+		Size:      objectSize,
+		ETag:      strings.Trim(aws.ToString(out.ETag), `"`),
+		VersionID: aws.ToString(out.VersionId),
+	}, nil
+}
+
+func (s *S3StorageService) GetObject(ctx context.Context, bucketName, objectName, versionID string) (GetObjectResult, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+
+	out, err := s.client.GetObject(ctx, input)
+	if err != nil {
+		if s3ErrorCodeIs(err, "NoSuchKey", "NoSuchVersion") {
+			return GetObjectResult{}, fmt.Errorf("S3 获取文件失败: %w: %w", ErrObjectNotFound, err)
+		}
+		return GetObjectResult{}, fmt.Errorf("S3 获取文件失败: %w", err)
+	}
+
+	size := int64(-1) // 无法确定大小
+	if out.ContentLength != nil {
+		size = *out.ContentLength
+	}
+	return GetObjectResult{
+		Reader:   out.Body,
+		Size:     size,
+		MimeType: aws.ToString(out.ContentType),
+	}, nil
+}
+
+// 从指定存储桶删除指定版本文件
+func (s *S3StorageService) RemoveObject(ctx context.Context, bucketName, objectName, VersionID string) error {
+	input := &s3.DeleteObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+	}
+	if VersionID != "" {
+		input.VersionId = aws.String(VersionID)
+	}
+
+	if _, err := s.client.DeleteObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to remove object version: %w", err)
+	}
+	return nil
+}
+
+// 从指定存储桶删除所有版本文件
+func (s *S3StorageService) RemoveObjects(ctx context.Context, bucketName, objectName string) error {
+	var objects []types.ObjectIdentifier
+
+	// 列出对象的所有版本和删除标记
+	paginator := s3.NewListObjectVersionsPaginator(s.client, &s3.ListObjectVersionsInput{
+		Bucket: aws.String(bucketName),
+		Prefix: aws.String(objectName),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("S3 列出对象版本失败: %w", err)
+		}
+		// Prefix 会匹配到同前缀的其他对象,只保留完全相同的 Key
+		for _, version := range page.Versions {
+			if aws.ToString(version.Key) == objectName {
+				objects = append(objects, types.ObjectIdentifier{Key: version.Key, VersionId: version.VersionId})
+			}
+		}
+		for _, marker := range page.DeleteMarkers {
+			if aws.ToString(marker.Key) == objectName {
+				objects = append(objects, types.ObjectIdentifier{Key: marker.Key, VersionId: marker.VersionId})
+			}
+		}
+	}
+
+	// 分批删除所有版本的物理文件
+	for start := 0; start < len(objects); start += s3MaxDeleteObjects {
+		end := min(start+s3MaxDeleteObjects, len(objects))
+		out, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucketName),
+			Delete: &types.Delete{
+				Objects: objects[start:end],
+				Quiet:   aws.Bool(true),
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("S3 批量删除对象失败: %w", err)
+		}
+		for _, e := range out.Errors {
+			logger.Error("Failed to remove object",
+				zap.String("object", aws.ToString(e.Key)),
+				zap.String("version", aws.ToString(e.VersionId)),
+				zap.String("error", aws.ToString(e.Message)))
+		}
+	}
+
+	return nil
+}
+
+func (s *S3StorageService) IsBucketExist(ctx context.Context, bucketName string) (bool, error) {
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucketName)})
+	if err != nil {
+		if s3ErrorCodeIs(err, "NotFound", "NoSuchBucket") {
+			return false, nil
+		}
+		return false, fmt.Errorf("检查 S3 存储桶存在性失败: %w", err)
+	}
+	return true, nil
+}
+
+func (s *S3StorageService) MakeBucket(ctx context.Context, bucketName string) error {
+	input := &s3.CreateBucketInput{Bucket: aws.String(bucketName)}
+	// us-east-1 不允许指定 LocationConstraint
+	if s.cfg.Region != "us-east-1" {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(s.cfg.Region),
+		}
+	}
+
+	if _, err := s.client.CreateBucket(ctx, input); err != nil {
+		// 如果桶已存在，通常不是错误
+		if s3ErrorCodeIs(err, "BucketAlreadyOwnedByYou") {
+			logger.Info("S3 存储桶已存在，无需创建", zap.String("bucket", bucketName))
+			return nil
+		}
+		return fmt.Errorf("创建 S3 存储桶失败: %w", err)
+	}
+	logger.Info("S3 存储桶创建成功", zap.String("bucket", bucketName))
+	return nil
+}
+
+// GetObjectURL 获取对象的公开访问URL (如果桶是公开的)
+func (s *S3StorageService) GetObjectURL(bucketName, objectName string) string {
+	endpoint := s3EndpointURL(s.cfg.Endpoint, s.cfg.UseSSL)
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", s.cfg.Region)
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+
+	if s.cfg.UsePathStyle {
+		return fmt.Sprintf("%s/%s/%s", endpoint, bucketName, objectName)
+	}
+	// 虚拟主机风格: scheme://bucket.host/object
+	scheme, host, _ := strings.Cut(endpoint, "://")
+	return fmt.Sprintf("%s://%s.%s/%s", scheme, bucketName, host, objectName)
+}
+
+// GeneratePresignedURL 为下载生成预签名URL,配置了其他地域时使用离客户端最近的入口签名
+func (s *S3StorageService) GeneratePresignedURL(ctx context.Context, bucketName, objectName, versionID string, expiry time.Duration, opts PresignOptions) (string, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+	if opts.ContentDisposition != "" {
+		input.ResponseContentDisposition = aws.String(opts.ContentDisposition)
+	}
+	if opts.ContentType != "" {
+		input.ResponseContentType = aws.String(opts.ContentType)
+	}
+
+	presignOpts := []func(*s3.PresignOptions){s3.WithPresignExpires(expiry)}
+	if region := s.regions.Select(ctx); region != nil {
+		presignOpts = append(presignOpts, s3.WithPresignClientFromClientOptions(func(o *s3.Options) {
+			o.BaseEndpoint = aws.String(s3EndpointURL(region.Endpoint, s.cfg.UseSSL))
+			if region.SigningRegion != "" {
+				o.Region = region.SigningRegion
+			}
+		}))
+	}
+
+	req, err := s.presign.PresignGetObject(ctx, input, presignOpts...)
+	if err != nil {
+		return "", fmt.Errorf("生成 S3 预签名URL失败: %w", err)
+	}
+	return req.URL, nil
+}
+
+// --- 分块上传实现 ---
+
+func (s *S3StorageService) InitMultiPartUpload(ctx context.Context, bucketName, objectName string, opts PutObjectOptions) (string, error) {
+	input := &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+	}
+	if opts.ContentType != "" {
+		input.ContentType = aws.String(opts.ContentType)
+	}
+
+	out, err := s.client.CreateMultipartUpload(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("S3 初始化分块上传失败: %w", err)
+	}
+	return aws.ToString(out.UploadId), nil
+}
+
+func (s *S3StorageService) UploadPart(ctx context.Context, bucketName, objectName, uploadID string, reader io.Reader, partNumber int, partSize int64) (UploadPartResult, error) {
+	out, err := s.client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(bucketName),
+		Key:           aws.String(objectName),
+		UploadId:      aws.String(uploadID),
+		PartNumber:    aws.Int32(int32(partNumber)),
+		Body:          reader,
+		ContentLength: aws.Int64(partSize),
+	}, s3.WithAPIOptions(v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware))
+	if err != nil {
+		return UploadPartResult{}, fmt.Errorf("S3 上传分块失败: %w", err)
+	}
+	return UploadPartResult{
+		PartNumber: partNumber,
+		ETag:       strings.Trim(aws.ToString(out.ETag), `"`),
+	}, nil
+}
+
+func (s *S3StorageService) CompleteMultiPartUpload(ctx context.Context, bucketName, objectName, uploadID string, parts []UploadPartResult) (PutObjectResult, error) {
+	completedParts := make([]types.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completedParts = append(completedParts, types.CompletedPart{
+			PartNumber: aws.Int32(int32(part.PartNumber)),
+			ETag:       aws.String(part.ETag),
+		})
+	}
+
+	out, err := s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucketName),
+		Key:             aws.String(objectName),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completedParts},
+	})
+	if err != nil {
+		return PutObjectResult{}, fmt.Errorf("S3 完成分块上传失败: %w", err)
+	}
+
+	result := PutObjectResult{
+		Bucket:    bucketName,
+		Key:       objectName,
+		ETag:      strings.Trim(aws.ToString(out.ETag), `"`),
+		VersionID: aws.ToString(out.VersionId),
+	}
+
+	// CompleteMultipartUpload 不返回对象大小,合并后通过 HeadObject 获取
+	headInput := &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+	}
+	if result.VersionID != "" {
+		headInput.VersionId = aws.String(result.VersionID)
+	}
+	head, err := s.client.HeadObject(ctx, headInput)
+	if err != nil {
+		logger.Error("S3 HeadObject after complete failed", zap.Error(err), zap.String("objectName", objectName))
+		// 即使 head 失败，也返回已获得的信息，避免整个操作失败
+		return result, nil
+	}
+	if head.ContentLength != nil {
+		result.Size = *head.ContentLength
+	}
+	return result, nil
+}
+
+func (s *S3StorageService) AbortMultiPartUpload(ctx context.Context, bucketName, objectName, uploadID string) error {
+	_, err := s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String(objectName),
+		UploadId: aws.String(uploadID),
+	})
+	if err != nil {
+		return fmt.Errorf("S3 中止分块上传失败: %w", err)
+	}
+	return nil
+}
+
+func (s *S3StorageService) GetUploadObjName(fileHash, fileName string) string {
+	return fmt.Sprintf("uploads/%s", fileName)
+}
+
+func (s *S3StorageService) ListObjectParts(ctx context.Context, bucketName, objectName, uploadID string) ([]UploadPartResult, error) {
+	var parts []UploadPartResult
+
+	paginator := s3.NewListPartsPaginator(s.client, &s3.ListPartsInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String(objectName),
+		UploadId: aws.String(uploadID),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("S3 列出已上传分块失败: %w", err)
+		}
+		for _, part := range page.Parts {
+			parts = append(parts, UploadPartResult{
+				PartNumber: int(aws.ToInt32(part.PartNumber)),
+				ETag:       strings.Trim(aws.ToString(part.ETag), `"`),
+			})
+		}
+	}
+	return parts, nil
+}
+
+func (s *S3StorageService) IsUploadIDNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	// S3 在 upload ID 不存在时返回 "NoSuchUpload" 错误码
+	return s3ErrorCodeIs(err, "NoSuchUpload")
+}
+
+// s3ErrorCodeIs 检查错误是否为指定错误码的 S3 API 错误
+func s3ErrorCodeIs(err error, codes ...string) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, code := range codes {
+		if apiErr.ErrorCode() == code {
+			return true
+		}
+	}
+	return false
+}
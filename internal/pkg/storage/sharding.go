@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+)
+
+// 存储桶分片策略
+const (
+	ShardingStrategyUserHash = "user_hash" // 按用户ID哈希,同一用户的文件集中在一个存储桶
+	ShardingStrategyDate     = "date"      // 按月份轮换,每个存储桶的对象数随时间均匀增长
+)
+
+// BucketSelector 决定新对象写入主后端的哪个存储桶
+type BucketSelector interface {
+	// SelectBucket 返回 userID 在 at 时刻上传的对象应写入的存储桶
+	SelectBucket(userID uint64, at time.Time) string
+	// Buckets 返回可能被选中的全部存储桶
+	Buckets() []string
+}
+
+// NewBucketSelector 按 storageconfig.sharding 创建存储桶选择器,未配置分片时总是返回默认存储桶
+func NewBucketSelector(cfg *config.Config) (BucketSelector, error) {
+	sharding := cfg.Storage.Sharding
+	if sharding.Strategy == "" {
+		return &shardedBuckets{buckets: []string{cfg.DefaultBucketName()}}, nil
+	}
+	if len(sharding.Buckets) == 0 {
+		return nil, fmt.Errorf("storage sharding strategy %q requires at least one bucket", sharding.Strategy)
+	}
+	seen := make(map[string]bool, len(sharding.Buckets))
+	for _, bucket := range sharding.Buckets {
+		if bucket == "" || seen[bucket] {
+			return nil, fmt.Errorf("storage sharding bucket %q is empty or duplicated", bucket)
+		}
+		seen[bucket] = true
+	}
+
+	s := &shardedBuckets{buckets: sharding.Buckets}
+	switch sharding.Strategy {
+	case ShardingStrategyUserHash:
+		s.shard = func(userID uint64, _ time.Time) uint64 {
+			h := fnv.New64a()
+			h.Write([]byte(strconv.FormatUint(userID, 10)))
+			return h.Sum64()
+		}
+	case ShardingStrategyDate:
+		s.shard = func(_ uint64, at time.Time) uint64 {
+			return uint64(at.Year())*12 + uint64(at.Month()) - 1
+		}
+	default:
+		return nil, fmt.Errorf("unknown storage sharding strategy %q", sharding.Strategy)
+	}
+	return s, nil
+}
+
+type shardedBuckets struct {
+	buckets []string
+	shard   func(userID uint64, at time.Time) uint64
+}
+
+func (s *shardedBuckets) SelectBucket(userID uint64, at time.Time) string {
+	if s.shard == nil {
+		return s.buckets[0]
+	}
+	return s.buckets[s.shard(userID, at)%uint64(len(s.buckets))]
+}
+
+func (s *shardedBuckets) Buckets() []string {
+	return s.buckets
+}
+
+// EnsureBuckets 创建不存在的存储桶
+func EnsureBuckets(ctx context.Context, svc StorageService, buckets []string) error {
+	for _, bucket := range buckets {
+		exists, err := svc.IsBucketExist(ctx, bucket)
+		if err != nil {
+			return fmt.Errorf("failed to check bucket %s: %w", bucket, err)
+		}
+		if exists {
+			continue
+		}
+		if err := svc.MakeBucket(ctx, bucket); err != nil {
+			return fmt.Errorf("failed to create bucket %s: %w", bucket, err)
+		}
+	}
+	return nil
+}
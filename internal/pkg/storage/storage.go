@@ -25,8 +25,8 @@ type StorageService interface {
 	MakeBucket(ctx context.Context, bucketName string) error
 	// 获取对象的公开访问URL（如果支持）
 	GetObjectURL(bucketName, objectName string) string
-	// GeneratePresignedURL 为下载生成预签名URL
-	GeneratePresignedURL(ctx context.Context, bucketName, objectName, versionID string, expiry time.Duration) (string, error)
+	// GeneratePresignedURL 为下载生成预签名URL,opts 中非空的字段覆盖下载时的响应头
+	GeneratePresignedURL(ctx context.Context, bucketName, objectName, versionID string, expiry time.Duration, opts PresignOptions) (string, error)
 
 	// --- 分块上传方法 ---
 
@@ -52,6 +52,9 @@ type StorageService interface {
 	IsUploadIDNotFound(err error) bool
 }
 
+// ErrObjectNotFound 对象或指定的版本不存在,GetObject 返回的错误包装了它
+var ErrObjectNotFound = errors.New("storage: object not found")
+
 type PutObjectResult struct {
 	Bucket    string
 	Key       string
@@ -60,6 +63,15 @@ type PutObjectResult struct {
 	VersionID string // 版本 ID
 }
 
+// PresignOptions 预签名下载链接的响应头覆盖,为空时使用对象本身的元数据
+type PresignOptions struct {
+	ContentDisposition string
+	ContentType        string
+	// UserID、ShareID 下载流量计入的用户和分享链接,本地存储的签名链接据此限速,其他后端忽略
+	UserID  uint64
+	ShareID uint64
+}
+
 type PutObjectOptions struct {
 	ContentType string
 	// 可根据需要添加其他选项，如用户元数据等
@@ -77,12 +89,31 @@ type GetObjectResult struct {
 	// 可以添加其他元数据，如文件名等
 }
 
+// NewStorageService 按配置创建存储后端,并记录每次调用的耗时指标
 func NewStorageService(cfg *config.Config) (StorageService, error) {
-	switch cfg.Storage.Type {
+	backend, err := newBackend(cfg, cfg.Storage.Type)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Storage.Backends) > 0 || len(cfg.Storage.Sharding.Buckets) > 0 {
+		backend, err = newRoutedStorage(cfg, backend)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return newInstrumentedStorage(backend), nil
+}
+
+func newBackend(cfg *config.Config, storageType string) (StorageService, error) {
+	switch storageType {
 	case "minio":
 		return NewMinIOStorageService(&cfg.MinIO)
 	case "aliyun_oss":
 		return NewAliyunOSSStorageService(&cfg.AliyunOSS)
+	case "s3":
+		return NewS3StorageService(&cfg.S3)
+	case "local":
+		return NewLocalStorageService(cfg.Storage.LocalBasePath, &cfg.Local)
 	default:
 		return nil, errors.New("invalid storageType")
 	}
@@ -0,0 +1,189 @@
+// Package textextract 从文档中提取纯文本,用于全文索引。纯文本和 docx 使用内置实现,
+// PDF 以及内置实现无法处理的文件交给 Apache Tika
+package textextract
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrUnsupported 文件类型不支持提取文本
+var ErrUnsupported = errors.New("unsupported document type")
+
+// Kind 可以提取文本的文档类型
+type Kind string
+
+const (
+	KindNone Kind = ""
+	KindText Kind = "text"
+	KindDocx Kind = "docx"
+	KindPDF  Kind = "pdf"
+)
+
+const docxMimeType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+
+// textMimeTypes text/ 之外按纯文本处理的类型
+var textMimeTypes = map[string]bool{
+	"application/json":       true,
+	"application/xml":        true,
+	"application/javascript": true,
+	"application/x-yaml":     true,
+	"application/yaml":       true,
+}
+
+// DetectKind 按 MIME 类型和扩展名判断文档类型。上传时内容检测会把 docx 识别为 application/zip,因此同时参考扩展名
+func DetectKind(fileName, mimeType string) Kind {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(mimeType))
+	}
+	ext := strings.ToLower(filepath.Ext(fileName))
+	switch {
+	case mediaType == docxMimeType || ext == ".docx":
+		return KindDocx
+	case mediaType == "application/pdf" || ext == ".pdf":
+		return KindPDF
+	case strings.HasPrefix(mediaType, "text/") || textMimeTypes[mediaType]:
+		return KindText
+	default:
+		return KindNone
+	}
+}
+
+// Extractor 提取文档文本,结果最多 maxSize 字节
+type Extractor struct {
+	tika    *TikaClient // 为 nil 时不提取 PDF,内置实现失败时也不再重试
+	maxSize int
+}
+
+// NewExtractor 创建提取器,tikaURL 为空时不使用 Tika
+func NewExtractor(tikaURL string, maxSize int) *Extractor {
+	e := &Extractor{maxSize: maxSize}
+	if tikaURL != "" {
+		e.tika = NewTikaClient(tikaURL)
+	}
+	return e
+}
+
+// Supports 判断是否能提取该类型的文档
+func (e *Extractor) Supports(kind Kind) bool {
+	switch kind {
+	case KindText, KindDocx:
+		return true
+	case KindPDF:
+		return e.tika != nil
+	default:
+		return false
+	}
+}
+
+// Extract 读取 r 的全部内容并提取文本。内置实现失败时,配置了 Tika 则交给 Tika 再试一次
+func (e *Extractor) Extract(ctx context.Context, r io.Reader, kind Kind) (string, error) {
+	if !e.Supports(kind) {
+		return "", ErrUnsupported
+	}
+	if kind == KindText {
+		return e.truncate(readText(r, e.maxSize)), nil
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to read document: %w", err)
+	}
+	var builtinErr error
+	if kind == KindDocx {
+		text, err := extractDocx(data, e.maxSize)
+		if err == nil {
+			return e.truncate(text), nil
+		}
+		builtinErr = err
+	}
+	if e.tika == nil {
+		return "", builtinErr
+	}
+	text, err := e.tika.Extract(ctx, bytes.NewReader(data), e.maxSize)
+	if err != nil {
+		return "", errors.Join(builtinErr, err)
+	}
+	return e.truncate(text), nil
+}
+
+// truncate 截断到 maxSize 字节以内,不截断在多字节字符中间
+func (e *Extractor) truncate(text string) string {
+	text = strings.ToValidUTF8(text, "")
+	if e.maxSize <= 0 || len(text) <= e.maxSize {
+		return text
+	}
+	cut := e.maxSize
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut]
+}
+
+// readText 读取纯文本,最多读取 maxSize 字节
+func readText(r io.Reader, maxSize int) string {
+	if maxSize > 0 {
+		r = io.LimitReader(r, int64(maxSize))
+	}
+	data, _ := io.ReadAll(r)
+	return string(data)
+}
+
+// extractDocx 读取 word/document.xml 中的文字,段落之间用换行分隔
+func extractDocx(data []byte, maxSize int) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("failed to open docx: %w", err)
+	}
+	doc, err := zr.Open("word/document.xml")
+	if err != nil {
+		return "", fmt.Errorf("failed to open docx body: %w", err)
+	}
+	defer doc.Close()
+
+	var b strings.Builder
+	decoder := xml.NewDecoder(doc)
+	inText := false
+	for maxSize <= 0 || b.Len() < maxSize {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse docx body: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				b.WriteByte('\t')
+			case "br":
+				b.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				b.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(t)
+			}
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,42 @@
+package textextract
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// TikaClient 调用 Apache Tika Server 的 /tika 接口提取纯文本
+type TikaClient struct {
+	url    string
+	client *http.Client
+}
+
+// NewTikaClient 创建 Tika 客户端,超时由调用方的 ctx 控制
+func NewTikaClient(url string) *TikaClient {
+	return &TikaClient{
+		url:    strings.TrimRight(url, "/"),
+		client: &http.Client{},
+	}
+}
+
+// Extract 上传文档并返回提取的纯文本,最多读取 maxSize 字节
+func (c *TikaClient) Extract(ctx context.Context, r io.Reader, maxSize int) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.url+"/tika", r)
+	if err != nil {
+		return "", fmt.Errorf("failed to create tika request: %w", err)
+	}
+	req.Header.Set("Accept", "text/plain; charset=UTF-8")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to call tika: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("tika returned status %d", resp.StatusCode)
+	}
+	return readText(resp.Body, maxSize), nil
+}
@@ -1,16 +1,29 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// TokenPurposeTwoFactor 密码验证通过、等待两步验证的中间 Token,不能用于访问其他接口
+const TokenPurposeTwoFactor = "2fa_pending"
+
+// TokenPurposeOfficeCallback 文档服务器保存回调地址中的 Token,只能用于在线编辑回调
+const TokenPurposeOfficeCallback = "office_callback"
+
 type Claims struct {
 	UserID   uint64 `json:"user_id"`
 	Username string `json:"username"`
 	Email    string `json:"email"`
+	// TwoFactor 登录时是否通过了两步验证
+	TwoFactor bool `json:"2fa,omitempty"`
+	// Purpose 为空表示普通登录 Token
+	Purpose string `json:"purpose,omitempty"`
+	// SessionID 签发该 Token 的登录会话,会话撤销后 Token 立即失效
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -19,12 +32,16 @@ type Claims struct {
 // secretKey: 用于签名的密钥
 // expiresIn: Token 的过期时间（分钟）
 // issuer: Token 的签发者
-func GenerateToken(userID uint64, username, email, secretKey, issuer string, expiresIn time.Duration) (string, error) {
+// twoFactor: 登录时是否通过了两步验证
+// sessionID: 所属的登录会话
+func GenerateToken(userID uint64, username, email, secretKey, issuer string, expiresIn time.Duration, twoFactor bool, sessionID string) (string, error) {
 	expirationTime := time.Now().Add(expiresIn * time.Minute)
 	claims := &Claims{
-		UserID:   userID,
-		Username: username,
-		Email:    email,
+		UserID:    userID,
+		Username:  username,
+		Email:     email,
+		TwoFactor: twoFactor,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -44,3 +61,44 @@ func GenerateToken(userID uint64, username, email, secretKey, issuer string, exp
 
 	return tokenString, nil
 }
+
+// GenerateTwoFactorToken 生成等待两步验证的中间 Token,expiresIn 为有效时长
+func GenerateTwoFactorToken(userID uint64, secretKey, issuer string, expiresIn time.Duration) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		UserID:  userID,
+		Purpose: TokenPurposeTwoFactor,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    issuer,
+			Subject:   fmt.Sprintf("%d", userID),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(secretKey))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return tokenString, nil
+}
+
+// ParseToken 校验签名和有效期并返回 Claims
+func ParseToken(tokenString, secretKey string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (any, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(secretKey), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
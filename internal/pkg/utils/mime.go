@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SniffLen 内容类型检测需要的最大字节数
+const SniffLen = 512
+
+// 可执行文件的魔数,http.DetectContentType 无法识别这些格式
+var executableSignatures = []struct {
+	magic    []byte
+	mimeType string
+}{
+	{[]byte("MZ"), "application/x-msdownload"},                // Windows PE
+	{[]byte("\x7fELF"), "application/x-executable"},           // Linux ELF
+	{[]byte("\xfe\xed\xfa\xce"), "application/x-mach-binary"}, // Mach-O 32 位
+	{[]byte("\xfe\xed\xfa\xcf"), "application/x-mach-binary"}, // Mach-O 64 位
+	{[]byte("\xce\xfa\xed\xfe"), "application/x-mach-binary"},
+	{[]byte("\xcf\xfa\xed\xfe"), "application/x-mach-binary"},
+	{[]byte("#!"), "text/x-shellscript"},
+}
+
+// inlineTextTypes 可以在浏览器中直接显示的纯文本类型。text/html、text/xml 等会被浏览器解析执行,不在其中
+var inlineTextTypes = []string{"text/plain", "text/csv", "text/markdown"}
+
+// IsInlineSafe 判断 MIME 类型能否以 inline 方式在浏览器中打开。
+// 只允许 PDF、图片、音频、视频和纯文本,SVG 可以包含脚本,按附件处理
+func IsInlineSafe(mimeType string) bool {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "application/pdf":
+		return true
+	case mediaType == "image/svg+xml":
+		return false
+	case strings.HasPrefix(mediaType, "image/"), strings.HasPrefix(mediaType, "audio/"), strings.HasPrefix(mediaType, "video/"):
+		return true
+	}
+	for _, textType := range inlineTextTypes {
+		if mediaType == textType {
+			return true
+		}
+	}
+	return false
+}
+
+// ContentDisposition 生成下载响应的 Content-Disposition。
+// 客户端请求 inline 且类型在白名单中时在浏览器中打开,其余情况一律作为附件下载,避免上传的 HTML 在站点域名下执行
+func ContentDisposition(fileName, mimeType string, inline bool) string {
+	dispositionType := "attachment"
+	if inline && IsInlineSafe(mimeType) {
+		dispositionType = "inline"
+	}
+	encodedFileName := url.PathEscape(fileName)
+	return fmt.Sprintf(`%s; filename="%s"; filename*=UTF-8''%s`, dispositionType, encodedFileName, encodedFileName)
+}
+
+// DetectContentType 根据文件开头的内容检测 MIME 类型,
+// 在 http.DetectContentType 的基础上补充了常见可执行文件格式的识别
+func DetectContentType(head []byte) string {
+	if len(head) > SniffLen {
+		head = head[:SniffLen]
+	}
+	for _, sig := range executableSignatures {
+		if bytes.HasPrefix(head, sig.magic) {
+			return sig.mimeType
+		}
+	}
+	return http.DetectContentType(head)
+}
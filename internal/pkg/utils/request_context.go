@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type requestContextKey int
+
+const (
+	clientIPKey requestContextKey = iota
+	actorIDKey
+	primaryReadKey
+	routeKey
+	clientRegionKey
+)
+
+// RouteInfo 处理请求的路由模板和 handler 名称
+type RouteInfo struct {
+	Route   string
+	Handler string
+}
+
+// WithClientIP 将客户端IP写入 context，供 service 层记录审计日志使用
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey, ip)
+}
+
+// ClientIPFromContext 从 context 中读取客户端IP，不存在时返回空字符串
+func ClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey).(string)
+	return ip
+}
+
+// WithClientRegion 将客户端声明的所在地域写入 context,供存储层选择最近的访问入口
+func WithClientRegion(ctx context.Context, region string) context.Context {
+	return context.WithValue(ctx, clientRegionKey, region)
+}
+
+// ClientRegionFromContext 从 context 中读取客户端声明的地域，不存在时返回空字符串
+func ClientRegionFromContext(ctx context.Context) string {
+	region, _ := ctx.Value(clientRegionKey).(string)
+	return region
+}
+
+// WithActorID 将当前登录用户ID写入 context
+func WithActorID(ctx context.Context, userID uint64) context.Context {
+	return context.WithValue(ctx, actorIDKey, userID)
+}
+
+// ActorIDFromContext 从 context 中读取当前登录用户ID，匿名请求返回 false
+func ActorIDFromContext(ctx context.Context) (uint64, bool) {
+	userID, ok := ctx.Value(actorIDKey).(uint64)
+	return userID, ok
+}
+
+// WithRoute 将路由模板和 handler 名称写入 context,供慢查询日志定位发起查询的接口
+func WithRoute(ctx context.Context, route, handler string) context.Context {
+	return context.WithValue(ctx, routeKey, RouteInfo{Route: route, Handler: handler})
+}
+
+// RouteFromContext 从 context 中读取路由信息,后台任务等非请求上下文返回 false
+func RouteFromContext(ctx context.Context) (RouteInfo, bool) {
+	info, ok := ctx.Value(routeKey).(RouteInfo)
+	return info, ok
+}
+
+// WithPrimaryRead 为请求创建主库读取标记,force 为 true 时整个请求都从主库读取。
+// 配置了 MySQL 从库时,请求写入主库之后的读取也改为从主库读取,避免主从延迟读到旧数据
+func WithPrimaryRead(ctx context.Context, force bool) context.Context {
+	flag := new(atomic.Bool)
+	flag.Store(force)
+	return context.WithValue(ctx, primaryReadKey, flag)
+}
+
+// MarkPrimaryWrite 记录请求已经写入主库,ctx 没有主库读取标记时不做任何事
+func MarkPrimaryWrite(ctx context.Context) {
+	if flag, ok := ctx.Value(primaryReadKey).(*atomic.Bool); ok {
+		flag.Store(true)
+	}
+}
+
+// ReadFromPrimary 返回当前请求的查询是否需要从主库读取
+func ReadFromPrimary(ctx context.Context) bool {
+	flag, ok := ctx.Value(primaryReadKey).(*atomic.Bool)
+	return ok && flag.Load()
+}
@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// throttledWriter 按令牌桶速率写入数据的 Writer
+type throttledWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	limiter *rate.Limiter
+}
+
+// NewThrottledWriter 返回一个限速 Writer,bytesPerSecond <= 0 时直接返回原 Writer
+func NewThrottledWriter(ctx context.Context, w io.Writer, bytesPerSecond int64) io.Writer {
+	if bytesPerSecond <= 0 {
+		return w
+	}
+	return ThrottleWriter(ctx, w, newBandwidthLimiter(bytesPerSecond))
+}
+
+// ThrottleWriter 使用给定的令牌桶限速,多个 Writer 共享同一个令牌桶时共享带宽。limiter 为 nil 时直接返回原 Writer
+func ThrottleWriter(ctx context.Context, w io.Writer, limiter *rate.Limiter) io.Writer {
+	if limiter == nil {
+		return w
+	}
+	return &throttledWriter{ctx: ctx, w: w, limiter: limiter}
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		// 单次等待的字节数不能超过令牌桶容量
+		n := min(len(p)-written, t.limiter.Burst())
+		if err := t.limiter.WaitN(t.ctx, n); err != nil {
+			return written, err
+		}
+		m, err := t.w.Write(p[written : written+n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// newBandwidthLimiter 令牌桶容量为一秒的流量
+func newBandwidthLimiter(bytesPerSecond int64) *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), int(bytesPerSecond))
+}
+
+// limiterIdleTimeout 超过该时间未使用的令牌桶会被回收
+const limiterIdleTimeout = 10 * time.Minute
+
+// LimiterRegistry 按键(如用户ID、分享ID)保存共享的令牌桶,同一个键的并发下载共享带宽上限
+type LimiterRegistry struct {
+	mu        sync.Mutex
+	limiters  map[string]*registeredLimiter
+	lastSweep time.Time
+}
+
+type registeredLimiter struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+func NewLimiterRegistry() *LimiterRegistry {
+	return &LimiterRegistry{limiters: make(map[string]*registeredLimiter), lastSweep: time.Now()}
+}
+
+// Get 返回 key 对应的令牌桶,速率变化时原地调整。bytesPerSecond <= 0 表示不限速,返回 nil
+func (r *LimiterRegistry) Get(key string, bytesPerSecond int64) *rate.Limiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.sweep(now)
+
+	if bytesPerSecond <= 0 {
+		delete(r.limiters, key)
+		return nil
+	}
+
+	entry, ok := r.limiters[key]
+	if !ok {
+		entry = &registeredLimiter{limiter: newBandwidthLimiter(bytesPerSecond)}
+		r.limiters[key] = entry
+	} else if entry.limiter.Limit() != rate.Limit(bytesPerSecond) {
+		entry.limiter.SetLimitAt(now, rate.Limit(bytesPerSecond))
+		entry.limiter.SetBurstAt(now, int(bytesPerSecond))
+	}
+	entry.lastUsed = now
+	return entry.limiter
+}
+
+// sweep 回收长时间未使用的令牌桶,调用方需持有锁
+func (r *LimiterRegistry) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < time.Minute {
+		return
+	}
+	r.lastSweep = now
+	for key, entry := range r.limiters {
+		if now.Sub(entry.lastUsed) > limiterIdleTimeout {
+			delete(r.limiters, key)
+		}
+	}
+}
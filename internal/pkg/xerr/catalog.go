@@ -0,0 +1,243 @@
+package xerr
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Entry 错误码目录中的一项,通过 GET /api/v1/errors 导出给客户端开发者对照
+type Entry struct {
+	Code       int    `json:"code"`
+	HTTPStatus int    `json:"http_status"`
+	Name       string `json:"name"`    // 稳定的英文标识,客户端可以据此做本地化
+	Message    string `json:"message"` // 返回给客户端的默认说明,不包含内部细节
+}
+
+// catalog 全部错误码,新增错误码时需要同步添加
+var catalog = []Entry{
+	{InvalidParamsCode, http.StatusBadRequest, "invalid_params", "Invalid request parameters"},
+	{ValidationFailedCode, http.StatusBadRequest, "validation_failed", "Parameter validation failed"},
+	{MethodNotAllowedCode, http.StatusMethodNotAllowed, "method_not_allowed", "HTTP method not allowed"},
+	{FileTooLargeCode, http.StatusRequestEntityTooLarge, "file_too_large", "File is too large"},
+	{FileNameInvalidCode, http.StatusBadRequest, "file_name_invalid", "File name contains invalid characters"},
+	{FileStatusInvalidCode, http.StatusBadRequest, "file_status_invalid", "File is in a state that does not allow this operation"},
+	{CannotMoveRootCode, http.StatusBadRequest, "cannot_move_root", "The root folder cannot be moved"},
+	{CannotMoveIntoSubtreeCode, http.StatusBadRequest, "cannot_move_into_subtree", "A folder cannot be moved into its own subfolder"},
+	{TargetNotFolderCode, http.StatusBadRequest, "target_not_folder", "The target is not a folder"},
+	{CannotDownloadFolderCode, http.StatusBadRequest, "cannot_download_folder", "Folders must be downloaded with the folder download endpoint"},
+	{ChunkMissingCode, http.StatusBadRequest, "chunk_missing", "Some upload chunks are missing, upload them again"},
+	{HashMismatchCode, http.StatusBadRequest, "hash_mismatch", "File hash does not match the uploaded content"},
+	{PreviewNotSupportedCode, http.StatusUnsupportedMediaType, "preview_not_supported", "Preview is not supported for this file type"},
+	{ChunkSizeInvalidCode, http.StatusBadRequest, "chunk_size_invalid", "Chunk size does not match the negotiated upload strategy"},
+	{FileTypeNotAllowedCode, http.StatusUnsupportedMediaType, "file_type_not_allowed", "This file type is not allowed"},
+	{QuotaExceededCode, http.StatusRequestEntityTooLarge, "quota_exceeded", "Not enough storage space"},
+	{FileNameTooLongCode, http.StatusBadRequest, "file_name_too_long", "File name is too long"},
+	{FileNameReservedCode, http.StatusBadRequest, "file_name_reserved", "File name is reserved by the operating system"},
+	{TagInvalidCode, http.StatusBadRequest, "tag_invalid", "Tag is empty, too long or contains invalid characters"},
+	{TooManyTagsCode, http.StatusBadRequest, "too_many_tags", "Too many tags on this file"},
+	{EmailTokenInvalidCode, http.StatusBadRequest, "email_token_invalid", "The link is invalid or has expired"},
+	{TwoFactorNotEnabledCode, http.StatusBadRequest, "two_factor_not_enabled", "Two-factor authentication is not enabled or enrollment has not been started"},
+	{OfficeNotSupportedCode, http.StatusUnsupportedMediaType, "office_not_supported", "Online editing is not available for this file"},
+	{UploadProofRejectedCode, http.StatusUnprocessableEntity, "upload_proof_rejected", "Instant upload is not available, upload the file content instead"},
+	{OAuthClientInvalidCode, http.StatusBadRequest, "oauth_client_invalid", "Client authentication failed or the redirect URI is not registered"},
+	{OAuthScopeInvalidCode, http.StatusBadRequest, "oauth_scope_invalid", "The requested scope is invalid"},
+	{OAuthGrantInvalidCode, http.StatusBadRequest, "oauth_grant_invalid", "The authorization code or refresh token is invalid or expired"},
+	{AttributeInvalidCode, http.StatusBadRequest, "attribute_invalid", "Custom attribute name or value is invalid, or there are too many attributes"},
+	{LifecycleRuleInvalidCode, http.StatusBadRequest, "lifecycle_rule_invalid", "Lifecycle rule is invalid"},
+	{UnsupportedArchiveCode, http.StatusBadRequest, "unsupported_archive", "Archive format is not supported"},
+	{ContentSearchDisabledCode, http.StatusBadRequest, "content_search_disabled", "Content search is not enabled"},
+
+	{UnauthorizedCode, http.StatusUnauthorized, "unauthorized", "Authentication is required"},
+	{TokenInvalidCode, http.StatusUnauthorized, "token_invalid", "Token is invalid or expired"},
+	{InvalidCredentialsCode, http.StatusUnauthorized, "invalid_credentials", "Incorrect username or password"},
+	{TwoFactorCodeInvalidCode, http.StatusUnauthorized, "two_factor_code_invalid", "The authentication code or recovery code is incorrect"},
+
+	{ForbiddenCode, http.StatusForbidden, "forbidden", "Access is forbidden"},
+	{PermissionDeniedCode, http.StatusForbidden, "permission_denied", "You do not have permission to access this resource"},
+	{SharePasswordRequiredCode, http.StatusForbidden, "share_password_required", "This share requires a password"},
+	{SharePasswordIncorrectCode, http.StatusForbidden, "share_password_incorrect", "Incorrect share password"},
+	{ShareRefererDeniedCode, http.StatusForbidden, "share_referer_denied", "This share cannot be embedded on the referring page"},
+	{FileQuarantinedCode, http.StatusForbidden, "file_quarantined", "File was flagged by the virus scanner and quarantined"},
+	{InsufficientScopeCode, http.StatusForbidden, "insufficient_scope", "The access token scope does not allow this operation"},
+	{EmailNotVerifiedCode, http.StatusForbidden, "email_not_verified", "Verify your email address before using this feature"},
+	{SignedURLInvalidCode, http.StatusForbidden, "signed_url_invalid", "The download link is invalid or has expired"},
+	{AccountDeletionPendingCode, http.StatusForbidden, "account_deletion_pending", "Account is scheduled for deletion"},
+
+	{NotFoundCode, http.StatusNotFound, "not_found", "Resource not found"},
+	{UserNotFoundCode, http.StatusNotFound, "user_not_found", "User not found"},
+	{FileNotFoundCode, http.StatusNotFound, "file_not_found", "File not found"},
+	{DirectoryNotFoundCode, http.StatusNotFound, "directory_not_found", "Folder not found"},
+	{ShareNotFoundCode, http.StatusNotFound, "share_not_found", "Share link not found or expired"},
+	{FileNotInRecycleBinCode, http.StatusNotFound, "file_not_in_recycle_bin", "File is not in the recycle bin"},
+	{UploadSessionNotFoundCode, http.StatusNotFound, "upload_session_not_found", "Upload session not found or expired"},
+	{FileVersionNotFoundCode, http.StatusNotFound, "file_version_not_found", "File version not found"},
+	{PermissionNotFoundCode, http.StatusNotFound, "permission_not_found", "Permission grant not found"},
+	{AccessTokenNotFoundCode, http.StatusNotFound, "access_token_not_found", "Access token not found"},
+	{ExportNotFoundCode, http.StatusNotFound, "export_not_found", "Export not found or expired"},
+	{PurgeJobNotFoundCode, http.StatusNotFound, "purge_job_not_found", "Purge job not found"},
+	{SessionNotFoundCode, http.StatusNotFound, "session_not_found", "Session not found or expired"},
+	{DeadLetterNotFoundCode, http.StatusNotFound, "dead_letter_not_found", "Dead letter not found"},
+	{CommentNotFoundCode, http.StatusNotFound, "comment_not_found", "Comment not found"},
+	{ArchiveNotFoundCode, http.StatusNotFound, "archive_not_found", "Archive not found or expired"},
+	{MigrationNotFoundCode, http.StatusNotFound, "migration_not_found", "Storage migration not found"},
+	{OAuthClientNotFoundCode, http.StatusNotFound, "oauth_client_not_found", "OAuth application or authorization not found"},
+	{LifecycleRuleNotFoundCode, http.StatusNotFound, "lifecycle_rule_not_found", "Lifecycle rule not found"},
+	{LinkTargetMissingCode, http.StatusNotFound, "link_target_missing", "Shortcut target no longer exists"},
+	{OrgNotFoundCode, http.StatusNotFound, "org_not_found", "Organization not found"},
+	{OrgMemberNotFoundCode, http.StatusNotFound, "org_member_not_found", "Organization member not found"},
+	{ExtractJobNotFoundCode, http.StatusNotFound, "extract_job_not_found", "Extract job not found"},
+	{AccountDeletionNotFoundCode, http.StatusNotFound, "account_deletion_not_found", "Account deletion not found"},
+
+	{UserAlreadyExistsCode, http.StatusConflict, "user_already_exists", "Username is already taken"},
+	{EmailAlreadyExistsCode, http.StatusConflict, "email_already_exists", "Email is already registered"},
+	{DirNotEmptyCode, http.StatusConflict, "dir_not_empty", "Folder is not empty"},
+	{ShareAlreadyExistsCode, http.StatusConflict, "share_already_exists", "An active share link already exists for this file"},
+	{FileAlreadyExistsCode, http.StatusConflict, "file_already_exists", "A file or folder with this name already exists"},
+	{FileLockedCode, http.StatusConflict, "file_locked", "File is locked by another user"},
+	{ExportInProgressCode, http.StatusConflict, "export_in_progress", "An export is already in progress"},
+	{VersionConflictCode, http.StatusConflict, "version_conflict", "The file was modified by another request, reload it and try again"},
+	{EmailAlreadyVerifiedCode, http.StatusConflict, "email_already_verified", "The email address is already verified"},
+	{TwoFactorAlreadyEnabledCode, http.StatusConflict, "two_factor_already_enabled", "Two-factor authentication is already enabled"},
+	{MigrationInProgressCode, http.StatusConflict, "migration_in_progress", "A storage migration is already in progress"},
+	{LastOrgOwnerCode, http.StatusConflict, "last_org_owner", "An organization must keep at least one owner"},
+	{AccountDeletionInProgressCode, http.StatusConflict, "account_deletion_in_progress", "Account deletion is still in progress"},
+	{FileCorruptedCode, http.StatusConflict, "file_corrupted", "The stored content still does not match the recorded hash"},
+	{LifecycleRuleRunningCode, http.StatusConflict, "lifecycle_rule_running", "The lifecycle rule is already running"},
+
+	{TooManyRequestsCode, http.StatusTooManyRequests, "too_many_requests", "Too many requests, try again later"},
+	{SharePasswordLockedCode, http.StatusTooManyRequests, "share_password_locked", "Too many incorrect passwords, this share is temporarily locked"},
+	{TwoFactorLockedCode, http.StatusTooManyRequests, "two_factor_locked", "Too many incorrect authentication codes, try again later"},
+
+	{InternalServerErrorCode, http.StatusInternalServerError, "internal_error", "Internal server error"},
+	{DatabaseErrorCode, http.StatusInternalServerError, "database_error", "Database operation failed"},
+	{StorageErrorCode, http.StatusInternalServerError, "storage_error", "Storage service operation failed"},
+	{MQErrorCode, http.StatusInternalServerError, "mq_error", "Message queue operation failed"},
+}
+
+// errorCodes 哨兵错误对应的错误码,FromError 按顺序匹配
+var errorCodes = []struct {
+	err  error
+	code int
+}{
+	{ErrInvalidParams, InvalidParamsCode},
+	{ErrValidationFailed, ValidationFailedCode},
+	{ErrFileTooLarge, FileTooLargeCode},
+	{ErrFileNameInvalid, FileNameInvalidCode},
+	{ErrFileStatusInvalid, FileStatusInvalidCode},
+	{ErrCannotMoveRoot, CannotMoveRootCode},
+	{ErrCannotMoveIntoSubtree, CannotMoveIntoSubtreeCode},
+	{ErrTargetNotFolder, TargetNotFolderCode},
+	{ErrCannotDownloadFolder, CannotDownloadFolderCode},
+	{ErrChunkMissing, ChunkMissingCode},
+	{ErrHashMismatch, HashMismatchCode},
+	{ErrPreviewNotSupported, PreviewNotSupportedCode},
+	{ErrChunkSizeInvalid, ChunkSizeInvalidCode},
+	{ErrFileTypeNotAllowed, FileTypeNotAllowedCode},
+	{ErrQuotaExceeded, QuotaExceededCode},
+	{ErrFileNameTooLong, FileNameTooLongCode},
+	{ErrFileNameReserved, FileNameReservedCode},
+	{ErrTagInvalid, TagInvalidCode},
+	{ErrTooManyTags, TooManyTagsCode},
+	{ErrEmailTokenInvalid, EmailTokenInvalidCode},
+	{ErrTwoFactorNotEnabled, TwoFactorNotEnabledCode},
+	{ErrOfficeNotSupported, OfficeNotSupportedCode},
+	{ErrUploadProofRejected, UploadProofRejectedCode},
+	{ErrOAuthClientInvalid, OAuthClientInvalidCode},
+	{ErrOAuthScopeInvalid, OAuthScopeInvalidCode},
+	{ErrOAuthGrantInvalid, OAuthGrantInvalidCode},
+	{ErrAttributeInvalid, AttributeInvalidCode},
+	{ErrLifecycleRuleInvalid, LifecycleRuleInvalidCode},
+	{ErrUnsupportedArchive, UnsupportedArchiveCode},
+	{ErrContentSearchDisabled, ContentSearchDisabledCode},
+	{ErrUnauthorized, UnauthorizedCode},
+	{ErrTokenInvalid, TokenInvalidCode},
+	{ErrInvalidCredentials, InvalidCredentialsCode},
+	{ErrTwoFactorCodeInvalid, TwoFactorCodeInvalidCode},
+	{ErrUserAlreadyExists, UserAlreadyExistsCode},
+	{ErrEmailAlreadyExists, EmailAlreadyExistsCode},
+	{ErrForbidden, ForbiddenCode},
+	{ErrPermissionDenied, PermissionDeniedCode},
+	{ErrSharePasswordRequired, SharePasswordRequiredCode},
+	{ErrSharePasswordIncorrect, SharePasswordIncorrectCode},
+	{ErrSharePasswordLocked, SharePasswordLockedCode},
+	{ErrTwoFactorLocked, TwoFactorLockedCode},
+	{ErrShareRefererDenied, ShareRefererDeniedCode},
+	{ErrFileQuarantined, FileQuarantinedCode},
+	{ErrInsufficientScope, InsufficientScopeCode},
+	{ErrEmailNotVerified, EmailNotVerifiedCode},
+	{ErrSignedURLInvalid, SignedURLInvalidCode},
+	{ErrAccountDeletionPending, AccountDeletionPendingCode},
+	{ErrUserNotFound, UserNotFoundCode},
+	{ErrFileNotFound, FileNotFoundCode},
+	{ErrDirectoryNotFound, DirectoryNotFoundCode},
+	{ErrShareNotFound, ShareNotFoundCode},
+	{ErrFileNotInRecycleBin, FileNotInRecycleBinCode},
+	{ErrUploadSessionNotFound, UploadSessionNotFoundCode},
+	{ErrFileVersionNotFound, FileVersionNotFoundCode},
+	{ErrPermissionNotFound, PermissionNotFoundCode},
+	{ErrAccessTokenNotFound, AccessTokenNotFoundCode},
+	{ErrExportNotFound, ExportNotFoundCode},
+	{ErrPurgeJobNotFound, PurgeJobNotFoundCode},
+	{ErrSessionNotFound, SessionNotFoundCode},
+	{ErrDeadLetterNotFound, DeadLetterNotFoundCode},
+	{ErrCommentNotFound, CommentNotFoundCode},
+	{ErrArchiveNotFound, ArchiveNotFoundCode},
+	{ErrMigrationNotFound, MigrationNotFoundCode},
+	{ErrOAuthClientNotFound, OAuthClientNotFoundCode},
+	{ErrLifecycleRuleNotFound, LifecycleRuleNotFoundCode},
+	{ErrLinkTargetMissing, LinkTargetMissingCode},
+	{ErrOrgNotFound, OrgNotFoundCode},
+	{ErrOrgMemberNotFound, OrgMemberNotFoundCode},
+	{ErrExtractJobNotFound, ExtractJobNotFoundCode},
+	{ErrAccountDeletionNotFound, AccountDeletionNotFoundCode},
+	{ErrDirNotEmpty, DirNotEmptyCode},
+	{ErrShareAlreadyExists, ShareAlreadyExistsCode},
+	{ErrFileAlreadyExists, FileAlreadyExistsCode},
+	{ErrFileLocked, FileLockedCode},
+	{ErrExportInProgress, ExportInProgressCode},
+	{ErrVersionConflict, VersionConflictCode},
+	{ErrEmailAlreadyVerified, EmailAlreadyVerifiedCode},
+	{ErrTwoFactorAlreadyEnabled, TwoFactorAlreadyEnabledCode},
+	{ErrMigrationInProgress, MigrationInProgressCode},
+	{ErrLastOrgOwner, LastOrgOwnerCode},
+	{ErrAccountDeletionInProgress, AccountDeletionInProgressCode},
+	{ErrFileCorrupted, FileCorruptedCode},
+	{ErrLifecycleRuleRunning, LifecycleRuleRunningCode},
+	{ErrDatabaseError, DatabaseErrorCode},
+	{ErrStorageError, StorageErrorCode},
+	{ErrMQError, MQErrorCode},
+	{ErrInternalServer, InternalServerErrorCode},
+}
+
+// Catalog 返回全部错误码
+func Catalog() []Entry {
+	entries := make([]Entry, len(catalog))
+	copy(entries, catalog)
+	return entries
+}
+
+// Lookup 按错误码查找目录项
+func Lookup(code int) (Entry, bool) {
+	for _, entry := range catalog {
+		if entry.Code == code {
+			return entry, true
+		}
+	}
+	return Entry{}, false
+}
+
+// FromError 将服务层返回的错误映射为目录项,*CodeError 优先使用其携带的错误码
+func FromError(err error) (Entry, bool) {
+	var codeErr *CodeError
+	if errors.As(err, &codeErr) {
+		if entry, ok := Lookup(codeErr.Code); ok {
+			return entry, true
+		}
+	}
+	for _, mapping := range errorCodes {
+		if errors.Is(err, mapping.err) {
+			return Lookup(mapping.code)
+		}
+	}
+	return Entry{}, false
+}
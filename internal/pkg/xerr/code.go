@@ -17,34 +17,91 @@ const (
 	CannotDownloadFolderCode  = 40010 // 无法使用文件下载接口下载文件夹
 	ChunkMissingCode          = 40011 // 上传分片丢失
 	HashMismatchCode          = 40012 // 文件Hash不匹配
+	PreviewNotSupportedCode   = 40013 // 文件类型不支持预览
+	ChunkSizeInvalidCode      = 40014 // 分片大小与协商的上传策略不符
+	FileTypeNotAllowedCode    = 40015 // 文件类型不允许上传
+	QuotaExceededCode         = 40016 // 存储空间不足
+	FileNameTooLongCode       = 40017 // 文件名过长
+	FileNameReservedCode      = 40018 // 文件名为系统保留名称
+	TagInvalidCode            = 40019 // 标签无效
+	TooManyTagsCode           = 40020 // 标签数量超过上限
+	EmailTokenInvalidCode     = 40021 // 邮箱验证或密码重置链接无效或已过期
+	TwoFactorNotEnabledCode   = 40022 // 未开启或未开始设置两步验证
+	OfficeNotSupportedCode    = 40023 // 文件类型不支持在线编辑或未开启在线编辑
+	UploadProofRejectedCode   = 40024 // 秒传内容证明未通过
+	OAuthClientInvalidCode    = 40025 // 第三方应用认证失败或回调地址未登记
+	OAuthScopeInvalidCode     = 40026 // 申请的权限范围无效
+	OAuthGrantInvalidCode     = 40027 // 授权码或刷新令牌无效、已使用或已过期
+	AttributeInvalidCode      = 40028 // 文件自定义属性无效
+	LifecycleRuleInvalidCode  = 40029 // 生命周期规则无效
+	UnsupportedArchiveCode    = 40030 // 不支持的压缩包格式
+	ContentSearchDisabledCode = 40031 // 未开启文档内容搜索
 
 	// --- 认证与授权错误系列 (401xx) ---
-	UnauthorizedCode       = 40100 // 通用未授权
-	TokenInvalidCode       = 40101 // Token 无效或过期
-	InvalidCredentialsCode = 40102 // 用户名或密码错误
+	UnauthorizedCode         = 40100 // 通用未授权
+	TokenInvalidCode         = 40101 // Token 无效或过期
+	InvalidCredentialsCode   = 40102 // 用户名或密码错误
+	TwoFactorCodeInvalidCode = 40103 // 两步验证码或恢复码不正确
 
 	// --- 权限错误系列 (403xx) ---
 	ForbiddenCode              = 40300 // 通用无权限
 	PermissionDeniedCode       = 40301 // 权限不足 (细分)
 	SharePasswordRequiredCode  = 40302 // 分享需要密码
 	SharePasswordIncorrectCode = 40303 // 分享密码不正确
+	ShareRefererDeniedCode     = 40304 // 直链分享来源不被允许
+	FileQuarantinedCode        = 40305 // 文件检测到病毒已被隔离
+	InsufficientScopeCode      = 40306 // 访问令牌的权限范围不足
+	EmailNotVerifiedCode       = 40307 // 邮箱未验证
+	SignedURLInvalidCode       = 40308 // 签名下载链接无效或已过期
+	AccountDeletionPendingCode = 40309 // 账号已申请注销
 
 	// --- 资源未找到错误系列 (404xx) ---
-	NotFoundCode              = 40400 // 通用资源未找到
-	UserNotFoundCode          = 40401 // 用户不存在
-	FileNotFoundCode          = 40402 // 文件不存在
-	DirectoryNotFoundCode     = 40403 // 目录不存在
-	ShareNotFoundCode         = 40404 // 分享链接不存在
-	FileNotInRecycleBinCode   = 40405 // 文件不在回收站中
-	UploadSessionNotFoundCode = 40406 // 上传会话不存在
-	FileVersionNotFoundCode   = 40407 //版本记录不存在
+	NotFoundCode                = 40400 // 通用资源未找到
+	UserNotFoundCode            = 40401 // 用户不存在
+	FileNotFoundCode            = 40402 // 文件不存在
+	DirectoryNotFoundCode       = 40403 // 目录不存在
+	ShareNotFoundCode           = 40404 // 分享链接不存在
+	FileNotInRecycleBinCode     = 40405 // 文件不在回收站中
+	UploadSessionNotFoundCode   = 40406 // 上传会话不存在
+	FileVersionNotFoundCode     = 40407 //版本记录不存在
+	PermissionNotFoundCode      = 40408 // 协作授权不存在
+	AccessTokenNotFoundCode     = 40409 // 访问令牌不存在
+	ExportNotFoundCode          = 40410 // 导出任务不存在
+	PurgeJobNotFoundCode        = 40411 // 彻底删除任务不存在
+	SessionNotFoundCode         = 40412 // 登录会话不存在
+	DeadLetterNotFoundCode      = 40413 // 死信消息不存在
+	CommentNotFoundCode         = 40414 // 评论不存在
+	ArchiveNotFoundCode         = 40415 // 打包任务不存在或已过期
+	MigrationNotFoundCode       = 40416 // 存储迁移任务不存在
+	OAuthClientNotFoundCode     = 40417 // 第三方应用或授权不存在
+	LifecycleRuleNotFoundCode   = 40418 // 生命周期规则不存在
+	LinkTargetMissingCode       = 40419 // 快捷方式指向的文件已不存在
+	OrgNotFoundCode             = 40420 // 组织不存在
+	OrgMemberNotFoundCode       = 40421 // 组织成员不存在
+	ExtractJobNotFoundCode      = 40422 // 解压任务不存在
+	AccountDeletionNotFoundCode = 40423 // 注销任务不存在
 
 	// --- 业务逻辑冲突系列 (409xx) ---
-	UserAlreadyExistsCode  = 40900 // 用户名已存在
-	EmailAlreadyExistsCode = 40901 // 邮箱已存在
-	DirNotEmptyCode        = 40902 // 目录不为空，无法删除
-	ShareAlreadyExistsCode = 40903 // 分享链接已存在
-	FileAlreadyExistsCode  = 40904 // 文件或目录已存在
+	UserAlreadyExistsCode         = 40900 // 用户名已存在
+	EmailAlreadyExistsCode        = 40901 // 邮箱已存在
+	DirNotEmptyCode               = 40902 // 目录不为空，无法删除
+	ShareAlreadyExistsCode        = 40903 // 分享链接已存在
+	FileAlreadyExistsCode         = 40904 // 文件或目录已存在
+	FileLockedCode                = 40905 // 文件已被其他用户锁定
+	ExportInProgressCode          = 40906 // 已有正在进行的导出任务
+	VersionConflictCode           = 40907 // 文件已被其他请求修改
+	EmailAlreadyVerifiedCode      = 40908 // 邮箱已验证
+	TwoFactorAlreadyEnabledCode   = 40909 // 两步验证已开启
+	MigrationInProgressCode       = 40910 // 已有正在进行的存储迁移任务
+	LastOrgOwnerCode              = 40911 // 组织至少需要保留一名所有者
+	AccountDeletionInProgressCode = 40912 // 注销任务正在进行
+	FileCorruptedCode             = 40913 // 文件内容仍与记录的哈希不一致
+	LifecycleRuleRunningCode      = 40914 // 生命周期规则正在执行
+
+	// --- 限流错误系列 (429xx) ---
+	TooManyRequestsCode     = 42900 // 请求过于频繁
+	SharePasswordLockedCode = 42901 // 分享密码错误次数过多,暂时锁定
+	TwoFactorLockedCode     = 42902 // 两步验证码错误次数过多,暂时锁定
 
 	// --- 服务器内部错误系列 (500xx) ---
 	InternalServerErrorCode = 50000 // 服务器内部通用错误
@@ -19,36 +19,90 @@ var (
 	ErrCannotDownloadFolder  = errors.New("无法下载文件夹，请使用文件夹下载接口")
 	ErrChunkMissing          = errors.New("部分上传分片丢失，请重新上传")
 	ErrHashMismatch          = errors.New("文件哈希值校验失败")
+	ErrPreviewNotSupported   = errors.New("该文件类型不支持预览")
+	ErrChunkSizeInvalid      = errors.New("分片大小与协商的上传策略不符")
+	ErrFileTypeNotAllowed    = errors.New("不允许上传该类型的文件")
+	ErrQuotaExceeded         = errors.New("存储空间不足")
+	ErrFileNameTooLong       = errors.New("文件名过长")
+	ErrFileNameReserved      = errors.New("文件名为系统保留名称")
+	ErrTagInvalid            = errors.New("标签无效")
+	ErrTooManyTags           = errors.New("标签数量超过上限")
+	ErrEmailTokenInvalid     = errors.New("链接无效或已过期")
+	ErrTwoFactorNotEnabled   = errors.New("未开启两步验证")
+	ErrOfficeNotSupported    = errors.New("该文件不支持在线编辑")
+	ErrUploadProofRejected   = errors.New("秒传校验未通过,请上传文件内容")
+	ErrOAuthClientInvalid    = errors.New("第三方应用认证失败")
+	ErrOAuthScopeInvalid     = errors.New("申请的权限范围无效")
+	ErrOAuthGrantInvalid     = errors.New("授权码或刷新令牌无效或已过期")
+	ErrAttributeInvalid      = errors.New("文件自定义属性无效")
+	ErrLifecycleRuleInvalid  = errors.New("生命周期规则无效")
+	ErrUnsupportedArchive    = errors.New("不支持的压缩包格式")
+	ErrContentSearchDisabled = errors.New("未开启文档内容搜索")
 
 	// 认证与授权错误
-	ErrUnauthorized       = errors.New("用户未授权")
-	ErrTokenInvalid       = errors.New("认证 Token 无效或已过期")
-	ErrInvalidCredentials = errors.New("用户名或密码不正确")
-	ErrUserAlreadyExists  = errors.New("该用户名已被注册")
-	ErrEmailAlreadyExists = errors.New("邮箱已被注册")
+	ErrUnauthorized         = errors.New("用户未授权")
+	ErrTokenInvalid         = errors.New("认证 Token 无效或已过期")
+	ErrInvalidCredentials   = errors.New("用户名或密码不正确")
+	ErrUserAlreadyExists    = errors.New("该用户名已被注册")
+	ErrEmailAlreadyExists   = errors.New("邮箱已被注册")
+	ErrTwoFactorCodeInvalid = errors.New("两步验证码不正确")
+	ErrTwoFactorLocked      = errors.New("两步验证码错误次数过多,请稍后再试")
 
 	// 权限错误
 	ErrForbidden              = errors.New("禁止访问")
 	ErrPermissionDenied       = errors.New("您没有操作此资源的权限")
 	ErrSharePasswordRequired  = errors.New("分享链接需要密码")
 	ErrSharePasswordIncorrect = errors.New("分享链接密码不正确")
+	ErrSharePasswordLocked    = errors.New("分享密码错误次数过多,请稍后再试")
+	ErrShareRefererDenied     = errors.New("不允许在该来源页面引用此分享链接")
+	ErrFileQuarantined        = errors.New("文件检测到病毒，已被隔离")
+	ErrInsufficientScope      = errors.New("访问令牌的权限范围不足")
+	ErrEmailNotVerified       = errors.New("邮箱未验证")
+	ErrSignedURLInvalid       = errors.New("下载链接无效或已过期")
+	ErrAccountDeletionPending = errors.New("账号已申请注销")
 
 	// 缓存错误系列(402xx)
 	ErrEmptyCache = errors.New("缓存为空")
 
 	// 资源未找到错误
-	ErrUserNotFound          = errors.New("用户不存在")
-	ErrFileNotFound          = errors.New("文件不存在")
-	ErrDirectoryNotFound     = errors.New("目录不存在")
-	ErrShareNotFound         = errors.New("分享链接不存在或已过期")
-	ErrFileNotInRecycleBin   = errors.New("文件不在回收站中")
-	ErrUploadSessionNotFound = errors.New("上传会话不存在或已过期")
-	ErrFileVersionNotFound   = errors.New("文件版本号不存在")
+	ErrUserNotFound            = errors.New("用户不存在")
+	ErrFileNotFound            = errors.New("文件不存在")
+	ErrDirectoryNotFound       = errors.New("目录不存在")
+	ErrShareNotFound           = errors.New("分享链接不存在或已过期")
+	ErrFileNotInRecycleBin     = errors.New("文件不在回收站中")
+	ErrUploadSessionNotFound   = errors.New("上传会话不存在或已过期")
+	ErrFileVersionNotFound     = errors.New("文件版本号不存在")
+	ErrPermissionNotFound      = errors.New("协作授权不存在")
+	ErrAccessTokenNotFound     = errors.New("访问令牌不存在")
+	ErrExportNotFound          = errors.New("导出任务不存在或已过期")
+	ErrPurgeJobNotFound        = errors.New("彻底删除任务不存在")
+	ErrSessionNotFound         = errors.New("登录会话不存在或已过期")
+	ErrDeadLetterNotFound      = errors.New("死信消息不存在")
+	ErrCommentNotFound         = errors.New("评论不存在")
+	ErrArchiveNotFound         = errors.New("打包任务不存在或已过期")
+	ErrMigrationNotFound       = errors.New("存储迁移任务不存在")
+	ErrOAuthClientNotFound     = errors.New("第三方应用或授权不存在")
+	ErrLifecycleRuleNotFound   = errors.New("生命周期规则不存在")
+	ErrLinkTargetMissing       = errors.New("快捷方式指向的文件已不存在")
+	ErrOrgNotFound             = errors.New("组织不存在")
+	ErrOrgMemberNotFound       = errors.New("组织成员不存在")
+	ErrExtractJobNotFound      = errors.New("解压任务不存在")
+	ErrAccountDeletionNotFound = errors.New("注销任务不存在")
 
 	// 业务逻辑冲突
-	ErrDirNotEmpty        = errors.New("目录不为空，无法删除")
-	ErrShareAlreadyExists = errors.New("该文件已存在有效的分享链接")
-	ErrFileAlreadyExists  = errors.New("文件或目录已存在")
+	ErrDirNotEmpty               = errors.New("目录不为空，无法删除")
+	ErrShareAlreadyExists        = errors.New("该文件已存在有效的分享链接")
+	ErrFileAlreadyExists         = errors.New("文件或目录已存在")
+	ErrFileLocked                = errors.New("文件已被其他用户锁定")
+	ErrExportInProgress          = errors.New("已有正在进行的导出任务")
+	ErrVersionConflict           = errors.New("文件已被其他请求修改，请刷新后重试")
+	ErrEmailAlreadyVerified      = errors.New("邮箱已验证")
+	ErrTwoFactorAlreadyEnabled   = errors.New("两步验证已开启")
+	ErrMigrationInProgress       = errors.New("已有正在进行的存储迁移任务")
+	ErrLastOrgOwner              = errors.New("组织至少需要保留一名所有者")
+	ErrAccountDeletionInProgress = errors.New("注销任务正在进行")
+	ErrFileCorrupted             = errors.New("文件内容与记录的哈希不一致")
+	ErrLifecycleRuleRunning      = errors.New("生命周期规则正在执行")
 
 	// 数据库与外部服务错误
 	ErrDatabaseError = errors.New("数据库操作失败")
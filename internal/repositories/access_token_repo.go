@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"gorm.io/gorm"
+)
+
+// AccessTokenRepository 定义了个人访问令牌的数据库操作接口
+type AccessTokenRepository interface {
+	Create(ctx context.Context, token *models.PersonalAccessToken) error
+	FindByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error)
+	FindByUserID(ctx context.Context, userID uint64) ([]models.PersonalAccessToken, error)
+	// Delete 删除用户的令牌,返回删除的记录数
+	Delete(ctx context.Context, userID, tokenID uint64) (int64, error)
+	UpdateLastUsed(ctx context.Context, tokenID uint64, usedAt time.Time) error
+}
+
+type accessTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewAccessTokenRepository 创建新的 accessTokenRepository 实例
+func NewAccessTokenRepository(db *gorm.DB) AccessTokenRepository {
+	return &accessTokenRepository{db: db}
+}
+
+func (r *accessTokenRepository) Create(ctx context.Context, token *models.PersonalAccessToken) error {
+	return writeDB(ctx, r.db).Create(token).Error
+}
+
+func (r *accessTokenRepository) FindByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	err := readDB(ctx, r.db).Where("token_hash = ?", tokenHash).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("access token repository: %w", xerr.ErrAccessTokenNotFound)
+		}
+		return nil, fmt.Errorf("access token repository: failed to find token: %w", err)
+	}
+	return &token, nil
+}
+
+func (r *accessTokenRepository) FindByUserID(ctx context.Context, userID uint64) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	err := readDB(ctx, r.db).Where("user_id = ?", userID).Order("created_at desc").Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *accessTokenRepository) Delete(ctx context.Context, userID, tokenID uint64) (int64, error) {
+	result := writeDB(ctx, r.db).Where("id = ? AND user_id = ?", tokenID, userID).Delete(&models.PersonalAccessToken{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *accessTokenRepository) UpdateLastUsed(ctx context.Context, tokenID uint64, usedAt time.Time) error {
+	return writeDB(ctx, r.db).Model(&models.PersonalAccessToken{}).Where("id = ?", tokenID).Update("last_used_at", usedAt).Error
+}
@@ -0,0 +1,168 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"gorm.io/gorm"
+)
+
+// AccountDeletionRepository 定义了账号注销任务和注销时批量清除用户数据的数据库操作接口
+type AccountDeletionRepository interface {
+	Create(ctx context.Context, deletion *models.AccountDeletion) error
+	// FindByID 不存在时返回 xerr.ErrAccountDeletionNotFound
+	FindByID(ctx context.Context, id uint64) (*models.AccountDeletion, error)
+	// FindUnfinishedByUserID 查询用户未完成的注销任务,不存在时返回 nil
+	FindUnfinishedByUserID(ctx context.Context, userID uint64) (*models.AccountDeletion, error)
+	// List 按创建时间倒序列出注销任务,status 为空时不按状态过滤
+	List(ctx context.Context, status string, limit int) ([]models.AccountDeletion, error)
+	Update(ctx context.Context, deletion *models.AccountDeletion) error
+	// RevokeTokens 删除用户的个人访问令牌和第三方应用令牌,与标记注销在同一事务中执行
+	RevokeTokens(ctx context.Context, userID uint64) error
+	// RevokeAccess 删除用户的令牌、分享、协作授权、收藏、标签和组织成员关系,
+	// 以及其他用户对该用户文件的分享、收藏、授权和评论,使之后可以直接删除文件记录
+	RevokeAccess(ctx context.Context, userID uint64) error
+	// Anonymize 清除活动日志中的 IP 和详情,删除评论和通知,改写用户名和邮箱并软删除账号
+	Anonymize(ctx context.Context, userID uint64) error
+	WithTx(tx *gorm.DB) AccountDeletionRepository
+}
+
+type accountDeletionRepository struct {
+	db *gorm.DB
+}
+
+// NewAccountDeletionRepository 创建新的 accountDeletionRepository 实例
+func NewAccountDeletionRepository(db *gorm.DB) AccountDeletionRepository {
+	return &accountDeletionRepository{db: db}
+}
+
+func (r *accountDeletionRepository) Create(ctx context.Context, deletion *models.AccountDeletion) error {
+	return writeDB(ctx, r.db).Create(deletion).Error
+}
+
+func (r *accountDeletionRepository) FindByID(ctx context.Context, id uint64) (*models.AccountDeletion, error) {
+	var deletion models.AccountDeletion
+	if err := readDB(ctx, r.db).First(&deletion, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("account deletion repository: %w", xerr.ErrAccountDeletionNotFound)
+		}
+		return nil, fmt.Errorf("account deletion repository: failed to find deletion: %w", err)
+	}
+	return &deletion, nil
+}
+
+func (r *accountDeletionRepository) FindUnfinishedByUserID(ctx context.Context, userID uint64) (*models.AccountDeletion, error) {
+	var deletion models.AccountDeletion
+	err := writeDB(ctx, r.db).Where("user_id = ? AND status <> ?", userID, models.AccountDeletionCompleted).
+		Order("id desc").First(&deletion).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &deletion, nil
+}
+
+func (r *accountDeletionRepository) List(ctx context.Context, status string, limit int) ([]models.AccountDeletion, error) {
+	var deletions []models.AccountDeletion
+	query := readDB(ctx, r.db)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("created_at desc, id desc").Limit(limit).Find(&deletions).Error
+	return deletions, err
+}
+
+func (r *accountDeletionRepository) Update(ctx context.Context, deletion *models.AccountDeletion) error {
+	return writeDB(ctx, r.db).Model(deletion).
+		Select("Email", "Status", "Step", "PurgedFiles", "Error", "CompletedAt").Updates(deletion).Error
+}
+
+func (r *accountDeletionRepository) RevokeTokens(ctx context.Context, userID uint64) error {
+	db := writeDB(ctx, r.db)
+	if err := db.Where("user_id = ?", userID).Delete(&models.PersonalAccessToken{}).Error; err != nil {
+		return fmt.Errorf("account deletion repository: failed to delete access tokens: %w", err)
+	}
+	if err := db.Where("user_id = ?", userID).Delete(&models.OAuthToken{}).Error; err != nil {
+		return fmt.Errorf("account deletion repository: failed to delete oauth tokens: %w", err)
+	}
+	return nil
+}
+
+func (r *accountDeletionRepository) RevokeAccess(ctx context.Context, userID uint64) error {
+	return writeDB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		ownFiles := tx.Model(&models.File{}).Unscoped().Select("id").Where("user_id = ?", userID)
+		deletes := []struct {
+			model any
+			query string
+			args  []any
+		}{
+			{&models.PersonalAccessToken{}, "user_id = ?", []any{userID}},
+			{&models.OAuthToken{}, "user_id = ?", []any{userID}},
+			{&models.OAuthToken{}, "client_id IN (?)", []any{tx.Model(&models.OAuthClient{}).Select("client_id").Where("owner_id = ?", userID)}},
+			{&models.OAuthClient{}, "owner_id = ?", []any{userID}},
+			{&models.TwoFactorRecoveryCode{}, "user_id = ?", []any{userID}},
+			{&models.Share{}, "user_id = ? OR recipient_id = ? OR file_id IN (?)", []any{userID, userID, ownFiles}},
+			{&models.FilePermission{}, "owner_id = ? OR grantee_id = ? OR file_id IN (?)", []any{userID, userID, ownFiles}},
+			{&models.FileFavorite{}, "user_id = ? OR file_id IN (?)", []any{userID, ownFiles}},
+			{&models.FileTag{}, "user_id = ? OR file_id IN (?)", []any{userID, ownFiles}},
+			{&models.FileComment{}, "file_id IN (?)", []any{ownFiles}},
+			{&models.LifecycleRule{}, "user_id = ?", []any{userID}},
+			{&models.OrganizationMember{}, "user_id = ?", []any{userID}},
+		}
+		for _, d := range deletes {
+			if err := tx.Unscoped().Where(d.query, d.args...).Delete(d.model).Error; err != nil {
+				return fmt.Errorf("failed to delete %T: %w", d.model, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (r *accountDeletionRepository) Anonymize(ctx context.Context, userID uint64) error {
+	return writeDB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		// 活动日志保留操作类型和时间用于审计,去掉能识别到个人的 IP 和文件名等详情
+		err := tx.Model(&models.Activity{}).Where("user_id = ?", userID).
+			Updates(map[string]any{"ip": "", "detail": ""}).Error
+		if err != nil {
+			return fmt.Errorf("failed to anonymize activities: %w", err)
+		}
+		err = tx.Model(&models.Activity{}).Where("actor_id = ?", userID).
+			Updates(map[string]any{"actor_id": nil, "ip": ""}).Error
+		if err != nil {
+			return fmt.Errorf("failed to anonymize actor activities: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.FileComment{}).Error; err != nil {
+			return fmt.Errorf("failed to delete comments: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.Notification{}).Error; err != nil {
+			return fmt.Errorf("failed to delete notifications: %w", err)
+		}
+
+		// 用户名和邮箱有唯一索引,改写为按ID生成的占位值,原值可以重新注册
+		placeholder := fmt.Sprintf("deleted-%d", userID)
+		err = tx.Unscoped().Model(&models.User{}).Where("id = ?", userID).Updates(map[string]any{
+			"username":              placeholder,
+			"email":                 placeholder,
+			"password_hash":         "",
+			"totp_secret":           "",
+			"two_factor_enabled_at": nil,
+			"email_verified_at":     nil,
+			"status":                models.UserStatusDeleted,
+			"deleted_at":            gorm.Expr("COALESCE(deleted_at, ?)", time.Now()),
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to anonymize user: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *accountDeletionRepository) WithTx(tx *gorm.DB) AccountDeletionRepository {
+	return NewAccountDeletionRepository(tx)
+}
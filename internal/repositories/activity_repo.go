@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"gorm.io/gorm"
+)
+
+// ActivityRepository 定义了活动日志的数据库操作接口
+type ActivityRepository interface {
+	Create(ctx context.Context, activity *models.Activity) error
+	FindByFileID(ctx context.Context, userID, fileID uint64, page, pageSize int) ([]models.Activity, int64, error)
+	FindByUserID(ctx context.Context, userID uint64, page, pageSize int) ([]models.Activity, int64, error)
+}
+
+type activityRepository struct {
+	db *gorm.DB
+}
+
+// NewActivityRepository 创建新的 activityRepository 实例
+func NewActivityRepository(db *gorm.DB) ActivityRepository {
+	return &activityRepository{db: db}
+}
+
+func (r *activityRepository) Create(ctx context.Context, activity *models.Activity) error {
+	return writeDB(ctx, r.db).Create(activity).Error
+}
+
+// 查找指定用户某个文件的活动记录,按 user_id 过滤以保证只能查看自己空间内的记录
+func (r *activityRepository) FindByFileID(ctx context.Context, userID, fileID uint64, page, pageSize int) ([]models.Activity, int64, error) {
+	return r.findPage(readDB(ctx, r.db).Model(&models.Activity{}).Where("user_id = ? AND file_id = ?", userID, fileID), page, pageSize)
+}
+
+// 查找指定用户空间内的活动记录
+func (r *activityRepository) FindByUserID(ctx context.Context, userID uint64, page, pageSize int) ([]models.Activity, int64, error) {
+	return r.findPage(readDB(ctx, r.db).Model(&models.Activity{}).Where("user_id = ?", userID), page, pageSize)
+}
+
+func (r *activityRepository) findPage(query *gorm.DB, page, pageSize int) ([]models.Activity, int64, error) {
+	var activities []models.Activity
+	var total int64
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计活动记录总数失败: %w", err)
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.Order("created_at desc, id desc").Offset(offset).Limit(pageSize).Find(&activities).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询活动记录失败: %w", err)
+	}
+	return activities, total, nil
+}
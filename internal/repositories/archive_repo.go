@@ -0,0 +1,77 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"gorm.io/gorm"
+)
+
+// ArchiveJobRepository 定义了文件夹打包任务的数据库操作接口
+type ArchiveJobRepository interface {
+	Create(ctx context.Context, job *models.ArchiveJob) error
+	// FindByID 查询打包任务,不存在时返回 xerr.ErrArchiveNotFound
+	FindByID(ctx context.Context, id uint64) (*models.ArchiveJob, error)
+	// FindActive 查询用户对同一文件夹等待中或处理中的打包任务,没有时返回 nil
+	FindActive(ctx context.Context, userID, folderID uint64) (*models.ArchiveJob, error)
+	// Update 保存打包任务的状态、存储位置和统计字段
+	Update(ctx context.Context, job *models.ArchiveJob) error
+	// FindExpired 查询 before 之前过期但仍为已完成状态的打包任务
+	FindExpired(ctx context.Context, before time.Time, limit int) ([]models.ArchiveJob, error)
+}
+
+type archiveJobRepository struct {
+	db *gorm.DB
+}
+
+// NewArchiveJobRepository 创建新的 archiveJobRepository 实例
+func NewArchiveJobRepository(db *gorm.DB) ArchiveJobRepository {
+	return &archiveJobRepository{db: db}
+}
+
+func (r *archiveJobRepository) Create(ctx context.Context, job *models.ArchiveJob) error {
+	return writeDB(ctx, r.db).Create(job).Error
+}
+
+func (r *archiveJobRepository) FindByID(ctx context.Context, id uint64) (*models.ArchiveJob, error) {
+	var job models.ArchiveJob
+	if err := readDB(ctx, r.db).First(&job, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("archive repository: %w", xerr.ErrArchiveNotFound)
+		}
+		return nil, fmt.Errorf("archive repository: failed to find archive job: %w", err)
+	}
+	return &job, nil
+}
+
+func (r *archiveJobRepository) FindActive(ctx context.Context, userID, folderID uint64) (*models.ArchiveJob, error) {
+	var job models.ArchiveJob
+	err := readDB(ctx, r.db).
+		Where("user_id = ? AND folder_id = ? AND status IN ?", userID, folderID, []string{models.ArchiveStatusPending, models.ArchiveStatusProcessing}).
+		Order("id desc").First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *archiveJobRepository) Update(ctx context.Context, job *models.ArchiveJob) error {
+	return writeDB(ctx, r.db).Model(job).
+		Select("Status", "OssBucket", "OssKey", "VersionID", "Size", "FileCount", "TotalSize", "Error", "ExpiresAt").
+		Updates(job).Error
+}
+
+func (r *archiveJobRepository) FindExpired(ctx context.Context, before time.Time, limit int) ([]models.ArchiveJob, error) {
+	var jobs []models.ArchiveJob
+	err := readDB(ctx, r.db).
+		Where("status = ? AND expires_at < ?", models.ArchiveStatusReady, before).
+		Order("expires_at asc").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"gorm.io/gorm"
+)
+
+// FileCommentRepository 定义了文件评论的数据库操作接口
+type FileCommentRepository interface {
+	Create(ctx context.Context, comment *models.FileComment) error
+	FindByID(ctx context.Context, id uint64) (*models.FileComment, error)
+	// FindByFileID 分页查询文件上的评论,按发表时间从早到晚排列
+	FindByFileID(ctx context.Context, fileID uint64, page, pageSize int) ([]models.FileComment, int64, error)
+	Delete(ctx context.Context, id uint64) error
+}
+
+type fileCommentRepository struct {
+	db *gorm.DB
+}
+
+// NewFileCommentRepository 创建新的 fileCommentRepository 实例
+func NewFileCommentRepository(db *gorm.DB) FileCommentRepository {
+	return &fileCommentRepository{db: db}
+}
+
+func (r *fileCommentRepository) Create(ctx context.Context, comment *models.FileComment) error {
+	return writeDB(ctx, r.db).Create(comment).Error
+}
+
+func (r *fileCommentRepository) FindByID(ctx context.Context, id uint64) (*models.FileComment, error) {
+	var comment models.FileComment
+	if err := r.withAuthor(readDB(ctx, r.db)).Where("file_comments.id = ?", id).First(&comment).Error; err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func (r *fileCommentRepository) FindByFileID(ctx context.Context, fileID uint64, page, pageSize int) ([]models.FileComment, int64, error) {
+	var comments []models.FileComment
+	var total int64
+
+	query := readDB(ctx, r.db).Model(&models.FileComment{}).Where("file_comments.file_id = ?", fileID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计评论总数失败: %w", err)
+	}
+
+	offset := (page - 1) * pageSize
+	err := r.withAuthor(query).Order("file_comments.created_at asc, file_comments.id asc").
+		Offset(offset).Limit(pageSize).Find(&comments).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询评论失败: %w", err)
+	}
+	return comments, total, nil
+}
+
+func (r *fileCommentRepository) Delete(ctx context.Context, id uint64) error {
+	return writeDB(ctx, r.db).Delete(&models.FileComment{}, id).Error
+}
+
+// withAuthor 关联 users 表取出评论者的用户名,用户注销后用户名为空
+func (r *fileCommentRepository) withAuthor(query *gorm.DB) *gorm.DB {
+	return query.Select("file_comments.*, COALESCE(users.username, '') AS author_name").
+		Joins("LEFT JOIN users ON users.id = file_comments.user_id")
+}
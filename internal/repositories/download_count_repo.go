@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FileDownloadCountRepository 定义了文件下载次数的数据库操作接口
+type FileDownloadCountRepository interface {
+	// Add 把 counts 中的 Downloads 作为增量累加到已有计数上,不存在的记录自动创建
+	Add(ctx context.Context, counts []models.FileDownloadCount) error
+	// FindByFileIDs 返回文件各版本的下载次数
+	FindByFileIDs(ctx context.Context, fileIDs []uint64) ([]models.FileDownloadCount, error)
+	// DeleteByFileIDs 删除文件的全部下载次数,彻底删除文件时调用
+	DeleteByFileIDs(ctx context.Context, fileIDs []uint64) error
+}
+
+type fileDownloadCountRepository struct {
+	db *gorm.DB
+}
+
+// NewFileDownloadCountRepository 创建新的 fileDownloadCountRepository 实例
+func NewFileDownloadCountRepository(db *gorm.DB) FileDownloadCountRepository {
+	return &fileDownloadCountRepository{db: db}
+}
+
+func (r *fileDownloadCountRepository) Add(ctx context.Context, counts []models.FileDownloadCount) error {
+	if len(counts) == 0 {
+		return nil
+	}
+	return writeDB(ctx, r.db).Clauses(clause.OnConflict{
+		DoUpdates: clause.Assignments(map[string]any{
+			"downloads":  gorm.Expr("downloads + VALUES(downloads)"),
+			"updated_at": gorm.Expr("VALUES(updated_at)"),
+		}),
+	}).CreateInBatches(counts, 500).Error
+}
+
+func (r *fileDownloadCountRepository) FindByFileIDs(ctx context.Context, fileIDs []uint64) ([]models.FileDownloadCount, error) {
+	var counts []models.FileDownloadCount
+	if len(fileIDs) == 0 {
+		return counts, nil
+	}
+	err := readDB(ctx, r.db).Where("file_id IN ?", fileIDs).Find(&counts).Error
+	return counts, err
+}
+
+func (r *fileDownloadCountRepository) DeleteByFileIDs(ctx context.Context, fileIDs []uint64) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	return writeDB(ctx, r.db).Where("file_id IN ?", fileIDs).Delete(&models.FileDownloadCount{}).Error
+}
@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DataExportRepository 定义了账户数据导出任务的数据库操作接口
+type DataExportRepository interface {
+	Create(export *models.DataExport) error
+	// FindByID 查询导出任务及其分卷,不存在时返回 xerr.ErrExportNotFound
+	FindByID(id uint64) (*models.DataExport, error)
+	// FindByUserID 查询用户最近的导出任务,按创建时间倒序
+	FindByUserID(userID uint64, limit int) ([]models.DataExport, error)
+	// CountActive 统计用户等待中和处理中的导出任务数。先锁定用户记录,需要在事务中调用,
+	// 同一用户并发的请求在锁上排队,统计和随后的 Create 之间不会插入其他任务
+	CountActive(userID uint64) (int64, error)
+	// Update 保存导出任务的状态和统计字段,不包括分卷
+	Update(export *models.DataExport) error
+	CreatePart(part *models.DataExportPart) error
+	// DeleteParts 删除导出任务的全部分卷记录
+	DeleteParts(exportID uint64) error
+	// FindExpired 查询 before 之前过期但仍为已完成状态的导出任务
+	FindExpired(before time.Time, limit int) ([]models.DataExport, error)
+}
+
+type dataExportRepository struct {
+	db *gorm.DB
+}
+
+// NewDataExportRepository 创建新的 dataExportRepository 实例
+func NewDataExportRepository(db *gorm.DB) DataExportRepository {
+	return &dataExportRepository{db: db}
+}
+
+func (r *dataExportRepository) Create(export *models.DataExport) error {
+	return r.db.Create(export).Error
+}
+
+func (r *dataExportRepository) FindByID(id uint64) (*models.DataExport, error) {
+	var export models.DataExport
+	err := r.db.Preload("Parts", func(db *gorm.DB) *gorm.DB {
+		return db.Order("part_number asc")
+	}).First(&export, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("export repository: %w", xerr.ErrExportNotFound)
+		}
+		return nil, fmt.Errorf("export repository: failed to find export: %w", err)
+	}
+	return &export, nil
+}
+
+func (r *dataExportRepository) FindByUserID(userID uint64, limit int) ([]models.DataExport, error) {
+	var exports []models.DataExport
+	err := r.db.Where("user_id = ?", userID).Order("created_at desc, id desc").Limit(limit).Find(&exports).Error
+	return exports, err
+}
+
+func (r *dataExportRepository) CountActive(userID uint64) (int64, error) {
+	var user models.User
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", userID).Take(&user).Error; err != nil {
+		return 0, fmt.Errorf("export repository: failed to lock user: %w", err)
+	}
+
+	var count int64
+	err := r.db.Model(&models.DataExport{}).
+		Where("user_id = ? AND status IN ?", userID, []string{models.ExportStatusPending, models.ExportStatusProcessing}).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *dataExportRepository) Update(export *models.DataExport) error {
+	return r.db.Model(export).Select("Status", "OssBucket", "FileCount", "TotalSize", "Error", "ExpiresAt").Updates(export).Error
+}
+
+func (r *dataExportRepository) CreatePart(part *models.DataExportPart) error {
+	return r.db.Create(part).Error
+}
+
+func (r *dataExportRepository) DeleteParts(exportID uint64) error {
+	return r.db.Where("export_id = ?", exportID).Delete(&models.DataExportPart{}).Error
+}
+
+func (r *dataExportRepository) FindExpired(before time.Time, limit int) ([]models.DataExport, error) {
+	var exports []models.DataExport
+	err := r.db.Preload("Parts").
+		Where("status = ? AND expires_at < ?", models.ExportStatusReady, before).
+		Order("expires_at asc").Limit(limit).Find(&exports).Error
+	return exports, err
+}
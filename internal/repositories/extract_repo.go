@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"gorm.io/gorm"
+)
+
+// ExtractJobRepository 定义了压缩包解压任务的数据库操作接口
+type ExtractJobRepository interface {
+	Create(ctx context.Context, job *models.ExtractJob) error
+	// FindByID 查询解压任务,不存在时返回 xerr.ErrExtractJobNotFound
+	FindByID(ctx context.Context, id uint64) (*models.ExtractJob, error)
+	// FindActive 查询用户对同一压缩包等待中或处理中的解压任务,没有时返回 nil
+	FindActive(ctx context.Context, userID, fileID uint64) (*models.ExtractJob, error)
+	// Update 保存解压任务的状态和进度
+	Update(ctx context.Context, job *models.ExtractJob) error
+}
+
+type extractJobRepository struct {
+	db *gorm.DB
+}
+
+// NewExtractJobRepository 创建新的 extractJobRepository 实例
+func NewExtractJobRepository(db *gorm.DB) ExtractJobRepository {
+	return &extractJobRepository{db: db}
+}
+
+func (r *extractJobRepository) Create(ctx context.Context, job *models.ExtractJob) error {
+	return writeDB(ctx, r.db).Create(job).Error
+}
+
+func (r *extractJobRepository) FindByID(ctx context.Context, id uint64) (*models.ExtractJob, error) {
+	var job models.ExtractJob
+	if err := readDB(ctx, r.db).First(&job, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("extract repository: %w", xerr.ErrExtractJobNotFound)
+		}
+		return nil, fmt.Errorf("extract repository: failed to find extract job: %w", err)
+	}
+	return &job, nil
+}
+
+func (r *extractJobRepository) FindActive(ctx context.Context, userID, fileID uint64) (*models.ExtractJob, error) {
+	var job models.ExtractJob
+	err := readDB(ctx, r.db).
+		Where("user_id = ? AND file_id = ? AND status IN ?", userID, fileID, []string{models.ExtractStatusPending, models.ExtractStatusProcessing}).
+		Order("id desc").First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *extractJobRepository) Update(ctx context.Context, job *models.ExtractJob) error {
+	return writeDB(ctx, r.db).Model(job).
+		Select("RootFolderID", "Status", "TotalEntries", "ProcessedEntries", "FileCount", "FolderCount", "SkippedEntries", "ExtractedSize", "Error").
+		Updates(job).Error
+}
@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"fmt"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FileFavoriteRepository 定义了收藏的数据库操作接口
+type FileFavoriteRepository interface {
+	// Create 添加收藏,已收藏时不做任何操作
+	Create(favorite *models.FileFavorite) error
+	// Delete 取消收藏,返回删除的记录数
+	Delete(userID, fileID uint64) (int64, error)
+	// FindByUserID 分页查询用户的收藏,只包含正常状态且用户仍能访问的文件,按收藏时间倒序
+	FindByUserID(userID uint64, page, pageSize int) ([]models.FileFavorite, int64, error)
+}
+
+type fileFavoriteRepository struct {
+	db *gorm.DB
+}
+
+// NewFileFavoriteRepository 创建新的 fileFavoriteRepository 实例
+func NewFileFavoriteRepository(db *gorm.DB) FileFavoriteRepository {
+	return &fileFavoriteRepository{db: db}
+}
+
+func (r *fileFavoriteRepository) Create(favorite *models.FileFavorite) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(favorite).Error
+}
+
+func (r *fileFavoriteRepository) Delete(userID, fileID uint64) (int64, error) {
+	result := r.db.Where("user_id = ? AND file_id = ?", userID, fileID).Delete(&models.FileFavorite{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *fileFavoriteRepository) FindByUserID(userID uint64, page, pageSize int) ([]models.FileFavorite, int64, error) {
+	var favorites []models.FileFavorite
+	var total int64
+
+	// 回收站中和已删除的文件不出现在收藏列表中,恢复后重新出现。
+	// 收藏的其他用户的文件在授权被撤销或退出组织后不再返回,与 authz 的判断一致:
+	// 自己的文件、所在组织网盘账号的文件,或文件自身及祖先文件夹上有正常状态文件夹的协作授权
+	granted := `WITH RECURSIVE chain (file_id, id, parent_folder_id, depth) AS (
+	SELECT f.id, f.id, f.parent_folder_id, 0 FROM files f
+	JOIN file_favorites ff ON ff.file_id = f.id AND ff.user_id = ?
+	WHERE f.user_id <> ?
+	UNION ALL
+	SELECT c.file_id, p.id, p.parent_folder_id, c.depth + 1 FROM files p
+	JOIN chain c ON p.id = c.parent_folder_id
+	WHERE c.depth < ?
+) SELECT c.file_id FROM chain c
+JOIN file_permissions fp ON fp.file_id = c.id AND fp.grantee_id = ?
+JOIN files g ON g.id = fp.file_id AND g.status = ? AND g.deleted_at IS NULL`
+	orgDrives := `SELECT o.drive_user_id FROM organizations o
+JOIN organization_members m ON m.org_id = o.id AND m.user_id = ?`
+	query := r.db.Model(&models.FileFavorite{}).
+		Joins("JOIN files ON files.id = file_favorites.file_id AND files.status = ? AND files.deleted_at IS NULL", models.StatusNormal).
+		Where("file_favorites.user_id = ?", userID).
+		Where("files.user_id = ? OR files.user_id IN (?) OR files.id IN (?)",
+			userID,
+			gorm.Expr(orgDrives, userID),
+			gorm.Expr(granted, userID, userID, maxFolderDepth, userID, models.StatusNormal))
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计收藏总数失败: %w", err)
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.Preload("File").Order("file_favorites.created_at desc, file_favorites.id desc").
+		Offset(offset).Limit(pageSize).Find(&favorites).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询收藏失败: %w", err)
+	}
+	return favorites, total, nil
+}
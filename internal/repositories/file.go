@@ -1,26 +1,64 @@
 package repositories
 
 import (
+	"context"
+	"time"
+
 	"github.com/3Eeeecho/go-clouddisk/internal/models"
 	"gorm.io/gorm"
 )
 
 // FileRepository defines the interface for file data access.
 type FileRepository interface {
-	Create(file *models.File) error
-	FindByID(id uint64) (*models.File, error)
-	FindByUserIDAndParentFolderID(userID uint64, parentFolderID *uint64) ([]models.File, error)
-	FindByPath(path string) (*models.File, error)
-	FindByUUID(uuid string) (*models.File, error)
-	FindByOssKey(ossKey string) (*models.File, error)
-	FindByFileName(userID uint64, parentFolderID *uint64, fileName string) (*models.File, error)
-	FindFileByMD5Hash(md5Hash string) (*models.File, error)
-	FindDeletedFilesByUserID(userID uint64) ([]models.File, error)
-	FindChildrenByPathPrefix(userID uint64, pathPrefix string) ([]models.File, error)
-	CountFilesInStorage(ossKey string, md5Hash string, excludeFileID uint64) (int64, error)
-	UpdateFilesPathInBatch(userID uint64, oldPathPrefix, newPathPrefix string) error
-	Update(file *models.File) error
-	SoftDelete(id uint64) error
-	PermanentDelete(tx *gorm.DB, fileID uint64) error
-	UpdateFileStatus(fileID uint64, status uint8) error
+	Create(ctx context.Context, file *models.File) error
+	FindByID(ctx context.Context, id uint64) (*models.File, error)
+	// FindByIDs 按ID批量查询,包含回收站中的记录,不存在的ID不出现在结果中
+	FindByIDs(ctx context.Context, ids []uint64) ([]models.File, error)
+	FindByUserIDAndParentFolderID(ctx context.Context, userID uint64, parentFolderID *uint64, opts models.FileListOptions) ([]models.File, int64, error)
+	FindByPath(ctx context.Context, userID uint64, parentPath string, fileName string) (*models.File, error)
+	FindByUUID(ctx context.Context, uuid string) (*models.File, error)
+	FindByOssKey(ctx context.Context, ossKey string) (*models.File, error)
+	FindByFileName(ctx context.Context, userID uint64, parentFolderID *uint64, fileName string) (*models.File, error)
+	// FindFileByMD5Hash 在用户自己的文件中按 MD5 查找
+	FindFileByMD5Hash(ctx context.Context, userID uint64, md5Hash string) (*models.File, error)
+	// FindFileBySHA256Hash 在用户自己的文件中按 SHA-256 查找
+	FindFileBySHA256Hash(ctx context.Context, userID uint64, sha256Hash string) (*models.File, error)
+	// FindSharedFileBySHA256Hash 在其他用户的文件中按 SHA-256 查找,跳过不参与跨用户秒传的用户
+	FindSharedFileBySHA256Hash(ctx context.Context, excludeUserID uint64, sha256Hash string) (*models.File, error)
+	// FindDeletedFilesByUserID 按删除时间倒序列出回收站中的文件,opts.Limit 为 0 时返回全部
+	FindDeletedFilesByUserID(ctx context.Context, userID uint64, opts models.TrashListOptions) ([]models.File, error)
+	// FindExpiredTrash 按删除时间从早到晚列出超过所有者回收站保留期的文件,用户未设置保留期时使用 defaultDays,
+	// 跳过待删除的记录和已申请注销的用户
+	FindExpiredTrash(ctx context.Context, now time.Time, defaultDays int, limit int) ([]models.File, error)
+	// CountDeletedByParentIDs 按原父文件夹统计回收站中的文件数量,parentIDs 中的 0 表示根目录,没有已删除文件的文件夹不出现在结果中
+	CountDeletedByParentIDs(ctx context.Context, userID uint64, parentIDs []uint64) (map[uint64]int64, error)
+	// SearchByName 按文件名包含 keyword 和 filter 分页搜索用户正常状态的文件和文件夹,文件夹在前
+	SearchByName(ctx context.Context, userID uint64, keyword string, filter models.FileFilter, page, pageSize int) ([]models.File, int64, error)
+	// FindAllByUserID 返回用户所有未进入回收站的记录
+	FindAllByUserID(ctx context.Context, userID uint64) ([]models.File, error)
+	// FindRootsByUserID 返回用户根目录下的全部记录,包括回收站中和待删除的记录
+	FindRootsByUserID(ctx context.Context, userID uint64) ([]models.File, error)
+	// FindDescendants 一次查询获取文件夹下的整棵子树(不包括文件夹自身),按层级从浅到深排列。
+	// includeDeleted 为 true 时包括回收站中和待删除的记录
+	FindDescendants(ctx context.Context, userID uint64, folderID uint64, includeDeleted bool) ([]models.File, error)
+	// FindFolderChains 沿 parent_folder_id 向上查找,返回用户每个文件夹从根目录下第一级到自身的文件夹链,
+	// 链中只填充 ID、UserID、ParentFolderID 和 FileName。不存在或不属于该用户的文件夹不出现在结果中
+	FindFolderChains(ctx context.Context, userID uint64, folderIDs []uint64) (map[uint64][]models.File, error)
+	// IsInFolder 判断文件是否位于文件夹的子树中,不包括文件夹自身
+	IsInFolder(ctx context.Context, file *models.File, folderID uint64) (bool, error)
+	Update(ctx context.Context, file *models.File) error
+	SoftDelete(ctx context.Context, id uint64) error
+	PermanentDelete(ctx context.Context, tx *gorm.DB, fileID uint64) error
+	UpdateFileStatus(ctx context.Context, fileID uint64, status uint8) error
+	// MarkDeleting 把文件标记为待删除并移出列表和回收站,等待后台彻底删除
+	MarkDeleting(ctx context.Context, files []models.File) error
+	UpdateScanStatus(ctx context.Context, fileID uint64, scanStatus string) error
+	// FindForIntegrityCheck 按上次完整性校验时间从早到晚返回正常状态的文件,从未校验过的优先
+	FindForIntegrityCheck(ctx context.Context, limit int) ([]models.File, error)
+	// MarkIntegrityChecked 记录文件的完整性校验时间
+	MarkIntegrityChecked(ctx context.Context, fileIDs []uint64, checkedAt time.Time) error
+	// WithTx 返回绑定到指定事务的仓库
+	WithTx(tx *gorm.DB) FileRepository
+	// WithPrimary 返回查询数据库时都走主库的仓库,用于写入后立即回读,避免读到从库中的旧数据
+	WithPrimary() FileRepository
 }
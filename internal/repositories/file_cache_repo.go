@@ -2,560 +2,578 @@ package repositories
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"math/rand"
-	"sort"
-	"strconv"
+	"maps"
+	"slices"
 	"time"
 
 	"github.com/3Eeeecho/go-clouddisk/internal/models"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache/filecache"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
-	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mapper"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/metrics"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
-	"github.com/go-redis/redis/v8"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
 type cachedFileRepository struct {
-	next  FileRepository // Next repository in the chain (the db repository)
-	cache *cache.RedisCache
+	next   FileRepository // Next repository in the chain (the db repository)
+	cache  filecache.FileCache
+	outbox OutboxRepository // 缓存更新消息和数据库写入在同一事务中写入发件箱
+	inTx   bool             // 绑定了事务,事务中可能有未提交的移动,祖先链不读写缓存
 }
 
 // NewCachedFileRepository creates a new cachedFileRepository instance.
-func NewCachedFileRepository(next FileRepository, cache *cache.RedisCache) FileRepository {
+// outbox must use the same connection (or transaction) as next.
+func NewCachedFileRepository(next FileRepository, redisCache *cache.RedisCache, outbox OutboxRepository) FileRepository {
 	return &cachedFileRepository{
-		next:  next,
-		cache: cache,
+		next:   next,
+		cache:  filecache.New(redisCache),
+		outbox: outbox,
 	}
 }
 
-func (r *cachedFileRepository) Create(file *models.File) error {
+func (r *cachedFileRepository) Create(ctx context.Context, file *models.File) error {
 	// First, call the next repository to create the file in the database.
-	if err := r.next.Create(file); err != nil {
+	if err := r.next.Create(ctx, file); err != nil {
 		return err
 	}
 
 	// After successful creation, update the cache.
-	ctx := context.Background()
-	pipe := r.cache.TxPipeline()
-	// Cache the new file's metadata.
-	fileMetadataKey := cache.GenerateFileMetadataKey(file.ID)
-	fileMap, err := mapper.FileToMap(file)
-	if err != nil {
-		logger.Error("Create: Failed to map models.File to hash for caching", zap.Uint64("id", file.ID), zap.Error(err))
-	} else {
-		pipe.HMSet(ctx, fileMetadataKey, fileMap)
-		pipe.Expire(ctx, fileMetadataKey, cache.CacheTTL+time.Duration(rand.Intn(300))*time.Second)
+	// 数据库已经写入,请求被取消时也要完成缓存维护,否则缓存中会留下旧数据
+	ctx = context.WithoutCancel(ctx)
+	if err := r.cache.PutFile(ctx, filecache.ByID(file.ID), file); err != nil {
+		logger.Error("Create: Failed to cache file metadata", zap.Uint64("fileID", file.ID), zap.Error(err))
 	}
-
-	// Add the new file's ID to the corresponding sorted set.
-	listCacheKey := cache.GenerateFileListKey(file.UserID, file.ParentFolderID)
-	pipe.ZAdd(ctx, listCacheKey, &redis.Z{
-		Score:  float64(file.CreatedAt.Unix()),
-		Member: strconv.FormatUint(file.ID, 10),
-	})
-	pipe.ZRem(ctx, listCacheKey, "__EMPTY_LIST__")
-	pipe.Expire(ctx, listCacheKey, cache.CacheTTL+time.Duration(rand.Intn(300))*time.Second)
-
-	if _, execErr := pipe.Exec(ctx); execErr != nil {
-		logger.Error("Create: Failed to execute Redis pipeline for cache update",
-			zap.Uint64("fileID", file.ID),
-			zap.Uint64("userID", file.UserID),
-			zap.Error(execErr),
-		)
+	// Invalidate the sorted lists of the parent folder, they are rebuilt on the next read.
+	if err := r.cache.MutateList(ctx, filecache.ListChange{After: file}); err != nil {
+		logger.Error("Create: Failed to update file list cache", zap.Uint64("fileID", file.ID), zap.Uint64("userID", file.UserID), zap.Error(err))
 	}
 	logger.Info("Create: File created and cache updated", zap.Uint64("fileID", file.ID), zap.Uint64("userID", file.UserID))
 	return nil
 }
 
-func (r *cachedFileRepository) FindByID(id uint64) (*models.File, error) {
-	ctx := context.Background()
-	fileMetadataKey := cache.GenerateFileMetadataKey(id)
+func (r *cachedFileRepository) FindByID(ctx context.Context, id uint64) (*models.File, error) {
+	key := filecache.ByID(id)
 
 	// Try to get from cache
-	resultMap, err := r.cache.HGetAll(ctx, fileMetadataKey)
-	if err == nil {
-		if _, ok := resultMap["__NOT_FOUND__"]; ok {
-			return nil, xerr.ErrFileNotFound
-		}
-		file, err := mapper.MapToFile(resultMap)
-		if err == nil {
-			return file, nil
-		}
-		logger.Error("FindByID: Failed to map cached hash to models.File", zap.Uint64("id", id), zap.Error(err))
-	} else if !errors.Is(err, cache.ErrCacheMiss) {
-		logger.Error("FindByID: Error getting file hash from cache", zap.Uint64("id", id), zap.Error(err))
+	file, err := r.cache.GetFile(ctx, key)
+	switch {
+	case err == nil:
+		metrics.ObserveCache("file_metadata", true)
+		return r.withPath(ctx, file)
+	case errors.Is(err, xerr.ErrFileNotFound):
+		return nil, err
+	case !errors.Is(err, cache.ErrCacheMiss):
+		logger.Error("FindByID: Error getting file from cache", zap.Uint64("id", id), zap.Error(err))
 	}
 
 	// Cache miss, get from db
-	file, err := r.next.FindByID(id)
+	metrics.ObserveCache("file_metadata", false)
+	return r.loadFile(ctx, key, func() (*models.File, error) { return r.next.FindByID(ctx, id) })
+}
+
+// FindByIDs 先从缓存的元数据 Hash 中批量读取,未缓存的一次性回源查询并写入缓存
+func (r *cachedFileRepository) FindByIDs(ctx context.Context, ids []uint64) ([]models.File, error) {
+	cached, missed, err := r.cache.GetFiles(ctx, ids)
 	if err != nil {
-		if errors.Is(err, xerr.ErrFileNotFound) {
-			r.cache.HSet(ctx, fileMetadataKey, "__NOT_FOUND__", "1")
-			r.cache.Expire(ctx, fileMetadataKey, 1*time.Minute)
-		}
-		return nil, err
+		logger.Error("FindByIDs: Error getting files from cache", zap.Int("count", len(ids)), zap.Error(err))
+		cached, missed = map[uint64]*models.File{}, ids
 	}
+	metrics.ObserveCache("file_metadata", len(missed) == 0)
 
-	// Set to cache
-	fileMap, mapErr := mapper.FileToMap(file)
-	if mapErr != nil {
-		logger.Error("FindByID: Failed to map models.File to hash for caching", zap.Uint64("id", id), zap.Error(mapErr))
-	} else {
-		r.cache.HMSet(ctx, fileMetadataKey, fileMap)
-		r.cache.Expire(ctx, fileMetadataKey, cache.CacheTTL+time.Duration(rand.Intn(300))*time.Second)
+	files := make([]models.File, 0, len(ids))
+	for _, id := range ids {
+		if file, ok := cached[id]; ok {
+			files = append(files, *file)
+		}
 	}
-
-	return file, nil
-}
-
-func (r *cachedFileRepository) FindByUserIDAndParentFolderID(userID uint64, parentFolderID *uint64) ([]models.File, error) {
-	ctx := context.Background()
-	listCacheKey := cache.GenerateFileListKey(userID, parentFolderID)
-
-	files, err := r.getFilesFromCacheList(ctx, listCacheKey)
-	if err == nil {
-		sort.Slice(files, func(i, j int) bool {
-			if files[i].IsFolder != files[j].IsFolder {
-				return files[i].IsFolder > files[j].IsFolder
-			}
-			return files[i].FileName < files[j].FileName
-		})
+	if err := fillListPaths(ctx, r, files); err != nil {
+		return nil, err
+	}
+	if len(missed) == 0 {
 		return files, nil
-	} else if !errors.Is(err, cache.ErrCacheMiss) {
-		logger.Error("FindByUserIDAndParentFolderID: Error getting file list from cache", zap.String("key", listCacheKey), zap.Error(err))
 	}
 
-	dbFiles, err := r.next.FindByUserIDAndParentFolderID(userID, parentFolderID)
+	loaded, err := r.next.FindByIDs(ctx, missed)
 	if err != nil {
 		return nil, err
 	}
-
-	saveErr := r.saveFilesToCacheList(ctx, listCacheKey, dbFiles, func(file models.File) float64 {
-		return float64(file.CreatedAt.Unix())
-	})
-	if saveErr != nil {
-		logger.Error("FindByUserIDAndParentFolderID: Failed to save files to cache", zap.Error(saveErr))
+	for i := range loaded {
+		if err := r.cache.PutFile(ctx, filecache.ByID(loaded[i].ID), &loaded[i]); err != nil {
+			logger.Error("Failed to cache file metadata", zap.Uint64("id", loaded[i].ID), zap.Error(err))
+		}
 	}
-	return dbFiles, nil
+	return append(files, loaded...), nil
 }
 
-func (r *cachedFileRepository) FindFileByMD5Hash(md5Hash string) (*models.File, error) {
-	ctx := context.Background()
-	fileMetadataKey := cache.GenerateFileMD5Key(md5Hash)
+// maxCachedListSize 超过该数量的文件夹不整体写入缓存,未命中时直接从数据库读取请求的那一页
+const maxCachedListSize = 5000
+
+// FindByUserIDAndParentFolderID serves pages from a per-sort Sorted Set whose score is the rank of each file,
+// so a page is a single ZRANGE instead of loading the whole folder. Cursor pages start right after the
+// cursor's rank. On a miss only the requested page is read from the DB; the whole folder is cached
+// only when it is small enough. Filtered listings bypass the cache.
+func (r *cachedFileRepository) FindByUserIDAndParentFolderID(ctx context.Context, userID uint64, parentFolderID *uint64, opts models.FileListOptions) ([]models.File, int64, error) {
+	opts.Normalize()
+	if !opts.Filter.IsZero() {
+		return r.next.FindByUserIDAndParentFolderID(ctx, userID, parentFolderID, opts)
+	}
 
-	resultMap, err := r.cache.HGetAll(ctx, fileMetadataKey)
+	var files []models.File
+	var total int64
+	var err error
+	switch {
+	case opts.Cursor != nil && opts.PageSize > 0:
+		files, total, err = r.cache.GetFolderPageAfter(ctx, userID, parentFolderID, opts.SortBy, opts.Order, opts.Cursor.ID, int64(opts.PageSize))
+	case opts.Cursor != nil:
+		err = cache.ErrCacheMiss
+	case opts.PageSize > 0:
+		start := int64((opts.Page - 1) * opts.PageSize)
+		files, total, err = r.cache.GetFolderPage(ctx, userID, parentFolderID, opts.SortBy, opts.Order, start, start+int64(opts.PageSize)-1)
+	default:
+		files, total, err = r.cache.GetFolderPage(ctx, userID, parentFolderID, opts.SortBy, opts.Order, 0, -1)
+	}
 	if err == nil {
-		if _, ok := resultMap["__NOT_FOUND__"]; ok {
-			return nil, xerr.ErrFileNotFound
+		metrics.ObserveCache("file_list", true)
+		if err := fillListPaths(ctx, r, files); err != nil {
+			return nil, 0, err
 		}
-		file, err := mapper.MapToFile(resultMap)
-		if err == nil {
-			return file, nil
-		}
-		logger.Error("FindFileByMD5Hash: Failed to map cached hash to models.File", zap.String("md5Hash", md5Hash), zap.Error(err))
+		return files, total, nil
 	} else if !errors.Is(err, cache.ErrCacheMiss) {
-		logger.Error("FindFileByMD5Hash: Error getting file hash from cache", zap.String("md5Hash", md5Hash), zap.Error(err))
+		logger.Error("FindByUserIDAndParentFolderID: Error getting file list from cache", zap.Uint64("userID", userID), zap.Error(err))
 	}
+	metrics.ObserveCache("file_list", false)
 
-	file, err := r.next.FindFileByMD5Hash(md5Hash)
+	dbFiles, total, err := r.next.FindByUserIDAndParentFolderID(ctx, userID, parentFolderID, opts)
 	if err != nil {
-		if errors.Is(err, xerr.ErrFileNotFound) {
-			r.cache.HSet(ctx, fileMetadataKey, "__NOT_FOUND__", "1")
-			r.cache.Expire(ctx, fileMetadataKey, 1*time.Minute)
+		return nil, 0, err
+	}
+	if total <= maxCachedListSize {
+		r.cacheFolder(ctx, userID, parentFolderID, opts, dbFiles)
+	}
+	return dbFiles, total, nil
+}
+
+// cacheFolder 把文件夹按 opts 排序后的完整列表写入缓存,score 为每个文件的名次。
+// page 为本次已经读取到的数据,已经是完整列表时不再查询数据库
+func (r *cachedFileRepository) cacheFolder(ctx context.Context, userID uint64, parentFolderID *uint64, opts models.FileListOptions, page []models.File) {
+	files := page
+	if opts.PageSize > 0 || (opts.Cursor != nil && opts.Cursor.ID != 0) {
+		fullOpts := models.FileListOptions{SortBy: opts.SortBy, Order: opts.Order}
+		var err error
+		if files, _, err = r.next.FindByUserIDAndParentFolderID(ctx, userID, parentFolderID, fullOpts); err != nil {
+			logger.Error("FindByUserIDAndParentFolderID: Failed to load folder for cache", zap.Uint64("userID", userID), zap.Error(err))
+			return
 		}
-		return nil, err
 	}
+	if err := r.cache.PutFolder(ctx, userID, parentFolderID, opts.SortBy, opts.Order, files); err != nil {
+		logger.Error("FindByUserIDAndParentFolderID: Failed to save files to cache", zap.Uint64("userID", userID), zap.Error(err))
+	}
+}
 
-	fileMap, mapErr := mapper.FileToMap(file)
-	if mapErr != nil {
-		logger.Error("FindFileByMD5Hash: Failed to map models.File to hash for caching", zap.String("md5Hash", md5Hash), zap.Error(mapErr))
-	} else {
-		r.cache.HMSet(ctx, fileMetadataKey, fileMap)
-		r.cache.Expire(ctx, fileMetadataKey, cache.CacheTTL+time.Duration(rand.Intn(300))*time.Second)
+func (r *cachedFileRepository) FindFileByMD5Hash(ctx context.Context, userID uint64, md5Hash string) (*models.File, error) {
+	key := filecache.ByMD5(userID, md5Hash)
+
+	file, err := r.cache.GetFile(ctx, key)
+	switch {
+	case err == nil:
+		return r.withPath(ctx, file)
+	case errors.Is(err, xerr.ErrFileNotFound):
+		return nil, err
+	case !errors.Is(err, cache.ErrCacheMiss):
+		logger.Error("FindFileByMD5Hash: Error getting file from cache", zap.String("md5Hash", md5Hash), zap.Error(err))
 	}
 
-	return file, nil
+	return r.loadFile(ctx, key, func() (*models.File, error) { return r.next.FindFileByMD5Hash(ctx, userID, md5Hash) })
 }
 
-func (r *cachedFileRepository) FindDeletedFilesByUserID(userID uint64) ([]models.File, error) {
-	ctx := context.Background()
-	listCacheKey := cache.GenerateDeletedFilesKey(userID)
+// FindFileBySHA256Hash 秒传匹配需要实时结果,直接查询数据库
+func (r *cachedFileRepository) FindFileBySHA256Hash(ctx context.Context, userID uint64, sha256Hash string) (*models.File, error) {
+	return r.next.FindFileBySHA256Hash(ctx, userID, sha256Hash)
+}
 
-	files, err := r.getFilesFromCacheList(ctx, listCacheKey)
+func (r *cachedFileRepository) FindSharedFileBySHA256Hash(ctx context.Context, excludeUserID uint64, sha256Hash string) (*models.File, error) {
+	return r.next.FindSharedFileBySHA256Hash(ctx, excludeUserID, sha256Hash)
+}
+
+// FindDeletedFilesByUserID 分页读取时只缓存能放进第一页的回收站,更大的回收站每页都从数据库按游标读取
+func (r *cachedFileRepository) FindDeletedFilesByUserID(ctx context.Context, userID uint64, opts models.TrashListOptions) ([]models.File, error) {
+	// 按原父文件夹过滤的结果不缓存
+	if opts.ParentFolderID != nil {
+		return r.next.FindDeletedFilesByUserID(ctx, userID, opts)
+	}
+
+	var files []models.File
+	var err error
+	var afterID uint64
+	if opts.Cursor != nil {
+		afterID = opts.Cursor.ID
+	}
+	if opts.Limit > 0 {
+		files, err = r.cache.GetTrashPageAfter(ctx, userID, afterID, int64(opts.Limit))
+	} else {
+		files, err = r.cache.GetTrash(ctx, userID)
+	}
 	if err == nil {
-		sort.Slice(files, func(i, j int) bool {
-			return files[i].DeletedAt.Time.After(files[j].DeletedAt.Time)
-		})
+		if err := fillListPaths(ctx, r, files); err != nil {
+			return nil, err
+		}
 		return files, nil
 	} else if !errors.Is(err, cache.ErrCacheMiss) {
-		logger.Error("FindDeletedFilesByUserID: Error getting deleted file list from cache", zap.String("key", listCacheKey), zap.Error(err))
+		logger.Error("FindDeletedFilesByUserID: Error getting deleted file list from cache", zap.Uint64("userID", userID), zap.Error(err))
 	}
 
-	dbFiles, err := r.next.FindDeletedFilesByUserID(userID)
+	dbFiles, err := r.next.FindDeletedFilesByUserID(ctx, userID, opts)
 	if err != nil {
 		return nil, err
 	}
-
-	saveErr := r.saveFilesToCacheList(ctx, listCacheKey, dbFiles, func(file models.File) float64 {
-		score := float64(0)
-		if file.DeletedAt.Valid {
-			score = float64(file.DeletedAt.Time.Unix())
-		}
-		return score
-	})
-	if saveErr != nil {
-		logger.Error("FindDeletedFilesByUserID: Failed to save deleted files to cache", zap.Error(saveErr))
+	if opts.Limit > 0 && (afterID != 0 || len(dbFiles) >= opts.Limit) {
+		return dbFiles, nil
+	}
+	if err := r.cache.PutTrash(ctx, userID, dbFiles); err != nil {
+		logger.Error("FindDeletedFilesByUserID: Failed to save deleted files to cache", zap.Uint64("userID", userID), zap.Error(err))
 	}
-
 	return dbFiles, nil
 }
 
-func (r *cachedFileRepository) Update(file *models.File) error {
-	oldFile, findErr := r.FindByID(file.ID)
+func (r *cachedFileRepository) CountDeletedByParentIDs(ctx context.Context, userID uint64, parentIDs []uint64) (map[uint64]int64, error) {
+	return r.next.CountDeletedByParentIDs(ctx, userID, parentIDs)
+}
+
+func (r *cachedFileRepository) Update(ctx context.Context, file *models.File) error {
+	oldFile, findErr := r.FindByID(ctx, file.ID)
 	if findErr != nil {
 		return fmt.Errorf("Update: failed to find file for cache invalidation: %w", findErr)
 	}
 
-	if err := r.next.Update(file); err != nil {
+	// 消费者异步重新写入元数据,防止并发读取把旧值写回缓存
+	err := r.writeWithEvents(ctx, func(next FileRepository) ([]models.OutboxEvent, error) {
+		if err := next.Update(ctx, file); err != nil {
+			return nil, err
+		}
+		event, err := models.NewStreamEvent(filecache.UpdateStream, cache.CacheUpdateMessage{
+			File:              *file,
+			OldParentFolderID: oldFile.ParentFolderID,
+			OldMD5Hash:        oldFile.MD5Hash,
+			OldDeletedAt:      oldFile.DeletedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+		events := []models.OutboxEvent{event}
+		if pathEvent, err := folderPathChangeEvent(ctx, next, oldFile, file); err != nil {
+			return nil, err
+		} else if pathEvent != nil {
+			events = append(events, *pathEvent)
+		}
+		return events, nil
+	})
+	if err != nil {
 		return err
 	}
 
-	ctx := context.Background()
-	fileMetadataKey := cache.GenerateFileMetadataKey(file.ID)
-	if err := r.cache.Del(ctx, fileMetadataKey); err != nil {
+	ctx = context.WithoutCancel(ctx)
+	if err := r.cache.InvalidateFile(ctx, oldFile, file); err != nil {
 		logger.Error("Update: Failed to synchronously delete file metadata cache", zap.Uint64("fileID", file.ID), zap.Error(err))
 	}
-
-	message := cache.CacheUpdateMessage{
-		File:              *file,
-		OldParentFolderID: oldFile.ParentFolderID,
-		OldMD5Hash:        oldFile.MD5Hash,
-		OldDeletedAt:      oldFile.DeletedAt,
+	if oldFile.IsFolder == 1 && (oldFile.FileName != file.FileName || !isSameFolder(oldFile.ParentFolderID, file.ParentFolderID) ||
+		oldFile.DeletedAt.Valid != file.DeletedAt.Valid) {
+		r.invalidateChains(ctx, "Update", file.UserID)
 	}
-	messageJSON, _ := json.Marshal(message)
-
-	_, streamErr := r.cache.XAdd(ctx, &redis.XAddArgs{
-		Stream: "file_cache_updates",
-		MaxLen: 10000,
-		Values: map[string]any{"payload": messageJSON},
-	}).Result()
-
-	if streamErr != nil {
-		logger.Error("Update: Failed to publish cache update message", zap.Uint64("fileID", file.ID), zap.Error(streamErr))
+	if err := r.cache.MutateList(ctx, filecache.ListChange{Before: oldFile, After: file}); err != nil {
+		logger.Error("Update: Failed to synchronously update file list cache", zap.Uint64("fileID", file.ID), zap.Error(err))
 	}
-
 	return nil
 }
 
-func (r *cachedFileRepository) SoftDelete(id uint64) error {
-	file, err := r.FindByID(id)
+func (r *cachedFileRepository) SoftDelete(ctx context.Context, id uint64) error {
+	oldFile, err := r.FindByID(ctx, id)
 	if err != nil {
 		return err
 	}
-	if file == nil {
-		return xerr.ErrFileNotFound
-	}
 
-	if err := r.next.SoftDelete(id); err != nil {
+	if err := r.next.SoftDelete(ctx, id); err != nil {
 		return err
 	}
 
+	ctx = context.WithoutCancel(ctx)
 	// Refresh file state after soft delete
-	file, err = r.next.FindByID(id)
+	file, err := r.next.WithPrimary().FindByID(ctx, id)
 	if err != nil {
 		logger.Error("SoftDelete: Failed to retrieve file after DB soft delete", zap.Uint64("fileID", id), zap.Error(err))
 		// Even if we can't get the file, we should try to invalidate what we can
-	}
-
-	ctx := context.Background()
-	pipe := r.cache.TxPipeline()
-
-	if file != nil {
-		fileMetadataKey := cache.GenerateFileMetadataKey(file.ID)
-		fileMap, mapErr := mapper.FileToMap(file)
-		if mapErr != nil {
-			logger.Error("SoftDelete: Failed to map models.File to hash for caching", zap.Uint64("id", file.ID), zap.Error(mapErr))
-		} else {
-			pipe.HMSet(ctx, fileMetadataKey, fileMap)
-			pipe.Expire(ctx, fileMetadataKey, cache.CacheTTL+time.Duration(rand.Intn(300))*time.Second)
+		if err := r.cache.InvalidateFile(ctx, oldFile); err != nil {
+			logger.Error("SoftDelete: Failed to invalidate file cache", zap.Uint64("fileID", id), zap.Error(err))
 		}
-
-		listCacheKey := cache.GenerateFileListKey(file.UserID, file.ParentFolderID)
-		pipe.ZRem(ctx, listCacheKey, strconv.FormatUint(file.ID, 10))
-
-		deletedListCacheKey := cache.GenerateDeletedFilesKey(file.UserID)
-		if file.DeletedAt.Valid {
-			deletedZMember := &redis.Z{
-				Score:  float64(file.DeletedAt.Time.Unix()),
-				Member: strconv.FormatUint(file.ID, 10),
-			}
-			pipe.ZAdd(ctx, deletedListCacheKey, deletedZMember)
-			pipe.ZRem(ctx, deletedListCacheKey, "__EMPTY_LIST__")
+		file = nil
+	} else {
+		if err := r.cache.InvalidateFile(ctx, file); err != nil {
+			logger.Error("SoftDelete: Failed to invalidate file cache", zap.Uint64("fileID", id), zap.Error(err))
 		}
-
-		if file.MD5Hash != nil && *file.MD5Hash != "" {
-			pipe.Del(ctx, cache.GenerateFileMD5Key(*file.MD5Hash))
+		if err := r.cache.PutFile(ctx, filecache.ByID(file.ID), file); err != nil {
+			logger.Error("SoftDelete: Failed to cache file metadata", zap.Uint64("fileID", id), zap.Error(err))
 		}
-	} else {
-		// If we couldn't get the file, at least delete the main metadata key
-		pipe.Del(ctx, cache.GenerateFileMetadataKey(id))
 	}
 
-	if _, execErr := pipe.Exec(ctx); execErr != nil {
-		logger.Error("SoftDelete: Failed to execute Redis pipeline for cache update", zap.Uint64("fileID", id), zap.Error(execErr))
+	if err := r.cache.MutateList(ctx, filecache.ListChange{Before: oldFile, After: file}); err != nil {
+		logger.Error("SoftDelete: Failed to update file list cache", zap.Uint64("fileID", id), zap.Error(err))
+	}
+	if oldFile.IsFolder == 1 {
+		r.invalidateChains(ctx, "SoftDelete", oldFile.UserID)
 	}
 
 	logger.Info("SoftDelete: File soft deleted and cache updated", zap.Uint64("fileID", id))
 	return nil
 }
 
-func (r *cachedFileRepository) PermanentDelete(tx *gorm.DB, fileID uint64) error {
-	file, err := r.FindByID(fileID)
+func (r *cachedFileRepository) PermanentDelete(ctx context.Context, tx *gorm.DB, fileID uint64) error {
+	file, err := r.FindByID(ctx, fileID)
 	if err != nil {
 		return err
 	}
-	if file == nil {
-		return xerr.ErrFileNotFound
-	}
 
-	if err := r.next.PermanentDelete(tx, fileID); err != nil {
+	if err := r.next.PermanentDelete(ctx, tx, fileID); err != nil {
 		return err
 	}
 
-	ctx := context.Background()
-	pipe := r.cache.TxPipeline()
-
-	pipe.Del(ctx, cache.GenerateFileMetadataKey(file.ID))
-
-	listCacheKey := cache.GenerateFileListKey(file.UserID, file.ParentFolderID)
-	pipe.ZRem(ctx, listCacheKey, strconv.FormatUint(file.ID, 10))
-
-	deletedListCacheKey := cache.GenerateDeletedFilesKey(file.UserID)
-	pipe.ZRem(ctx, deletedListCacheKey, strconv.FormatUint(file.ID, 10))
-
-	if file.MD5Hash != nil && *file.MD5Hash != "" {
-		pipe.Del(ctx, cache.GenerateFileMD5Key(*file.MD5Hash))
+	ctx = context.WithoutCancel(ctx)
+	if err := r.cache.InvalidateFile(ctx, file); err != nil {
+		logger.Error("PermanentDelete: Failed to invalidate file cache", zap.Uint64("fileID", file.ID), zap.Error(err))
 	}
-
-	if _, execErr := pipe.Exec(ctx); execErr != nil {
-		logger.Error("PermanentDelete: Failed to execute Redis pipeline for cache update", zap.Uint64("fileID", file.ID), zap.Error(execErr))
+	if err := r.cache.MutateList(ctx, filecache.ListChange{Before: file}); err != nil {
+		logger.Error("PermanentDelete: Failed to update file list cache", zap.Uint64("fileID", file.ID), zap.Error(err))
+	}
+	if file.IsFolder == 1 {
+		r.invalidateChains(ctx, "PermanentDelete", file.UserID)
 	}
 
 	logger.Info("PermanentDelete: File permanently deleted and cache invalidated", zap.Uint64("fileID", file.ID))
 	return nil
 }
 
-func (r *cachedFileRepository) UpdateFilesPathInBatch(userID uint64, oldPathPrefix, newPathPrefix string) error {
-	if err := r.next.UpdateFilesPathInBatch(userID, oldPathPrefix, newPathPrefix); err != nil {
+func (r *cachedFileRepository) MarkDeleting(ctx context.Context, files []models.File) error {
+	if err := r.next.MarkDeleting(ctx, files); err != nil {
 		return err
 	}
 
-	message := cache.CachePathInvalidationMessage{
-		UserID:        userID,
-		OldPathPrefix: oldPathPrefix,
-		NewPathPrefix: newPathPrefix,
+	ctx = context.WithoutCancel(ctx)
+	invalidated := make([]*models.File, 0, len(files))
+	changes := make([]filecache.ListChange, 0, len(files))
+	var folderOwners []uint64
+	for i := range files {
+		invalidated = append(invalidated, &files[i])
+		changes = append(changes, filecache.ListChange{Before: &files[i]})
+		if files[i].IsFolder == 1 && !slices.Contains(folderOwners, files[i].UserID) {
+			folderOwners = append(folderOwners, files[i].UserID)
+		}
 	}
-	messageJSON, _ := json.Marshal(message)
-
-	_, err := r.cache.XAdd(context.Background(), &redis.XAddArgs{
-		Stream: "cache_path_invalidation_stream",
-		MaxLen: 10000,
-		Values: map[string]any{"payload": messageJSON},
-	}).Result()
-
-	if err != nil {
-		logger.Error("Failed to publish cache path invalidation message", zap.Error(err))
+	if err := r.cache.InvalidateFile(ctx, invalidated...); err != nil {
+		logger.Error("MarkDeleting: Failed to invalidate file cache", zap.Int("count", len(files)), zap.Error(err))
 	}
+	if err := r.cache.MutateList(ctx, changes...); err != nil {
+		logger.Error("MarkDeleting: Failed to update file list cache", zap.Int("count", len(files)), zap.Error(err))
+	}
+	r.invalidateChains(ctx, "MarkDeleting", folderOwners...)
 	return nil
 }
 
-func (r *cachedFileRepository) UpdateFileStatus(fileID uint64, status uint8) error {
-	if err := r.next.UpdateFileStatus(fileID, status); err != nil {
-		return err
-	}
-
-	ctx := context.Background()
-	file, err := r.FindByID(fileID)
-	if err != nil {
-		logger.Error("UpdateFileStatus: Failed to find file for cache invalidation", zap.Uint64("fileID", fileID), zap.Error(err))
-		return nil
-	}
-
-	message := cache.CacheUpdateMessage{
-		File: *file,
+func (r *cachedFileRepository) WithTx(tx *gorm.DB) FileRepository {
+	return &cachedFileRepository{
+		next:   r.next.WithTx(tx),
+		cache:  r.cache,
+		outbox: r.outbox.WithTx(tx),
+		inTx:   true,
 	}
-	messageJSON, _ := json.Marshal(message)
-
-	_, streamErr := r.cache.XAdd(ctx, &redis.XAddArgs{
-		Stream: "file_cache_updates",
-		MaxLen: 10000,
-		Values: map[string]any{"payload": messageJSON},
-	}).Result()
+}
 
-	if streamErr != nil {
-		logger.Error("UpdateFileStatus: Failed to publish cache update message", zap.Uint64("fileID", fileID), zap.Error(streamErr))
+func (r *cachedFileRepository) WithPrimary() FileRepository {
+	return &cachedFileRepository{
+		next:   r.next.WithPrimary(),
+		cache:  r.cache,
+		outbox: r.outbox,
+		inTx:   r.inTx,
 	}
-
-	return nil
 }
 
-// Passthrough methods that don't have caching logic
-func (r *cachedFileRepository) FindByPath(path string) (*models.File, error) {
-	return r.next.FindByPath(path)
+// writeWithEvents 在同一事务中执行数据库写入并把 write 返回的消息写入发件箱,
+// 由 OutboxWorker 在事务提交后投递,进程在提交后崩溃也不会丢失缓存更新消息
+func (r *cachedFileRepository) writeWithEvents(ctx context.Context, write func(next FileRepository) ([]models.OutboxEvent, error)) error {
+	return r.outbox.Transaction(ctx, func(tx *gorm.DB) error {
+		events, err := write(r.next.WithTx(tx))
+		if err != nil {
+			return err
+		}
+		return r.outbox.WithTx(tx).Add(ctx, events...)
+	})
 }
 
-func (r *cachedFileRepository) FindByUUID(uuid string) (*models.File, error) {
-	return r.next.FindByUUID(uuid)
+func (r *cachedFileRepository) UpdateFileStatus(ctx context.Context, fileID uint64, status uint8) error {
+	return r.writeWithEvents(ctx, func(next FileRepository) ([]models.OutboxEvent, error) {
+		if err := next.UpdateFileStatus(ctx, fileID, status); err != nil {
+			return nil, err
+		}
+		return fileUpdateEvents(ctx, next, fileID)
+	})
 }
 
-func (r *cachedFileRepository) FindByOssKey(ossKey string) (*models.File, error) {
-	return r.next.FindByOssKey(ossKey)
+func (r *cachedFileRepository) UpdateScanStatus(ctx context.Context, fileID uint64, scanStatus string) error {
+	return r.writeWithEvents(ctx, func(next FileRepository) ([]models.OutboxEvent, error) {
+		if err := next.UpdateScanStatus(ctx, fileID, scanStatus); err != nil {
+			return nil, err
+		}
+		return fileUpdateEvents(ctx, next, fileID)
+	})
 }
 
-func (r *cachedFileRepository) FindByFileName(userID uint64, parentFolderID *uint64, fileName string) (*models.File, error) {
-	return r.next.FindByFileName(userID, parentFolderID, fileName)
+// fileUpdateEvents 在事务中读取更新后的记录生成缓存更新消息,缓存和从库中的可能还是旧值
+func fileUpdateEvents(ctx context.Context, next FileRepository, fileID uint64) ([]models.OutboxEvent, error) {
+	file, err := next.FindByID(ctx, fileID)
+	if errors.Is(err, xerr.ErrFileNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find file for cache update: %w", err)
+	}
+	event, err := models.NewStreamEvent(filecache.UpdateStream, cache.CacheUpdateMessage{File: *file})
+	return []models.OutboxEvent{event}, err
 }
 
-func (r *cachedFileRepository) FindChildrenByPathPrefix(userID uint64, pathPrefix string) ([]models.File, error) {
-	return r.next.FindChildrenByPathPrefix(userID, pathPrefix)
+// folderPathChangeEvent 文件夹移动或重命名时生成路径变化消息,其他情况返回 nil。
+// 新路径在事务中按新的父文件夹链计算
+func folderPathChangeEvent(ctx context.Context, next FileRepository, oldFile, file *models.File) (*models.OutboxEvent, error) {
+	if oldFile.IsFolder != 1 || (oldFile.FileName == file.FileName && isSameFolder(oldFile.ParentFolderID, file.ParentFolderID)) {
+		return nil, nil
+	}
+	moved := *file
+	if err := fillPath(ctx, next, &moved); err != nil {
+		return nil, err
+	}
+	event, err := models.NewStreamEvent(filecache.PathChangeStream, cache.FolderPathChangeMessage{
+		UserID:   file.UserID,
+		FolderID: file.ID,
+		OldPath:  oldFile.Path + oldFile.FileName + "/",
+		NewPath:  moved.Path + moved.FileName + "/",
+	})
+	return &event, err
 }
 
-func (r *cachedFileRepository) CountFilesInStorage(ossKey string, md5Hash string, excludeFileID uint64) (int64, error) {
-	return r.next.CountFilesInStorage(ossKey, md5Hash, excludeFileID)
+func isSameFolder(a, b *uint64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
 }
 
-// private helper methods for caching
-func (r *cachedFileRepository) getFilesFromCacheList(ctx context.Context, listCacheKey string) ([]models.File, error) {
-	keyExists, err := r.cache.Exists(ctx, listCacheKey)
-	if err != nil {
-		logger.Error("getFilesFromCacheList: Error checking key existence in cache", zap.String("listCacheKey", listCacheKey), zap.Error(err))
-		return nil, fmt.Errorf("failed to check cache key existence: %w", err)
+// withPath 缓存中的 Path 是写入缓存时计算的,祖先文件夹之后移动或重命名不会使其失效,命中后按缓存的祖先链重新计算
+func (r *cachedFileRepository) withPath(ctx context.Context, file *models.File) (*models.File, error) {
+	if err := fillPath(ctx, r, file); err != nil {
+		return nil, err
 	}
+	return file, nil
+}
 
-	if !keyExists {
-		return nil, cache.ErrCacheMiss
+// FindFolderChains 先读取缓存的祖先链,未缓存或已失效的一次回源查询。
+// 回源结果按查询前读到的链版本号写入,查询期间有文件夹移动时写入的结果会被视为失效
+func (r *cachedFileRepository) FindFolderChains(ctx context.Context, userID uint64, folderIDs []uint64) (map[uint64][]models.File, error) {
+	if r.inTx || len(folderIDs) == 0 {
+		return r.next.FindFolderChains(ctx, userID, folderIDs)
 	}
 
-	fileIDsStr, err := r.cache.ZRevRange(ctx, listCacheKey, 0, -1).Result()
+	chains, missed, epoch, err := r.cache.GetFolderChains(ctx, userID, folderIDs)
 	if err != nil {
-		if err == redis.Nil {
-			return nil, cache.ErrCacheMiss
-		}
-		logger.Error("Error getting file ID list from cache", zap.String("key", listCacheKey), zap.Error(err))
-		return nil, fmt.Errorf("failed to get file ID list from cache: %w", err)
+		logger.Error("FindFolderChains: Error getting folder chains from cache", zap.Uint64("userID", userID), zap.Error(err))
+		return r.next.FindFolderChains(ctx, userID, folderIDs)
+	}
+	metrics.ObserveCache("folder_chain", len(missed) == 0)
+	if len(missed) == 0 {
+		return chains, nil
 	}
 
-	if len(fileIDsStr) == 0 {
-		logger.Warn("getFilesFromCacheList: Sorted Set exists but is truly empty. Treating as cache miss to force DB refresh.", zap.String("listCacheKey", listCacheKey))
-		return nil, cache.ErrCacheMiss
+	loaded, err := r.next.FindFolderChains(ctx, userID, missed)
+	if err != nil {
+		return nil, err
 	}
+	if err := r.cache.PutFolderChains(ctx, userID, epoch, loaded); err != nil {
+		logger.Error("FindFolderChains: Failed to cache folder chains", zap.Uint64("userID", userID), zap.Error(err))
+	}
+	maps.Copy(chains, loaded)
+	return chains, nil
+}
 
-	if len(fileIDsStr) == 1 && fileIDsStr[0] == "__EMPTY_LIST__" {
-		return []models.File{}, nil
+// invalidateChains 文件夹移动、重命名或删除后使用户缓存的祖先链失效。
+// 事务提交后缓存消费者收到路径变化消息会再失效一次,覆盖提交前被重新写入的旧链
+func (r *cachedFileRepository) invalidateChains(ctx context.Context, op string, userIDs ...uint64) {
+	if err := r.cache.InvalidateFolderChains(ctx, userIDs...); err != nil {
+		logger.Error(op+": Failed to invalidate folder chain cache", zap.Uint64s("userIDs", userIDs), zap.Error(err))
 	}
+}
 
-	var fileIDs []uint64
-	for _, idStr := range fileIDsStr {
-		id, parseErr := strconv.ParseUint(idStr, 10, 64)
-		if parseErr != nil {
-			logger.Error("Failed to parse file ID from cache", zap.String("idStr", idStr), zap.Error(parseErr))
-			continue
-		}
-		if id > 0 {
-			fileIDs = append(fileIDs, id)
+// loadFile 缓存未命中时回源查询并写入缓存,记录不存在时写入短期的"不存在"标记防止缓存穿透
+func (r *cachedFileRepository) loadFile(ctx context.Context, key filecache.Key, load func() (*models.File, error)) (*models.File, error) {
+	file, err := load()
+	if err != nil {
+		if errors.Is(err, xerr.ErrFileNotFound) {
+			if cacheErr := r.cache.PutNotFound(ctx, key); cacheErr != nil {
+				logger.Error("Failed to cache not-found marker", zap.String("key", string(key)), zap.Error(cacheErr))
+			}
 		}
+		return nil, err
 	}
-
-	if len(fileIDs) == 0 {
-		return []models.File{}, nil
+	if err := r.cache.PutFile(ctx, key, file); err != nil {
+		logger.Error("Failed to cache file metadata", zap.String("key", string(key)), zap.Error(err))
 	}
+	return file, nil
+}
 
-	pipe := r.cache.TxPipeline()
-	cmds := make(map[uint64]*redis.StringStringMapCmd)
-	for _, fileID := range fileIDs {
-		metaKey := fmt.Sprintf("file:metadata:%d", fileID)
-		cmds[fileID] = pipe.HGetAll(ctx, metaKey)
-	}
+// Passthrough methods that don't have caching logic
+func (r *cachedFileRepository) FindByPath(ctx context.Context, userID uint64, parentPath string, fileName string) (*models.File, error) {
+	return r.next.FindByPath(ctx, userID, parentPath, fileName)
+}
 
-	_, execErr := pipe.Exec(ctx)
-	if execErr != nil && execErr != redis.Nil {
-		logger.Error("Error executing HGetAll pipeline for files metadata", zap.Error(execErr))
-		return nil, fmt.Errorf("failed to execute HGetAll pipeline: %w", execErr)
-	}
+func (r *cachedFileRepository) FindByUUID(ctx context.Context, uuid string) (*models.File, error) {
+	return r.next.FindByUUID(ctx, uuid)
+}
 
-	var files []models.File
-	var missedIDs []uint64
-	for _, fileID := range fileIDs {
-		cmd := cmds[fileID]
-		fileMap, getErr := cmd.Result()
-
-		if getErr == nil && len(fileMap) > 0 && fileMap["__NOT_FOUND__"] == "" {
-			file, mapErr := mapper.MapToFile(fileMap)
-			if mapErr == nil {
-				files = append(files, *file)
-			} else {
-				logger.Error("Failed to map file hash to struct from cache, will fetch from DB", zap.Uint64("fileID", fileID), zap.Error(mapErr))
-				missedIDs = append(missedIDs, fileID)
-			}
-		} else {
-			if getErr != nil && getErr != redis.Nil {
-				logger.Error("Error getting file metadata hash for ID, will fetch from DB", zap.Uint64("fileID", fileID), zap.Error(getErr))
-			}
-			missedIDs = append(missedIDs, fileID)
-		}
-	}
+func (r *cachedFileRepository) FindByOssKey(ctx context.Context, ossKey string) (*models.File, error) {
+	return r.next.FindByOssKey(ctx, ossKey)
+}
 
-	if len(missedIDs) > 0 {
-		logger.Warn("getFilesFromCacheList: Cache inconsistency detected. Fetching from DB.",
-			zap.String("listCacheKey", listCacheKey),
-			zap.Uint64s("missedFileIDs", missedIDs))
+func (r *cachedFileRepository) FindByFileName(ctx context.Context, userID uint64, parentFolderID *uint64, fileName string) (*models.File, error) {
+	return r.next.FindByFileName(ctx, userID, parentFolderID, fileName)
+}
 
-		// This is a simplification. In a real-world scenario, you might want to fetch from the `next` repository.
-		// However, to avoid circular dependencies and keep the decorator simple, we'll log this.
-		// A more robust solution might involve a specific method in the `next` repo to find by multiple IDs.
-		// For now, we return what we have and let the next request handle the cache miss.
-		// This is a trade-off for simplicity.
-	}
+func (r *cachedFileRepository) SearchByName(ctx context.Context, userID uint64, keyword string, filter models.FileFilter, page, pageSize int) ([]models.File, int64, error) {
+	return r.next.SearchByName(ctx, userID, keyword, filter, page, pageSize)
+}
 
-	return files, nil
+func (r *cachedFileRepository) FindAllByUserID(ctx context.Context, userID uint64) ([]models.File, error) {
+	return r.next.FindAllByUserID(ctx, userID)
 }
 
-func (r *cachedFileRepository) saveFilesToCacheList(ctx context.Context, cacheKey string, files []models.File, scoreFunc func(file models.File) float64) error {
-	pipe := r.cache.TxPipeline()
+func (r *cachedFileRepository) FindExpiredTrash(ctx context.Context, now time.Time, defaultDays int, limit int) ([]models.File, error) {
+	return r.next.FindExpiredTrash(ctx, now, defaultDays, limit)
+}
 
-	if len(files) == 0 {
-		pipe.ZAdd(ctx, cacheKey, &redis.Z{Score: 0, Member: "__EMPTY_LIST__"})
-	} else {
-		var zs []*redis.Z
-		for _, file := range files {
-			fileMap, mapErr := mapper.FileToMap(&file)
-			if mapErr != nil {
-				logger.Error("saveFilesToCacheList: Failed to map models.File to hash for caching", zap.Uint64("fileID", file.ID), zap.Error(mapErr))
-				continue
-			}
-			metaKey := cache.GenerateFileMetadataKey(file.ID)
-			pipe.HMSet(ctx, metaKey, fileMap)
-			pipe.Expire(ctx, metaKey, cache.CacheTTL+time.Duration(rand.Intn(300))*time.Second)
-
-			zs = append(zs, &redis.Z{
-				Score:  scoreFunc(file),
-				Member: strconv.FormatUint(file.ID, 10),
-			})
-		}
-		if len(zs) > 0 {
-			pipe.ZAdd(ctx, cacheKey, zs...)
-		}
-	}
-	pipe.Expire(ctx, cacheKey, cache.CacheTTL+time.Duration(rand.Intn(300))*time.Second)
+func (r *cachedFileRepository) FindRootsByUserID(ctx context.Context, userID uint64) ([]models.File, error) {
+	return r.next.FindRootsByUserID(ctx, userID)
+}
 
-	_, execErr := pipe.Exec(ctx)
-	if execErr != nil {
-		logger.Error("saveFilesToCacheList: Failed to execute Redis pipeline for caching list.", zap.String("key", cacheKey), zap.Error(execErr))
-	}
-	return nil
+func (r *cachedFileRepository) IsInFolder(ctx context.Context, file *models.File, folderID uint64) (bool, error) {
+	return isInFolder(ctx, r, file, folderID)
+}
+
+func (r *cachedFileRepository) FindDescendants(ctx context.Context, userID uint64, folderID uint64, includeDeleted bool) ([]models.File, error) {
+	return r.next.FindDescendants(ctx, userID, folderID, includeDeleted)
+}
+
+func (r *cachedFileRepository) FindForIntegrityCheck(ctx context.Context, limit int) ([]models.File, error) {
+	return r.next.FindForIntegrityCheck(ctx, limit)
+}
+
+// MarkIntegrityChecked 校验时间不输出到 JSON,无需更新缓存
+func (r *cachedFileRepository) MarkIntegrityChecked(ctx context.Context, fileIDs []uint64, checkedAt time.Time) error {
+	return r.next.MarkIntegrityChecked(ctx, fileIDs, checkedAt)
 }
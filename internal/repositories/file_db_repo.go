@@ -1,9 +1,12 @@
 package repositories
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/3Eeeecho/go-clouddisk/internal/models"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
@@ -24,8 +27,8 @@ func NewDBFileRepository(db *gorm.DB) FileRepository {
 	}
 }
 
-func (r *dbFileRepository) Create(file *models.File) error {
-	err := r.db.Create(file).Error
+func (r *dbFileRepository) Create(ctx context.Context, file *models.File) error {
+	err := writeDB(ctx, r.db).Create(file).Error
 	if err != nil {
 		logger.Error("Create: Failed to create file in DB", zap.Error(err), zap.Uint64("userID", file.UserID), zap.String("fileName", file.FileName))
 		return fmt.Errorf("failed to create file: %w", err)
@@ -33,21 +36,46 @@ func (r *dbFileRepository) Create(file *models.File) error {
 	return nil
 }
 
-func (r *dbFileRepository) FindByID(id uint64) (*models.File, error) {
+func (r *dbFileRepository) FindByID(ctx context.Context, id uint64) (*models.File, error) {
 	var file models.File
-	err := r.db.Unscoped().First(&file, id).Error
+	err := readDB(ctx, r.db).Unscoped().First(&file, id).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, xerr.ErrFileNotFound // 文件未找到
 		}
 		return nil, fmt.Errorf("file not found: %w", err)
 	}
+	if err := fillPath(ctx, r, &file); err != nil {
+		return nil, err
+	}
 	return &file, nil
 }
 
-func (r *dbFileRepository) FindByUserIDAndParentFolderID(userID uint64, parentFolderID *uint64) ([]models.File, error) {
+func (r *dbFileRepository) FindByIDs(ctx context.Context, ids []uint64) ([]models.File, error) {
+	var files []models.File
+	if len(ids) == 0 {
+		return files, nil
+	}
+	if err := readDB(ctx, r.db).Unscoped().Where("id IN ?", ids).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	if err := fillListPaths(ctx, r, files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// 排序字段到数据库列的映射
+var fileSortColumns = map[string]string{
+	models.SortByName:      "file_name",
+	models.SortBySize:      "size",
+	models.SortByUpdatedAt: "updated_at",
+}
+
+func (r *dbFileRepository) FindByUserIDAndParentFolderID(ctx context.Context, userID uint64, parentFolderID *uint64, opts models.FileListOptions) ([]models.File, int64, error) {
 	var dbFiles []models.File
-	query := r.db.Where("user_id = ?", userID)
+	var total int64
+	query := applyFileFilter(readDB(ctx, r.db).Model(&models.File{}).Where("user_id = ?", userID), opts.Filter)
 
 	if parentFolderID == nil {
 		query = query.Where("parent_folder_id IS NULL") // 查找根目录
@@ -55,18 +83,54 @@ func (r *dbFileRepository) FindByUserIDAndParentFolderID(userID uint64, parentFo
 		query = query.Where("parent_folder_id = ?", *parentFolderID) // 查找指定文件夹
 	}
 
-	// 优先显示文件夹，然后按文件名排序
-	err := query.Order("is_folder DESC, file_name ASC").Find(&dbFiles).Error
-	if err != nil {
+	if err := query.Count(&total).Error; err != nil {
+		logger.Error("Error counting files from DB", zap.Uint64("userID", userID), zap.Any("parentFolderID", parentFolderID), zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to count files: %w", err)
+	}
+
+	column, ok := fileSortColumns[opts.SortBy]
+	if !ok {
+		column = "file_name"
+	}
+	direction := "ASC"
+	if opts.Order == models.OrderDesc {
+		direction = "DESC"
+	}
+
+	// 优先显示文件夹，然后按指定字段排序,id 保证排序稳定
+	query = query.Order(fmt.Sprintf("is_folder DESC, %s %s, id ASC", column, direction))
+	if opts.Cursor != nil {
+		// 游标分页从上一页最后一条记录之后继续,不需要跳过前面的行
+		if opts.Cursor.ID != 0 {
+			nameOp := ">"
+			if opts.Order == models.OrderDesc {
+				nameOp = "<"
+			}
+			c := opts.Cursor
+			query = query.Where("(is_folder < ? OR (is_folder = ? AND (file_name "+nameOp+" ? OR (file_name = ? AND id > ?))))",
+				c.IsFolder, c.IsFolder, c.FileName, c.FileName, c.ID)
+		}
+		if opts.PageSize > 0 {
+			query = query.Limit(opts.PageSize)
+		}
+	} else if opts.PageSize > 0 {
+		page := max(opts.Page, 1)
+		query = query.Offset((page - 1) * opts.PageSize).Limit(opts.PageSize)
+	}
+
+	if err := query.Find(&dbFiles).Error; err != nil {
 		logger.Error("Error finding files from DB", zap.Uint64("userID", userID), zap.Any("parentFolderID", parentFolderID), zap.Error(err))
-		return nil, fmt.Errorf("failed to find files: %w", err)
+		return nil, 0, fmt.Errorf("failed to find files: %w", err)
 	}
-	return dbFiles, nil
+	if err := fillListPaths(ctx, r, dbFiles); err != nil {
+		return nil, 0, err
+	}
+	return dbFiles, total, nil
 }
 
-func (r *dbFileRepository) FindFileByMD5Hash(md5Hash string) (*models.File, error) {
+func (r *dbFileRepository) FindFileByMD5Hash(ctx context.Context, userID uint64, md5Hash string) (*models.File, error) {
 	var file models.File
-	err := r.db.Where("md5_hash = ? AND is_folder = 0 AND status = 1", md5Hash).First(&file).Error
+	err := readDB(ctx, r.db).Where("user_id = ? AND md5_hash = ? AND is_folder = 0 AND status = 1", userID, md5Hash).First(&file).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, xerr.ErrFileNotFound // 文件未找到
@@ -74,117 +138,497 @@ func (r *dbFileRepository) FindFileByMD5Hash(md5Hash string) (*models.File, erro
 		log.Printf("Error finding file by MD5 hash %s: %v", md5Hash, err)
 		return nil, err
 	}
+	if err := fillPath(ctx, r, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+func (r *dbFileRepository) FindFileBySHA256Hash(ctx context.Context, userID uint64, sha256Hash string) (*models.File, error) {
+	var file models.File
+	err := readDB(ctx, r.db).Where("user_id = ? AND sha256_hash = ? AND is_folder = 0 AND status = 1", userID, sha256Hash).First(&file).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, xerr.ErrFileNotFound
+		}
+		return nil, err
+	}
+	if err := fillPath(ctx, r, &file); err != nil {
+		return nil, err
+	}
 	return &file, nil
 }
 
-func (r *dbFileRepository) FindDeletedFilesByUserID(userID uint64) ([]models.File, error) {
+func (r *dbFileRepository) FindSharedFileBySHA256Hash(ctx context.Context, excludeUserID uint64, sha256Hash string) (*models.File, error) {
+	db := readDB(ctx, r.db)
+	optedOut := db.Session(&gorm.Session{NewDB: true}).Model(&models.User{}).Select("id").Where("dedup_opt_out = ?", true)
+
+	var file models.File
+	err := db.Where("sha256_hash = ? AND is_folder = 0 AND status = 1 AND user_id <> ?", sha256Hash, excludeUserID).
+		Where("user_id NOT IN (?)", optedOut).
+		First(&file).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, xerr.ErrFileNotFound
+		}
+		return nil, err
+	}
+	if err := fillPath(ctx, r, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+func (r *dbFileRepository) FindDeletedFilesByUserID(ctx context.Context, userID uint64, opts models.TrashListOptions) ([]models.File, error) {
 	var dbFiles []models.File
-	err := r.db.Unscoped().Where("user_id = ?", userID).Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&dbFiles).Error
+	query := readDB(ctx, r.db).Unscoped().Where("user_id = ? AND status <> ?", userID, models.StatusDeleting).Where("deleted_at IS NOT NULL")
+	if opts.ParentFolderID != nil {
+		if *opts.ParentFolderID == 0 {
+			query = query.Where("parent_folder_id IS NULL")
+		} else {
+			query = query.Where("parent_folder_id = ?", *opts.ParentFolderID)
+		}
+	}
+	if c := opts.Cursor; c != nil && c.ID != 0 && c.DeletedAt != nil {
+		query = query.Where("(deleted_at < ? OR (deleted_at = ? AND id < ?))", *c.DeletedAt, *c.DeletedAt, c.ID)
+	}
+	if opts.Limit > 0 {
+		query = query.Limit(opts.Limit)
+	}
+	err := query.Order("deleted_at DESC, id DESC").Find(&dbFiles).Error
 	if err != nil {
 		logger.Error("Error finding deleted files from DB", zap.Uint64("userID", userID), zap.Error(err))
 		return nil, fmt.Errorf("查询已删除文件列表失败: %w", err)
 	}
+	if err := fillListPaths(ctx, r, dbFiles); err != nil {
+		return nil, err
+	}
 	return dbFiles, nil
 }
 
-func (r *dbFileRepository) FindByUUID(uuid string) (*models.File, error) {
+func (r *dbFileRepository) FindExpiredTrash(ctx context.Context, now time.Time, defaultDays int, limit int) ([]models.File, error) {
+	var files []models.File
+	err := readDB(ctx, r.db).Unscoped().Model(&models.File{}).Select("files.*").
+		Joins("JOIN users ON users.id = files.user_id").
+		Where("files.deleted_at IS NOT NULL AND files.status <> ?", models.StatusDeleting).
+		Where("users.status = ?", models.UserStatusActive).
+		Where("files.deleted_at < DATE_SUB(?, INTERVAL IF(users.trash_retention_days > 0, users.trash_retention_days, ?) DAY)", now, defaultDays).
+		Order("files.deleted_at ASC, files.id ASC").Limit(limit).Find(&files).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find expired trash: %w", err)
+	}
+	if err := fillListPaths(ctx, r, files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (r *dbFileRepository) CountDeletedByParentIDs(ctx context.Context, userID uint64, parentIDs []uint64) (map[uint64]int64, error) {
+	counts := make(map[uint64]int64)
+	if len(parentIDs) == 0 {
+		return counts, nil
+	}
+
+	folderIDs := make([]uint64, 0, len(parentIDs))
+	includeRoot := false
+	for _, id := range parentIDs {
+		if id == 0 {
+			includeRoot = true
+		} else {
+			folderIDs = append(folderIDs, id)
+		}
+	}
+
+	db := readDB(ctx, r.db)
+	parentCond := db.Session(&gorm.Session{NewDB: true})
+	if len(folderIDs) > 0 {
+		parentCond = parentCond.Or("parent_folder_id IN ?", folderIDs)
+	}
+	if includeRoot {
+		parentCond = parentCond.Or("parent_folder_id IS NULL")
+	}
+
+	var rows []struct {
+		ParentID uint64
+		Count    int64
+	}
+	err := db.Unscoped().Model(&models.File{}).
+		Select("COALESCE(parent_folder_id, 0) AS parent_id, COUNT(*) AS count").
+		Where("user_id = ? AND status <> ? AND deleted_at IS NOT NULL", userID, models.StatusDeleting).
+		Where(parentCond).
+		Group("COALESCE(parent_folder_id, 0)").
+		Scan(&rows).Error
+	if err != nil {
+		logger.Error("Error counting deleted files by parent", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("failed to count deleted files: %w", err)
+	}
+	for _, row := range rows {
+		counts[row.ParentID] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *dbFileRepository) FindByUUID(ctx context.Context, uuid string) (*models.File, error) {
 	var file models.File
-	err := r.db.Where("uuid = ?", uuid).First(&file).Error
+	err := readDB(ctx, r.db).Where("uuid = ?", uuid).First(&file).Error
 	if err != nil {
 		log.Printf("Error finding file by UUID %s: %v", uuid, err)
 		return nil, err
 	}
+	if err := fillPath(ctx, r, &file); err != nil {
+		return nil, err
+	}
 	return &file, nil
 }
 
-func (r *dbFileRepository) FindByOssKey(ossKey string) (*models.File, error) {
+func (r *dbFileRepository) FindByOssKey(ctx context.Context, ossKey string) (*models.File, error) {
 	var file models.File
-	err := r.db.Where("oss_key = ?", ossKey).First(&file).Error
+	err := readDB(ctx, r.db).Where("oss_key = ?", ossKey).First(&file).Error
 	if err != nil {
 		log.Printf("Error finding file by OssKey %s: %v", ossKey, err)
 		return nil, err
 	}
+	if err := fillPath(ctx, r, &file); err != nil {
+		return nil, err
+	}
 	return &file, nil
 }
 
-func (r *dbFileRepository) FindByFileName(userID uint64, parentFolderID *uint64, fileName string) (*models.File, error) {
+func (r *dbFileRepository) FindByFileName(ctx context.Context, userID uint64, parentFolderID *uint64, fileName string) (*models.File, error) {
 	var file models.File
-	query := r.db.Where("user_id = ? AND file_name = ?", userID, fileName)
+	query := readDB(ctx, r.db).Where("user_id = ? AND file_name = ?", userID, fileName)
 	if parentFolderID == nil {
 		query = query.Where("parent_folder_id IS NULL")
 	} else {
 		query = query.Where("parent_folder_id = ?", *parentFolderID)
 	}
-	err := query.First(&file).Error
-	return &file, err
+	if err := query.First(&file).Error; err != nil {
+		return &file, err
+	}
+	return &file, fillPath(ctx, r, &file)
 }
 
-func (r *dbFileRepository) FindByPath(path string) (*models.File, error) {
+// FindByPath 按父目录的逻辑路径(如 "/a/b/")和名称定位正常状态的文件。
+// 路径的每一级作为一行参数,递归 CTE 从根目录沿 parent_folder_id 逐级匹配名称,一次查询完成
+func (r *dbFileRepository) FindByPath(ctx context.Context, userID uint64, parentPath string, fileName string) (*models.File, error) {
+	var segments []string
+	for _, name := range strings.Split(parentPath, "/") {
+		if name != "" {
+			segments = append(segments, name)
+		}
+	}
+	if len(segments) > maxFolderDepth {
+		return nil, xerr.ErrFileNotFound
+	}
+
+	query := readDB(ctx, r.db).Where("user_id = ? AND file_name = ? AND status = ?", userID, fileName, models.StatusNormal)
+	if len(segments) == 0 {
+		query = query.Where("parent_folder_id IS NULL")
+	} else {
+		rows := make([]string, len(segments))
+		args := make([]any, 0, len(segments)*2+5)
+		for i, name := range segments {
+			rows[i] = "SELECT ?, ?"
+			args = append(args, i+1, name)
+		}
+		args = append(args, userID, models.StatusNormal, userID, models.StatusNormal, len(segments))
+		query = query.Where("parent_folder_id = (?)", gorm.Expr(`
+WITH RECURSIVE segments (depth, name) AS (
+	`+strings.Join(rows, " UNION ALL ")+`
+), walk (id, depth) AS (
+	SELECT f.id, 1 FROM files f
+	JOIN segments s ON s.depth = 1 AND f.file_name = s.name
+	WHERE f.user_id = ? AND f.parent_folder_id IS NULL AND f.is_folder = 1 AND f.status = ? AND f.deleted_at IS NULL
+	UNION ALL
+	SELECT f.id, w.depth + 1 FROM walk w
+	JOIN segments s ON s.depth = w.depth + 1
+	JOIN files f ON f.parent_folder_id = w.id AND f.file_name = s.name
+	WHERE f.user_id = ? AND f.is_folder = 1 AND f.status = ? AND f.deleted_at IS NULL
+)
+SELECT id FROM walk WHERE depth = ? ORDER BY id LIMIT 1`, args...))
+	}
+
 	var file models.File
-	err := r.db.Where("storage_path = ?", path).First(&file).Error
-	if err != nil {
-		log.Printf("Error finding file by path %s: %v", path, err)
-		return nil, err
+	if err := query.First(&file).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, xerr.ErrFileNotFound
+		}
+		logger.Error("FindByPath: Error finding file by path", zap.Uint64("userID", userID), zap.String("parentPath", parentPath), zap.String("fileName", fileName), zap.Error(err))
+		return nil, fmt.Errorf("failed to find file by path: %w", err)
 	}
-	return &file, nil
+	return &file, fillPath(ctx, r, &file)
 }
 
-func (r *dbFileRepository) Update(file *models.File) error {
-	err := r.db.Save(file).Error
-	if err != nil {
-		logger.Error("Update: Failed to update file in DB", zap.Error(err), zap.Uint64("fileID", file.ID), zap.Uint64("userID", file.UserID))
-		return fmt.Errorf("failed to update file: %w", err)
+// fileUpdateColumns Update 保存的字段。扫描状态和完整性校验时间由后台任务单独写入,
+// 不随元数据更新一起保存,避免用读取时的旧值覆盖
+var fileUpdateColumns = []string{
+	"UserID", "ParentFolderID", "FileName", "IsFolder", "Size", "MimeType",
+	"OssBucket", "OssKey", "VersionID", "MD5Hash", "SHA256Hash", "Status", "Version",
+	"SkipRecycleBin", "IsLink", "LinkTargetID", "Attributes", "UpdatedAt", "DeletedAt",
+}
+
+// Update 保存文件的元数据字段(见 fileUpdateColumns),只有数据库中的版本号与 file.Version 一致时才会更新,成功后 file.Version 加一。
+// 版本号不一致说明文件在读取之后已被其他请求修改,返回 xerr.ErrVersionConflict
+func (r *dbFileRepository) Update(ctx context.Context, file *models.File) error {
+	expected := file.Version
+	file.Version = expected + 1
+	// 恢复回收站中的文件时记录仍处于软删除状态,因此不能使用默认的软删除过滤
+	result := writeDB(ctx, r.db).Unscoped().Model(file).Select(fileUpdateColumns).Where("version = ?", expected).Updates(file)
+	if result.Error != nil {
+		file.Version = expected
+		logger.Error("Update: Failed to update file in DB", zap.Error(result.Error), zap.Uint64("fileID", file.ID), zap.Uint64("userID", file.UserID))
+		return fmt.Errorf("failed to update file: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		file.Version = expected
+		return fmt.Errorf("file %d is no longer at version %d: %w", file.ID, expected, xerr.ErrVersionConflict)
 	}
 	return nil
 }
 
-func (r *dbFileRepository) SoftDelete(id uint64) error {
-	return r.db.Delete(&models.File{}, id).Error
+func (r *dbFileRepository) SoftDelete(ctx context.Context, id uint64) error {
+	return writeDB(ctx, r.db).Delete(&models.File{}, id).Error
 }
 
-func (r *dbFileRepository) PermanentDelete(tx *gorm.DB, fileID uint64) error {
-	err := tx.Unscoped().Delete(&models.File{}, fileID).Error
+func (r *dbFileRepository) PermanentDelete(ctx context.Context, tx *gorm.DB, fileID uint64) error {
+	err := tx.WithContext(ctx).Unscoped().Delete(&models.File{}, fileID).Error
 	if err != nil {
 		return fmt.Errorf("failed to permanently delete file: %w", err)
 	}
 	return nil
 }
 
-func (r *dbFileRepository) FindChildrenByPathPrefix(userID uint64, pathPrefix string) ([]models.File, error) {
+// likeEscaper 转义 LIKE 中的通配符,使关键字按字面匹配
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// applyFileFilter 把过滤条件加到文件查询上,Status 为 trashed 或 all 时包含已软删除的记录,
+// 但不包含等待彻底删除的记录
+func applyFileFilter(query *gorm.DB, filter models.FileFilter) *gorm.DB {
+	switch filter.Status {
+	case models.FilterStatusTrashed:
+		query = query.Unscoped().Where("deleted_at IS NOT NULL AND status <> ?", models.StatusDeleting)
+	case models.FilterStatusAll:
+		query = query.Unscoped().Where("status <> ?", models.StatusDeleting)
+	}
+	if filter.MinSize != nil || filter.MaxSize != nil {
+		query = query.Where("is_folder = 0")
+		if filter.MinSize != nil {
+			query = query.Where("size >= ?", *filter.MinSize)
+		}
+		if filter.MaxSize != nil {
+			query = query.Where("size <= ?", *filter.MaxSize)
+		}
+	}
+	if filter.MimePrefix != "" {
+		query = query.Where("mime_type LIKE ?", likeEscaper.Replace(filter.MimePrefix)+"%")
+	}
+	if filter.ModifiedAfter != nil {
+		query = query.Where("updated_at > ?", *filter.ModifiedAfter)
+	}
+	return query
+}
+
+func (r *dbFileRepository) SearchByName(ctx context.Context, userID uint64, keyword string, filter models.FileFilter, page, pageSize int) ([]models.File, int64, error) {
 	var files []models.File
-	err := r.db.Where("user_id = ? AND path LIKE ?", userID, pathPrefix+"%").Find(&files).Error
+	var total int64
+	query := readDB(ctx, r.db).Model(&models.File{}).
+		Where("user_id = ? AND status = ?", userID, models.StatusNormal).
+		Where("file_name LIKE ?", "%"+likeEscaper.Replace(keyword)+"%")
+	query = applyFileFilter(query, filter)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count files: %w", err)
+	}
+	page = max(page, 1)
+	err := query.Order("is_folder DESC, file_name ASC, id ASC").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&files).Error
 	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search files: %w", err)
+	}
+	if err := fillListPaths(ctx, r, files); err != nil {
+		return nil, 0, err
+	}
+	return files, total, nil
+}
+
+func (r *dbFileRepository) FindAllByUserID(ctx context.Context, userID uint64) ([]models.File, error) {
+	var files []models.File
+	err := readDB(ctx, r.db).Where("user_id = ?", userID).Find(&files).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := fillListPaths(ctx, r, files); err != nil {
 		return nil, err
 	}
 	return files, nil
 }
 
-func (r *dbFileRepository) UpdateFilesPathInBatch(userID uint64, oldPathPrefix, newPathPrefix string) error {
-	return r.db.Model(&models.File{}).
-		Where("user_id = ? AND path LIKE ?", userID, oldPathPrefix+"%").
-		Update("path", gorm.Expr("REPLACE(path, ?, ?)", oldPathPrefix, newPathPrefix)).Error
+func (r *dbFileRepository) FindRootsByUserID(ctx context.Context, userID uint64) ([]models.File, error) {
+	var files []models.File
+	err := readDB(ctx, r.db).Unscoped().Where("user_id = ? AND parent_folder_id IS NULL", userID).
+		Order("id ASC").Find(&files).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find root files: %w", err)
+	}
+	if err := fillListPaths(ctx, r, files); err != nil {
+		return nil, err
+	}
+	return files, nil
 }
 
-func (r *dbFileRepository) UpdateFileStatus(fileID uint64, status uint8) error {
-	if err := r.db.Model(&models.File{}).Where("id = ?", fileID).Update("status", status).Error; err != nil {
+// maxFolderDepth 递归展开子树的最大层数,低于 MySQL 默认的 cte_max_recursion_depth(1000),
+// 数据异常出现环时查询也能结束而不是报错
+const maxFolderDepth = 512
+
+// markDeletingBatchSize 标记待删除时每条 UPDATE 语句包含的记录数,避免 IN 列表过长
+const markDeletingBatchSize = 1000
+
+// FindDescendants 使用递归 CTE(需要 MySQL 8)沿 parent_folder_id 展开子树,不依赖 path 字段是否准确
+func (r *dbFileRepository) FindDescendants(ctx context.Context, userID uint64, folderID uint64, includeDeleted bool) ([]models.File, error) {
+	anchorFilter, recursiveFilter := " AND deleted_at IS NULL", " AND f.deleted_at IS NULL"
+	if includeDeleted {
+		anchorFilter, recursiveFilter = "", ""
+	}
+
+	var files []models.File
+	err := readDB(ctx, r.db).Raw(`
+WITH RECURSIVE subtree (id, depth) AS (
+	SELECT id, 1 FROM files
+	WHERE user_id = ? AND parent_folder_id = ?`+anchorFilter+`
+	UNION
+	SELECT f.id, s.depth + 1 FROM files f
+	JOIN subtree s ON f.parent_folder_id = s.id
+	WHERE f.user_id = ? AND s.depth < ?`+recursiveFilter+`
+)
+SELECT files.* FROM files
+JOIN (SELECT id, MIN(depth) AS depth FROM subtree GROUP BY id) t ON files.id = t.id
+ORDER BY t.depth ASC, files.is_folder DESC, files.file_name ASC, files.id ASC`,
+		userID, folderID, userID, maxFolderDepth).Scan(&files).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find descendants: %w", err)
+	}
+	if err := fillListPaths(ctx, r, files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// FindFolderChains 使用递归 CTE 沿 parent_folder_id 向上查找,一次查询得到所有文件夹的祖先链。
+// 回收站中的祖先也包含在内,回收站中的文件仍按删除前的位置显示路径
+func (r *dbFileRepository) FindFolderChains(ctx context.Context, userID uint64, folderIDs []uint64) (map[uint64][]models.File, error) {
+	chains := make(map[uint64][]models.File, len(folderIDs))
+	if len(folderIDs) == 0 {
+		return chains, nil
+	}
+
+	var rows []struct {
+		FolderID       uint64
+		ID             uint64
+		ParentFolderID *uint64
+		FileName       string
+	}
+	err := readDB(ctx, r.db).Raw(`
+WITH RECURSIVE chain (folder_id, id, parent_folder_id, file_name, depth) AS (
+	SELECT id, id, parent_folder_id, file_name, 0 FROM files
+	WHERE user_id = ? AND id IN ?
+	UNION ALL
+	SELECT c.folder_id, f.id, f.parent_folder_id, f.file_name, c.depth + 1 FROM files f
+	JOIN chain c ON f.id = c.parent_folder_id
+	WHERE c.depth < ?
+)
+SELECT folder_id, id, parent_folder_id, file_name FROM chain
+ORDER BY folder_id ASC, depth DESC`,
+		userID, folderIDs, maxFolderDepth).Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find folder chains: %w", err)
+	}
+	for _, row := range rows {
+		chains[row.FolderID] = append(chains[row.FolderID], models.File{
+			ID:             row.ID,
+			UserID:         userID,
+			ParentFolderID: row.ParentFolderID,
+			FileName:       row.FileName,
+			IsFolder:       1,
+		})
+	}
+	return chains, nil
+}
+
+func (r *dbFileRepository) IsInFolder(ctx context.Context, file *models.File, folderID uint64) (bool, error) {
+	return isInFolder(ctx, r, file, folderID)
+}
+
+func (r *dbFileRepository) WithTx(tx *gorm.DB) FileRepository {
+	return NewDBFileRepository(tx)
+}
+
+func (r *dbFileRepository) WithPrimary() FileRepository {
+	return NewDBFileRepository(primaryDB(r.db))
+}
+
+func (r *dbFileRepository) UpdateFileStatus(ctx context.Context, fileID uint64, status uint8) error {
+	if err := writeDB(ctx, r.db).Model(&models.File{}).Where("id = ?", fileID).Updates(map[string]any{
+		"status":  status,
+		"version": gorm.Expr("version + 1"),
+	}).Error; err != nil {
 		logger.Error("UpdateFileStatus: Failed to update file status in DB", zap.Uint64("fileID", fileID), zap.Uint8("status", status), zap.Error(err))
 		return fmt.Errorf("failed to update file status: %w", err)
 	}
 	return nil
 }
 
-func (r *dbFileRepository) CountFilesInStorage(ossKey string, md5Hash string, excludeFileID uint64) (int64, error) {
-	var count int64
-	err := r.db.Model(&models.File{}).
-		Where("oss_key = ? AND md5_hash = ? AND status = 1 AND id != ?", ossKey, md5Hash, excludeFileID).
-		Count(&count).Error
+func (r *dbFileRepository) MarkDeleting(ctx context.Context, files []models.File) error {
+	ids := make([]uint64, 0, len(files))
+	for _, file := range files {
+		ids = append(ids, file.ID)
+	}
+	// 回收站中的记录保留原删除时间
+	for start := 0; start < len(ids); start += markDeletingBatchSize {
+		batch := ids[start:min(start+markDeletingBatchSize, len(ids))]
+		err := writeDB(ctx, r.db).Unscoped().Model(&models.File{}).Where("id IN ?", batch).Updates(map[string]any{
+			"status":     models.StatusDeleting,
+			"deleted_at": gorm.Expr("COALESCE(deleted_at, ?)", time.Now()),
+			"version":    gorm.Expr("version + 1"),
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to mark files deleting: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *dbFileRepository) UpdateScanStatus(ctx context.Context, fileID uint64, scanStatus string) error {
+	if err := writeDB(ctx, r.db).Model(&models.File{}).Where("id = ?", fileID).Update("scan_status", scanStatus).Error; err != nil {
+		logger.Error("UpdateScanStatus: Failed to update scan status in DB", zap.Uint64("fileID", fileID), zap.String("scanStatus", scanStatus), zap.Error(err))
+		return fmt.Errorf("failed to update scan status: %w", err)
+	}
+	return nil
+}
+
+func (r *dbFileRepository) FindForIntegrityCheck(ctx context.Context, limit int) ([]models.File, error) {
+	var files []models.File
+	// MySQL 升序排列时 NULL 在前,从未校验过的文件优先
+	err := readDB(ctx, r.db).Where("is_folder = 0 AND status = ? AND oss_key IS NOT NULL", models.StatusNormal).
+		Order("integrity_checked_at ASC, id ASC").
+		Limit(limit).
+		Find(&files).Error
 	if err != nil {
-		logger.Error("Failed to count files in storage for ossKey",
-			zap.String("ossKey", ossKey),
-			zap.String("md5Hash", md5Hash),
-			zap.Uint64("excludeFileID", excludeFileID),
-			zap.Error(err))
-		return 0, fmt.Errorf("failed to count files in storage: %w", err)
+		return nil, fmt.Errorf("failed to find files for integrity check: %w", err)
+	}
+	if err := fillListPaths(ctx, r, files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (r *dbFileRepository) MarkIntegrityChecked(ctx context.Context, fileIDs []uint64, checkedAt time.Time) error {
+	if len(fileIDs) == 0 {
+		return nil
 	}
-	return count, nil
+	// UpdateColumn 不修改 updated_at,校验不算文件变更
+	if err := writeDB(ctx, r.db).Model(&models.File{}).Where("id IN ?", fileIDs).UpdateColumn("integrity_checked_at", checkedAt).Error; err != nil {
+		return fmt.Errorf("failed to mark integrity checked: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+)
+
+// 文件的层级只由 parent_folder_id 决定,数据库中不保存路径。
+// 移动或重命名文件夹只更新文件夹自身,仓库返回的文件统一按父文件夹链计算 Path
+
+// folderPath 返回文件夹链对应的路径,即链中最后一个文件夹下子项的父路径,如 "/a/b/"
+func folderPath(chain []models.File) string {
+	var b strings.Builder
+	b.WriteString("/")
+	for _, folder := range chain {
+		b.WriteString(folder.FileName)
+		b.WriteString("/")
+	}
+	return b.String()
+}
+
+// fillPaths 按父文件夹当前的位置重新计算 files 的 Path,同一个用户的父文件夹一次查询。
+// 父文件夹链查不到(如已被彻底删除)时 Path 保持不变
+func fillPaths(ctx context.Context, repo FileRepository, files []*models.File) error {
+	parentIDs := map[uint64][]uint64{}
+	for _, file := range files {
+		if file.ParentFolderID == nil {
+			file.Path = "/"
+		} else if !slices.Contains(parentIDs[file.UserID], *file.ParentFolderID) {
+			parentIDs[file.UserID] = append(parentIDs[file.UserID], *file.ParentFolderID)
+		}
+	}
+
+	for userID, ids := range parentIDs {
+		chains, err := repo.FindFolderChains(ctx, userID, ids)
+		if err != nil {
+			return fmt.Errorf("failed to resolve file paths: %w", err)
+		}
+		for _, file := range files {
+			if file.ParentFolderID == nil || file.UserID != userID {
+				continue
+			}
+			if chain, ok := chains[*file.ParentFolderID]; ok {
+				file.Path = folderPath(chain)
+			}
+		}
+	}
+	return nil
+}
+
+// isInFolder 判断文件的父文件夹链中是否包含 folderID
+func isInFolder(ctx context.Context, repo FileRepository, file *models.File, folderID uint64) (bool, error) {
+	if file.ParentFolderID == nil {
+		return false, nil
+	}
+	chains, err := repo.FindFolderChains(ctx, file.UserID, []uint64{*file.ParentFolderID})
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(chains[*file.ParentFolderID], func(folder models.File) bool { return folder.ID == folderID }), nil
+}
+
+// fillPath 重新计算单个文件的 Path
+func fillPath(ctx context.Context, repo FileRepository, file *models.File) error {
+	return fillPaths(ctx, repo, []*models.File{file})
+}
+
+// fillListPaths 重新计算列表中每个文件的 Path
+func fillListPaths(ctx context.Context, repo FileRepository, files []models.File) error {
+	ptrs := make([]*models.File, len(files))
+	for i := range files {
+		ptrs[i] = &files[i]
+	}
+	return fillPaths(ctx, repo, ptrs)
+}
@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FilePermissionRepository 定义了协作授权的数据库操作接口
+type FilePermissionRepository interface {
+	// Upsert 创建授权,同一文件夹对同一用户已有授权时更新权限级别
+	Upsert(permission *models.FilePermission) error
+	Delete(fileID, granteeID uint64) (int64, error)
+	FindByFileID(fileID uint64) ([]models.FilePermission, error)
+	FindByGranteeID(granteeID uint64) ([]models.FilePermission, error)
+	FindByGranteeAndFileIDs(granteeID, ownerID uint64, fileIDs []uint64) ([]models.FilePermission, error)
+}
+
+type filePermissionRepository struct {
+	db *gorm.DB
+}
+
+// NewFilePermissionRepository 创建新的 filePermissionRepository 实例
+func NewFilePermissionRepository(db *gorm.DB) FilePermissionRepository {
+	return &filePermissionRepository{db: db}
+}
+
+func (r *filePermissionRepository) Upsert(permission *models.FilePermission) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "file_id"}, {Name: "grantee_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"permission", "updated_at"}),
+	}).Create(permission).Error
+}
+
+// Delete 撤销授权,返回删除的记录数
+func (r *filePermissionRepository) Delete(fileID, granteeID uint64) (int64, error) {
+	result := r.db.Where("file_id = ? AND grantee_id = ?", fileID, granteeID).Delete(&models.FilePermission{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *filePermissionRepository) FindByFileID(fileID uint64) ([]models.FilePermission, error) {
+	var permissions []models.FilePermission
+	err := r.db.Where("file_id = ?", fileID).Order("created_at asc").Find(&permissions).Error
+	return permissions, err
+}
+
+// FindByGranteeID 查找授予某用户的全部授权,用于"与我共享"列表
+func (r *filePermissionRepository) FindByGranteeID(granteeID uint64) ([]models.FilePermission, error) {
+	var permissions []models.FilePermission
+	err := r.db.Preload("Folder").Where("grantee_id = ?", granteeID).Order("created_at desc").Find(&permissions).Error
+	return permissions, err
+}
+
+// FindByGranteeAndFileIDs 查找某所有者在指定文件夹上授予某用户的授权,用于按文件的祖先链校验权限
+func (r *filePermissionRepository) FindByGranteeAndFileIDs(granteeID, ownerID uint64, fileIDs []uint64) ([]models.FilePermission, error) {
+	var permissions []models.FilePermission
+	if len(fileIDs) == 0 {
+		return permissions, nil
+	}
+	err := r.db.Preload("Folder").Where("grantee_id = ? AND owner_id = ? AND file_id IN ?", granteeID, ownerID, fileIDs).Find(&permissions).Error
+	return permissions, err
+}
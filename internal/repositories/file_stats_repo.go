@@ -0,0 +1,165 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FileStatsRepository interface {
+	FindByFileIDs(ctx context.Context, fileIDs []uint64) ([]models.FileStats, error)
+	// Upsert 写入或覆盖文件夹的统计结果
+	Upsert(ctx context.Context, stats *models.FileStats) error
+	// Aggregate 统计文件夹子树中所有正常状态的文件和文件夹
+	Aggregate(ctx context.Context, userID uint64, folderID uint64) (*models.FileStats, error)
+	// AggregateDeleted 统计用户回收站中的条目
+	AggregateDeleted(ctx context.Context, userID uint64) (*models.TrashStats, error)
+	// AggregateByType 按 MIME 类型分类统计用户正常状态的文件
+	AggregateByType(ctx context.Context, userID uint64) ([]models.TypeUsage, error)
+	// TopFolders 返回根目录下递归大小最大的 limit 个文件夹,尚未统计过的文件夹不包含在内
+	TopFolders(ctx context.Context, userID uint64, limit int) ([]models.FolderUsage, error)
+
+	// FindUsage 读取用户的用量汇总,尚未计算过时返回 nil
+	FindUsage(ctx context.Context, userID uint64) (*models.UserUsage, error)
+	// UpsertUsage 写入或覆盖用户的用量汇总,不修改用量预警时间
+	UpsertUsage(ctx context.Context, usage *models.UserUsage) error
+	// MarkQuotaWarned 记录用量预警时间,已经记录过时返回 false,保证同一次超限只提醒一次
+	MarkQuotaWarned(ctx context.Context, userID uint64, at time.Time) (bool, error)
+	// ClearQuotaWarning 用量回落到阈值以下后清空预警时间
+	ClearQuotaWarning(ctx context.Context, userID uint64) error
+}
+
+// usageTypeExpr 按 MIME 类型归类文件的 SQL 表达式,与 models.UsageType* 对应
+const usageTypeExpr = "CASE " +
+	"WHEN mime_type LIKE 'image/%' THEN '" + models.UsageTypeImage + "' " +
+	"WHEN mime_type LIKE 'video/%' THEN '" + models.UsageTypeVideo + "' " +
+	"WHEN mime_type LIKE 'text/%' OR mime_type = 'application/pdf' OR mime_type = 'application/rtf' " +
+	"OR mime_type = 'application/msword' OR mime_type LIKE 'application/vnd.ms-%' " +
+	"OR mime_type LIKE 'application/vnd.openxmlformats-officedocument.%' " +
+	"OR mime_type LIKE 'application/vnd.oasis.opendocument.%' THEN '" + models.UsageTypeDocument + "' " +
+	"ELSE '" + models.UsageTypeOther + "' END"
+
+type fileStatsRepository struct {
+	db *gorm.DB
+}
+
+func NewFileStatsRepository(db *gorm.DB) FileStatsRepository {
+	return &fileStatsRepository{db: db}
+}
+
+func (r *fileStatsRepository) FindByFileIDs(ctx context.Context, fileIDs []uint64) ([]models.FileStats, error) {
+	var stats []models.FileStats
+	if len(fileIDs) == 0 {
+		return stats, nil
+	}
+	err := readDB(ctx, r.db).Where("file_id IN ?", fileIDs).Find(&stats).Error
+	return stats, err
+}
+
+func (r *fileStatsRepository) Upsert(ctx context.Context, stats *models.FileStats) error {
+	return writeDB(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "file_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"file_count", "folder_count", "total_size", "updated_at"}),
+	}).Create(stats).Error
+}
+
+// Aggregate 使用递归 CTE 沿 parent_folder_id 展开子树,与 FindDescendants 相同
+func (r *fileStatsRepository) Aggregate(ctx context.Context, userID uint64, folderID uint64) (*models.FileStats, error) {
+	var stats models.FileStats
+	err := readDB(ctx, r.db).Raw(`
+WITH RECURSIVE subtree (id, depth) AS (
+	SELECT id, 1 FROM files
+	WHERE user_id = ? AND parent_folder_id = ? AND deleted_at IS NULL
+	UNION
+	SELECT f.id, s.depth + 1 FROM files f
+	JOIN subtree s ON f.parent_folder_id = s.id
+	WHERE f.user_id = ? AND s.depth < ? AND f.deleted_at IS NULL
+)
+SELECT COALESCE(SUM(CASE WHEN files.is_folder = 0 THEN 1 ELSE 0 END), 0) AS file_count,
+	COALESCE(ctx context.Context, SUM(files.is_folder), 0) AS folder_count,
+	COALESCE(ctx context.Context, SUM(files.size), 0) AS total_size
+FROM files
+JOIN (SELECT DISTINCT id FROM subtree) t ON files.id = t.id
+WHERE files.status = ?`,
+		userID, folderID, userID, maxFolderDepth, models.StatusNormal).Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	stats.UserID = userID
+	return &stats, nil
+}
+
+func (r *fileStatsRepository) AggregateDeleted(ctx context.Context, userID uint64) (*models.TrashStats, error) {
+	var stats models.TrashStats
+	err := readDB(ctx, r.db).Unscoped().Model(&models.File{}).
+		Select("COUNT(*) AS item_count, "+
+			"COALESCE(SUM(CASE WHEN is_folder = 0 THEN 1 ELSE 0 END), 0) AS file_count, "+
+			"COALESCE(SUM(size), 0) AS total_size").
+		Where("user_id = ? AND deleted_at IS NOT NULL AND status <> ?", userID, models.StatusDeleting).
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func (r *fileStatsRepository) AggregateByType(ctx context.Context, userID uint64) ([]models.TypeUsage, error) {
+	var usages []models.TypeUsage
+	err := readDB(ctx, r.db).Model(&models.File{}).
+		Select(usageTypeExpr+" AS type, COUNT(*) AS file_count, COALESCE(SUM(size), 0) AS total_size").
+		Where("user_id = ? AND is_folder = 0 AND status = ?", userID, models.StatusNormal).
+		Group("type").
+		Scan(&usages).Error
+	return usages, err
+}
+
+func (r *fileStatsRepository) TopFolders(ctx context.Context, userID uint64, limit int) ([]models.FolderUsage, error) {
+	var folders []models.FolderUsage
+	err := readDB(ctx, r.db).Table("files").
+		Select("files.id AS file_id, files.file_name, file_stats.file_count, file_stats.total_size").
+		Joins("JOIN file_stats ON file_stats.file_id = files.id").
+		Where("files.user_id = ? AND files.parent_folder_id IS NULL AND files.is_folder = 1 AND files.status = ? AND files.deleted_at IS NULL",
+			userID, models.StatusNormal).
+		Order("file_stats.total_size DESC").
+		Limit(limit).
+		Scan(&folders).Error
+	return folders, err
+}
+
+func (r *fileStatsRepository) FindUsage(ctx context.Context, userID uint64) (*models.UserUsage, error) {
+	var usages []models.UserUsage
+	if err := readDB(ctx, r.db).Where("user_id = ?", userID).Limit(1).Find(&usages).Error; err != nil {
+		return nil, err
+	}
+	if len(usages) == 0 {
+		return nil, nil
+	}
+	return &usages[0], nil
+}
+
+func (r *fileStatsRepository) UpsertUsage(ctx context.Context, usage *models.UserUsage) error {
+	return writeDB(ctx, r.db).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"image_count", "image_size", "video_count", "video_size",
+			"document_count", "document_size", "other_count", "other_size",
+			"trash_count", "trash_file_count", "trash_size", "updated_at",
+		}),
+	}).Omit("quota_warned_at").Create(usage).Error
+}
+
+func (r *fileStatsRepository) MarkQuotaWarned(ctx context.Context, userID uint64, at time.Time) (bool, error) {
+	result := writeDB(ctx, r.db).Model(&models.UserUsage{}).
+		Where("user_id = ? AND quota_warned_at IS NULL", userID).
+		Update("quota_warned_at", at)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *fileStatsRepository) ClearQuotaWarning(ctx context.Context, userID uint64) error {
+	return writeDB(ctx, r.db).Model(&models.UserUsage{}).
+		Where("user_id = ? AND quota_warned_at IS NOT NULL", userID).
+		Update("quota_warned_at", nil).Error
+}
@@ -1,24 +1,38 @@
 package repositories
 
 import (
+	"context"
+	"time"
+
 	"github.com/3Eeeecho/go-clouddisk/internal/models"
 	"gorm.io/gorm"
 )
 
+// FileVersionRepository 版本记录的数据库操作接口。每条版本记录是存储对象的一个引用,
+// 创建、替换内容和彻底删除版本记录时在同一事务中更新 storage_objects 的引用计数
 type FileVersionRepository interface {
-	Create(fileVersion *models.FileVersion) error
-
-	FindByID(id uint64) (*models.FileVersion, error)
-	FindByFileID(fileID uint64) ([]models.FileVersion, error)
-	FindLatestVersion(fileID uint64) (*models.FileVersion, error)
-	FindByVersion(versionNum uint64) (*models.FileVersion, error)
-	FindByVersionID(versionID string) (*models.FileVersion, error)
-	FindFileVersions(fileID uint64) ([]models.FileVersion, error)
-
-	Delete(id uint64) error
-	DeleteFile(fileID uint64) error
-	DeleteVersion(fileID uint64, versionID string) error
-	SoftDeleteByFileID(fileID uint64) error
+	Create(ctx context.Context, fileVersion *models.FileVersion) error
+	// Update 保存版本记录,存储对象改变时把引用从原对象转移到新对象
+	Update(ctx context.Context, fileVersion *models.FileVersion) error
+
+	FindByID(ctx context.Context, id uint64) (*models.FileVersion, error)
+	FindByFileID(ctx context.Context, fileID uint64) ([]models.FileVersion, error)
+	FindLatestVersion(ctx context.Context, fileID uint64) (*models.FileVersion, error)
+	FindByVersion(ctx context.Context, versionNum uint64) (*models.FileVersion, error)
+	FindByVersionID(ctx context.Context, versionID string) (*models.FileVersion, error)
+	FindFileVersions(ctx context.Context, fileID uint64) ([]models.FileVersion, error)
+	// FindExpiredVersions 查找超出保留策略的版本: 不在所属文件最近 keepLast 个版本内且创建时间早于 before,
+	// 文件当前指向的版本不会返回
+	FindExpiredVersions(ctx context.Context, keepLast int, before time.Time, limit int) ([]models.FileVersion, error)
+	// PurgeByFileIDs 彻底删除文件的全部版本记录(包括已软删除的)并释放对象引用,返回引用数降为 0 的对象
+	PurgeByFileIDs(ctx context.Context, fileIDs []uint64) ([]models.StorageObject, error)
+	// PurgeVersion 彻底删除文件的指定版本并释放对象引用,返回引用数降为 0 的对象
+	PurgeVersion(ctx context.Context, fileID uint64, versionID string) ([]models.StorageObject, error)
+
+	Delete(ctx context.Context, id uint64) error
+	DeleteFile(ctx context.Context, fileID uint64) error
+	DeleteVersion(ctx context.Context, fileID uint64, versionID string) error
+	SoftDeleteByFileID(ctx context.Context, fileID uint64) error
 }
 
 type fileVersionRepository struct {
@@ -29,57 +43,151 @@ func NewFileVersionRepository(db *gorm.DB) FileVersionRepository {
 	return &fileVersionRepository{db: db}
 }
 
-func (r *fileVersionRepository) Create(fileVersion *models.FileVersion) error {
-	return r.db.Create(fileVersion).Error
+func (r *fileVersionRepository) Create(ctx context.Context, fileVersion *models.FileVersion) error {
+	return writeDB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(fileVersion).Error; err != nil {
+			return err
+		}
+		return retainObject(tx, fileVersion)
+	})
+}
+
+func (r *fileVersionRepository) Update(ctx context.Context, fileVersion *models.FileVersion) error {
+	return writeDB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var stored models.FileVersion
+		if err := tx.Unscoped().Select("oss_bucket", "oss_key", "version_id").First(&stored, fileVersion.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(fileVersion).Error; err != nil {
+			return err
+		}
+		if stored.OssBucket == fileVersion.OssBucket && stored.OssKey == fileVersion.OssKey && stored.VersionID == fileVersion.VersionID {
+			return nil
+		}
+		if err := retainObject(tx, fileVersion); err != nil {
+			return err
+		}
+		_, err := releaseObject(tx, stored.OssBucket, stored.OssKey, stored.VersionID, 1)
+		return err
+	})
 }
 
-func (r *fileVersionRepository) FindByID(id uint64) (*models.FileVersion, error) {
+func (r *fileVersionRepository) FindByID(ctx context.Context, id uint64) (*models.FileVersion, error) {
 	var version models.FileVersion
-	err := r.db.First(&version, id).Error
+	err := readDB(ctx, r.db).First(&version, id).Error
 	return &version, err
 }
-func (r *fileVersionRepository) FindByFileID(fileID uint64) ([]models.FileVersion, error) {
+func (r *fileVersionRepository) FindByFileID(ctx context.Context, fileID uint64) ([]models.FileVersion, error) {
 	var versions []models.FileVersion
-	err := r.db.Where("file_id = ?", fileID).Order("version desc").Find(&versions).Error
+	err := readDB(ctx, r.db).Where("file_id = ?", fileID).Order("version desc").Find(&versions).Error
 	return versions, err
 }
 
-func (r *fileVersionRepository) FindLatestVersion(fileID uint64) (*models.FileVersion, error) {
+func (r *fileVersionRepository) FindLatestVersion(ctx context.Context, fileID uint64) (*models.FileVersion, error) {
 	var version models.FileVersion
-	err := r.db.Where("file_id = ?", fileID).Order("version desc").First(&version).Error
+	err := readDB(ctx, r.db).Where("file_id = ?", fileID).Order("version desc").First(&version).Error
 	return &version, err
 }
 
-func (r *fileVersionRepository) FindByVersion(versionNum uint64) (*models.FileVersion, error) {
+func (r *fileVersionRepository) FindByVersion(ctx context.Context, versionNum uint64) (*models.FileVersion, error) {
 	var version models.FileVersion
-	err := r.db.Where("version = ?", versionNum).Order("version desc").First(&version).Error
+	err := readDB(ctx, r.db).Where("version = ?", versionNum).Order("version desc").First(&version).Error
 	return &version, err
 }
 
-func (r *fileVersionRepository) FindByVersionID(versionID string) (*models.FileVersion, error) {
+func (r *fileVersionRepository) FindByVersionID(ctx context.Context, versionID string) (*models.FileVersion, error) {
 	var version models.FileVersion
-	err := r.db.Where("version_id = ?", versionID).Order("version desc").First(&version).Error
+	err := readDB(ctx, r.db).Where("version_id = ?", versionID).Order("version desc").First(&version).Error
 	return &version, err
 }
 
-func (r *fileVersionRepository) FindFileVersions(fileID uint64) ([]models.FileVersion, error) {
+func (r *fileVersionRepository) FindFileVersions(ctx context.Context, fileID uint64) ([]models.FileVersion, error) {
 	var versions []models.FileVersion
-	err := r.db.Where("file_id = ?", fileID).Find(&versions).Error
+	err := readDB(ctx, r.db).Where("file_id = ?", fileID).Find(&versions).Error
 	return versions, err
 }
 
-func (r *fileVersionRepository) Delete(id uint64) error {
-	return r.db.Delete(&models.FileVersion{}, id).Error
+func (r *fileVersionRepository) FindExpiredVersions(ctx context.Context, keepLast int, before time.Time, limit int) ([]models.FileVersion, error) {
+	var versions []models.FileVersion
+	err := readDB(ctx, r.db).Raw(`
+		SELECT v.* FROM (
+			SELECT fv.*, ROW_NUMBER() OVER (PARTITION BY fv.file_id ORDER BY fv.version DESC) AS rn
+			FROM file_versions fv
+			WHERE fv.deleted_at IS NULL
+		) v
+		JOIN files f ON f.id = v.file_id
+		WHERE v.rn > ? AND v.created_at < ? AND (f.version_id IS NULL OR f.version_id <> v.version_id)
+		ORDER BY v.id
+		LIMIT ?`, keepLast, before, limit).Scan(&versions).Error
+	return versions, err
+}
+
+func (r *fileVersionRepository) Delete(ctx context.Context, id uint64) error {
+	return writeDB(ctx, r.db).Delete(&models.FileVersion{}, id).Error
+}
+
+func (r *fileVersionRepository) DeleteFile(ctx context.Context, fileID uint64) error {
+	return writeDB(ctx, r.db).Where("file_id = ?", fileID).Delete(&models.FileVersion{}).Error
+}
+
+func (r *fileVersionRepository) DeleteVersion(ctx context.Context, fileID uint64, versionID string) error {
+	return writeDB(ctx, r.db).Where("file_id = ? AND version_id = ?", fileID, versionID).Delete(&models.FileVersion{}).Error
+}
+
+func (r *fileVersionRepository) SoftDeleteByFileID(ctx context.Context, fileID uint64) error {
+	return writeDB(ctx, r.db).Where("file_id = ?", fileID).Delete(&models.FileVersion{}).Error
 }
 
-func (r *fileVersionRepository) DeleteFile(fileID uint64) error {
-	return r.db.Where("file_id = ?", fileID).Delete(&models.FileVersion{}).Error
+func (r *fileVersionRepository) PurgeByFileIDs(ctx context.Context, fileIDs []uint64) ([]models.StorageObject, error) {
+	if len(fileIDs) == 0 {
+		return nil, nil
+	}
+	return r.purge(ctx, "file_id IN ?", fileIDs)
 }
 
-func (r *fileVersionRepository) DeleteVersion(fileID uint64, versionID string) error {
-	return r.db.Where("file_id = ? AND version_id = ?", fileID, versionID).Delete(&models.FileVersion{}).Error
+func (r *fileVersionRepository) PurgeVersion(ctx context.Context, fileID uint64, versionID string) ([]models.StorageObject, error) {
+	return r.purge(ctx, "file_id = ? AND version_id = ?", fileID, versionID)
 }
 
-func (r *fileVersionRepository) SoftDeleteByFileID(fileID uint64) error {
-	return r.db.Where("file_id = ?", fileID).Delete(&models.FileVersion{}).Error
+// purge 彻底删除符合条件的版本记录,按对象汇总后释放引用
+func (r *fileVersionRepository) purge(ctx context.Context, query string, args ...any) ([]models.StorageObject, error) {
+	var released []models.StorageObject
+	err := writeDB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var versions []models.FileVersion
+		if err := tx.Unscoped().Select("id", "oss_bucket", "oss_key", "version_id", "sha256_hash", "size").
+			Where(query, args...).Find(&versions).Error; err != nil {
+			return err
+		}
+		if len(versions) == 0 {
+			return nil
+		}
+
+		type objectRef struct{ bucket, key, versionID string }
+		ids := make([]uint64, 0, len(versions))
+		refs := make(map[objectRef]int)
+		var order []models.StorageObject
+		for _, version := range versions {
+			ids = append(ids, version.ID)
+			ref := objectRef{version.OssBucket, version.OssKey, version.VersionID}
+			if refs[ref] == 0 {
+				order = append(order, models.StorageObject{Bucket: version.OssBucket, OssKey: version.OssKey, VersionID: version.VersionID, SHA256Hash: version.SHA256Hash, Size: version.Size})
+			}
+			refs[ref]++
+		}
+		if err := tx.Unscoped().Where("id IN ?", ids).Delete(&models.FileVersion{}).Error; err != nil {
+			return err
+		}
+
+		for _, object := range order {
+			zero, err := releaseObject(tx, object.Bucket, object.OssKey, object.VersionID, refs[objectRef{object.Bucket, object.OssKey, object.VersionID}])
+			if err != nil {
+				return err
+			}
+			if zero {
+				released = append(released, object)
+			}
+		}
+		return nil
+	})
+	return released, err
 }
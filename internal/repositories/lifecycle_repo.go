@@ -0,0 +1,157 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"gorm.io/gorm"
+)
+
+// LifecycleRuleRepository 定义了文件夹生命周期规则的数据库操作接口
+type LifecycleRuleRepository interface {
+	Create(ctx context.Context, rule *models.LifecycleRule) error
+	FindByID(ctx context.Context, id uint64) (*models.LifecycleRule, error)
+	FindByUser(ctx context.Context, userID uint64) ([]models.LifecycleRule, error)
+	CountByUser(ctx context.Context, userID uint64) (int64, error)
+	// FindEnabled 按 ID 顺序分批返回启用的规则,afterID 为上一批最后一条规则的 ID
+	FindEnabled(ctx context.Context, afterID uint64, limit int) ([]models.LifecycleRule, error)
+	Update(ctx context.Context, rule *models.LifecycleRule) error
+	// SaveReport 只更新执行时间和报告,不覆盖执行期间用户对规则的修改
+	SaveReport(ctx context.Context, ruleID uint64, report *models.LifecycleReport) error
+	Disable(ctx context.Context, ruleID uint64) error
+	// Delete 删除用户的规则,返回删除的记录数
+	Delete(ctx context.Context, userID, ruleID uint64) (int64, error)
+	// FindExpiredFiles 查询规则作用范围内最后修改时间早于 before 的正常状态文件,按修改时间从早到晚排列,
+	// 跳过处理失败后尚未到重试时间的文件。includeSubfolders 为 true 时包括整个子树中的文件
+	FindExpiredFiles(ctx context.Context, rule *models.LifecycleRule, before time.Time, limit int) ([]models.File, error)
+	// FindFailure 查询文件在规则下的失败记录,没有记录时返回 nil
+	FindFailure(ctx context.Context, ruleID, fileID uint64) (*models.LifecycleFailure, error)
+	// SaveFailure 写入或覆盖失败记录
+	SaveFailure(ctx context.Context, failure *models.LifecycleFailure) error
+	// ClearFailures 删除处理成功的文件的失败记录
+	ClearFailures(ctx context.Context, ruleID uint64, fileIDs []uint64) error
+}
+
+type lifecycleRuleRepository struct {
+	db *gorm.DB
+}
+
+// NewLifecycleRuleRepository 创建新的 lifecycleRuleRepository 实例
+func NewLifecycleRuleRepository(db *gorm.DB) LifecycleRuleRepository {
+	return &lifecycleRuleRepository{db: db}
+}
+
+func (r *lifecycleRuleRepository) Create(ctx context.Context, rule *models.LifecycleRule) error {
+	return writeDB(ctx, r.db).Create(rule).Error
+}
+
+func (r *lifecycleRuleRepository) FindByID(ctx context.Context, id uint64) (*models.LifecycleRule, error) {
+	var rule models.LifecycleRule
+	if err := readDB(ctx, r.db).First(&rule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("lifecycle repository: %w", xerr.ErrLifecycleRuleNotFound)
+		}
+		return nil, fmt.Errorf("lifecycle repository: failed to find rule: %w", err)
+	}
+	return &rule, nil
+}
+
+func (r *lifecycleRuleRepository) FindByUser(ctx context.Context, userID uint64) ([]models.LifecycleRule, error) {
+	var rules []models.LifecycleRule
+	err := readDB(ctx, r.db).Where("user_id = ?", userID).Order("id asc").Find(&rules).Error
+	return rules, err
+}
+
+func (r *lifecycleRuleRepository) CountByUser(ctx context.Context, userID uint64) (int64, error) {
+	var count int64
+	err := readDB(ctx, r.db).Model(&models.LifecycleRule{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+func (r *lifecycleRuleRepository) FindEnabled(ctx context.Context, afterID uint64, limit int) ([]models.LifecycleRule, error) {
+	var rules []models.LifecycleRule
+	err := readDB(ctx, r.db).Where("enabled = ? AND id > ?", true, afterID).Order("id asc").Limit(limit).Find(&rules).Error
+	return rules, err
+}
+
+func (r *lifecycleRuleRepository) Update(ctx context.Context, rule *models.LifecycleRule) error {
+	return writeDB(ctx, r.db).Save(rule).Error
+}
+
+func (r *lifecycleRuleRepository) SaveReport(ctx context.Context, ruleID uint64, report *models.LifecycleReport) error {
+	return writeDB(ctx, r.db).Model(&models.LifecycleRule{ID: ruleID}).
+		Select("last_run_at", "last_report").
+		Updates(&models.LifecycleRule{LastRunAt: &report.RunAt, LastReport: report}).Error
+}
+
+func (r *lifecycleRuleRepository) Disable(ctx context.Context, ruleID uint64) error {
+	return writeDB(ctx, r.db).Model(&models.LifecycleRule{}).Where("id = ?", ruleID).Update("enabled", false).Error
+}
+
+func (r *lifecycleRuleRepository) Delete(ctx context.Context, userID, ruleID uint64) (int64, error) {
+	var deleted int64
+	err := writeDB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND user_id = ?", ruleID, userID).Delete(&models.LifecycleRule{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		return tx.Where("rule_id = ?", ruleID).Delete(&models.LifecycleFailure{}).Error
+	})
+	return deleted, err
+}
+
+func (r *lifecycleRuleRepository) FindExpiredFiles(ctx context.Context, rule *models.LifecycleRule, before time.Time, limit int) ([]models.File, error) {
+	userID, folderID := rule.UserID, rule.FolderID
+	query := readDB(ctx, r.db).
+		Where("user_id = ? AND status = ? AND is_folder = 0 AND updated_at < ?", userID, models.StatusNormal, before).
+		Where("NOT EXISTS (SELECT 1 FROM lifecycle_failures lf WHERE lf.rule_id = ? AND lf.file_id = files.id AND lf.next_attempt_at > ?)", rule.ID, time.Now())
+	if rule.IncludeSubfolders {
+		// 子树中的文件夹(包括自身)
+		subtree := `WITH RECURSIVE subtree (id, depth) AS (
+	SELECT id, 0 FROM files WHERE id = ?
+	UNION
+	SELECT f.id, s.depth + 1 FROM files f
+	JOIN subtree s ON f.parent_folder_id = s.id
+	WHERE f.user_id = ? AND f.is_folder = 1 AND s.depth < ? AND f.deleted_at IS NULL
+) SELECT id FROM subtree`
+		query = query.Where("parent_folder_id IN (?)", gorm.Expr(subtree, folderID, userID, maxFolderDepth))
+	} else {
+		query = query.Where("parent_folder_id = ?", folderID)
+	}
+
+	var files []models.File
+	if err := query.Order("updated_at asc, id asc").Limit(limit).Find(&files).Error; err != nil {
+		return nil, fmt.Errorf("failed to find expired files: %w", err)
+	}
+	if err := fillListPaths(ctx, NewDBFileRepository(r.db), files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (r *lifecycleRuleRepository) FindFailure(ctx context.Context, ruleID, fileID uint64) (*models.LifecycleFailure, error) {
+	var failures []models.LifecycleFailure
+	if err := readDB(ctx, r.db).Where("rule_id = ? AND file_id = ?", ruleID, fileID).Limit(1).Find(&failures).Error; err != nil {
+		return nil, err
+	}
+	if len(failures) == 0 {
+		return nil, nil
+	}
+	return &failures[0], nil
+}
+
+func (r *lifecycleRuleRepository) SaveFailure(ctx context.Context, failure *models.LifecycleFailure) error {
+	return writeDB(ctx, r.db).Save(failure).Error
+}
+
+func (r *lifecycleRuleRepository) ClearFailures(ctx context.Context, ruleID uint64, fileIDs []uint64) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	return writeDB(ctx, r.db).Where("rule_id = ? AND file_id IN ?", ruleID, fileIDs).Delete(&models.LifecycleFailure{}).Error
+}
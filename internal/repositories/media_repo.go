@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// galleryTakenAt 相册的排序时间,还没有提取元数据或没有拍摄时间的文件使用上传时间
+const galleryTakenAt = "COALESCE(media_metadata.taken_at, files.created_at)"
+
+// MediaMetadataRepository 定义了图片和视频元数据的数据库操作接口
+type MediaMetadataRepository interface {
+	// Save 保存文件的元数据,已存在时覆盖
+	Save(ctx context.Context, meta *models.MediaMetadata) error
+	FindByFileIDs(ctx context.Context, fileIDs []uint64) ([]models.MediaMetadata, error)
+	// FindGalleryFiles 跨文件夹分页查询用户正常状态的图片和视频,按拍摄时间倒序。from 和 to 不为零时只返回 [from, to) 内的文件
+	FindGalleryFiles(ctx context.Context, userID uint64, from, to time.Time, page, pageSize int) ([]models.File, int64, error)
+}
+
+type mediaMetadataRepository struct {
+	db *gorm.DB
+}
+
+// NewMediaMetadataRepository 创建新的 mediaMetadataRepository 实例
+func NewMediaMetadataRepository(db *gorm.DB) MediaMetadataRepository {
+	return &mediaMetadataRepository{db: db}
+}
+
+func (r *mediaMetadataRepository) Save(ctx context.Context, meta *models.MediaMetadata) error {
+	return writeDB(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "file_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "version_id", "taken_at", "width", "height", "camera_model", "updated_at"}),
+	}).Create(meta).Error
+}
+
+func (r *mediaMetadataRepository) FindByFileIDs(ctx context.Context, fileIDs []uint64) ([]models.MediaMetadata, error) {
+	var metas []models.MediaMetadata
+	if len(fileIDs) == 0 {
+		return metas, nil
+	}
+	err := readDB(ctx, r.db).Where("file_id IN ?", fileIDs).Find(&metas).Error
+	return metas, err
+}
+
+func (r *mediaMetadataRepository) FindGalleryFiles(ctx context.Context, userID uint64, from, to time.Time, page, pageSize int) ([]models.File, int64, error) {
+	var files []models.File
+	var total int64
+
+	query := readDB(ctx, r.db).Model(&models.File{}).
+		Joins("LEFT JOIN media_metadata ON media_metadata.file_id = files.id").
+		Where("files.user_id = ? AND files.status = ? AND files.is_folder = 0", userID, models.StatusNormal).
+		Where("(files.mime_type LIKE ? OR files.mime_type LIKE ?)", "image/%", "video/%")
+	if !from.IsZero() && !to.IsZero() {
+		query = query.Where(galleryTakenAt+" >= ? AND "+galleryTakenAt+" < ?", from, to)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计相册文件总数失败: %w", err)
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.Order(galleryTakenAt + " desc, files.id desc").
+		Offset(offset).Limit(pageSize).Find(&files).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询相册文件失败: %w", err)
+	}
+	if err := fillListPaths(ctx, NewDBFileRepository(r.db), files); err != nil {
+		return nil, 0, err
+	}
+	return files, total, nil
+}
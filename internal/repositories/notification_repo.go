@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"gorm.io/gorm"
+)
+
+// NotificationRepository 站内通知的数据库操作接口
+type NotificationRepository interface {
+	Create(ctx context.Context, notification *models.Notification) error
+	// FindByUserID 分页查询用户的通知,unreadOnly 为 true 时只返回未读通知
+	FindByUserID(ctx context.Context, userID uint64, unreadOnly bool, page, pageSize int) ([]models.Notification, int64, error)
+	CountUnread(ctx context.Context, userID uint64) (int64, error)
+	// MarkRead 将用户的指定通知标记为已读,返回实际更新的数量
+	MarkRead(ctx context.Context, userID uint64, ids []uint64, at time.Time) (int64, error)
+	// MarkAllRead 将用户的全部未读通知标记为已读
+	MarkAllRead(ctx context.Context, userID uint64, at time.Time) (int64, error)
+}
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+func (r *notificationRepository) Create(ctx context.Context, notification *models.Notification) error {
+	return writeDB(ctx, r.db).Create(notification).Error
+}
+
+func (r *notificationRepository) FindByUserID(ctx context.Context, userID uint64, unreadOnly bool, page, pageSize int) ([]models.Notification, int64, error) {
+	query := readDB(ctx, r.db).Model(&models.Notification{}).Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var notifications []models.Notification
+	err := query.Order("created_at DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&notifications).Error
+	return notifications, total, err
+}
+
+func (r *notificationRepository) CountUnread(ctx context.Context, userID uint64) (int64, error) {
+	var count int64
+	err := readDB(ctx, r.db).Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+func (r *notificationRepository) MarkRead(ctx context.Context, userID uint64, ids []uint64, at time.Time) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := writeDB(ctx, r.db).Model(&models.Notification{}).
+		Where("user_id = ? AND id IN ? AND read_at IS NULL", userID, ids).
+		Update("read_at", at)
+	return result.RowsAffected, result.Error
+}
+
+func (r *notificationRepository) MarkAllRead(ctx context.Context, userID uint64, at time.Time) (int64, error) {
+	result := writeDB(ctx, r.db).Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", at)
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"gorm.io/gorm"
+)
+
+// OAuthRepository 定义了第三方应用和 OAuth 令牌的数据库操作接口
+type OAuthRepository interface {
+	CreateClient(ctx context.Context, client *models.OAuthClient) error
+	FindClientByClientID(ctx context.Context, clientID string) (*models.OAuthClient, error)
+	FindClientsByOwner(ctx context.Context, ownerID uint64) ([]models.OAuthClient, error)
+	// DeleteClient 删除应用及其签发的全部令牌,返回删除的应用数
+	DeleteClient(ctx context.Context, ownerID uint64, clientID string) (int64, error)
+
+	CreateToken(ctx context.Context, token *models.OAuthToken) error
+	FindTokenByAccessHash(ctx context.Context, accessTokenHash string) (*models.OAuthToken, error)
+	FindTokenByRefreshHash(ctx context.Context, refreshTokenHash string) (*models.OAuthToken, error)
+	// RotateToken 只有刷新令牌仍为 oldRefreshHash 时才更新,返回是否更新成功,避免同一个刷新令牌被并发使用两次
+	RotateToken(ctx context.Context, tokenID uint64, oldRefreshHash string, updates map[string]any) (bool, error)
+	UpdateTokenLastUsed(ctx context.Context, tokenID uint64, usedAt time.Time) error
+	FindAuthorizationsByUser(ctx context.Context, userID uint64) ([]models.OAuthAuthorization, error)
+	// DeleteToken 撤销用户的一次授权,返回删除的记录数
+	DeleteToken(ctx context.Context, userID, tokenID uint64) (int64, error)
+}
+
+type oauthRepository struct {
+	db *gorm.DB
+}
+
+// NewOAuthRepository 创建新的 oauthRepository 实例
+func NewOAuthRepository(db *gorm.DB) OAuthRepository {
+	return &oauthRepository{db: db}
+}
+
+func (r *oauthRepository) CreateClient(ctx context.Context, client *models.OAuthClient) error {
+	return writeDB(ctx, r.db).Create(client).Error
+}
+
+func (r *oauthRepository) FindClientByClientID(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	if err := readDB(ctx, r.db).Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("oauth repository: %w", xerr.ErrOAuthClientNotFound)
+		}
+		return nil, fmt.Errorf("oauth repository: failed to find client: %w", err)
+	}
+	return &client, nil
+}
+
+func (r *oauthRepository) FindClientsByOwner(ctx context.Context, ownerID uint64) ([]models.OAuthClient, error) {
+	var clients []models.OAuthClient
+	err := readDB(ctx, r.db).Where("owner_id = ?", ownerID).Order("created_at desc").Find(&clients).Error
+	return clients, err
+}
+
+func (r *oauthRepository) DeleteClient(ctx context.Context, ownerID uint64, clientID string) (int64, error) {
+	var deleted int64
+	err := writeDB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("client_id = ? AND owner_id = ?", clientID, ownerID).Delete(&models.OAuthClient{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		if deleted == 0 {
+			return nil
+		}
+		return tx.Where("client_id = ?", clientID).Delete(&models.OAuthToken{}).Error
+	})
+	return deleted, err
+}
+
+func (r *oauthRepository) CreateToken(ctx context.Context, token *models.OAuthToken) error {
+	return writeDB(ctx, r.db).Create(token).Error
+}
+
+func (r *oauthRepository) FindTokenByAccessHash(ctx context.Context, accessTokenHash string) (*models.OAuthToken, error) {
+	return r.findToken(ctx, "access_token_hash = ?", accessTokenHash)
+}
+
+func (r *oauthRepository) FindTokenByRefreshHash(ctx context.Context, refreshTokenHash string) (*models.OAuthToken, error) {
+	return r.findToken(ctx, "refresh_token_hash = ?", refreshTokenHash)
+}
+
+// findToken 令牌刚签发或刚轮换后就会被使用,从主库读取避免复制延迟导致认证失败
+func (r *oauthRepository) findToken(ctx context.Context, query string, hash string) (*models.OAuthToken, error) {
+	var token models.OAuthToken
+	if err := writeDB(ctx, r.db).Where(query, hash).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("oauth repository: %w", xerr.ErrOAuthGrantInvalid)
+		}
+		return nil, fmt.Errorf("oauth repository: failed to find token: %w", err)
+	}
+	return &token, nil
+}
+
+func (r *oauthRepository) RotateToken(ctx context.Context, tokenID uint64, oldRefreshHash string, updates map[string]any) (bool, error) {
+	result := writeDB(ctx, r.db).Model(&models.OAuthToken{}).
+		Where("id = ? AND refresh_token_hash = ?", tokenID, oldRefreshHash).
+		Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *oauthRepository) UpdateTokenLastUsed(ctx context.Context, tokenID uint64, usedAt time.Time) error {
+	return writeDB(ctx, r.db).Model(&models.OAuthToken{}).Where("id = ?", tokenID).Update("last_used_at", usedAt).Error
+}
+
+func (r *oauthRepository) FindAuthorizationsByUser(ctx context.Context, userID uint64) ([]models.OAuthAuthorization, error) {
+	var authorizations []models.OAuthAuthorization
+	err := readDB(ctx, r.db).Table("oauth_tokens AS t").
+		Select("t.id, t.client_id, c.name AS client_name, t.scope, t.last_used_at, t.created_at").
+		Joins("JOIN oauth_clients AS c ON c.client_id = t.client_id").
+		Where("t.user_id = ?", userID).
+		Order("t.created_at desc").
+		Scan(&authorizations).Error
+	return authorizations, err
+}
+
+func (r *oauthRepository) DeleteToken(ctx context.Context, userID, tokenID uint64) (int64, error) {
+	result := writeDB(ctx, r.db).Where("id = ? AND user_id = ?", tokenID, userID).Delete(&models.OAuthToken{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrganizationRepository 组织和成员的数据库操作接口
+type OrganizationRepository interface {
+	// Create 在一个事务中创建组织的网盘账号、团队根文件夹、组织记录和所有者成员
+	Create(ctx context.Context, org *models.Organization, driveUser *models.User, rootFolder *models.File, ownerID uint64) error
+	// FindByID 查找组织,不存在时返回 nil
+	FindByID(ctx context.Context, id uint64) (*models.Organization, error)
+	// FindByDriveUserID 按网盘账号查找组织,不存在时返回 nil
+	FindByDriveUserID(ctx context.Context, driveUserID uint64) (*models.Organization, error)
+	// FindMember 查找用户在组织中的成员记录,不是成员时返回 nil
+	FindMember(ctx context.Context, orgID, userID uint64) (*models.OrganizationMember, error)
+	// FindMembershipsByUserID 列出用户加入的全部组织,包含组织信息
+	FindMembershipsByUserID(ctx context.Context, userID uint64) ([]models.OrganizationMember, error)
+	// ListMembers 列出组织的全部成员,包含用户信息
+	ListMembers(ctx context.Context, orgID uint64) ([]models.OrganizationMember, error)
+	// UpsertMember 添加成员,用户已是成员时更新角色
+	UpsertMember(ctx context.Context, member *models.OrganizationMember) error
+	// DeleteMember 移除成员,返回删除的记录数
+	DeleteMember(ctx context.Context, orgID, userID uint64) (int64, error)
+	CountOwners(ctx context.Context, orgID uint64) (int64, error)
+	// CountByCreator 统计用户创建的组织数量
+	CountByCreator(ctx context.Context, userID uint64) (int64, error)
+}
+
+type organizationRepository struct {
+	db *gorm.DB
+}
+
+// NewOrganizationRepository 创建新的 organizationRepository 实例
+func NewOrganizationRepository(db *gorm.DB) OrganizationRepository {
+	return &organizationRepository{db: db}
+}
+
+func (r *organizationRepository) Create(ctx context.Context, org *models.Organization, driveUser *models.User, rootFolder *models.File, ownerID uint64) error {
+	return writeDB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(driveUser).Error; err != nil {
+			return err
+		}
+		rootFolder.UserID = driveUser.ID
+		if err := tx.Create(rootFolder).Error; err != nil {
+			return err
+		}
+		org.DriveUserID = driveUser.ID
+		org.RootFolderID = rootFolder.ID
+		org.CreatedBy = ownerID
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.OrganizationMember{OrgID: org.ID, UserID: ownerID, Role: models.OrgRoleOwner}).Error
+	})
+}
+
+func (r *organizationRepository) FindByID(ctx context.Context, id uint64) (*models.Organization, error) {
+	return r.findOne(ctx, "id = ?", id)
+}
+
+func (r *organizationRepository) FindByDriveUserID(ctx context.Context, driveUserID uint64) (*models.Organization, error) {
+	return r.findOne(ctx, "drive_user_id = ?", driveUserID)
+}
+
+func (r *organizationRepository) findOne(ctx context.Context, query string, args ...any) (*models.Organization, error) {
+	var org models.Organization
+	err := readDB(ctx, r.db).Where(query, args...).First(&org).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+func (r *organizationRepository) FindMember(ctx context.Context, orgID, userID uint64) (*models.OrganizationMember, error) {
+	var member models.OrganizationMember
+	err := readDB(ctx, r.db).Where("org_id = ? AND user_id = ?", orgID, userID).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (r *organizationRepository) FindMembershipsByUserID(ctx context.Context, userID uint64) ([]models.OrganizationMember, error) {
+	var members []models.OrganizationMember
+	err := readDB(ctx, r.db).Preload("Organization").Where("user_id = ?", userID).Order("created_at asc").Find(&members).Error
+	return members, err
+}
+
+func (r *organizationRepository) ListMembers(ctx context.Context, orgID uint64) ([]models.OrganizationMember, error) {
+	var members []models.OrganizationMember
+	err := readDB(ctx, r.db).Preload("User").Where("org_id = ?", orgID).Order("created_at asc").Find(&members).Error
+	return members, err
+}
+
+func (r *organizationRepository) UpsertMember(ctx context.Context, member *models.OrganizationMember) error {
+	return writeDB(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "org_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role", "updated_at"}),
+	}).Create(member).Error
+}
+
+func (r *organizationRepository) DeleteMember(ctx context.Context, orgID, userID uint64) (int64, error) {
+	result := writeDB(ctx, r.db).Where("org_id = ? AND user_id = ?", orgID, userID).Delete(&models.OrganizationMember{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *organizationRepository) CountOwners(ctx context.Context, orgID uint64) (int64, error) {
+	var count int64
+	err := readDB(ctx, r.db).Model(&models.OrganizationMember{}).
+		Where("org_id = ? AND role = ?", orgID, models.OrgRoleOwner).Count(&count).Error
+	return count, err
+}
+
+func (r *organizationRepository) CountByCreator(ctx context.Context, userID uint64) (int64, error) {
+	var count int64
+	err := readDB(ctx, r.db).Model(&models.Organization{}).Where("created_by = ?", userID).Count(&count).Error
+	return count, err
+}
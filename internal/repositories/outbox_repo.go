@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OutboxRepository 定义了发件箱事件的数据库操作接口
+type OutboxRepository interface {
+	// Add 写入待投递的事件,需要和业务数据在同一事务中调用
+	Add(ctx context.Context, events ...models.OutboxEvent) error
+	// Transaction 在事务中执行 fn,当前已处于事务中时使用保存点
+	Transaction(ctx context.Context, fn func(tx *gorm.DB) error) error
+	// ClaimDue 在独立的短事务中领取到期的事件并把下次投递时间推后 lease,被其他实例锁定的事件直接跳过。
+	// 投递期间不持有行锁,实例在投递完成前退出时事件在 lease 之后被重新领取
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error)
+	Delete(ctx context.Context, ids []uint64) error
+	// MarkFailed 记录投递失败,nextAttemptAt 之前不再投递
+	MarkFailed(ctx context.Context, id uint64, attempts int, nextAttemptAt time.Time, lastError string) error
+	// MarkDead 超过最大重试次数的事件标记为失败,不再投递
+	MarkDead(ctx context.Context, id uint64, attempts int, lastError string) error
+	WithTx(tx *gorm.DB) OutboxRepository
+}
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+// NewOutboxRepository 创建新的 outboxRepository 实例
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) Add(ctx context.Context, events ...models.OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return writeDB(ctx, r.db).Create(&events).Error
+}
+
+func (r *outboxRepository) Transaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return writeDB(ctx, r.db).Transaction(fn)
+}
+
+func (r *outboxRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	err := writeDB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.OutboxStatusPending, now).
+			Order("id asc").
+			Limit(limit).
+			Find(&events).Error
+		if err != nil || len(events) == 0 {
+			return err
+		}
+		ids := make([]uint64, len(events))
+		for i := range events {
+			ids[i] = events[i].ID
+		}
+		return tx.Model(&models.OutboxEvent{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(lease)).Error
+	})
+	return events, err
+}
+
+func (r *outboxRepository) Delete(ctx context.Context, ids []uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return writeDB(ctx, r.db).Delete(&models.OutboxEvent{}, ids).Error
+}
+
+func (r *outboxRepository) MarkFailed(ctx context.Context, id uint64, attempts int, nextAttemptAt time.Time, lastError string) error {
+	lastError = truncateOutboxError(lastError)
+	return writeDB(ctx, r.db).Model(&models.OutboxEvent{}).Where("id = ?", id).Updates(map[string]any{
+		"attempts":        attempts,
+		"next_attempt_at": nextAttemptAt,
+		"last_error":      lastError,
+	}).Error
+}
+
+func (r *outboxRepository) MarkDead(ctx context.Context, id uint64, attempts int, lastError string) error {
+	return writeDB(ctx, r.db).Model(&models.OutboxEvent{}).Where("id = ?", id).Updates(map[string]any{
+		"status":     models.OutboxStatusFailed,
+		"attempts":   attempts,
+		"last_error": truncateOutboxError(lastError),
+	}).Error
+}
+
+func (r *outboxRepository) WithTx(tx *gorm.DB) OutboxRepository {
+	return NewOutboxRepository(tx)
+}
+
+// truncateOutboxError 错误信息不超过 last_error 列的长度
+func truncateOutboxError(message string) string {
+	if len(message) > 512 {
+		return strings.ToValidUTF8(message[:512], "")
+	}
+	return message
+}
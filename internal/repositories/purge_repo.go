@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"gorm.io/gorm"
+)
+
+// PurgeJobRepository 定义了彻底删除任务的数据库操作接口
+type PurgeJobRepository interface {
+	Create(job *models.PurgeJob) error
+	// FindByID 查询彻底删除任务,不存在时返回 xerr.ErrPurgeJobNotFound
+	FindByID(id uint64) (*models.PurgeJob, error)
+	// FindActiveByFileID 查询文件等待中或进行中的彻底删除任务,没有时返回 nil
+	FindActiveByFileID(fileID uint64) (*models.PurgeJob, error)
+	// Update 保存任务的状态和进度
+	Update(job *models.PurgeJob) error
+}
+
+type purgeJobRepository struct {
+	db *gorm.DB
+}
+
+// NewPurgeJobRepository 创建新的 purgeJobRepository 实例
+func NewPurgeJobRepository(db *gorm.DB) PurgeJobRepository {
+	return &purgeJobRepository{db: db}
+}
+
+func (r *purgeJobRepository) Create(job *models.PurgeJob) error {
+	return r.db.Create(job).Error
+}
+
+func (r *purgeJobRepository) FindByID(id uint64) (*models.PurgeJob, error) {
+	var job models.PurgeJob
+	if err := r.db.First(&job, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("purge repository: %w", xerr.ErrPurgeJobNotFound)
+		}
+		return nil, fmt.Errorf("purge repository: failed to find purge job: %w", err)
+	}
+	return &job, nil
+}
+
+func (r *purgeJobRepository) FindActiveByFileID(fileID uint64) (*models.PurgeJob, error) {
+	var job models.PurgeJob
+	err := r.db.Where("file_id = ? AND status IN ?", fileID, []string{models.PurgeStatusPending, models.PurgeStatusRunning}).
+		Order("id desc").First(&job).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *purgeJobRepository) Update(job *models.PurgeJob) error {
+	return r.db.Model(job).Select("Status", "TotalItems", "PurgedItems", "Attempts", "Error", "FinishedAt").Updates(job).Error
+}
@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"gorm.io/gorm"
+)
+
+// RecoveryCodeRepository 定义了两步验证恢复码的数据库操作接口
+type RecoveryCodeRepository interface {
+	// Replace 删除用户已有的恢复码并写入新的恢复码哈希
+	Replace(userID uint64, codeHashes []string) error
+	// Consume 将未使用的恢复码标记为已使用,返回恢复码是否有效
+	Consume(userID uint64, codeHash string) (bool, error)
+	DeleteByUserID(userID uint64) error
+}
+
+type recoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+// NewRecoveryCodeRepository 创建新的 recoveryCodeRepository 实例
+func NewRecoveryCodeRepository(db *gorm.DB) RecoveryCodeRepository {
+	return &recoveryCodeRepository{db: db}
+}
+
+func (r *recoveryCodeRepository) Replace(userID uint64, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.TwoFactorRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		codes := make([]models.TwoFactorRecoveryCode, 0, len(codeHashes))
+		for _, hash := range codeHashes {
+			codes = append(codes, models.TwoFactorRecoveryCode{UserID: userID, CodeHash: hash})
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r *recoveryCodeRepository) Consume(userID uint64, codeHash string) (bool, error) {
+	// 条件更新保证并发请求中同一个恢复码只有一个能成功
+	result := r.db.Model(&models.TwoFactorRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *recoveryCodeRepository) DeleteByUserID(userID uint64) error {
+	return r.db.Where("user_id = ?", userID).Delete(&models.TwoFactorRecoveryCode{}).Error
+}
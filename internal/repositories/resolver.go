@@ -0,0 +1,30 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// readDB 返回绑定 ctx 的查询。配置了从库时查询默认分发到从库,
+// 当前请求已经写入过主库时强制从主库读取,保证能读到自己刚写入的数据
+func readDB(ctx context.Context, db *gorm.DB) *gorm.DB {
+	db = db.WithContext(ctx)
+	if utils.ReadFromPrimary(ctx) {
+		return db.Clauses(dbresolver.Write)
+	}
+	return db
+}
+
+// writeDB 返回绑定 ctx 的写入,并标记当前请求之后的查询从主库读取
+func writeDB(ctx context.Context, db *gorm.DB) *gorm.DB {
+	utils.MarkPrimaryWrite(ctx)
+	return db.WithContext(ctx)
+}
+
+// primaryDB 返回所有查询都走主库的连接,用于写入后立即回读的场景
+func primaryDB(db *gorm.DB) *gorm.DB {
+	return db.Clauses(dbresolver.Write).Session(&gorm.Session{})
+}
@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"gorm.io/gorm"
+)
+
+// ShareAccessLogRepository 定义了分享访问日志的数据库操作接口
+type ShareAccessLogRepository interface {
+	Create(log *models.ShareAccessLog) error
+	// DailyCounts 按天统计 since 之后的查看和下载次数,按日期升序
+	DailyCounts(shareID uint64, since time.Time) ([]models.ShareDailyCount, error)
+	// TopReferrers 统计 since 之后访问次数最多的来源域名,不包括直接访问
+	TopReferrers(shareID uint64, since time.Time, limit int) ([]models.ShareRefererCount, error)
+}
+
+type shareAccessLogRepository struct {
+	db *gorm.DB
+}
+
+// NewShareAccessLogRepository 创建新的 shareAccessLogRepository 实例
+func NewShareAccessLogRepository(db *gorm.DB) ShareAccessLogRepository {
+	return &shareAccessLogRepository{db: db}
+}
+
+func (r *shareAccessLogRepository) Create(log *models.ShareAccessLog) error {
+	return r.db.Create(log).Error
+}
+
+func (r *shareAccessLogRepository) DailyCounts(shareID uint64, since time.Time) ([]models.ShareDailyCount, error) {
+	var counts []models.ShareDailyCount
+	err := r.db.Model(&models.ShareAccessLog{}).
+		Select("DATE_FORMAT(created_at, '%Y-%m-%d') AS date, "+
+			"SUM(CASE WHEN action = ? THEN 1 ELSE 0 END) AS views, "+
+			"SUM(CASE WHEN action = ? THEN 1 ELSE 0 END) AS downloads",
+			models.ShareAccessView, models.ShareAccessDownload).
+		Where("share_id = ? AND created_at >= ?", shareID, since).
+		Group("date").
+		Order("date asc").
+		Scan(&counts).Error
+	return counts, err
+}
+
+func (r *shareAccessLogRepository) TopReferrers(shareID uint64, since time.Time, limit int) ([]models.ShareRefererCount, error) {
+	var counts []models.ShareRefererCount
+	err := r.db.Model(&models.ShareAccessLog{}).
+		Select("referer_host AS host, COUNT(*) AS count").
+		Where("share_id = ? AND created_at >= ? AND referer_host <> ''", shareID, since).
+		Group("referer_host").
+		Order("count desc, host asc").
+		Limit(limit).
+		Scan(&counts).Error
+	return counts, err
+}
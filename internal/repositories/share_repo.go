@@ -1,21 +1,31 @@
 package repositories
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/3Eeeecho/go-clouddisk/internal/models"
 	"gorm.io/gorm"
 )
 
 type ShareRepository interface {
-	Create(share *models.Share) error
-	FindByUUID(uuid string) (*models.Share, error)
-	FindByID(shareID uint64) (*models.Share, error)
-	FindByFileIDAndUserID(fileID, userID uint64) (*models.Share, error)
-	FindAllByUserID(userID uint64, page, pageSize int) ([]models.Share, int64, error)
-	Update(share *models.Share) error
-	Delete(id uint64) error // 逻辑删除分享链接
+	Create(ctx context.Context, share *models.Share) error
+	FindByUUID(ctx context.Context, uuid string) (*models.Share, error)
+	FindByID(ctx context.Context, shareID uint64) (*models.Share, error)
+	// FindByFileIDAndUserID 查找文件上有效的公开分享链接
+	FindByFileIDAndUserID(ctx context.Context, fileID, userID uint64) (*models.Share, error)
+	// FindByFileIDAndRecipientID 查找文件上分享给指定用户的有效站内分享
+	FindByFileIDAndRecipientID(ctx context.Context, fileID, recipientID uint64) (*models.Share, error)
+	// FindAllByRecipientID 分页列出分享给指定用户且未过期的站内分享,文件已被删除的分享不返回
+	FindAllByRecipientID(ctx context.Context, recipientID uint64, page, pageSize int) ([]models.Share, int64, error)
+	FindAllByUserID(ctx context.Context, userID uint64, page, pageSize int) ([]models.Share, int64, error)
+	Update(ctx context.Context, share *models.Share) error
+	Delete(ctx context.Context, id uint64) error // 逻辑删除分享链接
+	// FindPurgeable 查找 before 之前已撤销或已过期的分享记录,包括已软删除的记录
+	FindPurgeable(ctx context.Context, before time.Time, limit int) ([]models.Share, error)
+	PermanentDelete(ctx context.Context, ids []uint64) error
 }
 
 type shareRepository struct {
@@ -28,15 +38,16 @@ func NewShareRepository(db *gorm.DB) ShareRepository {
 }
 
 // 创建新的数据库记录
-func (r *shareRepository) Create(share *models.Share) error {
-	return r.db.Create(share).Error
+func (r *shareRepository) Create(ctx context.Context, share *models.Share) error {
+	return writeDB(ctx, r.db).Create(share).Error
 }
 
 // 根据uuid查找记录
-func (r *shareRepository) FindByUUID(uuid string) (*models.Share, error) {
+func (r *shareRepository) FindByUUID(ctx context.Context, uuid string) (*models.Share, error) {
 	var share models.Share
 	// Preload the associated File model for convenience
-	err := r.db.Preload("File").Where("uuid = ? AND status = 1", uuid).First(&share).Error
+	// 站内分享不能通过链接访问
+	err := readDB(ctx, r.db).Preload("File").Where("uuid = ? AND status = 1 AND recipient_id IS NULL", uuid).First(&share).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil // Return nil, nil if not found
@@ -46,9 +57,9 @@ func (r *shareRepository) FindByUUID(uuid string) (*models.Share, error) {
 	return &share, nil
 }
 
-func (r *shareRepository) FindByID(shareID uint64) (*models.Share, error) {
+func (r *shareRepository) FindByID(ctx context.Context, shareID uint64) (*models.Share, error) {
 	var share models.Share
-	err := r.db.Where("id = ?", shareID).First(&share).Error
+	err := readDB(ctx, r.db).Where("id = ?", shareID).First(&share).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -59,9 +70,9 @@ func (r *shareRepository) FindByID(shareID uint64) (*models.Share, error) {
 }
 
 // 查找特定文件用户是否已分享
-func (r *shareRepository) FindByFileIDAndUserID(fileID, userID uint64) (*models.Share, error) {
+func (r *shareRepository) FindByFileIDAndUserID(ctx context.Context, fileID, userID uint64) (*models.Share, error) {
 	var share models.Share
-	err := r.db.Where("file_id = ? AND user_id = ? AND status = 1", fileID, userID).First(&share).Error
+	err := readDB(ctx, r.db).Where("file_id = ? AND user_id = ? AND status = 1 AND recipient_id IS NULL", fileID, userID).First(&share).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil // Not found
@@ -71,13 +82,45 @@ func (r *shareRepository) FindByFileIDAndUserID(fileID, userID uint64) (*models.
 	return &share, nil
 }
 
+func (r *shareRepository) FindByFileIDAndRecipientID(ctx context.Context, fileID, recipientID uint64) (*models.Share, error) {
+	var share models.Share
+	err := readDB(ctx, r.db).Where("file_id = ? AND recipient_id = ? AND status = 1", fileID, recipientID).First(&share).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询站内分享失败: %w", err)
+	}
+	return &share, nil
+}
+
+func (r *shareRepository) FindAllByRecipientID(ctx context.Context, recipientID uint64, page, pageSize int) ([]models.Share, int64, error) {
+	var shares []models.Share
+	var total int64
+
+	offset := (page - 1) * pageSize
+	query := readDB(ctx, r.db).Model(&models.Share{}).
+		Joins("JOIN files ON files.id = shares.file_id AND files.status = ? AND files.deleted_at IS NULL", models.StatusNormal).
+		Where("shares.recipient_id = ? AND shares.status = 1 AND (shares.expires_at IS NULL OR shares.expires_at > ?)", recipientID, time.Now())
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计站内分享总数失败: %w", err)
+	}
+
+	err := query.Order("shares.created_at desc").Offset(offset).Limit(pageSize).Preload("File").Find(&shares).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询站内分享列表失败: %w", err)
+	}
+	return shares, total, nil
+}
+
 // 查找特定用户的所有已分享记录
-func (r *shareRepository) FindAllByUserID(userID uint64, page, pageSize int) ([]models.Share, int64, error) {
+func (r *shareRepository) FindAllByUserID(ctx context.Context, userID uint64, page, pageSize int) ([]models.Share, int64, error) {
 	var shares []models.Share
 	var total int64
 
 	offset := (page - 1) * pageSize
-	query := r.db.Model(&models.Share{}).Where("user_id = ? AND status = 1", userID)
+	query := readDB(ctx, r.db).Model(&models.Share{}).Where("user_id = ? AND status = 1", userID)
 
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, fmt.Errorf("统计分享总数失败: %w", err)
@@ -91,11 +134,31 @@ func (r *shareRepository) FindAllByUserID(userID uint64, page, pageSize int) ([]
 }
 
 // 更新数据库记录
-func (r *shareRepository) Update(share *models.Share) error {
-	return r.db.Save(share).Error
+func (r *shareRepository) Update(ctx context.Context, share *models.Share) error {
+	return writeDB(ctx, r.db).Save(share).Error
 }
 
 // 软删除记录(设置deleted_at字段)
-func (r *shareRepository) Delete(id uint64) error {
-	return r.db.Delete(&models.Share{}, id).Error
+func (r *shareRepository) Delete(ctx context.Context, id uint64) error {
+	return writeDB(ctx, r.db).Delete(&models.Share{}, id).Error
+}
+
+func (r *shareRepository) FindPurgeable(ctx context.Context, before time.Time, limit int) ([]models.Share, error) {
+	var shares []models.Share
+	err := readDB(ctx, r.db).Unscoped().
+		Where("(deleted_at IS NOT NULL AND deleted_at < ?) OR (status = 0 AND updated_at < ?) OR (expires_at IS NOT NULL AND expires_at < ?)",
+			before, before, before).
+		Order("id").Limit(limit).Find(&shares).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询失效分享链接失败: %w", err)
+	}
+	return shares, nil
+}
+
+// 永久删除记录
+func (r *shareRepository) PermanentDelete(ctx context.Context, ids []uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return writeDB(ctx, r.db).Unscoped().Where("id IN ?", ids).Delete(&models.Share{}).Error
 }
@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"gorm.io/gorm"
+)
+
+// StorageMigrationRepository 定义了存储迁移任务及迁移过程中文件存储位置的数据库操作接口
+type StorageMigrationRepository interface {
+	Create(ctx context.Context, migration *models.StorageMigration) error
+	// FindByID 查询迁移任务,不存在时返回 xerr.ErrMigrationNotFound
+	FindByID(ctx context.Context, id uint64) (*models.StorageMigration, error)
+	// List 按创建时间倒序返回最近的迁移任务
+	List(ctx context.Context, limit int) ([]models.StorageMigration, error)
+	// FindActive 查询等待中或迁移中的任务,没有时返回 nil
+	FindActive(ctx context.Context) (*models.StorageMigration, error)
+	// Update 保存迁移任务的状态和进度
+	Update(ctx context.Context, migration *models.StorageMigration) error
+
+	// FindFilesToMigrate 按ID顺序返回 afterID 之后存储桶不是 targetBucket 的文件,包括回收站中的文件
+	FindFilesToMigrate(ctx context.Context, targetBucket string, afterID uint64, limit int) ([]models.File, error)
+	// FindFileVersions 返回文件的全部版本记录,包括已软删除的
+	FindFileVersions(ctx context.Context, fileID uint64) ([]models.FileVersion, error)
+	// MoveVersion 更新版本记录在目标存储桶中的版本 ID,引用随之转移到目标存储桶中的对象
+	MoveVersion(ctx context.Context, id uint64, bucket, versionID string) error
+	// CountObjectReferences 统计存储桶中仍引用该对象的文件数,文件当前指向或任一版本指向该对象都算引用
+	CountObjectReferences(ctx context.Context, bucket, ossKey, versionID string) (int64, error)
+}
+
+type storageMigrationRepository struct {
+	db *gorm.DB
+}
+
+// NewStorageMigrationRepository 创建新的 storageMigrationRepository 实例
+func NewStorageMigrationRepository(db *gorm.DB) StorageMigrationRepository {
+	return &storageMigrationRepository{db: db}
+}
+
+func (r *storageMigrationRepository) Create(ctx context.Context, migration *models.StorageMigration) error {
+	return writeDB(ctx, r.db).Create(migration).Error
+}
+
+func (r *storageMigrationRepository) FindByID(ctx context.Context, id uint64) (*models.StorageMigration, error) {
+	var migration models.StorageMigration
+	if err := readDB(ctx, r.db).First(&migration, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("storage migration repository: %w", xerr.ErrMigrationNotFound)
+		}
+		return nil, fmt.Errorf("storage migration repository: failed to find migration: %w", err)
+	}
+	return &migration, nil
+}
+
+func (r *storageMigrationRepository) List(ctx context.Context, limit int) ([]models.StorageMigration, error) {
+	var migrations []models.StorageMigration
+	err := readDB(ctx, r.db).Order("id desc").Limit(limit).Find(&migrations).Error
+	return migrations, err
+}
+
+func (r *storageMigrationRepository) FindActive(ctx context.Context) (*models.StorageMigration, error) {
+	var migration models.StorageMigration
+	err := writeDB(ctx, r.db).
+		Where("status IN ?", []string{models.MigrationStatusPending, models.MigrationStatusRunning}).
+		Order("id desc").First(&migration).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &migration, nil
+}
+
+func (r *storageMigrationRepository) Update(ctx context.Context, migration *models.StorageMigration) error {
+	return writeDB(ctx, r.db).Model(migration).
+		Select("Status", "LastFileID", "MigratedFiles", "MigratedBytes", "FailedFiles", "Error", "FinishedAt", "UpdatedAt").
+		Updates(migration).Error
+}
+
+func (r *storageMigrationRepository) FindFilesToMigrate(ctx context.Context, targetBucket string, afterID uint64, limit int) ([]models.File, error) {
+	var files []models.File
+	err := readDB(ctx, r.db).Unscoped().
+		Where("id > ? AND is_folder = 0 AND oss_key IS NOT NULL AND oss_bucket IS NOT NULL AND oss_bucket <> ?", afterID, targetBucket).
+		Where("status <> ?", models.StatusDeleting).
+		Order("id asc").Limit(limit).Find(&files).Error
+	return files, err
+}
+
+func (r *storageMigrationRepository) FindFileVersions(ctx context.Context, fileID uint64) ([]models.FileVersion, error) {
+	var versions []models.FileVersion
+	err := readDB(ctx, r.db).Unscoped().Where("file_id = ?", fileID).Order("version asc").Find(&versions).Error
+	return versions, err
+}
+
+func (r *storageMigrationRepository) MoveVersion(ctx context.Context, id uint64, bucket, versionID string) error {
+	return writeDB(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var version models.FileVersion
+		if err := tx.Unscoped().First(&version, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.FileVersion{}).Where("id = ?", id).
+			Updates(map[string]any{"oss_bucket": bucket, "version_id": versionID}).Error; err != nil {
+			return err
+		}
+
+		// 引用随版本记录转移到目标存储桶中的对象。源对象由迁移流程按存储桶判断后删除,这里只删除计数记录
+		previous := version
+		version.OssBucket, version.VersionID = bucket, versionID
+		if err := retainObject(tx, &version); err != nil {
+			return err
+		}
+		zero, err := releaseObject(tx, previous.OssBucket, previous.OssKey, previous.VersionID, 1)
+		if err != nil || !zero {
+			return err
+		}
+		return tx.Where("bucket = ? AND oss_key = ? AND version_id = ? AND ref_count = 0", previous.OssBucket, previous.OssKey, previous.VersionID).
+			Delete(&models.StorageObject{}).Error
+	})
+}
+
+func (r *storageMigrationRepository) CountObjectReferences(ctx context.Context, bucket, ossKey, versionID string) (int64, error) {
+	db := writeDB(ctx, r.db)
+	versionRefs := db.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&models.FileVersion{}).
+		Select("file_id").Where("oss_bucket = ? AND oss_key = ? AND version_id = ?", bucket, ossKey, versionID)
+
+	var count int64
+	err := db.Unscoped().Model(&models.File{}).
+		Where("(oss_bucket = ? AND oss_key = ? AND IFNULL(version_id, '') = ?) OR id IN (?)", bucket, ossKey, versionID, versionRefs).
+		Count(&count).Error
+	return count, err
+}
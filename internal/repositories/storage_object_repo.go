@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StorageObjectRepository 存储对象引用计数的数据库操作接口。
+// 引用的增减由 FileVersionRepository 在写入版本记录时同步完成,这里提供删除物理对象前的认领和秒传、转存时的锁定
+type StorageObjectRepository interface {
+	// LockReferenced 锁定仍被引用的对象直到事务结束,防止引用已有对象的同时它被认领删除。
+	// 对象已没有引用(已被或即将被删除)时返回 gorm.ErrRecordNotFound
+	LockReferenced(ctx context.Context, bucket, ossKey, versionID string) error
+	// Claim 认领引用数为 0 的对象并删除其记录,返回 true 表示由调用方删除物理对象
+	Claim(ctx context.Context, bucket, ossKey, versionID string) (bool, error)
+	// Restore 物理对象删除失败时恢复引用数为 0 的记录,之后可以重新认领
+	Restore(ctx context.Context, object *models.StorageObject) error
+	// IsReferenced 检查对象是否仍被引用,用于清理写入后事务回滚的新对象
+	IsReferenced(ctx context.Context, bucket, ossKey, versionID string) (bool, error)
+}
+
+type storageObjectRepository struct {
+	db *gorm.DB
+}
+
+// NewStorageObjectRepository 创建新的 storageObjectRepository 实例
+func NewStorageObjectRepository(db *gorm.DB) StorageObjectRepository {
+	return &storageObjectRepository{db: db}
+}
+
+func (r *storageObjectRepository) LockReferenced(ctx context.Context, bucket, ossKey, versionID string) error {
+	var object models.StorageObject
+	return writeDB(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("bucket = ? AND oss_key = ? AND version_id = ? AND ref_count > 0", bucket, ossKey, versionID).
+		First(&object).Error
+}
+
+func (r *storageObjectRepository) Claim(ctx context.Context, bucket, ossKey, versionID string) (bool, error) {
+	result := writeDB(ctx, r.db).Where("bucket = ? AND oss_key = ? AND version_id = ? AND ref_count = 0", bucket, ossKey, versionID).
+		Delete(&models.StorageObject{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *storageObjectRepository) Restore(ctx context.Context, object *models.StorageObject) error {
+	restored := *object
+	restored.ID = 0
+	restored.RefCount = 0
+	// 认领后对象又被新的版本记录引用时保留已有记录
+	return writeDB(ctx, r.db).Clauses(clause.OnConflict{DoNothing: true}).Create(&restored).Error
+}
+
+func (r *storageObjectRepository) IsReferenced(ctx context.Context, bucket, ossKey, versionID string) (bool, error) {
+	var count int64
+	err := writeDB(ctx, r.db).Model(&models.StorageObject{}).
+		Where("bucket = ? AND oss_key = ? AND version_id = ? AND ref_count > 0", bucket, ossKey, versionID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// retainObject 版本记录引用对象,对象第一次被引用时创建记录。tx 为写入版本记录的事务
+func retainObject(tx *gorm.DB, version *models.FileVersion) error {
+	if version.OssKey == "" {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "bucket"}, {Name: "oss_key"}, {Name: "version_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"ref_count":  gorm.Expr("ref_count + 1"),
+			"updated_at": gorm.Expr("VALUES(updated_at)"),
+		}),
+	}).Create(&models.StorageObject{
+		Bucket:     version.OssBucket,
+		OssKey:     version.OssKey,
+		VersionID:  version.VersionID,
+		SHA256Hash: version.SHA256Hash,
+		Size:       version.Size,
+		RefCount:   1,
+	}).Error
+}
+
+// releaseObject 对象减少 n 个引用,返回引用数是否已降为 0。tx 为删除版本记录的事务
+func releaseObject(tx *gorm.DB, bucket, ossKey, versionID string, n int) (bool, error) {
+	if ossKey == "" || n <= 0 {
+		return false, nil
+	}
+	var object models.StorageObject
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("bucket = ? AND oss_key = ? AND version_id = ?", bucket, ossKey, versionID).First(&object).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 没有计数记录的对象无法确认是否还有其他引用,保留物理文件
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	remaining := object.RefCount - min(object.RefCount, uint64(n))
+	if err := tx.Model(&object).Update("ref_count", remaining).Error; err != nil {
+		return false, err
+	}
+	return remaining == 0, nil
+}
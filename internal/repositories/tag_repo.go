@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FileTagRepository 定义了文件标签的数据库操作接口
+type FileTagRepository interface {
+	// Create 批量添加标签,已存在的标签不做任何操作
+	Create(tags []models.FileTag) error
+	// Delete 删除文件上的指定标签,tags 为空时删除该用户在文件上的全部标签,返回删除的记录数
+	Delete(userID, fileID uint64, tags []string) (int64, error)
+	// FindByFileIDs 查询用户在多个文件上的标签,按标签名排序
+	FindByFileIDs(userID uint64, fileIDs []uint64) ([]models.FileTag, error)
+	// CountTags 统计用户使用过的标签和对应的正常状态文件数量
+	CountTags(userID uint64) ([]models.TagCount, error)
+	// FindFilesByTag 分页查询带有指定标签的正常状态文件,按文件名排序
+	FindFilesByTag(ctx context.Context, userID uint64, tag string, page, pageSize int) ([]models.File, int64, error)
+}
+
+type fileTagRepository struct {
+	db *gorm.DB
+}
+
+// NewFileTagRepository 创建新的 fileTagRepository 实例
+func NewFileTagRepository(db *gorm.DB) FileTagRepository {
+	return &fileTagRepository{db: db}
+}
+
+func (r *fileTagRepository) Create(tags []models.FileTag) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error
+}
+
+func (r *fileTagRepository) Delete(userID, fileID uint64, tags []string) (int64, error) {
+	query := r.db.Where("user_id = ? AND file_id = ?", userID, fileID)
+	if len(tags) > 0 {
+		query = query.Where("tag IN ?", tags)
+	}
+	result := query.Delete(&models.FileTag{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *fileTagRepository) FindByFileIDs(userID uint64, fileIDs []uint64) ([]models.FileTag, error) {
+	var tags []models.FileTag
+	if len(fileIDs) == 0 {
+		return tags, nil
+	}
+	err := r.db.Where("user_id = ? AND file_id IN ?", userID, fileIDs).Order("tag asc").Find(&tags).Error
+	return tags, err
+}
+
+func (r *fileTagRepository) CountTags(userID uint64) ([]models.TagCount, error) {
+	var counts []models.TagCount
+	err := r.db.Model(&models.FileTag{}).
+		Select("file_tags.tag AS tag, COUNT(*) AS count").
+		Joins("JOIN files ON files.id = file_tags.file_id AND files.status = ? AND files.deleted_at IS NULL", models.StatusNormal).
+		Where("file_tags.user_id = ?", userID).
+		Group("file_tags.tag").
+		Order("file_tags.tag asc").
+		Scan(&counts).Error
+	return counts, err
+}
+
+func (r *fileTagRepository) FindFilesByTag(ctx context.Context, userID uint64, tag string, page, pageSize int) ([]models.File, int64, error) {
+	var files []models.File
+	var total int64
+
+	// 回收站中和已删除的文件不出现在标签列表中,恢复后重新出现
+	query := r.db.Model(&models.File{}).
+		Joins("JOIN file_tags ON file_tags.file_id = files.id AND file_tags.user_id = ? AND file_tags.tag = ?", userID, tag).
+		Where("files.status = ?", models.StatusNormal)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计标签文件总数失败: %w", err)
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.Order("files.is_folder desc, files.file_name asc, files.id asc").
+		Offset(offset).Limit(pageSize).Find(&files).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询标签文件失败: %w", err)
+	}
+	if err := fillListPaths(ctx, NewDBFileRepository(r.db), files); err != nil {
+		return nil, 0, err
+	}
+	return files, total, nil
+}
@@ -1,6 +1,9 @@
 package repositories
 
 import (
+	"context"
+	"time"
+
 	"github.com/3Eeeecho/go-clouddisk/internal/models"
 	"gorm.io/gorm"
 )
@@ -8,11 +11,15 @@ import (
 // MultipartUploadRepository 定义了分片上传任务的数据库操作接口
 type MultipartUploadRepository interface {
 	// FindByFileHash 根据文件哈希查找进行中的上传任务
-	FindByFileHash(fileHash string, userID uint64) (*models.MultipartUpload, error)
+	FindByFileHash(ctx context.Context, fileHash string, userID uint64) (*models.MultipartUpload, error)
+	// FindByUploadID 根据 uploadID 查找用户进行中的上传任务
+	FindByUploadID(ctx context.Context, uploadID string, userID uint64) (*models.MultipartUpload, error)
 	// Create 创建一个新的分片上传任务记录
-	Create(upload *models.MultipartUpload) error
+	Create(ctx context.Context, upload *models.MultipartUpload) error
 	// UpdateStatus 更新指定 uploadID 的任务状态
-	UpdateStatus(uploadID string, status string) error
+	UpdateStatus(ctx context.Context, uploadID string, status string) error
+	// FindStale 查找 before 之前创建且仍在进行中的上传任务
+	FindStale(ctx context.Context, before time.Time, limit int) ([]models.MultipartUpload, error)
 }
 
 type dbMultipartUploadRepository struct {
@@ -24,19 +31,34 @@ func NewDBMultipartUploadRepository(db *gorm.DB) MultipartUploadRepository {
 	return &dbMultipartUploadRepository{db: db}
 }
 
-func (r *dbMultipartUploadRepository) FindByFileHash(fileHash string, userID uint64) (*models.MultipartUpload, error) {
+func (r *dbMultipartUploadRepository) FindByFileHash(ctx context.Context, fileHash string, userID uint64) (*models.MultipartUpload, error) {
+	var upload models.MultipartUpload
+	err := readDB(ctx, r.db).Where("file_hash = ? AND user_id = ? AND status = ?", fileHash, userID, "in_progress").First(&upload).Error
+	if err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+func (r *dbMultipartUploadRepository) FindByUploadID(ctx context.Context, uploadID string, userID uint64) (*models.MultipartUpload, error) {
 	var upload models.MultipartUpload
-	err := r.db.Where("file_hash = ? AND user_id = ? AND status = ?", fileHash, userID, "in_progress").First(&upload).Error
+	err := readDB(ctx, r.db).Where("upload_id = ? AND user_id = ? AND status = ?", uploadID, userID, "in_progress").First(&upload).Error
 	if err != nil {
 		return nil, err
 	}
 	return &upload, nil
 }
 
-func (r *dbMultipartUploadRepository) Create(upload *models.MultipartUpload) error {
-	return r.db.Create(upload).Error
+func (r *dbMultipartUploadRepository) Create(ctx context.Context, upload *models.MultipartUpload) error {
+	return writeDB(ctx, r.db).Create(upload).Error
+}
+
+func (r *dbMultipartUploadRepository) UpdateStatus(ctx context.Context, uploadID string, status string) error {
+	return writeDB(ctx, r.db).Model(&models.MultipartUpload{}).Where("upload_id = ?", uploadID).Update("status", status).Error
 }
 
-func (r *dbMultipartUploadRepository) UpdateStatus(uploadID string, status string) error {
-	return r.db.Model(&models.MultipartUpload{}).Where("upload_id = ?", uploadID).Update("status", status).Error
+func (r *dbMultipartUploadRepository) FindStale(ctx context.Context, before time.Time, limit int) ([]models.MultipartUpload, error) {
+	var uploads []models.MultipartUpload
+	err := readDB(ctx, r.db).Where("status = ? AND created_at < ?", "in_progress", before).Order("id").Limit(limit).Find(&uploads).Error
+	return uploads, err
 }
@@ -31,7 +31,7 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 }
 
 func (r *userRepository) CreateUser(ctx context.Context, user *models.User) error {
-	if err := r.db.WithContext(ctx).Create(user).Error; err != nil {
+	if err := writeDB(ctx, r.db).Create(user).Error; err != nil {
 		var mysqlErr *mysql.MySQLError
 		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
 			logger.Error("Failed to create user due to duplicate key", zap.String("username", user.Username), zap.String("email", user.Email), zap.Error(err))
@@ -50,7 +50,7 @@ func (r *userRepository) CreateUser(ctx context.Context, user *models.User) erro
 
 func (r *userRepository) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
 	var user models.User
-	err := r.db.WithContext(ctx).Where("username = ?", username).First(&user).Error
+	err := readDB(ctx, r.db).Where("username = ?", username).First(&user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, fmt.Errorf("user repository: %w", xerr.ErrUserNotFound)
@@ -63,7 +63,7 @@ func (r *userRepository) GetUserByUsername(ctx context.Context, username string)
 
 func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error
+	err := readDB(ctx, r.db).Where("email = ?", email).First(&user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, fmt.Errorf("user repository: %w", xerr.ErrUserNotFound)
@@ -76,7 +76,7 @@ func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (*mod
 
 func (r *userRepository) GetUserByID(ctx context.Context, id uint64) (*models.User, error) {
 	var user models.User
-	err := r.db.WithContext(ctx).Where("id = ?", id).First(&user).Error
+	err := readDB(ctx, r.db).Where("id = ?", id).First(&user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, fmt.Errorf("user repository: %w", xerr.ErrUserNotFound)
@@ -88,7 +88,7 @@ func (r *userRepository) GetUserByID(ctx context.Context, id uint64) (*models.Us
 }
 
 func (r *userRepository) UpdateUser(ctx context.Context, user *models.User) error {
-	err := r.db.WithContext(ctx).Save(user).Error
+	err := writeDB(ctx, r.db).Save(user).Error
 	if err != nil {
 		logger.Error("Error updating user", zap.Uint64("id", user.ID), zap.Error(err))
 		return fmt.Errorf("user repository: failed to update user: %w", err)
@@ -0,0 +1,119 @@
+package router
+
+import (
+	"fmt"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/middlewares"
+	"github.com/gin-gonic/gin"
+)
+
+// Access 路由允许的调用方,决定挂载哪些认证中间件
+type Access int
+
+const (
+	// Public 无需认证
+	Public Access = iota
+	// ShareLink 公开分享链接,由 handler 按分享 UUID 和密码校验后签发的令牌判断能否访问
+	ShareLink
+	// Callback 外部服务回调或客户端凭据接口,由 handler 校验签名或应用密钥
+	Callback
+	// Authenticated 网页登录、个人访问令牌或 OAuth 令牌,令牌还需要满足分组的 Scope
+	Authenticated
+	// SessionOnly 只允许网页登录,拒绝个人访问令牌和 OAuth 令牌
+	SessionOnly
+	// Admin 只允许管理员,令牌需要 full 权限
+	Admin
+)
+
+func (a Access) requiresAuth() bool {
+	return a == Authenticated || a == SessionOnly || a == Admin
+}
+
+// Scope 令牌访问 Authenticated 分组需要的权限,网页登录的请求不受限制。
+// Any 为空时按 Read、Write 区分读写请求,见 middlewares.RequireReadScope,
+// 都为空表示 OAuth 令牌不能访问;Any 不为空时不区分读写,需要其中之一或 full 权限
+type Scope struct {
+	Read  string
+	Write string
+	Any   []string
+}
+
+// Route 一条路由,Middlewares 挂载在分组策略之后、Handler 之前
+type Route struct {
+	Method      string
+	Path        string
+	Handler     gin.HandlerFunc
+	RateLimit   string // 限流规则名,见配置文件 rate_limit,在分组的限流之后执行
+	Middlewares []gin.HandlerFunc
+}
+
+// Group 共用同一访问策略的一组路由
+type Group struct {
+	Prefix     string
+	Access     Access
+	Scope      Scope
+	QueryToken bool   // 允许通过 access_token 查询参数传递令牌,只用于 WebSocket 等无法设置请求头的接口
+	RateLimit  string // 分组内所有路由共用的限流规则名
+	Routes     []Route
+}
+
+// routeBuilder 按分组声明的策略组装中间件并注册路由
+type routeBuilder struct {
+	auth    gin.HandlerFunc // 认证中间件,同一个实例在所有分组中复用
+	admin   gin.HandlerFunc // 管理员校验
+	limiter *middlewares.RateLimiter
+}
+
+// register 把 groups 注册到 parent 下
+func (b *routeBuilder) register(parent gin.IRoutes, groups []Group) {
+	for _, group := range groups {
+		policy := b.policy(group)
+		for _, route := range group.Routes {
+			handlers := append([]gin.HandlerFunc{}, policy...)
+			if route.RateLimit != "" {
+				handlers = append(handlers, b.limiter.Limit(route.RateLimit))
+			}
+			handlers = append(handlers, route.Middlewares...)
+			handlers = append(handlers, route.Handler)
+			parent.Handle(route.Method, joinPath(group.Prefix, route.Path), handlers...)
+		}
+	}
+}
+
+// policy 返回分组策略对应的中间件,顺序为查询参数令牌、认证、权限、限流
+func (b *routeBuilder) policy(group Group) []gin.HandlerFunc {
+	if group.QueryToken && !group.Access.requiresAuth() {
+		panic(fmt.Sprintf("router: group %q allows query tokens but requires no authentication", group.Prefix))
+	}
+
+	var handlers []gin.HandlerFunc
+	if group.QueryToken {
+		handlers = append(handlers, middlewares.QueryToken())
+	}
+	switch group.Access {
+	case Authenticated:
+		handlers = append(handlers, b.auth)
+		if len(group.Scope.Any) > 0 {
+			handlers = append(handlers, middlewares.RequireScope(group.Scope.Any...))
+		} else {
+			handlers = append(handlers, middlewares.RequireReadScope(group.Scope.Read, group.Scope.Write))
+		}
+	case SessionOnly:
+		handlers = append(handlers, b.auth, middlewares.RejectAccessToken())
+	case Admin:
+		handlers = append(handlers, b.auth, middlewares.RequireScope(), b.admin)
+	}
+	// 限流放在认证之后,已认证的请求按用户计数
+	if group.RateLimit != "" {
+		handlers = append(handlers, b.limiter.Limit(group.RateLimit))
+	}
+	return handlers
+}
+
+// joinPath 拼接分组前缀和路由路径,路由路径为空时使用前缀本身
+func joinPath(prefix, path string) string {
+	if path == "" {
+		return prefix
+	}
+	return prefix + path
+}
@@ -8,9 +8,16 @@ import (
 	"github.com/3Eeeecho/go-clouddisk/internal/handlers"
 	"github.com/3Eeeecho/go-clouddisk/internal/handlers/response"
 	"github.com/3Eeeecho/go-clouddisk/internal/middlewares"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/admin"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/authz"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 func InitRouter(authHandler *handlers.AuthHandler,
@@ -18,92 +25,415 @@ func InitRouter(authHandler *handlers.AuthHandler,
 	shareHandler *handlers.ShareHandler,
 	uploadHandler *handlers.UploadHandler,
 	userHandler *handlers.UserHandler,
+	activityHandler *handlers.ActivityHandler,
+	permissionHandler *handlers.PermissionHandler,
+	accessTokenHandler *handlers.AccessTokenHandler,
+	transferHandler *handlers.TransferHandler,
+	adminHandler *handlers.AdminHandler,
+	favoriteHandler *handlers.FavoriteHandler,
+	tagHandler *handlers.TagHandler,
+	commentHandler *handlers.CommentHandler,
+	officeHandler *handlers.OfficeHandler,
+	archiveHandler *handlers.ArchiveHandler,
+	extractHandler *handlers.ExtractHandler,
+	exportHandler *handlers.ExportHandler,
+	oauthHandler *handlers.OAuthHandler,
+	signedDownloadHandler *handlers.SignedDownloadHandler,
+	galleryHandler *handlers.GalleryHandler,
+	searchHandler *handlers.SearchHandler,
+	lifecycleHandler *handlers.LifecycleHandler,
+	organizationHandler *handlers.OrganizationHandler,
+	notificationHandler *handlers.NotificationHandler,
+	webSocketHandler *handlers.WebSocketHandler,
+	tokenService admin.AccessTokenService,
+	oauthService admin.OAuthService,
+	sessionService admin.SessionService,
+	userService admin.UserService,
+	authorizer authz.Authorizer,
+	redisCache *cache.RedisCache,
 	cfg *config.Config,
 ) *gin.Engine {
 	// 设置 Gin 模式，开发环境为 DebugMode，生产环境为 ReleaseMode
 	gin.SetMode(gin.DebugMode) // 或者根据 routerCfg.cfg.AppCfg.Server.Env 来设置
 
-	router := gin.Default() // 使用默认的 Gin 引擎，包含 Logger 和 Recovery 中间件
+	router := gin.New()
+	router.Use(gin.Recovery())
 
 	// 全局中间件 CORS 跨域处理 (前端分离)
 	router.Use(middlewares.Cors())
+	// 全局中间件 将客户端IP等信息注入请求上下文
+	router.Use(middlewares.RequestContext())
+	// 全局中间件 记录请求数量和耗时的 Prometheus 指标
+	router.Use(middlewares.Metrics())
+	// 全局中间件 为每个请求创建根 span,并延续客户端传入的 traceparent
+	router.Use(otelgin.Middleware(cfg.Tracing.ServiceName, otelgin.WithFilter(func(r *http.Request) bool {
+		return r.URL.Path != "/metrics" && r.URL.Path != "/ping"
+	})))
+	// 全局中间件 结构化请求日志,放在 tracing 之后以记录 trace_id,关闭时使用 gin 格式的访问日志。
+	// 两者都不会记录 access_token 等敏感查询参数
+	if cfg.Log.Request.Enabled {
+		router.Use(middlewares.RequestLogger(cfg.Log.Request))
+	} else {
+		router.Use(middlewares.AccessLogger())
+	}
+
+	// 路由按分组声明访问策略(见 route.go),认证、权限和限流中间件由 routeBuilder 统一挂载。
+	// 开销较大的接口按用户和IP限流,规则见配置文件 rate_limit
+	routes := &routeBuilder{
+		auth:    middlewares.AuthMiddleware(cfg, tokenService, oauthService, sessionService, userService),
+		admin:   middlewares.RequireAdmin(authorizer),
+		limiter: middlewares.NewRateLimiter(redisCache, cfg.RateLimit),
+	}
 
 	// Health Check 路由
 	router.GET("/ping", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "pong"})
 	})
 
+	// Prometheus 抓取接口,配置了抓取令牌时按令牌校验,否则只允许管理员访问
+	metricsHandler := gin.WrapH(promhttp.Handler())
+	if cfg.Metrics.Token != "" {
+		router.GET("/metrics", middlewares.BearerToken(cfg.Metrics.Token), metricsHandler)
+	} else {
+		routes.register(router, []Group{{
+			Prefix: "/metrics",
+			Access: Admin,
+			Routes: []Route{{Method: http.MethodGet, Handler: metricsHandler}},
+		}})
+	}
+
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	v1 := router.Group("/api/v1")
-	{
-		// 认证相关路由 (无需认证)
-		authGroup := v1.Group("/auth")
-		{
-			authGroup.POST("/register", authHandler.Register)
-			authGroup.POST("/login", authHandler.Login)
-			authGroup.POST("/refresh", authHandler.RefreshToken)
-		}
+	// 实时事件推送,浏览器通过 access_token 查询参数传递令牌
+	if cfg.WebSocket.Enabled {
+		routes.register(router, []Group{{
+			Prefix:     "/ws",
+			Access:     Authenticated,
+			Scope:      Scope{Read: models.OAuthScopeFilesRead, Write: models.OAuthScopeFilesWrite},
+			QueryToken: true,
+			Routes: []Route{
+				{Method: http.MethodGet, Handler: webSocketHandler.Connect},
+			},
+		}})
+	}
 
-		// 需要认证的路由组
-		authenticated := v1.Group("/")
-		authenticated.Use(middlewares.AuthMiddleware(cfg))
+	// 本地存储的签名下载链接,令牌即凭证,不经过登录认证
+	if cfg.UsesStorage("local") {
+		routes.register(router, []Group{{
+			Prefix:    "/d",
+			Access:    Public,
+			RateLimit: "download",
+			Routes: []Route{
+				{Method: http.MethodGet, Path: "/:token", Handler: signedDownloadHandler.Download},
+				{Method: http.MethodHead, Path: "/:token", Handler: signedDownloadHandler.Download},
+			},
+		}})
+	}
 
+	// 各版本的 API 共用同一套路由和 handler,版本之间的差异由 response 按请求的版本适配响应格式
+	filesScope := Scope{Read: models.OAuthScopeFilesRead, Write: models.OAuthScopeFilesWrite}
+	api := []Group{
+		// 认证相关路由 (无需认证)
+		{
+			Prefix: "/auth",
+			Access: Public,
+			Routes: []Route{
+				{Method: http.MethodPost, Path: "/register", Handler: authHandler.Register},
+				{Method: http.MethodPost, Path: "/login", Handler: authHandler.Login},
+				{Method: http.MethodPost, Path: "/refresh", Handler: authHandler.RefreshToken},
+				{Method: http.MethodPost, Path: "/verify", Handler: authHandler.VerifyEmail},
+				{Method: http.MethodPost, Path: "/forgot", Handler: authHandler.ForgotPassword, RateLimit: "auth_email"},
+				{Method: http.MethodPost, Path: "/reset", Handler: authHandler.ResetPassword},
+				{Method: http.MethodPost, Path: "/2fa", Handler: authHandler.TwoFactorLogin, RateLimit: "auth_2fa"},
+			},
+		},
+		// OnlyOffice 文档服务器回调 (由回调 token 和文档服务器签名校验)
+		{
+			Prefix: "/office",
+			Access: Callback,
+			Routes: []Route{
+				{Method: http.MethodPost, Path: "/callback", Handler: officeHandler.Callback},
+			},
+		},
+		// OAuth2 令牌接口 (无需用户认证,由应用密钥校验)
+		{
+			Prefix: "/oauth",
+			Access: Callback,
+			Routes: []Route{
+				{Method: http.MethodPost, Path: "/token", Handler: oauthHandler.Token},
+			},
+		},
+		// 错误码目录 (无需认证)
+		{
+			Prefix: "/errors",
+			Access: Public,
+			Routes: []Route{
+				{Method: http.MethodGet, Handler: handlers.ListErrorCodes},
+				{Method: http.MethodGet, Path: "/:code", Handler: handlers.GetErrorCode},
+			},
+		},
 		// 用户相关路由
-		userGroup := authenticated.Group("/users")
 		{
-			userGroup.GET("/me", userHandler.GetUserProfile)
-		}
-
+			Prefix: "/users",
+			Access: Authenticated,
+			Routes: []Route{
+				{Method: http.MethodGet, Path: "/me", Handler: userHandler.GetUserProfile},
+				{Method: http.MethodPut, Path: "/me/settings", Handler: userHandler.UpdateUserSettings},
+				{Method: http.MethodPatch, Path: "/me/settings", Handler: userHandler.UpdateUserSettings},
+				{Method: http.MethodGet, Path: "/me/activity", Handler: activityHandler.ListUserActivities},
+				{Method: http.MethodGet, Path: "/me/usage", Handler: fileHandler.GetStorageUsage},
+				{Method: http.MethodPost, Path: "/me/verify-email", Handler: authHandler.ResendVerificationEmail, RateLimit: "auth_email"},
+				{Method: http.MethodPost, Path: "/me/export", Handler: exportHandler.RequestExport},
+				{Method: http.MethodGet, Path: "/me/exports", Handler: exportHandler.ListExports},
+				{Method: http.MethodGet, Path: "/me/exports/:export_id", Handler: exportHandler.GetExport},
+			},
+		},
+		// 站内通知
+		{
+			Prefix: "/notifications",
+			Access: Authenticated,
+			Routes: []Route{
+				{Method: http.MethodGet, Handler: notificationHandler.ListNotifications},
+				{Method: http.MethodPost, Path: "/read", Handler: notificationHandler.MarkRead},
+				{Method: http.MethodPost, Path: "/read-all", Handler: notificationHandler.MarkAllRead},
+			},
+		},
+		// 两步验证管理
+		{
+			Prefix: "/users/me/2fa",
+			Access: SessionOnly,
+			Routes: []Route{
+				{Method: http.MethodPost, Path: "/enroll", Handler: authHandler.EnrollTwoFactor},
+				{Method: http.MethodPost, Path: "/verify", Handler: authHandler.EnableTwoFactor, RateLimit: "auth_2fa"},
+				{Method: http.MethodPost, Path: "/disable", Handler: authHandler.DisableTwoFactor, RateLimit: "auth_2fa"},
+			},
+		},
+		// 登录会话管理
+		{
+			Prefix: "/users/me/sessions",
+			Access: SessionOnly,
+			Routes: []Route{
+				{Method: http.MethodGet, Handler: authHandler.ListSessions},
+				{Method: http.MethodDelete, Path: "/:session_id", Handler: authHandler.RevokeSession},
+			},
+		},
+		// 注销账号
+		{
+			Prefix: "/users/me",
+			Access: SessionOnly,
+			Routes: []Route{
+				{Method: http.MethodDelete, Handler: userHandler.DeleteAccount},
+			},
+		},
+		// 个人访问令牌管理
+		{
+			Prefix: "/users/me/tokens",
+			Access: SessionOnly,
+			Routes: []Route{
+				{Method: http.MethodPost, Handler: accessTokenHandler.CreateToken},
+				{Method: http.MethodGet, Handler: accessTokenHandler.ListTokens},
+				{Method: http.MethodDelete, Path: "/:token_id", Handler: accessTokenHandler.RevokeToken},
+			},
+		},
+		// 第三方应用授权管理
+		{
+			Prefix: "/users/me/authorizations",
+			Access: SessionOnly,
+			Routes: []Route{
+				{Method: http.MethodGet, Handler: oauthHandler.ListAuthorizations},
+				{Method: http.MethodDelete, Path: "/:authorization_id", Handler: oauthHandler.RevokeAuthorization},
+			},
+		},
+		// OAuth2 应用注册和授权确认
+		{
+			Prefix: "/oauth",
+			Access: SessionOnly,
+			Routes: []Route{
+				{Method: http.MethodGet, Path: "/authorize", Handler: oauthHandler.GetConsent},
+				{Method: http.MethodPost, Path: "/authorize", Handler: oauthHandler.Authorize},
+				{Method: http.MethodPost, Path: "/clients", Handler: oauthHandler.RegisterClient},
+				{Method: http.MethodGet, Path: "/clients", Handler: oauthHandler.ListClients},
+				{Method: http.MethodDelete, Path: "/clients/:client_id", Handler: oauthHandler.DeleteClient},
+			},
+		},
 		// 文件相关路由
-		fileGroup := authenticated.Group("/files")
-		{
-
-			fileGroup.GET("", fileHandler.ListUserFiles)
-			fileGroup.GET("/:file_id", fileHandler.GetSpecificFile)
-			fileGroup.POST("/folder", fileHandler.CreateFolder)
-			fileGroup.GET("/download/:file_id", fileHandler.DownloadFile)
-			fileGroup.GET("/download/folder/:id", fileHandler.DownloadFolder)
-			fileGroup.DELETE("/softdelete/:file_id", fileHandler.SoftDeleteFile)
-			fileGroup.DELETE("/permanentdelete/:file_id", fileHandler.PermanentDeleteFile)
-			fileGroup.GET("/recyclebin", fileHandler.ListRecycleBinFiles)
-			fileGroup.PUT("/restore/:file_id", fileHandler.RestoreFile)
-			fileGroup.PUT("/rename/:id", fileHandler.RenameFile)
-			fileGroup.PUT("/move", fileHandler.MoveFile)
-
-			//fileVersion
-			fileGroup.DELETE("/:file_id/versions/:version_id", fileHandler.DeleteFileVersion)
-			fileGroup.GET("/versions/:file_id", fileHandler.ListFileVersions)
-			fileGroup.POST("/:file_id/versions/:version_id/restore", fileHandler.RestoreFileVersion)
-		}
-
-		// 分享相关路由 (需要认证)
-		shareAuthGroup := authenticated.Group("/shares")
-		{
-			shareAuthGroup.POST("/", shareHandler.CreateShare)
-			shareAuthGroup.GET("/my", shareHandler.ListUserShares)
-			shareAuthGroup.DELETE("/:share_id", shareHandler.RevokeShare)
-		}
-
-		// 注册断点续传路由
-		uploadRoutes := authenticated.Group("/uploads")
-		{
-			uploadRoutes.POST("/init", uploadHandler.InitUploadHandler)
-			uploadRoutes.POST("/chunk", uploadHandler.UploadChunkHandler)
-			uploadRoutes.POST("/complete", uploadHandler.CompleteUploadHandler)
-		}
-	}
+		{
+			Prefix: "/files",
+			Access: Authenticated,
+			Scope:  filesScope,
+			Routes: []Route{
+				{Method: http.MethodGet, Handler: fileHandler.ListUserFiles},
+				{Method: http.MethodGet, Path: "/:file_id", Handler: fileHandler.GetSpecificFile},
+				{Method: http.MethodGet, Path: "/by-path", Handler: fileHandler.GetFileByPath},
+				{Method: http.MethodGet, Path: "/manifest", Handler: fileHandler.GetManifest},
+				{Method: http.MethodGet, Path: "/search", Handler: searchHandler.SearchFiles},
+				{Method: http.MethodPost, Path: "/warm", Handler: fileHandler.WarmCache},
+				{Method: http.MethodGet, Path: "/shared-with-me", Handler: permissionHandler.ListSharedWithMe},
+				{Method: http.MethodGet, Path: "/starred", Handler: favoriteHandler.ListStarredFiles},
+				{Method: http.MethodGet, Path: "/recent", Handler: favoriteHandler.ListRecentFiles},
+				{Method: http.MethodGet, Path: "/tags", Handler: tagHandler.ListTags},
+				{Method: http.MethodPost, Path: "/folder", Handler: fileHandler.CreateFolder},
+				{Method: http.MethodPost, Path: "/link", Handler: fileHandler.CreateLink},
+				{Method: http.MethodGet, Path: "/folder/:id/size", Handler: fileHandler.GetFolderSize},
+				{Method: http.MethodPut, Path: "/folder/:id/settings", Handler: fileHandler.UpdateFolderSettings},
+				{Method: http.MethodPatch, Path: "/:file_id/attributes", Handler: fileHandler.UpdateAttributes},
+				{Method: http.MethodGet, Path: "/download/:file_id", Handler: fileHandler.DownloadFile, RateLimit: "download"},
+				{Method: http.MethodHead, Path: "/download/:file_id", Handler: fileHandler.HeadDownloadFile},
+				{Method: http.MethodGet, Path: "/download/folder/:id", Handler: fileHandler.DownloadFolder, RateLimit: "download"},
+				{Method: http.MethodPost, Path: "/download/batch", Handler: fileHandler.DownloadBatch, RateLimit: "download"},
+				{Method: http.MethodPost, Path: "/metadata/batch", Handler: fileHandler.GetFilesMetadata},
+				{Method: http.MethodPost, Path: "/:file_id/archive", Handler: archiveHandler.RequestArchive},
+				{Method: http.MethodGet, Path: "/archives/:job_id", Handler: archiveHandler.GetArchive},
+				{Method: http.MethodGet, Path: "/archives/:job_id/download", Handler: archiveHandler.DownloadArchive, RateLimit: "download"},
+				{Method: http.MethodPost, Path: "/:file_id/extract", Handler: extractHandler.RequestExtract},
+				{Method: http.MethodGet, Path: "/extracts/:job_id", Handler: extractHandler.GetExtract},
+				{Method: http.MethodGet, Path: "/:file_id/preview", Handler: fileHandler.GetFilePreview},
+				{Method: http.MethodGet, Path: "/:file_id/stats", Handler: fileHandler.GetFileStats},
+				{Method: http.MethodGet, Path: "/:file_id/stat", Handler: fileHandler.StatFile},
+				{Method: http.MethodDelete, Path: "/softdelete/:file_id", Handler: fileHandler.SoftDeleteFile},
+				{Method: http.MethodDelete, Path: "/permanentdelete/:file_id", Handler: fileHandler.PermanentDeleteFile},
+				{Method: http.MethodGet, Path: "/purge-jobs/:job_id", Handler: fileHandler.GetPurgeJob},
+				{Method: http.MethodGet, Path: "/recyclebin", Handler: fileHandler.ListRecycleBinFiles},
+				{Method: http.MethodGet, Path: "/recyclebin/stats", Handler: fileHandler.GetRecycleBinStats},
+				{Method: http.MethodPut, Path: "/restore/:file_id", Handler: fileHandler.RestoreFile},
+				{Method: http.MethodPut, Path: "/rename/:id", Handler: fileHandler.RenameFile},
+				{Method: http.MethodPut, Path: "/move", Handler: fileHandler.MoveFile},
+				{Method: http.MethodPost, Path: "/batch/move", Handler: fileHandler.BatchMoveFiles},
+				{Method: http.MethodPost, Path: "/organize", Handler: fileHandler.OrganizeFiles},
+				{Method: http.MethodPost, Path: "/batch/softdelete", Handler: fileHandler.BatchSoftDeleteFiles},
+				{Method: http.MethodPost, Path: "/:file_id/transfer", Handler: transferHandler.TransferFile},
+
+				//fileVersion
+				{Method: http.MethodDelete, Path: "/:file_id/versions/:version_id", Handler: fileHandler.DeleteFileVersion},
+				{Method: http.MethodGet, Path: "/versions/:file_id", Handler: fileHandler.ListFileVersions},
+				{Method: http.MethodPost, Path: "/:file_id/versions/:version_id/restore", Handler: fileHandler.RestoreFileVersion},
+				{Method: http.MethodGet, Path: "/:file_id/versions/:version_id/download", Handler: fileHandler.DownloadFileVersion, RateLimit: "download"},
 
-	// 公开的分享链接路由 (无需认证)
-	sharePublicGroup := router.Group("/share")
-	{
-		sharePublicGroup.GET("/:share_uuid/details", shareHandler.GetShareDetails)
-		sharePublicGroup.POST("/:share_uuid/verify", shareHandler.VerifySharePassword)
-		sharePublicGroup.GET("/:share_uuid/download", shareHandler.DownloadSharedContent)
+				//activity
+				{Method: http.MethodGet, Path: "/:file_id/activity", Handler: activityHandler.ListFileActivities},
+				{Method: http.MethodPost, Path: "/:file_id/lock", Handler: fileHandler.LockFile},
+				{Method: http.MethodDelete, Path: "/:file_id/lock", Handler: fileHandler.UnlockFile},
+				{Method: http.MethodPost, Path: "/:file_id/star", Handler: favoriteHandler.StarFile},
+				{Method: http.MethodDelete, Path: "/:file_id/star", Handler: favoriteHandler.UnstarFile},
+				{Method: http.MethodGet, Path: "/:file_id/tags", Handler: tagHandler.GetFileTags},
+				{Method: http.MethodPost, Path: "/:file_id/tags", Handler: tagHandler.AddTags},
+				{Method: http.MethodDelete, Path: "/:file_id/tags/:tag", Handler: tagHandler.RemoveTag},
+				{Method: http.MethodGet, Path: "/:file_id/comments", Handler: commentHandler.ListComments},
+				{Method: http.MethodPost, Path: "/:file_id/comments", Handler: commentHandler.PostComment},
+				{Method: http.MethodDelete, Path: "/:file_id/comments/:comment_id", Handler: commentHandler.DeleteComment},
+				{Method: http.MethodGet, Path: "/:file_id/office/session", Handler: officeHandler.CreateSession},
+
+				//collaboration
+				{Method: http.MethodGet, Path: "/:file_id/permissions", Handler: permissionHandler.ListPermissions},
+				{Method: http.MethodPost, Path: "/:file_id/permissions", Handler: permissionHandler.GrantPermission},
+				{Method: http.MethodDelete, Path: "/:file_id/permissions/:user_id", Handler: permissionHandler.RevokePermission},
+			},
+		},
+		// 相册,跨文件夹按拍摄时间列出图片和视频
+		{
+			Prefix: "/gallery",
+			Access: Authenticated,
+			Scope:  filesScope,
+			Routes: []Route{
+				{Method: http.MethodGet, Handler: galleryHandler.ListGallery},
+			},
+		},
+		// 文件夹生命周期规则
+		{
+			Prefix: "/lifecycle/rules",
+			Access: Authenticated,
+			Scope:  filesScope,
+			Routes: []Route{
+				{Method: http.MethodGet, Handler: lifecycleHandler.ListRules},
+				{Method: http.MethodPost, Handler: lifecycleHandler.CreateRule},
+				{Method: http.MethodPatch, Path: "/:rule_id", Handler: lifecycleHandler.UpdateRule},
+				{Method: http.MethodDelete, Path: "/:rule_id", Handler: lifecycleHandler.DeleteRule},
+				{Method: http.MethodPost, Path: "/:rule_id/run", Handler: lifecycleHandler.RunRule},
+			},
+		},
+		// 组织(团队空间)路由,团队文件的上传和修改使用文件路由
+		{
+			Prefix: "/orgs",
+			Access: Authenticated,
+			Scope:  filesScope,
+			Routes: []Route{
+				{Method: http.MethodPost, Handler: organizationHandler.CreateOrganization},
+				{Method: http.MethodGet, Handler: organizationHandler.ListOrganizations},
+				{Method: http.MethodGet, Path: "/:org_id", Handler: organizationHandler.GetOrganization},
+				{Method: http.MethodGet, Path: "/:org_id/files", Handler: organizationHandler.ListFiles},
+				{Method: http.MethodGet, Path: "/:org_id/members", Handler: organizationHandler.ListMembers},
+				{Method: http.MethodPost, Path: "/:org_id/members", Handler: organizationHandler.AddMember},
+				{Method: http.MethodDelete, Path: "/:org_id/members/:user_id", Handler: organizationHandler.RemoveMember},
+			},
+		},
+		// 分享管理
+		{
+			Prefix: "/shares",
+			Access: Authenticated,
+			Scope:  Scope{Read: models.OAuthScopeSharesManage, Write: models.OAuthScopeSharesManage},
+			Routes: []Route{
+				{Method: http.MethodPost, Path: "/", Handler: shareHandler.CreateShare, Middlewares: []gin.HandlerFunc{middlewares.RequireVerifiedEmail(userService)}},
+				{Method: http.MethodGet, Path: "/my", Handler: shareHandler.ListUserShares},
+				{Method: http.MethodGet, Path: "/received", Handler: shareHandler.ListReceivedShares},
+				{Method: http.MethodGet, Path: "/received/:share_id/files", Handler: shareHandler.ListReceivedShareFiles},
+				{Method: http.MethodGet, Path: "/received/:share_id/download", Handler: shareHandler.DownloadReceivedShare, RateLimit: "download"},
+				{Method: http.MethodDelete, Path: "/:share_id", Handler: shareHandler.RevokeShare},
+				{Method: http.MethodPost, Path: "/:share_id/rotate", Handler: shareHandler.RotateShare},
+				{Method: http.MethodGet, Path: "/:share_id/analytics", Handler: shareHandler.GetShareAnalytics},
+			},
+		},
+		// 管理员接口
+		{
+			Prefix: "/admin",
+			Access: Admin,
+			Routes: []Route{
+				{Method: http.MethodPut, Path: "/users/:user_id/bandwidth", Handler: adminHandler.SetUserBandwidth},
+				{Method: http.MethodPut, Path: "/shares/:share_id/bandwidth", Handler: adminHandler.SetShareBandwidth},
+				{Method: http.MethodGet, Path: "/dead-letters", Handler: adminHandler.ListDeadLetters},
+				{Method: http.MethodPost, Path: "/dead-letters/:id/replay", Handler: adminHandler.ReplayDeadLetter},
+				{Method: http.MethodPost, Path: "/storage/migrations", Handler: adminHandler.StartStorageMigration},
+				{Method: http.MethodGet, Path: "/storage/migrations", Handler: adminHandler.ListStorageMigrations},
+				{Method: http.MethodGet, Path: "/storage/migrations/:id", Handler: adminHandler.GetStorageMigration},
+				{Method: http.MethodPost, Path: "/storage/migrations/:id/resume", Handler: adminHandler.ResumeStorageMigration},
+				{Method: http.MethodPost, Path: "/storage/files/:file_id/migrate", Handler: adminHandler.MigrateFileStorage},
+				{Method: http.MethodPost, Path: "/storage/rebalance", Handler: adminHandler.StartStorageRebalance},
+				{Method: http.MethodPost, Path: "/files/:file_id/integrity/recheck", Handler: adminHandler.RecheckFileIntegrity},
+				{Method: http.MethodGet, Path: "/account-deletions", Handler: adminHandler.ListAccountDeletions},
+				{Method: http.MethodPost, Path: "/account-deletions/:id/retry", Handler: adminHandler.RetryAccountDeletion},
+			},
+		},
+		// 断点续传
+		{
+			Prefix: "/uploads",
+			Access: Authenticated,
+			Scope:  Scope{Any: []string{models.TokenScopeUpload, models.OAuthScopeFilesWrite}},
+			Routes: []Route{
+				{Method: http.MethodPost, Path: "/init", Handler: uploadHandler.InitUploadHandler},
+				{Method: http.MethodPost, Path: "/proof", Handler: uploadHandler.SubmitProofHandler},
+				{Method: http.MethodPost, Path: "/chunk", Handler: uploadHandler.UploadChunkHandler, RateLimit: "upload_chunk"},
+				{Method: http.MethodPost, Path: "/complete", Handler: uploadHandler.CompleteUploadHandler},
+				{Method: http.MethodDelete, Path: "/:upload_id", Handler: uploadHandler.AbortUploadHandler},
+			},
+		},
 	}
+	routes.register(router.Group("/api/v1", middlewares.APIVersion(response.APIVersion1, cfg.API)), api)
+	routes.register(router.Group("/api/v2", middlewares.APIVersion(response.APIVersion2, cfg.API)), api)
+
+	// 公开的分享链接路由
+	routes.register(router, []Group{{
+		Prefix:    "/share",
+		Access:    ShareLink,
+		RateLimit: "share_access",
+		Routes: []Route{
+			{Method: http.MethodGet, Path: "/:share_uuid/details", Handler: shareHandler.GetShareDetails},
+			{Method: http.MethodPost, Path: "/:share_uuid/verify", Handler: shareHandler.VerifySharePassword},
+			{Method: http.MethodGet, Path: "/:share_uuid/download", Handler: shareHandler.DownloadSharedContent},
+			{Method: http.MethodGet, Path: "/:share_uuid/raw", Handler: shareHandler.ServeDirectShare},
+		},
+	}})
 
 	router.NoRoute(func(c *gin.Context) {
-		response.Error(c, http.StatusNotFound, http.StatusNotFound, "Route not found")
+		response.Error(c, http.StatusNotFound, xerr.NotFoundCode, "Route not found")
 	})
 
 	return router
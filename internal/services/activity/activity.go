@@ -0,0 +1,85 @@
+package activity
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"go.uber.org/zap"
+)
+
+// QueueName 活动日志消息队列名称
+const QueueName = "activity_log_queue"
+
+// ActivityService 定义了活动日志服务需要实现的接口
+type ActivityService interface {
+	// Record 异步记录一条活动日志，操作者和IP从 ctx 中获取。记录失败不会影响主流程
+	Record(ctx context.Context, ownerID uint64, fileID uint64, action string, detail string)
+	// ListFileActivities 分页查询指定文件的活动日志
+	ListFileActivities(ctx context.Context, userID uint64, fileID uint64, page, pageSize int) ([]models.Activity, int64, error)
+	// ListUserActivities 分页查询用户空间内的活动日志
+	ListUserActivities(ctx context.Context, userID uint64, page, pageSize int) ([]models.Activity, int64, error)
+}
+
+type activityService struct {
+	activityRepo repositories.ActivityRepository
+	outbox       repositories.OutboxRepository
+}
+
+var _ ActivityService = (*activityService)(nil)
+
+// NewActivityService 创建一个新的 ActivityService 实例
+func NewActivityService(activityRepo repositories.ActivityRepository, outbox repositories.OutboxRepository) ActivityService {
+	return &activityService{
+		activityRepo: activityRepo,
+		outbox:       outbox,
+	}
+}
+
+func (s *activityService) Record(ctx context.Context, ownerID uint64, fileID uint64, action string, detail string) {
+	activity := models.Activity{
+		UserID:    ownerID,
+		FileID:    &fileID,
+		Action:    action,
+		IP:        utils.ClientIPFromContext(ctx),
+		Detail:    detail,
+		CreatedAt: time.Now(),
+	}
+	if actorID, ok := utils.ActorIDFromContext(ctx); ok {
+		activity.ActorID = &actorID
+	}
+
+	event, err := models.NewQueueEvent(QueueName, activity)
+	if err != nil {
+		logger.Error("Record: Failed to marshal activity", zap.String("action", action), zap.Uint64("fileID", fileID), zap.Error(err))
+		return
+	}
+
+	// 写入发件箱,RabbitMQ 暂时不可用时由 OutboxWorker 重试投递
+	if err := s.outbox.Add(context.WithoutCancel(ctx), event); err != nil {
+		logger.Error("Record: Failed to publish activity", zap.String("action", action), zap.Uint64("fileID", fileID), zap.Error(err))
+	}
+}
+
+func (s *activityService) ListFileActivities(ctx context.Context, userID uint64, fileID uint64, page, pageSize int) ([]models.Activity, int64, error) {
+	activities, total, err := s.activityRepo.FindByFileID(ctx, userID, fileID, page, pageSize)
+	if err != nil {
+		logger.Error("ListFileActivities: Failed to query activities", zap.Uint64("userID", userID), zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, 0, fmt.Errorf("activity service: %w", xerr.ErrDatabaseError)
+	}
+	return activities, total, nil
+}
+
+func (s *activityService) ListUserActivities(ctx context.Context, userID uint64, page, pageSize int) ([]models.Activity, int64, error) {
+	activities, total, err := s.activityRepo.FindByUserID(ctx, userID, page, pageSize)
+	if err != nil {
+		logger.Error("ListUserActivities: Failed to query activities", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, 0, fmt.Errorf("activity service: %w", xerr.ErrDatabaseError)
+	}
+	return activities, total, nil
+}
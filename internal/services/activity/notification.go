@@ -0,0 +1,117 @@
+package activity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"go.uber.org/zap"
+)
+
+// NotificationService 站内通知。通知写入数据库,开启推送时同时发布到用户的 Redis 频道,
+// 由 WebSocket 会话转发给在线的客户端
+type NotificationService interface {
+	// Notify 创建一条通知,失败只记录日志,不影响主流程
+	Notify(ctx context.Context, notification *models.Notification)
+	// NotifyOnce 与 Notify 相同,但同一个 key 在 window 内只通知一次,用于分享访问等频繁发生的事件
+	NotifyOnce(ctx context.Context, key string, window time.Duration, notification *models.Notification)
+	// List 分页查询通知,同时返回未读数量
+	List(ctx context.Context, userID uint64, unreadOnly bool, page, pageSize int) ([]models.Notification, int64, int64, error)
+	// MarkRead 将指定通知标记为已读,返回实际更新的数量
+	MarkRead(ctx context.Context, userID uint64, ids []uint64) (int64, error)
+	// MarkAllRead 将全部未读通知标记为已读
+	MarkAllRead(ctx context.Context, userID uint64) (int64, error)
+}
+
+type notificationService struct {
+	notificationRepo repositories.NotificationRepository
+	cache            *cache.RedisCache
+	cfg              config.NotificationConfig
+}
+
+var _ NotificationService = (*notificationService)(nil)
+
+func NewNotificationService(notificationRepo repositories.NotificationRepository, cache *cache.RedisCache, cfg config.NotificationConfig) NotificationService {
+	return &notificationService{
+		notificationRepo: notificationRepo,
+		cache:            cache,
+		cfg:              cfg,
+	}
+}
+
+func (s *notificationService) Notify(ctx context.Context, notification *models.Notification) {
+	// 通知在主流程完成之后创建,请求取消不应导致通知丢失
+	ctx = context.WithoutCancel(ctx)
+	if notification.CreatedAt.IsZero() {
+		notification.CreatedAt = time.Now()
+	}
+	if err := s.notificationRepo.Create(ctx, notification); err != nil {
+		logger.Error("Notify: Failed to create notification",
+			zap.Uint64("userID", notification.UserID), zap.String("type", notification.Type), zap.Error(err))
+		return
+	}
+
+	if !s.cfg.Push {
+		return
+	}
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		logger.Error("Notify: Failed to marshal notification", zap.Uint64("notificationID", notification.ID), zap.Error(err))
+		return
+	}
+	// 推送失败时客户端仍可以通过列表接口获取
+	_ = s.cache.Publish(ctx, cache.GenerateNotificationChannel(notification.UserID), string(payload))
+}
+
+func (s *notificationService) NotifyOnce(ctx context.Context, key string, window time.Duration, notification *models.Notification) {
+	if window > 0 {
+		first, err := s.cache.SetNX(context.WithoutCancel(ctx), cache.GenerateNotificationOnceKey(key), 1, window)
+		if err != nil {
+			logger.Warn("NotifyOnce: Failed to check notification window", zap.String("key", key), zap.Error(err))
+		} else if !first {
+			return
+		}
+	}
+	s.Notify(ctx, notification)
+}
+
+func (s *notificationService) List(ctx context.Context, userID uint64, unreadOnly bool, page, pageSize int) ([]models.Notification, int64, int64, error) {
+	notifications, total, err := s.notificationRepo.FindByUserID(ctx, userID, unreadOnly, page, pageSize)
+	if err != nil {
+		logger.Error("ListNotifications: Failed to query notifications", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, 0, 0, fmt.Errorf("notification service: %w", xerr.ErrDatabaseError)
+	}
+	unread := total
+	if !unreadOnly {
+		if unread, err = s.notificationRepo.CountUnread(ctx, userID); err != nil {
+			logger.Error("ListNotifications: Failed to count unread notifications", zap.Uint64("userID", userID), zap.Error(err))
+			return nil, 0, 0, fmt.Errorf("notification service: %w", xerr.ErrDatabaseError)
+		}
+	}
+	return notifications, total, unread, nil
+}
+
+func (s *notificationService) MarkRead(ctx context.Context, userID uint64, ids []uint64) (int64, error) {
+	updated, err := s.notificationRepo.MarkRead(ctx, userID, ids, time.Now())
+	if err != nil {
+		logger.Error("MarkRead: Failed to mark notifications read", zap.Uint64("userID", userID), zap.Error(err))
+		return 0, fmt.Errorf("notification service: %w", xerr.ErrDatabaseError)
+	}
+	return updated, nil
+}
+
+func (s *notificationService) MarkAllRead(ctx context.Context, userID uint64) (int64, error) {
+	updated, err := s.notificationRepo.MarkAllRead(ctx, userID, time.Now())
+	if err != nil {
+		logger.Error("MarkAllRead: Failed to mark notifications read", zap.Uint64("userID", userID), zap.Error(err))
+		return 0, fmt.Errorf("notification service: %w", xerr.ErrDatabaseError)
+	}
+	return updated, nil
+}
@@ -7,7 +7,9 @@ import (
 
 	"github.com/3Eeeecho/go-clouddisk/internal/config"
 	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mail"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
 	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
@@ -17,28 +19,62 @@ import (
 )
 
 type AuthService interface {
-	RegisterUser(username, password, email string) (*models.User, error)
-	LoginUser(username, password string) (string, error)
+	RegisterUser(ctx context.Context, username, password, email string) (*models.User, error)
+	// LoginUser 校验用户名和密码并创建登录会话,开启了两步验证的用户返回中间 Token,需要通过 CompleteTwoFactorLogin 换取登录 Token
+	LoginUser(ctx context.Context, username, password string, userAgent string) (*LoginResult, error)
+	// SendVerificationEmail 重新发送邮箱验证邮件
+	SendVerificationEmail(ctx context.Context, userID uint64) error
+	// VerifyEmail 使用邮件中的令牌完成邮箱验证
+	VerifyEmail(ctx context.Context, token string) error
+	// ForgotPassword 向邮箱发送密码重置链接,邮箱未注册时同样返回成功
+	ForgotPassword(ctx context.Context, email string) error
+	// ResetPassword 使用邮件中的令牌设置新密码
+	ResetPassword(ctx context.Context, token string, newPassword string) error
+	// CompleteTwoFactorLogin 使用中间 Token 和两步验证码(或恢复码)换取登录 Token
+	CompleteTwoFactorLogin(ctx context.Context, twoFactorToken string, code string, userAgent string) (*TokenPair, error)
+	// EnrollTwoFactor 生成新的两步验证密钥,需要调用 EnableTwoFactor 确认后才生效
+	EnrollTwoFactor(ctx context.Context, userID uint64) (*TwoFactorEnrollment, error)
+	// EnableTwoFactor 校验验证码并开启两步验证,返回只展示一次的恢复码
+	EnableTwoFactor(ctx context.Context, userID uint64, code string) ([]string, error)
+	// DisableTwoFactor 校验验证码或恢复码并关闭两步验证
+	DisableTwoFactor(ctx context.Context, userID uint64, code string) error
+}
+
+// LoginResult 登录结果,TwoFactorRequired 为 true 时只返回等待两步验证的中间 Token
+type LoginResult struct {
+	Tokens            *TokenPair
+	TwoFactorRequired bool
+	TwoFactorToken    string
 }
 
 type authService struct {
-	userRepo repositories.UserRepository
-	jwtCfg   *config.JWTConfig
+	userRepo         repositories.UserRepository
+	recoveryCodeRepo repositories.RecoveryCodeRepository
+	sessionService   SessionService
+	cache            cache.Cache
+	mailer           mail.Sender
+	jwtCfg           *config.JWTConfig
+	mailCfg          *config.MailConfig
 }
 
 // 确保authService实现了AuthService的方法
 var _ AuthService = (*authService)(nil)
 
-func NewAuthService(userRepo repositories.UserRepository, cfg *config.JWTConfig) AuthService {
+func NewAuthService(userRepo repositories.UserRepository, recoveryCodeRepo repositories.RecoveryCodeRepository, sessionService SessionService, c cache.Cache, mailer mail.Sender, jwtCfg *config.JWTConfig, mailCfg *config.MailConfig) AuthService {
 	return &authService{
-		userRepo: userRepo,
-		jwtCfg:   cfg,
+		userRepo:         userRepo,
+		recoveryCodeRepo: recoveryCodeRepo,
+		sessionService:   sessionService,
+		cache:            c,
+		mailer:           mailer,
+		jwtCfg:           jwtCfg,
+		mailCfg:          mailCfg,
 	}
 }
 
-func (s *authService) RegisterUser(username, password, email string) (*models.User, error) {
+func (s *authService) RegisterUser(ctx context.Context, username, password, email string) (*models.User, error) {
 	// 检查用户名是否存在
-	_, err := s.userRepo.GetUserByUsername(context.Background(), username)
+	_, err := s.userRepo.GetUserByUsername(ctx, username)
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		logger.Error("failed to check username existence", zap.String("username", username), zap.Error(err))
 		return nil, fmt.Errorf("auth service: failed to check username: %w", xerr.ErrDatabaseError)
@@ -49,7 +85,7 @@ func (s *authService) RegisterUser(username, password, email string) (*models.Us
 	}
 
 	// 检查邮箱是否存在
-	_, err = s.userRepo.GetUserByEmail(context.Background(), email)
+	_, err = s.userRepo.GetUserByEmail(ctx, email)
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		logger.Error("failed to check email existence", zap.String("email", email), zap.Error(err))
 		return nil, fmt.Errorf("auth service: failed to check email: %w", xerr.ErrDatabaseError)
@@ -77,7 +113,7 @@ func (s *authService) RegisterUser(username, password, email string) (*models.Us
 	}
 
 	// 调用 Repository 层
-	if err := s.userRepo.CreateUser(context.Background(), user); err != nil {
+	if err := s.userRepo.CreateUser(ctx, user); err != nil {
 		// 在 Service 层可以判断 Repository 返回的错误类型
 		// 这里假设 repository 层可能返回特定的业务错误
 		if xerr.Is(err, xerr.ErrUserAlreadyExists) {
@@ -90,28 +126,38 @@ func (s *authService) RegisterUser(username, password, email string) (*models.Us
 	}
 
 	logger.Info("User registered successfully", zap.String("username", user.Username))
+	// 验证邮件发送失败不影响注册,用户可以登录后重新发送
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		logger.Error("Failed to send verification email", zap.Uint64("userID", user.ID), zap.Error(err))
+	}
 	return user, nil
 }
 
-func (s *authService) LoginUser(identifier, password string) (string, error) {
+func (s *authService) LoginUser(ctx context.Context, identifier, password string, userAgent string) (*LoginResult, error) {
 	var user *models.User
 	var err error
 
 	// 尝试通过用户名或邮箱查找用户
-	user, err = s.userRepo.GetUserByUsername(context.Background(), identifier)
+	user, err = s.userRepo.GetUserByUsername(ctx, identifier)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		// 如果用户名未找到，尝试通过邮箱查找
-		user, err = s.userRepo.GetUserByEmail(context.Background(), identifier)
+		user, err = s.userRepo.GetUserByEmail(ctx, identifier)
 	}
 
 	// 处理查找用户过程中可能发生的错误
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			logger.Warn("Login failed: user not found", zap.String("identifier", identifier))
-			return "", fmt.Errorf("auth service: %w", xerr.ErrUserNotFound)
+			return nil, fmt.Errorf("auth service: %w", xerr.ErrUserNotFound)
 		}
 		logger.Error("Login failed: error getting user", zap.String("identifier", identifier), zap.Error(err))
-		return "", fmt.Errorf("auth service: failed to get user: %w", xerr.ErrDatabaseError)
+		return nil, fmt.Errorf("auth service: failed to get user: %w", xerr.ErrDatabaseError)
+	}
+
+	// 组织的网盘账号只用于持有团队文件,不能登录
+	if user.Role == models.RoleOrg {
+		logger.Warn("Login failed: organization drive account", zap.String("identifier", identifier))
+		return nil, fmt.Errorf("auth service: %w", xerr.ErrInvalidCredentials)
 	}
 
 	// 验证密码
@@ -119,26 +165,34 @@ func (s *authService) LoginUser(identifier, password string) (string, error) {
 	if err != nil {
 		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
 			logger.Warn("Login failed: invalid credentials", zap.String("identifier", identifier))
-			return "", fmt.Errorf("auth service: %w", xerr.ErrInvalidCredentials)
+			return nil, fmt.Errorf("auth service: %w", xerr.ErrInvalidCredentials)
 		}
 		logger.Error("Login failed: failed to compare password", zap.String("identifier", identifier), zap.Error(err))
-		return "", fmt.Errorf("auth service: failed to compare password: %w", err)
-	}
-
-	// 生成JWT Token
-	tokenString, err := utils.GenerateToken(
-		user.ID,
-		user.Username,
-		user.Email,
-		s.jwtCfg.SecretKey,
-		s.jwtCfg.Issuer,
-		s.jwtCfg.ExpiresIn,
-	)
+		return nil, fmt.Errorf("auth service: failed to compare password: %w", err)
+	}
+
+	// 已申请注销的账号等待后台清除数据,不能再登录
+	if user.Status == models.UserStatusDeleting {
+		logger.Warn("Login failed: account scheduled for deletion", zap.Uint64("userID", user.ID))
+		return nil, fmt.Errorf("auth service: %w", xerr.ErrAccountDeletionPending)
+	}
+
+	// 开启了两步验证: 只签发中间 Token,验证码通过后再签发登录 Token
+	if user.TwoFactorEnabledAt != nil {
+		tokenString, err := utils.GenerateTwoFactorToken(user.ID, s.jwtCfg.SecretKey, s.jwtCfg.Issuer, twoFactorTokenTTL)
+		if err != nil {
+			logger.Error("Login failed: failed to generate two-factor token", zap.String("username", user.Username), zap.Error(err))
+			return nil, fmt.Errorf("auth service: failed to generate token: %w", err)
+		}
+		logger.Info("Two-factor authentication required", zap.String("username", user.Username))
+		return &LoginResult{TwoFactorRequired: true, TwoFactorToken: tokenString}, nil
+	}
+
+	tokens, err := s.sessionService.Create(ctx, user, false, userAgent)
 	if err != nil {
-		logger.Error("Login failed: failed to generate token", zap.String("username", user.Username), zap.Error(err))
-		return "", fmt.Errorf("auth service: failed to generate token: %w", err)
+		return nil, err
 	}
 
 	logger.Info("User logged in successfully", zap.String("username", user.Username))
-	return tokenString, nil
+	return &LoginResult{Tokens: tokens}, nil
 }
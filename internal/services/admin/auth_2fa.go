@@ -0,0 +1,289 @@
+package admin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/totp"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"go.uber.org/zap"
+)
+
+const (
+	// twoFactorTokenTTL 密码验证通过后输入两步验证码的时限
+	twoFactorTokenTTL = 5 * time.Minute
+	// defaultTOTPIssuer 未配置 JWT 签发者时身份验证器中显示的名称
+	defaultTOTPIssuer = "go-clouddisk"
+	// recoveryCodeLength 恢复码长度(不含分隔符)
+	recoveryCodeLength = 10
+	// maxTwoFactorChallengeAttempts 同一个中间 Token 允许输错的次数,用完后需要重新输入密码登录
+	maxTwoFactorChallengeAttempts = 5
+	// maxTwoFactorAccountAttempts 账号在 twoFactorFailWindow 内允许输错的次数,超过后暂时锁定两步验证登录
+	maxTwoFactorAccountAttempts = 10
+	// twoFactorFailWindow 账号失败计数的窗口期,每次失败后重新计时
+	twoFactorFailWindow = 15 * time.Minute
+)
+
+// TwoFactorEnrollment 两步验证设置信息,ProvisioningURI 可以渲染为二维码供身份验证器扫描
+type TwoFactorEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+func (s *authService) CompleteTwoFactorLogin(ctx context.Context, twoFactorToken string, code string, userAgent string) (*TokenPair, error) {
+	claims, err := utils.ParseToken(twoFactorToken, s.jwtCfg.SecretKey)
+	if err != nil || claims.Purpose != utils.TokenPurposeTwoFactor {
+		return nil, fmt.Errorf("auth service: %w", xerr.ErrTokenInvalid)
+	}
+
+	user, err := s.getUser(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrUserNotFound) {
+			return nil, fmt.Errorf("auth service: %w", xerr.ErrTokenInvalid)
+		}
+		return nil, err
+	}
+	// 中间 Token 签发后用户关闭了两步验证,要求重新登录
+	if user.TwoFactorEnabledAt == nil {
+		return nil, fmt.Errorf("auth service: %w", xerr.ErrTokenInvalid)
+	}
+
+	challengeKey := cache.GenerateTwoFactorChallengeKey(hashEmailToken(twoFactorToken))
+	failKey := cache.GenerateTwoFactorFailKey(user.ID)
+	if err := s.checkTwoFactorAttempts(ctx, challengeKey, failKey); err != nil {
+		return nil, err
+	}
+
+	if err := s.verifyTwoFactorCode(ctx, user, code, true); err != nil {
+		logger.Warn("Two-factor login failed", zap.Uint64("userID", user.ID), zap.Error(err))
+		if errors.Is(err, xerr.ErrTwoFactorCodeInvalid) {
+			s.recordTwoFactorFailure(ctx, challengeKey, failKey)
+		}
+		return nil, err
+	}
+	// 中间 Token 只能成功使用一次
+	if err := s.cache.Set(ctx, challengeKey, maxTwoFactorChallengeAttempts, twoFactorTokenTTL); err != nil {
+		logger.Error("CompleteTwoFactorLogin: Failed to invalidate challenge", zap.Uint64("userID", user.ID), zap.Error(err))
+		return nil, fmt.Errorf("auth service: %w", xerr.ErrInternalServer)
+	}
+	if err := s.cache.Del(ctx, failKey); err != nil {
+		logger.Warn("CompleteTwoFactorLogin: Failed to reset failure count", zap.Uint64("userID", user.ID), zap.Error(err))
+	}
+
+	tokens, err := s.sessionService.Create(ctx, user, true, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("User logged in with two-factor authentication", zap.String("username", user.Username))
+	return tokens, nil
+}
+
+func (s *authService) EnrollTwoFactor(ctx context.Context, userID uint64) (*TwoFactorEnrollment, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabledAt != nil {
+		return nil, fmt.Errorf("auth service: %w", xerr.ErrTwoFactorAlreadyEnabled)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		logger.Error("EnrollTwoFactor: Failed to generate secret", zap.Error(err))
+		return nil, fmt.Errorf("auth service: %w", xerr.ErrInternalServer)
+	}
+	// 重复设置时覆盖之前未确认的密钥
+	user.TOTPSecret = secret
+	if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+		logger.Error("EnrollTwoFactor: Failed to save secret", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("auth service: %w", xerr.ErrDatabaseError)
+	}
+
+	issuer := s.jwtCfg.Issuer
+	if issuer == "" {
+		issuer = defaultTOTPIssuer
+	}
+	return &TwoFactorEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(issuer, user.Username, secret),
+	}, nil
+}
+
+func (s *authService) EnableTwoFactor(ctx context.Context, userID uint64, code string) ([]string, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabledAt != nil {
+		return nil, fmt.Errorf("auth service: %w", xerr.ErrTwoFactorAlreadyEnabled)
+	}
+	if user.TOTPSecret == "" {
+		return nil, fmt.Errorf("auth service: %w", xerr.ErrTwoFactorNotEnabled)
+	}
+	// 确认设置时只接受身份验证器生成的验证码
+	if err := s.verifyTwoFactorCode(ctx, user, code, false); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, models.RecoveryCodeCount)
+	hashes := make([]string, 0, models.RecoveryCodeCount)
+	for range models.RecoveryCodeCount {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			logger.Error("EnableTwoFactor: Failed to generate recovery code", zap.Error(err))
+			return nil, fmt.Errorf("auth service: %w", xerr.ErrInternalServer)
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	if err := s.recoveryCodeRepo.Replace(userID, hashes); err != nil {
+		logger.Error("EnableTwoFactor: Failed to save recovery codes", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("auth service: %w", xerr.ErrDatabaseError)
+	}
+
+	now := time.Now()
+	user.TwoFactorEnabledAt = &now
+	if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+		logger.Error("EnableTwoFactor: Failed to update user", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("auth service: %w", xerr.ErrDatabaseError)
+	}
+	logger.Info("Two-factor authentication enabled", zap.Uint64("userID", userID))
+	return codes, nil
+}
+
+func (s *authService) DisableTwoFactor(ctx context.Context, userID uint64, code string) error {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.TwoFactorEnabledAt == nil {
+		return fmt.Errorf("auth service: %w", xerr.ErrTwoFactorNotEnabled)
+	}
+	if err := s.verifyTwoFactorCode(ctx, user, code, true); err != nil {
+		return err
+	}
+
+	user.TOTPSecret = ""
+	user.TwoFactorEnabledAt = nil
+	if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+		logger.Error("DisableTwoFactor: Failed to update user", zap.Uint64("userID", userID), zap.Error(err))
+		return fmt.Errorf("auth service: %w", xerr.ErrDatabaseError)
+	}
+	// 两步验证已经关闭,恢复码删除失败不影响结果,重新开启时会被替换
+	if err := s.recoveryCodeRepo.DeleteByUserID(userID); err != nil {
+		logger.Warn("DisableTwoFactor: Failed to delete recovery codes", zap.Uint64("userID", userID), zap.Error(err))
+	}
+	logger.Info("Two-factor authentication disabled", zap.Uint64("userID", userID))
+	return nil
+}
+
+// verifyTwoFactorCode 校验身份验证器生成的验证码,allowRecovery 为 true 时也接受恢复码
+func (s *authService) verifyTwoFactorCode(ctx context.Context, user *models.User, code string, allowRecovery bool) error {
+	code = strings.TrimSpace(code)
+	if counter, ok := totp.Validate(user.TOTPSecret, code, time.Now()); ok {
+		// 同一验证码只能使用一次,记录保留到验证码超出允许的时钟偏差为止
+		fresh, err := s.cache.SetNX(ctx, cache.GenerateTOTPUsedKey(user.ID, counter), 1, 3*totp.Period)
+		if err != nil {
+			logger.Error("verifyTwoFactorCode: Failed to record used code", zap.Uint64("userID", user.ID), zap.Error(err))
+			return fmt.Errorf("auth service: %w", xerr.ErrInternalServer)
+		}
+		if !fresh {
+			return fmt.Errorf("auth service: code already used: %w", xerr.ErrTwoFactorCodeInvalid)
+		}
+		return nil
+	}
+
+	if !allowRecovery {
+		return fmt.Errorf("auth service: %w", xerr.ErrTwoFactorCodeInvalid)
+	}
+	ok, err := s.recoveryCodeRepo.Consume(user.ID, hashRecoveryCode(code))
+	if err != nil {
+		logger.Error("verifyTwoFactorCode: Failed to consume recovery code", zap.Uint64("userID", user.ID), zap.Error(err))
+		return fmt.Errorf("auth service: %w", xerr.ErrDatabaseError)
+	}
+	if !ok {
+		return fmt.Errorf("auth service: %w", xerr.ErrTwoFactorCodeInvalid)
+	}
+	logger.Info("Recovery code used", zap.Uint64("userID", user.ID))
+	return nil
+}
+
+// checkTwoFactorAttempts 中间 Token 的失败次数用完时返回 ErrTokenInvalid,账号被锁定时返回 ErrTwoFactorLocked
+func (s *authService) checkTwoFactorAttempts(ctx context.Context, challengeKey, failKey string) error {
+	challengeFailures, err := s.countFailures(ctx, challengeKey)
+	if err != nil {
+		return err
+	}
+	if challengeFailures >= maxTwoFactorChallengeAttempts {
+		return fmt.Errorf("auth service: challenge exhausted: %w", xerr.ErrTokenInvalid)
+	}
+	accountFailures, err := s.countFailures(ctx, failKey)
+	if err != nil {
+		return err
+	}
+	if accountFailures >= maxTwoFactorAccountAttempts {
+		return fmt.Errorf("auth service: %w", xerr.ErrTwoFactorLocked)
+	}
+	return nil
+}
+
+func (s *authService) countFailures(ctx context.Context, key string) (int, error) {
+	var count int
+	if err := s.cache.Get(ctx, key, &count); err != nil {
+		if errors.Is(err, cache.ErrCacheMiss) {
+			return 0, nil
+		}
+		logger.Error("countFailures: Failed to get failure count", zap.String("key", key), zap.Error(err))
+		return 0, fmt.Errorf("auth service: %w", xerr.ErrInternalServer)
+	}
+	return count, nil
+}
+
+// recordTwoFactorFailure 同时增加中间 Token 和账号的失败次数
+func (s *authService) recordTwoFactorFailure(ctx context.Context, challengeKey, failKey string) {
+	pipe := s.cache.TxPipeline()
+	pipe.Incr(ctx, challengeKey)
+	pipe.Expire(ctx, challengeKey, twoFactorTokenTTL)
+	pipe.Incr(ctx, failKey)
+	pipe.Expire(ctx, failKey, twoFactorFailWindow)
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.Error("recordTwoFactorFailure: Failed to count failure", zap.Error(err))
+	}
+}
+
+func (s *authService) getUser(ctx context.Context, userID uint64) (*models.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrUserNotFound) {
+			return nil, fmt.Errorf("auth service: %w", xerr.ErrUserNotFound)
+		}
+		logger.Error("Failed to get user", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("auth service: %w", xerr.ErrDatabaseError)
+	}
+	return user, nil
+}
+
+// generateRecoveryCode 生成 XXXXX-XXXXX 格式的恢复码,使用 Base32 字母表避免易混淆的字符
+func generateRecoveryCode() (string, error) {
+	buf := make([]byte, recoveryCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	raw := base32.StdEncoding.EncodeToString(buf)[:recoveryCodeLength]
+	return raw[:recoveryCodeLength/2] + "-" + raw[recoveryCodeLength/2:], nil
+}
+
+// hashRecoveryCode 忽略大小写和分隔符后计算哈希
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return hashEmailToken(normalized)
+}
@@ -0,0 +1,203 @@
+package admin
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultVerifyTTL = 24 * time.Hour
+	defaultResetTTL  = 30 * time.Minute
+	// mailSendTimeout 异步发送邮件的超时时间
+	mailSendTimeout = 30 * time.Second
+)
+
+func (s *authService) SendVerificationEmail(ctx context.Context, userID uint64) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrUserNotFound) {
+			return fmt.Errorf("auth service: %w", xerr.ErrUserNotFound)
+		}
+		logger.Error("SendVerificationEmail: Failed to get user", zap.Uint64("userID", userID), zap.Error(err))
+		return fmt.Errorf("auth service: %w", xerr.ErrDatabaseError)
+	}
+	if user.EmailVerifiedAt != nil {
+		return fmt.Errorf("auth service: %w", xerr.ErrEmailAlreadyVerified)
+	}
+	return s.sendVerificationEmail(ctx, user)
+}
+
+func (s *authService) VerifyEmail(ctx context.Context, token string) error {
+	user, err := s.consumeToken(ctx, cache.GenerateEmailVerifyTokenKey(hashEmailToken(token)))
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+		logger.Error("VerifyEmail: Failed to update user", zap.Uint64("userID", user.ID), zap.Error(err))
+		return fmt.Errorf("auth service: %w", xerr.ErrDatabaseError)
+	}
+	logger.Info("Email verified", zap.Uint64("userID", user.ID))
+	return nil
+}
+
+func (s *authService) ForgotPassword(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		// 邮箱未注册时同样返回成功,避免被用来探测账户是否存在
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Info("ForgotPassword: Email not registered", zap.String("email", email))
+			return nil
+		}
+		logger.Error("ForgotPassword: Failed to get user", zap.String("email", email), zap.Error(err))
+		return fmt.Errorf("auth service: %w", xerr.ErrDatabaseError)
+	}
+
+	ttl := minutesOrDefault(s.mailCfg.ResetTTL, defaultResetTTL)
+	token, err := s.issueToken(ctx, cache.GeneratePasswordResetTokenKey, user.ID, ttl)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("你好 %s：\n\n我们收到了重置你的 go-clouddisk 账户密码的请求。请在 %d 分钟内打开以下链接设置新密码：\n%s\n\n如果这不是你的操作，请忽略这封邮件，你的密码不会改变。\n",
+		user.Username, int(ttl.Minutes()), s.mailLink("/reset-password", token))
+	s.sendMailAsync(user.Email, "重置你的密码", body)
+	return nil
+}
+
+func (s *authService) ResetPassword(ctx context.Context, token string, newPassword string) error {
+	user, err := s.consumeToken(ctx, cache.GeneratePasswordResetTokenKey(hashEmailToken(token)))
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := utils.HashPassword(newPassword)
+	if err != nil {
+		logger.Error("ResetPassword: Failed to hash password", zap.Error(err))
+		return fmt.Errorf("auth service: failed to hash password: %w", xerr.ErrInternalServer)
+	}
+	user.PasswordHash = hashedPassword
+	// 能收到重置邮件说明用户拥有该邮箱
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+		logger.Error("ResetPassword: Failed to update user", zap.Uint64("userID", user.ID), zap.Error(err))
+		return fmt.Errorf("auth service: %w", xerr.ErrDatabaseError)
+	}
+	// 密码可能已经泄露,让所有已登录的设备重新登录
+	if err := s.sessionService.RevokeAll(ctx, user.ID); err != nil {
+		logger.Error("ResetPassword: Failed to revoke sessions", zap.Uint64("userID", user.ID), zap.Error(err))
+	}
+	logger.Info("Password reset", zap.Uint64("userID", user.ID))
+	return nil
+}
+
+// sendVerificationEmail 生成验证令牌并异步发送验证邮件
+func (s *authService) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	ttl := hoursOrDefault(s.mailCfg.VerifyTTL, defaultVerifyTTL)
+	token, err := s.issueToken(ctx, cache.GenerateEmailVerifyTokenKey, user.ID, ttl)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("你好 %s：\n\n感谢注册 go-clouddisk。请在 %d 小时内打开以下链接完成邮箱验证：\n%s\n\n如果这不是你的操作，请忽略这封邮件。\n",
+		user.Username, int(ttl.Hours()), s.mailLink("/verify-email", token))
+	s.sendMailAsync(user.Email, "验证你的邮箱", body)
+	return nil
+}
+
+// issueToken 生成随机令牌,Redis 中只保存令牌的哈希
+func (s *authService) issueToken(ctx context.Context, keyFunc func(string) string, userID uint64, ttl time.Duration) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		logger.Error("issueToken: Failed to generate token", zap.Error(err))
+		return "", fmt.Errorf("auth service: failed to generate token: %w", xerr.ErrInternalServer)
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	if err := s.cache.Set(ctx, keyFunc(hashEmailToken(token)), userID, ttl); err != nil {
+		logger.Error("issueToken: Failed to save token", zap.Uint64("userID", userID), zap.Error(err))
+		return "", fmt.Errorf("auth service: failed to save token: %w", xerr.ErrInternalServer)
+	}
+	return token, nil
+}
+
+// consumeToken 校验令牌并立即删除,令牌只能使用一次。读取和删除是一个原子操作,并发请求只有一个能取到令牌
+func (s *authService) consumeToken(ctx context.Context, key string) (*models.User, error) {
+	var userID uint64
+	if err := s.cache.GetDel(ctx, key, &userID); err != nil {
+		if errors.Is(err, cache.ErrCacheMiss) {
+			return nil, fmt.Errorf("auth service: %w", xerr.ErrEmailTokenInvalid)
+		}
+		logger.Error("consumeToken: Failed to get token", zap.Error(err))
+		return nil, fmt.Errorf("auth service: failed to get token: %w", xerr.ErrInternalServer)
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrUserNotFound) {
+			return nil, fmt.Errorf("auth service: %w", xerr.ErrEmailTokenInvalid)
+		}
+		logger.Error("consumeToken: Failed to get user", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("auth service: %w", xerr.ErrDatabaseError)
+	}
+	return user, nil
+}
+
+func (s *authService) mailLink(path string, token string) string {
+	return strings.TrimSuffix(s.mailCfg.BaseURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// sendMailAsync 在后台发送邮件,发送失败只记录日志,用户可以重新请求
+func (s *authService) sendMailAsync(to, subject, body string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+		defer cancel()
+		if err := s.mailer.Send(ctx, to, subject, body); err != nil {
+			logger.Error("Failed to send mail", zap.String("to", to), zap.String("subject", subject), zap.Error(err))
+		}
+	}()
+}
+
+// hashEmailToken 令牌本身有足够的随机性,使用 SHA-256 即可
+func hashEmailToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func hoursOrDefault(hours int, fallback time.Duration) time.Duration {
+	if hours <= 0 {
+		return fallback
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func minutesOrDefault(minutes int, fallback time.Duration) time.Duration {
+	if minutes <= 0 {
+		return fallback
+	}
+	return time.Duration(minutes) * time.Minute
+}
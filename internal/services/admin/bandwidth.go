@@ -0,0 +1,147 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"go.uber.org/zap"
+)
+
+// BandwidthService 下载限速: 同一用户或同一分享链接的并发下载共享令牌桶,管理员可以覆盖配置的默认值
+type BandwidthService interface {
+	// Throttle 为下载流添加限速,userID 为流量所属的用户,share 不为 nil 时同时应用分享链接的限速
+	Throttle(ctx context.Context, w io.Writer, userID uint64, share *models.Share) io.Writer
+	// ThrottleByID 同 Throttle,分享链接按 shareID 查询,0 表示不是分享下载。用于只携带 ID 的签名下载链接
+	ThrottleByID(ctx context.Context, w io.Writer, userID uint64, shareID uint64) io.Writer
+	// Limited 返回下载是否受限速约束。受约束的下载需要由服务端输出,不能重定向到对象存储的预签名链接
+	Limited(ctx context.Context, userID uint64, share *models.Share) bool
+	// SetUserLimit 设置用户的限速,0 表示恢复默认值,-1 表示不限速
+	SetUserLimit(ctx context.Context, userID uint64, bytesPerSecond int64) (*models.User, error)
+	// SetShareLimit 设置分享链接的限速,取值含义同 SetUserLimit
+	SetShareLimit(ctx context.Context, shareID uint64, bytesPerSecond int64) (*models.Share, error)
+}
+
+type bandwidthService struct {
+	userRepo  repositories.UserRepository
+	shareRepo repositories.ShareRepository
+	limiters  *utils.LimiterRegistry
+	cfg       *config.DownloadConfig
+}
+
+var _ BandwidthService = (*bandwidthService)(nil)
+
+func NewBandwidthService(userRepo repositories.UserRepository, shareRepo repositories.ShareRepository, cfg *config.DownloadConfig) BandwidthService {
+	return &bandwidthService{
+		userRepo:  userRepo,
+		shareRepo: shareRepo,
+		limiters:  utils.NewLimiterRegistry(),
+		cfg:       cfg,
+	}
+}
+
+func (s *bandwidthService) Throttle(ctx context.Context, w io.Writer, userID uint64, share *models.Share) io.Writer {
+	w = utils.ThrottleWriter(ctx, w, s.limiters.Get("user:"+strconv.FormatUint(userID, 10), s.userLimit(ctx, userID)))
+
+	if share != nil {
+		shareLimit := effectiveLimit(share.BandwidthLimit, s.cfg.ShareBandwidthLimit)
+		w = utils.ThrottleWriter(ctx, w, s.limiters.Get("share:"+strconv.FormatUint(share.ID, 10), shareLimit))
+	}
+	return w
+}
+
+func (s *bandwidthService) ThrottleByID(ctx context.Context, w io.Writer, userID uint64, shareID uint64) io.Writer {
+	var share *models.Share
+	if shareID != 0 {
+		found, err := s.shareRepo.FindByID(ctx, shareID)
+		if err != nil {
+			// 查询失败时只按用户限速,不影响下载
+			logger.Warn("ThrottleByID: Failed to get share bandwidth limit", zap.Uint64("shareID", shareID), zap.Error(err))
+		}
+		share = found
+	}
+	return s.Throttle(ctx, w, userID, share)
+}
+
+func (s *bandwidthService) Limited(ctx context.Context, userID uint64, share *models.Share) bool {
+	if share != nil && effectiveLimit(share.BandwidthLimit, s.cfg.ShareBandwidthLimit) > 0 {
+		return true
+	}
+	return s.userLimit(ctx, userID) > 0
+}
+
+// userLimit 返回用户实际生效的限速,查询失败时按默认值限速,不影响下载
+func (s *bandwidthService) userLimit(ctx context.Context, userID uint64) int64 {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		logger.Warn("Throttle: Failed to get user bandwidth limit, using default", zap.Uint64("userID", userID), zap.Error(err))
+		return s.cfg.UserBandwidthLimit
+	}
+	return effectiveLimit(user.BandwidthLimit, s.cfg.UserBandwidthLimit)
+}
+
+func (s *bandwidthService) SetUserLimit(ctx context.Context, userID uint64, bytesPerSecond int64) (*models.User, error) {
+	if bytesPerSecond < -1 {
+		return nil, fmt.Errorf("bandwidth service: invalid limit %d: %w", bytesPerSecond, xerr.ErrInvalidParams)
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrUserNotFound) {
+			return nil, fmt.Errorf("bandwidth service: %w", xerr.ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("bandwidth service: failed to get user: %w", xerr.ErrDatabaseError)
+	}
+
+	user.BandwidthLimit = bytesPerSecond
+	if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+		return nil, fmt.Errorf("bandwidth service: failed to update user: %w", xerr.ErrDatabaseError)
+	}
+
+	logger.Info("User bandwidth limit updated", zap.Uint64("userID", userID), zap.Int64("bytesPerSecond", bytesPerSecond))
+	return user, nil
+}
+
+func (s *bandwidthService) SetShareLimit(ctx context.Context, shareID uint64, bytesPerSecond int64) (*models.Share, error) {
+	if bytesPerSecond < -1 {
+		return nil, fmt.Errorf("bandwidth service: invalid limit %d: %w", bytesPerSecond, xerr.ErrInvalidParams)
+	}
+
+	share, err := s.shareRepo.FindByID(ctx, shareID)
+	if err != nil {
+		logger.Error("SetShareLimit: Failed to get share", zap.Uint64("shareID", shareID), zap.Error(err))
+		return nil, fmt.Errorf("bandwidth service: failed to get share: %w", xerr.ErrDatabaseError)
+	}
+	if share == nil {
+		return nil, fmt.Errorf("bandwidth service: %w", xerr.ErrShareNotFound)
+	}
+
+	share.BandwidthLimit = bytesPerSecond
+	if err := s.shareRepo.Update(ctx, share); err != nil {
+		logger.Error("SetShareLimit: Failed to update share", zap.Uint64("shareID", shareID), zap.Error(err))
+		return nil, fmt.Errorf("bandwidth service: failed to update share: %w", xerr.ErrDatabaseError)
+	}
+
+	logger.Info("Share bandwidth limit updated", zap.Uint64("shareID", shareID), zap.Int64("bytesPerSecond", bytesPerSecond))
+	return share, nil
+}
+
+// effectiveLimit 计算实际生效的限速,返回 0 表示不限速
+func effectiveLimit(override int64, defaultLimit int64) int64 {
+	switch {
+	case override < 0:
+		return 0
+	case override > 0:
+		return override
+	default:
+		return defaultLimit
+	}
+}
@@ -0,0 +1,82 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache/consumer"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// maxDeadLetterListSize 单次最多返回的死信数
+const maxDeadLetterListSize = 200
+
+// DeadLetterService 查看和重放缓存消费者多次处理失败的消息
+type DeadLetterService interface {
+	// List 按失败时间倒序返回最近的死信
+	List(ctx context.Context, limit int) ([]consumer.DeadLetter, error)
+	// Replay 将死信的原始消息重新写入来源 Stream,并从死信流删除
+	Replay(ctx context.Context, id string) (*consumer.DeadLetter, error)
+}
+
+type deadLetterService struct {
+	redisClient *redis.Client
+}
+
+var _ DeadLetterService = (*deadLetterService)(nil)
+
+func NewDeadLetterService(redisClient *redis.Client) DeadLetterService {
+	return &deadLetterService{redisClient: redisClient}
+}
+
+func (s *deadLetterService) List(ctx context.Context, limit int) ([]consumer.DeadLetter, error) {
+	if limit <= 0 || limit > maxDeadLetterListSize {
+		limit = maxDeadLetterListSize
+	}
+	messages, err := s.redisClient.XRevRangeN(ctx, consumer.DeadLetterStream, "+", "-", int64(limit)).Result()
+	if err != nil {
+		logger.Error("ListDeadLetters: Failed to read dead-letter stream", zap.Error(err))
+		return nil, fmt.Errorf("dead letter service: %w", xerr.ErrInternalServer)
+	}
+
+	letters := make([]consumer.DeadLetter, 0, len(messages))
+	for _, message := range messages {
+		letters = append(letters, consumer.ParseDeadLetter(message))
+	}
+	return letters, nil
+}
+
+func (s *deadLetterService) Replay(ctx context.Context, id string) (*consumer.DeadLetter, error) {
+	messages, err := s.redisClient.XRangeN(ctx, consumer.DeadLetterStream, id, id, 1).Result()
+	if err != nil {
+		// ID 格式不合法时 Redis 返回错误,按不存在处理
+		logger.Warn("ReplayDeadLetter: Failed to read dead letter", zap.String("id", id), zap.Error(err))
+		return nil, fmt.Errorf("dead letter service: %w", xerr.ErrDeadLetterNotFound)
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("dead letter service: %w", xerr.ErrDeadLetterNotFound)
+	}
+	letter := consumer.ParseDeadLetter(messages[0])
+	if letter.Stream == "" || letter.Payload == "" {
+		return nil, fmt.Errorf("dead letter service: invalid dead letter: %w", xerr.ErrInvalidParams)
+	}
+
+	if _, err := s.redisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: letter.Stream,
+		MaxLen: 10000,
+		Values: map[string]any{"payload": letter.Payload},
+	}).Result(); err != nil {
+		logger.Error("ReplayDeadLetter: Failed to republish message", zap.String("id", id), zap.String("stream", letter.Stream), zap.Error(err))
+		return nil, fmt.Errorf("dead letter service: %w", xerr.ErrInternalServer)
+	}
+	// 消息已经重新投递,删除失败只会在列表中多留一条记录
+	if err := s.redisClient.XDel(ctx, consumer.DeadLetterStream, id).Err(); err != nil {
+		logger.Warn("ReplayDeadLetter: Failed to delete dead letter", zap.String("id", id), zap.Error(err))
+	}
+
+	logger.Info("Dead letter replayed", zap.String("id", id), zap.String("stream", letter.Stream), zap.String("messageID", letter.MessageID))
+	return &letter, nil
+}
@@ -0,0 +1,339 @@
+package admin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultRefreshTTL 未配置 refresh_expire_hours 时刷新 Token 的有效期
+	defaultRefreshTTL = 7 * 24 * time.Hour
+	// maxSessionUserAgentLength 会话中保存的 User-Agent 最大长度
+	maxSessionUserAgentLength = 512
+)
+
+// SessionService 登录会话管理: 签发和轮换刷新 Token,撤销会话后其访问 Token 立即失效
+type SessionService interface {
+	// Create 创建会话并签发访问 Token 和刷新 Token
+	Create(ctx context.Context, user *models.User, twoFactor bool, userAgent string) (*TokenPair, error)
+	// Refresh 使用刷新 Token 换取新的 Token,旧的刷新 Token 随即失效。
+	// 已轮换的刷新 Token 再次出现时视为泄露,整个会话被撤销
+	Refresh(ctx context.Context, refreshToken string) (*TokenPair, error)
+	// List 返回用户的有效会话,currentSessionID 对应的会话标记为当前会话
+	List(ctx context.Context, userID uint64, currentSessionID string) ([]models.Session, error)
+	Revoke(ctx context.Context, userID uint64, sessionID string) error
+	// RevokeAll 撤销用户的全部会话,用于重置密码等场景
+	RevokeAll(ctx context.Context, userID uint64) error
+	// IsRevoked 由认证中间件调用,检查会话是否在黑名单中
+	IsRevoked(ctx context.Context, sessionID string) (bool, error)
+}
+
+// TokenPair 登录和刷新接口返回的 Token
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // 访问 Token 的有效期(秒)
+	SessionID    string `json:"session_id"`
+	UserID       uint64 `json:"-"` // 登录成功后预热缓存使用
+}
+
+// sessionRecord Redis 中保存的会话,RefreshTokenHash 不对外返回
+type sessionRecord struct {
+	models.Session
+	RefreshTokenHash string `json:"refresh_token_hash"`
+}
+
+type sessionService struct {
+	userRepo repositories.UserRepository
+	cache    cache.Cache
+	jwtCfg   *config.JWTConfig
+}
+
+var _ SessionService = (*sessionService)(nil)
+
+// NewSessionService 创建登录会话服务实例
+func NewSessionService(userRepo repositories.UserRepository, c cache.Cache, jwtCfg *config.JWTConfig) SessionService {
+	return &sessionService{
+		userRepo: userRepo,
+		cache:    c,
+		jwtCfg:   jwtCfg,
+	}
+}
+
+func (s *sessionService) Create(ctx context.Context, user *models.User, twoFactor bool, userAgent string) (*TokenPair, error) {
+	sessionID, err := randomToken(16)
+	if err != nil {
+		logger.Error("CreateSession: Failed to generate session ID", zap.Error(err))
+		return nil, fmt.Errorf("session service: %w", xerr.ErrInternalServer)
+	}
+
+	now := time.Now()
+	record := &sessionRecord{Session: models.Session{
+		ID:         sessionID,
+		UserID:     user.ID,
+		UserAgent:  truncateString(userAgent, maxSessionUserAgentLength),
+		IP:         utils.ClientIPFromContext(ctx),
+		TwoFactor:  twoFactor,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}}
+	return s.issue(ctx, user, record)
+}
+
+func (s *sessionService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	sessionID, _, ok := strings.Cut(refreshToken, ".")
+	if !ok || sessionID == "" {
+		return nil, fmt.Errorf("session service: %w", xerr.ErrTokenInvalid)
+	}
+	record, err := s.getRecord(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrSessionNotFound) {
+			return nil, fmt.Errorf("session service: %w", xerr.ErrTokenInvalid)
+		}
+		return nil, err
+	}
+
+	// 旧 Token 被重复使用说明可能已经泄露,撤销会话让双方都重新登录
+	tokenHash := hashEmailToken(refreshToken)
+	if tokenHash != record.RefreshTokenHash {
+		s.revokeReused(ctx, record)
+		return nil, fmt.Errorf("session service: refresh token reused: %w", xerr.ErrTokenInvalid)
+	}
+	// 并发使用同一个刷新 Token 时只有一个请求能完成轮换
+	first, err := s.cache.SetNX(ctx, cache.GenerateUsedRefreshTokenKey(tokenHash), record.ID, s.refreshTTL())
+	if err != nil {
+		logger.Error("RefreshSession: Failed to mark refresh token as used", zap.String("sessionID", record.ID), zap.Error(err))
+		return nil, fmt.Errorf("session service: %w", xerr.ErrInternalServer)
+	}
+	if !first {
+		s.revokeReused(ctx, record)
+		return nil, fmt.Errorf("session service: refresh token reused: %w", xerr.ErrTokenInvalid)
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, record.UserID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrUserNotFound) {
+			return nil, fmt.Errorf("session service: %w", xerr.ErrTokenInvalid)
+		}
+		logger.Error("RefreshSession: Failed to get user", zap.Uint64("userID", record.UserID), zap.Error(err))
+		return nil, fmt.Errorf("session service: %w", xerr.ErrDatabaseError)
+	}
+
+	record.LastUsedAt = time.Now()
+	if ip := utils.ClientIPFromContext(ctx); ip != "" {
+		record.IP = ip
+	}
+	return s.issue(ctx, user, record)
+}
+
+func (s *sessionService) List(ctx context.Context, userID uint64, currentSessionID string) ([]models.Session, error) {
+	indexKey := cache.GenerateUserSessionsKey(userID)
+	sessionIDs, err := s.cache.ZRange(ctx, indexKey, 0, -1).Result()
+	if err != nil {
+		logger.Error("ListSessions: Failed to get session index", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("session service: %w", xerr.ErrInternalServer)
+	}
+
+	sessions := make([]models.Session, 0, len(sessionIDs))
+	var expired []any
+	for _, sessionID := range sessionIDs {
+		record, err := s.getRecord(ctx, sessionID)
+		if err != nil {
+			if errors.Is(err, xerr.ErrSessionNotFound) {
+				expired = append(expired, sessionID)
+				continue
+			}
+			return nil, err
+		}
+		session := record.Session
+		session.Current = session.ID == currentSessionID
+		sessions = append(sessions, session)
+	}
+	// 会话过期后只剩索引项,顺便清理
+	if len(expired) > 0 {
+		if err := s.cache.ZRem(ctx, indexKey, expired...).Err(); err != nil {
+			logger.Warn("ListSessions: Failed to clean up expired sessions", zap.Uint64("userID", userID), zap.Error(err))
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
+
+func (s *sessionService) Revoke(ctx context.Context, userID uint64, sessionID string) error {
+	record, err := s.getRecord(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if record.UserID != userID {
+		return fmt.Errorf("session service: %w", xerr.ErrSessionNotFound)
+	}
+	if err := s.revoke(ctx, userID, sessionID); err != nil {
+		return err
+	}
+	logger.Info("Session revoked", zap.Uint64("userID", userID), zap.String("sessionID", sessionID))
+	return nil
+}
+
+func (s *sessionService) RevokeAll(ctx context.Context, userID uint64) error {
+	sessionIDs, err := s.cache.ZRange(ctx, cache.GenerateUserSessionsKey(userID), 0, -1).Result()
+	if err != nil {
+		logger.Error("RevokeAllSessions: Failed to get session index", zap.Uint64("userID", userID), zap.Error(err))
+		return fmt.Errorf("session service: %w", xerr.ErrInternalServer)
+	}
+	for _, sessionID := range sessionIDs {
+		if err := s.revoke(ctx, userID, sessionID); err != nil {
+			return err
+		}
+	}
+	logger.Info("All sessions revoked", zap.Uint64("userID", userID), zap.Int("count", len(sessionIDs)))
+	return nil
+}
+
+func (s *sessionService) IsRevoked(ctx context.Context, sessionID string) (bool, error) {
+	revoked, err := s.cache.Exists(ctx, cache.GenerateRevokedSessionKey(sessionID))
+	if err != nil {
+		return false, fmt.Errorf("session service: failed to check revoked session: %w", err)
+	}
+	return revoked, nil
+}
+
+// issue 生成新的刷新 Token 写入会话,并签发对应的访问 Token
+func (s *sessionService) issue(ctx context.Context, user *models.User, record *sessionRecord) (*TokenPair, error) {
+	secret, err := randomToken(32)
+	if err != nil {
+		logger.Error("issueSession: Failed to generate refresh token", zap.Error(err))
+		return nil, fmt.Errorf("session service: %w", xerr.ErrInternalServer)
+	}
+	refreshToken := record.ID + "." + secret
+	refreshTTL := s.refreshTTL()
+	record.RefreshTokenHash = hashEmailToken(refreshToken)
+	record.ExpiresAt = time.Now().Add(refreshTTL)
+
+	accessToken, err := utils.GenerateToken(
+		user.ID,
+		user.Username,
+		user.Email,
+		s.jwtCfg.SecretKey,
+		s.jwtCfg.Issuer,
+		s.jwtCfg.ExpiresIn,
+		record.TwoFactor,
+		record.ID,
+	)
+	if err != nil {
+		logger.Error("issueSession: Failed to generate access token", zap.String("username", user.Username), zap.Error(err))
+		return nil, fmt.Errorf("session service: failed to generate token: %w", err)
+	}
+
+	if err := s.cache.Set(ctx, cache.GenerateSessionKey(record.ID), record, refreshTTL); err != nil {
+		logger.Error("issueSession: Failed to save session", zap.String("sessionID", record.ID), zap.Error(err))
+		return nil, fmt.Errorf("session service: %w", xerr.ErrInternalServer)
+	}
+	indexKey := cache.GenerateUserSessionsKey(user.ID)
+	if err := s.cache.ZAdd(ctx, indexKey, &redis.Z{Score: float64(record.ExpiresAt.Unix()), Member: record.ID}).Err(); err != nil {
+		logger.Error("issueSession: Failed to index session", zap.String("sessionID", record.ID), zap.Error(err))
+		return nil, fmt.Errorf("session service: %w", xerr.ErrInternalServer)
+	}
+	if err := s.cache.Expire(ctx, indexKey, refreshTTL); err != nil {
+		logger.Warn("issueSession: Failed to extend session index", zap.Uint64("userID", user.ID), zap.Error(err))
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.accessTTL().Seconds()),
+		SessionID:    record.ID,
+		UserID:       user.ID,
+	}, nil
+}
+
+// revoke 删除会话并加入黑名单,该会话已签发的访问 Token 随即失效
+func (s *sessionService) revoke(ctx context.Context, userID uint64, sessionID string) error {
+	if err := s.cache.Set(ctx, cache.GenerateRevokedSessionKey(sessionID), userID, s.accessTTL()); err != nil {
+		logger.Error("revokeSession: Failed to deny-list session", zap.String("sessionID", sessionID), zap.Error(err))
+		return fmt.Errorf("session service: %w", xerr.ErrInternalServer)
+	}
+	if err := s.cache.Del(ctx, cache.GenerateSessionKey(sessionID)); err != nil {
+		logger.Error("revokeSession: Failed to delete session", zap.String("sessionID", sessionID), zap.Error(err))
+		return fmt.Errorf("session service: %w", xerr.ErrInternalServer)
+	}
+	if err := s.cache.ZRem(ctx, cache.GenerateUserSessionsKey(userID), sessionID).Err(); err != nil {
+		logger.Warn("revokeSession: Failed to remove session from index", zap.String("sessionID", sessionID), zap.Error(err))
+	}
+	// 通知所有实例断开该会话的 WebSocket 连接,发布失败时连接在访问 Token 过期前不会被断开
+	payload, err := json.Marshal(cache.SessionRevokedMessage{UserID: userID, SessionID: sessionID})
+	if err == nil {
+		err = s.cache.Publish(ctx, cache.SessionRevokedChannel, string(payload))
+	}
+	if err != nil {
+		logger.Warn("revokeSession: Failed to publish session revocation", zap.String("sessionID", sessionID), zap.Error(err))
+	}
+	return nil
+}
+
+// revokeReused 刷新 Token 被重复使用时撤销会话
+func (s *sessionService) revokeReused(ctx context.Context, record *sessionRecord) {
+	logger.Warn("Refresh token reuse detected, revoking session",
+		zap.Uint64("userID", record.UserID), zap.String("sessionID", record.ID), zap.String("ip", utils.ClientIPFromContext(ctx)))
+	if err := s.revoke(ctx, record.UserID, record.ID); err != nil {
+		logger.Error("Failed to revoke session after refresh token reuse", zap.String("sessionID", record.ID), zap.Error(err))
+	}
+}
+
+func (s *sessionService) getRecord(ctx context.Context, sessionID string) (*sessionRecord, error) {
+	var record sessionRecord
+	if err := s.cache.Get(ctx, cache.GenerateSessionKey(sessionID), &record); err != nil {
+		if errors.Is(err, cache.ErrCacheMiss) {
+			return nil, fmt.Errorf("session service: %w", xerr.ErrSessionNotFound)
+		}
+		logger.Error("Failed to get session", zap.String("sessionID", sessionID), zap.Error(err))
+		return nil, fmt.Errorf("session service: %w", xerr.ErrInternalServer)
+	}
+	return &record, nil
+}
+
+func (s *sessionService) accessTTL() time.Duration {
+	return s.jwtCfg.ExpiresIn * time.Minute
+}
+
+func (s *sessionService) refreshTTL() time.Duration {
+	if s.jwtCfg.RefreshExpireHours <= 0 {
+		return defaultRefreshTTL
+	}
+	return s.jwtCfg.RefreshExpireHours * time.Hour
+}
+
+// randomToken 生成 n 字节的随机串,使用 URL 安全的 Base64 编码
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func truncateString(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max]
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
 	"github.com/3Eeeecho/go-clouddisk/internal/models"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
@@ -14,21 +15,28 @@ import (
 )
 
 type UserService interface {
-	GetUserProfile(userID uint64) (*models.User, error)
+	GetUserProfile(ctx context.Context, userID uint64) (*models.User, error)
+	// IsEmailVerified 检查用户是否已验证邮箱
+	IsEmailVerified(ctx context.Context, userID uint64) (bool, error)
+	// IsActive 检查账号是否处于正常状态,已申请注销或已注销的账号返回 false
+	IsActive(ctx context.Context, userID uint64) (bool, error)
+	// UpdateSettings 修改用户设置,只修改不为 nil 的字段。回收站保留天数不在配置范围内时返回 ErrInvalidParams
+	UpdateSettings(ctx context.Context, userID uint64, update models.UserSettingsUpdate) (*models.User, error)
 }
 
 type userService struct {
 	userRepo repositories.UserRepository
+	cfg      *config.Config
 }
 
 var _ UserService = (*userService)(nil)
 
-func NewUserService(userRepo repositories.UserRepository) UserService {
-	return &userService{userRepo: userRepo}
+func NewUserService(userRepo repositories.UserRepository, cfg *config.Config) UserService {
+	return &userService{userRepo: userRepo, cfg: cfg}
 }
 
-func (s *userService) GetUserProfile(userID uint64) (*models.User, error) {
-	user, err := s.userRepo.GetUserByID(context.Background(), userID)
+func (s *userService) GetUserProfile(ctx context.Context, userID uint64) (*models.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			logger.Warn("GetUserProfile: User not found", zap.Uint64("userID", userID))
@@ -47,3 +55,59 @@ func (s *userService) GetUserProfile(userID uint64) (*models.User, error) {
 	logger.Info("GetUserProfile: User profile retrieved successfully", zap.Uint64("userID", userID))
 	return user, nil
 }
+
+func (s *userService) IsEmailVerified(ctx context.Context, userID uint64) (bool, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrUserNotFound) {
+			return false, fmt.Errorf("user service: %w", xerr.ErrUserNotFound)
+		}
+		logger.Error("IsEmailVerified: Error retrieving user from DB", zap.Uint64("userID", userID), zap.Error(err))
+		return false, fmt.Errorf("user service: failed to retrieve user: %w", xerr.ErrDatabaseError)
+	}
+	return user.EmailVerifiedAt != nil, nil
+}
+
+func (s *userService) IsActive(ctx context.Context, userID uint64) (bool, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrUserNotFound) {
+			return false, nil
+		}
+		logger.Error("IsActive: Error retrieving user from DB", zap.Uint64("userID", userID), zap.Error(err))
+		return false, fmt.Errorf("user service: failed to retrieve user: %w", xerr.ErrDatabaseError)
+	}
+	return user.Status == models.UserStatusActive, nil
+}
+
+func (s *userService) UpdateSettings(ctx context.Context, userID uint64, update models.UserSettingsUpdate) (*models.User, error) {
+	// 0 表示恢复默认值
+	if days := update.TrashRetentionDays; days != nil && *days != 0 {
+		minDays, maxDays := s.cfg.Trash.RetentionRange()
+		if *days < minDays || *days > maxDays {
+			return nil, fmt.Errorf("user service: trash retention must be between %d and %d days: %w", minDays, maxDays, xerr.ErrInvalidParams)
+		}
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrUserNotFound) {
+			return nil, fmt.Errorf("user service: %w", xerr.ErrUserNotFound)
+		}
+		logger.Error("UpdateSettings: Error retrieving user from DB", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("user service: failed to retrieve user: %w", xerr.ErrDatabaseError)
+	}
+
+	if update.DedupOptOut != nil {
+		user.DedupOptOut = *update.DedupOptOut
+	}
+	if update.TrashRetentionDays != nil {
+		user.TrashRetentionDays = *update.TrashRetentionDays
+	}
+	if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+		return nil, fmt.Errorf("user service: failed to update user: %w", xerr.ErrDatabaseError)
+	}
+
+	logger.Info("UpdateSettings: User settings updated", zap.Uint64("userID", userID), zap.Bool("dedupOptOut", user.DedupOptOut), zap.Int("trashRetentionDays", user.TrashRetentionDays))
+	return user, nil
+}
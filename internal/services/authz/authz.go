@@ -0,0 +1,249 @@
+// Package authz 集中判断用户能否对资源执行某个操作,各服务通过 Authorizer 校验权限而不是各自比较所有者。
+// 当前策略: 文件所有者可以执行全部操作,组织成员按角色操作团队文件,协作者按文件夹授权执行读写类操作,
+// 其他用户的记录(分享、评论等)只有创建者可以操作,管理接口需要管理员角色
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"go.uber.org/zap"
+)
+
+// Action 对资源执行的操作
+type Action string
+
+const (
+	ActionRead     Action = "read"     // 查看、列出和预览
+	ActionDownload Action = "download" // 下载文件内容
+	ActionWrite    Action = "write"    // 上传、新建、重命名、移动和编辑
+	ActionDelete   Action = "delete"   // 删除到回收站
+	ActionPurge    Action = "purge"    // 彻底删除,不经过回收站
+	ActionShare    Action = "share"    // 创建分享链接和站内分享
+	ActionManage   Action = "manage"   // 修改协作授权、生命周期规则等影响其他人的设置
+	ActionAdmin    Action = "admin"    // 管理接口
+)
+
+// collaboratorPermissions 协作者执行各操作需要的最低授权级别,不在表中的操作只允许所有者执行
+var collaboratorPermissions = map[Action]uint8{
+	ActionRead:     models.PermissionRead,
+	ActionDownload: models.PermissionRead,
+	ActionWrite:    models.PermissionWrite,
+	ActionDelete:   models.PermissionWrite,
+}
+
+// orgRoles 组织成员执行各操作需要的最低角色,作用于团队文件和组织本身。
+// 对组织执行 ActionAdmin 表示分配管理员、所有者等只有所有者能做的操作
+var orgRoles = map[Action]string{
+	ActionRead:     models.OrgRoleMember,
+	ActionDownload: models.OrgRoleMember,
+	ActionWrite:    models.OrgRoleMember,
+	ActionDelete:   models.OrgRoleMember,
+	ActionShare:    models.OrgRoleAdmin,
+	ActionPurge:    models.OrgRoleAdmin,
+	ActionManage:   models.OrgRoleAdmin,
+	ActionAdmin:    models.OrgRoleOwner,
+}
+
+// ResourceKind 资源类型
+type ResourceKind string
+
+const (
+	KindFile   ResourceKind = "file"   // 文件或文件夹,协作授权对其生效
+	KindRecord ResourceKind = "record" // 属于某个用户的记录,如分享、评论、规则
+	KindSystem ResourceKind = "system" // 整个系统,用于管理操作
+	KindOrg    ResourceKind = "org"    // 组织,用于成员管理
+)
+
+// Resource 被访问的资源
+type Resource struct {
+	Kind    ResourceKind
+	OwnerID uint64
+	File    *models.File         // Kind 为 KindFile 时的文件,用于判断协作授权的子树范围
+	Org     *models.Organization // Kind 为 KindOrg 时的组织
+}
+
+// File 文件或文件夹资源
+func File(file *models.File) Resource {
+	return Resource{Kind: KindFile, OwnerID: file.UserID, File: file}
+}
+
+// Record 属于 ownerID 的记录
+func Record(ownerID uint64) Resource {
+	return Resource{Kind: KindRecord, OwnerID: ownerID}
+}
+
+// Org 组织资源,OwnerID 为组织的网盘账号
+func Org(org *models.Organization) Resource {
+	return Resource{Kind: KindOrg, OwnerID: org.DriveUserID, Org: org}
+}
+
+// System 系统资源,只有管理员可以操作
+func System() Resource {
+	return Resource{Kind: KindSystem}
+}
+
+// Authorizer 权限判断
+type Authorizer interface {
+	// Can 判断用户能否对资源执行操作,只有查询授权或角色失败时返回错误
+	Can(ctx context.Context, userID uint64, action Action, resource Resource) (bool, error)
+	// Authorize 与 Can 相同,不允许时返回 xerr.ErrPermissionDenied
+	Authorize(ctx context.Context, userID uint64, action Action, resource Resource) error
+}
+
+type authorizer struct {
+	permissionRepo repositories.FilePermissionRepository
+	userRepo       repositories.UserRepository
+	orgRepo        repositories.OrganizationRepository
+	fileRepo       repositories.FileRepository
+}
+
+var _ Authorizer = (*authorizer)(nil)
+
+// NewAuthorizer 创建权限判断实例
+func NewAuthorizer(permissionRepo repositories.FilePermissionRepository, userRepo repositories.UserRepository, orgRepo repositories.OrganizationRepository, fileRepo repositories.FileRepository) Authorizer {
+	return &authorizer{
+		permissionRepo: permissionRepo,
+		userRepo:       userRepo,
+		orgRepo:        orgRepo,
+		fileRepo:       fileRepo,
+	}
+}
+
+func (a *authorizer) Can(ctx context.Context, userID uint64, action Action, resource Resource) (bool, error) {
+	switch resource.Kind {
+	case KindSystem:
+		return a.isAdmin(ctx, userID)
+	case KindRecord:
+		return resource.OwnerID == userID, nil
+	case KindOrg:
+		if resource.Org == nil {
+			return false, nil
+		}
+		return a.hasOrgRole(ctx, userID, resource.Org.ID, action)
+	case KindFile:
+		if resource.File == nil {
+			return false, nil
+		}
+		if resource.File.UserID == userID {
+			return true, nil
+		}
+		// 团队文件归组织的网盘账号所有,成员按角色访问
+		org, err := a.orgRepo.FindByDriveUserID(ctx, resource.File.UserID)
+		if err != nil {
+			logger.Error("Can: Failed to query organization", zap.Uint64("ownerID", resource.File.UserID), zap.Error(err))
+			return false, fmt.Errorf("authz: failed to query organization: %w", xerr.ErrDatabaseError)
+		}
+		if org != nil {
+			if allowed, err := a.hasOrgRole(ctx, userID, org.ID, action); err != nil || allowed {
+				return allowed, err
+			}
+		}
+		required, ok := collaboratorPermissions[action]
+		if !ok {
+			return false, nil
+		}
+		return a.hasGrant(ctx, userID, resource.File, required)
+	default:
+		return false, nil
+	}
+}
+
+func (a *authorizer) Authorize(ctx context.Context, userID uint64, action Action, resource Resource) error {
+	allowed, err := a.Can(ctx, userID, action, resource)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		fields := []zap.Field{
+			zap.Uint64("userID", userID),
+			zap.String("action", string(action)),
+			zap.String("resource", string(resource.Kind)),
+			zap.Uint64("ownerID", resource.OwnerID),
+		}
+		if resource.File != nil {
+			fields = append(fields, zap.Uint64("fileID", resource.File.ID))
+		}
+		if resource.Org != nil {
+			fields = append(fields, zap.Uint64("orgID", resource.Org.ID))
+		}
+		logger.Warn("Authorize: Access denied", fields...)
+		return fmt.Errorf("authz: %s denied: %w", action, xerr.ErrPermissionDenied)
+	}
+	return nil
+}
+
+// isAdmin 检查用户是否为管理员
+func (a *authorizer) isAdmin(ctx context.Context, userID uint64) (bool, error) {
+	user, err := a.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrUserNotFound) {
+			return false, nil
+		}
+		logger.Error("isAdmin: Failed to retrieve user", zap.Uint64("userID", userID), zap.Error(err))
+		return false, fmt.Errorf("authz: failed to retrieve user: %w", xerr.ErrDatabaseError)
+	}
+	return user.Role == models.RoleAdmin, nil
+}
+
+// hasOrgRole 检查用户在组织中的角色是否满足操作要求
+func (a *authorizer) hasOrgRole(ctx context.Context, userID, orgID uint64, action Action) (bool, error) {
+	required, ok := orgRoles[action]
+	if !ok {
+		return false, nil
+	}
+	member, err := a.orgRepo.FindMember(ctx, orgID, userID)
+	if err != nil {
+		logger.Error("hasOrgRole: Failed to query organization member",
+			zap.Uint64("orgID", orgID), zap.Uint64("userID", userID), zap.Error(err))
+		return false, fmt.Errorf("authz: failed to query organization member: %w", xerr.ErrDatabaseError)
+	}
+	if member == nil {
+		return false, nil
+	}
+	return models.OrgRoleRanks[member.Role] >= models.OrgRoleRanks[required], nil
+}
+
+// hasGrant 检查用户是否被授予了覆盖该文件的文件夹权限,授权对文件夹自身及其整个子树生效。
+// 只查询授予文件自身和祖先文件夹的授权,与用户其他授权的数量无关
+func (a *authorizer) hasGrant(ctx context.Context, userID uint64, file *models.File, permission uint8) (bool, error) {
+	covering, err := a.coveringFolders(ctx, file)
+	if err != nil {
+		return false, err
+	}
+	grants, err := a.permissionRepo.FindByGranteeAndFileIDs(userID, file.UserID, covering)
+	if err != nil {
+		logger.Error("hasGrant: Failed to query file permissions",
+			zap.Uint64("fileID", file.ID), zap.Uint64("userID", userID), zap.Error(err))
+		return false, fmt.Errorf("authz: failed to query permissions: %w", xerr.ErrDatabaseError)
+	}
+
+	for _, grant := range grants {
+		if grant.Permission >= permission && grant.Folder != nil && grant.Folder.Status == models.StatusNormal {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// coveringFolders 返回文件自身及其所有祖先文件夹的ID,授予其中任何一个的权限都对该文件生效
+func (a *authorizer) coveringFolders(ctx context.Context, file *models.File) ([]uint64, error) {
+	ids := []uint64{file.ID}
+	if file.ParentFolderID == nil {
+		return ids, nil
+	}
+	chains, err := a.fileRepo.FindFolderChains(ctx, file.UserID, []uint64{*file.ParentFolderID})
+	if err != nil {
+		logger.Error("coveringFolders: Failed to resolve folder ancestors", zap.Uint64("fileID", file.ID), zap.Error(err))
+		return nil, fmt.Errorf("authz: failed to resolve folder ancestors: %w", xerr.ErrDatabaseError)
+	}
+	for _, folder := range chains[*file.ParentFolderID] {
+		ids = append(ids, folder.ID)
+	}
+	return ids, nil
+}
@@ -0,0 +1,322 @@
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mail"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/admin"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// AccountDeletionQueueName 账号注销任务队列名称
+const AccountDeletionQueueName = "account_deletion_queue"
+
+const (
+	// maxListedAccountDeletions 管理员列表单次返回的任务数
+	maxListedAccountDeletions = 100
+	// maxAccountDeletionErrorLength 保存到任务记录中的错误信息长度上限
+	maxAccountDeletionErrorLength = 512
+	// accountDeletionNotifyTimeout 发送注销完成邮件的超时时间
+	accountDeletionNotifyTimeout = 30 * time.Second
+)
+
+// AccountDeletionService 账号注销: 申请后账号立即不能登录,之后由 Worker 依次撤销访问、彻底删除文件、
+// 匿名化活动日志并删除账号,完成后邮件通知。失败的任务由管理员查看并从失败的步骤重试
+type AccountDeletionService interface {
+	// RequestDeletion 校验密码后创建注销任务并撤销全部登录会话,已有未完成的任务时直接返回该任务
+	RequestDeletion(ctx context.Context, userID uint64, password string) (*models.AccountDeletion, error)
+	// ProcessDeletion 从任务当前所在的步骤开始执行,由注销 Worker 调用
+	ProcessDeletion(ctx context.Context, deletionID uint64) error
+	// ListDeletions 管理员查看注销任务,status 为空时返回全部状态
+	ListDeletions(ctx context.Context, status string) ([]models.AccountDeletion, error)
+	// RetryDeletion 管理员重新投递失败的任务,从失败的步骤继续
+	RetryDeletion(ctx context.Context, deletionID uint64) (*models.AccountDeletion, error)
+}
+
+// accountDeletionStep 注销的一个步骤,每个步骤都可以重复执行
+type accountDeletionStep struct {
+	status string
+	run    func(ctx context.Context, deletion *models.AccountDeletion) error
+}
+
+type accountDeletionService struct {
+	deletionRepo   repositories.AccountDeletionRepository
+	userRepo       repositories.UserRepository
+	orgRepo        repositories.OrganizationRepository
+	tm             TransactionManager
+	purgeService   PurgeService
+	sessionService admin.SessionService
+	mailer         mail.Sender
+}
+
+var _ AccountDeletionService = (*accountDeletionService)(nil)
+
+// NewAccountDeletionService 创建账号注销服务实例
+func NewAccountDeletionService(
+	deletionRepo repositories.AccountDeletionRepository,
+	userRepo repositories.UserRepository,
+	orgRepo repositories.OrganizationRepository,
+	tm TransactionManager,
+	purgeService PurgeService,
+	sessionService admin.SessionService,
+	mailer mail.Sender,
+) AccountDeletionService {
+	return &accountDeletionService{
+		deletionRepo:   deletionRepo,
+		userRepo:       userRepo,
+		orgRepo:        orgRepo,
+		tm:             tm,
+		purgeService:   purgeService,
+		sessionService: sessionService,
+		mailer:         mailer,
+	}
+}
+
+func (s *accountDeletionService) RequestDeletion(ctx context.Context, userID uint64, password string) (*models.AccountDeletion, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrUserNotFound) {
+			return nil, fmt.Errorf("account deletion service: %w", xerr.ErrUserNotFound)
+		}
+		logger.Error("RequestDeletion: Failed to get user", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("account deletion service: %w", xerr.ErrDatabaseError)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		logger.Warn("RequestDeletion: Invalid password", zap.Uint64("userID", userID))
+		return nil, fmt.Errorf("account deletion service: %w", xerr.ErrInvalidCredentials)
+	}
+
+	active, err := s.deletionRepo.FindUnfinishedByUserID(ctx, userID)
+	if err != nil {
+		logger.Error("RequestDeletion: Failed to find unfinished deletion", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("account deletion service: %w", xerr.ErrDatabaseError)
+	}
+	if active != nil {
+		return active, nil
+	}
+
+	// 组织的最后一名所有者需要先转让所有权,否则团队文件将无人管理
+	memberships, err := s.orgRepo.FindMembershipsByUserID(ctx, userID)
+	if err != nil {
+		logger.Error("RequestDeletion: Failed to list organization memberships", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("account deletion service: %w", xerr.ErrDatabaseError)
+	}
+	for _, member := range memberships {
+		if member.Role != models.OrgRoleOwner {
+			continue
+		}
+		owners, err := s.orgRepo.CountOwners(ctx, member.OrgID)
+		if err != nil {
+			logger.Error("RequestDeletion: Failed to count organization owners", zap.Uint64("orgID", member.OrgID), zap.Error(err))
+			return nil, fmt.Errorf("account deletion service: %w", xerr.ErrDatabaseError)
+		}
+		if owners <= 1 {
+			return nil, fmt.Errorf("account deletion service: organization %d: %w", member.OrgID, xerr.ErrLastOrgOwner)
+		}
+	}
+
+	deletion := &models.AccountDeletion{
+		UserID:   userID,
+		Username: user.Username,
+		Email:    user.Email,
+		Status:   models.AccountDeletionPending,
+	}
+	// 禁止登录、吊销令牌、创建任务和投递任务消息在同一事务中完成
+	err = s.tm.WithTransaction(ctx, func(tx *gorm.DB) error {
+		user.Status = models.UserStatusDeleting
+		if err := repositories.NewUserRepository(tx).UpdateUser(ctx, user); err != nil {
+			return err
+		}
+		deletionRepo := s.deletionRepo.WithTx(tx)
+		if err := deletionRepo.RevokeTokens(ctx, userID); err != nil {
+			return err
+		}
+		if err := deletionRepo.Create(ctx, deletion); err != nil {
+			return err
+		}
+		event, err := models.NewQueueEvent(AccountDeletionQueueName, models.AccountDeletionTask{DeletionID: deletion.ID})
+		if err != nil {
+			return err
+		}
+		return repositories.NewOutboxRepository(tx).Add(ctx, event)
+	})
+	if err != nil {
+		logger.Error("RequestDeletion: Failed to create deletion", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("account deletion service: %w", xerr.ErrDatabaseError)
+	}
+
+	// 会话撤销失败时 Worker 在第一步会再次撤销
+	if err := s.sessionService.RevokeAll(ctx, userID); err != nil {
+		logger.Error("RequestDeletion: Failed to revoke sessions", zap.Uint64("userID", userID), zap.Error(err))
+	}
+
+	logger.Info("RequestDeletion: Account deletion requested", zap.Uint64("userID", userID), zap.Uint64("deletionID", deletion.ID))
+	return deletion, nil
+}
+
+func (s *accountDeletionService) ProcessDeletion(ctx context.Context, deletionID uint64) error {
+	deletion, err := s.deletionRepo.FindByID(ctx, deletionID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrAccountDeletionNotFound) {
+			logger.Warn("ProcessDeletion: Deletion not found", zap.Uint64("deletionID", deletionID))
+			return nil
+		}
+		return err
+	}
+	if deletion.Status == models.AccountDeletionCompleted || deletion.Status == models.AccountDeletionFailed {
+		return nil
+	}
+
+	steps := []accountDeletionStep{
+		{models.AccountDeletionRevoking, s.revokeAccess},
+		{models.AccountDeletionPurging, s.purgeFiles},
+		{models.AccountDeletionAnonymizing, s.anonymize},
+	}
+	// 消息重新投递时从中断的步骤继续,待处理的任务从第一步开始
+	start := max(slices.IndexFunc(steps, func(step accountDeletionStep) bool { return step.status == deletion.Status }), 0)
+	for _, step := range steps[start:] {
+		deletion.Status = step.status
+		deletion.Step = step.status
+		if err := s.deletionRepo.Update(ctx, deletion); err != nil {
+			return fmt.Errorf("failed to update deletion: %w", err)
+		}
+		if err := step.run(ctx, deletion); err != nil {
+			s.fail(ctx, deletion, err)
+			return err
+		}
+	}
+
+	s.complete(ctx, deletion)
+	return nil
+}
+
+// revokeAccess 撤销会话、令牌、分享、协作授权和组织成员关系
+func (s *accountDeletionService) revokeAccess(ctx context.Context, deletion *models.AccountDeletion) error {
+	if err := s.sessionService.RevokeAll(ctx, deletion.UserID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	if err := s.deletionRepo.RevokeAccess(ctx, deletion.UserID); err != nil {
+		return fmt.Errorf("failed to revoke access: %w", err)
+	}
+	return nil
+}
+
+// purgeFiles 按彻底删除的流程删除全部文件,仍被其他用户引用的存储对象会保留
+func (s *accountDeletionService) purgeFiles(ctx context.Context, deletion *models.AccountDeletion) error {
+	purged, err := s.purgeService.PurgeUserFiles(ctx, deletion.UserID)
+	deletion.PurgedFiles += purged
+	if err != nil {
+		return fmt.Errorf("failed to purge files: %w", err)
+	}
+	return nil
+}
+
+// anonymize 匿名化活动日志并删除账号
+func (s *accountDeletionService) anonymize(ctx context.Context, deletion *models.AccountDeletion) error {
+	if err := s.deletionRepo.Anonymize(ctx, deletion.UserID); err != nil {
+		return fmt.Errorf("failed to anonymize user: %w", err)
+	}
+	return nil
+}
+
+// complete 保存完成状态并发送通知邮件,邮箱地址在发送后清空
+func (s *accountDeletionService) complete(ctx context.Context, deletion *models.AccountDeletion) {
+	if deletion.Email != "" {
+		mailCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), accountDeletionNotifyTimeout)
+		subject := "[go-clouddisk] 账号已注销"
+		body := fmt.Sprintf("你的账号 %s 已按申请注销。\n\n"+
+			"账号中的全部文件、分享和授权已删除,活动记录已匿名化,这些数据无法恢复。\n",
+			deletion.Username)
+		if err := s.mailer.Send(mailCtx, deletion.Email, subject, body); err != nil {
+			logger.Error("ProcessDeletion: Failed to send completion mail", zap.Uint64("deletionID", deletion.ID), zap.Error(err))
+		}
+		cancel()
+	}
+
+	now := time.Now()
+	deletion.Status = models.AccountDeletionCompleted
+	deletion.Email = ""
+	deletion.Error = ""
+	deletion.CompletedAt = &now
+	if err := s.deletionRepo.Update(ctx, deletion); err != nil {
+		logger.Error("ProcessDeletion: Failed to update deletion", zap.Uint64("deletionID", deletion.ID), zap.Error(err))
+		return
+	}
+	logger.Info("ProcessDeletion: Account deleted", zap.Uint64("deletionID", deletion.ID), zap.Uint64("userID", deletion.UserID),
+		zap.Int64("purgedFiles", deletion.PurgedFiles))
+}
+
+// fail 保存失败状态,Step 保留失败的步骤
+func (s *accountDeletionService) fail(ctx context.Context, deletion *models.AccountDeletion, err error) {
+	logger.Error("Account deletion failed", zap.Uint64("deletionID", deletion.ID), zap.String("step", deletion.Step), zap.Error(err))
+	message := err.Error()
+	if len(message) > maxAccountDeletionErrorLength {
+		message = strings.ToValidUTF8(message[:maxAccountDeletionErrorLength], "")
+	}
+	deletion.Status = models.AccountDeletionFailed
+	deletion.Error = message
+	if updateErr := s.deletionRepo.Update(context.WithoutCancel(ctx), deletion); updateErr != nil {
+		logger.Error("ProcessDeletion: Failed to update deletion", zap.Uint64("deletionID", deletion.ID), zap.Error(updateErr))
+	}
+}
+
+func (s *accountDeletionService) ListDeletions(ctx context.Context, status string) ([]models.AccountDeletion, error) {
+	deletions, err := s.deletionRepo.List(ctx, status, maxListedAccountDeletions)
+	if err != nil {
+		logger.Error("ListDeletions: Failed to list deletions", zap.String("status", status), zap.Error(err))
+		return nil, fmt.Errorf("account deletion service: %w", xerr.ErrDatabaseError)
+	}
+	return deletions, nil
+}
+
+func (s *accountDeletionService) RetryDeletion(ctx context.Context, deletionID uint64) (*models.AccountDeletion, error) {
+	deletion, err := s.deletionRepo.FindByID(ctx, deletionID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrAccountDeletionNotFound) {
+			return nil, fmt.Errorf("account deletion service: %w", xerr.ErrAccountDeletionNotFound)
+		}
+		logger.Error("RetryDeletion: Failed to find deletion", zap.Uint64("deletionID", deletionID), zap.Error(err))
+		return nil, fmt.Errorf("account deletion service: %w", xerr.ErrDatabaseError)
+	}
+	switch deletion.Status {
+	case models.AccountDeletionCompleted:
+		return deletion, nil
+	case models.AccountDeletionFailed:
+	default:
+		return nil, fmt.Errorf("account deletion service: deletion %d is %s: %w", deletion.ID, deletion.Status, xerr.ErrAccountDeletionInProgress)
+	}
+
+	deletion.Status = deletion.Step
+	if deletion.Status == "" {
+		deletion.Status = models.AccountDeletionPending
+	}
+	deletion.Error = ""
+	err = s.tm.WithTransaction(ctx, func(tx *gorm.DB) error {
+		if err := s.deletionRepo.WithTx(tx).Update(ctx, deletion); err != nil {
+			return err
+		}
+		event, err := models.NewQueueEvent(AccountDeletionQueueName, models.AccountDeletionTask{DeletionID: deletion.ID})
+		if err != nil {
+			return err
+		}
+		return repositories.NewOutboxRepository(tx).Add(ctx, event)
+	})
+	if err != nil {
+		logger.Error("RetryDeletion: Failed to requeue deletion", zap.Uint64("deletionID", deletion.ID), zap.Error(err))
+		return nil, fmt.Errorf("account deletion service: %w", xerr.ErrDatabaseError)
+	}
+
+	logger.Info("RetryDeletion: Account deletion requeued", zap.Uint64("deletionID", deletion.ID), zap.String("step", deletion.Step))
+	return deletion, nil
+}
@@ -0,0 +1,378 @@
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/storage"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/activity"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ArchiveQueueName 文件夹打包任务队列名称
+const ArchiveQueueName = "folder_archive_queue"
+
+const (
+	defaultArchiveTTL          = 24 // 小时
+	defaultArchiveURLExpiry    = 60 // 分钟
+	defaultArchiveMaxTotalSize = 10 << 30
+	// archiveEntryOverhead 每个条目的本地头、中央目录和校验清单行预留的字节数
+	archiveEntryOverhead = 1 << 10
+)
+
+// errArchiveTooLarge ZIP 超出大小上限时写入中止
+var errArchiveTooLarge = fmt.Errorf("archive exceeds size limit: %w", xerr.ErrFileTooLarge)
+
+// ArchiveService 文件夹异步打包: Worker 把文件夹打包成 ZIP 暂存到存储桶,
+// 完成后客户端通过限时链接或下载接口获取,两者都支持 Range 请求,可以断点续传
+type ArchiveService interface {
+	// RequestArchive 创建打包任务并投递给 Worker,同一文件夹已有进行中的任务时直接返回该任务
+	RequestArchive(ctx context.Context, userID uint64, folderID uint64) (*models.ArchiveJob, error)
+	// GetArchive 返回打包任务,已完成且未过期时附带限时下载链接
+	GetArchive(ctx context.Context, userID uint64, jobID uint64) (*models.ArchiveJob, error)
+	// OpenArchive 打开已完成的 ZIP,调用方负责关闭 reader
+	OpenArchive(ctx context.Context, userID uint64, jobID uint64) (*models.ArchiveJob, io.ReadCloser, error)
+	// ProcessArchive 打包并上传 ZIP,由打包 Worker 调用
+	ProcessArchive(ctx context.Context, jobID uint64) error
+	// ExpireArchives 删除 before 之前过期的 ZIP,返回处理的任务数
+	ExpireArchives(ctx context.Context, before time.Time, limit int) (int, error)
+}
+
+type archiveService struct {
+	archiveRepo   repositories.ArchiveJobRepository
+	fileRepo      repositories.FileRepository
+	domainService FileDomainService
+	fileService   FileService
+	storage       storage.StorageService
+	tm            TransactionManager
+	notifications activity.NotificationService
+	cfg           *config.Config
+}
+
+var _ ArchiveService = (*archiveService)(nil)
+
+// NewArchiveService 创建文件夹打包服务实例
+func NewArchiveService(
+	archiveRepo repositories.ArchiveJobRepository,
+	fileRepo repositories.FileRepository,
+	domainService FileDomainService,
+	fileService FileService,
+	storageService storage.StorageService,
+	tm TransactionManager,
+	notifications activity.NotificationService,
+	cfg *config.Config,
+) ArchiveService {
+	return &archiveService{
+		archiveRepo:   archiveRepo,
+		fileRepo:      fileRepo,
+		domainService: domainService,
+		fileService:   fileService,
+		storage:       storageService,
+		tm:            tm,
+		notifications: notifications,
+		cfg:           cfg,
+	}
+}
+
+func (s *archiveService) RequestArchive(ctx context.Context, userID uint64, folderID uint64) (*models.ArchiveJob, error) {
+	folder, err := s.domainService.CheckFile(ctx, userID, folderID)
+	if err != nil {
+		return nil, err
+	}
+	if folder.IsFolder != 1 {
+		return nil, fmt.Errorf("archive service: %w", xerr.ErrTargetNotFolder)
+	}
+
+	active, err := s.archiveRepo.FindActive(ctx, userID, folderID)
+	if err != nil {
+		logger.Error("RequestArchive: Failed to find active archive job", zap.Uint64("folderID", folderID), zap.Error(err))
+		return nil, fmt.Errorf("archive service: failed to find active archive job: %w", xerr.ErrDatabaseError)
+	}
+	if active != nil {
+		return active, nil
+	}
+
+	job := &models.ArchiveJob{
+		UserID:    userID,
+		FolderID:  folderID,
+		FileName:  folder.FileName + ".zip",
+		Status:    models.ArchiveStatusPending,
+		OssBucket: s.cfg.DefaultBucketName(),
+	}
+	// 任务和任务消息在同一事务中写入,创建成功的任务一定会被处理
+	err = s.tm.WithTransaction(ctx, func(tx *gorm.DB) error {
+		if err := repositories.NewArchiveJobRepository(tx).Create(ctx, job); err != nil {
+			return err
+		}
+		event, err := models.NewQueueEvent(ArchiveQueueName, models.ArchiveTask{JobID: job.ID, UserID: userID})
+		if err != nil {
+			return err
+		}
+		return repositories.NewOutboxRepository(tx).Add(ctx, event)
+	})
+	if err != nil {
+		logger.Error("RequestArchive: Failed to create archive job", zap.Uint64("folderID", folderID), zap.Error(err))
+		return nil, fmt.Errorf("archive service: failed to create archive job: %w", xerr.ErrDatabaseError)
+	}
+
+	logger.Info("RequestArchive: Archive requested", zap.Uint64("userID", userID), zap.Uint64("folderID", folderID), zap.Uint64("jobID", job.ID))
+	return job, nil
+}
+
+func (s *archiveService) GetArchive(ctx context.Context, userID uint64, jobID uint64) (*models.ArchiveJob, error) {
+	job, err := s.findJob(ctx, userID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != models.ArchiveStatusReady {
+		return job, nil
+	}
+
+	// 链接有效期不超过剩余保留时间,过期后对象会被清理
+	remaining := time.Until(*job.ExpiresAt)
+	expiry := time.Duration(s.cfg.Archive.URLExpiry) * time.Minute
+	if expiry <= 0 {
+		expiry = defaultArchiveURLExpiry * time.Minute
+	}
+	url, err := s.storage.GeneratePresignedURL(ctx, job.OssBucket, job.OssKey, job.VersionID, min(expiry, remaining), storage.PresignOptions{})
+	if err != nil {
+		logger.Error("GetArchive: Failed to generate download URL", zap.Uint64("jobID", jobID), zap.Error(err))
+		return nil, fmt.Errorf("archive service: failed to generate download url: %w", xerr.ErrStorageError)
+	}
+	job.URL = url
+	return job, nil
+}
+
+func (s *archiveService) OpenArchive(ctx context.Context, userID uint64, jobID uint64) (*models.ArchiveJob, io.ReadCloser, error) {
+	job, err := s.findJob(ctx, userID, jobID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if job.Status != models.ArchiveStatusReady {
+		return nil, nil, fmt.Errorf("archive service: archive is %s: %w", job.Status, xerr.ErrArchiveNotFound)
+	}
+
+	object, err := s.storage.GetObject(ctx, job.OssBucket, job.OssKey, job.VersionID)
+	if err != nil {
+		logger.Error("OpenArchive: Failed to get archive from storage", zap.Uint64("jobID", jobID), zap.Error(err))
+		return nil, nil, fmt.Errorf("archive service: failed to get archive: %w", xerr.ErrStorageError)
+	}
+	return job, object.Reader, nil
+}
+
+// findJob 查询属于该用户的打包任务,已过期但尚未清理的任务视为不存在
+func (s *archiveService) findJob(ctx context.Context, userID uint64, jobID uint64) (*models.ArchiveJob, error) {
+	job, err := s.archiveRepo.FindByID(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrArchiveNotFound) {
+			return nil, fmt.Errorf("archive service: %w", xerr.ErrArchiveNotFound)
+		}
+		logger.Error("GetArchive: Failed to get archive job", zap.Uint64("jobID", jobID), zap.Error(err))
+		return nil, fmt.Errorf("archive service: failed to get archive job: %w", xerr.ErrDatabaseError)
+	}
+	if job.UserID != userID || job.Status == models.ArchiveStatusExpired {
+		return nil, fmt.Errorf("archive service: %w", xerr.ErrArchiveNotFound)
+	}
+	if job.Status == models.ArchiveStatusReady && (job.ExpiresAt == nil || !time.Now().Before(*job.ExpiresAt)) {
+		return nil, fmt.Errorf("archive service: %w", xerr.ErrArchiveNotFound)
+	}
+	return job, nil
+}
+
+func (s *archiveService) ProcessArchive(ctx context.Context, jobID uint64) error {
+	job, err := s.archiveRepo.FindByID(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrArchiveNotFound) {
+			return nil
+		}
+		return fmt.Errorf("archive service: failed to get archive job: %w", err)
+	}
+	// 处理中的任务说明上次处理时 Worker 异常退出,消息重新投递后重新打包
+	if job.Status != models.ArchiveStatusPending && job.Status != models.ArchiveStatusProcessing {
+		return nil
+	}
+
+	job.Status = models.ArchiveStatusProcessing
+	if err := s.archiveRepo.Update(ctx, job); err != nil {
+		return fmt.Errorf("archive service: failed to mark archive job processing: %w", err)
+	}
+
+	// 排队期间用户可能已失去访问权限或文件夹已被删除
+	folder, err := s.domainService.CheckFile(ctx, job.UserID, job.FolderID)
+	if err != nil {
+		s.fail(ctx, job, err)
+		return err
+	}
+	files, err := s.domainService.CollectAllNormalFiles(ctx, folder.UserID, folder.ID)
+	if err != nil {
+		err = fmt.Errorf("failed to collect folder children: %w", err)
+		s.fail(ctx, job, err)
+		return err
+	}
+
+	entries := make([]zipEntry, 0, len(files))
+	for i := range files {
+		entries = append(entries, zipEntry{Name: s.domainService.GetRelativePathInZip(folder, &files[i]), File: &files[i]})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	size := sumFolderSize(folder.ID, files)
+	if maxSize := s.maxTotalSize(); size.TotalSize > uint64(maxSize) {
+		err := fmt.Errorf("folder size %d exceeds limit %d: %w", size.TotalSize, maxSize, xerr.ErrFileTooLarge)
+		s.fail(ctx, job, err)
+		return err
+	}
+
+	if err := s.writeArchive(ctx, job, entries); err != nil {
+		s.fail(ctx, job, err)
+		return err
+	}
+
+	ttl := s.cfg.Archive.TTL
+	if ttl <= 0 {
+		ttl = defaultArchiveTTL
+	}
+	expiresAt := time.Now().Add(time.Duration(ttl) * time.Hour)
+	job.Status = models.ArchiveStatusReady
+	job.FileCount = size.FileCount
+	job.TotalSize = size.TotalSize
+	job.ExpiresAt = &expiresAt
+	if err := s.archiveRepo.Update(ctx, job); err != nil {
+		s.removeArchive(ctx, job)
+		return fmt.Errorf("archive service: failed to mark archive job ready: %w", err)
+	}
+
+	s.notifications.Notify(ctx, &models.Notification{
+		UserID:     job.UserID,
+		Type:       models.NotificationArchiveReady,
+		Title:      "文件夹打包完成",
+		Body:       fmt.Sprintf("%s 已打包完成,可以下载", job.FileName),
+		FileID:     &job.FolderID,
+		ResourceID: &job.ID,
+	})
+
+	logger.Info("ProcessArchive: Archive ready",
+		zap.Uint64("jobID", job.ID),
+		zap.Uint64("folderID", job.FolderID),
+		zap.Int64("files", job.FileCount),
+		zap.Int64("size", job.Size))
+	return nil
+}
+
+func (s *archiveService) ExpireArchives(ctx context.Context, before time.Time, limit int) (int, error) {
+	jobs, err := s.archiveRepo.FindExpired(ctx, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("archive service: failed to find expired archives: %w", err)
+	}
+
+	for i := range jobs {
+		job := &jobs[i]
+		s.removeArchive(ctx, job)
+		job.Status = models.ArchiveStatusExpired
+		if err := s.archiveRepo.Update(ctx, job); err != nil {
+			return i, fmt.Errorf("archive service: failed to mark archive job %d expired: %w", job.ID, err)
+		}
+	}
+	return len(jobs), nil
+}
+
+// writeArchive 先把 ZIP 写入临时文件,得到确切大小后再上传,部分存储后端不支持未知长度的上传
+func (s *archiveService) writeArchive(ctx context.Context, job *models.ArchiveJob, entries []zipEntry) error {
+	tmp, err := os.CreateTemp(s.cfg.Archive.TempDir, fmt.Sprintf("archive-%d-*.zip", job.ID))
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	// 记录的大小与实际内容不一致时也不会写出超过上限的 ZIP
+	limit := s.maxTotalSize() + int64(len(entries)+1)*archiveEntryOverhead
+	prefetcher := newZipPrefetcher(s.fileService.GetFileContentReader, s.cfg.Download.ZipPrefetch, s.cfg.Download.ZipPrefetchMemory)
+	if err := writeZipArchive(ctx, &limitedWriter{w: tmp, remaining: limit}, entries, prefetcher, false); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("failed to get archive size: %w", err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind archive: %w", err)
+	}
+
+	objectName := fmt.Sprintf("archives/%d/%d/%s", job.UserID, job.ID, job.FileName)
+	result, err := s.storage.PutObject(ctx, job.OssBucket, objectName, tmp, size, "application/zip")
+	if err != nil {
+		return fmt.Errorf("failed to upload archive: %w", err)
+	}
+	job.OssKey = objectName
+	job.VersionID = result.VersionID
+	job.Size = size
+	return nil
+}
+
+// removeArchive 删除暂存的 ZIP,失败只记录日志
+func (s *archiveService) removeArchive(ctx context.Context, job *models.ArchiveJob) {
+	if job.OssKey == "" {
+		return
+	}
+	if err := s.storage.RemoveObject(ctx, job.OssBucket, job.OssKey, job.VersionID); err != nil {
+		logger.Warn("Failed to remove archive", zap.Uint64("jobID", job.ID), zap.String("ossKey", job.OssKey), zap.Error(err))
+	}
+}
+
+// maxTotalSize 返回允许打包的文件总大小上限
+func (s *archiveService) maxTotalSize() int64 {
+	if s.cfg.Archive.MaxTotalSize > 0 {
+		return s.cfg.Archive.MaxTotalSize
+	}
+	return defaultArchiveMaxTotalSize
+}
+
+// fail 把打包任务标记为失败。错误原因只记录到日志,任务中只保存面向用户的提示,避免暴露存储路径等内部信息
+func (s *archiveService) fail(ctx context.Context, job *models.ArchiveJob, cause error) {
+	logger.Error("Archive failed", zap.Uint64("jobID", job.ID), zap.Uint64("folderID", job.FolderID), zap.Error(cause))
+	job.Status = models.ArchiveStatusFailed
+	job.Error = archiveFailureMessage(cause)
+	if err := s.archiveRepo.Update(ctx, job); err != nil {
+		logger.Error("Failed to mark archive job failed", zap.Uint64("jobID", job.ID), zap.Error(err))
+	}
+}
+
+// archiveFailureMessage 把打包失败的原因转换为面向用户的提示
+func archiveFailureMessage(cause error) string {
+	switch {
+	case errors.Is(cause, xerr.ErrFileTooLarge):
+		return "文件夹超过打包大小上限"
+	case errors.Is(cause, xerr.ErrFileNotFound), errors.Is(cause, xerr.ErrPermissionDenied):
+		return "文件夹不存在或无权访问"
+	default:
+		return "打包失败,请稍后重试"
+	}
+}
+
+// limitedWriter 写入超过 remaining 字节时返回 errArchiveTooLarge
+type limitedWriter struct {
+	w         io.Writer
+	remaining int64
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining {
+		return 0, errArchiveTooLarge
+	}
+	n, err := l.w.Write(p)
+	l.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,380 @@
+package explorer
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/tracing"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/authz"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// MaxBatchSize 单次批量操作允许的最大条目数
+const MaxBatchSize = 500
+
+// BatchMove 批量移动文件或文件夹到同一目标目录,所有条目预先校验,在同一事务中完成
+func (s *fileService) BatchMove(ctx context.Context, userID uint64, fileIDs []uint64, targetParentID *uint64, tagPropagation string) ([]models.File, error) {
+	ctx, span := tracing.Start(ctx, "FileService.BatchMove")
+	defer span.End()
+
+	if err := checkTagPropagation(tagPropagation); err != nil {
+		return nil, err
+	}
+
+	filesToMove, ownerID, err := s.checkBatchFiles(ctx, userID, fileIDs, authz.ActionWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	targetParentFolder, err := s.domainService.CheckWritableDirectory(ctx, userID, targetParentID)
+	if err != nil {
+		return nil, err
+	}
+	targetOwnerID := userID
+	targetParentFullPath := "/"
+	if targetParentFolder != nil {
+		targetOwnerID = targetParentFolder.UserID
+		targetParentFullPath = targetParentFolder.Path + targetParentFolder.FileName + "/"
+	}
+	if targetOwnerID != ownerID {
+		logger.Warn("BatchMove: Cannot move files across owners",
+			zap.Uint64("ownerID", ownerID), zap.Uint64("targetOwnerID", targetOwnerID))
+		return nil, fmt.Errorf("file service: %w", xerr.ErrPermissionDenied)
+	}
+
+	// 选中的条目之间不能存在包含关系,否则子项会被移动两次
+	if nested := findNestedFiles(filesToMove); len(nested) > 0 {
+		logger.Warn("BatchMove: Selection contains items nested in other selected folders",
+			zap.Uint64("userID", userID), zap.Uint64("fileID", nested[0].ID))
+		return nil, fmt.Errorf("file service: file %d is inside another selected folder: %w", nested[0].ID, xerr.ErrInvalidParams)
+	}
+
+	// 已经位于目标目录的条目无需移动,视为成功,也不参与命名冲突检查
+	var moving []int
+	seenNames := make(map[string]bool, len(filesToMove))
+	for i, file := range filesToMove {
+		if isSameParent(file.ParentFolderID, targetParentID) {
+			logger.Info("BatchMove: File already in the target directory, skipping",
+				zap.Uint64("fileID", file.ID), zap.Any("targetParentID", targetParentID), zap.Uint64("userID", userID))
+			continue
+		}
+		if strings.HasPrefix(targetParentFullPath, fullPathWithSelf(&file)) {
+			logger.Warn("BatchMove: Cannot move folder into its own subdirectory",
+				zap.Uint64("fileID", file.ID), zap.Any("targetParentID", targetParentID), zap.Uint64("userID", userID))
+			return nil, fmt.Errorf("file service: %w", xerr.ErrCannotMoveIntoSubtree)
+		}
+		if seenNames[file.FileName] {
+			return nil, fmt.Errorf("file service: duplicate name %q in batch: %w", file.FileName, xerr.ErrInvalidParams)
+		}
+		seenNames[file.FileName] = true
+		moving = append(moving, i)
+	}
+	if len(moving) == 0 {
+		logger.Info("BatchMove: All files already in the target directory", zap.Uint64("userID", userID), zap.Int("count", len(filesToMove)))
+		return filesToMove, nil
+	}
+
+	// 解决与目标目录已有文件的命名冲突,并记录移动前的路径
+	sourcePaths := make([]string, len(moving))
+	moved := make([]models.File, len(moving))
+	finalNames := make(map[string]bool, len(moving))
+	for i, idx := range moving {
+		file := &filesToMove[idx]
+		sourcePaths[i] = file.Path
+
+		finalFileName, err := s.domainService.ResolveFileNameConflict(ctx, ownerID, targetParentID, file.FileName, file.ID, file.IsFolder)
+		if err != nil {
+			return nil, err
+		}
+		if finalNames[finalFileName] {
+			return nil, fmt.Errorf("file service: resolved name %q conflicts within batch: %w", finalFileName, xerr.ErrFileAlreadyExists)
+		}
+		finalNames[finalFileName] = true
+
+		file.FileName = finalFileName
+		file.ParentFolderID = targetParentID
+		file.Path = targetParentFullPath
+		moved[i] = *file
+	}
+
+	movedIDs := make([]uint64, len(moved))
+	for i, file := range moved {
+		movedIDs[i] = file.ID
+	}
+	err = s.transactionManager.WithTransaction(ctx, func(tx *gorm.DB) error {
+		if err := s.saveBatchMove(ctx, s.fileRepo.WithTx(tx), moved); err != nil {
+			return err
+		}
+		return propagateTags(repositories.NewFileTagRepository(tx), userID, movedIDs, targetParentID, tagPropagation)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, file := range moved {
+		s.activityService.Record(ctx, ownerID, file.ID, models.ActivityMove, fmt.Sprintf("%s -> %s", sourcePaths[i], targetParentFullPath))
+	}
+	s.statsService.NotifyChanged(ctx, ownerID, append(sourcePaths, targetParentFullPath)...)
+	logger.Info("BatchMove success", zap.Uint64("userID", userID), zap.Int("count", len(filesToMove)), zap.Int("moved", len(moved)))
+	return filesToMove, nil
+}
+
+// OrganizeFiles 在 parentFolderID 下新建文件夹(名称冲突时自动重命名),并把选中的条目移动进去。
+// 新建文件夹和移动在同一事务中完成,任意一步失败则都不生效
+func (s *fileService) OrganizeFiles(ctx context.Context, userID uint64, folderName string, parentFolderID *uint64, fileIDs []uint64, tagPropagation string) (*models.File, []models.File, error) {
+	ctx, span := tracing.Start(ctx, "FileService.OrganizeFiles")
+	defer span.End()
+
+	if err := checkTagPropagation(tagPropagation); err != nil {
+		return nil, nil, err
+	}
+
+	folderName, err := s.domainService.NormalizeFileName(folderName)
+	if err != nil {
+		return nil, nil, err
+	}
+	filesToMove, ownerID, err := s.checkBatchFiles(ctx, userID, fileIDs, authz.ActionWrite)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parentFolder, err := s.domainService.CheckWritableDirectory(ctx, userID, parentFolderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	parentOwnerID := userID
+	parentPath := "/"
+	if parentFolder != nil {
+		parentOwnerID = parentFolder.UserID
+		parentPath = parentFolder.Path + parentFolder.FileName + "/"
+	}
+	if parentOwnerID != ownerID {
+		logger.Warn("OrganizeFiles: Cannot move files across owners",
+			zap.Uint64("ownerID", ownerID), zap.Uint64("targetOwnerID", parentOwnerID))
+		return nil, nil, fmt.Errorf("file service: %w", xerr.ErrPermissionDenied)
+	}
+
+	if nested := findNestedFiles(filesToMove); len(nested) > 0 {
+		logger.Warn("OrganizeFiles: Selection contains items nested in other selected folders",
+			zap.Uint64("userID", userID), zap.Uint64("fileID", nested[0].ID))
+		return nil, nil, fmt.Errorf("file service: file %d is inside another selected folder: %w", nested[0].ID, xerr.ErrInvalidParams)
+	}
+	// 新文件夹是空的,只需要保证选中的条目之间不重名
+	seenNames := make(map[string]bool, len(filesToMove))
+	for _, file := range filesToMove {
+		if strings.HasPrefix(parentPath, fullPathWithSelf(&file)) {
+			logger.Warn("OrganizeFiles: Cannot move folder into its own subdirectory",
+				zap.Uint64("fileID", file.ID), zap.Any("parentFolderID", parentFolderID), zap.Uint64("userID", userID))
+			return nil, nil, fmt.Errorf("file service: %w", xerr.ErrCannotMoveIntoSubtree)
+		}
+		if seenNames[file.FileName] {
+			return nil, nil, fmt.Errorf("file service: duplicate name %q in batch: %w", file.FileName, xerr.ErrInvalidParams)
+		}
+		seenNames[file.FileName] = true
+	}
+
+	finalFolderName, err := s.domainService.ResolveFileNameConflict(ctx, ownerID, parentFolderID, folderName, 0, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	newFolder := &models.File{
+		UUID:           uuid.New().String(),
+		UserID:         ownerID,
+		ParentFolderID: parentFolderID,
+		FileName:       finalFolderName,
+		Path:           parentPath,
+		IsFolder:       1,
+		Status:         models.StatusNormal,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	targetPath := parentPath + finalFolderName + "/"
+
+	sourcePaths := make([]string, len(filesToMove))
+	for i := range filesToMove {
+		file := &filesToMove[i]
+		sourcePaths[i] = file.Path
+		file.Path = targetPath
+	}
+
+	err = s.transactionManager.WithTransaction(ctx, func(tx *gorm.DB) error {
+		fileRepo := s.fileRepo.WithTx(tx)
+		if err := fileRepo.Create(ctx, newFolder); err != nil {
+			logger.Error("OrganizeFiles: Failed to create folder in DB transaction",
+				zap.Uint64("userID", userID), zap.String("folderName", finalFolderName), zap.Error(err))
+			return fmt.Errorf("file service: failed to create folder: %w", xerr.ErrDatabaseError)
+		}
+		movedIDs := make([]uint64, len(filesToMove))
+		for i := range filesToMove {
+			filesToMove[i].ParentFolderID = &newFolder.ID
+			movedIDs[i] = filesToMove[i].ID
+		}
+		if err := s.saveBatchMove(ctx, fileRepo, filesToMove); err != nil {
+			return err
+		}
+		return propagateTags(repositories.NewFileTagRepository(tx), userID, movedIDs, &newFolder.ID, tagPropagation)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, file := range filesToMove {
+		s.activityService.Record(ctx, ownerID, file.ID, models.ActivityMove, fmt.Sprintf("%s -> %s", sourcePaths[i], targetPath))
+	}
+	s.statsService.NotifyChanged(ctx, ownerID, append(sourcePaths, parentPath, targetPath)...)
+	logger.Info("OrganizeFiles success", zap.Uint64("userID", userID), zap.Uint64("folderID", newFolder.ID), zap.Int("count", len(filesToMove)))
+	return newFolder, filesToMove, nil
+}
+
+// saveBatchMove 在事务中保存已修改父目录的条目,子项的路径在读取时按新的位置计算,不需要改写
+func (s *fileService) saveBatchMove(ctx context.Context, fileRepo repositories.FileRepository, files []models.File) error {
+	for i := range files {
+		if err := fileRepo.Update(ctx, &files[i]); err != nil {
+			logger.Error("saveBatchMove: Failed to update file's parent and path in DB transaction",
+				zap.Uint64("fileID", files[i].ID), zap.Error(err))
+			return fmt.Errorf("file service: failed to update file %d: %w", files[i].ID, xerr.ErrDatabaseError)
+		}
+	}
+	return nil
+}
+
+// BatchSoftDelete 批量将文件或文件夹移入回收站,位于跳过回收站文件夹中的条目发起彻底删除,
+// 全部在同一事务中完成,任意一项失败则整体不变
+func (s *fileService) BatchSoftDelete(ctx context.Context, userID uint64, fileIDs []uint64) ([]models.PurgeJob, error) {
+	ctx, span := tracing.Start(ctx, "FileService.BatchSoftDelete")
+	defer span.End()
+
+	selected, ownerID, err := s.checkBatchFiles(ctx, userID, fileIDs, authz.ActionDelete)
+	if err != nil {
+		return nil, err
+	}
+
+	// 已被选中文件夹包含的条目会随文件夹一起删除,无需单独处理
+	nested := findNestedFiles(selected)
+	roots := slices.DeleteFunc(slices.Clone(selected), func(f models.File) bool {
+		return slices.ContainsFunc(nested, func(n models.File) bool { return n.ID == f.ID })
+	})
+
+	var filesToDelete, filesToPurge []models.File
+	for _, root := range roots {
+		skip, err := s.inSkipRecycleBinFolder(ctx, &root)
+		if err != nil {
+			return nil, err
+		}
+		if skip {
+			filesToPurge = append(filesToPurge, root)
+			continue
+		}
+		files, err := s.domainService.CollectAllFiles(ctx, ownerID, root.ID)
+		if err != nil {
+			logger.Error("BatchSoftDelete: Failed to collect files for soft deletion", zap.Uint64("fileID", root.ID), zap.Error(err))
+			return nil, fmt.Errorf("file service: %w", err)
+		}
+		filesToDelete = append(filesToDelete, files...)
+	}
+
+	//需要反转文件切片,从尾部开始删除
+	slices.Reverse(filesToDelete)
+	var jobs []models.PurgeJob
+	err = s.transactionManager.WithTransaction(ctx, func(tx *gorm.DB) error {
+		jobs = nil
+		if err := s.performSoftDelete(ctx, s.fileRepo.WithTx(tx), repositories.NewFileVersionRepository(tx), ownerID, filesToDelete); err != nil {
+			return err
+		}
+		for i := range filesToPurge {
+			job, err := s.purgeService.PurgeInTx(ctx, tx, userID, &filesToPurge[i])
+			if err != nil {
+				return err
+			}
+			jobs = append(jobs, *job)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range selected {
+		if slices.ContainsFunc(filesToPurge, func(p models.File) bool { return p.ID == file.ID }) {
+			s.activityService.Record(ctx, ownerID, file.ID, models.ActivityDelete, "permanent: "+file.FileName)
+			continue
+		}
+		s.activityService.Record(ctx, ownerID, file.ID, models.ActivityDelete, file.FileName)
+	}
+	folderPaths := make([]string, 0, len(roots))
+	for _, root := range roots {
+		folderPaths = append(folderPaths, root.Path)
+	}
+	s.statsService.NotifyChanged(ctx, ownerID, folderPaths...)
+	logger.Info("BatchSoftDelete success", zap.Uint64("userID", userID), zap.Int("count", len(selected)), zap.Int("purged", len(filesToPurge)))
+	return jobs, nil
+}
+
+// checkBatchFiles 去重并校验批量操作中的每一个条目,任意一项不合法则整体失败
+// 所有条目必须属于同一个所有者,返回该所有者ID
+func (s *fileService) checkBatchFiles(ctx context.Context, userID uint64, fileIDs []uint64, action authz.Action) ([]models.File, uint64, error) {
+	if len(fileIDs) == 0 || len(fileIDs) > MaxBatchSize {
+		return nil, 0, fmt.Errorf("file service: batch size must be between 1 and %d: %w", MaxBatchSize, xerr.ErrInvalidParams)
+	}
+
+	seen := make(map[uint64]bool, len(fileIDs))
+	files := make([]models.File, 0, len(fileIDs))
+	for _, fileID := range fileIDs {
+		if seen[fileID] {
+			continue
+		}
+		seen[fileID] = true
+
+		file, err := s.domainService.CheckFileAccess(ctx, userID, fileID, action)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := s.lockService.CheckTreeLock(ctx, userID, file); err != nil {
+			return nil, 0, err
+		}
+		if len(files) > 0 && file.UserID != files[0].UserID {
+			return nil, 0, fmt.Errorf("file service: batch items belong to different owners: %w", xerr.ErrInvalidParams)
+		}
+		files = append(files, *file)
+	}
+	return files, files[0].UserID, nil
+}
+
+// findNestedFiles 返回位于其他选中文件夹内部的条目
+func findNestedFiles(files []models.File) []models.File {
+	var nested []models.File
+	for _, file := range files {
+		for _, folder := range files {
+			if folder.IsFolder == 1 && folder.ID != file.ID && strings.HasPrefix(file.Path, fullPathWithSelf(&folder)) {
+				nested = append(nested, file)
+				break
+			}
+		}
+	}
+	return nested
+}
+
+// fullPathWithSelf 返回包含自身名称的完整路径,文件夹以 "/" 结尾
+func fullPathWithSelf(file *models.File) string {
+	if file.IsFolder == 1 {
+		return file.Path + file.FileName + "/"
+	}
+	return file.Path + file.FileName
+}
+
+func isSameParent(a, b *uint64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
@@ -0,0 +1,151 @@
+package explorer
+
+import (
+	"context"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultWarmConcurrency = 8
+	defaultWarmMaxFolders  = 10
+	defaultWarmInterval    = 5 * time.Minute
+	// warmTimeout 单次预热的最长时间,超时后放弃剩余的文件夹
+	warmTimeout = 30 * time.Second
+)
+
+// CacheWarmService 预先把用户的根目录和最近使用的文件夹加载到 Redis 列表缓存和元数据缓存,
+// 加载过程在后台进行,不阻塞触发预热的请求
+type CacheWarmService interface {
+	// Warm 开始在后台预热用户的缓存,未开启预热或该用户在间隔内已预热过时返回 false
+	Warm(ctx context.Context, userID uint64) bool
+}
+
+type cacheWarmService struct {
+	fileRepo        repositories.FileRepository
+	favoriteService FavoriteService
+	cache           cache.Cache
+	cfg             *config.CacheWarmConfig
+	// slots 限制全部用户同时加载的文件夹数量,避免大量用户同时登录时压垮数据库
+	slots chan struct{}
+}
+
+var _ CacheWarmService = (*cacheWarmService)(nil)
+
+// NewCacheWarmService 创建缓存预热服务实例
+func NewCacheWarmService(fileRepo repositories.FileRepository, favoriteService FavoriteService, c cache.Cache, cfg *config.CacheWarmConfig) CacheWarmService {
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultWarmConcurrency
+	}
+	return &cacheWarmService{
+		fileRepo:        fileRepo,
+		favoriteService: favoriteService,
+		cache:           c,
+		cfg:             cfg,
+		slots:           make(chan struct{}, concurrency),
+	}
+}
+
+func (s *cacheWarmService) Warm(ctx context.Context, userID uint64) bool {
+	if !s.cfg.Enabled {
+		return false
+	}
+	interval := defaultWarmInterval
+	if s.cfg.Interval > 0 {
+		interval = time.Duration(s.cfg.Interval) * time.Second
+	}
+	first, err := s.cache.SetNX(ctx, cache.GenerateCacheWarmKey(userID), time.Now().Unix(), interval)
+	if err != nil {
+		logger.Warn("Warm: Failed to set cache warm mark", zap.Uint64("userID", userID), zap.Error(err))
+		return false
+	}
+	if !first {
+		return false
+	}
+
+	// 请求结束后继续预热,但保留请求上下文中的追踪信息
+	warmCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), warmTimeout)
+	go func() {
+		defer cancel()
+		s.warm(warmCtx, userID)
+	}()
+	return true
+}
+
+// warm 先加载根目录,再按最近访问顺序加载最近使用的文件所在的文件夹。
+// 读取最近使用的文件时会逐个查询元数据,顺带填充元数据缓存
+func (s *cacheWarmService) warm(ctx context.Context, userID uint64) {
+	start := time.Now()
+	maxFolders := s.cfg.MaxFolders
+	if maxFolders <= 0 {
+		maxFolders = defaultWarmMaxFolders
+	}
+
+	type folderKey struct {
+		ownerID  uint64
+		folderID uint64
+	}
+	folders := []folderKey{{ownerID: userID}}
+	seen := map[folderKey]bool{{ownerID: userID}: true}
+
+	recent, err := s.favoriteService.ListRecent(ctx, userID, 0)
+	if err != nil {
+		logger.Warn("Warm: Failed to list recent files, only root folder is warmed", zap.Uint64("userID", userID), zap.Error(err))
+	}
+	for _, file := range recent {
+		if len(folders) >= maxFolders {
+			break
+		}
+		key := folderKey{ownerID: file.UserID}
+		if file.IsFolder == 1 {
+			key.folderID = file.ID
+		} else if file.ParentFolderID != nil {
+			key.folderID = *file.ParentFolderID
+		}
+		if !seen[key] {
+			seen[key] = true
+			folders = append(folders, key)
+		}
+	}
+
+	done := make(chan struct{}, len(folders))
+	for _, folder := range folders {
+		select {
+		case s.slots <- struct{}{}:
+		case <-ctx.Done():
+			logger.Warn("Warm: Cache warm-up timed out", zap.Uint64("userID", userID), zap.Int("folders", len(folders)))
+			return
+		}
+		go func() {
+			defer func() {
+				<-s.slots
+				done <- struct{}{}
+			}()
+			var parentFolderID *uint64
+			if folder.folderID != 0 {
+				parentFolderID = &folder.folderID
+			}
+			// 缓存未命中时会加载整个文件夹,返回的一页只是用来触发回源
+			opts := models.FileListOptions{Page: 1, PageSize: 1}
+			if _, _, err := s.fileRepo.FindByUserIDAndParentFolderID(ctx, folder.ownerID, parentFolderID, opts); err != nil {
+				logger.Warn("Warm: Failed to warm folder list", zap.Uint64("ownerID", folder.ownerID), zap.Uint64("folderID", folder.folderID), zap.Error(err))
+			}
+		}()
+	}
+	for range folders {
+		<-done
+	}
+
+	logger.Info("Warm: Cache warm-up finished",
+		zap.Uint64("userID", userID),
+		zap.Int("folders", len(folders)),
+		zap.Int("recentFiles", len(recent)),
+		zap.Duration("elapsed", time.Since(start)))
+}
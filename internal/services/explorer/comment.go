@@ -0,0 +1,200 @@
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/activity"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/authz"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxCommentLength 单条评论的最大字符数
+	maxCommentLength = 2000
+	// maxMentionsPerComment 单条评论最多通知的用户数,超出的提及只保留文本
+	maxMentionsPerComment = 10
+	// commentExcerptLength 活动日志中记录的评论摘要长度
+	commentExcerptLength = 200
+)
+
+// mentionPattern 匹配评论中的 @用户名,用户名前必须是开头或空白,避免误认邮箱地址
+var mentionPattern = regexp.MustCompile(`(?:^|\s)@([\p{L}\p{N}_.-]+)`)
+
+// CommentService 文件评论。能访问文件的用户(所有者和被授权的协作者)都可以查看和发表评论
+type CommentService interface {
+	// PostComment 发表评论,评论中 @ 到的能访问该文件的用户会在活动日志中收到提及记录
+	PostComment(ctx context.Context, userID uint64, fileID uint64, content string) (*models.FileComment, error)
+	// ListComments 分页列出文件上的评论,按发表时间从早到晚排列
+	ListComments(ctx context.Context, userID uint64, fileID uint64, page, pageSize int) ([]models.FileComment, int64, error)
+	// DeleteComment 删除评论,评论者本人和文件所有者可以删除
+	DeleteComment(ctx context.Context, userID uint64, fileID uint64, commentID uint64) error
+}
+
+type commentService struct {
+	commentRepo     repositories.FileCommentRepository
+	userRepo        repositories.UserRepository
+	domainService   FileDomainService
+	activityService activity.ActivityService
+	authorizer      authz.Authorizer
+}
+
+var _ CommentService = (*commentService)(nil)
+
+// NewCommentService 创建文件评论服务实例
+func NewCommentService(
+	commentRepo repositories.FileCommentRepository,
+	userRepo repositories.UserRepository,
+	domainService FileDomainService,
+	activityService activity.ActivityService,
+	authorizer authz.Authorizer,
+) CommentService {
+	return &commentService{
+		commentRepo:     commentRepo,
+		userRepo:        userRepo,
+		domainService:   domainService,
+		activityService: activityService,
+		authorizer:      authorizer,
+	}
+}
+
+func (s *commentService) PostComment(ctx context.Context, userID uint64, fileID uint64, content string) (*models.FileComment, error) {
+	content = strings.TrimSpace(content)
+	if content == "" || utf8.RuneCountInString(content) > maxCommentLength {
+		return nil, fmt.Errorf("comment service: comment must be 1 to %d characters: %w", maxCommentLength, xerr.ErrInvalidParams)
+	}
+
+	file, err := s.domainService.CheckFile(ctx, userID, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	comment := &models.FileComment{FileID: file.ID, UserID: userID, Content: content}
+	if err := s.commentRepo.Create(ctx, comment); err != nil {
+		logger.Error("PostComment: Failed to save comment", zap.Uint64("userID", userID), zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, fmt.Errorf("comment service: failed to save comment: %w", xerr.ErrDatabaseError)
+	}
+
+	excerpt := commentExcerpt(content)
+	s.activityService.Record(ctx, file.UserID, file.ID, models.ActivityComment, excerpt)
+	s.notifyMentions(ctx, userID, file, excerpt, content)
+
+	// 重新查询以带上评论者的用户名
+	saved, err := s.commentRepo.FindByID(ctx, comment.ID)
+	if err != nil {
+		logger.Warn("PostComment: Failed to reload comment", zap.Uint64("commentID", comment.ID), zap.Error(err))
+		return comment, nil
+	}
+	return saved, nil
+}
+
+func (s *commentService) ListComments(ctx context.Context, userID uint64, fileID uint64, page, pageSize int) ([]models.FileComment, int64, error) {
+	if _, err := s.domainService.CheckFile(ctx, userID, fileID); err != nil {
+		return nil, 0, err
+	}
+
+	comments, total, err := s.commentRepo.FindByFileID(ctx, fileID, page, pageSize)
+	if err != nil {
+		logger.Error("ListComments: Failed to list comments", zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, 0, fmt.Errorf("comment service: failed to list comments: %w", xerr.ErrDatabaseError)
+	}
+	return comments, total, nil
+}
+
+func (s *commentService) DeleteComment(ctx context.Context, userID uint64, fileID uint64, commentID uint64) error {
+	comment, err := s.commentRepo.FindByID(ctx, commentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("comment service: %w", xerr.ErrCommentNotFound)
+		}
+		logger.Error("DeleteComment: Failed to find comment", zap.Uint64("commentID", commentID), zap.Error(err))
+		return fmt.Errorf("comment service: failed to find comment: %w", xerr.ErrDatabaseError)
+	}
+	if comment.FileID != fileID {
+		return fmt.Errorf("comment service: comment %d is not on file %d: %w", commentID, fileID, xerr.ErrCommentNotFound)
+	}
+
+	file, err := s.domainService.CheckFile(ctx, userID, fileID)
+	if err != nil {
+		return err
+	}
+	// 评论者本人可以删除自己的评论,其他人的评论需要文件的管理权限
+	allowed, err := s.authorizer.Can(ctx, userID, authz.ActionDelete, authz.Record(comment.UserID))
+	if err == nil && !allowed {
+		allowed, err = s.authorizer.Can(ctx, userID, authz.ActionManage, authz.File(file))
+	}
+	if err != nil {
+		return fmt.Errorf("comment service: %w", err)
+	}
+	if !allowed {
+		logger.Warn("DeleteComment: Only the author or the file owner can delete a comment",
+			zap.Uint64("commentID", commentID), zap.Uint64("userID", userID))
+		return fmt.Errorf("comment service: %w", xerr.ErrPermissionDenied)
+	}
+
+	if err := s.commentRepo.Delete(ctx, commentID); err != nil {
+		logger.Error("DeleteComment: Failed to delete comment", zap.Uint64("commentID", commentID), zap.Error(err))
+		return fmt.Errorf("comment service: failed to delete comment: %w", xerr.ErrDatabaseError)
+	}
+	return nil
+}
+
+// notifyMentions 为评论中提及的用户记录活动日志,评论者自己和无权访问该文件的用户会被忽略
+func (s *commentService) notifyMentions(ctx context.Context, authorID uint64, file *models.File, excerpt string, content string) {
+	notified := make(map[uint64]bool)
+	for _, username := range parseMentions(content) {
+		if len(notified) >= maxMentionsPerComment {
+			return
+		}
+
+		user, err := s.userRepo.GetUserByUsername(ctx, username)
+		if err != nil {
+			if !errors.Is(err, xerr.ErrUserNotFound) {
+				logger.Error("notifyMentions: Failed to find mentioned user", zap.String("username", username), zap.Error(err))
+			}
+			continue
+		}
+		if user.ID == authorID || notified[user.ID] {
+			continue
+		}
+		if s.domainService.ValidateFile(ctx, user.ID, file) != nil {
+			continue
+		}
+
+		notified[user.ID] = true
+		s.activityService.Record(ctx, user.ID, file.ID, models.ActivityMention, excerpt)
+	}
+}
+
+// parseMentions 按出现顺序返回评论中 @ 到的用户名,重复的只保留一次
+func parseMentions(content string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		username := strings.TrimRight(match[1], ".-")
+		if username == "" || seen[username] {
+			continue
+		}
+		seen[username] = true
+		usernames = append(usernames, username)
+	}
+	return usernames
+}
+
+// commentExcerpt 截取评论开头作为活动日志的详情
+func commentExcerpt(content string) string {
+	if utf8.RuneCountInString(content) <= commentExcerptLength {
+		return content
+	}
+	runes := []rune(content)
+	return string(runes[:commentExcerptLength]) + "..."
+}
@@ -1,33 +1,43 @@
 package explorer
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
 
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
 	"github.com/3Eeeecho/go-clouddisk/internal/models"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/authz"
 	"go.uber.org/zap"
-	"gorm.io/gorm"
 )
 
 // FileDomainService 文件领域服务，处理文件相关的业务逻辑
 type FileDomainService interface {
-	// 文件验证
-	ValidateFile(userID uint64, file *models.File) error
-	ValidateFolder(userID uint64, folder *models.File) error
-	CheckFile(userID uint64, fileID uint64) (*models.File, error)
-	CheckDirectory(userID uint64, folderID *uint64) (*models.File, error)
-	CheckDeletedFile(userID uint64, fileID uint64) (*models.File, error)
+	// 文件验证,权限由 authz.Authorizer 判断
+	ValidateFile(ctx context.Context, userID uint64, file *models.File) error
+	ValidateFolder(ctx context.Context, userID uint64, folder *models.File) error
+	ValidateFileAccess(ctx context.Context, userID uint64, file *models.File, action authz.Action) error
+	CheckFile(ctx context.Context, userID uint64, fileID uint64) (*models.File, error)
+	CheckFileAccess(ctx context.Context, userID uint64, fileID uint64, action authz.Action) (*models.File, error)
+	CheckDirectory(ctx context.Context, userID uint64, folderID *uint64) (*models.File, error)
+	CheckWritableFile(ctx context.Context, userID uint64, fileID uint64) (*models.File, error)
+	CheckWritableDirectory(ctx context.Context, userID uint64, folderID *uint64) (*models.File, error)
+	CheckDeletedFile(ctx context.Context, userID uint64, fileID uint64) (*models.File, error)
 
 	// 文件名处理
-	ResolveFileNameConflict(userID uint64, parentFolderID *uint64, fileName string, currentFileID uint64, isFolder uint8) (string, error)
+	// NormalizeFileName 校验并规范化用户提交的文件名,用于上传、重命名和新建文件夹
+	NormalizeFileName(name string) (string, error)
+	// SanitizeFileName 将不合法的文件名修正为可用的名称,用于恢复旧文件
+	SanitizeFileName(name string) string
+	ResolveFileNameConflict(ctx context.Context, userID uint64, parentFolderID *uint64, fileName string, currentFileID uint64, isFolder uint8) (string, error)
 
 	// 文件收集
-	CollectAllNormalFiles(userID uint64, fileID uint64) ([]models.File, error)
-	CollectAllFiles(userID uint64, fileID uint64) ([]models.File, error)
-	collectChildrenRecursively(userID uint64, folderID uint64) ([]models.File, error)
+	CollectAllNormalFiles(ctx context.Context, userID uint64, fileID uint64) ([]models.File, error)
+	CollectAllFiles(ctx context.Context, userID uint64, fileID uint64) ([]models.File, error)
+	collectChildrenRecursively(ctx context.Context, userID uint64, folderID uint64) ([]models.File, error)
 
 	// 路径处理
 	GetRelativePathInZip(rootFolder *models.File, file *models.File) string
@@ -35,34 +45,39 @@ type FileDomainService interface {
 
 // FileRepository 接口，用于依赖注入
 type FileRepository interface {
-	FindByID(id uint64) (*models.File, error)
-	FindByUserIDAndParentFolderID(userID uint64, parentFolderID *uint64) ([]models.File, error)
-	FindChildrenByPathPrefix(userID uint64, pathPrefix string) ([]models.File, error)
+	FindByID(ctx context.Context, id uint64) (*models.File, error)
+	FindByUserIDAndParentFolderID(ctx context.Context, userID uint64, parentFolderID *uint64, opts models.FileListOptions) ([]models.File, int64, error)
+	FindDescendants(ctx context.Context, userID uint64, folderID uint64, includeDeleted bool) ([]models.File, error)
 }
 
 type fileDomainService struct {
-	fileRepo FileRepository
+	fileRepo   FileRepository
+	authorizer authz.Authorizer
+	nameCfg    config.FileNameConfig
 }
 
 // NewFileDomainService 创建文件领域服务实例
-func NewFileDomainService(fileRepo FileRepository) FileDomainService {
+func NewFileDomainService(fileRepo FileRepository, authorizer authz.Authorizer, nameCfg config.FileNameConfig) FileDomainService {
 	return &fileDomainService{
-		fileRepo: fileRepo,
+		fileRepo:   fileRepo,
+		authorizer: authorizer,
+		nameCfg:    nameCfg,
 	}
 }
 
-// ValidateFile 只检查文件状态和权限,不返回文件
-func (s *fileDomainService) ValidateFile(userID uint64, file *models.File) error {
+// ValidateFile 只检查文件状态和读权限,不返回文件
+func (s *fileDomainService) ValidateFile(ctx context.Context, userID uint64, file *models.File) error {
+	return s.ValidateFileAccess(ctx, userID, file, authz.ActionRead)
+}
+
+// ValidateFileAccess 检查文件状态,以及用户能否对文件执行 action
+func (s *fileDomainService) ValidateFileAccess(ctx context.Context, userID uint64, file *models.File, action authz.Action) error {
 	if file == nil {
 		return fmt.Errorf("domain service: %w", xerr.ErrFileNotFound)
 	}
 
-	if file.UserID != userID {
-		logger.Warn("File access denied",
-			zap.Uint64("fileID", file.ID),
-			zap.Uint64("userID", userID),
-			zap.Uint64("ownerID", file.UserID))
-		return fmt.Errorf("domain service: %w", xerr.ErrPermissionDenied)
+	if err := s.authorizer.Authorize(ctx, userID, action, authz.File(file)); err != nil {
+		return fmt.Errorf("domain service: %w", err)
 	}
 
 	if file.Status != 1 {
@@ -76,8 +91,8 @@ func (s *fileDomainService) ValidateFile(userID uint64, file *models.File) error
 }
 
 // ValidateFolder 只检查目录状态和权限,不返回目录文件
-func (s *fileDomainService) ValidateFolder(userID uint64, folder *models.File) error {
-	if err := s.ValidateFile(userID, folder); err != nil {
+func (s *fileDomainService) ValidateFolder(ctx context.Context, userID uint64, folder *models.File) error {
+	if err := s.ValidateFile(ctx, userID, folder); err != nil {
 		return err
 	}
 
@@ -88,11 +103,16 @@ func (s *fileDomainService) ValidateFolder(userID uint64, folder *models.File) e
 	return nil
 }
 
-// CheckFile 检查文件状态和权限,并返回正常状态的文件
-func (s *fileDomainService) CheckFile(userID uint64, fileID uint64) (*models.File, error) {
-	file, err := s.fileRepo.FindByID(fileID)
+// CheckFile 检查文件状态和读权限,并返回正常状态的文件
+func (s *fileDomainService) CheckFile(ctx context.Context, userID uint64, fileID uint64) (*models.File, error) {
+	return s.CheckFileAccess(ctx, userID, fileID, authz.ActionRead)
+}
+
+// CheckFileAccess 检查文件状态以及用户能否对文件执行 action,并返回正常状态的文件
+func (s *fileDomainService) CheckFileAccess(ctx context.Context, userID uint64, fileID uint64, action authz.Action) (*models.File, error) {
+	file, err := s.fileRepo.FindByID(ctx, fileID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+		if errors.Is(err, xerr.ErrFileNotFound) {
 			logger.Warn("CheckFile: File not found in DB", zap.Uint64("fileID", fileID))
 			return nil, fmt.Errorf("domain service: %w", xerr.ErrFileNotFound)
 		}
@@ -100,7 +120,7 @@ func (s *fileDomainService) CheckFile(userID uint64, fileID uint64) (*models.Fil
 		return nil, fmt.Errorf("domain service: failed to retrieve file: %w", xerr.ErrDatabaseError)
 	}
 
-	if err := s.ValidateFile(userID, file); err != nil {
+	if err := s.ValidateFileAccess(ctx, userID, file, action); err != nil {
 		return nil, err
 	}
 
@@ -108,13 +128,13 @@ func (s *fileDomainService) CheckFile(userID uint64, fileID uint64) (*models.Fil
 }
 
 // CheckDirectory 检查目录状态和权限,并返回正常状态的目录
-func (s *fileDomainService) CheckDirectory(userID uint64, folderID *uint64) (*models.File, error) {
+func (s *fileDomainService) CheckDirectory(ctx context.Context, userID uint64, folderID *uint64) (*models.File, error) {
 	//如果是根目录,无需检查直接返回
 	if folderID == nil {
 		return nil, nil
 	}
 
-	folder, err := s.CheckFile(userID, *folderID)
+	folder, err := s.CheckFile(ctx, userID, *folderID)
 	if err != nil {
 		return nil, err
 	}
@@ -126,11 +146,30 @@ func (s *fileDomainService) CheckDirectory(userID uint64, folderID *uint64) (*mo
 	return folder, nil
 }
 
+// CheckWritableFile 检查文件状态和写权限,并返回正常状态的文件
+func (s *fileDomainService) CheckWritableFile(ctx context.Context, userID uint64, fileID uint64) (*models.File, error) {
+	return s.CheckFileAccess(ctx, userID, fileID, authz.ActionWrite)
+}
+
+// CheckWritableDirectory 检查目录状态和写权限,根目录始终属于当前用户
+func (s *fileDomainService) CheckWritableDirectory(ctx context.Context, userID uint64, folderID *uint64) (*models.File, error) {
+	folder, err := s.CheckDirectory(ctx, userID, folderID)
+	if err != nil || folder == nil {
+		return folder, err
+	}
+
+	if err := s.ValidateFileAccess(ctx, userID, folder, authz.ActionWrite); err != nil {
+		return nil, err
+	}
+
+	return folder, nil
+}
+
 // CheckDeletedFile 检查并返回已经被软删除的文件
-func (s *fileDomainService) CheckDeletedFile(userID uint64, fileID uint64) (*models.File, error) {
-	file, err := s.fileRepo.FindByID(fileID)
+func (s *fileDomainService) CheckDeletedFile(ctx context.Context, userID uint64, fileID uint64) (*models.File, error) {
+	file, err := s.fileRepo.FindByID(ctx, fileID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+		if errors.Is(err, xerr.ErrFileNotFound) {
 			logger.Warn("CheckDeletedFile: File not found", zap.Uint64("fileID", fileID), zap.Uint64("userID", userID))
 			return nil, fmt.Errorf("domain service: %w", xerr.ErrFileNotFound)
 		}
@@ -138,10 +177,9 @@ func (s *fileDomainService) CheckDeletedFile(userID uint64, fileID uint64) (*mod
 		return nil, fmt.Errorf("domain service: failed to retrieve file: %w", xerr.ErrDatabaseError)
 	}
 
-	// 权限检查
-	if file.UserID != userID {
-		logger.Warn("CheckDeletedFile: Access denied", zap.Uint64("fileID", file.ID), zap.Uint64("userID", userID), zap.Uint64("ownerID", file.UserID))
-		return nil, fmt.Errorf("domain service: %w", xerr.ErrPermissionDenied)
+	// 回收站中的文件不适用协作授权,只有所有者可以查看和还原
+	if err := s.authorizer.Authorize(ctx, userID, authz.ActionRead, authz.Record(file.UserID)); err != nil {
+		return nil, fmt.Errorf("domain service: %w", err)
 	}
 
 	// 检查文件是否在回收站中
@@ -154,13 +192,13 @@ func (s *fileDomainService) CheckDeletedFile(userID uint64, fileID uint64) (*mod
 }
 
 // ResolveFileNameConflict 解决文件名冲突
-func (s *fileDomainService) ResolveFileNameConflict(userID uint64, parentFolderID *uint64, fileName string, currentFileID uint64, isFolder uint8) (string, error) {
+func (s *fileDomainService) ResolveFileNameConflict(ctx context.Context, userID uint64, parentFolderID *uint64, fileName string, currentFileID uint64, isFolder uint8) (string, error) {
 	if fileName == "" {
 		return "", fmt.Errorf("domain service: %w", xerr.ErrFileNameInvalid)
 	}
 
 	// 获取同级文件列表
-	siblingFiles, err := s.fileRepo.FindByUserIDAndParentFolderID(userID, parentFolderID)
+	siblingFiles, _, err := s.fileRepo.FindByUserIDAndParentFolderID(ctx, userID, parentFolderID, models.FileListOptions{})
 	if err != nil {
 		logger.Error("ResolveFileNameConflict: Failed to get sibling files",
 			zap.Uint64("userID", userID),
@@ -225,8 +263,8 @@ func (s *fileDomainService) ResolveFileNameConflict(userID uint64, parentFolderI
 	}
 }
 
-func (s *fileDomainService) CollectAllNormalFiles(userID uint64, fileID uint64) ([]models.File, error) {
-	allFiles, err := s.CollectAllFiles(userID, fileID)
+func (s *fileDomainService) CollectAllNormalFiles(ctx context.Context, userID uint64, fileID uint64) ([]models.File, error) {
+	allFiles, err := s.CollectAllFiles(ctx, userID, fileID)
 	if err != nil {
 		return nil, err
 	}
@@ -240,7 +278,7 @@ func (s *fileDomainService) CollectAllNormalFiles(userID uint64, fileID uint64)
 
 		// 验证文件所有权和状态
 		// ValidateFile 应该检查文件是否属于用户，以及是否被软删除
-		if err := s.ValidateFile(userID, &file); err != nil {
+		if err := s.ValidateFile(ctx, userID, &file); err != nil {
 			// 如果文件不可用，记录警告并跳过，而不是返回错误
 			logger.Warn("CollectAllNormalFiles: File is not available, skipping",
 				zap.Uint64("fileID", file.ID),
@@ -257,11 +295,11 @@ func (s *fileDomainService) CollectAllNormalFiles(userID uint64, fileID uint64)
 
 // 优化后的收集子文件方法
 // 递归地获取一个文件夹下的所有文件和子文件夹,包括文件自身
-func (s *fileDomainService) CollectAllFiles(userID uint64, fileID uint64) ([]models.File, error) {
+func (s *fileDomainService) CollectAllFiles(ctx context.Context, userID uint64, fileID uint64) ([]models.File, error) {
 	// 验证权限并获取根文件
-	rootFile, err := s.fileRepo.FindByID(fileID)
+	rootFile, err := s.fileRepo.FindByID(ctx, fileID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+		if errors.Is(err, xerr.ErrFileNotFound) {
 			logger.Warn("CollectAllFiles: Root file not found", zap.Uint64("fileID", fileID))
 			return nil, fmt.Errorf("domain service: %w", xerr.ErrFileNotFound)
 		}
@@ -274,7 +312,7 @@ func (s *fileDomainService) CollectAllFiles(userID uint64, fileID uint64) ([]mod
 
 	// 如果是文件夹，收集所有子项
 	if rootFile.IsFolder == 1 {
-		children, err := s.collectChildrenRecursively(userID, fileID)
+		children, err := s.collectChildrenRecursively(ctx, userID, fileID)
 		if err != nil {
 			return nil, err // 错误已在下层包裹
 		}
@@ -284,41 +322,16 @@ func (s *fileDomainService) CollectAllFiles(userID uint64, fileID uint64) ([]mod
 	return allFiles, nil
 }
 
-// 递归收集子文件（优化版本）
-func (s *fileDomainService) collectChildrenRecursively(userID uint64, folderID uint64) ([]models.File, error) {
-	var allChildren []models.File
-	// 使用队列进行BFS，避免深层递归
-	queue := []uint64{folderID}
-	processed := make(map[uint64]bool)
-	processed[folderID] = true
-
-	for len(queue) > 0 {
-		currentID := queue[0]
-		queue = queue[1:]
-
-		// 获取当前文件夹的子项
-		children, err := s.fileRepo.FindByUserIDAndParentFolderID(userID, &currentID)
-		if err != nil {
-			logger.Error("collectChildrenRecursively: Failed to get children",
-				zap.Uint64("folderID", currentID),
-				zap.Error(err))
-			return nil, fmt.Errorf("domain service: failed to get children of folder %d: %w", currentID, xerr.ErrDatabaseError)
-		}
-
-		for _, child := range children {
-			if !processed[child.ID] {
-				allChildren = append(allChildren, child)
-				processed[child.ID] = true
-
-				// 如果是文件夹，加入队列继续处理
-				if child.IsFolder == 1 {
-					queue = append(queue, child.ID)
-				}
-			}
-		}
+// collectChildrenRecursively 一次查询获取文件夹下的所有子文件和子文件夹,父文件夹总是排在子项之前
+func (s *fileDomainService) collectChildrenRecursively(ctx context.Context, userID uint64, folderID uint64) ([]models.File, error) {
+	children, err := s.fileRepo.FindDescendants(ctx, userID, folderID, false)
+	if err != nil {
+		logger.Error("collectChildrenRecursively: Failed to get descendants",
+			zap.Uint64("folderID", folderID),
+			zap.Error(err))
+		return nil, fmt.Errorf("domain service: failed to get children of folder %d: %w", folderID, xerr.ErrDatabaseError)
 	}
-
-	return allChildren, nil
+	return children, nil
 }
 
 // GetRelativePathInZip 获取文件在ZIP中的相对路径
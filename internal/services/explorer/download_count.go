@@ -0,0 +1,172 @@
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	// downloadCountsPendingKey 下载时累加计数的 Redis Hash,字段为 "文件ID:存储版本ID"
+	downloadCountsPendingKey = "download_counts:pending"
+	// downloadCountsFlushingKey 正在写入数据库的计数,写入成功后删除,失败时下次继续写入
+	downloadCountsFlushingKey = "download_counts:flushing"
+	// downloadCountsLockKey 多个实例同时写入时只有一个实例执行
+	downloadCountsLockKey = "download_counts:flush_lock"
+	downloadCountsLockTTL = 5 * time.Minute
+)
+
+// takeDownloadCountsScript 把累加中的计数移到待写入的 key,上次写入失败留下的计数优先写入
+var takeDownloadCountsScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[2]) == 1 then
+	return 1
+end
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('RENAME', KEYS[1], KEYS[2])
+return 1
+`)
+
+// DownloadCountService 按文件和存储版本统计下载次数。下载时只在 Redis 中累加,由 Worker 定期写入数据库,
+// 查询返回的是数据库中的次数,最多比实际少一个写入周期内的下载
+type DownloadCountService interface {
+	// Record 记录一次下载,versionID 为下载内容的存储版本,文件夹打包下载为空。失败只记录日志
+	Record(ctx context.Context, fileID uint64, versionID string)
+	// Flush 把 Redis 中累加的计数写入数据库,返回写入的记录数
+	Flush(ctx context.Context) (int, error)
+	// GetDownloads 返回文件的累计下载次数,没有下载过的文件不出现在结果中
+	GetDownloads(ctx context.Context, fileIDs []uint64) (map[uint64]int64, error)
+	// GetVersionDownloads 返回文件各存储版本的下载次数
+	GetVersionDownloads(ctx context.Context, fileID uint64) (map[string]int64, error)
+}
+
+type downloadCountService struct {
+	countRepo repositories.FileDownloadCountRepository
+	cache     *cache.RedisCache
+}
+
+var _ DownloadCountService = (*downloadCountService)(nil)
+
+// NewDownloadCountService 创建下载次数统计服务实例
+func NewDownloadCountService(countRepo repositories.FileDownloadCountRepository, redisCache *cache.RedisCache) DownloadCountService {
+	return &downloadCountService{
+		countRepo: countRepo,
+		cache:     redisCache,
+	}
+}
+
+func (s *downloadCountService) Record(ctx context.Context, fileID uint64, versionID string) {
+	field := strconv.FormatUint(fileID, 10) + ":" + versionID
+	if err := s.cache.HIncrBy(ctx, downloadCountsPendingKey, field, 1); err != nil {
+		logger.Warn("RecordDownload: Failed to increment download count", zap.Uint64("fileID", fileID), zap.Error(err))
+	}
+}
+
+func (s *downloadCountService) Flush(ctx context.Context) (int, error) {
+	locked, err := s.cache.SetNX(ctx, downloadCountsLockKey, 1, downloadCountsLockTTL)
+	if err != nil {
+		return 0, fmt.Errorf("download count service: failed to acquire flush lock: %w", err)
+	}
+	if !locked {
+		return 0, nil
+	}
+	defer func() { _ = s.cache.Del(context.WithoutCancel(ctx), downloadCountsLockKey) }()
+
+	taken, err := s.cache.RunScript(ctx, takeDownloadCountsScript, []string{downloadCountsPendingKey, downloadCountsFlushingKey}).Int()
+	if err != nil {
+		return 0, fmt.Errorf("download count service: failed to take pending counts: %w", err)
+	}
+	if taken == 0 {
+		return 0, nil
+	}
+
+	fields, err := s.cache.HGetAll(ctx, downloadCountsFlushingKey)
+	if err != nil {
+		if errors.Is(err, cache.ErrCacheMiss) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("download count service: failed to read pending counts: %w", err)
+	}
+
+	counts := make([]models.FileDownloadCount, 0, len(fields))
+	now := time.Now()
+	for field, value := range fields {
+		rawID, versionID, _ := strings.Cut(field, ":")
+		fileID, err := strconv.ParseUint(rawID, 10, 64)
+		if err != nil {
+			logger.Warn("FlushDownloadCounts: Skipping malformed field", zap.String("field", field))
+			continue
+		}
+		downloads, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || downloads <= 0 {
+			continue
+		}
+		counts = append(counts, models.FileDownloadCount{FileID: fileID, VersionID: versionID, Downloads: downloads, UpdatedAt: now})
+	}
+
+	// 写入失败时保留待写入的计数,下次继续写入;写入成功但删除失败时这批计数会被重复累加
+	if err := s.countRepo.Add(ctx, counts); err != nil {
+		logger.Error("FlushDownloadCounts: Failed to save download counts", zap.Int("count", len(counts)), zap.Error(err))
+		return 0, fmt.Errorf("download count service: %w", xerr.ErrDatabaseError)
+	}
+	if err := s.cache.Del(ctx, downloadCountsFlushingKey); err != nil {
+		logger.Error("FlushDownloadCounts: Failed to clear flushed counts", zap.Error(err))
+	}
+	return len(counts), nil
+}
+
+func (s *downloadCountService) GetDownloads(ctx context.Context, fileIDs []uint64) (map[uint64]int64, error) {
+	counts, err := s.countRepo.FindByFileIDs(ctx, fileIDs)
+	if err != nil {
+		logger.Error("GetDownloads: Failed to query download counts", zap.Int("count", len(fileIDs)), zap.Error(err))
+		return nil, fmt.Errorf("download count service: %w", xerr.ErrDatabaseError)
+	}
+	downloads := make(map[uint64]int64, len(fileIDs))
+	for _, count := range counts {
+		downloads[count.FileID] += count.Downloads
+	}
+	return downloads, nil
+}
+
+func (s *downloadCountService) GetVersionDownloads(ctx context.Context, fileID uint64) (map[string]int64, error) {
+	counts, err := s.countRepo.FindByFileIDs(ctx, []uint64{fileID})
+	if err != nil {
+		logger.Error("GetVersionDownloads: Failed to query download counts", zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, fmt.Errorf("download count service: %w", xerr.ErrDatabaseError)
+	}
+	downloads := make(map[string]int64, len(counts))
+	for _, count := range counts {
+		downloads[count.VersionID] = count.Downloads
+	}
+	return downloads, nil
+}
+
+// fillDownloads 填充文件的累计下载次数,查询失败时保持为 0,不影响元数据查询
+func (s *fileService) fillDownloads(ctx context.Context, files []models.File) {
+	if len(files) == 0 {
+		return
+	}
+	ids := make([]uint64, len(files))
+	for i := range files {
+		ids[i] = files[i].ID
+	}
+	downloads, err := s.downloads.GetDownloads(ctx, ids)
+	if err != nil {
+		return
+	}
+	for i := range files {
+		files[i].Downloads = downloads[files[i].ID]
+	}
+}
@@ -0,0 +1,406 @@
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/storage"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/activity"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ExportQueueName 账户数据导出任务队列名称
+const ExportQueueName = "data_export_queue"
+
+const (
+	// maxListedExports 导出列表返回的最近任务数
+	maxListedExports = 20
+	// maxExportErrorLength 保存到导出记录中的错误信息长度上限
+	maxExportErrorLength = 512
+)
+
+// ExportService 账户数据导出(takeout): 异步把用户的整个目录树或回收站打包成一个或多个 ZIP 分卷,
+// 暂存在存储桶中,完成后通过活动日志通知,用户凭限时链接下载
+type ExportService interface {
+	// RequestExport 创建导出任务并投递给 Worker,同一用户同时只能有一个进行中的导出
+	RequestExport(ctx context.Context, userID uint64, scope string) (*models.DataExport, error)
+	// ListExports 列出用户最近的导出任务
+	ListExports(ctx context.Context, userID uint64) ([]models.DataExport, error)
+	// GetExport 返回导出任务,已完成且未过期时为每个分卷生成限时下载链接
+	GetExport(ctx context.Context, userID uint64, exportID uint64) (*models.DataExport, error)
+	// ProcessExport 打包并上传导出任务的 ZIP 分卷,由导出 Worker 调用
+	ProcessExport(ctx context.Context, exportID uint64) error
+	// ExpireExports 删除 before 之前过期的导出分卷,返回处理的任务数
+	ExpireExports(ctx context.Context, before time.Time, limit int) (int, error)
+}
+
+type exportService struct {
+	exportRepo    repositories.DataExportRepository
+	fileRepo      repositories.FileRepository
+	activityRepo  repositories.ActivityRepository
+	fileService   FileService
+	storage       storage.StorageService
+	tm            TransactionManager
+	notifications activity.NotificationService
+	cfg           *config.Config
+}
+
+var _ ExportService = (*exportService)(nil)
+
+// NewExportService 创建账户数据导出服务实例
+func NewExportService(
+	exportRepo repositories.DataExportRepository,
+	fileRepo repositories.FileRepository,
+	activityRepo repositories.ActivityRepository,
+	fileService FileService,
+	storageService storage.StorageService,
+	tm TransactionManager,
+	notifications activity.NotificationService,
+	cfg *config.Config,
+) ExportService {
+	return &exportService{
+		exportRepo:    exportRepo,
+		fileRepo:      fileRepo,
+		activityRepo:  activityRepo,
+		fileService:   fileService,
+		storage:       storageService,
+		tm:            tm,
+		notifications: notifications,
+		cfg:           cfg,
+	}
+}
+
+func (s *exportService) RequestExport(ctx context.Context, userID uint64, scope string) (*models.DataExport, error) {
+	if scope == "" {
+		scope = models.ExportScopeAll
+	}
+	if scope != models.ExportScopeAll && scope != models.ExportScopeRecycleBin {
+		return nil, fmt.Errorf("export service: unknown scope %q: %w", scope, xerr.ErrInvalidParams)
+	}
+
+	export := &models.DataExport{
+		UserID:    userID,
+		Scope:     scope,
+		Status:    models.ExportStatusPending,
+		OssBucket: s.cfg.DefaultBucketName(),
+	}
+	// 统计和创建在同一事务中,CountActive 锁定用户记录,并发的请求不会同时通过检查
+	err := s.tm.WithTransaction(ctx, func(tx *gorm.DB) error {
+		exportRepo := repositories.NewDataExportRepository(tx)
+		active, err := exportRepo.CountActive(userID)
+		if err != nil {
+			logger.Error("RequestExport: Failed to count active exports", zap.Uint64("userID", userID), zap.Error(err))
+			return fmt.Errorf("export service: failed to count active exports: %w", xerr.ErrDatabaseError)
+		}
+		if active > 0 {
+			return fmt.Errorf("export service: %w", xerr.ErrExportInProgress)
+		}
+		if err := exportRepo.Create(export); err != nil {
+			logger.Error("RequestExport: Failed to create export", zap.Uint64("userID", userID), zap.Error(err))
+			return fmt.Errorf("export service: failed to create export: %w", xerr.ErrDatabaseError)
+		}
+		// 任务消息与导出记录一起提交,创建成功的导出一定会被处理
+		event, err := models.NewQueueEvent(ExportQueueName, models.ExportTask{ExportID: export.ID, UserID: userID})
+		if err == nil {
+			err = repositories.NewOutboxRepository(tx).Add(ctx, event)
+		}
+		if err != nil {
+			logger.Error("RequestExport: Failed to publish export task", zap.Uint64("userID", userID), zap.Error(err))
+			return fmt.Errorf("export service: failed to publish export task: %w", xerr.ErrDatabaseError)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("RequestExport: Export requested", zap.Uint64("userID", userID), zap.Uint64("exportID", export.ID), zap.String("scope", scope))
+	return export, nil
+}
+
+func (s *exportService) ListExports(ctx context.Context, userID uint64) ([]models.DataExport, error) {
+	exports, err := s.exportRepo.FindByUserID(userID, maxListedExports)
+	if err != nil {
+		logger.Error("ListExports: Failed to list exports", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("export service: failed to list exports: %w", xerr.ErrDatabaseError)
+	}
+	return exports, nil
+}
+
+func (s *exportService) GetExport(ctx context.Context, userID uint64, exportID uint64) (*models.DataExport, error) {
+	export, err := s.exportRepo.FindByID(exportID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrExportNotFound) {
+			return nil, fmt.Errorf("export service: %w", xerr.ErrExportNotFound)
+		}
+		logger.Error("GetExport: Failed to get export", zap.Uint64("exportID", exportID), zap.Error(err))
+		return nil, fmt.Errorf("export service: failed to get export: %w", xerr.ErrDatabaseError)
+	}
+	if export.UserID != userID {
+		return nil, fmt.Errorf("export service: %w", xerr.ErrExportNotFound)
+	}
+	if export.Status != models.ExportStatusReady || export.ExpiresAt == nil {
+		return export, nil
+	}
+
+	// 链接有效期不超过导出的剩余保留时间,过期后对象会被清理
+	remaining := time.Until(*export.ExpiresAt)
+	if remaining <= 0 {
+		return nil, fmt.Errorf("export service: %w", xerr.ErrExportNotFound)
+	}
+	expiry := min(time.Duration(s.cfg.Export.URLExpiry)*time.Minute, remaining)
+	if expiry <= 0 {
+		expiry = remaining
+	}
+	for i := range export.Parts {
+		part := &export.Parts[i]
+		url, err := s.storage.GeneratePresignedURL(ctx, export.OssBucket, part.OssKey, part.VersionID, expiry, storage.PresignOptions{})
+		if err != nil {
+			logger.Error("GetExport: Failed to generate download URL", zap.Uint64("exportID", exportID), zap.Int("part", part.PartNumber), zap.Error(err))
+			return nil, fmt.Errorf("export service: failed to generate download url: %w", xerr.ErrStorageError)
+		}
+		part.URL = url
+	}
+	return export, nil
+}
+
+func (s *exportService) ProcessExport(ctx context.Context, exportID uint64) error {
+	export, err := s.exportRepo.FindByID(exportID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrExportNotFound) {
+			return nil
+		}
+		return fmt.Errorf("export service: failed to get export: %w", err)
+	}
+	// 处理中的任务说明上次处理时 Worker 异常退出,消息重新投递后重新打包
+	if export.Status != models.ExportStatusPending && export.Status != models.ExportStatusProcessing {
+		return nil
+	}
+	if len(export.Parts) > 0 {
+		for _, part := range export.Parts {
+			s.removePart(ctx, export, part)
+		}
+		if err := s.exportRepo.DeleteParts(export.ID); err != nil {
+			return fmt.Errorf("export service: failed to delete stale parts: %w", err)
+		}
+		export.Parts = nil
+	}
+
+	export.Status = models.ExportStatusProcessing
+	if err := s.exportRepo.Update(export); err != nil {
+		return fmt.Errorf("export service: failed to mark export processing: %w", err)
+	}
+
+	entries, totalSize, fileCount, err := s.collectEntries(ctx, export)
+	if err != nil {
+		s.fail(export, err)
+		return err
+	}
+
+	for i, partEntries := range splitZipEntries(entries, s.cfg.Export.PartSize) {
+		part, err := s.writePart(ctx, export, i+1, partEntries)
+		if err != nil {
+			for _, uploaded := range export.Parts {
+				s.removePart(ctx, export, uploaded)
+			}
+			if len(export.Parts) > 0 {
+				if delErr := s.exportRepo.DeleteParts(export.ID); delErr != nil {
+					logger.Warn("ProcessExport: Failed to delete part records", zap.Uint64("exportID", export.ID), zap.Error(delErr))
+				}
+				export.Parts = nil
+			}
+			s.fail(export, err)
+			return err
+		}
+		export.Parts = append(export.Parts, *part)
+	}
+
+	expiresAt := time.Now().Add(time.Duration(s.cfg.Export.TTL) * time.Hour)
+	export.Status = models.ExportStatusReady
+	export.FileCount = fileCount
+	export.TotalSize = totalSize
+	export.ExpiresAt = &expiresAt
+	if err := s.exportRepo.Update(export); err != nil {
+		return fmt.Errorf("export service: failed to mark export ready: %w", err)
+	}
+
+	// 活动日志即导出完成的通知,客户端据此提示用户下载
+	activity := &models.Activity{
+		UserID:    export.UserID,
+		Action:    models.ActivityExportReady,
+		Detail:    fmt.Sprintf("export %d: %d files in %d parts", export.ID, fileCount, len(export.Parts)),
+		CreatedAt: time.Now(),
+	}
+	if err := s.activityRepo.Create(ctx, activity); err != nil {
+		logger.Error("ProcessExport: Failed to record export activity", zap.Uint64("exportID", export.ID), zap.Error(err))
+	}
+
+	s.notifications.Notify(ctx, &models.Notification{
+		UserID:     export.UserID,
+		Type:       models.NotificationExportReady,
+		Title:      "账户数据导出完成",
+		Body:       fmt.Sprintf("共 %d 个文件,分为 %d 个压缩包,请在过期前下载", fileCount, len(export.Parts)),
+		ResourceID: &export.ID,
+	})
+
+	logger.Info("ProcessExport: Export ready",
+		zap.Uint64("exportID", export.ID),
+		zap.Uint64("userID", export.UserID),
+		zap.Int64("files", fileCount),
+		zap.Int("parts", len(export.Parts)))
+	return nil
+}
+
+func (s *exportService) ExpireExports(ctx context.Context, before time.Time, limit int) (int, error) {
+	exports, err := s.exportRepo.FindExpired(before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("export service: failed to find expired exports: %w", err)
+	}
+
+	for i := range exports {
+		export := &exports[i]
+		for _, part := range export.Parts {
+			s.removePart(ctx, export, part)
+		}
+		export.Status = models.ExportStatusExpired
+		if err := s.exportRepo.Update(export); err != nil {
+			return i, fmt.Errorf("export service: failed to mark export %d expired: %w", export.ID, err)
+		}
+	}
+	return len(exports), nil
+}
+
+// collectEntries 按导出范围收集文件,返回按完整路径排序的 ZIP 条目、文件总大小和文件数
+func (s *exportService) collectEntries(ctx context.Context, export *models.DataExport) ([]zipEntry, uint64, int64, error) {
+	var files []models.File
+	var status uint8
+	var err error
+	if export.Scope == models.ExportScopeRecycleBin {
+		files, err = s.fileRepo.FindDeletedFilesByUserID(ctx, export.UserID, models.TrashListOptions{})
+		status = models.StatusDeleted
+	} else {
+		files, err = s.fileRepo.FindAllByUserID(ctx, export.UserID)
+		status = models.StatusNormal
+	}
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to collect files: %w", err)
+	}
+
+	var entries []zipEntry
+	var totalSize uint64
+	var fileCount int64
+	for i := range files {
+		file := &files[i]
+		if file.Status != status {
+			continue
+		}
+		entries = append(entries, zipEntry{Name: strings.TrimPrefix(file.Path+file.FileName, "/"), File: file})
+		if file.IsFolder == 0 {
+			totalSize += file.Size
+			fileCount++
+		}
+	}
+	// 父文件夹的路径是子项路径的前缀,排序后目录项总在其内容之前
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, totalSize, fileCount, nil
+}
+
+// splitZipEntries 按文件内容大小把条目拆分为多个分卷,单个超过上限的文件独占一个分卷。partSize <= 0 表示不分卷
+func splitZipEntries(entries []zipEntry, partSize int64) [][]zipEntry {
+	if partSize <= 0 || len(entries) == 0 {
+		return [][]zipEntry{entries}
+	}
+
+	var parts [][]zipEntry
+	var current []zipEntry
+	var currentSize int64
+	for _, entry := range entries {
+		size := int64(entry.File.Size)
+		if entry.File.IsFolder == 0 && len(current) > 0 && currentSize+size > partSize {
+			parts = append(parts, current)
+			current, currentSize = nil, 0
+		}
+		current = append(current, entry)
+		if entry.File.IsFolder == 0 {
+			currentSize += size
+		}
+	}
+	return append(parts, current)
+}
+
+// writePart 先把分卷写入临时文件,得到确切大小后再上传,部分存储后端不支持未知长度的上传
+func (s *exportService) writePart(ctx context.Context, export *models.DataExport, partNumber int, entries []zipEntry) (*models.DataExportPart, error) {
+	tmp, err := os.CreateTemp(s.cfg.Export.TempDir, fmt.Sprintf("export-%d-*.zip", export.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	prefetcher := newZipPrefetcher(s.fileService.GetFileContentReader, s.cfg.Download.ZipPrefetch, s.cfg.Download.ZipPrefetchMemory)
+	if err := writeZipArchive(ctx, tmp, entries, prefetcher, false); err != nil {
+		return nil, fmt.Errorf("failed to write part %d: %w", partNumber, err)
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get size of part %d: %w", partNumber, err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind part %d: %w", partNumber, err)
+	}
+
+	objectName := fmt.Sprintf("exports/%d/%d/takeout-part%03d.zip", export.UserID, export.ID, partNumber)
+	result, err := s.storage.PutObject(ctx, export.OssBucket, objectName, tmp, size, "application/zip")
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+	}
+
+	part := &models.DataExportPart{
+		ExportID:   export.ID,
+		PartNumber: partNumber,
+		OssKey:     objectName,
+		VersionID:  result.VersionID,
+		Size:       size,
+	}
+	if err := s.exportRepo.CreatePart(part); err != nil {
+		_ = s.storage.RemoveObject(ctx, export.OssBucket, objectName, result.VersionID)
+		return nil, fmt.Errorf("failed to save part %d: %w", partNumber, err)
+	}
+	logger.Info("ProcessExport: Export part uploaded", zap.Uint64("exportID", export.ID), zap.Int("part", partNumber), zap.Int64("size", size))
+	return part, nil
+}
+
+// removePart 删除分卷对应的存储对象,失败只记录日志
+func (s *exportService) removePart(ctx context.Context, export *models.DataExport, part models.DataExportPart) {
+	if err := s.storage.RemoveObject(ctx, export.OssBucket, part.OssKey, part.VersionID); err != nil {
+		logger.Warn("Failed to remove export part", zap.Uint64("exportID", export.ID), zap.String("ossKey", part.OssKey), zap.Error(err))
+	}
+}
+
+// fail 把导出任务标记为失败并保存错误信息
+func (s *exportService) fail(export *models.DataExport, cause error) {
+	logger.Error("Export failed", zap.Uint64("exportID", export.ID), zap.Uint64("userID", export.UserID), zap.Error(cause))
+	message := cause.Error()
+	if len(message) > maxExportErrorLength {
+		message = strings.ToValidUTF8(message[:maxExportErrorLength], "")
+	}
+	export.Status = models.ExportStatusFailed
+	export.Error = message
+	if err := s.exportRepo.Update(export); err != nil {
+		logger.Error("Failed to mark export failed", zap.Uint64("exportID", export.ID), zap.Error(err))
+	}
+}
@@ -0,0 +1,705 @@
+package explorer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/storage"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/activity"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/authz"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ExtractQueueName 压缩包解压任务队列名称
+const ExtractQueueName = "archive_extract_queue"
+
+const (
+	defaultExtractMaxEntries   = 10000
+	defaultExtractMaxTotalSize = 10 << 30 // 10GB
+	defaultExtractMaxRatio     = 100
+	// extractRatioFloor 解压后不超过该大小的 ZIP 条目不检查压缩比,高度重复的小文本文件压缩比很容易超过上限
+	extractRatioFloor = 1 << 20
+	// extractProgressInterval 每处理多少个条目保存一次进度
+	extractProgressInterval = 50
+)
+
+// ExtractService 在服务端解压网盘中的 ZIP 或 TAR 压缩包: Worker 从存储中读取压缩包,
+// 在目标文件夹下新建以压缩包命名的文件夹,逐个创建其中的文件夹和文件
+type ExtractService interface {
+	// RequestExtract 创建解压任务并投递给 Worker,targetFolderID 为空时解压到压缩包所在的文件夹。
+	// 同一压缩包已有进行中的任务时直接返回该任务
+	RequestExtract(ctx context.Context, userID uint64, fileID uint64, targetFolderID *uint64) (*models.ExtractJob, error)
+	// GetExtract 返回解压任务及其进度
+	GetExtract(ctx context.Context, userID uint64, jobID uint64) (*models.ExtractJob, error)
+	// ProcessExtract 解压压缩包,由解压 Worker 调用
+	ProcessExtract(ctx context.Context, jobID uint64) error
+}
+
+type extractService struct {
+	extractRepo   repositories.ExtractJobRepository
+	fileRepo      repositories.FileRepository
+	statsRepo     repositories.FileStatsRepository
+	domainService FileDomainService
+	tm            TransactionManager
+	storage       storage.StorageService
+	notifications activity.NotificationService
+	deps          UploadServiceDeps
+}
+
+var _ ExtractService = (*extractService)(nil)
+
+// NewExtractService 创建压缩包解压服务实例
+func NewExtractService(
+	extractRepo repositories.ExtractJobRepository,
+	fileRepo repositories.FileRepository,
+	statsRepo repositories.FileStatsRepository,
+	domainService FileDomainService,
+	tm TransactionManager,
+	storageService storage.StorageService,
+	notifications activity.NotificationService,
+	deps UploadServiceDeps,
+) ExtractService {
+	return &extractService{
+		extractRepo:   extractRepo,
+		fileRepo:      fileRepo,
+		statsRepo:     statsRepo,
+		domainService: domainService,
+		tm:            tm,
+		storage:       storageService,
+		notifications: notifications,
+		deps:          deps,
+	}
+}
+
+func (s *extractService) RequestExtract(ctx context.Context, userID uint64, fileID uint64, targetFolderID *uint64) (*models.ExtractJob, error) {
+	file, err := s.domainService.CheckFileAccess(ctx, userID, fileID, authz.ActionDownload)
+	if err != nil {
+		return nil, err
+	}
+	format := archiveFormat(file)
+	if format == "" {
+		return nil, fmt.Errorf("extract service: %s: %w", file.FileName, xerr.ErrUnsupportedArchive)
+	}
+	if err := ensureNotQuarantined(file); err != nil {
+		return nil, fmt.Errorf("extract service: %w", err)
+	}
+
+	if targetFolderID == nil {
+		targetFolderID = file.ParentFolderID
+	}
+	if _, err := s.domainService.CheckWritableDirectory(ctx, userID, targetFolderID); err != nil {
+		return nil, err
+	}
+
+	active, err := s.extractRepo.FindActive(ctx, userID, fileID)
+	if err != nil {
+		logger.Error("RequestExtract: Failed to find active extract job", zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, fmt.Errorf("extract service: failed to find active extract job: %w", xerr.ErrDatabaseError)
+	}
+	if active != nil {
+		return active, nil
+	}
+
+	job := &models.ExtractJob{
+		UserID:         userID,
+		FileID:         fileID,
+		TargetFolderID: targetFolderID,
+		Format:         format,
+		Status:         models.ExtractStatusPending,
+	}
+	// 任务和任务消息在同一事务中写入,创建成功的任务一定会被处理
+	err = s.tm.WithTransaction(ctx, func(tx *gorm.DB) error {
+		if err := repositories.NewExtractJobRepository(tx).Create(ctx, job); err != nil {
+			return err
+		}
+		event, err := models.NewQueueEvent(ExtractQueueName, models.ExtractTask{JobID: job.ID, UserID: userID})
+		if err != nil {
+			return err
+		}
+		return repositories.NewOutboxRepository(tx).Add(ctx, event)
+	})
+	if err != nil {
+		logger.Error("RequestExtract: Failed to create extract job", zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, fmt.Errorf("extract service: failed to create extract job: %w", xerr.ErrDatabaseError)
+	}
+
+	logger.Info("RequestExtract: Extract requested", zap.Uint64("userID", userID), zap.Uint64("fileID", fileID), zap.Uint64("jobID", job.ID))
+	return job, nil
+}
+
+func (s *extractService) GetExtract(ctx context.Context, userID uint64, jobID uint64) (*models.ExtractJob, error) {
+	job, err := s.extractRepo.FindByID(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrExtractJobNotFound) {
+			return nil, fmt.Errorf("extract service: %w", xerr.ErrExtractJobNotFound)
+		}
+		logger.Error("GetExtract: Failed to get extract job", zap.Uint64("jobID", jobID), zap.Error(err))
+		return nil, fmt.Errorf("extract service: failed to get extract job: %w", xerr.ErrDatabaseError)
+	}
+	if job.UserID != userID {
+		return nil, fmt.Errorf("extract service: %w", xerr.ErrExtractJobNotFound)
+	}
+	return job, nil
+}
+
+func (s *extractService) ProcessExtract(ctx context.Context, jobID uint64) error {
+	job, err := s.extractRepo.FindByID(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrExtractJobNotFound) {
+			return nil
+		}
+		return fmt.Errorf("extract service: failed to get extract job: %w", err)
+	}
+	// 处理中的任务说明 Worker 异常退出过,已创建的文件夹无法确认是否完整,不再重试
+	if job.Status == models.ExtractStatusProcessing {
+		err := errors.New("extraction was interrupted")
+		s.fail(ctx, job, err)
+		return err
+	}
+	if job.Status != models.ExtractStatusPending {
+		return nil
+	}
+
+	job.Status = models.ExtractStatusProcessing
+	if err := s.extractRepo.Update(ctx, job); err != nil {
+		return fmt.Errorf("extract service: failed to mark extract job processing: %w", err)
+	}
+
+	e, err := s.prepare(ctx, job)
+	if err != nil {
+		s.fail(ctx, job, err)
+		return err
+	}
+	err = e.run(ctx)
+	// 失败时已解压的内容保留在新建的文件夹中,同样需要刷新用量统计
+	s.deps.Stats.NotifyChanged(ctx, e.ownerID, fullPathWithSelf(e.root))
+	if err != nil {
+		s.fail(ctx, job, err)
+		return err
+	}
+
+	job.Status = models.ExtractStatusDone
+	job.TotalEntries = job.ProcessedEntries
+	if err := s.extractRepo.Update(ctx, job); err != nil {
+		return fmt.Errorf("extract service: failed to mark extract job done: %w", err)
+	}
+
+	s.notifications.Notify(ctx, &models.Notification{
+		UserID:     job.UserID,
+		Type:       models.NotificationExtractDone,
+		Title:      "压缩包解压完成",
+		Body:       fmt.Sprintf("%s 已解压到 %s", e.archive.FileName, fullPathWithSelf(e.root)),
+		FileID:     job.RootFolderID,
+		ResourceID: &job.ID,
+	})
+
+	logger.Info("ProcessExtract: Extract done",
+		zap.Uint64("jobID", job.ID),
+		zap.Uint64("fileID", job.FileID),
+		zap.Int64("files", job.FileCount),
+		zap.Int64("folders", job.FolderCount),
+		zap.Int64("skipped", job.SkippedEntries),
+		zap.Uint64("size", job.ExtractedSize))
+	return nil
+}
+
+// prepare 重新检查权限和目标文件夹,计算各项上限并创建以压缩包命名的根文件夹
+func (s *extractService) prepare(ctx context.Context, job *models.ExtractJob) (*extraction, error) {
+	// 排队期间用户可能已失去访问权限或文件已被删除
+	archive, err := s.domainService.CheckFileAccess(ctx, job.UserID, job.FileID, authz.ActionDownload)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureNotQuarantined(archive); err != nil {
+		return nil, err
+	}
+	if archive.OssBucket == nil || archive.OssKey == nil {
+		return nil, fmt.Errorf("archive has no stored content: %w", xerr.ErrFileStatusInvalid)
+	}
+	target, err := s.domainService.CheckWritableDirectory(ctx, job.UserID, job.TargetFolderID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 解压到共享文件夹或团队空间时,新建的内容归目标文件夹的所有者,占用所有者的空间
+	ownerID := job.UserID
+	if target != nil {
+		ownerID = target.UserID
+	}
+	available, err := s.availableSpace(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := s.deps.Config.Extract
+	e := &extraction{
+		s:          s,
+		job:        job,
+		archive:    archive,
+		ownerID:    ownerID,
+		available:  available,
+		maxEntries: int64(cfg.MaxEntries),
+		maxTotal:   uint64(cfg.MaxTotalSize),
+		maxRatio:   uint64(cfg.MaxRatio),
+		folders:    make(map[string]*models.File),
+	}
+	if e.maxEntries <= 0 {
+		e.maxEntries = defaultExtractMaxEntries
+	}
+	if e.maxTotal == 0 {
+		e.maxTotal = defaultExtractMaxTotalSize
+	}
+	if e.maxRatio == 0 {
+		e.maxRatio = defaultExtractMaxRatio
+	}
+
+	var parentPath = "/"
+	if target != nil {
+		parentPath = fullPathWithSelf(target)
+	}
+	e.root, err = e.createFolder(ctx, job.TargetFolderID, parentPath, archiveBaseName(archive.FileName))
+	if err != nil {
+		return nil, err
+	}
+	e.folders[""] = e.root
+	job.RootFolderID = &e.root.ID
+	if err := s.extractRepo.Update(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to save extract job: %w", err)
+	}
+	return e, nil
+}
+
+// availableSpace 返回所有者剩余的存储空间,TotalSpace 为 0 表示不限制,返回 nil
+func (s *extractService) availableSpace(ctx context.Context, ownerID uint64) (*uint64, error) {
+	owner, err := s.deps.Users.GetUserByID(ctx, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get owner: %w", err)
+	}
+	if owner.TotalSpace == 0 {
+		return nil, nil
+	}
+	byType, err := s.statsRepo.AggregateByType(ctx, ownerID)
+	if err != nil {
+		logger.Error("ProcessExtract: Failed to get owner usage", zap.Uint64("ownerID", ownerID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get owner usage: %w", xerr.ErrDatabaseError)
+	}
+	var used uint64
+	for _, usage := range byType {
+		used += usage.TotalSize
+	}
+	available := owner.TotalSpace - min(used, owner.TotalSpace)
+	return &available, nil
+}
+
+// fail 把解压任务标记为失败并保存错误信息
+func (s *extractService) fail(ctx context.Context, job *models.ExtractJob, cause error) {
+	logger.Error("Extract failed", zap.Uint64("jobID", job.ID), zap.Uint64("fileID", job.FileID), zap.Error(cause))
+	message := cause.Error()
+	if len(message) > maxExportErrorLength {
+		message = strings.ToValidUTF8(message[:maxExportErrorLength], "")
+	}
+	job.Status = models.ExtractStatusFailed
+	job.Error = message
+	if err := s.extractRepo.Update(ctx, job); err != nil {
+		logger.Error("Failed to mark extract job failed", zap.Uint64("jobID", job.ID), zap.Error(err))
+	}
+}
+
+// extraction 一次解压的状态。压缩包中的路径只用于确定层级,
+// 含有 ".." 或绝对路径的条目直接判定压缩包不安全,不会被解析到根文件夹之外
+type extraction struct {
+	s          *extractService
+	job        *models.ExtractJob
+	archive    *models.File
+	root       *models.File
+	ownerID    uint64
+	available  *uint64 // 所有者剩余空间,nil 表示不限制
+	maxEntries int64
+	maxTotal   uint64
+	maxRatio   uint64
+	folders    map[string]*models.File // 压缩包内的文件夹路径到新建记录,"" 为根文件夹
+}
+
+// run 从存储中读取压缩包并逐个解压条目
+func (e *extraction) run(ctx context.Context) error {
+	object, err := e.s.storage.GetObject(ctx, *e.archive.OssBucket, *e.archive.OssKey, stringValue(e.archive.VersionID))
+	if err != nil {
+		logger.Error("ProcessExtract: Failed to get archive from storage", zap.Uint64("fileID", e.archive.ID), zap.Error(err))
+		return fmt.Errorf("failed to read archive: %w", xerr.ErrStorageError)
+	}
+	defer object.Reader.Close()
+
+	switch e.job.Format {
+	case models.ExtractFormatZip:
+		err = e.extractZip(ctx, object.Reader)
+	case models.ExtractFormatTar:
+		err = e.extractTar(ctx, object.Reader)
+	case models.ExtractFormatTarGz:
+		gz, gzErr := gzip.NewReader(object.Reader)
+		if gzErr != nil {
+			return fmt.Errorf("invalid gzip archive: %w", xerr.ErrUnsupportedArchive)
+		}
+		defer gz.Close()
+		err = e.extractTar(ctx, gz)
+	default:
+		err = fmt.Errorf("format %q: %w", e.job.Format, xerr.ErrUnsupportedArchive)
+	}
+	return err
+}
+
+// extractZip ZIP 的目录在文件末尾,需要随机读取,先把压缩包暂存到临时文件
+func (e *extraction) extractZip(ctx context.Context, r io.Reader) error {
+	tmp, err := os.CreateTemp(e.s.deps.Config.Extract.TempDir, fmt.Sprintf("extract-%d-*.zip", e.job.ID))
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		return fmt.Errorf("failed to download archive: %w", xerr.ErrStorageError)
+	}
+
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		return fmt.Errorf("invalid zip archive: %w", xerr.ErrUnsupportedArchive)
+	}
+	if int64(len(zr.File)) > e.maxEntries {
+		return fmt.Errorf("archive has %d entries, limit is %d: %w", len(zr.File), e.maxEntries, xerr.ErrFileTooLarge)
+	}
+	e.job.TotalEntries = int64(len(zr.File))
+
+	for _, f := range zr.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			err = e.addFolder(ctx, f.Name)
+		case mode.IsRegular():
+			// 按压缩后的大小限制实际读出的字节数,不信任条目声明的解压后大小
+			limit := max(f.CompressedSize64*e.maxRatio, extractRatioFloor)
+			err = e.addFile(ctx, f.Name, limit, func() (io.ReadCloser, error) { return f.Open() })
+		default:
+			e.job.SkippedEntries++
+		}
+		if err != nil {
+			return err
+		}
+		e.entryDone(ctx)
+	}
+	return nil
+}
+
+// extractTar 顺序读取 TAR,不需要暂存压缩包
+func (e *extraction) extractTar(ctx context.Context, r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tar archive: %w", xerr.ErrUnsupportedArchive)
+		}
+		if e.job.ProcessedEntries >= e.maxEntries {
+			return fmt.Errorf("archive has more than %d entries: %w", e.maxEntries, xerr.ErrFileTooLarge)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = e.addFolder(ctx, header.Name)
+		case tar.TypeReg:
+			err = e.addFile(ctx, header.Name, e.maxTotal, func() (io.ReadCloser, error) { return io.NopCloser(tr), nil })
+		case tar.TypeXGlobalHeader:
+			continue // PAX 全局头不是条目
+		default:
+			e.job.SkippedEntries++
+		}
+		if err != nil {
+			return err
+		}
+		e.entryDone(ctx)
+	}
+}
+
+// entryDone 记录一个条目处理完成,每隔若干条目保存一次进度,保存失败不影响解压
+func (e *extraction) entryDone(ctx context.Context) {
+	e.job.ProcessedEntries++
+	if e.job.ProcessedEntries%extractProgressInterval != 0 {
+		return
+	}
+	if err := e.s.extractRepo.Update(ctx, e.job); err != nil {
+		logger.Warn("ProcessExtract: Failed to save progress", zap.Uint64("jobID", e.job.ID), zap.Error(err))
+	}
+}
+
+// addFolder 创建压缩包中的文件夹条目,已经因子项创建过的文件夹直接复用
+func (e *extraction) addFolder(ctx context.Context, name string) error {
+	segments, err := entrySegments(name)
+	if err != nil {
+		return err
+	}
+	_, err = e.ensureFolder(ctx, segments)
+	return err
+}
+
+// addFile 把压缩包中的文件写入存储并创建记录,limit 为该条目允许读出的最大字节数
+func (e *extraction) addFile(ctx context.Context, name string, limit uint64, open func() (io.ReadCloser, error)) error {
+	segments, err := entrySegments(name)
+	if err != nil {
+		return err
+	}
+	if len(segments) == 0 {
+		e.job.SkippedEntries++
+		return nil
+	}
+	parent, err := e.ensureFolder(ctx, segments[:len(segments)-1])
+	if err != nil {
+		return err
+	}
+	fileName := e.s.domainService.SanitizeFileName(segments[len(segments)-1])
+	mimeType := resolveMimeType(fileName, "")
+	// 上传时禁止的文件类型同样不能通过解压进入网盘,跳过而不是让整个压缩包失败
+	if err := checkFileType(e.s.deps.Config.Upload, fileName, mimeType); err != nil {
+		e.job.SkippedEntries++
+		return nil
+	}
+
+	reader, err := open()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, xerr.ErrUnsupportedArchive)
+	}
+	defer reader.Close()
+	object, err := e.storeObject(ctx, fileName, mimeType, reader, limit)
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", name, err)
+	}
+	if err := e.createFile(ctx, parent, fileName, object); err != nil {
+		e.s.removeUnreferencedObject(ctx, object.Result)
+		return err
+	}
+	return nil
+}
+
+// storeObject 把条目内容暂存到临时文件并计算哈希,通过大小和空间检查后上传到存储
+func (e *extraction) storeObject(ctx context.Context, fileName, mimeType string, r io.Reader, limit uint64) (*uploadedObject, error) {
+	tmp, err := os.CreateTemp(e.s.deps.Config.Extract.TempDir, fmt.Sprintf("extract-%d-*", e.job.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	remaining := e.maxTotal - min(e.job.ExtractedSize, e.maxTotal)
+	totalLimited := remaining <= limit
+	limit = min(limit, remaining)
+	md5Hasher, sha256Hasher := md5.New(), sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, md5Hasher, sha256Hasher), io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive content: %w", xerr.ErrUnsupportedArchive)
+	}
+	if uint64(size) > limit {
+		if totalLimited {
+			return nil, fmt.Errorf("archive expands beyond %d bytes: %w", e.maxTotal, xerr.ErrFileTooLarge)
+		}
+		return nil, fmt.Errorf("compression ratio exceeds %d: %w", e.maxRatio, xerr.ErrFileTooLarge)
+	}
+	if e.available != nil && e.job.ExtractedSize+uint64(size) > *e.available {
+		return nil, fmt.Errorf("owner has %d bytes available: %w", *e.available, xerr.ErrQuotaExceeded)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind temp file: %w", err)
+	}
+
+	object := &uploadedObject{
+		MD5Hash:    hex.EncodeToString(md5Hasher.Sum(nil)),
+		SHA256Hash: hex.EncodeToString(sha256Hasher.Sum(nil)),
+		MimeType:   mimeType,
+	}
+	objectName := e.s.storage.GetUploadObjName(object.MD5Hash, fileName)
+	object.Result, err = e.s.storage.PutObject(ctx, e.s.deps.Buckets.SelectBucket(e.ownerID, time.Now()), objectName, tmp, size, mimeType)
+	if err != nil {
+		logger.Error("ProcessExtract: Failed to store extracted file", zap.Uint64("jobID", e.job.ID), zap.Error(err))
+		return nil, fmt.Errorf("failed to store file: %w", xerr.ErrStorageError)
+	}
+	return object, nil
+}
+
+// createFile 在一个事务中创建文件记录、第一个版本和扫描等内容处理任务
+func (e *extraction) createFile(ctx context.Context, parent *models.File, fileName string, object *uploadedObject) error {
+	finalName, err := e.s.domainService.ResolveFileNameConflict(ctx, e.ownerID, &parent.ID, fileName, 0, 0)
+	if err != nil {
+		return err
+	}
+	file := &models.File{
+		UserID:         e.ownerID,
+		UUID:           uuid.NewString(),
+		FileName:       finalName,
+		ParentFolderID: &parent.ID,
+		Path:           fullPathWithSelf(parent),
+		IsFolder:       0,
+		MimeType:       &object.MimeType,
+		VersionID:      &object.Result.VersionID,
+		MD5Hash:        &object.MD5Hash,
+		SHA256Hash:     &object.SHA256Hash,
+		Status:         models.StatusNormal,
+		ScanStatus:     initialScanStatus(e.s.deps.Config),
+		Size:           uint64(object.Result.Size),
+		OssKey:         &object.Result.Key,
+		OssBucket:      &object.Result.Bucket,
+	}
+	err = e.s.tm.WithTransaction(ctx, func(tx *gorm.DB) error {
+		fileRepo := repositories.NewCachedFileRepository(repositories.NewDBFileRepository(tx), e.s.deps.Cache, repositories.NewOutboxRepository(tx))
+		if err := fileRepo.Create(ctx, file); err != nil {
+			return fmt.Errorf("failed to create file: %w", err)
+		}
+		version := &models.FileVersion{
+			FileID:     file.ID,
+			Version:    1,
+			Size:       file.Size,
+			OssBucket:  object.Result.Bucket,
+			OssKey:     object.Result.Key,
+			VersionID:  object.Result.VersionID,
+			MD5Hash:    object.MD5Hash,
+			SHA256Hash: object.SHA256Hash,
+		}
+		if err := repositories.NewFileVersionRepository(tx).Create(ctx, version); err != nil {
+			return fmt.Errorf("failed to create first file version: %w", err)
+		}
+		return addContentTaskEvents(ctx, tx, e.s.deps.Config, file)
+	})
+	if err != nil {
+		logger.Error("ProcessExtract: Failed to save extracted file", zap.Uint64("jobID", e.job.ID), zap.String("fileName", finalName), zap.Error(err))
+		return fmt.Errorf("failed to save extracted file: %w", xerr.ErrDatabaseError)
+	}
+	e.job.FileCount++
+	e.job.ExtractedSize += file.Size
+	return nil
+}
+
+// ensureFolder 按层级创建压缩包内的文件夹,返回最深一层的记录
+func (e *extraction) ensureFolder(ctx context.Context, segments []string) (*models.File, error) {
+	parent := e.root
+	for i := range segments {
+		key := strings.Join(segments[:i+1], "/")
+		if folder, ok := e.folders[key]; ok {
+			parent = folder
+			continue
+		}
+		folder, err := e.createFolder(ctx, &parent.ID, fullPathWithSelf(parent), segments[i])
+		if err != nil {
+			return nil, err
+		}
+		e.folders[key] = folder
+		parent = folder
+	}
+	return parent, nil
+}
+
+// createFolder 创建文件夹记录,与已有文件夹或文件重名时自动重命名
+func (e *extraction) createFolder(ctx context.Context, parentID *uint64, parentPath, name string) (*models.File, error) {
+	name, err := e.s.domainService.ResolveFileNameConflict(ctx, e.ownerID, parentID, e.s.domainService.SanitizeFileName(name), 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	folder := &models.File{
+		UUID:           uuid.NewString(),
+		UserID:         e.ownerID,
+		ParentFolderID: parentID,
+		FileName:       name,
+		Path:           parentPath,
+		IsFolder:       1,
+		Status:         models.StatusNormal,
+	}
+	if err := e.s.fileRepo.Create(ctx, folder); err != nil {
+		logger.Error("ProcessExtract: Failed to create folder", zap.Uint64("jobID", e.job.ID), zap.String("folderName", name), zap.Error(err))
+		return nil, fmt.Errorf("failed to create folder: %w", xerr.ErrDatabaseError)
+	}
+	e.job.FolderCount++
+	return folder, nil
+}
+
+// removeUnreferencedObject 文件记录创建失败时删除刚上传的对象,对象已被其他版本引用时保留
+func (s *extractService) removeUnreferencedObject(ctx context.Context, result storage.PutObjectResult) {
+	referenced, err := s.deps.Objects.IsReferenced(ctx, result.Bucket, result.Key, result.VersionID)
+	if err != nil || referenced {
+		return
+	}
+	if err := s.storage.RemoveObject(ctx, result.Bucket, result.Key, result.VersionID); err != nil {
+		logger.Warn("ProcessExtract: Failed to remove unreferenced object", zap.String("key", result.Key), zap.Error(err))
+	}
+}
+
+// entrySegments 把压缩包中的条目路径拆分为各级名称。Windows 工具生成的 ZIP 可能使用反斜杠,
+// 绝对路径、盘符和 ".." 会让条目落到解压目录之外(zip-slip),整个压缩包视为不安全
+func entrySegments(name string) ([]string, error) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if strings.HasPrefix(name, "/") || (len(name) >= 2 && name[1] == ':') {
+		return nil, fmt.Errorf("unsafe entry path %q: %w", name, xerr.ErrInvalidParams)
+	}
+	var segments []string
+	for _, segment := range strings.Split(name, "/") {
+		switch segment {
+		case "", ".":
+			continue
+		case "..":
+			return nil, fmt.Errorf("unsafe entry path %q: %w", name, xerr.ErrInvalidParams)
+		}
+		segments = append(segments, segment)
+	}
+	return segments, nil
+}
+
+// archiveFormat 按扩展名判断压缩包格式,不支持时返回空字符串
+func archiveFormat(file *models.File) string {
+	if file.IsFolder == 1 {
+		return ""
+	}
+	name := strings.ToLower(file.FileName)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return models.ExtractFormatZip
+	case strings.HasSuffix(name, ".tar"):
+		return models.ExtractFormatTar
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return models.ExtractFormatTarGz
+	}
+	return ""
+}
+
+// archiveBaseName 去掉压缩包扩展名作为解压文件夹的名称
+func archiveBaseName(fileName string) string {
+	lower := strings.ToLower(fileName)
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip"} {
+		if strings.HasSuffix(lower, ext) && len(fileName) > len(ext) {
+			return fileName[:len(fileName)-len(ext)]
+		}
+	}
+	return fileName
+}
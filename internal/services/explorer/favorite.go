@@ -0,0 +1,161 @@
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxRecentFiles 每个用户保留的最近访问记录数
+	maxRecentFiles = 50
+	// recentFilesTTL 长时间不活跃的用户的最近访问记录自动过期
+	recentFilesTTL = 30 * 24 * time.Hour
+)
+
+// recentActions 会更新最近访问列表的操作
+var recentActions = map[string]bool{
+	models.ActivityUpload:      true,
+	models.ActivityDownload:    true,
+	models.ActivityRename:      true,
+	models.ActivityMove:        true,
+	models.ActivityRestore:     true,
+	models.ActivityShareCreate: true,
+	models.ActivityTransferIn:  true,
+}
+
+// FavoriteService 收藏和最近访问,用于客户端首页展示,无需遍历整个目录树
+type FavoriteService interface {
+	Star(ctx context.Context, userID uint64, fileID uint64) error
+	Unstar(ctx context.Context, userID uint64, fileID uint64) error
+	// ListStarred 分页列出收藏的文件,已无权访问的文件不会返回
+	ListStarred(ctx context.Context, userID uint64, page, pageSize int) ([]models.FileFavorite, int64, error)
+	// ListRecent 按访问时间倒序列出最近访问的文件,最多 limit 个
+	ListRecent(ctx context.Context, userID uint64, limit int) ([]models.File, error)
+	// TrackActivity 根据活动日志更新操作者的最近访问列表,由活动日志 Worker 调用
+	TrackActivity(ctx context.Context, activity *models.Activity)
+}
+
+type favoriteService struct {
+	favoriteRepo  repositories.FileFavoriteRepository
+	domainService FileDomainService
+	cache         *cache.RedisCache
+}
+
+var _ FavoriteService = (*favoriteService)(nil)
+
+// NewFavoriteService 创建收藏服务实例
+func NewFavoriteService(
+	favoriteRepo repositories.FileFavoriteRepository,
+	domainService FileDomainService,
+	cache *cache.RedisCache,
+) FavoriteService {
+	return &favoriteService{
+		favoriteRepo:  favoriteRepo,
+		domainService: domainService,
+		cache:         cache,
+	}
+}
+
+func (s *favoriteService) Star(ctx context.Context, userID uint64, fileID uint64) error {
+	if _, err := s.domainService.CheckFile(ctx, userID, fileID); err != nil {
+		return err
+	}
+
+	if err := s.favoriteRepo.Create(&models.FileFavorite{UserID: userID, FileID: fileID}); err != nil {
+		logger.Error("Star: Failed to save favorite", zap.Uint64("userID", userID), zap.Uint64("fileID", fileID), zap.Error(err))
+		return fmt.Errorf("favorite service: failed to save favorite: %w", xerr.ErrDatabaseError)
+	}
+	return nil
+}
+
+func (s *favoriteService) Unstar(ctx context.Context, userID uint64, fileID uint64) error {
+	deleted, err := s.favoriteRepo.Delete(userID, fileID)
+	if err != nil {
+		logger.Error("Unstar: Failed to delete favorite", zap.Uint64("userID", userID), zap.Uint64("fileID", fileID), zap.Error(err))
+		return fmt.Errorf("favorite service: failed to delete favorite: %w", xerr.ErrDatabaseError)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("favorite service: %w", xerr.ErrFileNotFound)
+	}
+	return nil
+}
+
+func (s *favoriteService) ListStarred(ctx context.Context, userID uint64, page, pageSize int) ([]models.FileFavorite, int64, error) {
+	favorites, total, err := s.favoriteRepo.FindByUserID(userID, page, pageSize)
+	if err != nil {
+		logger.Error("ListStarred: Failed to list favorites", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, 0, fmt.Errorf("favorite service: failed to list favorites: %w", xerr.ErrDatabaseError)
+	}
+	// 已无权访问的文件由仓库在分页前过滤,total 与返回的列表一致
+	return favorites, total, nil
+}
+
+func (s *favoriteService) ListRecent(ctx context.Context, userID uint64, limit int) ([]models.File, error) {
+	if limit <= 0 || limit > maxRecentFiles {
+		limit = maxRecentFiles
+	}
+
+	key := cache.GenerateRecentFilesKey(userID)
+	members, err := s.cache.ZRevRange(ctx, key, 0, maxRecentFiles-1).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		logger.Error("ListRecent: Failed to get recent files", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("favorite service: failed to get recent files: %w", xerr.ErrInternalServer)
+	}
+
+	files := make([]models.File, 0, limit)
+	var stale []any
+	for _, member := range members {
+		if len(files) >= limit {
+			break
+		}
+		fileID, err := strconv.ParseUint(member, 10, 64)
+		if err != nil {
+			stale = append(stale, member)
+			continue
+		}
+		file, err := s.domainService.CheckFile(ctx, userID, fileID)
+		if err != nil {
+			// 已删除或无权访问的文件从列表中移除,回收站中的文件暂时跳过,恢复后仍可见
+			if !errors.Is(err, xerr.ErrFileStatusInvalid) && !errors.Is(err, xerr.ErrDatabaseError) {
+				stale = append(stale, member)
+			}
+			continue
+		}
+		files = append(files, *file)
+	}
+
+	if len(stale) > 0 {
+		if err := s.cache.ZRem(ctx, key, stale...).Err(); err != nil {
+			logger.Warn("ListRecent: Failed to remove stale recent files", zap.Uint64("userID", userID), zap.Error(err))
+		}
+	}
+	return files, nil
+}
+
+func (s *favoriteService) TrackActivity(ctx context.Context, activity *models.Activity) {
+	// 只记录用户本人的操作,分享链接的匿名访问和后台任务没有操作者
+	if !recentActions[activity.Action] || activity.ActorID == nil || activity.FileID == nil {
+		return
+	}
+
+	key := cache.GenerateRecentFilesKey(*activity.ActorID)
+	pipe := s.cache.TxPipeline()
+	pipe.ZAdd(ctx, key, &redis.Z{Score: float64(activity.CreatedAt.UnixMilli()), Member: strconv.FormatUint(*activity.FileID, 10)})
+	pipe.ZRemRangeByRank(ctx, key, 0, -maxRecentFiles-1)
+	pipe.Expire(ctx, key, recentFilesTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.Warn("TrackActivity: Failed to update recent files",
+			zap.Uint64("userID", *activity.ActorID), zap.Uint64("fileID", *activity.FileID), zap.Error(err))
+	}
+}
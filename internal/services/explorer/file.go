@@ -2,21 +2,25 @@ package explorer
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"path"
 	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/3Eeeecho/go-clouddisk/internal/config"
 	"github.com/3Eeeecho/go-clouddisk/internal/models"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
-	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mq"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/storage"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/tracing"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
 	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/activity"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/authz"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -24,42 +28,86 @@ import (
 
 type FileService interface {
 	// 文件查询
-	GetFileByID(userID uint64, fileID uint64) (*models.File, error)
-	GetFileByMD5Hash(userID uint64, md5Hash string) (*models.File, error)
-	GetFilesByUserID(userID uint64, parentFolderID *uint64) ([]models.File, error)
+	GetFileByID(ctx context.Context, userID uint64, fileID uint64) (*models.File, error)
+	GetFileByMD5Hash(ctx context.Context, userID uint64, md5Hash string) (*models.File, error)
+	// GetFilesByUserID 分页列出文件夹内容。opts.Cursor 不为 nil 时按游标分页,还有下一页时返回下一页的游标
+	GetFilesByUserID(ctx context.Context, userID uint64, parentFolderID *uint64, opts models.FileListOptions) ([]models.File, int64, string, error)
+	GetFileByPath(ctx context.Context, userID uint64, fullPath string) (*models.File, error)
+	GetFolderSize(ctx context.Context, userID uint64, folderID uint64) (*models.FolderSize, error)
+	// GetManifest 返回文件夹子树(folderID 为 nil 时为整个网盘)的同步清单,父文件夹总在其子项之前
+	GetManifest(ctx context.Context, userID uint64, folderID *uint64) ([]models.ManifestEntry, error)
+	// GetFilesMetadata 按请求顺序批量返回可访问的正常状态文件,missing 为不存在、不在正常状态或无权访问的ID
+	GetFilesMetadata(ctx context.Context, userID uint64, fileIDs []uint64) (files []models.File, missing []uint64, err error)
+	// StatFile 获取文件元数据,快捷方式解析为目标文件,只查询数据库和缓存,不访问对象存储
+	StatFile(ctx context.Context, userID uint64, fileID uint64) (*models.File, error)
+	// CountTrashedChildren 统计文件夹和列表中的子文件夹在回收站中的直接子项数量,只统计 userID 自己的文件
+	CountTrashedChildren(ctx context.Context, userID uint64, parentFolderID *uint64, files []models.File) (*models.TrashCounts, error)
 
 	//文件上传
 	//UploadFile(userID uint64, originalName, mimeType string, filesize uint64, parentFolderID *uint64, fileContent io.Reader) (*models.File, error)
 
-	// 文件下载
-	Download(ctx context.Context, userID uint64, fileID uint64) (*models.File, io.ReadCloser, error)
-	GetPresignedURLForDownload(ctx context.Context, userID uint64, fileID uint64) (string, error)
+	// Download 下载文件,文件夹打包为 ZIP,checksums 为 true 时 ZIP 中附加 SHA256SUMS.txt 校验清单
+	Download(ctx context.Context, userID uint64, fileID uint64, checksums bool) (*models.File, io.ReadCloser, error)
+	// DownloadBatch 把选中的多个文件和文件夹打包成一个 ZIP,返回未压缩总大小和 ZIP 读取器。checksums 同 Download
+	DownloadBatch(ctx context.Context, userID uint64, fileIDs []uint64, checksums bool) (uint64, io.ReadCloser, error)
+	// GetPresignedURLForDownload 生成下载链接,inline 为 true 且类型安全时链接在浏览器中直接打开
+	GetPresignedURLForDownload(ctx context.Context, userID uint64, fileID uint64, inline bool) (string, error)
+	// GetFileContentReader 获取文件当前版本内容的读取器,不做权限校验
+	GetFileContentReader(ctx context.Context, file *models.File) (io.ReadCloser, error)
 
 	// 文件删除
-	SoftDelete(userID uint64, fileID uint64) error
-	PermanentDelete(userID uint64, fileID uint64) error
-	DeleteFileVersion(userID uint64, fileID uint64, versionID string) error
+	// SoftDelete 把文件移入回收站。位于设置了跳过回收站的文件夹中时改为彻底删除,返回创建的彻底删除任务
+	SoftDelete(ctx context.Context, userID uint64, fileID uint64) (*models.PurgeJob, error)
+	DeleteFileVersion(ctx context.Context, userID uint64, fileID uint64, versionID string) error
 
 	// 回收站操作
-	ListRecycleBinFiles(userID uint64) ([]models.File, error)
-	RestoreFile(userID uint64, fileID uint64) error
+	// ListRecycleBinFiles 按删除时间倒序列出回收站,还有下一页时返回下一页的游标
+	ListRecycleBinFiles(ctx context.Context, userID uint64, opts models.TrashListOptions) ([]models.File, string, error)
+	// RestoreFile 恢复回收站中的文件或文件夹。targetParentID 为 nil 时恢复到原位置,原父文件夹已不存在时恢复到根目录。
+	// 返回恢复后的文件记录
+	RestoreFile(ctx context.Context, userID uint64, fileID uint64, targetParentID *uint64) (*models.File, error)
 
 	// 文件操作
-	CreateFolder(userID uint64, folderName string, parentFolderID *uint64) (*models.File, error)
-	RenameFile(userID uint64, fileID uint64, newFileName string) (*models.File, error)
-	MoveFile(userID uint64, fileID uint64, parentFolderID *uint64) (*models.File, error)
-	ListFileVersions(userID uint64, fileID uint64) ([]models.FileVersion, error)
-	RestoreFileVersion(userID uint64, fileID uint64, versionID string) error
+	CreateFolder(ctx context.Context, userID uint64, folderName string, parentFolderID *uint64) (*models.File, error)
+	// CreateLink 在文件夹中创建指向其他文件的快捷方式,目标可以位于共享给自己的文件夹中,linkName 为空时使用目标文件名
+	CreateLink(ctx context.Context, userID uint64, targetID uint64, parentFolderID *uint64, linkName string) (*models.File, error)
+	// RenameFile、MoveFile 和 RestoreFileVersion 的 expectedVersion 不为 nil 时,文件的当前版本号必须与之一致,
+	// 否则返回 xerr.ErrVersionConflict,用于防止并发客户端互相覆盖修改。
+	// 移动类操作的 tagPropagation 为 userID 在移动的条目上的标签处理方式,见 models.TagPropagation*,与移动在同一事务中完成
+	RenameFile(ctx context.Context, userID uint64, fileID uint64, newFileName string, expectedVersion *uint64) (*models.File, error)
+	MoveFile(ctx context.Context, userID uint64, fileID uint64, parentFolderID *uint64, expectedVersion *uint64, tagPropagation string) (*models.File, error)
+	BatchMove(ctx context.Context, userID uint64, fileIDs []uint64, parentFolderID *uint64, tagPropagation string) ([]models.File, error)
+	// OrganizeFiles 新建文件夹并把选中的条目移动进去,返回新文件夹和移动后的条目
+	OrganizeFiles(ctx context.Context, userID uint64, folderName string, parentFolderID *uint64, fileIDs []uint64, tagPropagation string) (*models.File, []models.File, error)
+	// BatchSoftDelete 批量删除,位于跳过回收站文件夹中的条目改为彻底删除,返回创建的彻底删除任务
+	BatchSoftDelete(ctx context.Context, userID uint64, fileIDs []uint64) ([]models.PurgeJob, error)
+	// SetSkipRecycleBin 设置文件夹中的内容删除时是否跳过回收站,仅文件夹所有者可以修改
+	SetSkipRecycleBin(ctx context.Context, userID uint64, folderID uint64, enabled bool) (*models.File, error)
+	// UpdateAttributes 合并修改文件的自定义属性,值为 nil 表示删除该属性
+	UpdateAttributes(ctx context.Context, userID uint64, fileID uint64, changes map[string]*string) (*models.File, error)
+	ListFileVersions(ctx context.Context, userID uint64, fileID uint64) ([]models.FileVersion, error)
+	RestoreFileVersion(ctx context.Context, userID uint64, fileID uint64, versionID string, expectedVersion *uint64) error
+	// GetPresignedURLForVersion 为文件的指定历史版本生成下载链接
+	GetPresignedURLForVersion(ctx context.Context, userID uint64, fileID uint64, versionID string) (string, error)
 }
 
 type fileService struct {
 	fileRepo           repositories.FileRepository
 	fileVersionRepo    repositories.FileVersionRepository
+	userRepo           repositories.UserRepository
 	domainService      FileDomainService  // 业务逻辑
 	transactionManager TransactionManager // 事务管理
 	StorageService     storage.StorageService
-	mqClient           *mq.RabbitMQClient
+	outbox             repositories.OutboxRepository // 异步任务消息
+	activityService    activity.ActivityService      // 活动日志
+	notifications      activity.NotificationService  // 站内通知
+	lockService        FileLockService               // 文件锁
+	statsService       FileStatsService              // 文件夹统计
+	purgeService       PurgeService                  // 彻底删除
+	downloads          DownloadCountService          // 下载次数统计
+	authorizer         authz.Authorizer              // 权限判断
 	cfg                *config.Config
+	presignedURLExpiry atomic.Int64 // 预签名URL有效期（分钟）,支持配置热更新
 }
 
 var _ FileService = (*fileService)(nil)
@@ -68,37 +116,123 @@ var _ FileService = (*fileService)(nil)
 func NewFileService(
 	fileRepo repositories.FileRepository,
 	fileVersionRepo repositories.FileVersionRepository,
+	userRepo repositories.UserRepository,
 	domainService FileDomainService,
 	transactionManager TransactionManager,
 	storageService storage.StorageService,
-	mqClient *mq.RabbitMQClient,
+	outbox repositories.OutboxRepository,
+	activityService activity.ActivityService,
+	notifications activity.NotificationService,
+	lockService FileLockService,
+	statsService FileStatsService,
+	purgeService PurgeService,
+	downloads DownloadCountService,
+	authorizer authz.Authorizer,
 	cfg *config.Config,
 ) FileService {
-	return &fileService{
+	s := &fileService{
 		fileRepo:           fileRepo,
 		fileVersionRepo:    fileVersionRepo,
+		userRepo:           userRepo,
 		domainService:      domainService,
 		transactionManager: transactionManager,
 		StorageService:     storageService,
-		mqClient:           mqClient,
+		outbox:             outbox,
+		activityService:    activityService,
+		notifications:      notifications,
+		lockService:        lockService,
+		statsService:       statsService,
+		purgeService:       purgeService,
+		downloads:          downloads,
+		authorizer:         authorizer,
 		cfg:                cfg,
 	}
+	s.presignedURLExpiry.Store(int64(cfg.Storage.PresignedURLExpiry))
+	config.OnChange(func(_, next *config.Config) {
+		s.presignedURLExpiry.Store(int64(next.Storage.PresignedURLExpiry))
+	})
+	return s
 }
 
-func (s *fileService) GetFileByID(userID uint64, fileID uint64) (*models.File, error) {
-	file, err := s.domainService.CheckFile(userID, fileID)
+func (s *fileService) GetFileByID(ctx context.Context, userID uint64, fileID uint64) (*models.File, error) {
+	file, err := s.domainService.CheckFile(ctx, userID, fileID)
 	if err != nil {
 		return nil, err // 错误已在 domainService 中包裹
 	}
+	if file.IsLink {
+		linked := []models.File{*file}
+		s.markBrokenLinks(ctx, userID, linked)
+		file = &linked[0]
+	}
 
 	logger.Info("GetFileByID success", zap.Uint64("userID", userID), zap.Any("fileID", fileID))
 	return file, nil
 }
 
-func (s *fileService) GetFileByMD5Hash(userID uint64, md5Hash string) (*models.File, error) {
-	file, err := s.fileRepo.FindFileByMD5Hash(md5Hash)
+func (s *fileService) StatFile(ctx context.Context, userID uint64, fileID uint64) (*models.File, error) {
+	file, err := s.domainService.CheckFile(ctx, userID, fileID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if file, err = s.resolveLink(ctx, userID, file); err != nil {
+		return nil, err
+	}
+	stat := []models.File{*file}
+	s.fillDownloads(ctx, stat)
+	return &stat[0], nil
+}
+
+// GetFilesMetadata 批量获取文件元数据,结果按请求顺序排列并去重。
+// 不存在、不可访问或不在正常状态的文件放入 missing,不让整批请求失败
+func (s *fileService) GetFilesMetadata(ctx context.Context, userID uint64, fileIDs []uint64) ([]models.File, []uint64, error) {
+	ids := make([]uint64, 0, len(fileIDs))
+	seen := make(map[uint64]struct{}, len(fileIDs))
+	for _, id := range fileIDs {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+
+	found, err := s.fileRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		logger.Error("GetFilesMetadata: Failed to get files", zap.Uint64("userID", userID), zap.Int("count", len(ids)), zap.Error(err))
+		return nil, nil, fmt.Errorf("file service: failed to get files: %w", xerr.ErrDatabaseError)
+	}
+	byID := make(map[uint64]*models.File, len(found))
+	for i := range found {
+		byID[found[i].ID] = &found[i]
+	}
+
+	files := make([]models.File, 0, len(found))
+	var missing []uint64
+	for _, id := range ids {
+		file, ok := byID[id]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		if err := s.domainService.ValidateFile(ctx, userID, file); err != nil {
+			if errors.Is(err, xerr.ErrPermissionDenied) || errors.Is(err, xerr.ErrFileStatusInvalid) {
+				missing = append(missing, id)
+				continue
+			}
+			return nil, nil, err
+		}
+		files = append(files, *file)
+	}
+	s.markBrokenLinks(ctx, userID, files)
+	s.fillDownloads(ctx, files)
+
+	logger.Info("GetFilesMetadata success", zap.Uint64("userID", userID), zap.Int("found", len(files)), zap.Int("missing", len(missing)))
+	return files, missing, nil
+}
+
+func (s *fileService) GetFileByMD5Hash(ctx context.Context, userID uint64, md5Hash string) (*models.File, error) {
+	file, err := s.fileRepo.FindFileByMD5Hash(ctx, userID, md5Hash)
+	if err != nil {
+		if errors.Is(err, xerr.ErrFileNotFound) {
 			logger.Warn("GetFileByMD5Hash: File not found", zap.String("md5Hash", md5Hash))
 			return nil, fmt.Errorf("file service: %w", xerr.ErrFileNotFound)
 		}
@@ -107,7 +241,7 @@ func (s *fileService) GetFileByMD5Hash(userID uint64, md5Hash string) (*models.F
 	}
 
 	// 检查文件状态
-	if err := s.domainService.ValidateFile(userID, file); err != nil {
+	if err := s.domainService.ValidateFile(ctx, userID, file); err != nil {
 		return nil, err
 	}
 
@@ -115,28 +249,139 @@ func (s *fileService) GetFileByMD5Hash(userID uint64, md5Hash string) (*models.F
 	return file, nil
 }
 
-// GetFilesByUserID 获取用户在指定文件夹下的文件和文件夹列表
-func (s *fileService) GetFilesByUserID(userID uint64, parentFolderID *uint64) ([]models.File, error) {
+// GetFilesByUserID 分页获取用户在指定文件夹下的文件和文件夹列表,返回当前页和总数
+func (s *fileService) GetFilesByUserID(ctx context.Context, userID uint64, parentFolderID *uint64, opts models.FileListOptions) ([]models.File, int64, string, error) {
+	if !opts.Normalize() {
+		return nil, 0, "", fmt.Errorf("file service: invalid sort option %q %q: %w", opts.SortBy, opts.Order, xerr.ErrInvalidParams)
+	}
+
 	// 检查父文件夹
-	if _, err := s.domainService.CheckDirectory(userID, parentFolderID); err != nil {
-		return nil, err
+	parentFolder, err := s.domainService.CheckDirectory(ctx, userID, parentFolderID)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	// 浏览共享给自己的文件夹时,按文件夹所有者查询
+	ownerID := userID
+	if parentFolder != nil {
+		ownerID = parentFolder.UserID
 	}
 
-	files, err := s.fileRepo.FindByUserIDAndParentFolderID(userID, parentFolderID)
+	// 游标分页多取一条,用来判断是否还有下一页
+	limit := opts.PageSize
+	if opts.Cursor != nil && limit > 0 {
+		opts.PageSize++
+	}
+
+	files, total, err := s.fileRepo.FindByUserIDAndParentFolderID(ctx, ownerID, parentFolderID, opts)
 	if err != nil {
 		logger.Error("GetFilesByUserID: Failed to get files", zap.Uint64("userID", userID), zap.Any("parentFolderID", parentFolderID), zap.Error(err))
-		return nil, fmt.Errorf("file service: failed to get files: %w", xerr.ErrDatabaseError)
+		return nil, 0, "", fmt.Errorf("file service: failed to get files: %w", xerr.ErrDatabaseError)
+	}
+
+	var nextCursor string
+	if opts.Cursor != nil && limit > 0 && len(files) > limit {
+		files = files[:limit]
+		nextCursor = models.FileCursor(&files[limit-1]).Encode()
+	}
+	s.markBrokenLinks(ctx, userID, files)
+	logger.Info("GetFilesByUserID success", zap.Uint64("userID", userID), zap.Any("parentFolderID", parentFolderID), zap.Int("fileCount", len(files)), zap.Int64("total", total))
+	return files, total, nextCursor, nil
+}
+
+func (s *fileService) CountTrashedChildren(ctx context.Context, userID uint64, parentFolderID *uint64, files []models.File) (*models.TrashCounts, error) {
+	var folderID uint64
+	if parentFolderID != nil {
+		folderID = *parentFolderID
+	}
+	parentIDs := []uint64{folderID}
+	for i := range files {
+		if files[i].IsFolder == 1 && !files[i].IsLink && files[i].UserID == userID {
+			parentIDs = append(parentIDs, files[i].ID)
+		}
+	}
+
+	counts, err := s.fileRepo.CountDeletedByParentIDs(ctx, userID, parentIDs)
+	if err != nil {
+		logger.Error("CountTrashedChildren: Failed to count deleted files", zap.Uint64("userID", userID), zap.Uint64("folderID", folderID), zap.Error(err))
+		return nil, fmt.Errorf("file service: failed to count deleted files: %w", xerr.ErrDatabaseError)
+	}
+
+	result := &models.TrashCounts{Folder: counts[folderID], Children: make(map[uint64]int64)}
+	for _, id := range parentIDs[1:] {
+		if n := counts[id]; n > 0 {
+			result.Children[id] = n
+		}
+	}
+	return result, nil
+}
+
+// GetFileByPath 将逻辑路径(如 "/Docs/Report.pdf")解析为文件记录
+func (s *fileService) GetFileByPath(ctx context.Context, userID uint64, fullPath string) (*models.File, error) {
+	if !strings.HasPrefix(fullPath, "/") {
+		return nil, fmt.Errorf("file service: path must be absolute: %w", xerr.ErrInvalidParams)
+	}
+
+	// 去除多余的 "/"、"." 和 "..",文件夹路径末尾的 "/" 也一并去除
+	cleanPath := path.Clean(fullPath)
+	if cleanPath == "/" {
+		return nil, fmt.Errorf("file service: root directory has no file record: %w", xerr.ErrInvalidParams)
+	}
+	parentPath, fileName := path.Split(cleanPath)
+
+	file, err := s.fileRepo.FindByPath(ctx, userID, parentPath, fileName)
+	if err != nil {
+		if errors.Is(err, xerr.ErrFileNotFound) {
+			logger.Warn("GetFileByPath: File not found", zap.Uint64("userID", userID), zap.String("path", cleanPath))
+			return nil, fmt.Errorf("file service: %w", xerr.ErrFileNotFound)
+		}
+		logger.Error("GetFileByPath: Failed to get file by path", zap.Uint64("userID", userID), zap.String("path", cleanPath), zap.Error(err))
+		return nil, fmt.Errorf("file service: failed to get file by path: %w", xerr.ErrDatabaseError)
+	}
+
+	if err := s.domainService.ValidateFile(ctx, userID, file); err != nil {
+		return nil, err
+	}
+
+	logger.Info("GetFileByPath success", zap.Uint64("userID", userID), zap.String("path", cleanPath), zap.Uint64("fileID", file.ID))
+	return file, nil
+}
+
+// GetFolderSize 递归统计文件夹下的文件数量和未压缩总大小
+func (s *fileService) GetFolderSize(ctx context.Context, userID uint64, folderID uint64) (*models.FolderSize, error) {
+	folder, err := s.domainService.CheckFile(ctx, userID, folderID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.domainService.ValidateFolder(ctx, userID, folder); err != nil {
+		return nil, err
 	}
-	logger.Info("GetFilesByUserID success", zap.Uint64("userID", userID), zap.Any("parentFolderID", parentFolderID), zap.Int("fileCount", len(files)))
-	return files, nil
+
+	files, err := s.domainService.CollectAllNormalFiles(ctx, folder.UserID, folder.ID)
+	if err != nil {
+		logger.Error("GetFolderSize: Failed to collect children for folder", zap.Uint64("folderID", folderID), zap.Error(err))
+		return nil, fmt.Errorf("file service: failed to collect folder children: %w", err)
+	}
+	return sumFolderSize(folder.ID, files), nil
 }
 
-func (s *fileService) CreateFolder(userID uint64, folderName string, parentFolderID *uint64) (*models.File, error) {
-	targetParentFolder, err := s.domainService.CheckDirectory(userID, parentFolderID)
+func (s *fileService) CreateFolder(ctx context.Context, userID uint64, folderName string, parentFolderID *uint64) (*models.File, error) {
+	folderName, err := s.domainService.NormalizeFileName(folderName)
 	if err != nil {
 		return nil, err
 	}
 
+	targetParentFolder, err := s.domainService.CheckWritableDirectory(ctx, userID, parentFolderID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 在共享文件夹中新建的文件夹归属于共享文件夹的所有者
+	ownerID := userID
+	if targetParentFolder != nil {
+		ownerID = targetParentFolder.UserID
+	}
+
 	// 用于存储父文件夹的完整路径，默认为根目录的路径 "/"
 	var parentPath string
 
@@ -149,7 +394,7 @@ func (s *fileService) CreateFolder(userID uint64, folderName string, parentFolde
 
 	// 2. 检查同一父文件夹下是否已存在同名文件夹
 	// 这是一个简单的检查，更严谨的实现可能需要查询所有子文件和文件夹的名字
-	finalFolderName, err := s.domainService.ResolveFileNameConflict(userID, parentFolderID, folderName, 0, 1) // isFolder = 1
+	finalFolderName, err := s.domainService.ResolveFileNameConflict(ctx, ownerID, parentFolderID, folderName, 0, 1) // isFolder = 1
 	if err != nil {
 		logger.Error("CreateFolder: ResolveFileNameConflict failed", zap.Error(err))
 		return nil, err // 错误已在 ResolveFileNameConflict 中记录
@@ -158,7 +403,7 @@ func (s *fileService) CreateFolder(userID uint64, folderName string, parentFolde
 	// 3. 创建文件夹记录
 	newFolder := &models.File{
 		UUID:           uuid.New().String(), // 文件夹也需要一个 UUID
-		UserID:         userID,
+		UserID:         ownerID,
 		ParentFolderID: parentFolderID,
 		FileName:       finalFolderName,
 		Path:           parentPath,
@@ -173,7 +418,7 @@ func (s *fileService) CreateFolder(userID uint64, folderName string, parentFolde
 		UpdatedAt:      time.Now(),
 	}
 
-	if err := s.fileRepo.Create(newFolder); err != nil {
+	if err := s.fileRepo.Create(ctx, newFolder); err != nil {
 		logger.Error("CreateFolder: Failed to create folder in DB",
 			zap.Uint64("userID", userID),
 			zap.Any("parentFolderID", parentFolderID),
@@ -186,31 +431,62 @@ func (s *fileService) CreateFolder(userID uint64, folderName string, parentFolde
 		zap.Uint64("folderID", newFolder.ID),
 		zap.Uint64("userID", userID),
 		zap.String("folderName", finalFolderName))
+	s.statsService.NotifyChanged(ctx, ownerID, parentPath)
 	return newFolder, nil
 }
 
-func (s *fileService) ListRecycleBinFiles(userID uint64) ([]models.File, error) {
-	files, err := s.fileRepo.FindDeletedFilesByUserID(userID)
+func (s *fileService) ListRecycleBinFiles(ctx context.Context, userID uint64, opts models.TrashListOptions) ([]models.File, string, error) {
+	if c := opts.Cursor; c != nil && c.ID != 0 && c.DeletedAt == nil {
+		return nil, "", fmt.Errorf("file service: recycle bin cursor has no deleted_at: %w", xerr.ErrInvalidParams)
+	}
+
+	// 多取一条,用来判断是否还有下一页
+	limit := opts.Limit
+	if limit > 0 {
+		opts.Limit++
+	}
+
+	files, err := s.fileRepo.FindDeletedFilesByUserID(ctx, userID, opts)
 	if err != nil {
 		logger.Error("ListRecycleBinFiles: Failed to retrieve deleted files", zap.Uint64("userID", userID), zap.Error(err))
-		return nil, fmt.Errorf("file service: failed to retrieve recycle bin files: %w", xerr.ErrDatabaseError)
+		return nil, "", fmt.Errorf("file service: failed to retrieve recycle bin files: %w", xerr.ErrDatabaseError)
 	}
-	logger.Info("ListRecycleBinFiles success", zap.Uint64("userID", userID), zap.Int("fileCount", len(files)))
-	return files, nil
+
+	var nextCursor string
+	if limit > 0 && len(files) > limit {
+		files = files[:limit]
+		nextCursor = models.TrashCursor(&files[limit-1]).Encode()
+	}
+	s.fillPurgeAt(ctx, userID, files)
+	logger.Info("ListRecycleBinFiles success", zap.Uint64("userID", userID), zap.Any("parentFolderID", opts.ParentFolderID), zap.Int("fileCount", len(files)))
+	return files, nextCursor, nil
 }
 
-func (s *fileService) RestoreFile(userID uint64, fileID uint64) error {
-	rootFile, err := s.domainService.CheckDeletedFile(userID, fileID)
+func (s *fileService) RestoreFile(ctx context.Context, userID uint64, fileID uint64, targetParentID *uint64) (*models.File, error) {
+	ctx, span := tracing.Start(ctx, "FileService.RestoreFile")
+	defer span.End()
+
+	rootFile, err := s.domainService.CheckDeletedFile(ctx, userID, fileID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	// 子项路径中记录的是删除时的位置和名称
+	oldFullPath := fullPathWithSelf(rootFile)
+
+	// 校验规则生效前保存的文件名可能不合法,恢复时一并修正
+	rootFile.FileName = s.domainService.SanitizeFileName(rootFile.FileName)
+
+	parentID, parentPath, err := s.restoreDestination(ctx, rootFile, targetParentID)
+	if err != nil {
+		return nil, err
 	}
 
-	// 检查恢复到原始位置是否会引起命名冲突
+	// 检查恢复到目标位置是否会引起命名冲突
 	// 注意：对于恢复操作，currentFileID 应该传递 0 或一个特殊值，因为恢复的文件在冲突检查时
 	// 通常被视为一个“新”文件，不应该排除自身。
-	finalFileName, err := s.domainService.ResolveFileNameConflict(userID, rootFile.ParentFolderID, rootFile.FileName, 0, rootFile.IsFolder)
+	finalFileName, err := s.domainService.ResolveFileNameConflict(ctx, userID, parentID, rootFile.FileName, 0, rootFile.IsFolder)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if finalFileName != rootFile.FileName {
 		logger.Info("RestoreFile: Naming conflict resolved for restoration",
@@ -219,26 +495,50 @@ func (s *fileService) RestoreFile(userID uint64, fileID uint64) error {
 			zap.String("finalName", finalFileName))
 	}
 	rootFile.FileName = finalFileName // 更新为最终确定的文件名
+	rootFile.ParentFolderID = parentID
+	rootFile.Path = parentPath
 
-	err = s.transactionManager.WithTransaction(context.Background(), func(tx *gorm.DB) error {
-		return s.restoreFile(userID, fileID, finalFileName)
+	err = s.transactionManager.WithTransaction(ctx, func(tx *gorm.DB) error {
+		return s.restoreFile(ctx, userID, rootFile, oldFullPath)
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
+	if rootFile.Status != models.StatusCorrupted {
+		rootFile.Status = models.StatusNormal
+	}
+	rootFile.DeletedAt = gorm.DeletedAt{}
+	s.activityService.Record(ctx, userID, fileID, models.ActivityRestore, finalFileName)
+	// 恢复的文件夹自身的统计在删除期间没有刷新,需要一起重新计算
+	s.statsService.NotifyChanged(ctx, rootFile.UserID, rootFile.Path, fullPathWithSelf(rootFile))
 
 	logger.Info("RestoreFile: File/Folder restored successfully",
 		zap.Uint64("fileID", fileID),
-		zap.String("finalName", finalFileName))
-	return nil
+		zap.String("finalName", finalFileName),
+		zap.String("path", rootFile.Path))
+	return rootFile, nil
 }
 
-func (s *fileService) RenameFile(userID uint64, fileID uint64, newFileName string) (*models.File, error) {
+func (s *fileService) RenameFile(ctx context.Context, userID uint64, fileID uint64, newFileName string, expectedVersion *uint64) (*models.File, error) {
+	ctx, span := tracing.Start(ctx, "FileService.RenameFile")
+	defer span.End()
+
+	newFileName, err := s.domainService.NormalizeFileName(newFileName)
+	if err != nil {
+		return nil, err
+	}
+
 	// 获取要改名的文件,检查文件是否处于正常状态
-	fileToRename, err := s.domainService.CheckFile(userID, fileID)
+	fileToRename, err := s.domainService.CheckWritableFile(ctx, userID, fileID)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkExpectedVersion(fileToRename, expectedVersion); err != nil {
+		return nil, err
+	}
+	if err := s.lockService.CheckLock(ctx, userID, fileID); err != nil {
+		return nil, err
+	}
 
 	// 如果新旧文件名相同，直接返回，不做任何操作
 	if fileToRename.FileName == newFileName {
@@ -247,18 +547,20 @@ func (s *fileService) RenameFile(userID uint64, fileID uint64, newFileName strin
 	}
 
 	// 处理命名冲突,检查当前目录下是否存在同名文件
-	finalFileName, err := s.domainService.ResolveFileNameConflict(userID, fileToRename.ParentFolderID, newFileName, fileToRename.ID, fileToRename.IsFolder)
+	finalFileName, err := s.domainService.ResolveFileNameConflict(ctx, fileToRename.UserID, fileToRename.ParentFolderID, newFileName, fileToRename.ID, fileToRename.IsFolder)
 	if err != nil {
 		return nil, err // 错误已在 ResolveFileNameConflict 中记录
 	}
+	oldFileName := fileToRename.FileName
 	fileToRename.FileName = finalFileName
 
-	err = s.transactionManager.WithTransaction(context.Background(), func(tx *gorm.DB) error {
-		return s.renameFile(fileToRename)
+	err = s.transactionManager.WithTransaction(ctx, func(tx *gorm.DB) error {
+		return s.renameFile(ctx, s.fileRepo.WithTx(tx), fileToRename)
 	})
 	if err != nil {
 		return nil, err
 	}
+	s.activityService.Record(ctx, fileToRename.UserID, fileID, models.ActivityRename, fmt.Sprintf("%s -> %s", oldFileName, finalFileName))
 
 	logger.Info("RenameFile: File/Folder renamed successfully",
 		zap.Uint64("fileID", fileID),
@@ -267,20 +569,44 @@ func (s *fileService) RenameFile(userID uint64, fileID uint64, newFileName strin
 	return fileToRename, nil
 }
 
-func (s *fileService) MoveFile(userID uint64, fileID uint64, targetParentID *uint64) (*models.File, error) {
+func (s *fileService) MoveFile(ctx context.Context, userID uint64, fileID uint64, targetParentID *uint64, expectedVersion *uint64, tagPropagation string) (*models.File, error) {
+	ctx, span := tracing.Start(ctx, "FileService.MoveFile")
+	defer span.End()
+
+	if err := checkTagPropagation(tagPropagation); err != nil {
+		return nil, err
+	}
+
 	// 获取要移动的文件并检查文件是否处于正常状态
-	fileToMove, err := s.domainService.CheckFile(userID, fileID)
+	fileToMove, err := s.domainService.CheckWritableFile(ctx, userID, fileID)
 	if err != nil {
-		logger.Warn("MoveFile: Cannot rename a deleted or abnormal file", zap.Uint64("fileID", fileID), zap.Uint8("status", fileToMove.Status))
+		logger.Warn("MoveFile: Cannot move a deleted or abnormal file", zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, err
+	}
+	if err := checkExpectedVersion(fileToMove, expectedVersion); err != nil {
+		return nil, err
+	}
+	if err := s.lockService.CheckTreeLock(ctx, userID, fileToMove); err != nil {
 		return nil, err
 	}
 
 	// 获取目标父文件夹信息并进行权限和状态检查
-	targetParentFolder, err := s.domainService.CheckDirectory(userID, targetParentID)
+	targetParentFolder, err := s.domainService.CheckWritableDirectory(ctx, userID, targetParentID)
 	if err != nil {
 		return nil, err
 	}
 
+	// 不支持在不同用户的空间之间移动
+	targetOwnerID := userID
+	if targetParentFolder != nil {
+		targetOwnerID = targetParentFolder.UserID
+	}
+	if targetOwnerID != fileToMove.UserID {
+		logger.Warn("MoveFile: Cannot move file across owners",
+			zap.Uint64("fileID", fileID), zap.Uint64("ownerID", fileToMove.UserID), zap.Uint64("targetOwnerID", targetOwnerID))
+		return nil, fmt.Errorf("file service: %w", xerr.ErrPermissionDenied)
+	}
+
 	// 目标路径
 	var targetParentFullPath string
 	if targetParentFolder == nil {
@@ -319,128 +645,172 @@ func (s *fileService) MoveFile(userID uint64, fileID uint64, targetParentID *uin
 	}
 
 	// 解决命名冲突问题
-	finalFileName, err := s.domainService.ResolveFileNameConflict(userID, targetParentID, fileToMove.FileName, fileID, fileToMove.IsFolder)
+	finalFileName, err := s.domainService.ResolveFileNameConflict(ctx, fileToMove.UserID, targetParentID, fileToMove.FileName, fileID, fileToMove.IsFolder)
 	if err != nil {
 		return nil, err
 	}
 	fileToMove.FileName = finalFileName
+	sourcePath := fileToMove.Path
 
-	err = s.transactionManager.WithTransaction(context.Background(), func(tx *gorm.DB) error {
-		return s.moveFile(userID, fileToMove, targetParentID, targetParentFolder)
+	err = s.transactionManager.WithTransaction(ctx, func(tx *gorm.DB) error {
+		if err := s.moveFile(ctx, s.fileRepo.WithTx(tx), fileToMove, targetParentID, targetParentFolder); err != nil {
+			return err
+		}
+		return propagateTags(repositories.NewFileTagRepository(tx), userID, []uint64{fileToMove.ID}, targetParentID, tagPropagation)
 	})
 	if err != nil {
 		return nil, err
 	}
+	s.activityService.Record(ctx, fileToMove.UserID, fileID, models.ActivityMove, fmt.Sprintf("%s -> %s", sourcePath, targetParentFullPath))
+	s.statsService.NotifyChanged(ctx, fileToMove.UserID, sourcePath, targetParentFullPath)
 
 	return fileToMove, nil
 }
 
 // 文件下载
-func (s *fileService) Download(ctx context.Context, userID uint64, fileID uint64) (*models.File, io.ReadCloser, error) {
-	file, err := s.fileRepo.FindByID(fileID)
+func (s *fileService) Download(ctx context.Context, userID uint64, fileID uint64, checksums bool) (*models.File, io.ReadCloser, error) {
+	ctx, span := tracing.Start(ctx, "FileService.Download")
+	defer span.End()
+
+	file, err := s.fileRepo.FindByID(ctx, fileID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+		if errors.Is(err, xerr.ErrFileNotFound) {
 			logger.Warn("Download: File not found in DB", zap.Uint64("fileID", fileID))
 			return nil, nil, fmt.Errorf("file service: %w", xerr.ErrFileNotFound)
 		}
 		logger.Error("Download: Error retrieving file from DB", zap.Uint64("fileID", fileID), zap.Error(err))
 		return nil, nil, fmt.Errorf("file service: failed to retrieve file: %w", xerr.ErrDatabaseError)
 	}
-	logger.Info("Download", zap.String("versionID", *file.VersionID))
 	// 如果file是文件夹,压缩成zip并下载
 	if file.IsFolder == 1 {
-		err := s.domainService.ValidateFolder(userID, file)
+		err := s.domainService.ValidateFolder(ctx, userID, file)
+		if err == nil {
+			err = s.domainService.ValidateFileAccess(ctx, userID, file, authz.ActionDownload)
+		}
 		if err != nil {
 			return nil, nil, err
 		}
-		return s.downloadFolder(ctx, userID, file)
+		folder, reader, err := s.downloadFolder(ctx, file.UserID, file, checksums)
+		if err == nil {
+			s.activityService.Record(ctx, folder.UserID, folder.ID, models.ActivityDownload, folder.FileName+".zip")
+			s.downloads.Record(ctx, folder.ID, "")
+		}
+		return folder, reader, err
 	}
 
-	err = s.domainService.ValidateFile(userID, file)
+	err = s.domainService.ValidateFileAccess(ctx, userID, file, authz.ActionDownload)
 	if err != nil {
 		return nil, nil, err // 错误已在 checkFile 中处理
 	}
-	return s.downloadFile(ctx, file)
+	// 快捷方式下载目标文件的内容
+	if file, err = s.resolveLink(ctx, userID, file); err != nil {
+		return nil, nil, err
+	}
+	if err := ensureNotQuarantined(file); err != nil {
+		return nil, nil, fmt.Errorf("file service: %w", err)
+	}
+	file, reader, err := s.downloadFile(ctx, file)
+	if err == nil {
+		s.activityService.Record(ctx, file.UserID, file.ID, models.ActivityDownload, file.FileName)
+		s.downloads.Record(ctx, file.ID, stringValue(file.VersionID))
+	}
+	return file, reader, err
 }
 
 // 文件删除
-func (s *fileService) SoftDelete(userID uint64, fileID uint64) error {
+func (s *fileService) SoftDelete(ctx context.Context, userID uint64, fileID uint64) (*models.PurgeJob, error) {
+	ctx, span := tracing.Start(ctx, "FileService.SoftDelete")
+	defer span.End()
+
 	// 验证文件
-	_, err := s.domainService.CheckFile(userID, fileID)
+	file, err := s.domainService.CheckFileAccess(ctx, userID, fileID, authz.ActionDelete)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := s.lockService.CheckTreeLock(ctx, userID, file); err != nil {
+		return nil, err
+	}
+
+	skip, err := s.inSkipRecycleBinFolder(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	if skip {
+		// 有删除权限的协作者已通过上面的校验,任务归属所有者,锁按操作者检查
+		var job *models.PurgeJob
+		err := s.transactionManager.WithTransaction(ctx, func(tx *gorm.DB) error {
+			var err error
+			job, err = s.purgeService.PurgeInTx(ctx, tx, userID, file)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		s.activityService.Record(ctx, file.UserID, fileID, models.ActivityDelete, "permanent: "+file.FileName)
+		s.statsService.NotifyChanged(ctx, file.UserID, file.Path)
+		return job, nil
 	}
 
 	// 获取所有需要删除的文件或文件夹及其所有子项
-	filesToDelete, err := s.domainService.CollectAllFiles(userID, fileID)
+	filesToDelete, err := s.domainService.CollectAllFiles(ctx, file.UserID, fileID)
 	if err != nil {
 		logger.Error("SoftDeleteFile: Failed to collect files for soft deletion", zap.Uint64("fileID", fileID), zap.Error(err))
-		return fmt.Errorf("file service: %w", err)
+		return nil, fmt.Errorf("file service: %w", err)
 	}
 
 	//需要反转文件切片,从尾部开始删除
 	slices.Reverse(filesToDelete)
-	return s.transactionManager.WithTransaction(context.Background(), func(tx *gorm.DB) error {
-		return s.performSoftDelete(userID, filesToDelete)
+	err = s.transactionManager.WithTransaction(ctx, func(tx *gorm.DB) error {
+		return s.performSoftDelete(ctx, s.fileRepo.WithTx(tx), repositories.NewFileVersionRepository(tx), file.UserID, filesToDelete)
 	})
+	if err != nil {
+		return nil, err
+	}
+	s.activityService.Record(ctx, file.UserID, fileID, models.ActivityDelete, file.FileName)
+	s.statsService.NotifyChanged(ctx, file.UserID, file.Path)
+	return nil, nil
 }
 
-func (s *fileService) PermanentDelete(userID uint64, fileID uint64) error {
-	// 验证文件
-	file, err := s.fileRepo.FindByID(fileID)
+func (s *fileService) SetSkipRecycleBin(ctx context.Context, userID uint64, folderID uint64, enabled bool) (*models.File, error) {
+	folder, err := s.domainService.CheckFile(ctx, userID, folderID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			logger.Warn("PermanentDeleteFile: File not found in DB", zap.Uint64("fileID", fileID))
-			return fmt.Errorf("domain service: %w", xerr.ErrFileNotFound)
-		}
-		logger.Error("PermanentDeleteFile: Error retrieving file from DB", zap.Uint64("fileID", fileID), zap.Error(err))
-		return fmt.Errorf("file service: failed to retrieve file: %w", xerr.ErrDatabaseError)
+		return nil, err
 	}
-
-	if userID != file.UserID {
-		logger.Warn("File access denied",
-			zap.Uint64("fileID", file.ID),
-			zap.Uint64("userID", userID),
-			zap.Uint64("ownerID", file.UserID))
-		return fmt.Errorf("file service: %w", xerr.ErrPermissionDenied)
+	if folder.IsFolder != 1 {
+		return nil, fmt.Errorf("file service: %w", xerr.ErrTargetNotFolder)
+	}
+	// 跳过回收站会影响所有协作者的删除行为,只允许所有者修改
+	if err := s.authorizer.Authorize(ctx, userID, authz.ActionManage, authz.File(folder)); err != nil {
+		return nil, fmt.Errorf("file service: only the owner can change folder settings: %w", err)
+	}
+	if folder.SkipRecycleBin == enabled {
+		return folder, nil
 	}
 
-	// 开启事务
-	return s.transactionManager.WithTransaction(context.Background(), func(tx *gorm.DB) error {
-		// 1. 更新文件状态为“待删除”
-		if err := s.fileRepo.UpdateFileStatus(fileID, models.StatusDeleting); err != nil {
-			logger.Error("PermanentDeleteFile: Failed to update file status to deleting", zap.Uint64("fileID", fileID), zap.Error(err))
-			return fmt.Errorf("file service: failed to update file status: %w", xerr.ErrDatabaseError)
-		}
-
-		// 2. 发送删除任务到 RabbitMQ
-		task := models.DeleteFileTask{
-			FileID: file.ID,
-			UserID: file.UserID,
-			OssKey: *file.OssKey,
-		}
-		taskBody, _ := json.Marshal(task)
-
-		if err := s.mqClient.Publish("delete_all_versions_queue", taskBody); err != nil {
-			logger.Error("PermanentDeleteFile: Failed to publish delete task to RabbitMQ", zap.Uint64("fileID", fileID), zap.Error(err))
-			// 注意：这里事务会回滚，文件状态将恢复。
-			return fmt.Errorf("file service: failed to publish delete task: %w", xerr.ErrMQError)
+	folder.SkipRecycleBin = enabled
+	if err := s.fileRepo.Update(ctx, folder); err != nil {
+		if errors.Is(err, xerr.ErrVersionConflict) {
+			return nil, fmt.Errorf("file service: %w", err)
 		}
-
-		logger.Info("PermanentDeleteFile: Successfully marked file for deletion and published task", zap.Uint64("fileID", fileID))
-		return nil
-	})
+		logger.Error("SetSkipRecycleBin: Failed to update folder", zap.Uint64("folderID", folderID), zap.Error(err))
+		return nil, fmt.Errorf("file service: failed to update folder: %w", xerr.ErrDatabaseError)
+	}
+	logger.Info("SetSkipRecycleBin: Folder setting updated", zap.Uint64("folderID", folderID), zap.Bool("enabled", enabled))
+	return folder, nil
 }
 
-func (s *fileService) DeleteFileVersion(userID uint64, fileID uint64, versionID string) error {
-	// 1. 验证用户是否有权访问该文件
-	file, err := s.domainService.CheckFile(userID, fileID)
+func (s *fileService) DeleteFileVersion(ctx context.Context, userID uint64, fileID uint64, versionID string) error {
+	ctx, span := tracing.Start(ctx, "FileService.DeleteFileVersion")
+	defer span.End()
+
+	// 1. 验证用户是否有权修改该文件
+	file, err := s.domainService.CheckWritableFile(ctx, userID, fileID)
 	if err != nil {
 		return err
 	}
 
 	// 2. 查找指定的版本
-	versionToDelete, err := s.fileVersionRepo.FindByVersionID(versionID)
+	versionToDelete, err := s.fileVersionRepo.FindByVersionID(ctx, versionID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return fmt.Errorf("file service: %w", xerr.ErrFileNotFound)
@@ -453,51 +823,61 @@ func (s *fileService) DeleteFileVersion(userID uint64, fileID uint64, versionID
 		return fmt.Errorf("file service: %w", xerr.ErrPermissionDenied)
 	}
 
-	// 4. 发送删除任务到 RabbitMQ
-	task := models.DeleteFileTask{
+	// 4. 写入删除任务,由 OutboxWorker 投递到 RabbitMQ
+	event, err := models.NewQueueEvent("delete_specific_version_queue", models.DeleteFileTask{
 		FileID:    file.ID,
 		UserID:    file.UserID,
+		Bucket:    versionToDelete.OssBucket,
 		OssKey:    versionToDelete.OssKey,
 		VersionID: versionToDelete.VersionID,
+	})
+	if err == nil {
+		err = s.outbox.Add(ctx, event)
 	}
-	taskBody, _ := json.Marshal(task)
-
-	if err := s.mqClient.Publish("delete_specific_version_queue", taskBody); err != nil {
-		logger.Error("DeleteFileVersion: Failed to publish delete task to RabbitMQ", zap.Uint64("fileID", fileID), zap.Error(err))
-		return fmt.Errorf("file service: failed to publish delete task: %w", xerr.ErrMQError)
+	if err != nil {
+		logger.Error("DeleteFileVersion: Failed to enqueue delete task", zap.Uint64("fileID", fileID), zap.Error(err))
+		return fmt.Errorf("file service: failed to enqueue delete task: %w", xerr.ErrDatabaseError)
 	}
 
 	logger.Info("DeleteFileVersion: Successfully deleted file version", zap.Uint64("fileID", fileID), zap.String("versionID", versionID))
 	return nil
 }
 
-func (s *fileService) ListFileVersions(userID uint64, fileID uint64) ([]models.FileVersion, error) {
+func (s *fileService) ListFileVersions(ctx context.Context, userID uint64, fileID uint64) ([]models.FileVersion, error) {
 	// 1. 验证用户是否有权访问该文件
-	if _, err := s.domainService.CheckFile(userID, fileID); err != nil {
+	if _, err := s.domainService.CheckFile(ctx, userID, fileID); err != nil {
 		return nil, err
 	}
 
 	// 2. 查询版本历史
-	versions, err := s.fileVersionRepo.FindByFileID(fileID)
+	versions, err := s.fileVersionRepo.FindByFileID(ctx, fileID)
 	if err != nil {
 		logger.Error("ListFileVersions: Failed to get file versions", zap.Uint64("fileID", fileID), zap.Error(err))
 		return nil, fmt.Errorf("file service: failed to get file versions: %w", xerr.ErrDatabaseError)
 	}
+	if counts, err := s.downloads.GetVersionDownloads(ctx, fileID); err == nil {
+		for i := range versions {
+			versions[i].Downloads = counts[versions[i].VersionID]
+		}
+	}
 
 	logger.Info("ListFileVersions: Successfully retrieved file versions", zap.Uint64("fileID", fileID), zap.Int("versionCount", len(versions)))
 	return versions, nil
 }
 
 // 还原文件版本到指定的版本,需要文件状态正常
-func (s *fileService) RestoreFileVersion(userID uint64, fileID uint64, versionID string) error {
-	// 1. 验证用户是否有权访问该文件
-	file, err := s.domainService.CheckFile(userID, fileID)
+func (s *fileService) RestoreFileVersion(ctx context.Context, userID uint64, fileID uint64, versionID string, expectedVersion *uint64) error {
+	// 1. 验证用户是否有权修改该文件
+	file, err := s.domainService.CheckWritableFile(ctx, userID, fileID)
 	if err != nil {
 		return err
 	}
+	if err := checkExpectedVersion(file, expectedVersion); err != nil {
+		return err
+	}
 
 	// 2. 查找指定的版本
-	versionToRestore, err := s.fileVersionRepo.FindByVersionID(versionID)
+	versionToRestore, err := s.fileVersionRepo.FindByVersionID(ctx, versionID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return fmt.Errorf("file service: %w", xerr.ErrFileNotFound)
@@ -511,33 +891,129 @@ func (s *fileService) RestoreFileVersion(userID uint64, fileID uint64, versionID
 	}
 
 	// 4. 更新主文件记录
+	previous := *file
 	file.Size = versionToRestore.Size
+	// 没有记录存储桶的旧版本与文件当前内容在同一个存储桶
+	if versionToRestore.OssBucket != "" {
+		file.OssBucket = &versionToRestore.OssBucket
+	}
 	file.OssKey = &versionToRestore.OssKey
 	file.VersionID = &versionToRestore.VersionID
 	file.DeletedAt = gorm.DeletedAt{}
 	file.MD5Hash = &versionToRestore.MD5Hash
-
-	if err := s.fileRepo.Update(file); err != nil {
+	// 早期版本没有记录 SHA-256
+	file.SHA256Hash = nil
+	if versionToRestore.SHA256Hash != "" {
+		file.SHA256Hash = &versionToRestore.SHA256Hash
+	}
+	// 恢复后的内容需要重新扫描
+	file.ScanStatus = initialScanStatus(s.cfg)
+
+	// 重新扫描和提取元数据的任务与文件记录在同一事务中写入
+	err = s.transactionManager.WithTransaction(ctx, func(tx *gorm.DB) error {
+		fileRepo := s.fileRepo.WithTx(tx)
+		if err := fileRepo.Update(ctx, file); err != nil {
+			return err
+		}
+		// Update 不保存扫描状态,内容替换后单独重置
+		if err := fileRepo.UpdateScanStatus(ctx, file.ID, file.ScanStatus); err != nil {
+			return err
+		}
+		return addContentTaskEvents(ctx, tx, s.cfg, file)
+	})
+	if err != nil {
+		if errors.Is(err, xerr.ErrVersionConflict) {
+			return fmt.Errorf("file service: %w", err)
+		}
 		logger.Error("RestoreFileVersion: Failed to update file record", zap.Uint64("fileID", fileID), zap.Error(err))
 		return fmt.Errorf("file service: failed to update file record: %w", xerr.ErrDatabaseError)
 	}
 
 	logger.Info("RestoreFileVersion: Successfully restored file version", zap.Uint64("fileID", fileID), zap.String("versionID", versionID))
+	removeStalePreviews(ctx, s.StorageService, &previous, file)
+	s.statsService.NotifyChanged(ctx, file.UserID, file.Path)
+	// 协作者还原时通知所有者,所有者自己还原不需要通知
+	if userID != file.UserID {
+		s.notifications.Notify(ctx, &models.Notification{
+			UserID: file.UserID,
+			Type:   models.NotificationVersionRestored,
+			Title:  "文件已被还原到历史版本",
+			Body:   fmt.Sprintf("%s 被还原到版本 %s", file.FileName, versionID),
+			FileID: &file.ID,
+		})
+	}
 	return nil
 
 }
 
-func (s *fileService) GetPresignedURLForDownload(ctx context.Context, userID uint64, fileID uint64) (string, error) {
-	// 1. 验证文件是否存在且用户有权访问
-	file, err := s.domainService.CheckFile(userID, fileID)
+func (s *fileService) GetPresignedURLForVersion(ctx context.Context, userID uint64, fileID uint64, versionID string) (string, error) {
+	// 1. 验证用户是否有权下载该文件
+	file, err := s.domainService.CheckFileAccess(ctx, userID, fileID, authz.ActionDownload)
+	if err != nil {
+		return "", err
+	}
+	if file.IsFolder == 1 {
+		return "", fmt.Errorf("file service: %w", xerr.ErrCannotDownloadFolder)
+	}
+	if err := ensureNotQuarantined(file); err != nil {
+		return "", fmt.Errorf("file service: %w", err)
+	}
+
+	// 2. 查找指定的版本,并确保版本属于该文件
+	version, err := s.fileVersionRepo.FindByVersionID(ctx, versionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", fmt.Errorf("file service: %w", xerr.ErrFileVersionNotFound)
+		}
+		logger.Error("GetPresignedURLForVersion: Failed to find file version", zap.String("versionID", versionID), zap.Error(err))
+		return "", fmt.Errorf("file service: failed to find file version: %w", xerr.ErrDatabaseError)
+	}
+	if version.FileID != file.ID {
+		return "", fmt.Errorf("file service: %w", xerr.ErrFileVersionNotFound)
+	}
+
+	// 3. 生成指向该版本对象的预签名URL
+	bucketName := s.cfg.DefaultBucketName()
+	if file.OssBucket != nil {
+		bucketName = *file.OssBucket
+	}
+	expiry := time.Duration(s.presignedURLExpiry.Load()) * time.Minute
+	opts := storage.PresignOptions{ContentDisposition: utils.ContentDisposition(file.FileName, "", false), UserID: userID}
+	presignedURL, err := s.StorageService.GeneratePresignedURL(ctx, bucketName, version.OssKey, version.VersionID, expiry, opts)
+	if err != nil {
+		logger.Error("GetPresignedURLForVersion: Failed to generate presigned URL",
+			zap.Uint64("fileID", file.ID),
+			zap.String("versionID", versionID),
+			zap.Error(err))
+		return "", fmt.Errorf("file service: failed to generate presigned URL: %w", xerr.ErrStorageError)
+	}
+
+	s.activityService.Record(ctx, file.UserID, fileID, models.ActivityDownload, fmt.Sprintf("%s (v%d)", file.FileName, version.Version))
+	s.downloads.Record(ctx, file.ID, version.VersionID)
+	return presignedURL, nil
+}
+
+func (s *fileService) GetPresignedURLForDownload(ctx context.Context, userID uint64, fileID uint64, inline bool) (string, error) {
+	ctx, span := tracing.Start(ctx, "FileService.GetPresignedURLForDownload")
+	defer span.End()
+
+	// 1. 验证文件是否存在且用户有权下载
+	file, err := s.domainService.CheckFileAccess(ctx, userID, fileID, authz.ActionDownload)
 	if err != nil {
 		return "", err // 错误已在 domainService 中包裹
 	}
+	// 快捷方式解析为目标文件,目标被删除时返回 ErrLinkTargetMissing
+	if file, err = s.resolveLink(ctx, userID, file); err != nil {
+		return "", err
+	}
 
 	// 2. 检查文件是否为文件夹，文件夹不支持生成预签名URL
 	if file.IsFolder == 1 {
 		return "", fmt.Errorf("file service: %w", xerr.ErrTargetNotFolder)
 	}
+	if err := ensureNotQuarantined(file); err != nil {
+		return "", fmt.Errorf("file service: %w", err)
+	}
 
 	// 3. 检查 OssKey 是否存在
 	if file.OssKey == nil || *file.OssKey == "" {
@@ -546,10 +1022,17 @@ func (s *fileService) GetPresignedURLForDownload(ctx context.Context, userID uin
 	}
 
 	// 4. 从配置中获取预签名URL的有效期
-	expiry := time.Duration(s.cfg.Storage.PresignedURLExpiry) * time.Minute
+	expiry := time.Duration(s.presignedURLExpiry.Load()) * time.Minute
+
+	// 5. 决定浏览器打开还是下载,inline 打开时明确指定类型,不依赖对象元数据
+	mimeType := stringValue(file.MimeType)
+	opts := storage.PresignOptions{ContentDisposition: utils.ContentDisposition(file.FileName, mimeType, inline), UserID: userID}
+	if inline && utils.IsInlineSafe(mimeType) {
+		opts.ContentType = mimeType
+	}
 
-	// 5. 调用存储服务生成预签名URL
-	presignedURL, err := s.StorageService.GeneratePresignedURL(ctx, *file.OssBucket, *file.OssKey, *file.VersionID, expiry)
+	// 6. 调用存储服务生成预签名URL
+	presignedURL, err := s.StorageService.GeneratePresignedURL(ctx, *file.OssBucket, *file.OssKey, *file.VersionID, expiry, opts)
 	if err != nil {
 		logger.Error("GetPresignedURLForDownload: Failed to generate presigned URL",
 			zap.Uint64("fileID", file.ID),
@@ -560,6 +1043,8 @@ func (s *fileService) GetPresignedURLForDownload(ctx context.Context, userID uin
 	logger.Info("GetPresignedURLForDownload: Successfully generated presigned URL",
 		zap.Uint64("fileID", fileID),
 		zap.Uint64("userID", userID))
+	s.activityService.Record(ctx, userID, fileID, models.ActivityDownload, file.FileName)
+	s.downloads.Record(ctx, file.ID, stringValue(file.VersionID))
 
 	return presignedURL, nil
 }
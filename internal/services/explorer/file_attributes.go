@@ -0,0 +1,106 @@
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxAttributesPerFile 每个文件最多保存的自定义属性数量
+	maxAttributesPerFile = 32
+	// maxAttributeValueLength 属性值的最大字符数
+	maxAttributeValueLength = 1024
+)
+
+var (
+	attributeKeyPattern   = regexp.MustCompile(`^[a-z][a-z0-9_.-]{0,63}$`)
+	attributeColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+)
+
+func (s *fileService) UpdateAttributes(ctx context.Context, userID uint64, fileID uint64, changes map[string]*string) (*models.File, error) {
+	file, err := s.domainService.CheckWritableFile(ctx, userID, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.lockService.CheckLock(ctx, userID, fileID); err != nil {
+		return nil, err
+	}
+
+	attributes := maps.Clone(file.Attributes)
+	if attributes == nil {
+		attributes = models.FileAttributes{}
+	}
+	for key, value := range changes {
+		if !attributeKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("file service: invalid attribute name %q: %w", key, xerr.ErrAttributeInvalid)
+		}
+		if value == nil {
+			delete(attributes, key)
+			continue
+		}
+		if err := validateAttributeValue(key, *value); err != nil {
+			return nil, err
+		}
+		attributes[key] = *value
+	}
+	if len(attributes) > maxAttributesPerFile {
+		return nil, fmt.Errorf("file service: too many attributes: %w", xerr.ErrAttributeInvalid)
+	}
+	if maps.Equal(attributes, file.Attributes) {
+		return file, nil
+	}
+
+	if len(attributes) == 0 {
+		attributes = nil
+	}
+	file.Attributes = attributes
+	// 属性与索引任务消息在同一事务中写入,保证搜索索引最终与属性一致
+	err = s.transactionManager.WithTransaction(ctx, func(tx *gorm.DB) error {
+		if err := s.fileRepo.WithTx(tx).Update(ctx, file); err != nil {
+			return err
+		}
+		events, err := attributesIndexTaskEvents(s.cfg, file)
+		if err != nil {
+			return err
+		}
+		return repositories.NewOutboxRepository(tx).Add(ctx, events...)
+	})
+	if err != nil {
+		if errors.Is(err, xerr.ErrVersionConflict) {
+			return nil, fmt.Errorf("file service: %w", err)
+		}
+		logger.Error("UpdateAttributes: Failed to update file", zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, fmt.Errorf("file service: failed to update file: %w", xerr.ErrDatabaseError)
+	}
+	logger.Info("UpdateAttributes: File attributes updated", zap.Uint64("fileID", fileID), zap.Int("count", len(attributes)))
+	return file, nil
+}
+
+// validateAttributeValue 属性值不能过长或包含控制字符,颜色必须是 #rrggbb 格式
+func validateAttributeValue(key, value string) error {
+	if utf8.RuneCountInString(value) > maxAttributeValueLength {
+		return fmt.Errorf("file service: attribute %q is too long: %w", key, xerr.ErrAttributeInvalid)
+	}
+	for _, r := range value {
+		// 备注允许换行
+		if unicode.IsControl(r) && r != '\n' {
+			return fmt.Errorf("file service: attribute %q contains control characters: %w", key, xerr.ErrAttributeInvalid)
+		}
+	}
+	if key == models.AttributeColor && !attributeColorPattern.MatchString(value) {
+		return fmt.Errorf("file service: color must be in #rrggbb format: %w", xerr.ErrAttributeInvalid)
+	}
+	return nil
+}
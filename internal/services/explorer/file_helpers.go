@@ -1,22 +1,25 @@
 package explorer
 
 import (
-	"archive/zip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/3Eeeecho/go-clouddisk/internal/models"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/metrics"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
 // 删除文件相关辅助函数
 // performSoftDelete 执行软删除
-func (s *fileService) performSoftDelete(userID uint64, filesToDelete []models.File) error {
+func (s *fileService) performSoftDelete(ctx context.Context, fileRepo repositories.FileRepository, fileVersionRepo repositories.FileVersionRepository, userID uint64, filesToDelete []models.File) error {
 	for _, fileToDelete := range filesToDelete {
 		// 双重检查权限
 		if fileToDelete.UserID != userID {
@@ -26,14 +29,14 @@ func (s *fileService) performSoftDelete(userID uint64, filesToDelete []models.Fi
 
 		// 如果是文件，则软删除其所有版本
 		if fileToDelete.IsFolder == 0 {
-			if err := s.fileVersionRepo.SoftDeleteByFileID(fileToDelete.ID); err != nil {
+			if err := fileVersionRepo.SoftDeleteByFileID(ctx, fileToDelete.ID); err != nil {
 				logger.Error("performSoftDelete: Failed to soft delete file versions", zap.Uint64("fileID", fileToDelete.ID), zap.Error(err))
 				return fmt.Errorf("helper: failed to soft delete file versions for file %d: %w", fileToDelete.ID, xerr.ErrDatabaseError)
 			}
 		}
 
 		// 执行软删除
-		if err := s.fileRepo.SoftDelete(fileToDelete.ID); err != nil {
+		if err := fileRepo.SoftDelete(ctx, fileToDelete.ID); err != nil {
 			logger.Error("performSoftDelete: Failed to soft delete", zap.Uint64("fileID", fileToDelete.ID), zap.Error(err))
 			return fmt.Errorf("helper: failed to soft delete file %d: %w", fileToDelete.ID, xerr.ErrDatabaseError)
 		}
@@ -46,6 +49,25 @@ func (s *fileService) performSoftDelete(userID uint64, filesToDelete []models.Fi
 	return nil
 }
 
+// inSkipRecycleBinFolder 沿父文件夹向上查找,任意上级文件夹设置了跳过回收站时返回 true
+func (s *fileService) inSkipRecycleBinFolder(ctx context.Context, file *models.File) (bool, error) {
+	for parentID := file.ParentFolderID; parentID != nil; {
+		parent, err := s.fileRepo.FindByID(ctx, *parentID)
+		if err != nil {
+			if errors.Is(err, xerr.ErrFileNotFound) {
+				return false, nil
+			}
+			logger.Error("SoftDelete: Failed to find parent folder", zap.Uint64("folderID", *parentID), zap.Error(err))
+			return false, fmt.Errorf("helper: failed to find parent folder: %w", xerr.ErrDatabaseError)
+		}
+		if parent.SkipRecycleBin {
+			return true, nil
+		}
+		parentID = parent.ParentFolderID
+	}
+	return false, nil
+}
+
 // 下载文件相关辅助函数
 func (s *fileService) downloadFile(ctx context.Context, file *models.File) (*models.File, io.ReadCloser, error) {
 	// 检查 OssKey 是否存在
@@ -63,100 +85,57 @@ func (s *fileService) downloadFile(ctx context.Context, file *models.File) (*mod
 	return file, fileContentReader, nil // 返回文件元数据和读取器
 }
 
-func (s *fileService) downloadFolder(ctx context.Context, userID uint64, rootFolder *models.File) (*models.File, io.ReadCloser, error) {
+func (s *fileService) downloadFolder(ctx context.Context, userID uint64, rootFolder *models.File, checksums bool) (*models.File, io.ReadCloser, error) {
 	// CollectAllNormalFiles 返回一个扁平化的列表,它能递归地获取一个文件夹下的所有文件和子文件夹,包括文件自身
-	filesToCompress, err := s.domainService.CollectAllNormalFiles(rootFolder.ID, userID)
+	filesToCompress, err := s.domainService.CollectAllNormalFiles(ctx, userID, rootFolder.ID)
 	if err != nil {
 		logger.Error("DownloadFolder: Failed to collect children for folder", zap.Uint64("folderID", rootFolder.ID), zap.Error(err))
 		return nil, nil, fmt.Errorf("helper: failed to collect folder children: %w", err)
 	}
 
+	// 预先统计未压缩总大小,文件夹记录自身的 Size 恒为 0,这里用它把预估大小带给调用方
+	rootFolder.Size = sumFolderSize(rootFolder.ID, filesToCompress).TotalSize
+
+	entries := make([]zipEntry, 0, len(filesToCompress))
+	for i := range filesToCompress {
+		entries = append(entries, zipEntry{
+			Name: s.domainService.GetRelativePathInZip(rootFolder, &filesToCompress[i]),
+			File: &filesToCompress[i],
+		})
+	}
+
 	// 使用 pipe 来实现流式 ZIP 压缩
 	// reader 用于从 pipe 读取 ZIP 数据，writer 用于向 pipe 写入 ZIP 数据
 	pr, pw := io.Pipe()
 	go func() {
-		defer pw.Close()
-		zipWriter := zip.NewWriter(pw)
-		defer func() {
-			if err := zipWriter.Close(); err != nil {
-				logger.Error("DownloadFolder: 关闭 ZIP 写入器失败", zap.Error(err))
-				// 如果关闭 zipWriter 失败，也通过 pipe writer 传递错误
-				pw.CloseWithError(fmt.Errorf("关闭 ZIP 写入器失败: %w", err))
-			}
-		}()
-
-		for _, fileRecord := range filesToCompress {
-			relativePath := s.domainService.GetRelativePathInZip(rootFolder, &fileRecord)
-
-			// 如果是文件夹，则在 ZIP 中创建对应的目录项
-			if fileRecord.IsFolder == 1 {
-				if !strings.HasSuffix(relativePath, "/") {
-					relativePath += "/"
-				}
-
-				if _, err := zipWriter.Create(relativePath); err != nil {
-					pw.CloseWithError(fmt.Errorf("failed to create folder entry %s: %w", relativePath, err))
-					return
-				}
-				continue
-			}
-
-			// 如果是文件，从存储中获取内容并写入 ZIP
-			if fileRecord.OssKey == nil || *fileRecord.OssKey == "" {
-				logger.Warn("DownloadFolder: 文件记录缺少存储键 OssKey,在 ZIP 中跳过",
-					zap.Uint64("fileID", fileRecord.ID),
-					zap.String("fileName", fileRecord.FileName))
-				continue // 跳过没有物理文件的记录
-			}
-
-			// 使用一个匿名函数来封装文件读取和写入 ZIP 的逻辑，确保 defer 能够及时执行
-			func() {
-				// 获取文件内容读取器，并传入 goroutine 的上下文
-				fileContentReader, getErr := s.GetFileContentReader(ctx, &fileRecord)
-				if getErr != nil {
-					logger.Error("DownloadFolder: 获取文件内容读取器失败",
-						zap.Uint64("fileID", fileRecord.ID),
-						zap.String("ossKey", *fileRecord.OssKey),
-						zap.Error(getErr))
-					return // 遇到错误立即退出匿名函数
-				}
-				defer fileContentReader.Close() // 确保每个文件读取器都被关闭
-
-				// 创建 ZIP 文件头
-				header := &zip.FileHeader{
-					Name:     relativePath,
-					Method:   zip.Deflate,          // 默认使用 Deflate 压缩方法
-					Modified: fileRecord.UpdatedAt, // 使用文件更新时间
-				}
-				// 如果你存储了文件的原始大小，可以在这里设置 header.UncompressedSize64
-				if fileRecord.Size > 0 {
-					header.UncompressedSize64 = uint64(fileRecord.Size) // 确保类型匹配
-				}
-
-				writer, err := zipWriter.CreateHeader(header)
-				if err != nil {
-					pw.CloseWithError(fmt.Errorf("为 %s 创建 ZIP 头失败: %w", relativePath, err))
-					return // 遇到错误立即退出匿名函数
-				}
-
-				// 将文件内容从读取器复制到 ZIP 写入器
-				_, err = io.Copy(writer, fileContentReader)
-				if err != nil {
-					pw.CloseWithError(fmt.Errorf("复制 %s 内容到 ZIP 失败: %w", relativePath, err))
-					return // 遇到错误立即退出匿名函数
-				}
-			}() // 立即执行匿名函数
-		}
-		// 所有文件处理完毕后，关闭 zipWriter
-		if err := zipWriter.Close(); err != nil {
-			pw.CloseWithError(fmt.Errorf("failed to close zip writer: %w", err))
+		start := time.Now()
+		prefetcher := newZipPrefetcher(s.GetFileContentReader, s.cfg.Download.ZipPrefetch, s.cfg.Download.ZipPrefetchMemory)
+		if err := writeZipArchive(ctx, pw, entries, prefetcher, checksums); err != nil {
+			logger.Error("DownloadFolder: ZIP 压缩失败", zap.Uint64("folderID", rootFolder.ID), zap.Error(err))
+			pw.CloseWithError(err)
+			return
 		}
+		pw.Close()
+		metrics.FolderZipDuration.Observe(time.Since(start).Seconds())
 		logger.Info("DownloadFolder: ZIP creation finished for folder", zap.Uint64("folderID", rootFolder.ID))
 	}()
 
 	return rootFolder, pr, nil
 }
 
+// sumFolderSize 统计文件列表中的文件数量和总大小,文件夹条目不计入
+func sumFolderSize(folderID uint64, files []models.File) *models.FolderSize {
+	size := &models.FolderSize{FolderID: folderID}
+	for _, file := range files {
+		if file.IsFolder == 1 {
+			continue
+		}
+		size.FileCount++
+		size.TotalSize += file.Size
+	}
+	return size
+}
+
 // GetFileContentReader 是一个辅助函数，用于根据存储类型获取文件内容 Reader
 // 这个函数与 DownloadFile 逻辑类似，但返回 io.ReadCloser
 func (s *fileService) GetFileContentReader(ctx context.Context, file *models.File) (io.ReadCloser, error) {
@@ -172,10 +151,8 @@ func (s *fileService) GetFileContentReader(ctx context.Context, file *models.Fil
 		bucketName = *file.OssBucket
 	} else {
 		switch storageType {
-		case "minio":
-			bucketName = s.cfg.MinIO.BucketName
-		case "aliyun_oss":
-			bucketName = s.cfg.AliyunOSS.BucketName
+		case "minio", "aliyun_oss", "s3", "local":
+			bucketName = s.cfg.DefaultBucketName()
 		// case "qiniu_kodo":
 		// 	// 七牛云通常不直接通过桶名访问，而是通过绑定的域名，但为了统一接口，此处仍保留
 		// 	bucketName = s.cfg.QiniuKodo.BucketName
@@ -188,12 +165,7 @@ func (s *fileService) GetFileContentReader(ctx context.Context, file *models.Fil
 			zap.Uint64("fileID", file.ID), zap.String("defaultBucket", bucketName))
 	}
 
-	// local存储不处理
-	if storageType == "local" {
-		return nil, fmt.Errorf("helper: %w", xerr.ErrStorageError)
-	}
-
-	// 将所有云存储类型统一处理
+	// 所有存储类型统一通过 StorageService 处理
 	logger.Info("GetFileContentReader: Attempting to get object from cloud storage",
 		zap.String("storageType", storageType),
 		zap.String("bucket", bucketName),
@@ -219,8 +191,18 @@ func (s *fileService) GetFileContentReader(ctx context.Context, file *models.Fil
 }
 
 // 文件操作相关辅助函数
-func (s *fileService) moveFile(userID uint64, fileToMove *models.File, targetParentID *uint64, targetParentFolder *models.File) error {
-	// 更改文件本身的 ParentFolderID 和 Path
+
+// checkExpectedVersion 客户端提供了期望的版本号时,确认文件在客户端读取之后没有被修改
+func checkExpectedVersion(file *models.File, expectedVersion *uint64) error {
+	if expectedVersion != nil && *expectedVersion != file.Version {
+		return fmt.Errorf("file %d is at version %d, expected %d: %w", file.ID, file.Version, *expectedVersion, xerr.ErrVersionConflict)
+	}
+	return nil
+}
+
+// moveFile 在调用方的事务中执行,fileRepo 为绑定该事务的仓库
+func (s *fileService) moveFile(ctx context.Context, fileRepo repositories.FileRepository, fileToMove *models.File, targetParentID *uint64, targetParentFolder *models.File) error {
+	// 层级只由 ParentFolderID 决定,移动只更新被移动的记录,子项的路径在读取时按新的位置计算
 	var newParentPath string
 	if targetParentID == nil {
 		newParentPath = "/"
@@ -228,14 +210,14 @@ func (s *fileService) moveFile(userID uint64, fileToMove *models.File, targetPar
 		newParentPath = targetParentFolder.Path + targetParentFolder.FileName + "/"
 	}
 
-	oldFullPathWithSelf := fileToMove.Path + fileToMove.FileName
-	newFullPathWithSelf := newParentPath + fileToMove.FileName
-
 	// 更新 fileToMove 对象的字段
 	fileToMove.ParentFolderID = targetParentID
 	fileToMove.Path = newParentPath
 
-	if err := s.fileRepo.Update(fileToMove); err != nil {
+	if err := fileRepo.Update(ctx, fileToMove); err != nil {
+		if errors.Is(err, xerr.ErrVersionConflict) {
+			return fmt.Errorf("helper: %w", err)
+		}
 		logger.Error("MoveFile: Failed to update file's parent and path in DB transaction",
 			zap.Uint64("fileID", fileToMove.ID),
 			zap.String("newName", fileToMove.FileName),
@@ -245,29 +227,17 @@ func (s *fileService) moveFile(userID uint64, fileToMove *models.File, targetPar
 	logger.Info("MoveFile: File name updated successfully in DB transaction",
 		zap.Uint64("fileID", fileToMove.ID),
 		zap.String("newName", fileToMove.FileName))
-
-	// 如果是文件夹,递归更新所有子项的path
-	if fileToMove.IsFolder == 1 {
-		// `oldChildPathPrefix` 是指被移动文件夹在旧位置的“内部”所有文件的旧父路径前缀
-		// 例如，原文件夹是 /root/old_folder/，其内部文件 file.txt 的 Path 是 /root/old_folder/
-		// 这里的 `fileToMove.Path` 是 `/root/`，`fileToMove.FileName` 是 `old_folder`
-		oldChildPathPrefix := oldFullPathWithSelf + "/" // 完整旧文件夹的逻辑路径，例如 `/user1/documents/MyOldFolder/`
-		newChildPathPrefix := newFullPathWithSelf + "/" // 完整新文件夹的逻辑路径，例如 `/user1/archive/MyOldFolder/`
-
-		// 调用 repository 批量更新 Path
-		if err := s.fileRepo.UpdateFilesPathInBatch(userID, oldChildPathPrefix, newChildPathPrefix); err != nil {
-			logger.Error("MoveFile: Failed to update children paths in DB transaction",
-				zap.Uint64("parentFolderID", fileToMove.ID), zap.Error(err))
-			return fmt.Errorf("helper: failed to update children paths: %w", xerr.ErrDatabaseError)
-		}
-	}
-
 	return nil
 }
 
-func (s *fileService) renameFile(fileToRename *models.File) error {
-	err := s.fileRepo.Update(fileToRename)
+// renameFile 在调用方的事务中执行。与移动相同只更新被重命名的记录,文件夹中子项的路径在读取时按父文件夹链计算,
+// 不需要逐个改写;缓存中的子项路径命中后也会重新计算
+func (s *fileService) renameFile(ctx context.Context, fileRepo repositories.FileRepository, fileToRename *models.File) error {
+	err := fileRepo.Update(ctx, fileToRename)
 	if err != nil {
+		if errors.Is(err, xerr.ErrVersionConflict) {
+			return fmt.Errorf("helper: %w", err)
+		}
 		logger.Error("RenameFile: Failed to update file name in DB transaction",
 			zap.Uint64("fileID", fileToRename.ID),
 			zap.String("newName", fileToRename.FileName),
@@ -277,25 +247,35 @@ func (s *fileService) renameFile(fileToRename *models.File) error {
 	return nil
 }
 
-func (s *fileService) restoreFile(userID uint64, fileID uint64, finalFileName string) error {
+// restoreFile 恢复 rootFile 及其子项,rootFile 已经填好恢复后的名称和位置。
+// oldFullPath 为删除时的完整路径,恢复位置或名称变化时子项的路径前缀一起改写
+func (s *fileService) restoreFile(ctx context.Context, userID uint64, rootFile *models.File, oldFullPath string) error {
 	// 收集所有需要恢复的文件和文件夹 (包括子项)
-	filesToRestore, err := s.domainService.CollectAllFiles(userID, fileID)
+	filesToRestore, err := s.domainService.CollectAllFiles(ctx, userID, rootFile.ID)
 	if err != nil {
-		logger.Error("RestoreFile: Failed to collect files for restoration", zap.Uint64("fileID", fileID), zap.Error(err))
+		logger.Error("RestoreFile: Failed to collect files for restoration", zap.Uint64("fileID", rootFile.ID), zap.Error(err))
 		return fmt.Errorf("helper: %w", err)
 	}
+	newFullPath := fullPathWithSelf(rootFile)
+	s.sanitizeRestoredNames(rootFile.ID, filesToRestore)
 
 	//批量恢复数据库记录
 	for _, fileToUpdate := range filesToRestore {
-		if fileToUpdate.ID == fileID {
-			fileToUpdate.FileName = finalFileName
+		if fileToUpdate.ID == rootFile.ID {
+			fileToUpdate.FileName = rootFile.FileName
+			fileToUpdate.ParentFolderID = rootFile.ParentFolderID
+			fileToUpdate.Path = rootFile.Path
+		} else if newFullPath != oldFullPath && strings.HasPrefix(fileToUpdate.Path, oldFullPath) {
+			fileToUpdate.Path = newFullPath + strings.TrimPrefix(fileToUpdate.Path, oldFullPath)
 		}
 
-		// 恢复操作：将 status 改为 1，清空 deleted_at
-		fileToUpdate.Status = 1
+		// 恢复操作：清空 deleted_at,被完整性校验标记为损坏的文件保持损坏状态
+		if fileToUpdate.Status != models.StatusCorrupted {
+			fileToUpdate.Status = models.StatusNormal
+		}
 		fileToUpdate.DeletedAt = gorm.DeletedAt{}
 
-		err = s.fileRepo.Update(&fileToUpdate)
+		err = s.fileRepo.Update(ctx, &fileToUpdate)
 		if err != nil {
 			logger.Error("RestoreFile: Failed to restore file record in DB transaction",
 				zap.Uint64("fileToUpdateID", fileToUpdate.ID),
@@ -306,3 +286,113 @@ func (s *fileService) restoreFile(userID uint64, fileID uint64, finalFileName st
 	}
 	return nil
 }
+
+// sanitizeRestoredNames 修正恢复的子项中校验规则生效前保存的不合法名称,根文件由调用方单独处理。
+// 同一文件夹中修正后与其他子项重名时添加数字后缀
+func (s *fileService) sanitizeRestoredNames(rootID uint64, files []models.File) {
+	siblings := make(map[uint64]map[string]bool)
+	for _, file := range files {
+		if file.ID == rootID || file.ParentFolderID == nil {
+			continue
+		}
+		if siblings[*file.ParentFolderID] == nil {
+			siblings[*file.ParentFolderID] = make(map[string]bool)
+		}
+		siblings[*file.ParentFolderID][file.FileName] = true
+	}
+
+	for i := range files {
+		file := &files[i]
+		if file.ID == rootID || file.ParentFolderID == nil {
+			continue
+		}
+		sanitized := s.domainService.SanitizeFileName(file.FileName)
+		if sanitized == file.FileName {
+			continue
+		}
+		names := siblings[*file.ParentFolderID]
+		finalName := uniqueName(names, sanitized, file.IsFolder)
+		logger.Info("RestoreFile: Sanitized invalid file name",
+			zap.Uint64("fileID", file.ID), zap.String("originalName", file.FileName), zap.String("finalName", finalName))
+		file.FileName = finalName
+		names[finalName] = true
+	}
+}
+
+// uniqueName 返回不在 taken 中的名称,重名时与 ResolveFileNameConflict 一样在扩展名前添加 " (n)"
+func uniqueName(taken map[string]bool, name string, isFolder uint8) string {
+	if !taken[name] {
+		return name
+	}
+	baseName, extension := name, ""
+	if isFolder == 0 {
+		if i := strings.LastIndex(name, "."); i > 0 {
+			baseName, extension = name[:i], name[i:]
+		}
+	}
+	for counter := 1; ; counter++ {
+		candidate := fmt.Sprintf("%s (%d)%s", baseName, counter, extension)
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+}
+
+// restoreDestination 返回恢复的父文件夹和父路径。指定了 targetParentID 时目标必须是所有者自己的正常文件夹;
+// 否则恢复到原父文件夹,原父文件夹已被彻底删除或仍在回收站中时恢复到根目录
+func (s *fileService) restoreDestination(ctx context.Context, file *models.File, targetParentID *uint64) (*uint64, string, error) {
+	parentID := targetParentID
+	if parentID == nil {
+		parentID = file.ParentFolderID
+	}
+	if parentID == nil {
+		return nil, "/", nil
+	}
+
+	parent, err := s.fileRepo.FindByID(ctx, *parentID)
+	if err != nil && !errors.Is(err, xerr.ErrFileNotFound) {
+		logger.Error("RestoreFile: Failed to find destination folder", zap.Uint64("fileID", file.ID), zap.Uint64("parentID", *parentID), zap.Error(err))
+		return nil, "", fmt.Errorf("helper: failed to find destination folder: %w", xerr.ErrDatabaseError)
+	}
+
+	if targetParentID == nil {
+		if err != nil || parent.Status != models.StatusNormal || parent.IsFolder != 1 {
+			logger.Info("RestoreFile: Original parent folder no longer exists, restoring to root",
+				zap.Uint64("fileID", file.ID), zap.Uint64("parentID", *parentID))
+			return nil, "/", nil
+		}
+		// 原父文件夹在删除期间可能被移动或重命名,以它当前的路径为准
+		return parentID, parent.Path + parent.FileName + "/", nil
+	}
+
+	switch {
+	case err != nil:
+		return nil, "", fmt.Errorf("helper: %w", xerr.ErrDirectoryNotFound)
+	case parent.UserID != file.UserID:
+		return nil, "", fmt.Errorf("helper: cannot restore into another user's folder: %w", xerr.ErrPermissionDenied)
+	case parent.IsFolder != 1:
+		return nil, "", fmt.Errorf("helper: %w", xerr.ErrTargetNotFolder)
+	case parent.Status != models.StatusNormal:
+		return nil, "", fmt.Errorf("helper: destination folder is not available: %w", xerr.ErrFileStatusInvalid)
+	}
+	return parentID, parent.Path + parent.FileName + "/", nil
+}
+
+// fillPurgeAt 按用户的回收站保留期计算每个文件将被彻底删除的时间,查询用户失败时不填充
+func (s *fileService) fillPurgeAt(ctx context.Context, userID uint64, files []models.File) {
+	if len(files) == 0 || !s.cfg.Trash.Enabled {
+		return
+	}
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		logger.Warn("ListRecycleBinFiles: Failed to get user trash retention", zap.Uint64("userID", userID), zap.Error(err))
+		return
+	}
+	retention := time.Duration(s.cfg.Trash.EffectiveRetentionDays(user.TrashRetentionDays)) * 24 * time.Hour
+	for i := range files {
+		if files[i].DeletedAt.Valid {
+			purgeAt := files[i].DeletedAt.Time.Add(retention)
+			files[i].PurgeAt = &purgeAt
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package explorer
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"golang.org/x/text/unicode/norm"
+)
+
+const defaultMaxFileNameLength = 255
+
+// windowsForbiddenChars Windows 文件名中不允许的字符,"/" 和 "\" 始终禁止,不在此列出
+const windowsForbiddenChars = `<>:"|?*`
+
+// reservedFileNames Windows 保留的设备名,带扩展名(如 CON.txt)同样不可用
+var reservedFileNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// NormalizeFileName 校验用户提交的文件名,返回规范化后的名称。
+// 不合法的名称会导致 ZIP 路径和 Content-Disposition 出错,直接拒绝而不是静默修改
+func (s *fileDomainService) NormalizeFileName(name string) (string, error) {
+	if !utf8.ValidString(name) {
+		return "", fmt.Errorf("domain service: file name is not valid UTF-8: %w", xerr.ErrFileNameInvalid)
+	}
+	if s.nameCfg.NormalizeNFC {
+		name = norm.NFC.String(name)
+	}
+
+	if name == "" || name == "." || name == ".." {
+		return "", fmt.Errorf("domain service: %w", xerr.ErrFileNameInvalid)
+	}
+	if length := utf8.RuneCountInString(name); length > s.maxFileNameLength() {
+		return "", fmt.Errorf("domain service: file name has %d characters: %w", length, xerr.ErrFileNameTooLong)
+	}
+	for _, r := range name {
+		if s.isForbiddenRune(r) {
+			return "", fmt.Errorf("domain service: file name contains %q: %w", r, xerr.ErrFileNameInvalid)
+		}
+	}
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return "", fmt.Errorf("domain service: file name ends with a dot or space: %w", xerr.ErrFileNameInvalid)
+	}
+	if isReservedFileName(name) {
+		return "", fmt.Errorf("domain service: %w", xerr.ErrFileNameReserved)
+	}
+	return name, nil
+}
+
+// SanitizeFileName 将不合法的文件名修正为可用的名称,用于恢复校验规则生效前保存的文件
+func (s *fileDomainService) SanitizeFileName(name string) string {
+	name = strings.ToValidUTF8(name, "_")
+	if s.nameCfg.NormalizeNFC {
+		name = norm.NFC.String(name)
+	}
+
+	name = strings.Map(func(r rune) rune {
+		if s.isForbiddenRune(r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(name, ". ")
+	if runes := []rune(name); len(runes) > s.maxFileNameLength() {
+		name = strings.TrimRight(string(runes[:s.maxFileNameLength()]), ". ")
+	}
+
+	if name == "" {
+		return "_"
+	}
+	if isReservedFileName(name) {
+		return "_" + name
+	}
+	return name
+}
+
+func (s *fileDomainService) maxFileNameLength() int {
+	if s.nameCfg.MaxLength <= 0 {
+		return defaultMaxFileNameLength
+	}
+	return s.nameCfg.MaxLength
+}
+
+func (s *fileDomainService) isForbiddenRune(r rune) bool {
+	if r == '/' || r == '\\' || unicode.IsControl(r) {
+		return true
+	}
+	return s.nameCfg.WindowsCompatible && strings.ContainsRune(windowsForbiddenChars, r)
+}
+
+// isReservedFileName 保留名称不区分大小写,只比较第一个点之前的部分
+func isReservedFileName(name string) bool {
+	base, _, _ := strings.Cut(name, ".")
+	return reservedFileNames[strings.ToUpper(strings.TrimRight(base, " "))]
+}
@@ -0,0 +1,168 @@
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/media"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/storage"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"go.uber.org/zap"
+)
+
+// MediaQueueName 图片和视频元数据提取任务队列名称
+const MediaQueueName = "media_extract_queue"
+
+// GalleryService 相册服务,跨文件夹按拍摄时间列出用户的图片和视频
+type GalleryService interface {
+	// ListGallery 分页列出图片和视频,year 为 0 时不按时间过滤,month 为 0 时返回整年
+	ListGallery(ctx context.Context, userID uint64, year, month, page, pageSize int) ([]models.GalleryItem, int64, error)
+	// ExtractMetadata 由 Worker 调用,读取文件内容提取拍摄时间和尺寸
+	ExtractMetadata(ctx context.Context, task models.ExtractMediaTask) error
+}
+
+type galleryService struct {
+	mediaRepo repositories.MediaMetadataRepository
+	fileRepo  repositories.FileRepository
+	storage   storage.StorageService
+}
+
+var _ GalleryService = (*galleryService)(nil)
+
+// NewGalleryService 创建相册服务实例
+func NewGalleryService(mediaRepo repositories.MediaMetadataRepository, fileRepo repositories.FileRepository, storage storage.StorageService) GalleryService {
+	return &galleryService{
+		mediaRepo: mediaRepo,
+		fileRepo:  fileRepo,
+		storage:   storage,
+	}
+}
+
+func (s *galleryService) ListGallery(ctx context.Context, userID uint64, year, month, page, pageSize int) ([]models.GalleryItem, int64, error) {
+	from, to, err := galleryRange(year, month)
+	if err != nil {
+		return nil, 0, fmt.Errorf("gallery service: %w", err)
+	}
+
+	files, total, err := s.mediaRepo.FindGalleryFiles(ctx, userID, from, to, page, pageSize)
+	if err != nil {
+		logger.Error("ListGallery: Failed to find gallery files", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, 0, fmt.Errorf("gallery service: %w", xerr.ErrDatabaseError)
+	}
+
+	fileIDs := make([]uint64, 0, len(files))
+	for _, file := range files {
+		fileIDs = append(fileIDs, file.ID)
+	}
+	metas, err := s.mediaRepo.FindByFileIDs(ctx, fileIDs)
+	if err != nil {
+		logger.Error("ListGallery: Failed to find media metadata", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, 0, fmt.Errorf("gallery service: %w", xerr.ErrDatabaseError)
+	}
+	metaByFile := make(map[uint64]models.MediaMetadata, len(metas))
+	for _, meta := range metas {
+		metaByFile[meta.FileID] = meta
+	}
+
+	items := make([]models.GalleryItem, 0, len(files))
+	for _, file := range files {
+		item := models.GalleryItem{File: file, TakenAt: file.CreatedAt}
+		if meta, ok := metaByFile[file.ID]; ok {
+			if meta.TakenAt != nil {
+				item.TakenAt = *meta.TakenAt
+			}
+			item.Width, item.Height, item.CameraModel = meta.Width, meta.Height, meta.CameraModel
+		}
+		items = append(items, item)
+	}
+	return items, total, nil
+}
+
+// galleryRange 把年月转换为 [from, to) 时间范围,year 为 0 时返回零值表示不过滤
+func galleryRange(year, month int) (time.Time, time.Time, error) {
+	if year == 0 {
+		if month != 0 {
+			return time.Time{}, time.Time{}, xerr.ErrInvalidParams
+		}
+		return time.Time{}, time.Time{}, nil
+	}
+	if year < 1 || year > 9999 || month < 0 || month > 12 {
+		return time.Time{}, time.Time{}, xerr.ErrInvalidParams
+	}
+	if month == 0 {
+		from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.Local)
+		return from, from.AddDate(1, 0, 0), nil
+	}
+	from := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.Local)
+	return from, from.AddDate(0, 1, 0), nil
+}
+
+func (s *galleryService) ExtractMetadata(ctx context.Context, task models.ExtractMediaTask) error {
+	file, err := s.fileRepo.FindByID(ctx, task.FileID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrFileNotFound) {
+			return nil
+		}
+		return err
+	}
+	// 任务投递后文件又上传了新版本,新版本有自己的提取任务
+	if file.VersionID != nil && *file.VersionID != task.VersionID {
+		return nil
+	}
+
+	object, err := s.storage.GetObject(ctx, task.OssBucket, task.OssKey, task.VersionID)
+	if err != nil {
+		return fmt.Errorf("failed to get object: %w", err)
+	}
+	defer object.Reader.Close()
+
+	extracted, err := media.Extract(object.Reader, task.MimeType)
+	if err != nil {
+		return fmt.Errorf("failed to extract media metadata: %w", err)
+	}
+
+	meta := &models.MediaMetadata{
+		FileID:      file.ID,
+		UserID:      file.UserID,
+		VersionID:   task.VersionID,
+		TakenAt:     extracted.TakenAt,
+		Width:       extracted.Width,
+		Height:      extracted.Height,
+		CameraModel: extracted.CameraModel,
+	}
+	if err := s.mediaRepo.Save(ctx, meta); err != nil {
+		return fmt.Errorf("failed to save media metadata: %w", err)
+	}
+	logger.Info("ExtractMetadata: Media metadata extracted", zap.Uint64("fileID", file.ID), zap.Bool("hasTakenAt", meta.TakenAt != nil))
+	return nil
+}
+
+// mediaTaskEvents 为图片和视频生成元数据提取任务消息,提取完成前相册中按上传时间排列
+func mediaTaskEvents(file *models.File) ([]models.OutboxEvent, error) {
+	if file.OssKey == nil || file.MimeType == nil || !media.IsMedia(*file.MimeType) {
+		return nil, nil
+	}
+
+	task := models.ExtractMediaTask{
+		FileID:   file.ID,
+		OssKey:   *file.OssKey,
+		MimeType: *file.MimeType,
+	}
+	if file.OssBucket != nil {
+		task.OssBucket = *file.OssBucket
+	}
+	if file.VersionID != nil {
+		task.VersionID = *file.VersionID
+	}
+
+	event, err := models.NewQueueEvent(MediaQueueName, task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal media task: %w", err)
+	}
+	return []models.OutboxEvent{event}, nil
+}
@@ -0,0 +1,128 @@
+package explorer
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/storage"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/activity"
+	"go.uber.org/zap"
+)
+
+// IntegrityService 比对存储中的文件内容与数据库记录的哈希,由完整性校验 Worker 和管理员接口使用
+type IntegrityService interface {
+	// Verify 流式读取文件当前版本的内容并计算哈希,返回不一致的说明,一致时返回空字符串。
+	// 对象在存储中不存在也视为不一致,其他读取错误返回 error
+	Verify(ctx context.Context, file *models.File) (string, error)
+	// Recheck 重新校验被标记为损坏的文件,内容已从备份修复时恢复为正常状态,仍不一致时返回 xerr.ErrFileCorrupted
+	Recheck(ctx context.Context, fileID uint64) (*models.File, error)
+}
+
+type integrityService struct {
+	fileRepo        repositories.FileRepository
+	storage         storage.StorageService
+	activityService activity.ActivityService
+	cfg             *config.Config
+}
+
+var _ IntegrityService = (*integrityService)(nil)
+
+// NewIntegrityService 创建完整性校验服务实例
+func NewIntegrityService(fileRepo repositories.FileRepository, storageService storage.StorageService, activityService activity.ActivityService, cfg *config.Config) IntegrityService {
+	return &integrityService{
+		fileRepo:        fileRepo,
+		storage:         storageService,
+		activityService: activityService,
+		cfg:             cfg,
+	}
+}
+
+// 优先比对服务端计算的 SHA-256,旧文件没有 SHA-256 时比对 MD5
+func (s *integrityService) Verify(ctx context.Context, file *models.File) (string, error) {
+	bucket := s.cfg.DefaultBucketName()
+	if file.OssBucket != nil && *file.OssBucket != "" {
+		bucket = *file.OssBucket
+	}
+	versionID := ""
+	if file.VersionID != nil {
+		versionID = *file.VersionID
+	}
+	object, err := s.storage.GetObject(ctx, bucket, *file.OssKey, versionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			return "object missing from storage", nil
+		}
+		return "", fmt.Errorf("failed to get object: %w", err)
+	}
+	defer object.Reader.Close()
+
+	md5Hasher, sha256Hasher := md5.New(), sha256.New()
+	size, err := io.Copy(io.MultiWriter(md5Hasher, sha256Hasher), object.Reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to read object: %w", err)
+	}
+
+	if uint64(size) != file.Size {
+		return fmt.Sprintf("size %d, expected %d", size, file.Size), nil
+	}
+	if file.SHA256Hash != nil && *file.SHA256Hash != "" {
+		if actual := hex.EncodeToString(sha256Hasher.Sum(nil)); !strings.EqualFold(actual, *file.SHA256Hash) {
+			return fmt.Sprintf("sha256 %s, expected %s", actual, *file.SHA256Hash), nil
+		}
+		return "", nil
+	}
+	if file.MD5Hash != nil && *file.MD5Hash != "" {
+		if actual := hex.EncodeToString(md5Hasher.Sum(nil)); !strings.EqualFold(actual, *file.MD5Hash) {
+			return fmt.Sprintf("md5 %s, expected %s", actual, *file.MD5Hash), nil
+		}
+	}
+	return "", nil
+}
+
+func (s *integrityService) Recheck(ctx context.Context, fileID uint64) (*models.File, error) {
+	file, err := s.fileRepo.FindByID(ctx, fileID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrFileNotFound) {
+			return nil, fmt.Errorf("integrity service: %w", xerr.ErrFileNotFound)
+		}
+		logger.Error("Recheck: Failed to find file", zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, fmt.Errorf("integrity service: %w", xerr.ErrDatabaseError)
+	}
+	if file.Status != models.StatusCorrupted || file.OssKey == nil {
+		return nil, fmt.Errorf("integrity service: file is not marked corrupted: %w", xerr.ErrFileStatusInvalid)
+	}
+
+	mismatch, err := s.Verify(ctx, file)
+	if err != nil {
+		logger.Error("Recheck: Failed to verify file", zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, fmt.Errorf("integrity service: %w", xerr.ErrStorageError)
+	}
+	if mismatch != "" {
+		logger.Warn("Recheck: File is still corrupted", zap.Uint64("fileID", fileID), zap.String("mismatch", mismatch))
+		return nil, fmt.Errorf("integrity service: %s: %w", mismatch, xerr.ErrFileCorrupted)
+	}
+
+	if err := s.fileRepo.UpdateFileStatus(ctx, fileID, models.StatusNormal); err != nil {
+		logger.Error("Recheck: Failed to clear corrupted status", zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, fmt.Errorf("integrity service: %w", xerr.ErrDatabaseError)
+	}
+	if err := s.fileRepo.MarkIntegrityChecked(ctx, []uint64{fileID}, time.Now()); err != nil {
+		logger.Warn("Recheck: Failed to record check time", zap.Uint64("fileID", fileID), zap.Error(err))
+	}
+	file.Status = models.StatusNormal
+	logger.Info("Recheck: Corrupted status cleared", zap.Uint64("fileID", fileID))
+	s.activityService.Record(ctx, file.UserID, file.ID, models.ActivityIntegrityOK, file.FileName)
+	return file, nil
+}
@@ -0,0 +1,339 @@
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/authz"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultLifecycleBatchSize = 500
+	defaultLifecycleMaxRules  = 20
+	// maxLifecycleReportFiles 报告中最多记录的文件数量
+	maxLifecycleReportFiles = 100
+	// lifecycleRuleScanSize 定时任务每次从数据库读取的规则数量
+	lifecycleRuleScanSize = 100
+	// lifecycleRunLockTTL 规则执行锁的有效期,实例异常退出时锁到期后自动释放
+	lifecycleRunLockTTL = 30 * time.Minute
+	// 处理失败的文件按失败次数指数退避后再重试
+	lifecycleRetryBaseDelay = time.Hour
+	lifecycleRetryMaxDelay  = 7 * 24 * time.Hour
+	// maxLifecycleErrorLength 失败记录中保存的错误信息长度上限
+	maxLifecycleErrorLength = 512
+)
+
+// LifecycleService 文件夹生命周期规则,按规则把长时间未修改的文件归档到指定文件夹或移入回收站
+type LifecycleService interface {
+	CreateRule(ctx context.Context, userID uint64, req *models.LifecycleRuleRequest) (*models.LifecycleRule, error)
+	ListRules(ctx context.Context, userID uint64) ([]models.LifecycleRule, error)
+	UpdateRule(ctx context.Context, userID, ruleID uint64, req *models.LifecycleRuleUpdateRequest) (*models.LifecycleRule, error)
+	DeleteRule(ctx context.Context, userID, ruleID uint64) error
+	// RunRule 立即执行一次规则,dryRun 为 true 时只生成报告,规则本身设置了试运行时同样只生成报告
+	RunRule(ctx context.Context, userID, ruleID uint64, dryRun bool) (*models.LifecycleReport, error)
+	// RunAll 由定时任务调用,依次执行所有启用的规则
+	RunAll(ctx context.Context)
+}
+
+type lifecycleService struct {
+	ruleRepo      repositories.LifecycleRuleRepository
+	fileService   FileService
+	domainService FileDomainService
+	authorizer    authz.Authorizer
+	cache         cache.Cache
+	cfg           *config.LifecycleConfig
+}
+
+var _ LifecycleService = (*lifecycleService)(nil)
+
+// NewLifecycleService 创建生命周期规则服务实例
+func NewLifecycleService(ruleRepo repositories.LifecycleRuleRepository, fileService FileService, domainService FileDomainService, authorizer authz.Authorizer, cache cache.Cache, cfg *config.LifecycleConfig) LifecycleService {
+	return &lifecycleService{
+		ruleRepo:      ruleRepo,
+		fileService:   fileService,
+		domainService: domainService,
+		authorizer:    authorizer,
+		cache:         cache,
+		cfg:           cfg,
+	}
+}
+
+func (s *lifecycleService) CreateRule(ctx context.Context, userID uint64, req *models.LifecycleRuleRequest) (*models.LifecycleRule, error) {
+	maxRules := s.cfg.MaxRules
+	if maxRules <= 0 {
+		maxRules = defaultLifecycleMaxRules
+	}
+	count, err := s.ruleRepo.CountByUser(ctx, userID)
+	if err != nil {
+		logger.Error("CreateRule: Failed to count lifecycle rules", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("lifecycle service: %w", xerr.ErrDatabaseError)
+	}
+	if count >= int64(maxRules) {
+		return nil, fmt.Errorf("lifecycle service: too many rules: %w", xerr.ErrLifecycleRuleInvalid)
+	}
+
+	folder, err := s.ownedFolder(ctx, userID, req.FolderID)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &models.LifecycleRule{
+		UserID:            userID,
+		FolderID:          folder.ID,
+		Action:            req.Action,
+		AgeDays:           req.AgeDays,
+		IncludeSubfolders: req.IncludeSubfolders,
+		DryRun:            req.DryRun,
+		Enabled:           true,
+	}
+	if req.Action == models.LifecycleActionArchive {
+		if req.TargetFolderID == nil {
+			return nil, fmt.Errorf("lifecycle service: archive rule requires a target folder: %w", xerr.ErrLifecycleRuleInvalid)
+		}
+		target, err := s.ownedFolder(ctx, userID, *req.TargetFolderID)
+		if err != nil {
+			return nil, err
+		}
+		// 目标文件夹位于规则范围内时,归档的文件会再次命中规则
+		if target.ID == folder.ID || strings.HasPrefix(fullPathWithSelf(target), fullPathWithSelf(folder)) {
+			return nil, fmt.Errorf("lifecycle service: target folder is inside the rule folder: %w", xerr.ErrLifecycleRuleInvalid)
+		}
+		rule.TargetFolderID = &target.ID
+	}
+
+	if err := s.ruleRepo.Create(ctx, rule); err != nil {
+		logger.Error("CreateRule: Failed to create lifecycle rule", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("lifecycle service: %w", xerr.ErrDatabaseError)
+	}
+	logger.Info("CreateRule: Lifecycle rule created", zap.Uint64("ruleID", rule.ID), zap.Uint64("folderID", folder.ID), zap.String("action", rule.Action))
+	return rule, nil
+}
+
+// ownedFolder 规则会以所有者的身份移动和删除文件,只允许为自己的文件夹创建
+func (s *lifecycleService) ownedFolder(ctx context.Context, userID, folderID uint64) (*models.File, error) {
+	folder, err := s.domainService.CheckFile(ctx, userID, folderID)
+	if err != nil {
+		return nil, err
+	}
+	if folder.IsFolder != 1 {
+		return nil, fmt.Errorf("lifecycle service: %w", xerr.ErrTargetNotFolder)
+	}
+	if err := s.authorizer.Authorize(ctx, userID, authz.ActionManage, authz.File(folder)); err != nil {
+		return nil, fmt.Errorf("lifecycle service: only the owner can manage lifecycle rules: %w", err)
+	}
+	return folder, nil
+}
+
+func (s *lifecycleService) ListRules(ctx context.Context, userID uint64) ([]models.LifecycleRule, error) {
+	rules, err := s.ruleRepo.FindByUser(ctx, userID)
+	if err != nil {
+		logger.Error("ListRules: Failed to list lifecycle rules", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("lifecycle service: %w", xerr.ErrDatabaseError)
+	}
+	return rules, nil
+}
+
+func (s *lifecycleService) UpdateRule(ctx context.Context, userID, ruleID uint64, req *models.LifecycleRuleUpdateRequest) (*models.LifecycleRule, error) {
+	rule, err := s.findRule(ctx, userID, ruleID)
+	if err != nil {
+		return nil, err
+	}
+	if req.AgeDays != nil {
+		rule.AgeDays = *req.AgeDays
+	}
+	if req.DryRun != nil {
+		rule.DryRun = *req.DryRun
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	if err := s.ruleRepo.Update(ctx, rule); err != nil {
+		logger.Error("UpdateRule: Failed to update lifecycle rule", zap.Uint64("ruleID", ruleID), zap.Error(err))
+		return nil, fmt.Errorf("lifecycle service: %w", xerr.ErrDatabaseError)
+	}
+	return rule, nil
+}
+
+func (s *lifecycleService) DeleteRule(ctx context.Context, userID, ruleID uint64) error {
+	deleted, err := s.ruleRepo.Delete(ctx, userID, ruleID)
+	if err != nil {
+		logger.Error("DeleteRule: Failed to delete lifecycle rule", zap.Uint64("ruleID", ruleID), zap.Error(err))
+		return fmt.Errorf("lifecycle service: %w", xerr.ErrDatabaseError)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("lifecycle service: %w", xerr.ErrLifecycleRuleNotFound)
+	}
+	return nil
+}
+
+// findRule 查询用户自己的规则,其他用户的规则按不存在处理
+func (s *lifecycleService) findRule(ctx context.Context, userID, ruleID uint64) (*models.LifecycleRule, error) {
+	rule, err := s.ruleRepo.FindByID(ctx, ruleID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrLifecycleRuleNotFound) {
+			return nil, fmt.Errorf("lifecycle service: %w", err)
+		}
+		logger.Error("findRule: Failed to find lifecycle rule", zap.Uint64("ruleID", ruleID), zap.Error(err))
+		return nil, fmt.Errorf("lifecycle service: %w", xerr.ErrDatabaseError)
+	}
+	if rule.UserID != userID {
+		return nil, fmt.Errorf("lifecycle service: %w", xerr.ErrLifecycleRuleNotFound)
+	}
+	return rule, nil
+}
+
+func (s *lifecycleService) RunRule(ctx context.Context, userID, ruleID uint64, dryRun bool) (*models.LifecycleReport, error) {
+	rule, err := s.findRule(ctx, userID, ruleID)
+	if err != nil {
+		return nil, err
+	}
+	return s.run(ctx, rule, dryRun || rule.DryRun)
+}
+
+func (s *lifecycleService) RunAll(ctx context.Context) {
+	var afterID uint64
+	for ctx.Err() == nil {
+		rules, err := s.ruleRepo.FindEnabled(ctx, afterID, lifecycleRuleScanSize)
+		if err != nil {
+			logger.Error("RunAll: Failed to find lifecycle rules", zap.Error(err))
+			return
+		}
+		for i := range rules {
+			// 正在由其他实例或手动执行的规则本轮跳过
+			if _, err := s.run(ctx, &rules[i], rules[i].DryRun); err != nil && !errors.Is(err, xerr.ErrLifecycleRuleRunning) {
+				logger.Error("RunAll: Failed to run lifecycle rule", zap.Uint64("ruleID", rules[i].ID), zap.Error(err))
+			}
+		}
+		if len(rules) < lifecycleRuleScanSize {
+			return
+		}
+		afterID = rules[len(rules)-1].ID
+	}
+}
+
+// run 查找超过期限的文件并通过 FileService 移动或删除,与用户手动操作一样检查锁、权限并记录活动日志。
+// 同一条规则同时只在一个实例中执行
+func (s *lifecycleService) run(ctx context.Context, rule *models.LifecycleRule, dryRun bool) (*models.LifecycleReport, error) {
+	lockKey := cache.GenerateLifecycleRuleLockKey(rule.ID)
+	locked, err := s.cache.SetNX(ctx, lockKey, 1, lifecycleRunLockTTL)
+	if err != nil {
+		logger.Error("run: Failed to acquire lifecycle rule lock", zap.Uint64("ruleID", rule.ID), zap.Error(err))
+		return nil, fmt.Errorf("lifecycle service: %w", xerr.ErrInternalServer)
+	}
+	if !locked {
+		return nil, fmt.Errorf("lifecycle service: %w", xerr.ErrLifecycleRuleRunning)
+	}
+	defer func() { _ = s.cache.Del(context.WithoutCancel(ctx), lockKey) }()
+
+	_, err = s.domainService.CheckFile(ctx, rule.UserID, rule.FolderID)
+	if err != nil {
+		// 文件夹已被删除,规则不再有效
+		if errors.Is(err, xerr.ErrFileNotFound) || errors.Is(err, xerr.ErrFileStatusInvalid) {
+			if err := s.ruleRepo.Disable(ctx, rule.ID); err != nil {
+				logger.Error("run: Failed to disable lifecycle rule", zap.Uint64("ruleID", rule.ID), zap.Error(err))
+			}
+			logger.Warn("run: Lifecycle rule folder no longer exists, rule disabled", zap.Uint64("ruleID", rule.ID), zap.Uint64("folderID", rule.FolderID))
+		}
+		return nil, err
+	}
+
+	batchSize := s.cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultLifecycleBatchSize
+	}
+	before := time.Now().AddDate(0, 0, -rule.AgeDays)
+	files, err := s.ruleRepo.FindExpiredFiles(ctx, rule, before, batchSize)
+	if err != nil {
+		logger.Error("run: Failed to find expired files", zap.Uint64("ruleID", rule.ID), zap.Error(err))
+		return nil, fmt.Errorf("lifecycle service: %w", xerr.ErrDatabaseError)
+	}
+
+	report := &models.LifecycleReport{
+		DryRun:  dryRun,
+		RunAt:   time.Now(),
+		Matched: len(files),
+		Files:   make([]models.LifecycleReportItem, 0, min(len(files), maxLifecycleReportFiles)),
+	}
+	var applied []uint64
+	for _, file := range files {
+		item := models.LifecycleReportItem{
+			FileID:    file.ID,
+			Path:      fullPathWithSelf(&file),
+			UpdatedAt: file.UpdatedAt,
+		}
+		if !dryRun {
+			if err := s.apply(ctx, rule, &file); err != nil {
+				logger.Warn("run: Failed to apply lifecycle rule to file", zap.Uint64("ruleID", rule.ID), zap.Uint64("fileID", file.ID), zap.Error(err))
+				item.Error = err.Error()
+				report.Failed++
+				s.recordFailure(ctx, rule.ID, file.ID, err)
+			} else {
+				report.Applied++
+				applied = append(applied, file.ID)
+			}
+		}
+		if len(report.Files) < maxLifecycleReportFiles {
+			report.Files = append(report.Files, item)
+		}
+	}
+
+	if err := s.ruleRepo.ClearFailures(ctx, rule.ID, applied); err != nil {
+		logger.Error("run: Failed to clear lifecycle failures", zap.Uint64("ruleID", rule.ID), zap.Error(err))
+	}
+	if err := s.ruleRepo.SaveReport(ctx, rule.ID, report); err != nil {
+		logger.Error("run: Failed to save lifecycle report", zap.Uint64("ruleID", rule.ID), zap.Error(err))
+	}
+	if report.Matched > 0 {
+		logger.Info("run: Lifecycle rule executed", zap.Uint64("ruleID", rule.ID), zap.Bool("dryRun", dryRun),
+			zap.Int("matched", report.Matched), zap.Int("applied", report.Applied), zap.Int("failed", report.Failed))
+	}
+	return report, nil
+}
+
+// recordFailure 记录处理失败的文件,下次重试的时间随失败次数翻倍
+func (s *lifecycleService) recordFailure(ctx context.Context, ruleID, fileID uint64, cause error) {
+	failure, err := s.ruleRepo.FindFailure(ctx, ruleID, fileID)
+	if err != nil {
+		logger.Error("run: Failed to find lifecycle failure", zap.Uint64("ruleID", ruleID), zap.Uint64("fileID", fileID), zap.Error(err))
+		return
+	}
+	if failure == nil {
+		failure = &models.LifecycleFailure{RuleID: ruleID, FileID: fileID}
+	}
+	failure.Attempts++
+	delay := lifecycleRetryMaxDelay
+	if failure.Attempts < 16 {
+		delay = min(lifecycleRetryBaseDelay<<(failure.Attempts-1), lifecycleRetryMaxDelay)
+	}
+	failure.NextAttemptAt = time.Now().Add(delay)
+	failure.LastError = cause.Error()
+	if len(failure.LastError) > maxLifecycleErrorLength {
+		failure.LastError = strings.ToValidUTF8(failure.LastError[:maxLifecycleErrorLength], "")
+	}
+	if err := s.ruleRepo.SaveFailure(ctx, failure); err != nil {
+		logger.Error("run: Failed to save lifecycle failure", zap.Uint64("ruleID", ruleID), zap.Uint64("fileID", fileID), zap.Error(err))
+	}
+}
+
+func (s *lifecycleService) apply(ctx context.Context, rule *models.LifecycleRule, file *models.File) error {
+	switch rule.Action {
+	case models.LifecycleActionArchive:
+		_, err := s.fileService.MoveFile(ctx, rule.UserID, file.ID, rule.TargetFolderID, nil, models.TagPropagationKeep)
+		return err
+	case models.LifecycleActionDelete:
+		_, err := s.fileService.SoftDelete(ctx, rule.UserID, file.ID)
+		return err
+	default:
+		return fmt.Errorf("lifecycle service: unknown action %q: %w", rule.Action, xerr.ErrLifecycleRuleInvalid)
+	}
+}
@@ -0,0 +1,118 @@
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func (s *fileService) CreateLink(ctx context.Context, userID uint64, targetID uint64, parentFolderID *uint64, linkName string) (*models.File, error) {
+	target, err := s.domainService.CheckFile(ctx, userID, targetID)
+	if err != nil {
+		return nil, err
+	}
+	// 指向快捷方式时直接指向最终的文件,不形成链
+	if target.IsLink {
+		if target, err = s.resolveLink(ctx, userID, target); err != nil {
+			return nil, err
+		}
+	}
+	if target.IsFolder == 1 {
+		return nil, fmt.Errorf("file service: shortcut target must be a file: %w", xerr.ErrInvalidParams)
+	}
+
+	if linkName == "" {
+		linkName = target.FileName
+	}
+	linkName, err = s.domainService.NormalizeFileName(linkName)
+	if err != nil {
+		return nil, err
+	}
+
+	parentFolder, err := s.domainService.CheckWritableDirectory(ctx, userID, parentFolderID)
+	if err != nil {
+		return nil, err
+	}
+	// 在共享文件夹中新建的快捷方式归属于共享文件夹的所有者
+	ownerID, parentPath := userID, "/"
+	if parentFolder != nil {
+		ownerID = parentFolder.UserID
+		parentPath = parentFolder.Path + parentFolder.FileName + "/"
+	}
+
+	finalName, err := s.domainService.ResolveFileNameConflict(ctx, ownerID, parentFolderID, linkName, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	link := &models.File{
+		UUID:           uuid.NewString(),
+		UserID:         ownerID,
+		ParentFolderID: parentFolderID,
+		FileName:       finalName,
+		Path:           parentPath,
+		MimeType:       target.MimeType,
+		Status:         models.StatusNormal,
+		IsLink:         true,
+		LinkTargetID:   &target.ID,
+	}
+	if err := s.fileRepo.Create(ctx, link); err != nil {
+		logger.Error("CreateLink: Failed to create shortcut", zap.Uint64("userID", userID), zap.Uint64("targetID", target.ID), zap.Error(err))
+		return nil, fmt.Errorf("file service: failed to create shortcut: %w", xerr.ErrDatabaseError)
+	}
+
+	logger.Info("CreateLink: Shortcut created", zap.Uint64("linkID", link.ID), zap.Uint64("targetID", target.ID), zap.Uint64("userID", userID))
+	s.statsService.NotifyChanged(ctx, ownerID, parentPath)
+	return link, nil
+}
+
+// resolveLink 返回快捷方式指向的文件,并检查当前用户是否仍然可以访问。
+// 目标被彻底删除或已进入回收站时返回 ErrLinkTargetMissing,不是快捷方式时原样返回
+func (s *fileService) resolveLink(ctx context.Context, userID uint64, file *models.File) (*models.File, error) {
+	if !file.IsLink {
+		return file, nil
+	}
+	if file.LinkTargetID == nil {
+		return nil, fmt.Errorf("file service: shortcut %d has no target: %w", file.ID, xerr.ErrLinkTargetMissing)
+	}
+
+	target, err := s.fileRepo.FindByID(ctx, *file.LinkTargetID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrFileNotFound) {
+			return nil, fmt.Errorf("file service: shortcut %d: %w", file.ID, xerr.ErrLinkTargetMissing)
+		}
+		logger.Error("resolveLink: Failed to find shortcut target", zap.Uint64("linkID", file.ID), zap.Error(err))
+		return nil, fmt.Errorf("file service: failed to find shortcut target: %w", xerr.ErrDatabaseError)
+	}
+	if err := s.domainService.ValidateFile(ctx, userID, target); err != nil {
+		if errors.Is(err, xerr.ErrFileStatusInvalid) {
+			return nil, fmt.Errorf("file service: shortcut %d: %w", file.ID, xerr.ErrLinkTargetMissing)
+		}
+		return nil, err
+	}
+	return target, nil
+}
+
+// markBrokenLinks 检查列表中的快捷方式,目标已不存在或当前用户无权访问时标记 LinkBroken
+func (s *fileService) markBrokenLinks(ctx context.Context, userID uint64, files []models.File) {
+	for i := range files {
+		if !files[i].IsLink {
+			continue
+		}
+		_, err := s.resolveLink(ctx, userID, &files[i])
+		switch {
+		case err == nil:
+		case errors.Is(err, xerr.ErrLinkTargetMissing), errors.Is(err, xerr.ErrPermissionDenied):
+			files[i].LinkBroken = true
+		default:
+			// 查询失败时不确定目标状态,保持未标记,下载时会返回具体错误
+			logger.Warn("markBrokenLinks: Failed to check shortcut target", zap.Uint64("linkID", files[i].ID), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package explorer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// FileLockTTL 文件锁的有效期,持有者重复加锁会续期
+const FileLockTTL = 30 * time.Minute
+
+// treeLockBatchSize 检查子树中的锁时每次管道读取的锁数量
+const treeLockBatchSize = 500
+
+// refreshLockScript 锁由 ARGV[1] 持有时写入新的锁内容并续期,保留最初的加锁时间,返回写入的锁内容;
+// 锁被其他用户持有时返回 0,锁不存在时返回 nil
+// KEYS[1]: 锁  ARGV: 用户ID, 新的锁内容, 有效期毫秒数
+var refreshLockScript = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if not value then
+	return nil
+end
+local holder = cjson.decode(value)
+if holder.user_id ~= tonumber(ARGV[1]) then
+	return 0
+end
+local lock = cjson.decode(ARGV[2])
+lock.locked_at = holder.locked_at
+local updated = cjson.encode(lock)
+redis.call('SET', KEYS[1], updated, 'PX', ARGV[3])
+return updated
+`)
+
+// FileLockService 基于 Redis 的文件编辑锁
+type FileLockService interface {
+	// Lock 为文件加锁,文件已被当前用户锁定时刷新有效期
+	Lock(ctx context.Context, userID uint64, fileID uint64) (*models.FileLock, error)
+	// Unlock 释放当前用户持有的文件锁
+	Unlock(ctx context.Context, userID uint64, fileID uint64) error
+	// CheckLock 检查文件是否被其他用户锁定,是则返回 xerr.ErrFileLocked
+	CheckLock(ctx context.Context, userID uint64, fileID uint64) error
+	// CheckTreeLock 检查文件及文件夹下的所有子项是否被其他用户锁定,任意一项被锁定则返回 xerr.ErrFileLocked
+	CheckTreeLock(ctx context.Context, userID uint64, file *models.File) error
+}
+
+type fileLockService struct {
+	cache         *cache.RedisCache
+	domainService FileDomainService
+}
+
+var _ FileLockService = (*fileLockService)(nil)
+
+// NewFileLockService 创建文件锁服务实例
+func NewFileLockService(c *cache.RedisCache, domainService FileDomainService) FileLockService {
+	return &fileLockService{
+		cache:         c,
+		domainService: domainService,
+	}
+}
+
+func (s *fileLockService) Lock(ctx context.Context, userID uint64, fileID uint64) (*models.FileLock, error) {
+	if _, err := s.domainService.CheckWritableFile(ctx, userID, fileID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	lock := &models.FileLock{
+		FileID:    fileID,
+		UserID:    userID,
+		LockedAt:  now,
+		ExpiresAt: now.Add(FileLockTTL),
+	}
+
+	key := cache.GenerateFileLockKey(fileID)
+	ok, err := s.cache.SetNX(ctx, key, lock, FileLockTTL)
+	if err != nil {
+		logger.Error("Lock: Failed to acquire file lock", zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, fmt.Errorf("lock service: %w", xerr.ErrInternalServer)
+	}
+	if ok {
+		return lock, nil
+	}
+
+	// 锁已存在,只有持有者本人可以续期,比较持有者和续期在同一个脚本中完成
+	value, err := json.Marshal(lock)
+	if err != nil {
+		logger.Error("Lock: Failed to marshal file lock", zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, fmt.Errorf("lock service: %w", xerr.ErrInternalServer)
+	}
+	result, err := s.cache.RunScript(ctx, refreshLockScript, []string{key}, userID, value, FileLockTTL.Milliseconds()).Result()
+	if errors.Is(err, redis.Nil) {
+		// 锁在 SetNX 之后恰好过期或被释放,重新加锁
+		ok, err = s.cache.SetNX(ctx, key, lock, FileLockTTL)
+		if err != nil {
+			logger.Error("Lock: Failed to acquire file lock", zap.Uint64("fileID", fileID), zap.Error(err))
+			return nil, fmt.Errorf("lock service: %w", xerr.ErrInternalServer)
+		}
+		if !ok {
+			return nil, fmt.Errorf("lock service: %w", xerr.ErrFileLocked)
+		}
+		return lock, nil
+	}
+	if err != nil {
+		logger.Error("Lock: Failed to refresh file lock", zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, fmt.Errorf("lock service: %w", xerr.ErrInternalServer)
+	}
+	updated, isLock := result.(string)
+	if !isLock {
+		return nil, fmt.Errorf("lock service: %w", xerr.ErrFileLocked)
+	}
+	if err := json.Unmarshal([]byte(updated), lock); err != nil {
+		logger.Error("Lock: Failed to unmarshal refreshed lock", zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, fmt.Errorf("lock service: %w", xerr.ErrInternalServer)
+	}
+	return lock, nil
+}
+
+func (s *fileLockService) Unlock(ctx context.Context, userID uint64, fileID uint64) error {
+	if _, err := s.domainService.CheckWritableFile(ctx, userID, fileID); err != nil {
+		return err
+	}
+
+	holder, err := s.getLock(ctx, fileID)
+	if err != nil {
+		return err
+	}
+	// 未加锁时视为解锁成功
+	if holder == nil {
+		return nil
+	}
+	if holder.UserID != userID {
+		return fmt.Errorf("lock service: %w", xerr.ErrFileLocked)
+	}
+
+	if err := s.cache.Del(ctx, cache.GenerateFileLockKey(fileID)); err != nil {
+		logger.Error("Unlock: Failed to release file lock", zap.Uint64("fileID", fileID), zap.Error(err))
+		return fmt.Errorf("lock service: %w", xerr.ErrInternalServer)
+	}
+	return nil
+}
+
+func (s *fileLockService) CheckLock(ctx context.Context, userID uint64, fileID uint64) error {
+	holder, err := s.getLock(ctx, fileID)
+	if err != nil {
+		return err
+	}
+	if holder != nil && holder.UserID != userID {
+		logger.Warn("CheckLock: File is locked by another user",
+			zap.Uint64("fileID", fileID), zap.Uint64("userID", userID), zap.Uint64("holderID", holder.UserID))
+		return fmt.Errorf("lock service: %w", xerr.ErrFileLocked)
+	}
+	return nil
+}
+
+func (s *fileLockService) CheckTreeLock(ctx context.Context, userID uint64, file *models.File) error {
+	if err := s.CheckLock(ctx, userID, file.ID); err != nil {
+		return err
+	}
+	if file.IsFolder != 1 {
+		return nil
+	}
+
+	children, err := s.domainService.collectChildrenRecursively(ctx, file.UserID, file.ID)
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(children); start += treeLockBatchSize {
+		end := min(start+treeLockBatchSize, len(children))
+		pipe := s.cache.TxPipeline()
+		cmds := make([]*redis.StringCmd, 0, end-start)
+		for _, child := range children[start:end] {
+			cmds = append(cmds, pipe.Get(ctx, cache.GenerateFileLockKey(child.ID)))
+		}
+		if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+			logger.Error("CheckTreeLock: Failed to read descendant locks", zap.Uint64("folderID", file.ID), zap.Error(err))
+			return fmt.Errorf("lock service: %w", xerr.ErrInternalServer)
+		}
+
+		for i, cmd := range cmds {
+			data, err := cmd.Bytes()
+			if errors.Is(err, redis.Nil) {
+				continue // 未加锁
+			}
+			if err != nil {
+				logger.Error("CheckTreeLock: Failed to read file lock", zap.Uint64("fileID", children[start+i].ID), zap.Error(err))
+				return fmt.Errorf("lock service: %w", xerr.ErrInternalServer)
+			}
+			var holder models.FileLock
+			if err := json.Unmarshal(data, &holder); err != nil {
+				logger.Error("CheckTreeLock: Failed to unmarshal file lock", zap.Uint64("fileID", children[start+i].ID), zap.Error(err))
+				return fmt.Errorf("lock service: %w", xerr.ErrInternalServer)
+			}
+			if holder.UserID != userID {
+				logger.Warn("CheckTreeLock: Descendant is locked by another user",
+					zap.Uint64("folderID", file.ID), zap.Uint64("fileID", children[start+i].ID),
+					zap.Uint64("userID", userID), zap.Uint64("holderID", holder.UserID))
+				return fmt.Errorf("lock service: %w", xerr.ErrFileLocked)
+			}
+		}
+	}
+	return nil
+}
+
+// getLock 读取文件当前的锁,未加锁时返回 nil
+func (s *fileLockService) getLock(ctx context.Context, fileID uint64) (*models.FileLock, error) {
+	var lock models.FileLock
+	if err := s.cache.Get(ctx, cache.GenerateFileLockKey(fileID), &lock); err != nil {
+		if errors.Is(err, cache.ErrCacheMiss) {
+			return nil, nil
+		}
+		logger.Error("getLock: Failed to read file lock", zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, fmt.Errorf("lock service: %w", xerr.ErrInternalServer)
+	}
+	return &lock, nil
+}
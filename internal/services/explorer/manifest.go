@@ -0,0 +1,75 @@
+package explorer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"go.uber.org/zap"
+)
+
+// GetManifest 一次查询取出整个子树,按层级从浅到深排列,客户端可以逐行建立目录结构。
+// 快捷方式没有内容,父文件夹不在正常状态的条目(如位于回收站中的文件夹下)也不会同步,都不包含在清单中
+func (s *fileService) GetManifest(ctx context.Context, userID uint64, folderID *uint64) ([]models.ManifestEntry, error) {
+	var files []models.File
+	var err error
+	if folderID == nil {
+		files, err = s.fileRepo.FindAllByUserID(ctx, userID)
+	} else {
+		folder, checkErr := s.domainService.CheckFile(ctx, userID, *folderID)
+		if checkErr != nil {
+			return nil, checkErr
+		}
+		if err := s.domainService.ValidateFolder(ctx, userID, folder); err != nil {
+			return nil, err
+		}
+		files, err = s.fileRepo.FindDescendants(ctx, folder.UserID, folder.ID, false)
+	}
+	if err != nil {
+		logger.Error("GetManifest: Failed to collect files", zap.Uint64("userID", userID), zap.Any("folderID", folderID), zap.Error(err))
+		return nil, fmt.Errorf("file service: failed to collect files: %w", xerr.ErrDatabaseError)
+	}
+
+	children := make(map[uint64][]*models.File)
+	var rootItems []*models.File
+	for i := range files {
+		file := &files[i]
+		if file.Status != models.StatusNormal || file.IsLink {
+			continue
+		}
+		switch {
+		case file.ParentFolderID == nil && folderID == nil:
+			rootItems = append(rootItems, file)
+		case file.ParentFolderID != nil && folderID != nil && *file.ParentFolderID == *folderID:
+			rootItems = append(rootItems, file)
+		case file.ParentFolderID != nil:
+			children[*file.ParentFolderID] = append(children[*file.ParentFolderID], file)
+		}
+	}
+
+	// 从起点逐层展开,只有能从起点到达的条目才会出现在清单中
+	entries := make([]models.ManifestEntry, 0, len(files))
+	queue := rootItems
+	for len(queue) > 0 {
+		file := queue[0]
+		queue = queue[1:]
+		entry := models.ManifestEntry{
+			ID:       file.ID,
+			ParentID: file.ParentFolderID,
+			Name:     file.FileName,
+			IsFolder: file.IsFolder,
+			Size:     file.Size,
+			MTime:    file.UpdatedAt.Unix(),
+		}
+		if file.SHA256Hash != nil {
+			entry.SHA256 = *file.SHA256Hash
+		}
+		entries = append(entries, entry)
+		if file.IsFolder == 1 {
+			queue = append(queue, children[file.ID]...)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,479 @@
+package explorer
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/storage"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/authz"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultOfficeSessionTTL      = 480 // 分钟
+	defaultOfficeDownloadTimeout = 120 // 秒
+	// officeCallbackPath 文档服务器回调的接口路径,需与路由保持一致
+	officeCallbackPath = "/api/v1/office/callback"
+)
+
+// OfficeService 通过 OnlyOffice Docs 在线编辑 docx/xlsx/pptx 文件,编辑结果保存为文件的新版本
+type OfficeService interface {
+	// CreateSession 创建编辑会话,有写权限且文件未被他人锁定时以编辑模式打开,否则只读打开
+	CreateSession(ctx context.Context, userID uint64, fileID uint64) (*models.OfficeSession, error)
+	// HandleCallback 处理文档服务器的回调,callbackToken 为回调地址中的 token 参数,
+	// authHeader 为请求的 Authorization 头,文档关闭且有修改时把编辑结果保存为新版本
+	HandleCallback(ctx context.Context, callbackToken, authHeader string, callback *models.OfficeCallback) error
+}
+
+type officeService struct {
+	fileRepo        repositories.FileRepository
+	fileVersionRepo repositories.FileVersionRepository
+	userRepo        repositories.UserRepository
+	domainService   FileDomainService
+	tm              TransactionManager
+	storage         storage.StorageService
+	deps            UploadServiceDeps
+	httpClient      *http.Client
+}
+
+var _ OfficeService = (*officeService)(nil)
+
+// officeCallbackClaims 回调地址中的 token,绑定打开会话的用户和文档版本
+type officeCallbackClaims struct {
+	FileID  uint64 `json:"file_id"`
+	UserID  uint64 `json:"user_id"`
+	Key     string `json:"key"`
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// NewOfficeService 创建在线编辑服务实例,保存编辑结果与上传新版本使用相同的依赖
+func NewOfficeService(
+	fileRepo repositories.FileRepository,
+	fileVersionRepo repositories.FileVersionRepository,
+	userRepo repositories.UserRepository,
+	domainService FileDomainService,
+	tm TransactionManager,
+	ss storage.StorageService,
+	deps UploadServiceDeps,
+) OfficeService {
+	timeout := deps.Config.Office.DownloadTimeout
+	if timeout <= 0 {
+		timeout = defaultOfficeDownloadTimeout
+	}
+	return &officeService{
+		fileRepo:        fileRepo,
+		fileVersionRepo: fileVersionRepo,
+		userRepo:        userRepo,
+		domainService:   domainService,
+		tm:              tm,
+		storage:         ss,
+		deps:            deps,
+		httpClient:      &http.Client{Timeout: time.Duration(timeout) * time.Second},
+	}
+}
+
+// enabled 未配置文档服务器地址或共享密钥时不提供在线编辑,避免接受未签名的回调
+func (s *officeService) enabled() bool {
+	cfg := s.deps.Config.Office
+	return cfg.Enabled && cfg.DocumentServerURL != "" && cfg.Secret != ""
+}
+
+func (s *officeService) CreateSession(ctx context.Context, userID uint64, fileID uint64) (*models.OfficeSession, error) {
+	if !s.enabled() {
+		return nil, fmt.Errorf("office service: office editing disabled: %w", xerr.ErrOfficeNotSupported)
+	}
+
+	file, err := s.domainService.CheckFile(ctx, userID, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if file.IsFolder == 1 {
+		return nil, fmt.Errorf("office service: %w", xerr.ErrCannotDownloadFolder)
+	}
+	if err := ensureNotQuarantined(file); err != nil {
+		return nil, fmt.Errorf("office service: %w", err)
+	}
+	fileType := models.OfficeFileType(file.FileName)
+	documentType, ok := models.OfficeDocumentTypes[fileType]
+	if !ok {
+		return nil, fmt.Errorf("office service: unsupported file type %q: %w", fileType, xerr.ErrOfficeNotSupported)
+	}
+	if file.OssBucket == nil || file.OssKey == nil {
+		logger.Error("CreateSession: File record has no storage location", zap.Uint64("fileID", fileID))
+		return nil, fmt.Errorf("office service: %w", xerr.ErrStorageError)
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 只读访问的协作者和被他人锁定的文件以查看模式打开
+	canEdit := s.domainService.ValidateFileAccess(ctx, userID, file, authz.ActionWrite) == nil &&
+		s.deps.Lock.CheckLock(ctx, userID, file.ID) == nil
+
+	ttl := s.deps.Config.Office.SessionTTL
+	if ttl <= 0 {
+		ttl = defaultOfficeSessionTTL
+	}
+	// 文档由文档服务器下载,不使用离用户最近的存储入口
+	documentURL, err := s.storage.GeneratePresignedURL(storage.WithDefaultRegion(ctx), *file.OssBucket, *file.OssKey, stringValue(file.VersionID), time.Duration(ttl)*time.Minute, storage.PresignOptions{})
+	if err != nil {
+		logger.Error("CreateSession: Failed to generate document URL", zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, fmt.Errorf("office service: failed to generate document URL: %w", xerr.ErrStorageError)
+	}
+
+	key := officeDocumentKey(file)
+	editorConfig := &models.OfficeEditorConfig{
+		Document: models.OfficeDocument{
+			FileType:    fileType,
+			Key:         key,
+			Title:       file.FileName,
+			URL:         documentURL,
+			Permissions: models.OfficePermissions{Edit: canEdit, Download: true},
+		},
+		DocumentType: documentType,
+		EditorConfig: models.OfficeEditor{
+			Mode: "view",
+			User: models.OfficeUser{ID: strconv.FormatUint(userID, 10), Name: user.Username},
+		},
+	}
+	if canEdit {
+		callbackURL, err := s.callbackURL(file.ID, userID, key, time.Duration(ttl)*time.Minute)
+		if err != nil {
+			return nil, err
+		}
+		editorConfig.EditorConfig.Mode = "edit"
+		editorConfig.EditorConfig.CallbackURL = callbackURL
+	}
+
+	token, err := s.signEditorConfig(editorConfig, time.Duration(ttl)*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	editorConfig.Token = token
+
+	logger.Info("CreateSession: Office session created", zap.Uint64("fileID", fileID), zap.Uint64("userID", userID), zap.Bool("edit", canEdit))
+	return &models.OfficeSession{
+		APIURL: strings.TrimRight(s.deps.Config.Office.DocumentServerURL, "/") + "/web-apps/apps/api/documents/api.js",
+		Config: editorConfig,
+	}, nil
+}
+
+func (s *officeService) HandleCallback(ctx context.Context, callbackToken, authHeader string, callback *models.OfficeCallback) error {
+	if !s.enabled() {
+		return fmt.Errorf("office service: office editing disabled: %w", xerr.ErrOfficeNotSupported)
+	}
+
+	claims, err := s.parseCallbackToken(callbackToken)
+	if err != nil {
+		return err
+	}
+	// 只信任文档服务器签名的内容
+	payload, err := s.verifyCallback(authHeader, callback)
+	if err != nil {
+		return err
+	}
+	if payload.Key != claims.Key {
+		logger.Warn("HandleCallback: Document key does not match callback token", zap.Uint64("fileID", claims.FileID), zap.String("key", payload.Key))
+		return fmt.Errorf("office service: document key mismatch: %w", xerr.ErrTokenInvalid)
+	}
+
+	switch payload.Status {
+	case models.OfficeStatusReadyForSave:
+	case models.OfficeStatusSaveError, models.OfficeStatusForceSaveError:
+		logger.Warn("HandleCallback: Document server failed to save document", zap.Uint64("fileID", claims.FileID), zap.Int("status", payload.Status))
+		return nil
+	default:
+		// 编辑中、无修改关闭和强制保存都不产生新版本,强制保存的内容会在文档关闭时一起保存
+		return nil
+	}
+
+	ctx = utils.WithActorID(ctx, claims.UserID)
+	return s.saveEditedDocument(ctx, claims, payload.URL)
+}
+
+// saveEditedDocument 下载编辑结果并保存为文件的新版本,保存前重新检查编辑者的写权限和文件锁
+func (s *officeService) saveEditedDocument(ctx context.Context, claims *officeCallbackClaims, documentURL string) error {
+	file, err := s.fileRepo.FindByID(ctx, claims.FileID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrFileNotFound) {
+			return fmt.Errorf("office service: %w", xerr.ErrFileNotFound)
+		}
+		return fmt.Errorf("office service: failed to find file: %w", xerr.ErrDatabaseError)
+	}
+	if err := s.domainService.ValidateFileAccess(ctx, claims.UserID, file, authz.ActionWrite); err != nil {
+		return err
+	}
+	if err := s.deps.Lock.CheckLock(ctx, claims.UserID, file.ID); err != nil {
+		return err
+	}
+	if officeDocumentKey(file) != claims.Key {
+		logger.Warn("HandleCallback: File changed while being edited", zap.Uint64("fileID", file.ID))
+		return fmt.Errorf("office service: file changed while being edited: %w", xerr.ErrVersionConflict)
+	}
+
+	object, err := s.storeEditedDocument(ctx, file, documentURL)
+	if err != nil {
+		return err
+	}
+
+	err = s.tm.WithTransaction(ctx, func(tx *gorm.DB) error {
+		fileRepo := repositories.NewCachedFileRepository(repositories.NewDBFileRepository(tx), s.deps.Cache, repositories.NewOutboxRepository(tx))
+		fileVersionRepo := repositories.NewFileVersionRepository(tx)
+
+		// 下载期间文件可能被其他请求修改
+		current, err := fileRepo.FindByID(ctx, file.ID)
+		if err != nil {
+			return fmt.Errorf("failed to reload file: %w", err)
+		}
+		if officeDocumentKey(current) != claims.Key {
+			return fmt.Errorf("office service: file changed while being edited: %w", xerr.ErrVersionConflict)
+		}
+		// 团队文档保存后变大时检查团队空间的配额
+		growth := uint64(object.Result.Size)
+		growth -= min(growth, current.Size)
+		if err := s.deps.Stats.EnforceOrgQuota(ctx, current.UserID, growth); err != nil {
+			return err
+		}
+
+		newVersionNumber := 1
+		latestVersion, err := fileVersionRepo.FindLatestVersion(ctx, current.ID)
+		if err == nil {
+			newVersionNumber = int(latestVersion.Version) + 1
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to find latest version: %w", err)
+		}
+		if err := fileVersionRepo.Create(ctx, &models.FileVersion{
+			FileID:     current.ID,
+			Version:    uint(newVersionNumber),
+			Size:       uint64(object.Result.Size),
+			OssBucket:  object.Result.Bucket,
+			OssKey:     object.Result.Key,
+			VersionID:  object.Result.VersionID,
+			MD5Hash:    object.MD5Hash,
+			SHA256Hash: object.SHA256Hash,
+		}); err != nil {
+			return fmt.Errorf("failed to create new file version: %w", err)
+		}
+
+		current.Size = uint64(object.Result.Size)
+		current.MD5Hash = &object.MD5Hash
+		current.SHA256Hash = &object.SHA256Hash
+		current.OssBucket = &object.Result.Bucket
+		current.OssKey = &object.Result.Key
+		current.VersionID = &object.Result.VersionID
+		current.ScanStatus = initialScanStatus(s.deps.Config)
+		if err := fileRepo.Update(ctx, current); err != nil {
+			return fmt.Errorf("failed to update main file record: %w", err)
+		}
+		// Update 不保存扫描状态,内容替换后单独重置
+		if err := fileRepo.UpdateScanStatus(ctx, current.ID, current.ScanStatus); err != nil {
+			return fmt.Errorf("failed to reset scan status: %w", err)
+		}
+		file = current
+		return addContentTaskEvents(ctx, tx, s.deps.Config, file)
+	})
+	if err != nil {
+		s.removeUnreferencedObject(ctx, object.Result.Bucket, object.Result.Key, object.Result.VersionID)
+		if errors.Is(err, xerr.ErrVersionConflict) || errors.Is(err, xerr.ErrQuotaExceeded) {
+			return err
+		}
+		logger.Error("HandleCallback: Failed to save edited document", zap.Uint64("fileID", file.ID), zap.Error(err))
+		return fmt.Errorf("office service: failed to save edited document: %w", xerr.ErrDatabaseError)
+	}
+
+	logger.Info("HandleCallback: Edited document saved as new version", zap.Uint64("fileID", file.ID), zap.Uint64("editorID", claims.UserID))
+	s.deps.Activity.Record(ctx, file.UserID, file.ID, models.ActivityEdit, file.FileName)
+	s.deps.Stats.NotifyChanged(ctx, file.UserID, file.Path)
+	return nil
+}
+
+// storeEditedDocument 先把编辑结果下载到临时文件并计算哈希,得到确切大小后再写入存储
+func (s *officeService) storeEditedDocument(ctx context.Context, file *models.File, documentURL string) (*uploadedObject, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, documentURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("office service: invalid document URL: %w", xerr.ErrInvalidParams)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		logger.Error("HandleCallback: Failed to download edited document", zap.Uint64("fileID", file.ID), zap.Error(err))
+		return nil, fmt.Errorf("office service: failed to download edited document: %w", xerr.ErrStorageError)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		logger.Error("HandleCallback: Document server returned unexpected status", zap.Uint64("fileID", file.ID), zap.Int("status", resp.StatusCode))
+		return nil, fmt.Errorf("office service: failed to download edited document: %w", xerr.ErrStorageError)
+	}
+
+	tmp, err := os.CreateTemp("", fmt.Sprintf("office-%d-*", file.ID))
+	if err != nil {
+		return nil, fmt.Errorf("office service: failed to create temp file: %w", err)
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	var body io.Reader = resp.Body
+	maxSize := s.deps.Config.Office.MaxFileSize
+	if maxSize > 0 {
+		body = io.LimitReader(resp.Body, maxSize+1)
+	}
+	md5Hasher, sha256Hasher := md5.New(), sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, md5Hasher, sha256Hasher), body)
+	if err != nil {
+		logger.Error("HandleCallback: Failed to download edited document", zap.Uint64("fileID", file.ID), zap.Error(err))
+		return nil, fmt.Errorf("office service: failed to download edited document: %w", xerr.ErrStorageError)
+	}
+	if maxSize > 0 && size > maxSize {
+		return nil, fmt.Errorf("office service: edited document exceeds %d bytes: %w", maxSize, xerr.ErrFileTooLarge)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("office service: failed to rewind temp file: %w", err)
+	}
+
+	object := &uploadedObject{
+		MD5Hash:    hex.EncodeToString(md5Hasher.Sum(nil)),
+		SHA256Hash: hex.EncodeToString(sha256Hasher.Sum(nil)),
+		MimeType:   stringValue(file.MimeType),
+	}
+	objectName := s.storage.GetUploadObjName(object.MD5Hash, file.FileName)
+	object.Result, err = s.storage.PutObject(ctx, s.deps.Buckets.SelectBucket(file.UserID, time.Now()), objectName, tmp, size, object.MimeType)
+	if err != nil {
+		logger.Error("HandleCallback: Failed to store edited document", zap.Uint64("fileID", file.ID), zap.Error(err))
+		return nil, fmt.Errorf("office service: failed to store edited document: %w", xerr.ErrStorageError)
+	}
+	return object, nil
+}
+
+// removeUnreferencedObject 对象不再被任何版本记录引用时删除物理文件，失败只记录日志
+func (s *officeService) removeUnreferencedObject(ctx context.Context, bucket, key, versionID string) {
+	referenced, err := s.deps.Objects.IsReferenced(ctx, bucket, key, versionID)
+	if err != nil || referenced {
+		return
+	}
+	if err := s.storage.RemoveObject(ctx, bucket, key, versionID); err != nil {
+		logger.Warn("HandleCallback: Failed to remove unreferenced object", zap.String("key", key), zap.Error(err))
+	}
+}
+
+// callbackURL 生成文档服务器保存时回调的地址,token 使用在线编辑的密钥签名并与编辑会话同时过期,
+// 即使泄露也不能当作登录 Token 使用
+func (s *officeService) callbackURL(fileID, userID uint64, key string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := &officeCallbackClaims{
+		FileID:  fileID,
+		UserID:  userID,
+		Key:     key,
+		Purpose: utils.TokenPurposeOfficeCallback,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    s.deps.Config.JWT.Issuer,
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.deps.Config.Office.Secret))
+	if err != nil {
+		return "", fmt.Errorf("office service: failed to sign callback token: %w", err)
+	}
+	return strings.TrimRight(s.deps.Config.Office.CallbackBaseURL, "/") + officeCallbackPath + "?token=" + token, nil
+}
+
+// parseCallbackToken 校验回调地址中的 token 的签名、有效期和用途。
+// 文档保存后 key 随内容改变,旧 token 无法再用于保存
+func (s *officeService) parseCallbackToken(tokenString string) (*officeCallbackClaims, error) {
+	claims := &officeCallbackClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (any, error) {
+		return []byte(s.deps.Config.Office.Secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || claims.Purpose != utils.TokenPurposeOfficeCallback || claims.FileID == 0 || claims.Key == "" {
+		return nil, fmt.Errorf("office service: invalid callback token: %w", xerr.ErrTokenInvalid)
+	}
+	return claims, nil
+}
+
+// verifyCallback 使用共享密钥校验文档服务器的签名,返回签名中的回调内容。
+// 签名在请求体的 token 字段中,或在 Authorization 头中以 payload 字段包裹
+func (s *officeService) verifyCallback(authHeader string, callback *models.OfficeCallback) (*models.OfficeCallback, error) {
+	tokenString, wrapped := callback.Token, false
+	if tokenString == "" {
+		tokenString, wrapped = strings.TrimPrefix(authHeader, "Bearer "), true
+	}
+	if tokenString == "" {
+		return nil, fmt.Errorf("office service: callback is not signed: %w", xerr.ErrTokenInvalid)
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (any, error) {
+		return []byte(s.deps.Config.Office.Secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		logger.Warn("HandleCallback: Invalid document server signature", zap.Error(err))
+		return nil, fmt.Errorf("office service: invalid callback signature: %w", xerr.ErrTokenInvalid)
+	}
+
+	var signed any = claims
+	if wrapped {
+		signed = claims["payload"]
+	}
+	data, err := json.Marshal(signed)
+	if err != nil {
+		return nil, fmt.Errorf("office service: invalid callback payload: %w", xerr.ErrInvalidParams)
+	}
+	var payload models.OfficeCallback
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("office service: invalid callback payload: %w", xerr.ErrInvalidParams)
+	}
+	return &payload, nil
+}
+
+// signEditorConfig 使用共享密钥签名编辑器配置,文档服务器据此拒绝被篡改的配置
+func (s *officeService) signEditorConfig(editorConfig *models.OfficeEditorConfig, ttl time.Duration) (string, error) {
+	data, err := json.Marshal(editorConfig)
+	if err != nil {
+		return "", fmt.Errorf("office service: failed to encode editor config: %w", err)
+	}
+	claims := jwt.MapClaims{}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return "", fmt.Errorf("office service: failed to encode editor config: %w", err)
+	}
+	claims["exp"] = time.Now().Add(ttl).Unix()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.deps.Config.Office.Secret))
+	if err != nil {
+		return "", fmt.Errorf("office service: failed to sign editor config: %w", err)
+	}
+	return token, nil
+}
+
+// officeDocumentKey 文档服务器用 key 识别同一份内容,多人打开同一版本时共享编辑会话,内容变化后 key 随之改变
+func officeDocumentKey(file *models.File) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%d:%s:%s:%s", file.ID, stringValue(file.OssKey), stringValue(file.VersionID), stringValue(file.MD5Hash)))
+	return hex.EncodeToString(sum[:16])
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,337 @@
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/authz"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultOrgSpace 未配置时新建组织的团队空间配额
+	defaultOrgSpace uint64 = 10 << 30
+	// maxOrgNameLength 组织名称的最大字符数
+	maxOrgNameLength = 64
+)
+
+// OrganizationService 组织(团队空间)服务。团队文件归组织的网盘账号所有,
+// 成员通过 FileService 的常规接口在团队根文件夹下操作,权限由 authz 按成员角色判断
+type OrganizationService interface {
+	// CreateOrganization 创建组织、网盘账号和团队根文件夹,创建者成为所有者
+	CreateOrganization(ctx context.Context, userID uint64, name string) (*models.Organization, error)
+	// ListOrganizations 列出用户加入的全部组织和用户在其中的角色
+	ListOrganizations(ctx context.Context, userID uint64) ([]models.OrganizationDetail, error)
+	// GetOrganization 返回组织详情和团队空间用量,仅成员可见
+	GetOrganization(ctx context.Context, userID uint64, orgID uint64) (*models.OrganizationDetail, error)
+	// ListFiles 分页列出团队文件夹内容,parentFolderID 为 nil 时列出团队根文件夹
+	ListFiles(ctx context.Context, userID uint64, orgID uint64, parentFolderID *uint64, opts models.FileListOptions) ([]models.File, int64, string, error)
+	// ListMembers 列出组织成员,仅成员可见
+	ListMembers(ctx context.Context, userID uint64, orgID uint64) ([]models.OrganizationMember, error)
+	// AddMember 添加成员或修改成员角色。管理员可以添加普通成员,分配或变更管理员和所有者需要所有者
+	AddMember(ctx context.Context, userID uint64, orgID uint64, username string, role string) (*models.OrganizationMember, error)
+	// RemoveMember 移除成员,成员可以主动退出。组织至少保留一名所有者
+	RemoveMember(ctx context.Context, userID uint64, orgID uint64, memberID uint64) error
+}
+
+type organizationService struct {
+	orgRepo       repositories.OrganizationRepository
+	userRepo      repositories.UserRepository
+	fileService   FileService
+	statsService  FileStatsService
+	domainService FileDomainService
+	authorizer    authz.Authorizer
+	cfg           *config.OrganizationConfig
+}
+
+var _ OrganizationService = (*organizationService)(nil)
+
+// NewOrganizationService 创建组织服务实例
+func NewOrganizationService(
+	orgRepo repositories.OrganizationRepository,
+	userRepo repositories.UserRepository,
+	fileService FileService,
+	statsService FileStatsService,
+	domainService FileDomainService,
+	authorizer authz.Authorizer,
+	cfg *config.OrganizationConfig,
+) OrganizationService {
+	return &organizationService{
+		orgRepo:       orgRepo,
+		userRepo:      userRepo,
+		fileService:   fileService,
+		statsService:  statsService,
+		domainService: domainService,
+		authorizer:    authorizer,
+		cfg:           cfg,
+	}
+}
+
+func (s *organizationService) CreateOrganization(ctx context.Context, userID uint64, name string) (*models.Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxOrgNameLength {
+		return nil, fmt.Errorf("organization service: name must be 1-%d characters: %w", maxOrgNameLength, xerr.ErrInvalidParams)
+	}
+	// 组织名称同时作为团队根文件夹的名称
+	folderName, err := s.domainService.NormalizeFileName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.cfg.MaxPerUser > 0 {
+		count, err := s.orgRepo.CountByCreator(ctx, userID)
+		if err != nil {
+			logger.Error("CreateOrganization: Failed to count organizations", zap.Uint64("userID", userID), zap.Error(err))
+			return nil, fmt.Errorf("organization service: failed to count organizations: %w", xerr.ErrDatabaseError)
+		}
+		if count >= int64(s.cfg.MaxPerUser) {
+			return nil, fmt.Errorf("organization service: created %d organizations, limit is %d: %w", count, s.cfg.MaxPerUser, xerr.ErrPermissionDenied)
+		}
+	}
+
+	space := s.cfg.DefaultSpace
+	if space == 0 {
+		space = defaultOrgSpace
+	}
+	// 网盘账号没有可用的密码,登录时按角色拒绝
+	accountID := uuid.New().String()
+	driveUser := &models.User{
+		Username:     "org-" + accountID,
+		PasswordHash: "!",
+		Email:        accountID + "@org.invalid",
+		TotalSpace:   space,
+		Status:       1,
+		Role:         models.RoleOrg,
+	}
+	now := time.Now()
+	rootFolder := &models.File{
+		UUID:      uuid.New().String(),
+		FileName:  folderName,
+		Path:      "/",
+		IsFolder:  1,
+		Status:    models.StatusNormal,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	org := &models.Organization{Name: name}
+	if err := s.orgRepo.Create(ctx, org, driveUser, rootFolder, userID); err != nil {
+		logger.Error("CreateOrganization: Failed to create organization", zap.Uint64("userID", userID), zap.String("name", name), zap.Error(err))
+		return nil, fmt.Errorf("organization service: failed to create organization: %w", xerr.ErrDatabaseError)
+	}
+
+	logger.Info("Organization created",
+		zap.Uint64("orgID", org.ID),
+		zap.Uint64("userID", userID),
+		zap.Uint64("driveUserID", org.DriveUserID),
+		zap.Uint64("rootFolderID", org.RootFolderID))
+	return org, nil
+}
+
+func (s *organizationService) ListOrganizations(ctx context.Context, userID uint64) ([]models.OrganizationDetail, error) {
+	memberships, err := s.orgRepo.FindMembershipsByUserID(ctx, userID)
+	if err != nil {
+		logger.Error("ListOrganizations: Failed to query memberships", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("organization service: failed to query organizations: %w", xerr.ErrDatabaseError)
+	}
+
+	orgs := make([]models.OrganizationDetail, 0, len(memberships))
+	for _, membership := range memberships {
+		if membership.Organization == nil {
+			continue
+		}
+		orgs = append(orgs, models.OrganizationDetail{Organization: *membership.Organization, Role: membership.Role})
+	}
+	return orgs, nil
+}
+
+func (s *organizationService) GetOrganization(ctx context.Context, userID uint64, orgID uint64) (*models.OrganizationDetail, error) {
+	org, member, err := s.memberOrg(ctx, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := s.statsService.GetUsage(ctx, org.DriveUserID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.OrganizationDetail{Organization: *org, Role: member.Role, Usage: usage}, nil
+}
+
+func (s *organizationService) ListFiles(ctx context.Context, userID uint64, orgID uint64, parentFolderID *uint64, opts models.FileListOptions) ([]models.File, int64, string, error) {
+	org, _, err := s.memberOrg(ctx, userID, orgID)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	if parentFolderID == nil {
+		parentFolderID = &org.RootFolderID
+	} else {
+		// 只能浏览团队空间内的文件夹,成员通过其他途径获得的文件夹不在这里列出
+		parent, err := s.domainService.CheckDirectory(ctx, userID, parentFolderID)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		if parent.UserID != org.DriveUserID {
+			return nil, 0, "", fmt.Errorf("organization service: folder %d is not in organization %d: %w", *parentFolderID, orgID, xerr.ErrFileNotFound)
+		}
+	}
+	return s.fileService.GetFilesByUserID(ctx, userID, parentFolderID, opts)
+}
+
+func (s *organizationService) ListMembers(ctx context.Context, userID uint64, orgID uint64) ([]models.OrganizationMember, error) {
+	if _, _, err := s.memberOrg(ctx, userID, orgID); err != nil {
+		return nil, err
+	}
+
+	members, err := s.orgRepo.ListMembers(ctx, orgID)
+	if err != nil {
+		logger.Error("ListMembers: Failed to query members", zap.Uint64("orgID", orgID), zap.Error(err))
+		return nil, fmt.Errorf("organization service: failed to query members: %w", xerr.ErrDatabaseError)
+	}
+	return members, nil
+}
+
+func (s *organizationService) AddMember(ctx context.Context, userID uint64, orgID uint64, username string, role string) (*models.OrganizationMember, error) {
+	if _, ok := models.OrgRoleRanks[role]; !ok {
+		return nil, fmt.Errorf("organization service: unknown role %q: %w", role, xerr.ErrInvalidParams)
+	}
+	org, _, err := s.memberOrg(ctx, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorizer.Authorize(ctx, userID, authz.ActionManage, authz.Org(org)); err != nil {
+		return nil, fmt.Errorf("organization service: %w", err)
+	}
+
+	user, err := s.userRepo.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, xerr.ErrUserNotFound) {
+			return nil, fmt.Errorf("organization service: %w", xerr.ErrUserNotFound)
+		}
+		logger.Error("AddMember: Failed to get user", zap.String("username", username), zap.Error(err))
+		return nil, fmt.Errorf("organization service: failed to get user: %w", xerr.ErrDatabaseError)
+	}
+	if user.Role == models.RoleOrg {
+		return nil, fmt.Errorf("organization service: %w", xerr.ErrUserNotFound)
+	}
+
+	existing, err := s.orgRepo.FindMember(ctx, orgID, user.ID)
+	if err != nil {
+		logger.Error("AddMember: Failed to query member", zap.Uint64("orgID", orgID), zap.Uint64("memberID", user.ID), zap.Error(err))
+		return nil, fmt.Errorf("organization service: failed to query member: %w", xerr.ErrDatabaseError)
+	}
+	// 分配管理员或所有者,以及变更现有管理员和所有者的角色,只有所有者可以操作
+	if role != models.OrgRoleMember || (existing != nil && existing.Role != models.OrgRoleMember) {
+		if err := s.authorizer.Authorize(ctx, userID, authz.ActionAdmin, authz.Org(org)); err != nil {
+			return nil, fmt.Errorf("organization service: %w", err)
+		}
+	}
+	if existing != nil && existing.Role == models.OrgRoleOwner && role != models.OrgRoleOwner {
+		if err := s.checkNotLastOwner(ctx, orgID); err != nil {
+			return nil, err
+		}
+	}
+
+	member := &models.OrganizationMember{OrgID: orgID, UserID: user.ID, Role: role}
+	if err := s.orgRepo.UpsertMember(ctx, member); err != nil {
+		logger.Error("AddMember: Failed to save member", zap.Uint64("orgID", orgID), zap.Uint64("memberID", user.ID), zap.Error(err))
+		return nil, fmt.Errorf("organization service: failed to save member: %w", xerr.ErrDatabaseError)
+	}
+
+	logger.Info("Organization member saved",
+		zap.Uint64("orgID", orgID),
+		zap.Uint64("userID", userID),
+		zap.Uint64("memberID", user.ID),
+		zap.String("role", role))
+	return member, nil
+}
+
+func (s *organizationService) RemoveMember(ctx context.Context, userID uint64, orgID uint64, memberID uint64) error {
+	org, _, err := s.memberOrg(ctx, userID, orgID)
+	if err != nil {
+		return err
+	}
+
+	target, err := s.orgRepo.FindMember(ctx, orgID, memberID)
+	if err != nil {
+		logger.Error("RemoveMember: Failed to query member", zap.Uint64("orgID", orgID), zap.Uint64("memberID", memberID), zap.Error(err))
+		return fmt.Errorf("organization service: failed to query member: %w", xerr.ErrDatabaseError)
+	}
+	if target == nil {
+		return fmt.Errorf("organization service: %w", xerr.ErrOrgMemberNotFound)
+	}
+
+	// 成员可以主动退出,移除其他人需要管理员,移除管理员和所有者需要所有者
+	if memberID != userID {
+		action := authz.ActionManage
+		if target.Role != models.OrgRoleMember {
+			action = authz.ActionAdmin
+		}
+		if err := s.authorizer.Authorize(ctx, userID, action, authz.Org(org)); err != nil {
+			return fmt.Errorf("organization service: %w", err)
+		}
+	}
+	if target.Role == models.OrgRoleOwner {
+		if err := s.checkNotLastOwner(ctx, orgID); err != nil {
+			return err
+		}
+	}
+
+	deleted, err := s.orgRepo.DeleteMember(ctx, orgID, memberID)
+	if err != nil {
+		logger.Error("RemoveMember: Failed to delete member", zap.Uint64("orgID", orgID), zap.Uint64("memberID", memberID), zap.Error(err))
+		return fmt.Errorf("organization service: failed to delete member: %w", xerr.ErrDatabaseError)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("organization service: %w", xerr.ErrOrgMemberNotFound)
+	}
+
+	logger.Info("Organization member removed",
+		zap.Uint64("orgID", orgID), zap.Uint64("userID", userID), zap.Uint64("memberID", memberID))
+	return nil
+}
+
+// memberOrg 返回组织和当前用户的成员记录,不是成员时按组织不存在处理,不暴露组织是否存在
+func (s *organizationService) memberOrg(ctx context.Context, userID uint64, orgID uint64) (*models.Organization, *models.OrganizationMember, error) {
+	org, err := s.orgRepo.FindByID(ctx, orgID)
+	if err != nil {
+		logger.Error("memberOrg: Failed to query organization", zap.Uint64("orgID", orgID), zap.Error(err))
+		return nil, nil, fmt.Errorf("organization service: failed to query organization: %w", xerr.ErrDatabaseError)
+	}
+	if org == nil {
+		return nil, nil, fmt.Errorf("organization service: %w", xerr.ErrOrgNotFound)
+	}
+
+	member, err := s.orgRepo.FindMember(ctx, orgID, userID)
+	if err != nil {
+		logger.Error("memberOrg: Failed to query member", zap.Uint64("orgID", orgID), zap.Uint64("userID", userID), zap.Error(err))
+		return nil, nil, fmt.Errorf("organization service: failed to query member: %w", xerr.ErrDatabaseError)
+	}
+	if member == nil {
+		return nil, nil, fmt.Errorf("organization service: %w", xerr.ErrOrgNotFound)
+	}
+	return org, member, nil
+}
+
+// checkNotLastOwner 降级或移除所有者前检查组织还有其他所有者
+func (s *organizationService) checkNotLastOwner(ctx context.Context, orgID uint64) error {
+	owners, err := s.orgRepo.CountOwners(ctx, orgID)
+	if err != nil {
+		logger.Error("checkNotLastOwner: Failed to count owners", zap.Uint64("orgID", orgID), zap.Error(err))
+		return fmt.Errorf("organization service: failed to count owners: %w", xerr.ErrDatabaseError)
+	}
+	if owners <= 1 {
+		return fmt.Errorf("organization service: %w", xerr.ErrLastOrgOwner)
+	}
+	return nil
+}
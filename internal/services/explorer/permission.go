@@ -0,0 +1,151 @@
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/authz"
+	"go.uber.org/zap"
+)
+
+// PermissionService 文件夹协作授权服务
+type PermissionService interface {
+	// Grant 授予其他用户对文件夹子树的访问权限,已有授权时更新权限级别
+	Grant(ctx context.Context, ownerID uint64, folderID uint64, granteeUsername string, permission uint8) (*models.FilePermission, error)
+	// Revoke 撤销授权,所有者和被授权者本人都可以撤销
+	Revoke(ctx context.Context, userID uint64, folderID uint64, granteeID uint64) error
+	// ListGrants 列出文件夹上的全部授权,仅所有者可见
+	ListGrants(ctx context.Context, ownerID uint64, folderID uint64) ([]models.FilePermission, error)
+	// ListSharedWithMe 列出其他用户共享给当前用户的文件夹
+	ListSharedWithMe(ctx context.Context, userID uint64) ([]models.FilePermission, error)
+}
+
+type permissionService struct {
+	permissionRepo repositories.FilePermissionRepository
+	userRepo       repositories.UserRepository
+	domainService  FileDomainService
+	authorizer     authz.Authorizer
+}
+
+var _ PermissionService = (*permissionService)(nil)
+
+// NewPermissionService 创建协作授权服务实例
+func NewPermissionService(permissionRepo repositories.FilePermissionRepository, userRepo repositories.UserRepository, domainService FileDomainService, authorizer authz.Authorizer) PermissionService {
+	return &permissionService{
+		permissionRepo: permissionRepo,
+		userRepo:       userRepo,
+		domainService:  domainService,
+		authorizer:     authorizer,
+	}
+}
+
+func (s *permissionService) Grant(ctx context.Context, ownerID uint64, folderID uint64, granteeUsername string, permission uint8) (*models.FilePermission, error) {
+	if permission != models.PermissionRead && permission != models.PermissionWrite {
+		return nil, fmt.Errorf("permission service: unknown permission %d: %w", permission, xerr.ErrInvalidParams)
+	}
+
+	if _, err := s.checkOwnedFolder(ctx, ownerID, folderID); err != nil {
+		return nil, err
+	}
+
+	grantee, err := s.userRepo.GetUserByUsername(ctx, granteeUsername)
+	if err != nil {
+		if errors.Is(err, xerr.ErrUserNotFound) {
+			return nil, fmt.Errorf("permission service: %w", xerr.ErrUserNotFound)
+		}
+		logger.Error("Grant: Failed to get grantee", zap.String("username", granteeUsername), zap.Error(err))
+		return nil, fmt.Errorf("permission service: failed to get grantee: %w", xerr.ErrDatabaseError)
+	}
+	if grantee.ID == ownerID {
+		return nil, fmt.Errorf("permission service: cannot grant permission to yourself: %w", xerr.ErrInvalidParams)
+	}
+
+	grant := &models.FilePermission{
+		FileID:     folderID,
+		OwnerID:    ownerID,
+		GranteeID:  grantee.ID,
+		Permission: permission,
+	}
+	if err := s.permissionRepo.Upsert(grant); err != nil {
+		logger.Error("Grant: Failed to save file permission",
+			zap.Uint64("folderID", folderID), zap.Uint64("granteeID", grantee.ID), zap.Error(err))
+		return nil, fmt.Errorf("permission service: failed to save permission: %w", xerr.ErrDatabaseError)
+	}
+
+	logger.Info("Folder permission granted",
+		zap.Uint64("folderID", folderID),
+		zap.Uint64("ownerID", ownerID),
+		zap.Uint64("granteeID", grantee.ID),
+		zap.Uint8("permission", permission))
+	return grant, nil
+}
+
+func (s *permissionService) Revoke(ctx context.Context, userID uint64, folderID uint64, granteeID uint64) error {
+	// 被授权者可以主动退出共享,其余情况只有所有者可以撤销
+	if userID != granteeID {
+		if _, err := s.checkOwnedFolder(ctx, userID, folderID); err != nil {
+			return err
+		}
+	}
+
+	deleted, err := s.permissionRepo.Delete(folderID, granteeID)
+	if err != nil {
+		logger.Error("Revoke: Failed to delete file permission",
+			zap.Uint64("folderID", folderID), zap.Uint64("granteeID", granteeID), zap.Error(err))
+		return fmt.Errorf("permission service: failed to delete permission: %w", xerr.ErrDatabaseError)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("permission service: %w", xerr.ErrPermissionNotFound)
+	}
+
+	logger.Info("Folder permission revoked",
+		zap.Uint64("folderID", folderID), zap.Uint64("userID", userID), zap.Uint64("granteeID", granteeID))
+	return nil
+}
+
+func (s *permissionService) ListGrants(ctx context.Context, ownerID uint64, folderID uint64) ([]models.FilePermission, error) {
+	if _, err := s.checkOwnedFolder(ctx, ownerID, folderID); err != nil {
+		return nil, err
+	}
+
+	grants, err := s.permissionRepo.FindByFileID(folderID)
+	if err != nil {
+		logger.Error("ListGrants: Failed to query file permissions", zap.Uint64("folderID", folderID), zap.Error(err))
+		return nil, fmt.Errorf("permission service: failed to query permissions: %w", xerr.ErrDatabaseError)
+	}
+	return grants, nil
+}
+
+func (s *permissionService) ListSharedWithMe(ctx context.Context, userID uint64) ([]models.FilePermission, error) {
+	grants, err := s.permissionRepo.FindByGranteeID(userID)
+	if err != nil {
+		logger.Error("ListSharedWithMe: Failed to query file permissions", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("permission service: failed to query permissions: %w", xerr.ErrDatabaseError)
+	}
+
+	// 已删除或移入回收站的文件夹不再展示
+	shared := make([]models.FilePermission, 0, len(grants))
+	for _, grant := range grants {
+		if grant.Folder != nil && grant.Folder.Status == models.StatusNormal {
+			shared = append(shared, grant)
+		}
+	}
+	return shared, nil
+}
+
+// checkOwnedFolder 检查文件夹存在且属于当前用户,协作者不能管理授权
+func (s *permissionService) checkOwnedFolder(ctx context.Context, userID uint64, folderID uint64) (*models.File, error) {
+	folder, err := s.domainService.CheckDirectory(ctx, userID, &folderID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorizer.Authorize(ctx, userID, authz.ActionManage, authz.File(folder)); err != nil {
+		return nil, fmt.Errorf("permission service: %w", err)
+	}
+	return folder, nil
+}
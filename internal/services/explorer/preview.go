@@ -0,0 +1,349 @@
+package explorer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/storage"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultPreviewWorkers = 2
+	defaultPreviewTimeout = 300 // 秒
+
+	// maxInlineThumbnailSize 批量元数据中内嵌的预览大小上限,超出的由客户端单独请求
+	maxInlineThumbnailSize = 64 << 10
+	// thumbnailConcurrency 批量读取预览时同时进行的读取数
+	thumbnailConcurrency = 8
+)
+
+// PreviewService 媒体文件预览服务,按需转码并将结果缓存到对象存储
+type PreviewService interface {
+	// GetPreview 返回指定尺寸的预览内容,调用方负责关闭 reader
+	GetPreview(ctx context.Context, userID uint64, fileID uint64, size string) (*models.PreviewInfo, io.ReadCloser, error)
+	// GetThumbnails 读取图片已生成的小尺寸预览,不触发转码,未生成或过大的不包含在结果中
+	GetThumbnails(ctx context.Context, files []models.File) map[uint64][]byte
+}
+
+type previewService struct {
+	domainService  FileDomainService
+	storageService storage.StorageService
+	cfg            *config.Config
+	workers        chan struct{}      // 限制同时运行的转码任务数
+	group          singleflight.Group // 合并同一预览的并发转码请求
+}
+
+var _ PreviewService = (*previewService)(nil)
+
+// NewPreviewService 创建预览服务实例
+func NewPreviewService(domainService FileDomainService, storageService storage.StorageService, cfg *config.Config) PreviewService {
+	workers := cfg.Preview.Workers
+	if workers <= 0 {
+		workers = defaultPreviewWorkers
+	}
+	return &previewService{
+		domainService:  domainService,
+		storageService: storageService,
+		cfg:            cfg,
+		workers:        make(chan struct{}, workers),
+	}
+}
+
+func (s *previewService) GetPreview(ctx context.Context, userID uint64, fileID uint64, size string) (*models.PreviewInfo, io.ReadCloser, error) {
+	if !s.cfg.Preview.Enabled {
+		return nil, nil, fmt.Errorf("preview service: preview disabled: %w", xerr.ErrPreviewNotSupported)
+	}
+	if _, ok := models.PreviewSizes[size]; !ok {
+		return nil, nil, fmt.Errorf("preview service: unknown preview size %q: %w", size, xerr.ErrInvalidParams)
+	}
+
+	file, err := s.domainService.CheckFile(ctx, userID, fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if file.IsFolder == 1 {
+		return nil, nil, fmt.Errorf("preview service: %w", xerr.ErrCannotDownloadFolder)
+	}
+	if err := ensureNotQuarantined(file); err != nil {
+		return nil, nil, fmt.Errorf("preview service: %w", err)
+	}
+	kind := models.PreviewKind(file.MimeType)
+	if kind == "" {
+		return nil, nil, fmt.Errorf("preview service: %w", xerr.ErrPreviewNotSupported)
+	}
+	if file.OssBucket == nil || file.OssKey == nil {
+		logger.Error("GetPreview: File record has no storage location", zap.Uint64("fileID", fileID))
+		return nil, nil, fmt.Errorf("preview service: %w", xerr.ErrStorageError)
+	}
+
+	bucketName := *file.OssBucket
+	previewKey := previewObjectKey(file, kind, size)
+
+	// 命中缓存直接返回
+	if info, reader, ok := s.getCachedPreview(ctx, bucketName, previewKey); ok {
+		return info, reader, nil
+	}
+
+	if limit := s.cfg.Preview.MaxSourceSize; limit > 0 && file.Size > uint64(limit) {
+		logger.Warn("GetPreview: Source file too large to transcode", zap.Uint64("fileID", fileID), zap.Uint64("size", file.Size))
+		return nil, nil, fmt.Errorf("preview service: %w", xerr.ErrFileTooLarge)
+	}
+
+	// 同一预览的并发请求只转码一次
+	_, err, _ = s.group.Do(previewKey, func() (any, error) {
+		return nil, s.transcode(ctx, file, kind, size, bucketName, previewKey)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, reader, ok := s.getCachedPreview(ctx, bucketName, previewKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("preview service: failed to read generated preview: %w", xerr.ErrStorageError)
+	}
+	return info, reader, nil
+}
+
+func (s *previewService) GetThumbnails(ctx context.Context, files []models.File) map[uint64][]byte {
+	thumbnails := make(map[uint64][]byte)
+	if !s.cfg.Preview.Enabled {
+		return thumbnails
+	}
+
+	var mu sync.Mutex
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(thumbnailConcurrency)
+	for i := range files {
+		file := &files[i]
+		if file.IsFolder == 1 || file.OssBucket == nil || file.ScanStatus == models.ScanStatusInfected ||
+			models.PreviewKind(file.MimeType) != models.PreviewKindImage {
+			continue
+		}
+		g.Go(func() error {
+			data, ok := s.readThumbnail(gctx, *file.OssBucket, previewObjectKey(file, models.PreviewKindImage, models.PreviewSizeSmall))
+			if ok {
+				mu.Lock()
+				thumbnails[file.ID] = data
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+	return thumbnails
+}
+
+// readThumbnail 读取不超过 maxInlineThumbnailSize 的已生成预览
+func (s *previewService) readThumbnail(ctx context.Context, bucketName, previewKey string) ([]byte, bool) {
+	_, reader, ok := s.getCachedPreview(ctx, bucketName, previewKey)
+	if !ok {
+		return nil, false
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(io.LimitReader(reader, maxInlineThumbnailSize+1))
+	if err != nil || len(data) > maxInlineThumbnailSize {
+		return nil, false
+	}
+	return data, true
+}
+
+// getCachedPreview 从对象存储读取已生成的预览
+func (s *previewService) getCachedPreview(ctx context.Context, bucketName, previewKey string) (*models.PreviewInfo, io.ReadCloser, bool) {
+	result, err := s.storageService.GetObject(ctx, bucketName, previewKey, "")
+	if err != nil {
+		logger.Debug("getCachedPreview: Preview not cached", zap.String("previewKey", previewKey), zap.Error(err))
+		return nil, nil, false
+	}
+	// MinIO 在对象不存在时不会立即报错,只能通过 Stat 失败(Size 为 -1)判断
+	if result.Size < 0 {
+		result.Reader.Close()
+		return nil, nil, false
+	}
+	return &models.PreviewInfo{ContentType: result.MimeType, Size: result.Size}, result.Reader, true
+}
+
+// transcode 下载源文件到本地,调用 ffmpeg 转码后上传到派生的预览对象
+func (s *previewService) transcode(ctx context.Context, file *models.File, kind, size, bucketName, previewKey string) error {
+	// 转码任务独立于请求的生命周期,避免客户端断开导致其他等待者一起失败
+	timeout := s.cfg.Preview.Timeout
+	if timeout <= 0 {
+		timeout = defaultPreviewTimeout
+	}
+	jobCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	select {
+	case s.workers <- struct{}{}:
+		defer func() { <-s.workers }()
+	case <-ctx.Done():
+		return fmt.Errorf("preview service: %w", ctx.Err())
+	}
+
+	srcPath, err := s.downloadSource(jobCtx, file)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(srcPath)
+
+	outFile, err := os.CreateTemp(s.cfg.Preview.TempDir, "preview-*"+previewExt(kind))
+	if err != nil {
+		logger.Error("transcode: Failed to create temp output file", zap.Error(err))
+		return fmt.Errorf("preview service: %w", xerr.ErrInternalServer)
+	}
+	outPath := outFile.Name()
+	outFile.Close()
+	defer os.Remove(outPath)
+
+	ffmpegPath := s.cfg.Preview.FFmpegPath
+	if ffmpegPath == "" {
+		ffmpegPath = "ffmpeg"
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(jobCtx, ffmpegPath, ffmpegArgs(kind, models.PreviewSizes[size], srcPath, outPath)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		logger.Error("transcode: ffmpeg failed",
+			zap.Uint64("fileID", file.ID),
+			zap.String("size", size),
+			zap.String("stderr", tail(stderr.String(), 1024)),
+			zap.Error(err))
+		return fmt.Errorf("preview service: transcode failed: %w", xerr.ErrInternalServer)
+	}
+
+	output, err := os.Open(outPath)
+	if err != nil {
+		return fmt.Errorf("preview service: %w", xerr.ErrInternalServer)
+	}
+	defer output.Close()
+	stat, err := output.Stat()
+	if err != nil {
+		return fmt.Errorf("preview service: %w", xerr.ErrInternalServer)
+	}
+
+	if _, err := s.storageService.PutObject(jobCtx, bucketName, previewKey, output, stat.Size(), previewContentType(kind)); err != nil {
+		logger.Error("transcode: Failed to upload preview", zap.String("previewKey", previewKey), zap.Error(err))
+		return fmt.Errorf("preview service: %w", xerr.ErrStorageError)
+	}
+
+	logger.Info("Preview generated",
+		zap.Uint64("fileID", file.ID),
+		zap.String("size", size),
+		zap.String("previewKey", previewKey),
+		zap.Int64("previewSize", stat.Size()))
+	return nil
+}
+
+// downloadSource 把源文件写入本地临时文件,ffmpeg 处理视频时需要可随机访问的输入
+func (s *previewService) downloadSource(ctx context.Context, file *models.File) (string, error) {
+	versionID := ""
+	if file.VersionID != nil {
+		versionID = *file.VersionID
+	}
+	result, err := s.storageService.GetObject(ctx, *file.OssBucket, *file.OssKey, versionID)
+	if err != nil {
+		logger.Error("downloadSource: Failed to get source object", zap.Uint64("fileID", file.ID), zap.Error(err))
+		return "", fmt.Errorf("preview service: %w", xerr.ErrStorageError)
+	}
+	defer result.Reader.Close()
+
+	src, err := os.CreateTemp(s.cfg.Preview.TempDir, "preview-src-*")
+	if err != nil {
+		logger.Error("downloadSource: Failed to create temp source file", zap.Error(err))
+		return "", fmt.Errorf("preview service: %w", xerr.ErrInternalServer)
+	}
+	defer src.Close()
+
+	if _, err := io.Copy(src, result.Reader); err != nil {
+		os.Remove(src.Name())
+		logger.Error("downloadSource: Failed to copy source object", zap.Uint64("fileID", file.ID), zap.Error(err))
+		return "", fmt.Errorf("preview service: %w", xerr.ErrStorageError)
+	}
+	return src.Name(), nil
+}
+
+// removeStalePreviews 文件内容变化后删除旧内容的各尺寸预览,next 为 nil 表示文件已被彻底删除,内容未变化的尺寸保留。
+// 内容相同的文件共享预览,被删除的预览在下次访问时重新生成,删除失败只记录日志
+func removeStalePreviews(ctx context.Context, ss storage.StorageService, previous, next *models.File) {
+	kind := models.PreviewKind(previous.MimeType)
+	if kind == "" || previous.OssBucket == nil {
+		return
+	}
+	for size := range models.PreviewSizes {
+		previewKey := previewObjectKey(previous, kind, size)
+		if next != nil && next.OssBucket != nil && *next.OssBucket == *previous.OssBucket &&
+			previewObjectKey(next, models.PreviewKind(next.MimeType), size) == previewKey {
+			continue
+		}
+		if err := ss.RemoveObjects(ctx, *previous.OssBucket, previewKey); err != nil {
+			logger.Warn("removeStalePreviews: Failed to remove preview", zap.Uint64("fileID", previous.ID), zap.String("previewKey", previewKey), zap.Error(err))
+		}
+	}
+}
+
+// previewObjectKey 预览对象的派生键,内容相同的文件共享同一份预览
+func previewObjectKey(file *models.File, kind, size string) string {
+	source := file.UUID
+	if file.MD5Hash != nil && *file.MD5Hash != "" {
+		source = *file.MD5Hash
+	} else if file.VersionID != nil {
+		source = file.UUID + "/" + *file.VersionID
+	}
+	return fmt.Sprintf("previews/%s/%s%s", source, size, previewExt(kind))
+}
+
+func previewExt(kind string) string {
+	if kind == models.PreviewKindVideo {
+		return ".mp4"
+	}
+	return ".jpg"
+}
+
+func previewContentType(kind string) string {
+	if kind == models.PreviewKindVideo {
+		return "video/mp4"
+	}
+	return "image/jpeg"
+}
+
+// ffmpegArgs 生成转码参数,等比缩放到最长边不超过 maxSide,小图不放大
+func ffmpegArgs(kind string, maxSide int, srcPath, outPath string) []string {
+	scale := fmt.Sprintf("scale='min(%d,iw)':'min(%d,ih)':force_original_aspect_ratio=decrease", maxSide, maxSide)
+	if kind == models.PreviewKindVideo {
+		// H.264 要求宽高为偶数
+		return []string{
+			"-y", "-i", srcPath,
+			"-vf", scale + ",scale=trunc(iw/2)*2:trunc(ih/2)*2",
+			"-c:v", "libx264", "-preset", "veryfast", "-crf", "28",
+			"-c:a", "aac", "-b:a", "128k",
+			"-movflags", "+faststart",
+			outPath,
+		}
+	}
+	return []string{
+		"-y", "-i", srcPath,
+		"-vf", scale,
+		"-frames:v", "1", "-q:v", "4",
+		outPath,
+	}
+}
+
+// tail 截取字符串末尾最多 n 个字节,用于记录 ffmpeg 的错误输出
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[len(s)-n:]
+}
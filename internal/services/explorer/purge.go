@@ -0,0 +1,464 @@
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/storage"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/tracing"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/activity"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/authz"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// PurgeQueueName 彻底删除任务消息队列名称
+const PurgeQueueName = "file_purge_queue"
+
+const (
+	// purgeBatchSize 每个事务删除的文件数,避免删除大文件夹时长时间持有事务
+	purgeBatchSize = 200
+	// maxPurgeErrorLength 保存到任务记录中的错误信息长度上限
+	maxPurgeErrorLength = 512
+	// maxPurgeAttempts 彻底删除失败后最多执行的次数,用完后任务失败,剩余的文件回到回收站
+	maxPurgeAttempts = 5
+	// purgeRetryBaseDelay 第一次重试的等待时间,之后每次加倍,不超过 purgeRetryMaxDelay
+	purgeRetryBaseDelay = time.Minute
+	purgeRetryMaxDelay  = time.Hour
+)
+
+// PurgeService 定义了彻底删除文件和文件夹的接口。
+// 发起时同步把整棵子树标记为待删除并立即返回,之后由 Worker 分批删除版本、存储对象和数据库记录
+type PurgeService interface {
+	// RequestPurge 标记文件及其子项为待删除并创建彻底删除任务,已有进行中的任务时直接返回该任务
+	RequestPurge(ctx context.Context, userID uint64, fileID uint64) (*models.PurgeJob, error)
+	// PurgeInTx 在调用方的事务中标记文件及其子项为待删除并创建彻底删除任务,用于删除跳过回收站文件夹中的条目。
+	// 删除权限由调用方校验,锁按操作者 actorID 检查,任务归属文件所有者,活动日志由调用方在事务提交后记录
+	PurgeInTx(ctx context.Context, tx *gorm.DB, actorID uint64, file *models.File) (*models.PurgeJob, error)
+	// GetPurgeJob 查询彻底删除任务的进度
+	GetPurgeJob(ctx context.Context, userID uint64, jobID uint64) (*models.PurgeJob, error)
+	// ProcessPurge 由 Worker 调用,分批执行彻底删除
+	ProcessPurge(ctx context.Context, jobID uint64) error
+	// PurgeUserFiles 彻底删除用户的全部文件和文件夹,包括回收站,返回删除的数量。由账号注销调用,不检查权限和锁
+	PurgeUserFiles(ctx context.Context, userID uint64) (int64, error)
+	// PurgeExpiredTrash 为超过所有者回收站保留期的文件创建彻底删除任务,返回创建的任务数
+	PurgeExpiredTrash(ctx context.Context, now time.Time, limit int) (int, error)
+}
+
+type purgeService struct {
+	purgeRepo       repositories.PurgeJobRepository
+	fileRepo        repositories.FileRepository
+	fileVersionRepo repositories.FileVersionRepository
+	objectRepo      repositories.StorageObjectRepository
+	tm              TransactionManager
+	lockService     FileLockService
+	activityService activity.ActivityService
+	statsService    FileStatsService
+	storage         storage.StorageService
+	authorizer      authz.Authorizer
+	cfg             *config.Config
+}
+
+var _ PurgeService = (*purgeService)(nil)
+
+// NewPurgeService 创建彻底删除服务实例
+func NewPurgeService(
+	purgeRepo repositories.PurgeJobRepository,
+	fileRepo repositories.FileRepository,
+	fileVersionRepo repositories.FileVersionRepository,
+	objectRepo repositories.StorageObjectRepository,
+	tm TransactionManager,
+	lockService FileLockService,
+	activityService activity.ActivityService,
+	statsService FileStatsService,
+	storageService storage.StorageService,
+	authorizer authz.Authorizer,
+	cfg *config.Config,
+) PurgeService {
+	return &purgeService{
+		purgeRepo:       purgeRepo,
+		fileRepo:        fileRepo,
+		fileVersionRepo: fileVersionRepo,
+		objectRepo:      objectRepo,
+		tm:              tm,
+		lockService:     lockService,
+		activityService: activityService,
+		statsService:    statsService,
+		storage:         storageService,
+		authorizer:      authorizer,
+		cfg:             cfg,
+	}
+}
+
+func (s *purgeService) RequestPurge(ctx context.Context, userID uint64, fileID uint64) (*models.PurgeJob, error) {
+	ctx, span := tracing.Start(ctx, "PurgeService.RequestPurge")
+	defer span.End()
+
+	file, err := s.fileRepo.FindByID(ctx, fileID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrFileNotFound) {
+			return nil, fmt.Errorf("purge service: %w", xerr.ErrFileNotFound)
+		}
+		logger.Error("RequestPurge: Failed to find file", zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, fmt.Errorf("purge service: %w", xerr.ErrDatabaseError)
+	}
+	if err := s.authorizer.Authorize(ctx, userID, authz.ActionPurge, authz.File(file)); err != nil {
+		return nil, fmt.Errorf("purge service: %w", err)
+	}
+
+	var job *models.PurgeJob
+	created := false
+	err = s.tm.WithTransaction(ctx, func(tx *gorm.DB) error {
+		var err error
+		job, created, err = s.createPurgeJob(ctx, tx, userID, file)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		s.activityService.Record(ctx, userID, fileID, models.ActivityDelete, "permanent: "+file.FileName)
+		s.statsService.NotifyChanged(ctx, userID, file.Path)
+	}
+	return job, nil
+}
+
+func (s *purgeService) PurgeInTx(ctx context.Context, tx *gorm.DB, actorID uint64, file *models.File) (*models.PurgeJob, error) {
+	job, _, err := s.createPurgeJob(ctx, tx, actorID, file)
+	return job, err
+}
+
+// createPurgeJob 在 tx 中标记文件及其子项为待删除、创建任务并写入任务消息,
+// 要么整棵子树都进入待删除状态并且一定会被处理,要么都不变。已有进行中的任务时返回该任务和 false
+func (s *purgeService) createPurgeJob(ctx context.Context, tx *gorm.DB, actorID uint64, file *models.File) (*models.PurgeJob, bool, error) {
+	purgeRepo := repositories.NewPurgeJobRepository(tx)
+	fileRepo := s.fileRepo.WithTx(tx)
+
+	active, err := purgeRepo.FindActiveByFileID(file.ID)
+	if err != nil {
+		logger.Error("RequestPurge: Failed to find active purge job", zap.Uint64("fileID", file.ID), zap.Error(err))
+		return nil, false, fmt.Errorf("purge service: %w", xerr.ErrDatabaseError)
+	}
+	if active != nil {
+		return active, false, nil
+	}
+
+	// 协作者删除时锁按协作者本人检查,自己持有的锁不阻止删除
+	if err := s.lockService.CheckTreeLock(ctx, actorID, file); err != nil {
+		return nil, false, err
+	}
+
+	files := []models.File{*file}
+	if file.IsFolder == 1 {
+		children, err := fileRepo.FindDescendants(ctx, file.UserID, file.ID, true)
+		if err != nil {
+			logger.Error("RequestPurge: Failed to collect children", zap.Uint64("fileID", file.ID), zap.Error(err))
+			return nil, false, fmt.Errorf("purge service: %w", xerr.ErrDatabaseError)
+		}
+		files = append(files, children...)
+	}
+
+	job := &models.PurgeJob{
+		UserID:     file.UserID,
+		FileID:     file.ID,
+		FileName:   file.FileName,
+		Status:     models.PurgeStatusPending,
+		TotalItems: int64(len(files)),
+	}
+	err = func() error {
+		if err := fileRepo.MarkDeleting(ctx, files); err != nil {
+			return err
+		}
+		if err := purgeRepo.Create(job); err != nil {
+			return err
+		}
+		event, err := models.NewQueueEvent(PurgeQueueName, models.PurgeTask{JobID: job.ID})
+		if err != nil {
+			return err
+		}
+		return repositories.NewOutboxRepository(tx).Add(ctx, event)
+	}()
+	if err != nil {
+		logger.Error("RequestPurge: Failed to mark files deleting", zap.Uint64("fileID", file.ID), zap.Error(err))
+		return nil, false, fmt.Errorf("purge service: %w", xerr.ErrDatabaseError)
+	}
+
+	logger.Info("RequestPurge: Files marked for deletion", zap.Uint64("fileID", file.ID), zap.Uint64("jobID", job.ID), zap.Int64("items", job.TotalItems))
+	return job, true, nil
+}
+
+func (s *purgeService) GetPurgeJob(ctx context.Context, userID uint64, jobID uint64) (*models.PurgeJob, error) {
+	job, err := s.purgeRepo.FindByID(jobID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrPurgeJobNotFound) {
+			return nil, fmt.Errorf("purge service: %w", xerr.ErrPurgeJobNotFound)
+		}
+		logger.Error("GetPurgeJob: Failed to find purge job", zap.Uint64("jobID", jobID), zap.Error(err))
+		return nil, fmt.Errorf("purge service: %w", xerr.ErrDatabaseError)
+	}
+	// 不暴露其他用户的任务是否存在
+	if job.UserID != userID {
+		return nil, fmt.Errorf("purge service: %w", xerr.ErrPurgeJobNotFound)
+	}
+	return job, nil
+}
+
+func (s *purgeService) ProcessPurge(ctx context.Context, jobID uint64) error {
+	job, err := s.purgeRepo.FindByID(jobID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrPurgeJobNotFound) {
+			logger.Warn("ProcessPurge: Purge job not found", zap.Uint64("jobID", jobID))
+			return nil
+		}
+		return err
+	}
+	if job.Status == models.PurgeStatusCompleted || job.Status == models.PurgeStatusFailed {
+		return nil
+	}
+
+	job.Status = models.PurgeStatusRunning
+	if err := s.purgeRepo.Update(job); err != nil {
+		return fmt.Errorf("failed to update purge job: %w", err)
+	}
+
+	err = s.purge(ctx, job)
+	if err != nil && job.Attempts+1 < maxPurgeAttempts {
+		return s.retryJob(ctx, job, err)
+	}
+	s.finishJob(ctx, job, err)
+	return err
+}
+
+// retryJob 记录失败并在退避时间后重新投递任务消息,文件保持待删除状态
+func (s *purgeService) retryJob(ctx context.Context, job *models.PurgeJob, cause error) error {
+	job.Attempts++
+	job.Status = models.PurgeStatusPending
+	job.Error = truncatePurgeError(cause)
+	delay := min(purgeRetryBaseDelay<<(job.Attempts-1), purgeRetryMaxDelay)
+	logger.Warn("ProcessPurge: Purge failed, will retry", zap.Uint64("jobID", job.ID), zap.Int("attempts", job.Attempts),
+		zap.Duration("delay", delay), zap.Error(cause))
+
+	err := s.tm.WithTransaction(ctx, func(tx *gorm.DB) error {
+		if err := repositories.NewPurgeJobRepository(tx).Update(job); err != nil {
+			return err
+		}
+		event, err := models.NewQueueEvent(PurgeQueueName, models.PurgeTask{JobID: job.ID})
+		if err != nil {
+			return err
+		}
+		event.NextAttemptAt = time.Now().Add(delay)
+		return repositories.NewOutboxRepository(tx).Add(ctx, event)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to schedule purge retry: %w", err)
+	}
+	return cause
+}
+
+// purge 按从深到浅的顺序分批删除,中断后剩余部分仍然是一棵完整的子树,可以重新发起
+func (s *purgeService) purge(ctx context.Context, job *models.PurgeJob) error {
+	root, err := s.fileRepo.FindByID(ctx, job.FileID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrFileNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to find root file: %w", err)
+	}
+
+	files := []models.File{*root}
+	if root.IsFolder == 1 {
+		children, err := s.fileRepo.FindDescendants(ctx, job.UserID, root.ID, true)
+		if err != nil {
+			return fmt.Errorf("failed to collect children: %w", err)
+		}
+		files = append(files, children...)
+	}
+	slices.Reverse(files)
+
+	// 消息重新投递时从剩余数量推算进度
+	job.PurgedItems = max(job.TotalItems-int64(len(files)), 0)
+
+	for start := 0; start < len(files); start += purgeBatchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batch := files[start:min(start+purgeBatchSize, len(files))]
+		if err := s.purgeBatch(ctx, batch); err != nil {
+			return err
+		}
+
+		job.PurgedItems += int64(len(batch))
+		if err := s.purgeRepo.Update(job); err != nil {
+			logger.Error("ProcessPurge: Failed to update purge progress", zap.Uint64("jobID", job.ID), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+func (s *purgeService) PurgeUserFiles(ctx context.Context, userID uint64) (int64, error) {
+	roots, err := s.fileRepo.FindRootsByUserID(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find root files: %w", err)
+	}
+
+	var purged int64
+	for _, root := range roots {
+		files := []models.File{root}
+		if root.IsFolder == 1 {
+			children, err := s.fileRepo.FindDescendants(ctx, userID, root.ID, true)
+			if err != nil {
+				return purged, fmt.Errorf("failed to collect children: %w", err)
+			}
+			files = append(files, children...)
+		}
+		// 与 RequestPurge 相同,先把整棵子树标记为待删除,再从深到浅分批删除
+		if err := s.fileRepo.MarkDeleting(ctx, files); err != nil {
+			return purged, err
+		}
+		slices.Reverse(files)
+
+		for start := 0; start < len(files); start += purgeBatchSize {
+			if err := ctx.Err(); err != nil {
+				return purged, err
+			}
+			batch := files[start:min(start+purgeBatchSize, len(files))]
+			if err := s.purgeBatch(ctx, batch); err != nil {
+				return purged, err
+			}
+			purged += int64(len(batch))
+		}
+	}
+	return purged, nil
+}
+
+func (s *purgeService) PurgeExpiredTrash(ctx context.Context, now time.Time, limit int) (int, error) {
+	expired, err := s.fileRepo.FindExpiredTrash(ctx, now, s.cfg.Trash.DefaultRetentionDays(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("purge service: %w", err)
+	}
+
+	created := 0
+	for _, candidate := range expired {
+		if err := ctx.Err(); err != nil {
+			return created, err
+		}
+		// 文件夹的彻底删除任务会一并标记其中的子项,子项排在后面时已不需要单独删除
+		file, err := s.fileRepo.FindByID(ctx, candidate.ID)
+		if err != nil || file.Status == models.StatusDeleting || !file.DeletedAt.Valid {
+			continue
+		}
+		if _, err := s.RequestPurge(ctx, file.UserID, file.ID); err != nil {
+			logger.Warn("PurgeExpiredTrash: Failed to purge expired file", zap.Uint64("fileID", file.ID), zap.Error(err))
+			continue
+		}
+		created++
+	}
+	return created, nil
+}
+
+// purgeBatch 在一个事务中删除一批文件的版本和主记录并释放对象引用,提交后删除引用数降为 0 的存储对象
+func (s *purgeService) purgeBatch(ctx context.Context, batch []models.File) error {
+	ids := make([]uint64, 0, len(batch))
+	for _, file := range batch {
+		ids = append(ids, file.ID)
+	}
+
+	var released []models.StorageObject
+	err := s.tm.WithTransaction(ctx, func(tx *gorm.DB) error {
+		var err error
+		if released, err = repositories.NewFileVersionRepository(tx).PurgeByFileIDs(ctx, ids); err != nil {
+			return fmt.Errorf("failed to delete versions: %w", err)
+		}
+		if err := repositories.NewFileDownloadCountRepository(tx).DeleteByFileIDs(ctx, ids); err != nil {
+			return fmt.Errorf("failed to delete download counts: %w", err)
+		}
+		for _, id := range ids {
+			if err := s.fileRepo.PermanentDelete(ctx, tx, id); err != nil && !errors.Is(err, xerr.ErrFileNotFound) {
+				return fmt.Errorf("failed to delete file %d: %w", id, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 对象仍被其他文件引用(秒传或转存)时保留物理文件。数据库记录已删除,对象删除失败只记录不重试,
+	// 未删除的对象保留引用数为 0 的计数记录
+	if err := RemoveReleasedObjects(ctx, s.objectRepo, s.storage, s.cfg.DefaultBucketName(), released); err != nil {
+		logger.Error("ProcessPurge: Failed to delete physical files", zap.Error(err))
+	}
+	for i := range batch {
+		removeStalePreviews(ctx, s.storage, &batch[i], nil)
+	}
+	return nil
+}
+
+// finishJob 保存任务的最终状态,err 为 nil 表示已全部删除。失败时把剩余的文件放回回收站,用户可以看到并重新发起
+func (s *purgeService) finishJob(ctx context.Context, job *models.PurgeJob, err error) {
+	now := time.Now()
+	job.FinishedAt = &now
+	if err != nil {
+		logger.Error("Purge failed", zap.Uint64("jobID", job.ID), zap.Uint64("fileID", job.FileID), zap.Int("attempts", job.Attempts+1), zap.Error(err))
+		job.Attempts++
+		job.Status = models.PurgeStatusFailed
+		job.Error = truncatePurgeError(err)
+		if restoreErr := s.restoreRemaining(ctx, job); restoreErr != nil {
+			logger.Error("ProcessPurge: Failed to return remaining files to the recycle bin", zap.Uint64("jobID", job.ID), zap.Error(restoreErr))
+		}
+	} else {
+		job.Status = models.PurgeStatusCompleted
+		job.PurgedItems = max(job.PurgedItems, job.TotalItems)
+		job.Error = ""
+	}
+	if updateErr := s.purgeRepo.Update(job); updateErr != nil {
+		logger.Error("ProcessPurge: Failed to update purge job", zap.Uint64("jobID", job.ID), zap.Error(updateErr))
+	}
+}
+
+// restoreRemaining 把任务中未删除的文件从待删除状态改回正常状态。标记待删除时已记录删除时间,
+// 改回后文件出现在回收站中,回收站过期清理也会再次为它们创建任务
+func (s *purgeService) restoreRemaining(ctx context.Context, job *models.PurgeJob) error {
+	root, err := s.fileRepo.FindByID(ctx, job.FileID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrFileNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to find root file: %w", err)
+	}
+	files := []models.File{*root}
+	if root.IsFolder == 1 {
+		children, err := s.fileRepo.FindDescendants(ctx, job.UserID, root.ID, true)
+		if err != nil {
+			return fmt.Errorf("failed to collect children: %w", err)
+		}
+		files = append(files, children...)
+	}
+	for _, file := range files {
+		if file.Status != models.StatusDeleting {
+			continue
+		}
+		if err := s.fileRepo.UpdateFileStatus(ctx, file.ID, models.StatusNormal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// truncatePurgeError 截断保存到任务记录中的错误信息
+func truncatePurgeError(err error) string {
+	message := err.Error()
+	if len(message) > maxPurgeErrorLength {
+		message = strings.ToValidUTF8(message[:maxPurgeErrorLength], "")
+	}
+	return message
+}
@@ -0,0 +1,75 @@
+package explorer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// ScanQueueName 病毒扫描任务队列名称
+const ScanQueueName = "file_scan_queue"
+
+// initialScanStatus 启用扫描时,新写入的内容先标记为等待扫描
+func initialScanStatus(cfg *config.Config) string {
+	if cfg.Scan.Enabled {
+		return models.ScanStatusPending
+	}
+	return ""
+}
+
+// scanTaskEvents 为文件当前指向的对象生成扫描任务消息,文件保持等待扫描状态直到扫描完成
+func scanTaskEvents(cfg *config.Config, file *models.File) ([]models.OutboxEvent, error) {
+	if !cfg.Scan.Enabled || file.OssKey == nil {
+		return nil, nil
+	}
+
+	task := models.ScanFileTask{
+		FileID: file.ID,
+		UserID: file.UserID,
+		OssKey: *file.OssKey,
+		Size:   file.Size,
+	}
+	if file.OssBucket != nil {
+		task.OssBucket = *file.OssBucket
+	}
+	if file.VersionID != nil {
+		task.VersionID = *file.VersionID
+	}
+
+	event, err := models.NewQueueEvent(ScanQueueName, task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal scan task: %w", err)
+	}
+	return []models.OutboxEvent{event}, nil
+}
+
+// addContentTaskEvents 文件内容变化后,在更新文件记录的同一事务中写入扫描、媒体元数据提取和内容索引任务
+func addContentTaskEvents(ctx context.Context, tx *gorm.DB, cfg *config.Config, file *models.File) error {
+	events, err := scanTaskEvents(cfg, file)
+	if err != nil {
+		return err
+	}
+	mediaEvents, err := mediaTaskEvents(file)
+	if err != nil {
+		return err
+	}
+	indexEvents, err := contentIndexTaskEvents(cfg, file)
+	if err != nil {
+		return err
+	}
+	events = append(events, mediaEvents...)
+	return repositories.NewOutboxRepository(tx).Add(ctx, append(events, indexEvents...)...)
+}
+
+// ensureNotQuarantined 检测到病毒的文件禁止下载和预览
+func ensureNotQuarantined(file *models.File) error {
+	if file.ScanStatus == models.ScanStatusInfected {
+		return fmt.Errorf("file %d is quarantined: %w", file.ID, xerr.ErrFileQuarantined)
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/search"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/storage"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/textextract"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"go.uber.org/zap"
+)
+
+// ContentIndexQueueName 文档内容提取和索引任务队列名称
+const ContentIndexQueueName = "content_index_queue"
+
+// defaultSearchTimeout 单个文件提取内容的默认超时时间（秒）
+const defaultSearchTimeout = 60
+
+// SearchService 文件搜索服务,按文件名搜索查询数据库,按内容搜索查询 Elasticsearch,同时匹配自定义属性
+type SearchService interface {
+	// SearchFiles 在用户自己的文件中搜索
+	SearchFiles(ctx context.Context, userID uint64, opts models.FileSearchOptions) ([]models.FileSearchResult, int64, error)
+	// IndexContent 由 Worker 调用,提取文件内容和自定义属性写入索引。提取失败时删除旧内容,文件仍可以按文件名和属性搜索到
+	IndexContent(ctx context.Context, task models.ContentIndexTask) error
+}
+
+type searchService struct {
+	fileRepo  repositories.FileRepository
+	storage   storage.StorageService
+	index     search.ContentIndex // 未开启内容搜索时为 nil
+	extractor *textextract.Extractor
+	cfg       *config.Config
+}
+
+var _ SearchService = (*searchService)(nil)
+
+// NewSearchService 创建搜索服务实例,index 为 nil 表示未开启内容搜索
+func NewSearchService(fileRepo repositories.FileRepository, storage storage.StorageService, index search.ContentIndex, cfg *config.Config) SearchService {
+	return &searchService{
+		fileRepo:  fileRepo,
+		storage:   storage,
+		index:     index,
+		extractor: textextract.NewExtractor(cfg.Search.TikaURL, cfg.Search.MaxContentSize),
+		cfg:       cfg,
+	}
+}
+
+func (s *searchService) SearchFiles(ctx context.Context, userID uint64, opts models.FileSearchOptions) ([]models.FileSearchResult, int64, error) {
+	opts.Query = strings.TrimSpace(opts.Query)
+	if opts.Query == "" {
+		return nil, 0, fmt.Errorf("search service: empty query: %w", xerr.ErrInvalidParams)
+	}
+	if !opts.Filter.Normalize() {
+		return nil, 0, fmt.Errorf("search service: invalid filter: %w", xerr.ErrInvalidParams)
+	}
+
+	switch opts.In {
+	case "", models.SearchInName:
+		files, total, err := s.fileRepo.SearchByName(ctx, userID, opts.Query, opts.Filter, opts.Page, opts.PageSize)
+		if err != nil {
+			logger.Error("SearchFiles: Failed to search files by name", zap.Uint64("userID", userID), zap.Error(err))
+			return nil, 0, fmt.Errorf("search service: failed to search files: %w", xerr.ErrDatabaseError)
+		}
+		results := make([]models.FileSearchResult, len(files))
+		for i := range files {
+			results[i].File = files[i]
+		}
+		return results, total, nil
+	case models.SearchInContent:
+		return s.searchContent(ctx, userID, opts)
+	default:
+		return nil, 0, fmt.Errorf("search service: unknown search scope %q: %w", opts.In, xerr.ErrInvalidParams)
+	}
+}
+
+// searchContent 从索引中取出命中的文件后按数据库中的当前状态和 opts.Filter 过滤,
+// 已删除或内容已被新版本替换且属性未命中的文件不出现在结果中,因此一页的结果可能少于 PageSize。
+// 大小和内容类型在索引中过滤,删除状态和修改时间随时会变化,只按数据库中的记录过滤
+func (s *searchService) searchContent(ctx context.Context, userID uint64, opts models.FileSearchOptions) ([]models.FileSearchResult, int64, error) {
+	if s.index == nil {
+		return nil, 0, fmt.Errorf("search service: %w", xerr.ErrContentSearchDisabled)
+	}
+
+	page := max(opts.Page, 1)
+	filter := search.Filter{MinSize: opts.Filter.MinSize, MaxSize: opts.Filter.MaxSize, MimePrefix: opts.Filter.MimePrefix}
+	hits, total, err := s.index.Search(ctx, userID, opts.Query, filter, (page-1)*opts.PageSize, opts.PageSize)
+	if err != nil {
+		logger.Error("SearchFiles: Failed to search content index", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, 0, fmt.Errorf("search service: failed to search content: %w", xerr.ErrInternalServer)
+	}
+	if len(hits) == 0 {
+		return []models.FileSearchResult{}, total, nil
+	}
+
+	ids := make([]uint64, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.FileID
+	}
+	files, err := s.fileRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		logger.Error("SearchFiles: Failed to get matched files", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, 0, fmt.Errorf("search service: failed to get files: %w", xerr.ErrDatabaseError)
+	}
+	byID := make(map[uint64]*models.File, len(files))
+	for i := range files {
+		byID[files[i].ID] = &files[i]
+	}
+
+	results := make([]models.FileSearchResult, 0, len(hits))
+	for _, hit := range hits {
+		file, ok := byID[hit.FileID]
+		staleContent := ok && fileVersionID(file) != hit.VersionID
+		if !ok || file.UserID != userID || file.Status != models.StatusNormal || !opts.Filter.Match(file) || (staleContent && !hit.AttributesMatched) {
+			continue
+		}
+		snippet := hit.Snippet
+		if staleContent || snippet == "" {
+			snippet = hit.AttributesSnippet
+		}
+		results = append(results, models.FileSearchResult{File: *file, Snippet: snippet})
+	}
+	return results, total, nil
+}
+
+func (s *searchService) IndexContent(ctx context.Context, task models.ContentIndexTask) error {
+	if s.index == nil {
+		return nil
+	}
+	file, err := s.fileRepo.FindByID(ctx, task.FileID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrFileNotFound) {
+			return s.index.Delete(ctx, task.FileID)
+		}
+		return err
+	}
+	if task.AttributesOnly {
+		if err := s.index.PutAttributes(ctx, file.ID, file.UserID, attributesText(file.Attributes)); err != nil {
+			return fmt.Errorf("failed to index attributes: %w", err)
+		}
+		return nil
+	}
+	// 任务投递后文件又上传了新版本,新版本有自己的索引任务
+	if fileVersionID(file) != task.VersionID {
+		return nil
+	}
+
+	timeout := s.cfg.Search.Timeout
+	if timeout <= 0 {
+		timeout = defaultSearchTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	content, err := s.extractContent(ctx, task)
+	if err != nil {
+		// 旧版本的内容已经不对应文件,删除后文件只能按文件名和属性搜索到
+		if delErr := s.dropContent(ctx, file); delErr != nil {
+			logger.Warn("IndexContent: Failed to delete stale document", zap.Uint64("fileID", task.FileID), zap.Error(delErr))
+		}
+		return fmt.Errorf("failed to extract content: %w", err)
+	}
+
+	doc := search.Document{
+		FileID:     file.ID,
+		UserID:     file.UserID,
+		VersionID:  task.VersionID,
+		Size:       file.Size,
+		Content:    content,
+		Attributes: attributesText(file.Attributes),
+	}
+	if file.MimeType != nil {
+		doc.MimeType = *file.MimeType
+	}
+	if err := s.index.Put(ctx, doc); err != nil {
+		return fmt.Errorf("failed to index content: %w", err)
+	}
+	logger.Info("IndexContent: File content indexed", zap.Uint64("fileID", file.ID), zap.Int("contentSize", len(content)))
+	return nil
+}
+
+func (s *searchService) extractContent(ctx context.Context, task models.ContentIndexTask) (string, error) {
+	object, err := s.storage.GetObject(ctx, task.OssBucket, task.OssKey, task.VersionID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get object: %w", err)
+	}
+	defer object.Reader.Close()
+	return s.extractor.Extract(ctx, object.Reader, textextract.Kind(task.Kind))
+}
+
+// dropContent 删除索引中的内容,文件有自定义属性时保留只有属性的文档
+func (s *searchService) dropContent(ctx context.Context, file *models.File) error {
+	if len(file.Attributes) == 0 {
+		return s.index.Delete(ctx, file.ID)
+	}
+	return s.index.Put(ctx, search.Document{FileID: file.ID, UserID: file.UserID, Attributes: attributesText(file.Attributes)})
+}
+
+// attributesText 按属性名排序拼接属性值,每行一个
+func attributesText(attributes models.FileAttributes) string {
+	values := make([]string, 0, len(attributes))
+	for _, key := range slices.Sorted(maps.Keys(attributes)) {
+		values = append(values, attributes[key])
+	}
+	return strings.Join(values, "\n")
+}
+
+// attributesIndexTaskEvents 开启内容搜索时,为修改了自定义属性的文件或文件夹生成索引任务消息
+func attributesIndexTaskEvents(cfg *config.Config, file *models.File) ([]models.OutboxEvent, error) {
+	if !cfg.Search.Enabled {
+		return nil, nil
+	}
+	event, err := models.NewQueueEvent(ContentIndexQueueName, models.ContentIndexTask{FileID: file.ID, UserID: file.UserID, AttributesOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal content index task: %w", err)
+	}
+	return []models.OutboxEvent{event}, nil
+}
+
+// fileVersionID 文件当前指向的对象版本,未开启版本控制时为空
+func fileVersionID(file *models.File) string {
+	if file.VersionID == nil {
+		return ""
+	}
+	return *file.VersionID
+}
+
+// contentIndexTaskEvents 开启内容搜索时,为可以提取文本的文件生成索引任务消息
+func contentIndexTaskEvents(cfg *config.Config, file *models.File) ([]models.OutboxEvent, error) {
+	if !cfg.Search.Enabled || file.OssKey == nil || file.IsFolder == 1 {
+		return nil, nil
+	}
+	if cfg.Search.MaxSourceSize > 0 && file.Size > uint64(cfg.Search.MaxSourceSize) {
+		return nil, nil
+	}
+	mimeType := ""
+	if file.MimeType != nil {
+		mimeType = *file.MimeType
+	}
+	kind := textextract.DetectKind(file.FileName, mimeType)
+	if kind == textextract.KindNone || (kind == textextract.KindPDF && cfg.Search.TikaURL == "") {
+		return nil, nil
+	}
+
+	task := models.ContentIndexTask{
+		FileID:    file.ID,
+		UserID:    file.UserID,
+		OssKey:    *file.OssKey,
+		VersionID: fileVersionID(file),
+		Kind:      string(kind),
+	}
+	if file.OssBucket != nil {
+		task.OssBucket = *file.OssBucket
+	}
+
+	event, err := models.NewQueueEvent(ContentIndexQueueName, task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal content index task: %w", err)
+	}
+	return []models.OutboxEvent{event}, nil
+}
@@ -0,0 +1,413 @@
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mail"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/activity"
+	"go.uber.org/zap"
+)
+
+// FileStatsStreamName 文件夹统计刷新事件的 Redis Stream
+const FileStatsStreamName = "file_stats_updates"
+
+const (
+	// usageTopFolders 用量明细中返回的最大文件夹数量
+	usageTopFolders = 10
+	// quotaNotifyTimeout 发送用量预警邮件的超时时间
+	quotaNotifyTimeout = 30 * time.Second
+)
+
+// FileStatsService 维护文件夹的递归统计(文件数、子文件夹数、总大小)。
+// 文件变更后发布刷新事件,由消费者异步重新计算受影响的祖先文件夹和用户的用量汇总
+type FileStatsService interface {
+	// GetStats 返回文件或文件夹的统计,文件夹优先读取预计算结果
+	GetStats(ctx context.Context, userID uint64, fileID uint64) (*models.FileStats, error)
+	// GetFolderStats 批量读取列表中文件夹的统计,缺失的即时计算并保存
+	GetFolderStats(ctx context.Context, files []models.File) (map[uint64]models.FileStats, error)
+	// GetTrashStats 统计回收站中的条目数量和总大小
+	GetTrashStats(ctx context.Context, userID uint64) (*models.TrashStats, error)
+	// NotifyChanged 发布刷新事件,folderPaths 为发生变化的文件夹完整路径,根目录为 "/"
+	NotifyChanged(ctx context.Context, userID uint64, folderPaths ...string)
+	// Refresh 重新计算 folderPaths 及其所有祖先文件夹的统计,以及用户的用量汇总
+	Refresh(ctx context.Context, userID uint64, folderPaths []string) error
+	// GetUsage 返回用户存储用量按类型、根目录文件夹和回收站的明细
+	GetUsage(ctx context.Context, userID uint64) (*models.StorageUsage, error)
+	// EnforceOrgQuota 所有者是组织网盘账号时检查团队空间能否再写入 size 字节,超出配额返回 ErrQuotaExceeded。
+	// 个人用户的配额只用于预警,不拒绝写入
+	EnforceOrgQuota(ctx context.Context, ownerID uint64, size uint64) error
+}
+
+type fileStatsService struct {
+	fileRepo      repositories.FileRepository
+	statsRepo     repositories.FileStatsRepository
+	domainService FileDomainService
+	outbox        repositories.OutboxRepository
+	userRepo      repositories.UserRepository
+	activityRepo  repositories.ActivityRepository
+	notifications activity.NotificationService
+	mailer        mail.Sender
+	quotaCfg      atomic.Pointer[config.QuotaConfig]
+}
+
+var _ FileStatsService = (*fileStatsService)(nil)
+
+func NewFileStatsService(
+	fileRepo repositories.FileRepository,
+	statsRepo repositories.FileStatsRepository,
+	domainService FileDomainService,
+	outbox repositories.OutboxRepository,
+	userRepo repositories.UserRepository,
+	activityRepo repositories.ActivityRepository,
+	notifications activity.NotificationService,
+	mailer mail.Sender,
+	quotaCfg config.QuotaConfig,
+) FileStatsService {
+	s := &fileStatsService{
+		fileRepo:      fileRepo,
+		statsRepo:     statsRepo,
+		domainService: domainService,
+		outbox:        outbox,
+		userRepo:      userRepo,
+		activityRepo:  activityRepo,
+		notifications: notifications,
+		mailer:        mailer,
+	}
+	s.quotaCfg.Store(&quotaCfg)
+	// 配置热更新后按新的比例提醒
+	config.OnChange(func(_, next *config.Config) {
+		quota := next.Quota
+		s.quotaCfg.Store(&quota)
+	})
+	return s
+}
+
+func (s *fileStatsService) GetStats(ctx context.Context, userID uint64, fileID uint64) (*models.FileStats, error) {
+	file, err := s.domainService.CheckFile(ctx, userID, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if file.IsFolder == 0 {
+		return &models.FileStats{FileID: file.ID, UserID: file.UserID, FileCount: 1, TotalSize: file.Size, UpdatedAt: file.UpdatedAt}, nil
+	}
+
+	statsMap, err := s.GetFolderStats(ctx, []models.File{*file})
+	if err != nil {
+		return nil, err
+	}
+	stats := statsMap[file.ID]
+	return &stats, nil
+}
+
+func (s *fileStatsService) GetFolderStats(ctx context.Context, files []models.File) (map[uint64]models.FileStats, error) {
+	var folderIDs []uint64
+	for _, file := range files {
+		if file.IsFolder == 1 {
+			folderIDs = append(folderIDs, file.ID)
+		}
+	}
+	result := make(map[uint64]models.FileStats, len(folderIDs))
+	if len(folderIDs) == 0 {
+		return result, nil
+	}
+
+	stored, err := s.statsRepo.FindByFileIDs(ctx, folderIDs)
+	if err != nil {
+		logger.Error("GetFolderStats: Failed to query folder stats", zap.Error(err))
+		return nil, fmt.Errorf("stats service: %w", xerr.ErrDatabaseError)
+	}
+	for _, stats := range stored {
+		result[stats.FileID] = stats
+	}
+
+	// 尚未统计过的文件夹(如功能上线前创建的)即时计算一次
+	for i := range files {
+		folder := &files[i]
+		if folder.IsFolder == 0 {
+			continue
+		}
+		if _, ok := result[folder.ID]; ok {
+			continue
+		}
+		stats, err := s.refreshFolder(ctx, folder)
+		if err != nil {
+			return nil, err
+		}
+		result[folder.ID] = *stats
+	}
+	return result, nil
+}
+
+func (s *fileStatsService) GetTrashStats(ctx context.Context, userID uint64) (*models.TrashStats, error) {
+	stats, err := s.statsRepo.AggregateDeleted(ctx, userID)
+	if err != nil {
+		logger.Error("GetTrashStats: Failed to aggregate recycle bin", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("stats service: %w", xerr.ErrDatabaseError)
+	}
+	return stats, nil
+}
+
+func (s *fileStatsService) NotifyChanged(ctx context.Context, userID uint64, folderPaths ...string) {
+	event, err := models.NewStreamEvent(FileStatsStreamName, cache.FileStatsUpdateMessage{UserID: userID, FolderPaths: folderPaths})
+	if err != nil {
+		logger.Error("NotifyChanged: Failed to marshal stats update message", zap.Uint64("userID", userID), zap.Error(err))
+		return
+	}
+	// 写入发件箱,Redis 暂时不可用时由 OutboxWorker 重试投递
+	if err := s.outbox.Add(context.WithoutCancel(ctx), event); err != nil {
+		logger.Error("NotifyChanged: Failed to publish stats update message", zap.Uint64("userID", userID), zap.Strings("paths", folderPaths), zap.Error(err))
+	}
+}
+
+func (s *fileStatsService) Refresh(ctx context.Context, userID uint64, folderPaths []string) error {
+	for _, folderPath := range ancestorFolderPaths(folderPaths) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		parentPath, folderName := path.Split(strings.TrimSuffix(folderPath, "/"))
+		folder, err := s.fileRepo.FindByPath(ctx, userID, parentPath, folderName)
+		if err != nil {
+			if errors.Is(err, xerr.ErrFileNotFound) {
+				continue // 文件夹已被删除或移走
+			}
+			return fmt.Errorf("stats service: failed to find folder %s: %w", folderPath, err)
+		}
+		if folder.IsFolder == 0 {
+			continue
+		}
+		if _, err := s.refreshFolder(ctx, folder); err != nil {
+			return err
+		}
+	}
+	_, err := s.refreshUsage(ctx, userID)
+	return err
+}
+
+func (s *fileStatsService) GetUsage(ctx context.Context, userID uint64) (*models.StorageUsage, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrUserNotFound) {
+			return nil, err
+		}
+		logger.Error("GetUsage: Failed to find user", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("stats service: %w", xerr.ErrDatabaseError)
+	}
+
+	usage, err := s.statsRepo.FindUsage(ctx, userID)
+	if err != nil {
+		logger.Error("GetUsage: Failed to query usage", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("stats service: %w", xerr.ErrDatabaseError)
+	}
+	// 尚未收到过刷新事件的用户(如功能上线前没有变更过文件的)即时计算一次
+	if usage == nil {
+		if usage, err = s.refreshUsage(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	folders, err := s.statsRepo.TopFolders(ctx, userID, usageTopFolders)
+	if err != nil {
+		logger.Error("GetUsage: Failed to query top folders", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("stats service: %w", xerr.ErrDatabaseError)
+	}
+
+	result := &models.StorageUsage{
+		TotalSpace: user.TotalSpace,
+		UsedSize:   usage.UsedSize(),
+		ByType: []models.TypeUsage{
+			{Type: models.UsageTypeImage, FileCount: usage.ImageCount, TotalSize: usage.ImageSize},
+			{Type: models.UsageTypeVideo, FileCount: usage.VideoCount, TotalSize: usage.VideoSize},
+			{Type: models.UsageTypeDocument, FileCount: usage.DocumentCount, TotalSize: usage.DocumentSize},
+			{Type: models.UsageTypeOther, FileCount: usage.OtherCount, TotalSize: usage.OtherSize},
+		},
+		TopFolders: folders,
+		Trash:      models.TrashStats{ItemCount: usage.TrashCount, FileCount: usage.TrashFileCount, TotalSize: usage.TrashSize},
+		UpdatedAt:  usage.UpdatedAt,
+	}
+	if result.TopFolders == nil {
+		result.TopFolders = []models.FolderUsage{}
+	}
+	if user.TotalSpace > 0 {
+		result.UsedPercent = math.Round(float64(result.UsedSize)/float64(user.TotalSpace)*10000) / 100
+	}
+	return result, nil
+}
+
+func (s *fileStatsService) EnforceOrgQuota(ctx context.Context, ownerID uint64, size uint64) error {
+	if size == 0 {
+		return nil
+	}
+	owner, err := s.userRepo.GetUserByID(ctx, ownerID)
+	if err != nil {
+		logger.Error("EnforceOrgQuota: Failed to find owner", zap.Uint64("ownerID", ownerID), zap.Error(err))
+		return fmt.Errorf("stats service: failed to find owner: %w", xerr.ErrDatabaseError)
+	}
+	if owner.Role != models.RoleOrg || owner.TotalSpace == 0 {
+		return nil
+	}
+	// 按文件实时汇总,不使用异步刷新的用量汇总,避免连续写入时汇总滞后导致超出配额
+	byType, err := s.statsRepo.AggregateByType(ctx, ownerID)
+	if err != nil {
+		logger.Error("EnforceOrgQuota: Failed to get owner usage", zap.Uint64("ownerID", ownerID), zap.Error(err))
+		return fmt.Errorf("stats service: failed to get owner usage: %w", xerr.ErrDatabaseError)
+	}
+	var used uint64
+	for _, usage := range byType {
+		used += usage.TotalSize
+	}
+	if used+size > owner.TotalSpace {
+		return fmt.Errorf("stats service: organization drive needs %d bytes, %d available: %w",
+			size, owner.TotalSpace-min(used, owner.TotalSpace), xerr.ErrQuotaExceeded)
+	}
+	return nil
+}
+
+// refreshUsage 重新计算用户的用量汇总并保存,用量达到预警比例时提醒用户
+func (s *fileStatsService) refreshUsage(ctx context.Context, userID uint64) (*models.UserUsage, error) {
+	byType, err := s.statsRepo.AggregateByType(ctx, userID)
+	if err != nil {
+		logger.Error("refreshUsage: Failed to aggregate usage by type", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("stats service: %w", xerr.ErrDatabaseError)
+	}
+	trash, err := s.statsRepo.AggregateDeleted(ctx, userID)
+	if err != nil {
+		logger.Error("refreshUsage: Failed to aggregate recycle bin", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("stats service: %w", xerr.ErrDatabaseError)
+	}
+
+	usage := &models.UserUsage{
+		UserID:         userID,
+		TrashCount:     trash.ItemCount,
+		TrashFileCount: trash.FileCount,
+		TrashSize:      trash.TotalSize,
+	}
+	for _, t := range byType {
+		switch t.Type {
+		case models.UsageTypeImage:
+			usage.ImageCount, usage.ImageSize = t.FileCount, t.TotalSize
+		case models.UsageTypeVideo:
+			usage.VideoCount, usage.VideoSize = t.FileCount, t.TotalSize
+		case models.UsageTypeDocument:
+			usage.DocumentCount, usage.DocumentSize = t.FileCount, t.TotalSize
+		default:
+			usage.OtherCount, usage.OtherSize = t.FileCount, t.TotalSize
+		}
+	}
+	if err := s.statsRepo.UpsertUsage(ctx, usage); err != nil {
+		logger.Error("refreshUsage: Failed to save usage", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("stats service: %w", xerr.ErrDatabaseError)
+	}
+
+	s.checkQuota(ctx, usage)
+	return usage, nil
+}
+
+// checkQuota 用量达到预警比例时记录活动日志并发送邮件,同一次超限只提醒一次。失败只记录日志
+func (s *fileStatsService) checkQuota(ctx context.Context, usage *models.UserUsage) {
+	warnPercent := s.quotaCfg.Load().WarnPercent
+	if warnPercent <= 0 {
+		return
+	}
+	user, err := s.userRepo.GetUserByID(ctx, usage.UserID)
+	if err != nil {
+		logger.Warn("checkQuota: Failed to find user", zap.Uint64("userID", usage.UserID), zap.Error(err))
+		return
+	}
+	if user.TotalSpace == 0 {
+		return
+	}
+
+	used := usage.UsedSize()
+	if used*100 < uint64(warnPercent)*user.TotalSpace {
+		if err := s.statsRepo.ClearQuotaWarning(ctx, user.ID); err != nil {
+			logger.Error("checkQuota: Failed to clear quota warning", zap.Uint64("userID", user.ID), zap.Error(err))
+		}
+		return
+	}
+
+	first, err := s.statsRepo.MarkQuotaWarned(ctx, user.ID, time.Now())
+	if err != nil {
+		logger.Error("checkQuota: Failed to mark quota warning", zap.Uint64("userID", user.ID), zap.Error(err))
+		return
+	}
+	if !first {
+		return
+	}
+
+	percent := float64(used) / float64(user.TotalSpace) * 100
+	activity := &models.Activity{
+		UserID:    user.ID,
+		Action:    models.ActivityQuotaWarn,
+		Detail:    fmt.Sprintf("storage usage %.1f%%: %d of %d bytes used", percent, used, user.TotalSpace),
+		CreatedAt: time.Now(),
+	}
+	if err := s.activityRepo.Create(ctx, activity); err != nil {
+		logger.Error("checkQuota: Failed to record quota activity", zap.Uint64("userID", user.ID), zap.Error(err))
+	}
+	s.notifications.Notify(ctx, &models.Notification{
+		UserID: user.ID,
+		Type:   models.NotificationQuotaWarning,
+		Title:  "存储空间即将用完",
+		Body:   fmt.Sprintf("已使用 %.1f%%（%d / %d 字节）", percent, used, user.TotalSpace),
+	})
+
+	if user.Email == "" {
+		return
+	}
+	mailCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), quotaNotifyTimeout)
+	defer cancel()
+	subject := "[go-clouddisk] 存储空间即将用完"
+	body := fmt.Sprintf("你的存储空间已使用 %.1f%%（%d / %d 字节）。\n\n"+
+		"空间用完后将无法继续上传文件。可以清空回收站或删除不需要的文件来释放空间。\n",
+		percent, used, user.TotalSpace)
+	if err := s.mailer.Send(mailCtx, user.Email, subject, body); err != nil {
+		logger.Error("checkQuota: Failed to send quota warning", zap.Uint64("userID", user.ID), zap.Error(err))
+	}
+}
+
+// refreshFolder 重新统计文件夹并保存
+func (s *fileStatsService) refreshFolder(ctx context.Context, folder *models.File) (*models.FileStats, error) {
+	stats, err := s.statsRepo.Aggregate(ctx, folder.UserID, folder.ID)
+	if err != nil {
+		logger.Error("refreshFolder: Failed to aggregate folder", zap.Uint64("folderID", folder.ID), zap.Error(err))
+		return nil, fmt.Errorf("stats service: %w", xerr.ErrDatabaseError)
+	}
+	stats.FileID = folder.ID
+	if err := s.statsRepo.Upsert(ctx, stats); err != nil {
+		logger.Error("refreshFolder: Failed to save folder stats", zap.Uint64("folderID", folder.ID), zap.Error(err))
+		return nil, fmt.Errorf("stats service: %w", xerr.ErrDatabaseError)
+	}
+	return stats, nil
+}
+
+// ancestorFolderPaths 展开路径上的所有祖先文件夹并去重,如 "/a/b/" 展开为 "/a/" 和 "/a/b/",根目录没有记录不需要统计
+func ancestorFolderPaths(folderPaths []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, folderPath := range folderPaths {
+		for i := 1; i < len(folderPath); i++ {
+			if folderPath[i] != '/' {
+				continue
+			}
+			ancestor := folderPath[:i+1]
+			if !seen[ancestor] {
+				seen[ancestor] = true
+				result = append(result, ancestor)
+			}
+		}
+	}
+	return result
+}
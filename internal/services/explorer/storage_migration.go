@@ -0,0 +1,482 @@
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/storage"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// StorageMigrationQueueName 存储迁移任务队列名称
+const StorageMigrationQueueName = "storage_migration_queue"
+
+const (
+	// migrationBatchSize 每次查询待迁移文件的数量
+	migrationBatchSize = 100
+	// migrationStaleAfter 迁移中的任务超过该时间没有进度更新,视为处理进程已退出,允许手动继续
+	migrationStaleAfter = 30 * time.Minute
+)
+
+// StorageMigrationService 在已配置的存储后端之间搬迁文件的存储对象。
+// 通过 GetObject/PutObject 流式复制文件当前内容和所有历史版本,再在一个事务中更新文件的存储桶和版本 ID,
+// 读取随之切换到目标后端。需要在 storageconfig.backends 中同时启用源后端和目标后端
+type StorageMigrationService interface {
+	// StartMigration 创建迁移任务并投递给 Worker,同一时间只允许一个进行中的任务
+	StartMigration(ctx context.Context, userID uint64, targetBackend string, deleteSource bool) (*models.StorageMigration, error)
+	// StartRebalance 创建重新均衡任务,把不在分片策略所选存储桶中的文件搬到对应的存储桶
+	StartRebalance(ctx context.Context, userID uint64, deleteSource bool) (*models.StorageMigration, error)
+	GetMigration(ctx context.Context, id uint64) (*models.StorageMigration, error)
+	// ListMigrations 返回最近的迁移任务
+	ListMigrations(ctx context.Context, limit int) ([]models.StorageMigration, error)
+	// ResumeMigration 继续失败或中断的任务,从 LastFileID 之后的文件开始
+	ResumeMigration(ctx context.Context, id uint64) (*models.StorageMigration, error)
+	// ProcessMigration 执行迁移任务,由 Worker 或迁移命令调用
+	ProcessMigration(ctx context.Context, id uint64) error
+	// MigrateFile 立即迁移单个文件及其所有版本,返回迁移后的文件
+	MigrateFile(ctx context.Context, fileID uint64, targetBackend string, deleteSource bool) (*models.File, error)
+}
+
+type storageMigrationService struct {
+	migrationRepo repositories.StorageMigrationRepository
+	fileRepo      repositories.FileRepository
+	tm            TransactionManager
+	storage       storage.StorageService
+	buckets       storage.BucketSelector
+	outbox        repositories.OutboxRepository
+	cfg           *config.Config
+}
+
+var _ StorageMigrationService = (*storageMigrationService)(nil)
+
+// NewStorageMigrationService 创建存储迁移服务实例。outbox 为 nil 时只创建任务,由调用方直接执行
+func NewStorageMigrationService(
+	migrationRepo repositories.StorageMigrationRepository,
+	fileRepo repositories.FileRepository,
+	tm TransactionManager,
+	storageService storage.StorageService,
+	buckets storage.BucketSelector,
+	outbox repositories.OutboxRepository,
+	cfg *config.Config,
+) StorageMigrationService {
+	return &storageMigrationService{
+		migrationRepo: migrationRepo,
+		fileRepo:      fileRepo,
+		tm:            tm,
+		storage:       storageService,
+		buckets:       buckets,
+		outbox:        outbox,
+		cfg:           cfg,
+	}
+}
+
+// objectRef 存储桶中的一个对象
+type objectRef struct {
+	Bucket    string
+	Key       string
+	VersionID string
+}
+
+func (s *storageMigrationService) StartMigration(ctx context.Context, userID uint64, targetBackend string, deleteSource bool) (*models.StorageMigration, error) {
+	targetBucket, err := s.targetBucket(targetBackend)
+	if err != nil {
+		return nil, err
+	}
+
+	migration := &models.StorageMigration{
+		TargetBackend: targetBackend,
+		TargetBucket:  targetBucket,
+		DeleteSource:  deleteSource,
+		Status:        models.MigrationStatusPending,
+		CreatedBy:     userID,
+	}
+	if err := s.createMigration(ctx, migration); err != nil {
+		return nil, err
+	}
+
+	logger.Info("StartMigration: Storage migration created", zap.Uint64("migrationID", migration.ID),
+		zap.String("targetBackend", targetBackend), zap.Bool("deleteSource", deleteSource), zap.Uint64("userID", userID))
+	return migration, nil
+}
+
+func (s *storageMigrationService) StartRebalance(ctx context.Context, userID uint64, deleteSource bool) (*models.StorageMigration, error) {
+	if s.cfg.Storage.Sharding.Strategy == "" {
+		return nil, fmt.Errorf("storage migration service: bucket sharding is not configured: %w", xerr.ErrInvalidParams)
+	}
+
+	// 重新均衡的目标存储桶因文件而异,TargetBucket 留空,查询时包含所有有存储对象的文件
+	migration := &models.StorageMigration{
+		TargetBackend: s.cfg.Storage.Type,
+		DeleteSource:  deleteSource,
+		Rebalance:     true,
+		Status:        models.MigrationStatusPending,
+		CreatedBy:     userID,
+	}
+	if err := s.createMigration(ctx, migration); err != nil {
+		return nil, err
+	}
+
+	logger.Info("StartRebalance: Bucket rebalance created", zap.Uint64("migrationID", migration.ID),
+		zap.String("strategy", s.cfg.Storage.Sharding.Strategy), zap.Bool("deleteSource", deleteSource), zap.Uint64("userID", userID))
+	return migration, nil
+}
+
+// createMigration 没有进行中的任务时保存并投递任务
+func (s *storageMigrationService) createMigration(ctx context.Context, migration *models.StorageMigration) error {
+	active, err := s.migrationRepo.FindActive(ctx)
+	if err != nil {
+		return fmt.Errorf("storage migration service: failed to find active migration: %w", xerr.ErrDatabaseError)
+	}
+	if active != nil {
+		return fmt.Errorf("storage migration service: migration %d is %s: %w", active.ID, active.Status, xerr.ErrMigrationInProgress)
+	}
+
+	err = s.tm.WithTransaction(ctx, func(tx *gorm.DB) error {
+		if err := repositories.NewStorageMigrationRepository(tx).Create(ctx, migration); err != nil {
+			return err
+		}
+		return s.enqueue(ctx, tx, migration)
+	})
+	if err != nil {
+		logger.Error("createMigration: Failed to create storage migration", zap.Error(err))
+		return fmt.Errorf("storage migration service: failed to create migration: %w", xerr.ErrDatabaseError)
+	}
+	return nil
+}
+
+func (s *storageMigrationService) GetMigration(ctx context.Context, id uint64) (*models.StorageMigration, error) {
+	migration, err := s.migrationRepo.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, xerr.ErrMigrationNotFound) {
+			return nil, fmt.Errorf("storage migration service: %w", xerr.ErrMigrationNotFound)
+		}
+		return nil, fmt.Errorf("storage migration service: failed to get migration: %w", xerr.ErrDatabaseError)
+	}
+	return migration, nil
+}
+
+func (s *storageMigrationService) ListMigrations(ctx context.Context, limit int) ([]models.StorageMigration, error) {
+	migrations, err := s.migrationRepo.List(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("storage migration service: failed to list migrations: %w", xerr.ErrDatabaseError)
+	}
+	return migrations, nil
+}
+
+func (s *storageMigrationService) ResumeMigration(ctx context.Context, id uint64) (*models.StorageMigration, error) {
+	migration, err := s.GetMigration(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch migration.Status {
+	case models.MigrationStatusCompleted:
+		return migration, nil
+	case models.MigrationStatusPending, models.MigrationStatusRunning:
+		if time.Since(migration.UpdatedAt) < migrationStaleAfter {
+			return nil, fmt.Errorf("storage migration service: migration %d is %s: %w", migration.ID, migration.Status, xerr.ErrMigrationInProgress)
+		}
+	}
+	if _, err := s.targetBucket(migration.TargetBackend); err != nil {
+		return nil, err
+	}
+
+	migration.Status = models.MigrationStatusPending
+	migration.Error = ""
+	err = s.tm.WithTransaction(ctx, func(tx *gorm.DB) error {
+		if err := repositories.NewStorageMigrationRepository(tx).Update(ctx, migration); err != nil {
+			return err
+		}
+		return s.enqueue(ctx, tx, migration)
+	})
+	if err != nil {
+		logger.Error("ResumeMigration: Failed to update storage migration", zap.Uint64("migrationID", migration.ID), zap.Error(err))
+		return nil, fmt.Errorf("storage migration service: failed to update migration: %w", xerr.ErrDatabaseError)
+	}
+
+	logger.Info("ResumeMigration: Storage migration resumed", zap.Uint64("migrationID", migration.ID), zap.Uint64("lastFileID", migration.LastFileID))
+	return migration, nil
+}
+
+func (s *storageMigrationService) ProcessMigration(ctx context.Context, id uint64) error {
+	migration, err := s.migrationRepo.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, xerr.ErrMigrationNotFound) {
+			return nil
+		}
+		return fmt.Errorf("storage migration service: failed to get migration: %w", err)
+	}
+	// 迁移中的任务说明上次处理时进程异常退出,消息重新投递后从 LastFileID 继续
+	if migration.Status != models.MigrationStatusPending && migration.Status != models.MigrationStatusRunning {
+		return nil
+	}
+
+	migration.Status = models.MigrationStatusRunning
+	if err := s.migrationRepo.Update(ctx, migration); err != nil {
+		return fmt.Errorf("storage migration service: failed to mark migration running: %w", err)
+	}
+	targetBuckets := []string{migration.TargetBucket}
+	if migration.Rebalance {
+		targetBuckets = s.buckets.Buckets()
+	}
+	if err := storage.EnsureBuckets(ctx, s.storage, targetBuckets); err != nil {
+		s.fail(ctx, migration, err)
+		return err
+	}
+
+	for {
+		files, err := s.migrationRepo.FindFilesToMigrate(ctx, migration.TargetBucket, migration.LastFileID, migrationBatchSize)
+		if err != nil {
+			err = fmt.Errorf("failed to find files to migrate: %w", err)
+			s.fail(ctx, migration, err)
+			return err
+		}
+		if len(files) == 0 {
+			break
+		}
+
+		for i := range files {
+			if err := ctx.Err(); err != nil {
+				s.fail(ctx, migration, fmt.Errorf("migration interrupted: %w", err))
+				return err
+			}
+			targetBucket := migration.TargetBucket
+			if migration.Rebalance {
+				targetBucket = s.buckets.SelectBucket(files[i].UserID, files[i].CreatedAt)
+			}
+			if *files[i].OssBucket != targetBucket {
+				size, err := s.migrateFile(ctx, &files[i], targetBucket, migration.DeleteSource)
+				if err != nil {
+					migration.FailedFiles++
+					logger.Warn("ProcessMigration: Failed to migrate file", zap.Uint64("migrationID", migration.ID), zap.Uint64("fileID", files[i].ID), zap.Error(err))
+				} else {
+					migration.MigratedFiles++
+					migration.MigratedBytes += size
+				}
+			}
+			migration.LastFileID = files[i].ID
+			if err := s.migrationRepo.Update(ctx, migration); err != nil {
+				logger.Error("ProcessMigration: Failed to save migration progress", zap.Uint64("migrationID", migration.ID), zap.Error(err))
+			}
+		}
+	}
+
+	now := time.Now()
+	migration.Status = models.MigrationStatusCompleted
+	migration.FinishedAt = &now
+	if err := s.migrationRepo.Update(ctx, migration); err != nil {
+		return fmt.Errorf("storage migration service: failed to mark migration completed: %w", err)
+	}
+
+	logger.Info("ProcessMigration: Storage migration completed", zap.Uint64("migrationID", migration.ID),
+		zap.Int64("migratedFiles", migration.MigratedFiles), zap.Uint64("migratedBytes", migration.MigratedBytes), zap.Int64("failedFiles", migration.FailedFiles))
+	return nil
+}
+
+func (s *storageMigrationService) MigrateFile(ctx context.Context, fileID uint64, targetBackend string, deleteSource bool) (*models.File, error) {
+	targetBucket, err := s.targetBucket(targetBackend)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := s.fileRepo.FindByID(ctx, fileID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrFileNotFound) {
+			return nil, fmt.Errorf("storage migration service: %w", xerr.ErrFileNotFound)
+		}
+		return nil, fmt.Errorf("storage migration service: failed to get file: %w", xerr.ErrDatabaseError)
+	}
+	if file.IsFolder == 1 || file.OssBucket == nil || file.OssKey == nil {
+		return nil, fmt.Errorf("storage migration service: file %d has no stored object: %w", fileID, xerr.ErrInvalidParams)
+	}
+	if file.Status == models.StatusDeleting {
+		return nil, fmt.Errorf("storage migration service: %w", xerr.ErrFileStatusInvalid)
+	}
+	if *file.OssBucket == targetBucket {
+		return file, nil
+	}
+
+	if err := storage.EnsureBuckets(ctx, s.storage, []string{targetBucket}); err != nil {
+		return nil, err
+	}
+	if _, err := s.migrateFile(ctx, file, targetBucket, deleteSource); err != nil {
+		if errors.Is(err, xerr.ErrVersionConflict) {
+			return nil, fmt.Errorf("storage migration service: %w", xerr.ErrVersionConflict)
+		}
+		return nil, fmt.Errorf("storage migration service: failed to migrate file %d: %w", fileID, err)
+	}
+	return s.fileRepo.WithPrimary().FindByID(ctx, fileID)
+}
+
+// migrateFile 复制文件当前内容和所有版本到目标存储桶,在事务中切换文件的存储位置,返回复制的字节数。
+// 复制期间文件被修改时放弃本次迁移并删除已复制的对象
+func (s *storageMigrationService) migrateFile(ctx context.Context, file *models.File, targetBucket string, deleteSource bool) (uint64, error) {
+	sourceBucket := *file.OssBucket
+	versions, err := s.migrationRepo.FindFileVersions(ctx, file.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find file versions: %w", err)
+	}
+
+	// 版本记录没有存储桶时与文件当前内容在同一个存储桶,已在目标存储桶中的版本不需要复制
+	versionRef := func(version models.FileVersion) objectRef {
+		bucket := version.OssBucket
+		if bucket == "" {
+			bucket = sourceBucket
+		}
+		return objectRef{Bucket: bucket, Key: version.OssKey, VersionID: version.VersionID}
+	}
+	current := objectRef{Bucket: sourceBucket, Key: *file.OssKey, VersionID: stringValue(file.VersionID)}
+	refs := []objectRef{current}
+	for _, version := range versions {
+		ref := versionRef(version)
+		if ref.Bucket != targetBucket && !slices.Contains(refs, ref) {
+			refs = append(refs, ref)
+		}
+	}
+
+	var contentType string
+	if file.MimeType != nil {
+		contentType = *file.MimeType
+	}
+	moved := make(map[objectRef]string, len(refs))
+	var copied uint64
+	for _, ref := range refs {
+		versionID, size, err := s.copyObject(ctx, targetBucket, ref, contentType)
+		if err != nil {
+			s.discardCopies(ctx, targetBucket, moved)
+			return 0, fmt.Errorf("failed to copy object %s: %w", ref.Key, err)
+		}
+		moved[ref] = versionID
+		copied += uint64(size)
+	}
+
+	err = s.tm.WithTransaction(ctx, func(tx *gorm.DB) error {
+		fileRepo := s.fileRepo.WithTx(tx)
+		migrationRepo := repositories.NewStorageMigrationRepository(tx)
+
+		latest, err := fileRepo.FindByID(ctx, file.ID)
+		if err != nil {
+			return fmt.Errorf("failed to reload file: %w", err)
+		}
+		if latest.Version != file.Version {
+			return fmt.Errorf("file %d changed during migration: %w", file.ID, xerr.ErrVersionConflict)
+		}
+
+		for _, version := range versions {
+			ref := versionRef(version)
+			if ref.Bucket == targetBucket {
+				continue
+			}
+			if err := migrationRepo.MoveVersion(ctx, version.ID, targetBucket, moved[ref]); err != nil {
+				return fmt.Errorf("failed to update file version %d: %w", version.ID, err)
+			}
+		}
+
+		bucket, versionID := targetBucket, moved[current]
+		latest.OssBucket = &bucket
+		latest.VersionID = &versionID
+		return fileRepo.Update(ctx, latest)
+	})
+	if err != nil {
+		s.discardCopies(ctx, targetBucket, moved)
+		return 0, err
+	}
+
+	if deleteSource {
+		for ref := range moved {
+			s.removeSourceObject(ctx, ref)
+		}
+	}
+
+	logger.Info("Storage object migrated", zap.Uint64("fileID", file.ID), zap.String("from", sourceBucket), zap.String("to", targetBucket), zap.Int("objects", len(moved)))
+	return copied, nil
+}
+
+// copyObject 从对象所在的存储桶流式读取对象写入目标存储桶,key 保持不变,返回目标后端中的版本 ID 和大小
+func (s *storageMigrationService) copyObject(ctx context.Context, targetBucket string, ref objectRef, contentType string) (string, int64, error) {
+	object, err := s.storage.GetObject(ctx, ref.Bucket, ref.Key, ref.VersionID)
+	if err != nil {
+		return "", 0, err
+	}
+	defer object.Reader.Close()
+
+	if object.MimeType != "" {
+		contentType = object.MimeType
+	}
+	result, err := s.storage.PutObject(ctx, targetBucket, ref.Key, object.Reader, object.Size, contentType)
+	if err != nil {
+		return "", 0, err
+	}
+	return result.VersionID, object.Size, nil
+}
+
+// discardCopies 删除迁移失败时已复制到目标存储桶且没有被其他文件引用的对象
+func (s *storageMigrationService) discardCopies(ctx context.Context, targetBucket string, moved map[objectRef]string) {
+	ctx = context.WithoutCancel(ctx)
+	for ref, versionID := range moved {
+		refs, err := s.migrationRepo.CountObjectReferences(ctx, targetBucket, ref.Key, versionID)
+		if err != nil || refs > 0 {
+			continue
+		}
+		if err := s.storage.RemoveObject(ctx, targetBucket, ref.Key, versionID); err != nil {
+			logger.Warn("Failed to remove copied object after migration failure", zap.String("bucket", targetBucket), zap.String("key", ref.Key), zap.Error(err))
+		}
+	}
+}
+
+// removeSourceObject 源存储桶中已没有文件引用该对象时删除它,秒传共享的对象要等所有引用都迁移后才会删除
+func (s *storageMigrationService) removeSourceObject(ctx context.Context, ref objectRef) {
+	refs, err := s.migrationRepo.CountObjectReferences(ctx, ref.Bucket, ref.Key, ref.VersionID)
+	if err != nil {
+		logger.Warn("Failed to count source object references, keeping object", zap.String("key", ref.Key), zap.Error(err))
+		return
+	}
+	if refs > 0 {
+		return
+	}
+	if err := s.storage.RemoveObject(ctx, ref.Bucket, ref.Key, ref.VersionID); err != nil {
+		logger.Warn("Failed to remove migrated source object", zap.String("bucket", ref.Bucket), zap.String("key", ref.Key), zap.Error(err))
+	}
+}
+
+// targetBucket 校验目标存储类型已启用,返回其存储桶
+func (s *storageMigrationService) targetBucket(backend string) (string, error) {
+	if backend != s.cfg.Storage.Type && !slices.Contains(s.cfg.Storage.Backends, backend) {
+		return "", fmt.Errorf("storage migration service: storage backend %q is not enabled: %w", backend, xerr.ErrInvalidParams)
+	}
+	return s.cfg.BucketNameFor(backend), nil
+}
+
+// enqueue 在事务中写入迁移任务消息,未配置 outbox 时由调用方直接执行
+func (s *storageMigrationService) enqueue(ctx context.Context, tx *gorm.DB, migration *models.StorageMigration) error {
+	if s.outbox == nil {
+		return nil
+	}
+	event, err := models.NewQueueEvent(StorageMigrationQueueName, models.StorageMigrationTask{MigrationID: migration.ID})
+	if err != nil {
+		return err
+	}
+	return s.outbox.WithTx(tx).Add(ctx, event)
+}
+
+func (s *storageMigrationService) fail(ctx context.Context, migration *models.StorageMigration, cause error) {
+	logger.Error("Storage migration failed", zap.Uint64("migrationID", migration.ID), zap.Uint64("lastFileID", migration.LastFileID), zap.Error(cause))
+	message := cause.Error()
+	if len(message) > maxExportErrorLength {
+		message = strings.ToValidUTF8(message[:maxExportErrorLength], "")
+	}
+	migration.Status = models.MigrationStatusFailed
+	migration.Error = message
+	if err := s.migrationRepo.Update(context.WithoutCancel(ctx), migration); err != nil {
+		logger.Error("Failed to mark storage migration failed", zap.Uint64("migrationID", migration.ID), zap.Error(err))
+	}
+}
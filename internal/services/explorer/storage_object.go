@@ -0,0 +1,68 @@
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/storage"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"go.uber.org/zap"
+)
+
+// RemoveReleasedObjects 认领引用数已降为 0 的对象并按存储桶分组删除物理文件,没有记录存储桶的对象属于 defaultBucket。
+// 同一对象只有一个调用方能认领,认领前又被引用的对象会保留。删除失败时恢复计数记录以便重试,返回遇到的删除错误
+func RemoveReleasedObjects(ctx context.Context, objectRepo repositories.StorageObjectRepository, ss storage.StorageService, defaultBucket string, objects []models.StorageObject) error {
+	var buckets []string
+	groups := make(map[string][]*models.StorageObject)
+	for i := range objects {
+		bucket := objects[i].Bucket
+		if bucket == "" {
+			bucket = defaultBucket
+		}
+		if _, ok := groups[bucket]; !ok {
+			buckets = append(buckets, bucket)
+		}
+		groups[bucket] = append(groups[bucket], &objects[i])
+	}
+
+	var errs []error
+	for _, bucket := range buckets {
+		if err := removeBucketObjects(ctx, objectRepo, ss, bucket, groups[bucket]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// removeBucketObjects 认领并删除同一存储桶中的对象
+func removeBucketObjects(ctx context.Context, objectRepo repositories.StorageObjectRepository, ss storage.StorageService, bucket string, objects []*models.StorageObject) error {
+	var errs []error
+	for _, object := range objects {
+		claimed, err := objectRepo.Claim(ctx, object.Bucket, object.OssKey, object.VersionID)
+		if err != nil {
+			logger.Error("RemoveReleasedObjects: Failed to claim object, keeping physical file",
+				zap.String("bucket", bucket), zap.String("key", object.OssKey), zap.String("versionID", object.VersionID), zap.Error(err))
+			errs = append(errs, fmt.Errorf("failed to claim object %s/%s: %w", bucket, object.OssKey, err))
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := ss.RemoveObject(ctx, bucket, object.OssKey, object.VersionID); err != nil {
+			logger.Error("RemoveReleasedObjects: Failed to remove physical file",
+				zap.String("bucket", bucket), zap.String("key", object.OssKey), zap.String("versionID", object.VersionID), zap.Error(err))
+			if restoreErr := objectRepo.Restore(context.WithoutCancel(ctx), object); restoreErr != nil {
+				logger.Error("RemoveReleasedObjects: Failed to restore object record (need manual cleanup)",
+					zap.String("bucket", bucket), zap.String("key", object.OssKey), zap.String("versionID", object.VersionID), zap.Error(restoreErr))
+			}
+			errs = append(errs, fmt.Errorf("failed to remove object %s/%s: %w", bucket, object.OssKey, err))
+			continue
+		}
+		logger.Info("Unreferenced object removed", zap.String("bucket", bucket), zap.String("key", object.OssKey), zap.String("versionID", object.VersionID))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,224 @@
+package explorer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxTagLength 单个标签的最大字符数
+	maxTagLength = 64
+	// maxTagsPerFile 每个用户在单个文件上最多可以添加的标签数
+	maxTagsPerFile = 20
+)
+
+// TagService 文件标签。标签属于打标签的用户,可以给自己的文件和共享给自己的文件打标签
+type TagService interface {
+	// AddTags 给文件添加标签,返回添加后文件上的全部标签
+	AddTags(ctx context.Context, userID uint64, fileID uint64, tags []string) ([]string, error)
+	// RemoveTag 移除文件上的一个标签
+	RemoveTag(ctx context.Context, userID uint64, fileID uint64, tag string) error
+	// GetFileTags 返回文件上的全部标签
+	GetFileTags(ctx context.Context, userID uint64, fileID uint64) ([]string, error)
+	// ListTags 列出用户使用过的标签和对应的文件数量
+	ListTags(ctx context.Context, userID uint64) ([]models.TagCount, error)
+	// ListFilesByTag 分页列出带有指定标签的文件,已无权访问的文件不会返回
+	ListFilesByTag(ctx context.Context, userID uint64, tag string, page, pageSize int) ([]models.File, int64, error)
+}
+
+type tagService struct {
+	tagRepo       repositories.FileTagRepository
+	domainService FileDomainService
+}
+
+var _ TagService = (*tagService)(nil)
+
+// NewTagService 创建文件标签服务实例
+func NewTagService(tagRepo repositories.FileTagRepository, domainService FileDomainService) TagService {
+	return &tagService{
+		tagRepo:       tagRepo,
+		domainService: domainService,
+	}
+}
+
+// NormalizeTag 去除首尾空白并转为小写,标签不能为空、过长或包含逗号和控制字符
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" || utf8.RuneCountInString(tag) > maxTagLength {
+		return "", xerr.ErrTagInvalid
+	}
+	for _, r := range tag {
+		if r == ',' || unicode.IsControl(r) {
+			return "", xerr.ErrTagInvalid
+		}
+	}
+	return tag, nil
+}
+
+func (s *tagService) AddTags(ctx context.Context, userID uint64, fileID uint64, tags []string) ([]string, error) {
+	if _, err := s.domainService.CheckFile(ctx, userID, fileID); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.fileTags(userID, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(existing)+len(tags))
+	for _, tag := range existing {
+		seen[tag] = true
+	}
+	var toAdd []models.FileTag
+	for _, raw := range tags {
+		tag, err := NormalizeTag(raw)
+		if err != nil {
+			return nil, fmt.Errorf("tag service: invalid tag %q: %w", raw, err)
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		toAdd = append(toAdd, models.FileTag{UserID: userID, FileID: fileID, Tag: tag})
+	}
+	if len(seen) > maxTagsPerFile {
+		return nil, fmt.Errorf("tag service: file %d would have %d tags: %w", fileID, len(seen), xerr.ErrTooManyTags)
+	}
+
+	if err := s.tagRepo.Create(toAdd); err != nil {
+		logger.Error("AddTags: Failed to save tags", zap.Uint64("userID", userID), zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, fmt.Errorf("tag service: failed to save tags: %w", xerr.ErrDatabaseError)
+	}
+	return s.fileTags(userID, fileID)
+}
+
+func (s *tagService) RemoveTag(ctx context.Context, userID uint64, fileID uint64, tag string) error {
+	tag, err := NormalizeTag(tag)
+	if err != nil {
+		return fmt.Errorf("tag service: %w", err)
+	}
+
+	deleted, err := s.tagRepo.Delete(userID, fileID, []string{tag})
+	if err != nil {
+		logger.Error("RemoveTag: Failed to delete tag", zap.Uint64("userID", userID), zap.Uint64("fileID", fileID), zap.Error(err))
+		return fmt.Errorf("tag service: failed to delete tag: %w", xerr.ErrDatabaseError)
+	}
+	if deleted == 0 {
+		return fmt.Errorf("tag service: tag %q not found on file %d: %w", tag, fileID, xerr.ErrFileNotFound)
+	}
+	return nil
+}
+
+func (s *tagService) GetFileTags(ctx context.Context, userID uint64, fileID uint64) ([]string, error) {
+	if _, err := s.domainService.CheckFile(ctx, userID, fileID); err != nil {
+		return nil, err
+	}
+	return s.fileTags(userID, fileID)
+}
+
+func (s *tagService) ListTags(ctx context.Context, userID uint64) ([]models.TagCount, error) {
+	counts, err := s.tagRepo.CountTags(userID)
+	if err != nil {
+		logger.Error("ListTags: Failed to count tags", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("tag service: failed to count tags: %w", xerr.ErrDatabaseError)
+	}
+	return counts, nil
+}
+
+func (s *tagService) ListFilesByTag(ctx context.Context, userID uint64, tag string, page, pageSize int) ([]models.File, int64, error) {
+	tag, err := NormalizeTag(tag)
+	if err != nil {
+		return nil, 0, fmt.Errorf("tag service: %w", err)
+	}
+
+	files, total, err := s.tagRepo.FindFilesByTag(ctx, userID, tag, page, pageSize)
+	if err != nil {
+		logger.Error("ListFilesByTag: Failed to list files", zap.Uint64("userID", userID), zap.String("tag", tag), zap.Error(err))
+		return nil, 0, fmt.Errorf("tag service: failed to list files by tag: %w", xerr.ErrDatabaseError)
+	}
+
+	// 给共享文件打的标签在授权撤销后保留,但文件不再可见
+	visible := files[:0]
+	for i := range files {
+		if s.domainService.ValidateFile(ctx, userID, &files[i]) == nil {
+			visible = append(visible, files[i])
+		}
+	}
+	return visible, total, nil
+}
+
+// checkTagPropagation 校验移动时的标签处理方式,为空表示 keep
+func checkTagPropagation(mode string) error {
+	switch mode {
+	case "", models.TagPropagationKeep, models.TagPropagationInherit, models.TagPropagationReplace:
+		return nil
+	}
+	return fmt.Errorf("tag service: unknown tag propagation mode %q: %w", mode, xerr.ErrInvalidParams)
+}
+
+// propagateTags 按 mode 处理移动后文件的标签,见 models.TagPropagation*。
+// tagRepo 与移动使用同一事务,标签处理失败时移动一起回滚
+func propagateTags(tagRepo repositories.FileTagRepository, userID uint64, fileIDs []uint64, targetParentID *uint64, mode string) error {
+	if mode == "" || mode == models.TagPropagationKeep || len(fileIDs) == 0 {
+		return nil
+	}
+	if err := checkTagPropagation(mode); err != nil {
+		return err
+	}
+
+	// 根目录没有标签,replace 模式下相当于清空标签
+	var targetTags []string
+	if targetParentID != nil {
+		tags, err := loadFileTags(tagRepo, userID, *targetParentID)
+		if err != nil {
+			return err
+		}
+		targetTags = tags
+	}
+
+	for _, fileID := range fileIDs {
+		if mode == models.TagPropagationReplace {
+			if _, err := tagRepo.Delete(userID, fileID, nil); err != nil {
+				logger.Error("propagateTags: Failed to clear tags", zap.Uint64("userID", userID), zap.Uint64("fileID", fileID), zap.Error(err))
+				return fmt.Errorf("tag service: failed to clear tags: %w", xerr.ErrDatabaseError)
+			}
+		}
+
+		toAdd := make([]models.FileTag, 0, len(targetTags))
+		for _, tag := range targetTags {
+			toAdd = append(toAdd, models.FileTag{UserID: userID, FileID: fileID, Tag: tag})
+		}
+		if err := tagRepo.Create(toAdd); err != nil {
+			logger.Error("propagateTags: Failed to save tags", zap.Uint64("userID", userID), zap.Uint64("fileID", fileID), zap.Error(err))
+			return fmt.Errorf("tag service: failed to save tags: %w", xerr.ErrDatabaseError)
+		}
+	}
+	return nil
+}
+
+// fileTags 返回用户在文件上的标签名,按名称排序
+func (s *tagService) fileTags(userID uint64, fileID uint64) ([]string, error) {
+	return loadFileTags(s.tagRepo, userID, fileID)
+}
+
+func loadFileTags(tagRepo repositories.FileTagRepository, userID uint64, fileID uint64) ([]string, error) {
+	records, err := tagRepo.FindByFileIDs(userID, []uint64{fileID})
+	if err != nil {
+		logger.Error("fileTags: Failed to get tags", zap.Uint64("userID", userID), zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, fmt.Errorf("tag service: failed to get tags: %w", xerr.ErrDatabaseError)
+	}
+	tags := make([]string, 0, len(records))
+	for _, record := range records {
+		tags = append(tags, record.Tag)
+	}
+	return tags, nil
+}
@@ -19,7 +19,7 @@ func NewTransactionManager(db *gorm.DB) TransactionManager {
 }
 
 func (tm *transactionManager) WithTransaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
-	tx := tm.db.Begin()
+	tx := tm.db.WithContext(ctx).Begin()
 	if tx.Error != nil {
 		return tx.Error
 	}
@@ -0,0 +1,346 @@
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/activity"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// TransferService 跨用户转存: 把文件或文件夹的副本发送给其他用户。
+// 副本直接引用原有的存储对象,不复制物理文件,删除时由删除 Worker 检查引用计数
+type TransferService interface {
+	// Transfer 将 fileID 的副本发送到接收方的接收文件夹,返回接收方新建的根记录。
+	// copyTags 为 true 时发送方的标签一并复制给接收方
+	Transfer(ctx context.Context, senderID uint64, fileID uint64, recipientUsername string, copyTags bool) (*models.File, error)
+}
+
+type transferService struct {
+	fileRepo        repositories.FileRepository
+	userRepo        repositories.UserRepository
+	statsRepo       repositories.FileStatsRepository
+	domainService   FileDomainService
+	tm              TransactionManager
+	cache           *cache.RedisCache
+	activityService activity.ActivityService
+	notifications   activity.NotificationService
+	statsService    FileStatsService
+	cfg             *config.Config
+}
+
+var _ TransferService = (*transferService)(nil)
+
+// NewTransferService 创建跨用户转存服务实例
+func NewTransferService(
+	fileRepo repositories.FileRepository,
+	userRepo repositories.UserRepository,
+	statsRepo repositories.FileStatsRepository,
+	domainService FileDomainService,
+	tm TransactionManager,
+	cache *cache.RedisCache,
+	activityService activity.ActivityService,
+	notifications activity.NotificationService,
+	statsService FileStatsService,
+	cfg *config.Config,
+) TransferService {
+	return &transferService{
+		fileRepo:        fileRepo,
+		userRepo:        userRepo,
+		statsRepo:       statsRepo,
+		domainService:   domainService,
+		tm:              tm,
+		cache:           cache,
+		activityService: activityService,
+		notifications:   notifications,
+		statsService:    statsService,
+		cfg:             cfg,
+	}
+}
+
+func (s *transferService) Transfer(ctx context.Context, senderID uint64, fileID uint64, recipientUsername string, copyTags bool) (*models.File, error) {
+	source, err := s.domainService.CheckFile(ctx, senderID, fileID)
+	if err != nil {
+		return nil, err
+	}
+	// 只有所有者可以转存,协作者需要先下载再上传
+	if source.UserID != senderID {
+		return nil, fmt.Errorf("transfer service: %w", xerr.ErrPermissionDenied)
+	}
+	if err := ensureNotQuarantined(source); err != nil {
+		return nil, fmt.Errorf("transfer service: %w", err)
+	}
+
+	recipient, err := s.userRepo.GetUserByUsername(ctx, recipientUsername)
+	if err != nil {
+		if errors.Is(err, xerr.ErrUserNotFound) {
+			return nil, fmt.Errorf("transfer service: %w", xerr.ErrUserNotFound)
+		}
+		logger.Error("Transfer: Failed to get recipient", zap.String("username", recipientUsername), zap.Error(err))
+		return nil, fmt.Errorf("transfer service: failed to get recipient: %w", xerr.ErrDatabaseError)
+	}
+	if recipient.ID == senderID {
+		return nil, fmt.Errorf("transfer service: cannot transfer to yourself: %w", xerr.ErrInvalidParams)
+	}
+
+	items, totalSize, err := s.collectTransferItems(ctx, senderID, source)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkQuota(ctx, recipient, totalSize); err != nil {
+		return nil, err
+	}
+
+	targetFolder, err := s.ensureReceivedFolder(ctx, recipient.ID)
+	if err != nil {
+		return nil, err
+	}
+	var targetParentID *uint64
+	targetPath := "/"
+	if targetFolder != nil {
+		targetParentID = &targetFolder.ID
+		targetPath = fullPathWithSelf(targetFolder)
+	}
+
+	rootName, err := s.domainService.ResolveFileNameConflict(ctx, recipient.ID, targetParentID, source.FileName, 0, source.IsFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	var newRoot *models.File
+	err = s.tm.WithTransaction(ctx, func(tx *gorm.DB) error {
+		fileRepo := repositories.NewCachedFileRepository(repositories.NewDBFileRepository(tx), s.cache, repositories.NewOutboxRepository(tx))
+		fileVersionRepo := repositories.NewFileVersionRepository(tx)
+		objectRepo := repositories.NewStorageObjectRepository(tx)
+
+		// items 按 BFS 顺序排列,父文件夹总是先于子项创建
+		copies := make(map[uint64]*models.File, len(items))
+		for i := range items {
+			item := &items[i]
+			parentID, parentPath, fileName := targetParentID, targetPath, rootName
+			if item.ID != source.ID {
+				parent, ok := copies[*item.ParentFolderID]
+				if !ok {
+					continue // 父文件夹不可用(如已在回收站),跳过整个子树
+				}
+				parentID, parentPath, fileName = &parent.ID, fullPathWithSelf(parent), item.FileName
+			}
+
+			copied := copyFileForRecipient(item, recipient.ID, parentID, parentPath, fileName)
+			if err := fileRepo.Create(ctx, copied); err != nil {
+				return fmt.Errorf("failed to create copy of file %d: %w", item.ID, err)
+			}
+			if copied.IsFolder == 0 {
+				// 副本引用发送方的对象,锁定计数记录防止发送方同时彻底删除时对象被认领删除
+				version := firstVersionOf(copied)
+				if version.OssKey != "" {
+					if err := objectRepo.LockReferenced(ctx, version.OssBucket, version.OssKey, version.VersionID); err != nil {
+						if errors.Is(err, gorm.ErrRecordNotFound) {
+							return fmt.Errorf("object of file %d is no longer referenced: %w", item.ID, xerr.ErrFileNotFound)
+						}
+						return fmt.Errorf("failed to lock storage object of file %d: %w", item.ID, err)
+					}
+				}
+				if err := fileVersionRepo.Create(ctx, version); err != nil {
+					return fmt.Errorf("failed to create version of copy %d: %w", copied.ID, err)
+				}
+			}
+			copies[item.ID] = copied
+		}
+		newRoot = copies[source.ID]
+
+		if copyTags {
+			return copyTagsForRecipient(repositories.NewFileTagRepository(tx), senderID, recipient.ID, copies)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, xerr.ErrFileNotFound) {
+			return nil, fmt.Errorf("transfer service: %w", xerr.ErrFileNotFound)
+		}
+		logger.Error("Transfer: Failed to copy files to recipient",
+			zap.Uint64("fileID", fileID), zap.Uint64("recipientID", recipient.ID), zap.Error(err))
+		return nil, fmt.Errorf("transfer service: failed to copy files: %w", xerr.ErrDatabaseError)
+	}
+
+	s.statsService.NotifyChanged(ctx, recipient.ID, targetPath)
+	s.activityService.Record(ctx, senderID, source.ID, models.ActivityTransferOut, "to "+recipient.Username)
+	s.activityService.Record(ctx, recipient.ID, newRoot.ID, models.ActivityTransferIn, newRoot.FileName)
+	s.notifications.Notify(ctx, &models.Notification{
+		UserID: recipient.ID,
+		Type:   models.NotificationTransferIn,
+		Title:  "收到新文件",
+		Body:   fmt.Sprintf("其他用户向你发送了 %s", newRoot.FileName),
+		FileID: &newRoot.ID,
+	})
+
+	logger.Info("Transfer: Files transferred successfully",
+		zap.Uint64("fileID", fileID),
+		zap.Uint64("senderID", senderID),
+		zap.Uint64("recipientID", recipient.ID),
+		zap.Int("items", len(items)),
+		zap.Uint64("totalSize", totalSize))
+	return newRoot, nil
+}
+
+// collectTransferItems 收集要转存的正常状态文件和文件夹,隔离的文件和快捷方式不会转存,
+// 快捷方式指向发送方的文件,接收方通常无权访问
+func (s *transferService) collectTransferItems(ctx context.Context, senderID uint64, source *models.File) ([]models.File, uint64, error) {
+	if source.IsLink {
+		return nil, 0, fmt.Errorf("transfer service: shortcuts cannot be transferred: %w", xerr.ErrInvalidParams)
+	}
+	if source.IsFolder == 0 {
+		return []models.File{*source}, source.Size, nil
+	}
+
+	allFiles, err := s.domainService.CollectAllFiles(ctx, senderID, source.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var items []models.File
+	var totalSize uint64
+	for _, file := range allFiles {
+		if file.Status != models.StatusNormal || file.ScanStatus == models.ScanStatusInfected || file.IsLink {
+			continue
+		}
+		items = append(items, file)
+		if file.IsFolder == 0 {
+			totalSize += file.Size
+		}
+	}
+	return items, totalSize, nil
+}
+
+// checkQuota 检查接收方剩余空间,TotalSpace 为 0 表示不限制
+func (s *transferService) checkQuota(ctx context.Context, recipient *models.User, size uint64) error {
+	if recipient.TotalSpace == 0 {
+		return nil
+	}
+	byType, err := s.statsRepo.AggregateByType(ctx, recipient.ID)
+	if err != nil {
+		logger.Error("Transfer: Failed to get recipient usage", zap.Uint64("recipientID", recipient.ID), zap.Error(err))
+		return fmt.Errorf("transfer service: failed to get recipient usage: %w", xerr.ErrDatabaseError)
+	}
+	var used uint64
+	for _, usage := range byType {
+		used += usage.TotalSize
+	}
+	if used+size > recipient.TotalSpace {
+		return fmt.Errorf("transfer service: recipient needs %d bytes, %d available: %w",
+			size, recipient.TotalSpace-min(used, recipient.TotalSpace), xerr.ErrQuotaExceeded)
+	}
+	return nil
+}
+
+// ensureReceivedFolder 返回接收方根目录下的接收文件夹,不存在时创建。未配置接收文件夹时返回 nil,表示根目录
+func (s *transferService) ensureReceivedFolder(ctx context.Context, recipientID uint64) (*models.File, error) {
+	folderName := s.cfg.Transfer.ReceivedFolder
+	if folderName == "" {
+		return nil, nil
+	}
+
+	folder, err := s.fileRepo.FindByPath(ctx, recipientID, "/", folderName)
+	if err == nil && folder.IsFolder == 1 {
+		return folder, nil
+	}
+	if err != nil && !errors.Is(err, xerr.ErrFileNotFound) {
+		logger.Error("Transfer: Failed to find received folder", zap.Uint64("recipientID", recipientID), zap.Error(err))
+		return nil, fmt.Errorf("transfer service: failed to find received folder: %w", xerr.ErrDatabaseError)
+	}
+
+	// 同名的是文件时,新建的文件夹自动改名
+	finalName, err := s.domainService.ResolveFileNameConflict(ctx, recipientID, nil, folderName, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	folder = &models.File{
+		UUID:     uuid.NewString(),
+		UserID:   recipientID,
+		FileName: finalName,
+		Path:     "/",
+		IsFolder: 1,
+		Status:   models.StatusNormal,
+	}
+	if err := s.fileRepo.Create(ctx, folder); err != nil {
+		logger.Error("Transfer: Failed to create received folder", zap.Uint64("recipientID", recipientID), zap.Error(err))
+		return nil, fmt.Errorf("transfer service: failed to create received folder: %w", xerr.ErrDatabaseError)
+	}
+	return folder, nil
+}
+
+// copyTagsForRecipient 把发送方在源文件上的标签复制到接收方的副本上,copies 为源文件 ID 到副本的映射
+func copyTagsForRecipient(tagRepo repositories.FileTagRepository, senderID, recipientID uint64, copies map[uint64]*models.File) error {
+	sourceIDs := make([]uint64, 0, len(copies))
+	for id := range copies {
+		sourceIDs = append(sourceIDs, id)
+	}
+	tags, err := tagRepo.FindByFileIDs(senderID, sourceIDs)
+	if err != nil {
+		return fmt.Errorf("failed to get tags of source files: %w", err)
+	}
+
+	copiedTags := make([]models.FileTag, 0, len(tags))
+	for _, tag := range tags {
+		copiedTags = append(copiedTags, models.FileTag{UserID: recipientID, FileID: copies[tag.FileID].ID, Tag: tag.Tag})
+	}
+	if err := tagRepo.Create(copiedTags); err != nil {
+		return fmt.Errorf("failed to copy tags: %w", err)
+	}
+	return nil
+}
+
+// copyFileForRecipient 复制文件记录,存储对象和哈希保持不变
+func copyFileForRecipient(source *models.File, recipientID uint64, parentID *uint64, parentPath string, fileName string) *models.File {
+	return &models.File{
+		UUID:           uuid.NewString(),
+		UserID:         recipientID,
+		ParentFolderID: parentID,
+		FileName:       fileName,
+		Path:           parentPath,
+		IsFolder:       source.IsFolder,
+		Size:           source.Size,
+		MimeType:       source.MimeType,
+		OssBucket:      source.OssBucket,
+		OssKey:         source.OssKey,
+		VersionID:      source.VersionID,
+		MD5Hash:        source.MD5Hash,
+		SHA256Hash:     source.SHA256Hash,
+		Status:         models.StatusNormal,
+		ScanStatus:     source.ScanStatus,
+	}
+}
+
+// firstVersionOf 为副本创建首个版本记录,同时作为存储对象的一个引用
+func firstVersionOf(file *models.File) *models.FileVersion {
+	version := &models.FileVersion{
+		FileID:  file.ID,
+		Version: 1,
+		Size:    file.Size,
+	}
+	if file.OssBucket != nil {
+		version.OssBucket = *file.OssBucket
+	}
+	if file.OssKey != nil {
+		version.OssKey = *file.OssKey
+	}
+	if file.VersionID != nil {
+		version.VersionID = *file.VersionID
+	}
+	if file.MD5Hash != nil {
+		version.MD5Hash = *file.MD5Hash
+	}
+	if file.SHA256Hash != nil {
+		version.SHA256Hash = *file.SHA256Hash
+	}
+	return version
+}
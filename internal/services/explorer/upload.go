@@ -1,21 +1,28 @@
 package explorer
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
-	"sort"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/3Eeeecho/go-clouddisk/internal/config"
 	"github.com/3Eeeecho/go-clouddisk/internal/models"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
-	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mq"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/metrics"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/storage"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/tracing"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
 	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/activity"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -24,13 +31,27 @@ import (
 type UploadService interface {
 	UploadInit(ctx context.Context, userID uint64, req *models.UploadInitRequest) (*models.UploadInitResponse, error)
 	UploadChunk(ctx context.Context, userID uint64, req *models.UploadChunkRequest, chunkData io.Reader) error
-	UploadComplete(ctx context.Context, userID uint64, req *models.UploadCompleteRequest) (*models.File, error)
+	// UploadComplete 完成上传并按 UploadMode 处理同名文件，返回的文件记录附带实际执行的操作
+	UploadComplete(ctx context.Context, userID uint64, req *models.UploadCompleteRequest) (*models.UploadCompleteResponse, error)
+	// AbortUpload 取消进行中的上传会话,释放存储中已上传的内容
+	AbortUpload(ctx context.Context, userID uint64, uploadID string) error
+	// ExpireStaleUploads 将 before 之前创建仍未完成的会话标记为过期并释放存储,返回处理的会话数量
+	ExpireStaleUploads(ctx context.Context, before time.Time, limit int) (int, error)
+	// SubmitUploadProof 校验初始化时下发的内容证明,通过后把上传会话转为秒传
+	SubmitUploadProof(ctx context.Context, userID uint64, req *models.UploadProofRequest) (*models.UploadInitResponse, error)
 }
 
 type UploadServiceDeps struct {
 	Cache    *cache.RedisCache
-	MQClient *mq.RabbitMQClient
+	Activity activity.ActivityService
+	Lock     FileLockService
+	Stats    FileStatsService
+	Users    repositories.UserRepository
 	Config   *config.Config
+	// Buckets 选择新对象写入的存储桶
+	Buckets storage.BucketSelector
+	// Objects 存储对象引用计数,决定被替换或未使用的对象能否删除
+	Objects repositories.StorageObjectRepository
 }
 
 type uploadService struct {
@@ -65,25 +86,63 @@ func NewUploadService(
 
 // UploadInit 处理分片上传的初始化。
 // 它通过首先检查数据库，然后检查 Redis 缓存来支持断点续传。
+// 新会话会根据文件大小协商上传方式和分片大小，恢复的会话沿用原有的协商结果。
 func (s *uploadService) UploadInit(ctx context.Context, userID uint64, req *models.UploadInitRequest) (*models.UploadInitResponse, error) {
+	ctx, span := tracing.Start(ctx, "UploadService.UploadInit")
+	defer span.End()
+
+	fileName, err := s.domainService.NormalizeFileName(req.FileName)
+	if err != nil {
+		return nil, err
+	}
+	req.FileName = fileName
+
 	objectName := s.storage.GetUploadObjName(req.FileHash, req.FileName)
-	bucketName := s.deps.Config.MinIO.BucketName
+	bucketName := s.deps.Buckets.SelectBucket(userID, time.Now())
+	plan := negotiateUploadPlan(s.deps.Config.Upload, req.FileSize)
+
+	// 0. 秒传：存储中已有相同内容时无需重新上传
+	scope := dedupScope(s.deps.Config.Upload.Dedup)
+	if req.FileSHA256 != "" && scope != models.DedupScopeOff {
+		if resp, ok := s.tryInstantUpload(ctx, userID, req); ok {
+			return resp, nil
+		}
+	}
+
+	resp, err := s.initUploadSession(ctx, userID, req, bucketName, objectName, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	// 跨用户秒传需要内容证明时,无论是否命中都下发挑战,响应中不体现其他用户的文件是否存在
+	if req.FileSHA256 != "" && scope == models.DedupScopeGlobal && s.deps.Config.Upload.Dedup.RequireProof && s.crossUserDedupAllowed(ctx, userID) {
+		resp.ProofChallenge = s.issueProofChallenge(ctx, userID, resp.UploadID, req)
+	}
+	return resp, nil
+}
 
+// initUploadSession 恢复该文件未完成的上传会话,没有时启动新会话
+func (s *uploadService) initUploadSession(ctx context.Context, userID uint64, req *models.UploadInitRequest, bucketName, objectName string, plan uploadPlan) (*models.UploadInitResponse, error) {
 	// 1. 尝试从数据库获取正在进行的上传任务
-	uploadTask, err := s.uploadRepo.FindByFileHash(req.FileHash, userID)
+	uploadTask, err := s.uploadRepo.FindByFileHash(ctx, req.FileHash, userID)
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		logger.Error("UploadInit: 从数据库获取上传任务失败", zap.Error(err), zap.String("fileHash", req.FileHash))
 		return nil, fmt.Errorf("upload service: failed to get upload task from db: %w", err)
 	}
 
 	// 2. 如果数据库中存在任务，则恢复会话
+	if uploadTask != nil && uploadTask.Strategy == models.UploadStrategySingle {
+		// 单次 PUT 的文件较小，不支持断点续传，客户端重新上传整个文件即可
+		logger.Info("UploadInit: 正在恢复已存在的单次上传会话", zap.String("uploadID", uploadTask.UploadID))
+		return newUploadInitResponse(uploadTask, []models.UploadPartInfo{}), nil
+	}
 	if uploadTask != nil {
-		parts, err := s.storage.ListObjectParts(ctx, bucketName, objectName, uploadTask.UploadID)
+		parts, err := s.storage.ListObjectParts(ctx, s.sessionBucket(uploadTask), objectName, uploadTask.UploadID)
 		if err != nil {
 			if s.storage.IsUploadIDNotFound(err) {
 				// MinIO 中的会话已过期或被中止。开启一个新的会话。
 				logger.Warn("UploadInit: 在 DB 中找到 UploadID 但在存储中未找到，正在重新初始化。", zap.String("uploadID", uploadTask.UploadID))
-				return s.startNewUploadSession(ctx, userID, req, bucketName, objectName)
+				return s.startNewUploadSession(ctx, userID, req, bucketName, objectName, plan)
 			}
 			logger.Error("UploadInit: 为已存在的 UploadID 列出分片失败", zap.Error(err), zap.String("uploadID", uploadTask.UploadID))
 			return nil, fmt.Errorf("upload service: failed to list parts: %w", err)
@@ -91,25 +150,89 @@ func (s *uploadService) UploadInit(ctx context.Context, userID uint64, req *mode
 
 		// 会话有效，返回现有状态
 		logger.Info("UploadInit: 正在恢复已存在的上传会话", zap.String("uploadID", uploadTask.UploadID), zap.Int("partCount", len(parts)))
-		return &models.UploadInitResponse{
-			FileExists:    false,
-			UploadID:      uploadTask.UploadID,
-			UploadedParts: convertToModelParts(parts),
-		}, nil
+		return newUploadInitResponse(uploadTask, convertToModelParts(parts)), nil
 	}
 
 	// 3. 如果数据库中没有任务，则启动一个新会话
-	return s.startNewUploadSession(ctx, userID, req, bucketName, objectName)
+	return s.startNewUploadSession(ctx, userID, req, bucketName, objectName, plan)
 }
 
-// startNewUploadSession 在存储中初始化一个新的分片上传并将该会话保存到数据库和 Redis。
-func (s *uploadService) startNewUploadSession(ctx context.Context, userID uint64, req *models.UploadInitRequest, bucketName, objectName string) (*models.UploadInitResponse, error) {
-	newUploadID, err := s.storage.InitMultiPartUpload(ctx, bucketName, objectName, storage.PutObjectOptions{
-		ContentType: "application/octet-stream",
-	})
+// tryInstantUpload 按 SHA-256 查找已有内容，命中时生成秒传会话，客户端直接调用完成接口即可。
+// MD5 存在碰撞风险，不再用于秒传匹配。
+// 优先匹配用户自己的文件;跨用户匹配只在配置为 global 且不要求内容证明时直接进行
+func (s *uploadService) tryInstantUpload(ctx context.Context, userID uint64, req *models.UploadInitRequest) (*models.UploadInitResponse, bool) {
+	sha256Hash := strings.ToLower(req.FileSHA256)
+	source, err := s.fileRepo.FindFileBySHA256Hash(ctx, userID, sha256Hash)
+	if errors.Is(err, xerr.ErrFileNotFound) && dedupScope(s.deps.Config.Upload.Dedup) == models.DedupScopeGlobal &&
+		!s.deps.Config.Upload.Dedup.RequireProof && s.crossUserDedupAllowed(ctx, userID) {
+		source, err = s.fileRepo.FindSharedFileBySHA256Hash(ctx, userID, sha256Hash)
+	}
 	if err != nil {
-		logger.Error("startNewUploadSession: 初始化分片上传失败", zap.Error(err))
-		return nil, fmt.Errorf("upload service: failed to init multipart upload: %w", err)
+		if !errors.Is(err, xerr.ErrFileNotFound) {
+			logger.Warn("UploadInit: Failed to find file by sha256, falling back to normal upload", zap.Error(err), zap.String("sha256", req.FileSHA256))
+		}
+		return nil, false
+	}
+
+	uploadID := uuid.NewString()
+	if !s.saveInstantUpload(ctx, userID, uploadID, source) {
+		return nil, false
+	}
+	return &models.UploadInitResponse{
+		FileExists:    true,
+		UploadID:      uploadID,
+		UploadedParts: []models.UploadPartInfo{},
+		Strategy:      models.UploadStrategyInstant,
+	}, true
+}
+
+// saveInstantUpload 以 source 的存储对象生成秒传会话,完成上传时直接引用该对象
+func (s *uploadService) saveInstantUpload(ctx context.Context, userID uint64, uploadID string, source *models.File) bool {
+	if source.OssBucket == nil || source.OssKey == nil || source.SHA256Hash == nil {
+		return false
+	}
+
+	object := uploadedObject{
+		Result: storage.PutObjectResult{
+			Bucket: *source.OssBucket,
+			Key:    *source.OssKey,
+			Size:   int64(source.Size),
+		},
+		SHA256Hash: *source.SHA256Hash,
+	}
+	if source.VersionID != nil {
+		object.Result.VersionID = *source.VersionID
+	}
+	if source.MD5Hash != nil {
+		object.MD5Hash = *source.MD5Hash
+	}
+	if source.MimeType != nil {
+		object.MimeType = *source.MimeType
+	}
+
+	if err := s.deps.Cache.Set(ctx, generateInstantKey(userID, uploadID), object, 24*time.Hour); err != nil {
+		logger.Warn("UploadInit: Failed to save instant upload session, falling back to normal upload", zap.Error(err))
+		return false
+	}
+
+	logger.Info("UploadInit: Content already exists, instant upload available",
+		zap.Uint64("userID", userID), zap.Uint64("sourceFileID", source.ID), zap.String("uploadID", uploadID))
+	return true
+}
+
+// startNewUploadSession 在存储中初始化一个新的分片上传并将该会话保存到数据库和 Redis。
+// 单次 PUT 的会话不需要在存储中初始化，只生成一个会话 ID。
+func (s *uploadService) startNewUploadSession(ctx context.Context, userID uint64, req *models.UploadInitRequest, bucketName, objectName string, plan uploadPlan) (*models.UploadInitResponse, error) {
+	newUploadID := uuid.NewString()
+	if plan.Strategy == models.UploadStrategyMultipart {
+		var err error
+		newUploadID, err = s.storage.InitMultiPartUpload(ctx, bucketName, objectName, storage.PutObjectOptions{
+			ContentType: "application/octet-stream",
+		})
+		if err != nil {
+			logger.Error("startNewUploadSession: 初始化分片上传失败", zap.Error(err))
+			return nil, fmt.Errorf("upload service: failed to init multipart upload: %w", err)
+		}
 	}
 
 	// 将新的上传任务持久化到数据库
@@ -117,12 +240,18 @@ func (s *uploadService) startNewUploadSession(ctx context.Context, userID uint64
 		FileHash:   req.FileHash,
 		UploadID:   newUploadID,
 		ObjectName: objectName,
+		Bucket:     bucketName,
 		UserID:     userID,
 		Status:     "in_progress",
+		Strategy:   plan.Strategy,
+		FileSize:   req.FileSize,
+		ChunkSize:  plan.ChunkSize,
 	}
-	if err := s.uploadRepo.Create(uploadTask); err != nil {
+	if err := s.uploadRepo.Create(ctx, uploadTask); err != nil {
 		logger.Error("startNewUploadSession: 无法将新的 uploadID 保存到数据库", zap.Error(err), zap.String("uploadID", newUploadID))
-		_ = s.storage.AbortMultiPartUpload(ctx, bucketName, objectName, newUploadID) // 回滚 MinIO 操作
+		if plan.Strategy == models.UploadStrategyMultipart {
+			_ = s.storage.AbortMultiPartUpload(ctx, bucketName, objectName, newUploadID) // 回滚 MinIO 操作
+		}
 		return nil, fmt.Errorf("upload service: failed to save session to db: %w", err)
 	}
 
@@ -133,12 +262,27 @@ func (s *uploadService) startNewUploadSession(ctx context.Context, userID uint64
 		logger.Warn("startNewUploadSession: 无法将新的 uploadID 缓存到 Redis", zap.Error(err), zap.String("uploadID", newUploadID))
 	}
 
-	logger.Info("startNewUploadSession: 已启动新的上传会话", zap.String("uploadID", newUploadID))
+	logger.Info("startNewUploadSession: 已启动新的上传会话",
+		zap.String("uploadID", newUploadID),
+		zap.String("strategy", plan.Strategy),
+		zap.Int64("chunkSize", plan.ChunkSize))
+	return newUploadInitResponse(uploadTask, []models.UploadPartInfo{}), nil
+}
+
+// newUploadInitResponse 根据上传任务记录的协商结果构造响应
+func newUploadInitResponse(task *models.MultipartUpload, parts []models.UploadPartInfo) *models.UploadInitResponse {
+	var totalChunks int64
+	if task.FileSize > 0 && task.ChunkSize > 0 {
+		totalChunks = ceilDiv(task.FileSize, task.ChunkSize)
+	}
 	return &models.UploadInitResponse{
 		FileExists:    false,
-		UploadID:      newUploadID,
-		UploadedParts: []models.UploadPartInfo{},
-	}, nil
+		UploadID:      task.UploadID,
+		UploadedParts: parts,
+		Strategy:      task.Strategy,
+		ChunkSize:     task.ChunkSize,
+		TotalChunks:   totalChunks,
+	}
 }
 
 // convertToModelParts 将存储分片信息转换为模型分片信息。
@@ -153,12 +297,80 @@ func convertToModelParts(storageParts []storage.UploadPartResult) []models.Uploa
 	return modelParts
 }
 
-// UploadChunk 处理分片上传
+// UploadChunk 处理分片上传，分片必须符合 UploadInit 协商的上传方式
 func (s *uploadService) UploadChunk(ctx context.Context, userID uint64, req *models.UploadChunkRequest, chunkData io.Reader) error {
-	//TODO 分片上传策略,大中小文件
+	ctx, span := tracing.Start(ctx, "UploadService.UploadChunk")
+	defer span.End()
+
+	// 对象名由文件名生成,分片上传时需要与初始化时使用相同的规范化名称
+	fileName, err := s.domainService.NormalizeFileName(req.FileName)
+	if err != nil {
+		return err
+	}
+	req.FileName = fileName
+
 	objectName := s.storage.GetUploadObjName(req.FileHash, req.FileName)
-	bucketName := s.deps.Config.MinIO.BucketName
 
+	task, err := s.findUploadTask(ctx, req.UploadID, userID)
+	if err != nil {
+		return err
+	}
+	bucketName := s.sessionBucket(task)
+	if err := validateChunk(task, req.ChunkNumber, req.ChunkSize); err != nil {
+		logger.Warn("UploadChunk: Chunk does not match negotiated strategy",
+			zap.String("uploadID", req.UploadID), zap.Int("chunkNumber", req.ChunkNumber), zap.Int64("chunkSize", req.ChunkSize), zap.Error(err))
+		return err
+	}
+
+	// 第一个分片包含文件头，检测内容类型，不允许上传的类型尽早拒绝
+	mimeType := defaultMimeType
+	if req.ChunkNumber == 1 {
+		head := make([]byte, utils.SniffLen)
+		n, err := io.ReadFull(chunkData, head)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			logger.Error("UploadChunk: Failed to read chunk", zap.Error(err), zap.String("uploadID", req.UploadID))
+			return fmt.Errorf("upload service: failed to read chunk: %w", err)
+		}
+		head = head[:n]
+		chunkData = io.MultiReader(bytes.NewReader(head), chunkData)
+
+		mimeType = resolveMimeType(req.FileName, utils.DetectContentType(head))
+		if err := checkFileType(s.deps.Config.Upload, req.FileName, mimeType); err != nil {
+			logger.Warn("UploadChunk: File type not allowed",
+				zap.String("uploadID", req.UploadID), zap.String("fileName", req.FileName), zap.String("mimeType", mimeType))
+			return err
+		}
+	}
+
+	// 单次 PUT 直接写入最终对象，结果暂存到 Redis 供 UploadComplete 使用
+	if task.Strategy == models.UploadStrategySingle {
+		// 上传的同时计算 MD5 和 SHA-256，避免再次读取对象
+		md5Hasher, sha256Hasher := md5.New(), sha256.New()
+		putResult, err := s.storage.PutObject(ctx, bucketName, objectName, io.TeeReader(chunkData, io.MultiWriter(md5Hasher, sha256Hasher)), req.ChunkSize, mimeType)
+		if err != nil {
+			logger.Error("UploadChunk: Failed to put object", zap.Error(err), zap.String("uploadID", req.UploadID))
+			return fmt.Errorf("upload service: failed to put object: %w", err)
+		}
+		object := uploadedObject{
+			Result:     putResult,
+			MD5Hash:    hex.EncodeToString(md5Hasher.Sum(nil)),
+			SHA256Hash: hex.EncodeToString(sha256Hasher.Sum(nil)),
+			MimeType:   mimeType,
+		}
+		if err := s.deps.Cache.Set(ctx, generateSingleResultKey(req.UploadID), object, 24*time.Hour); err != nil {
+			logger.Error("UploadChunk: Failed to save put result to redis", zap.Error(err), zap.String("uploadID", req.UploadID))
+			return fmt.Errorf("upload service: failed to save put result: %w", err)
+		}
+		metrics.AddTransferBytes(metrics.DirectionUpload, putResult.Size)
+		logger.Info("UploadChunk: Single object uploaded successfully", zap.String("uploadID", req.UploadID), zap.Int64("size", putResult.Size))
+		return nil
+	}
+
+	// 分片按顺序到达时接着上一个分片的状态计算哈希
+	hasher := s.resumePartHasher(ctx, req.UploadID, req.ChunkNumber, mimeType)
+	if hasher != nil {
+		chunkData = io.TeeReader(chunkData, hasher)
+	}
 	partResult, err := s.storage.UploadPart(ctx, bucketName, objectName, req.UploadID, chunkData, req.ChunkNumber, req.ChunkSize)
 	if err != nil {
 		logger.Error("UploadChunk: Failed to upload part", zap.Error(err), zap.String("uploadID", req.UploadID))
@@ -175,7 +387,11 @@ func (s *uploadService) UploadChunk(ctx context.Context, userID uint64, req *mod
 		// 简单起见，我们先返回错误。
 		return fmt.Errorf("upload service: failed to save part info: %w", err)
 	}
+	if hasher != nil {
+		s.savePartHashState(ctx, req.UploadID, partResult.PartNumber, partResult.ETag, hasher)
+	}
 
+	metrics.AddTransferBytes(metrics.DirectionUpload, req.ChunkSize)
 	logger.Info("UploadChunk: Part uploaded successfully",
 		zap.String("uploadID", req.UploadID),
 		zap.Int("partNumber", partResult.PartNumber),
@@ -185,153 +401,463 @@ func (s *uploadService) UploadChunk(ctx context.Context, userID uint64, req *mod
 }
 
 // UploadComplete now only creates the final file metadata record in the database.
-func (s *uploadService) UploadComplete(ctx context.Context, userID uint64, req *models.UploadCompleteRequest) (*models.File, error) {
-	// 1. 合并分块
-	redisKey := generatePartKey(req.UploadID)
-	partsMap, err := s.deps.Cache.HGetAll(ctx, redisKey)
-	if err != nil {
-		logger.Error("UploadComplete: Failed to get parts from redis", zap.Error(err), zap.String("uploadID", req.UploadID))
-		return nil, fmt.Errorf("upload service: failed to get parts info: %w", err)
-	}
+func (s *uploadService) UploadComplete(ctx context.Context, userID uint64, req *models.UploadCompleteRequest) (*models.UploadCompleteResponse, error) {
+	ctx, span := tracing.Start(ctx, "UploadService.UploadComplete")
+	defer span.End()
 
-	var parts []storage.UploadPartResult
-	for partNumberStr, etag := range partsMap {
-		partNumber, _ := strconv.Atoi(partNumberStr)
-		parts = append(parts, storage.UploadPartResult{PartNumber: partNumber, ETag: etag})
+	fileName, err := s.domainService.NormalizeFileName(req.FileName)
+	if err != nil {
+		return nil, err
 	}
-	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	req.FileName = fileName
 
 	objectName := s.storage.GetUploadObjName(req.FileHash, req.FileName)
-	bucketName := s.deps.Config.MinIO.BucketName
+	redisKey := generatePartKey(req.UploadID)
 
-	putResult, err := s.storage.CompleteMultiPartUpload(ctx, bucketName, objectName, req.UploadID, parts)
+	// 1. 秒传直接引用已有对象，其余会话需要取得上传结果并计算 SHA-256
+	object, err := s.getInstantUpload(ctx, userID, req.UploadID)
 	if err != nil {
-		logger.Error("UploadComplete: Failed to complete multipart upload", zap.Error(err), zap.String("uploadID", req.UploadID))
-		// 尝试中止 MinIO 上传并更新数据库状态
-		_ = s.storage.AbortMultiPartUpload(ctx, bucketName, objectName, req.UploadID)
-		if err := s.uploadRepo.UpdateStatus(req.UploadID, "aborted"); err != nil {
-			logger.Error("UploadComplete: Failed to update upload task status to aborted", zap.Error(err), zap.String("uploadID", req.UploadID))
+		return nil, err
+	}
+	instant := object != nil
+	if !instant {
+		task, err := s.findUploadTask(ctx, req.UploadID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if object, err = s.finishUpload(ctx, task, redisKey, s.sessionBucket(task), objectName); err != nil {
+			return nil, err
 		}
-		return nil, fmt.Errorf("upload service: failed to complete multipart upload: %w", err)
 	}
-
-	// 更新数据库中的任务状态
-	if err := s.uploadRepo.UpdateStatus(req.UploadID, "completed"); err != nil {
-		// 主要流程已成功，这里只记录错误
-		logger.Error("UploadComplete: Failed to update upload task status to completed", zap.Error(err), zap.String("uploadID", req.UploadID))
+	// 秒传引用的旧文件和升级前上传的单次 PUT 结果没有服务端计算的 MD5,沿用客户端声明的值
+	if object.MD5Hash == "" {
+		object.MD5Hash = req.FileHash
+	}
+	if object.MimeType == "" {
+		object.MimeType = resolveMimeType(req.FileName, "")
+	}
+	verifyErr := s.verifyUploadedObject(req, object)
+
+	// 更新数据库中的任务状态，校验失败的会话需要重新上传
+	if !instant {
+		status := "completed"
+		if verifyErr != nil {
+			status = "aborted"
+			// 刚写入的对象版本还没有被任何文件引用，直接删除
+			if err := s.storage.RemoveObject(ctx, object.Result.Bucket, object.Result.Key, object.Result.VersionID); err != nil {
+				logger.Warn("UploadComplete: Failed to remove rejected object", zap.Error(err), zap.String("key", object.Result.Key))
+			}
+		}
+		if err := s.uploadRepo.UpdateStatus(ctx, req.UploadID, status); err != nil {
+			// 主要流程已成功，这里只记录错误
+			logger.Error("UploadComplete: Failed to update upload task status", zap.Error(err), zap.String("uploadID", req.UploadID), zap.String("status", status))
+		}
 	}
 
 	// 清理 Redis 中的缓存
 	logger.Info("UploadComplete: Clearing redis cache for completed upload", zap.String("uploadID", req.UploadID))
 	defer func() {
-		_ = s.deps.Cache.Del(ctx, redisKey)
+		_ = s.deps.Cache.Del(ctx, redisKey, generateHashStateKey(req.UploadID), generateSingleResultKey(req.UploadID), generateInstantKey(userID, req.UploadID))
 		redisUploadIDKey := fmt.Sprintf("uploadid:%s", req.FileHash)
 		_ = s.deps.Cache.Del(ctx, redisUploadIDKey)
 	}()
 
+	if verifyErr != nil {
+		return nil, verifyErr
+	}
+
+	// 上传到共享文件夹或团队文件夹的文件归文件夹的所有者,占用所有者的空间
+	parentFolder, err := s.domainService.CheckWritableDirectory(ctx, userID, req.ParentFolderID)
+	if err != nil {
+		return nil, err
+	}
+	ownerID := userID
+	if parentFolder != nil {
+		ownerID = parentFolder.UserID
+	}
+
 	// 2. 数据库操作
+	mode := req.UploadMode
+	if mode == "" {
+		mode = models.UploadModeVersion
+	}
 	var finalFile *models.File
+	var action string
+	var replaced *models.FileVersion // overwrite 模式下被替换内容的原版本
+	var replacedBucket string
+	var previous *models.File // version 和 overwrite 模式下文件修改前的记录,用于清理旧内容的预览
 	err = s.tm.WithTransaction(ctx, func(tx *gorm.DB) error {
 		dbFileRepo := repositories.NewDBFileRepository(tx)
-		fileRepo := repositories.NewCachedFileRepository(dbFileRepo, s.deps.Cache)
+		fileRepo := repositories.NewCachedFileRepository(dbFileRepo, s.deps.Cache, repositories.NewOutboxRepository(tx))
 		fileVersionRepo := repositories.NewFileVersionRepository(tx)
 
+		// 秒传引用已有对象,锁定其计数记录,防止事务提交前对象的最后一个引用被删除而对象被认领删除
+		if instant {
+			if err := repositories.NewStorageObjectRepository(tx).LockReferenced(ctx, object.Result.Bucket, object.Result.Key, object.Result.VersionID); err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("upload service: instant upload source object is no longer referenced: %w", xerr.ErrUploadProofRejected)
+				}
+				return fmt.Errorf("failed to lock storage object: %w", err)
+			}
+		}
+
 		// 检查是否存在同名文件的旧版本
-		existingFile, err := fileRepo.FindByFileName(userID, req.ParentFolderID, req.FileName)
+		existingFile, err := fileRepo.FindByFileName(ctx, ownerID, req.ParentFolderID, req.FileName)
 		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 			return fmt.Errorf("failed to check for existing file: %w", err)
 		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			existingFile = nil
+		}
 
-		// 如果用户未指定模式，则默认为创建版本
-		if req.UploadMode == "" {
-			req.UploadMode = "version"
+		// 团队空间的配额是硬限制: 新建文件(包括重命名保存)占用全部大小,新版本和覆盖只计算增加的部分
+		growth := uint64(object.Result.Size)
+		switch {
+		case existingFile == nil, mode == models.UploadModeRename:
+		case mode == models.UploadModeSkip:
+			growth = 0
+		default:
+			growth -= min(growth, existingFile.Size)
+		}
+		if err := s.deps.Stats.EnforceOrgQuota(ctx, ownerID, growth); err != nil {
+			return err
 		}
 
-		if existingFile != nil && err == nil {
-			// --- 文件已存在，根据模式处理 ---
-			if req.UploadMode == "version" {
-				// --- 创建新版本 ---
-				latestVersion, err := fileVersionRepo.FindLatestVersion(existingFile.ID)
-				if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-					return fmt.Errorf("failed to find latest version: %w", err)
-				}
+		if existingFile == nil {
+			// --- 文件不存在，创建新文件 ---
+			newFile, err := s.createNewFileWithInitialVersion(ctx, fileRepo, fileVersionRepo, ownerID, req, object, req.FileName)
+			if err != nil {
+				return err
+			}
+			finalFile, action = newFile, models.UploadActionCreated
+			return addContentTaskEvents(ctx, tx, s.deps.Config, finalFile)
+		}
 
-				newVersionNumber := 1
-				if latestVersion != nil {
-					newVersionNumber = int(latestVersion.Version) + 1
-				}
+		// --- 文件已存在，根据模式处理 ---
+		switch mode {
+		case models.UploadModeSkip:
+			finalFile, action = existingFile, models.UploadActionSkipped
+			return nil
 
-				newVersion := &models.FileVersion{
-					FileID:    existingFile.ID,
-					Version:   uint(newVersionNumber),
-					Size:      uint64(putResult.Size),
-					OssKey:    putResult.Key,
-					VersionID: putResult.VersionID,
-					MD5Hash:   req.FileHash,
-				}
-				if err := fileVersionRepo.Create(newVersion); err != nil {
-					return fmt.Errorf("failed to create new file version: %w", err)
-				}
+		case models.UploadModeRename:
+			finalFileName, err := s.domainService.ResolveFileNameConflict(ctx, ownerID, req.ParentFolderID, req.FileName, 0, 0) // isFolder = 0
+			if err != nil {
+				return err
+			}
+			newFile, err := s.createNewFileWithInitialVersion(ctx, fileRepo, fileVersionRepo, ownerID, req, object, finalFileName)
+			if err != nil {
+				return err
+			}
+			finalFile, action = newFile, models.UploadActionRenamed
+			return addContentTaskEvents(ctx, tx, s.deps.Config, finalFile)
+		}
 
-				// 更新主文件记录以指向最新版本
-				existingFile.Size = uint64(putResult.Size)
-				existingFile.MD5Hash = &req.FileHash
-				existingFile.OssKey = &putResult.Key
-				existingFile.MimeType = &req.MimeType
-				existingFile.VersionID = &putResult.VersionID
-				if err := fileRepo.Update(existingFile); err != nil {
-					return fmt.Errorf("failed to update main file record: %w", err)
-				}
-				finalFile = existingFile
+		// version 和 overwrite 都会修改已有文件，被其他用户锁定的文件不允许修改
+		if err := s.deps.Lock.CheckLock(ctx, userID, existingFile.ID); err != nil {
+			return err
+		}
+		latestVersion, err := fileVersionRepo.FindLatestVersion(ctx, existingFile.ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			latestVersion = nil
+		} else if err != nil {
+			return fmt.Errorf("failed to find latest version: %w", err)
+		}
 
-			} else { // req.UploadMode == "rename"
-				// --- 重命名并创建为新文件 ---
-				finalFileName, err := s.domainService.ResolveFileNameConflict(userID, req.ParentFolderID, req.FileName, 0, 0) // isFolder = 0
-				if err != nil {
-					return err
-				}
-				newFile, err := s.createNewFileWithInitialVersion(fileRepo, fileVersionRepo, userID, req, &putResult, &bucketName, finalFileName)
-				if err != nil {
-					return err
-				}
-				finalFile = newFile
+		snapshot := *existingFile
+		previous = &snapshot
+		if mode == models.UploadModeOverwrite && latestVersion != nil {
+			// --- 替换最新版本的内容 ---
+			original := *latestVersion
+			replaced, replacedBucket = &original, object.Result.Bucket
+			if existingFile.OssBucket != nil {
+				replacedBucket = *existingFile.OssBucket
 			}
+			latestVersion.Size = uint64(object.Result.Size)
+			latestVersion.OssBucket = object.Result.Bucket
+			latestVersion.OssKey = object.Result.Key
+			latestVersion.VersionID = object.Result.VersionID
+			latestVersion.MD5Hash = object.MD5Hash
+			latestVersion.SHA256Hash = object.SHA256Hash
+			if err := fileVersionRepo.Update(ctx, latestVersion); err != nil {
+				return fmt.Errorf("failed to overwrite latest file version: %w", err)
+			}
+			action = models.UploadActionOverwritten
 		} else {
-			// --- 文件不存在，创建新文件 ---
-			newFile, err := s.createNewFileWithInitialVersion(fileRepo, fileVersionRepo, userID, req, &putResult, &bucketName, req.FileName)
-			if err != nil {
-				return err
+			// --- 创建新版本，没有版本记录的文件覆盖时同样创建第一个版本 ---
+			newVersionNumber := 1
+			if latestVersion != nil {
+				newVersionNumber = int(latestVersion.Version) + 1
+			}
+
+			newVersion := &models.FileVersion{
+				FileID:     existingFile.ID,
+				Version:    uint(newVersionNumber),
+				Size:       uint64(object.Result.Size),
+				OssBucket:  object.Result.Bucket,
+				OssKey:     object.Result.Key,
+				VersionID:  object.Result.VersionID,
+				MD5Hash:    object.MD5Hash,
+				SHA256Hash: object.SHA256Hash,
+			}
+			if err := fileVersionRepo.Create(ctx, newVersion); err != nil {
+				return fmt.Errorf("failed to create new file version: %w", err)
+			}
+			action = models.UploadActionVersioned
+			if mode == models.UploadModeOverwrite {
+				action = models.UploadActionOverwritten
 			}
-			finalFile = newFile
 		}
-		return nil
+
+		// 更新主文件记录以指向最新内容
+		existingFile.Size = uint64(object.Result.Size)
+		existingFile.MD5Hash = &object.MD5Hash
+		existingFile.SHA256Hash = &object.SHA256Hash
+		existingFile.OssBucket = &object.Result.Bucket
+		existingFile.OssKey = &object.Result.Key
+		existingFile.MimeType = &object.MimeType
+		existingFile.VersionID = &object.Result.VersionID
+		existingFile.ScanStatus = initialScanStatus(s.deps.Config)
+		if err := fileRepo.Update(ctx, existingFile); err != nil {
+			return fmt.Errorf("failed to update main file record: %w", err)
+		}
+		// Update 不保存扫描状态,内容替换后单独重置
+		if err := fileRepo.UpdateScanStatus(ctx, existingFile.ID, existingFile.ScanStatus); err != nil {
+			return fmt.Errorf("failed to reset scan status: %w", err)
+		}
+		finalFile = existingFile
+		return addContentTaskEvents(ctx, tx, s.deps.Config, finalFile)
 	})
 
+	if err != nil {
+		// 超出配额时刚上传的对象不会被引用,直接释放
+		if !instant && errors.Is(err, xerr.ErrQuotaExceeded) {
+			s.removeUnreferencedObject(ctx, object.Result.Bucket, object.Result.Key, object.Result.VersionID)
+		}
+		return nil, err
+	}
+
+	if action == models.UploadActionSkipped {
+		// 秒传的对象属于其他文件，刚上传的对象没有被引用，直接释放
+		if !instant {
+			s.removeUnreferencedObject(ctx, object.Result.Bucket, object.Result.Key, object.Result.VersionID)
+		}
+		logger.Info("Upload skipped because file already exists", zap.Uint64("fileID", finalFile.ID), zap.String("uploadID", req.UploadID))
+		return &models.UploadCompleteResponse{File: finalFile, Action: action, Skipped: true}, nil
+	}
+	if replaced != nil && (replaced.OssBucket != object.Result.Bucket || replaced.OssKey != object.Result.Key || replaced.VersionID != object.Result.VersionID) {
+		// 被替换的内容已在事务中释放引用,仍被其他版本记录引用时不会被认领。没有记录存储桶的旧版本与文件原来的内容在同一个存储桶
+		released := []models.StorageObject{{Bucket: replaced.OssBucket, OssKey: replaced.OssKey, VersionID: replaced.VersionID, SHA256Hash: replaced.SHA256Hash, Size: replaced.Size}}
+		if err := RemoveReleasedObjects(ctx, s.deps.Objects, s.storage, replacedBucket, released); err != nil {
+			logger.Warn("UploadComplete: Failed to remove replaced object", zap.String("key", replaced.OssKey), zap.Error(err))
+		}
+	}
+
+	if previous != nil {
+		removeStalePreviews(ctx, s.storage, previous, finalFile)
+	}
+
+	logger.Info("Upload complete and versioning handled", zap.Uint64("fileID", finalFile.ID), zap.String("action", action))
+	s.deps.Activity.Record(ctx, userID, finalFile.ID, models.ActivityUpload, finalFile.FileName)
+	s.deps.Stats.NotifyChanged(ctx, finalFile.UserID, finalFile.Path)
+	return &models.UploadCompleteResponse{File: finalFile, Action: action}, nil
+}
+
+// removeUnreferencedObject 刚写入但没有被版本记录引用的对象在其他文件也没有引用它时删除，失败只记录日志。
+// 按内容哈希命名的对象可能与已有文件共用同一个 key
+func (s *uploadService) removeUnreferencedObject(ctx context.Context, bucket, key, versionID string) {
+	referenced, err := s.deps.Objects.IsReferenced(ctx, bucket, key, versionID)
+	if err != nil {
+		logger.Error("UploadComplete: Failed to check object references, keeping physical file", zap.String("key", key), zap.Error(err))
+		return
+	}
+	if referenced {
+		return
+	}
+	if err := s.storage.RemoveObject(ctx, bucket, key, versionID); err != nil {
+		logger.Warn("UploadComplete: Failed to remove unreferenced object", zap.String("key", key), zap.Error(err))
+	}
+}
+
+// uploadedObject 已写入存储的对象及其内容哈希、服务端检测到的内容类型
+type uploadedObject struct {
+	Result     storage.PutObjectResult
+	MD5Hash    string
+	SHA256Hash string
+	MimeType   string
+}
+
+// getInstantUpload 读取秒传会话，不是秒传会话时返回 nil
+func (s *uploadService) getInstantUpload(ctx context.Context, userID uint64, uploadID string) (*uploadedObject, error) {
+	var object uploadedObject
+	if err := s.deps.Cache.Get(ctx, generateInstantKey(userID, uploadID), &object); err != nil {
+		if errors.Is(err, cache.ErrCacheMiss) {
+			return nil, nil
+		}
+		logger.Error("UploadComplete: Failed to get instant upload from redis", zap.Error(err), zap.String("uploadID", uploadID))
+		return nil, fmt.Errorf("upload service: failed to get instant upload: %w", err)
+	}
+	return &object, nil
+}
+
+// finishUpload 取得上传结果：单次 PUT 的对象在上传时已经生成并计算了哈希，分片上传需要合并分块后计算
+func (s *uploadService) finishUpload(ctx context.Context, task *models.MultipartUpload, redisKey, bucketName, objectName string) (*uploadedObject, error) {
+	if task.Strategy == models.UploadStrategySingle {
+		var object uploadedObject
+		if err := s.deps.Cache.Get(ctx, generateSingleResultKey(task.UploadID), &object); err != nil {
+			if errors.Is(err, cache.ErrCacheMiss) {
+				return nil, fmt.Errorf("upload service: %w", xerr.ErrChunkMissing)
+			}
+			logger.Error("UploadComplete: Failed to get put result from redis", zap.Error(err), zap.String("uploadID", task.UploadID))
+			return nil, fmt.Errorf("upload service: failed to get put result: %w", err)
+		}
+		return &object, nil
+	}
+
+	start := time.Now()
+	putResult, parts, err := s.completeMultipart(ctx, task, redisKey, bucketName, objectName)
 	if err != nil {
 		return nil, err
 	}
+	// 分片按顺序上传时哈希已经累积完成,否则重新读取合并后的对象
+	object := s.streamedHashes(ctx, task.UploadID, parts)
+	if object == nil {
+		if object, err = s.hashObject(ctx, task, putResult); err != nil {
+			return nil, err
+		}
+	}
+	object.Result = putResult
+	metrics.MultipartCompleteDuration.Observe(time.Since(start).Seconds())
+	return object, nil
+}
+
+// hashObject 流式读取合并后的对象计算 MD5 和 SHA-256，同时保留文件头用于检测内容类型。
+// 分片乱序上传时只能在合并后计算
+func (s *uploadService) hashObject(ctx context.Context, task *models.MultipartUpload, putResult storage.PutObjectResult) (*uploadedObject, error) {
+	result, err := s.storage.GetObject(ctx, putResult.Bucket, putResult.Key, putResult.VersionID)
+	if err != nil {
+		logger.Error("hashObject: Failed to get object", zap.Error(err), zap.String("key", putResult.Key))
+		return nil, fmt.Errorf("upload service: failed to read object: %w", xerr.ErrStorageError)
+	}
+	defer result.Reader.Close()
 
-	logger.Info("Upload complete and versioning handled", zap.Uint64("fileID", finalFile.ID))
-	return finalFile, nil
+	md5Hasher, sha256Hasher := md5.New(), sha256.New()
+	head := &sniffBuffer{}
+	if _, err := io.Copy(io.MultiWriter(md5Hasher, sha256Hasher, head), result.Reader); err != nil {
+		logger.Error("hashObject: Failed to read object", zap.Error(err), zap.String("key", putResult.Key))
+		return nil, fmt.Errorf("upload service: failed to read object: %w", xerr.ErrStorageError)
+	}
+	return &uploadedObject{
+		MD5Hash:    hex.EncodeToString(md5Hasher.Sum(nil)),
+		SHA256Hash: hex.EncodeToString(sha256Hasher.Sum(nil)),
+		MimeType:   resolveMimeType(task.ObjectName, utils.DetectContentType(head.Bytes())),
+	}, nil
+}
+
+// sniffBuffer 只保留写入内容的前 SniffLen 个字节
+type sniffBuffer struct {
+	bytes.Buffer
+}
+
+func (b *sniffBuffer) Write(p []byte) (int, error) {
+	if remain := utils.SniffLen - b.Len(); remain > 0 {
+		b.Buffer.Write(p[:min(remain, len(p))])
+	}
+	return len(p), nil
+}
+
+// verifyUploadedObject 校验服务端计算的 MD5、SHA-256 与客户端声明的是否一致,以及文件类型是否允许上传
+func (s *uploadService) verifyUploadedObject(req *models.UploadCompleteRequest, object *uploadedObject) error {
+	if !strings.EqualFold(req.FileHash, object.MD5Hash) {
+		logger.Warn("UploadComplete: MD5 mismatch",
+			zap.String("uploadID", req.UploadID), zap.String("expected", req.FileHash), zap.String("actual", object.MD5Hash))
+		return fmt.Errorf("upload service: md5 mismatch: %w", xerr.ErrHashMismatch)
+	}
+	if !sha256Matches(req.FileSHA256, object.SHA256Hash) {
+		logger.Warn("UploadComplete: SHA-256 mismatch",
+			zap.String("uploadID", req.UploadID), zap.String("expected", req.FileSHA256), zap.String("actual", object.SHA256Hash))
+		return fmt.Errorf("upload service: sha256 mismatch: %w", xerr.ErrHashMismatch)
+	}
+	if err := checkFileType(s.deps.Config.Upload, req.FileName, object.MimeType); err != nil {
+		logger.Warn("UploadComplete: File type not allowed",
+			zap.String("uploadID", req.UploadID), zap.String("fileName", req.FileName), zap.String("mimeType", object.MimeType))
+		return err
+	}
+	return nil
+}
+
+// sha256Matches 客户端未提供 SHA-256 时不做校验
+func sha256Matches(expected, actual string) bool {
+	return expected == "" || strings.EqualFold(expected, actual)
+}
+
+// completeMultipart 核对分片后按分片号顺序合并。分片缺失或不一致时返回 PartsMismatchError 并保留会话,
+// 客户端重新上传这些分片后可以再次完成;合并失败时中止上传
+func (s *uploadService) completeMultipart(ctx context.Context, task *models.MultipartUpload, redisKey, bucketName, objectName string) (storage.PutObjectResult, []storage.UploadPartResult, error) {
+	uploadID := task.UploadID
+	parts, err := s.reconcileParts(ctx, task, redisKey, bucketName, objectName)
+	if err != nil {
+		return storage.PutObjectResult{}, nil, err
+	}
+
+	putResult, err := s.storage.CompleteMultiPartUpload(ctx, bucketName, objectName, uploadID, parts)
+	if err != nil {
+		logger.Error("UploadComplete: Failed to complete multipart upload", zap.Error(err), zap.String("uploadID", uploadID))
+		// 尝试中止 MinIO 上传并更新数据库状态
+		_ = s.storage.AbortMultiPartUpload(ctx, bucketName, objectName, uploadID)
+		if err := s.uploadRepo.UpdateStatus(ctx, uploadID, "aborted"); err != nil {
+			logger.Error("UploadComplete: Failed to update upload task status to aborted", zap.Error(err), zap.String("uploadID", uploadID))
+		}
+		return storage.PutObjectResult{}, nil, fmt.Errorf("upload service: failed to complete multipart upload: %w", err)
+	}
+	return putResult, parts, nil
+}
+
+// sessionBucket 返回上传会话使用的存储桶,分片前创建的会话没有记录时使用默认存储桶
+func (s *uploadService) sessionBucket(task *models.MultipartUpload) string {
+	if task.Bucket != "" {
+		return task.Bucket
+	}
+	return s.deps.Config.DefaultBucketName()
+}
+
+// findUploadTask 查找当前用户进行中的上传任务
+func (s *uploadService) findUploadTask(ctx context.Context, uploadID string, userID uint64) (*models.MultipartUpload, error) {
+	task, err := s.uploadRepo.FindByUploadID(ctx, uploadID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("upload service: %w", xerr.ErrUploadSessionNotFound)
+		}
+		logger.Error("findUploadTask: Failed to get upload task", zap.Error(err), zap.String("uploadID", uploadID))
+		return nil, fmt.Errorf("upload service: failed to get upload task: %w", xerr.ErrDatabaseError)
+	}
+	return task, nil
 }
 
 func generatePartKey(uploadID string) string {
 	return fmt.Sprintf("upload:%s:parts", uploadID)
 }
 
+func generateSingleResultKey(uploadID string) string {
+	return fmt.Sprintf("upload:%s:single", uploadID)
+}
+
+func generateInstantKey(userID uint64, uploadID string) string {
+	return fmt.Sprintf("upload:%d:%s:instant", userID, uploadID)
+}
+
 // createNewFileWithInitialVersion 封装了创建新文件及其初始版本记录的逻辑
 func (s *uploadService) createNewFileWithInitialVersion(
+	ctx context.Context,
 	fileRepo repositories.FileRepository,
 	fileVersionRepo repositories.FileVersionRepository,
 	userID uint64,
 	req *models.UploadCompleteRequest,
-	putResult *storage.PutObjectResult,
-	bucketName *string,
+	object *uploadedObject,
 	fileName string,
 ) (*models.File, error) {
 	var parentPath = "/"
 	if req.ParentFolderID != nil {
-		parent, err := fileRepo.FindByID(*req.ParentFolderID)
+		parent, err := fileRepo.FindByID(ctx, *req.ParentFolderID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to find parent folder: %w", err)
 		}
@@ -345,30 +871,34 @@ func (s *uploadService) createNewFileWithInitialVersion(
 		ParentFolderID: req.ParentFolderID,
 		Path:           parentPath,
 		IsFolder:       0,
-		MimeType:       &req.MimeType,
-		VersionID:      &putResult.VersionID,
-		MD5Hash:        &req.FileHash,
+		MimeType:       &object.MimeType,
+		VersionID:      &object.Result.VersionID,
+		MD5Hash:        &object.MD5Hash,
+		SHA256Hash:     &object.SHA256Hash,
 		Status:         models.StatusNormal,
-		Size:           uint64(putResult.Size),
-		OssKey:         &putResult.Key,
-		OssBucket:      bucketName,
+		ScanStatus:     initialScanStatus(s.deps.Config),
+		Size:           uint64(object.Result.Size),
+		OssKey:         &object.Result.Key,
+		OssBucket:      &object.Result.Bucket,
 	}
 
 	// 1. 创建主文件记录
-	if err := fileRepo.Create(newFile); err != nil {
+	if err := fileRepo.Create(ctx, newFile); err != nil {
 		return nil, fmt.Errorf("failed to create new file: %w", err)
 	}
 
 	// 2. 为新文件创建第一个版本记录
 	firstVersion := &models.FileVersion{
-		FileID:    newFile.ID,
-		Version:   1,
-		Size:      uint64(putResult.Size),
-		OssKey:    putResult.Key,
-		VersionID: putResult.VersionID,
-		MD5Hash:   req.FileHash,
-	}
-	if err := fileVersionRepo.Create(firstVersion); err != nil {
+		FileID:     newFile.ID,
+		Version:    1,
+		Size:       uint64(object.Result.Size),
+		OssBucket:  object.Result.Bucket,
+		OssKey:     object.Result.Key,
+		VersionID:  object.Result.VersionID,
+		MD5Hash:    object.MD5Hash,
+		SHA256Hash: object.SHA256Hash,
+	}
+	if err := fileVersionRepo.Create(ctx, firstVersion); err != nil {
 		return nil, fmt.Errorf("failed to create first file version: %w", err)
 	}
 
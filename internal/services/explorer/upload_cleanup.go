@@ -0,0 +1,96 @@
+package explorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"go.uber.org/zap"
+)
+
+func (s *uploadService) AbortUpload(ctx context.Context, userID uint64, uploadID string) error {
+	// 秒传会话只保存在 Redis 中
+	instant, err := s.getInstantUpload(ctx, userID, uploadID)
+	if err != nil {
+		return err
+	}
+	if instant != nil {
+		_ = s.deps.Cache.Del(ctx, generateInstantKey(userID, uploadID))
+		logger.Info("AbortUpload: Instant upload session cancelled", zap.Uint64("userID", userID), zap.String("uploadID", uploadID))
+		return nil
+	}
+
+	task, err := s.findUploadTask(ctx, uploadID, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.releaseUploadSession(ctx, task, "aborted"); err != nil {
+		return err
+	}
+
+	logger.Info("AbortUpload: Upload session cancelled", zap.Uint64("userID", userID), zap.String("uploadID", uploadID))
+	return nil
+}
+
+func (s *uploadService) ExpireStaleUploads(ctx context.Context, before time.Time, limit int) (int, error) {
+	tasks, err := s.uploadRepo.FindStale(ctx, before, limit)
+	if err != nil {
+		logger.Error("ExpireStaleUploads: Failed to find stale upload sessions", zap.Error(err))
+		return 0, fmt.Errorf("upload service: failed to find stale uploads: %w", xerr.ErrDatabaseError)
+	}
+
+	expired := 0
+	for i := range tasks {
+		if ctx.Err() != nil {
+			break
+		}
+		// 单个会话失败不影响其他会话,保持进行中状态等待下一轮重试
+		if err := s.releaseUploadSession(ctx, &tasks[i], "expired"); err != nil {
+			logger.Warn("ExpireStaleUploads: Failed to expire upload session", zap.String("uploadID", tasks[i].UploadID), zap.Error(err))
+			continue
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// releaseUploadSession 中止存储中的分片上传或删除单次上传已写入的对象,更新任务状态并清理 Redis 中的会话数据
+func (s *uploadService) releaseUploadSession(ctx context.Context, task *models.MultipartUpload, status string) error {
+	switch task.Strategy {
+	case models.UploadStrategySingle:
+		// 单次上传已写入但未完成的对象没有被任何文件引用,直接删除
+		var object uploadedObject
+		err := s.deps.Cache.Get(ctx, generateSingleResultKey(task.UploadID), &object)
+		if err == nil {
+			if err := s.storage.RemoveObject(ctx, object.Result.Bucket, object.Result.Key, object.Result.VersionID); err != nil {
+				logger.Error("releaseUploadSession: Failed to remove uncommitted object", zap.Error(err), zap.String("uploadID", task.UploadID))
+				return fmt.Errorf("upload service: failed to remove uncommitted object: %w", xerr.ErrStorageError)
+			}
+		} else if !errors.Is(err, cache.ErrCacheMiss) {
+			return fmt.Errorf("upload service: failed to get put result: %w", err)
+		}
+	default:
+		err := s.storage.AbortMultiPartUpload(ctx, s.sessionBucket(task), task.ObjectName, task.UploadID)
+		if err != nil && !s.storage.IsUploadIDNotFound(err) {
+			logger.Error("releaseUploadSession: Failed to abort multipart upload", zap.Error(err), zap.String("uploadID", task.UploadID))
+			return fmt.Errorf("upload service: failed to abort multipart upload: %w", xerr.ErrStorageError)
+		}
+	}
+
+	if err := s.uploadRepo.UpdateStatus(ctx, task.UploadID, status); err != nil {
+		logger.Error("releaseUploadSession: Failed to update upload task status", zap.Error(err), zap.String("uploadID", task.UploadID), zap.String("status", status))
+		return fmt.Errorf("upload service: failed to update upload status: %w", xerr.ErrDatabaseError)
+	}
+
+	_ = s.deps.Cache.Del(ctx,
+		generatePartKey(task.UploadID),
+		generateHashStateKey(task.UploadID),
+		generateSingleResultKey(task.UploadID),
+		fmt.Sprintf("uploadid:%s", task.FileHash))
+	return nil
+}
@@ -0,0 +1,139 @@
+package explorer
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"strconv"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/storage"
+	"go.uber.org/zap"
+)
+
+// 分片上传的服务端哈希: 分片按顺序到达时,上传分片的同时从上一个分片的中间状态继续计算 MD5 和 SHA-256,
+// 完成时直接得到整个文件的哈希。分片乱序、并发或重传导致状态接不上时,合并后重新读取对象计算
+
+// partHashState 分片 1..n 依次写入后的哈希中间状态,以分片号为字段保存在 Redis Hash 中
+type partHashState struct {
+	Chain    string `json:"chain"`     // 分片 1..n 的 ETag 依次累积的摘要,完成时据此确认状态对应合并使用的分片
+	MD5      []byte `json:"md5"`       // MD5 的中间状态
+	SHA256   []byte `json:"sha256"`    // SHA-256 的中间状态
+	MimeType string `json:"mime_type"` // 第一个分片检测到的内容类型
+}
+
+// partHasher 上传单个分片时累积计算的哈希
+type partHasher struct {
+	md5      hash.Hash
+	sha256   hash.Hash
+	chain    string
+	mimeType string
+}
+
+func (h *partHasher) Write(p []byte) (int, error) {
+	h.md5.Write(p)
+	h.sha256.Write(p)
+	return len(p), nil
+}
+
+func generateHashStateKey(uploadID string) string {
+	return fmt.Sprintf("upload:%s:hashes", uploadID)
+}
+
+// resumePartHasher 从上一个分片的中间状态继续计算,mimeType 只对第一个分片有效。
+// 上一个分片的状态不存在时返回 nil,该分片及之后的分片不再累积
+func (s *uploadService) resumePartHasher(ctx context.Context, uploadID string, partNumber int, mimeType string) *partHasher {
+	h := &partHasher{md5: md5.New(), sha256: sha256.New(), mimeType: mimeType}
+	if partNumber == 1 {
+		return h
+	}
+	state, ok := s.loadPartHashState(ctx, uploadID, partNumber-1)
+	if !ok || restoreHash(h.md5, state.MD5) != nil || restoreHash(h.sha256, state.SHA256) != nil {
+		return nil
+	}
+	h.chain, h.mimeType = state.Chain, state.MimeType
+	return h
+}
+
+// savePartHashState 分片上传成功后保存累积到该分片的状态,保存失败只会让完成时重新读取对象
+func (s *uploadService) savePartHashState(ctx context.Context, uploadID string, partNumber int, etag string, h *partHasher) {
+	md5State, err := h.md5.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return
+	}
+	sha256State, err := h.sha256.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(partHashState{
+		Chain:    chainETag(h.chain, etag),
+		MD5:      md5State,
+		SHA256:   sha256State,
+		MimeType: h.mimeType,
+	})
+	if err != nil {
+		return
+	}
+
+	key := generateHashStateKey(uploadID)
+	if err := s.deps.Cache.HSet(ctx, key, strconv.Itoa(partNumber), string(data)); err != nil {
+		logger.Warn("UploadChunk: Failed to save hash state", zap.String("uploadID", uploadID), zap.Int("partNumber", partNumber), zap.Error(err))
+		return
+	}
+	_ = s.deps.Cache.Expire(ctx, key, 24*time.Hour)
+}
+
+// streamedHashes 取出累积到最后一个分片的状态,状态与合并使用的分片一致时返回整个文件的哈希和内容类型,
+// 否则返回 nil,由调用方重新读取对象计算
+func (s *uploadService) streamedHashes(ctx context.Context, uploadID string, parts []storage.UploadPartResult) *uploadedObject {
+	if len(parts) == 0 {
+		return nil
+	}
+	chain := ""
+	for _, part := range parts {
+		chain = chainETag(chain, part.ETag)
+	}
+	state, ok := s.loadPartHashState(ctx, uploadID, parts[len(parts)-1].PartNumber)
+	if !ok || state.Chain != chain {
+		return nil
+	}
+
+	md5Hasher, sha256Hasher := md5.New(), sha256.New()
+	if restoreHash(md5Hasher, state.MD5) != nil || restoreHash(sha256Hasher, state.SHA256) != nil {
+		return nil
+	}
+	return &uploadedObject{
+		MD5Hash:    hex.EncodeToString(md5Hasher.Sum(nil)),
+		SHA256Hash: hex.EncodeToString(sha256Hasher.Sum(nil)),
+		MimeType:   state.MimeType,
+	}
+}
+
+func (s *uploadService) loadPartHashState(ctx context.Context, uploadID string, partNumber int) (*partHashState, bool) {
+	raw, err := s.deps.Cache.HGet(ctx, generateHashStateKey(uploadID), strconv.Itoa(partNumber))
+	if err != nil {
+		return nil, false
+	}
+	var state partHashState
+	if err := json.Unmarshal([]byte(raw), &state); err != nil {
+		return nil, false
+	}
+	return &state, true
+}
+
+// restoreHash 把中间状态恢复到 h 中
+func restoreHash(h hash.Hash, state []byte) error {
+	return h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state)
+}
+
+// chainETag 把分片的 ETag 累积到前面分片的摘要上,重传过的分片 ETag 不同,之后的状态随之失效
+func chainETag(prev, etag string) string {
+	sum := sha256.Sum256([]byte(prev + "/" + normalizeETag(etag)))
+	return hex.EncodeToString(sum[:])
+}
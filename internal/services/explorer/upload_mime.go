@@ -0,0 +1,65 @@
+package explorer
+
+import (
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strings"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+)
+
+const defaultMimeType = "application/octet-stream"
+
+// resolveMimeType 以服务端检测到的内容类型为准,不信任客户端声明的类型。
+// 检测结果为通用二进制类型时,才按扩展名推断更具体的类型
+func resolveMimeType(fileName, detected string) string {
+	if detected != "" && detected != defaultMimeType {
+		return detected
+	}
+	if byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(fileName))); byExt != "" {
+		return byExt
+	}
+	return defaultMimeType
+}
+
+// checkFileType 按配置的扩展名黑名单、MIME 黑名单和白名单检查文件是否允许上传
+func checkFileType(cfg config.UploadConfig, fileName, mimeType string) error {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	for _, blocked := range cfg.BlockedExtensions {
+		blocked = strings.ToLower(strings.TrimSpace(blocked))
+		if !strings.HasPrefix(blocked, ".") {
+			blocked = "." + blocked
+		}
+		if ext != "" && ext == blocked {
+			return fmt.Errorf("upload service: extension %s is not allowed: %w", ext, xerr.ErrFileTypeNotAllowed)
+		}
+	}
+
+	if matchMimeType(cfg.BlockedMimeTypes, mimeType) {
+		return fmt.Errorf("upload service: file type %s is not allowed: %w", mimeType, xerr.ErrFileTypeNotAllowed)
+	}
+	if len(cfg.AllowedMimeTypes) > 0 && !matchMimeType(cfg.AllowedMimeTypes, mimeType) {
+		return fmt.Errorf("upload service: file type %s is not in allowed list: %w", mimeType, xerr.ErrFileTypeNotAllowed)
+	}
+	return nil
+}
+
+// matchMimeType 忽略 charset 等参数比较 MIME 类型,支持 "image/*" 形式的通配
+func matchMimeType(patterns []string, mimeType string) bool {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(mimeType))
+	}
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasSuffix(prefix, "/") && strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package explorer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/storage"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// PartsMismatchError 完成上传前核对发现分片缺失或与记录不一致,Report 中列出需要重新上传的分片
+type PartsMismatchError struct {
+	Report models.UploadPartsReport
+}
+
+func (e *PartsMismatchError) Error() string {
+	return fmt.Sprintf("upload service: %d parts missing, %d parts mismatched: %v",
+		len(e.Report.MissingParts), len(e.Report.MismatchedParts), xerr.ErrChunkMissing)
+}
+
+// Unwrap 使 errors.Is(err, xerr.ErrChunkMissing) 成立
+func (e *PartsMismatchError) Unwrap() error {
+	return xerr.ErrChunkMissing
+}
+
+// reconcileParts 同时读取 Redis 中的分片记录和存储中实际的分片并逐个核对,返回用于合并的分片列表。
+// 存储中有而记录丢失的分片以存储为准;存储中缺少或 ETag 不一致的分片需要客户端重新上传
+func (s *uploadService) reconcileParts(ctx context.Context, task *models.MultipartUpload, redisKey, bucketName, objectName string) ([]storage.UploadPartResult, error) {
+	var recorded map[string]string
+	var stored []storage.UploadPartResult
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		var err error
+		if recorded, err = s.deps.Cache.HGetAll(gctx, redisKey); err != nil {
+			logger.Error("UploadComplete: Failed to get parts from redis", zap.Error(err), zap.String("uploadID", task.UploadID))
+			return fmt.Errorf("upload service: failed to get parts info: %w", err)
+		}
+		return nil
+	})
+	g.Go(func() error {
+		var err error
+		if stored, err = s.storage.ListObjectParts(gctx, bucketName, objectName, task.UploadID); err != nil {
+			if s.storage.IsUploadIDNotFound(err) {
+				logger.Warn("UploadComplete: Upload session not found in storage", zap.String("uploadID", task.UploadID))
+				return fmt.Errorf("upload service: upload id not found in storage: %w", xerr.ErrUploadSessionNotFound)
+			}
+			logger.Error("UploadComplete: Failed to list parts", zap.Error(err), zap.String("uploadID", task.UploadID))
+			return fmt.Errorf("upload service: failed to list parts: %w", xerr.ErrStorageError)
+		}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	storedETags := make(map[int]string, len(stored))
+	for _, part := range stored {
+		storedETags[part.PartNumber] = part.ETag
+	}
+
+	// 文件大小已知时按协商的分片大小计算总数,否则以记录和存储中最大的分片号为准
+	total := 0
+	if task.FileSize > 0 && task.ChunkSize > 0 {
+		total = int(ceilDiv(task.FileSize, task.ChunkSize))
+	} else {
+		for partNumber := range storedETags {
+			total = max(total, partNumber)
+		}
+		for partNumberStr := range recorded {
+			if partNumber, err := strconv.Atoi(partNumberStr); err == nil {
+				total = max(total, partNumber)
+			}
+		}
+	}
+	if total == 0 {
+		return nil, &PartsMismatchError{Report: models.UploadPartsReport{MissingParts: []int{1}, MismatchedParts: []int{}}}
+	}
+
+	report := models.UploadPartsReport{TotalChunks: total, MissingParts: []int{}, MismatchedParts: []int{}}
+	parts := make([]storage.UploadPartResult, 0, total)
+	for partNumber := 1; partNumber <= total; partNumber++ {
+		storedETag, ok := storedETags[partNumber]
+		if !ok {
+			report.MissingParts = append(report.MissingParts, partNumber)
+			continue
+		}
+		if recordedETag, ok := recorded[strconv.Itoa(partNumber)]; ok && normalizeETag(recordedETag) != normalizeETag(storedETag) {
+			report.MismatchedParts = append(report.MismatchedParts, partNumber)
+			continue
+		}
+		parts = append(parts, storage.UploadPartResult{PartNumber: partNumber, ETag: storedETag})
+	}
+
+	if len(report.MissingParts) > 0 || len(report.MismatchedParts) > 0 {
+		logger.Warn("UploadComplete: Parts verification failed",
+			zap.String("uploadID", task.UploadID),
+			zap.Int("totalChunks", total),
+			zap.Ints("missingParts", report.MissingParts),
+			zap.Ints("mismatchedParts", report.MismatchedParts))
+		return nil, &PartsMismatchError{Report: report}
+	}
+	return parts, nil
+}
+
+// normalizeETag 去掉 ETag 的引号并统一大小写,不同存储后端返回的格式不完全一致
+func normalizeETag(etag string) string {
+	return strings.ToLower(strings.Trim(etag, `"`))
+}
@@ -0,0 +1,189 @@
+package explorer
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"go.uber.org/zap"
+)
+
+// defaultProofSize 内容证明默认抽取的字节数
+const defaultProofSize = 64 << 10
+
+// proofChallengeTTL 挑战的有效期,与上传会话的缓存保持一致
+const proofChallengeTTL = 24 * time.Hour
+
+// proofChallenge 保存在 Redis 中的挑战。SourceFileID 为 0 表示下发时没有匹配到可秒传的内容
+type proofChallenge struct {
+	Challenge    models.UploadProofChallenge `json:"challenge"`
+	FileSize     int64                       `json:"file_size"`
+	SourceFileID uint64                      `json:"source_file_id"`
+}
+
+// dedupScope 返回配置的秒传匹配范围,未配置或无法识别时按 global 处理
+func dedupScope(cfg config.UploadDedupConfig) string {
+	switch cfg.Scope {
+	case models.DedupScopeUser, models.DedupScopeOff:
+		return cfg.Scope
+	default:
+		return models.DedupScopeGlobal
+	}
+}
+
+// crossUserDedupAllowed 检查用户是否参与跨用户秒传,查询失败时按不参与处理
+func (s *uploadService) crossUserDedupAllowed(ctx context.Context, userID uint64) bool {
+	if s.deps.Users == nil {
+		return true
+	}
+	user, err := s.deps.Users.GetUserByID(ctx, userID)
+	if err != nil {
+		logger.Warn("UploadInit: Failed to get user dedup setting, cross-user dedup disabled", zap.Uint64("userID", userID), zap.Error(err))
+		return false
+	}
+	return !user.DedupOptOut
+}
+
+// issueProofChallenge 为上传会话生成内容证明挑战。抽取范围按客户端声明的文件大小随机选择,
+// 只有声明的大小与已有内容一致时才记录秒传来源。生成失败时不下发挑战,客户端正常上传即可
+func (s *uploadService) issueProofChallenge(ctx context.Context, userID uint64, uploadID string, req *models.UploadInitRequest) *models.UploadProofChallenge {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		logger.Warn("UploadInit: Failed to generate proof salt", zap.Error(err))
+		return nil
+	}
+
+	fileSize := max(req.FileSize, 0)
+	length := min(valueOrDefault(s.deps.Config.Upload.Dedup.ProofSize, defaultProofSize), fileSize)
+	var offset int64
+	if fileSize > length {
+		n, err := rand.Int(rand.Reader, big.NewInt(fileSize-length+1))
+		if err != nil {
+			logger.Warn("UploadInit: Failed to generate proof offset", zap.Error(err))
+			return nil
+		}
+		offset = n.Int64()
+	}
+
+	stored := proofChallenge{
+		Challenge: models.UploadProofChallenge{
+			Salt:   hex.EncodeToString(salt),
+			Offset: offset,
+			Length: length,
+		},
+		FileSize: fileSize,
+	}
+	source, err := s.fileRepo.FindSharedFileBySHA256Hash(ctx, userID, strings.ToLower(req.FileSHA256))
+	switch {
+	case err == nil:
+		if int64(source.Size) == fileSize {
+			stored.SourceFileID = source.ID
+		}
+	case !errors.Is(err, xerr.ErrFileNotFound):
+		logger.Warn("UploadInit: Failed to find shared file by sha256", zap.Error(err), zap.String("sha256", req.FileSHA256))
+	}
+
+	if err := s.deps.Cache.Set(ctx, generateProofKey(userID, uploadID), stored, proofChallengeTTL); err != nil {
+		logger.Warn("UploadInit: Failed to save proof challenge", zap.Error(err), zap.String("uploadID", uploadID))
+		return nil
+	}
+	return &stored.Challenge
+}
+
+// SubmitUploadProof 校验内容证明。挑战只能使用一次,没有可秒传的内容和证明不正确返回同样的错误,
+// 通过后中止原有的上传会话,并以同一个 UploadID 生成秒传会话
+func (s *uploadService) SubmitUploadProof(ctx context.Context, userID uint64, req *models.UploadProofRequest) (*models.UploadInitResponse, error) {
+	key := generateProofKey(userID, req.UploadID)
+	var stored proofChallenge
+	if err := s.deps.Cache.Get(ctx, key, &stored); err != nil {
+		if errors.Is(err, cache.ErrCacheMiss) {
+			return nil, fmt.Errorf("upload service: proof challenge not found: %w", xerr.ErrUploadSessionNotFound)
+		}
+		return nil, fmt.Errorf("upload service: failed to get proof challenge: %w", err)
+	}
+	_ = s.deps.Cache.Del(ctx, key)
+
+	if stored.SourceFileID == 0 {
+		return nil, fmt.Errorf("upload service: %w", xerr.ErrUploadProofRejected)
+	}
+	source, err := s.fileRepo.FindByID(ctx, stored.SourceFileID)
+	if err != nil || source.Status != 1 || int64(source.Size) != stored.FileSize || source.OssBucket == nil || source.OssKey == nil {
+		return nil, fmt.Errorf("upload service: %w", xerr.ErrUploadProofRejected)
+	}
+
+	expected, err := s.computeProof(ctx, source, stored.Challenge)
+	if err != nil {
+		logger.Error("SubmitUploadProof: Failed to compute expected proof", zap.Uint64("sourceFileID", source.ID), zap.Error(err))
+		return nil, fmt.Errorf("upload service: failed to compute proof: %w", err)
+	}
+	proof, err := hex.DecodeString(req.Proof)
+	if err != nil || subtle.ConstantTimeCompare(proof, expected) != 1 {
+		logger.Warn("SubmitUploadProof: Proof mismatch", zap.Uint64("userID", userID), zap.String("uploadID", req.UploadID))
+		return nil, fmt.Errorf("upload service: %w", xerr.ErrUploadProofRejected)
+	}
+
+	// 释放已开始的常规上传,之后完成接口按秒传处理同一个 UploadID
+	if err := s.AbortUpload(ctx, userID, req.UploadID); err != nil && !errors.Is(err, xerr.ErrUploadSessionNotFound) {
+		logger.Warn("SubmitUploadProof: Failed to abort regular upload session", zap.String("uploadID", req.UploadID), zap.Error(err))
+	}
+	if !s.saveInstantUpload(ctx, userID, req.UploadID, source) {
+		return nil, fmt.Errorf("upload service: failed to save instant upload session: %w", xerr.ErrInternalServer)
+	}
+
+	return &models.UploadInitResponse{
+		FileExists:    true,
+		UploadID:      req.UploadID,
+		UploadedParts: []models.UploadPartInfo{},
+		Strategy:      models.UploadStrategyInstant,
+	}, nil
+}
+
+// computeProof 读取存储中挑战范围内的内容,计算 SHA-256(salt + 内容)
+func (s *uploadService) computeProof(ctx context.Context, source *models.File, challenge models.UploadProofChallenge) ([]byte, error) {
+	salt, err := hex.DecodeString(challenge.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	var versionID string
+	if source.VersionID != nil {
+		versionID = *source.VersionID
+	}
+	object, err := s.storage.GetObject(ctx, *source.OssBucket, *source.OssKey, versionID)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Reader.Close()
+
+	if seeker, ok := object.Reader.(io.Seeker); ok {
+		if _, err := seeker.Seek(challenge.Offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+	} else if _, err := io.CopyN(io.Discard, object.Reader, challenge.Offset); err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	h.Write(salt)
+	if _, err := io.CopyN(h, object.Reader, challenge.Length); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+func generateProofKey(userID uint64, uploadID string) string {
+	return fmt.Sprintf("upload:%d:%s:proof", userID, uploadID)
+}
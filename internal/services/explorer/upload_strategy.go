@@ -0,0 +1,104 @@
+package explorer
+
+import (
+	"fmt"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+)
+
+// 上传策略默认值,配置缺省时使用
+const (
+	defaultSinglePutThreshold int64 = 5 << 20
+	defaultLargeFileThreshold int64 = 1 << 30
+	defaultChunkSize          int64 = 5 << 20 // S3 协议要求除最后一片外分片不小于 5MB
+	defaultLargeChunkSize     int64 = 16 << 20
+	defaultMaxParts           int64 = 10000
+)
+
+// uploadPlan 协商得到的上传方式
+type uploadPlan struct {
+	Strategy    string
+	ChunkSize   int64
+	TotalChunks int64
+}
+
+// negotiateUploadPlan 根据文件大小选择上传方式和分片大小:
+// 小文件单次 PUT,中等文件使用普通分片,大文件使用大分片,分片数超过上限时按上限反推分片大小
+func negotiateUploadPlan(cfg config.UploadConfig, fileSize int64) uploadPlan {
+	singlePutThreshold := valueOrDefault(cfg.SinglePutThreshold, defaultSinglePutThreshold)
+	largeFileThreshold := valueOrDefault(cfg.LargeFileThreshold, defaultLargeFileThreshold)
+	chunkSize := valueOrDefault(cfg.ChunkSize, defaultChunkSize)
+	largeChunkSize := valueOrDefault(cfg.LargeChunkSize, defaultLargeChunkSize)
+	maxParts := valueOrDefault(cfg.MaxParts, defaultMaxParts)
+
+	// 客户端未提供文件大小时无法预估,按普通分片处理
+	if fileSize <= 0 {
+		return uploadPlan{Strategy: models.UploadStrategyMultipart, ChunkSize: chunkSize}
+	}
+
+	if fileSize <= singlePutThreshold {
+		return uploadPlan{Strategy: models.UploadStrategySingle, ChunkSize: fileSize, TotalChunks: 1}
+	}
+
+	if fileSize > largeFileThreshold {
+		chunkSize = largeChunkSize
+	}
+	if ceilDiv(fileSize, chunkSize) > maxParts {
+		// 向上取整到 1MB,避免出现零碎的分片大小
+		chunkSize = ceilDiv(ceilDiv(fileSize, maxParts), 1<<20) << 20
+	}
+
+	return uploadPlan{
+		Strategy:    models.UploadStrategyMultipart,
+		ChunkSize:   chunkSize,
+		TotalChunks: ceilDiv(fileSize, chunkSize),
+	}
+}
+
+// validateChunk 检查分片是否符合协商的上传方式,除最后一片外分片大小必须等于协商值
+func validateChunk(task *models.MultipartUpload, chunkNumber int, chunkSize int64) error {
+	// 空文件以一个大小为 0 的分片上传
+	if chunkNumber < 1 || chunkSize < 0 || (chunkSize == 0 && chunkNumber != 1) {
+		return fmt.Errorf("upload service: invalid chunk %d with size %d: %w", chunkNumber, chunkSize, xerr.ErrChunkSizeInvalid)
+	}
+
+	// 旧的上传任务没有协商信息,不做校验
+	if task.ChunkSize <= 0 {
+		return nil
+	}
+
+	// 文件大小未知时只能限制分片不超过协商大小
+	if task.FileSize <= 0 {
+		if chunkSize > task.ChunkSize {
+			return fmt.Errorf("upload service: chunk %d size %d exceeds negotiated size %d: %w", chunkNumber, chunkSize, task.ChunkSize, xerr.ErrChunkSizeInvalid)
+		}
+		return nil
+	}
+
+	totalChunks := ceilDiv(task.FileSize, task.ChunkSize)
+	if int64(chunkNumber) > totalChunks {
+		return fmt.Errorf("upload service: chunk %d out of range, total %d: %w", chunkNumber, totalChunks, xerr.ErrChunkSizeInvalid)
+	}
+
+	expected := task.ChunkSize
+	if int64(chunkNumber) == totalChunks {
+		expected = task.FileSize - (totalChunks-1)*task.ChunkSize
+	}
+	if chunkSize != expected {
+		return fmt.Errorf("upload service: chunk %d size %d, expected %d: %w", chunkNumber, chunkSize, expected, xerr.ErrChunkSizeInvalid)
+	}
+	return nil
+}
+
+func valueOrDefault(value, fallback int64) int64 {
+	if value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+func ceilDiv(a, b int64) int64 {
+	return (a + b - 1) / b
+}
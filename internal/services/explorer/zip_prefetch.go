@@ -0,0 +1,120 @@
+package explorer
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"golang.org/x/sync/semaphore"
+)
+
+// zipPrefetcher 在写入当前 ZIP 条目的同时并发获取后续文件的内容。
+// 内存预算允许时整个文件读入内存,否则只提前建立读取流,读取仍在写入该条目时进行
+type zipPrefetcher struct {
+	open   func(ctx context.Context, file *models.File) (io.ReadCloser, error)
+	slots  chan struct{}       // 正在写入和已预取但未写入的文件数上限
+	memory *semaphore.Weighted // 已读入内存但未写入的内容总大小
+}
+
+// prefetchedFile 单个文件的预取结果,ready 关闭后 reader 和 err 可读
+type prefetchedFile struct {
+	ready    chan struct{}
+	acquired bool // 是否占用了预取名额,ctx 取消时未开始的预取不占用
+	reader   io.ReadCloser
+	err      error
+}
+
+// newZipPrefetcher 创建预取器,prefetch 为当前条目之外并发预取的文件数,0 表示顺序读取
+func newZipPrefetcher(open func(ctx context.Context, file *models.File) (io.ReadCloser, error), prefetch int, memoryLimit int64) *zipPrefetcher {
+	if prefetch < 0 {
+		prefetch = 0
+	}
+	if memoryLimit < 0 {
+		memoryLimit = 0
+	}
+	return &zipPrefetcher{
+		open:   open,
+		slots:  make(chan struct{}, prefetch+1),
+		memory: semaphore.NewWeighted(memoryLimit),
+	}
+}
+
+// start 按顺序为 files 启动预取,返回与 files 一一对应的结果。
+// 调用方必须对每个结果调用 release,ctx 取消后未开始的预取直接以 ctx 的错误结束
+func (p *zipPrefetcher) start(ctx context.Context, files []*models.File) []*prefetchedFile {
+	results := make([]*prefetchedFile, len(files))
+	for i := range results {
+		results[i] = &prefetchedFile{ready: make(chan struct{})}
+	}
+
+	go func() {
+		for i, file := range files {
+			select {
+			case p.slots <- struct{}{}:
+			case <-ctx.Done():
+				for _, result := range results[i:] {
+					result.err = ctx.Err()
+					close(result.ready)
+				}
+				return
+			}
+			results[i].acquired = true
+			go p.fetch(ctx, file, results[i])
+		}
+	}()
+	return results
+}
+
+func (p *zipPrefetcher) fetch(ctx context.Context, file *models.File, result *prefetchedFile) {
+	defer close(result.ready)
+
+	reader, err := p.open(ctx, file)
+	if err != nil {
+		result.err = err
+		return
+	}
+
+	size := int64(file.Size)
+	if !p.memory.TryAcquire(size) {
+		result.reader = reader
+		return
+	}
+	defer reader.Close()
+
+	buf := bytes.NewBuffer(make([]byte, 0, size))
+	if _, err := io.Copy(buf, reader); err != nil {
+		p.memory.Release(size)
+		result.err = err
+		return
+	}
+	result.reader = &bufferedEntry{Reader: bytes.NewReader(buf.Bytes()), release: func() { p.memory.Release(size) }}
+}
+
+// wait 等待预取完成
+func (p *zipPrefetcher) wait(result *prefetchedFile) (io.ReadCloser, error) {
+	<-result.ready
+	return result.reader, result.err
+}
+
+// release 关闭读取器并让出预取名额
+func (p *zipPrefetcher) release(result *prefetchedFile) {
+	<-result.ready
+	if result.reader != nil {
+		result.reader.Close()
+	}
+	if result.acquired {
+		<-p.slots
+	}
+}
+
+// bufferedEntry 已读入内存的文件内容,Close 时归还内存预算
+type bufferedEntry struct {
+	*bytes.Reader
+	release func()
+}
+
+func (b *bufferedEntry) Close() error {
+	b.release()
+	return nil
+}
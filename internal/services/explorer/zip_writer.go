@@ -0,0 +1,177 @@
+package explorer
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// zipEntry ZIP 中的一项,Name 为条目在 ZIP 中的路径,File 为文件夹时只创建目录项
+type zipEntry struct {
+	Name string
+	File *models.File
+}
+
+// ChecksumManifestName 打包下载时附加的校验清单文件名,与顶层条目重名时自动添加后缀
+const ChecksumManifestName = "SHA256SUMS.txt"
+
+// writeZipArchive 按顺序把 entries 写成 ZIP 到 w,文件内容由预取器并发获取。
+// 没有物理文件、已隔离和读取失败的文件会被跳过,写入 w 失败时返回错误。
+// checksums 为 true 时在最后附加 ChecksumManifestName,列出实际写入的每个文件的 SHA-256 和大小
+func writeZipArchive(ctx context.Context, w io.Writer, entries []zipEntry, prefetcher *zipPrefetcher, checksums bool) error {
+	zipWriter := zip.NewWriter(w)
+	var written []zipEntry
+
+	// 跳过没有物理文件和已隔离的文件,其余文件按 ZIP 中的顺序交给预取器并发获取
+	var contentFiles []*models.File
+	for _, entry := range entries {
+		fileRecord := entry.File
+		if fileRecord.IsFolder == 1 {
+			continue
+		}
+		if fileRecord.OssKey == nil || *fileRecord.OssKey == "" {
+			logger.Warn("writeZipArchive: 文件记录缺少存储键 OssKey,在 ZIP 中跳过",
+				zap.Uint64("fileID", fileRecord.ID),
+				zap.String("fileName", fileRecord.FileName))
+			continue // 跳过没有物理文件的记录
+		}
+		if ensureNotQuarantined(fileRecord) != nil {
+			logger.Warn("writeZipArchive: 文件已被隔离,在 ZIP 中跳过",
+				zap.Uint64("fileID", fileRecord.ID),
+				zap.String("fileName", fileRecord.FileName))
+			continue
+		}
+		contentFiles = append(contentFiles, fileRecord)
+	}
+
+	prefetchCtx, cancelPrefetch := context.WithCancel(ctx)
+	pending := prefetcher.start(prefetchCtx, contentFiles)
+	next := 0
+	// 提前退出时取消剩余的预取并关闭已获取的读取器
+	defer func() {
+		cancelPrefetch()
+		for _, result := range pending[next:] {
+			prefetcher.release(result)
+		}
+	}()
+
+	for _, entry := range entries {
+		// 客户端断开或请求超时后不再继续从存储读取
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fileRecord := entry.File
+		relativePath := entry.Name
+
+		// 如果是文件夹，则在 ZIP 中创建对应的目录项
+		if fileRecord.IsFolder == 1 {
+			if !strings.HasSuffix(relativePath, "/") {
+				relativePath += "/"
+			}
+
+			if _, err := zipWriter.Create(relativePath); err != nil {
+				return fmt.Errorf("failed to create folder entry %s: %w", relativePath, err)
+			}
+			continue
+		}
+
+		// 如果是文件，从预取结果中获取内容并写入 ZIP
+		if next >= len(contentFiles) || contentFiles[next].ID != fileRecord.ID {
+			continue // 预处理时已跳过
+		}
+		result := pending[next]
+		next++
+
+		// 使用一个匿名函数来封装文件读取和写入 ZIP 的逻辑，确保 defer 能够及时执行
+		err := func() error {
+			defer prefetcher.release(result) // 关闭读取器并让出预取名额
+
+			fileContentReader, getErr := prefetcher.wait(result)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if getErr != nil {
+				logger.Error("writeZipArchive: 获取文件内容读取器失败",
+					zap.Uint64("fileID", fileRecord.ID),
+					zap.String("ossKey", *fileRecord.OssKey),
+					zap.Error(getErr))
+				return nil // 跳过读取失败的文件
+			}
+
+			// 创建 ZIP 文件头
+			header := &zip.FileHeader{
+				Name:     relativePath,
+				Method:   zip.Deflate,          // 默认使用 Deflate 压缩方法
+				Modified: fileRecord.UpdatedAt, // 使用文件更新时间
+			}
+			if fileRecord.Size > 0 {
+				header.UncompressedSize64 = uint64(fileRecord.Size) // 确保类型匹配
+			}
+
+			writer, err := zipWriter.CreateHeader(header)
+			if err != nil {
+				return fmt.Errorf("为 %s 创建 ZIP 头失败: %w", relativePath, err)
+			}
+
+			// 将文件内容从读取器复制到 ZIP 写入器
+			if _, err := io.Copy(writer, fileContentReader); err != nil {
+				return fmt.Errorf("复制 %s 内容到 ZIP 失败: %w", relativePath, err)
+			}
+			written = append(written, entry)
+			return nil
+		}() // 立即执行匿名函数
+		if err != nil {
+			return err
+		}
+	}
+
+	if checksums {
+		if err := writeChecksumManifest(zipWriter, entries, written); err != nil {
+			return err
+		}
+	}
+
+	// 所有文件处理完毕后，关闭 zipWriter 写入中央目录
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to close zip writer: %w", err)
+	}
+	return nil
+}
+
+// writeChecksumManifest 按数据库中记录的哈希生成 sha256sum 格式的校验清单,可以直接用 `sha256sum -c` 校验。
+// 每个文件前有一行以 # 开头的注释记录大小,没有记录哈希的文件只写注释
+func writeChecksumManifest(zipWriter *zip.Writer, entries []zipEntry, written []zipEntry) error {
+	used := make(map[string]bool)
+	for _, entry := range entries {
+		top, _, _ := strings.Cut(entry.Name, "/")
+		used[strings.ToLower(top)] = true
+	}
+	name := uniqueZipName(ChecksumManifestName, false, used)
+
+	var b strings.Builder
+	b.WriteString("# SHA-256 checksums from stored file metadata. Verify with: sha256sum -c " + name + "\n")
+	for _, entry := range written {
+		fmt.Fprintf(&b, "# %d bytes\n", entry.File.Size)
+		if hash := stringValue(entry.File.SHA256Hash); hash != "" {
+			fmt.Fprintf(&b, "%s  %s\n", hash, entry.Name)
+		} else {
+			fmt.Fprintf(&b, "# no checksum recorded: %s\n", entry.Name)
+		}
+	}
+
+	writer, err := zipWriter.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to create checksum manifest: %w", err)
+	}
+	if _, err := io.WriteString(writer, b.String()); err != nil {
+		return fmt.Errorf("failed to write checksum manifest: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package share
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/authz"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
+	"go.uber.org/zap"
+)
+
+// AccessQueueName 分享访问日志消息队列名称
+const AccessQueueName = "share_access_queue"
+
+const (
+	defaultAnalyticsDays = 30
+	maxAnalyticsDays     = 90
+	topReferrerLimit     = 10
+)
+
+// ShareAnalyticsService 定义了分享访问统计服务需要实现的接口
+type ShareAnalyticsService interface {
+	// RecordAccess 异步记录一次分享访问,IP 从 ctx 中获取。记录失败不会影响下载
+	RecordAccess(ctx context.Context, shareID uint64, action, userAgent, referer string)
+	// GetAnalytics 获取分享最近 days 天的每日访问次数和主要来源,只有分享者可以查看
+	GetAnalytics(ctx context.Context, userID, shareID uint64, days int) (*models.ShareAnalytics, error)
+}
+
+type shareAnalyticsService struct {
+	accessRepo repositories.ShareAccessLogRepository
+	shareRepo  repositories.ShareRepository
+	outbox     repositories.OutboxRepository
+	authorizer authz.Authorizer
+	downloads  explorer.DownloadCountService
+}
+
+var _ ShareAnalyticsService = (*shareAnalyticsService)(nil)
+
+// NewShareAnalyticsService 创建一个新的 ShareAnalyticsService 实例
+func NewShareAnalyticsService(accessRepo repositories.ShareAccessLogRepository, shareRepo repositories.ShareRepository, outbox repositories.OutboxRepository, authorizer authz.Authorizer, downloads explorer.DownloadCountService) ShareAnalyticsService {
+	return &shareAnalyticsService{
+		accessRepo: accessRepo,
+		shareRepo:  shareRepo,
+		outbox:     outbox,
+		authorizer: authorizer,
+		downloads:  downloads,
+	}
+}
+
+func (s *shareAnalyticsService) RecordAccess(ctx context.Context, shareID uint64, action, userAgent, referer string) {
+	record := models.ShareAccessLog{
+		ShareID:     shareID,
+		Action:      action,
+		IP:          utils.ClientIPFromContext(ctx),
+		UserAgent:   truncate(userAgent, 512),
+		Referer:     truncate(referer, 1024),
+		RefererHost: truncate(refererHost(referer), 255),
+		CreatedAt:   time.Now(),
+	}
+
+	event, err := models.NewQueueEvent(AccessQueueName, record)
+	if err != nil {
+		logger.Error("RecordAccess: Failed to marshal share access log", zap.Uint64("shareID", shareID), zap.Error(err))
+		return
+	}
+	if err := s.outbox.Add(context.WithoutCancel(ctx), event); err != nil {
+		logger.Error("RecordAccess: Failed to publish share access log", zap.Uint64("shareID", shareID), zap.Error(err))
+	}
+}
+
+func (s *shareAnalyticsService) GetAnalytics(ctx context.Context, userID, shareID uint64, days int) (*models.ShareAnalytics, error) {
+	if days <= 0 {
+		days = defaultAnalyticsDays
+	}
+	if days > maxAnalyticsDays {
+		days = maxAnalyticsDays
+	}
+
+	share, err := s.shareRepo.FindByID(ctx, shareID)
+	if err != nil {
+		logger.Error("GetAnalytics: Failed to query share", zap.Uint64("shareID", shareID), zap.Error(err))
+		return nil, fmt.Errorf("share service: %w", xerr.ErrDatabaseError)
+	}
+	if share == nil {
+		return nil, fmt.Errorf("share service: %w", xerr.ErrShareNotFound)
+	}
+	if err := s.authorizer.Authorize(ctx, userID, authz.ActionRead, authz.Record(share.UserID)); err != nil {
+		return nil, fmt.Errorf("share service: %w", err)
+	}
+
+	// 统计区间从 days-1 天前的零点开始,包含今天
+	now := time.Now()
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -(days - 1))
+
+	daily, err := s.accessRepo.DailyCounts(shareID, since)
+	if err != nil {
+		logger.Error("GetAnalytics: Failed to count daily access", zap.Uint64("shareID", shareID), zap.Error(err))
+		return nil, fmt.Errorf("share service: %w", xerr.ErrDatabaseError)
+	}
+	referrers, err := s.accessRepo.TopReferrers(shareID, since, topReferrerLimit)
+	if err != nil {
+		logger.Error("GetAnalytics: Failed to count referrers", zap.Uint64("shareID", shareID), zap.Error(err))
+		return nil, fmt.Errorf("share service: %w", xerr.ErrDatabaseError)
+	}
+
+	analytics := &models.ShareAnalytics{
+		ShareID:      shareID,
+		Days:         days,
+		Daily:        fillMissingDays(daily, since, days),
+		TopReferrers: referrers,
+	}
+	if analytics.TopReferrers == nil {
+		analytics.TopReferrers = []models.ShareRefererCount{}
+	}
+	for _, d := range analytics.Daily {
+		analytics.TotalViews += d.Views
+		analytics.TotalDownloads += d.Downloads
+	}
+	if downloads, err := s.downloads.GetDownloads(ctx, []uint64{share.FileID}); err == nil {
+		analytics.FileDownloads = downloads[share.FileID]
+	}
+	return analytics, nil
+}
+
+// fillMissingDays 补齐没有访问的日期,便于客户端直接绘制折线图
+func fillMissingDays(counts []models.ShareDailyCount, since time.Time, days int) []models.ShareDailyCount {
+	byDate := make(map[string]models.ShareDailyCount, len(counts))
+	for _, c := range counts {
+		byDate[c.Date] = c
+	}
+	result := make([]models.ShareDailyCount, 0, days)
+	for i := 0; i < days; i++ {
+		date := since.AddDate(0, 0, i).Format("2006-01-02")
+		c, ok := byDate[date]
+		if !ok {
+			c = models.ShareDailyCount{Date: date}
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+// refererHost 提取来源页面的域名,无法解析时返回空字符串
+func refererHost(referer string) string {
+	if referer == "" {
+		return ""
+	}
+	u, err := url.Parse(referer)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+func truncate(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	return strings.ToValidUTF8(s[:maxBytes], "")
+}
@@ -0,0 +1,241 @@
+package share
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/authz"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// CreateInternalShare 创建指定接收者的站内分享,recipient 为接收者的用户名或邮箱
+func (s *shareService) CreateInternalShare(ctx context.Context, userID uint64, fileID uint64, recipient string, permission string, expiresInMinutes *int) (*models.Share, error) {
+	if permission == "" {
+		permission = models.SharePermissionRead
+	}
+	if permission != models.SharePermissionRead && permission != models.SharePermissionDownload {
+		return nil, fmt.Errorf("share service: unknown permission %q: %w", permission, xerr.ErrInvalidParams)
+	}
+
+	file, err := s.fileRepo.FindByID(ctx, fileID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrFileNotFound) {
+			return nil, fmt.Errorf("share service: %w", xerr.ErrFileNotFound)
+		}
+		logger.Error("CreateInternalShare: 查询文件失败", zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, fmt.Errorf("share service: %w", xerr.ErrDatabaseError)
+	}
+	if err := s.authorizer.Authorize(ctx, userID, authz.ActionShare, authz.File(file)); err != nil {
+		return nil, fmt.Errorf("share service: %w", err)
+	}
+	if file.Status != models.StatusNormal || file.DeletedAt.Valid {
+		return nil, fmt.Errorf("share service: %w", xerr.ErrFileStatusInvalid)
+	}
+
+	target, err := s.findRecipient(ctx, recipient)
+	if err != nil {
+		return nil, err
+	}
+	if target.ID == userID {
+		return nil, fmt.Errorf("share service: cannot share with yourself: %w", xerr.ErrInvalidParams)
+	}
+
+	existingShare, err := s.shareRepo.FindByFileIDAndRecipientID(ctx, fileID, target.ID)
+	if err != nil {
+		logger.Error("CreateInternalShare: 检查现有站内分享失败", zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, fmt.Errorf("share service: %w", xerr.ErrDatabaseError)
+	}
+	if existingShare != nil {
+		return existingShare, fmt.Errorf("share service: %w", xerr.ErrShareAlreadyExists)
+	}
+
+	recipientID := target.ID
+	newShare := &models.Share{
+		UUID:        uuid.New().String(),
+		UserID:      userID,
+		FileID:      fileID,
+		Status:      1,
+		RecipientID: &recipientID,
+		Permission:  permission,
+	}
+	if expiresInMinutes != nil && *expiresInMinutes > 0 {
+		expiresAt := time.Now().Add(time.Duration(*expiresInMinutes) * time.Minute)
+		newShare.ExpiresAt = &expiresAt
+	}
+	if err := s.shareRepo.Create(ctx, newShare); err != nil {
+		logger.Error("CreateInternalShare: 创建站内分享记录失败", zap.Error(err))
+		return nil, fmt.Errorf("share service: %w", xerr.ErrDatabaseError)
+	}
+
+	logger.Info("CreateInternalShare: 站内分享创建成功",
+		zap.Uint64("shareID", newShare.ID),
+		zap.Uint64("fileID", fileID),
+		zap.Uint64("recipientID", recipientID),
+		zap.String("permission", permission))
+	s.activity.Record(ctx, userID, fileID, models.ActivityShareCreate, target.Username)
+
+	shareID := newShare.ID
+	s.notifications.Notify(ctx, &models.Notification{
+		UserID:     recipientID,
+		Type:       models.NotificationShareReceived,
+		Title:      "收到新的分享",
+		Body:       fmt.Sprintf("其他用户向你分享了「%s」", file.FileName),
+		ResourceID: &shareID,
+	})
+	return newShare, nil
+}
+
+// findRecipient 按邮箱或用户名查找站内分享的接收者。
+// 接收者不存在时返回和其他无效参数相同的错误,避免通过分享接口探测邮箱或用户名是否已注册
+func (s *shareService) findRecipient(ctx context.Context, recipient string) (*models.User, error) {
+	recipient = strings.TrimSpace(recipient)
+	if recipient == "" {
+		return nil, fmt.Errorf("share service: recipient is empty: %w", xerr.ErrInvalidParams)
+	}
+
+	var user *models.User
+	var err error
+	if strings.Contains(recipient, "@") {
+		user, err = s.userRepo.GetUserByEmail(ctx, recipient)
+	} else {
+		user, err = s.userRepo.GetUserByUsername(ctx, recipient)
+	}
+	if err != nil {
+		if errors.Is(err, xerr.ErrUserNotFound) {
+			return nil, fmt.Errorf("share service: recipient is not available: %w", xerr.ErrInvalidParams)
+		}
+		logger.Error("findRecipient: 查询接收者失败", zap.String("recipient", recipient), zap.Error(err))
+		return nil, fmt.Errorf("share service: %w", xerr.ErrDatabaseError)
+	}
+	return user, nil
+}
+
+// ListReceivedShares 列出其他用户分享给自己的站内分享,文件已被删除的分享不返回
+func (s *shareService) ListReceivedShares(ctx context.Context, userID uint64, page, pageSize int) ([]models.Share, int64, error) {
+	shares, total, err := s.shareRepo.FindAllByRecipientID(ctx, userID, page, pageSize)
+	if err != nil {
+		logger.Error("ListReceivedShares: 查询站内分享失败", zap.Uint64("userID", userID), zap.Error(err))
+		return nil, 0, fmt.Errorf("share service: %w", xerr.ErrDatabaseError)
+	}
+	return shares, total, nil
+}
+
+// ListReceivedShareFiles 浏览站内分享的文件夹,parentID 为 nil 时列出分享的文件夹本身,否则必须是其中的子文件夹
+func (s *shareService) ListReceivedShareFiles(ctx context.Context, userID uint64, shareID uint64, parentID *uint64, page, pageSize int) ([]models.File, int64, error) {
+	share, err := s.getReceivedShare(ctx, userID, shareID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if share.File.IsFolder != 1 {
+		return nil, 0, fmt.Errorf("share service: %w", xerr.ErrTargetNotFolder)
+	}
+
+	folder := share.File
+	if parentID != nil && *parentID != share.FileID {
+		if folder, err = s.resolveInShare(ctx, share, *parentID); err != nil {
+			return nil, 0, err
+		}
+		if folder.IsFolder != 1 {
+			return nil, 0, fmt.Errorf("share service: %w", xerr.ErrTargetNotFolder)
+		}
+	}
+
+	files, total, err := s.fileRepo.FindByUserIDAndParentFolderID(ctx, share.UserID, &folder.ID, models.FileListOptions{Page: page, PageSize: pageSize})
+	if err != nil {
+		logger.Error("ListReceivedShareFiles: 查询文件夹内容失败", zap.Uint64("shareID", shareID), zap.Uint64("folderID", folder.ID), zap.Error(err))
+		return nil, 0, fmt.Errorf("share service: %w", xerr.ErrDatabaseError)
+	}
+	return files, total, nil
+}
+
+// GetReceivedDownload 下载站内分享中的文件,需要下载权限。fileID 为 nil 时下载分享的文件或文件夹本身。
+// 文件返回预签名URL,文件夹返回 ZIP 读取器,由调用方关闭
+func (s *shareService) GetReceivedDownload(ctx context.Context, userID uint64, shareID uint64, fileID *uint64, inline bool) (*models.Share, *models.File, string, io.ReadCloser, error) {
+	share, err := s.getReceivedShare(ctx, userID, shareID)
+	if err != nil {
+		return nil, nil, "", nil, err
+	}
+	if !share.CanDownload() {
+		return nil, nil, "", nil, fmt.Errorf("share service: share %d is read only: %w", shareID, xerr.ErrPermissionDenied)
+	}
+
+	target := share.File
+	if fileID != nil && *fileID != share.FileID {
+		if target, err = s.resolveInShare(ctx, share, *fileID); err != nil {
+			return nil, nil, "", nil, err
+		}
+	}
+	s.activity.Record(ctx, share.UserID, target.ID, models.ActivityShareAccess, share.UUID)
+
+	// 以分享者的身份读取,与公开链接相同
+	if target.IsFolder == 1 {
+		_, reader, err := s.fileService.Download(ctx, share.UserID, target.ID, false)
+		if err != nil {
+			return nil, nil, "", nil, err
+		}
+		return share, target, "", reader, nil
+	}
+	presignedURL, err := s.fileService.GetPresignedURLForDownload(ctx, share.UserID, target.ID, inline)
+	if err != nil {
+		return nil, nil, "", nil, err
+	}
+	return share, target, presignedURL, nil, nil
+}
+
+// getReceivedShare 查找分享给 userID 的有效站内分享,不是接收者时与分享不存在的表现相同
+func (s *shareService) getReceivedShare(ctx context.Context, userID uint64, shareID uint64) (*models.Share, error) {
+	share, err := s.shareRepo.FindByID(ctx, shareID)
+	if err != nil {
+		logger.Error("getReceivedShare: 查询站内分享失败", zap.Uint64("shareID", shareID), zap.Error(err))
+		return nil, fmt.Errorf("share service: %w", xerr.ErrDatabaseError)
+	}
+	if share == nil || share.RecipientID == nil || *share.RecipientID != userID || share.Status != 1 {
+		return nil, fmt.Errorf("share service: %w", xerr.ErrShareNotFound)
+	}
+	if share.ExpiresAt != nil && time.Now().After(*share.ExpiresAt) {
+		return nil, fmt.Errorf("share service: share %d expired: %w", shareID, xerr.ErrShareNotFound)
+	}
+
+	file, err := s.domainService.CheckFile(ctx, share.UserID, share.FileID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrFileNotFound) || errors.Is(err, xerr.ErrFileStatusInvalid) {
+			return nil, fmt.Errorf("share service: shared file unavailable: %w", xerr.ErrShareNotFound)
+		}
+		return nil, err
+	}
+	share.File = file
+	return share, nil
+}
+
+// resolveInShare 查找分享的文件夹中的文件,不在该文件夹子树中的文件视为不存在
+func (s *shareService) resolveInShare(ctx context.Context, share *models.Share, fileID uint64) (*models.File, error) {
+	file, err := s.domainService.CheckFile(ctx, share.UserID, fileID)
+	if err != nil {
+		if errors.Is(err, xerr.ErrPermissionDenied) || errors.Is(err, xerr.ErrFileStatusInvalid) {
+			return nil, fmt.Errorf("share service: %w", xerr.ErrFileNotFound)
+		}
+		return nil, err
+	}
+
+	root := share.File
+	if root.IsFolder != 1 || file.UserID != root.UserID {
+		return nil, fmt.Errorf("share service: file %d is outside share %d: %w", fileID, share.ID, xerr.ErrFileNotFound)
+	}
+	inShare, err := s.fileRepo.IsInFolder(ctx, file, root.ID)
+	if err != nil {
+		logger.Error("resolveInShare: Failed to resolve folder ancestors", zap.Uint64("fileID", fileID), zap.Uint64("shareID", share.ID), zap.Error(err))
+		return nil, fmt.Errorf("share service: %w", xerr.ErrDatabaseError)
+	}
+	if !inShare {
+		return nil, fmt.Errorf("share service: file %d is outside share %d: %w", fileID, share.ID, xerr.ErrFileNotFound)
+	}
+	return file, nil
+}
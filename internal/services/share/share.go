@@ -9,8 +9,13 @@ import (
 
 	"github.com/3Eeeecho/go-clouddisk/internal/config"
 	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/mail"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
 	"github.com/3Eeeecho/go-clouddisk/internal/repositories"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/activity"
+	"github.com/3Eeeecho/go-clouddisk/internal/services/authz"
 	"github.com/3Eeeecho/go-clouddisk/internal/services/explorer"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -20,18 +25,31 @@ import (
 // ShareService 定义了文件分享服务需要实现的接口
 type ShareService interface {
 	// CreateShare 创建一个新的文件分享链接
-	CreateShare(ctx context.Context, userID uint64, fileID uint64, password *string, expiresInMinutes *int) (*models.Share, error)
+	CreateShare(ctx context.Context, userID uint64, fileID uint64, password *string, expiresInMinutes *int, direct bool) (*models.Share, error)
 	// GetShareByUUID 通过分享UUID获取分享详情，并验证密码
 	GetShareByUUID(ctx context.Context, uuid string, providedPassword *string) (*models.Share, error)
+	// GetDirectShare 获取直链分享，只有开启直链的单文件分享可以通过
+	GetDirectShare(ctx context.Context, uuid string) (*models.Share, error)
 	// ListUserShares 列出指定用户创建的所有分享链接
-	ListUserShares(userID uint64, page, pageSize int) ([]models.Share, int64, error)
+	ListUserShares(ctx context.Context, userID uint64, page, pageSize int) ([]models.Share, int64, error)
 	// RevokeShare 撤销一个分享链接
-	RevokeShare(userID uint64, shareID uint64) error
+	RevokeShare(ctx context.Context, userID uint64, shareID uint64) error
+	// RotateShare 重新生成分享链接的 UUID,旧链接立即失效,密码、有效期等设置保持不变
+	RotateShare(ctx context.Context, userID uint64, shareID uint64) (*models.Share, error)
 	// GetSharedFileContent 获取分享文件的内容读取器
 	GetSharedFileContent(ctx context.Context, share *models.Share) (io.ReadCloser, error)
 	// GetSharedFolderContent 获取分享文件夹（打包成zip）的内容读取器
 	GetSharedFolderContent(ctx context.Context, share *models.Share) (io.ReadCloser, error)
-	GetSharedFilePresignedURL(ctx context.Context, share *models.Share) (string, error)
+	GetSharedFilePresignedURL(ctx context.Context, share *models.Share, inline bool) (string, error)
+
+	// CreateInternalShare 将文件分享给指定的注册用户,recipient 为用户名或邮箱,permission 为 read 或 download
+	CreateInternalShare(ctx context.Context, userID uint64, fileID uint64, recipient string, permission string, expiresInMinutes *int) (*models.Share, error)
+	// ListReceivedShares 列出其他用户分享给自己的站内分享
+	ListReceivedShares(ctx context.Context, userID uint64, page, pageSize int) ([]models.Share, int64, error)
+	// ListReceivedShareFiles 浏览站内分享的文件夹,parentID 为 nil 时列出分享的文件夹本身的内容
+	ListReceivedShareFiles(ctx context.Context, userID uint64, shareID uint64, parentID *uint64, page, pageSize int) ([]models.File, int64, error)
+	// GetReceivedDownload 下载站内分享中的文件,文件返回预签名URL,文件夹返回 ZIP 读取器
+	GetReceivedDownload(ctx context.Context, userID uint64, shareID uint64, fileID *uint64, inline bool) (*models.Share, *models.File, string, io.ReadCloser, error)
 }
 
 // shareService 是 ShareService 接口的具体实现
@@ -40,45 +58,74 @@ type shareService struct {
 	fileRepo      repositories.FileRepository  // 文件数据仓库
 	fileService   explorer.FileService         // 文件核心服务，用于复用文件内容获取和文件夹打包逻辑
 	domainService explorer.FileDomainService   // 文件领域服务，处理文件相关的业务规则
+	activity      activity.ActivityService     // 活动日志服务
+	notifications activity.NotificationService // 站内通知
+	userRepo      repositories.UserRepository  // 用户数据仓库,用于通知分享者
+	cache         *cache.RedisCache            // 记录密码错误次数和锁定状态
+	mailer        mail.Sender                  // 分享被锁定时通知分享者
+	authorizer    authz.Authorizer             // 权限判断
 	cfg           *config.Config               // 全局配置
 }
 
 // NewShareService 创建一个新的 ShareService 实例
-func NewShareService(shareRepo repositories.ShareRepository, fileRepo repositories.FileRepository, fileService explorer.FileService, domainService explorer.FileDomainService, cfg *config.Config) ShareService {
+func NewShareService(shareRepo repositories.ShareRepository, fileRepo repositories.FileRepository, fileService explorer.FileService, domainService explorer.FileDomainService, activityService activity.ActivityService, notifications activity.NotificationService, userRepo repositories.UserRepository, redisCache *cache.RedisCache, mailer mail.Sender, authorizer authz.Authorizer, cfg *config.Config) ShareService {
 	return &shareService{
 		shareRepo:     shareRepo,
 		fileRepo:      fileRepo,
 		fileService:   fileService,
 		domainService: domainService,
+		activity:      activityService,
+		notifications: notifications,
+		userRepo:      userRepo,
+		cache:         redisCache,
+		mailer:        mailer,
+		authorizer:    authorizer,
 		cfg:           cfg,
 	}
 }
 
 // CreateShare 处理创建文件分享链接的业务逻辑
-func (s *shareService) CreateShare(ctx context.Context, userID uint64, fileID uint64, password *string, expiresInMinutes *int) (*models.Share, error) {
+func (s *shareService) CreateShare(ctx context.Context, userID uint64, fileID uint64, password *string, expiresInMinutes *int, direct bool) (*models.Share, error) {
 	// 1. 验证文件或文件夹是否存在，并且是否属于当前用户
-	file, err := s.fileRepo.FindByID(fileID)
+	file, err := s.fileRepo.FindByID(ctx, fileID)
 	if err != nil {
-		return nil, fmt.Errorf("文件或文件夹不存在或访问受限: %w", err)
+		if errors.Is(err, xerr.ErrFileNotFound) {
+			return nil, fmt.Errorf("share service: %w", xerr.ErrFileNotFound)
+		}
+		logger.Error("CreateShare: 查询文件失败", zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, fmt.Errorf("share service: %w", xerr.ErrDatabaseError)
 	}
-	if file.UserID != userID {
-		return nil, errors.New("无权分享此文件或文件夹")
+	if err := s.authorizer.Authorize(ctx, userID, authz.ActionShare, authz.File(file)); err != nil {
+		return nil, fmt.Errorf("share service: %w", err)
 	}
 	// 检查文件状态是否正常，例如文件不在回收站中
 	if file.Status != 1 || file.DeletedAt.Valid {
-		return nil, errors.New("文件或文件夹状态异常，无法分享")
+		return nil, fmt.Errorf("share service: %w", xerr.ErrFileStatusInvalid)
+	}
+	// 直链用于网页直接嵌入文件,只支持单个文件且不能设置密码
+	if direct {
+		if !s.cfg.Share.DirectLink.Enabled {
+			return nil, fmt.Errorf("share service: direct link is disabled: %w", xerr.ErrInvalidParams)
+		}
+		if file.IsFolder == 1 {
+			return nil, fmt.Errorf("share service: direct link only supports files: %w", xerr.ErrInvalidParams)
+		}
+		if password != nil && *password != "" {
+			return nil, fmt.Errorf("share service: direct link cannot have a password: %w", xerr.ErrInvalidParams)
+		}
 	}
 
 	// 2. 检查该文件是否已经存在一个有效的分享链接
-	existingShare, err := s.shareRepo.FindByFileIDAndUserID(fileID, userID)
+	existingShare, err := s.shareRepo.FindByFileIDAndUserID(ctx, fileID, userID)
 	if err != nil {
-		return nil, fmt.Errorf("检查现有分享链接失败: %w", err)
+		logger.Error("CreateShare: 检查现有分享链接失败", zap.Uint64("fileID", fileID), zap.Error(err))
+		return nil, fmt.Errorf("share service: %w", xerr.ErrDatabaseError)
 	}
 	if existingShare != nil {
 		// 如果已存在，可以选择返回现有链接，或者报错不允许重复分享
 		logger.Warn("CreateShare: 文件已存在有效分享链接",
 			zap.Uint64("fileID", fileID), zap.Uint64("shareID", existingShare.ID))
-		return existingShare, errors.New("此文件/文件夹已存在有效分享链接，请勿重复创建")
+		return existingShare, fmt.Errorf("share service: %w", xerr.ErrShareAlreadyExists)
 	}
 
 	// 构造新的分享记录
@@ -87,6 +134,7 @@ func (s *shareService) CreateShare(ctx context.Context, userID uint64, fileID ui
 		UserID: userID,
 		FileID: fileID,
 		Status: 1, // 初始状态为“可用”
+		Direct: direct,
 	}
 
 	// 3. 如果设置了密码，对密码进行哈希处理
@@ -107,7 +155,7 @@ func (s *shareService) CreateShare(ctx context.Context, userID uint64, fileID ui
 	}
 
 	// 5. 将新的分享记录保存到数据库
-	if err := s.shareRepo.Create(newShare); err != nil {
+	if err := s.shareRepo.Create(ctx, newShare); err != nil {
 		logger.Error("CreateShare: 创建分享链接记录失败", zap.Error(err))
 		return nil, fmt.Errorf("创建分享链接失败: %w", err)
 	}
@@ -116,6 +164,7 @@ func (s *shareService) CreateShare(ctx context.Context, userID uint64, fileID ui
 		zap.Uint64("shareID", newShare.ID),
 		zap.String("shareUUID", newShare.UUID),
 		zap.Uint64("fileID", fileID))
+	s.activity.Record(ctx, userID, fileID, models.ActivityShareCreate, newShare.UUID)
 	return newShare, nil
 }
 
@@ -124,54 +173,80 @@ func (s *shareService) GetShareByUUID(ctx context.Context, uuid string, provided
 	logger.Debug("GetShareByUUID called", zap.String("uuid", uuid))
 
 	// 从数据库中根据UUID查找分享记录
-	share, err := s.shareRepo.FindByUUID(uuid)
+	share, err := s.shareRepo.FindByUUID(ctx, uuid)
 	if err != nil {
 		return nil, fmt.Errorf("获取分享链接失败: %w", err)
 	}
 	if share == nil {
-		return nil, errors.New("分享链接不存在或已失效")
+		return nil, fmt.Errorf("分享链接不存在或已失效: %w", xerr.ErrShareNotFound)
 	}
 
 	// 1. 检查分享状态是否有效
 	if share.Status != 1 {
-		return nil, errors.New("分享链接已失效或被撤销")
+		return nil, fmt.Errorf("分享链接已失效或被撤销: %w", xerr.ErrShareNotFound)
 	}
 
 	// 2. 检查分享链接是否已过期
 	if share.ExpiresAt != nil && time.Now().After(*share.ExpiresAt) {
 		// 如果已过期，可以选择更新数据库中的状态（可以异步处理以优化性能）
 		share.Status = 0 // 设置为过期状态
-		s.shareRepo.Update(share)
-		return nil, errors.New("分享链接已过期")
+		s.shareRepo.Update(ctx, share)
+		return nil, fmt.Errorf("分享链接已过期: %w", xerr.ErrShareNotFound)
 	}
 
-	// 3. 如果分享链接设有密码，则校验提供的密码
+	// 3. 如果分享链接设有密码，则校验提供的密码。错误次数过多的分享暂时拒绝所有密码尝试
 	if share.Password != nil && *share.Password != "" {
 		if providedPassword == nil || *providedPassword == "" {
-			return nil, errors.New("该分享链接需要密码")
+			return nil, fmt.Errorf("该分享链接需要密码: %w", xerr.ErrSharePasswordRequired)
+		}
+		if err := s.checkPasswordLock(ctx, share); err != nil {
+			return nil, err
 		}
 		// 使用 bcrypt 对比哈希值和提供的密码
 		if err := bcrypt.CompareHashAndPassword([]byte(*share.Password), []byte(*providedPassword)); err != nil {
-			return nil, errors.New("分享密码不正确")
+			s.recordPasswordFailure(ctx, share)
+			return nil, fmt.Errorf("分享密码不正确: %w", xerr.ErrSharePasswordIncorrect)
 		}
+		s.resetPasswordFailures(ctx, share)
 	}
 
 	// 4. 异步增加访问次数，避免阻塞主流程
 	go func() {
 		share.AccessCount++
-		if err := s.shareRepo.Update(share); err != nil {
+		if err := s.shareRepo.Update(ctx, share); err != nil {
 			logger.Error("GetShareByUUID: 更新分享访问次数失败", zap.Uint64("shareID", share.ID), zap.Error(err))
 		}
 	}()
 
 	logger.Info("GetShareByUUID: 分享链接访问成功", zap.Uint64("shareID", share.ID))
+	s.activity.Record(ctx, share.UserID, share.FileID, models.ActivityShareAccess, share.UUID)
+	s.notifyShareAccess(ctx, share)
+	return share, nil
+}
+
+// GetDirectShare 获取直链分享,直链不需要密码,但仍然校验状态和过期时间
+func (s *shareService) GetDirectShare(ctx context.Context, uuid string) (*models.Share, error) {
+	if !s.cfg.Share.DirectLink.Enabled {
+		return nil, fmt.Errorf("share service: %w", xerr.ErrShareNotFound)
+	}
+
+	share, err := s.GetShareByUUID(ctx, uuid, nil)
+	if err != nil {
+		// 直链对外只区分可用与不可用,失效、过期等情况统一视为不存在
+		logger.Warn("GetDirectShare: 直链分享不可用", zap.String("uuid", uuid), zap.Error(err))
+		return nil, fmt.Errorf("share service: %w", xerr.ErrShareNotFound)
+	}
+	// 非直链分享对外表现为不存在,避免泄露分享是否存在
+	if !share.Direct || share.File == nil || share.File.IsFolder == 1 {
+		return nil, fmt.Errorf("share service: %w", xerr.ErrShareNotFound)
+	}
 	return share, nil
 }
 
 // ListUserShares 获取指定用户创建的所有分享链接列表（分页）
-func (s *shareService) ListUserShares(userID uint64, page, pageSize int) ([]models.Share, int64, error) {
+func (s *shareService) ListUserShares(ctx context.Context, userID uint64, page, pageSize int) ([]models.Share, int64, error) {
 	logger.Debug("ListUserShares called", zap.Uint64("userID", userID), zap.Int("page", page), zap.Int("pageSize", pageSize))
-	shares, total, err := s.shareRepo.FindAllByUserID(userID, page, pageSize)
+	shares, total, err := s.shareRepo.FindAllByUserID(ctx, userID, page, pageSize)
 	if err != nil {
 		logger.Error("ListUserShares: 查询用户分享列表失败", zap.Uint64("userID", userID), zap.Error(err))
 		return nil, 0, fmt.Errorf("查询分享列表失败: %w", err)
@@ -180,46 +255,78 @@ func (s *shareService) ListUserShares(userID uint64, page, pageSize int) ([]mode
 }
 
 // RevokeShare 撤销一个分享链接
-func (s *shareService) RevokeShare(userID uint64, shareID uint64) error {
+func (s *shareService) RevokeShare(ctx context.Context, userID uint64, shareID uint64) error {
 	logger.Debug("RevokeShare called", zap.Uint64("userID", userID), zap.Uint64("shareID", shareID))
 
 	// 1. 查找分享链接是否存在
-	share, err := s.shareRepo.FindByID(shareID)
+	share, err := s.shareRepo.FindByID(ctx, shareID)
 	if err != nil {
-		return fmt.Errorf("获取分享链接失败: %w", err)
+		logger.Error("RevokeShare: 查询分享链接失败", zap.Uint64("shareID", shareID), zap.Error(err))
+		return fmt.Errorf("share service: %w", xerr.ErrDatabaseError)
 	}
 	if share == nil {
-		return errors.New("分享链接不存在")
+		return fmt.Errorf("share service: %w", xerr.ErrShareNotFound)
 	}
 	// 2. 验证操作者是否为分享的创建者
-	if share.UserID != userID {
-		return errors.New("无权撤销此分享链接")
+	if err := s.authorizer.Authorize(ctx, userID, authz.ActionManage, authz.Record(share.UserID)); err != nil {
+		return fmt.Errorf("share service: %w", err)
 	}
 	// 3. 检查链接是否已经是失效状态
 	if share.Status == 0 {
-		return errors.New("分享链接已失效或已撤销")
+		return fmt.Errorf("share service: %w", xerr.ErrShareNotFound)
 	}
 
 	// 4. 更新状态并进行逻辑删除
 	share.Status = 0
-	if err := s.shareRepo.Update(share); err != nil {
+	if err := s.shareRepo.Update(ctx, share); err != nil {
 		logger.Error("RevokeShare: 更新分享链接状态失败", zap.Uint64("shareID", shareID), zap.Error(err))
-		return fmt.Errorf("撤销分享链接失败: %w", err)
+		return fmt.Errorf("share service: %w", xerr.ErrDatabaseError)
 	}
-	if err := s.shareRepo.Delete(shareID); err != nil {
+	if err := s.shareRepo.Delete(ctx, shareID); err != nil {
 		logger.Error("RevokeShare: 逻辑删除分享链接失败", zap.Uint64("shareID", shareID), zap.Error(err))
-		return fmt.Errorf("撤销分享链接失败: %w", err)
+		return fmt.Errorf("share service: %w", xerr.ErrDatabaseError)
 	}
 
 	logger.Info("RevokeShare: 分享链接撤销成功", zap.Uint64("shareID", shareID), zap.Uint64("userID", userID))
 	return nil
 }
 
+// RotateShare 处理重新生成分享链接的业务逻辑
+func (s *shareService) RotateShare(ctx context.Context, userID uint64, shareID uint64) (*models.Share, error) {
+	share, err := s.shareRepo.FindByID(ctx, shareID)
+	if err != nil {
+		logger.Error("RotateShare: 查询分享链接失败", zap.Uint64("shareID", shareID), zap.Error(err))
+		return nil, fmt.Errorf("share service: %w", xerr.ErrDatabaseError)
+	}
+	if share == nil {
+		return nil, fmt.Errorf("share service: %w", xerr.ErrShareNotFound)
+	}
+	if err := s.authorizer.Authorize(ctx, userID, authz.ActionManage, authz.Record(share.UserID)); err != nil {
+		return nil, fmt.Errorf("share service: %w", err)
+	}
+	// 已撤销或已过期的链接不能重新生成,需要重新创建分享
+	if share.Status != 1 || (share.ExpiresAt != nil && time.Now().After(*share.ExpiresAt)) {
+		return nil, fmt.Errorf("share service: %w", xerr.ErrShareNotFound)
+	}
+
+	oldUUID := share.UUID
+	share.UUID = uuid.New().String()
+	if err := s.shareRepo.Update(ctx, share); err != nil {
+		logger.Error("RotateShare: 更新分享链接失败", zap.Uint64("shareID", shareID), zap.Error(err))
+		return nil, fmt.Errorf("share service: %w", xerr.ErrDatabaseError)
+	}
+
+	logger.Info("RotateShare: 分享链接已重新生成",
+		zap.Uint64("shareID", shareID), zap.String("oldUUID", oldUUID), zap.String("newUUID", share.UUID))
+	s.activity.Record(ctx, userID, share.FileID, models.ActivityShareRotate, oldUUID+" -> "+share.UUID)
+	return share, nil
+}
+
 // GetSharedFileContent 获取分享的单个文件的内容读取器
 func (s *shareService) GetSharedFileContent(ctx context.Context, share *models.Share) (io.ReadCloser, error) {
 	// 如果分享对象中没有文件信息，则从数据库加载
 	if share.File == nil {
-		file, err := s.fileRepo.FindByID(share.FileID)
+		file, err := s.fileRepo.FindByID(ctx, share.FileID)
 		if err != nil {
 			return nil, fmt.Errorf("获取分享文件信息失败: %w", err)
 		}
@@ -228,11 +335,11 @@ func (s *shareService) GetSharedFileContent(ctx context.Context, share *models.S
 
 	// 确认分享的是文件而不是文件夹
 	if share.File.IsFolder == 1 {
-		return nil, errors.New("分享的是文件夹，请使用文件夹下载接口")
+		return nil, fmt.Errorf("share service: %w", xerr.ErrCannotDownloadFolder)
 	}
 
 	// 复用 FileService 的 Download 方法来获取文件内容的读取器
-	_, reader, err := s.fileService.Download(ctx, share.UserID, share.FileID)
+	_, reader, err := s.fileService.Download(ctx, share.UserID, share.FileID, false)
 	if err != nil {
 		logger.Error("GetSharedFileContent: 获取文件内容读取器失败",
 			zap.Uint64("fileID", share.File.ID), zap.String("shareUUID", share.UUID), zap.Error(err))
@@ -242,15 +349,15 @@ func (s *shareService) GetSharedFileContent(ctx context.Context, share *models.S
 }
 
 // GetSharedFilePresignedURL 获取分享文件的预签名URL
-func (s *shareService) GetSharedFilePresignedURL(ctx context.Context, share *models.Share) (string, error) {
+func (s *shareService) GetSharedFilePresignedURL(ctx context.Context, share *models.Share, inline bool) (string, error) {
 	// 确认分享的是文件而不是文件夹
 	if share.File.IsFolder == 1 {
-		return "", errors.New("分享的是文件夹，不支持生成预签名URL")
+		return "", fmt.Errorf("share service: %w", xerr.ErrCannotDownloadFolder)
 	}
 
 	// 调用 fileService 来生成预签名URL
 	// 注意：这里传递的是分享创建者 share.UserID，以确保有权限访问文件
-	presignedURL, err := s.fileService.GetPresignedURLForDownload(ctx, share.UserID, share.FileID)
+	presignedURL, err := s.fileService.GetPresignedURLForDownload(ctx, share.UserID, share.FileID, inline)
 	if err != nil {
 		logger.Error("GetSharedFilePresignedURL: 生成预签名URL失败",
 			zap.Uint64("fileID", share.File.ID),
@@ -266,7 +373,7 @@ func (s *shareService) GetSharedFilePresignedURL(ctx context.Context, share *mod
 func (s *shareService) GetSharedFolderContent(ctx context.Context, share *models.Share) (io.ReadCloser, error) {
 	// 如果分享对象中没有文件夹信息，则从数据库加载
 	if share.File == nil {
-		file, err := s.fileRepo.FindByID(share.FileID)
+		file, err := s.fileRepo.FindByID(ctx, share.FileID)
 		if err != nil {
 			return nil, fmt.Errorf("获取分享文件夹信息失败: %w", err)
 		}
@@ -275,12 +382,12 @@ func (s *shareService) GetSharedFolderContent(ctx context.Context, share *models
 
 	// 确认分享的是文件夹而不是文件
 	if share.File.IsFolder == 0 {
-		return nil, errors.New("分享的是文件，请使用文件下载接口")
+		return nil, fmt.Errorf("share service: %w", xerr.ErrTargetNotFolder)
 	}
 
 	// 复用 FileService 的 Download 方法来处理文件夹打包和获取内容读取器
 	// 注意：这里传递的是分享创建者 share.UserID，以确保有权限访问文件夹内容
-	_, reader, err := s.fileService.Download(ctx, share.UserID, share.File.ID)
+	_, reader, err := s.fileService.Download(ctx, share.UserID, share.File.ID, false)
 	if err != nil {
 		logger.Error("GetSharedFolderContent: 打包分享文件夹失败",
 			zap.Uint64("folderID", share.File.ID), zap.String("shareUUID", share.UUID), zap.Error(err))
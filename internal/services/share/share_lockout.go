@@ -0,0 +1,154 @@
+package share
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/cache"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/xerr"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultShareMaxAttempts  = 5
+	defaultShareLockWindow   = 15 // 分钟
+	defaultShareLockDuration = 15 // 分钟
+	shareLockNotifyTimeout   = 30 * time.Second
+)
+
+// sharePasswordFailScript 原子地增加错误次数,达到上限时写入锁定标记并清零计数,返回 {错误次数, 是否刚被锁定}
+// KEYS[1]: 错误次数  KEYS[2]: 锁定标记  ARGV: 窗口毫秒数, 次数上限, 锁定毫秒数
+var sharePasswordFailScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+if count >= tonumber(ARGV[2]) then
+	redis.call('SET', KEYS[2], '1', 'PX', ARGV[3])
+	redis.call('DEL', KEYS[1])
+	return {count, 1}
+end
+return {count, 0}
+`)
+
+// checkPasswordLock 分享因密码错误过多被锁定时返回 ErrSharePasswordLocked。Redis 不可用时放行,不影响正常访问
+func (s *shareService) checkPasswordLock(ctx context.Context, share *models.Share) error {
+	if !s.cfg.Share.PasswordLockout.Enabled {
+		return nil
+	}
+	locked, err := s.cache.Exists(ctx, cache.GenerateSharePasswordLockKey(share.ID))
+	if err != nil {
+		logger.Error("checkPasswordLock: Failed to check share lock", zap.Uint64("shareID", share.ID), zap.Error(err))
+		return nil
+	}
+	if locked {
+		return fmt.Errorf("share service: %w", xerr.ErrSharePasswordLocked)
+	}
+	return nil
+}
+
+// recordPasswordFailure 记录一次密码错误,达到上限时锁定分享,写入分享者的活动日志并按配置通知分享者
+func (s *shareService) recordPasswordFailure(ctx context.Context, share *models.Share) {
+	lockoutCfg := s.cfg.Share.PasswordLockout
+	if !lockoutCfg.Enabled {
+		return
+	}
+	maxAttempts := lockoutCfg.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultShareMaxAttempts
+	}
+	window := lockoutCfg.Window
+	if window <= 0 {
+		window = defaultShareLockWindow
+	}
+	duration := lockoutCfg.Duration
+	if duration <= 0 {
+		duration = defaultShareLockDuration
+	}
+
+	result, err := s.cache.RunScript(ctx, sharePasswordFailScript,
+		[]string{cache.GenerateSharePasswordFailKey(share.ID), cache.GenerateSharePasswordLockKey(share.ID)},
+		(time.Duration(window) * time.Minute).Milliseconds(), maxAttempts, (time.Duration(duration) * time.Minute).Milliseconds(),
+	).Result()
+	if err != nil {
+		logger.Error("recordPasswordFailure: Failed to count password failure", zap.Uint64("shareID", share.ID), zap.Error(err))
+		return
+	}
+	values, ok := result.([]any)
+	if !ok || len(values) != 2 {
+		logger.Error("recordPasswordFailure: Unexpected script result", zap.Any("result", result))
+		return
+	}
+	count, _ := values[0].(int64)
+	justLocked, _ := values[1].(int64)
+
+	ip := utils.ClientIPFromContext(ctx)
+	logger.Warn("recordPasswordFailure: Incorrect share password",
+		zap.Uint64("shareID", share.ID), zap.String("ip", ip), zap.Int64("failures", count))
+	if justLocked != 1 {
+		return
+	}
+
+	logger.Warn("recordPasswordFailure: Share locked after repeated password failures",
+		zap.Uint64("shareID", share.ID), zap.String("ip", ip), zap.Int("lockMinutes", duration))
+	s.activity.Record(ctx, share.UserID, share.FileID, models.ActivityShareLocked, fmt.Sprintf("%s (%d 次密码错误)", share.UUID, count))
+	if lockoutCfg.NotifyOwner {
+		go s.notifyShareLocked(share, ip, int(count), duration)
+	}
+}
+
+// resetPasswordFailures 密码正确后清零错误次数
+func (s *shareService) resetPasswordFailures(ctx context.Context, share *models.Share) {
+	if !s.cfg.Share.PasswordLockout.Enabled {
+		return
+	}
+	if err := s.cache.Del(ctx, cache.GenerateSharePasswordFailKey(share.ID)); err != nil {
+		logger.Error("resetPasswordFailures: Failed to reset password failures", zap.Uint64("shareID", share.ID), zap.Error(err))
+	}
+}
+
+// notifyShareAccess 通知分享者分享链接被访问,同一分享在 share_access_interval 内只通知一次
+func (s *shareService) notifyShareAccess(ctx context.Context, share *models.Share) {
+	fileName := ""
+	if share.File != nil {
+		fileName = share.File.FileName
+	}
+	fileID := share.FileID
+	interval := time.Duration(s.cfg.Notification.ShareAccessInterval) * time.Second
+	s.notifications.NotifyOnce(ctx, fmt.Sprintf("share_access:%d", share.ID), interval, &models.Notification{
+		UserID: share.UserID,
+		Type:   models.NotificationShareAccess,
+		Title:  "你的分享链接被访问",
+		Body:   fmt.Sprintf("分享链接 %s（%s）被访问", share.UUID, fileName),
+		FileID: &fileID,
+	})
+}
+
+// notifyShareLocked 给分享者发送分享被锁定的邮件,在请求之外执行,失败只记录日志
+func (s *shareService) notifyShareLocked(share *models.Share, ip string, failures, duration int) {
+	ctx, cancel := context.WithTimeout(context.Background(), shareLockNotifyTimeout)
+	defer cancel()
+
+	owner, err := s.userRepo.GetUserByID(ctx, share.UserID)
+	if err != nil || owner.Email == "" {
+		logger.Warn("notifyShareLocked: Owner email unavailable", zap.Uint64("userID", share.UserID), zap.Error(err))
+		return
+	}
+
+	fileName := ""
+	if share.File != nil {
+		fileName = share.File.FileName
+	}
+	subject := "[go-clouddisk] 分享链接因密码错误次数过多被暂时锁定"
+	body := fmt.Sprintf("你的分享链接 %s（%s）连续 %d 次输入了错误的密码，最近一次来自 %s。\n\n"+
+		"该链接已被锁定 %d 分钟，期间任何人都无法通过密码访问。如果不是你本人或你分享的对象在尝试，建议撤销该分享或重新生成链接。\n",
+		share.UUID, fileName, failures, ip, duration)
+	if err := s.mailer.Send(ctx, owner.Email, subject, body); err != nil {
+		logger.Error("notifyShareLocked: Failed to send notification", zap.Uint64("shareID", share.ID), zap.Error(err))
+	}
+}
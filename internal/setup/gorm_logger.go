@@ -0,0 +1,96 @@
+package setup
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/3Eeeecho/go-clouddisk/internal/config"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	gormutils "gorm.io/gorm/utils"
+)
+
+// gormLogger 将 GORM 的日志写入 zap,超过阈值的查询记为慢查询,
+// 并附带发起查询的代码位置以及请求的路由和 handler
+type gormLogger struct {
+	level      gormlogger.LogLevel
+	threshold  time.Duration
+	sampleRate float64
+}
+
+var _ gormlogger.Interface = (*gormLogger)(nil)
+
+func newGormLogger(cfg config.SlowQueryConfig) *gormLogger {
+	return &gormLogger{
+		level:      gormlogger.Warn,
+		threshold:  time.Duration(cfg.Threshold) * time.Millisecond,
+		sampleRate: cfg.SampleRate,
+	}
+}
+
+func (l *gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	copied := *l
+	copied.level = level
+	return &copied
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, args ...any) {
+	if l.level >= gormlogger.Info {
+		logger.Sugar().Infof(msg, args...)
+	}
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, args ...any) {
+	if l.level >= gormlogger.Warn {
+		logger.Sugar().Warnf(msg, args...)
+	}
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, args ...any) {
+	if l.level >= gormlogger.Error {
+		logger.Sugar().Errorf(msg, args...)
+	}
+}
+
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && l.level >= gormlogger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		logger.Error("SQL query failed", append(l.fields(ctx, elapsed, fc), zap.Error(err))...)
+	case l.threshold > 0 && elapsed > l.threshold && l.level >= gormlogger.Warn && l.sampled():
+		logger.Warn("Slow SQL query", append(l.fields(ctx, elapsed, fc), zap.Duration("threshold", l.threshold))...)
+	case l.level >= gormlogger.Info:
+		logger.Debug("SQL query", l.fields(ctx, elapsed, fc)...)
+	}
+}
+
+func (l *gormLogger) fields(ctx context.Context, elapsed time.Duration, fc func() (string, int64)) []zap.Field {
+	sql, rows := fc()
+	fields := []zap.Field{
+		zap.String("sql", sql),
+		zap.Int64("rows", rows),
+		zap.Duration("elapsed", elapsed),
+		zap.String("caller", gormutils.FileWithLineNum()),
+	}
+	if info, ok := utils.RouteFromContext(ctx); ok {
+		fields = append(fields, zap.String("route", info.Route), zap.String("handler", info.Handler))
+	}
+	return fields
+}
+
+// sampled 按比例决定是否记录慢查询,sampleRate 不在 (0,1) 内时全部记录
+func (l *gormLogger) sampled() bool {
+	if l.sampleRate <= 0 || l.sampleRate >= 1 {
+		return true
+	}
+	return rand.Float64() < l.sampleRate
+}
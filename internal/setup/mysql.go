@@ -1,55 +1,121 @@
 package setup
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	"github.com/3Eeeecho/go-clouddisk/internal/config"
-	"github.com/3Eeeecho/go-clouddisk/internal/models"
+	"github.com/3Eeeecho/go-clouddisk/internal/migrations"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/uptrace/opentelemetry-go-extra/otelgorm"
 	"go.uber.org/zap"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 )
 
-// InitMySQL 初始化 MySQL 数据库连接
+// InitMySQL 初始化 MySQL 数据库连接,按配置执行迁移后校验表结构版本,与当前版本不一致时直接退出
 func InitMySQL(cfg *config.MySQLConfig) (*gorm.DB, error) {
-	var err error
-	db, err := gorm.Open(mysql.Open(cfg.DSN), &gorm.Config{})
+	db, err := OpenMySQL(cfg)
 	if err != nil {
 		logger.Fatal("Failed to connect to MySQL database", zap.Error(err))
 		return nil, err
 	}
 
-	sqlDB, err := db.DB()
+	// 迁移和校验在注册从库之前执行,保证读取的表结构来自主库
+	if err := migrateSchema(db, cfg); err != nil {
+		logger.Fatal("Database schema verification failed", zap.Error(err))
+		return nil, err
+	}
+
+	if len(cfg.Replicas) > 0 {
+		if err := useReplicas(db, cfg); err != nil {
+			logger.Fatal("Failed to register MySQL read replicas", zap.Error(err))
+			return nil, err
+		}
+		logger.Info("MySQL read replicas registered", zap.Int("replicas", len(cfg.Replicas)))
+	}
+
+	return db, nil
+}
+
+// OpenMySQL 连接主库并设置连接池,不执行迁移和校验,供 cmd/migrate 使用
+func OpenMySQL(cfg *config.MySQLConfig) (*gorm.DB, error) {
+	db, err := gorm.Open(mysql.Open(cfg.DSN), &gorm.Config{Logger: newGormLogger(cfg.SlowQuery)})
 	if err != nil {
-		logger.Fatal("Failed to get generic database object from GORM", zap.Error(err))
 		return nil, err
 	}
 
+	// 为每条 SQL 创建 span,需要调用方通过 WithContext 传入请求上下文
+	if err := db.Use(otelgorm.NewPlugin(otelgorm.WithoutMetrics())); err != nil {
+		return nil, fmt.Errorf("failed to register GORM tracing plugin: %w", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get generic database object from GORM: %w", err)
+	}
+
 	// 设置连接池参数
-	sqlDB.SetMaxIdleConns(10)  // 最大空闲连接数
-	sqlDB.SetMaxOpenConns(100) // 最大打开连接数
-	// sqlDB.SetConnMaxLifetime(time.Hour) // 连接最大复用时间
+	maxIdleConns, maxOpenConns := poolSize(cfg)
+	sqlDB.SetMaxIdleConns(maxIdleConns)
+	sqlDB.SetMaxOpenConns(maxOpenConns)
+	sqlDB.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime) * time.Second)
+	sqlDB.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTime) * time.Second)
 
 	logger.Info("成功连接MySQL数据库!")
+	return db, nil
+}
 
-	// 自动迁移数据库表结构
-	AutoMigrate(db)
+// migrateSchema auto_migrate 开启时执行未执行的迁移,之后校验表结构版本
+func migrateSchema(db *gorm.DB, cfg *config.MySQLConfig) error {
+	ctx := context.Background()
+	if cfg.AutoMigrate {
+		applied, err := migrations.Up(ctx, db)
+		if err != nil {
+			return err
+		}
+		for _, migration := range applied {
+			logger.Info("Database migration applied", zap.Uint("version", migration.Version), zap.String("name", migration.Name))
+		}
+	}
+	if err := migrations.Verify(ctx, db); err != nil {
+		return err
+	}
+	logger.Info("Database schema is up to date")
+	return nil
+}
 
-	return db, nil
+// useReplicas 注册读写分离插件: 查询随机分发到从库,写入、事务以及带 dbresolver.Write 子句的查询走主库
+func useReplicas(db *gorm.DB, cfg *config.MySQLConfig) error {
+	replicas := make([]gorm.Dialector, 0, len(cfg.Replicas))
+	for _, dsn := range cfg.Replicas {
+		replicas = append(replicas, mysql.Open(dsn))
+	}
+
+	maxIdleConns, maxOpenConns := poolSize(cfg)
+	resolver := dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   dbresolver.RandomPolicy{},
+	}).
+		SetMaxIdleConns(maxIdleConns).
+		SetMaxOpenConns(maxOpenConns).
+		SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime) * time.Second).
+		SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTime) * time.Second)
+	return db.Use(resolver)
 }
 
-// AutoMigrate 自动迁移数据库表结构
-func AutoMigrate(db *gorm.DB) {
-	err := db.AutoMigrate(
-		&models.User{},
-		&models.File{},
-		&models.Share{},
-		&models.FileVersion{},
-		&models.MultipartUpload{},
-	)
-	if err != nil {
-		logger.Fatal("Failed to auto migrate database tables", zap.Error(err))
+// poolSize 返回连接池大小,未配置时使用默认值
+func poolSize(cfg *config.MySQLConfig) (maxIdleConns, maxOpenConns int) {
+	maxIdleConns, maxOpenConns = cfg.MaxIdleConns, cfg.MaxOpenConns
+	if maxIdleConns <= 0 {
+		maxIdleConns = 10
+	}
+	if maxOpenConns <= 0 {
+		maxOpenConns = 100
 	}
-	logger.Info("Database tables migrated successfully!")
+	return maxIdleConns, maxOpenConns
 }
 
 // CloseMySQLDB 关闭数据库连接
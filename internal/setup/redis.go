@@ -6,6 +6,7 @@ import (
 
 	"github.com/3Eeeecho/go-clouddisk/internal/config"
 	"github.com/3Eeeecho/go-clouddisk/internal/pkg/logger"
+	"github.com/3Eeeecho/go-clouddisk/internal/pkg/tracing"
 	"github.com/go-redis/redis/v8"
 	"go.uber.org/zap"
 )
@@ -21,6 +22,8 @@ func InitRedis(ctx context.Context, cfg *config.Config) (*redis.Client, error) {
 		DialTimeout:  5 * time.Second,
 	})
 
+	redisClient.AddHook(tracing.RedisHook{})
+
 	_, err := redisClient.Ping(context.Background()).Result()
 	if err != nil {
 		logger.Fatal("Failed to connect to Redis", zap.Error(err))
@@ -32,8 +35,6 @@ func InitRedis(ctx context.Context, cfg *config.Config) (*redis.Client, error) {
 	//TODO 转移
 	// logger.Info("Starting cache update consumer...")
 	// go consumer.StartCacheUpdateConsumer(ctx, redisClient)
-	// logger.Info("Starting cache path Invalidation consumer...")
-	// go consumer.StartPathInvalidationConsumer(ctx, DB, redisClient)
 	return redisClient, nil
 }
 
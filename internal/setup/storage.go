@@ -81,6 +81,26 @@ func InitAliyunOSSStorage(cfg *config.Config) (storage.StorageService, error) {
 	return aliyunSvc, nil
 }
 
+// InitS3Storage 初始化通用 S3 兼容存储服务，存储桶在服务创建时检查并创建。
+func InitS3Storage(cfg *config.Config) (storage.StorageService, error) {
+	s3Svc, err := storage.NewS3StorageService(&cfg.S3)
+	if err != nil {
+		return nil, fmt.Errorf("初始化 S3 存储服务失败: %w", err)
+	}
+	logger.Info("S3 存储服务已选择并初始化", zap.String("bucketName", cfg.S3.BucketName))
+	return s3Svc, nil
+}
+
+// InitLocalStorage 初始化本地文件系统存储服务，根目录和存储桶目录在服务创建时创建。
+func InitLocalStorage(cfg *config.Config) (storage.StorageService, error) {
+	localSvc, err := storage.NewLocalStorageService(cfg.Storage.LocalBasePath, &cfg.Local)
+	if err != nil {
+		return nil, fmt.Errorf("初始化本地存储服务失败: %w", err)
+	}
+	logger.Info("本地存储服务已选择并初始化", zap.String("root", cfg.Storage.LocalBasePath))
+	return localSvc, nil
+}
+
 func InitStorage(cfg *config.Config) storage.StorageService {
 	var fileStorageService storage.StorageService
 	switch cfg.Storage.Type {
@@ -96,6 +116,18 @@ func InitStorage(cfg *config.Config) storage.StorageService {
 			logger.Fatal("初始化阿里云 OSS 存储服务失败", zap.Error(err))
 		}
 		fileStorageService = aliyunSvc
+	case "s3":
+		s3Svc, err := InitS3Storage(cfg)
+		if err != nil {
+			logger.Fatal("初始化 S3 存储服务失败", zap.Error(err))
+		}
+		fileStorageService = s3Svc
+	case "local":
+		localSvc, err := InitLocalStorage(cfg)
+		if err != nil {
+			logger.Fatal("初始化本地存储服务失败", zap.Error(err))
+		}
+		fileStorageService = localSvc
 	default:
 		logger.Fatal("未知的存储服务类型，请检查配置: " + cfg.Storage.Type)
 	}